@@ -1,13 +1,17 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/cayleygraph/cayley/quad/pquads"
+	"github.com/cayleygraph/cayley/server/http/model"
 )
 
 func New(addr string) *Client {
@@ -102,3 +106,42 @@ func (c *Client) QuadWriter() (quad.WriteCloser, error) {
 	}})
 	return qw, nil
 }
+
+func (c *Client) getJSON(ctx context.Context, addr string, out interface{}) error {
+	req, err := http.NewRequest("GET", addr, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.cli.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errRequestFailed{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Horizon returns the current horizon of the database.
+func (c *Client) Horizon(ctx context.Context) (int64, error) {
+	var out model.Horizon
+	if err := c.getJSON(ctx, c.url("/api/v2/horizon", nil), &out); err != nil {
+		return 0, err
+	}
+	return out.Horizon, nil
+}
+
+// LogEntries returns transactions recorded in the delta log with horizons in range (from, to].
+// The server may truncate the range, in which case the Next field of the result is set.
+func (c *Client) LogEntries(ctx context.Context, from, to int64) (*model.LogEntryList, error) {
+	var out model.LogEntryList
+	err := c.getJSON(ctx, c.url("/api/v2/log", map[string]string{
+		"from": strconv.FormatInt(from, 10),
+		"to":   strconv.FormatInt(to, 10),
+	}), &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
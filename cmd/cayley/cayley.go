@@ -37,6 +37,7 @@ import (
 	_ "github.com/cayleygraph/cayley/graph/all"
 
 	// Load all supported quad formats.
+	_ "github.com/cayleygraph/cayley/quad/csv"
 	_ "github.com/cayleygraph/cayley/quad/dot"
 	_ "github.com/cayleygraph/cayley/quad/gml"
 	_ "github.com/cayleygraph/cayley/quad/graphml"
@@ -44,11 +45,21 @@ import (
 	_ "github.com/cayleygraph/cayley/quad/jsonld"
 	_ "github.com/cayleygraph/cayley/quad/nquads"
 	_ "github.com/cayleygraph/cayley/quad/pquads"
+	_ "github.com/cayleygraph/cayley/quad/turtle"
+
+	// Load text indexes
+	_ "github.com/cayleygraph/cayley/graph/text/bleve"
+
+	// Load change data capture sinks
+	_ "github.com/cayleygraph/cayley/server/cdc/kafka"
+	_ "github.com/cayleygraph/cayley/server/cdc/nats"
 
 	// Load writer registry
 	_ "github.com/cayleygraph/cayley/writer"
+	_ "github.com/cayleygraph/cayley/writer/raft"
 
 	// Load supported query languages
+	_ "github.com/cayleygraph/cayley/query/cypher"
 	_ "github.com/cayleygraph/cayley/query/gizmo"
 	_ "github.com/cayleygraph/cayley/query/graphql"
 	_ "github.com/cayleygraph/cayley/query/mql"
@@ -68,6 +79,9 @@ var (
 			if _, ok := err.(viper.ConfigFileNotFoundError); !ok && err != nil {
 				return err
 			}
+			if err = command.ApplyConfig(cmd); err != nil {
+				return err
+			}
 			if conf := viper.ConfigFileUsed(); conf != "" {
 				wd, _ := os.Getwd()
 				if rel, _ := filepath.Rel(wd, conf); rel != "" && strings.Count(rel, "..") < 3 {
@@ -107,6 +121,9 @@ func init() {
 	// set config names and paths
 	viper.SetConfigName("cayley")
 	viper.SetEnvPrefix("cayley")
+	// options can be set with CAYLEY_STORE_BACKEND, CAYLEY_QUERY_TIMEOUT, etc
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
 	viper.AddConfigPath(".")
 	viper.AddConfigPath("$HOME/.cayley/")
 	viper.AddConfigPath("/etc/")
@@ -119,14 +136,28 @@ func init() {
 		command.NewInitDatabaseCmd(),
 		command.NewLoadDatabaseCmd(),
 		command.NewDumpDatabaseCmd(),
+		command.NewBackupCmd(),
+		command.NewRestoreCmd(),
 		command.NewUpgradeCmd(),
 		command.NewReplCmd(),
 		command.NewQueryCmd(),
 		command.NewHttpCmd(),
 		command.NewConvertCmd(),
 		command.NewDedupCommand(),
+		command.NewDedupeCmd(),
+		command.NewPurgeCmd(),
+		command.NewGCCmd(),
+		command.NewFsckCmd(),
+		command.NewMigrateCmd(),
+		command.NewStatsCmd(),
+		command.NewAlgoCmd(),
+		command.NewDiffCmd(),
+		command.NewBenchCmd(),
+		command.NewConfigCmd(),
+		command.NewNamespaceCmd(),
 	)
 	rootCmd.PersistentFlags().StringP("config", "c", "", "path to an explicit configuration file")
+	command.RegisterConfigFlags(rootCmd)
 
 	qnames := graph.QuadStores()
 	rootCmd.PersistentFlags().StringP("db", "d", "memstore", "database backend to use: "+strings.Join(qnames, ", "))
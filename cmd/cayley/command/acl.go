@@ -0,0 +1,55 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	keyACLGrants      = "acl.grants"
+	keyACLRolesHeader = "acl.roles_header"
+)
+
+type aclGrant struct {
+	Role   string   `mapstructure:"role"`
+	Graphs []string `mapstructure:"graphs"`
+	Access string   `mapstructure:"access"`
+}
+
+// aclPolicy reads access control grants from the config. It returns nil if there are no grants.
+func aclPolicy() (*acl.Policy, error) {
+	var list []aclGrant
+	if err := viper.UnmarshalKey(keyACLGrants, &list); err != nil {
+		return nil, fmt.Errorf("cannot parse %q config: %v", keyACLGrants, err)
+	} else if len(list) == 0 {
+		return nil, nil
+	}
+	grants := make([]acl.Grant, 0, len(list))
+	for _, g := range list {
+		if g.Role == "" {
+			return nil, fmt.Errorf("role is not set in %q config", keyACLGrants)
+		}
+		a, err := acl.ParseAccess(g.Access)
+		if err != nil {
+			return nil, fmt.Errorf("role %q: %v", g.Role, err)
+		}
+		gr := acl.Grant{Role: g.Role, Access: a}
+		for _, s := range g.Graphs {
+			switch s {
+			case "*":
+				gr.AllLabels = true
+			case "":
+				// default graph
+				gr.Labels = append(gr.Labels, nil)
+			default:
+				gr.Labels = append(gr.Labels, quad.StringToValue(s))
+			}
+		}
+		grants = append(grants, gr)
+	}
+	return acl.NewPolicy(grants...), nil
+}
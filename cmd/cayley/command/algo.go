@@ -0,0 +1,253 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph/algo"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func NewAlgoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "algo",
+		Short: "Run graph algorithms on the database.",
+		Long: `Run graph algorithms on the database.
+
+The graph is loaded into memory first: each quad is an edge from the subject to the object.
+Only IRIs and blank nodes are used as nodes, unless --literals is set. Use --pred to consider only some predicates.
+
+Results are printed, or stored back in the database as quads with a given predicate if --write is set.
+Previous results stored with the same predicate and label are replaced.`,
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringSlice("pred", nil, "predicates of edges to consider (all by default)")
+	flags.Bool("literals", false, "use literal values as nodes")
+	flags.String("write", "", "predicate to store results with")
+	flags.String("label", "", "label to store results with")
+	flags.Int("top", 20, "number of results to print (0 for all)")
+	flags.Int("workers", 0, "number of parallel workers (defaults to the number of CPUs)")
+	cmd.AddCommand(
+		newAlgoPageRankCmd(),
+		newAlgoComponentsCmd("wcc", "weakly", algo.WeaklyConnected),
+		newAlgoComponentsCmd("scc", "strongly", algo.StronglyConnected),
+		newAlgoDegreeCmd(),
+		newAlgoBetweennessCmd(),
+	)
+	return cmd
+}
+
+// algoCmd loads a snapshot of the database and runs a function on it.
+// If the function returns values, they are written back to the database, if requested.
+func algoCmd(cmd *cobra.Command, fnc func(ctx context.Context, s *algo.Snapshot) ([]quad.Value, error)) error {
+	printBackendInfo()
+	h, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	var opt algo.LoadOptions
+	opt.Literals, _ = cmd.Flags().GetBool("literals")
+	preds, _ := cmd.Flags().GetStringSlice("pred")
+	for _, p := range preds {
+		opt.Predicates = append(opt.Predicates, quad.IRI(p).Full())
+	}
+	s, err := algo.Load(ctx, h.QuadStore, &opt)
+	if err != nil {
+		return err
+	}
+	clog.Infof("loaded %d nodes and %d edges", s.Len(), s.Edges())
+	vals, err := fnc(ctx, s)
+	if err != nil || vals == nil {
+		return err
+	}
+	pred, _ := cmd.Flags().GetString("write")
+	if pred == "" {
+		return nil
+	}
+	var label quad.Value
+	if l, _ := cmd.Flags().GetString("label"); l != "" {
+		label = quad.IRI(l).Full()
+	}
+	if err = algo.Write(ctx, h.QuadStore, h.QuadWriter, s, quad.IRI(pred).Full(), label, vals); err != nil {
+		return err
+	}
+	fmt.Printf("stored results for %d nodes as %v\n", s.Len(), quad.IRI(pred).Full())
+	return nil
+}
+
+// printScores prints nodes with the highest scores.
+func printScores(w io.Writer, s *algo.Snapshot, scores []float64, top int) error {
+	idx := make([]int, len(scores))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return scores[idx[i]] > scores[idx[j]]
+	})
+	if top > 0 && len(idx) > top {
+		idx = idx[:top]
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, i := range idx {
+		fmt.Fprintf(tw, "%s\t%g\n", quad.StringOf(s.Node(i)), scores[i])
+	}
+	return tw.Flush()
+}
+
+func newAlgoPageRankCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pagerank",
+		Short: "Calculate PageRank of nodes.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return algoCmd(cmd, func(ctx context.Context, s *algo.Snapshot) ([]quad.Value, error) {
+				var opt algo.PageRankOptions
+				opt.Damping, _ = cmd.Flags().GetFloat64("damping")
+				opt.MaxIterations, _ = cmd.Flags().GetInt("iterations")
+				opt.Workers, _ = cmd.Flags().GetInt("workers")
+				rank, err := algo.PageRank(ctx, s, &opt)
+				if err != nil {
+					return nil, err
+				}
+				top, _ := cmd.Flags().GetInt("top")
+				if err = printScores(os.Stdout, s, rank, top); err != nil {
+					return nil, err
+				}
+				return algo.FloatValues(rank), nil
+			})
+		},
+	}
+	cmd.Flags().Float64("damping", 0.85, "probability of following a link")
+	cmd.Flags().Int("iterations", 100, "maximal number of iterations")
+	return cmd
+}
+
+func newAlgoComponentsCmd(name, kind string, fnc func(s *algo.Snapshot) []int) *cobra.Command {
+	return &cobra.Command{
+		Use:   name,
+		Short: fmt.Sprintf("Find %s connected components.", kind),
+		Long: fmt.Sprintf(`Find %s connected components.
+
+The number of components and the largest components are printed.
+Stored results are component numbers of each node.`, kind),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return algoCmd(cmd, func(ctx context.Context, s *algo.Snapshot) ([]quad.Value, error) {
+				comp := fnc(s)
+				sizes := algo.ComponentSizes(comp)
+				first := make([]int, len(sizes))
+				for i := len(comp) - 1; i >= 0; i-- {
+					first[comp[i]] = i
+				}
+				ids := make([]int, len(sizes))
+				for i := range ids {
+					ids[i] = i
+				}
+				sort.SliceStable(ids, func(i, j int) bool {
+					return sizes[ids[i]] > sizes[ids[j]]
+				})
+				top, _ := cmd.Flags().GetInt("top")
+				if top > 0 && len(ids) > top {
+					ids = ids[:top]
+				}
+				tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintf(tw, "components:\t%d\n\n", len(sizes))
+				fmt.Fprintf(tw, "component\tnodes\tfirst node\n")
+				for _, c := range ids {
+					fmt.Fprintf(tw, "%d\t%d\t%s\n", c, sizes[c], quad.StringOf(s.Node(first[c])))
+				}
+				if err := tw.Flush(); err != nil {
+					return nil, err
+				}
+				return algo.IntValues(comp), nil
+			})
+		},
+	}
+}
+
+func newAlgoDegreeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "degree",
+		Short: "Calculate degree centrality of nodes.",
+		Long: `Calculate degree centrality of nodes: the number of links, divided by the number of other nodes.
+
+Direction of links is selected with --dir: "in", "out" or "both".
+Use --count to get the number of links instead.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := quad.Any
+			switch d, _ := cmd.Flags().GetString("dir"); d {
+			case "in":
+				dir = quad.Object
+			case "out":
+				dir = quad.Subject
+			case "both":
+			default:
+				return fmt.Errorf("unsupported direction: %q", d)
+			}
+			count, _ := cmd.Flags().GetBool("count")
+			return algoCmd(cmd, func(ctx context.Context, s *algo.Snapshot) ([]quad.Value, error) {
+				var (
+					deg  []float64
+					vals []quad.Value
+				)
+				if count {
+					cnt := algo.Degree(s, dir)
+					deg = make([]float64, len(cnt))
+					for i, d := range cnt {
+						deg[i] = float64(d)
+					}
+					vals = algo.IntValues(cnt)
+				} else {
+					deg = algo.DegreeCentrality(s, dir)
+					vals = algo.FloatValues(deg)
+				}
+				top, _ := cmd.Flags().GetInt("top")
+				if err := printScores(os.Stdout, s, deg, top); err != nil {
+					return nil, err
+				}
+				return vals, nil
+			})
+		},
+	}
+	cmd.Flags().String("dir", "both", "direction of links to count")
+	cmd.Flags().Bool("count", false, "return the number of links instead of a normalized value")
+	return cmd
+}
+
+func newAlgoBetweennessCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "betweenness",
+		Short: "Calculate betweenness centrality of nodes.",
+		Long: `Calculate betweenness centrality of nodes: the number of shortest paths between other nodes passing through the node.
+
+The algorithm visits the whole graph from each node, thus it may be slow for large graphs.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return algoCmd(cmd, func(ctx context.Context, s *algo.Snapshot) ([]quad.Value, error) {
+				var opt algo.BetweennessOptions
+				opt.Normalized, _ = cmd.Flags().GetBool("normalized")
+				opt.Workers, _ = cmd.Flags().GetInt("workers")
+				bc, err := algo.Betweenness(ctx, s, &opt)
+				if err != nil {
+					return nil, err
+				}
+				top, _ := cmd.Flags().GetInt("top")
+				if err = printScores(os.Stdout, s, bc, top); err != nil {
+					return nil, err
+				}
+				return algo.FloatValues(bc), nil
+			})
+		},
+	}
+	cmd.Flags().Bool("normalized", false, "divide values by the number of node pairs")
+	return cmd
+}
@@ -0,0 +1,34 @@
+package command
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/audit"
+)
+
+const (
+	keyAuditSink       = "audit.sink"
+	keyAuditAddress    = "audit.address"
+	keyAuditRetention  = "audit.retention"
+	keyAuditOptions    = "audit.options"
+	keyAuditUserHeader = "audit.user_header"
+)
+
+// openAuditLog opens an audit log set in the config. It returns nil if the audit log is disabled.
+func openAuditLog() (*audit.Log, error) {
+	typ := viper.GetString(keyAuditSink)
+	if typ == "" {
+		return nil, nil
+	}
+	addr := viper.GetString(keyAuditAddress)
+	sink, err := audit.NewSink(typ, addr, graph.Options(viper.GetStringMap(keyAuditOptions)))
+	if err != nil {
+		return nil, err
+	}
+	clog.Infof("writing audit log to %s sink %q", typ, addr)
+	return audit.New(sink, audit.Config{
+		Retention: viper.GetDuration(keyAuditRetention),
+	}), nil
+}
@@ -0,0 +1,115 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/internal/auth"
+)
+
+const (
+	keyAuthKeys        = "auth.keys"
+	keyAuthJWT         = "auth.jwt"
+	keyAuthPermissions = "auth.permissions"
+)
+
+// adminAuth accepts http.admin_token while authentication is enabled. It is updated when the config is reloaded.
+var adminAuth *auth.AdminToken
+
+type authKey struct {
+	Key   string   `mapstructure:"key"`
+	Name  string   `mapstructure:"name"`
+	Roles []string `mapstructure:"roles"`
+}
+
+type authJWT struct {
+	Secret     string `mapstructure:"secret"`
+	PublicKey  string `mapstructure:"public_key"`
+	Issuer     string `mapstructure:"issuer"`
+	Audience   string `mapstructure:"audience"`
+	RolesClaim string `mapstructure:"roles_claim"`
+}
+
+type authPermission struct {
+	Role      string   `mapstructure:"role"`
+	Databases []string `mapstructure:"databases"`
+	Access    string   `mapstructure:"access"`
+}
+
+// authMiddleware reads authentication settings from the config and wraps the handler with them.
+// The handler is returned as is if neither API keys nor JWT validation are configured.
+func authMiddleware(h http.Handler) (http.Handler, error) {
+	var keys []authKey
+	if err := viper.UnmarshalKey(keyAuthKeys, &keys); err != nil {
+		return nil, fmt.Errorf("cannot parse %q config: %v", keyAuthKeys, err)
+	}
+	var jwt authJWT
+	if err := viper.UnmarshalKey(keyAuthJWT, &jwt); err != nil {
+		return nil, fmt.Errorf("cannot parse %q config: %v", keyAuthJWT, err)
+	}
+	if len(keys) == 0 && jwt.Secret == "" && jwt.PublicKey == "" {
+		return h, nil
+	}
+	var (
+		auths []auth.Authenticator
+		perms []auth.Permission
+	)
+	m := make(map[string]auth.Identity, len(keys))
+	for i, k := range keys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("key %d is not set in %q config", i, keyAuthKeys)
+		} else if _, ok := m[k.Key]; ok {
+			return nil, fmt.Errorf("duplicate key %q in %q config", k.Name, keyAuthKeys)
+		}
+		m[k.Key] = auth.Identity{Name: k.Name, Roles: k.Roles}
+	}
+	// admin token is checked first, since it may share the Authorization header with other credentials
+	adminAuth = auth.NewAdminToken(viper.GetString(keyAdminToken))
+	auths = append(auths, adminAuth)
+	perms = append(perms, auth.Permission{Role: auth.AdminTokenRole, Database: "*", Level: auth.Admin})
+	if len(m) != 0 {
+		auths = append(auths, auth.NewAPIKeys(m))
+	}
+	if jwt.Secret != "" || jwt.PublicKey != "" {
+		a := &auth.JWT{
+			Secret:     []byte(jwt.Secret),
+			Issuer:     jwt.Issuer,
+			Audience:   jwt.Audience,
+			RolesClaim: jwt.RolesClaim,
+		}
+		if jwt.PublicKey != "" {
+			data, err := ioutil.ReadFile(jwt.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			if a.PublicKey, err = auth.ParseRSAPublicKey(data); err != nil {
+				return nil, err
+			}
+		}
+		auths = append(auths, a)
+	}
+	var list []authPermission
+	if err := viper.UnmarshalKey(keyAuthPermissions, &list); err != nil {
+		return nil, fmt.Errorf("cannot parse %q config: %v", keyAuthPermissions, err)
+	}
+	for _, p := range list {
+		if p.Role == "" {
+			return nil, fmt.Errorf("role is not set in %q config", keyAuthPermissions)
+		}
+		lvl, err := auth.ParseLevel(p.Access)
+		if err != nil {
+			return nil, fmt.Errorf("role %q: %v", p.Role, err)
+		}
+		dbs := p.Databases
+		if len(dbs) == 0 {
+			dbs = []string{"*"}
+		}
+		for _, db := range dbs {
+			perms = append(perms, auth.Permission{Role: p.Role, Database: db, Level: lvl})
+		}
+	}
+	return auth.Middleware(h, auths, auth.NewPolicy(perms...)), nil
+}
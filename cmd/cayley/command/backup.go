@@ -0,0 +1,223 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+)
+
+// envBackupPassword is an environment variable with a password for backup encryption.
+const envBackupPassword = "CAYLEY_BACKUP_PASSWORD"
+
+func registerPasswordFlags(cmd *cobra.Command) {
+	cmd.Flags().String("password_file", "", "file with a password for backup encryption (the "+envBackupPassword+" variable is used if not set)")
+}
+
+// previousBackupHorizon reads the horizon of the database from the metadata of a backup archive.
+func previousBackupHorizon(path string, opt internal.BackupOptions) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, _, err := internal.ReadBackupInfo(f, opt)
+	if err != nil {
+		return 0, err
+	} else if info.Horizon == 0 {
+		return 0, fmt.Errorf("backup %q has no horizon; the database does not support incremental backups", path)
+	}
+	return info.Horizon, nil
+}
+
+// backupPassword reads the password set by --password_file or by the environment variable.
+func backupPassword(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("password_file"); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return os.Getenv(envBackupPassword), nil
+}
+
+func NewBackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Make a backup archive of the database.",
+		Long: `Make a single backup archive with metadata, namespaces and all data of the database.
+
+Key-value backends (bolt, leveldb, btree) write a consistent snapshot without blocking writes.
+Other backends dump all quads; the database should not be modified during the backup.
+If a password is set with --password_file or the ` + envBackupPassword + ` variable, the archive is encrypted.
+
+Incremental backups contain transactions recorded in the delta log after the previous backup
+(set with --incremental) or after a given horizon (set with --since). The database must be opened
+with the delta_log option.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printBackendInfo()
+			path, _ := cmd.Flags().GetString("output")
+			if path == "" && len(args) == 1 {
+				path = args[0]
+			}
+			if path == "" {
+				return errors.New("output file must be specified")
+			}
+			var opt internal.BackupOptions
+			opt.Compress, _ = cmd.Flags().GetBool("compress")
+			var err error
+			if opt.Password, err = backupPassword(cmd); err != nil {
+				return err
+			}
+			since := int64(-1)
+			if prev, _ := cmd.Flags().GetString("incremental"); prev != "" {
+				if since, err = previousBackupHorizon(prev, opt); err != nil {
+					return err
+				}
+			} else if cmd.Flags().Changed("since") {
+				since, _ = cmd.Flags().GetInt64("since")
+			}
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+
+			var w io.Writer = os.Stdout
+			if path != "-" {
+				f, err := os.Create(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			ctx, cancel := getContext()
+			defer cancel()
+			var info *internal.BackupInfo
+			if since >= 0 {
+				info, err = internal.BackupIncremental(ctx, w, h.QuadStore, viper.GetString(KeyBackend), since, opt)
+			} else {
+				info, err = internal.Backup(ctx, w, h.QuadStore, viper.GetString(KeyBackend), opt)
+			}
+			if err == nil && path != "-" {
+				err = w.(*os.File).Close()
+			}
+			if err != nil {
+				if path != "-" {
+					os.Remove(path)
+				}
+				return err
+			}
+			if info.Incremental() {
+				clog.Infof("incremental backup of horizons (%d, %d] written", info.Since, info.Horizon)
+			} else {
+				clog.Infof("backup of %d quads written in %q format", info.Quads, info.Format)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringP("output", "o", "", `backup file ("-" for stdout)`)
+	cmd.Flags().Bool("compress", true, "compress the backup with gzip")
+	cmd.Flags().String("incremental", "", "make an incremental backup with changes made after a given previous backup")
+	cmd.Flags().Int64("since", 0, "make an incremental backup with changes made after a given horizon")
+	registerPasswordFlags(cmd)
+	return cmd
+}
+
+func NewRestoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the database from a backup archive.",
+		Long: `Restore an empty database from a backup archive made by the backup command.
+
+Snapshots of key-value backends can be restored to any key-value backend. Quad dumps can be restored to any backend.
+The number of restored quads is verified, unless --verify=false is set.
+
+Incremental backups can be passed after the full one, in the order they were made, or applied later
+to a restored database. Use --until to replay changes only up to a given horizon (point-in-time recovery).`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printBackendInfo()
+			name := viper.GetString(KeyBackend)
+			if graph.IsRegistered(name) && !graph.IsPersistent(name) {
+				return ErrNotPersistent
+			}
+			var paths []string
+			if path, _ := cmd.Flags().GetString("input"); path != "" {
+				paths = append(paths, path)
+			}
+			paths = append(paths, args...)
+			if len(paths) == 0 {
+				return errors.New("backup file must be specified")
+			}
+			until, _ := cmd.Flags().GetInt64("until")
+			var (
+				opt internal.BackupOptions
+				err error
+			)
+			opt.Verify, _ = cmd.Flags().GetBool("verify")
+			if opt.Password, err = backupPassword(cmd); err != nil {
+				return err
+			}
+			if init, _ := cmd.Flags().GetBool("init"); init {
+				if err = initDatabase(); err != nil {
+					return err
+				}
+			}
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+
+			ctx, cancel := getContext()
+			defer cancel()
+			for _, path := range paths {
+				if err = restoreFile(ctx, path, h, opt, until); err != nil {
+					return fmt.Errorf("%s: %v", path, err)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringP("input", "i", "", `backup file ("-" for stdin)`)
+	cmd.Flags().Bool("init", false, "initialize the database before restoring")
+	cmd.Flags().Int64("until", 0, "only replay changes from incremental backups up to a given horizon (0 = all)")
+	cmd.Flags().Bool("verify", true, "check that the number of restored quads matches the backup")
+	registerPasswordFlags(cmd)
+	return cmd
+}
+
+// restoreFile restores a single backup archive.
+func restoreFile(ctx context.Context, path string, h *graph.Handle, opt internal.BackupOptions, until int64) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	info, err := internal.RestoreUntil(ctx, r, h.QuadStore, h.QuadWriter, opt, until)
+	if err != nil {
+		return err
+	}
+	if info.Incremental() {
+		clog.Infof("applied incremental backup of horizons (%d, %d] made at %v", info.Since, info.Horizon, info.Created)
+	} else {
+		clog.Infof("restored backup of %q backend made at %v", info.Backend, info.Created)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package command
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/internal"
+	"github.com/cayleygraph/cayley/internal/bench"
+)
+
+func NewBenchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Run a benchmark workload against the database.",
+		Long: `Loads data into the database and runs a mix of queries with given concurrency,
+reporting throughput and latency percentiles.
+
+Data is either loaded from a file (--load) or generated (--quads). If no queries are given,
+a default mix of Gizmo queries for generated data is used. Each query can be prefixed with
+a language name, e.g. "gizmo:g.V().All()".`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printBackendInfo()
+			p := mustSetupProfile(cmd)
+			defer mustFinishProfile(p)
+
+			if init, err := cmd.Flags().GetBool("init"); err != nil {
+				return err
+			} else if init {
+				if err = initDatabase(); err != nil {
+					return err
+				}
+			}
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+
+			out := cmd.OutOrStdout()
+			batch := viper.GetInt(KeyLoadBatch)
+			load, _ := cmd.Flags().GetString(flagLoad)
+			n, _ := cmd.Flags().GetInt("quads")
+			if load != "" && n > 0 {
+				return errors.New("either a file to load or a number of quads to generate should be set")
+			}
+			if load != "" {
+				typ, err := loadFormat(cmd)
+				if err != nil {
+					return err
+				}
+				qr, err := internal.QuadReaderFor(load, typ)
+				if err != nil {
+					return err
+				}
+				st, err := bench.Load(h.QuadWriter, qr, batch)
+				qr.Close()
+				if err != nil {
+					return err
+				}
+				bench.WriteReport(out, "load", st)
+			} else if n > 0 {
+				seed, _ := cmd.Flags().GetInt64("seed")
+				st, err := bench.Load(h.QuadWriter, bench.GenerateQuads(n, seed), batch)
+				if err != nil {
+					return err
+				}
+				bench.WriteReport(out, "load", st)
+			}
+
+			lang, _ := cmd.Flags().GetString("lang")
+			qstr, _ := cmd.Flags().GetStringArray("query")
+			queries := bench.DefaultQueries
+			if len(qstr) != 0 {
+				queries = make([]bench.Query, 0, len(qstr))
+				for _, s := range qstr {
+					queries = append(queries, bench.ParseQuery(s, lang))
+				}
+			}
+			var c bench.Config
+			c.Concurrency, _ = cmd.Flags().GetInt("concurrency")
+			c.Requests, _ = cmd.Flags().GetInt("requests")
+			c.Duration, _ = cmd.Flags().GetDuration("duration")
+			c.Limit, _ = cmd.Flags().GetInt("limit")
+			c.Timeout = viper.GetDuration(keyQueryTimeout)
+			if c.Requests <= 0 && c.Duration <= 0 {
+				return nil
+			}
+
+			ctx, cancel := getContext()
+			defer cancel()
+			st, err := bench.Run(ctx, h.QuadStore, queries, c)
+			if err != nil {
+				return err
+			}
+			bench.WriteReport(out, "query", st)
+			return nil
+		},
+	}
+	cmd.Flags().Bool("init", false, "initialize the database before using it")
+	registerLoadFlags(cmd)
+	cmd.Flags().Int("quads", 0, "number of random quads to generate and load")
+	cmd.Flags().Int64("seed", 1, "seed for generated data")
+	cmd.Flags().String("lang", "gizmo", "default query language")
+	cmd.Flags().StringArrayP("query", "q", nil, `query to run; can be repeated to run a mix of queries ("lang:" prefix overrides the language)`)
+	cmd.Flags().IntP("concurrency", "n", 1, "number of concurrent queries")
+	cmd.Flags().Int("requests", 1000, "total number of queries to run (0 = unlimited)")
+	cmd.Flags().Duration("duration", 0, "maximal time to run queries (0 = unlimited)")
+	cmd.Flags().Int("limit", 100, "maximal number of results for a single query")
+	cmd.Flags().DurationP("timeout", "t", 30*time.Second, "elapsed time until an individual query times out")
+	viper.BindPFlag(keyQueryTimeout, cmd.Flags().Lookup("timeout"))
+	return cmd
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/cdc"
+)
+
+const (
+	keyCDCSink    = "cdc.sink"
+	keyCDCAddress = "cdc.address"
+	keyCDCTopic   = "cdc.topic"
+	keyCDCFormat  = "cdc.format"
+	keyCDCName    = "cdc.name"
+	keyCDCOptions = "cdc.options"
+)
+
+// cdcRetryDelay is the time to wait before restarting a failed CDC publisher.
+const cdcRetryDelay = 5 * time.Second
+
+// startCDC starts publishing changes of the database to a sink set in the config, if any.
+// The publisher is restarted on errors and stops when the context is canceled.
+func startCDC(ctx context.Context, qs graph.QuadStore) error {
+	typ := viper.GetString(keyCDCSink)
+	if typ == "" {
+		return nil
+	}
+	sink, err := cdc.NewSink(typ, viper.GetString(keyCDCAddress), viper.GetString(keyCDCTopic),
+		graph.Options(viper.GetStringMap(keyCDCOptions)))
+	if err != nil {
+		return err
+	}
+	p, err := cdc.NewPublisher(qs, sink, cdc.Config{
+		Name:   viper.GetString(keyCDCName),
+		Format: viper.GetString(keyCDCFormat),
+		Redact: redactor(),
+	})
+	if err != nil {
+		sink.Close()
+		return err
+	}
+	go func() {
+		defer sink.Close()
+		for {
+			err := p.Run(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			clog.Errorf("cdc: publisher failed: %v; restarting in %v", err, cdcRetryDelay)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cdcRetryDelay):
+			}
+		}
+	}()
+	clog.Infof("publishing changes to %s %q", typ, viper.GetString(keyCDCTopic))
+	return nil
+}
@@ -0,0 +1,233 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// envPrefix is a prefix of environment variables that set config options.
+	envPrefix = "CAYLEY"
+	// flagSet is a name of the flag that overrides config options.
+	flagSet = "set"
+)
+
+// configOption is a config option known to Cayley.
+type configOption struct {
+	Key  string
+	Flag string // persistent flag bound to the option, if any
+	// Structured options are read from environment variables and --set as JSON.
+	Structured bool
+}
+
+// configOptions lists all known config options, in the order of the documentation.
+var configOptions = []configOption{
+	{Key: KeyBackend, Flag: "db"},
+	{Key: KeyAddress, Flag: "dbpath"},
+	{Key: KeyReadOnly, Flag: "read_only"},
+	{Key: KeyOptions, Structured: true},
+	{Key: KeyDatabases, Structured: true},
+	{Key: keyGraphsPath},
+	{Key: keyQueryTimeout},
+	{Key: keyQueryMaxResults},
+	{Key: keyQueryMaxMemory},
+	{Key: keyQueryMaxQuads},
+	{Key: keyQueryParallel, Flag: "parallel"},
+	{Key: keyAdminToken},
+	{Key: keyURLPrefix},
+	{Key: keyDrainTimeout},
+	{Key: keyStreamFlush},
+	{Key: keyCache},
+	{Key: keyAccessLog},
+	{Key: keyAccessLogSample},
+	{Key: keyLogLevel},
+	{Key: keyMetrics},
+	{Key: keyMetricsIterators},
+	{Key: keyTraceEndpoint},
+	{Key: keyTraceInsecure},
+	{Key: keyTraceSample},
+	{Key: keyGRPCAddress},
+	{Key: keyGRPCBatch},
+	{Key: keySweepInterval},
+	{Key: "load.ignore_duplicates", Flag: "dup"},
+	{Key: "load.ignore_missing", Flag: "missing"},
+	{Key: KeyLoadBatch, Flag: "batch"},
+}
+
+// overrides is a set of options changed by --set.
+var overrides = make(map[string]struct{})
+
+// EnvName returns the name of the environment variable for a config option.
+func EnvName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+func isStructured(key string) bool {
+	for _, o := range configOptions {
+		if o.Key == key {
+			return o.Structured
+		}
+	}
+	return false
+}
+
+// parseConfigValue decodes a value of a structured option from JSON.
+// Values of other options are returned as is and converted by viper on read.
+func parseConfigValue(key, val string) (interface{}, error) {
+	if !isStructured(key) {
+		return val, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(val), &v); err != nil {
+		return nil, fmt.Errorf("cannot parse %q option as JSON: %v", key, err)
+	}
+	return v, nil
+}
+
+// RegisterConfigFlags adds flags that control config options to the root command.
+func RegisterConfigFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringArray(flagSet, nil, "set a config option (key=value); structured options are set with JSON values")
+}
+
+// ApplyConfig applies config options set by environment variables and --set flags.
+// It must be called after reading the config file.
+//
+// Options are applied in the following order of precedence: --set and command line flags,
+// environment variables, config file and default values.
+func ApplyConfig(cmd *cobra.Command) error {
+	for _, o := range configOptions {
+		if !o.Structured {
+			// read by viper on request
+			continue
+		}
+		env, ok := os.LookupEnv(EnvName(o.Key))
+		if !ok {
+			continue
+		}
+		v, err := parseConfigValue(o.Key, env)
+		if err != nil {
+			return fmt.Errorf("%s: %v", EnvName(o.Key), err)
+		}
+		viper.Set(o.Key, v)
+	}
+	sets, err := cmd.Flags().GetStringArray(flagSet)
+	if err != nil {
+		return err
+	}
+	for _, s := range sets {
+		i := strings.Index(s, "=")
+		if i <= 0 {
+			return fmt.Errorf("expected key=value in --%s, got %q", flagSet, s)
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:i]))
+		v, err := parseConfigValue(key, s[i+1:])
+		if err != nil {
+			return err
+		}
+		viper.Set(key, v)
+		overrides[key] = struct{}{}
+	}
+	return nil
+}
+
+// configSource returns where the effective value of the option was set.
+func configSource(cmd *cobra.Command, o configOption) string {
+	if _, ok := overrides[o.Key]; ok {
+		return "flag"
+	}
+	if o.Flag != "" {
+		if f := cmd.Flags().Lookup(o.Flag); f != nil && f.Changed {
+			return "flag"
+		}
+	}
+	if _, ok := os.LookupEnv(EnvName(o.Key)); ok {
+		return "env"
+	}
+	if viper.InConfig(o.Key) {
+		return "file"
+	}
+	return "default"
+}
+
+// formatConfigValue formats an option value for printing. Secrets are not printed.
+func formatConfigValue(key string, v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if key == keyAdminToken {
+		if s, _ := v.(string); s != "" {
+			return "<hidden>"
+		}
+	}
+	switch v.(type) {
+	case map[string]interface{}, []interface{}, []map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+func NewConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration.",
+	}
+	cmd.AddCommand(newConfigShowCmd())
+	return cmd
+}
+
+func newConfigShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the configuration.",
+		Long: `Print the configuration file, or the effective value of each option with --effective.
+
+Each option can be set in the config file, with a ` + envPrefix + `_ environment variable (for example,
+` + EnvName(KeyBackend) + ` for ` + KeyBackend + `) or with --` + flagSet + ` key=value. Options are applied in the
+following order of precedence: flags, environment variables, config file and default values.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if eff, _ := cmd.Flags().GetBool("effective"); eff {
+				return printEffectiveConfig(cmd)
+			}
+			path := viper.ConfigFileUsed()
+			if path == "" {
+				return fmt.Errorf("no config file is used")
+			}
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				return fmt.Errorf("no config file is used")
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("# %s\n", path)
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+	cmd.Flags().Bool("effective", false, "print effective values of all options and where they were set")
+	return cmd
+}
+
+func printEffectiveConfig(cmd *cobra.Command) error {
+	if path := viper.ConfigFileUsed(); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			fmt.Printf("# config file: %s\n", path)
+		}
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tENV")
+	for _, o := range configOptions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", o.Key, formatConfigValue(o.Key, viper.Get(o.Key)), configSource(cmd, o), EnvName(o.Key))
+	}
+	return tw.Flush()
+}
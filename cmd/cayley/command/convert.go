@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"runtime"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/internal"
@@ -75,9 +77,20 @@ func NewConvertCmd() *cobra.Command {
 		Use:     "convert",
 		Aliases: []string{"conv"},
 		Short:   "Convert quad files between supported formats.",
+		Long: `Convert quad files between supported formats without loading them into a database.
+
+Quads are streamed from the input files to the output. Formats are detected by file extensions,
+and files compressed with gzip, bzip2 or zstd are decompressed. Output files with ".gz" and ".zst"
+extensions are compressed; zstd compression requires the zstd tool to be installed.
+Line-based formats (N-Quads) are parsed in parallel by multiple workers.`,
+		Example: `  cayley conv --in data.nq.gz --out data.pq.zst
+  cayley conv -i a.nq b.nq out.json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			dump, _ := cmd.Flags().GetString(flagDump)
-			dumpf, _ := cmd.Flags().GetString(flagDumpFormat)
+			dumpf, err := dumpFormat(cmd)
+			if err != nil {
+				return err
+			}
 			if dump == "" && len(args) > 0 {
 				i := len(args) - 1
 				dump, args = args[i], args[:i]
@@ -91,7 +104,11 @@ func NewConvertCmd() *cobra.Command {
 			if len(files) == 0 || dump == "" {
 				return errors.New("both input and output files must be specified")
 			}
-			loadf, _ := cmd.Flags().GetString(flagLoadFormat)
+			loadf, err := loadFormat(cmd)
+			if err != nil {
+				return err
+			}
+			workers, _ := cmd.Flags().GetInt("workers")
 			var multi multiReader
 			for _, path := range files {
 				path := path
@@ -101,7 +118,7 @@ func NewConvertCmd() *cobra.Command {
 					} else {
 						fmt.Printf("reading %q\n", path)
 					}
-					return internal.QuadReaderFor(path, loadf)
+					return internal.ParallelQuadReaderFor(path, loadf, workers)
 				}))
 			}
 			// TODO: print additional stats
@@ -110,5 +127,16 @@ func NewConvertCmd() *cobra.Command {
 	}
 	registerLoadFlags(cmd)
 	registerDumpFlags(cmd)
+	cmd.Flags().Int("workers", runtime.NumCPU(), "number of workers to parse line-based formats")
+	// allow --in and --out as more natural names for conversion
+	cmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		switch name {
+		case "in":
+			name = flagLoad
+		case "out":
+			name = flagDump
+		}
+		return pflag.NormalizedName(name)
+	})
 	return cmd
 }
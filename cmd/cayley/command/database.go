@@ -1,8 +1,10 @@
 package command
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"runtime"
 	"runtime/pprof"
@@ -17,6 +19,8 @@ import (
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/internal"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/csv"
+	"github.com/cayleygraph/cayley/quad/jsonld"
 )
 
 const (
@@ -26,21 +30,32 @@ const (
 	KeyReadOnly = "store.read_only"
 	KeyOptions  = "store.options"
 
+	// KeyReplication is a type of the replication manager used by the http and repl commands.
+	KeyReplication        = "replication"
+	KeyReplicationOptions = "replication_options"
+
+	// KeyDatabases is a list of additional named databases served by the http command.
+	KeyDatabases = "databases"
+
 	KeyLoadBatch = "load.batch"
 )
 
 const (
 	flagLoad       = "load"
 	flagLoadFormat = "load_format"
+	flagMapping    = "mapping"
 	flagDump       = "dump"
 	flagDumpFormat = "dump_format"
+	flagLdContext  = "ld_context"
+	flagLdFrame    = "ld_frame"
+	flagNoRedact   = "no_redact"
 )
 
 var ErrNotPersistent = errors.New("database type is not persistent")
 
 func registerLoadFlags(cmd *cobra.Command) {
 	// TODO: allow to load multiple files
-	cmd.Flags().StringP(flagLoad, "i", "", `quad file to load after initialization (".gz" supported, "-" for stdin)`)
+	cmd.Flags().StringP(flagLoad, "i", "", `quad file or URL to load after initialization (".gz" supported, "-" for stdin)`)
 	var names []string
 	for _, f := range quad.Formats() {
 		if f.Reader != nil {
@@ -49,6 +64,20 @@ func registerLoadFlags(cmd *cobra.Command) {
 	}
 	sort.Strings(names)
 	cmd.Flags().String(flagLoadFormat, "", `quad file format to use for loading instead of auto-detection ("`+strings.Join(names, `", "`)+`")`)
+	cmd.Flags().String(flagMapping, "", "JSON file with a column-to-quad mapping for CSV and TSV files")
+}
+
+// loadFormat returns a quad file format for loading and applies a table mapping, if it is set.
+func loadFormat(cmd *cobra.Command) (string, error) {
+	typ, _ := cmd.Flags().GetString(flagLoadFormat)
+	if path, _ := cmd.Flags().GetString(flagMapping); path != "" {
+		m, err := csv.LoadMapping(path)
+		if err != nil {
+			return "", err
+		}
+		csv.DefaultMapping = m
+	}
+	return typ, nil
 }
 
 func registerDumpFlags(cmd *cobra.Command) {
@@ -61,6 +90,37 @@ func registerDumpFlags(cmd *cobra.Command) {
 	}
 	sort.Strings(names)
 	cmd.Flags().String(flagDumpFormat, "", `quad file format to use instead of auto-detection ("`+strings.Join(names, `", "`)+`")`)
+	cmd.Flags().Bool(flagNoRedact, false, "do not apply redaction rules from the config to the dump")
+	cmd.Flags().String(flagLdContext, "", "JSON file with a @context to compact JSON-LD dumps")
+	cmd.Flags().String(flagLdFrame, "", "JSON file with a frame to output JSON-LD dumps as nested objects")
+}
+
+// dumpFormat returns a quad file format for dumping and applies JSON-LD context and frame, if they are set.
+func dumpFormat(cmd *cobra.Command) (string, error) {
+	typ, _ := cmd.Flags().GetString(flagDumpFormat)
+	for _, f := range []struct {
+		flag string
+		dst  *interface{}
+	}{
+		{flagLdContext, &jsonld.DefaultContext},
+		{flagLdFrame, &jsonld.DefaultFrame},
+	} {
+		path, _ := cmd.Flags().GetString(f.flag)
+		if path == "" {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		doc, err := jsonld.ReadDocument(file)
+		file.Close()
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", path, err)
+		}
+		*f.dst = doc
+	}
+	return typ, nil
 }
 
 func NewInitDatabaseCmd() *cobra.Command {
@@ -112,14 +172,28 @@ func NewLoadDatabaseCmd() *cobra.Command {
 			defer h.Close()
 
 			// TODO: check read-only flag in config before that?
-			typ, _ := cmd.Flags().GetString(flagLoadFormat)
-			if err = internal.Load(h.QuadWriter, quad.DefaultBatch, load, typ); err != nil {
+			opt := internal.LoadOptions{Batch: quad.DefaultBatch}
+			if opt.Format, err = loadFormat(cmd); err != nil {
+				return err
+			}
+			opt.Resume, _ = cmd.Flags().GetBool("resume")
+			opt.Bulk, _ = cmd.Flags().GetBool("bulk")
+			opt.Workers, _ = cmd.Flags().GetInt("workers")
+			if show, _ := cmd.Flags().GetBool("progress"); show {
+				opt.Progress = os.Stderr
+			}
+			ctx, cancel := getContext()
+			defer cancel()
+			if err = internal.LoadFile(ctx, h.QuadStore, h.QuadWriter, load, opt); err != nil {
 				return err
 			}
 
 			if dump, _ := cmd.Flags().GetString(flagDump); dump != "" {
-				typ, _ := cmd.Flags().GetString(flagDumpFormat)
-				if err = dumpDatabase(h, dump, typ); err != nil {
+				typ, err := dumpFormat(cmd)
+				if err != nil {
+					return err
+				}
+				if err = dumpDatabase(h, dump, typ, dumpRedactor(cmd)); err != nil {
 					return err
 				}
 			}
@@ -127,6 +201,10 @@ func NewLoadDatabaseCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().Bool("init", false, "initialize the database before using it")
+	cmd.Flags().Bool("resume", false, "continue an interrupted load of the same file from the checkpoint stored in the database")
+	cmd.Flags().Bool("progress", true, "print a progress bar to stderr")
+	cmd.Flags().Bool("bulk", false, "load with concurrent writers and build indexes after the load, if supported by the backend (cannot be resumed)")
+	cmd.Flags().Int("workers", runtime.NumCPU(), "number of concurrent writers for --bulk")
 	registerLoadFlags(cmd)
 	registerDumpFlags(cmd)
 	return cmd
@@ -151,11 +229,36 @@ func NewDumpDatabaseCmd() *cobra.Command {
 			}
 			defer h.Close()
 
-			typ, _ := cmd.Flags().GetString(flagDumpFormat)
-			return dumpDatabase(h, dump, typ)
+			typ, err := dumpFormat(cmd)
+			if err != nil {
+				return err
+			}
+			pattern, _ := cmd.Flags().GetString("pattern")
+			qu, _ := cmd.Flags().GetString("query")
+			if pattern == "" && qu == "" {
+				return dumpDatabase(h, dump, typ, dumpRedactor(cmd))
+			}
+			p, err := internal.ParsePattern(pattern)
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(qu, "@") {
+				data, err := ioutil.ReadFile(qu[1:])
+				if err != nil {
+					return err
+				}
+				qu = string(data)
+			}
+			lang, _ := cmd.Flags().GetString("lang")
+			ctx, cancel := getContext()
+			defer cancel()
+			return dumpFiltered(ctx, h, dump, typ, dumpRedactor(cmd), p, lang, qu)
 		},
 	}
 	registerDumpFlags(cmd)
+	cmd.Flags().String("pattern", "", `dump only quads matching a pattern in N-Quads notation ("*" matches any value), e.g. '* <follows> *'`)
+	cmd.Flags().String("query", "", `dump only quads with subjects returned by a query ("@file" reads the query from a file)`)
+	cmd.Flags().String("lang", "gizmo", "query language for --query")
 	return cmd
 }
 
@@ -198,10 +301,49 @@ func openDatabase() (*graph.Handle, error) {
 	name := viper.GetString(KeyBackend)
 	path := viper.GetString(KeyAddress)
 	opts := graph.Options(viper.GetStringMap(KeyOptions))
+	h, err := openStore(name, path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = setupIndexes(h, opts, ""); err != nil {
+		h.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// setupIndexes wraps the store of the handle with text, geospatial and vector indexes and inference set in the config.
+// Indexes of named databases are stored separately from the indexes of the main database (see indexPath).
+func setupIndexes(h *graph.Handle, opts graph.Options, db string) error {
+	for _, setup := range []func(h *graph.Handle, opts graph.Options, db string) error{
+		setupTextIndex, setupGeoIndex, setupVectorIndex, setupInference,
+	} {
+		if err := setup(h, opts, db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexPath returns a path of a persistent index set by the config key for a given database.
+// Named databases use the path with the name of the database as a suffix.
+func indexPath(key, db string) string {
+	path := viper.GetString(key)
+	if path == "" || db == "" {
+		return path
+	}
+	return path + "." + db
+}
+
+func openStore(name, path string, opts graph.Options) (*graph.Handle, error) {
 	qs, err := graph.NewQuadStore(name, path, opts)
 	if err != nil {
 		return nil, err
 	}
+	if err = graph.LoadNamespaces(context.TODO(), qs, nil); err != nil {
+		qs.Close()
+		return nil, err
+	}
 	qw, err := graph.NewQuadWriter("single", qs, opts)
 	if err != nil {
 		return nil, err
@@ -209,6 +351,22 @@ func openDatabase() (*graph.Handle, error) {
 	return &graph.Handle{QuadStore: qs, QuadWriter: qw}, nil
 }
 
+// setupReplication replaces the writer of the handle with a replication manager set in the config.
+func setupReplication(h *graph.Handle) error {
+	wtyp := viper.GetString(KeyReplication)
+	if wtyp == "" || wtyp == "single" {
+		return nil
+	}
+	opts := graph.Options(viper.GetStringMap(KeyReplicationOptions))
+	qw, err := graph.NewQuadWriter(wtyp, h.QuadStore, opts)
+	if err != nil {
+		return err
+	}
+	h.QuadWriter.Close()
+	h.QuadWriter = qw
+	return nil
+}
+
 func openForQueries(cmd *cobra.Command) (*graph.Handle, error) {
 	if init, err := cmd.Flags().GetBool("init"); err != nil {
 		return nil, err
@@ -231,6 +389,10 @@ func openForQueries(cmd *cobra.Command) (*graph.Handle, error) {
 	} else if err != nil {
 		return nil, err
 	}
+	if err = setupReplication(h); err != nil {
+		h.Close()
+		return nil, err
+	}
 
 	if load2, _ := cmd.Flags().GetString(flagLoad); load2 != "" {
 		if load != "" {
@@ -240,7 +402,11 @@ func openForQueries(cmd *cobra.Command) (*graph.Handle, error) {
 		load = load2
 	}
 	if load != "" {
-		typ, _ := cmd.Flags().GetString(flagLoadFormat)
+		typ, err := loadFormat(cmd)
+		if err != nil {
+			h.Close()
+			return nil, err
+		}
 		// TODO: check read-only flag in config before that?
 		start := time.Now()
 		if err = internal.Load(h.QuadWriter, quad.DefaultBatch, load, typ); err != nil {
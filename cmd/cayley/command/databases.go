@@ -0,0 +1,95 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+	chttp "github.com/cayleygraph/cayley/internal/http"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// namedDatabase is a configuration of an additional database served under /db/{name}/.
+type namedDatabase struct {
+	Name     string                 `mapstructure:"name"`
+	Backend  string                 `mapstructure:"backend"`
+	Address  string                 `mapstructure:"address"`
+	ReadOnly bool                   `mapstructure:"read_only"`
+	Options  map[string]interface{} `mapstructure:"options"`
+	Timeout  time.Duration          `mapstructure:"timeout"`
+}
+
+// namedDatabases reads configuration of additional databases.
+func namedDatabases() ([]namedDatabase, error) {
+	var dbs []namedDatabase
+	if err := viper.UnmarshalKey(KeyDatabases, &dbs); err != nil {
+		return nil, fmt.Errorf("cannot parse %q config: %v", KeyDatabases, err)
+	}
+	seen := make(map[string]struct{}, len(dbs))
+	for _, db := range dbs {
+		if db.Name == "" {
+			return nil, fmt.Errorf("database name is not set")
+		} else if db.Backend == "" {
+			return nil, fmt.Errorf("backend is not set for database %q", db.Name)
+		} else if _, ok := seen[db.Name]; ok {
+			return nil, fmt.Errorf("duplicate database name: %q", db.Name)
+		}
+		seen[db.Name] = struct{}{}
+	}
+	return dbs, nil
+}
+
+// openNamed opens a named database. Same as for the main database, address of
+// non-persistent backends is interpreted as a file to load.
+func openNamed(cmd *cobra.Command, db namedDatabase) (*graph.Handle, error) {
+	init, _ := cmd.Flags().GetBool("init")
+	return openNamedDatabase(db, init)
+}
+
+// openGraph opens a database for a graph created through the graph management API.
+// Persistent databases are initialized, unless they exist already.
+func openGraph(name string, spec chttp.GraphSpec) (*graph.Handle, error) {
+	return openNamedDatabase(namedDatabase{
+		Name:    name,
+		Backend: spec.Backend,
+		Address: spec.Address,
+		Options: spec.Options,
+	}, true)
+}
+
+// openNamedDatabase opens a named database and sets up the same indexes and inference as for the main database.
+func openNamedDatabase(db namedDatabase, init bool) (*graph.Handle, error) {
+	opts := graph.Options(db.Options)
+	if init && graph.IsPersistent(db.Backend) {
+		if err := graph.InitQuadStore(db.Backend, db.Address, opts); err != nil && err != graph.ErrDatabaseExists {
+			return nil, err
+		}
+	}
+	var load string
+	h, err := openStore(db.Backend, db.Address, opts)
+	if err == graph.ErrQuadStoreNotPersistent {
+		load = db.Address
+		h, err = openStore(db.Backend, "", opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database %q: %v", db.Name, err)
+	}
+	if err = setupIndexes(h, opts, db.Name); err != nil {
+		h.Close()
+		return nil, fmt.Errorf("database %q: %v", db.Name, err)
+	}
+	if load != "" {
+		start := time.Now()
+		if err = internal.Load(h.QuadWriter, quad.DefaultBatch, load, ""); err != nil {
+			h.Close()
+			return nil, fmt.Errorf("database %q: %v", db.Name, err)
+		}
+		clog.Infof("loaded %q into database %q in %v", load, db.Name, time.Since(start))
+	}
+	return h, nil
+}
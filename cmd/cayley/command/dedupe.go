@@ -0,0 +1,56 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+)
+
+func NewDedupeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Remove duplicate quads and unused nodes.",
+		Long: `Remove duplicate quads and nodes that are not referenced by any quad.
+
+Such records may be left in the database by bugs in previous versions or by interrupted writes.
+With --dry_run, found records are only counted. Use the "dedup" command to merge equivalent blank nodes.
+It is recommended to make a backup of the database before running this command.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printBackendInfo()
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+
+			dryRun, _ := cmd.Flags().GetBool("dry_run")
+			ctx, cancel := getContext()
+			defer cancel()
+			st, err := graph.Dedup(ctx, h.QuadStore, dryRun)
+			if err == graph.ErrNotSupported {
+				return fmt.Errorf("deduplication is not supported by %q backend", viper.GetString(KeyBackend))
+			} else if err != nil {
+				return err
+			}
+			if dryRun {
+				clog.Infof("found %d duplicate quads and %d unused nodes", st.Quads, st.Nodes)
+				if st.Bytes != 0 {
+					clog.Infof("%s can be reclaimed", internal.FormatBytes(st.Bytes))
+				}
+				return nil
+			}
+			clog.Infof("removed %d duplicate quads and %d unused nodes", st.Quads, st.Nodes)
+			if st.Bytes != 0 {
+				clog.Infof("reclaimed %s", internal.FormatBytes(st.Bytes))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("dry_run", false, "only count duplicate quads and unused nodes")
+	return cmd
+}
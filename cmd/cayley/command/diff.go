@@ -0,0 +1,151 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// Output modes of the diff command.
+const (
+	diffAdded   = "added"
+	diffRemoved = "removed"
+	diffTx      = "tx"
+)
+
+// txDelta is a single operation of the transaction printed by the diff command.
+type txDelta struct {
+	Action string     `json:"action"` // "add" or "delete"
+	Quad   model.Quad `json:"quad"`
+}
+
+func NewDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare quads of two databases or quad files.",
+		Long: `Compare quads of two databases or quad files and print the difference.
+
+Each side is either a database (--from and --from_db, --to and --to_db) or a quad file (--from_file, --to_file).
+The first side defaults to the database set in the config or by --db and --dbpath flags.
+Quads of both sides are sorted in memory and compared in a single pass.
+
+Output modes:
+  added    quads that exist only in the second source, in N-Quads
+  removed  quads that exist only in the first source, in N-Quads
+  tx       a transaction that converts the first source into the second one,
+           one JSON object with "action" ("add" or "delete") and "quad" per line`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode, _ := cmd.Flags().GetString("output")
+			switch mode {
+			case diffAdded, diffRemoved, diffTx:
+			default:
+				return fmt.Errorf("unsupported output mode: %q", mode)
+			}
+			format, _ := cmd.Flags().GetString("format")
+			from, err := openDiffSource(cmd, "from", true, format)
+			if err != nil {
+				return err
+			}
+			defer from.Close()
+			to, err := openDiffSource(cmd, "to", false, format)
+			if err != nil {
+				return err
+			}
+			defer to.Close()
+
+			var (
+				w   io.Writer = os.Stdout
+				qw  quad.WriteCloser
+				enc *json.Encoder
+			)
+			if mode == diffTx {
+				enc = json.NewEncoder(w)
+			} else {
+				qw = quad.FormatByName("nquads").Writer(w)
+				defer qw.Close()
+			}
+			ctx, cancel := getContext()
+			defer cancel()
+			st, err := internal.Diff(ctx, from, to, func(d graph.Delta) error {
+				switch {
+				case enc != nil:
+					return enc.Encode(txDelta{Action: d.Action.String(), Quad: model.NewQuad(d.Quad)})
+				case (d.Action == graph.Add) == (mode == diffAdded):
+					return qw.WriteQuad(d.Quad)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if qw != nil {
+				if err = qw.Close(); err != nil {
+					return err
+				}
+			}
+			clog.Infof("%d quads added, %d quads removed", st.Added, st.Removed)
+			return nil
+		},
+	}
+	cmd.Flags().String("from", "", "first database backend (defaults to the configured database)")
+	cmd.Flags().String("from_db", "", "first database path or address")
+	cmd.Flags().String("from_file", "", "first quad file")
+	cmd.Flags().String("to", "", "second database backend")
+	cmd.Flags().String("to_db", "", "second database path or address")
+	cmd.Flags().String("to_file", "", "second quad file")
+	cmd.Flags().String("format", "", `quad file format: "nquads", "pquads", etc.; detected by file extension if not set`)
+	cmd.Flags().StringP("output", "o", diffAdded, `output mode: "added", "removed" or "tx"`)
+	return cmd
+}
+
+// openDiffSource opens one side of the diff, either a quad file or a database, using flags with a given prefix.
+func openDiffSource(cmd *cobra.Command, prefix string, def bool, format string) (quad.ReadCloser, error) {
+	file, _ := cmd.Flags().GetString(prefix + "_file")
+	name, _ := cmd.Flags().GetString(prefix)
+	addr, _ := cmd.Flags().GetString(prefix + "_db")
+	if file != "" {
+		if name != "" || addr != "" {
+			return nil, fmt.Errorf("both database and file are set for --%s", prefix)
+		}
+		return internal.QuadReaderFor(file, format)
+	}
+	opts := graph.Options{}
+	if name == "" {
+		if !def {
+			return nil, errors.New("second database or file must be specified")
+		}
+		name = viper.GetString(KeyBackend)
+		if addr == "" {
+			addr = viper.GetString(KeyAddress)
+			opts = graph.Options(viper.GetStringMap(KeyOptions))
+		}
+	}
+	qs, err := graph.NewQuadStore(name, addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &storeReader{ReadCloser: graph.NewQuadStoreReader(qs), qs: qs}, nil
+}
+
+// storeReader closes the database after reading all quads from it.
+type storeReader struct {
+	quad.ReadCloser
+	qs graph.QuadStore
+}
+
+func (r *storeReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.qs.Close()
+	return err
+}
@@ -2,6 +2,7 @@ package command
 
 import (
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -10,7 +11,10 @@ import (
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+	"github.com/cayleygraph/cayley/internal/decompressor"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/redact"
 )
 
 func writerQuadsTo(path string, typ string, qr quad.Reader) error {
@@ -28,13 +32,25 @@ func writerQuadsTo(path string, typ string, qr quad.Reader) error {
 		fmt.Printf("writing quads to file %q\n", path)
 	}
 
-	var w io.Writer = f
+	var (
+		w    io.Writer = f
+		comp io.WriteCloser
+	)
 	ext := filepath.Ext(path)
-	if ext == ".gz" {
+	switch ext {
+	case ".gz":
+		comp = gzip.NewWriter(f)
+	case ".zst":
+		zw, err := decompressor.NewZstdWriter(f)
+		if err != nil {
+			return err
+		}
+		comp = zw
+	}
+	if comp != nil {
 		ext = filepath.Ext(strings.TrimSuffix(path, ext))
-		gzip := gzip.NewWriter(f)
-		defer gzip.Close()
-		w = gzip
+		defer comp.Close()
+		w = comp
 	}
 	var format *quad.Format
 	if typ == "" {
@@ -59,6 +75,10 @@ func writerQuadsTo(path string, typ string, qr quad.Reader) error {
 		return err
 	} else if err = qw.Close(); err != nil {
 		return err
+	} else if comp != nil {
+		if err = comp.Close(); err != nil {
+			return err
+		}
 	}
 	if path != "-" {
 		fmt.Printf("%d entries were written\n", n)
@@ -66,9 +86,26 @@ func writerQuadsTo(path string, typ string, qr quad.Reader) error {
 	return nil
 }
 
-func dumpDatabase(h *graph.Handle, path string, typ string) error {
-	//TODO: add possible support for exporting specific queries only
+func dumpDatabase(h *graph.Handle, path string, typ string, red *redact.Redactor) error {
 	qr := graph.NewQuadStoreReader(h.QuadStore)
 	defer qr.Close()
-	return writerQuadsTo(path, typ, qr)
+	return writerQuadsTo(path, typ, red.NewReader(qr))
+}
+
+// dumpFiltered writes only quads matching a pattern. If a query is set, only quads
+// with subjects returned by the query are written.
+func dumpFiltered(ctx context.Context, h *graph.Handle, path, typ string, red *redact.Redactor, p internal.QuadPattern, lang, qu string) error {
+	var qr quad.ReadCloser
+	if qu != "" {
+		nodes, err := internal.QueryNodes(ctx, h.QuadStore, lang, qu)
+		if err != nil {
+			return err
+		}
+		clog.Infof("query returned %d nodes", len(nodes))
+		qr = internal.NewNodesReader(h.QuadStore, nodes, p)
+	} else {
+		qr = graph.NewResultReader(h.QuadStore, p.Shape().BuildIterator(h.QuadStore))
+	}
+	defer qr.Close()
+	return writerQuadsTo(path, typ, red.NewReader(qr))
 }
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+func NewFsckCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Verify consistency of the database.",
+		Long: `Verify internal consistency of the database: dangling node references, reference counters,
+orphaned or missing index entries and values that cannot be decoded.
+
+With --repair, problems that can be fixed without losing any readable data are repaired.
+It is recommended to make a backup of the database before running the repair.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printBackendInfo()
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+
+			repair, _ := cmd.Flags().GetBool("repair")
+			ctx, cancel := getContext()
+			defer cancel()
+			problems, err := graph.Check(ctx, h.QuadStore, repair)
+			if err == graph.ErrNotSupported {
+				return fmt.Errorf("consistency check is not supported by %q backend", viper.GetString(KeyBackend))
+			}
+			unfixed := 0
+			for _, p := range problems {
+				status := ""
+				if p.Repaired {
+					status = " (repaired)"
+				} else {
+					unfixed++
+				}
+				fmt.Fprintf(os.Stdout, "%s: %s%s\n", p.Kind, p.Desc, status)
+			}
+			if err != nil {
+				return err
+			}
+			if len(problems) == 0 {
+				clog.Infof("no problems found")
+				return nil
+			}
+			clog.Infof("found %d problems, %d repaired", len(problems), len(problems)-unfixed)
+			if unfixed != 0 {
+				if !repair {
+					return fmt.Errorf("database is inconsistent; run with --repair to fix it")
+				}
+				return fmt.Errorf("%d problems cannot be repaired", unfixed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("repair", false, "repair found problems")
+	return cmd
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+)
+
+func NewGCCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove nodes that are not used by any quad.",
+		Long: `Remove node values that are not referenced by any quad anymore and compact the database.
+
+Nodes are removed in batches of --batch_size, each in a separate transaction, thus the command
+can run while the database is being written. The database is compacted afterwards, if the backend
+supports it. With --dry_run, unused nodes are only counted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printBackendInfo()
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+
+			batch, _ := cmd.Flags().GetInt("batch_size")
+			dryRun, _ := cmd.Flags().GetBool("dry_run")
+			ctx, cancel := getContext()
+			defer cancel()
+			st, err := graph.GC(ctx, h.QuadStore, batch, dryRun)
+			if err == graph.ErrNotSupported {
+				return fmt.Errorf("gc is not supported by %q backend", viper.GetString(KeyBackend))
+			} else if err != nil {
+				return err
+			}
+			if dryRun {
+				clog.Infof("found %d unused nodes", st.Nodes)
+				if st.Bytes != 0 {
+					clog.Infof("%s can be reclaimed", internal.FormatBytes(st.Bytes))
+				}
+				return nil
+			}
+			clog.Infof("removed %d unused nodes in %d batches", st.Nodes, st.Batches)
+			if st.Bytes != 0 {
+				clog.Infof("reclaimed %s", internal.FormatBytes(st.Bytes))
+			}
+			if st.Compacted {
+				clog.Infof("database compacted")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Int("batch_size", graph.DefaultGCBatch, "maximal number of nodes removed in a single transaction")
+	cmd.Flags().Bool("dry_run", false, "only count nodes that can be removed")
+	return cmd
+}
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/spatial"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	keyGeoType       = "geo.type"
+	keyGeoPath       = "geo.path"
+	keyGeoPredicates = "geo.predicates"
+	keyGeoOptions    = "geo.options"
+)
+
+// setupGeoIndex wraps the store of the handle to maintain a geospatial index set in the config, if any.
+func setupGeoIndex(h *graph.Handle, opts graph.Options, db string) error {
+	list := viper.GetStringSlice(keyGeoPredicates)
+	if len(list) == 0 {
+		return nil
+	}
+	preds := make([]quad.IRI, 0, len(list))
+	for _, s := range list {
+		preds = append(preds, quad.IRI(s).Full())
+	}
+	qs, err := spatial.New(h.QuadStore, spatial.Config{
+		Type:       viper.GetString(keyGeoType),
+		Path:       indexPath(keyGeoPath, db),
+		Predicates: preds,
+		Options:    graph.Options(viper.GetStringMap(keyGeoOptions)),
+	})
+	if err != nil {
+		return err
+	}
+	qw, err := graph.NewQuadWriter("single", qs, opts)
+	if err != nil {
+		qs.Index().Close()
+		return err
+	}
+	h.QuadWriter.Close()
+	h.QuadStore, h.QuadWriter = qs, qw
+	clog.Infof("maintaining geospatial index for %d predicates", len(preds))
+	return nil
+}
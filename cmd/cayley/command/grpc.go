@@ -0,0 +1,56 @@
+package command
+
+import (
+	"net"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	chttp "github.com/cayleygraph/cayley/internal/http"
+	cayleygrpc "github.com/cayleygraph/cayley/server/grpc"
+)
+
+const (
+	keyGRPCAddress = "grpc.address"
+	keyGRPCBatch   = "grpc.batch"
+)
+
+// startGRPC starts serving the gRPC API on the address set in the config, if any.
+// It uses the same read-only mode, timeout and query limits as the HTTP API.
+// The returned function stops the server, waiting up to a given timeout for active calls to finish.
+func startGRPC(h *graph.Handle, cfg chttp.Config) (func(timeout time.Duration), error) {
+	addr := viper.GetString(keyGRPCAddress)
+	if addr == "" {
+		return func(time.Duration) {}, nil
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := cayleygrpc.NewServer(h, cayleygrpc.Config{
+		ReadOnly: cfg.ReadOnly,
+		Timeout:  cfg.Timeout,
+		Limits:   cfg.Limits,
+		Batch:    viper.GetInt(keyGRPCBatch),
+	})
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			clog.Errorf("grpc: %v", err)
+		}
+	}()
+	clog.Infof("serving gRPC API on %s", addr)
+	return func(timeout time.Duration) {
+		done := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(timeout):
+			srv.Stop()
+		}
+	}, nil
+}
@@ -1,53 +1,367 @@
 package command
 
 import (
+	"context"
+	"io"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
 	chttp "github.com/cayleygraph/cayley/internal/http"
+	"github.com/cayleygraph/cayley/query"
+	cayleyhttp "github.com/cayleygraph/cayley/server/http"
+	"github.com/cayleygraph/cayley/server/replica"
 )
 
+const (
+	keyAdminToken = "http.admin_token"
+	keyURLPrefix  = "http.url_prefix"
+
+	keyDrainTimeout = "http.drain_timeout"
+	keyStreamFlush  = "http.stream_flush"
+	keyCache        = "http.cache"
+
+	keyAccessLog       = "http.access_log"
+	keyAccessLogSample = "http.access_log_sample"
+
+	keyLogLevel = "log.level"
+
+	keyReplicaOf       = "replica.of"
+	keyReplicaInterval = "replica.interval"
+
+	keySweepInterval = "expiration.sweep_interval"
+
+	keyGraphsPath = "graphs.path"
+)
+
+// httpConfig reads settings of the HTTP API from the config.
+func httpConfig() (chttp.Config, error) {
+	policy, err := aclPolicy()
+	if err != nil {
+		return chttp.Config{}, err
+	}
+	cacheSize, err := cayleyhttp.ParseCacheSpec(viper.GetString(keyCache))
+	if err != nil {
+		return chttp.Config{}, err
+	}
+	return chttp.Config{
+		Timeout: viper.GetDuration(keyQueryTimeout),
+		// replicas only accept changes from the primary
+		ReadOnly: viper.GetBool(KeyReadOnly) || viper.GetString(keyReplicaOf) != "",
+		Limits: query.Limits{
+			MaxResults: viper.GetInt(keyQueryMaxResults),
+			MaxMemory:  viper.GetInt64(keyQueryMaxMemory),
+			MaxQuads:   viper.GetInt64(keyQueryMaxQuads),
+		},
+		Parallel:    viper.GetInt(keyQueryParallel),
+		StreamFlush: viper.GetDuration(keyStreamFlush),
+		AdminToken:  viper.GetString(keyAdminToken),
+		ACL:         policy,
+		RolesHeader: viper.GetString(keyACLRolesHeader),
+		Redact:      redactor(),
+		CacheSize:   cacheSize,
+	}, nil
+}
+
+// databaseConfig applies overrides of a named database to the config of the main one.
+func databaseConfig(cfg chttp.Config, db namedDatabase) chttp.Config {
+	cfg.ReadOnly = cfg.ReadOnly || db.ReadOnly
+	if db.Timeout != 0 {
+		cfg.Timeout = db.Timeout
+	}
+	return cfg
+}
+
+// setLogLevel sets log verbosity from the config, if it is set.
+func setLogLevel() {
+	if viper.IsSet(keyLogLevel) {
+		clog.SetV(viper.GetInt(keyLogLevel))
+	}
+}
+
+// reloadConfig reads the config file again and applies settings that can be changed without restarting
+// the server: query limits and timeout, admin token, access control, read-only mode and log level.
+// Databases cannot be added or removed this way.
+func reloadConfig(hs *cayleyhttp.Health, served map[string]struct{}) error {
+	err := viper.ReadInConfig()
+	if _, ok := err.(viper.ConfigFileNotFoundError); !ok && err != nil {
+		return err
+	}
+	setLogLevel()
+	cfg, err := httpConfig()
+	if err != nil {
+		return err
+	}
+	if err = chttp.Reload("", &cfg); err != nil {
+		return err
+	}
+	hs.SetReadOnly(cfg.ReadOnly)
+	if adminAuth != nil {
+		adminAuth.SetToken(cfg.AdminToken)
+	}
+	dbs, err := namedDatabases()
+	if err != nil {
+		return err
+	}
+	for _, db := range dbs {
+		if _, ok := served[db.Name]; !ok {
+			clog.Warningf("database %q was added to the config; restart the server to serve it", db.Name)
+			continue
+		}
+		dcfg := databaseConfig(cfg, db)
+		if err = chttp.Reload(db.Name, &dcfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func NewHttpCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "http",
 		Short: "Serve an HTTP endpoint on the given host and port.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			printBackendInfo()
+			setLogLevel()
 			p := mustSetupProfile(cmd)
 			defer mustFinishProfile(p)
 
+			// start serving health probes before opening the database,
+			// since the initial load might take a while
+			hs := cayleyhttp.NewHealth()
+			hs.SetBackend(viper.GetString(KeyBackend))
+			hs.SetReadOnly(viper.GetBool(KeyReadOnly))
+			if wtyp := viper.GetString(KeyReplication); wtyp != "" {
+				hs.SetReplication(wtyp)
+			}
+			primary := viper.GetString(keyReplicaOf)
+			if primary != "" {
+				hs.SetReadOnly(true)
+				hs.SetReplication("replica")
+			}
+			chttp.SetupHealth(hs)
+
+			host, _ := cmd.Flags().GetString("host")
+			prefix := strings.TrimRight(viper.GetString(keyURLPrefix), "/")
+			if prefix != "" && !strings.HasPrefix(prefix, "/") {
+				prefix = "/" + prefix
+			}
+			lis, err := net.Listen("tcp", host)
+			if err != nil {
+				return err
+			}
+			handler, err := authMiddleware(http.DefaultServeMux)
+			if err != nil {
+				lis.Close()
+				return err
+			}
+			srv := &http.Server{Handler: cayleyhttp.StripPrefix(prefix, handler)}
+			errc := make(chan error, 1)
+			go func() {
+				errc <- srv.Serve(lis)
+			}()
+
 			h, err := openForQueries(cmd)
 			if err != nil {
+				lis.Close()
 				return err
 			}
 			defer h.Close()
 
-			err = chttp.SetupRoutes(h, &chttp.Config{
-				Timeout:  viper.GetDuration(keyQueryTimeout),
-				ReadOnly: viper.GetBool(KeyReadOnly),
-			})
+			replCtx, stopReplica := context.WithCancel(context.Background())
+			defer stopReplica()
+			cfg, err := httpConfig()
 			if err != nil {
+				lis.Close()
 				return err
 			}
-			host, _ := cmd.Flags().GetString("host")
+			if primary != "" {
+				rp := replica.New(h.QuadStore, primary, replica.Config{
+					Interval: viper.GetDuration(keyReplicaInterval),
+				})
+				cfg.Replica = rp.Status
+				hs.SetReplicaStatus(rp.Status)
+				go func() {
+					if err := rp.Run(replCtx); err != nil && err != context.Canceled {
+						clog.Errorf("replica: %v", err)
+					}
+				}()
+				clog.Infof("replicating from %s", primary)
+			}
+			if path := viper.GetString(keyAccessLog); path != "" {
+				w := io.Writer(os.Stdout)
+				if path != "-" {
+					f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+					if err != nil {
+						lis.Close()
+						return err
+					}
+					defer f.Close()
+					w = f
+				}
+				cfg.AccessLog = chttp.NewAccessLog(w, viper.GetFloat64(keyAccessLogSample))
+			}
+			auditLog, err := openAuditLog()
+			if err != nil {
+				lis.Close()
+				return err
+			}
+			if auditLog != nil {
+				defer auditLog.Close()
+				cfg.Audit = auditLog
+				cfg.AuditUserHeader = viper.GetString(keyAuditUserHeader)
+			}
+			cfg.Metrics = newMetrics()
+			if cfg.Tracer, err = newTracer(); err != nil {
+				lis.Close()
+				return err
+			} else if cfg.Tracer != nil {
+				defer cfg.Tracer.Close()
+			}
+			err = chttp.SetupRoutes(h, &cfg)
+			if err != nil {
+				lis.Close()
+				return err
+			}
+
+			dbs, err := namedDatabases()
+			if err != nil {
+				lis.Close()
+				return err
+			}
+			served := make(map[string]struct{}, len(dbs))
+			for _, db := range dbs {
+				dh, err := openNamed(cmd, db)
+				if err != nil {
+					lis.Close()
+					return err
+				}
+				defer dh.Close()
+				dcfg := databaseConfig(cfg, db)
+				if err = chttp.SetupDatabase(db.Name, dh, &dcfg); err != nil {
+					lis.Close()
+					return err
+				}
+				served[db.Name] = struct{}{}
+				clog.Infof("serving database %q (%s) under /db/%s/", db.Name, db.Backend, db.Name)
+			}
+			if err = chttp.SetupGraphs(openGraph, &cfg, viper.GetString(keyGraphsPath)); err != nil {
+				lis.Close()
+				return err
+			}
+			defer chttp.CloseGraphs()
+			cdcCtx, stopCDC := context.WithCancel(context.Background())
+			defer stopCDC()
+			if err = startCDC(cdcCtx, h.QuadStore); err != nil {
+				lis.Close()
+				return err
+			}
+			sweepCtx, stopSweeper := context.WithCancel(context.Background())
+			defer stopSweeper()
+			if d := viper.GetDuration(keySweepInterval); d > 0 && !cfg.ReadOnly {
+				go func() {
+					if err := graph.RunSweeper(sweepCtx, h.QuadStore, d); err == graph.ErrNotSupported {
+						clog.Infof("backend does not support quad expiration, sweeper is disabled")
+					}
+				}()
+			}
+			stopGRPC, err := startGRPC(h, cfg)
+			if err != nil {
+				lis.Close()
+				return err
+			}
+			hs.SetHandle(h)
 			phost := host
 			if host, port, err := net.SplitHostPort(host); err == nil && host == "" {
 				phost = net.JoinHostPort("localhost", port)
 			}
-			clog.Infof("listening on %s, web interface at http://%s", host, phost)
-			return http.ListenAndServe(host, nil)
+			clog.Infof("listening on %s, web interface at http://%s%s/", host, phost, prefix)
+
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+			defer signal.Stop(sig)
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			defer signal.Stop(hup)
+		wait:
+			for {
+				select {
+				case err = <-errc:
+					return err
+				case <-hup:
+					clog.Infof("received SIGHUP, reloading configuration")
+					if err := reloadConfig(hs, served); err != nil {
+						clog.Errorf("cannot reload configuration: %v", err)
+					}
+				case s := <-sig:
+					clog.Infof("received %v, draining connections", s)
+					break wait
+				}
+			}
+			// stop accepting new requests and wait for in-flight ones; deferred calls will
+			// flush pending writes and close databases after that
+			hs.SetDraining()
+			ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration(keyDrainTimeout))
+			defer cancel()
+			if err = srv.Shutdown(ctx); err != nil {
+				clog.Warningf("drain timeout exceeded, canceling remaining requests")
+				srv.Close()
+			}
+			stopGRPC(viper.GetDuration(keyDrainTimeout))
+			clog.Infof("closing the database")
+			return nil
 		},
 	}
 	cmd.Flags().String("host", "127.0.0.1:64210", "host:port to listen on")
 	cmd.Flags().Bool("init", false, "initialize the database before using it")
 	cmd.Flags().DurationP("timeout", "t", 30*time.Second, "elapsed time until an individual query times out")
+	cmd.Flags().Int("max_results", 0, "maximal number of results a single query can return (0 = unlimited)")
+	cmd.Flags().Int64("max_memory", 0, "approximate size of values in bytes a single query can load (0 = unlimited)")
+	cmd.Flags().Int64("max_quads", 0, "maximal number of quads a single query can touch (0 = unlimited)")
+	cmd.Flags().Int("parallel", 0, "run independent branches of queries in parallel, buffering a given number of results (0 = disabled)")
+	cmd.Flags().String("url_prefix", "", "path prefix to serve the API and web interface under, when running behind a reverse proxy")
+	cmd.Flags().String("admin_token", "", "token for admin endpoints (admin API is disabled if not set)")
+	cmd.Flags().Duration("drain_timeout", 30*time.Second, "time to wait for in-flight requests to finish on shutdown")
+	cmd.Flags().Duration("stream_flush", 0, "maximal interval between flushes of streamed query results (0 = flush each result)")
+	cmd.Flags().String("cache", "", "cache results of repeated queries, in the form \"lru,size=N\" (disabled if not set)")
+	cmd.Flags().String("grpc", "", "host:port to serve the gRPC API on (disabled if not set)")
+	cmd.Flags().String("access_log", "", "write JSON access log to a given file (\"-\" for stdout)")
+	cmd.Flags().Float64("access_log_sample", 1, "fraction of successful requests to write to the access log")
+	cmd.Flags().String("replica-of", "", "run as a read-only replica of a Cayley instance with a given address (it must enable delta_log)")
+	cmd.Flags().Duration("replica-interval", replica.DefaultInterval, "interval between polls of the primary instance")
+	cmd.Flags().Duration("sweep_interval", time.Minute, "interval between removals of expired quads (0 = disabled)")
+	cmd.Flags().String("graphs", "", "file to keep the list of graphs created through the graph management API")
+	cmd.Flags().Bool("metrics", false, "serve Prometheus metrics of queries and writes on /metrics")
+	cmd.Flags().String("trace_endpoint", "", "address of the OpenTelemetry collector to export query traces to (disabled if not set)")
 	cmd.Flags().StringVar(&chttp.AssetsPath, "assets", "", "explicit path to the HTTP assets")
 	registerLoadFlags(cmd)
 	viper.BindPFlag(keyQueryTimeout, cmd.Flags().Lookup("timeout"))
+	viper.BindPFlag(keyQueryMaxResults, cmd.Flags().Lookup("max_results"))
+	viper.BindPFlag(keyQueryMaxMemory, cmd.Flags().Lookup("max_memory"))
+	viper.BindPFlag(keyQueryMaxQuads, cmd.Flags().Lookup("max_quads"))
+	viper.BindPFlag(keyQueryParallel, cmd.Flags().Lookup("parallel"))
+	viper.BindPFlag(keyAdminToken, cmd.Flags().Lookup("admin_token"))
+	viper.BindPFlag(keyURLPrefix, cmd.Flags().Lookup("url_prefix"))
+	viper.BindPFlag(keyDrainTimeout, cmd.Flags().Lookup("drain_timeout"))
+	viper.BindPFlag(keyStreamFlush, cmd.Flags().Lookup("stream_flush"))
+	viper.BindPFlag(keyCache, cmd.Flags().Lookup("cache"))
+	viper.BindPFlag(keyGRPCAddress, cmd.Flags().Lookup("grpc"))
+	viper.BindPFlag(keyAccessLog, cmd.Flags().Lookup("access_log"))
+	viper.BindPFlag(keyAccessLogSample, cmd.Flags().Lookup("access_log_sample"))
+	viper.BindPFlag(keyReplicaOf, cmd.Flags().Lookup("replica-of"))
+	viper.BindPFlag(keyReplicaInterval, cmd.Flags().Lookup("replica-interval"))
+	viper.BindPFlag(keySweepInterval, cmd.Flags().Lookup("sweep_interval"))
+	viper.BindPFlag(keyGraphsPath, cmd.Flags().Lookup("graphs"))
+	viper.BindPFlag(keyMetrics, cmd.Flags().Lookup("metrics"))
+	viper.BindPFlag(keyTraceEndpoint, cmd.Flags().Lookup("trace_endpoint"))
 	return cmd
 }
@@ -0,0 +1,45 @@
+package command
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/inference"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	keyInferenceStrategy = "inference.strategy"
+	keyInferenceLabel    = "inference.label"
+	keyInferenceOWL      = "inference.owl"
+)
+
+// setupInference wraps the store of the handle to apply RDFS entailments, if enabled in the config.
+// It should be the outermost wrapper, so materialized quads are seen by indexes.
+func setupInference(h *graph.Handle, opts graph.Options, _ string) error {
+	s := viper.GetString(keyInferenceStrategy)
+	if s == "" {
+		return nil
+	}
+	st, err := inference.ParseStrategy(s)
+	if err != nil {
+		return err
+	}
+	conf := inference.Config{Strategy: st, OWL: viper.GetBool(keyInferenceOWL)}
+	if l := viper.GetString(keyInferenceLabel); l != "" {
+		conf.Label = quad.IRI(l).Full()
+	}
+	qs, err := inference.New(h.QuadStore, conf)
+	if err != nil {
+		return err
+	}
+	qw, err := graph.NewQuadWriter("single", qs, opts)
+	if err != nil {
+		return err
+	}
+	h.QuadWriter.Close()
+	h.QuadStore, h.QuadWriter = qs, qw
+	clog.Infof("applying RDFS entailments with %q strategy", st)
+	return nil
+}
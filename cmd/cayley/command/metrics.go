@@ -0,0 +1,46 @@
+package command
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/internal/metrics"
+)
+
+const (
+	keyMetrics          = "metrics.enabled"
+	keyMetricsIterators = "metrics.iterators"
+
+	keyTraceEndpoint = "tracing.endpoint"
+	keyTraceInsecure = "tracing.insecure"
+	keyTraceSample   = "tracing.sample"
+)
+
+// newMetrics creates a set of metrics if they are enabled in the config. It returns nil otherwise.
+func newMetrics() *metrics.Metrics {
+	if !viper.GetBool(keyMetrics) {
+		return nil
+	}
+	clog.Infof("serving metrics on /metrics")
+	return metrics.New(metrics.Options{
+		Iterators: viper.GetBool(keyMetricsIterators),
+	})
+}
+
+// newTracer creates a tracer that exports spans to a collector set in the config. It returns nil if tracing is disabled.
+func newTracer() (*metrics.Tracer, error) {
+	addr := viper.GetString(keyTraceEndpoint)
+	if addr == "" {
+		return nil, nil
+	}
+	t, err := metrics.NewTracer(metrics.TraceOptions{
+		Endpoint: addr,
+		Insecure: viper.GetBool(keyTraceInsecure),
+		Sample:   viper.GetFloat64(keyTraceSample),
+	})
+	if err != nil {
+		return nil, err
+	}
+	clog.Infof("exporting traces to %s", addr)
+	return t, nil
+}
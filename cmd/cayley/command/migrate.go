@@ -0,0 +1,86 @@
+package command
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+)
+
+func NewMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy all quads from one database to another.",
+		Long: `Copy all quads from one database to another without dumping them to a file first.
+
+Source database defaults to the one set in the config or by --db and --dbpath flags.
+A checkpoint is stored in the destination database after each batch, so an interrupted
+migration can be continued with --resume, if the destination backend supports it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, _ := cmd.Flags().GetString("from")
+			fromAddr, _ := cmd.Flags().GetString("from_db")
+			fromOpts := graph.Options{}
+			if from == "" {
+				from = viper.GetString(KeyBackend)
+				if fromAddr == "" {
+					fromAddr = viper.GetString(KeyAddress)
+					fromOpts = graph.Options(viper.GetStringMap(KeyOptions))
+				}
+			}
+			to, _ := cmd.Flags().GetString("to")
+			toAddr, _ := cmd.Flags().GetString("to_db")
+			if to == "" {
+				return errors.New("destination backend must be specified")
+			} else if from == to && fromAddr == toAddr {
+				return errors.New("source and destination databases are the same")
+			}
+			if init, _ := cmd.Flags().GetBool("init"); init {
+				if err := graph.InitQuadStore(to, toAddr, nil); err != nil {
+					return err
+				}
+			}
+			clog.Infof("migrating from %q (%s) to %q (%s)", from, fromAddr, to, toAddr)
+			src, err := graph.NewQuadStore(from, fromAddr, fromOpts)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			dst, err := openStore(to, toAddr, nil)
+			if err != nil {
+				return err
+			}
+			defer dst.Close()
+
+			opt := internal.MigrateOptions{Batch: viper.GetInt(KeyLoadBatch)}
+			opt.Resume, _ = cmd.Flags().GetBool("resume")
+			if show, _ := cmd.Flags().GetBool("progress"); show {
+				opt.Progress = os.Stderr
+			}
+			ctx, cancel := getContext()
+			defer cancel()
+			return internal.Migrate(ctx, src, from+":"+fromAddr, dst.QuadStore, dst.QuadWriter, opt)
+		},
+	}
+	cmd.Flags().String("from", "", "source database backend (defaults to the configured database)")
+	cmd.Flags().String("from_db", "", "source database path or address")
+	cmd.Flags().String("to", "", "destination database backend")
+	cmd.Flags().String("to_db", "", "destination database path or address")
+	cmd.Flags().Bool("init", false, "initialize the destination database before migration")
+	cmd.Flags().Bool("resume", false, "continue an interrupted migration from the checkpoint stored in the destination database")
+	cmd.Flags().Bool("progress", true, "print a progress bar to stderr")
+	// allow --from-db and --to-db spelling
+	aliases := map[string]string{"from-db": "from_db", "to-db": "to_db"}
+	cmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if alias, ok := aliases[name]; ok {
+			name = alias
+		}
+		return pflag.NormalizedName(name)
+	})
+	return cmd
+}
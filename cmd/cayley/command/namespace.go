@@ -0,0 +1,106 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/voc"
+)
+
+func NewNamespaceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "namespace",
+		Short: "Manage RDF namespaces stored in the database.",
+		Long: `Manage RDF namespaces (prefixes) stored in the database metadata.
+
+Stored namespaces are loaded each time the database is opened, thus prefix expansion in queries
+and compaction in outputs are the same for all clients.`,
+	}
+	cmd.AddCommand(
+		newNamespaceListCmd(),
+		newNamespaceAddCmd(),
+		newNamespaceDeleteCmd(),
+	)
+	return cmd
+}
+
+// namespaceCmd opens the database and runs a function on it. It returns a readable error
+// if the backend cannot store namespaces.
+func namespaceCmd(fnc func(h *graph.Handle) error) error {
+	h, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	err = fnc(h)
+	if err == graph.ErrNotSupported {
+		return fmt.Errorf("namespaces cannot be stored in %q backend", viper.GetString(KeyBackend))
+	}
+	return err
+}
+
+func newNamespaceListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List namespaces stored in the database.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return namespaceCmd(func(h *graph.Handle) error {
+				ctx, cancel := getContext()
+				defer cancel()
+				list, err := graph.Namespaces(ctx, h.QuadStore)
+				if err != nil {
+					return err
+				}
+				tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				for _, ns := range list {
+					fmt.Fprintf(tw, "%s\t%s\n", ns.Prefix, ns.Full)
+				}
+				return tw.Flush()
+			})
+		},
+	}
+}
+
+func newNamespaceAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <prefix> <iri>",
+		Short: "Store a namespace in the database.",
+		Long: `Store a namespace in the database, replacing a namespace with the same prefix.
+
+Example:
+  cayley namespace add foaf: http://xmlns.com/foaf/0.1/`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("expected a prefix and an IRI")
+			}
+			return namespaceCmd(func(h *graph.Handle) error {
+				ctx, cancel := getContext()
+				defer cancel()
+				return graph.AddNamespace(ctx, h.QuadStore, voc.Namespace{Prefix: args[0], Full: args[1]})
+			})
+		},
+	}
+}
+
+func newNamespaceDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <prefix>",
+		Short: "Remove a namespace from the database.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("expected a prefix")
+			}
+			return namespaceCmd(func(h *graph.Handle) error {
+				ctx, cancel := getContext()
+				defer cancel()
+				return graph.DeleteNamespace(ctx, h.QuadStore, args[0])
+			})
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+)
+
+func NewPurgeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Remove records of deleted quads.",
+		Long: `Physically remove records of deleted quads (tombstones) and entries of the delta log.
+
+Only records older than --older_than are removed. Backends keep records younger than their
+retention window (the "tombstone_retention" option for key-value backends) regardless of the flag.
+With --dry_run, found records are only counted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printBackendInfo()
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+
+			age, _ := cmd.Flags().GetDuration("older_than")
+			dryRun, _ := cmd.Flags().GetBool("dry_run")
+			ctx, cancel := getContext()
+			defer cancel()
+			st, err := graph.Purge(ctx, h.QuadStore, time.Now().Add(-age), dryRun)
+			if err == graph.ErrNotSupported {
+				return fmt.Errorf("purge is not supported by %q backend", viper.GetString(KeyBackend))
+			} else if err != nil {
+				return err
+			}
+			if dryRun {
+				clog.Infof("found %d deleted quads and %d log entries", st.Quads, st.LogEntries)
+				if st.Bytes != 0 {
+					clog.Infof("%s can be reclaimed", internal.FormatBytes(st.Bytes))
+				}
+				return nil
+			}
+			clog.Infof("removed %d deleted quads and %d log entries", st.Quads, st.LogEntries)
+			if st.Bytes != 0 {
+				clog.Infof("reclaimed %s", internal.FormatBytes(st.Bytes))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Duration("older_than", 0, "only remove records older than a given duration")
+	cmd.Flags().Bool("dry_run", false, "only count records that can be removed")
+	return cmd
+}
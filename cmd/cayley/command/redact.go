@@ -0,0 +1,65 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/redact"
+)
+
+const (
+	keyRedactAllow         = "redact.allow"
+	keyRedactDeny          = "redact.deny"
+	keyRedactHash          = "redact.hash"
+	keyRedactMask          = "redact.mask"
+	keyRedactExcludeGraphs = "redact.exclude_graphs"
+	keyRedactSalt          = "redact.salt"
+	keyRedactMaskValue     = "redact.mask_value"
+)
+
+// predicateList reads a list of predicate IRIs from the config.
+func predicateList(key string) []quad.Value {
+	list := viper.GetStringSlice(key)
+	if len(list) == 0 {
+		return nil
+	}
+	out := make([]quad.Value, 0, len(list))
+	for _, s := range list {
+		out = append(out, quad.IRI(s).Full())
+	}
+	return out
+}
+
+// redactor reads redaction rules from the config. It returns nil if no rules are set.
+func redactor() *redact.Redactor {
+	rules := redact.Rules{
+		Allow:     predicateList(keyRedactAllow),
+		Deny:      predicateList(keyRedactDeny),
+		Hash:      predicateList(keyRedactHash),
+		Mask:      predicateList(keyRedactMask),
+		Salt:      viper.GetString(keyRedactSalt),
+		MaskValue: viper.GetString(keyRedactMaskValue),
+	}
+	for _, s := range viper.GetStringSlice(keyRedactExcludeGraphs) {
+		// empty string is the default graph
+		rules.ExcludeLabels = append(rules.ExcludeLabels, quad.StringToValue(s))
+	}
+	if len(rules.Hash) != 0 && rules.Salt == "" {
+		clog.Warningf("%s is not set; hashed values can be recovered by hashing guessed values", keyRedactSalt)
+	}
+	return redact.New(rules)
+}
+
+// dumpRedactor returns redaction rules applied to dumps, unless they are disabled by a flag.
+func dumpRedactor(cmd *cobra.Command) *redact.Redactor {
+	if skip, _ := cmd.Flags().GetBool(flagNoRedact); skip {
+		return nil
+	}
+	red := redactor()
+	if red != nil {
+		clog.Infof("applying redaction rules to the dump")
+	}
+	return red
+}
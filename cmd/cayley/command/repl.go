@@ -2,7 +2,6 @@ package command
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -21,7 +20,11 @@ import (
 )
 
 const (
-	keyQueryTimeout = "query.timeout"
+	keyQueryTimeout    = "query.timeout"
+	keyQueryMaxResults = "query.max_results"
+	keyQueryMaxMemory  = "query.max_memory"
+	keyQueryMaxQuads   = "query.max_quads"
+	keyQueryParallel   = "query.parallel"
 )
 
 func getContext() (context.Context, func()) {
@@ -44,7 +47,9 @@ func registerQueryFlags(cmd *cobra.Command) {
 	cmd.Flags().Bool("init", false, "initialize the database before using it")
 	cmd.Flags().String("lang", "gizmo", `query language to use ("`+strings.Join(langs, `", "`)+`")`)
 	cmd.Flags().DurationP("timeout", "t", 30*time.Second, "elapsed time until an individual query times out")
+	cmd.Flags().Int("parallel", 0, "run independent branches of queries in parallel, buffering a given number of results (0 = disabled)")
 	viper.BindPFlag(keyQueryTimeout, cmd.Flags().Lookup("timeout"))
+	viper.BindPFlag(keyQueryParallel, cmd.Flags().Lookup("parallel"))
 	registerLoadFlags(cmd)
 }
 
@@ -65,8 +70,12 @@ func NewReplCmd() *cobra.Command {
 
 			ctx, cancel := getContext()
 			defer cancel()
+			ctx = query.WithParallel(ctx, viper.GetInt(keyQueryParallel))
+			if !viper.GetBool(KeyReadOnly) {
+				ctx = query.WithWriter(ctx, h.QuadWriter)
+			}
 
-			timeout := viper.GetDuration("timeout")
+			timeout := viper.GetDuration(keyQueryTimeout)
 			lang, _ := cmd.Flags().GetString("lang")
 			return repl.Repl(ctx, h, lang, timeout)
 		},
@@ -80,9 +89,31 @@ func NewQueryCmd() *cobra.Command {
 		Use:     "query",
 		Aliases: []string{"qu"},
 		Short:   "Run a query in a specified database and print results.",
+		Long: `Run a query in a specified database and print results to stdout.
+
+The query is passed as an argument, read from a file set by --file, or read from stdin.
+Results are printed in one of the following formats:
+  ndjson  one JSON object per line (default)
+  json    a single JSON array
+  csv     CSV with a header of all tags, sorted by name
+  table   the same columns as csv, aligned for reading`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			rw, err := newResultWriter(os.Stdout, format)
+			if err != nil {
+				return err
+			}
 			var querystr string
-			if len(args) == 0 {
+			if file, _ := cmd.Flags().GetString("file"); file != "" {
+				if len(args) != 0 {
+					return fmt.Errorf("query cannot be passed both as an argument and as a file")
+				}
+				bytes, err := ioutil.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				querystr = string(bytes)
+			} else if len(args) == 0 {
 				bytes, err := ioutil.ReadAll(os.Stdin)
 				if err != nil {
 					return fmt.Errorf("Error occured while reading from stdin : %s.", err)
@@ -106,8 +137,12 @@ func NewQueryCmd() *cobra.Command {
 
 			ctx, cancel := getContext()
 			defer cancel()
+			ctx = query.WithParallel(ctx, viper.GetInt(keyQueryParallel))
+			if !viper.GetBool(KeyReadOnly) {
+				ctx = query.WithWriter(ctx, h.QuadWriter)
+			}
 
-			timeout := viper.GetDuration("timeout")
+			timeout := viper.GetDuration(keyQueryTimeout)
 			if timeout > 0 {
 				ctx, cancel = context.WithTimeout(ctx, timeout)
 				defer cancel()
@@ -122,7 +157,6 @@ func NewQueryCmd() *cobra.Command {
 			if l == nil {
 				return fmt.Errorf("unknown query language: %q", lang)
 			}
-			enc := json.NewEncoder(os.Stdout)
 			sess := l.Session(h)
 			ch := make(chan query.Result, 100)
 			go sess.Execute(ctx, querystr, ch, limit)
@@ -132,7 +166,7 @@ func NewQueryCmd() *cobra.Command {
 					return ctx.Err()
 				case r, ok := <-ch:
 					if !ok {
-						return nil
+						return rw.Close()
 					} else if err = r.Err(); err != nil {
 						return err
 					}
@@ -145,13 +179,17 @@ func NewQueryCmd() *cobra.Command {
 						}
 						obj = m
 					}
-					enc.Encode(obj)
+					if err = rw.WriteResult(obj); err != nil {
+						return err
+					}
 				}
 			}
-			return nil
+			return rw.Close()
 		},
 	}
 	registerQueryFlags(cmd)
 	cmd.Flags().IntP("limit", "n", 100, "limit a number of results")
+	cmd.Flags().StringP("file", "f", "", "read the query from a file")
+	cmd.Flags().String("format", "ndjson", `output format: "`+strings.Join(resultFormats, `", "`)+`"`)
 	return cmd
 }
@@ -0,0 +1,170 @@
+package command
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// resultsColumn is a column name used for query results that are not objects.
+const resultsColumn = "result"
+
+// resultFormats lists output formats supported by the query command.
+var resultFormats = []string{"json", "ndjson", "csv", "table"}
+
+// resultWriter writes query results to the output in one of resultFormats.
+type resultWriter interface {
+	// WriteResult writes a single result. Results may be buffered until Close is called.
+	WriteResult(r interface{}) error
+	Close() error
+}
+
+func newResultWriter(w io.Writer, format string) (resultWriter, error) {
+	switch format {
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return &ndjsonResults{enc: enc}, nil
+	case "json":
+		return &bufferedResults{w: w, write: writeResultsJSON}, nil
+	case "csv":
+		return &bufferedResults{w: w, write: writeResultsCSV}, nil
+	case "table":
+		return &bufferedResults{w: w, write: writeResultsTable}, nil
+	}
+	return nil, fmt.Errorf("unsupported output format: %q (expected one of %s)", format, strings.Join(resultFormats, ", "))
+}
+
+// ndjsonResults writes each result as a JSON object on a separate line, without buffering.
+type ndjsonResults struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonResults) WriteResult(r interface{}) error { return w.enc.Encode(r) }
+func (w *ndjsonResults) Close() error                    { return nil }
+
+// bufferedResults collects all results and writes them on Close.
+// It is used by formats that need to know all columns beforehand.
+type bufferedResults struct {
+	w     io.Writer
+	rows  []interface{}
+	write func(w io.Writer, rows []interface{}) error
+}
+
+func (w *bufferedResults) WriteResult(r interface{}) error {
+	w.rows = append(w.rows, r)
+	return nil
+}
+
+func (w *bufferedResults) Close() error {
+	return w.write(w.w, w.rows)
+}
+
+func writeResultsJSON(w io.Writer, rows []interface{}) error {
+	if rows == nil {
+		rows = []interface{}{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// resultObject returns the result as a map of columns.
+func resultObject(r interface{}) map[string]interface{} {
+	switch r := r.(type) {
+	case map[string]interface{}:
+		return r
+	case map[string]quad.Value:
+		m := make(map[string]interface{}, len(r))
+		for k, v := range r {
+			m[k] = v
+		}
+		return m
+	}
+	return map[string]interface{}{resultsColumn: r}
+}
+
+// resultColumns returns a sorted list of all keys of results.
+func resultColumns(rows []interface{}) []string {
+	seen := make(map[string]struct{})
+	var cols []string
+	for _, r := range rows {
+		for k := range resultObject(r) {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				cols = append(cols, k)
+			}
+		}
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// resultCell formats a single value of the result. Strings are printed as-is,
+// other quad values are printed in N-Quads notation.
+func resultCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case quad.Value:
+		return quad.ToString(v)
+	case fmt.Stringer:
+		return v.String()
+	case int, int64, float64, bool:
+		return fmt.Sprint(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// resultRecords converts results to a header and records with one cell per column.
+func resultRecords(rows []interface{}, fnc func(rec []string) error) error {
+	cols := resultColumns(rows)
+	if err := fnc(cols); err != nil {
+		return err
+	}
+	rec := make([]string, len(cols))
+	for _, r := range rows {
+		m := resultObject(r)
+		for i, c := range cols {
+			rec[i] = resultCell(m[c])
+		}
+		if err := fnc(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeResultsCSV(w io.Writer, rows []interface{}) error {
+	cw := csv.NewWriter(w)
+	if err := resultRecords(rows, cw.Write); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeResultsTable(w io.Writer, rows []interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	err := resultRecords(rows, func(rec []string) error {
+		_, err := fmt.Fprintln(tw, strings.Join(rec, "\t"))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,116 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+)
+
+func NewStatsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Print statistics of the database.",
+		Long: `Print statistics of the database: total number of quads and nodes, number of quads for each predicate,
+distribution of node value types and size of internal structures of the backend (if supported).
+
+Statistics are calculated by iterating over the whole database for most backends, thus it may take a while.
+With --estimate, only the number of quads and nodes is printed; it may be estimated, but is cheap to get.
+Key-value backends also print predicates and value types estimated from sampled statistics, if they were collected.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printBackendInfo()
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+
+			ctx, cancel := getContext()
+			defer cancel()
+			est, _ := cmd.Flags().GetBool("estimate")
+			st, err := graph.StatsOf(ctx, h.QuadStore, !est)
+			if err != nil {
+				return err
+			}
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(st)
+			}
+			top, _ := cmd.Flags().GetInt("top")
+			return printStats(os.Stdout, st, top)
+		},
+	}
+	cmd.Flags().Bool("json", false, "print statistics as JSON")
+	cmd.Flags().Bool("estimate", false, "print only the number of quads and nodes, possibly estimated")
+	cmd.Flags().Int("top", 20, "number of most used predicates to print (0 for all)")
+	return cmd
+}
+
+type statsEntry struct {
+	Name  string
+	Count int64
+}
+
+// sortedStats returns map entries sorted by count in descending order.
+func sortedStats(m map[string]int64) []statsEntry {
+	out := make([]statsEntry, 0, len(m))
+	for k, v := range m {
+		out = append(out, statsEntry{Name: k, Count: v})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func printStats(w io.Writer, st graph.Stats, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "quads:\t%d\n", st.Quads)
+	fmt.Fprintf(tw, "nodes:\t%d\n", st.Nodes)
+	if !st.Exact {
+		fmt.Fprintf(tw, "(estimated)\n")
+	}
+	if st.Predicates == nil {
+		// only counts were requested
+		return tw.Flush()
+	}
+
+	preds := sortedStats(st.Predicates)
+	fmt.Fprintf(tw, "\npredicates (%d):\n", len(preds))
+	if top > 0 && len(preds) > top {
+		preds = preds[:top]
+	}
+	for _, p := range preds {
+		fmt.Fprintf(tw, "  %s\t%d\n", p.Name, p.Count)
+	}
+	if n := len(st.Predicates) - len(preds); n > 0 {
+		fmt.Fprintf(tw, "  (%d more)\n", n)
+	}
+
+	fmt.Fprintf(tw, "\nvalue types:\n")
+	for _, t := range sortedStats(st.ValueTypes) {
+		fmt.Fprintf(tw, "  %s\t%d\n", t.Name, t.Count)
+	}
+
+	if len(st.Storage) != 0 {
+		fmt.Fprintf(tw, "\nstorage:\n")
+		var total int64
+		for _, s := range sortedStats(st.Storage) {
+			total += s.Count
+			fmt.Fprintf(tw, "  %s\t%s\n", s.Name, internal.FormatBytes(s.Count))
+		}
+		fmt.Fprintf(tw, "  total\t%s\n", internal.FormatBytes(total))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	keyTextType       = "text.type"
+	keyTextPath       = "text.path"
+	keyTextPredicates = "text.predicates"
+	keyTextOptions    = "text.options"
+)
+
+// setupTextIndex wraps the store of the handle to maintain a text index set in the config, if any.
+func setupTextIndex(h *graph.Handle, opts graph.Options, db string) error {
+	list := viper.GetStringSlice(keyTextPredicates)
+	if len(list) == 0 {
+		return nil
+	}
+	preds := make([]quad.IRI, 0, len(list))
+	for _, s := range list {
+		preds = append(preds, quad.IRI(s).Full())
+	}
+	qs, err := text.New(h.QuadStore, text.Config{
+		Type:       viper.GetString(keyTextType),
+		Path:       indexPath(keyTextPath, db),
+		Predicates: preds,
+		Options:    graph.Options(viper.GetStringMap(keyTextOptions)),
+	})
+	if err != nil {
+		return err
+	}
+	qw, err := graph.NewQuadWriter("single", qs, opts)
+	if err != nil {
+		qs.Index().Close()
+		return err
+	}
+	h.QuadWriter.Close()
+	h.QuadStore, h.QuadWriter = qs, qw
+	clog.Infof("maintaining text index for %d predicates", len(preds))
+	return nil
+}
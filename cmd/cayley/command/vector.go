@@ -0,0 +1,50 @@
+package command
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/ann"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/vector"
+)
+
+const (
+	keyVectorType       = "vector.type"
+	keyVectorPath       = "vector.path"
+	keyVectorPredicates = "vector.predicates"
+	keyVectorMetric     = "vector.metric"
+	keyVectorOptions    = "vector.options"
+)
+
+// setupVectorIndex wraps the store of the handle to maintain a nearest neighbours index set in the config, if any.
+func setupVectorIndex(h *graph.Handle, opts graph.Options, db string) error {
+	list := viper.GetStringSlice(keyVectorPredicates)
+	if len(list) == 0 {
+		return nil
+	}
+	preds := make([]quad.IRI, 0, len(list))
+	for _, s := range list {
+		preds = append(preds, quad.IRI(s).Full())
+	}
+	qs, err := ann.New(h.QuadStore, ann.Config{
+		Type:       viper.GetString(keyVectorType),
+		Path:       indexPath(keyVectorPath, db),
+		Predicates: preds,
+		Metric:     vector.Metric(viper.GetString(keyVectorMetric)),
+		Options:    graph.Options(viper.GetStringMap(keyVectorOptions)),
+	})
+	if err != nil {
+		return err
+	}
+	qw, err := graph.NewQuadWriter("single", qs, opts)
+	if err != nil {
+		qs.Index().Close()
+		return err
+	}
+	h.QuadWriter.Close()
+	h.QuadStore, h.QuadWriter = qs, qw
+	clog.Infof("maintaining vector index for %d predicates", len(preds))
+	return nil
+}
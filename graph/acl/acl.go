@@ -0,0 +1,189 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package acl restricts access to quads by their labels (named graphs).
+//
+// A Policy grants roles read or write access to quads with specific labels. A QuadStore view created
+// with NewStore for a set of roles only returns quads from readable graphs and rejects writes to other graphs,
+// so a single store can be shared by multiple tenants. Roles of the request are usually set by an authentication
+// middleware with WithRoles.
+package acl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Access is a set of operations permitted on quads.
+type Access uint8
+
+const (
+	// Read access allows to query quads.
+	Read Access = 1 << iota
+	// Write access allows to add and remove quads.
+	Write
+
+	// ReadWrite allows both reads and writes.
+	ReadWrite = Read | Write
+)
+
+func (a Access) String() string {
+	switch a {
+	case 0:
+		return "none"
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case ReadWrite:
+		return "readwrite"
+	}
+	return fmt.Sprintf("Access(%d)", uint8(a))
+}
+
+// ParseAccess parses the name of access level: "read", "write" or "readwrite".
+func ParseAccess(s string) (Access, error) {
+	switch strings.ToLower(s) {
+	case "read", "r":
+		return Read, nil
+	case "write", "w":
+		return Write, nil
+	case "readwrite", "rw":
+		return ReadWrite, nil
+	}
+	return 0, fmt.Errorf("acl: unknown access level: %q", s)
+}
+
+// Anyone is a special role that is granted to all requests, including requests without roles.
+const Anyone = "*"
+
+// Grant gives a role access to quads with given labels.
+type Grant struct {
+	Role string
+	// Labels of quads the role can access. A nil label is the default graph, i.e. quads without a label.
+	Labels []quad.Value
+	// AllLabels grants access to quads with any label, including the default graph.
+	AllLabels bool
+	Access    Access
+}
+
+// perms is access of a set of roles.
+type perms struct {
+	all    Access            // access to all graphs
+	def    Access            // access to the default graph
+	labels map[string]Access // label -> access
+}
+
+func (p *perms) add(g Grant) {
+	if g.AllLabels {
+		p.all |= g.Access
+	}
+	for _, l := range g.Labels {
+		if l == nil {
+			p.def |= g.Access
+			continue
+		}
+		if p.labels == nil {
+			p.labels = make(map[string]Access)
+		}
+		p.labels[quad.StringOf(l)] |= g.Access
+	}
+}
+
+func (p *perms) merge(p2 *perms) {
+	p.all |= p2.all
+	p.def |= p2.def
+	for l, a := range p2.labels {
+		if p.labels == nil {
+			p.labels = make(map[string]Access)
+		}
+		p.labels[l] |= a
+	}
+}
+
+// allowed checks if a label can be accessed.
+func (p *perms) allowed(label quad.Value, a Access) bool {
+	if p.all&a == a {
+		return true
+	} else if label == nil {
+		return p.def&a == a
+	}
+	return p.labels[quad.StringOf(label)]&a == a
+}
+
+// Policy is a set of grants. It is safe for concurrent use.
+type Policy struct {
+	roles map[string]*perms
+}
+
+// NewPolicy creates a policy from a list of grants. Access of multiple grants of the same role is combined.
+func NewPolicy(grants ...Grant) *Policy {
+	p := &Policy{roles: make(map[string]*perms)}
+	for _, g := range grants {
+		r := p.roles[g.Role]
+		if r == nil {
+			r = new(perms)
+			p.roles[g.Role] = r
+		}
+		r.add(g)
+	}
+	return p
+}
+
+// perms returns combined access of given roles.
+func (p *Policy) perms(roles []string) *perms {
+	out := new(perms)
+	if r := p.roles[Anyone]; r != nil {
+		out.merge(r)
+	}
+	for _, name := range roles {
+		if r := p.roles[name]; r != nil {
+			out.merge(r)
+		}
+	}
+	return out
+}
+
+// Allowed checks if any of the roles has a given access to quads with a label.
+func (p *Policy) Allowed(roles []string, label quad.Value, a Access) bool {
+	return p.perms(roles).allowed(label, a)
+}
+
+type rolesKey struct{}
+
+// WithRoles returns a context with roles of the request.
+func WithRoles(ctx context.Context, roles ...string) context.Context {
+	return context.WithValue(ctx, rolesKey{}, roles)
+}
+
+// RolesFromContext returns roles of the request set by WithRoles.
+func RolesFromContext(ctx context.Context) []string {
+	roles, _ := ctx.Value(rolesKey{}).([]string)
+	return roles
+}
+
+// ForbiddenError is returned when writing quads to a graph without write access.
+type ForbiddenError struct {
+	Label quad.Value
+}
+
+func (e *ForbiddenError) Error() string {
+	if e.Label == nil {
+		return "acl: no write access to the default graph"
+	}
+	return fmt.Sprintf("acl: no write access to graph %s", e.Label)
+}
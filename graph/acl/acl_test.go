@@ -0,0 +1,194 @@
+package acl_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+var (
+	tenantA = quad.IRI("tenant-a")
+	tenantB = quad.IRI("tenant-b")
+)
+
+var testQuads = []quad.Quad{
+	quad.MakeIRI("alice", "follows", "bob", "tenant-a"),
+	quad.MakeIRI("bob", "follows", "carol", "tenant-a"),
+	quad.MakeIRI("alice", "follows", "dave", "tenant-b"),
+	quad.MakeIRI("dave", "status", "secret", "tenant-b"),
+	quad.MakeIRI("alice", "name", "public", ""),
+}
+
+var testPolicy = acl.NewPolicy(
+	acl.Grant{Role: "a", Labels: []quad.Value{tenantA}, Access: acl.ReadWrite},
+	acl.Grant{Role: "b", Labels: []quad.Value{tenantB}, Access: acl.Read},
+	acl.Grant{Role: acl.Anyone, Labels: []quad.Value{nil}, Access: acl.Read},
+	acl.Grant{Role: "admin", AllLabels: true, Access: acl.ReadWrite},
+)
+
+func TestPolicy(t *testing.T) {
+	for _, c := range []struct {
+		roles []string
+		label quad.Value
+		a     acl.Access
+		exp   bool
+	}{
+		{nil, nil, acl.Read, true},
+		{nil, nil, acl.Write, false},
+		{nil, tenantA, acl.Read, false},
+		{[]string{"a"}, tenantA, acl.ReadWrite, true},
+		{[]string{"a"}, tenantB, acl.Read, false},
+		{[]string{"b"}, tenantB, acl.Write, false},
+		{[]string{"a", "b"}, tenantB, acl.Read, true},
+		{[]string{"admin"}, quad.IRI("other"), acl.ReadWrite, true},
+		{[]string{"unknown"}, tenantA, acl.Read, false},
+	} {
+		require.Equal(t, c.exp, testPolicy.Allowed(c.roles, c.label, c.a), "%v %v %v", c.roles, c.label, c.a)
+	}
+
+	a, err := acl.ParseAccess("readwrite")
+	require.NoError(t, err)
+	require.Equal(t, acl.ReadWrite, a)
+	_, err = acl.ParseAccess("admin")
+	require.Error(t, err)
+
+	ctx := acl.WithRoles(context.Background(), "a", "b")
+	require.Equal(t, []string{"a", "b"}, acl.RolesFromContext(ctx))
+	require.Nil(t, acl.RolesFromContext(context.Background()))
+}
+
+func allValues(t testing.TB, qs graph.QuadStore, p *path.Path) []quad.Value {
+	vals, err := p.Iterate(context.TODO()).AllValues(qs)
+	require.NoError(t, err)
+	return vals
+}
+
+func TestStoreRead(t *testing.T) {
+	mem := memstore.New(testQuads...)
+
+	qs := acl.NewStore(mem, testPolicy, "a")
+	require.ElementsMatch(t, []quad.Value{quad.IRI("bob"), quad.IRI("public")},
+		allValues(t, qs, path.StartPath(qs, quad.IRI("alice")).Out()))
+	require.ElementsMatch(t, []quad.Value{quad.IRI("carol")},
+		allValues(t, qs, path.StartPath(qs, quad.IRI("alice")).Out(quad.IRI("follows")).Out(quad.IRI("follows"))))
+	// nodes of other graphs are not visible
+	require.Nil(t, qs.ValueOf(quad.IRI("dave")))
+	require.Nil(t, allValues(t, qs, path.StartPath(qs, quad.IRI("dave")).Out()))
+	require.Empty(t, allValues(t, qs, path.StartPath(qs).Has(quad.IRI("status"))))
+	require.ElementsMatch(t, []quad.Value{
+		quad.IRI("alice"), quad.IRI("bob"), quad.IRI("carol"), quad.IRI("public"),
+		quad.IRI("follows"), quad.IRI("name"), tenantA,
+	}, allValues(t, qs, path.StartPath(qs)))
+	require.Empty(t, allValues(t, qs, path.StartPath(qs).LabelContext(tenantB).Out()))
+
+	// anonymous requests only see the default graph
+	anon := acl.NewStore(mem, testPolicy)
+	require.ElementsMatch(t, []quad.Value{quad.IRI("public")},
+		allValues(t, anon, path.StartPath(anon, quad.IRI("alice")).Out()))
+
+	admin := acl.NewStore(mem, testPolicy, "admin")
+	require.ElementsMatch(t, []quad.Value{quad.IRI("bob"), quad.IRI("dave"), quad.IRI("public")},
+		allValues(t, admin, path.StartPath(admin, quad.IRI("alice")).Out()))
+}
+
+func countQuads(t testing.TB, qs graph.QuadStore) int {
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	n := 0
+	for it.Next(context.TODO()) {
+		n++
+	}
+	require.NoError(t, it.Err())
+	return n
+}
+
+func TestStoreWrite(t *testing.T) {
+	mem := memstore.New(testQuads...)
+
+	qs := acl.NewStore(mem, testPolicy, "a", "b")
+	qw, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("carol", "follows", "alice", "tenant-a")))
+	err = qw.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("carol", "follows", "bob", "tenant-a"),
+		quad.MakeIRI("carol", "follows", "dave", "tenant-b"),
+	})
+	require.Equal(t, &acl.ForbiddenError{Label: tenantB}, err)
+	err = qw.RemoveQuad(testQuads[4])
+	require.Equal(t, &acl.ForbiddenError{}, err)
+	// nothing is written if any of the quads is forbidden
+	require.Equal(t, len(testQuads)+1, countQuads(t, mem))
+	require.NoError(t, qw.RemoveQuad(testQuads[0]))
+	require.Equal(t, len(testQuads), countQuads(t, mem))
+}
+
+func TestStoreNames(t *testing.T) {
+	mem := memstore.New(testQuads...)
+	qs := acl.NewStore(mem, testPolicy, "a")
+
+	dave := mem.ValueOf(quad.IRI("dave"))
+	require.NotNil(t, dave)
+	require.Nil(t, qs.NameOf(dave))
+	require.Equal(t, quad.IRI("bob"), qs.NameOf(mem.ValueOf(quad.IRI("bob"))))
+
+	it := mem.QuadIterator(quad.Label, mem.ValueOf(tenantB))
+	defer it.Close()
+	require.True(t, it.Next(context.TODO()))
+	require.Equal(t, quad.Quad{}, qs.Quad(it.Result()))
+}
+
+// recordWriter records quads passed to it instead of writing them.
+type recordWriter struct {
+	graph.QuadWriter
+	txs [][]graph.Delta
+}
+
+func (w *recordWriter) AddQuadSet(quads []quad.Quad) error {
+	var deltas []graph.Delta
+	for _, q := range quads {
+		deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Add})
+	}
+	w.txs = append(w.txs, deltas)
+	return nil
+}
+
+func (w *recordWriter) ApplyTransaction(tx *graph.Transaction) error {
+	w.txs = append(w.txs, tx.Deltas)
+	return nil
+}
+
+func TestWriter(t *testing.T) {
+	mem := memstore.New(testQuads...)
+	qs := acl.NewStore(mem, testPolicy, "a", "b")
+	rec := &recordWriter{}
+	qw := acl.NewWriter(qs, graph.NewLabelWriter(rec, tenantA))
+
+	// writes are passed to the underlying writer, after the default label is set by it
+	q := quad.MakeIRI("carol", "follows", "alice", "tenant-a")
+	require.NoError(t, qw.AddQuadSet([]quad.Quad{q}))
+	require.Len(t, rec.txs, 1)
+
+	err := qw.AddQuadSet([]quad.Quad{q, quad.MakeIRI("carol", "follows", "dave", "tenant-b")})
+	require.Equal(t, &acl.ForbiddenError{Label: tenantB}, err)
+	require.Len(t, rec.txs, 1)
+
+	// matching deletes only see readable quads, and fail if any of them is not writable
+	tx := graph.NewTransaction()
+	tx.Append(graph.Delta{Quad: quad.Quad{Subject: quad.IRI("alice"), Predicate: quad.IRI("follows")}, Action: graph.DeleteMatching})
+	err = qw.ApplyTransaction(tx)
+	require.Equal(t, &acl.ForbiddenError{Label: tenantB}, err)
+
+	qw = acl.NewWriter(acl.NewStore(mem, testPolicy, "a"), rec)
+	require.NoError(t, qw.ApplyTransaction(tx))
+	require.Equal(t, []graph.Delta{{Quad: testQuads[0], Action: graph.Delete}}, rec.txs[len(rec.txs)-1])
+	require.NoError(t, qw.Close())
+}
@@ -0,0 +1,147 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+)
+
+var _ graph.Iterator = (*filter)(nil)
+
+// filter passes results of the subiterator accepted by a function.
+// Unlike iterator.ValueFilter, the function receives references, thus it can be used for quads.
+type filter struct {
+	uid    uint64
+	tags   graph.Tagger
+	sub    graph.Iterator
+	name   string
+	accept func(graph.Value) bool
+	result graph.Value
+	err    error
+}
+
+func newFilter(sub graph.Iterator, name string, accept func(graph.Value) bool) *filter {
+	return &filter{
+		uid:    iterator.NextUID(),
+		sub:    sub,
+		name:   name,
+		accept: accept,
+	}
+}
+
+func (it *filter) UID() uint64 {
+	return it.uid
+}
+
+func (it *filter) Close() error {
+	return it.sub.Close()
+}
+
+func (it *filter) Reset() {
+	it.sub.Reset()
+	it.err = nil
+	it.result = nil
+}
+
+func (it *filter) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *filter) Clone() graph.Iterator {
+	out := newFilter(it.sub.Clone(), it.name, it.accept)
+	out.tags.CopyFrom(it)
+	return out
+}
+
+func (it *filter) Next(ctx context.Context) bool {
+	for it.sub.Next(ctx) {
+		val := it.sub.Result()
+		if it.accept(val) {
+			it.result = val
+			return true
+		}
+	}
+	it.err = it.sub.Err()
+	return false
+}
+
+func (it *filter) NextPath(ctx context.Context) bool {
+	// results of the subiterator were already checked
+	if !it.sub.NextPath(ctx) {
+		it.err = it.sub.Err()
+		return false
+	}
+	return true
+}
+
+func (it *filter) Err() error {
+	return it.err
+}
+
+func (it *filter) Result() graph.Value {
+	return it.result
+}
+
+func (it *filter) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.sub}
+}
+
+func (it *filter) Contains(ctx context.Context, val graph.Value) bool {
+	if !it.accept(val) {
+		return false
+	}
+	ok := it.sub.Contains(ctx, val)
+	if ok {
+		it.result = val
+	} else {
+		it.err = it.sub.Err()
+	}
+	return ok
+}
+
+func (it *filter) Type() graph.Type {
+	return graph.Filter
+}
+
+func (it *filter) String() string {
+	return fmt.Sprintf("ACLFilter(%s)", it.name)
+}
+
+func (it *filter) Optimize() (graph.Iterator, bool) {
+	sub, changed := it.sub.Optimize()
+	if changed {
+		it.sub.Close()
+		it.sub = sub
+	}
+	return it, false
+}
+
+func (it *filter) Stats() graph.IteratorStats {
+	return it.sub.Stats()
+}
+
+func (it *filter) TagResults(dst map[string]graph.Value) {
+	it.tags.TagResult(dst, it.Result())
+	it.sub.TagResults(dst)
+}
+
+func (it *filter) Size() (int64, bool) {
+	sz, _ := it.sub.Size()
+	return sz, false
+}
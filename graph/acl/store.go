@@ -0,0 +1,256 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acl
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var (
+	_ graph.QuadStore          = (*Store)(nil)
+	_ graph.DeltaLog           = (*Store)(nil)
+	_ graph.HorizonStore       = (*Store)(nil)
+	_ graph.CapabilityReporter = (*Store)(nil)
+)
+
+// Store is a view of a QuadStore restricted to graphs accessible by a set of roles.
+//
+// Quads are only returned if their label is readable, and nodes are only visible if they are a part of
+// at least one readable quad. Writes are rejected with ForbiddenError if any of the quads has a label
+// that is not writable; nothing is written in this case.
+//
+// Store intentionally does not implement graph.Wrapper, thus optional features of the underlying store
+// that could bypass the restrictions (metadata, provenance, backend-specific query optimizations and indexes
+// maintained by wrappers) are not available through the view. Text, geospatial and vector searches fall
+// back to scanning literals of readable quads.
+type Store struct {
+	qs graph.QuadStore
+	p  *perms
+
+	mu     sync.RWMutex
+	labels map[interface{}]bool // label ref -> readable
+	nodes  map[interface{}]bool // node ref -> visible
+}
+
+// NewStore creates a view of the QuadStore restricted by the policy to graphs accessible by given roles.
+// The view is cheap to create, so it is usually created for each request.
+func NewStore(qs graph.QuadStore, p *Policy, roles ...string) *Store {
+	return &Store{
+		qs: qs, p: p.perms(roles),
+		labels: make(map[interface{}]bool),
+		nodes:  make(map[interface{}]bool),
+	}
+}
+
+// readsAll checks if all quads are readable, so no filtering is needed.
+func (s *Store) readsAll() bool {
+	return s.p.all&Read != 0
+}
+
+// labelReadable checks if quads with a given label node are readable.
+func (s *Store) labelReadable(ref graph.Value) bool {
+	if ref == nil {
+		return s.p.def&Read != 0
+	}
+	key := graph.ToKey(ref)
+	s.mu.RLock()
+	ok, cached := s.labels[key]
+	s.mu.RUnlock()
+	if cached {
+		return ok
+	}
+	ok = s.p.allowed(s.qs.NameOf(ref), Read)
+	s.mu.Lock()
+	s.labels[key] = ok
+	s.mu.Unlock()
+	return ok
+}
+
+func (s *Store) quadReadable(ref graph.Value) bool {
+	return s.labelReadable(s.qs.QuadDirection(ref, quad.Label))
+}
+
+// visible checks if a node is a part of at least one readable quad.
+// Results are cached, since the store is usually created for a single request.
+func (s *Store) visible(ref graph.Value) bool {
+	if s.readsAll() {
+		return true
+	}
+	key := graph.ToKey(ref)
+	s.mu.RLock()
+	ok, cached := s.nodes[key]
+	s.mu.RUnlock()
+	if cached {
+		return ok
+	}
+	ctx := context.TODO()
+	for _, d := range quad.Directions {
+		it := s.QuadIterator(d, ref)
+		ok = it.Next(ctx)
+		it.Close()
+		if ok {
+			break
+		}
+	}
+	s.mu.Lock()
+	s.nodes[key] = ok
+	s.mu.Unlock()
+	return ok
+}
+
+func (s *Store) filterQuads(it graph.Iterator) graph.Iterator {
+	if s.readsAll() {
+		return it
+	}
+	return newFilter(it, "quads", s.quadReadable)
+}
+
+// ApplyDeltas checks that all quads can be written and applies deltas to the underlying store.
+func (s *Store) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error {
+	for _, d := range in {
+		if !s.p.allowed(d.Quad.Label, Write) {
+			return &ForbiddenError{Label: d.Quad.Label}
+		}
+	}
+	return s.qs.ApplyDeltas(in, opts)
+}
+
+// ApplyDeltasAt implements graph.HorizonStore. Same as ApplyDeltas, quads are checked before writing.
+func (s *Store) ApplyDeltasAt(in []graph.Delta, opts graph.IgnoreOpts, h int64) error {
+	for _, d := range in {
+		if !s.p.allowed(d.Quad.Label, Write) {
+			return &ForbiddenError{Label: d.Quad.Label}
+		}
+	}
+	hs, ok := graph.Unwrap(s.qs).(graph.HorizonStore)
+	if !ok {
+		return graph.ErrNotSupported
+	}
+	return hs.ApplyDeltasAt(in, opts, h)
+}
+
+// Horizon implements graph.HorizonStore.
+func (s *Store) Horizon(ctx context.Context) (int64, error) {
+	return graph.Horizon(ctx, s.qs)
+}
+
+// LogEntries implements graph.DeltaLog. Deltas of quads that are not readable are removed from the entries,
+// and entries without deltas are skipped.
+func (s *Store) LogEntries(ctx context.Context, from, to int64) ([]graph.LogEntry, error) {
+	list, err := graph.LogEntries(ctx, s.qs, from, to)
+	if err != nil || s.readsAll() {
+		return list, err
+	}
+	out := list[:0]
+	for _, e := range list {
+		deltas := e.Deltas[:0]
+		for _, d := range e.Deltas {
+			if s.p.allowed(d.Quad.Label, Read) {
+				deltas = append(deltas, d)
+			}
+		}
+		if len(deltas) != 0 {
+			e.Deltas = deltas
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// Capabilities implements graph.CapabilityReporter.
+func (s *Store) Capabilities() graph.Capabilities {
+	c := graph.CapabilitiesOf(s.qs)
+	return graph.Capabilities{
+		Transactions:      c.Transactions,
+		ConditionalWrites: c.ConditionalWrites,
+		DeltaLog:          c.DeltaLog,
+	}
+}
+
+// Quad returns a quad by reference, or an empty quad if it is not readable.
+func (s *Store) Quad(ref graph.Value) quad.Quad {
+	if !s.readsAll() && !s.quadReadable(ref) {
+		return quad.Quad{}
+	}
+	return s.qs.Quad(ref)
+}
+
+// QuadIterator returns readable quads with a given node in a given direction.
+func (s *Store) QuadIterator(d quad.Direction, ref graph.Value) graph.Iterator {
+	if s.readsAll() {
+		return s.qs.QuadIterator(d, ref)
+	} else if d == quad.Label {
+		if !s.labelReadable(ref) {
+			return iterator.NewNull()
+		}
+		return s.qs.QuadIterator(d, ref)
+	}
+	return s.filterQuads(s.qs.QuadIterator(d, ref))
+}
+
+// NodesAllIterator returns nodes that are a part of at least one readable quad.
+func (s *Store) NodesAllIterator() graph.Iterator {
+	it := s.qs.NodesAllIterator()
+	if s.readsAll() {
+		return it
+	}
+	return newFilter(it, "nodes", s.visible)
+}
+
+func (s *Store) QuadsAllIterator() graph.Iterator {
+	return s.filterQuads(s.qs.QuadsAllIterator())
+}
+
+// ValueOf returns a reference to a node, or nil if the node is not a part of any readable quad.
+func (s *Store) ValueOf(v quad.Value) graph.Value {
+	ref := s.qs.ValueOf(v)
+	if ref == nil || s.visible(ref) {
+		return ref
+	}
+	return nil
+}
+
+// NameOf returns a value of a node, or nil if the node is not a part of any readable quad.
+func (s *Store) NameOf(ref graph.Value) quad.Value {
+	if ref == nil || !s.visible(ref) {
+		return nil
+	}
+	return s.qs.NameOf(ref)
+}
+
+// Size returns the size of the underlying store.
+func (s *Store) Size() int64 {
+	return s.qs.Size()
+}
+
+// OptimizeIterator does nothing. Iterators are not passed to the underlying store, since it might
+// replace them with its own iterators that are not filtered.
+func (s *Store) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool) {
+	return it, false
+}
+
+func (s *Store) QuadDirection(ref graph.Value, d quad.Direction) graph.Value {
+	return s.qs.QuadDirection(ref, d)
+}
+
+// Close does nothing; the underlying store is not closed.
+func (s *Store) Close() error {
+	return nil
+}
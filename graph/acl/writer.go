@@ -0,0 +1,161 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acl
+
+import (
+	"context"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var (
+	_ graph.QuadWriter     = (*Writer)(nil)
+	_ graph.HorizonWriter  = (*Writer)(nil)
+	_ graph.ExpiringWriter = (*Writer)(nil)
+)
+
+// Writer checks that quads can be written by roles of the restricted store and passes writes to another QuadWriter.
+//
+// Writes go through the writer of the database, thus replication, hooks and default labels configured
+// for it still apply. If any of the quads has a label that is not writable, ForbiddenError is returned
+// and nothing is written. Extended actions are resolved against the restricted store, so they never
+// match quads of graphs that are not readable.
+type Writer struct {
+	s  *Store
+	qw graph.QuadWriter
+}
+
+// NewWriter wraps a QuadWriter of the store underlying s to check writes against permissions of s.
+// Closing the returned writer does not close qw.
+func NewWriter(s *Store, qw graph.QuadWriter) *Writer {
+	return &Writer{s: s, qw: qw}
+}
+
+// check returns ForbiddenError if any of the deltas has a label that is not writable.
+func (w *Writer) check(deltas []graph.Delta) error {
+	for _, d := range deltas {
+		if !w.s.p.allowed(d.Quad.Label, Write) {
+			return &ForbiddenError{Label: d.Quad.Label}
+		}
+	}
+	return nil
+}
+
+func (w *Writer) checkQuads(quads ...quad.Quad) error {
+	for _, q := range quads {
+		if !w.s.p.allowed(q.Label, Write) {
+			return &ForbiddenError{Label: q.Label}
+		}
+	}
+	return nil
+}
+
+func (w *Writer) AddQuad(q quad.Quad) error {
+	if err := w.checkQuads(q); err != nil {
+		return err
+	}
+	return w.qw.AddQuad(q)
+}
+
+func (w *Writer) AddQuadSet(quads []quad.Quad) error {
+	if err := w.checkQuads(quads...); err != nil {
+		return err
+	}
+	return w.qw.AddQuadSet(quads)
+}
+
+func (w *Writer) RemoveQuad(q quad.Quad) error {
+	if err := w.checkQuads(q); err != nil {
+		return err
+	}
+	return w.qw.RemoveQuad(q)
+}
+
+// ApplyTransaction resolves extended actions against the restricted store, checks and applies the transaction.
+func (w *Writer) ApplyTransaction(tx *graph.Transaction) error {
+	return graph.ApplyResolved(context.TODO(), w.s, tx.Deltas, func(deltas []graph.Delta, h int64) error {
+		if err := w.check(deltas); err != nil {
+			return err
+		}
+		tx := &graph.Transaction{Deltas: deltas}
+		// writers without horizon checks apply resolved deltas unconditionally, same as stores without HorizonStore
+		if hw, ok := w.qw.(graph.HorizonWriter); ok && h >= 0 {
+			return hw.ApplyTransactionAt(tx, h)
+		}
+		return w.qw.ApplyTransaction(tx)
+	})
+}
+
+// resolve converts extended actions of the transaction against the restricted store and checks resulting deltas.
+func (w *Writer) resolve(tx *graph.Transaction) (*graph.Transaction, error) {
+	deltas := tx.Deltas
+	if graph.HasExtendedDeltas(deltas) {
+		var err error
+		if deltas, err = graph.ResolveDeltas(context.TODO(), w.s, deltas); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.check(deltas); err != nil {
+		return nil, err
+	}
+	return &graph.Transaction{Deltas: deltas}, nil
+}
+
+// ApplyTransactionAt implements graph.HorizonWriter.
+func (w *Writer) ApplyTransactionAt(tx *graph.Transaction, h int64) error {
+	tx, err := w.resolve(tx)
+	if err != nil {
+		return err
+	}
+	return graph.ApplyTransactionAt(w.qw, tx, h)
+}
+
+// ApplyTransactionWithExpiration implements graph.ExpiringWriter.
+func (w *Writer) ApplyTransactionWithExpiration(tx *graph.Transaction, expires time.Time) error {
+	tx, err := w.resolve(tx)
+	if err != nil {
+		return err
+	}
+	return graph.ApplyTransactionWithExpiration(w.qw, tx, expires)
+}
+
+// RemoveNode removes all readable quads with a given node. Quads of other graphs are kept.
+func (w *Writer) RemoveNode(v quad.Value) error {
+	gv := w.s.ValueOf(v)
+	if gv == nil {
+		return graph.ErrNodeNotExists
+	}
+	ctx := context.TODO()
+	tx := graph.NewTransaction()
+	for _, d := range quad.Directions {
+		it := w.s.QuadIterator(d, gv)
+		for it.Next(ctx) {
+			tx.RemoveQuad(w.s.Quad(it.Result()))
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return w.ApplyTransaction(tx)
+}
+
+// Close does nothing; the underlying writer is not closed.
+func (w *Writer) Close() error {
+	return nil
+}
@@ -0,0 +1,259 @@
+package algo_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/algo"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+var (
+	follows = quad.IRI("follows")
+	likes   = quad.IRI("likes")
+)
+
+func link(s, o string) quad.Quad {
+	return quad.Make(quad.IRI(s), follows, quad.IRI(o), nil)
+}
+
+var testGraph = []quad.Quad{
+	link("a", "b"),
+	link("b", "c"),
+	link("c", "a"),
+	link("c", "d"),
+	link("d", "e"),
+	link("e", "d"),
+	link("g", "h"),
+	quad.Make(quad.IRI("a"), likes, quad.IRI("b"), nil),
+	quad.Make(quad.IRI("f"), quad.IRI("name"), quad.String("f"), nil),
+}
+
+func load(t testing.TB, quads []quad.Quad, opt *algo.LoadOptions) (graph.QuadStore, *algo.Snapshot) {
+	qs := memstore.New(quads...)
+	s, err := algo.Load(context.TODO(), qs, opt)
+	require.NoError(t, err)
+	return qs, s
+}
+
+func name(s *algo.Snapshot, i int) string {
+	return string(s.Node(i).(quad.IRI))
+}
+
+// byNode converts per-node results to a map keyed by node names.
+func byNode(s *algo.Snapshot, n int, fnc func(i int) interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		m[name(s, i)] = fnc(i)
+	}
+	return m
+}
+
+func TestLoad(t *testing.T) {
+	_, s := load(t, testGraph, nil)
+	require.Equal(t, 8, s.Len())
+	require.Equal(t, 7, s.Edges())
+	a, ok := s.Index(quad.IRI("a"))
+	require.True(t, ok)
+	require.Len(t, s.Out(a), 1)
+	require.Equal(t, quad.IRI("b"), s.Node(s.Out(a)[0]))
+	_, ok = s.Index(quad.String("f"))
+	require.False(t, ok)
+
+	_, s = load(t, testGraph, &algo.LoadOptions{Predicates: []quad.Value{likes}, Literals: true})
+	require.Equal(t, 2, s.Len())
+	require.Equal(t, 1, s.Edges())
+
+	_, s = load(t, testGraph, &algo.LoadOptions{Literals: true})
+	require.Equal(t, 9, s.Len())
+	require.Equal(t, 8, s.Edges())
+}
+
+func components(s *algo.Snapshot, comp []int) [][]string {
+	groups := make([][]string, len(algo.ComponentSizes(comp)))
+	for i, c := range comp {
+		groups[c] = append(groups[c], name(s, i))
+	}
+	return groups
+}
+
+func TestComponents(t *testing.T) {
+	_, s := load(t, testGraph, nil)
+	wcc := algo.WeaklyConnected(s)
+	require.ElementsMatch(t, []int{5, 1, 2}, algo.ComponentSizes(wcc))
+	for _, g := range components(s, wcc) {
+		if len(g) == 5 {
+			require.ElementsMatch(t, []string{"a", "b", "c", "d", "e"}, g)
+		}
+	}
+
+	scc := algo.StronglyConnected(s)
+	require.ElementsMatch(t, []int{3, 2, 1, 1, 1}, algo.ComponentSizes(scc))
+	var big [][]string
+	for _, g := range components(s, scc) {
+		if len(g) > 1 {
+			big = append(big, g)
+		}
+	}
+	require.Len(t, big, 2)
+	if len(big[0]) != 3 {
+		big[0], big[1] = big[1], big[0]
+	}
+	require.ElementsMatch(t, []string{"a", "b", "c"}, big[0])
+	require.ElementsMatch(t, []string{"d", "e"}, big[1])
+}
+
+func TestPageRank(t *testing.T) {
+	ctx := context.TODO()
+	_, s := load(t, []quad.Quad{link("a", "b"), link("b", "c"), link("c", "a")}, nil)
+	rank, err := algo.PageRank(ctx, s, nil)
+	require.NoError(t, err)
+	for _, r := range rank {
+		require.InDelta(t, 1.0/3, r, 1e-6)
+	}
+
+	_, s = load(t, testGraph, nil)
+	rank, err = algo.PageRank(ctx, s, &algo.PageRankOptions{Workers: 3})
+	require.NoError(t, err)
+	sum := 0.0
+	for _, r := range rank {
+		sum += r
+	}
+	require.InDelta(t, 1.0, sum, 1e-6)
+	m := byNode(s, len(rank), func(i int) interface{} { return rank[i] })
+	// the d-e cycle collects the rank from the a-b-c cycle
+	require.True(t, m["d"].(float64) > m["a"].(float64))
+	require.True(t, m["h"].(float64) > m["g"].(float64))
+	require.InDelta(t, m["g"].(float64), m["f"].(float64), 1e-9)
+}
+
+func TestCentrality(t *testing.T) {
+	ctx := context.TODO()
+	_, s := load(t, []quad.Quad{link("x", "y"), link("y", "z"), link("x", "w")}, nil)
+	deg := algo.DegreeCentrality(s, quad.Subject)
+	require.Equal(t, map[string]interface{}{
+		"x": 2.0 / 3, "y": 1.0 / 3, "z": 0.0, "w": 0.0,
+	}, byNode(s, len(deg), func(i int) interface{} { return deg[i] }))
+	cnt := algo.Degree(s, quad.Object)
+	require.Equal(t, map[string]interface{}{
+		"x": 0, "y": 1, "z": 1, "w": 1,
+	}, byNode(s, len(cnt), func(i int) interface{} { return cnt[i] }))
+	deg = algo.DegreeCentrality(s, quad.Any)
+	require.Equal(t, map[string]interface{}{
+		"x": 2.0 / 3, "y": 2.0 / 3, "z": 1.0 / 3, "w": 1.0 / 3,
+	}, byNode(s, len(deg), func(i int) interface{} { return deg[i] }))
+
+	for _, workers := range []int{1, 3} {
+		bc, err := algo.Betweenness(ctx, s, &algo.BetweennessOptions{Workers: workers})
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"x": 0.0, "y": 1.0, "z": 0.0, "w": 0.0,
+		}, byNode(s, len(bc), func(i int) interface{} { return bc[i] }))
+	}
+
+	// two shortest paths from a to d
+	_, s = load(t, []quad.Quad{link("a", "b"), link("a", "c"), link("b", "d"), link("c", "d")}, nil)
+	bc, err := algo.Betweenness(ctx, s, &algo.BetweennessOptions{Normalized: true})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"a": 0.0, "b": 0.5 / 6, "c": 0.5 / 6, "d": 0.0,
+	}, byNode(s, len(bc), func(i int) interface{} { return bc[i] }))
+}
+
+func TestWrite(t *testing.T) {
+	ctx := context.TODO()
+	qs, s := load(t, testGraph, nil)
+	qw, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+
+	comp := quad.IRI("component")
+	vals := algo.IntValues(algo.WeaklyConnected(s))
+	require.NoError(t, algo.Write(ctx, qs, qw, s, comp, nil, vals))
+	// results are replaced on the next run
+	vals[0] = nil
+	require.NoError(t, algo.Write(ctx, qs, qw, s, comp, nil, vals))
+
+	got, err := path.StartPath(qs).Has(comp).Iterate(ctx).AllValues(qs)
+	require.NoError(t, err)
+	require.Len(t, got, s.Len()-1)
+	n, err := path.StartPath(qs).Out(comp).Iterate(ctx).Count()
+	require.NoError(t, err)
+	require.Equal(t, int64(s.Len()-1), n)
+}
+
+func TestSampleNodes(t *testing.T) {
+	ctx := context.TODO()
+	qs, _ := load(t, testGraph, nil)
+	rnd := rand.New(rand.NewSource(1))
+	nodes, err := algo.SampleNodes(ctx, qs, 3, rnd)
+	require.NoError(t, err)
+	require.Len(t, nodes, 3)
+	seen := make(map[string]bool)
+	for _, v := range nodes {
+		require.False(t, seen[quad.StringOf(v)], "duplicate node: %v", v)
+		seen[quad.StringOf(v)] = true
+	}
+
+	// nodes, predicates and literals
+	nodes, err = algo.SampleNodes(ctx, qs, 100, rnd)
+	require.NoError(t, err)
+	require.Len(t, nodes, 12)
+}
+
+func TestRandomWalk(t *testing.T) {
+	ctx := context.TODO()
+	qs, _ := load(t, testGraph, nil)
+	opt := &algo.WalkOptions{Length: 5, Predicates: []quad.Value{follows}, Rand: rand.New(rand.NewSource(1))}
+
+	// a-b-c cycle has a single way out
+	w, err := algo.RandomWalk(ctx, qs, quad.IRI("a"), opt)
+	require.NoError(t, err)
+	require.Len(t, w, 5)
+	require.Equal(t, []quad.Value{quad.IRI("a"), quad.IRI("b"), quad.IRI("c")}, w[:3])
+
+	// dead end
+	w, err = algo.RandomWalk(ctx, qs, quad.IRI("g"), opt)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.IRI("g"), quad.IRI("h")}, w)
+
+	// literals are not visited
+	w, err = algo.RandomWalk(ctx, qs, quad.IRI("f"), nil)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.IRI("f")}, w)
+
+	_, err = algo.RandomWalk(ctx, qs, quad.IRI("x"), nil)
+	require.Equal(t, graph.ErrNodeNotExists, err)
+
+	opt.Undirected = true
+	walks, err := algo.RandomWalks(ctx, qs, []quad.Value{quad.IRI("h"), quad.IRI("x")}, 3, opt)
+	require.NoError(t, err)
+	require.Len(t, walks, 3)
+	for _, w := range walks {
+		require.Equal(t, []quad.Value{quad.IRI("h"), quad.IRI("g"), quad.IRI("h"), quad.IRI("g"), quad.IRI("h")}, w)
+	}
+}
+
+func TestRandomWalkBias(t *testing.T) {
+	ctx := context.TODO()
+	// star: the center is linked with all leaves in both directions
+	var quads []quad.Quad
+	for _, leaf := range []string{"l1", "l2", "l3", "l4"} {
+		quads = append(quads, link("c", leaf), link(leaf, "c"))
+	}
+	qs, _ := load(t, quads, nil)
+	opt := &algo.WalkOptions{Length: 3, Return: 1e-6, InOut: 1e6, Rand: rand.New(rand.NewSource(1))}
+	for i := 0; i < 20; i++ {
+		w, err := algo.RandomWalk(ctx, qs, quad.IRI("c"), opt)
+		require.NoError(t, err)
+		require.Len(t, w, 3)
+		// low return parameter forces the walk back to the center
+		require.Equal(t, quad.IRI("c"), w[2])
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package algo
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Degree counts links of each node. Direction selects which links are counted: quad.Subject for outgoing,
+// quad.Object for incoming and quad.Any for both.
+func Degree(s *Snapshot, dir quad.Direction) []int {
+	out := make([]int, s.Len())
+	for i := range out {
+		if dir == quad.Subject || dir == quad.Any {
+			out[i] += len(s.Out(i))
+		}
+		if dir == quad.Object || dir == quad.Any {
+			out[i] += len(s.In(i))
+		}
+	}
+	return out
+}
+
+// DegreeCentrality calculates the degree of each node, normalized by the maximal possible degree (n-1).
+// Direction selects which edges are counted, as in Degree.
+func DegreeCentrality(s *Snapshot, dir quad.Direction) []float64 {
+	n := s.Len()
+	out := make([]float64, n)
+	if n <= 1 {
+		return out
+	}
+	norm := float64(n - 1)
+	for i, d := range Degree(s, dir) {
+		out[i] = float64(d) / norm
+	}
+	return out
+}
+
+// BetweennessOptions are parameters of the betweenness centrality algorithm.
+type BetweennessOptions struct {
+	// Normalized divides values by the number of node pairs not including the node itself, (n-1)(n-2).
+	Normalized bool
+	// Workers is the number of goroutines to use. Defaults to GOMAXPROCS.
+	Workers int
+}
+
+// Betweenness calculates betweenness centrality of each node: the sum of fractions of shortest directed paths
+// between all pairs of other nodes that pass through the node. Options may be nil.
+//
+// It uses Brandes' algorithm, which runs in O(n*m) time, thus it may be slow for large graphs.
+func Betweenness(ctx context.Context, s *Snapshot, opt *BetweennessOptions) ([]float64, error) {
+	var o BetweennessOptions
+	if opt != nil {
+		o = *opt
+	}
+	n := s.Len()
+	nw := workers(o.Workers)
+	if nw > n {
+		nw = n
+	}
+	partial := make([][]float64, nw)
+	errs := make([]error, nw)
+	// interleave sources, since the cost of a source depends on its position in the graph
+	parallel(nw, nw, func(_, from, to int) {
+		for w := from; w < to; w++ {
+			b := newBrandes(n)
+			for src := w; src < n; src += nw {
+				if err := ctx.Err(); err != nil {
+					errs[w] = err
+					return
+				}
+				b.accumulate(s, src)
+			}
+			partial[w] = b.score
+		}
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	out := make([]float64, n)
+	for _, p := range partial {
+		for i, v := range p {
+			out[i] += v
+		}
+	}
+	if o.Normalized && n > 2 {
+		norm := float64((n - 1) * (n - 2))
+		for i := range out {
+			out[i] /= norm
+		}
+	}
+	return out, nil
+}
+
+// brandes holds the state of a single-source step of Brandes' algorithm.
+type brandes struct {
+	score []float64
+	sigma []float64
+	dist  []int
+	delta []float64
+	order []int // nodes in order of BFS visits; used as a queue
+}
+
+func newBrandes(n int) *brandes {
+	return &brandes{
+		score: make([]float64, n),
+		sigma: make([]float64, n),
+		dist:  make([]int, n),
+		delta: make([]float64, n),
+		order: make([]int, 0, n),
+	}
+}
+
+// accumulate adds dependencies of a given source node to the scores.
+func (b *brandes) accumulate(s *Snapshot, src int) {
+	for i := range b.dist {
+		b.dist[i] = -1
+		b.sigma[i] = 0
+		b.delta[i] = 0
+	}
+	b.order = append(b.order[:0], src)
+	b.dist[src] = 0
+	b.sigma[src] = 1
+	// BFS from the source, counting the number of shortest paths
+	for head := 0; head < len(b.order); head++ {
+		v := b.order[head]
+		for _, w := range s.Out(v) {
+			if b.dist[w] < 0 {
+				b.dist[w] = b.dist[v] + 1
+				b.order = append(b.order, w)
+			}
+			if b.dist[w] == b.dist[v]+1 {
+				b.sigma[w] += b.sigma[v]
+			}
+		}
+	}
+	// back-propagate dependencies in order of non-increasing distance
+	for i := len(b.order) - 1; i >= 0; i-- {
+		w := b.order[i]
+		for _, v := range s.In(w) {
+			if b.dist[v] >= 0 && b.dist[v] == b.dist[w]-1 {
+				b.delta[v] += b.sigma[v] / b.sigma[w] * (1 + b.delta[w])
+			}
+		}
+		if w != src {
+			b.score[w] += b.delta[w]
+		}
+	}
+}
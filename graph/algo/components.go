@@ -0,0 +1,149 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package algo
+
+// WeaklyConnected finds weakly connected components of the graph, ignoring the direction of edges.
+// It returns a component number for each node. Components are numbered from 0 in order of their first node.
+func WeaklyConnected(s *Snapshot) []int {
+	n := s.Len()
+	parent := make([]int, n)
+	size := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+		size[i] = 1
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	for i := 0; i < n; i++ {
+		for _, j := range s.Out(i) {
+			a, b := find(i), find(j)
+			if a == b {
+				continue
+			}
+			if size[a] < size[b] {
+				a, b = b, a
+			}
+			parent[b] = a
+			size[a] += size[b]
+		}
+	}
+	for i := range parent {
+		parent[i] = find(i)
+	}
+	return renumber(parent)
+}
+
+// StronglyConnected finds strongly connected components of the graph.
+// It returns a component number for each node. Components are numbered from 0 in order of their first node.
+func StronglyConnected(s *Snapshot) []int {
+	// iterative version of Tarjan's algorithm
+	const unvisited = -1
+	n := s.Len()
+	var (
+		index   = make([]int, n)
+		low     = make([]int, n)
+		onStack = make([]bool, n)
+		comp    = make([]int, n)
+		stack   []int
+		ncomp   int
+		counter int
+	)
+	type frame struct {
+		node, edge int
+	}
+	var calls []frame
+	for i := range index {
+		index[i] = unvisited
+	}
+	for root := 0; root < n; root++ {
+		if index[root] != unvisited {
+			continue
+		}
+		calls = append(calls[:0], frame{node: root})
+		for len(calls) != 0 {
+			f := &calls[len(calls)-1]
+			v := f.node
+			if f.edge == 0 {
+				index[v], low[v] = counter, counter
+				counter++
+				stack = append(stack, v)
+				onStack[v] = true
+			}
+			out := s.Out(v)
+			if f.edge < len(out) {
+				w := out[f.edge]
+				f.edge++
+				if index[w] == unvisited {
+					calls = append(calls, frame{node: w})
+				} else if onStack[w] && index[w] < low[v] {
+					low[v] = index[w]
+				}
+				continue
+			}
+			calls = calls[:len(calls)-1]
+			if low[v] == index[v] {
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					comp[w] = ncomp
+					if w == v {
+						break
+					}
+				}
+				ncomp++
+			}
+			if len(calls) != 0 {
+				p := calls[len(calls)-1].node
+				if low[v] < low[p] {
+					low[p] = low[v]
+				}
+			}
+		}
+	}
+	return renumber(comp)
+}
+
+// renumber assigns component numbers in order of the first node of each component.
+func renumber(comp []int) []int {
+	ids := make(map[int]int)
+	out := make([]int, len(comp))
+	for i, c := range comp {
+		id, ok := ids[c]
+		if !ok {
+			id = len(ids)
+			ids[c] = id
+		}
+		out[i] = id
+	}
+	return out
+}
+
+// ComponentSizes returns the number of nodes in each component.
+func ComponentSizes(comp []int) []int {
+	var sizes []int
+	for _, c := range comp {
+		for c >= len(sizes) {
+			sizes = append(sizes, 0)
+		}
+		sizes[c]++
+	}
+	return sizes
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package algo
+
+import (
+	"context"
+	"math"
+)
+
+// PageRankOptions are parameters of the PageRank algorithm.
+type PageRankOptions struct {
+	// Damping is the probability of following a link. Defaults to 0.85.
+	Damping float64
+	// MaxIterations limits the number of iterations. Defaults to 100.
+	MaxIterations int
+	// Tolerance is the sum of rank changes at which ranks are considered converged. Defaults to 1e-6.
+	Tolerance float64
+	// Workers is the number of goroutines to use. Defaults to GOMAXPROCS.
+	Workers int
+}
+
+// PageRank calculates PageRank of all nodes in the snapshot. Ranks sum up to 1.
+// The rank of nodes without outgoing links is distributed uniformly. Options may be nil.
+func PageRank(ctx context.Context, s *Snapshot, opt *PageRankOptions) ([]float64, error) {
+	var o PageRankOptions
+	if opt != nil {
+		o = *opt
+	}
+	if o.Damping <= 0 || o.Damping >= 1 {
+		o.Damping = 0.85
+	}
+	if o.MaxIterations <= 0 {
+		o.MaxIterations = 100
+	}
+	if o.Tolerance <= 0 {
+		o.Tolerance = 1e-6
+	}
+	nw := workers(o.Workers)
+
+	n := s.Len()
+	if n == 0 {
+		return nil, nil
+	}
+	rank := make([]float64, n)
+	next := make([]float64, n)
+	contrib := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	diffs := make([]float64, nw)
+	for iter := 0; iter < o.MaxIterations; iter++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		dangling := 0.0
+		for i, r := range rank {
+			if d := len(s.Out(i)); d != 0 {
+				contrib[i] = r / float64(d)
+			} else {
+				contrib[i] = 0
+				dangling += r
+			}
+		}
+		base := (1-o.Damping)/float64(n) + o.Damping*dangling/float64(n)
+		parallel(n, nw, func(w, from, to int) {
+			diff := 0.0
+			for i := from; i < to; i++ {
+				sum := 0.0
+				for _, j := range s.In(i) {
+					sum += contrib[j]
+				}
+				next[i] = base + o.Damping*sum
+				diff += math.Abs(next[i] - rank[i])
+			}
+			diffs[w] = diff
+		})
+		rank, next = next, rank
+		diff := 0.0
+		for w, d := range diffs {
+			diff += d
+			diffs[w] = 0
+		}
+		if diff < o.Tolerance {
+			break
+		}
+	}
+	return rank, nil
+}
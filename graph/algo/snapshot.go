@@ -0,0 +1,245 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package algo implements graph algorithms, such as PageRank, connected components and centrality measures.
+//
+// Algorithms operate on a Snapshot - a compact in-memory adjacency structure loaded from a QuadStore,
+// thus they do not depend on the performance of the backend. Results can be written back as quads (see Write).
+//
+// Random walks and node sampling work directly on a QuadStore, thus they can be used on graphs that do not fit in memory.
+package algo
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// LoadOptions controls which quads are loaded into a Snapshot.
+type LoadOptions struct {
+	// Predicates restricts edges to quads with one of given predicates. All quads are used if empty.
+	Predicates []quad.Value
+	// Literals includes literal values as nodes. By default, only IRIs and blank nodes are used.
+	Literals bool
+}
+
+// Snapshot is an immutable adjacency structure of a directed graph loaded from a QuadStore.
+// Each quad is an edge from the subject to the object; predicates and labels are ignored.
+//
+// Nodes are numbered from 0 to Len()-1. Parallel edges are merged, thus nodes are connected
+// by at most one edge in each direction. It is safe for concurrent use.
+type Snapshot struct {
+	nodes []quad.Value
+	index map[string]int
+	out   adjacency
+	in    adjacency
+	edges int
+}
+
+// adjacency is a compressed list of neighbours: neighbours of node i are stored in adj[off[i]:off[i+1]].
+type adjacency struct {
+	off []int
+	adj []int
+}
+
+func (a adjacency) of(i int) []int {
+	return a.adj[a.off[i]:a.off[i+1]]
+}
+
+func newAdjacency(n int, edges [][2]int, from, to int) adjacency {
+	off := make([]int, n+1)
+	for _, e := range edges {
+		off[e[from]+1]++
+	}
+	for i := 1; i <= n; i++ {
+		off[i] += off[i-1]
+	}
+	adj := make([]int, len(edges))
+	pos := make([]int, n)
+	copy(pos, off[:n])
+	for _, e := range edges {
+		adj[pos[e[from]]] = e[to]
+		pos[e[from]]++
+	}
+	return adjacency{off: off, adj: adj}
+}
+
+func isNode(v quad.Value) bool {
+	switch v.(type) {
+	case quad.IRI, quad.BNode:
+		return true
+	}
+	return false
+}
+
+// Load builds a snapshot of the graph stored in the QuadStore. Options may be nil.
+func Load(ctx context.Context, qs graph.QuadStore, opt *LoadOptions) (*Snapshot, error) {
+	if opt == nil {
+		opt = &LoadOptions{}
+	}
+	var it graph.Iterator
+	if len(opt.Predicates) == 0 {
+		it = qs.QuadsAllIterator()
+	} else {
+		it = shape.BuildIterator(qs, shape.Quads{
+			{Dir: quad.Predicate, Values: shape.Lookup(opt.Predicates)},
+		})
+	}
+	defer it.Close()
+
+	var (
+		refs  []graph.Value
+		edges [][2]int
+	)
+	ids := make(map[interface{}]int)
+	id := func(v graph.Value) int {
+		k := graph.ToKey(v)
+		i, ok := ids[k]
+		if !ok {
+			i = len(refs)
+			ids[k] = i
+			refs = append(refs, v)
+		}
+		return i
+	}
+	for it.Next(ctx) {
+		q := it.Result()
+		s := id(qs.QuadDirection(q, quad.Subject))
+		o := id(qs.QuadDirection(q, quad.Object))
+		edges = append(edges, [2]int{s, o})
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	ids = nil
+	vals, err := graph.ValuesOf(ctx, qs, refs)
+	if err != nil {
+		return nil, err
+	}
+	refs = nil
+
+	// renumber nodes, skipping literals if necessary
+	s := &Snapshot{index: make(map[string]int, len(vals))}
+	remap := make([]int, len(vals))
+	for i, v := range vals {
+		if v == nil || (!opt.Literals && !isNode(v)) {
+			remap[i] = -1
+			continue
+		}
+		remap[i] = len(s.nodes)
+		s.index[quad.StringOf(v)] = len(s.nodes)
+		s.nodes = append(s.nodes, v)
+	}
+	n := 0
+	for _, e := range edges {
+		a, b := remap[e[0]], remap[e[1]]
+		if a < 0 || b < 0 {
+			continue
+		}
+		edges[n] = [2]int{a, b}
+		n++
+	}
+	edges = edges[:n]
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	n = 0
+	for i, e := range edges {
+		if i == 0 || e != edges[n-1] {
+			edges[n] = e
+			n++
+		}
+	}
+	edges = edges[:n]
+	s.edges = len(edges)
+	s.out = newAdjacency(len(s.nodes), edges, 0, 1)
+	s.in = newAdjacency(len(s.nodes), edges, 1, 0)
+	return s, nil
+}
+
+// Len returns the number of nodes in the snapshot.
+func (s *Snapshot) Len() int {
+	return len(s.nodes)
+}
+
+// Edges returns the number of edges in the snapshot.
+func (s *Snapshot) Edges() int {
+	return s.edges
+}
+
+// Node returns the value of the node with a given index.
+func (s *Snapshot) Node(i int) quad.Value {
+	return s.nodes[i]
+}
+
+// Index returns the index of the node with a given value.
+func (s *Snapshot) Index(v quad.Value) (int, bool) {
+	i, ok := s.index[quad.StringOf(v)]
+	return i, ok
+}
+
+// Out returns indexes of nodes linked from a given node. The slice must not be modified.
+func (s *Snapshot) Out(i int) []int {
+	return s.out.of(i)
+}
+
+// In returns indexes of nodes linking to a given node. The slice must not be modified.
+func (s *Snapshot) In(i int) []int {
+	return s.in.of(i)
+}
+
+func workers(n int) int {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	return n
+}
+
+// parallel splits a range of nodes [0, n) between a given number of workers.
+func parallel(n, workers int, fnc func(w, from, to int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		if n > 0 {
+			fnc(0, 0, n)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	chunk := (n + workers - 1) / workers
+	for w := 0; w < workers; w++ {
+		from, to := w*chunk, (w+1)*chunk
+		if to > n {
+			to = n
+		}
+		if from >= to {
+			break
+		}
+		wg.Add(1)
+		go func(w, from, to int) {
+			defer wg.Done()
+			fnc(w, from, to)
+		}(w, from, to)
+	}
+	wg.Wait()
+}
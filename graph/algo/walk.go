@@ -0,0 +1,237 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package algo
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func newRand(r *rand.Rand) *rand.Rand {
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return r
+}
+
+// SampleNodes returns up to n nodes chosen uniformly at random. If rnd is nil, a new random source is used.
+//
+// It uses reservoir sampling, thus it scans all nodes once, but keeps only n of them in memory.
+func SampleNodes(ctx context.Context, qs graph.QuadStore, n int, rnd *rand.Rand) ([]quad.Value, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	rnd = newRand(rnd)
+	it := qs.NodesAllIterator()
+	defer it.Close()
+	res := make([]graph.Value, 0, n)
+	seen := 0
+	for it.Next(ctx) {
+		seen++
+		if len(res) < n {
+			res = append(res, it.Result())
+		} else if i := rnd.Intn(seen); i < n {
+			res[i] = it.Result()
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return graph.ValuesOf(ctx, qs, res)
+}
+
+// WalkOptions are parameters of random walks.
+//
+// Walks are biased in the same way as in node2vec: the probability of returning to the previous node
+// is proportional to 1/Return, of moving to a neighbour of the previous node to 1, and of moving
+// further away from it to 1/InOut.
+type WalkOptions struct {
+	// Predicates restricts walks to links with one of given predicates. All links are followed if empty.
+	Predicates []quad.Value
+	// Length is the maximal number of nodes in a walk, including the start node. Defaults to 10.
+	Length int
+	// Return is the return parameter (p). Defaults to 1.
+	Return float64
+	// InOut is the in-out parameter (q). Defaults to 1.
+	InOut float64
+	// Undirected follows links in both directions.
+	Undirected bool
+	// Rand is the source of random numbers. A new source is used if nil.
+	Rand *rand.Rand
+}
+
+// walker performs random walks directly over the QuadStore, thus the graph is not loaded in memory.
+type walker struct {
+	qs    graph.QuadStore
+	opt   WalkOptions
+	preds map[interface{}]struct{}
+}
+
+func newWalker(qs graph.QuadStore, opt *WalkOptions) *walker {
+	w := &walker{qs: qs}
+	if opt != nil {
+		w.opt = *opt
+	}
+	if w.opt.Length <= 0 {
+		w.opt.Length = 10
+	}
+	if w.opt.Return <= 0 {
+		w.opt.Return = 1
+	}
+	if w.opt.InOut <= 0 {
+		w.opt.InOut = 1
+	}
+	w.opt.Rand = newRand(w.opt.Rand)
+	if len(w.opt.Predicates) != 0 {
+		w.preds = make(map[interface{}]struct{}, len(w.opt.Predicates))
+		for _, p := range w.opt.Predicates {
+			if gv := qs.ValueOf(p); gv != nil {
+				w.preds[graph.ToKey(gv)] = struct{}{}
+			}
+		}
+	}
+	return w
+}
+
+// step is a node visited by a walk.
+type step struct {
+	ref graph.Value
+	val quad.Value
+}
+
+// neighbours returns IRI and blank nodes linked with a given node.
+func (w *walker) neighbours(ctx context.Context, v graph.Value) ([]step, error) {
+	var refs []graph.Value
+	collect := func(from, to quad.Direction) error {
+		it := w.qs.QuadIterator(from, v)
+		defer it.Close()
+		for it.Next(ctx) {
+			q := it.Result()
+			if w.preds != nil {
+				if _, ok := w.preds[graph.ToKey(w.qs.QuadDirection(q, quad.Predicate))]; !ok {
+					continue
+				}
+			}
+			refs = append(refs, w.qs.QuadDirection(q, to))
+		}
+		return it.Err()
+	}
+	if err := collect(quad.Subject, quad.Object); err != nil {
+		return nil, err
+	}
+	if w.opt.Undirected {
+		if err := collect(quad.Object, quad.Subject); err != nil {
+			return nil, err
+		}
+	}
+	vals, err := graph.ValuesOf(ctx, w.qs, refs)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]step, 0, len(refs))
+	for i, r := range refs {
+		if isNode(vals[i]) {
+			out = append(out, step{ref: r, val: vals[i]})
+		}
+	}
+	return out, nil
+}
+
+// walk performs a single random walk from a given node.
+func (w *walker) walk(ctx context.Context, start quad.Value) ([]quad.Value, error) {
+	cur := w.qs.ValueOf(start)
+	if cur == nil {
+		return nil, graph.ErrNodeNotExists
+	}
+	out := []quad.Value{start}
+	var (
+		prev      graph.Value
+		prevLinks map[interface{}]struct{}
+		weights   []float64
+	)
+	for len(out) < w.opt.Length {
+		if err := ctx.Err(); err != nil {
+			return out, err
+		}
+		next, err := w.neighbours(ctx, cur)
+		if err != nil {
+			return out, err
+		} else if len(next) == 0 {
+			break
+		}
+		var i int
+		if prev == nil || (w.opt.Return == 1 && w.opt.InOut == 1) {
+			i = w.opt.Rand.Intn(len(next))
+		} else {
+			weights = weights[:0]
+			total := 0.0
+			pk := graph.ToKey(prev)
+			for _, n := range next {
+				wt := 1 / w.opt.InOut
+				k := graph.ToKey(n.ref)
+				if k == pk {
+					wt = 1 / w.opt.Return
+				} else if _, ok := prevLinks[k]; ok {
+					wt = 1
+				}
+				weights = append(weights, wt)
+				total += wt
+			}
+			x := w.opt.Rand.Float64() * total
+			for i = 0; i < len(next)-1; i++ {
+				x -= weights[i]
+				if x < 0 {
+					break
+				}
+			}
+		}
+		prevLinks = make(map[interface{}]struct{}, len(next))
+		for _, n := range next {
+			prevLinks[graph.ToKey(n.ref)] = struct{}{}
+		}
+		prev, cur = cur, next[i].ref
+		out = append(out, next[i].val)
+	}
+	return out, nil
+}
+
+// RandomWalk performs a single random walk from a given node. Options may be nil.
+// The walk stops early if a node has no links to follow. Literal values are never visited.
+func RandomWalk(ctx context.Context, qs graph.QuadStore, start quad.Value, opt *WalkOptions) ([]quad.Value, error) {
+	return newWalker(qs, opt).walk(ctx, start)
+}
+
+// RandomWalks performs a given number of random walks from each of the start nodes. Options may be nil.
+// Walks from the same node are returned one after another. Start nodes that do not exist are skipped.
+func RandomWalks(ctx context.Context, qs graph.QuadStore, starts []quad.Value, walks int, opt *WalkOptions) ([][]quad.Value, error) {
+	w := newWalker(qs, opt)
+	var out [][]quad.Value
+	for _, s := range starts {
+		for i := 0; i < walks; i++ {
+			p, err := w.walk(ctx, s)
+			if err == graph.ErrNodeNotExists {
+				break
+			} else if err != nil {
+				return out, err
+			}
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package algo
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// FloatValues converts results of an algorithm to quad values.
+func FloatValues(vals []float64) []quad.Value {
+	out := make([]quad.Value, len(vals))
+	for i, v := range vals {
+		out[i] = quad.Float(v)
+	}
+	return out
+}
+
+// IntValues converts results of an algorithm to quad values.
+func IntValues(vals []int) []quad.Value {
+	out := make([]quad.Value, len(vals))
+	for i, v := range vals {
+		out[i] = quad.Int(v)
+	}
+	return out
+}
+
+// Write stores results of an algorithm as quads <node> <pred> "value" <label>, one for each node of the snapshot.
+// Quads with the same predicate and label written previously are removed first. Nil values are skipped.
+func Write(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, s *Snapshot, pred quad.IRI, label quad.Value, vals []quad.Value) error {
+	// remove results of the previous run
+	it := shape.BuildIterator(qs, shape.Quads{
+		{Dir: quad.Predicate, Values: shape.Lookup{pred}},
+	})
+	var old []quad.Quad
+	for it.Next(ctx) {
+		q := qs.Quad(it.Result())
+		if quad.StringOf(q.Label) == quad.StringOf(label) {
+			old = append(old, q)
+		}
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		return err
+	}
+	tx := graph.NewTransaction()
+	flush := func(force bool) error {
+		if len(tx.Deltas) == 0 || (!force && len(tx.Deltas) < quad.DefaultBatch) {
+			return nil
+		}
+		err := qw.ApplyTransaction(tx)
+		tx = graph.NewTransaction()
+		return err
+	}
+	for _, q := range old {
+		tx.RemoveQuad(q)
+		if err = flush(false); err != nil {
+			return err
+		}
+	}
+	// removals and additions of the same quad cannot be mixed in one transaction
+	if err = flush(true); err != nil {
+		return err
+	}
+	for i, v := range vals {
+		if v == nil {
+			continue
+		}
+		tx.AddQuad(quad.Quad{Subject: s.Node(i), Predicate: pred, Object: v, Label: label})
+		if err = flush(false); err != nil {
+			return err
+		}
+	}
+	return flush(true)
+}
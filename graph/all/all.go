@@ -2,10 +2,13 @@ package all
 
 import (
 	// supported backends
+	_ "github.com/cayleygraph/cayley/graph/cassandra"
 	_ "github.com/cayleygraph/cayley/graph/kv/bolt"
 	_ "github.com/cayleygraph/cayley/graph/kv/btree"
 	_ "github.com/cayleygraph/cayley/graph/kv/leveldb"
+	_ "github.com/cayleygraph/cayley/graph/kv/redis"
 	_ "github.com/cayleygraph/cayley/graph/memstore"
+	_ "github.com/cayleygraph/cayley/graph/nosql/dynamodb"
 	_ "github.com/cayleygraph/cayley/graph/nosql/elastic"
 	_ "github.com/cayleygraph/cayley/graph/nosql/mongo"
 	_ "github.com/cayleygraph/cayley/graph/nosql/ouch"
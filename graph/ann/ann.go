@@ -0,0 +1,136 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ann maintains approximate nearest neighbours indexes over vector literals of configured predicates.
+//
+// The index is maintained by a QuadStore wrapper (see New) on each write and is used by the query
+// optimizer to resolve shape.VectorSearch. Each node that is a subject of indexed vectors is stored as
+// a separate document. Index types are pluggable (see RegisterIndex), so external vector databases
+// can be used instead of the in-process HNSW index.
+package ann
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/nodeindex"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/vector"
+)
+
+// DefaultType is a type of index used if it is not set in the config.
+const DefaultType = "hnsw"
+
+// Document is a set of vectors of a single node.
+type Document struct {
+	Node quad.Value
+	// Fields maps indexed predicates to vectors of the node.
+	Fields map[quad.IRI][]vector.Vector
+}
+
+// Index is a nearest neighbours index of documents.
+type Index interface {
+	// Update indexes documents, replacing previous versions of them, and removes documents of given nodes.
+	Update(ctx context.Context, docs []Document, del []quad.Value) error
+	// Search returns nodes with vectors nearest to the query vector, ordered by distance.
+	// The limit of the query is always set.
+	Search(ctx context.Context, q graph.VectorQuery) ([]graph.VectorHit, error)
+	// Count returns the number of indexed documents.
+	Count(ctx context.Context) (int64, error)
+	// Clear removes all documents from the index.
+	Clear(ctx context.Context) error
+	// Close releases resources associated with the index.
+	Close() error
+}
+
+// NewIndexFunc opens an index at a given path, creating it if necessary.
+// If the path is empty, the index is kept in memory.
+type NewIndexFunc func(path string, metric vector.Metric, opts graph.Options) (Index, error)
+
+// indexes is a registry of index types.
+var indexes = nodeindex.NewRegistry("ann", DefaultType)
+
+// RegisterIndex registers an index type.
+func RegisterIndex(name string, fnc NewIndexFunc) {
+	indexes.Register(name, fnc)
+}
+
+// Indexes returns names of all registered index types.
+func Indexes() []string {
+	return indexes.Types()
+}
+
+// Config is a configuration of a nearest neighbours index.
+type Config struct {
+	// Type of the index; DefaultType is used if not set.
+	Type string
+	// Path to the index, if it is persistent.
+	Path string
+	// Predicates with vector literals to index.
+	Predicates []quad.IRI
+	// Metric used to compare vectors; vector.DefaultMetric is used if not set.
+	Metric vector.Metric
+	// Options of the index.
+	Options graph.Options
+}
+
+// hit is a candidate document of a search.
+type hit struct {
+	key  string
+	node quad.Value
+	dist float64
+}
+
+// nearest merges candidates of the same document and returns up to n documents ordered by distance.
+func nearest(cands []hit, n int) []graph.VectorHit {
+	best := make(map[string]int, len(cands))
+	var uniq []hit
+	for _, c := range cands {
+		if i, ok := best[c.key]; ok {
+			if c.dist < uniq[i].dist {
+				uniq[i].dist = c.dist
+			}
+			continue
+		}
+		best[c.key] = len(uniq)
+		uniq = append(uniq, c)
+	}
+	sort.Slice(uniq, func(i, j int) bool {
+		if uniq[i].dist != uniq[j].dist {
+			return uniq[i].dist < uniq[j].dist
+		}
+		return uniq[i].key < uniq[j].key
+	})
+	if len(uniq) > n {
+		uniq = uniq[:n]
+	}
+	out := make([]graph.VectorHit, 0, len(uniq))
+	for _, h := range uniq {
+		out = append(out, graph.VectorHit{Node: h.node, Distance: h.dist})
+	}
+	return out
+}
+
+// predSet returns a set of predicates to search, or nil if all predicates are searched.
+func predSet(preds []quad.IRI) map[quad.IRI]struct{} {
+	if len(preds) == 0 {
+		return nil
+	}
+	m := make(map[quad.IRI]struct{}, len(preds))
+	for _, p := range preds {
+		m[p.Full()] = struct{}{}
+	}
+	return m
+}
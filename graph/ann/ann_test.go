@@ -0,0 +1,182 @@
+package ann_test
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/ann"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/nodeindex/nodeindextest"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/vector"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+const (
+	embedding = quad.IRI("embedding")
+	title     = quad.IRI("title")
+)
+
+var testQuads = []quad.Quad{
+	{Subject: quad.IRI("cat"), Predicate: embedding, Object: vector.Vector{1, 0, 0}.TypedString()},
+	{Subject: quad.IRI("kitten"), Predicate: embedding, Object: vector.Vector{0.9, 0.1, 0}.TypedString()},
+	{Subject: quad.IRI("dog"), Predicate: embedding, Object: vector.Vector{0.5, 0.5, 0}.TypedString()},
+	{Subject: quad.IRI("car"), Predicate: embedding, Object: vector.Vector{0, 0, 1}.TypedString()},
+	{Subject: quad.IRI("car"), Predicate: title, Object: vector.Vector{1, 0, 0}.TypedString()},
+	{Subject: quad.IRI("car"), Predicate: quad.IRI("note"), Object: vector.Vector{1, 0, 0}.TypedString()},
+	{Subject: quad.IRI("broken"), Predicate: embedding, Object: quad.TypedString{Value: "[1, x]", Type: vector.Type}},
+}
+
+func search(t testing.TB, qs graph.QuadStore, vec vector.Vector, k int, preds ...quad.IRI) []string {
+	hits, err := graph.SearchVectors(context.TODO(), qs, graph.VectorQuery{Vector: vec, Limit: k, Predicates: preds})
+	return nodeindextest.Nodes(t, hits, err)
+}
+
+func TestStore(t *testing.T) {
+	for _, typ := range []string{"hnsw", "flat"} {
+		t.Run(typ, func(t *testing.T) {
+			qs, err := ann.New(memstore.New(), ann.Config{Type: typ, Predicates: []quad.IRI{embedding, title}})
+			require.NoError(t, err)
+			defer qs.Close()
+			qw, err := graph.NewQuadWriter("single", qs, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, qw.AddQuadSet(testQuads))
+			require.Equal(t, int64(4), nodeindextest.Documents(t, qs.Store))
+			require.True(t, graph.CapabilitiesOf(qs).VectorSearch)
+
+			q := vector.Vector{1, 0, 0}
+			// ordered by distance, the closest vector of each node is used
+			require.Equal(t, []string{"<car>", "<cat>", "<kitten>"}, search(t, qs, q, 3))
+			require.Equal(t, []string{"<cat>", "<kitten>", "<dog>", "<car>"}, search(t, qs, q, 10, embedding))
+
+			require.NoError(t, qw.RemoveQuad(testQuads[0]))
+			require.Equal(t, []string{"<kitten>", "<dog>"}, search(t, qs, q, 2, embedding))
+			require.Equal(t, int64(3), nodeindextest.Documents(t, qs.Store))
+
+			require.NoError(t, qs.Rebuild(context.TODO()))
+			require.Equal(t, int64(3), nodeindextest.Documents(t, qs.Store))
+		})
+	}
+}
+
+func TestStoreBuild(t *testing.T) {
+	mem := memstore.New(testQuads...)
+	_, err := graph.SearchVectors(context.TODO(), mem, graph.VectorQuery{Vector: vector.Vector{1, 0, 0}})
+	require.Equal(t, graph.ErrNotSupported, err)
+
+	qs, err := ann.New(mem, ann.Config{Predicates: []quad.IRI{embedding}, Metric: vector.Euclidean})
+	require.NoError(t, err)
+	defer qs.Close()
+	require.Equal(t, int64(4), nodeindextest.Documents(t, qs.Store))
+
+	_, err = graph.SearchVectors(context.TODO(), qs, graph.VectorQuery{Vector: vector.Vector{1, 0}})
+	require.Error(t, err)
+
+	_, err = ann.New(mem, ann.Config{Type: "unknown", Predicates: []quad.IRI{embedding}})
+	require.Error(t, err)
+	_, err = ann.New(mem, ann.Config{Predicates: []quad.IRI{embedding}, Metric: "manhattan"})
+	require.Error(t, err)
+}
+
+func randomDocs(rnd *rand.Rand, n, dim int) []ann.Document {
+	docs := make([]ann.Document, 0, n)
+	for i := 0; i < n; i++ {
+		vec := make(vector.Vector, dim)
+		for j := range vec {
+			vec[j] = float32(rnd.NormFloat64())
+		}
+		docs = append(docs, ann.Document{
+			Node:   quad.IRI("n" + strconv.Itoa(i)),
+			Fields: map[quad.IRI][]vector.Vector{embedding: {vec}},
+		})
+	}
+	return docs
+}
+
+func TestHNSWRecall(t *testing.T) {
+	const (
+		n       = 2000
+		dim     = 16
+		queries = 50
+		k       = 10
+	)
+	ctx := context.TODO()
+	rnd := rand.New(rand.NewSource(1))
+	docs := randomDocs(rnd, n, dim)
+
+	exact := ann.NewFlat(vector.Cosine)
+	approx := ann.NewHNSW(vector.Cosine, ann.HNSWConfig{})
+	require.NoError(t, exact.Update(ctx, docs, nil))
+	require.NoError(t, approx.Update(ctx, docs, nil))
+
+	// remove most documents to check that the graph is rebuilt
+	var del []quad.Value
+	for _, d := range docs[:n/2+100] {
+		del = append(del, d.Node)
+	}
+	require.NoError(t, exact.Update(ctx, nil, del))
+	require.NoError(t, approx.Update(ctx, nil, del))
+	cnt, err := approx.Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(n/2-100), cnt)
+
+	found := 0
+	for _, q := range randomDocs(rnd, queries, dim) {
+		vq := graph.VectorQuery{Vector: q.Fields[embedding][0], Limit: k}
+		want, err := exact.Search(ctx, vq)
+		require.NoError(t, err)
+		got, err := approx.Search(ctx, vq)
+		require.NoError(t, err)
+		require.Len(t, got, k)
+		ids := make(map[quad.Value]struct{})
+		for _, h := range want {
+			ids[h.Node] = struct{}{}
+		}
+		for _, h := range got {
+			if _, ok := ids[h.Node]; ok {
+				found++
+			}
+		}
+	}
+	recall := float64(found) / (queries * k)
+	require.True(t, recall >= 0.9, "recall: %v", recall)
+}
+
+func TestPathNearestTo(t *testing.T) {
+	mem := memstore.New(testQuads...)
+	idx, err := ann.New(mem, ann.Config{Predicates: []quad.IRI{embedding, title}})
+	require.NoError(t, err)
+	defer idx.Close()
+
+	for _, c := range []struct {
+		name    string
+		qs      graph.QuadStore
+		nearest []quad.Value
+	}{
+		{"index", idx, []quad.Value{quad.IRI("car"), quad.IRI("cat")}},
+		// scan checks all predicates
+		{"scan", mem, []quad.Value{quad.IRI("car"), quad.IRI("cat")}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			run := func(p *path.Path) []quad.Value {
+				vals, err := p.Iterate(context.TODO()).AllValues(c.qs)
+				require.NoError(t, err)
+				return vals
+			}
+			q := vector.Vector{1, 0, 0}
+			require.ElementsMatch(t, c.nearest, run(path.StartPath(c.qs).NearestTo(q, 2)))
+			require.ElementsMatch(t, []quad.Value{quad.IRI("cat"), quad.IRI("kitten")},
+				run(path.StartPath(c.qs).NearestTo(q, 2, embedding)))
+			// nearest nodes are found first and then intersected with the path
+			require.ElementsMatch(t, []quad.Value{quad.IRI("cat")},
+				run(path.StartPath(c.qs, quad.IRI("cat"), quad.IRI("dog")).NearestTo(q, 2, embedding)))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ann
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/vector"
+)
+
+func init() {
+	RegisterIndex("flat", func(path string, metric vector.Metric, opts graph.Options) (Index, error) {
+		return NewFlat(metric), nil
+	})
+}
+
+var _ Index = (*flatIndex)(nil)
+
+// NewFlat creates an in-memory index that compares the query with all indexed vectors.
+// Results are exact, but the search is linear in the number of vectors.
+func NewFlat(metric vector.Metric) Index {
+	return &flatIndex{metric: metric, docs: make(map[string]Document)}
+}
+
+type flatIndex struct {
+	mu     sync.RWMutex
+	metric vector.Metric
+	docs   map[string]Document
+}
+
+func (m *flatIndex) Update(ctx context.Context, docs []Document, del []quad.Value) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range del {
+		delete(m.docs, quad.StringOf(v))
+	}
+	for _, doc := range docs {
+		key := quad.StringOf(doc.Node)
+		if len(doc.Fields) == 0 {
+			delete(m.docs, key)
+			continue
+		}
+		m.docs[key] = doc
+	}
+	return nil
+}
+
+// Search compares the query with all vectors of matching predicates. Vectors of other dimensions are skipped.
+func (m *flatIndex) Search(ctx context.Context, q graph.VectorQuery) ([]graph.VectorHit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	preds := predSet(q.Predicates)
+	var cands []hit
+	for key, doc := range m.docs {
+		for p, vecs := range doc.Fields {
+			if preds != nil {
+				if _, ok := preds[p.Full()]; !ok {
+					continue
+				}
+			}
+			for _, vec := range vecs {
+				if len(vec) != len(q.Vector) {
+					continue
+				}
+				cands = append(cands, hit{key: key, node: doc.Node, dist: m.metric.Distance(q.Vector, vec)})
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return nearest(cands, q.Limit), nil
+}
+
+func (m *flatIndex) Count(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(len(m.docs)), nil
+}
+
+func (m *flatIndex) Clear(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docs = make(map[string]Document)
+	return nil
+}
+
+func (m *flatIndex) Close() error {
+	return nil
+}
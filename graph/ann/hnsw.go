@@ -0,0 +1,367 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ann
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/vector"
+)
+
+const (
+	// DefaultM is a default number of links of each element on upper layers of the HNSW graph.
+	DefaultM = 16
+	// DefaultEfConstruction is a default size of the candidate list used when inserting elements.
+	DefaultEfConstruction = 200
+	// DefaultEfSearch is a default size of the candidate list used for searches.
+	DefaultEfSearch = 64
+)
+
+func init() {
+	RegisterIndex(DefaultType, func(path string, metric vector.Metric, opts graph.Options) (Index, error) {
+		var conf HNSWConfig
+		var err error
+		if conf.M, err = opts.IntKey("m", DefaultM); err != nil {
+			return nil, err
+		}
+		if conf.EfConstruction, err = opts.IntKey("ef_construction", DefaultEfConstruction); err != nil {
+			return nil, err
+		}
+		if conf.EfSearch, err = opts.IntKey("ef_search", DefaultEfSearch); err != nil {
+			return nil, err
+		}
+		if conf.M < 2 || conf.EfConstruction <= 0 || conf.EfSearch <= 0 {
+			return nil, errors.New("ann: m must be at least 2, ef_construction and ef_search must be positive")
+		}
+		return NewHNSW(metric, conf), nil
+	})
+}
+
+// HNSWConfig is a configuration of the HNSW index.
+type HNSWConfig struct {
+	// M is a number of links of each element on upper layers; elements of the bottom layer have 2*M links.
+	M int
+	// EfConstruction is a size of the candidate list used when inserting elements.
+	EfConstruction int
+	// EfSearch is a size of the candidate list used for searches. It is increased for searches with
+	// larger limits and for searches restricted to some of the predicates.
+	EfSearch int
+}
+
+var _ Index = (*hnswIndex)(nil)
+
+// NewHNSW creates an in-memory Hierarchical Navigable Small World index.
+//
+// The number of dimensions is set by the first indexed vector; vectors with other dimensions are not indexed.
+// Removed vectors are kept in the graph until more than half of the vectors are removed, then the graph is rebuilt.
+func NewHNSW(metric vector.Metric, conf HNSWConfig) Index {
+	if conf.M < 2 {
+		conf.M = DefaultM
+	}
+	if conf.EfConstruction <= 0 {
+		conf.EfConstruction = DefaultEfConstruction
+	}
+	if conf.EfSearch <= 0 {
+		conf.EfSearch = DefaultEfSearch
+	}
+	m := &hnswIndex{
+		metric: metric, conf: conf,
+		ml:  1 / math.Log(float64(conf.M)),
+		rnd: rand.New(rand.NewSource(1)),
+	}
+	m.reset()
+	return m
+}
+
+type hnswElem struct {
+	key     string
+	node    quad.Value
+	pred    quad.IRI // full IRI
+	vec     vector.Vector
+	links   [][]int32 // layer -> neighbours
+	deleted bool
+}
+
+type hnswIndex struct {
+	mu     sync.RWMutex
+	metric vector.Metric
+	conf   HNSWConfig
+	ml     float64
+	rnd    *rand.Rand
+
+	dim      int
+	elems    []*hnswElem
+	docs     map[string][]int32 // document key -> elements
+	deleted  int
+	entry    int32
+	maxLevel int
+}
+
+func (m *hnswIndex) reset() {
+	m.dim = 0
+	m.elems = nil
+	m.docs = make(map[string][]int32)
+	m.deleted = 0
+	m.entry = -1
+	m.maxLevel = 0
+}
+
+// maxLinks returns the maximal number of links of an element on a given layer.
+func (m *hnswIndex) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * m.conf.M
+	}
+	return m.conf.M
+}
+
+func (m *hnswIndex) dist(q vector.Vector, id int32) float64 {
+	return m.metric.Distance(q, m.elems[id].vec)
+}
+
+type cand struct {
+	id   int32
+	dist float64
+}
+
+// candHeap is a heap of candidates, ordered by distance in ascending or descending order.
+type candHeap struct {
+	c   []cand
+	max bool
+}
+
+func (h *candHeap) Len() int { return len(h.c) }
+func (h *candHeap) Less(i, j int) bool {
+	if h.max {
+		return h.c[i].dist > h.c[j].dist
+	}
+	return h.c[i].dist < h.c[j].dist
+}
+func (h *candHeap) Swap(i, j int)      { h.c[i], h.c[j] = h.c[j], h.c[i] }
+func (h *candHeap) Push(x interface{}) { h.c = append(h.c, x.(cand)) }
+func (h *candHeap) Pop() interface{} {
+	x := h.c[len(h.c)-1]
+	h.c = h.c[:len(h.c)-1]
+	return x
+}
+
+// searchLayer returns up to ef elements of a layer nearest to the query, ordered by distance.
+// Removed elements are included, since they are still used to navigate the graph.
+func (m *hnswIndex) searchLayer(q vector.Vector, ep int32, ef, level int) []cand {
+	visited := map[int32]struct{}{ep: {}}
+	c := cand{id: ep, dist: m.dist(q, ep)}
+	cands := &candHeap{c: []cand{c}}
+	res := &candHeap{c: []cand{c}, max: true}
+	for cands.Len() != 0 {
+		c := heap.Pop(cands).(cand)
+		if res.Len() >= ef && c.dist > res.c[0].dist {
+			break
+		}
+		for _, n := range m.elems[c.id].links[level] {
+			if _, ok := visited[n]; ok {
+				continue
+			}
+			visited[n] = struct{}{}
+			d := m.dist(q, n)
+			if res.Len() < ef || d < res.c[0].dist {
+				heap.Push(cands, cand{id: n, dist: d})
+				heap.Push(res, cand{id: n, dist: d})
+				if res.Len() > ef {
+					heap.Pop(res)
+				}
+			}
+		}
+	}
+	out := res.c
+	sort.Slice(out, func(i, j int) bool { return out[i].dist < out[j].dist })
+	return out
+}
+
+// closest returns up to n nearest candidates to a given element.
+func (m *hnswIndex) closest(id int32, links []int32, n int) []int32 {
+	if len(links) <= n {
+		return links
+	}
+	vec := m.elems[id].vec
+	cands := make([]cand, 0, len(links))
+	for _, l := range links {
+		cands = append(cands, cand{id: l, dist: m.dist(vec, l)})
+	}
+	sort.Slice(cands, func(i, j int) bool { return cands[i].dist < cands[j].dist })
+	out := links[:0]
+	for _, c := range cands[:n] {
+		out = append(out, c.id)
+	}
+	return out
+}
+
+// insert adds a vector to the graph. Vectors with a wrong number of dimensions are ignored.
+func (m *hnswIndex) insert(key string, node quad.Value, pred quad.IRI, vec vector.Vector) {
+	if len(vec) == 0 {
+		return
+	} else if m.dim == 0 {
+		m.dim = len(vec)
+	} else if len(vec) != m.dim {
+		return
+	}
+	level := int(math.Floor(-math.Log(1-m.rnd.Float64()) * m.ml))
+	id := int32(len(m.elems))
+	e := &hnswElem{key: key, node: node, pred: pred.Full(), vec: vec, links: make([][]int32, level+1)}
+	m.elems = append(m.elems, e)
+	m.docs[key] = append(m.docs[key], id)
+	if m.entry < 0 {
+		m.entry, m.maxLevel = id, level
+		return
+	}
+	ep := m.entry
+	for l := m.maxLevel; l > level; l-- {
+		ep = m.searchLayer(vec, ep, 1, l)[0].id
+	}
+	top := level
+	if top > m.maxLevel {
+		top = m.maxLevel
+	}
+	for l := top; l >= 0; l-- {
+		cands := m.searchLayer(vec, ep, m.conf.EfConstruction, l)
+		max := m.maxLinks(l)
+		links := make([]int32, 0, max)
+		for _, c := range cands {
+			if len(links) >= max {
+				break
+			}
+			links = append(links, c.id)
+		}
+		e.links[l] = links
+		for _, n := range links {
+			ne := m.elems[n]
+			ne.links[l] = m.closest(n, append(ne.links[l], id), max)
+		}
+		ep = cands[0].id
+	}
+	if level > m.maxLevel {
+		m.entry, m.maxLevel = id, level
+	}
+}
+
+func (m *hnswIndex) remove(key string) {
+	for _, id := range m.docs[key] {
+		m.elems[id].deleted = true
+		m.deleted++
+	}
+	delete(m.docs, key)
+}
+
+// rebuild creates a new graph from vectors that were not removed.
+func (m *hnswIndex) rebuild() {
+	old := m.elems
+	m.reset()
+	for _, e := range old {
+		if !e.deleted {
+			m.insert(e.key, e.node, e.pred, e.vec)
+		}
+	}
+}
+
+func (m *hnswIndex) Update(ctx context.Context, docs []Document, del []quad.Value) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range del {
+		m.remove(quad.StringOf(v))
+	}
+	for _, doc := range docs {
+		key := quad.StringOf(doc.Node)
+		m.remove(key)
+		for p, vecs := range doc.Fields {
+			for _, vec := range vecs {
+				m.insert(key, doc.Node, p, vec)
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if m.deleted != 0 && 2*m.deleted > len(m.elems) {
+		m.rebuild()
+	}
+	return nil
+}
+
+// Search finds the nearest vectors on the bottom layer of the graph. If not enough of them match the query,
+// the search is repeated with a larger candidate list.
+func (m *hnswIndex) Search(ctx context.Context, q graph.VectorQuery) ([]graph.VectorHit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.entry < 0 {
+		return nil, nil
+	} else if len(q.Vector) != m.dim {
+		return nil, fmt.Errorf("ann: expected a vector with %d dimensions, got %d", m.dim, len(q.Vector))
+	}
+	preds := predSet(q.Predicates)
+	ep := m.entry
+	for l := m.maxLevel; l > 0; l-- {
+		ep = m.searchLayer(q.Vector, ep, 1, l)[0].id
+	}
+	ef := m.conf.EfSearch
+	if ef < q.Limit {
+		ef = q.Limit
+	}
+	for {
+		var hits []hit
+		for _, c := range m.searchLayer(q.Vector, ep, ef, 0) {
+			e := m.elems[c.id]
+			if e.deleted {
+				continue
+			} else if preds != nil {
+				if _, ok := preds[e.pred]; !ok {
+					continue
+				}
+			}
+			hits = append(hits, hit{key: e.key, node: e.node, dist: c.dist})
+		}
+		out := nearest(hits, q.Limit)
+		if len(out) >= q.Limit || ef >= len(m.elems) {
+			return out, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		ef *= 2
+	}
+}
+
+func (m *hnswIndex) Count(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(len(m.docs)), nil
+}
+
+func (m *hnswIndex) Clear(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reset()
+	return nil
+}
+
+func (m *hnswIndex) Close() error {
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+// Capabilities describes optional features supported by a QuadStore.
+// Callers can use it to choose a strategy upfront instead of relying on ErrNotSupported.
+type Capabilities struct {
+	// Transactions is set if all deltas passed to ApplyDeltas are applied atomically.
+	Transactions bool `json:"transactions"`
+	// RegexPushdown is set if regular expression filters are evaluated by the backend.
+	RegexPushdown bool `json:"regex_pushdown"`
+	// SortPushdown is set if results can be sorted by the backend.
+	SortPushdown bool `json:"sort_pushdown"`
+	// ChangeFeed is set if the store can deliver applied deltas to subscribers (see Subscriber).
+	ChangeFeed bool `json:"change_feed"`
+	// HorizonReads is set if the store can provide read-only views pinned to a horizon (see HorizonReader).
+	HorizonReads bool `json:"horizon_reads"`
+	// ConditionalWrites is set if the store tracks the horizon of writes (see HorizonStore and IfHorizon).
+	ConditionalWrites bool `json:"conditional_writes"`
+	// DeltaLog is set if the store keeps a log of applied transactions (see DeltaLog).
+	DeltaLog bool `json:"delta_log"`
+	// Provenance is set if the store can record provenance of quads (see ProvenanceStore).
+	Provenance bool `json:"provenance"`
+	// Metadata is set if the store can persist metadata records (see MetadataStore).
+	Metadata bool `json:"metadata"`
+	// TextSearch is set if the store maintains a full-text index (see TextSearcher).
+	TextSearch bool `json:"text_search"`
+	// GeoSearch is set if the store maintains a geospatial index (see GeoSearcher).
+	GeoSearch bool `json:"geo_search"`
+	// VectorSearch is set if the store maintains a nearest neighbours index (see VectorSearcher).
+	VectorSearch bool `json:"vector_search"`
+}
+
+// CapabilityReporter is an optional interface for QuadStores that report their capabilities.
+//
+// It should be implemented by stores that support features not visible from the list of implemented
+// interfaces, or stores that implement an interface but may not support it depending on the configuration.
+type CapabilityReporter interface {
+	// Capabilities returns features supported by the store.
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf returns features supported by the QuadStore. If the store does not implement
+// CapabilityReporter, capabilities are derived from the optional interfaces it implements.
+// Features added by Wrappers are included as well.
+func CapabilitiesOf(qs QuadStore) Capabilities {
+	c := capabilitiesOf(Unwrap(qs))
+	if !c.TextSearch {
+		c.TextSearch = asTextSearcher(qs) != nil
+	}
+	if !c.GeoSearch {
+		c.GeoSearch = asGeoSearcher(qs) != nil
+	}
+	if !c.VectorSearch {
+		c.VectorSearch = asVectorSearcher(qs) != nil
+	}
+	return c
+}
+
+func capabilitiesOf(qs QuadStore) Capabilities {
+	if r, ok := qs.(CapabilityReporter); ok {
+		return r.Capabilities()
+	}
+	var c Capabilities
+	_, c.ChangeFeed = qs.(Subscriber)
+	_, c.HorizonReads = qs.(HorizonReader)
+	_, c.ConditionalWrites = qs.(HorizonStore)
+	_, c.DeltaLog = qs.(DeltaLog)
+	_, c.Provenance = qs.(ProvenanceStore)
+	_, c.Metadata = qs.(MetadataStore)
+	return c
+}
@@ -0,0 +1,671 @@
+// Package cassandra implements a QuadStore backed by Apache Cassandra or ScyllaDB.
+//
+// Quads are stored three times, in tables ordered as SPO, POS and OSP. Each table is partitioned by
+// the hash of its first direction and a bucket number, which is derived from the second direction,
+// so quads of hot nodes (for example, a common predicate) can be spread across several partitions.
+// Node values are stored in a separate table, while reference counts of nodes and sizes of the graph
+// are kept in counter tables.
+//
+// Writes are sent as batches of prepared statements. Batches are not atomic across tables with
+// counters, thus the store does not support transactions.
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/internal/lru"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+const Type = "cassandra"
+
+const (
+	DefaultKeyspace    = "cayley"
+	DefaultBatchSize   = 100  // quads per batch
+	DefaultPageSize    = 1000 // rows per page of iterators
+	DefaultConcurrency = 16   // concurrent reads for existence checks
+
+	metaBuckets = "buckets"
+	statQuads   = "quads"
+	statNodes   = "nodes"
+)
+
+func init() {
+	graph.RegisterQuadStore(Type, graph.QuadStoreRegistration{
+		NewFunc:      New,
+		InitFunc:     Init,
+		IsPersistent: true,
+	})
+}
+
+// table is one of the quad index tables. Quads are partitioned by the first direction and a bucket,
+// and the rest of directions are clustering columns.
+type table struct {
+	name string
+	dirs [4]quad.Direction
+}
+
+var tables = []table{
+	{name: "quads_spo", dirs: [4]quad.Direction{quad.Subject, quad.Predicate, quad.Object, quad.Label}},
+	{name: "quads_pos", dirs: [4]quad.Direction{quad.Predicate, quad.Object, quad.Subject, quad.Label}},
+	{name: "quads_osp", dirs: [4]quad.Direction{quad.Object, quad.Subject, quad.Predicate, quad.Label}},
+}
+
+// tableFor returns an index table partitioned by a given direction.
+func tableFor(d quad.Direction) (table, bool) {
+	for _, t := range tables {
+		if t.dirs[0] == d {
+			return t, true
+		}
+	}
+	return table{}, false
+}
+
+var consistencies = map[string]gocql.Consistency{
+	"any":          gocql.Any,
+	"one":          gocql.One,
+	"two":          gocql.Two,
+	"three":        gocql.Three,
+	"quorum":       gocql.Quorum,
+	"all":          gocql.All,
+	"local_quorum": gocql.LocalQuorum,
+	"each_quorum":  gocql.EachQuorum,
+	"local_one":    gocql.LocalOne,
+}
+
+// newCluster creates a cluster config from a comma-separated list of hosts.
+func newCluster(addr string, opts graph.Options) (*gocql.ClusterConfig, error) {
+	var hosts []string
+	for _, h := range strings.Split(addr, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = []string{"127.0.0.1"}
+	}
+	c := gocql.NewCluster(hosts...)
+	cons, err := opts.StringKey("consistency", "quorum")
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	c.Consistency, ok = consistencies[strings.ToLower(cons)]
+	if !ok {
+		return nil, fmt.Errorf("cassandra: unknown consistency level: %q", cons)
+	}
+	user, err := opts.StringKey("username", "")
+	if err != nil {
+		return nil, err
+	}
+	if user != "" {
+		pass, err := opts.StringKey("password", "")
+		if err != nil {
+			return nil, err
+		}
+		c.Authenticator = gocql.PasswordAuthenticator{Username: user, Password: pass}
+	}
+	timeout, err := opts.IntKey("timeout_ms", 0)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		c.Timeout = time.Duration(timeout) * time.Millisecond
+	}
+	return c, nil
+}
+
+func keyspaceFromOpts(opts graph.Options) (string, error) {
+	ks, err := opts.StringKey("keyspace", DefaultKeyspace)
+	if err != nil {
+		return "", err
+	}
+	// keyspace is not a bind parameter, thus it must be checked
+	for _, r := range ks {
+		if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return "", fmt.Errorf("cassandra: invalid keyspace name: %q", ks)
+		}
+	}
+	return ks, nil
+}
+
+func schema() []string {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS meta (key text PRIMARY KEY, value text);`,
+		`CREATE TABLE IF NOT EXISTS stats (name text PRIMARY KEY, value counter);`,
+		`CREATE TABLE IF NOT EXISTS nodes (hash blob PRIMARY KEY, value blob);`,
+		`CREATE TABLE IF NOT EXISTS node_refs (hash blob PRIMARY KEY, refs counter);`,
+	}
+	for _, t := range tables {
+		stmts = append(stmts, fmt.Sprintf(
+			`CREATE TABLE IF NOT EXISTS %s (subject blob, predicate blob, object blob, label blob, bucket int, PRIMARY KEY ((%s, bucket), %s, %s, %s));`,
+			t.name, t.dirs[0], t.dirs[1], t.dirs[2], t.dirs[3],
+		))
+	}
+	return stmts
+}
+
+// Init creates a keyspace and tables for the graph. The number of buckets cannot be changed later.
+func Init(addr string, opts graph.Options) error {
+	ks, err := keyspaceFromOpts(opts)
+	if err != nil {
+		return err
+	}
+	rf, err := opts.IntKey("replication_factor", 1)
+	if err != nil {
+		return err
+	}
+	buckets, err := opts.IntKey("buckets", 1)
+	if err != nil {
+		return err
+	} else if buckets < 1 {
+		return fmt.Errorf("cassandra: invalid number of buckets: %d", buckets)
+	}
+	c, err := newCluster(addr, opts)
+	if err != nil {
+		return err
+	}
+	sess, err := c.CreateSession()
+	if err != nil {
+		return err
+	}
+	err = sess.Query(fmt.Sprintf(
+		`CREATE KEYSPACE IF NOT EXISTS %s WITH replication = {'class': 'SimpleStrategy', 'replication_factor': %d};`,
+		ks, rf,
+	)).Exec()
+	sess.Close()
+	if err != nil {
+		return err
+	}
+	c.Keyspace = ks
+	if sess, err = c.CreateSession(); err != nil {
+		return err
+	}
+	defer sess.Close()
+	for _, stmt := range schema() {
+		if err = sess.Query(stmt).Exec(); err != nil {
+			return err
+		}
+	}
+	var cur string
+	err = sess.Query(`SELECT value FROM meta WHERE key = ?;`, metaBuckets).Scan(&cur)
+	if err == nil {
+		return graph.ErrDatabaseExists
+	} else if err != gocql.ErrNotFound {
+		return err
+	}
+	return sess.Query(`INSERT INTO meta (key, value) VALUES (?, ?);`, metaBuckets, strconv.Itoa(buckets)).Exec()
+}
+
+// New opens an existing graph.
+func New(addr string, opts graph.Options) (graph.QuadStore, error) {
+	ks, err := keyspaceFromOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	qs := &QuadStore{ids: lru.New(1024)}
+	if qs.batchSize, err = opts.IntKey("batch_size", DefaultBatchSize); err != nil {
+		return nil, err
+	}
+	if qs.pageSize, err = opts.IntKey("page_size", DefaultPageSize); err != nil {
+		return nil, err
+	}
+	if qs.concurrency, err = opts.IntKey("concurrency", DefaultConcurrency); err != nil {
+		return nil, err
+	}
+	if qs.batchSize < 1 || qs.pageSize < 1 || qs.concurrency < 1 {
+		return nil, fmt.Errorf("cassandra: batch_size, page_size and concurrency must be positive")
+	}
+	c, err := newCluster(addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.Keyspace = ks
+	if qs.sess, err = c.CreateSession(); err != nil {
+		return nil, err
+	}
+	var buckets string
+	err = qs.sess.Query(`SELECT value FROM meta WHERE key = ?;`, metaBuckets).Scan(&buckets)
+	if err == gocql.ErrNotFound {
+		err = graph.ErrNotInitialized
+	}
+	if err == nil {
+		qs.buckets, err = strconv.Atoi(buckets)
+	}
+	if err != nil {
+		qs.sess.Close()
+		return nil, err
+	}
+	return qs, nil
+}
+
+type QuadStore struct {
+	sess        *gocql.Session
+	buckets     int
+	batchSize   int
+	pageSize    int
+	concurrency int
+
+	ids *lru.Cache // decoded node values
+}
+
+// bucket returns a partition bucket of a quad in a given table.
+func (qs *QuadStore) bucket(t table, q graph.QuadHash) int {
+	if qs.buckets <= 1 {
+		return 0
+	}
+	return int(q.Get(t.dirs[1])[0]) % qs.buckets
+}
+
+// hashBytes converts a node hash to a column value. Missing labels are stored as empty blobs,
+// since clustering columns cannot be null.
+func hashBytes(h graph.ValueHash) []byte {
+	if !h.Valid() {
+		return []byte{}
+	}
+	return h[:]
+}
+
+func quadArgs(q graph.QuadHash) []interface{} {
+	return []interface{}{
+		hashBytes(q.Subject), hashBytes(q.Predicate),
+		hashBytes(q.Object), hashBytes(q.Label),
+	}
+}
+
+func (qs *QuadStore) quadExists(ctx context.Context, q graph.QuadHash) (bool, error) {
+	t := tables[0]
+	args := append(quadArgs(q), qs.bucket(t, q))
+	var s []byte
+	err := qs.sess.Query(
+		`SELECT subject FROM `+t.name+` WHERE subject = ? AND predicate = ? AND object = ? AND label = ? AND bucket = ?;`,
+		args...,
+	).WithContext(ctx).Scan(&s)
+	if err == gocql.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// parallel runs f for each index in [0, n) with a limited concurrency, and returns the first error.
+func (qs *QuadStore) parallel(n int, f func(i int) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		last error
+	)
+	jobs := make(chan int)
+	for w := 0; w < qs.concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := f(i); err != nil {
+					mu.Lock()
+					if last == nil {
+						last = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return last
+}
+
+// nodeRefs returns current reference counters for a set of nodes.
+func (qs *QuadStore) nodeRefs(ctx context.Context, hashes []graph.ValueHash) (map[graph.ValueHash]int64, error) {
+	const chunk = 100
+	refs := make(map[graph.ValueHash]int64, len(hashes))
+	for len(hashes) > 0 {
+		n := chunk
+		if n > len(hashes) {
+			n = len(hashes)
+		}
+		keys := make([][]byte, 0, n)
+		for i := range hashes[:n] {
+			keys = append(keys, hashes[i][:])
+		}
+		hashes = hashes[n:]
+		iter := qs.sess.Query(`SELECT hash, refs FROM node_refs WHERE hash IN ?;`, keys).WithContext(ctx).Iter()
+		var (
+			key []byte
+			cnt int64
+		)
+		for iter.Scan(&key, &cnt) {
+			var h graph.ValueHash
+			copy(h[:], key)
+			refs[h] = cnt
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return refs, nil
+}
+
+type nodeDelta struct {
+	val quad.Value
+	inc int64
+}
+
+// ApplyDeltas implements graph.QuadStore. Existence of quads is checked before the write,
+// thus concurrent writers of the same quads may leave reference counters inconsistent.
+func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error {
+	ctx := context.TODO()
+	in, err := graph.ResolveDeltas(ctx, qs, in)
+	if err != nil {
+		return err
+	}
+	hashes := make([]graph.QuadHash, len(in))
+	for i, d := range in {
+		if d.Action != graph.Add && d.Action != graph.Delete {
+			return &graph.DeltaError{Delta: d, Err: graph.ErrInvalidAction}
+		}
+		for _, dir := range quad.Directions {
+			if v := d.Quad.Get(dir); v != nil {
+				hashes[i].Set(dir, graph.HashOf(v))
+			}
+		}
+	}
+	exists := make([]bool, len(in))
+	err = qs.parallel(len(in), func(i int) error {
+		ok, err := qs.quadExists(ctx, hashes[i])
+		exists[i] = ok
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// validate deltas in order, since the same quad may be added and deleted in a single call,
+	// and only write the final state of each quad: statements in a batch share the same timestamp
+	type change struct {
+		q          quad.Quad
+		was, final bool
+	}
+	var (
+		changes = make(map[graph.QuadHash]*change, len(in))
+		order   []graph.QuadHash
+	)
+	for i, d := range in {
+		h := hashes[i]
+		c := changes[h]
+		if c == nil {
+			c = &change{q: d.Quad, was: exists[i], final: exists[i]}
+			changes[h] = c
+			order = append(order, h)
+		}
+		switch d.Action {
+		case graph.Add:
+			if c.final {
+				if !opts.IgnoreDup {
+					return &graph.DeltaError{Delta: d, Err: graph.ErrQuadExists}
+				}
+				continue
+			}
+		case graph.Delete:
+			if !c.final {
+				if !opts.IgnoreMissing {
+					return &graph.DeltaError{Delta: d, Err: graph.ErrQuadNotExist}
+				}
+				continue
+			}
+		}
+		c.final = d.Action == graph.Add
+	}
+	var (
+		nodes  = make(map[graph.ValueHash]*nodeDelta)
+		dquads int64
+	)
+	b := &batcher{qs: qs, ctx: ctx, typ: gocql.LoggedBatch}
+	for _, h := range order {
+		c := changes[h]
+		if c.was == c.final {
+			continue
+		}
+		dn := int64(1)
+		if !c.final {
+			dn = -1
+		}
+		dquads += dn
+		for _, dir := range quad.Directions {
+			v := c.q.Get(dir)
+			if v == nil {
+				continue
+			}
+			vh := h.Get(dir)
+			n := nodes[vh]
+			if n == nil {
+				n = &nodeDelta{val: v}
+				nodes[vh] = n
+			}
+			n.inc += dn
+		}
+		for _, t := range tables {
+			args := append(quadArgs(h), qs.bucket(t, h))
+			if c.final {
+				err = b.add(`INSERT INTO `+t.name+` (subject, predicate, object, label, bucket) VALUES (?, ?, ?, ?, ?);`, args...)
+			} else {
+				err = b.add(`DELETE FROM `+t.name+` WHERE subject = ? AND predicate = ? AND object = ? AND label = ? AND bucket = ?;`, args...)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if dquads == 0 && len(nodes) == 0 {
+		return nil
+	}
+	nhashes := make([]graph.ValueHash, 0, len(nodes))
+	for h, n := range nodes {
+		if n.inc != 0 {
+			nhashes = append(nhashes, h)
+		}
+	}
+	refs, err := qs.nodeRefs(ctx, nhashes)
+	if err != nil {
+		return err
+	}
+	var dnodes int64
+	for _, h := range nhashes {
+		n := nodes[h]
+		before := refs[h]
+		after := before + n.inc
+		if before <= 0 && after > 0 {
+			data, err := pquads.MarshalValue(n.val)
+			if err != nil {
+				return err
+			}
+			if err = b.add(`INSERT INTO nodes (hash, value) VALUES (?, ?);`, h[:], data); err != nil {
+				return err
+			}
+			dnodes++
+		} else if before > 0 && after <= 0 {
+			// counters cannot be removed and added again, thus unused nodes keep zero counters
+			if err = b.add(`DELETE FROM nodes WHERE hash = ?;`, h[:]); err != nil {
+				return err
+			}
+			qs.ids.Del(string(h[:]))
+			dnodes--
+		}
+	}
+	if err = b.flush(); err != nil {
+		return err
+	}
+
+	b = &batcher{qs: qs, ctx: ctx, typ: gocql.CounterBatch}
+	for _, h := range nhashes {
+		if err = b.add(`UPDATE node_refs SET refs = refs + ? WHERE hash = ?;`, nodes[h].inc, h[:]); err != nil {
+			return err
+		}
+	}
+	if dquads != 0 {
+		if err = b.add(`UPDATE stats SET value = value + ? WHERE name = ?;`, dquads, statQuads); err != nil {
+			return err
+		}
+	}
+	if dnodes != 0 {
+		if err = b.add(`UPDATE stats SET value = value + ? WHERE name = ?;`, dnodes, statNodes); err != nil {
+			return err
+		}
+	}
+	return b.flush()
+}
+
+// batcher groups statements into batches of a limited size.
+type batcher struct {
+	qs    *QuadStore
+	ctx   context.Context
+	typ   gocql.BatchType
+	batch *gocql.Batch
+}
+
+func (b *batcher) add(stmt string, args ...interface{}) error {
+	if b.batch == nil {
+		b.batch = b.qs.sess.NewBatch(b.typ).WithContext(b.ctx)
+	}
+	b.batch.Query(stmt, args...)
+	// each quad is written to all index tables
+	if b.batch.Size() >= b.qs.batchSize*len(tables) {
+		return b.flush()
+	}
+	return nil
+}
+
+func (b *batcher) flush() error {
+	if b.batch == nil || b.batch.Size() == 0 {
+		return nil
+	}
+	err := b.qs.sess.ExecuteBatch(b.batch)
+	b.batch = nil
+	return err
+}
+
+func (qs *QuadStore) Quad(val graph.Value) quad.Quad {
+	h, ok := val.(graph.QuadHash)
+	if !ok {
+		return quad.Quad{}
+	}
+	return quad.Quad{
+		Subject:   qs.NameOf(h.Subject),
+		Predicate: qs.NameOf(h.Predicate),
+		Object:    qs.NameOf(h.Object),
+		Label:     qs.NameOf(h.Label),
+	}
+}
+
+func (qs *QuadStore) QuadIterator(d quad.Direction, val graph.Value) graph.Iterator {
+	h, ok := val.(graph.ValueHash)
+	if !ok || !h.Valid() {
+		return iterator.NewNull()
+	}
+	return qs.newIterator(d, h)
+}
+
+func (qs *QuadStore) NodesAllIterator() graph.Iterator {
+	return qs.newAllIterator(true)
+}
+
+func (qs *QuadStore) QuadsAllIterator() graph.Iterator {
+	return qs.newAllIterator(false)
+}
+
+func (qs *QuadStore) ValueOf(s quad.Value) graph.Value {
+	if s == nil {
+		return nil
+	}
+	return graph.HashOf(s)
+}
+
+func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
+	if v == nil {
+		return nil
+	} else if pv, ok := v.(graph.PreFetchedValue); ok {
+		return pv.NameOf()
+	}
+	h, ok := v.(graph.ValueHash)
+	if !ok || !h.Valid() {
+		return nil
+	}
+	key := string(h[:])
+	if qv, ok := qs.ids.Get(key); ok {
+		return qv.(quad.Value)
+	}
+	var data []byte
+	err := qs.sess.Query(`SELECT value FROM nodes WHERE hash = ?;`, h[:]).Scan(&data)
+	if err == gocql.ErrNotFound {
+		return nil
+	} else if err != nil {
+		clog.Errorf("couldn't get node value: %v", err)
+		return nil
+	}
+	qv, err := pquads.UnmarshalValue(data)
+	if err != nil {
+		clog.Errorf("couldn't decode node value: %v", err)
+		return nil
+	}
+	qs.ids.Put(key, qv)
+	return qv
+}
+
+func (qs *QuadStore) stat(name string) int64 {
+	var n int64
+	err := qs.sess.Query(`SELECT value FROM stats WHERE name = ?;`, name).Scan(&n)
+	if err != nil && err != gocql.ErrNotFound {
+		clog.Errorf("couldn't get %s count: %v", name, err)
+	}
+	return n
+}
+
+func (qs *QuadStore) Size() int64 {
+	return qs.stat(statQuads)
+}
+
+var _ graph.StatsCollector = (*QuadStore)(nil)
+
+// Stats implements graph.StatsCollector. Exact statistics are calculated by scanning all quads.
+func (qs *QuadStore) Stats(ctx context.Context, exact bool) (graph.Stats, error) {
+	if exact {
+		return graph.IterateStats(ctx, qs)
+	}
+	return graph.Stats{
+		Quads: qs.stat(statQuads),
+		Nodes: qs.stat(statNodes),
+	}, nil
+}
+
+func (qs *QuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool) {
+	return it, false
+}
+
+func (qs *QuadStore) Close() error {
+	qs.sess.Close()
+	return nil
+}
+
+func (qs *QuadStore) QuadDirection(val graph.Value, d quad.Direction) graph.Value {
+	h, ok := val.(graph.QuadHash)
+	if !ok {
+		return nil
+	}
+	if vh := h.Get(d); vh.Valid() {
+		return vh
+	}
+	return nil
+}
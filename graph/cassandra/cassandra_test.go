@@ -0,0 +1,57 @@
+//go:build docker
+// +build docker
+
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/gocql/gocql"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/internal/dock"
+)
+
+func makeCassandra(t testing.TB) (graph.QuadStore, graph.Options, func()) {
+	var conf dock.Config
+
+	conf.Image = "scylladb/scylla:3.0.0"
+	conf.Cmd = []string{"--smp", "1", "--memory", "512M"}
+
+	addr, closer := dock.RunAndWait(t, conf, "9042", func(addr string) bool {
+		sess, err := gocql.NewCluster(addr).CreateSession()
+		if err != nil {
+			return false
+		}
+		sess.Close()
+		return true
+	})
+	opts := graph.Options{"buckets": 4, "consistency": "one"}
+	if err := Init(addr, opts); err != nil {
+		closer()
+		t.Fatal(err)
+	}
+	qs, err := New(addr, opts)
+	if err != nil {
+		closer()
+		t.Fatal(err)
+	}
+	return qs, opts, func() {
+		qs.Close()
+		closer()
+	}
+}
+
+var conf = &graphtest.Config{
+	NoPrimitives:  true,
+	SkipSubscribe: true,
+}
+
+func TestCassandra(t *testing.T) {
+	graphtest.TestAll(t, makeCassandra, conf)
+}
+
+func BenchmarkCassandra(t *testing.B) {
+	graphtest.BenchmarkAll(t, makeCassandra, conf)
+}
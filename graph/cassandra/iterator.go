@@ -0,0 +1,260 @@
+package cassandra
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Iterator = (*Iterator)(nil)
+
+// Iterator reads quads or nodes page by page, using paging state returned by the database.
+// Iterators over nodes and quads linked to them scan all buckets of the partition in order.
+type Iterator struct {
+	qs    *QuadStore
+	uid   uint64
+	tags  graph.Tagger
+	nodes bool            // iterate over all nodes
+	dir   quad.Direction  // direction of quads; quad.Any for all quads
+	hash  graph.ValueHash // node linked to quads in dir
+	size  int64           // cached size; negative if not yet known
+
+	bucket int
+	state  []byte // paging state of the current bucket
+	buf    []graph.Value
+	off    int
+	done   bool
+	res    graph.Value
+	err    error
+}
+
+func (qs *QuadStore) newAllIterator(nodes bool) *Iterator {
+	return &Iterator{
+		qs: qs, uid: iterator.NextUID(),
+		nodes: nodes, dir: quad.Any, size: -1,
+	}
+}
+
+func (qs *QuadStore) newIterator(d quad.Direction, h graph.ValueHash) *Iterator {
+	return &Iterator{
+		qs: qs, uid: iterator.NextUID(),
+		dir: d, hash: h, size: -1,
+	}
+}
+
+func (it *Iterator) isAll() bool {
+	return it.nodes || it.dir == quad.Any
+}
+
+func (it *Iterator) UID() uint64 {
+	return it.uid
+}
+
+func (it *Iterator) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Iterator) TagResults(dst map[string]graph.Value) {
+	it.tags.TagResult(dst, it.Result())
+}
+
+func (it *Iterator) Result() graph.Value {
+	return it.res
+}
+
+// query returns a statement and arguments for the current bucket. The statement selects hashes
+// of all directions, or a hash of the node for node iterators.
+func (it *Iterator) query() (string, []interface{}) {
+	const cols = `subject, predicate, object, label`
+	switch {
+	case it.nodes:
+		return `SELECT hash FROM nodes;`, nil
+	case it.dir == quad.Any:
+		return `SELECT ` + cols + ` FROM ` + tables[0].name + `;`, nil
+	}
+	t, ok := tableFor(it.dir)
+	if !ok {
+		// there is no table partitioned by label, thus the filter is checked by the database
+		return `SELECT ` + cols + ` FROM ` + tables[0].name + ` WHERE label = ? ALLOW FILTERING;`,
+			[]interface{}{it.hash[:]}
+	}
+	return `SELECT ` + cols + ` FROM ` + t.name + ` WHERE ` + it.dir.String() + ` = ? AND bucket = ?;`,
+		[]interface{}{it.hash[:], it.bucket}
+}
+
+// buckets returns the number of buckets the iterator scans.
+func (it *Iterator) buckets() int {
+	if _, ok := tableFor(it.dir); ok && !it.nodes {
+		return it.qs.buckets
+	}
+	return 1
+}
+
+// fetch loads the next page of results.
+func (it *Iterator) fetch(ctx context.Context) {
+	stmt, args := it.query()
+	iter := it.qs.sess.Query(stmt, args...).WithContext(ctx).
+		PageSize(it.qs.pageSize).PageState(it.state).Iter()
+	it.buf, it.off = it.buf[:0], 0
+	if it.nodes {
+		var key []byte
+		for iter.Scan(&key) {
+			var h graph.ValueHash
+			copy(h[:], key)
+			it.buf = append(it.buf, h)
+		}
+	} else {
+		var s, p, o, l []byte
+		for iter.Scan(&s, &p, &o, &l) {
+			var q graph.QuadHash
+			copy(q.Subject[:], s)
+			copy(q.Predicate[:], p)
+			copy(q.Object[:], o)
+			copy(q.Label[:], l)
+			it.buf = append(it.buf, q)
+		}
+	}
+	it.state = iter.PageState()
+	if err := iter.Close(); err != nil {
+		it.err = err
+		it.done = true
+		return
+	}
+	if len(it.state) == 0 {
+		// move to the next bucket
+		it.state = nil
+		if it.bucket++; it.bucket >= it.buckets() {
+			it.done = true
+		}
+	}
+}
+
+func (it *Iterator) Next(ctx context.Context) bool {
+	for it.off >= len(it.buf) {
+		if it.done || it.err != nil {
+			return false
+		}
+		if err := ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		it.fetch(ctx)
+	}
+	it.res = it.buf[it.off]
+	it.off++
+	return true
+}
+
+func (it *Iterator) NextPath(ctx context.Context) bool {
+	return false
+}
+
+func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
+	graph.ContainsLogIn(it, v)
+	if it.nodes {
+		_, ok := v.(graph.ValueHash)
+		if ok {
+			it.res = v
+		}
+		return graph.ContainsLogOut(it, v, ok)
+	}
+	q, ok := v.(graph.QuadHash)
+	if !ok {
+		return graph.ContainsLogOut(it, v, false)
+	}
+	if it.dir != quad.Any && q.Get(it.dir) != it.hash {
+		return graph.ContainsLogOut(it, v, false)
+	}
+	it.res = v
+	return graph.ContainsLogOut(it, v, true)
+}
+
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+func (it *Iterator) Reset() {
+	it.bucket = 0
+	it.state = nil
+	it.buf, it.off = it.buf[:0], 0
+	it.done = false
+	it.res = nil
+	it.err = nil
+}
+
+func (it *Iterator) Clone() graph.Iterator {
+	out := &Iterator{
+		qs: it.qs, uid: iterator.NextUID(),
+		nodes: it.nodes, dir: it.dir, hash: it.hash, size: it.size,
+	}
+	out.tags.CopyFrom(it)
+	return out
+}
+
+// Size returns the number of nodes or quads in the graph for iterators over all values.
+// For quads linked to a node, the number of references to the node is returned as an estimate.
+func (it *Iterator) Size() (int64, bool) {
+	if it.size >= 0 {
+		return it.size, it.isAll()
+	}
+	switch {
+	case it.nodes:
+		it.size = it.qs.stat(statNodes)
+	case it.dir == quad.Any:
+		it.size = it.qs.stat(statQuads)
+	default:
+		refs, err := it.qs.nodeRefs(context.TODO(), []graph.ValueHash{it.hash})
+		if err != nil {
+			return 0, false
+		}
+		it.size = refs[it.hash]
+		if it.size < 0 {
+			it.size = 0
+		}
+	}
+	return it.size, it.isAll()
+}
+
+func (it *Iterator) Stats() graph.IteratorStats {
+	size, exact := it.Size()
+	return graph.IteratorStats{
+		ContainsCost: 1,
+		NextCost:     5,
+		Size:         size,
+		ExactSize:    exact,
+	}
+}
+
+func (it *Iterator) Type() graph.Type {
+	if it.isAll() {
+		return graph.All
+	}
+	return Type
+}
+
+func (it *Iterator) Optimize() (graph.Iterator, bool) {
+	return it, false
+}
+
+func (it *Iterator) SubIterators() []graph.Iterator {
+	return nil
+}
+
+func (it *Iterator) Close() error {
+	it.buf = nil
+	it.done = true
+	return nil
+}
+
+func (it *Iterator) String() string {
+	switch {
+	case it.nodes:
+		return "CassandraNodes"
+	case it.dir == quad.Any:
+		return "CassandraQuads"
+	}
+	return fmt.Sprintf("Cassandra(%v=%v)", it.dir, it.hash)
+}
@@ -0,0 +1,306 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrHorizonExpired is returned when changes requested from a ChangeFeed are no longer retained.
+var ErrHorizonExpired = errors.New("changes for requested horizon are no longer available")
+
+// Change is a single delta applied to the database.
+type Change struct {
+	Delta
+	// Horizon of the store after the change was applied. Changes applied in the same transaction have the same horizon.
+	Horizon   int64
+	Timestamp time.Time
+}
+
+// ChangeFeed keeps a bounded in-memory log of deltas applied to the QuadStore and notifies subscribers about new changes.
+//
+// Changes are received from the store (see Subscriber), thus only deltas that were actually applied are published,
+// regardless of the writer that applied them. Subscribers may resume from a given horizon of the store as long as
+// changes after it are retained in memory or in the delta log of the store (see DeltaLog).
+type ChangeFeed struct {
+	qs     QuadStore
+	hs     bool // store reports its horizon
+	log    bool // store keeps a delta log
+	cancel func()
+
+	mu      sync.Mutex
+	horizon int64    // horizon of the last received change
+	expired int64    // changes up to this horizon are not retained in memory
+	buf     []Change // ring buffer
+	start   int      // index of the oldest change in the buffer
+	n       int      // number of changes in the buffer
+	notify  chan struct{}
+	err     error
+}
+
+// NewChangeFeed starts receiving changes applied to the QuadStore and retains up to size last changes in memory.
+// It returns ErrNotSupported if QuadStore does not implement Subscriber.
+//
+// The feed stops when the store is closed or when Close is called.
+func NewChangeFeed(qs QuadStore, size int) (*ChangeFeed, error) {
+	if size <= 0 {
+		size = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := Subscribe(ctx, qs)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	f := &ChangeFeed{
+		qs: qs, cancel: cancel,
+		buf:    make([]Change, size),
+		notify: make(chan struct{}),
+	}
+	// changes applied before the subscription are only available from the delta log
+	f.horizon, err = Horizon(ctx, qs)
+	if err != nil && err != ErrNotSupported {
+		cancel()
+		return nil, err
+	}
+	f.hs = err == nil
+	f.expired = f.horizon
+	if _, err = LogEntries(ctx, qs, f.horizon, f.horizon); err == nil {
+		f.log = true
+	}
+	go f.run(ctx, sub)
+	return f, nil
+}
+
+// run receives changes from the store until the subscription ends.
+// If the feed did not keep up with the store, it subscribes again and missed changes are marked as expired.
+func (f *ChangeFeed) run(ctx context.Context, sub *Subscription) {
+	for {
+		for c := range sub.C {
+			batch := []Change{c}
+		drain:
+			for len(batch) < len(f.buf) {
+				select {
+				case c, ok := <-sub.C:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, c)
+				default:
+					break drain
+				}
+			}
+			f.publish(batch)
+		}
+		err := sub.Err()
+		if err == ErrSlowSubscriber {
+			if sub, err = Subscribe(ctx, f.qs); err == nil {
+				var h int64
+				if h, err = Horizon(ctx, f.qs); err == nil || err == ErrNotSupported {
+					f.skip(h)
+					continue
+				}
+			}
+		}
+		f.mu.Lock()
+		f.err = err
+		close(f.notify)
+		f.mu.Unlock()
+		return
+	}
+}
+
+// publish appends changes to the buffer and wakes up all subscribers.
+func (f *ChangeFeed) publish(changes []Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range changes {
+		if c.Horizon > f.horizon {
+			f.horizon = c.Horizon
+		}
+		if f.n < len(f.buf) {
+			f.buf[(f.start+f.n)%len(f.buf)] = c
+			f.n++
+			continue
+		}
+		// the dropped change may share the horizon with retained ones, thus the whole horizon expires
+		if h := f.buf[f.start].Horizon; h > f.expired {
+			f.expired = h
+		}
+		f.buf[f.start] = c
+		f.start = (f.start + 1) % len(f.buf)
+	}
+	close(f.notify)
+	f.notify = make(chan struct{})
+}
+
+// skip drops all retained changes, because changes up to horizon h were missed.
+func (f *ChangeFeed) skip(h int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if h < f.horizon {
+		h = f.horizon
+	}
+	f.horizon, f.expired = h, h
+	f.start, f.n = 0, 0
+	close(f.notify)
+	f.notify = make(chan struct{})
+}
+
+// Close stops receiving changes. Active streams end with context.Canceled.
+func (f *ChangeFeed) Close() error {
+	f.cancel()
+	return nil
+}
+
+// Horizon returns the current horizon of the store. If the store does not report it,
+// a horizon of the last received change is returned instead.
+func (f *ChangeFeed) Horizon() int64 {
+	if f.hs {
+		if h, err := Horizon(context.TODO(), f.qs); err == nil {
+			return h
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.horizon
+}
+
+// Available checks if all changes after a given horizon are still retained by the feed or by the delta log.
+func (f *ChangeFeed) Available(h int64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.log || h >= f.expired
+}
+
+// since returns changes after a given horizon and a channel that will be closed on the next change.
+// Changes of a single transaction are never split between calls.
+func (f *ChangeFeed) since(ctx context.Context, h int64, max int) ([]Change, chan struct{}, error) {
+	f.mu.Lock()
+	if f.err != nil {
+		defer f.mu.Unlock()
+		return nil, nil, f.err
+	} else if h >= f.horizon {
+		defer f.mu.Unlock()
+		return nil, f.notify, nil
+	} else if h < f.expired {
+		expired, notify := f.expired, f.notify
+		f.mu.Unlock()
+		if !f.log {
+			return nil, nil, ErrHorizonExpired
+		}
+		return f.fromLog(ctx, h, expired, max, notify)
+	}
+	defer f.mu.Unlock()
+	i := 0
+	for ; i < f.n && f.buf[(f.start+i)%len(f.buf)].Horizon <= h; i++ {
+	}
+	var out []Change
+	for ; i < f.n; i++ {
+		c := f.buf[(f.start+i)%len(f.buf)]
+		if max > 0 && len(out) >= max && c.Horizon != out[len(out)-1].Horizon {
+			break
+		}
+		out = append(out, c)
+	}
+	return out, f.notify, nil
+}
+
+// fromLog reads changes with horizons in range (h, to] from the delta log.
+func (f *ChangeFeed) fromLog(ctx context.Context, h, to int64, max int, notify chan struct{}) ([]Change, chan struct{}, error) {
+	if max > 0 && to > h+int64(max) {
+		to = h + int64(max)
+	}
+	entries, err := LogEntries(ctx, f.qs, h, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	var out []Change
+	for _, e := range entries {
+		for _, d := range e.Deltas {
+			out = append(out, Change{Delta: d, Horizon: e.Horizon, Timestamp: e.Timestamp})
+		}
+	}
+	if len(out) == 0 {
+		// transactions in the range did not change anything; continue from the end of the range
+		out = append(out, Change{Horizon: to})
+	}
+	return out, notify, nil
+}
+
+// Changes returns a stream of changes applied after a given horizon.
+// Stream will wait for new changes until the context is canceled.
+func (f *ChangeFeed) Changes(since int64) *ChangeStream {
+	return &ChangeStream{f: f, last: since}
+}
+
+// ChangeStream iterates over changes received by the feed.
+type ChangeStream struct {
+	f    *ChangeFeed
+	last int64
+	buf  []Change
+	cur  Change
+	err  error
+}
+
+const changeStreamBatch = 100
+
+// Next waits for the next change. It returns false if context was canceled, if the feed was stopped,
+// or if the subscriber was too slow and changes were dropped from the log.
+func (s *ChangeStream) Next(ctx context.Context) bool {
+	for s.err == nil {
+		for len(s.buf) != 0 {
+			s.cur, s.buf = s.buf[0], s.buf[1:]
+			s.last = s.cur.Horizon
+			if s.cur.Quad.IsValid() {
+				return true
+			}
+		}
+		buf, notify, err := s.f.since(ctx, s.last, changeStreamBatch)
+		if err != nil {
+			s.err = err
+			return false
+		} else if len(buf) != 0 {
+			s.buf = buf
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			s.err = ctx.Err()
+			return false
+		case <-notify:
+		}
+	}
+	return false
+}
+
+// Result returns the current change.
+func (s *ChangeStream) Result() Change {
+	return s.cur
+}
+
+// Last checks if the current change is the last one of its transaction that is known to the stream.
+// Resuming from the horizon of such change will not skip other changes of the transaction.
+func (s *ChangeStream) Last() bool {
+	return len(s.buf) == 0 || s.buf[0].Horizon != s.cur.Horizon
+}
+
+// Err returns an error that interrupted the stream.
+func (s *ChangeStream) Err() error {
+	return s.err
+}
@@ -0,0 +1,133 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// feedStore is a QuadStore that only delivers changes and keeps a delta log.
+type feedStore struct {
+	QuadStore
+	n       Notifier
+	horizon int64
+	log     []LogEntry // nil if the log is not supported
+}
+
+func (qs *feedStore) Subscribe(ctx context.Context) (*Subscription, error) {
+	return qs.n.Subscribe(ctx), nil
+}
+
+func (qs *feedStore) Horizon(ctx context.Context) (int64, error) {
+	return qs.horizon, nil
+}
+
+func (qs *feedStore) ApplyDeltasAt(in []Delta, opts IgnoreOpts, h int64) error {
+	return ErrNotSupported
+}
+
+func (qs *feedStore) LogEntries(ctx context.Context, from, to int64) ([]LogEntry, error) {
+	if qs.log == nil {
+		return nil, ErrNotSupported
+	}
+	var out []LogEntry
+	for _, e := range qs.log {
+		if e.Horizon > from && e.Horizon <= to {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func feedDelta(s string) Delta {
+	return Delta{Quad: quad.MakeIRI(s, "p", "o", ""), Action: Add}
+}
+
+func (qs *feedStore) apply(ds ...Delta) {
+	qs.horizon++
+	var changes []Change
+	for _, d := range ds {
+		changes = append(changes, Change{Delta: d, Horizon: qs.horizon})
+	}
+	if qs.log != nil {
+		qs.log = append(qs.log, LogEntry{Horizon: qs.horizon, Deltas: ds})
+	}
+	qs.n.Notify(changes)
+}
+
+func expectChanges(t *testing.T, s *ChangeStream, exp ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, e := range exp {
+		if !s.Next(ctx) {
+			t.Fatal(s.Err())
+		} else if sub := s.Result().Quad.Subject; sub != quad.IRI(e) {
+			t.Fatalf("expected change of %q, got %v", e, sub)
+		}
+	}
+}
+
+func TestChangeFeed(t *testing.T) {
+	for _, withLog := range []bool{false, true} {
+		qs := &feedStore{}
+		if withLog {
+			qs.log = []LogEntry{}
+		}
+		// applied before the feed was started
+		qs.apply(feedDelta("x"))
+
+		f, err := NewChangeFeed(qs, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qs.apply(feedDelta("a"), feedDelta("b"))
+		qs.apply(feedDelta("c"))
+
+		s := f.Changes(1)
+		expectChanges(t, s, "a")
+		if s.Last() {
+			t.Fatal("change is not the last one in the transaction")
+		}
+		expectChanges(t, s, "b")
+		if !s.Last() || s.Result().Horizon != 2 {
+			t.Fatalf("unexpected change: %v", s.Result())
+		}
+		expectChanges(t, s, "c")
+
+		// only 3 last changes are retained in memory
+		qs.apply(feedDelta("d"), feedDelta("e"))
+		expectChanges(t, s, "d", "e")
+		if h := f.Horizon(); h != 4 {
+			t.Fatalf("unexpected horizon: %d", h)
+		}
+		if f.Available(1) != withLog {
+			t.Fatal("expected horizon to expire without the delta log")
+		} else if !f.Available(2) {
+			t.Fatal("expected horizon to be available")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		s = f.Changes(0)
+		if !withLog {
+			if s.Next(ctx) || s.Err() != ErrHorizonExpired {
+				t.Fatalf("unexpected error: %v", s.Err())
+			}
+			cancel()
+			f.Close()
+			continue
+		}
+		// expired changes are read from the delta log
+		expectChanges(t, s, "x", "a", "b", "c", "d", "e")
+		cancel()
+
+		f.Close()
+		s = f.Changes(f.Horizon())
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		if s.Next(ctx) || s.Err() != context.Canceled {
+			t.Fatalf("unexpected error: %v", s.Err())
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,452 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// maxConditionalRetries is a number of attempts to apply conditional writes if the store was modified concurrently.
+const maxConditionalRetries = 10
+
+type condKind int
+
+const (
+	condAddUnlessExists = condKind(iota + 1)
+	condRemoveIfExists
+	condReplaceValues
+)
+
+// CondOp is a conditional write operation. Conditions are checked against the current state of the store
+// and against previous operations in the same batch.
+type CondOp struct {
+	kind condKind
+	quad quad.Quad
+	vals []quad.Value
+}
+
+// AddUnlessExists adds a quad if it does not exist.
+func AddUnlessExists(q quad.Quad) CondOp {
+	return CondOp{kind: condAddUnlessExists, quad: q}
+}
+
+// RemoveIfExists removes a quad if it exists.
+func RemoveIfExists(q quad.Quad) CondOp {
+	return CondOp{kind: condRemoveIfExists, quad: q}
+}
+
+// ReplaceValues replaces objects of all quads with a given subject, predicate and label with given values.
+// Quads with other objects are removed, and quads with new objects are added. If no values are given,
+// all quads with a given subject, predicate and label are removed.
+func ReplaceValues(s, p, label quad.Value, objects ...quad.Value) CondOp {
+	return CondOp{kind: condReplaceValues, quad: quad.Quad{Subject: s, Predicate: p, Label: label}, vals: objects}
+}
+
+func valueEqual(a, b quad.Value) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a == b || a.String() == b.String()
+}
+
+// quadsMatching returns all quads equal to q in all directions except ones listed in any.
+func quadsMatching(ctx context.Context, qs QuadStore, q quad.Quad, any ...quad.Direction) ([]quad.Quad, error) {
+	isAny := func(d quad.Direction) bool {
+		for _, a := range any {
+			if a == d {
+				return true
+			}
+		}
+		return false
+	}
+	var (
+		best Iterator
+		size int64
+	)
+	defer func() {
+		if best != nil {
+			best.Close()
+		}
+	}()
+	// pick the smallest index
+	for _, d := range quad.Directions {
+		v := q.Get(d)
+		if v == nil || isAny(d) {
+			continue
+		}
+		gv := qs.ValueOf(v)
+		if gv == nil {
+			return nil, nil
+		}
+		it := qs.QuadIterator(d, gv)
+		if sz, _ := it.Size(); best == nil || sz < size {
+			if best != nil {
+				best.Close()
+			}
+			best, size = it, sz
+		} else {
+			it.Close()
+		}
+	}
+	if best == nil {
+		best = qs.QuadsAllIterator()
+	}
+	var out []quad.Quad
+	for best.Next(ctx) {
+		cq := qs.Quad(best.Result())
+		ok := true
+		for _, d := range quad.Directions {
+			if !isAny(d) && !valueEqual(q.Get(d), cq.Get(d)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, cq)
+		}
+	}
+	return out, best.Err()
+}
+
+type condResolver struct {
+	ctx context.Context
+	qs  QuadStore
+	tx  *Transaction
+}
+
+// exists checks if the quad will exist after applying deltas collected so far.
+func (r *condResolver) exists(q quad.Quad) (bool, error) {
+	ad, rd := createDeltas(q)
+	if _, ok := r.tx.deltas[ad]; ok {
+		return true, nil
+	} else if _, ok = r.tx.deltas[rd]; ok {
+		return false, nil
+	}
+	cur, err := quadsMatching(r.ctx, r.qs, q)
+	return len(cur) != 0, err
+}
+
+func (r *condResolver) add(q quad.Quad) error {
+	ok, err := r.exists(q)
+	if err == nil && !ok {
+		r.tx.AddQuad(q)
+	}
+	return err
+}
+
+func (r *condResolver) remove(q quad.Quad) error {
+	ok, err := r.exists(q)
+	if err == nil && ok {
+		r.tx.RemoveQuad(q)
+	}
+	return err
+}
+
+func (r *condResolver) replace(op CondOp) error {
+	if op.quad.Subject == nil || op.quad.Predicate == nil {
+		return errors.New("replace values must have subject and predicate")
+	}
+	cur, err := quadsMatching(r.ctx, r.qs, op.quad, quad.Object)
+	if err != nil {
+		return err
+	}
+	// include quads added by previous operations
+	for _, d := range r.tx.Deltas {
+		if d.Action != Add || !valueEqual(d.Quad.Subject, op.quad.Subject) ||
+			!valueEqual(d.Quad.Predicate, op.quad.Predicate) || !valueEqual(d.Quad.Label, op.quad.Label) {
+			continue
+		}
+		cur = append(cur, d.Quad)
+	}
+	for _, q := range cur {
+		keep := false
+		for _, v := range op.vals {
+			if valueEqual(q.Object, v) {
+				keep = true
+				break
+			}
+		}
+		if !keep {
+			if err = r.remove(q); err != nil {
+				return err
+			}
+		}
+	}
+	for _, v := range op.vals {
+		q := op.quad
+		q.Object = v
+		if err = r.add(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveConditional checks conditions of operations against the current state of the store
+// and returns deltas that must be applied. Operations are resolved in order.
+func ResolveConditional(ctx context.Context, qs QuadStore, ops []CondOp) ([]Delta, error) {
+	r := &condResolver{ctx: ctx, qs: qs, tx: NewTransactionN(len(ops))}
+	for _, op := range ops {
+		var err error
+		switch op.kind {
+		case condAddUnlessExists:
+			err = r.add(op.quad)
+		case condRemoveIfExists:
+			err = r.remove(op.quad)
+		case condReplaceValues:
+			err = r.replace(op)
+		default:
+			err = ErrInvalidAction
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.tx.Deltas, nil
+}
+
+// removeMatching removes all quads matching the pattern, including ones added by previous operations.
+func (r *condResolver) removeMatching(pattern quad.Quad) error {
+	var any []quad.Direction
+	for _, d := range quad.Directions {
+		if pattern.Get(d) == nil {
+			any = append(any, d)
+		}
+	}
+	if len(any) == len(quad.Directions) {
+		return errors.New("delete matching must have at least one value")
+	}
+	cur, err := quadsMatching(r.ctx, r.qs, pattern, any...)
+	if err != nil {
+		return err
+	}
+	for _, d := range r.tx.Deltas {
+		if d.Action == Add && matches(d.Quad, pattern) {
+			cur = append(cur, d.Quad)
+		}
+	}
+	for _, q := range cur {
+		if err = r.remove(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve adds deltas required to apply a given delta.
+func (r *condResolver) resolve(d Delta) error {
+	switch d.Action {
+	case Add:
+		r.tx.AddQuad(d.Quad)
+	case Delete:
+		r.tx.RemoveQuad(d.Quad)
+	case AddUnique:
+		ok, err := r.exists(d.Quad)
+		if err != nil {
+			return err
+		} else if ok {
+			return &DeltaError{Delta: d, Err: ErrQuadExists}
+		}
+		r.tx.AddQuad(d.Quad)
+	case ReplaceObject:
+		if d.Quad.Object == nil {
+			return &DeltaError{Delta: d, Err: errors.New("replace object must have an object")}
+		}
+		q := d.Quad
+		if err := r.replace(ReplaceValues(q.Subject, q.Predicate, q.Label, q.Object)); err != nil {
+			return &DeltaError{Delta: d, Err: err}
+		}
+	case DeleteMatching:
+		if err := r.removeMatching(d.Quad); err != nil {
+			return &DeltaError{Delta: d, Err: err}
+		}
+	default:
+		return &DeltaError{Delta: d, Err: ErrInvalidAction}
+	}
+	return nil
+}
+
+// HasExtendedDeltas checks if any of deltas has an extended action that must be resolved with ResolveDeltas.
+func HasExtendedDeltas(in []Delta) bool {
+	for _, d := range in {
+		if d.Action != Add && d.Action != Delete {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveTx resolves deltas into a new transaction.
+func resolveTx(ctx context.Context, qs QuadStore, in []Delta) (*Transaction, error) {
+	r := &condResolver{ctx: ctx, qs: qs, tx: NewTransactionN(len(in))}
+	for _, d := range in {
+		if err := r.resolve(d); err != nil {
+			return nil, err
+		}
+	}
+	return r.tx, nil
+}
+
+// ResolveDeltas converts deltas with extended actions (AddUnique, ReplaceObject and DeleteMatching) into Add and Delete
+// deltas by checking them against the current state of the store. Deltas are resolved in order, thus each of them
+// observes the effect of previous ones. Add and Delete deltas are returned as is.
+//
+// Backends without HorizonStore support resolve deltas right before applying them, thus a concurrent write
+// may be applied between resolving and applying the deltas.
+func ResolveDeltas(ctx context.Context, qs QuadStore, in []Delta) ([]Delta, error) {
+	if !HasExtendedDeltas(in) {
+		return in, nil
+	}
+	tx, err := resolveTx(ctx, qs, in)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Deltas, nil
+}
+
+// ApplyResolved resolves extended actions of deltas and calls fnc to apply resulting deltas.
+//
+// If QuadStore implements HorizonStore, deltas are resolved at the current horizon, and fnc must apply them
+// only if the store is still at this horizon. If fnc returns a conflict error, deltas are resolved again.
+// Otherwise, or if there are no extended actions, fnc receives a horizon of -1.
+func ApplyResolved(ctx context.Context, qs QuadStore, in []Delta, fnc func(deltas []Delta, h int64) error) error {
+	return applyResolvedTx(ctx, qs, &Transaction{Deltas: in}, func(tx *Transaction, h int64) error {
+		return fnc(tx.Deltas, h)
+	})
+}
+
+// applyResolvedTx is the same as ApplyResolved, but resolves deltas into a new transaction.
+// The transaction is passed as is if it has no extended actions.
+func applyResolvedTx(ctx context.Context, qs QuadStore, tx *Transaction, fnc func(tx *Transaction, h int64) error) error {
+	if !HasExtendedDeltas(tx.Deltas) {
+		return fnc(tx, -1)
+	}
+	hs, _ := UnwrapHandle(qs).(HorizonStore)
+	for i := 0; ; i++ {
+		h := int64(-1)
+		if hs != nil {
+			var err error
+			if h, err = hs.Horizon(ctx); err != nil {
+				return err
+			}
+		}
+		out, err := resolveTx(ctx, qs, tx.Deltas)
+		if err != nil {
+			return err
+		} else if len(out.Deltas) == 0 {
+			return nil
+		}
+		err = fnc(out, h)
+		if hs == nil || !IsConflict(err) || i+1 >= maxConditionalRetries {
+			return err
+		}
+	}
+}
+
+// applyTransaction applies the transaction at horizon h with QuadWriter, or applies it unconditionally if h is negative.
+func applyTransaction(qw QuadWriter, tx *Transaction, h int64) error {
+	if h < 0 {
+		return qw.ApplyTransaction(tx)
+	}
+	return ApplyTransactionAt(qw, tx, h)
+}
+
+// resolveTxAt resolves extended actions of a transaction that is applied at a known horizon.
+func resolveTxAt(qs QuadStore, tx *Transaction) (*Transaction, error) {
+	if !HasExtendedDeltas(tx.Deltas) {
+		return tx, nil
+	}
+	return resolveTx(context.TODO(), qs, tx.Deltas)
+}
+
+// ApplyConditional applies conditional write operations atomically: conditions are evaluated
+// and resulting deltas are applied only if the store was not modified in the meantime.
+// If it was, operations are evaluated again.
+//
+// QuadStore must implement HorizonStore, otherwise ErrNotSupported is returned.
+func ApplyConditional(ctx context.Context, qs QuadStore, ops ...CondOp) error {
+	hs, ok := Unwrap(qs).(HorizonStore)
+	if !ok {
+		return ErrNotSupported
+	}
+	for i := 0; ; i++ {
+		h, err := hs.Horizon(ctx)
+		if err != nil {
+			return err
+		}
+		deltas, err := ResolveConditional(ctx, qs, ops)
+		if err != nil {
+			return err
+		} else if len(deltas) == 0 {
+			return nil
+		}
+		err = hs.ApplyDeltasAt(deltas, IgnoreOpts{}, h)
+		if !IsConflict(err) || i+1 >= maxConditionalRetries {
+			return err
+		}
+	}
+}
+
+// ErrPreconditionFailed is returned when a precondition of a transaction does not hold.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// Precondition requires a quad to exist in the store, or to be absent from it if Absent is set.
+// All directions of the quad are matched exactly; nil label matches only the default graph.
+type Precondition struct {
+	Quad   quad.Quad
+	Absent bool
+}
+
+// CheckPreconditions checks that all preconditions hold for the current state of the store.
+// It returns ErrPreconditionFailed otherwise.
+func CheckPreconditions(ctx context.Context, qs QuadStore, conds []Precondition) error {
+	for _, c := range conds {
+		cur, err := quadsMatching(ctx, qs, c.Quad)
+		if err != nil {
+			return err
+		}
+		if exists := len(cur) != 0; exists == c.Absent {
+			return ErrPreconditionFailed
+		}
+	}
+	return nil
+}
+
+// ApplyIf applies a transaction with QuadWriter only if all preconditions hold. The transaction is applied
+// only if the store was not modified after preconditions were checked; if it was, preconditions are checked again.
+//
+// If there are no preconditions, the transaction is applied as is. Otherwise, QuadStore must implement HorizonStore
+// and QuadWriter must implement HorizonWriter, or ErrNotSupported is returned.
+func ApplyIf(ctx context.Context, qs QuadStore, qw QuadWriter, tx *Transaction, conds ...Precondition) error {
+	if len(conds) == 0 {
+		return qw.ApplyTransaction(tx)
+	}
+	for i := 0; ; i++ {
+		h, err := Horizon(ctx, qs)
+		if err != nil {
+			return err
+		}
+		if err = CheckPreconditions(ctx, qs, conds); err != nil {
+			return err
+		}
+		err = ApplyTransactionAt(qw, tx, h)
+		if !IsConflict(err) || i+1 >= maxConditionalRetries {
+			return err
+		}
+	}
+}
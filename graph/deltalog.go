@@ -0,0 +1,63 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoLogEntry is returned when a transaction is not recorded in the delta log.
+var ErrNoLogEntry = errors.New("transaction is not recorded in the delta log")
+
+// LogEntry is a set of deltas applied to the store by a single write transaction.
+type LogEntry struct {
+	// Horizon of the store after the transaction was applied. It serves as an ID of the transaction.
+	Horizon   int64
+	Timestamp time.Time
+	// Deltas that were applied by the transaction. Deltas ignored because of IgnoreOpts are not included.
+	Deltas []Delta
+}
+
+// DeltaLog is an optional interface for QuadStores that keep a log of applied transactions.
+// Horizons of the store (see HorizonStore) are used as transaction IDs.
+type DeltaLog interface {
+	// LogEntries returns log entries for transactions with horizons in range (from, to], ordered by horizon.
+	// Transactions that are not recorded in the log are skipped.
+	LogEntries(ctx context.Context, from, to int64) ([]LogEntry, error)
+}
+
+// LogEntries returns log entries for transactions with horizons in range (from, to], ordered by horizon.
+// It returns ErrNotSupported if QuadStore does not implement DeltaLog.
+func LogEntries(ctx context.Context, qs QuadStore, from, to int64) ([]LogEntry, error) {
+	if l, ok := Unwrap(qs).(DeltaLog); ok {
+		return l.LogEntries(ctx, from, to)
+	}
+	return nil, ErrNotSupported
+}
+
+// LogEntryAt returns a log entry for a transaction with a given horizon.
+// It returns ErrNoLogEntry if the transaction is not recorded in the log
+// and ErrNotSupported if QuadStore does not implement DeltaLog.
+func LogEntryAt(ctx context.Context, qs QuadStore, h int64) (*LogEntry, error) {
+	list, err := LogEntries(ctx, qs, h-1, h)
+	if err != nil {
+		return nil, err
+	} else if len(list) == 0 {
+		return nil, ErrNoLogEntry
+	}
+	return &list[0], nil
+}
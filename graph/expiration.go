@@ -0,0 +1,93 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+)
+
+// ExpiringStore is an optional interface for QuadStores that support quads with an expiration time.
+//
+// Expired quads are excluded from all iterators immediately, but they are only removed from the store
+// by ExpireQuads. Until then, they still hold references to their nodes. Adding an expired quad again
+// replaces it, as if it was already removed.
+type ExpiringStore interface {
+	// ApplyDeltasWithExpiration is the same as ApplyDeltas, but all added quads expire at a given time.
+	// Quads that already exist are not modified.
+	ApplyDeltasWithExpiration(in []Delta, opts IgnoreOpts, expires time.Time) error
+	// ExpireQuads removes all quads that expired before or at a given time and returns the number of removed quads.
+	ExpireQuads(ctx context.Context, now time.Time) (int, error)
+}
+
+// ExpiringWriter is an optional interface for QuadWriters that can add quads with an expiration time.
+type ExpiringWriter interface {
+	// ApplyTransactionWithExpiration is the same as ApplyTransaction, but all added quads expire at a given time.
+	ApplyTransactionWithExpiration(tx *Transaction, expires time.Time) error
+}
+
+// ApplyTransactionWithExpiration applies a transaction with QuadWriter, and all added quads expire at a given time.
+// It returns ErrNotSupported if QuadWriter does not implement ExpiringWriter.
+func ApplyTransactionWithExpiration(qw QuadWriter, tx *Transaction, expires time.Time) error {
+	if ew, ok := qw.(ExpiringWriter); ok {
+		return ew.ApplyTransactionWithExpiration(tx, expires)
+	}
+	return ErrNotSupported
+}
+
+// WithExpiration sets an expiration time for all quads added by ApplyDeltas.
+//
+// If QuadStore does not implement ExpiringStore, ApplyDeltas returns ErrNotSupported.
+// The option cannot be combined with IfHorizon or WithProvenance.
+func WithExpiration(t time.Time) ApplyOption {
+	return func(o *applyOptions) {
+		o.expires = t
+	}
+}
+
+// ExpireQuads removes all quads that expired before or at a given time.
+// It returns ErrNotSupported if QuadStore does not implement ExpiringStore.
+func ExpireQuads(ctx context.Context, qs QuadStore, now time.Time) (int, error) {
+	if es, ok := Unwrap(qs).(ExpiringStore); ok {
+		return es.ExpireQuads(ctx, now)
+	}
+	return 0, ErrNotSupported
+}
+
+// RunSweeper removes expired quads from QuadStore with a given interval until the context is canceled.
+// It returns ErrNotSupported immediately if QuadStore does not implement ExpiringStore.
+func RunSweeper(ctx context.Context, qs QuadStore, interval time.Duration) error {
+	es, ok := Unwrap(qs).(ExpiringStore)
+	if !ok {
+		return ErrNotSupported
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-t.C:
+			n, err := es.ExpireQuads(ctx, now)
+			if err != nil && ctx.Err() == nil {
+				clog.Warningf("cannot remove expired quads: %v", err)
+			} else if n != 0 {
+				clog.Infof("removed %d expired quads", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
+)
+
+// DefaultGeoLimit is a maximal number of nodes returned by a geospatial search if the limit is not set.
+const DefaultGeoLimit = 1000
+
+// GeoQuery is a geospatial search query.
+type GeoQuery struct {
+	// Region to search in.
+	Region geo.Region
+	// Predicates restricts the search to geometry literals of given predicates.
+	// All indexed predicates are searched if empty.
+	Predicates []quad.IRI
+	// Limit is a maximal number of nodes to return. DefaultGeoLimit is used if not set.
+	Limit int
+}
+
+// GeoHit is a node found by a geospatial search.
+type GeoHit struct {
+	// Node is a subject of quads with matching geometry literals.
+	Node quad.Value
+	// Distance from the center of the region to the closest geometry of the node, in kilometers.
+	Distance float64
+}
+
+// GeoSearcher is an optional interface for QuadStores that maintain a geospatial index over
+// geometry literals (see geo.FromValue) of some predicates. The index may be backend-native
+// or maintained by a Wrapper.
+type GeoSearcher interface {
+	// SearchGeo returns nodes with geometries inside the region, ordered by distance from its center.
+	SearchGeo(ctx context.Context, q GeoQuery) ([]GeoHit, error)
+}
+
+// asGeoSearcher finds a GeoSearcher in a chain of Handles and Wrappers.
+func asGeoSearcher(qs QuadStore) GeoSearcher {
+	for qs != nil {
+		if s, ok := qs.(GeoSearcher); ok {
+			return s
+		}
+		qs = unwrapOnce(qs)
+	}
+	return nil
+}
+
+// SearchGeo returns nodes with geometries inside the region, ordered by distance from its center.
+// It returns ErrNotSupported if QuadStore does not implement GeoSearcher.
+func SearchGeo(ctx context.Context, qs QuadStore, q GeoQuery) ([]GeoHit, error) {
+	if s := asGeoSearcher(qs); s != nil {
+		return s.SearchGeo(ctx, q)
+	}
+	return nil, ErrNotSupported
+}
@@ -33,6 +33,7 @@ type Config struct {
 
 	SkipDeletedFromIterator  bool
 	SkipSizeCheckAfterDelete bool
+	SkipSubscribe            bool // subscriptions are not available in the test environment
 }
 
 var graphTests = []struct {
@@ -44,8 +45,20 @@ var graphTests = []struct {
 	{"load dup single", TestLoadDupSingle},
 	{"load dup raw", TestLoadDupRaw},
 	{"delete quad", TestDeleteQuad},
+	{"transaction", TestTx},
+	{"if horizon", TestIfHorizon},
+	{"conditional", TestConditional},
+	{"apply if", TestApplyIf},
+	{"extended deltas", TestExtendedDeltas},
+	{"expiration", TestExpiration},
+	{"remove matching", TestRemoveMatching},
+	{"provenance", TestProvenance},
+	{"subscribe", TestSubscribe},
+	{"at horizon", TestAtHorizon},
+	{"capabilities", TestCapabilities},
 	{"sizes", TestSizes},
 	{"iterator", TestIterator},
+	{"next batch", TestNextBatch},
 	{"hasa", TestHasA},
 	{"set iterator", TestSetIterator},
 	{"deleted from iterator", TestDeletedFromIterator},
@@ -55,6 +68,7 @@ var graphTests = []struct {
 	{"iterators and next result order", TestIteratorsAndNextResultOrderA},
 	{"compare typed values", TestCompareTypedValues},
 	{"schema", TestSchema},
+	{"stats", TestStats},
 }
 
 func TestAll(t *testing.T, gen testutil.DatabaseFunc, conf *Config) {
@@ -115,6 +129,31 @@ func MakeQuadSet() []quad.Quad {
 	}
 }
 
+func TestStats(t testing.TB, gen testutil.DatabaseFunc, conf *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	st, err := graph.CollectStats(context.TODO(), qs)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), st.Quads)
+	require.Equal(t, int64(11), st.Nodes)
+	require.Equal(t, map[string]int64{
+		quad.String("follows").String(): 8,
+		quad.String("status").String():  3,
+	}, st.Predicates)
+	require.Equal(t, map[string]int64{"string": 11}, st.ValueTypes)
+	require.True(t, st.Exact)
+
+	est, err := graph.StatsOf(context.TODO(), qs, false)
+	require.NoError(t, err)
+	if est.Exact {
+		require.Equal(t, int64(11), est.Quads)
+		require.Equal(t, int64(11), est.Nodes)
+	}
+}
+
 func IteratedQuads(t testing.TB, qs graph.QuadStore, it graph.Iterator) []quad.Quad {
 	ctx := context.TODO()
 	var res quad.ByQuadString
@@ -668,6 +707,450 @@ func TestDeleteQuad(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
 	it.Close()
 }
 
+func TestTx(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+	size := qs.Size()
+
+	// changes are not visible before commit and are discarded on rollback
+	tx := graph.Begin(qs, graph.IgnoreOpts{})
+	require.NoError(t, tx.AddQuad(quad.Make("E", "follows", "G", nil)))
+	require.NoError(t, tx.RemoveQuad(quad.Make("A", "follows", "B", nil)))
+	require.Len(t, tx.Deltas(), 2)
+	require.Equal(t, size, qs.Size())
+	require.NoError(t, tx.Rollback())
+	require.Equal(t, graph.ErrTxDone, tx.AddQuad(quad.Make("E", "follows", "A", nil)))
+	require.Equal(t, graph.ErrTxDone, tx.Commit())
+	require.Equal(t, size, qs.Size())
+
+	tx = graph.Begin(qs, graph.IgnoreOpts{})
+	require.NoError(t, tx.AddQuad(quad.Make("E", "follows", "G", nil)))
+	require.NoError(t, tx.RemoveQuad(quad.Make("A", "follows", "B", nil)))
+	require.NoError(t, tx.Commit())
+	require.NoError(t, tx.Rollback())
+
+	it := qs.QuadIterator(quad.Subject, qs.ValueOf(quad.Raw("E")))
+	ExpectIteratedQuads(t, qs, it, []quad.Quad{
+		quad.Make("E", "follows", "F", nil),
+		quad.Make("E", "follows", "G", nil),
+	}, true)
+	it.Close()
+
+	// conflicting commit applies nothing
+	tx = graph.Begin(qs, graph.IgnoreOpts{})
+	require.NoError(t, tx.AddQuad(quad.Make("G", "follows", "A", nil)))
+	require.NoError(t, tx.AddQuad(quad.Make("E", "follows", "G", nil)))
+	require.Error(t, tx.Commit())
+
+	it = qs.QuadIterator(quad.Subject, qs.ValueOf(quad.Raw("G")))
+	ExpectIteratedQuads(t, qs, it, []quad.Quad{
+		quad.Make("G", "status", "cool", "status_graph"),
+	}, false)
+	it.Close()
+}
+
+func TestIfHorizon(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	ctx := context.TODO()
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+	h, err := graph.Horizon(ctx, qs)
+	if err == graph.ErrNotSupported {
+		t.SkipNow()
+	}
+	require.NoError(t, err)
+
+	del := []graph.Delta{{Quad: quad.Make("A", "follows", "B", nil), Action: graph.Delete}}
+	require.NoError(t, graph.ApplyDeltas(qs, del, graph.IfHorizon(h)))
+
+	h2, err := graph.Horizon(ctx, qs)
+	require.NoError(t, err)
+	require.True(t, h2 > h, "horizon must advance on delete: %d vs %d", h2, h)
+
+	add := []graph.Delta{{Quad: quad.Make("A", "follows", "B", nil), Action: graph.Add}}
+	err = graph.ApplyDeltas(qs, add, graph.IfHorizon(h))
+	require.True(t, graph.IsConflict(err), "expected conflict, got: %v", err)
+	require.Equal(t, &graph.HorizonConflictError{Expected: h, Actual: h2}, err)
+	require.Nil(t, qs.ValueOf(quad.Raw("A")))
+
+	require.NoError(t, graph.ApplyDeltas(qs, add, graph.IfHorizon(h2)))
+	require.NotNil(t, qs.ValueOf(quad.Raw("A")))
+}
+
+func TestConditional(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	ctx := context.TODO()
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+	size := qs.Size()
+
+	err := graph.ApplyConditional(ctx, qs,
+		graph.AddUnlessExists(quad.Make("A", "follows", "B", nil)),
+		graph.RemoveIfExists(quad.Make("A", "follows", "Z", nil)),
+	)
+	if err == graph.ErrNotSupported {
+		t.SkipNow()
+	}
+	require.NoError(t, err)
+	require.Equal(t, size, qs.Size())
+
+	err = graph.ApplyConditional(ctx, qs,
+		graph.AddUnlessExists(quad.Make("A", "follows", "C", nil)),
+		graph.RemoveIfExists(quad.Make("A", "follows", "B", nil)),
+		graph.ReplaceValues(quad.Raw("D"), quad.Raw("follows"), nil, quad.Raw("G"), quad.Raw("E")),
+		graph.ReplaceValues(quad.Raw("B"), quad.Raw("status"), quad.Raw("status_graph")),
+	)
+	require.NoError(t, err)
+
+	it := qs.QuadsAllIterator()
+	ExpectIteratedQuads(t, qs, it, []quad.Quad{
+		quad.Make("A", "follows", "C", nil),
+		quad.Make("C", "follows", "B", nil),
+		quad.Make("C", "follows", "D", nil),
+		quad.Make("D", "follows", "E", nil),
+		quad.Make("B", "follows", "F", nil),
+		quad.Make("F", "follows", "G", nil),
+		quad.Make("D", "follows", "G", nil),
+		quad.Make("E", "follows", "F", nil),
+		quad.Make("D", "status", "cool", "status_graph"),
+		quad.Make("G", "status", "cool", "status_graph"),
+	}, true)
+	it.Close()
+}
+
+func TestApplyIf(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	ctx := context.TODO()
+	w := testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+	if _, err := graph.Horizon(ctx, qs); err == graph.ErrNotSupported {
+		t.SkipNow()
+	}
+	ab := quad.Make("A", "follows", "B", nil)
+	az := quad.Make("A", "follows", "Z", nil)
+
+	tx := graph.NewTransaction()
+	tx.RemoveQuad(ab)
+	tx.AddQuad(az)
+	require.NoError(t, graph.ApplyIf(ctx, qs, w, tx, graph.Precondition{Quad: ab}))
+	require.Equal(t, graph.ErrPreconditionFailed, graph.ApplyIf(ctx, qs, w, tx, graph.Precondition{Quad: ab}))
+
+	tx = graph.NewTransaction()
+	tx.AddQuad(ab)
+	require.Equal(t, graph.ErrPreconditionFailed, graph.ApplyIf(ctx, qs, w, tx,
+		graph.Precondition{Quad: ab, Absent: true}, graph.Precondition{Quad: az, Absent: true}))
+	require.NoError(t, graph.ApplyIf(ctx, qs, w, tx, graph.Precondition{Quad: ab, Absent: true}, graph.Precondition{Quad: az}))
+
+	it := qs.QuadsAllIterator()
+	ExpectIteratedQuads(t, qs, it, append(MakeQuadSet(), az), true)
+	it.Close()
+}
+
+func TestExtendedDeltas(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	w := testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	err := qs.ApplyDeltas([]graph.Delta{
+		{Quad: quad.Make("A", "follows", "B", nil), Action: graph.AddUnique},
+	}, graph.IgnoreOpts{IgnoreDup: true})
+	require.True(t, graph.IsQuadExist(err), "expected quad exists error, got: %v", err)
+
+	err = qs.ApplyDeltas([]graph.Delta{
+		{Quad: quad.Make("A", "follows", "Z", nil), Action: graph.AddUnique},
+		{Quad: quad.Make("C", "follows", "E", nil), Action: graph.ReplaceObject},
+		{Quad: quad.Quad{Subject: quad.Raw("D")}, Action: graph.DeleteMatching},
+	}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+
+	tx := graph.NewTransaction()
+	tx.Append(graph.Delta{Quad: quad.Make("B", "status", "hot", "status_graph"), Action: graph.ReplaceObject})
+	require.NoError(t, w.ApplyTransaction(tx))
+
+	exp := []quad.Quad{
+		quad.Make("A", "follows", "B", nil),
+		quad.Make("A", "follows", "Z", nil),
+		quad.Make("C", "follows", "E", nil),
+		quad.Make("B", "follows", "F", nil),
+		quad.Make("F", "follows", "G", nil),
+		quad.Make("E", "follows", "F", nil),
+		quad.Make("B", "status", "hot", "status_graph"),
+		quad.Make("G", "status", "cool", "status_graph"),
+	}
+	it := qs.QuadsAllIterator()
+	ExpectIteratedQuads(t, qs, it, exp, true)
+	it.Close()
+}
+
+func TestExpiration(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	ctx := context.TODO()
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	s1 := quad.Make("A", "session", "s1", nil)
+	s2 := quad.Make("A", "session", "s2", nil)
+	s3 := quad.Make("Z", "session", "s3", nil)
+
+	past := time.Now().Add(-time.Minute)
+	err := graph.ApplyDeltas(qs, []graph.Delta{
+		{Quad: s1, Action: graph.Add},
+		{Quad: s3, Action: graph.Add},
+		// existing quads are not modified
+		{Quad: quad.Make("A", "follows", "B", nil), Action: graph.Add},
+	}, graph.WithExpiration(past), graph.WithIgnoreOpts(graph.IgnoreOpts{IgnoreDup: true}))
+	if err == graph.ErrNotSupported {
+		t.SkipNow()
+	}
+	require.NoError(t, err)
+	err = graph.ApplyDeltas(qs, []graph.Delta{
+		{Quad: s2, Action: graph.Add},
+	}, graph.WithExpiration(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+
+	// expired quads are hidden immediately
+	exp := append(MakeQuadSet(), s2)
+	ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), exp, true)
+	it := qs.QuadIterator(quad.Predicate, qs.ValueOf(quad.Raw("session")))
+	ExpectIteratedQuads(t, qs, it, []quad.Quad{s2}, false)
+	it.Close()
+
+	// expired quad is replaced when added again
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: s1, Action: graph.Add}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	exp = append(exp, s1)
+	ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), exp, true)
+
+	n, err := graph.ExpireQuads(ctx, qs, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Nil(t, qs.ValueOf(quad.Raw("Z")))
+	require.Nil(t, qs.ValueOf(quad.Raw("s3")))
+	ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), exp, true)
+
+	n, err = graph.ExpireQuads(ctx, qs, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Nil(t, qs.ValueOf(quad.Raw("s2")))
+	ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), append(MakeQuadSet(), s1), true)
+}
+
+func TestRemoveMatching(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	ctx := context.TODO()
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	n, err := graph.RemoveQuadsMatching(ctx, qs, quad.Quad{Predicate: quad.Raw("status")})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+
+	n, err = graph.RemoveQuadsMatching(ctx, qs, quad.Quad{Subject: quad.Raw("D")})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	n, err = graph.RemoveQuadsMatching(ctx, qs, quad.Quad{Subject: quad.Raw("Z")})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+
+	ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), []quad.Quad{
+		quad.Make("A", "follows", "B", nil),
+		quad.Make("C", "follows", "B", nil),
+		quad.Make("C", "follows", "D", nil),
+		quad.Make("B", "follows", "F", nil),
+		quad.Make("F", "follows", "G", nil),
+		quad.Make("E", "follows", "F", nil),
+	}, true)
+	ExpectIteratedValues(t, qs, qs.NodesAllIterator(), []quad.Value{
+		quad.Raw("A"),
+		quad.Raw("B"),
+		quad.Raw("C"),
+		quad.Raw("D"),
+		quad.Raw("E"),
+		quad.Raw("F"),
+		quad.Raw("G"),
+		quad.Raw("follows"),
+	}, true)
+}
+
+func TestProvenance(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	ctx := context.TODO()
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	ts := time.Date(2017, 5, 10, 12, 30, 0, 0, time.UTC)
+	prov := graph.Provenance{Author: "alice", Source: "import.nq", Time: ts}
+	q := quad.Make("A", "follows", "G", nil)
+	err := graph.ApplyDeltas(qs, []graph.Delta{{Quad: q, Action: graph.Add}}, graph.WithProvenance(prov))
+	if err == graph.ErrNotSupported {
+		t.SkipNow()
+	}
+	require.NoError(t, err)
+
+	p, err := graph.ProvenanceOf(ctx, qs, q)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	require.Equal(t, prov.Author, p.Author)
+	require.Equal(t, prov.Source, p.Source)
+	require.True(t, ts.Equal(p.Time), "unexpected time: %v", p.Time)
+
+	p, err = graph.ProvenanceOf(ctx, qs, quad.Make("A", "follows", "B", nil))
+	require.NoError(t, err)
+	if p != nil {
+		require.Equal(t, "", p.Author)
+	}
+
+	_, err = graph.ProvenanceOf(ctx, qs, quad.Make("A", "follows", "Z", nil))
+	require.Equal(t, graph.ErrQuadNotExist, err)
+
+	require.NoError(t, testutil.MakeWriter(t, qs, opts).RemoveQuad(q))
+	_, err = graph.ProvenanceOf(ctx, qs, q)
+	require.Equal(t, graph.ErrQuadNotExist, err)
+}
+
+func TestSubscribe(t testing.TB, gen testutil.DatabaseFunc, conf *Config) {
+	if conf.SkipSubscribe {
+		t.SkipNow()
+	}
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sub, err := graph.Subscribe(ctx, qs)
+	if err == graph.ErrNotSupported {
+		t.SkipNow()
+	}
+	require.NoError(t, err)
+
+	w := testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+	require.NoError(t, w.RemoveQuad(quad.Make("A", "follows", "B", nil)))
+
+	var changes []graph.Change
+	for len(changes) < len(MakeQuadSet())+1 {
+		c, ok := <-sub.C
+		require.True(t, ok, "subscription closed: %v", sub.Err())
+		changes = append(changes, c)
+	}
+	for i, c := range changes[:len(changes)-1] {
+		require.Equal(t, graph.Add, c.Action)
+		require.Equal(t, MakeQuadSet()[i].String(), c.Quad.String())
+	}
+	last := changes[len(changes)-1]
+	require.Equal(t, graph.Delete, last.Action)
+	require.Equal(t, quad.Make("A", "follows", "B", nil).String(), last.Quad.String())
+	require.True(t, last.Horizon > changes[0].Horizon, "horizon must advance: %d vs %d", last.Horizon, changes[0].Horizon)
+
+	sub.Close()
+	_, ok := <-sub.C
+	require.False(t, ok)
+	require.Equal(t, context.Canceled, sub.Err())
+}
+
+func TestNextBatch(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	ctx := context.TODO()
+	in := MakeQuadSet()
+	testutil.MakeWriter(t, qs, opts, in...)
+
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	var got []quad.Quad
+	buf := make([]graph.Value, 4)
+	for {
+		n, err := graph.NextBatch(ctx, it, buf)
+		require.NoError(t, err)
+		if n == 0 {
+			break
+		}
+		require.True(t, n <= len(buf))
+		for _, v := range buf[:n] {
+			got = append(got, qs.Quad(v))
+		}
+	}
+	sort.Sort(quad.ByQuadString(in))
+	sort.Sort(quad.ByQuadString(got))
+	require.Equal(t, in, got)
+
+	vals, err := graph.Iterate(ctx, qs.QuadsAllIterator()).Paths(false).Limit(10).All()
+	require.NoError(t, err)
+	require.Len(t, vals, 10)
+}
+
+func TestAtHorizon(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	ctx := context.TODO()
+	w := testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+	h, err := graph.Horizon(ctx, qs)
+	if err == graph.ErrNotSupported {
+		t.SkipNow()
+	}
+	require.NoError(t, err)
+	view, err := graph.AtHorizon(ctx, qs, h)
+	if err == graph.ErrNotSupported {
+		t.SkipNow()
+	}
+	require.NoError(t, err)
+	defer view.Close()
+
+	require.NoError(t, w.RemoveQuad(quad.Make("A", "follows", "B", nil)))
+	require.NoError(t, w.AddQuad(quad.Make("A", "follows", "Z", nil)))
+
+	ExpectIteratedQuads(t, view, view.QuadsAllIterator(), MakeQuadSet(), true)
+	require.Nil(t, view.ValueOf(quad.Raw("Z")))
+
+	err = view.ApplyDeltas([]graph.Delta{{Quad: quad.Make("A", "follows", "Y", nil), Action: graph.Add}}, graph.IgnoreOpts{})
+	require.Equal(t, graph.ErrReadOnly, err)
+
+	_, err = graph.AtHorizon(ctx, qs, h)
+	require.Equal(t, graph.ErrHorizonExpired, err)
+}
+
+// TestCapabilities checks that features reported by the store are actually supported.
+func TestCapabilities(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	ctx := context.TODO()
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+	c := graph.CapabilitiesOf(qs)
+
+	h, err := graph.Horizon(ctx, qs)
+	if c.ConditionalWrites {
+		require.NoError(t, err)
+	}
+	if c.HorizonReads {
+		view, err := graph.AtHorizon(ctx, qs, h)
+		require.NoError(t, err)
+		view.Close()
+	}
+	if c.DeltaLog {
+		_, err = graph.LogEntries(ctx, qs, 0, h)
+		require.NoError(t, err)
+	} else {
+		_, err = graph.LogEntries(ctx, qs, 0, h)
+		require.Equal(t, graph.ErrNotSupported, err)
+	}
+	if c.Metadata {
+		_, err = graph.GetMetadata(ctx, qs, "capabilities")
+		require.NoError(t, err)
+	}
+}
+
 func TestDeletedFromIterator(t testing.TB, gen testutil.DatabaseFunc, conf *Config) {
 	if conf.SkipDeletedFromIterator {
 		t.SkipNow()
@@ -0,0 +1,194 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package history builds read-only views of past states of quad stores that record a delta log.
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+// viewBackend is a backend used to build views of past states. It must implement freezer.
+const viewBackend = "memstore"
+
+// freezer is implemented by in-memory stores that can be made read-only at a given horizon.
+type freezer interface {
+	Freeze(h int64)
+}
+
+// maxCopyRetries is the number of attempts to copy the current state if the store is modified concurrently.
+const maxCopyRetries = 3
+
+// logBatch is a number of transactions read from the delta log at once when looking up a timestamp,
+// and a number of quads copied at once.
+const logBatch = 1000
+
+// AsOf returns a read-only view of the QuadStore at horizon h. The view must be closed after use.
+//
+// If the store can provide a view of the current horizon, it is used directly. Past states are
+// rebuilt in memory: the current state is copied and transactions recorded in the delta log after h
+// are reverted. Thus, the store must implement both graph.HorizonStore and graph.DeltaLog, and log
+// entries for all transactions after h must be retained. Building a view of the past is expensive,
+// since it copies all quads of the store into memstore, which must be registered.
+func AsOf(ctx context.Context, qs graph.QuadStore, h int64) (graph.QuadStore, error) {
+	if h < 0 {
+		return nil, fmt.Errorf("invalid horizon: %d", h)
+	}
+	cur, err := graph.Horizon(ctx, qs)
+	if err != nil {
+		return nil, err
+	} else if h > cur {
+		return nil, fmt.Errorf("horizon %d is ahead of the store horizon %d", h, cur)
+	} else if h == cur {
+		view, err := graph.AtHorizon(ctx, qs, h)
+		if err != graph.ErrNotSupported && err != graph.ErrHorizonExpired {
+			return view, err
+		}
+	}
+	for i := 0; ; i++ {
+		view, err := rebuild(ctx, qs, h, cur)
+		if err != errModified || i+1 >= maxCopyRetries {
+			return view, err
+		}
+		if cur, err = graph.Horizon(ctx, qs); err != nil {
+			return nil, err
+		}
+	}
+}
+
+var errModified = errors.New("store was modified while building a view")
+
+// rebuild copies the state of the store at horizon cur and reverts transactions up to horizon h.
+func rebuild(ctx context.Context, qs graph.QuadStore, h, cur int64) (graph.QuadStore, error) {
+	entries, err := graph.LogEntries(ctx, qs, h, cur)
+	if err != nil {
+		return nil, err
+	}
+	view, err := graph.NewQuadStore(viewBackend, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	fr, ok := view.(freezer)
+	if !ok {
+		view.Close()
+		return nil, fmt.Errorf("%s backend cannot be used for views", viewBackend)
+	}
+	if err = copyAt(ctx, view, qs, cur); err != nil {
+		view.Close()
+		return nil, err
+	}
+	opts := graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true}
+	for i := len(entries) - 1; i >= 0; i-- {
+		in := entries[i].Deltas
+		deltas := make([]graph.Delta, 0, len(in))
+		for j := len(in) - 1; j >= 0; j-- {
+			d := in[j]
+			switch d.Action {
+			case graph.Add:
+				d.Action = graph.Delete
+			case graph.Delete:
+				d.Action = graph.Add
+			default:
+				return nil, fmt.Errorf("unexpected action in the delta log: %v", d.Action)
+			}
+			deltas = append(deltas, d)
+		}
+		if err = view.ApplyDeltas(deltas, opts); err != nil {
+			view.Close()
+			return nil, err
+		}
+	}
+	fr.Freeze(h)
+	return view, nil
+}
+
+// copyAt copies all quads of the store at horizon cur. It returns errModified if the horizon has advanced.
+func copyAt(ctx context.Context, dst graph.QuadStore, qs graph.QuadStore, cur int64) error {
+	src, err := graph.AtHorizon(ctx, qs, cur)
+	if err == nil {
+		defer src.Close()
+	} else if err == graph.ErrNotSupported {
+		src = qs
+	} else if err == graph.ErrHorizonExpired {
+		return errModified
+	} else {
+		return err
+	}
+	opts := graph.IgnoreOpts{IgnoreDup: true}
+	it := src.QuadsAllIterator()
+	defer it.Close()
+	buf := make([]graph.Delta, 0, logBatch)
+	for it.Next(ctx) {
+		buf = append(buf, graph.Delta{Quad: src.Quad(it.Result()), Action: graph.Add})
+		if len(buf) == cap(buf) {
+			if err = dst.ApplyDeltas(buf, opts); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	if err = it.Err(); err != nil {
+		return err
+	} else if len(buf) != 0 {
+		if err = dst.ApplyDeltas(buf, opts); err != nil {
+			return err
+		}
+	}
+	if src != qs {
+		return nil
+	}
+	h, err := graph.Horizon(ctx, qs)
+	if err != nil {
+		return err
+	} else if h != cur {
+		return errModified
+	}
+	return nil
+}
+
+// HorizonAt returns the horizon of the QuadStore at a given time: the horizon of the last transaction
+// recorded in the delta log before or at t. The store must implement graph.HorizonStore and graph.DeltaLog.
+func HorizonAt(ctx context.Context, qs graph.QuadStore, t time.Time) (int64, error) {
+	cur, err := graph.Horizon(ctx, qs)
+	if err != nil {
+		return 0, err
+	}
+	h := int64(-1)
+	for from := int64(0); from < cur; from += logBatch {
+		to := from + logBatch
+		if to > cur {
+			to = cur
+		}
+		entries, err := graph.LogEntries(ctx, qs, from, to)
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range entries {
+			if e.Timestamp.After(t) {
+				if h < 0 {
+					// the state before the first logged transaction
+					h = e.Horizon - 1
+				}
+				return h, nil
+			}
+			h = e.Horizon
+		}
+	}
+	return cur, nil
+}
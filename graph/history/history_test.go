@@ -0,0 +1,91 @@
+package history_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/history"
+	_ "github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func TestAsOf(t *testing.T) {
+	ctx := context.TODO()
+	qs, err := graph.NewQuadStore("btree", "", graph.Options{"delta_log": true})
+	require.NoError(t, err)
+	defer qs.Close()
+	qw, err := graph.NewQuadWriter("single", qs, graph.Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("a", "b", "c", "")))
+	h1, err := graph.Horizon(ctx, qs)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	t1 := time.Now()
+	time.Sleep(time.Millisecond)
+
+	tx := graph.NewTransaction()
+	tx.RemoveQuad(quad.MakeIRI("a", "b", "c", ""))
+	tx.AddQuad(quad.MakeIRI("a", "b", "d", ""))
+	require.NoError(t, qw.ApplyTransaction(tx))
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("d", "e", "f", "")))
+
+	view, err := history.AsOf(ctx, qs, h1)
+	require.NoError(t, err)
+	defer view.Close()
+	graphtest.ExpectIteratedQuads(t, view, view.QuadsAllIterator(), []quad.Quad{
+		quad.MakeIRI("a", "b", "c", ""),
+	}, true)
+	require.Nil(t, view.ValueOf(quad.IRI("d")))
+	vh, err := graph.Horizon(ctx, view)
+	require.NoError(t, err)
+	require.Equal(t, h1, vh)
+	err = view.ApplyDeltas([]graph.Delta{{Quad: quad.MakeIRI("x", "y", "z", ""), Action: graph.Add}}, graph.IgnoreOpts{})
+	require.Equal(t, graph.ErrReadOnly, err)
+
+	view0, err := history.AsOf(ctx, qs, 0)
+	require.NoError(t, err)
+	defer view0.Close()
+	graphtest.ExpectIteratedQuads(t, view0, view0.QuadsAllIterator(), nil, true)
+
+	h, err := history.HorizonAt(ctx, qs, t1)
+	require.NoError(t, err)
+	require.Equal(t, h1, h)
+	h, err = history.HorizonAt(ctx, qs, t1.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, h1-1, h)
+
+	cur, err := graph.Horizon(ctx, qs)
+	require.NoError(t, err)
+	h, err = history.HorizonAt(ctx, qs, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, cur, h)
+
+	_, err = history.AsOf(ctx, qs, cur+1)
+	require.Error(t, err)
+}
+
+func TestAsOfNoHistory(t *testing.T) {
+	ctx := context.TODO()
+	qs := memstore.New()
+	err := qs.ApplyDeltas([]graph.Delta{{Quad: quad.MakeIRI("a", "b", "c", ""), Action: graph.Add}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	_, err = history.AsOf(ctx, qs, 0)
+	require.Equal(t, graph.ErrNotSupported, err)
+
+	cur, err := graph.Horizon(ctx, qs)
+	require.NoError(t, err)
+	view, err := history.AsOf(ctx, qs, cur)
+	require.NoError(t, err)
+	defer view.Close()
+	graphtest.ExpectIteratedQuads(t, view, view.QuadsAllIterator(), []quad.Quad{
+		quad.MakeIRI("a", "b", "c", ""),
+	}, true)
+}
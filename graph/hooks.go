@@ -0,0 +1,190 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// BeforeCommitHook is called with a batch of deltas before it is applied to the store.
+//
+// Hook may validate deltas and reject the batch by returning an error, or enrich it by
+// adding or removing deltas from the transaction.
+type BeforeCommitHook func(tx *Transaction) error
+
+// AfterCommitHook is called with a batch of deltas after it was applied to the store.
+type AfterCommitHook func(deltas []Delta)
+
+// Hooks is a set of functions invoked on each write. It is safe for concurrent use.
+type Hooks struct {
+	mu     sync.RWMutex
+	before []BeforeCommitHook
+	after  []AfterCommitHook
+}
+
+// BeforeCommit registers a hook that is called before each write. Hooks are called in order of registration.
+func (h *Hooks) BeforeCommit(fnc BeforeCommitHook) {
+	h.mu.Lock()
+	h.before = append(h.before, fnc)
+	h.mu.Unlock()
+}
+
+// AfterCommit registers a hook that is called after each successful write. Hooks are called in order of registration.
+func (h *Hooks) AfterCommit(fnc AfterCommitHook) {
+	h.mu.Lock()
+	h.after = append(h.after, fnc)
+	h.mu.Unlock()
+}
+
+func (h *Hooks) hooks() ([]BeforeCommitHook, []AfterCommitHook) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.before, h.after
+}
+
+// NewHookWriter wraps a QuadWriter to invoke hooks on each write.
+//
+// All writes are converted to transactions, thus before commit hooks see the whole batch.
+// If any of them returns an error, the batch is not applied and the error is returned to the caller.
+// After commit hooks receive deltas as they were passed to the writer, even if some of them
+// were ignored by the writer because of IgnoreOpts.
+func NewHookWriter(qs QuadStore, qw QuadWriter, h *Hooks) QuadWriter {
+	return &hookWriter{qs: qs, qw: qw, h: h}
+}
+
+type hookWriter struct {
+	qs QuadStore
+	qw QuadWriter
+	h  *Hooks
+}
+
+func (w *hookWriter) AddQuad(q quad.Quad) error {
+	tx := NewTransactionN(1)
+	tx.AddQuad(q)
+	return w.ApplyTransaction(tx)
+}
+
+func (w *hookWriter) AddQuadSet(quads []quad.Quad) error {
+	tx := NewTransactionN(len(quads))
+	for _, q := range quads {
+		tx.AddQuad(q)
+	}
+	return w.ApplyTransaction(tx)
+}
+
+func (w *hookWriter) RemoveQuad(q quad.Quad) error {
+	tx := NewTransactionN(1)
+	tx.RemoveQuad(q)
+	return w.ApplyTransaction(tx)
+}
+
+// ApplyTransaction runs hooks and applies the transaction.
+// Extended actions are resolved first, thus hooks only see Add and Delete deltas.
+func (w *hookWriter) ApplyTransaction(tx *Transaction) error {
+	return applyResolvedTx(context.TODO(), w.qs, tx, func(tx *Transaction, h int64) error {
+		return w.apply(tx, func(tx *Transaction) error {
+			return applyTransaction(w.qw, tx, h)
+		})
+	})
+}
+
+var _ HorizonWriter = (*hookWriter)(nil)
+
+// ApplyTransactionAt implements HorizonWriter.
+func (w *hookWriter) ApplyTransactionAt(tx *Transaction, h int64) error {
+	tx, err := resolveTxAt(w.qs, tx)
+	if err != nil {
+		return err
+	}
+	return w.apply(tx, func(tx *Transaction) error {
+		return ApplyTransactionAt(w.qw, tx, h)
+	})
+}
+
+var _ ExpiringWriter = (*hookWriter)(nil)
+
+// ApplyTransactionWithExpiration implements ExpiringWriter.
+func (w *hookWriter) ApplyTransactionWithExpiration(tx *Transaction, expires time.Time) error {
+	tx, err := resolveTxAt(w.qs, tx)
+	if err != nil {
+		return err
+	}
+	return w.apply(tx, func(tx *Transaction) error {
+		return ApplyTransactionWithExpiration(w.qw, tx, expires)
+	})
+}
+
+// apply runs hooks around a function that applies the transaction.
+func (w *hookWriter) apply(tx *Transaction, fnc func(tx *Transaction) error) error {
+	before, after := w.h.hooks()
+	for _, fnc := range before {
+		if err := fnc(tx); err != nil {
+			return err
+		}
+	}
+	if len(tx.Deltas) == 0 {
+		return nil
+	}
+	if err := fnc(tx); err != nil {
+		return err
+	}
+	for _, fnc := range after {
+		fnc(tx.Deltas)
+	}
+	return nil
+}
+
+// RemoveNode removes all quads with a given node in a single transaction, so hooks can see removed quads.
+func (w *hookWriter) RemoveNode(v quad.Value) error {
+	tx, err := removeNodeTx(w.qs, v)
+	if err != nil {
+		return err
+	}
+	return w.ApplyTransaction(tx)
+}
+
+func (w *hookWriter) Close() error {
+	return w.qw.Close()
+}
+
+// removeNodeTx returns a transaction that removes all quads with a given node.
+// It returns ErrNodeNotExists if node is missing.
+func removeNodeTx(qs QuadStore, v quad.Value) (*Transaction, error) {
+	gv := qs.ValueOf(v)
+	if gv == nil {
+		return nil, ErrNodeNotExists
+	}
+	ctx := context.TODO()
+	tx := NewTransaction()
+	for _, d := range quad.Directions {
+		it := qs.QuadIterator(d, gv)
+		for it.Next(ctx) {
+			tx.RemoveQuad(qs.Quad(it.Result()))
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(tx.Deltas) == 0 {
+		return nil, ErrNodeNotExists
+	}
+	return tx, nil
+}
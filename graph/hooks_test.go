@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+type recordWriter struct {
+	QuadWriter
+	txs [][]Delta
+}
+
+func (w *recordWriter) ApplyTransaction(tx *Transaction) error {
+	w.txs = append(w.txs, tx.Deltas)
+	return nil
+}
+
+func TestHookWriter(t *testing.T) {
+	errRejected := errors.New("rejected")
+	var (
+		hooks Hooks
+		after [][]Delta
+	)
+	hooks.BeforeCommit(func(tx *Transaction) error {
+		for _, d := range tx.Deltas {
+			if d.Quad.Predicate == quad.IRI("secret") {
+				return errRejected
+			}
+		}
+		return nil
+	})
+	hooks.BeforeCommit(func(tx *Transaction) error {
+		// enrich added quads with a type
+		for _, d := range tx.Deltas {
+			if d.Action == Add && d.Quad.Predicate == quad.IRI("name") {
+				tx.AddQuad(quad.MakeIRI(string(d.Quad.Subject.(quad.IRI)), "type", "Named", ""))
+			}
+		}
+		return nil
+	})
+	hooks.AfterCommit(func(deltas []Delta) {
+		after = append(after, deltas)
+	})
+
+	rw := &recordWriter{}
+	w := NewHookWriter(nil, rw, &hooks)
+
+	if err := w.AddQuad(quad.MakeIRI("a", "secret", "b", "")); err != errRejected {
+		t.Fatalf("expected write to be rejected, got: %v", err)
+	} else if len(rw.txs) != 0 || len(after) != 0 {
+		t.Fatal("rejected write must not be applied")
+	}
+
+	if err := w.AddQuad(quad.MakeIRI("a", "name", "b", "")); err != nil {
+		t.Fatal(err)
+	}
+	exp := []Delta{
+		{Quad: quad.MakeIRI("a", "name", "b", ""), Action: Add},
+		{Quad: quad.MakeIRI("a", "type", "Named", ""), Action: Add},
+	}
+	if !reflect.DeepEqual(rw.txs, [][]Delta{exp}) {
+		t.Fatalf("unexpected deltas applied: %v", rw.txs)
+	} else if !reflect.DeepEqual(after, [][]Delta{exp}) {
+		t.Fatalf("unexpected deltas passed to hook: %v", after)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrReadOnly is returned when writing to a read-only view of the store.
+var ErrReadOnly = errors.New("quad store is read-only")
+
+// HorizonStore is an optional interface for QuadStores that track a horizon of writes.
+//
+// The horizon is a number that advances each time a set of deltas is applied to the store,
+// thus it can be used for optimistic concurrency control: read the horizon, read the data and
+// apply changes only if the horizon has not advanced in the meantime.
+type HorizonStore interface {
+	// Horizon returns the current horizon of the store.
+	Horizon(ctx context.Context) (int64, error)
+	// ApplyDeltasAt is the same as ApplyDeltas, but applies deltas only if the current horizon
+	// is equal to h. It returns *HorizonConflictError otherwise.
+	ApplyDeltasAt(in []Delta, opts IgnoreOpts, h int64) error
+}
+
+// HorizonReader is an optional interface for QuadStores that can provide read-only views
+// of the data pinned to a horizon. Reads from a view are repeatable: they are not affected by
+// writes that happen after the view was created.
+type HorizonReader interface {
+	// AtHorizon returns a read-only view of the store at horizon h.
+	// It returns ErrHorizonExpired if the state at this horizon is no longer retained.
+	// The view must be closed after use.
+	AtHorizon(ctx context.Context, h int64) (QuadStore, error)
+}
+
+// HorizonWriter is an optional interface for QuadWriters that can apply a transaction only if the horizon
+// of the store has not advanced, similar to HorizonStore.ApplyDeltasAt.
+type HorizonWriter interface {
+	// ApplyTransactionAt is the same as ApplyTransaction, but applies the transaction only if the current
+	// horizon of the store is equal to h. It returns *HorizonConflictError otherwise.
+	ApplyTransactionAt(tx *Transaction, h int64) error
+}
+
+// ApplyTransactionAt applies a transaction with QuadWriter only if the horizon of the store is equal to h.
+// It returns ErrNotSupported if QuadWriter does not implement HorizonWriter.
+func ApplyTransactionAt(qw QuadWriter, tx *Transaction, h int64) error {
+	if hw, ok := qw.(HorizonWriter); ok {
+		return hw.ApplyTransactionAt(tx, h)
+	}
+	return ErrNotSupported
+}
+
+// HorizonConflictError is returned when deltas are applied with IfHorizon,
+// but the horizon of the store has advanced.
+type HorizonConflictError struct {
+	Expected int64 // horizon requested by IfHorizon
+	Actual   int64 // current horizon of the store
+}
+
+func (e *HorizonConflictError) Error() string {
+	return fmt.Sprintf("conflict: store horizon has advanced from %d to %d", e.Expected, e.Actual)
+}
+
+// IsConflict checks if an error is a HorizonConflictError.
+func IsConflict(err error) bool {
+	if e, ok := err.(*DeltaError); ok {
+		err = e.Err
+	}
+	_, ok := err.(*HorizonConflictError)
+	return ok
+}
+
+// Horizon returns the current horizon of the QuadStore.
+// It returns ErrNotSupported if QuadStore does not implement HorizonStore.
+func Horizon(ctx context.Context, qs QuadStore) (int64, error) {
+	if h, ok := Unwrap(qs).(HorizonStore); ok {
+		return h.Horizon(ctx)
+	}
+	return 0, ErrNotSupported
+}
+
+// AtHorizon returns a read-only view of the QuadStore at horizon h. The view must be closed after use.
+// It returns ErrNotSupported if QuadStore does not implement HorizonReader.
+//
+// Most backends only retain the current state, thus the current horizon should be used:
+//
+//	h, err := graph.Horizon(ctx, qs)
+//	view, err := graph.AtHorizon(ctx, qs, h)
+func AtHorizon(ctx context.Context, qs QuadStore, h int64) (QuadStore, error) {
+	if r, ok := Unwrap(qs).(HorizonReader); ok {
+		return r.AtHorizon(ctx, h)
+	}
+	return nil, ErrNotSupported
+}
+
+// CheckHorizon checks if the horizon h can be read when the store is at horizon cur.
+// It can be used by HorizonReader implementations that do not retain history.
+func CheckHorizon(h, cur int64) error {
+	if h < cur {
+		return ErrHorizonExpired
+	} else if h > cur {
+		return fmt.Errorf("horizon %d is ahead of the store horizon %d", h, cur)
+	}
+	return nil
+}
+
+// ApplyOption is an option for ApplyDeltas.
+type ApplyOption func(o *applyOptions)
+
+type applyOptions struct {
+	horizon    int64
+	ifHorizon  bool
+	ignoreOpts IgnoreOpts
+	prov       *Provenance
+	expires    time.Time
+}
+
+// IfHorizon makes ApplyDeltas fail with *HorizonConflictError if the horizon of the store is not equal to h,
+// meaning that some changes were applied after the horizon was read.
+func IfHorizon(h int64) ApplyOption {
+	return func(o *applyOptions) {
+		o.horizon, o.ifHorizon = h, true
+	}
+}
+
+// WithIgnoreOpts sets options for handling duplicate and missing quads.
+func WithIgnoreOpts(opts IgnoreOpts) ApplyOption {
+	return func(o *applyOptions) {
+		o.ignoreOpts = opts
+	}
+}
+
+// ApplyDeltas applies deltas to the QuadStore with given options.
+//
+// If IfHorizon, WithProvenance or WithExpiration is set and QuadStore does not implement a corresponding interface,
+// ErrNotSupported is returned.
+func ApplyDeltas(qs QuadStore, in []Delta, opts ...ApplyOption) error {
+	var o applyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !o.expires.IsZero() {
+		es, ok := Unwrap(qs).(ExpiringStore)
+		if !ok || o.ifHorizon || o.prov != nil {
+			return ErrNotSupported
+		}
+		return es.ApplyDeltasWithExpiration(in, o.ignoreOpts, o.expires)
+	}
+	if o.prov != nil {
+		ps, ok := Unwrap(qs).(ProvenanceStore)
+		if !ok || o.ifHorizon {
+			return ErrNotSupported
+		}
+		return ps.ApplyDeltasWithProvenance(in, o.ignoreOpts, *o.prov)
+	}
+	if !o.ifHorizon {
+		return qs.ApplyDeltas(in, o.ignoreOpts)
+	}
+	h, ok := Unwrap(qs).(HorizonStore)
+	if !ok {
+		return ErrNotSupported
+	}
+	return h.ApplyDeltasAt(in, o.ignoreOpts, o.horizon)
+}
@@ -0,0 +1,381 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inference implements a lightweight RDFS reasoning layer over a QuadStore.
+//
+// It supports entailments of rdfs:subClassOf, rdfs:subPropertyOf, rdfs:domain and rdfs:range,
+// thus instances of a class are also instances of all its superclasses, and a link with some
+// property also implies links with all its superproperties. A subset of OWL 2 RL rules (inverse, symmetric
+// and transitive properties, and equal nodes) can be enabled as well.
+//
+// Entailments are either materialized as quads on each write, or applied to queries by
+// rewriting query shapes (see Strategy).
+package inference
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/rdf"
+	"github.com/cayleygraph/cayley/voc/rdfs"
+)
+
+// Strategy defines how entailments are applied.
+type Strategy string
+
+const (
+	// Materialize writes entailed quads to the store with a separate label (see Config.Label).
+	// Queries are not changed and all kinds of queries see the inferred data,
+	// but writes are more expensive and changes of the schema require rewriting all entailments.
+	Materialize = Strategy("materialize")
+	// Rewrite expands query constraints on classes and properties with their subclasses and subproperties.
+	// Nothing is written to the store, but only queries for instances of a class and for links with a
+	// property are expanded. For example, listing types of a node returns only asserted types.
+	Rewrite = Strategy("rewrite")
+)
+
+// DefaultStrategy is used if the strategy is not set in the config.
+const DefaultStrategy = Rewrite
+
+// DefaultLabel is a label of materialized quads.
+const DefaultLabel = quad.IRI("cayley:inferred")
+
+// ParseStrategy checks if a given string is a name of a known strategy.
+func ParseStrategy(s string) (Strategy, error) {
+	switch st := Strategy(s); st {
+	case "":
+		return DefaultStrategy, nil
+	case Materialize, Rewrite:
+		return st, nil
+	}
+	return "", fmt.Errorf("inference: unknown strategy: %q", s)
+}
+
+// Config is a configuration of the inference layer.
+type Config struct {
+	Strategy Strategy
+	// OWL enables a subset of OWL 2 RL rules, see Schema for details.
+	OWL bool
+	// Label is a label of materialized quads. DefaultLabel is used if not set.
+	// Quads with this label are managed by the store and should not be written by users.
+	Label quad.Value
+}
+
+var (
+	typeIRI          = quad.IRI(rdf.Type).Full()
+	subClassOfIRI    = quad.IRI(rdfs.SubClassOf).Full()
+	subPropertyOfIRI = quad.IRI(rdfs.SubPropertyOf).Full()
+	domainIRI        = quad.IRI(rdfs.Domain).Full()
+	rangeIRI         = quad.IRI(rdfs.Range).Full()
+)
+
+// schemaPredicates is a list of predicates that define the schema.
+var schemaPredicates = []quad.IRI{subClassOfIRI, subPropertyOfIRI, domainIRI, rangeIRI}
+
+// relation is a set of links between schema nodes.
+type relation map[quad.Value]map[quad.Value]struct{}
+
+func (r relation) set(from, to quad.Value, ok bool) {
+	m := r[from]
+	if ok {
+		if m == nil {
+			m = make(map[quad.Value]struct{})
+			r[from] = m
+		}
+		m[to] = struct{}{}
+		return
+	}
+	delete(m, to)
+	if len(m) == 0 {
+		delete(r, from)
+	}
+}
+
+// closure returns all nodes reachable from given ones, excluding the starting nodes themselves
+// unless they are part of a cycle.
+func (r relation) closure(from ...quad.Value) []quad.Value {
+	var (
+		out  []quad.Value
+		seen = make(map[quad.Value]struct{})
+	)
+	next := from
+	for len(next) != 0 {
+		cur := next
+		next = nil
+		for _, v := range cur {
+			for n := range r[v] {
+				if _, ok := seen[n]; ok {
+					continue
+				}
+				seen[n] = struct{}{}
+				out = append(out, n)
+				next = append(next, n)
+			}
+		}
+	}
+	sortValues(out)
+	return out
+}
+
+func sortValues(arr []quad.Value) {
+	sort.Slice(arr, func(i, j int) bool {
+		return quad.StringOf(arr[i]) < quad.StringOf(arr[j])
+	})
+}
+
+// norm expands IRIs to the full form, since the same IRI may be written in the short form.
+func norm(v quad.Value) quad.Value {
+	if iri, ok := v.(quad.IRI); ok {
+		return iri.Full()
+	}
+	return v
+}
+
+func normAll(arr []quad.Value) []quad.Value {
+	out := make([]quad.Value, 0, len(arr))
+	for _, v := range arr {
+		out = append(out, norm(v))
+	}
+	return out
+}
+
+// isNode checks if a value can be used as a class or a property.
+func isNode(v quad.Value) bool {
+	switch v.(type) {
+	case quad.IRI, quad.BNode:
+		return true
+	}
+	return false
+}
+
+// Schema is an in-memory copy of RDFS class and property hierarchies. It is safe for concurrent use.
+//
+// If OWL rules are enabled, the schema also tracks owl:inverseOf links, transitive and symmetric properties
+// (declared with rdf:type), and keeps an index of nodes that are linked with owl:sameAs.
+//
+// IRIs of classes and properties are expanded to the full form.
+type Schema struct {
+	mu        sync.RWMutex
+	subClass  relation // class -> superclasses
+	superCl   relation // class -> subclasses
+	subProp   relation // property -> superproperties
+	superProp relation // property -> subproperties
+	domain    relation // property -> classes
+	rng       relation // property -> classes
+
+	owl        bool
+	inverse    relation // property -> inverse properties, as asserted
+	inverseRev relation // property -> properties that are asserted to be inverse of it
+	symmetric  map[quad.Value]struct{}
+	transitive map[quad.Value]struct{}
+	same       relation                    // node -> nodes, as asserted
+	sameRev    relation                    // node -> nodes that are asserted to be the same as it
+	eq         map[quad.Value][]quad.Value // node -> all nodes of the equivalence class
+}
+
+// NewSchema creates an empty schema. If owl is set, a subset of OWL 2 RL rules is supported.
+func NewSchema(owl bool) *Schema {
+	s := &Schema{
+		subClass: make(relation), superCl: make(relation),
+		subProp: make(relation), superProp: make(relation),
+		domain: make(relation), rng: make(relation),
+		owl: owl,
+	}
+	if owl {
+		s.inverse, s.inverseRev = make(relation), make(relation)
+		s.symmetric = make(map[quad.Value]struct{})
+		s.transitive = make(map[quad.Value]struct{})
+		s.same, s.sameRev = make(relation), make(relation)
+		s.eq = make(map[quad.Value][]quad.Value)
+	}
+	return s
+}
+
+// Defines checks if a quad defines the schema.
+func (s *Schema) Defines(q quad.Quad) bool {
+	p, ok := q.Predicate.(quad.IRI)
+	if !ok || !isNode(q.Subject) || !isNode(q.Object) {
+		return false
+	}
+	p = p.Full()
+	for _, sp := range schemaPredicates {
+		if p == sp {
+			return true
+		}
+	}
+	return s.owl && isOWL(p, q.Object)
+}
+
+// Set adds or removes a schema link defined by a quad. It returns false if the quad does not define the schema.
+func (s *Schema) Set(q quad.Quad, ok bool) bool {
+	if !s.Defines(q) {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, obj := norm(q.Subject), norm(q.Object)
+	switch q.Predicate.(quad.IRI).Full() {
+	case subClassOfIRI:
+		s.subClass.set(sub, obj, ok)
+		s.superCl.set(obj, sub, ok)
+	case subPropertyOfIRI:
+		s.subProp.set(sub, obj, ok)
+		s.superProp.set(obj, sub, ok)
+	case domainIRI:
+		s.domain.set(sub, obj, ok)
+	case rangeIRI:
+		s.rng.set(sub, obj, ok)
+	default:
+		s.setOWL(sub, q.Predicate.(quad.IRI).Full(), obj, ok)
+	}
+	return true
+}
+
+// Empty checks if the schema defines no entailments. Links between equal nodes are not counted,
+// since they are only applied to queries.
+func (s *Schema) Empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.subClass) == 0 && len(s.subProp) == 0 && len(s.domain) == 0 && len(s.rng) == 0 &&
+		len(s.inverse) == 0 && len(s.symmetric) == 0 && len(s.transitive) == 0
+}
+
+// SuperClasses returns all direct and indirect superclasses of classes.
+func (s *Schema) SuperClasses(c ...quad.Value) []quad.Value {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.subClass.closure(normAll(c)...)
+}
+
+// SubClasses returns all direct and indirect subclasses of classes.
+func (s *Schema) SubClasses(c ...quad.Value) []quad.Value {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.superCl.closure(normAll(c)...)
+}
+
+// SuperProperties returns all direct and indirect superproperties of properties.
+func (s *Schema) SuperProperties(p ...quad.Value) []quad.Value {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.subProp.closure(normAll(p)...)
+}
+
+// SubProperties returns all direct and indirect subproperties of properties.
+func (s *Schema) SubProperties(p ...quad.Value) []quad.Value {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.superProp.closure(normAll(p)...)
+}
+
+// Entail returns all quads that are entailed by a given one. Entailed quads have no label.
+// Links between nodes keep the original values, while classes and properties are in the full form.
+func (s *Schema) Entail(q quad.Quad) []quad.Quad {
+	if !isNode(q.Predicate) {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// links between nodes entailed by properties; the first one is the quad itself
+	links := []quad.Quad{{Subject: q.Subject, Predicate: norm(q.Predicate), Object: q.Object}}
+	seen := map[string]struct{}{tripleKey(links[0]): {}}
+	for i := 0; i < len(links); i++ {
+		l := links[i]
+		next := make([]quad.Quad, 0)
+		for _, p := range s.subProp.closure(l.Predicate) {
+			next = append(next, quad.Quad{Subject: l.Subject, Predicate: p, Object: l.Object})
+		}
+		if s.owl && isNode(l.Object) {
+			next = append(next, s.reversed(l)...)
+		}
+		for _, n := range next {
+			k := tripleKey(n)
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				links = append(links, n)
+			}
+		}
+	}
+	out := links[1:]
+	addTypes := func(node quad.Value, classes []quad.Value) {
+		for _, c := range classes {
+			out = append(out, quad.Quad{Subject: node, Predicate: typeIRI, Object: c})
+		}
+	}
+	for _, l := range links {
+		if l.Predicate == typeIRI && isNode(l.Object) {
+			addTypes(l.Subject, s.subClass.closure(norm(l.Object)))
+		}
+		if m := s.domain[l.Predicate]; len(m) != 0 {
+			classes := keys(m)
+			addTypes(l.Subject, append(classes, s.subClass.closure(classes...)...))
+		}
+		if m := s.rng[l.Predicate]; len(m) != 0 && isNode(l.Object) {
+			classes := keys(m)
+			addTypes(l.Object, append(classes, s.subClass.closure(classes...)...))
+		}
+	}
+	return out
+}
+
+// propertiesOf returns all properties with a domain set to one of given classes,
+// and all properties with a range set to one of them.
+func (s *Schema) propertiesOf(classes []quad.Value) (domain, rng []quad.Value) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := make(map[quad.Value]struct{}, len(classes))
+	for _, c := range classes {
+		set[norm(c)] = struct{}{}
+	}
+	collect := func(r relation) []quad.Value {
+		var out []quad.Value
+		for p, m := range r {
+			for c := range m {
+				if _, ok := set[c]; ok {
+					out = append(out, p)
+					break
+				}
+			}
+		}
+		// subproperties inherit domains and ranges
+		out = append(out, s.superProp.closure(out...)...)
+		return dedup(out)
+	}
+	return collect(s.domain), collect(s.rng)
+}
+
+func keys(m map[quad.Value]struct{}) []quad.Value {
+	out := make([]quad.Value, 0, len(m))
+	for v := range m {
+		out = append(out, v)
+	}
+	sortValues(out)
+	return out
+}
+
+func dedup(arr []quad.Value) []quad.Value {
+	seen := make(map[quad.Value]struct{}, len(arr))
+	out := make([]quad.Value, 0, len(arr))
+	for _, v := range arr {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	sortValues(out)
+	return out
+}
@@ -0,0 +1,323 @@
+package inference_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/inference"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/owl"
+	"github.com/cayleygraph/cayley/voc/rdf"
+	"github.com/cayleygraph/cayley/voc/rdfs"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+// types are written in the full form, the same way as loaders and query languages do it
+var typ = quad.IRI(rdf.Type).Full()
+
+func iri(s string) quad.IRI { return quad.IRI(s) }
+
+var schemaQuads = []quad.Quad{
+	quad.MakeIRI("Dog", rdfs.SubClassOf, "Mammal", ""),
+	quad.MakeIRI("Cat", rdfs.SubClassOf, "Mammal", ""),
+	quad.MakeIRI("Mammal", rdfs.SubClassOf, "Animal", ""),
+	quad.MakeIRI("owns", rdfs.Domain, "Person", ""),
+	quad.MakeIRI("owns", rdfs.Range, "Pet", ""),
+	quad.MakeIRI("hasChild", rdfs.SubPropertyOf, "hasRelative", ""),
+	quad.MakeIRI("hasSon", rdfs.SubPropertyOf, "hasChild", ""),
+	quad.MakeIRI("hasRelative", rdfs.Domain, "Person", ""),
+}
+
+var dataQuads = []quad.Quad{
+	quad.MakeIRI("rex", string(typ), "Dog", ""),
+	quad.MakeIRI("tom", string(typ), "Cat", ""),
+	quad.MakeIRI("nemo", string(typ), "Fish", ""),
+	quad.MakeIRI("alice", "owns", "rex", ""),
+	quad.MakeIRI("bob", "hasSon", "carol", ""),
+}
+
+func run(t testing.TB, qs graph.QuadStore, p *path.Path) []quad.Value {
+	vals, err := p.Iterate(context.TODO()).AllValues(qs)
+	require.NoError(t, err)
+	return vals
+}
+
+func unique(vals []quad.Value) []quad.Value {
+	seen := make(map[quad.Value]struct{})
+	var out []quad.Value
+	for _, v := range vals {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func TestSchema(t *testing.T) {
+	s := inference.NewSchema(false)
+	require.True(t, s.Empty())
+	for _, q := range schemaQuads {
+		require.True(t, s.Set(q, true))
+	}
+	require.False(t, s.Set(dataQuads[0], true))
+	require.False(t, s.Empty())
+
+	require.Equal(t, []quad.Value{iri("Animal"), iri("Mammal")}, s.SuperClasses(iri("Dog")))
+	require.Equal(t, []quad.Value{iri("Cat"), iri("Dog"), iri("Mammal")}, s.SubClasses(iri("Animal")))
+	require.Equal(t, []quad.Value{iri("hasChild"), iri("hasSon")}, s.SubProperties(iri("hasRelative")))
+
+	require.ElementsMatch(t, []quad.Quad{
+		{Subject: iri("bob"), Predicate: iri("hasChild"), Object: iri("carol")},
+		{Subject: iri("bob"), Predicate: iri("hasRelative"), Object: iri("carol")},
+		{Subject: iri("bob"), Predicate: typ, Object: iri("Person")},
+	}, s.Entail(dataQuads[4]))
+	require.ElementsMatch(t, []quad.Quad{
+		{Subject: iri("rex"), Predicate: typ, Object: iri("Mammal")},
+		{Subject: iri("rex"), Predicate: typ, Object: iri("Animal")},
+	}, s.Entail(dataQuads[0]))
+
+	require.True(t, s.Set(schemaQuads[2], false))
+	require.Empty(t, s.SuperClasses(iri("Mammal")))
+	require.Equal(t, []quad.Value{iri("Mammal")}, s.SuperClasses(iri("Dog")))
+
+	// cycles are allowed
+	s.Set(quad.MakeIRI("Mammal", rdfs.SubClassOf, "Dog", ""), true)
+	require.Equal(t, []quad.Value{iri("Dog"), iri("Mammal")}, s.SuperClasses(iri("Dog")))
+
+	// IRIs are compared in the full form
+	s.Set(quad.MakeIRI("Dog", rdfs.SubClassOf, rdfs.Class, ""), true)
+	require.Equal(t, []quad.Value{iri("Dog"), iri("Mammal"), quad.IRI(rdfs.Class).Full()},
+		s.SuperClasses(iri("Dog")))
+	require.True(t, s.Set(quad.Quad{Subject: iri("Dog"), Predicate: quad.IRI(rdfs.SubClassOf).Full(), Object: quad.IRI(rdfs.Class)}, false))
+	require.Equal(t, []quad.Value{iri("Dog"), iri("Mammal")}, s.SuperClasses(iri("Dog")))
+}
+
+func TestParseStrategy(t *testing.T) {
+	st, err := inference.ParseStrategy("")
+	require.NoError(t, err)
+	require.Equal(t, inference.DefaultStrategy, st)
+	st, err = inference.ParseStrategy("materialize")
+	require.NoError(t, err)
+	require.Equal(t, inference.Materialize, st)
+	_, err = inference.ParseStrategy("owl")
+	require.Error(t, err)
+}
+
+func TestStore(t *testing.T) {
+	for _, st := range []inference.Strategy{inference.Materialize, inference.Rewrite} {
+		t.Run(string(st), func(t *testing.T) {
+			mem := memstore.New(schemaQuads...)
+			qs, err := inference.New(mem, inference.Config{Strategy: st})
+			require.NoError(t, err)
+			defer qs.Close()
+			qw, err := graph.NewQuadWriter("single", qs, nil)
+			require.NoError(t, err)
+			require.NoError(t, qw.AddQuadSet(dataQuads))
+
+			instances := func(class string) []quad.Value {
+				return unique(run(t, qs, path.StartPath(qs, iri(class)).In(typ)))
+			}
+			require.ElementsMatch(t, []quad.Value{iri("rex"), iri("tom")}, instances("Animal"))
+			require.ElementsMatch(t, []quad.Value{iri("rex"), iri("tom")}, instances("Mammal"))
+			require.ElementsMatch(t, []quad.Value{iri("rex")}, instances("Dog"))
+			require.ElementsMatch(t, []quad.Value{iri("alice"), iri("bob")}, instances("Person"))
+			require.ElementsMatch(t, []quad.Value{iri("rex")}, instances("Pet"))
+			require.ElementsMatch(t, []quad.Value{iri("rex")},
+				unique(run(t, qs, path.StartPath(qs).Has(typ, iri("Pet")).Has(typ, iri("Mammal")))))
+			require.ElementsMatch(t, []quad.Value{iri("carol")},
+				run(t, qs, path.StartPath(qs, iri("bob")).Out(iri("hasRelative"))))
+			require.ElementsMatch(t, []quad.Value{iri("bob")},
+				run(t, qs, path.StartPath(qs, iri("carol")).In(iri("hasChild"))))
+
+			// schema changes apply to the existing data
+			require.NoError(t, qw.AddQuad(quad.MakeIRI("Fish", rdfs.SubClassOf, "Animal", "")))
+			require.ElementsMatch(t, []quad.Value{iri("rex"), iri("tom"), iri("nemo")}, instances("Animal"))
+
+			require.NoError(t, qw.RemoveQuad(dataQuads[3]))
+			require.Empty(t, instances("Pet"))
+			require.ElementsMatch(t, []quad.Value{iri("bob")}, instances("Person"))
+
+			require.NoError(t, qw.RemoveQuad(schemaQuads[2]))
+			require.ElementsMatch(t, []quad.Value{iri("nemo")}, instances("Animal"))
+		})
+	}
+}
+
+func TestMaterialize(t *testing.T) {
+	mem := memstore.New(append(schemaQuads, dataQuads...)...)
+	// existing data is materialized when the store is opened
+	qs, err := inference.New(mem, inference.Config{Strategy: inference.Materialize})
+	require.NoError(t, err)
+	defer qs.Close()
+
+	types := run(t, qs, path.StartPath(qs, iri("rex")).Out(typ))
+	require.ElementsMatch(t, []quad.Value{iri("Dog"), iri("Mammal"), iri("Animal"), iri("Pet")}, types)
+	inferred := run(t, qs, path.StartPath(qs).LabelContext(inference.DefaultLabel).Out(typ))
+	require.Len(t, inferred, 7) // rex: Mammal, Animal, Pet; tom: Mammal, Animal; alice, bob: Person
+	size := qs.Size()
+
+	// asserted links are not duplicated
+	qw, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("rex", string(typ), "Mammal", "")))
+	require.Equal(t, size, qs.Size())
+
+	require.NoError(t, qw.RemoveQuad(dataQuads[0]))
+	types = run(t, qs, path.StartPath(qs, iri("rex")).Out(typ))
+	require.ElementsMatch(t, []quad.Value{iri("Mammal"), iri("Animal"), iri("Pet")}, types)
+}
+
+func TestRewrite(t *testing.T) {
+	mem := memstore.New(append(schemaQuads, dataQuads...)...)
+	size := mem.Size()
+	qs, err := inference.New(mem, inference.Config{Strategy: inference.Rewrite})
+	require.NoError(t, err)
+	defer qs.Close()
+	require.Equal(t, size, qs.Size())
+
+	// only asserted types are returned for a node
+	types := run(t, qs, path.StartPath(qs, iri("rex")).Out(typ))
+	require.ElementsMatch(t, []quad.Value{iri("Dog")}, types)
+	// instances of classes include subclasses
+	require.ElementsMatch(t, []quad.Value{iri("rex"), iri("tom")},
+		run(t, qs, path.StartPath(qs, iri("Animal")).In(typ)))
+	// original store is not affected
+	require.Empty(t, run(t, mem, path.StartPath(mem, iri("Animal")).In(typ)))
+}
+
+// shapeRecorder is a backend that records shapes passed to it for optimization.
+type shapeRecorder struct {
+	graph.QuadStore
+	shapes []shape.Shape
+}
+
+func (r *shapeRecorder) OptimizeShape(s shape.Shape) (shape.Shape, bool) {
+	r.shapes = append(r.shapes, s)
+	return s, false
+}
+
+func TestRewritePushdown(t *testing.T) {
+	mem := memstore.New(append(schemaQuads, dataQuads...)...)
+	rec := &shapeRecorder{QuadStore: mem}
+	qs, err := inference.New(rec, inference.Config{Strategy: inference.Rewrite})
+	require.NoError(t, err)
+	defer qs.Close()
+
+	p := path.StartPath(qs, iri("Animal")).In(typ)
+	_, _ = shape.Optimize(p.Shape(), qs)
+
+	// subclasses are expanded to a single lookup on the object instead of a union of lookups for each class
+	var classes []quad.Value
+	for _, s := range rec.shapes {
+		nf, ok := s.(shape.NodesFrom)
+		if !ok {
+			continue
+		}
+		q, ok := nf.Quads.(shape.Quads)
+		require.True(t, ok, "expected a quads lookup, got %#v", nf.Quads)
+		for _, f := range q {
+			if f.Dir != quad.Object {
+				continue
+			}
+			fx, ok := f.Values.(shape.Fixed)
+			require.True(t, ok, "expected fixed objects, got %#v", f.Values)
+			for _, v := range fx {
+				classes = append(classes, mem.NameOf(v))
+			}
+		}
+	}
+	require.ElementsMatch(t, []quad.Value{iri("Animal"), iri("Mammal"), iri("Dog"), iri("Cat")}, classes)
+	for _, s := range rec.shapes {
+		_, union := s.(shape.Union)
+		require.False(t, union, "subclasses should not be queried separately")
+	}
+	require.ElementsMatch(t, []quad.Value{iri("rex"), iri("tom")}, run(t, qs, p))
+}
+
+var owlQuads = []quad.Quad{
+	quad.MakeIRI("hasParent", owl.InverseOf, "hasChild", ""),
+	quad.MakeIRI("knows", string(typ), owl.SymmetricProperty, ""),
+	quad.MakeIRI("ancestorOf", string(typ), owl.TransitiveProperty, ""),
+	quad.MakeIRI("alice", owl.SameAs, "alice2", ""),
+	quad.MakeIRI("alice", "hasChild", "bob", ""),
+	quad.MakeIRI("alice", "knows", "carol", ""),
+	quad.MakeIRI("a", "ancestorOf", "b", ""),
+	quad.MakeIRI("b", "ancestorOf", "c", ""),
+	quad.MakeIRI("c", "ancestorOf", "d", ""),
+	{Subject: iri("alice2"), Predicate: iri("email"), Object: quad.String("alice@example.com")},
+}
+
+func TestSchemaOWL(t *testing.T) {
+	s := inference.NewSchema(true)
+	for _, q := range owlQuads[:4] {
+		require.True(t, s.Set(q, true))
+	}
+	require.False(t, s.Empty())
+	require.Equal(t, []quad.Value{iri("ancestorOf")}, s.Transitive())
+	require.ElementsMatch(t, []quad.Value{iri("alice"), iri("alice2")}, s.Equivalents(iri("alice2")))
+	require.Nil(t, s.Equivalents(iri("bob")))
+
+	require.ElementsMatch(t, []quad.Quad{
+		{Subject: iri("bob"), Predicate: iri("hasParent"), Object: iri("alice")},
+	}, s.Entail(owlQuads[4]))
+	require.ElementsMatch(t, []quad.Quad{
+		{Subject: iri("carol"), Predicate: iri("knows"), Object: iri("alice")},
+	}, s.Entail(owlQuads[5]))
+
+	s.Set(quad.MakeIRI("alice2", owl.SameAs, "alice3", ""), true)
+	require.ElementsMatch(t, []quad.Value{iri("alice"), iri("alice2"), iri("alice3")}, s.Equivalents(iri("alice")))
+	s.Set(owlQuads[3], false)
+	require.Nil(t, s.Equivalents(iri("alice")))
+	require.ElementsMatch(t, []quad.Value{iri("alice2"), iri("alice3")}, s.Equivalents(iri("alice3")))
+
+	// OWL rules are ignored, unless enabled
+	require.False(t, inference.NewSchema(false).Set(owlQuads[0], true))
+}
+
+func TestStoreOWL(t *testing.T) {
+	for _, st := range []inference.Strategy{inference.Materialize, inference.Rewrite} {
+		t.Run(string(st), func(t *testing.T) {
+			qs, err := inference.New(memstore.New(), inference.Config{Strategy: st, OWL: true})
+			require.NoError(t, err)
+			defer qs.Close()
+			qw, err := graph.NewQuadWriter("single", qs, nil)
+			require.NoError(t, err)
+			require.NoError(t, qw.AddQuadSet(owlQuads))
+
+			out := func(node, pred string) []quad.Value {
+				return run(t, qs, path.StartPath(qs, iri(node)).Out(iri(pred)))
+			}
+			require.ElementsMatch(t, []quad.Value{iri("alice")}, out("bob", "hasParent"))
+			require.ElementsMatch(t, []quad.Value{iri("alice")}, out("carol", "knows"))
+			require.ElementsMatch(t, []quad.Value{iri("carol")}, out("alice", "knows"))
+			require.ElementsMatch(t, []quad.Value{iri("bob")},
+				run(t, qs, path.StartPath(qs, iri("alice")).In(iri("hasParent"))))
+			// equal nodes share links
+			require.ElementsMatch(t, []quad.Value{quad.String("alice@example.com")}, out("alice", "email"))
+			require.ElementsMatch(t, []quad.Value{iri("bob")}, out("alice2", "hasChild"))
+
+			require.NoError(t, qw.RemoveQuad(owlQuads[4]))
+			require.Empty(t, out("bob", "hasParent"))
+
+			if st != inference.Materialize {
+				// transitive properties are only materialized
+				require.ElementsMatch(t, []quad.Value{iri("b")}, out("a", "ancestorOf"))
+				return
+			}
+			require.ElementsMatch(t, []quad.Value{iri("b"), iri("c"), iri("d")}, out("a", "ancestorOf"))
+			require.NoError(t, qw.RemoveQuad(owlQuads[7]))
+			require.ElementsMatch(t, []quad.Value{iri("b")}, out("a", "ancestorOf"))
+			require.ElementsMatch(t, []quad.Value{iri("d")}, out("c", "ancestorOf"))
+			require.NoError(t, qw.AddQuadSet([]quad.Quad{owlQuads[7], quad.MakeIRI("d", "ancestorOf", "e", "")}))
+			require.ElementsMatch(t, []quad.Value{iri("b"), iri("c"), iri("d"), iri("e")}, out("a", "ancestorOf"))
+			require.ElementsMatch(t, []quad.Value{iri("d"), iri("e")}, out("c", "ancestorOf"))
+		})
+	}
+}
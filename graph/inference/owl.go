@@ -0,0 +1,178 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inference
+
+import (
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/owl"
+)
+
+var (
+	inverseOfIRI          = quad.IRI(owl.InverseOf).Full()
+	sameAsIRI             = quad.IRI(owl.SameAs).Full()
+	transitivePropertyIRI = quad.IRI(owl.TransitiveProperty).Full()
+	symmetricPropertyIRI  = quad.IRI(owl.SymmetricProperty).Full()
+)
+
+// owlPredicates is a list of predicates that define OWL rules, in addition to property declarations.
+var owlPredicates = []quad.IRI{inverseOfIRI, sameAsIRI}
+
+// owlClasses is a list of classes of properties that define OWL rules.
+var owlClasses = []quad.IRI{transitivePropertyIRI, symmetricPropertyIRI}
+
+// isOWL checks if a link with a given predicate and object defines one of supported OWL rules.
+func isOWL(p quad.IRI, obj quad.Value) bool {
+	switch p {
+	case inverseOfIRI, sameAsIRI:
+		return true
+	case typeIRI:
+		o := norm(obj)
+		return o == transitivePropertyIRI || o == symmetricPropertyIRI
+	}
+	return false
+}
+
+// isSameAs checks if a quad links equal nodes.
+func isSameAs(q quad.Quad) bool {
+	p, ok := q.Predicate.(quad.IRI)
+	return ok && p.Full() == sameAsIRI
+}
+
+func setFlag(m map[quad.Value]struct{}, v quad.Value, ok bool) {
+	if ok {
+		m[v] = struct{}{}
+	} else {
+		delete(m, v)
+	}
+}
+
+// setOWL adds or removes a link that defines OWL rules. Values must be normalized. Lock must be held.
+func (s *Schema) setOWL(sub quad.Value, p quad.IRI, obj quad.Value, ok bool) {
+	switch p {
+	case inverseOfIRI:
+		s.inverse.set(sub, obj, ok)
+		s.inverseRev.set(obj, sub, ok)
+	case sameAsIRI:
+		s.same.set(sub, obj, ok)
+		s.sameRev.set(obj, sub, ok)
+		s.updateEquivalent(sub)
+		s.updateEquivalent(obj)
+	case typeIRI:
+		switch obj {
+		case transitivePropertyIRI:
+			setFlag(s.transitive, sub, ok)
+		case symmetricPropertyIRI:
+			setFlag(s.symmetric, sub, ok)
+		}
+	}
+}
+
+// updateEquivalent updates the equivalence class of a node in the index. Lock must be held.
+func (s *Schema) updateEquivalent(v quad.Value) {
+	nodes := []quad.Value{v}
+	// sameAs is symmetric, thus both directions are followed
+	for i := 0; i < len(nodes); i++ {
+		for _, r := range []relation{s.same, s.sameRev} {
+			for n := range r[nodes[i]] {
+				if !contains(nodes, n) {
+					nodes = append(nodes, n)
+				}
+			}
+		}
+	}
+	if len(nodes) == 1 {
+		delete(s.eq, v)
+		return
+	}
+	sortValues(nodes)
+	for _, n := range nodes {
+		s.eq[n] = nodes
+	}
+}
+
+func contains(arr []quad.Value, v quad.Value) bool {
+	for _, a := range arr {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
+
+// reversed returns links entailed by inverse and symmetric properties. Lock must be held.
+func (s *Schema) reversed(l quad.Quad) []quad.Quad {
+	var out []quad.Quad
+	for _, p := range s.inversesOf(l.Predicate) {
+		out = append(out, quad.Quad{Subject: l.Object, Predicate: p, Object: l.Subject})
+	}
+	if _, ok := s.symmetric[l.Predicate]; ok {
+		out = append(out, quad.Quad{Subject: l.Object, Predicate: l.Predicate, Object: l.Subject})
+	}
+	return out
+}
+
+// inversesOf returns properties that are inverse of a given one. Lock must be held.
+func (s *Schema) inversesOf(p quad.Value) []quad.Value {
+	if len(s.inverse[p]) == 0 && len(s.inverseRev[p]) == 0 {
+		return nil
+	}
+	out := keys(s.inverse[p])
+	for v := range s.inverseRev[p] {
+		if !contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Equivalents returns all nodes that are linked to a given one with owl:sameAs, including the node itself.
+// It returns nil if there are no such links.
+func (s *Schema) Equivalents(v quad.Value) []quad.Value {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	nodes := s.eq[norm(v)]
+	if len(nodes) == 0 {
+		return nil
+	}
+	return append([]quad.Value{}, nodes...)
+}
+
+// hasEquivalents checks if there are any owl:sameAs links.
+func (s *Schema) hasEquivalents() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.eq) != 0
+}
+
+// Transitive returns all properties declared as transitive.
+func (s *Schema) Transitive() []quad.Value {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return keys(s.transitive)
+}
+
+// reversible returns properties that are inverse of given ones, and the symmetric properties among them.
+func (s *Schema) reversible(props []quad.Value) []quad.Value {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []quad.Value
+	for _, p := range props {
+		out = append(out, s.inversesOf(p)...)
+		if _, ok := s.symmetric[p]; ok {
+			out = append(out, p)
+		}
+	}
+	return dedup(out)
+}
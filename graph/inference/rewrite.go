@@ -0,0 +1,209 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inference
+
+import (
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// RewriteShape implements shape.Rewriter. With the Rewrite strategy, it expands constraints on properties
+// with their subproperties, and constraints on classes of nodes with subclasses, domains and ranges.
+// If OWL rules are enabled, links with inverse and symmetric properties are followed in both directions,
+// and constraints on nodes are expanded with equal nodes regardless of the strategy.
+func (s *Store) RewriteShape(sh shape.Shape) (shape.Shape, bool) {
+	nf, ok := sh.(shape.NodesFrom)
+	if !ok {
+		return sh, false
+	}
+	q, ok := nf.Quads.(shape.Quads)
+	if !ok {
+		return sh, false
+	}
+	var opt bool
+	if s.sch.hasEquivalents() {
+		q, opt = s.expandEquivalents(q)
+	}
+	if s.strategy == Rewrite && nf.Dir != quad.Predicate {
+		// with the predicate direction, results would include subproperties instead of the requested ones
+		ns, nopt := s.rewriteNodes(shape.NodesFrom{Dir: nf.Dir, Quads: q}, q)
+		return ns, opt || nopt
+	}
+	if !opt {
+		return sh, false
+	}
+	return shape.NodesFrom{Dir: nf.Dir, Quads: q}, true
+}
+
+// expandEquivalents adds equal nodes to constraints on subjects and objects.
+func (s *Store) expandEquivalents(q shape.Quads) (shape.Quads, bool) {
+	var nq shape.Quads
+	for i, f := range q {
+		if f.Dir != quad.Subject && f.Dir != quad.Object {
+			continue
+		}
+		fx, ok := f.Values.(shape.Fixed)
+		if !ok {
+			continue
+		}
+		nodes := s.names(fx)
+		var eq []quad.Value
+		for _, n := range nodes {
+			eq = append(eq, s.sch.Equivalents(n)...)
+		}
+		if len(eq) == 0 {
+			continue
+		}
+		if nq == nil {
+			nq = make(shape.Quads, len(q))
+			copy(nq, q)
+		}
+		nq[i].Values = s.fixed(append(nodes, eq...))
+	}
+	if nq == nil {
+		return q, false
+	}
+	return nq, true
+}
+
+// names returns values of fixed nodes. IRIs are expanded to the full form.
+func (s *Store) names(f shape.Fixed) []quad.Value {
+	out := make([]quad.Value, 0, len(f))
+	for _, v := range f {
+		if n := s.QuadStore.NameOf(v); n != nil {
+			out = append(out, norm(n))
+		}
+	}
+	return out
+}
+
+// fixed returns a set of nodes that exist in the store.
+func (s *Store) fixed(vals []quad.Value) shape.Fixed {
+	out := make(shape.Fixed, 0, len(vals))
+	for _, v := range dedup(vals) {
+		out = append(out, s.valuesOf(v)...)
+	}
+	return out
+}
+
+func (s *Store) rewriteNodes(nf shape.NodesFrom, q shape.Quads) (shape.Shape, bool) {
+	pi, oi := -1, -1
+	other := false // other filters on predicate or object
+	for i, f := range q {
+		if f.Dir != quad.Predicate && f.Dir != quad.Object {
+			continue
+		}
+		_, ok := f.Values.(shape.Fixed)
+		switch {
+		case ok && f.Dir == quad.Predicate && pi < 0:
+			pi = i
+		case ok && f.Dir == quad.Object && oi < 0:
+			oi = i
+		default:
+			other = true
+		}
+	}
+	if pi < 0 {
+		return nf, false
+	}
+	var (
+		preds = s.names(q[pi].Values.(shape.Fixed))
+		nq    = q
+		opt   bool
+	)
+	realloc := func() {
+		if !opt {
+			opt = true
+			nq = make(shape.Quads, len(q))
+			copy(nq, q)
+		}
+	}
+	props := preds
+	if sub := s.sch.SubProperties(preds...); len(sub) != 0 {
+		realloc()
+		props = append(preds, sub...)
+		nq[pi].Values = s.fixed(props)
+	}
+	var out shape.Union
+	if rev := s.sch.reversible(props); len(rev) != 0 && (nf.Dir == quad.Subject || nf.Dir == quad.Object) {
+		// links with inverse properties in the opposite direction
+		rev = append(rev, s.sch.SubProperties(rev...)...)
+		out = append(out, shape.NodesFrom{Dir: opposite(nf.Dir), Quads: reverseLinks(q, pi, s.fixed(rev))})
+	}
+	if len(preds) == 1 && preds[0] == typeIRI && nf.Dir == quad.Subject && oi >= 0 {
+		// query for instances of classes
+		classes := s.names(q[oi].Values.(shape.Fixed))
+		if sub := s.sch.SubClasses(classes...); len(sub) != 0 {
+			realloc()
+			classes = append(classes, sub...)
+			nq[oi].Values = s.fixed(classes)
+		}
+		if !other {
+			dom, rng := s.sch.propertiesOf(classes)
+			if len(dom) != 0 {
+				out = append(out, shape.NodesFrom{Dir: quad.Subject, Quads: linksWith(q, pi, oi, s.fixed(dom), false)})
+			}
+			if len(rng) != 0 {
+				out = append(out, shape.NodesFrom{Dir: quad.Object, Quads: linksWith(q, pi, oi, s.fixed(rng), true)})
+			}
+		}
+	}
+	nodes := shape.NodesFrom{Dir: nf.Dir, Quads: nq}
+	if len(out) == 0 {
+		return nodes, opt
+	}
+	return append(shape.Union{nodes}, out...), true
+}
+
+// opposite returns the opposite direction of a link.
+func opposite(d quad.Direction) quad.Direction {
+	if d == quad.Subject {
+		return quad.Object
+	}
+	return quad.Subject
+}
+
+// reverseLinks converts a filter for links with some properties to a filter for links in the opposite direction.
+func reverseLinks(q shape.Quads, pi int, props shape.Fixed) shape.Quads {
+	out := make(shape.Quads, 0, len(q))
+	for i, f := range q {
+		switch {
+		case i == pi:
+			f.Values = props
+		case f.Dir == quad.Subject || f.Dir == quad.Object:
+			f.Dir = opposite(f.Dir)
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// linksWith converts a filter for rdf:type quads to a filter for quads with given properties.
+// If rev is set, the node that has the type is the object of the quad.
+func linksWith(q shape.Quads, pi, oi int, props shape.Fixed, rev bool) shape.Quads {
+	out := make(shape.Quads, 0, len(q)-1)
+	for i, f := range q {
+		switch {
+		case i == oi:
+			continue
+		case i == pi:
+			f.Values = props
+		case rev && f.Dir == quad.Subject:
+			f.Dir = quad.Object
+		}
+		out = append(out, f)
+	}
+	return out
+}
@@ -0,0 +1,455 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inference
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var (
+	_ graph.Wrapper   = (*Store)(nil)
+	_ shape.Optimizer = (*Store)(nil)
+	_ shape.Rewriter  = (*Store)(nil)
+)
+
+// rebuildBatch is a number of nodes updated at once during a rebuild.
+const rebuildBatch = 1000
+
+// Store is a QuadStore wrapper that applies RDFS entailments to the data, and optionally a subset of OWL 2 RL rules.
+//
+// Links between equal nodes (owl:sameAs) are never materialized. Instead, constraints on nodes in queries
+// are expanded with all equal nodes, regardless of the strategy.
+type Store struct {
+	graph.QuadStore
+	strategy Strategy
+	label    quad.Value
+	sch      *Schema
+
+	// mu serializes writes, so entailments are updated in the same order as the data
+	mu sync.Mutex
+}
+
+// New loads the schema from the QuadStore and wraps it to apply entailments.
+// With the Materialize strategy, entailments are written to the store if it has none.
+// Writes must go through the returned QuadStore for the schema and entailments to be updated.
+func New(qs graph.QuadStore, conf Config) (*Store, error) {
+	st, err := ParseStrategy(string(conf.Strategy))
+	if err != nil {
+		return nil, err
+	}
+	if conf.Label == nil {
+		conf.Label = DefaultLabel
+	}
+	s := &Store{QuadStore: qs, strategy: st, label: conf.Label, sch: NewSchema(conf.OWL)}
+	ctx := context.TODO()
+	if err = s.loadSchema(ctx); err != nil {
+		return nil, err
+	}
+	if st == Materialize && !s.sch.Empty() && qs.ValueOf(s.label) == nil {
+		clog.Infof("inference: materializing entailments")
+		if err = s.Rebuild(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Unwrap implements graph.Wrapper.
+func (s *Store) Unwrap() graph.QuadStore {
+	return s.QuadStore
+}
+
+// Strategy returns the strategy used by the store.
+func (s *Store) Strategy() Strategy {
+	return s.strategy
+}
+
+// Schema returns the schema loaded from the store.
+func (s *Store) Schema() *Schema {
+	return s.sch
+}
+
+// eachQuad calls a function for each quad with a given node in a specified direction.
+func (s *Store) eachQuad(ctx context.Context, d quad.Direction, v graph.Value, fnc func(q quad.Quad)) error {
+	it := s.QuadStore.QuadIterator(d, v)
+	defer it.Close()
+	for it.Next(ctx) {
+		fnc(s.QuadStore.Quad(it.Result()))
+	}
+	return it.Err()
+}
+
+// valuesOf returns references to a node in the store, in both full and short forms in case of IRIs.
+func (s *Store) valuesOf(v quad.Value) []graph.Value {
+	vals := []quad.Value{v}
+	if iri, ok := v.(quad.IRI); ok {
+		vals = []quad.Value{iri.Full()}
+		if short := iri.Short(); short != vals[0] {
+			vals = append(vals, short)
+		}
+	}
+	out := make([]graph.Value, 0, len(vals))
+	for _, v := range vals {
+		if gv := s.QuadStore.ValueOf(v); gv != nil {
+			out = append(out, gv)
+		}
+	}
+	return out
+}
+
+// loadSchema reads all schema quads from the store.
+func (s *Store) loadSchema(ctx context.Context) error {
+	load := func(d quad.Direction, v quad.IRI) error {
+		for _, gv := range s.valuesOf(v) {
+			err := s.eachQuad(ctx, d, gv, func(q quad.Quad) {
+				if q.Label != s.label {
+					s.sch.Set(q, true)
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	preds := schemaPredicates
+	if s.sch.owl {
+		preds = append(preds[:len(preds):len(preds)], owlPredicates...)
+		// property declarations are loaded by class
+		for _, c := range owlClasses {
+			if err := load(quad.Object, c); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range preds {
+		if err := load(quad.Predicate, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// asserted checks if a link between subject and object of the quad is still asserted by some other quad.
+func (s *Store) asserted(ctx context.Context, q quad.Quad) (bool, error) {
+	sv := s.QuadStore.ValueOf(q.Subject)
+	if sv == nil {
+		return false, nil
+	}
+	found := false
+	err := s.eachQuad(ctx, quad.Subject, sv, func(q2 quad.Quad) {
+		if q2.Label != s.label && norm(q2.Predicate) == norm(q.Predicate) && norm(q2.Object) == norm(q.Object) {
+			found = true
+		}
+	})
+	return found, err
+}
+
+// ApplyDeltas applies deltas to the underlying QuadStore and updates the schema.
+// With the Materialize strategy, entailments of affected nodes are updated as well.
+func (s *Store) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.QuadStore.ApplyDeltas(deltas, opts); err != nil {
+		return err
+	}
+	ctx := context.TODO()
+	changed := false
+	for _, d := range deltas {
+		if d.Quad.Label == s.label || !s.sch.Defines(d.Quad) {
+			continue
+		}
+		ok := d.Action == graph.Add
+		if !ok {
+			// the same link might be defined by quads with other labels
+			var err error
+			if ok, err = s.asserted(ctx, d.Quad); err != nil {
+				return err
+			}
+		}
+		s.sch.Set(d.Quad, ok)
+		// equal nodes are only applied to queries
+		changed = changed || !isSameAs(d.Quad)
+	}
+	if s.strategy != Materialize {
+		return nil
+	}
+	var err error
+	if changed {
+		clog.Infof("inference: schema was changed, materializing entailments")
+		err = s.rebuild(ctx)
+	} else {
+		var nodes []quad.Value
+		nodes, err = s.affected(ctx, deltas)
+		if err == nil {
+			err = s.reconcile(ctx, nodes)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("inference: data was written, but entailments were not updated: %v", err)
+	}
+	return nil
+}
+
+// affected returns nodes that may have their entailments changed by deltas.
+//
+// Nodes that are linked to changed ones with a transitive property are affected as well. Since the closure
+// of transitive properties is already materialized, these nodes are exactly the subjects of links to changed nodes.
+func (s *Store) affected(ctx context.Context, deltas []graph.Delta) ([]quad.Value, error) {
+	var (
+		nodes []quad.Value
+		seen  = make(map[string]struct{})
+	)
+	add := func(v quad.Value) {
+		key := quad.StringOf(v)
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		nodes = append(nodes, v)
+	}
+	for _, d := range deltas {
+		if d.Quad.Label == s.label {
+			continue
+		}
+		add(d.Quad.Subject)
+		if isNode(d.Quad.Object) {
+			add(d.Quad.Object)
+		}
+	}
+	trans := s.sch.Transitive()
+	if len(trans) == 0 {
+		return nodes, nil
+	}
+	for _, n := range nodes[:len(nodes):len(nodes)] {
+		gv := s.QuadStore.ValueOf(n)
+		if gv == nil {
+			continue
+		}
+		err := s.eachQuad(ctx, quad.Object, gv, func(q quad.Quad) {
+			if contains(trans, norm(q.Predicate)) {
+				add(q.Subject)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// tripleKey returns a key of a quad that ignores the label and the form of IRIs.
+func tripleKey(q quad.Quad) string {
+	return quad.StringOf(norm(q.Subject)) + " " + quad.StringOf(norm(q.Predicate)) + " " + quad.StringOf(norm(q.Object))
+}
+
+// nodeLinks is a set of links of a single node.
+type nodeLinks struct {
+	asserted map[string]struct{}  // asserted links with the node as a subject
+	inferred map[string]quad.Quad // materialized links with the node as a subject
+	links    []quad.Quad          // asserted and entailed links with the node as a subject, except transitive closure
+}
+
+// linksOf reads links of a node and applies entailments to them.
+func (s *Store) linksOf(ctx context.Context, n quad.Value) (*nodeLinks, error) {
+	nl := &nodeLinks{
+		asserted: make(map[string]struct{}),
+		inferred: make(map[string]quad.Quad),
+	}
+	gv := s.QuadStore.ValueOf(n)
+	if gv == nil {
+		return nl, nil
+	}
+	var entailed []quad.Quad
+	err := s.eachQuad(ctx, quad.Subject, gv, func(q quad.Quad) {
+		if q.Label == s.label {
+			nl.inferred[tripleKey(q)] = q
+			return
+		}
+		nl.asserted[tripleKey(q)] = struct{}{}
+		nl.links = append(nl.links, q)
+		entailed = append(entailed, s.sch.Entail(q)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// ranges, inverse and symmetric properties are entailed by links to the node
+	err = s.eachQuad(ctx, quad.Object, gv, func(q quad.Quad) {
+		if q.Label != s.label {
+			entailed = append(entailed, s.sch.Entail(q)...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	key := quad.StringOf(n)
+	for _, q := range entailed {
+		if quad.StringOf(q.Subject) == key {
+			nl.links = append(nl.links, q)
+		}
+	}
+	return nl, nil
+}
+
+// closure returns links of a node with transitive properties to all reachable nodes.
+// Links of other nodes are cached.
+func (s *Store) closure(ctx context.Context, n quad.Value, nl *nodeLinks, trans []quad.Value, cache map[string]*nodeLinks) ([]quad.Quad, error) {
+	var out []quad.Quad
+	for _, p := range trans {
+		var (
+			next []quad.Value
+			seen = make(map[string]struct{})
+		)
+		follow := func(l *nodeLinks) {
+			for _, q := range l.links {
+				if norm(q.Predicate) != p || !isNode(q.Object) {
+					continue
+				}
+				k := quad.StringOf(q.Object)
+				if _, ok := seen[k]; !ok {
+					seen[k] = struct{}{}
+					next = append(next, q.Object)
+				}
+			}
+		}
+		follow(nl)
+		for i := 0; i < len(next); i++ {
+			m := next[i]
+			out = append(out, quad.Quad{Subject: n, Predicate: p, Object: m})
+			k := quad.StringOf(m)
+			ml, ok := cache[k]
+			if !ok {
+				var err error
+				if ml, err = s.linksOf(ctx, m); err != nil {
+					return nil, err
+				}
+				cache[k] = ml
+			}
+			follow(ml)
+		}
+	}
+	return out, nil
+}
+
+// reconcile writes entailed quads with a subject in the list of nodes and removes quads that are no longer entailed.
+func (s *Store) reconcile(ctx context.Context, nodes []quad.Value) error {
+	if s.sch.Empty() && s.QuadStore.ValueOf(s.label) == nil {
+		return nil
+	}
+	var (
+		deltas []graph.Delta
+		trans  = s.sch.Transitive()
+		cache  = make(map[string]*nodeLinks)
+	)
+	for _, n := range nodes {
+		nl, err := s.linksOf(ctx, n)
+		if err != nil {
+			return err
+		}
+		entailed := nl.links
+		if len(trans) != 0 {
+			links, err := s.closure(ctx, n, nl, trans, cache)
+			if err != nil {
+				return err
+			}
+			for _, q := range links {
+				entailed = append(entailed, q)
+				for _, e := range s.sch.Entail(q) {
+					if e.Subject == q.Subject {
+						entailed = append(entailed, e)
+					}
+				}
+			}
+		}
+		want := make(map[string]struct{}, len(entailed))
+		for _, q := range entailed {
+			k := tripleKey(q)
+			if _, ok := nl.asserted[k]; ok {
+				continue
+			} else if _, ok = want[k]; ok {
+				continue
+			}
+			want[k] = struct{}{}
+			if _, ok := nl.inferred[k]; ok {
+				continue
+			}
+			q.Label = s.label
+			deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Add})
+		}
+		for k, q := range nl.inferred {
+			if _, ok := want[k]; !ok {
+				deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Delete})
+			}
+		}
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+	return s.QuadStore.ApplyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true})
+}
+
+// Rebuild updates entailments of all nodes in the store. It is only useful for the Materialize strategy,
+// in case the data was written without the inference layer. Writes are blocked during the rebuild.
+func (s *Store) Rebuild(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rebuild(ctx)
+}
+
+func (s *Store) rebuild(ctx context.Context) error {
+	if s.sch.Empty() && s.QuadStore.ValueOf(s.label) == nil {
+		return nil
+	}
+	// collect nodes first, since the store is modified during the rebuild
+	var nodes []quad.Value
+	it := s.QuadStore.NodesAllIterator()
+	for it.Next(ctx) {
+		nodes = append(nodes, s.QuadStore.NameOf(it.Result()))
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		return err
+	}
+	for len(nodes) != 0 {
+		n := rebuildBatch
+		if n > len(nodes) {
+			n = len(nodes)
+		}
+		if err = s.reconcile(ctx, nodes[:n]); err != nil {
+			return err
+		}
+		nodes = nodes[n:]
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OptimizeShape passes shape optimization to the underlying QuadStore, if supported.
+func (s *Store) OptimizeShape(sh shape.Shape) (shape.Shape, bool) {
+	if o, ok := s.QuadStore.(shape.Optimizer); ok {
+		return o.OptimizeShape(sh)
+	}
+	return sh, false
+}
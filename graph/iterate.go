@@ -9,6 +9,9 @@ import (
 	"github.com/cayleygraph/cayley/quad"
 )
 
+// iterateBatch is a number of results requested from the root iterator at once if sub-paths are disabled.
+const iterateBatch = 64
+
 // IterateChain is a chain-enabled helper to setup iterator execution.
 type IterateChain struct {
 	ctx context.Context
@@ -20,6 +23,9 @@ type IterateChain struct {
 
 	limit int
 	n     int
+
+	endSpan func(error)
+	onClose []func()
 }
 
 // Iterate is a set of helpers for iteration. Context may be used to cancel execution.
@@ -60,13 +66,53 @@ func (c *IterateChain) nextPath() bool {
 	}
 	return ok
 }
+
+// nextBatch returns next results of the root iterator, up to len(buf). It is used instead of next
+// if sub-paths are disabled, so the iterator may amortize the cost of fetching results.
+func (c *IterateChain) nextBatch(buf []Value) []Value {
+	select {
+	case <-c.ctx.Done():
+		return nil
+	default:
+	}
+	if c.limit >= 0 && c.limit-c.n < len(buf) {
+		buf = buf[:c.limit-c.n]
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	// error is checked by the caller
+	n, _ := NextBatch(c.ctx, c.it, buf)
+	c.n += n
+	return buf[:n]
+}
+
+// eachBatch calls fnc for each result of the root iterator until it returns false.
+func (c *IterateChain) eachBatch(fnc func(Value) bool) {
+	buf := make([]Value, iterateBatch)
+	for {
+		vals := c.nextBatch(buf)
+		if len(vals) == 0 {
+			return
+		}
+		for _, v := range vals {
+			if !fnc(v) {
+				return
+			}
+		}
+	}
+}
+
 func (c *IterateChain) start() {
 	if c.optimize {
+		_, end := StartSpan(c.ctx, "optimize")
 		c.it, _ = c.it.Optimize()
 		if c.qs != nil {
 			c.it, _ = c.qs.OptimizeIterator(c.it)
 		}
+		end(nil)
 	}
+	c.ctx, c.endSpan = StartSpan(c.ctx, "iterate")
 	if !clog.V(2) {
 		return
 	}
@@ -77,7 +123,11 @@ func (c *IterateChain) start() {
 	}
 }
 func (c *IterateChain) end() {
+	c.endSpan(c.it.Err())
 	c.it.Close()
+	for _, fnc := range c.onClose {
+		fnc()
+	}
 	if !clog.V(2) {
 		return
 	}
@@ -88,6 +138,12 @@ func (c *IterateChain) end() {
 	}
 }
 
+// OnClose registers a function that is called after the iteration ends and the iterator is closed.
+func (c *IterateChain) OnClose(fnc func()) *IterateChain {
+	c.onClose = append(c.onClose, fnc)
+	return c
+}
+
 // Limit limits a total number of results returned.
 func (c *IterateChain) Limit(n int) *IterateChain {
 	c.limit = n
@@ -119,6 +175,17 @@ func (c *IterateChain) Each(fnc func(Value)) error {
 	defer c.end()
 	done := c.ctx.Done()
 
+	if !c.paths {
+		c.eachBatch(func(v Value) bool {
+			fnc(v)
+			return true
+		})
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		return c.it.Err()
+	}
+
 	for c.next() {
 		select {
 		case <-done:
@@ -148,8 +215,15 @@ func (c *IterateChain) Count() (int64, error) {
 	if size, exact := c.it.Size(); exact {
 		return size, nil
 	}
-	done := c.ctx.Done()
 	var cnt int64
+	if !c.paths {
+		c.eachBatch(func(Value) bool {
+			cnt++
+			return true
+		})
+		return cnt, c.it.Err()
+	}
+	done := c.ctx.Done()
 iteration:
 	for c.next() {
 		select {
@@ -174,8 +248,15 @@ iteration:
 func (c *IterateChain) All() ([]Value, error) {
 	c.start()
 	defer c.end()
-	done := c.ctx.Done()
 	var out []Value
+	if !c.paths {
+		c.eachBatch(func(v Value) bool {
+			out = append(out, v)
+			return true
+		})
+		return out, c.it.Err()
+	}
+	done := c.ctx.Done()
 iteration:
 	for c.next() {
 		select {
@@ -213,6 +294,20 @@ func (c *IterateChain) Send(out chan<- Value) error {
 	c.start()
 	defer c.end()
 	done := c.ctx.Done()
+	if !c.paths {
+		c.eachBatch(func(v Value) bool {
+			select {
+			case <-done:
+				return false
+			case out <- v:
+				return true
+			}
+		})
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		return c.it.Err()
+	}
 	for c.next() {
 		select {
 		case <-done:
@@ -277,11 +372,8 @@ func (c *IterateChain) EachValue(qs QuadStore, fnc func(quad.Value)) error {
 	if c.qs == nil {
 		return errNoQuadStore
 	}
-	// TODO(dennwc): batch NameOf?
-	return c.Each(func(v Value) {
-		if nv := c.qs.NameOf(v); nv != nil {
-			fnc(nv)
-		}
+	return c.eachValue(func(_ Value, nv quad.Value) {
+		fnc(nv)
 	})
 }
 
@@ -294,12 +386,42 @@ func (c *IterateChain) EachValuePair(qs QuadStore, fnc func(Value, quad.Value))
 	if c.qs == nil {
 		return errNoQuadStore
 	}
-	// TODO(dennwc): batch NameOf?
-	return c.Each(func(v Value) {
-		if nv := c.qs.NameOf(v); nv != nil {
-			fnc(v, nv)
+	return c.eachValue(fnc)
+}
+
+// eachValue calls fnc for each result of the iterator that has a value. If QuadStore implements
+// BatchQuadStore, values are resolved for a batch of results at once.
+func (c *IterateChain) eachValue(fnc func(Value, quad.Value)) error {
+	bq, ok := c.qs.(BatchQuadStore)
+	if !ok || c.paths {
+		return c.Each(func(v Value) {
+			if nv := c.qs.NameOf(v); nv != nil {
+				fnc(v, nv)
+			}
+		})
+	}
+	c.start()
+	defer c.end()
+	buf := make([]Value, iterateBatch)
+	for {
+		vals := c.nextBatch(buf)
+		if len(vals) == 0 {
+			break
 		}
-	})
+		names, err := bq.ValuesOf(c.ctx, vals)
+		if err != nil {
+			return err
+		}
+		for i, nv := range names {
+			if nv != nil {
+				fnc(vals[i], nv)
+			}
+		}
+	}
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	return c.it.Err()
 }
 
 // AllValues is an analog of All, but it will additionally call NameOf
@@ -374,11 +496,33 @@ func (c *IterateChain) TagValues(qs QuadStore, fnc func(map[string]quad.Value))
 	if c.qs == nil {
 		return errNoQuadStore
 	}
-	return c.TagEach(func(m map[string]Value) {
-		vm := make(map[string]quad.Value, len(m))
+	var (
+		keys []string
+		vals []Value
+		err  error
+	)
+	if e := c.TagEach(func(m map[string]Value) {
+		if err != nil {
+			return
+		}
+		// values of all tags are resolved at once
+		keys, vals = keys[:0], vals[:0]
 		for k, v := range m {
-			vm[k] = c.qs.NameOf(v) // TODO(dennwc): batch NameOf?
+			keys = append(keys, k)
+			vals = append(vals, v)
+		}
+		var names []quad.Value
+		names, err = ValuesOf(c.ctx, c.qs, vals)
+		if err != nil {
+			return
+		}
+		vm := make(map[string]quad.Value, len(m))
+		for i, k := range keys {
+			vm[k] = names[i]
 		}
 		fnc(vm)
-	})
+	}); e != nil {
+		return e
+	}
+	return err
 }
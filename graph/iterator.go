@@ -216,6 +216,30 @@ type NoNext interface {
 	NoNext()
 }
 
+// BatchIterator is an optional interface for iterators that can return multiple results at once.
+// It allows backends to amortize decoding and locking costs across many results.
+type BatchIterator interface {
+	Iterator
+	// NextBatch advances the iterator by up to len(dst) results and writes them to dst.
+	// It returns the number of results written and zero if the iterator is exhausted.
+	// Sub-paths are not included in the batch. After the call, Result returns the last value written to dst.
+	NextBatch(ctx context.Context, dst []Value) (int, error)
+}
+
+// NextBatch writes up to len(dst) next results of the iterator to dst and returns the number of results written.
+// It returns zero if the iterator is exhausted. An iterator that does not implement BatchIterator is advanced with Next.
+func NextBatch(ctx context.Context, it Iterator, dst []Value) (int, error) {
+	if b, ok := it.(BatchIterator); ok {
+		return b.NextBatch(ctx, dst)
+	}
+	n := 0
+	for n < len(dst) && it.Next(ctx) {
+		dst[n] = it.Result()
+		n++
+	}
+	return n, it.Err()
+}
+
 // Height is a convienence function to measure the height of an iterator tree.
 func Height(it Iterator, until Type) int {
 	if it.Type() == until {
@@ -270,8 +294,13 @@ const (
 	Limit       = Type("limit")
 	Skip        = Type("skip")
 	Regex       = Type("regexp")
+	Filter      = Type("filter")
 	Count       = Type("count")
 	Recursive   = Type("recursive")
+	Prefetch    = Type("prefetch")
+	Profile     = Type("profile")
+	Sort        = Type("sort")
+	Aggregate   = Type("aggregate")
 )
 
 // String returns a string representation of the Type.
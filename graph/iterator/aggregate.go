@@ -0,0 +1,231 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Iterator = &Aggregate{}
+
+// Aggregation is a function that reduces values of an iterator to a single value.
+type Aggregation int
+
+const (
+	// AggregateSum is a sum of numeric values. Other values are ignored.
+	// The result is an integer if all values are integers and a float otherwise.
+	AggregateSum = Aggregation(iota)
+	// AggregateMin is a minimal value, as defined by CompareValues.
+	AggregateMin
+	// AggregateMax is a maximal value, as defined by CompareValues.
+	AggregateMax
+	// AggregateAvg is an average of numeric values. Other values are ignored.
+	AggregateAvg
+)
+
+func (a Aggregation) String() string {
+	switch a {
+	case AggregateSum:
+		return "sum"
+	case AggregateMin:
+		return "min"
+	case AggregateMax:
+		return "max"
+	case AggregateAvg:
+		return "avg"
+	}
+	return fmt.Sprintf("Aggregation(%d)", int(a))
+}
+
+// Aggregate iterator returns at most one element with an aggregate of values of underlying iterator.
+// Sum of an empty set is zero, other functions return no results in this case.
+type Aggregate struct {
+	uid    uint64
+	it     graph.Iterator
+	fnc    Aggregation
+	done   bool
+	tags   graph.Tagger
+	result quad.Value
+	qs     graph.QuadStore
+	err    error
+}
+
+// NewAggregate creates a new iterator that computes an aggregate of results from a provided subiterator.
+func NewAggregate(qs graph.QuadStore, it graph.Iterator, fnc Aggregation) *Aggregate {
+	return &Aggregate{
+		uid: NextUID(),
+		it:  it, fnc: fnc, qs: qs,
+	}
+}
+
+func (it *Aggregate) UID() uint64 {
+	return it.uid
+}
+
+// Reset resets the internal iterators and the iterator itself.
+func (it *Aggregate) Reset() {
+	it.done = false
+	it.result = nil
+	it.err = nil
+	it.it.Reset()
+}
+
+func (it *Aggregate) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Aggregate) TagResults(dst map[string]graph.Value) {
+	it.tags.TagResult(dst, it.Result())
+}
+
+func (it *Aggregate) Clone() graph.Iterator {
+	it2 := NewAggregate(it.qs, it.it.Clone(), it.fnc)
+	it2.Tagger().CopyFrom(it)
+	return it2
+}
+
+// SubIterators returns a slice of the sub iterators.
+func (it *Aggregate) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.it}
+}
+
+func (it *Aggregate) nameOf(v graph.Value) quad.Value {
+	if pv, ok := v.(graph.PreFetchedValue); ok {
+		return pv.NameOf()
+	}
+	return it.qs.NameOf(v)
+}
+
+// Next computes an aggregate of all results in underlying iterator.
+func (it *Aggregate) Next(ctx context.Context) bool {
+	if it.done {
+		return false
+	}
+	it.done = true
+	var (
+		isum  quad.Int
+		fsum  quad.Float
+		float bool
+		n     int
+		best  quad.Value
+	)
+	for it.it.Next(ctx) {
+		v := it.nameOf(it.it.Result())
+		switch it.fnc {
+		case AggregateMin, AggregateMax:
+			if v == nil {
+				continue
+			}
+			c := 0
+			if best != nil {
+				c = CompareValues(v, best)
+			}
+			if best == nil || (it.fnc == AggregateMin && c < 0) || (it.fnc == AggregateMax && c > 0) {
+				best = v
+			}
+			n++
+		default:
+			switch v := v.(type) {
+			case quad.Int:
+				isum += v
+				fsum += quad.Float(v)
+			case quad.Float:
+				fsum += v
+				float = true
+			default:
+				continue
+			}
+			n++
+		}
+	}
+	if it.err = it.it.Err(); it.err != nil {
+		return false
+	}
+	switch it.fnc {
+	case AggregateMin, AggregateMax:
+		it.result = best
+	case AggregateSum:
+		if float {
+			it.result = fsum
+		} else {
+			it.result = isum
+		}
+	case AggregateAvg:
+		if n != 0 {
+			it.result = fsum / quad.Float(n)
+		}
+	default:
+		it.err = fmt.Errorf("unsupported aggregation: %v", it.fnc)
+	}
+	return it.result != nil
+}
+
+func (it *Aggregate) Err() error {
+	return it.err
+}
+
+func (it *Aggregate) Result() graph.Value {
+	if it.result == nil {
+		return nil
+	}
+	return graph.PreFetched(it.result)
+}
+
+func (it *Aggregate) Contains(ctx context.Context, val graph.Value) bool {
+	if !it.done {
+		it.Next(ctx)
+	}
+	if it.result == nil {
+		return false
+	}
+	return it.nameOf(val) == it.result
+}
+
+func (it *Aggregate) NextPath(ctx context.Context) bool {
+	return false
+}
+
+func (it *Aggregate) Close() error {
+	return it.it.Close()
+}
+
+func (it *Aggregate) Type() graph.Type { return graph.Aggregate }
+
+func (it *Aggregate) Optimize() (graph.Iterator, bool) {
+	sub, optimized := it.it.Optimize()
+	it.it = sub
+	return it, optimized
+}
+
+func (it *Aggregate) Stats() graph.IteratorStats {
+	sub := it.it.Stats()
+	stats := graph.IteratorStats{
+		NextCost:  sub.NextCost * sub.Size,
+		Size:      1,
+		ExactSize: false,
+	}
+	stats.ContainsCost = stats.NextCost
+	return stats
+}
+
+func (it *Aggregate) Size() (int64, bool) {
+	return 1, false
+}
+
+func (it *Aggregate) String() string { return fmt.Sprintf("Aggregate(%v)", it.fnc) }
@@ -0,0 +1,52 @@
+package iterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphmock"
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestAggregate(t *testing.T) {
+	ctx := context.TODO()
+	qs := &graphmock.Oldstore{Data: []string{"3", "foo", "10", "-1"}, Parse: true}
+	newFixed := func(n int) *Fixed {
+		f := NewFixed()
+		for i := 0; i < n; i++ {
+			f.Add(Int64Node(i))
+		}
+		return f
+	}
+	for _, c := range []struct {
+		fnc    Aggregation
+		n      int
+		expect quad.Value
+	}{
+		{fnc: AggregateSum, n: 4, expect: quad.Int(12)},
+		{fnc: AggregateMin, n: 4, expect: quad.Int(-1)},
+		{fnc: AggregateMax, n: 4, expect: quad.String("foo")},
+		{fnc: AggregateMax, n: 3, expect: quad.String("foo")},
+		{fnc: AggregateAvg, n: 4, expect: quad.Float(4)},
+		{fnc: AggregateSum, n: 0, expect: quad.Int(0)},
+		{fnc: AggregateMin, n: 0, expect: nil},
+		{fnc: AggregateAvg, n: 0, expect: nil},
+	} {
+		it := NewAggregate(qs, newFixed(c.n), c.fnc)
+		var got quad.Value
+		if it.Next(ctx) {
+			got = it.Result().(graph.PreFetchedValue).NameOf()
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if got != c.expect {
+			t.Errorf("%v of %d values: got: %#v, expected: %#v", c.fnc, c.n, got, c.expect)
+		}
+		if it.Next(ctx) {
+			t.Errorf("%v: expected a single result", c.fnc)
+		}
+	}
+}
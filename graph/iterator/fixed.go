@@ -27,7 +27,7 @@ import (
 	"github.com/cayleygraph/cayley/graph"
 )
 
-var _ graph.Iterator = &Fixed{}
+var _ graph.BatchIterator = &Fixed{}
 
 // A Fixed iterator consists of it's values, an index (where it is in the process of Next()ing) and
 // an equality function.
@@ -113,6 +113,16 @@ func (it *Fixed) Contains(ctx context.Context, v graph.Value) bool {
 	return graph.ContainsLogOut(it, v, false)
 }
 
+// NextBatch implements graph.BatchIterator.
+func (it *Fixed) NextBatch(ctx context.Context, dst []graph.Value) (int, error) {
+	n := copy(dst, it.values[it.lastIndex:])
+	if n != 0 {
+		it.lastIndex += n
+		it.result = dst[n-1]
+	}
+	return n, nil
+}
+
 // Next advances the iterator.
 func (it *Fixed) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
@@ -0,0 +1,30 @@
+package iterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixedNextBatch(t *testing.T) {
+	ctx := context.TODO()
+	it := NewFixed(Int64Node(1), Int64Node(2), Int64Node(3))
+
+	buf := make([]graph.Value, 2)
+	n, err := graph.NextBatch(ctx, it, buf)
+	require.NoError(t, err)
+	require.Equal(t, []graph.Value{Int64Node(1), Int64Node(2)}, buf[:n])
+	require.Equal(t, Int64Node(2), it.Result())
+
+	n, err = graph.NextBatch(ctx, it, buf)
+	require.NoError(t, err)
+	require.Equal(t, []graph.Value{Int64Node(3)}, buf[:n])
+
+	n, err = graph.NextBatch(ctx, it, buf)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+	require.False(t, it.Next(ctx))
+}
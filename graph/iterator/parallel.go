@@ -0,0 +1,257 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var _ graph.Iterator = &Prefetch{}
+
+// prefetchPath is a single result of the sub-iterator with its tags.
+type prefetchPath struct {
+	result graph.Value
+	tags   map[string]graph.Value
+}
+
+// prefetched is a result of the sub-iterator with all its sub-paths, or an error.
+type prefetched struct {
+	paths []prefetchPath
+	err   error
+}
+
+// Prefetch is an iterator that advances its sub-iterator in a separate goroutine and buffers
+// the results, so the work done by the sub-iterator overlaps with the work of the consumer.
+// Results are buffered with all their sub-paths and tags.
+//
+// Only Next is executed in the background. Contains stops the background goroutine and is
+// passed to the sub-iterator directly.
+type Prefetch struct {
+	uid    uint64
+	tags   graph.Tagger
+	sub    graph.Iterator
+	buffer int
+
+	results chan prefetched
+	stop    chan struct{}
+	done    chan struct{}
+
+	cur    *prefetched
+	path   int
+	result graph.Value
+	err    error
+}
+
+// NewPrefetch creates an iterator that buffers up to a given number of results of the sub-iterator.
+func NewPrefetch(sub graph.Iterator, buffer int) *Prefetch {
+	if buffer <= 0 {
+		buffer = 1
+	}
+	return &Prefetch{
+		uid:    NextUID(),
+		sub:    sub,
+		buffer: buffer,
+	}
+}
+
+func (it *Prefetch) UID() uint64 {
+	return it.uid
+}
+
+// capture returns the current result of the sub-iterator with its tags.
+func (it *Prefetch) capture() prefetchPath {
+	tags := make(map[string]graph.Value)
+	it.sub.TagResults(tags)
+	return prefetchPath{result: it.sub.Result(), tags: tags}
+}
+
+func (it *Prefetch) run(ctx context.Context, out chan<- prefetched, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	defer close(out)
+	for it.sub.Next(ctx) {
+		r := prefetched{paths: []prefetchPath{it.capture()}}
+		for it.sub.NextPath(ctx) {
+			r.paths = append(r.paths, it.capture())
+		}
+		select {
+		case out <- r:
+		case <-stop:
+			return
+		}
+	}
+	if err := it.sub.Err(); err != nil {
+		select {
+		case out <- prefetched{err: err}:
+		case <-stop:
+		}
+	}
+}
+
+// halt stops the background goroutine, if it is running, and discards buffered results.
+func (it *Prefetch) halt() {
+	if it.results == nil {
+		return
+	}
+	close(it.stop)
+	<-it.done
+	it.results, it.stop, it.done = nil, nil, nil
+}
+
+func (it *Prefetch) Reset() {
+	it.halt()
+	it.sub.Reset()
+	it.cur, it.result, it.err = nil, nil, nil
+}
+
+func (it *Prefetch) Close() error {
+	it.halt()
+	return it.sub.Close()
+}
+
+func (it *Prefetch) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Prefetch) TagResults(dst map[string]graph.Value) {
+	it.tags.TagResult(dst, it.Result())
+	if it.cur == nil {
+		return
+	}
+	for k, v := range it.cur.paths[it.path].tags {
+		dst[k] = v
+	}
+}
+
+func (it *Prefetch) Clone() graph.Iterator {
+	out := NewPrefetch(it.sub.Clone(), it.buffer)
+	out.tags.CopyFrom(it)
+	return out
+}
+
+func (it *Prefetch) Next(ctx context.Context) bool {
+	graph.NextLogIn(it)
+	it.cur, it.result = nil, nil
+	if it.err != nil {
+		return graph.NextLogOut(it, false)
+	}
+	if it.results == nil {
+		it.results = make(chan prefetched, it.buffer)
+		it.stop = make(chan struct{})
+		it.done = make(chan struct{})
+		go it.run(ctx, it.results, it.stop, it.done)
+	}
+	var (
+		r  prefetched
+		ok bool
+	)
+	select {
+	case r, ok = <-it.results:
+	case <-ctx.Done():
+		it.err = ctx.Err()
+		return graph.NextLogOut(it, false)
+	}
+	if !ok {
+		return graph.NextLogOut(it, false)
+	} else if r.err != nil {
+		it.err = r.err
+		return graph.NextLogOut(it, false)
+	}
+	it.cur, it.path = &r, 0
+	it.result = r.paths[0].result
+	return graph.NextLogOut(it, true)
+}
+
+func (it *Prefetch) NextPath(ctx context.Context) bool {
+	if it.cur == nil || it.path+1 >= len(it.cur.paths) {
+		return false
+	}
+	it.path++
+	it.result = it.cur.paths[it.path].result
+	return true
+}
+
+func (it *Prefetch) Contains(ctx context.Context, v graph.Value) bool {
+	graph.ContainsLogIn(it, v)
+	it.halt()
+	it.cur, it.result = nil, nil
+	if !it.sub.Contains(ctx, v) {
+		it.err = it.sub.Err()
+		return graph.ContainsLogOut(it, v, false)
+	}
+	it.cur = &prefetched{paths: []prefetchPath{it.capture()}}
+	it.path = 0
+	it.result = it.cur.paths[0].result
+	return graph.ContainsLogOut(it, v, true)
+}
+
+func (it *Prefetch) Err() error {
+	return it.err
+}
+
+func (it *Prefetch) Result() graph.Value {
+	return it.result
+}
+
+func (it *Prefetch) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.sub}
+}
+
+func (it *Prefetch) Optimize() (graph.Iterator, bool) {
+	sub, changed := it.sub.Optimize()
+	if changed {
+		it.sub = sub
+	}
+	return it, false
+}
+
+func (it *Prefetch) Size() (int64, bool) {
+	return it.sub.Size()
+}
+
+func (it *Prefetch) Stats() graph.IteratorStats {
+	return it.sub.Stats()
+}
+
+func (it *Prefetch) Type() graph.Type { return graph.Prefetch }
+
+func (it *Prefetch) String() string {
+	return fmt.Sprintf("Prefetch(%d)", it.buffer)
+}
+
+// Parallelize changes the iterator tree to execute independent branches of And and Or iterators
+// in separate goroutines. Each branch buffers up to a given number of results.
+//
+// All sub-iterators of an Or and the primary iterator of an And are executed in parallel,
+// thus backends with high latency can overlap their round trips. Other iterators are not changed.
+// The iterator should be optimized before calling this function.
+func Parallelize(it graph.Iterator, buffer int) graph.Iterator {
+	switch it := it.(type) {
+	case *And:
+		if it.primaryIt != nil {
+			it.primaryIt = NewPrefetch(Parallelize(it.primaryIt, buffer), buffer)
+		}
+	case *Or:
+		if it.isShortCircuiting || len(it.internalIterators) < 2 {
+			break
+		}
+		for i, sub := range it.internalIterators {
+			it.internalIterators[i] = NewPrefetch(Parallelize(sub, buffer), buffer)
+		}
+	}
+	return it
+}
@@ -0,0 +1,60 @@
+package iterator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/stretchr/testify/require"
+)
+
+func collectTags(t testing.TB, it graph.Iterator) []map[string]graph.Value {
+	var out []map[string]graph.Value
+	err := graph.Iterate(context.TODO(), it).UnOptimized().TagEach(func(m map[string]graph.Value) {
+		out = append(out, m)
+	})
+	require.NoError(t, err)
+	return out
+}
+
+func TestParallelize(t *testing.T) {
+	build := func() graph.Iterator {
+		a := NewFixed(Int64Node(1), Int64Node(2), Int64Node(3))
+		a.Tagger().Add("a")
+		b := NewFixed(Int64Node(3), Int64Node(4))
+		b.Tagger().Add("b")
+		or := NewOr(a, b)
+		c := NewFixed(Int64Node(2), Int64Node(3), Int64Node(4))
+		c.Tagger().Add("c")
+		return NewAnd(nil, or, c)
+	}
+	expect := collectTags(t, build())
+	require.Len(t, expect, 4)
+
+	it := Parallelize(build(), 1)
+	require.Equal(t, graph.Prefetch, it.SubIterators()[0].Type())
+	require.Equal(t, expect, collectTags(t, it))
+}
+
+func TestPrefetchClose(t *testing.T) {
+	ctx := context.TODO()
+	sub := NewFixed()
+	for i := 0; i < 100; i++ {
+		sub.Add(Int64Node(i))
+	}
+	it := NewPrefetch(sub, 2)
+	require.True(t, it.Next(ctx))
+	require.Equal(t, Int64Node(0), it.Result())
+	require.True(t, it.Contains(ctx, Int64Node(50)))
+	require.Equal(t, Int64Node(50), it.Result())
+	require.NoError(t, it.Close())
+}
+
+func TestPrefetchError(t *testing.T) {
+	errTest := errors.New("test")
+	it := NewPrefetch(newTestIterator(false, errTest), 2)
+	require.False(t, it.Next(context.TODO()))
+	require.Equal(t, errTest, it.Err())
+}
@@ -0,0 +1,285 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var (
+	_ graph.Iterator      = &Profiled{}
+	_ graph.BatchIterator = &Profiled{}
+)
+
+// ProfileStats are execution statistics of an iterator collected by Profiled.
+type ProfileStats struct {
+	Next     int64         // number of Next and NextPath calls, or the number of results for NextBatch
+	Contains int64         // number of Contains calls
+	Time     time.Duration // total time spent in the iterator, including its sub-iterators
+}
+
+// profileCounters are shared between clones of Profiled iterator and may be updated concurrently.
+type profileCounters struct {
+	next, contains, time int64
+}
+
+// Profiled is an iterator that measures the time spent in its sub-iterator and counts the calls to it.
+// Clones of the iterator share statistics.
+type Profiled struct {
+	uid   uint64
+	sub   graph.Iterator
+	stats *profileCounters
+}
+
+// NewProfiled creates an iterator that collects execution statistics of the sub-iterator.
+func NewProfiled(sub graph.Iterator) *Profiled {
+	return &Profiled{
+		uid:   NextUID(),
+		sub:   sub,
+		stats: new(profileCounters),
+	}
+}
+
+// Profile wraps the iterator and its sub-iterators into Profiled iterators. Sub-iterators are replaced in place.
+//
+// It must be called after the iterator tree is optimized, since wrappers hide sub-iterators from optimizations.
+// Sub-iterators of iterator types unknown to Profile are measured together with their parent.
+func Profile(it graph.Iterator) graph.Iterator {
+	switch it := it.(type) {
+	case nil:
+		return nil
+	case *Profiled:
+		return it
+	case *And:
+		wrapped := make(map[uint64]graph.Iterator)
+		wrap := func(sub graph.Iterator) graph.Iterator {
+			if p, ok := wrapped[sub.UID()]; ok {
+				return p
+			}
+			p := Profile(sub)
+			wrapped[sub.UID()] = p
+			return p
+		}
+		if it.primaryIt != nil {
+			it.primaryIt = wrap(it.primaryIt)
+		}
+		for i, sub := range it.internalIterators {
+			it.internalIterators[i] = wrap(sub)
+		}
+		for i, sub := range it.checkList {
+			it.checkList[i] = wrap(sub)
+		}
+	case *Or:
+		for i, sub := range it.internalIterators {
+			it.internalIterators[i] = Profile(sub)
+		}
+	case *HasA:
+		it.primaryIt = Profile(it.primaryIt)
+	case *LinksTo:
+		it.primaryIt = Profile(it.primaryIt)
+	case *Not:
+		it.primaryIt = Profile(it.primaryIt)
+		it.allIt = Profile(it.allIt)
+	case *Limit:
+		it.primaryIt = Profile(it.primaryIt)
+	case *Skip:
+		it.primaryIt = Profile(it.primaryIt)
+	case *Optional:
+		it.subIt = Profile(it.subIt)
+	case *Unique:
+		it.subIt = Profile(it.subIt)
+	case *Materialize:
+		it.subIt = Profile(it.subIt)
+	case *ValueFilter:
+		it.subIt = Profile(it.subIt)
+	case *Count:
+		it.it = Profile(it.it)
+	case *Prefetch:
+		it.sub = Profile(it.sub)
+	}
+	return NewProfiled(it)
+}
+
+func (it *Profiled) UID() uint64 {
+	return it.uid
+}
+
+// ProfileStats returns statistics collected so far.
+func (it *Profiled) ProfileStats() ProfileStats {
+	return ProfileStats{
+		Next:     atomic.LoadInt64(&it.stats.next),
+		Contains: atomic.LoadInt64(&it.stats.contains),
+		Time:     time.Duration(atomic.LoadInt64(&it.stats.time)),
+	}
+}
+
+func (it *Profiled) since(start time.Time) {
+	atomic.AddInt64(&it.stats.time, int64(time.Since(start)))
+}
+
+func (it *Profiled) Tagger() *graph.Tagger {
+	return it.sub.Tagger()
+}
+
+func (it *Profiled) TagResults(dst map[string]graph.Value) {
+	it.sub.TagResults(dst)
+}
+
+func (it *Profiled) Result() graph.Value {
+	return it.sub.Result()
+}
+
+func (it *Profiled) Next(ctx context.Context) bool {
+	defer it.since(time.Now())
+	atomic.AddInt64(&it.stats.next, 1)
+	return it.sub.Next(ctx)
+}
+
+func (it *Profiled) NextPath(ctx context.Context) bool {
+	defer it.since(time.Now())
+	atomic.AddInt64(&it.stats.next, 1)
+	return it.sub.NextPath(ctx)
+}
+
+func (it *Profiled) NextBatch(ctx context.Context, dst []graph.Value) (int, error) {
+	defer it.since(time.Now())
+	n, err := graph.NextBatch(ctx, it.sub, dst)
+	atomic.AddInt64(&it.stats.next, int64(n))
+	return n, err
+}
+
+func (it *Profiled) Contains(ctx context.Context, v graph.Value) bool {
+	defer it.since(time.Now())
+	atomic.AddInt64(&it.stats.contains, 1)
+	return it.sub.Contains(ctx, v)
+}
+
+func (it *Profiled) Err() error {
+	return it.sub.Err()
+}
+
+func (it *Profiled) Reset() {
+	it.sub.Reset()
+}
+
+func (it *Profiled) Clone() graph.Iterator {
+	return &Profiled{uid: NextUID(), sub: it.sub.Clone(), stats: it.stats}
+}
+
+func (it *Profiled) Stats() graph.IteratorStats {
+	return it.sub.Stats()
+}
+
+func (it *Profiled) Size() (int64, bool) {
+	return it.sub.Size()
+}
+
+func (it *Profiled) Type() graph.Type { return graph.Profile }
+
+func (it *Profiled) Optimize() (graph.Iterator, bool) {
+	nit, ok := it.sub.Optimize()
+	if ok {
+		it.sub = nit
+	}
+	return it, false
+}
+
+func (it *Profiled) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.sub}
+}
+
+func (it *Profiled) Close() error {
+	return it.sub.Close()
+}
+
+func (it *Profiled) String() string {
+	return "Profile"
+}
+
+// ProfileNode is a description of an iterator tree with execution statistics.
+type ProfileNode struct {
+	Type  graph.Type `json:"type"`
+	Name  string     `json:"name,omitempty"`
+	Tags  []string   `json:"tags,omitempty"`
+	Size  int64      `json:"size"`
+	Exact bool       `json:"exact,omitempty"`
+	// Next and Contains are the numbers of calls to the iterator.
+	Next     int64 `json:"next,omitempty"`
+	Contains int64 `json:"contains,omitempty"`
+	// Time is a total time spent in the iterator and its sub-iterators, in milliseconds.
+	// It is only set for iterators wrapped by Profile.
+	Time      float64       `json:"time_ms,omitempty"`
+	Iterators []ProfileNode `json:"iterators,omitempty"`
+}
+
+// DescribeProfile returns a description of the iterator tree with statistics collected by Profiled iterators.
+// Profiled iterators are not included in the tree; their statistics are reported for the wrapped iterators instead.
+func DescribeProfile(it graph.Iterator) ProfileNode {
+	p, profiled := it.(*Profiled)
+	if profiled {
+		it = p.sub
+	}
+	sz, exact := it.Size()
+	st := it.Stats()
+	d := ProfileNode{
+		Type: it.Type(),
+		Name: it.String(),
+		Tags: it.Tagger().Tags(),
+		Size: sz, Exact: exact,
+		Next: st.Next, Contains: st.Contains,
+	}
+	if profiled {
+		ps := p.ProfileStats()
+		d.Next, d.Contains = ps.Next, ps.Contains
+		d.Time = float64(ps.Time) / float64(time.Millisecond)
+	}
+	if sub := it.SubIterators(); len(sub) != 0 {
+		d.Iterators = make([]ProfileNode, 0, len(sub))
+		for _, sit := range sub {
+			d.Iterators = append(d.Iterators, DescribeProfile(sit))
+		}
+	}
+	return d
+}
+
+// TotalProfileStats returns the number of calls to all Profiled iterators in the tree and the time spent
+// in the root iterator. Iterators that share statistics, such as clones, are counted once.
+func TotalProfileStats(it graph.Iterator) ProfileStats {
+	var total ProfileStats
+	if p, ok := it.(*Profiled); ok {
+		total.Time = p.ProfileStats().Time
+	}
+	seen := make(map[*profileCounters]struct{})
+	var walk func(it graph.Iterator)
+	walk = func(it graph.Iterator) {
+		if p, ok := it.(*Profiled); ok {
+			if _, ok = seen[p.stats]; !ok {
+				seen[p.stats] = struct{}{}
+				st := p.ProfileStats()
+				total.Next += st.Next
+				total.Contains += st.Contains
+			}
+		}
+		for _, sub := range it.SubIterators() {
+			walk(sub)
+		}
+	}
+	walk(it)
+	return total
+}
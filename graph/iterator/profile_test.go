@@ -0,0 +1,39 @@
+package iterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	. "github.com/cayleygraph/cayley/graph/iterator"
+)
+
+func TestProfile(t *testing.T) {
+	ctx := context.TODO()
+	a := NewFixed(Int64Node(1), Int64Node(2), Int64Node(3))
+	b := NewFixed(Int64Node(2), Int64Node(3), Int64Node(4))
+	it := Profile(NewAnd(nil, a, b))
+	n := 0
+	for it.Next(ctx) {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("unexpected number of results: %d", n)
+	}
+	d := DescribeProfile(it)
+	if d.Type != graph.And || len(d.Iterators) != 2 {
+		t.Fatalf("unexpected description: %#v", d)
+	}
+	if d.Next != 3 {
+		t.Errorf("unexpected number of Next calls: %d", d.Next)
+	}
+	if sub := d.Iterators[0]; sub.Type != graph.Fixed || sub.Next != 4 {
+		t.Errorf("unexpected statistics of the primary iterator: %#v", sub)
+	}
+	if sub := d.Iterators[1]; sub.Type != graph.Fixed || sub.Contains != 3 {
+		t.Errorf("unexpected statistics of the sub-iterator: %#v", sub)
+	}
+	if st := TotalProfileStats(it); st.Next != 7 || st.Contains != 3 {
+		t.Errorf("unexpected total statistics: %#v", st)
+	}
+}
@@ -3,6 +3,7 @@ package iterator
 import (
 	"context"
 	"math"
+	"strconv"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/quad"
@@ -27,6 +28,7 @@ type Recursive struct {
 	pathIndex     int
 	containsValue graph.Value
 	depthTags     graph.Tagger
+	pathTags      []string
 	depthCache    []graph.Value
 	baseIt        graph.FixedIterator
 }
@@ -86,9 +88,24 @@ func (it *Recursive) AddDepthTag(s string) {
 	it.depthTags.Add(s)
 }
 
+// AddPathTag adds a prefix of tags that will contain nodes on the path to each result.
+// A start node is saved to a tag with a "0" suffix (prefix0), a node reached after the first step to prefix1,
+// and so on up to the result node itself.
+func (it *Recursive) AddPathTag(prefix string) {
+	it.pathTags = append(it.pathTags, prefix)
+}
+
 func (it *Recursive) TagResults(dst map[string]graph.Value) {
 	it.tags.TagResult(dst, it.Result())
 	it.depthTags.TagResult(dst, graph.PreFetched(quad.Int(it.result.depth)))
+	if len(it.pathTags) != 0 && it.result.val != nil {
+		path := it.pathTo(it.result.val)
+		for _, pref := range it.pathTags {
+			for i, v := range path {
+				dst[pref+strconv.Itoa(i)] = v
+			}
+		}
+	}
 
 	if it.containsValue != nil {
 		paths := it.pathMap[graph.ToKey(it.containsValue)]
@@ -108,6 +125,7 @@ func (it *Recursive) Clone() graph.Iterator {
 	n := NewRecursive(it.qs, it.subIt.Clone(), it.morphism, it.maxDepth)
 	n.tags.CopyFrom(it)
 	n.depthTags.CopyFromTagger(&it.depthTags)
+	n.pathTags = append([]string{}, it.pathTags...)
 	return n
 }
 
@@ -191,6 +209,20 @@ func (it *Recursive) getBaseValue(val graph.Value) graph.Value {
 	return at.val
 }
 
+// pathTo returns nodes on the path from a start node to a given result.
+func (it *Recursive) pathTo(val graph.Value) []graph.Value {
+	at := it.seen[graph.ToKey(val)]
+	path := make([]graph.Value, at.depth+1)
+	path[at.depth] = val
+	for d := at.depth - 1; d >= 0; d-- {
+		path[d] = at.val
+		if d > 0 {
+			at = it.seen[graph.ToKey(at.val)]
+		}
+	}
+	return path
+}
+
 func (it *Recursive) Contains(ctx context.Context, val graph.Value) bool {
 	graph.ContainsLogIn(it, val)
 	it.pathIndex = 0
@@ -18,6 +18,7 @@ import (
 	"context"
 	"reflect"
 	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/cayleygraph/cayley/graph"
@@ -118,3 +119,36 @@ func TestRecursiveNextPath(t *testing.T) {
 		t.Errorf("Failed to check NextPath, got: %v, expected: %v", got, expected)
 	}
 }
+
+func TestRecursivePathTags(t *testing.T) {
+	ctx := context.TODO()
+	qs := rec_test_qs
+	start := NewFixed()
+	start.Add(graph.PreFetched(quad.Raw("alice")))
+	r := NewRecursive(qs, start, singleHop("parent"), 0)
+	r.AddPathTag("hop")
+
+	expected := map[string][]string{
+		"bob":     {"alice", "bob"},
+		"charlie": {"alice", "bob", "charlie"},
+		"dani":    {"alice", "bob", "charlie", "dani"},
+		"emily":   {"alice", "bob", "charlie", "dani", "emily"},
+	}
+	got := make(map[string][]string)
+	for r.Next(ctx) {
+		res := make(map[string]graph.Value)
+		r.TagResults(res)
+		var path []string
+		for i := 0; ; i++ {
+			v, ok := res["hop"+strconv.Itoa(i)]
+			if !ok {
+				break
+			}
+			path = append(path, quad.ToString(qs.NameOf(v)))
+		}
+		got[quad.ToString(qs.NameOf(r.Result()))] = path
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Failed to check path tags, got: %v, expected: %v", got, expected)
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Iterator = &Sort{}
+
+// sortValue is a single result of the subiterator with all its paths.
+type sortValue struct {
+	name  quad.Value
+	paths []result
+}
+
+// Sort iterator orders values of its subiterator in ascending order, as defined by CompareValues.
+//
+// The subiterator is read completely on the first call to Next, thus the iterator is not suitable for large sets.
+type Sort struct {
+	uid      uint64
+	tags     graph.Tagger
+	qs       graph.QuadStore
+	subIt    graph.Iterator
+	values   []sortValue
+	sorted   bool
+	index    int
+	subindex int
+	result   graph.Value
+	contains bool // result was set by Contains
+	runstats graph.IteratorStats
+	err      error
+}
+
+// NewSort creates a new iterator that orders values of the subiterator.
+func NewSort(qs graph.QuadStore, subIt graph.Iterator) *Sort {
+	return &Sort{
+		uid:   NextUID(),
+		qs:    qs,
+		subIt: subIt,
+		index: -1,
+	}
+}
+
+func (it *Sort) UID() uint64 {
+	return it.uid
+}
+
+// Reset restarts the iteration. Values that were already sorted are reused.
+func (it *Sort) Reset() {
+	it.subIt.Reset()
+	it.index = -1
+	it.subindex = 0
+	it.result = nil
+	it.contains = false
+}
+
+func (it *Sort) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Sort) TagResults(dst map[string]graph.Value) {
+	it.tags.TagResult(dst, it.Result())
+	if it.contains {
+		it.subIt.TagResults(dst)
+		return
+	} else if it.index < 0 || it.index >= len(it.values) {
+		return
+	}
+	for tag, v := range it.values[it.index].paths[it.subindex].tags {
+		dst[tag] = v
+	}
+}
+
+func (it *Sort) Clone() graph.Iterator {
+	out := NewSort(it.qs, it.subIt.Clone())
+	out.tags.CopyFrom(it)
+	return out
+}
+
+func (it *Sort) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.subIt}
+}
+
+func (it *Sort) Next(ctx context.Context) bool {
+	graph.NextLogIn(it)
+	it.runstats.Next += 1
+	it.contains = false
+	if !it.sorted {
+		it.sort(ctx)
+	}
+	if it.err != nil {
+		return graph.NextLogOut(it, false)
+	}
+	it.index++
+	it.subindex = 0
+	if it.index >= len(it.values) {
+		it.index = len(it.values)
+		it.result = nil
+		return graph.NextLogOut(it, false)
+	}
+	it.result = it.values[it.index].paths[0].id
+	return graph.NextLogOut(it, true)
+}
+
+func (it *Sort) sort(ctx context.Context) {
+	it.sorted = true
+	for it.subIt.Next(ctx) {
+		id := it.subIt.Result()
+		v := sortValue{name: it.qs.NameOf(id)}
+		for {
+			tags := make(map[string]graph.Value)
+			it.subIt.TagResults(tags)
+			v.paths = append(v.paths, result{id: id, tags: tags})
+			if !it.subIt.NextPath(ctx) {
+				break
+			}
+		}
+		it.values = append(it.values, v)
+	}
+	if it.err = it.subIt.Err(); it.err != nil {
+		return
+	}
+	sort.SliceStable(it.values, func(i, j int) bool {
+		return CompareValues(it.values[i].name, it.values[j].name) < 0
+	})
+}
+
+func (it *Sort) NextPath(ctx context.Context) bool {
+	if it.contains {
+		return it.subIt.NextPath(ctx)
+	} else if it.index < 0 || it.index >= len(it.values) {
+		return false
+	}
+	if it.subindex+1 >= len(it.values[it.index].paths) {
+		return false
+	}
+	it.subindex++
+	return true
+}
+
+func (it *Sort) Err() error {
+	return it.err
+}
+
+func (it *Sort) Result() graph.Value {
+	return it.result
+}
+
+// Contains checks whether the passed value is part of the primary iterator, which is irrelevant for ordering.
+func (it *Sort) Contains(ctx context.Context, val graph.Value) bool {
+	graph.ContainsLogIn(it, val)
+	it.runstats.Contains += 1
+	it.contains = true
+	it.result = nil
+	ok := it.subIt.Contains(ctx, val)
+	if ok {
+		it.result = val
+	}
+	it.err = it.subIt.Err()
+	return graph.ContainsLogOut(it, val, ok)
+}
+
+func (it *Sort) Close() error {
+	it.values = nil
+	it.sorted = false
+	return it.subIt.Close()
+}
+
+func (it *Sort) Type() graph.Type { return graph.Sort }
+
+func (it *Sort) Optimize() (graph.Iterator, bool) {
+	newIt, optimized := it.subIt.Optimize()
+	if optimized {
+		it.subIt = newIt
+		if it.subIt.Type() == graph.Null {
+			return it.subIt, true
+		}
+	}
+	return it, false
+}
+
+func (it *Sort) Stats() graph.IteratorStats {
+	subStats := it.subIt.Stats()
+	return graph.IteratorStats{
+		// sorting is done once, when the first value is requested
+		NextCost:     subStats.NextCost * 2,
+		ContainsCost: subStats.ContainsCost,
+		Size:         subStats.Size,
+		ExactSize:    subStats.ExactSize,
+		Next:         it.runstats.Next,
+		Contains:     it.runstats.Contains,
+		ContainsNext: it.runstats.ContainsNext,
+	}
+}
+
+func (it *Sort) Size() (int64, bool) {
+	if it.sorted && it.err == nil {
+		return int64(len(it.values)), true
+	}
+	return it.subIt.Size()
+}
+
+func (it *Sort) String() string {
+	return "Sort"
+}
+
+// valueKind returns an order of groups of values that cannot be compared directly.
+func valueKind(v quad.Value) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case quad.Int, quad.Float:
+		return 1
+	case quad.Time:
+		return 2
+	case quad.Bool:
+		return 3
+	case quad.String, quad.TypedString, quad.LangString:
+		return 4
+	case quad.IRI:
+		return 5
+	case quad.BNode:
+		return 6
+	}
+	return 7
+}
+
+// rawString returns a string content of the value without any escaping.
+func rawString(v quad.Value) string {
+	switch v := v.(type) {
+	case quad.String:
+		return string(v)
+	case quad.TypedString:
+		return string(v.Value)
+	case quad.LangString:
+		return string(v.Value)
+	case quad.IRI:
+		return string(v)
+	case quad.BNode:
+		return string(v)
+	}
+	return quad.StringOf(v)
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return +1
+	}
+	return 0
+}
+
+// CompareValues defines the order of values used by Sort. It returns -1 if a < b, +1 if a > b and 0 otherwise.
+//
+// Numbers are compared numerically, times chronologically and strings byte-wise by their content.
+// Values of different kinds are ordered by kind: numbers, times, booleans, literals, IRIs and blank nodes.
+func CompareValues(a, b quad.Value) int {
+	ka, kb := valueKind(a), valueKind(b)
+	if ka != kb {
+		if ka < kb {
+			return -1
+		}
+		return +1
+	}
+	switch a := a.(type) {
+	case nil:
+		return 0
+	case quad.Int:
+		switch b := b.(type) {
+		case quad.Int:
+			if a != b {
+				if a < b {
+					return -1
+				}
+				return +1
+			}
+			return 0
+		case quad.Float:
+			return compareFloats(float64(a), float64(b))
+		}
+	case quad.Float:
+		switch b := b.(type) {
+		case quad.Int:
+			return compareFloats(float64(a), float64(b))
+		case quad.Float:
+			return compareFloats(float64(a), float64(b))
+		}
+	case quad.Time:
+		ta, tb := time.Time(a), time.Time(b.(quad.Time))
+		switch {
+		case ta.Before(tb):
+			return -1
+		case ta.After(tb):
+			return +1
+		}
+		return 0
+	case quad.Bool:
+		if a == b.(quad.Bool) {
+			return 0
+		} else if !a {
+			return -1
+		}
+		return +1
+	}
+	if c := compareStrings(rawString(a), rawString(b)); c != 0 {
+		return c
+	}
+	// same content, but different types or languages
+	return compareStrings(quad.StringOf(a), quad.StringOf(b))
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return +1
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package iterator_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphmock"
+	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestSortIterator(t *testing.T) {
+	ctx := context.TODO()
+	qs := &graphmock.Oldstore{Data: []string{"10", "foo", "9", "bar", "-1"}, Parse: true}
+	fixed := NewFixed()
+	for i := range qs.Data {
+		fixed.Add(Int64Node(i))
+	}
+	it := NewSort(qs, fixed)
+	it.Tagger().Add("id")
+
+	expect := []quad.Value{quad.Int(-1), quad.Int(9), quad.Int(10), quad.String("bar"), quad.String("foo")}
+	for i := 0; i < 2; i++ {
+		var got []quad.Value
+		for it.Next(ctx) {
+			tags := make(map[string]graph.Value)
+			it.TagResults(tags)
+			if tags["id"] != it.Result() {
+				t.Errorf("unexpected tag value: %v vs %v", tags["id"], it.Result())
+			}
+			got = append(got, qs.NameOf(it.Result()))
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("Failed to sort values on repeat %d: got:%v expected:%v", i, got, expect)
+		}
+		it.Reset()
+	}
+	if !it.Contains(ctx, Int64Node(1)) {
+		t.Errorf("Failed to find a correct value in the sort iterator.")
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	now := time.Now()
+	for _, c := range []struct {
+		a, b quad.Value
+		exp  int
+	}{
+		{quad.Int(2), quad.Int(10), -1},
+		{quad.Float(2.5), quad.Int(2), +1},
+		{quad.Int(3), quad.Float(3), 0},
+		{quad.Time(now), quad.Time(now.Add(time.Second)), -1},
+		{quad.String("ab c"), quad.String("ab"), +1},
+		{quad.String("b"), quad.LangString{Value: "a", Lang: "en"}, +1},
+		{quad.Int(100), quad.String("1"), -1},
+		{quad.String("z"), quad.IRI("a"), -1},
+		{nil, quad.Int(0), -1},
+	} {
+		if got := CompareValues(c.a, c.b); got != c.exp {
+			t.Errorf("compare(%v, %v): got: %d, expected: %d", c.a, c.b, got, c.exp)
+		}
+		if got := CompareValues(c.b, c.a); got != -c.exp {
+			t.Errorf("compare(%v, %v): got: %d, expected: %d", c.b, c.a, got, -c.exp)
+		}
+	}
+}
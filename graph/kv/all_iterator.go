@@ -16,6 +16,7 @@ package kv
 
 import (
 	"context"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
@@ -26,9 +27,11 @@ import (
 type AllIterator struct {
 	nodes   bool
 	id      uint64
+	after   uint64 // id the iteration starts after
 	buf     []*proto.Primitive
 	prim    *proto.Primitive
 	horizon int64
+	now     int64 // expired quads are skipped
 	tags    graph.Tagger
 	qs      *QuadStore
 	err     error
@@ -51,6 +54,7 @@ func NewAllIterator(nodes bool, qs *QuadStore, cons *constraint) *AllIterator {
 		nodes:   nodes,
 		qs:      qs,
 		horizon: qs.horizon(context.TODO()),
+		now:     time.Now().UnixNano(),
 		uid:     iterator.NextUID(),
 		cons:    cons,
 	}
@@ -61,7 +65,7 @@ func (it *AllIterator) UID() uint64 {
 }
 
 func (it *AllIterator) Reset() {
-	it.id = 0
+	it.id = it.after
 }
 
 func (it *AllIterator) Tagger() *graph.Tagger {
@@ -74,6 +78,7 @@ func (it *AllIterator) TagResults(dst map[string]graph.Value) {
 
 func (it *AllIterator) Clone() graph.Iterator {
 	out := NewAllIterator(it.nodes, it.qs, it.cons)
+	out.after, out.id = it.after, it.after
 	out.tags.CopyFrom(it)
 	return out
 }
@@ -115,6 +120,10 @@ func (it *AllIterator) Next(ctx context.Context) bool {
 			if it.id+1 > uint64(it.horizon) {
 				return false
 			}
+			// the query might be canceled while the iterator is advanced by the parent
+			if it.err = ctx.Err(); it.err != nil {
+				return false
+			}
 			ids := make([]uint64, 0, nextBatch)
 			for i := 0; i < nextBatch; i++ {
 				it.id++
@@ -136,7 +145,7 @@ func (it *AllIterator) Next(ctx context.Context) bool {
 		for ; len(it.buf) > 0; it.buf = it.buf[1:] {
 			p := it.buf[0]
 			it.prim = p
-			if p == nil || p.Deleted {
+			if p == nil || p.Deleted || p.IsExpired(it.now) {
 				continue
 			}
 			it.id = it.prim.ID
@@ -169,7 +178,7 @@ func (it *AllIterator) Contains(ctx context.Context, v graph.Value) bool {
 		return it.id <= uint64(it.horizon)
 	}
 	p, ok := v.(*proto.Primitive)
-	if !ok {
+	if !ok || p.IsExpired(it.now) {
 		return false
 	}
 	it.prim = p
@@ -184,7 +193,18 @@ func (it *AllIterator) Contains(ctx context.Context, v graph.Value) bool {
 }
 
 func (it *AllIterator) Size() (int64, bool) {
-	return it.qs.Size(), false
+	// the number of quads is tracked in metadata, while the number of nodes and quads
+	// with a specific predicate can only be estimated from sampled statistics
+	if it.nodes {
+		if n, ok := it.qs.estimateNodes(); ok {
+			return n, false
+		}
+	} else if it.cons != nil && it.cons.dir == quad.Predicate {
+		if n, ok := it.qs.estimatePredicate(uint64(it.cons.val)); ok {
+			return n, false
+		}
+	}
+	return it.qs.Size(), !it.nodes && it.cons == nil
 }
 
 func (it *AllIterator) String() string {
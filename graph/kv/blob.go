@@ -0,0 +1,246 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+// blobBucket stores values of large nodes, if no external blob store is configured.
+var blobBucket = []byte("blobs")
+
+// blobRef is a prefix of node values that are stored in a blob store. It is followed by a key of the blob.
+// Encoded values never start with a zero byte, since it is not a valid protobuf field tag.
+var blobRef = []byte{0, 'b'}
+
+// BlobStore stores values of large nodes outside of node records. Blobs are addressed by the key of the node value
+// (see "node_hash" option), thus the same key is always assigned the same data.
+//
+// Blobs are written before the transaction that references them is committed and are removed after the node
+// removal is committed, thus a failed write may leave an unused blob behind.
+type BlobStore interface {
+	PutBlob(ctx context.Context, key, data []byte) error
+	// GetBlob returns the data of a blob, or ErrNotFound if the blob does not exist.
+	GetBlob(ctx context.Context, key []byte) ([]byte, error)
+	DelBlob(ctx context.Context, key []byte) error
+}
+
+// BlobStoreFunc creates a blob store from the options of the QuadStore.
+type BlobStoreFunc func(opt graph.Options) (BlobStore, error)
+
+var blobStores = make(map[string]BlobStoreFunc)
+
+// RegisterBlobStore registers a blob store that can be selected with the "blob_store" option.
+func RegisterBlobStore(name string, fnc BlobStoreFunc) {
+	if fnc == nil {
+		panic("BlobStoreFunc must not be nil")
+	}
+	if _, found := blobStores[name]; found {
+		panic(fmt.Sprintf("Already registered blob store %q.", name))
+	}
+	blobStores[name] = fnc
+}
+
+func init() {
+	RegisterBlobStore("fs", func(opt graph.Options) (BlobStore, error) {
+		dir, err := opt.StringKey("blob_path", "")
+		if err != nil {
+			return nil, err
+		} else if dir == "" {
+			return nil, fmt.Errorf("kv: blob_path option must be set for fs blob store")
+		}
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		return FileBlobStore(dir), nil
+	})
+}
+
+// newBlobStore creates a blob store selected by options. It returns nil if blobs are stored in the database.
+func newBlobStore(opt graph.Options) (BlobStore, error) {
+	name, err := opt.StringKey("blob_store", "")
+	if err != nil || name == "" {
+		return nil, err
+	}
+	fnc, ok := blobStores[name]
+	if !ok {
+		return nil, fmt.Errorf("kv: unknown blob store %q", name)
+	}
+	return fnc(opt)
+}
+
+var _ BlobStore = FileBlobStore("")
+
+// FileBlobStore stores blobs as files in a directory. Files are spread across subdirectories
+// named by the first byte of the key.
+type FileBlobStore string
+
+func (s FileBlobStore) path(key []byte) string {
+	name := hex.EncodeToString(key)
+	return filepath.Join(string(s), name[:2], name)
+}
+
+func (s FileBlobStore) PutBlob(ctx context.Context, key, data []byte) error {
+	path := s.path(key)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first, so readers never observe a partial blob
+	f, err := ioutil.TempFile(filepath.Dir(path), ".blob")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (s FileBlobStore) GetBlob(ctx context.Context, key []byte) ([]byte, error) {
+	data, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s FileBlobStore) DelBlob(ctx context.Context, key []byte) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// blobKey returns a key of the blob if the node value is stored in a blob store.
+func blobKey(p *proto.Primitive) ([]byte, bool) {
+	if !bytes.HasPrefix(p.Value, blobRef) {
+		return nil, false
+	}
+	return p.Value[len(blobRef):], true
+}
+
+// putBlob moves a value of the node to a blob store if it is larger than the "blob_threshold" option.
+func (qs *QuadStore) putBlob(ctx context.Context, tx BucketTx, p *proto.Primitive, key []byte) error {
+	if qs.blobThreshold <= 0 || len(p.Value) <= qs.blobThreshold {
+		return nil
+	}
+	var err error
+	if qs.blobs != nil {
+		err = qs.blobs.PutBlob(ctx, key, p.Value)
+	} else {
+		err = tx.Bucket(blobBucket).Put(key, p.Value)
+	}
+	if err != nil {
+		return err
+	}
+	p.Value = append(append([]byte{}, blobRef...), key...)
+	return nil
+}
+
+// delBlobs removes blobs of nodes that are removed in the transaction. Blobs in an external store
+// are removed by flushBlobs after the transaction is committed.
+func (qs *QuadStore) delBlobs(ctx context.Context, tx BucketTx, ids []uint64) error {
+	if qs.blobThreshold <= 0 || len(ids) == 0 {
+		return nil
+	}
+	prims, err := qs.getPrimitivesFromLog(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range prims {
+		if p == nil {
+			continue
+		}
+		key, ok := blobKey(p)
+		if !ok {
+			continue
+		} else if qs.blobs != nil {
+			qs.blobDels = append(qs.blobDels, append([]byte{}, key...))
+			continue
+		}
+		if err = tx.Bucket(blobBucket).Del(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushBlobs removes blobs from an external store after the transaction is committed.
+// If the transaction failed, pending blobs are kept.
+func (qs *QuadStore) flushBlobs(ctx context.Context, committed bool) {
+	dels := qs.blobDels
+	qs.blobDels = nil
+	if !committed {
+		return
+	}
+	for _, key := range dels {
+		if err := qs.blobs.DelBlob(ctx, key); err != nil {
+			clog.Warningf("kv: cannot remove blob %x: %v", key, err)
+		}
+	}
+}
+
+// decodeValue decodes a value of the node primitive, loading it from a blob store if necessary.
+// If tx is nil, a new read transaction is opened to load the blob.
+func (qs *QuadStore) decodeValue(ctx context.Context, tx BucketTx, p *proto.Primitive) (quad.Value, error) {
+	key, ok := blobKey(p)
+	if !ok {
+		return pquads.UnmarshalValue(p.Value)
+	}
+	var (
+		data []byte
+		err  error
+	)
+	if qs.blobs != nil {
+		data, err = qs.blobs.GetBlob(ctx, key)
+	} else if tx != nil {
+		data, err = GetOne(ctx, tx.Bucket(blobBucket), key)
+	} else {
+		err = View(qs.db, func(tx BucketTx) error {
+			v, err := GetOne(ctx, tx.Bucket(blobBucket), key)
+			data = append([]byte{}, v...)
+			return err
+		})
+	}
+	if err == ErrNotFound || err == ErrNoBucket {
+		return nil, fmt.Errorf("kv: blob %x of node %d is missing", key, p.ID)
+	} else if err != nil {
+		return nil, err
+	}
+	return pquads.UnmarshalValue(data)
+}
@@ -0,0 +1,478 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Checker = (*QuadStore)(nil)
+
+// Check verifies that the log, value indexes, reference counters and quad indexes are consistent.
+//
+// Dangling quads are marked as deleted, unused nodes are removed, counters and indexes are rebuilt
+// from the log when repair is set. Records that cannot be decoded are only reported.
+// Checker keeps all quad records in memory, thus it may require a lot of memory for large databases.
+func (qs *QuadStore) Check(ctx context.Context, repair bool) ([]graph.Problem, error) {
+	qs.writer.Lock()
+	defer qs.writer.Unlock()
+
+	c := newChecker(qs)
+	err := View(qs.db, func(tx BucketTx) error {
+		for _, check := range []func(context.Context, BucketTx) error{
+			c.scanLog,
+			c.checkQuads,
+			c.checkNodes,
+			c.checkValueIndex,
+			c.checkQuadIndexes,
+			c.checkCounters,
+		} {
+			if err := check(ctx, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !repair || len(c.fixes) == 0 {
+		return c.problems, nil
+	}
+	err = Update(ctx, qs.db, func(tx BucketTx) error {
+		for _, fix := range c.fixes {
+			if err := fix(ctx, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.problems, err
+	}
+	for _, p := range c.dead {
+		qs.bloomRemove(p)
+	}
+	qs.purgeCaches()
+	for i, ok := range c.fixable {
+		c.problems[i].Repaired = ok
+	}
+	return c.problems, nil
+}
+
+// quadRecord is a compact form of a quad primitive used by the checker.
+type quadRecord struct {
+	dirs    [4]uint64
+	ts      int64
+	deleted bool
+}
+
+func (r quadRecord) primitive(id uint64) *proto.Primitive {
+	p := &proto.Primitive{ID: id, Timestamp: r.ts, Deleted: r.deleted}
+	for i, d := range quad.Directions {
+		p.SetDirection(d, r.dirs[i])
+	}
+	return p
+}
+
+type checker struct {
+	qs *QuadStore
+
+	problems []graph.Problem
+	fixable  []bool
+	fixes    []func(ctx context.Context, tx BucketTx) error
+	dead     []*proto.Primitive // quads that should be removed from bloom filter after repair
+
+	nodes map[uint64]string // keys of node values
+	quads map[uint64]quadRecord
+	refs  map[uint64]int64
+	size  int64  // number of live quads
+	maxID uint64 // largest ID in the log
+}
+
+func newChecker(qs *QuadStore) *checker {
+	return &checker{
+		qs:    qs,
+		nodes: make(map[uint64]string),
+		quads: make(map[uint64]quadRecord),
+		refs:  make(map[uint64]int64),
+	}
+}
+
+// report records a problem found by the checker.
+func (c *checker) report(kind string, fixable bool, format string, args ...interface{}) {
+	c.problems = append(c.problems, graph.Problem{Kind: kind, Desc: fmt.Sprintf(format, args...)})
+	c.fixable = append(c.fixable, fixable)
+}
+
+// fix schedules a repair of the database. It always returns true to be used as an argument of report.
+func (c *checker) fix(f func(ctx context.Context, tx BucketTx) error) bool {
+	c.fixes = append(c.fixes, f)
+	return true
+}
+
+func sortedIDs(n int, each func(func(id uint64))) []uint64 {
+	ids := make([]uint64, 0, n)
+	each(func(id uint64) {
+		ids = append(ids, id)
+	})
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (c *checker) nodeIDs() []uint64 {
+	return sortedIDs(len(c.nodes), func(fnc func(uint64)) {
+		for id := range c.nodes {
+			fnc(id)
+		}
+	})
+}
+
+func (c *checker) quadIDs() []uint64 {
+	return sortedIDs(len(c.quads), func(fnc func(uint64)) {
+		for id := range c.quads {
+			fnc(id)
+		}
+	})
+}
+
+// eachBucket scans a bucket, ignoring an error if the bucket does not exist.
+func eachBucket(ctx context.Context, tx BucketTx, name []byte, fnc func(k, v []byte) error) error {
+	err := Each(ctx, tx.Bucket(name), nil, fnc)
+	if err == ErrNoBucket || err == ErrNotFound {
+		err = nil
+	}
+	return err
+}
+
+// scanLog loads all node and quad records from the log.
+func (c *checker) scanLog(ctx context.Context, tx BucketTx) error {
+	return eachBucket(ctx, tx, logIndex, func(k, v []byte) error {
+		if len(k) != 8 {
+			c.report(graph.ProblemBadValue, false, "invalid key in the log: %x", k)
+			return nil
+		}
+		id := quadKeyEnc.Uint64(k)
+		if id > c.maxID {
+			c.maxID = id
+		}
+		var p proto.Primitive
+		if err := p.Unmarshal(v); err != nil {
+			c.report(graph.ProblemBadValue, false, "cannot decode record %d: %v", id, err)
+			return nil
+		}
+		if p.IsNode() {
+			val, err := c.qs.decodeValue(ctx, tx, &p)
+			if err != nil {
+				c.report(graph.ProblemBadValue, false, "cannot decode value of node %d: %v", id, err)
+				return nil
+			}
+			c.nodes[id] = string(c.qs.valueKey(val))
+			return nil
+		}
+		var r quadRecord
+		for i, d := range quad.Directions {
+			r.dirs[i] = p.GetDirection(d)
+		}
+		r.ts, r.deleted = p.Timestamp, p.Deleted
+		c.quads[id] = r
+		return nil
+	})
+}
+
+// checkQuads finds quads that reference missing nodes and counts references to nodes.
+func (c *checker) checkQuads(ctx context.Context, tx BucketTx) error {
+	for _, id := range c.quadIDs() {
+		r := c.quads[id]
+		if r.deleted {
+			continue
+		}
+		dangling := false
+		for i, n := range r.dirs {
+			if n == 0 {
+				continue
+			}
+			if _, ok := c.nodes[n]; !ok {
+				c.report(graph.ProblemDanglingRef, c.fix(c.markDead(id, r)),
+					"quad %d references missing %s node %d", id, quad.Directions[i], n)
+				dangling = true
+				break
+			}
+		}
+		if dangling {
+			r.deleted = true
+			c.quads[id] = r
+			continue
+		}
+		c.size++
+		for _, n := range r.dirs {
+			if n != 0 {
+				c.refs[n]++
+			}
+		}
+	}
+	return nil
+}
+
+func (c *checker) markDead(id uint64, r quadRecord) func(context.Context, BucketTx) error {
+	p := r.primitive(id)
+	p.Deleted = true
+	c.dead = append(c.dead, p)
+	return func(ctx context.Context, tx BucketTx) error {
+		return c.qs.addToLog(tx, p)
+	}
+}
+
+// checkNodesBatch is a number of nodes checked by a single request to the database.
+const checkNodesBatch = 1000
+
+// checkNodes verifies value index entries and reference counters of all nodes.
+func (c *checker) checkNodes(ctx context.Context, tx BucketTx) error {
+	ids := c.nodeIDs()
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > checkNodesBatch {
+			batch = batch[:checkNodesBatch]
+		}
+		ids = ids[len(batch):]
+		keys := make([]BucketKey, 0, 2*len(batch))
+		for _, id := range batch {
+			h := c.nodes[id]
+			keys = append(keys, bucketKeyForHash([]byte(h)), bucketKeyForHashRefs([]byte(h)))
+		}
+		vals, err := tx.Get(ctx, keys)
+		if err != nil {
+			return err
+		}
+		for i, id := range batch {
+			c.checkNode(id, vals[2*i], vals[2*i+1])
+		}
+	}
+	return nil
+}
+
+func (c *checker) checkNode(id uint64, index, refs []byte) {
+	h := c.nodes[id]
+	var indexID uint64
+	if len(index) != 0 {
+		indexID, _ = binary.Uvarint(index)
+	}
+	cnt := c.refs[id]
+	if cnt == 0 {
+		c.report(graph.ProblemRefCount, c.fix(c.deleteNode(id, h, indexID == id)), "node %d is not used by any quad", id)
+		return
+	}
+	if indexID == 0 {
+		c.report(graph.ProblemMissingIndex, c.fix(c.putUvarint(bucketKeyForHash([]byte(h)), id)),
+			"node %d is missing in the value index", id)
+	} else if indexID != id {
+		if other, ok := c.nodes[indexID]; ok && other == h && c.refs[indexID] != 0 {
+			c.report(graph.ProblemBadValue, false, "node %d duplicates node %d", id, indexID)
+		} else {
+			c.report(graph.ProblemOrphanIndex, c.fix(c.putUvarint(bucketKeyForHash([]byte(h)), id)),
+				"value index entry for node %d points to %d", id, indexID)
+		}
+	}
+	var stored uint64
+	if len(refs) != 0 {
+		stored, _ = binary.Uvarint(refs)
+	}
+	if int64(stored) != cnt {
+		c.report(graph.ProblemRefCount, c.fix(c.putUvarint(bucketKeyForHashRefs([]byte(h)), uint64(cnt))),
+			"node %d has %d references, expected %d", id, stored, cnt)
+	}
+}
+
+func (c *checker) putUvarint(k BucketKey, v uint64) func(context.Context, BucketTx) error {
+	return func(ctx context.Context, tx BucketTx) error {
+		return tx.Bucket(k.Bucket).Put(k.Key, uint64toBytes(v))
+	}
+}
+
+func (c *checker) deleteNode(id uint64, h string, indexed bool) func(context.Context, BucketTx) error {
+	return func(ctx context.Context, tx BucketTx) error {
+		k := bucketKeyForHashRefs([]byte(h))
+		if err := tx.Bucket(k.Bucket).Del(k.Key); err != nil {
+			return err
+		}
+		if indexed {
+			k = bucketKeyForHash([]byte(h))
+			if err := tx.Bucket(k.Bucket).Del(k.Key); err != nil {
+				return err
+			}
+		}
+		return c.qs.delLog(tx, id)
+	}
+}
+
+// checkValueIndex finds value index entries and reference counters for values that are not in the log.
+func (c *checker) checkValueIndex(ctx context.Context, tx BucketTx) error {
+	hashes := make(map[string]struct{}, len(c.nodes))
+	for _, h := range c.nodes {
+		hashes[h] = struct{}{}
+	}
+	for i := 0; i < 256; i++ {
+		for j := 0; j < 256; j++ {
+			for _, b := range []struct {
+				name []byte
+				desc string
+			}{
+				{bucketForVal(byte(i), byte(j)), "value index"},
+				{bucketForValRefs(byte(i), byte(j)), "reference counter"},
+			} {
+				name := b.name
+				err := eachBucket(ctx, tx, name, func(k, _ []byte) error {
+					if _, ok := hashes[string(k)]; ok {
+						return nil
+					}
+					key := append([]byte{}, k...)
+					c.report(graph.ProblemOrphanIndex, c.fix(func(ctx context.Context, tx BucketTx) error {
+						return tx.Bucket(name).Del(key)
+					}), "%s entry %x has no node", b.desc, key)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// indexFix is a set of changes to a single entry of a quad index.
+type indexFix struct {
+	bucket, key []byte
+	del         map[uint64]struct{}
+	add         []uint64
+}
+
+func (f *indexFix) apply(ctx context.Context, tx BucketTx) error {
+	b := tx.Bucket(f.bucket)
+	vals, err := b.Get(ctx, [][]byte{f.key})
+	if err != nil {
+		return err
+	}
+	var list []uint64
+	if len(vals) != 0 && len(vals[0]) != 0 {
+		if list, err = decodeIndex(vals[0]); err != nil {
+			list = nil
+		}
+	}
+	out := make([]uint64, 0, len(list)+len(f.add))
+	for _, id := range append(list, f.add...) {
+		if _, ok := f.del[id]; !ok {
+			out = append(out, id)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	uniq := out[:0]
+	for i, id := range out {
+		if i == 0 || id != out[i-1] {
+			uniq = append(uniq, id)
+		}
+	}
+	if len(uniq) == 0 {
+		return b.Del(f.key)
+	}
+	return b.Put(f.key, appendIndex(nil, uniq))
+}
+
+// checkQuadIndexes verifies that all quad indexes point to existing quads and contain all live quads.
+func (c *checker) checkQuadIndexes(ctx context.Context, tx BucketTx) error {
+	c.qs.indexes.RLock()
+	all := c.qs.indexes.all
+	c.qs.indexes.RUnlock()
+	for _, ind := range all {
+		bucket := ind.Bucket()
+		fixes := make(map[string]*indexFix)
+		fixFor := func(key []byte) *indexFix {
+			f := fixes[string(key)]
+			if f == nil {
+				f = &indexFix{bucket: bucket, key: append([]byte{}, key...), del: make(map[uint64]struct{})}
+				fixes[string(key)] = f
+				c.fix(f.apply)
+			}
+			return f
+		}
+		seen := make(map[uint64]struct{}, len(c.quads))
+		err := eachBucket(ctx, tx, bucket, func(k, v []byte) error {
+			list, err := decodeIndex(v)
+			if err != nil {
+				c.report(graph.ProblemBadValue, false, "cannot decode entry %x of index %q: %v", k, bucket, err)
+				return nil
+			}
+			for _, id := range list {
+				if r, ok := c.quads[id]; ok && bytes.Equal(ind.KeyFor(r.primitive(id)), k) {
+					seen[id] = struct{}{}
+					continue
+				}
+				fixFor(k).del[id] = struct{}{}
+				c.report(graph.ProblemOrphanIndex, true, "entry %x of index %q points to a missing quad %d", k, bucket, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range c.quadIDs() {
+			r := c.quads[id]
+			if _, ok := seen[id]; ok || r.deleted {
+				continue
+			}
+			k := ind.KeyFor(r.primitive(id))
+			f := fixFor(k)
+			f.add = append(f.add, id)
+			c.report(graph.ProblemMissingIndex, true, "quad %d is missing in index %q", id, bucket)
+		}
+	}
+	return nil
+}
+
+func (c *checker) putMetaInt(key string, v int64) func(context.Context, BucketTx) error {
+	return func(ctx context.Context, tx BucketTx) error {
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, uint64(v))
+		return tx.Bucket(metaBucket).Put([]byte(key), buf)
+	}
+}
+
+// checkCounters verifies the number of quads and the last issued ID.
+func (c *checker) checkCounters(ctx context.Context, tx BucketTx) error {
+	size, err := c.qs.getMetaIntTx(ctx, tx, "size")
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if size != c.size {
+		c.report(graph.ProblemCounter, c.fix(c.putMetaInt("size", c.size)), "database size is %d, expected %d", size, c.size)
+	}
+	horizon, err := c.qs.getMetaIntTx(ctx, tx, "horizon")
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if uint64(horizon) < c.maxID {
+		c.report(graph.ProblemCounter, c.fix(c.putMetaInt("horizon", int64(c.maxID))),
+			"last issued ID is %d, but the log contains ID %d", horizon, c.maxID)
+	}
+	return nil
+}
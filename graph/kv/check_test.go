@@ -0,0 +1,279 @@
+package kv_test
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+	"github.com/cayleygraph/cayley/writer"
+	"github.com/stretchr/testify/require"
+)
+
+func problemKinds(problems []graph.Problem) map[string]int {
+	out := make(map[string]int)
+	for _, p := range problems {
+		out[p.Kind]++
+	}
+	return out
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.TODO()
+	kdb := btree.New()
+	require.NoError(t, kv.Init(kdb, nil))
+	qs, err := kv.New(kdb, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	w, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	err = w.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("a", "p", "b", ""),
+		quad.MakeIRI("b", "p", "c", ""),
+		quad.MakeIRI("c", "p", "d", ""),
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.RemoveQuad(quad.MakeIRI("c", "p", "d", "")))
+
+	problems, err := graph.Check(ctx, qs, false)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+
+	err = kv.Update(ctx, kdb, func(tx kv.BucketTx) error {
+		var buf [binary.MaxVarintLen64]byte
+		// wrong reference counter
+		n := binary.PutUvarint(buf[:], 5)
+		if err := tx.Bucket([]byte(iric("a"))).Put(irih("a"), buf[:n]); err != nil {
+			return err
+		}
+		// value index entry without a node
+		n = binary.PutUvarint(buf[:], 100)
+		if err := tx.Bucket([]byte(irib("x"))).Put(irih("x"), buf[:n]); err != nil {
+			return err
+		}
+		// wrong size
+		if err := tx.Bucket([]byte("meta")).Put([]byte("size"), le(10)); err != nil {
+			return err
+		}
+		// missing index entries
+		it := tx.Bucket([]byte{quad.Object.Prefix()}).Scan(nil)
+		var keys [][]byte
+		for it.Next(ctx) {
+			keys = append(keys, append([]byte{}, it.Key()...))
+		}
+		it.Close()
+		for _, k := range keys {
+			if err := tx.Bucket([]byte{quad.Object.Prefix()}).Del(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	problems, err = graph.Check(ctx, qs, false)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{
+		graph.ProblemRefCount:     1,
+		graph.ProblemOrphanIndex:  1,
+		graph.ProblemCounter:      1,
+		graph.ProblemMissingIndex: 2,
+	}, problemKinds(problems), "%v", problems)
+	for _, p := range problems {
+		require.False(t, p.Repaired)
+	}
+
+	problems, err = graph.Check(ctx, qs, true)
+	require.NoError(t, err)
+	require.Len(t, problems, 5)
+	for _, p := range problems {
+		require.True(t, p.Repaired, "%v", p)
+	}
+
+	problems, err = graph.Check(ctx, qs, false)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+	require.Equal(t, int64(2), qs.Size())
+
+	it := qs.QuadIterator(quad.Object, qs.ValueOf(quad.IRI("c")))
+	defer it.Close()
+	require.True(t, it.Next(ctx))
+	require.Equal(t, quad.MakeIRI("b", "p", "c", ""), qs.Quad(it.Result()))
+}
+
+func TestDedup(t *testing.T) {
+	ctx := context.TODO()
+	kdb := btree.New()
+	require.NoError(t, kv.Init(kdb, nil))
+	qs, err := kv.New(kdb, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	w, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	err = w.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("a", "p", "b", ""),
+		quad.MakeIRI("b", "p", "c", ""),
+	})
+	require.NoError(t, err)
+
+	st, err := graph.Dedup(ctx, qs, true)
+	require.NoError(t, err)
+	require.Equal(t, graph.DedupStats{}, st)
+
+	err = kv.Update(ctx, kdb, func(tx kv.BucketTx) error {
+		var dup *proto.Primitive
+		it := tx.Bucket([]byte("log")).Scan(nil)
+		for dup == nil && it.Next(ctx) {
+			var p proto.Primitive
+			if err := p.Unmarshal(it.Val()); err != nil {
+				it.Close()
+				return err
+			}
+			if p.Subject != 0 {
+				dup = &p
+			}
+		}
+		it.Close()
+		require.NotNil(t, dup)
+		// duplicate of an existing quad
+		dup.ID = 100
+		data, err := dup.Marshal()
+		if err != nil {
+			return err
+		}
+		if err = tx.Bucket([]byte("log")).Put(be(100), data); err != nil {
+			return err
+		}
+		// node without references
+		value, err := pquads.MarshalValue(quad.IRI("x"))
+		if err != nil {
+			return err
+		}
+		orphan := proto.Primitive{ID: 101, Value: value}
+		if data, err = orphan.Marshal(); err != nil {
+			return err
+		}
+		if err = tx.Bucket([]byte("log")).Put(be(101), data); err != nil {
+			return err
+		}
+		var buf [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(buf[:], 101)
+		if err = tx.Bucket([]byte(irib("x"))).Put(irih("x"), buf[:n]); err != nil {
+			return err
+		}
+		n = binary.PutUvarint(buf[:], 0)
+		if err = tx.Bucket([]byte(iric("x"))).Put(irih("x"), buf[:n]); err != nil {
+			return err
+		}
+		// counters as if the duplicate was written by the quad store
+		for _, s := range []string{"a", "p", "b"} {
+			refs := map[string]uint64{"a": 2, "p": 3, "b": 3}[s]
+			n = binary.PutUvarint(buf[:], refs)
+			if err = tx.Bucket([]byte(iric(s))).Put(irih(s), buf[:n]); err != nil {
+				return err
+			}
+		}
+		meta := tx.Bucket([]byte("meta"))
+		if err = meta.Put([]byte("size"), le(3)); err != nil {
+			return err
+		}
+		return meta.Put([]byte("horizon"), le(101))
+	})
+	require.NoError(t, err)
+
+	st, err = graph.Dedup(ctx, qs, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), st.Quads)
+	require.Equal(t, int64(1), st.Nodes)
+	require.True(t, st.Bytes > 0)
+
+	st2, err := graph.Dedup(ctx, qs, false)
+	require.NoError(t, err)
+	require.Equal(t, st, st2)
+
+	problems, err := graph.Check(ctx, qs, false)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+	require.Equal(t, int64(2), qs.Size())
+
+	st, err = graph.Dedup(ctx, qs, true)
+	require.NoError(t, err)
+	require.Equal(t, graph.DedupStats{}, st)
+}
+
+func TestGC(t *testing.T) {
+	ctx := context.TODO()
+	kdb := btree.New()
+	require.NoError(t, kv.Init(kdb, nil))
+	qs, err := kv.New(kdb, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	w, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	err = w.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("a", "p", "b", ""),
+		quad.MakeIRI("b", "p", "c", ""),
+	})
+	require.NoError(t, err)
+
+	st, err := graph.GC(ctx, qs, 0, true)
+	require.NoError(t, err)
+	require.Equal(t, graph.GCStats{}, st)
+
+	err = kv.Update(ctx, kdb, func(tx kv.BucketTx) error {
+		var buf [binary.MaxVarintLen64]byte
+		for i, s := range []string{"x", "y"} {
+			id := uint64(101 + i)
+			value, err := pquads.MarshalValue(quad.IRI(s))
+			if err != nil {
+				return err
+			}
+			data, err := (&proto.Primitive{ID: id, Value: value}).Marshal()
+			if err != nil {
+				return err
+			}
+			if err = tx.Bucket([]byte("log")).Put(be(id), data); err != nil {
+				return err
+			}
+			n := binary.PutUvarint(buf[:], id)
+			if err = tx.Bucket([]byte(irib(s))).Put(irih(s), buf[:n]); err != nil {
+				return err
+			}
+		}
+		// counter of the first node is left as zero, the second one has no counter
+		n := binary.PutUvarint(buf[:], 0)
+		if err := tx.Bucket([]byte(iric("x"))).Put(irih("x"), buf[:n]); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("meta")).Put([]byte("horizon"), le(102))
+	})
+	require.NoError(t, err)
+
+	st, err = graph.GC(ctx, qs, 1, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), st.Nodes)
+	require.True(t, st.Bytes > 0)
+
+	st2, err := graph.GC(ctx, qs, 1, false)
+	require.NoError(t, err)
+	require.Equal(t, st.Nodes, st2.Nodes)
+	require.Equal(t, st.Bytes, st2.Bytes)
+	require.Equal(t, int64(2), st2.Batches)
+
+	problems, err := graph.Check(ctx, qs, false)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+	require.Equal(t, int64(2), qs.Size())
+
+	st, err = graph.GC(ctx, qs, 0, true)
+	require.NoError(t, err)
+	require.Equal(t, graph.GCStats{}, st)
+}
@@ -0,0 +1,194 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var _ graph.Deduplicator = (*QuadStore)(nil)
+
+// Dedup removes live quads that have the same values as a quad with a lower ID, and nodes
+// that are not referenced by any live quad. Records of removed quads are deleted from the log
+// and from quad indexes, and reference counters of their nodes are updated.
+//
+// Reclaimed space is calculated as a total size of removed log records and value index entries.
+// Same as Check, it keeps all quad records in memory.
+func (qs *QuadStore) Dedup(ctx context.Context, dryRun bool) (graph.DedupStats, error) {
+	qs.writer.Lock()
+	defer qs.writer.Unlock()
+
+	c := newChecker(qs)
+	d := &deduper{c: c}
+	err := View(qs.db, func(tx BucketTx) error {
+		if err := c.scanLog(ctx, tx); err != nil {
+			return err
+		}
+		d.findDuplicates()
+		return d.findOrphans(ctx, tx)
+	})
+	if err != nil || dryRun || len(c.fixes) == 0 {
+		return d.st, err
+	}
+	err = Update(ctx, qs.db, func(tx BucketTx) error {
+		for _, fix := range c.fixes {
+			if err := fix(ctx, tx); err != nil {
+				return err
+			}
+		}
+		return qs.incSize(ctx, tx, -d.st.Quads)
+	})
+	if err != nil {
+		return graph.DedupStats{}, err
+	}
+	qs.purgeCaches()
+	return d.st, nil
+}
+
+// deduper finds duplicate quads and unused nodes using records loaded by checker,
+// and schedules their removal as checker fixes.
+type deduper struct {
+	c  *checker
+	st graph.DedupStats
+
+	dups []uint64 // IDs of duplicate quads
+	// refs is a number of references to each value key from quads that are kept.
+	refs map[string]int64
+	// changed is a set of value keys with reference counters affected by removed quads.
+	changed map[string]struct{}
+}
+
+// findDuplicates finds live quads with the same values and counts references from the remaining ones.
+func (d *deduper) findDuplicates() {
+	c := d.c
+	d.refs = make(map[string]int64)
+	d.changed = make(map[string]struct{})
+	seen := make(map[[4]uint64]struct{}, len(c.quads))
+	for _, id := range c.quadIDs() {
+		r := c.quads[id]
+		if r.deleted {
+			continue
+		}
+		if _, ok := seen[r.dirs]; !ok {
+			seen[r.dirs] = struct{}{}
+			for _, n := range r.dirs {
+				if h, ok := c.nodes[n]; ok {
+					c.refs[n]++
+					d.refs[h]++
+				}
+			}
+			continue
+		}
+		d.dups = append(d.dups, id)
+		for _, n := range r.dirs {
+			if h, ok := c.nodes[n]; ok {
+				d.changed[h] = struct{}{}
+			}
+		}
+	}
+	d.st.Quads = int64(len(d.dups))
+	if len(d.dups) == 0 {
+		return
+	}
+	for _, id := range d.dups {
+		id := id
+		c.fix(func(ctx context.Context, tx BucketTx) error {
+			if err := c.qs.delProvenance(tx, id); err != nil {
+				return err
+			}
+			return c.qs.delLog(tx, id)
+		})
+	}
+	c.qs.indexes.RLock()
+	all := c.qs.indexes.all
+	c.qs.indexes.RUnlock()
+	for _, ind := range all {
+		fixes := make(map[string]*indexFix)
+		for _, id := range d.dups {
+			k := ind.KeyFor(c.quads[id].primitive(id))
+			f := fixes[string(k)]
+			if f == nil {
+				f = &indexFix{bucket: ind.Bucket(), key: k, del: make(map[uint64]struct{})}
+				fixes[string(k)] = f
+				c.fix(f.apply)
+			}
+			f.del[id] = struct{}{}
+		}
+	}
+}
+
+// findOrphans finds nodes without references and schedules updates of reference counters.
+func (d *deduper) findOrphans(ctx context.Context, tx BucketTx) error {
+	c := d.c
+	var orphans []uint64
+	for _, id := range c.nodeIDs() {
+		if c.refs[id] == 0 {
+			orphans = append(orphans, id)
+		}
+	}
+	d.st.Nodes = int64(len(orphans))
+	// live is a node that owns a value key after the removal
+	live := make(map[string]uint64)
+	for id, h := range c.nodes {
+		if c.refs[id] != 0 {
+			live[h] = id
+		}
+	}
+	if len(d.dups) != 0 {
+		keys := make([]BucketKey, 0, len(d.dups))
+		for _, id := range d.dups {
+			keys = append(keys, BucketKey{Bucket: logIndex, Key: uint64KeyBytes(id)})
+		}
+		vals, err := tx.Get(ctx, keys)
+		if err != nil {
+			return err
+		}
+		for i := range d.dups {
+			d.st.Bytes += int64(len(keys[i].Key) + len(vals[i]))
+		}
+	}
+	nodes := make([]orphanNode, 0, len(orphans))
+	for _, id := range orphans {
+		nodes = append(nodes, orphanNode{id: id, hash: c.nodes[id]})
+	}
+	recs, err := readOrphans(ctx, tx, nodes)
+	if err != nil {
+		return err
+	}
+	for _, r := range recs {
+		id, h := r.id, r.hash
+		d.st.Bytes += r.log
+		other, used := live[h]
+		if !used {
+			// value is not used at all, remove index entries as well
+			d.st.Bytes += r.value
+			c.fix(c.deleteNode(id, h, r.indexed))
+			delete(d.changed, h)
+			continue
+		}
+		if r.indexed {
+			c.fix(c.putUvarint(bucketKeyForHash([]byte(h)), other))
+		}
+		c.fix(func(ctx context.Context, tx BucketTx) error {
+			return c.qs.delLog(tx, id)
+		})
+	}
+	for h := range d.changed {
+		c.fix(c.putUvarint(bucketKeyForHashRefs([]byte(h)), uint64(d.refs[h])))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+var _ graph.DeltaLog = (*QuadStore)(nil)
+
+// deltaLogBatch is a number of log entries fetched in a single read.
+const deltaLogBatch = 256
+
+// putLogEntry records applied deltas in the delta log, keyed by the horizon of the transaction.
+//
+// An entry is a sequence of length-prefixed LogDelta messages.
+func (qs *QuadStore) putLogEntry(tx BucketTx, h int64, ts time.Time, deltas []graph.Delta) error {
+	var (
+		buf []byte
+		tmp [binary.MaxVarintLen64]byte
+	)
+	for _, d := range deltas {
+		ld := proto.LogDelta{
+			ID:        uint64(h),
+			Quad:      pquads.MakeQuad(d.Quad),
+			Action:    int32(d.Action),
+			Timestamp: ts.UnixNano(),
+		}
+		data, err := ld.Marshal()
+		if err != nil {
+			return err
+		}
+		n := binary.PutUvarint(tmp[:], uint64(len(data)))
+		buf = append(buf, tmp[:n]...)
+		buf = append(buf, data...)
+	}
+	return tx.Bucket(deltaLogIndex).Put(uint64KeyBytes(uint64(h)), buf)
+}
+
+func decodeLogEntry(h int64, data []byte) (graph.LogEntry, error) {
+	e := graph.LogEntry{Horizon: h}
+	for len(data) != 0 {
+		sz, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < sz {
+			return e, fmt.Errorf("kv: corrupted delta log entry %d", h)
+		}
+		data = data[n:]
+		var ld proto.LogDelta
+		if err := ld.Unmarshal(data[:sz]); err != nil {
+			return e, err
+		}
+		data = data[sz:]
+		e.Timestamp = time.Unix(0, ld.Timestamp)
+		e.Deltas = append(e.Deltas, graph.Delta{
+			Quad:   ld.Quad.ToNative(),
+			Action: graph.Procedure(ld.Action),
+		})
+	}
+	return e, nil
+}
+
+// LogEntries implements graph.DeltaLog. The log is only recorded if the store was opened with the
+// "delta_log" option, otherwise graph.ErrNotSupported is returned.
+func (qs *QuadStore) LogEntries(ctx context.Context, from, to int64) ([]graph.LogEntry, error) {
+	if !qs.deltaLog {
+		return nil, graph.ErrNotSupported
+	}
+	if from < 0 {
+		from = 0
+	}
+	var out []graph.LogEntry
+	err := View(qs.db, func(tx BucketTx) error {
+		cur, err := qs.getMetaIntTx(ctx, tx, metaCommits)
+		if err == ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if to > cur {
+			to = cur
+		}
+		b := tx.Bucket(deltaLogIndex)
+		keys := make([][]byte, 0, deltaLogBatch)
+		for start := from + 1; start <= to; start += deltaLogBatch {
+			keys = keys[:0]
+			for h := start; h <= to && len(keys) < deltaLogBatch; h++ {
+				keys = append(keys, uint64KeyBytes(uint64(h)))
+			}
+			vals, err := b.Get(ctx, keys)
+			if err == ErrNoBucket || err == ErrNotFound {
+				// nothing was logged yet
+				return nil
+			} else if err != nil {
+				return err
+			}
+			for i, v := range vals {
+				if v == nil {
+					continue
+				}
+				e, err := decodeLogEntry(start+int64(i), v)
+				if err != nil {
+					return err
+				}
+				out = append(out, e)
+			}
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return out, err
+}
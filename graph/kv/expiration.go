@@ -0,0 +1,91 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+)
+
+var _ graph.ExpiringStore = (*QuadStore)(nil)
+
+// maxExpireRetries is the number of attempts to remove expired quads if the store is modified concurrently.
+const maxExpireRetries = 3
+
+// ApplyDeltasWithExpiration implements graph.ExpiringStore.
+func (qs *QuadStore) ApplyDeltasWithExpiration(in []graph.Delta, ignoreOpts graph.IgnoreOpts, expires time.Time) error {
+	return qs.applyDeltas(in, ignoreOpts, -1, nil, expires.UnixNano(), nil)
+}
+
+// ExpireQuads implements graph.ExpiringStore.
+//
+// Expired quads are removed the same way as with ApplyDeltas, thus node reference counters are updated
+// and subscribers are notified about removed quads.
+func (qs *QuadStore) ExpireQuads(ctx context.Context, now time.Time) (int, error) {
+	ts := now.UnixNano()
+	var err error
+	for i := 0; i < maxExpireRetries; i++ {
+		var (
+			h      int64
+			deltas []graph.Delta
+		)
+		err = View(qs.db, func(tx BucketTx) error {
+			var err error
+			h, err = qs.getMetaIntTx(ctx, tx, metaCommits)
+			if err == ErrNotFound {
+				err = nil
+			} else if err != nil {
+				return err
+			}
+			var expired []*proto.Primitive
+			err = eachBucket(ctx, tx, logIndex, func(k, v []byte) error {
+				p := new(proto.Primitive)
+				if err := p.Unmarshal(v); err != nil {
+					return nil // reported by Check
+				}
+				if p.IsNode() || p.Deleted || !p.IsExpired(ts) {
+					return nil
+				}
+				expired = append(expired, p)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, p := range expired {
+				q, err := qs.primitiveToQuad(ctx, tx, p)
+				if err != nil {
+					return err
+				}
+				deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Delete})
+			}
+			return nil
+		})
+		if err != nil || len(deltas) == 0 {
+			return 0, err
+		}
+		err = qs.applyDeltas(deltas, graph.IgnoreOpts{IgnoreMissing: true}, h, nil, 0, nil)
+		if _, ok := err.(*graph.HorizonConflictError); ok {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		return len(deltas), nil
+	}
+	return 0, err
+}
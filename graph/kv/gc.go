@@ -0,0 +1,218 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+)
+
+var _ graph.GarbageCollector = (*QuadStore)(nil)
+
+// GC implements graph.GarbageCollector. Node records are removed if their value has no reference counter,
+// or the counter is zero. Reference counters are trusted, thus Check should be used first if they may be wrong.
+//
+// The log is scanned without blocking writes. Found nodes are removed in batches, each in a separate
+// transaction, and are checked again before the removal, since they may be used by quads written after the scan.
+// Reclaimed space is calculated as a total size of removed log records, value index entries and counters.
+func (qs *QuadStore) GC(ctx context.Context, batch int, dryRun bool) (graph.GCStats, error) {
+	if batch <= 0 {
+		batch = graph.DefaultGCBatch
+	}
+	var (
+		st      graph.GCStats
+		orphans []orphanNode
+	)
+	err := View(qs.db, func(tx BucketTx) error {
+		var nodes []orphanNode
+		flush := func() error {
+			found, bytes, err := qs.findOrphans(ctx, tx, nodes)
+			if err != nil {
+				return err
+			}
+			orphans = append(orphans, found...)
+			st.Bytes += bytes
+			nodes = nodes[:0]
+			return nil
+		}
+		err := eachBucket(ctx, tx, logIndex, func(k, v []byte) error {
+			if len(k) != 8 {
+				return nil // reported by Check
+			}
+			var p proto.Primitive
+			if err := p.Unmarshal(v); err != nil || !p.IsNode() {
+				return nil
+			}
+			val, err := qs.decodeValue(ctx, tx, &p)
+			if err != nil {
+				return nil // reported by Check
+			}
+			nodes = append(nodes, orphanNode{id: quadKeyEnc.Uint64(k), hash: string(qs.valueKey(val))})
+			if len(nodes) >= batch {
+				return flush()
+			}
+			return nil
+		})
+		if err != nil || len(nodes) == 0 {
+			return err
+		}
+		return flush()
+	})
+	st.Nodes = int64(len(orphans))
+	if err != nil || dryRun || len(orphans) == 0 {
+		return st, err
+	}
+	st = graph.GCStats{}
+	for len(orphans) != 0 {
+		n := batch
+		if n > len(orphans) {
+			n = len(orphans)
+		}
+		cnt, bytes, err := qs.removeOrphans(ctx, orphans[:n])
+		if err != nil {
+			return st, err
+		}
+		orphans = orphans[n:]
+		st.Nodes += cnt
+		st.Bytes += bytes
+		st.Batches++
+	}
+	qs.purgeCaches()
+	return st, nil
+}
+
+// orphanNode is a node record that may be removed if it has no references.
+type orphanNode struct {
+	id   uint64
+	hash string
+}
+
+// orphanRecords are records of a node that is removed together with its log record.
+type orphanRecords struct {
+	orphanNode
+	// found is set if the log record of the node exists.
+	found bool
+	// indexed is set if the value index points to this node.
+	indexed bool
+	// refs is a reference counter of the value. It is zero if the counter is missing.
+	refs uint64
+	// log and value are sizes of the log record and of value index entries (including the counter) of the node.
+	log, value int64
+}
+
+// readOrphans reads the log record, the value index entry and the reference counter of each node.
+// It is shared by GC and Dedup, which decide differently whether a node is unused.
+func readOrphans(ctx context.Context, tx BucketTx, nodes []orphanNode) ([]orphanRecords, error) {
+	keys := make([]BucketKey, 0, 3*len(nodes))
+	for _, n := range nodes {
+		keys = append(keys,
+			BucketKey{Bucket: logIndex, Key: uint64KeyBytes(n.id)},
+			bucketKeyForHash([]byte(n.hash)), bucketKeyForHashRefs([]byte(n.hash)),
+		)
+	}
+	vals, err := tx.Get(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]orphanRecords, 0, len(nodes))
+	for i, n := range nodes {
+		k, v := keys[3*i:3*i+3], vals[3*i:3*i+3]
+		r := orphanRecords{orphanNode: n, found: v[0] != nil}
+		if r.found {
+			r.log = int64(len(k[0].Key) + len(v[0]))
+		}
+		if len(v[1]) != 0 {
+			id, _ := binary.Uvarint(v[1])
+			r.indexed = id == n.id
+		}
+		if len(v[2]) != 0 {
+			r.refs, _ = binary.Uvarint(v[2])
+		}
+		for j := 1; j < 3; j++ {
+			if v[j] != nil {
+				r.value += int64(len(k[j].Key) + len(v[j]))
+			}
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// unreferenced checks if GC can remove the node: it must still exist and its value must have no references.
+func (r *orphanRecords) unreferenced() bool {
+	return r.found && r.refs == 0
+}
+
+// findOrphans returns nodes without references and the size of their records.
+func (qs *QuadStore) findOrphans(ctx context.Context, tx BucketTx, nodes []orphanNode) ([]orphanNode, int64, error) {
+	recs, err := readOrphans(ctx, tx, nodes)
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		out   []orphanNode
+		bytes int64
+	)
+	for _, r := range recs {
+		if r.unreferenced() {
+			out = append(out, r.orphanNode)
+			bytes += r.log + r.value
+		}
+	}
+	return out, bytes, nil
+}
+
+// removeOrphans removes nodes that still have no references in a single transaction.
+func (qs *QuadStore) removeOrphans(ctx context.Context, nodes []orphanNode) (cnt, bytes int64, _ error) {
+	qs.writer.Lock()
+	defer qs.writer.Unlock()
+	c := newChecker(qs)
+	err := Update(ctx, qs.db, func(tx BucketTx) error {
+		recs, err := readOrphans(ctx, tx, nodes)
+		if err != nil {
+			return err
+		}
+		var ids []uint64
+		for _, r := range recs {
+			if !r.unreferenced() {
+				continue
+			}
+			ids = append(ids, r.id)
+			cnt++
+			bytes += r.log + r.value
+			c.fix(c.deleteNode(r.id, r.hash, r.indexed))
+		}
+		// blobs are found by log records, thus they must be removed first
+		if err := qs.delBlobs(ctx, tx, ids); err != nil {
+			return err
+		}
+		for _, fix := range c.fixes {
+			if err := fix(ctx, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if qs.blobs != nil {
+		qs.flushBlobs(ctx, err == nil)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return cnt, bytes, nil
+}
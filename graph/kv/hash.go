@@ -0,0 +1,207 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	graphlog "github.com/cayleygraph/cayley/graph/log"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DefaultNodeHash is the name of the hash function used for keys of node values by default.
+// Databases created before the hash function became configurable use it as well.
+const DefaultNodeHash = "sha1"
+
+// metaNodeHash is a key in the meta bucket with the hash function used by the database.
+// It is only written if the database does not use the default hash function.
+const metaNodeHash = "node_hash"
+
+// NodeHash is a hash function used to calculate keys of node values in the value index.
+type NodeHash struct {
+	Name string
+	// Keyed is set for hash functions that require a secret key, set with the "node_hash_key" option.
+	Keyed bool
+	// New returns a new hash state. The key is nil for hash functions that are not keyed.
+	New func(key []byte) hash.Hash
+}
+
+var nodeHashes = make(map[string]NodeHash)
+
+// RegisterNodeHash registers a hash function that can be selected with the "node_hash" option.
+func RegisterNodeHash(h NodeHash) {
+	if h.New == nil {
+		panic("New must not be nil")
+	}
+	if _, found := nodeHashes[h.Name]; found {
+		panic(fmt.Sprintf("Already registered node hash %q.", h.Name))
+	}
+	nodeHashes[h.Name] = h
+}
+
+// NodeHashes returns names of all registered hash functions.
+func NodeHashes() []string {
+	out := make([]string, 0, len(nodeHashes))
+	for name := range nodeHashes {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func init() {
+	RegisterNodeHash(NodeHash{Name: "sha1", New: func([]byte) hash.Hash { return sha1.New() }})
+	RegisterNodeHash(NodeHash{Name: "sha256", New: func([]byte) hash.Hash { return sha256.New() }})
+	RegisterNodeHash(NodeHash{Name: "sha512", New: func([]byte) hash.Hash { return sha512.New() }})
+	RegisterNodeHash(NodeHash{Name: "hmac-sha256", Keyed: true, New: func(key []byte) hash.Hash {
+		return hmac.New(sha256.New, key)
+	}})
+}
+
+// nodeHashRecord is a hash function recorded in the meta bucket.
+type nodeHashRecord struct {
+	Name string `json:"name"`
+	// Check is a hash of an empty value, used to verify the secret key of keyed hash functions.
+	Check string `json:"check,omitempty"`
+}
+
+// nodeHasher calculates keys of node values with a given hash function.
+type nodeHasher struct {
+	name  string
+	keyed bool
+	pool  sync.Pool
+}
+
+func newNodeHasher(opt graph.Options) (*nodeHasher, error) {
+	name, err := opt.StringKey("node_hash", DefaultNodeHash)
+	if err != nil {
+		return nil, err
+	}
+	key, err := opt.StringKey("node_hash_key", "")
+	if err != nil {
+		return nil, err
+	}
+	nh, ok := nodeHashes[name]
+	if !ok {
+		return nil, fmt.Errorf("kv: unknown node hash %q", name)
+	} else if nh.Keyed && key == "" {
+		return nil, fmt.Errorf("kv: node hash %q requires node_hash_key option", name)
+	} else if !nh.Keyed && key != "" {
+		return nil, fmt.Errorf("kv: node hash %q is not keyed, but node_hash_key option is set", name)
+	}
+	h := &nodeHasher{name: name, keyed: nh.Keyed}
+	var bkey []byte
+	if nh.Keyed {
+		bkey = []byte(key)
+	}
+	h.pool.New = func() interface{} { return nh.New(bkey) }
+	return h, nil
+}
+
+// sum calculates a key of a node value.
+func (h *nodeHasher) sum(v quad.Value) []byte {
+	s := h.pool.Get().(hash.Hash)
+	defer h.pool.Put(s)
+	s.Reset()
+	if v != nil {
+		s.Write([]byte(v.String()))
+	}
+	return s.Sum(nil)
+}
+
+func (h *nodeHasher) record() nodeHashRecord {
+	r := nodeHashRecord{Name: h.name}
+	if h.keyed {
+		r.Check = hex.EncodeToString(h.sum(nil))
+	}
+	return r
+}
+
+// putNodeHash records the hash function in the meta bucket, unless it is the default one.
+func putNodeHash(ctx context.Context, kv BucketKV, h *nodeHasher) error {
+	if h.name == DefaultNodeHash {
+		return nil
+	}
+	data, err := json.Marshal(h.record())
+	if err != nil {
+		return err
+	}
+	return Update(ctx, kv, func(tx BucketTx) error {
+		return tx.Bucket(metaBucket).Put([]byte(metaNodeHash), data)
+	})
+}
+
+// openNodeHash returns a hasher for the database, checking that options match the recorded hash function.
+// The "node_hash" option can be omitted, while the key of a keyed hash function must always be set.
+func openNodeHash(ctx context.Context, kv BucketKV, opt graph.Options) (*nodeHasher, error) {
+	rec := nodeHashRecord{Name: DefaultNodeHash}
+	err := View(kv, func(tx BucketTx) error {
+		data, err := GetOne(ctx, tx.Bucket(metaBucket), []byte(metaNodeHash))
+		if err == ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, &rec)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kv: cannot read node hash: %v", err)
+	}
+	if name, err := opt.StringKey("node_hash", rec.Name); err != nil {
+		return nil, err
+	} else if name != rec.Name {
+		return nil, fmt.Errorf("kv: database uses %q node hash, but %q is set", rec.Name, name)
+	}
+	hopt := graph.Options{"node_hash": rec.Name}
+	if key, ok := opt["node_hash_key"]; ok {
+		hopt["node_hash_key"] = key
+	}
+	h, err := newNodeHasher(hopt)
+	if err != nil {
+		return nil, err
+	}
+	if h.record() != rec {
+		return nil, fmt.Errorf("kv: node_hash_key does not match the database")
+	}
+	return h, nil
+}
+
+// valueKey returns a key of a node value in the value index.
+func (qs *QuadStore) valueKey(v quad.Value) []byte {
+	if qs.hasher == nil || qs.hasher.name == DefaultNodeHash {
+		return quad.HashOf(v)
+	}
+	return qs.hasher.sum(v)
+}
+
+// updateKey is the same as valueKey, but reuses a hash of the node calculated for the update if possible.
+func (qs *QuadStore) updateKey(d *graphlog.NodeUpdate) []byte {
+	if qs.hasher == nil || qs.hasher.name == DefaultNodeHash {
+		h := d.Hash
+		return h[:]
+	}
+	return qs.hasher.sum(d.Val)
+}
@@ -32,14 +32,25 @@ import (
 	"github.com/tylertreat/BoomFilters"
 )
 
+// metaCommits is a key in the meta bucket with the number of committed write transactions.
+// It is used as a horizon for optimistic concurrency control, since quad IDs do not change on deletes.
+const metaCommits = "commits"
+
+var _ graph.HorizonStore = (*QuadStore)(nil)
+
 var (
-	metaBucket = []byte("meta")
-	logIndex   = []byte("log")
+	metaBucket      = []byte("meta")
+	logIndex        = []byte("log")
+	provenanceIndex = []byte("provenance")
+	deltaLogIndex   = []byte("deltalog")
 
 	// List of all buckets in the current version of the database.
 	buckets = [][]byte{
 		metaBucket,
 		logIndex,
+		provenanceIndex,
+		deltaLogIndex,
+		blobBucket,
 	}
 
 	DefaultQuadIndexes = []QuadIndex{
@@ -147,7 +158,7 @@ func (qs *QuadStore) resolveValDeltas(ctx context.Context, tx BucketTx, deltas [
 			continue
 		}
 		inds = append(inds, i)
-		keys = append(keys, bucketKeyForHash(d.Hash))
+		keys = append(keys, bucketKeyForHash(qs.updateKey(&deltas[i])))
 	}
 	if len(keys) == 0 {
 		return nil
@@ -225,8 +236,8 @@ type nodeUpdate struct {
 
 func (qs *QuadStore) incNodesCnt(ctx context.Context, tx BucketTx, deltas []nodeUpdate) ([]int, error) {
 	keys := make([]BucketKey, 0, len(deltas))
-	for _, d := range deltas {
-		keys = append(keys, bucketKeyForHashRefs(d.Hash))
+	for i := range deltas {
+		keys = append(keys, bucketKeyForHashRefs(qs.updateKey(&deltas[i].NodeUpdate)))
 	}
 	sizes, err := tx.Get(ctx, keys)
 	if err != nil {
@@ -298,7 +309,10 @@ func (qs *QuadStore) incNodes(ctx context.Context, tx BucketTx, deltas []graphlo
 			}
 			node.ID = id
 			ids[iv.Hash] = resolvedNode{ID: id, New: true}
-			if err := qs.indexNode(tx, node, iv.Val); err != nil {
+			if err := qs.putBlob(ctx, tx, node, qs.updateKey(&ins[i].NodeUpdate)); err != nil {
+				return ids, err
+			}
+			if err := qs.indexNode(ctx, tx, node, iv.Val); err != nil {
 				return ids, err
 			}
 			ins[i].ID = id
@@ -323,15 +337,27 @@ func (qs *QuadStore) decNodes(ctx context.Context, tx BucketTx, deltas []graphlo
 	if err != nil {
 		return err
 	}
+	if qs.blobThreshold > 0 {
+		ids := make([]uint64, 0, len(del))
+		for _, i := range del {
+			ids = append(ids, upds[i].ID)
+		}
+		if err = qs.delBlobs(ctx, tx, ids); err != nil {
+			return err
+		}
+	}
 	for _, i := range del {
 		d := upds[i]
-		bucket := tx.Bucket(bucketForVal(d.Hash[0], d.Hash[1]))
-		if err = bucket.Del(d.Hash[:]); err != nil {
+		k := bucketKeyForHash(qs.updateKey(&d.NodeUpdate))
+		if err = tx.Bucket(k.Bucket).Del(k.Key); err != nil {
 			return err
 		}
 		if iri, ok := d.Val.(quad.IRI); ok {
 			qs.valueLRU.Del(string(iri))
 		}
+		if qs.names != nil {
+			qs.names.Del(nameKey(d.ID))
+		}
 		if err := qs.delLog(tx, d.ID); err != nil {
 			return err
 		}
@@ -340,7 +366,51 @@ func (qs *QuadStore) decNodes(ctx context.Context, tx BucketTx, deltas []graphlo
 }
 
 func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	return qs.applyDeltas(in, ignoreOpts, -1, nil, 0, nil)
+}
+
+// ApplyDeltasAt implements graph.HorizonStore.
+func (qs *QuadStore) ApplyDeltasAt(in []graph.Delta, ignoreOpts graph.IgnoreOpts, h int64) error {
+	if h < 0 {
+		return fmt.Errorf("kv: invalid horizon: %d", h)
+	}
+	return qs.applyDeltas(in, ignoreOpts, h, nil, 0, nil)
+}
+
+// Horizon implements graph.HorizonStore. It returns the number of committed write transactions.
+func (qs *QuadStore) Horizon(ctx context.Context) (int64, error) {
+	var h int64
+	err := View(qs.db, func(tx BucketTx) error {
+		var err error
+		h, err = qs.getMetaIntTx(ctx, tx, metaCommits)
+		if err == ErrNotFound {
+			err = nil
+		}
+		return err
+	})
+	return h, err
+}
+
+// applyDeltas writes deltas in a single transaction. If horizon is not negative, deltas are
+// only applied if the number of committed transactions is equal to it. If prov is set,
+// it is recorded for all added quads. If expires is not zero, added quads expire at this time (in Unix nanoseconds).
+// User metadata records from meta are stored in the same transaction.
+func (qs *QuadStore) applyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts, horizon int64, prov *graph.Provenance, expires int64, meta map[string][]byte) error {
 	ctx := context.TODO()
+	if graph.HasExtendedDeltas(in) {
+		// extended actions are resolved before acquiring the write lock,
+		// and resolved deltas are only applied if the store was not modified after that
+		if horizon >= 0 {
+			deltas, err := graph.ResolveDeltas(ctx, qs, in)
+			if err != nil {
+				return err
+			}
+			return qs.applyDeltas(deltas, ignoreOpts, horizon, prov, expires, meta)
+		}
+		return graph.ApplyResolved(ctx, qs, in, func(deltas []graph.Delta, h int64) error {
+			return qs.applyDeltas(deltas, ignoreOpts, h, prov, expires, meta)
+		})
+	}
 	qs.writer.Lock()
 	defer qs.writer.Unlock()
 	tx, err := qs.db.Tx(true)
@@ -348,29 +418,64 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 		return err
 	}
 	defer tx.Rollback()
+	committed := false
+	if qs.blobs != nil {
+		defer func() { qs.flushBlobs(ctx, committed) }()
+	}
+	if horizon >= 0 {
+		cur, err := qs.getMetaIntTx(ctx, tx, metaCommits)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+		if cur != horizon {
+			return &graph.HorizonConflictError{Expected: horizon, Actual: cur}
+		}
+	}
 	b := tx.Bucket(logIndex)
 	if f, ok := b.(FillBucket); ok {
 		f.SetFillPercent(0.9)
 	}
 
 	deltas := graphlog.SplitDeltas(in)
+	// indexes of deltas that were applied, collected only if there are subscribers or the delta log is enabled
+	var applied []int
+	notify := qs.notify.Active()
+	collect := notify || qs.deltaLog
 	// first add all new nodes
 	nodes, err := qs.incNodes(ctx, tx, deltas.IncNode)
 	if err != nil {
 		return err
 	}
 	deltas.IncNode = nil
+	// resolve all nodes that will be removed; new quads may also reference them
+	// if the transaction removes more quads with the node than it adds
+	var dnodes map[graph.ValueHash]uint64
+	if len(deltas.DecNode) != 0 {
+		dnodes = make(map[graph.ValueHash]uint64, len(deltas.DecNode))
+		if err := qs.resolveValDeltas(ctx, tx, deltas.DecNode, func(i int, id uint64) {
+			dnodes[deltas.DecNode[i].Hash] = id
+		}); err != nil {
+			return err
+		}
+	}
 	// resolve and insert all new quads
 	links := make([]proto.Primitive, 0, len(deltas.QuadAdd))
 	qadd := make(map[[4]uint64]struct{}, len(deltas.QuadAdd))
+	// expired quads that are replaced by new ones
+	var expired []proto.Primitive
+	var expiredNodes map[graph.ValueHash]int
+	tnow := time.Now().UnixNano()
 	for _, q := range deltas.QuadAdd {
 		var link proto.Primitive
 		mustBeNew := false
 		var qkey [4]uint64
 		for i, dir := range quad.Directions {
-			n, ok := nodes[q.Quad.Get(dir)]
+			h := q.Quad.Get(dir)
+			n, ok := nodes[h]
 			if !ok {
-				continue
+				if n.ID, ok = dnodes[h]; !ok || n.ID == 0 {
+					continue
+				}
 			}
 			mustBeNew = mustBeNew || n.New
 			link.SetDirection(dir, n.ID)
@@ -381,18 +486,34 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 		}
 		qadd[qkey] = struct{}{}
 		if !mustBeNew {
-			p, err := qs.hasPrimitive(ctx, tx, &link, false)
+			// read the primitive to check if it's expired
+			p, err := qs.hasPrimitive(ctx, tx, &link, true)
 			if err != nil {
 				return err
 			}
-			if p != nil {
+			if p != nil && p.IsExpired(tnow) {
+				// replace expired quad; it still holds a reference to each node
+				expired = append(expired, *p)
+				if expiredNodes == nil {
+					expiredNodes = make(map[graph.ValueHash]int)
+				}
+				for _, dir := range quad.Directions {
+					if h := q.Quad.Get(dir); h.Valid() {
+						expiredNodes[h]--
+					}
+				}
+			} else if p != nil {
 				if ignoreOpts.IgnoreDup {
 					continue // already exists, no need to insert
 				}
 				return &graph.DeltaError{Delta: in[q.Ind], Err: graph.ErrQuadExists}
 			}
 		}
+		link.Expires = expires
 		links = append(links, link)
+		if collect {
+			applied = append(applied, q.Ind)
+		}
 	}
 	qadd = nil
 	deltas.QuadAdd = nil
@@ -401,24 +522,44 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 	if err != nil {
 		return err
 	}
+	now := time.Now()
+	if prov != nil {
+		now = prov.Time
+	}
 	for i := range links {
 		links[i].ID = qstart + uint64(i)
-		links[i].Timestamp = time.Now().UnixNano()
+		links[i].Timestamp = now.UnixNano()
 	}
 	if err := qs.indexLinks(ctx, tx, links); err != nil {
 		return err
 	}
+	if prov != nil {
+		if err := qs.putProvenance(tx, links, prov); err != nil {
+			return err
+		}
+	}
 	links = links[:0]
-
-	if len(deltas.QuadDel) != 0 || len(deltas.DecNode) != 0 {
-		// resolve all nodes that will be removed
-		dnodes := make(map[graph.ValueHash]uint64, len(deltas.DecNode))
-		if err := qs.resolveValDeltas(ctx, tx, deltas.DecNode, func(i int, id uint64) {
-			dnodes[deltas.DecNode[i].Hash] = id
-		}); err != nil {
+	if len(expired) != 0 {
+		if err := qs.markLinksDead(ctx, tx, expired, now.UnixNano()); err != nil {
+			return err
+		}
+		upds := make([]graphlog.NodeUpdate, 0, len(expiredNodes))
+		ids := make(map[graph.ValueHash]uint64, len(expiredNodes))
+		for h, n := range expiredNodes {
+			upds = append(upds, graphlog.NodeUpdate{Hash: h, RefInc: n})
+			if id := nodes[h].ID; id != 0 {
+				ids[h] = id
+			} else {
+				ids[h] = dnodes[h]
+			}
+		}
+		if err := qs.decNodes(ctx, tx, upds, ids); err != nil {
 			return err
 		}
+		expired, expiredNodes = nil, nil
+	}
 
+	if len(deltas.QuadDel) != 0 || len(deltas.DecNode) != 0 {
 		// check for existence and delete quads
 		fixNodes := make(map[graph.ValueHash]int)
 		for _, q := range deltas.QuadDel {
@@ -461,9 +602,12 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 				continue
 			}
 			links = append(links, link)
+			if collect {
+				applied = append(applied, q.Ind)
+			}
 		}
 		deltas.QuadDel = nil
-		if err := qs.markLinksDead(ctx, tx, links); err != nil {
+		if err := qs.markLinksDead(ctx, tx, links, now.UnixNano()); err != nil {
 			return err
 		}
 		links = nil
@@ -490,20 +634,50 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 	if err != nil {
 		return err
 	}
-	return tx.Commit(ctx)
+	commits, err := qs.incMetaInt(ctx, tx, metaCommits, 1)
+	if err != nil {
+		return err
+	}
+	if qs.deltaLog && len(applied) != 0 {
+		// commits is the horizon before this transaction
+		logged := make([]graph.Delta, 0, len(applied))
+		for _, i := range applied {
+			logged = append(logged, in[i])
+		}
+		if err = qs.putLogEntry(tx, commits+1, now, logged); err != nil {
+			return err
+		}
+	}
+	if len(meta) != 0 {
+		if err = putUserMetadata(tx, meta); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	committed = true
+	if notify && len(applied) != 0 {
+		now := time.Now()
+		changes := make([]graph.Change, 0, len(applied))
+		for _, i := range applied {
+			changes = append(changes, graph.Change{Delta: in[i], Horizon: commits + 1, Timestamp: now})
+		}
+		qs.notify.Notify(changes)
+	}
+	return nil
 }
 
-func (qs *QuadStore) indexNode(tx BucketTx, p *proto.Primitive, val quad.Value) error {
+func (qs *QuadStore) indexNode(ctx context.Context, tx BucketTx, p *proto.Primitive, val quad.Value) error {
 	var err error
 	if val == nil {
-		val, err = pquads.UnmarshalValue(p.Value)
+		val, err = qs.decodeValue(ctx, tx, p)
 		if err != nil {
 			return err
 		}
 	}
-	hash := quad.HashOf(val)
-	bucket := tx.Bucket(bucketForVal(hash[0], hash[1]))
-	err = bucket.Put(hash, uint64toBytes(p.ID))
+	k := bucketKeyForHash(qs.valueKey(val))
+	err = tx.Bucket(k.Bucket).Put(k.Key, uint64toBytes(p.ID))
 	if err != nil {
 		return err
 	}
@@ -540,8 +714,11 @@ func (qs *QuadStore) indexLink(tx BucketTx, p *proto.Primitive) error {
 	return qs.addToLog(tx, p)
 }
 
-func (qs *QuadStore) markAsDead(tx BucketTx, p *proto.Primitive) error {
+// markAsDead marks a quad as deleted. Records of deleted quads are kept in the log with the time of removal,
+// until they are removed by Purge.
+func (qs *QuadStore) markAsDead(tx BucketTx, p *proto.Primitive, ts int64) error {
 	p.Deleted = true
+	p.Timestamp = ts
 	//TODO(barakmich): Add tombstone?
 	qs.bloomRemove(p)
 	return qs.addToLog(tx, p)
@@ -551,9 +728,9 @@ func (qs *QuadStore) delLog(tx BucketTx, id uint64) error {
 	return tx.Bucket(logIndex).Del(uint64KeyBytes(id))
 }
 
-func (qs *QuadStore) markLinksDead(ctx context.Context, tx BucketTx, links []proto.Primitive) error {
+func (qs *QuadStore) markLinksDead(ctx context.Context, tx BucketTx, links []proto.Primitive, ts int64) error {
 	for _, p := range links {
-		if err := qs.markAsDead(tx, &p); err != nil {
+		if err := qs.markAsDead(tx, &p, ts); err != nil {
 			return err
 		}
 	}
@@ -681,7 +858,7 @@ func (qs *QuadStore) hasPrimitive(ctx context.Context, tx BucketTx, p *proto.Pri
 		if err != nil {
 			return nil, err
 		}
-		if prim.IsSameLink(p) {
+		if !prim.Deleted && prim.IsSameLink(p) {
 			return prim, nil
 		}
 	}
@@ -796,22 +973,21 @@ func (qs *QuadStore) resolveQuadValue(ctx context.Context, tx BucketTx, v quad.V
 	return out[0], nil
 }
 
-func bucketKeyForVal(v quad.Value) BucketKey {
-	hash := graph.HashOf(v)
-	return bucketKeyForHash(hash)
+func (qs *QuadStore) bucketKeyForVal(v quad.Value) BucketKey {
+	return bucketKeyForHash(qs.valueKey(v))
 }
 
-func bucketKeyForHash(h graph.ValueHash) BucketKey {
+func bucketKeyForHash(h []byte) BucketKey {
 	return BucketKey{
 		Bucket: bucketForVal(h[0], h[1]),
-		Key:    h[:],
+		Key:    h,
 	}
 }
 
-func bucketKeyForHashRefs(h graph.ValueHash) BucketKey {
+func bucketKeyForHashRefs(h []byte) BucketKey {
 	return BucketKey{
 		Bucket: bucketForValRefs(h[0], h[1]),
-		Key:    h[:],
+		Key:    h,
 	}
 }
 
@@ -829,7 +1005,7 @@ func (qs *QuadStore) resolveQuadValues(ctx context.Context, tx BucketTx, vals []
 			continue
 		}
 		inds = append(inds, i)
-		keys = append(keys, bucketKeyForVal(v))
+		keys = append(keys, qs.bucketKeyForVal(v))
 	}
 	if len(keys) == 0 {
 		return out, nil
@@ -933,6 +1109,10 @@ func (qs *QuadStore) initBloomFilter(ctx context.Context) error {
 func (qs *QuadStore) testBloom(p *proto.Primitive) bool {
 	qs.exists.Lock()
 	defer qs.exists.Unlock()
+	if qs.exists.DeletableBloomFilter == nil {
+		// read-only views have no filter
+		return true
+	}
 	writePrimToBuf(p, qs.exists.buf)
 	return qs.exists.Test(qs.exists.buf)
 }
@@ -5,6 +5,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
 )
 
 var (
@@ -73,6 +75,17 @@ type FlatKV interface {
 	Tx(update bool) (FlatTx, error)
 }
 
+// SnapshotReader is an optional interface for databases that can open read-only transactions observing
+// a consistent snapshot of data, which can be held open while other transactions are committed.
+type SnapshotReader interface {
+	ReadSnapshot() (BucketTx, error)
+}
+
+// FlatSnapshotReader is the same as SnapshotReader, but for flat databases.
+type FlatSnapshotReader interface {
+	ReadSnapshot() (FlatTx, error)
+}
+
 func Update(ctx context.Context, kv BucketKV, update func(tx BucketTx) error) error {
 	tx, err := kv.Tx(true)
 	if err != nil {
@@ -121,6 +134,23 @@ type flatKV struct {
 
 func (kv *flatKV) Type() string { return kv.flat.Type() }
 func (kv *flatKV) Close() error { return kv.flat.Close() }
+func (kv *flatKV) Compact(ctx context.Context) error {
+	if c, ok := kv.flat.(graph.Compactor); ok {
+		return c.Compact(ctx)
+	}
+	return graph.ErrNotSupported
+}
+func (kv *flatKV) ReadSnapshot() (BucketTx, error) {
+	sr, ok := kv.flat.(FlatSnapshotReader)
+	if !ok {
+		return nil, graph.ErrNotSupported
+	}
+	tx, err := sr.ReadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &flatTx{kv: kv.flat, tx: tx, ro: true}, nil
+}
 func (kv *flatKV) Tx(update bool) (BucketTx, error) {
 	tx, err := kv.flat.Tx(update)
 	if err != nil {
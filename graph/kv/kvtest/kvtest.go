@@ -2,8 +2,13 @@ package kvtest
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/graphtest"
@@ -11,6 +16,7 @@ import (
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/kv"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc"
 	"github.com/stretchr/testify/require"
 )
 
@@ -64,6 +70,375 @@ func TestAll(t *testing.T, gen DatabaseFunc, conf *Config) {
 	t.Run("optimize", func(t *testing.T) {
 		testOptimize(t, gen, conf)
 	})
+	t.Run("metadata", func(t *testing.T) {
+		testMetadata(t, gen, conf)
+	})
+	t.Run("namespaces", func(t *testing.T) {
+		testNamespaces(t, gen, conf)
+	})
+	t.Run("delta log", func(t *testing.T) {
+		testDeltaLog(t, gen, conf)
+	})
+	t.Run("purge", func(t *testing.T) {
+		testPurge(t, gen, conf)
+	})
+	t.Run("sampled stats", func(t *testing.T) {
+		testSampledStats(t, gen, conf)
+	})
+	t.Run("node hash", func(t *testing.T) {
+		testNodeHash(t, gen, conf)
+	})
+	t.Run("blobs", func(t *testing.T) {
+		testBlobs(t, gen, conf)
+	})
+}
+
+func testMetadata(t *testing.T, gen DatabaseFunc, _ *Config) {
+	ctx := context.TODO()
+	qs, _, closer := NewQuadStore(t, gen)
+	defer closer()
+
+	v, err := graph.GetMetadata(ctx, qs, "test")
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	err = graph.SetMetadata(ctx, qs, "test", []byte("value"))
+	require.NoError(t, err)
+	v, err = graph.GetMetadata(ctx, qs, "test")
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), v)
+
+	// user records must not clash with internal ones
+	err = graph.SetMetadata(ctx, qs, "size", []byte("value"))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), qs.Size())
+
+	err = graph.SetMetadata(ctx, qs, "test", nil)
+	require.NoError(t, err)
+	v, err = graph.GetMetadata(ctx, qs, "test")
+	require.NoError(t, err)
+	require.Nil(t, v)
+}
+
+func testNamespaces(t *testing.T, gen DatabaseFunc, _ *Config) {
+	ctx := context.TODO()
+	qs, _, closer := NewQuadStore(t, gen)
+	defer closer()
+
+	ex := voc.Namespace{Prefix: "ex:", Full: "http://example.com/"}
+	foaf := voc.Namespace{Prefix: "foaf:", Full: "http://xmlns.com/foaf/0.1/"}
+	require.NoError(t, graph.AddNamespace(ctx, qs, foaf))
+	require.NoError(t, graph.AddNamespace(ctx, qs, ex))
+	list, err := graph.Namespaces(ctx, qs)
+	require.NoError(t, err)
+	require.Equal(t, []voc.Namespace{ex, foaf}, list)
+
+	var ns voc.Namespaces
+	require.NoError(t, graph.LoadNamespaces(ctx, qs, &ns))
+	require.Equal(t, "http://example.com/name", ns.FullIRI("ex:name"))
+
+	require.NoError(t, graph.DeleteNamespace(ctx, qs, "ex:"))
+	require.Equal(t, graph.ErrNamespaceNotExists, graph.DeleteNamespace(ctx, qs, "ex:"))
+	list, err = graph.Namespaces(ctx, qs)
+	require.NoError(t, err)
+	require.Equal(t, []voc.Namespace{foaf}, list)
+}
+
+func testDeltaLog(t *testing.T, gen DatabaseFunc, _ *Config) {
+	ctx := context.TODO()
+	db, opt, closer := gen(t)
+	defer closer()
+	require.NoError(t, kv.Init(db, opt))
+	lopt := graph.Options{"delta_log": true}
+	for k, v := range opt {
+		lopt[k] = v
+	}
+	qs, err := kv.New(db, lopt)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	q1 := quad.MakeIRI("a", "b", "c", "")
+	q2 := quad.MakeIRI("a", "b", "d", "g")
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{
+		{Quad: q1, Action: graph.Add},
+		{Quad: q2, Action: graph.Add},
+	}, graph.IgnoreOpts{}))
+	// ignored deltas are not recorded
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{
+		{Quad: q1, Action: graph.Delete},
+		{Quad: q2, Action: graph.Add},
+	}, graph.IgnoreOpts{IgnoreDup: true}))
+
+	h, err := graph.Horizon(ctx, qs)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), h)
+
+	list, err := graph.LogEntries(ctx, qs, 0, h)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, int64(1), list[0].Horizon)
+	require.Equal(t, []graph.Delta{
+		{Quad: q1, Action: graph.Add},
+		{Quad: q2, Action: graph.Add},
+	}, list[0].Deltas)
+	require.False(t, list[0].Timestamp.IsZero())
+
+	e, err := graph.LogEntryAt(ctx, qs, 2)
+	require.NoError(t, err)
+	require.Equal(t, []graph.Delta{{Quad: q1, Action: graph.Delete}}, e.Deltas)
+
+	_, err = graph.LogEntryAt(ctx, qs, 3)
+	require.Equal(t, graph.ErrNoLogEntry, err)
+}
+
+func testPurge(t *testing.T, gen DatabaseFunc, _ *Config) {
+	ctx := context.TODO()
+	db, opt, closer := gen(t)
+	defer closer()
+	require.NoError(t, kv.Init(db, opt))
+	lopt := graph.Options{"delta_log": true}
+	for k, v := range opt {
+		lopt[k] = v
+	}
+	qs, err := kv.New(db, lopt)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	q1 := quad.MakeIRI("a", "b", "c", "")
+	q2 := quad.MakeIRI("a", "b", "d", "")
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{
+		{Quad: q1, Action: graph.Add},
+		{Quad: q2, Action: graph.Add},
+	}, graph.IgnoreOpts{}))
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{
+		{Quad: q1, Action: graph.Delete},
+	}, graph.IgnoreOpts{}))
+
+	// records of recently deleted quads are kept
+	st, err := graph.Purge(ctx, qs, time.Now().Add(-time.Hour), false)
+	require.NoError(t, err)
+	require.Equal(t, graph.PurgeStats{}, st)
+
+	st, err = graph.Purge(ctx, qs, time.Now().Add(time.Hour), true)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), st.Quads)
+	require.Equal(t, int64(2), st.LogEntries)
+	require.NotZero(t, st.Bytes)
+
+	st, err = graph.Purge(ctx, qs, time.Now().Add(time.Hour), false)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), st.Quads)
+	require.Equal(t, int64(2), st.LogEntries)
+
+	st, err = graph.Purge(ctx, qs, time.Now().Add(time.Hour), true)
+	require.NoError(t, err)
+	require.Equal(t, graph.PurgeStats{}, st)
+
+	list, err := graph.LogEntries(ctx, qs, 0, 2)
+	require.NoError(t, err)
+	require.Empty(t, list)
+	require.Equal(t, int64(1), qs.Size())
+
+	problems, err := graph.Check(ctx, qs, false)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+
+	// purged quad can be added again
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{
+		{Quad: q1, Action: graph.Add},
+	}, graph.IgnoreOpts{}))
+	require.Equal(t, int64(2), qs.Size())
+
+	// tombstones within the retention window are kept
+	lopt["tombstone_retention"] = "1h"
+	qs2, err := kv.New(db, lopt)
+	require.NoError(t, err)
+	require.NoError(t, qs2.ApplyDeltas([]graph.Delta{
+		{Quad: q2, Action: graph.Delete},
+	}, graph.IgnoreOpts{}))
+	st, err = graph.Purge(ctx, qs2, time.Now().Add(time.Hour), true)
+	require.NoError(t, err)
+	require.Equal(t, graph.PurgeStats{}, st)
+}
+
+func testSampledStats(t *testing.T, gen DatabaseFunc, _ *Config) {
+	ctx := context.TODO()
+	db, opt, closer := gen(t)
+	defer closer()
+	require.NoError(t, kv.Init(db, opt))
+	qs, err := kv.New(db, opt)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	var quads []quad.Quad
+	for i := 0; i < 10; i++ {
+		quads = append(quads, quad.Make(quad.IRI("a"), quad.IRI("follows"), i, nil))
+	}
+	quads = append(quads, quad.MakeIRI("a", "name", "b", ""))
+	testutil.MakeWriter(t, qs, nil, quads...)
+
+	predSize := func(qs graph.QuadStore, p string) int64 {
+		it := qs.QuadIterator(quad.Predicate, qs.ValueOf(quad.IRI(p)))
+		defer it.Close()
+		sz, _ := it.Size()
+		return sz
+	}
+	require.NoError(t, graph.RefreshStats(ctx, qs))
+	require.Equal(t, int64(10), predSize(qs, "follows"))
+	require.Equal(t, int64(1), predSize(qs, "name"))
+
+	st, err := graph.StatsOf(ctx, qs, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(14), st.Nodes)
+	require.Equal(t, map[string]int64{"<follows>": 10, "<name>": 1}, st.Predicates)
+	require.Equal(t, map[string]int64{"iri": 4, "int": 10}, st.ValueTypes)
+
+	// statistics are persisted
+	qs2, err := kv.New(db, opt)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), predSize(qs2, "follows"))
+
+	// and are refreshed in background
+	sopt := graph.Options{"stats_interval": "10ms"}
+	for k, v := range opt {
+		sopt[k] = v
+	}
+	qs3, err := kv.New(db, sopt)
+	require.NoError(t, err)
+	defer qs3.Close()
+	testutil.MakeWriter(t, qs, nil, quad.MakeIRI("b", "name", "c", ""))
+	deadline := time.Now().Add(5 * time.Second)
+	for predSize(qs3, "name") != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("statistics were not refreshed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testNodeHash(t *testing.T, gen DatabaseFunc, _ *Config) {
+	ctx := context.TODO()
+	db, opt, closer := gen(t)
+	defer closer()
+	withOpts := func(kvs ...interface{}) graph.Options {
+		o := make(graph.Options)
+		for k, v := range opt {
+			o[k] = v
+		}
+		for i := 0; i < len(kvs); i += 2 {
+			o[kvs[i].(string)] = kvs[i+1]
+		}
+		return o
+	}
+	require.Error(t, kv.Init(db, withOpts("node_hash", "md4")))
+	require.Error(t, kv.Init(db, withOpts("node_hash", "hmac-sha256")))
+	require.NoError(t, kv.Init(db, withOpts("node_hash", "hmac-sha256", "node_hash_key", "secret")))
+
+	qs, err := kv.New(db, withOpts("node_hash_key", "secret"))
+	require.NoError(t, err)
+	defer qs.Close()
+	testutil.MakeWriter(t, qs, nil, graphtest.MakeQuadSet()...)
+	require.Equal(t, int64(len(graphtest.MakeQuadSet())), qs.Size())
+	problems, err := graph.Check(ctx, qs, false)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+
+	// settings must match the database
+	for _, o := range []graph.Options{
+		withOpts(),
+		withOpts("node_hash_key", "wrong"),
+		withOpts("node_hash", "sha1"),
+		withOpts("node_hash", "sha256", "node_hash_key", "secret"),
+	} {
+		_, err = kv.New(db, o)
+		require.Error(t, err, "%v", o)
+	}
+
+	qs2, err := kv.New(db, withOpts("node_hash", "hmac-sha256", "node_hash_key", "secret"))
+	require.NoError(t, err)
+	it := qs2.QuadIterator(quad.Subject, qs2.ValueOf(quad.Raw("C")))
+	defer it.Close()
+	sz, exact := it.Size()
+	require.True(t, exact)
+	require.NotZero(t, sz)
+}
+
+func testBlobs(t *testing.T, gen DatabaseFunc, _ *Config) {
+	dir, err := ioutil.TempDir("", "cayley_blobs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, c := range []struct {
+		name string
+		opt  graph.Options
+	}{
+		{name: "bucket"},
+		{name: "fs", opt: graph.Options{"blob_store": "fs", "blob_path": dir}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.TODO()
+			db, opt, closer := gen(t)
+			defer closer()
+			require.NoError(t, kv.Init(db, opt))
+			bopt := graph.Options{"blob_threshold": 64}
+			for k, v := range opt {
+				bopt[k] = v
+			}
+			for k, v := range c.opt {
+				bopt[k] = v
+			}
+			qs, err := kv.New(db, bopt)
+			require.NoError(t, err)
+			defer qs.Close()
+
+			long := quad.String(strings.Repeat("large value ", 100))
+			q1 := quad.Make(quad.IRI("a"), quad.IRI("text"), long, nil)
+			q2 := quad.MakeIRI("a", "b", "c", "")
+			w := testutil.MakeWriter(t, qs, nil, q1, q2)
+
+			v := qs.ValueOf(long)
+			require.NotNil(t, v)
+			require.Equal(t, long, qs.NameOf(v))
+			it := qs.QuadIterator(quad.Object, v)
+			require.True(t, it.Next(ctx))
+			require.Equal(t, q1, qs.Quad(it.Result()))
+			it.Close()
+
+			problems, err := graph.Check(ctx, qs, false)
+			require.NoError(t, err)
+			require.Empty(t, problems)
+
+			blobs := func() []string {
+				var files []string
+				err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+					if err == nil && !fi.IsDir() {
+						files = append(files, path)
+					}
+					return err
+				})
+				require.NoError(t, err)
+				return files
+			}
+			if c.opt != nil {
+				require.Len(t, blobs(), 1)
+			} else {
+				st, err := graph.StatsOf(ctx, qs, true)
+				require.NoError(t, err)
+				require.NotZero(t, st.Storage["blobs"])
+			}
+
+			// blob is removed with the node
+			require.NoError(t, w.RemoveQuad(q1))
+			require.Nil(t, qs.ValueOf(long))
+			if c.opt != nil {
+				require.Empty(t, blobs())
+			}
+
+			// and can be added again
+			require.NoError(t, w.AddQuad(q1))
+			require.Equal(t, long, qs.NameOf(qs.ValueOf(long)))
+		})
+	}
 }
 
 func testOptimize(t *testing.T, gen DatabaseFunc, _ *Config) {
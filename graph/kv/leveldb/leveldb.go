@@ -88,6 +88,19 @@ func (db *DB) Type() string {
 func (db *DB) Close() error {
 	return db.DB.Close()
 }
+
+// Compact compacts the whole key range of the database.
+func (db *DB) Compact(ctx context.Context) error {
+	return db.DB.CompactRange(util.Range{})
+}
+
+var _ kv.FlatSnapshotReader = (*DB)(nil)
+
+// ReadSnapshot implements kv.FlatSnapshotReader. Read-only transactions use LevelDB snapshots.
+func (db *DB) ReadSnapshot() (kv.FlatTx, error) {
+	return db.Tx(false)
+}
+
 func (db *DB) Tx(update bool) (kv.FlatTx, error) {
 	tx := &Tx{db: db}
 	var err error
@@ -0,0 +1,93 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.ProvenanceStore = (*QuadStore)(nil)
+
+// ApplyDeltasWithProvenance implements graph.ProvenanceStore.
+//
+// Provenance is stored in a separate bucket, keyed by the ID of the quad in the log.
+func (qs *QuadStore) ApplyDeltasWithProvenance(in []graph.Delta, ignoreOpts graph.IgnoreOpts, p graph.Provenance) error {
+	if p.Time.IsZero() {
+		p.Time = time.Now().UTC()
+	}
+	return qs.applyDeltas(in, ignoreOpts, -1, &p, 0, nil)
+}
+
+func (qs *QuadStore) putProvenance(tx BucketTx, links []proto.Primitive, p *graph.Provenance) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	b := tx.Bucket(provenanceIndex)
+	for _, l := range links {
+		if err := b.Put(uint64KeyBytes(l.ID), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (qs *QuadStore) delProvenance(tx BucketTx, id uint64) error {
+	return tx.Bucket(provenanceIndex).Del(uint64KeyBytes(id))
+}
+
+// ProvenanceOf implements graph.ProvenanceStore. For quads added without provenance,
+// only the time of the write is returned.
+func (qs *QuadStore) ProvenanceOf(ctx context.Context, q quad.Quad) (*graph.Provenance, error) {
+	var out *graph.Provenance
+	err := View(qs.db, func(tx BucketTx) error {
+		var link proto.Primitive
+		for _, d := range quad.Directions {
+			v := q.Get(d)
+			if v == nil {
+				continue
+			}
+			id, err := qs.resolveQuadValue(ctx, tx, v)
+			if err != nil {
+				return err
+			} else if id == 0 {
+				return graph.ErrQuadNotExist
+			}
+			link.SetDirection(d, id)
+		}
+		p, err := qs.hasPrimitive(ctx, tx, &link, true)
+		if err != nil {
+			return err
+		} else if p == nil || p.Deleted {
+			return graph.ErrQuadNotExist
+		}
+		vals, err := tx.Get(ctx, []BucketKey{{Bucket: provenanceIndex, Key: uint64KeyBytes(p.ID)}})
+		if err != nil {
+			return err
+		}
+		out = &graph.Provenance{Time: time.Unix(0, p.Timestamp).UTC()}
+		if len(vals[0]) != 0 {
+			return json.Unmarshal(vals[0], out)
+		}
+		return nil
+	})
+	return out, err
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+)
+
+var _ graph.Purger = (*QuadStore)(nil)
+
+// Purge implements graph.Purger. Records of deleted quads are removed from the log, from quad indexes
+// and from the provenance bucket. Tombstones and delta log entries younger than the "tombstone_retention"
+// option are always kept, thus views of the recent history are not affected.
+//
+// Deleted quads are only referenced by quad indexes, since reference counters of nodes are updated on removal.
+func (qs *QuadStore) Purge(ctx context.Context, before time.Time, dryRun bool) (graph.PurgeStats, error) {
+	if qs.retention > 0 {
+		if limit := time.Now().Add(-qs.retention); before.After(limit) {
+			before = limit
+		}
+	}
+	ts := before.UnixNano()
+
+	qs.writer.Lock()
+	defer qs.writer.Unlock()
+
+	var (
+		st      graph.PurgeStats
+		dead    []*proto.Primitive
+		entries [][]byte
+	)
+	err := View(qs.db, func(tx BucketTx) error {
+		err := eachBucket(ctx, tx, logIndex, func(k, v []byte) error {
+			p := new(proto.Primitive)
+			if err := p.Unmarshal(v); err != nil {
+				return nil // reported by Check
+			}
+			if p.IsNode() || !p.Deleted || p.Timestamp >= ts {
+				return nil
+			}
+			dead = append(dead, p)
+			st.Bytes += int64(len(k) + len(v))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return eachBucket(ctx, tx, deltaLogIndex, func(k, v []byte) error {
+			if t, ok := logEntryTime(v); !ok || t >= ts {
+				return nil
+			}
+			entries = append(entries, append([]byte{}, k...))
+			st.Bytes += int64(len(k) + len(v))
+			return nil
+		})
+	})
+	st.Quads, st.LogEntries = int64(len(dead)), int64(len(entries))
+	if err != nil || dryRun || (len(dead) == 0 && len(entries) == 0) {
+		return st, err
+	}
+
+	c := newChecker(qs)
+	for _, p := range dead {
+		id := p.ID
+		c.fix(func(ctx context.Context, tx BucketTx) error {
+			if err := qs.delProvenance(tx, id); err != nil {
+				return err
+			}
+			return qs.delLog(tx, id)
+		})
+	}
+	qs.indexes.RLock()
+	all := qs.indexes.all
+	qs.indexes.RUnlock()
+	for _, ind := range all {
+		fixes := make(map[string]*indexFix)
+		for _, p := range dead {
+			k := ind.KeyFor(p)
+			f := fixes[string(k)]
+			if f == nil {
+				f = &indexFix{bucket: ind.Bucket(), key: k, del: make(map[uint64]struct{})}
+				fixes[string(k)] = f
+				c.fix(f.apply)
+			}
+			f.del[p.ID] = struct{}{}
+		}
+	}
+	for _, k := range entries {
+		k := k
+		c.fix(func(ctx context.Context, tx BucketTx) error {
+			return tx.Bucket(deltaLogIndex).Del(k)
+		})
+	}
+	err = Update(ctx, qs.db, func(tx BucketTx) error {
+		for _, fix := range c.fixes {
+			if err := fix(ctx, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return graph.PurgeStats{}, err
+	}
+	return st, nil
+}
+
+// logEntryTime returns the timestamp of a delta log entry without decoding all deltas.
+func logEntryTime(data []byte) (int64, bool) {
+	sz, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < sz {
+		return 0, false
+	}
+	var ld proto.LogDelta
+	if err := ld.Unmarshal(data[n : n+int(sz)]); err != nil {
+		return 0, false
+	}
+	return ld.Timestamp, true
+}
@@ -17,10 +17,12 @@ package kv
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad"
 )
 
 type QuadIterator struct {
@@ -29,8 +31,10 @@ type QuadIterator struct {
 	qs      *QuadStore
 	ind     QuadIndex
 	horizon int64
+	now     int64 // expired quads are skipped
 	vals    []uint64
 	size    int64
+	after   uint64 // quads with smaller or equal ids are skipped
 
 	tx   BucketTx
 	b    Bucket
@@ -44,13 +48,14 @@ type QuadIterator struct {
 	prim *proto.Primitive
 }
 
-var _ graph.Iterator = &QuadIterator{}
+var _ graph.BatchIterator = &QuadIterator{}
 
 func NewQuadIterator(qs *QuadStore, ind QuadIndex, vals []uint64) *QuadIterator {
 	return &QuadIterator{
 		qs:      qs,
 		ind:     ind,
 		horizon: qs.horizon(context.TODO()),
+		now:     time.Now().UnixNano(),
 		uid:     iterator.NextUID(),
 		vals:    vals,
 		size:    -1,
@@ -84,7 +89,9 @@ func (it *QuadIterator) Clone() graph.Iterator {
 	out := NewQuadIterator(it.qs, it.ind, it.vals)
 	out.tags.CopyFrom(it)
 	out.ids = it.ids
+	out.after = it.after
 	out.horizon = it.horizon
+	out.now = it.now
 	return out
 }
 
@@ -156,6 +163,19 @@ func (it *QuadIterator) Next(ctx context.Context) bool {
 				if it.err != nil {
 					return false
 				}
+				if it.after != 0 {
+					ids := it.ids[:0]
+					for _, id := range it.ids {
+						if id > it.after {
+							ids = append(ids, id)
+						}
+					}
+					it.ids = ids
+				}
+			}
+			// the query might be canceled while the iterator is advanced by the parent
+			if it.err = ctx.Err(); it.err != nil {
+				return false
 			}
 			ids := it.ids[it.off:]
 			if len(ids) > nextBatch {
@@ -170,7 +190,7 @@ func (it *QuadIterator) Next(ctx context.Context) bool {
 		}
 		for ; len(it.buf) > 0; it.buf, it.off = it.buf[1:], it.off+1 {
 			p := it.buf[0]
-			if p == nil || p.Deleted {
+			if p == nil || p.Deleted || p.IsExpired(it.now) {
 				continue
 			}
 			it.prim = p
@@ -179,6 +199,25 @@ func (it *QuadIterator) Next(ctx context.Context) bool {
 	}
 }
 
+// NextBatch implements graph.BatchIterator. It returns all primitives decoded by a single read
+// from the log without advancing the iterator for each of them.
+func (it *QuadIterator) NextBatch(ctx context.Context, dst []graph.Value) (int, error) {
+	n := 0
+	for n < len(dst) && it.Next(ctx) {
+		dst[n] = it.prim
+		n++
+		for n < len(dst) && len(it.buf) > 1 {
+			it.buf, it.off = it.buf[1:], it.off+1
+			if p := it.buf[0]; p != nil && !p.Deleted && !p.IsExpired(it.now) {
+				it.prim = p
+				dst[n] = p
+				n++
+			}
+		}
+	}
+	return n, it.err
+}
+
 func (it *QuadIterator) NextPath(ctx context.Context) bool {
 	return false
 }
@@ -186,7 +225,7 @@ func (it *QuadIterator) NextPath(ctx context.Context) bool {
 func (it *QuadIterator) Contains(ctx context.Context, v graph.Value) bool {
 	it.prim = nil
 	p, ok := v.(*proto.Primitive)
-	if !ok {
+	if !ok || p.IsExpired(it.now) {
 		return false
 	}
 	for i, v := range it.vals {
@@ -228,7 +267,16 @@ func (it *QuadIterator) Size() (int64, bool) {
 		it.size = int64(len(ids))
 		return it.size, true
 	}
-	return 1 + it.qs.Size()/2, false
+	est := 1 + it.qs.Size()/2
+	for i, v := range it.vals {
+		if it.ind.Dirs[i] != quad.Predicate {
+			continue
+		}
+		if n, ok := it.qs.estimatePredicate(v); ok && n < est {
+			est = n
+		}
+	}
+	return est, false
 }
 
 func (it *QuadIterator) String() string {
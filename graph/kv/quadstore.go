@@ -20,13 +20,13 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/proto"
 	"github.com/cayleygraph/cayley/internal/lru"
 	"github.com/cayleygraph/cayley/quad"
-	"github.com/cayleygraph/cayley/quad/pquads"
 	boom "github.com/tylertreat/BoomFilters"
 )
 
@@ -89,7 +89,33 @@ type QuadStore struct {
 		exists []QuadIndex
 	}
 
+	// hasher calculates keys of node values in the value index.
+	hasher *nodeHasher
+	// blobThreshold is a size of encoded node values that are moved to a blob store; zero disables blobs.
+	blobThreshold int
+	// blobs is an external blob store; if nil, blobs are stored in the database.
+	blobs BlobStore
+	// blobDels is a list of external blobs that will be removed after the write is committed.
+	blobDels [][]byte
+
 	valueLRU *lru.Cache
+	// names is a cache of decoded node values, indexed by node ID. It is shared by all readers of the store.
+	names  *lru.Cache
+	notify graph.Notifier
+	// deltaLog enables recording of applied transactions in the delta log.
+	deltaLog bool
+	// retention is a minimal age of tombstones and delta log entries removed by Purge.
+	retention time.Duration
+	// statsSample is a number of log records read to update sampled statistics.
+	statsSample int
+	stats       struct {
+		sync.RWMutex
+		cur    *sampledStats // nil if statistics were never sampled
+		loaded bool
+	}
+	// done is closed to stop background tasks when the store is closed.
+	done  chan struct{}
+	tasks sync.WaitGroup
 
 	writer    sync.Mutex
 	mapBucket map[string]map[string][]uint64
@@ -119,17 +145,55 @@ func Init(kv BucketKV, opt graph.Options) error {
 	if err != nil {
 		return err
 	}
+	hasher, err := newNodeHasher(opt)
+	if err != nil {
+		return err
+	}
 	if err := qs.createBuckets(ctx, upfront); err != nil {
 		return err
 	}
+	if err := putNodeHash(ctx, qs.db, hasher); err != nil {
+		return err
+	}
 	if err := setVersion(ctx, qs.db, latestDataVersion); err != nil {
 		return err
 	}
 	return nil
 }
 
-func New(kv BucketKV, _ graph.Options) (graph.QuadStore, error) {
+// DefaultValueCacheSize is the default number of decoded node values cached by the quad store.
+const DefaultValueCacheSize = 1 << 16
+
+func New(kv BucketKV, opt graph.Options) (graph.QuadStore, error) {
 	ctx := context.TODO()
+	cacheSize, err := opt.IntKey("value_cache_size", DefaultValueCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	deltaLog, err := opt.BoolKey("delta_log", false)
+	if err != nil {
+		return nil, err
+	}
+	retention, err := opt.DurationKey("tombstone_retention", 0)
+	if err != nil {
+		return nil, err
+	}
+	statsInterval, err := opt.DurationKey("stats_interval", 0)
+	if err != nil {
+		return nil, err
+	}
+	statsSample, err := opt.IntKey("stats_sample", DefaultStatsSample)
+	if err != nil {
+		return nil, err
+	}
+	blobThreshold, err := opt.IntKey("blob_threshold", 0)
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := newBlobStore(opt)
+	if err != nil {
+		return nil, err
+	}
 	qs := newQuadStore(kv)
 	if vers, err := qs.getMetadata(ctx); err == ErrNoBucket {
 		return nil, graph.ErrNotInitialized
@@ -138,10 +202,25 @@ func New(kv BucketKV, _ graph.Options) (graph.QuadStore, error) {
 	} else if vers != latestDataVersion {
 		return nil, errors.New("kv: data version is out of date. Run cayleyupgrade for your config to update the data.")
 	}
+	if qs.hasher, err = openNodeHash(ctx, kv, opt); err != nil {
+		return nil, err
+	}
 	qs.valueLRU = lru.New(2000)
+	if cacheSize > 0 {
+		qs.names = lru.New(cacheSize)
+	}
+	qs.deltaLog = deltaLog
+	qs.retention = retention
+	qs.statsSample = statsSample
+	qs.blobThreshold, qs.blobs = blobThreshold, blobs
 	if err := qs.initBloomFilter(ctx); err != nil {
 		return nil, err
 	}
+	if statsInterval > 0 {
+		qs.done = make(chan struct{})
+		qs.tasks.Add(1)
+		go qs.maintainStats(qs.done, statsInterval)
+	}
 	return qs, nil
 }
 
@@ -185,9 +264,103 @@ func (qs *QuadStore) Size() int64 {
 }
 
 func (qs *QuadStore) Close() error {
+	if qs.done != nil {
+		close(qs.done)
+		qs.tasks.Wait()
+		qs.done = nil
+	}
+	qs.notify.CloseAll(graph.ErrStoreClosed)
 	return qs.db.Close()
 }
 
+var _ graph.Subscriber = (*QuadStore)(nil)
+
+// Subscribe implements graph.Subscriber.
+func (qs *QuadStore) Subscribe(ctx context.Context) (*graph.Subscription, error) {
+	return qs.notify.Subscribe(ctx), nil
+}
+
+var _ graph.CapabilityReporter = (*QuadStore)(nil)
+
+// Capabilities implements graph.CapabilityReporter. Horizon reads depend on the underlying database
+// and the delta log is only available if it was enabled when opening the store.
+func (qs *QuadStore) Capabilities() graph.Capabilities {
+	_, snap := qs.db.(SnapshotReader)
+	return graph.Capabilities{
+		Transactions:      true,
+		ChangeFeed:        true,
+		HorizonReads:      snap,
+		ConditionalWrites: true,
+		DeltaLog:          qs.deltaLog,
+		Provenance:        true,
+		Metadata:          true,
+	}
+}
+
+// Compact reclaims space left by removed data, if supported by the underlying database.
+func (qs *QuadStore) Compact(ctx context.Context) error {
+	if c, ok := qs.db.(graph.Compactor); ok {
+		return c.Compact(ctx)
+	}
+	return graph.ErrNotSupported
+}
+
+// metaUserPrefix separates metadata records set by users of the QuadStore from internal ones.
+const metaUserPrefix = "user:"
+
+// GetMetadata returns a metadata record stored for the key, or nil if it is not set.
+func (qs *QuadStore) GetMetadata(ctx context.Context, key string) ([]byte, error) {
+	var out []byte
+	err := View(qs.db, func(tx BucketTx) error {
+		v, err := GetOne(ctx, tx.Bucket(metaBucket), []byte(metaUserPrefix+key))
+		if err == ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		out = append([]byte{}, v...)
+		return nil
+	})
+	return out, err
+}
+
+// SetMetadata stores a metadata record for the key. Nil value removes the record.
+func (qs *QuadStore) SetMetadata(ctx context.Context, key string, val []byte) error {
+	return Update(ctx, qs.db, func(tx BucketTx) error {
+		return putUserMetadata(tx, map[string][]byte{key: val})
+	})
+}
+
+var _ graph.MetadataWriter = (*QuadStore)(nil)
+
+// ApplyDeltasWithMetadata implements graph.MetadataWriter.
+func (qs *QuadStore) ApplyDeltasWithMetadata(in []graph.Delta, ignoreOpts graph.IgnoreOpts, meta map[string][]byte) error {
+	return qs.applyDeltas(in, ignoreOpts, -1, nil, 0, meta)
+}
+
+func putUserMetadata(tx BucketTx, meta map[string][]byte) error {
+	b := tx.Bucket(metaBucket)
+	for key, val := range meta {
+		k := []byte(metaUserPrefix + key)
+		var err error
+		if val == nil {
+			err = b.Del(k)
+		} else {
+			err = b.Put(k, val)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping checks that the database can be read by fetching the metadata record.
+func (qs *QuadStore) Ping(ctx context.Context) error {
+	_, err := qs.getMetadata(ctx)
+	return err
+}
+
 func (qs *QuadStore) getMetadata(ctx context.Context) (int64, error) {
 	var vers int64
 	err := View(qs.db, func(tx BucketTx) error {
@@ -251,6 +424,17 @@ func (qs *QuadStore) ValuesOf(ctx context.Context, vals []graph.Value) ([]quad.V
 			return out, fmt.Errorf("unknown type of graph.Value; not meant for this quadstore. apparently a %#v", v)
 		}
 	}
+	// values that are already decoded are taken from the cache
+	n := 0
+	for i, id := range refs {
+		if qv := qs.cachedName(id); qv != nil {
+			out[inds[i]] = qv
+			continue
+		}
+		inds[n], refs[n] = inds[i], id
+		n++
+	}
+	inds, refs = inds[:n], refs[:n]
 	if len(refs) == 0 {
 		return out, nil
 	}
@@ -260,10 +444,7 @@ func (qs *QuadStore) ValuesOf(ctx context.Context, vals []graph.Value) ([]quad.V
 	}
 	var last error
 	for i, p := range prim {
-		if !p.IsNode() {
-			continue
-		}
-		qv, err := pquads.UnmarshalValue(p.Value)
+		qv, err := qs.decodeName(ctx, nil, p)
 		if err != nil {
 			last = err
 			continue
@@ -272,6 +453,45 @@ func (qs *QuadStore) ValuesOf(ctx context.Context, vals []graph.Value) ([]quad.V
 	}
 	return out, last
 }
+
+func nameKey(id uint64) string {
+	return string(uint64KeyBytes(id))
+}
+
+// cachedName returns a decoded value of the node, if it is in the cache.
+func (qs *QuadStore) cachedName(id uint64) quad.Value {
+	if qs.names == nil {
+		return nil
+	}
+	if v, ok := qs.names.Get(nameKey(id)); ok {
+		return v.(quad.Value)
+	}
+	return nil
+}
+
+// decodeName decodes a value of the node primitive and caches it. It returns nil for other primitives.
+// The transaction is only used to load values from the blob bucket and can be nil.
+func (qs *QuadStore) decodeName(ctx context.Context, tx BucketTx, p *proto.Primitive) (quad.Value, error) {
+	if p == nil || !p.IsNode() {
+		return nil, nil
+	}
+	qv, err := qs.decodeValue(ctx, tx, p)
+	if err != nil {
+		return nil, err
+	}
+	if qs.names != nil {
+		qs.names.Put(nameKey(p.ID), qv)
+	}
+	return qv, nil
+}
+
+// purgeCaches drops all cached values. It must be called if IDs of existing nodes may change.
+func (qs *QuadStore) purgeCaches() {
+	qs.valueLRU.Purge()
+	if qs.names != nil {
+		qs.names.Purge()
+	}
+}
 func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
 	ctx := context.TODO()
 	vals, err := qs.ValuesOf(ctx, []graph.Value{v})
@@ -320,12 +540,14 @@ func (qs *QuadStore) primitiveToQuad(ctx context.Context, tx BucketTx, p *proto.
 func (qs *QuadStore) getValFromLog(ctx context.Context, tx BucketTx, k uint64) (quad.Value, error) {
 	if k == 0 {
 		return nil, nil
+	} else if qv := qs.cachedName(k); qv != nil {
+		return qv, nil
 	}
 	p, err := qs.getPrimitiveFromLog(ctx, tx, k)
 	if err != nil {
 		return nil, err
 	}
-	return pquads.UnmarshalValue(p.Value)
+	return qs.decodeName(ctx, tx, p)
 }
 
 func (qs *QuadStore) ValueOf(s quad.Value) graph.Value {
@@ -126,6 +126,7 @@ func TestApplyDeltas(t *testing.T) {
 
 	expect(Ops{
 		{opGet, bMeta, kVers, vVers, nil},
+		{opGet, bMeta, []byte("node_hash"), nil, nil},
 	})
 
 	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
@@ -163,6 +164,8 @@ func TestApplyDeltas(t *testing.T) {
 		{opPut, "o", be(3), hex("04"), nil},
 		{opGet, "s", be(1), nil, nil},
 		{opPut, "s", be(1), hex("04"), nil},
+		{opGet, bMeta, []byte("commits"), nil, nil},
+		{opPut, bMeta, []byte("commits"), le(1), nil},
 	})
 
 	err = qw.AddQuad(quad.MakeIRI("a", "b", "e", ""))
@@ -194,6 +197,8 @@ func TestApplyDeltas(t *testing.T) {
 		{opPut, "o", be(5), hex("06"), nil},
 		{opGet, "s", be(1), hex("04"), nil},
 		{opPut, "s", be(1), hex("0406"), nil},
+		{opGet, bMeta, []byte("commits"), le(1), nil},
+		{opPut, bMeta, []byte("commits"), le(2), nil},
 	})
 
 	err = qw.RemoveQuad(quad.MakeIRI("a", "b", "c", ""))
@@ -212,6 +217,8 @@ func TestApplyDeltas(t *testing.T) {
 		{opDel, iric("c"), irih("c"), nil, nil},
 		{opDel, irib("c"), irih("c"), nil, nil},
 		{opDel, bLog, be(3), nil, nil},
+		{opGet, bMeta, []byte("commits"), le(2), nil},
+		{opPut, bMeta, []byte("commits"), le(3), nil},
 	})
 	require.NoError(t, err)
 }
@@ -262,6 +269,27 @@ type kvOp struct {
 	err    error
 }
 
+func TestValueCache(t *testing.T) {
+	hook := &kvHook{db: btree.New()}
+	require.NoError(t, kv.Init(hook, nil))
+	qs, err := kv.New(hook, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{
+		{Action: graph.Add, Quad: quad.MakeIRI("a", "b", "c", "")},
+	}, graph.IgnoreOpts{}))
+	v := qs.ValueOf(quad.IRI("c"))
+	require.NotNil(t, v)
+	hook.log()
+
+	require.Equal(t, quad.IRI("c"), qs.NameOf(v))
+	require.NotEmpty(t, hook.log())
+	// decoded value is cached
+	require.Equal(t, quad.IRI("c"), qs.NameOf(v))
+	require.Empty(t, hook.log())
+}
+
 type kvHook struct {
 	db kv.BucketKV
 
@@ -378,3 +406,26 @@ func (h bucketHook) Del(k []byte) error {
 func (h bucketHook) Scan(pref []byte) kv.KVIterator {
 	return h.b.Scan(pref)
 }
+
+func TestIterateCanceled(t *testing.T) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{
+		{Action: graph.Add, Quad: quad.MakeIRI("a", "b", "c", "")},
+	}, graph.IgnoreOpts{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, it := range []graph.Iterator{
+		qs.QuadsAllIterator(),
+		qs.QuadIterator(quad.Subject, qs.ValueOf(quad.IRI("a"))),
+	} {
+		require.False(t, it.Next(ctx))
+		require.Equal(t, context.Canceled, it.Err())
+		it.Close()
+	}
+}
@@ -0,0 +1,445 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redis implements a key-value backend for Redis.
+//
+// Each bucket is stored as a hash that maps keys to values, and a sorted set of keys with equal scores,
+// which is used for ordered prefix scans. Write transactions are buffered by the client and committed
+// atomically with MULTI/EXEC. Read transactions are not isolated from concurrent writes, and only a single
+// writer is allowed per instance.
+package redis
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-redis/redis"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+)
+
+func init() {
+	kv.Register(Type, kv.Registration{
+		NewFunc:      Open,
+		InitFunc:     Create,
+		IsPersistent: true,
+	})
+}
+
+const (
+	Type = "redis"
+
+	DefaultAddr   = "localhost:6379"
+	DefaultPrefix = "cayley"
+
+	scanBatch = 1000 // number of keys fetched in a single request by iterators
+)
+
+func dial(addr string, m graph.Options) (*DB, error) {
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	pass, err := m.StringKey("password", "")
+	if err != nil {
+		return nil, err
+	}
+	num, err := m.IntKey("db", 0)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := m.StringKey("key_prefix", DefaultPrefix)
+	if err != nil {
+		return nil, err
+	}
+	cli := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: pass,
+		DB:       num,
+	})
+	if err = cli.Ping().Err(); err != nil {
+		cli.Close()
+		return nil, err
+	}
+	return &DB{cli: cli, prefix: prefix}, nil
+}
+
+// Create initializes a new database. It fails if the key prefix is already used by another database.
+func Create(addr string, m graph.Options) (kv.BucketKV, error) {
+	db, err := dial(addr, m)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := db.cli.SetNX(db.metaKey(), Type, 0).Result()
+	if err == nil && !ok {
+		err = graph.ErrDatabaseExists
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Open opens an existing database.
+func Open(addr string, m graph.Options) (kv.BucketKV, error) {
+	db, err := dial(addr, m)
+	if err != nil {
+		return nil, err
+	}
+	n, err := db.cli.Exists(db.metaKey()).Result()
+	if err == nil && n == 0 {
+		err = graph.ErrNotInitialized
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+type DB struct {
+	cli    *redis.Client
+	prefix string
+	wmu    sync.Mutex // single writer
+}
+
+func (db *DB) Type() string {
+	return Type
+}
+func (db *DB) Close() error {
+	return db.cli.Close()
+}
+
+func (db *DB) metaKey() string {
+	return db.prefix + ":meta"
+}
+
+// valsKey returns a name of the hash that stores values of the bucket.
+func (db *DB) valsKey(bucket string) string {
+	return db.prefix + ":v:" + bucket
+}
+
+// keysKey returns a name of the sorted set that stores keys of the bucket.
+func (db *DB) keysKey(bucket string) string {
+	return db.prefix + ":k:" + bucket
+}
+
+func (db *DB) Tx(update bool) (kv.BucketTx, error) {
+	tx := &Tx{db: db}
+	if update {
+		db.wmu.Lock()
+		tx.buf = make(map[string]map[string]*entry)
+	}
+	return tx, nil
+}
+
+// entry is a pending write in the transaction.
+type entry struct {
+	val []byte
+	del bool
+}
+
+type Tx struct {
+	db   *DB
+	buf  map[string]map[string]*entry // pending writes by bucket; nil for read-only transactions
+	done bool
+	err  error
+}
+
+func (tx *Tx) finish() {
+	if !tx.done && tx.buf != nil {
+		tx.db.wmu.Unlock()
+	}
+	tx.done = true
+	tx.buf = nil
+}
+
+func (tx *Tx) Commit(ctx context.Context) error {
+	if tx.done {
+		return tx.err
+	}
+	buf := tx.buf
+	defer tx.finish()
+	if tx.err != nil || len(buf) == 0 {
+		return tx.err
+	}
+	_, tx.err = tx.db.cli.WithContext(ctx).TxPipelined(func(p redis.Pipeliner) error {
+		for b, m := range buf {
+			vkey, kkey := tx.db.valsKey(b), tx.db.keysKey(b)
+			for k, e := range m {
+				if e.del {
+					p.HDel(vkey, k)
+					p.ZRem(kkey, k)
+				} else {
+					p.HSet(vkey, k, e.val)
+					p.ZAdd(kkey, redis.Z{Member: k})
+				}
+			}
+		}
+		return nil
+	})
+	return tx.err
+}
+
+func (tx *Tx) Rollback() error {
+	tx.finish()
+	return tx.err
+}
+
+func (tx *Tx) Bucket(name []byte) kv.Bucket {
+	return &Bucket{tx: tx, name: string(name)}
+}
+
+func (tx *Tx) Get(ctx context.Context, keys []kv.BucketKey) ([][]byte, error) {
+	// group keys by bucket, but keep the order of results
+	var (
+		order []string
+		inds  = make(map[string][]int)
+	)
+	for i, k := range keys {
+		b := string(k.Bucket)
+		if _, ok := inds[b]; !ok {
+			order = append(order, b)
+		}
+		inds[b] = append(inds[b], i)
+	}
+	vals := make([][]byte, len(keys))
+	for _, b := range order {
+		bkeys := make([][]byte, 0, len(inds[b]))
+		for _, i := range inds[b] {
+			bkeys = append(bkeys, keys[i].Key)
+		}
+		bvals, err := tx.Bucket([]byte(b)).Get(ctx, bkeys)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range inds[b] {
+			vals[i] = bvals[j]
+		}
+	}
+	return vals, nil
+}
+
+type Bucket struct {
+	tx   *Tx
+	name string
+}
+
+// pending returns buffered writes of the bucket. It returns nil for read-only transactions.
+func (b *Bucket) pending(create bool) map[string]*entry {
+	if b.tx.buf == nil {
+		return nil
+	}
+	m := b.tx.buf[b.name]
+	if m == nil && create {
+		m = make(map[string]*entry)
+		b.tx.buf[b.name] = m
+	}
+	return m
+}
+
+func (b *Bucket) Get(ctx context.Context, keys [][]byte) ([][]byte, error) {
+	if b.tx.done {
+		return nil, fmt.Errorf("redis: transaction is closed")
+	}
+	vals := make([][]byte, len(keys))
+	pend := b.pending(false)
+	fields := make([]string, 0, len(keys))
+	inds := make([]int, 0, len(keys))
+	for i, k := range keys {
+		if e, ok := pend[string(k)]; ok {
+			if !e.del {
+				vals[i] = append([]byte{}, e.val...)
+			}
+			continue
+		}
+		fields = append(fields, string(k))
+		inds = append(inds, i)
+	}
+	if len(fields) == 0 {
+		return vals, nil
+	}
+	res, err := b.tx.db.cli.WithContext(ctx).HMGet(b.tx.db.valsKey(b.name), fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for j, v := range res {
+		if s, ok := v.(string); ok {
+			vals[inds[j]] = []byte(s)
+		}
+	}
+	return vals, nil
+}
+
+func (b *Bucket) Put(k, v []byte) error {
+	m := b.pending(true)
+	if m == nil {
+		return fmt.Errorf("put on ro tx")
+	}
+	m[string(k)] = &entry{val: append([]byte{}, v...)}
+	return nil
+}
+
+func (b *Bucket) Del(k []byte) error {
+	m := b.pending(true)
+	if m == nil {
+		return fmt.Errorf("del on ro tx")
+	}
+	m[string(k)] = &entry{del: true}
+	return nil
+}
+
+// Scan implements kv.Bucket. Pending writes of the transaction are merged with stored keys.
+func (b *Bucket) Scan(pref []byte) kv.KVIterator {
+	it := &Iterator{b: b, pref: pref}
+	if len(pref) == 0 {
+		it.min, it.max = "-", "+"
+	} else {
+		it.min = "[" + string(pref)
+		if end := prefixEnd(pref); end != nil {
+			it.max = "(" + string(end)
+		} else {
+			it.max = "+"
+		}
+	}
+	for k, e := range b.pending(false) {
+		if bytes.HasPrefix([]byte(k), pref) {
+			it.over = append(it.over, k)
+			it.overv = append(it.overv, e)
+		}
+	}
+	sort.Sort(byKey{it})
+	return it
+}
+
+// prefixEnd returns the first key that is greater than all keys with a given prefix,
+// or nil if there is no such key.
+func prefixEnd(pref []byte) []byte {
+	end := append([]byte{}, pref...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+type byKey struct{ it *Iterator }
+
+func (s byKey) Len() int           { return len(s.it.over) }
+func (s byKey) Less(i, j int) bool { return s.it.over[i] < s.it.over[j] }
+func (s byKey) Swap(i, j int) {
+	s.it.over[i], s.it.over[j] = s.it.over[j], s.it.over[i]
+	s.it.overv[i], s.it.overv[j] = s.it.overv[j], s.it.overv[i]
+}
+
+type Iterator struct {
+	b        *Bucket
+	pref     []byte
+	min, max string // remaining range of stored keys
+
+	keys []string // current page of stored keys
+	vals [][]byte
+	done bool // all stored keys are fetched
+
+	over  []string // pending writes with the prefix, sorted by key
+	overv []*entry
+
+	k, v []byte
+	err  error
+}
+
+// fetch loads the next page of stored keys and their values.
+func (it *Iterator) fetch(ctx context.Context) {
+	db := it.b.tx.db
+	cli := db.cli.WithContext(ctx)
+	keys, err := cli.ZRangeByLex(db.keysKey(it.b.name), redis.ZRangeBy{
+		Min: it.min, Max: it.max, Count: scanBatch,
+	}).Result()
+	if err != nil {
+		it.err = err
+		return
+	}
+	if len(keys) < scanBatch {
+		it.done = true
+	} else {
+		it.min = "(" + keys[len(keys)-1]
+	}
+	it.keys, it.vals = it.keys[:0], it.vals[:0]
+	if len(keys) == 0 {
+		return
+	}
+	vals, err := cli.HMGet(db.valsKey(it.b.name), keys...).Result()
+	if err != nil {
+		it.err = err
+		return
+	}
+	for i, v := range vals {
+		// keys may be removed concurrently
+		if s, ok := v.(string); ok {
+			it.keys = append(it.keys, keys[i])
+			it.vals = append(it.vals, []byte(s))
+		}
+	}
+}
+
+func (it *Iterator) Next(ctx context.Context) bool {
+	if it.b.tx.done {
+		it.err = fmt.Errorf("redis: transaction is closed")
+	}
+	for it.err == nil {
+		if len(it.keys) == 0 && !it.done {
+			it.fetch(ctx)
+			continue
+		}
+		var k string
+		switch {
+		case len(it.keys) == 0 && len(it.over) == 0:
+			return false
+		case len(it.over) == 0 || (len(it.keys) != 0 && it.keys[0] < it.over[0]):
+			k, it.v = it.keys[0], it.vals[0]
+			it.keys, it.vals = it.keys[1:], it.vals[1:]
+		default:
+			// pending writes override stored values
+			k, e := it.over[0], it.overv[0]
+			it.over, it.overv = it.over[1:], it.overv[1:]
+			if len(it.keys) != 0 && it.keys[0] == k {
+				it.keys, it.vals = it.keys[1:], it.vals[1:]
+			}
+			if e.del {
+				continue
+			}
+			it.k, it.v = []byte(k), e.val
+			return true
+		}
+		it.k = []byte(k)
+		return true
+	}
+	return false
+}
+func (it *Iterator) Key() []byte { return it.k }
+func (it *Iterator) Val() []byte { return it.v }
+func (it *Iterator) Err() error {
+	return it.err
+}
+func (it *Iterator) Close() error {
+	it.keys, it.vals = nil, nil
+	it.over, it.overv = nil, nil
+	return it.Err()
+}
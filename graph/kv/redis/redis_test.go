@@ -0,0 +1,46 @@
+//go:build docker
+// +build docker
+
+package redis
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/kvtest"
+	"github.com/cayleygraph/cayley/internal/dock"
+)
+
+func makeRedis(t testing.TB) (kv.BucketKV, graph.Options, func()) {
+	var conf dock.Config
+
+	conf.Image = "redis:5"
+	conf.OpenStdin = true
+	conf.Tty = true
+
+	addr, closer := dock.RunAndWait(t, conf, "6379", func(addr string) bool {
+		cli := redis.NewClient(&redis.Options{Addr: addr})
+		defer cli.Close()
+		return cli.Ping().Err() == nil
+	})
+	db, err := Create(addr, nil)
+	if err != nil {
+		closer()
+		t.Fatal(err)
+	}
+	return db, nil, func() {
+		db.Close()
+		closer()
+	}
+}
+
+func TestRedis(t *testing.T) {
+	kvtest.TestAll(t, makeRedis, nil)
+}
+
+func BenchmarkRedis(b *testing.B) {
+	kvtest.BenchmarkAll(b, makeRedis, nil)
+}
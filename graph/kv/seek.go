@@ -0,0 +1,82 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.QuadSeeker = (*QuadStore)(nil)
+
+// SeekQuads implements graph.QuadSeeker. Positions are ids of quads in the log, which grow with each write.
+//
+// The index of one of pattern directions is used to list quads, if it exists, and other directions are checked
+// for each quad. Without such an index the log is scanned, starting from the position.
+func (qs *QuadStore) SeekQuads(pattern quad.Quad, after []byte) graph.Iterator {
+	var id uint64
+	if len(after) == 8 {
+		id = quadKeyEnc.Uint64(after)
+	} else if len(after) != 0 {
+		return iterator.NewError(graph.ErrInvalidPosition)
+	}
+	var (
+		primary graph.Iterator
+		checks  []graph.Iterator
+	)
+	for _, d := range quad.Directions {
+		v := pattern.Get(d)
+		if v == nil {
+			continue
+		}
+		gv := qs.ValueOf(v)
+		if gv == nil {
+			for _, it := range checks {
+				it.Close()
+			}
+			if primary != nil {
+				primary.Close()
+			}
+			return iterator.NewNull()
+		}
+		it := qs.QuadIterator(d, gv)
+		if qi, ok := it.(*QuadIterator); ok && primary == nil {
+			qi.after = id
+			primary = qi
+			continue
+		}
+		checks = append(checks, it)
+	}
+	if primary == nil {
+		all := NewAllIterator(false, qs, nil)
+		all.after, all.id = id, id
+		primary = all
+	}
+	if len(checks) == 0 {
+		return primary
+	}
+	return iterator.NewAnd(qs, append([]graph.Iterator{primary}, checks...)...)
+}
+
+// QuadPosition implements graph.QuadSeeker.
+func (qs *QuadStore) QuadPosition(v graph.Value) []byte {
+	p, ok := v.(*proto.Primitive)
+	if !ok {
+		return nil
+	}
+	return uint64KeyBytes(p.ID)
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var _ graph.Snapshotter = (*QuadStore)(nil)
+
+const (
+	// snapshotFormat is shared by all KV backends, since they use the same layout of buckets.
+	snapshotFormat = "kv"
+	// snapshotBatch is a number of records written in a single transaction on restore.
+	snapshotBatch = 10000
+	// maxSnapshotRecord is a maximal length of a key or value in the snapshot.
+	maxSnapshotRecord = 1 << 30
+)
+
+// SnapshotFormat implements graph.Snapshotter.
+func (qs *QuadStore) SnapshotFormat() string {
+	return snapshotFormat
+}
+
+// eachBucketName calls fnc for each bucket that may contain data of the QuadStore.
+func (qs *QuadStore) eachBucketName(fnc func(name []byte) error) error {
+	qs.indexes.RLock()
+	all := qs.indexes.all
+	qs.indexes.RUnlock()
+	names := append([][]byte{}, buckets...)
+	for _, ind := range all {
+		names = append(names, ind.Bucket())
+	}
+	for _, name := range names {
+		if err := fnc(name); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < 256; i++ {
+		for j := 0; j < 256; j++ {
+			if err := fnc(bucketForVal(byte(i), byte(j))); err != nil {
+				return err
+			}
+			if err := fnc(bucketForValRefs(byte(i), byte(j))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Snapshot writes all records of the database in a single read transaction.
+// Each record is written as a bucket name, key and value, each prefixed with a uvarint length.
+func (qs *QuadStore) Snapshot(ctx context.Context, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var buf [binary.MaxVarintLen64]byte
+	writeBytes := func(p []byte) error {
+		n := binary.PutUvarint(buf[:], uint64(len(p)))
+		if _, err := bw.Write(buf[:n]); err != nil {
+			return err
+		}
+		_, err := bw.Write(p)
+		return err
+	}
+	err := View(qs.db, func(tx BucketTx) error {
+		return qs.eachBucketName(func(name []byte) error {
+			return eachBucket(ctx, tx, name, func(k, v []byte) error {
+				for _, p := range [][]byte{name, k, v} {
+					if err := writeBytes(p); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// RestoreSnapshot loads records written by Snapshot. The database must be empty.
+func (qs *QuadStore) RestoreSnapshot(ctx context.Context, r io.Reader) error {
+	qs.writer.Lock()
+	defer qs.writer.Unlock()
+	if qs.horizon(ctx) != 0 {
+		return errors.New("kv: cannot restore a snapshot into a non-empty database")
+	}
+	br := bufio.NewReader(r)
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		} else if n > maxSnapshotRecord {
+			return nil, fmt.Errorf("kv: snapshot record is too large: %d", n)
+		}
+		p := make([]byte, n)
+		_, err = io.ReadFull(br, p)
+		return p, err
+	}
+	for done := false; !done; {
+		err := Update(ctx, qs.db, func(tx BucketTx) error {
+			for i := 0; i < snapshotBatch; i++ {
+				name, err := readBytes()
+				if err == io.EOF {
+					done = true
+					return nil
+				} else if err != nil {
+					return err
+				}
+				var kv [2][]byte
+				for j := range kv {
+					if kv[j], err = readBytes(); err == io.EOF {
+						return io.ErrUnexpectedEOF
+					} else if err != nil {
+						return err
+					}
+				}
+				if bytes.Equal(name, metaBucket) && string(kv[0]) == "version" {
+					if vers, err := asInt64(kv[1], nilDataVersion); err != nil {
+						return err
+					} else if vers != latestDataVersion {
+						return fmt.Errorf("kv: unsupported data version of the snapshot: %d", vers)
+					}
+				}
+				if err = tx.Bucket(name).Put(kv[0], kv[1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	qs.purgeCaches()
+	return qs.initBloomFilter(ctx)
+}
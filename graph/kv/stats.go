@@ -0,0 +1,334 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.StatsCollector = (*QuadStore)(nil)
+
+// Stats implements graph.StatsCollector. The number of quads is always exact, while the number of nodes
+// is estimated if exact is not set. If statistics were sampled (see RefreshStats), estimated statistics
+// also include the number of quads for each predicate and the number of nodes of each type.
+//
+// Exact statistics are calculated by iterating over all quads and nodes. They also report the size of buckets:
+// "log" for quads and nodes, "blobs" for large values, "values" and "refs" for the value index and reference counters,
+// and "index_<dirs>" for quad indexes. Size is a total length of keys and values, not the size on disk.
+func (qs *QuadStore) Stats(ctx context.Context, exact bool) (graph.Stats, error) {
+	if !exact {
+		return qs.estimateStats(ctx)
+	}
+	st, err := graph.IterateStats(ctx, qs)
+	if err != nil {
+		return st, err
+	}
+	// views of the store are read-only and use statistics of the store
+	if err = qs.RefreshStats(ctx); err != nil && err != graph.ErrReadOnly {
+		return st, err
+	}
+	st.Storage = make(map[string]int64)
+	qs.indexes.RLock()
+	all := qs.indexes.all
+	qs.indexes.RUnlock()
+	err = View(qs.db, func(tx BucketTx) error {
+		size := func(name string, bucket []byte) error {
+			return eachBucket(ctx, tx, bucket, func(k, v []byte) error {
+				st.Storage[name] += int64(len(k) + len(v))
+				return nil
+			})
+		}
+		if err := size("meta", metaBucket); err != nil {
+			return err
+		}
+		if err := size("log", logIndex); err != nil {
+			return err
+		}
+		if err := size("blobs", blobBucket); err != nil {
+			return err
+		}
+		for _, ind := range all {
+			if err := size("index_"+string(ind.Bucket()), ind.Bucket()); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < 256; i++ {
+			for j := 0; j < 256; j++ {
+				if err := size("values", bucketForVal(byte(i), byte(j))); err != nil {
+					return err
+				}
+				if err := size("refs", bucketForValRefs(byte(i), byte(j))); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return st, err
+}
+
+func (qs *QuadStore) estimateStats(ctx context.Context) (graph.Stats, error) {
+	st := graph.EstimateStats(qs)
+	sst := qs.sampledStats()
+	if sst == nil {
+		return st, nil
+	}
+	st.ValueTypes = make(map[string]int64, len(sst.ValueTypes))
+	for k, v := range sst.ValueTypes {
+		st.ValueTypes[k] = v
+	}
+	preds := make([]graph.Value, 0, len(sst.Predicates))
+	for id := range sst.Predicates {
+		preds = append(preds, Int64Value(id))
+	}
+	names, err := qs.ValuesOf(ctx, preds)
+	if err != nil {
+		return st, err
+	}
+	st.Predicates = make(map[string]int64, len(preds))
+	for i, p := range preds {
+		if names[i] != nil {
+			st.Predicates[quad.StringOf(names[i])] += sst.Predicates[uint64(p.(Int64Value))]
+		}
+	}
+	return st, nil
+}
+
+// metaStats is a key in the meta bucket with statistics sampled for the query optimizer.
+const metaStats = "stats"
+
+// DefaultStatsSample is the default number of log records read to update sampled statistics.
+const DefaultStatsSample = 10000
+
+// sampledStats are statistics estimated from a sample of log records. They are persisted in the meta bucket
+// and used by iterators to estimate their size without reading quad indexes.
+type sampledStats struct {
+	Updated time.Time `json:"updated"`
+	Nodes   int64     `json:"nodes"`
+	// Predicates is a number of quads for each predicate, indexed by node ID.
+	Predicates map[uint64]int64 `json:"predicates"`
+	// ValueTypes is a number of nodes of each type, indexed by the names returned by graph.ValueType.
+	ValueTypes map[string]int64 `json:"value_types"`
+}
+
+// sampledStats returns sampled statistics of the store, or nil if they were never collected.
+// Persisted statistics are loaded on the first use.
+func (qs *QuadStore) sampledStats() *sampledStats {
+	qs.stats.RLock()
+	st, loaded := qs.stats.cur, qs.stats.loaded
+	qs.stats.RUnlock()
+	if loaded {
+		return st
+	}
+	qs.stats.Lock()
+	defer qs.stats.Unlock()
+	if !qs.stats.loaded {
+		st, err := qs.loadStats(context.TODO())
+		if err != nil {
+			clog.Warningf("kv: cannot load sampled statistics: %v", err)
+			return nil
+		}
+		qs.stats.cur, qs.stats.loaded = st, true
+	}
+	return qs.stats.cur
+}
+
+func (qs *QuadStore) setSampledStats(st *sampledStats) {
+	qs.stats.Lock()
+	qs.stats.cur, qs.stats.loaded = st, true
+	qs.stats.Unlock()
+}
+
+// estimateNodes returns an estimated number of nodes in the store, if statistics were sampled.
+func (qs *QuadStore) estimateNodes() (int64, bool) {
+	st := qs.sampledStats()
+	if st == nil {
+		return 0, false
+	}
+	return st.Nodes, true
+}
+
+// estimatePredicate returns an estimated number of quads with a given predicate.
+// It returns false if statistics were not sampled, or if the predicate was not found in the sample.
+func (qs *QuadStore) estimatePredicate(id uint64) (int64, bool) {
+	st := qs.sampledStats()
+	if st == nil {
+		return 0, false
+	}
+	n, ok := st.Predicates[id]
+	return n, ok
+}
+
+var _ graph.Estimator = (*QuadStore)(nil)
+
+// EstimateNodes implements graph.Estimator. Estimates are only available if statistics were sampled (see RefreshStats).
+func (qs *QuadStore) EstimateNodes(typ string) (int64, bool) {
+	st := qs.sampledStats()
+	if st == nil {
+		return 0, false
+	} else if typ == "" {
+		return st.Nodes, true
+	}
+	return st.ValueTypes[typ], true
+}
+
+// EstimateQuads implements graph.Estimator. Only the number of quads for a predicate can be estimated
+// from sampled statistics.
+func (qs *QuadStore) EstimateQuads(d quad.Direction, v graph.Value) (int64, bool) {
+	id, ok := v.(Int64Value)
+	if !ok || d != quad.Predicate {
+		return 0, false
+	}
+	return qs.estimatePredicate(uint64(id))
+}
+
+// loadStats loads sampled statistics persisted in the meta bucket.
+func (qs *QuadStore) loadStats(ctx context.Context) (*sampledStats, error) {
+	var data []byte
+	err := View(qs.db, func(tx BucketTx) error {
+		v, err := GetOne(ctx, tx.Bucket(metaBucket), []byte(metaStats))
+		data = v
+		return err
+	})
+	if err == ErrNotFound || err == ErrNoBucket {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	st := new(sampledStats)
+	if err = json.Unmarshal(data, st); err != nil {
+		// statistics are only used for estimates and will be replaced on the next refresh
+		clog.Warningf("kv: cannot decode sampled statistics: %v", err)
+		return nil, nil
+	}
+	return st, nil
+}
+
+var _ graph.StatsRefresher = (*QuadStore)(nil)
+
+// RefreshStats implements graph.StatsRefresher. It reads a sample of log records to estimate the number
+// of nodes of each type and the number of quads for each predicate. Statistics are persisted in the meta bucket
+// and are used by iterators to estimate their size. The number of records read is set by the "stats_sample" option;
+// records are read in small batches, thus writes are not blocked.
+//
+// Statistics can be updated periodically by setting the "stats_interval" option.
+func (qs *QuadStore) RefreshStats(ctx context.Context) error {
+	h := uint64(qs.horizon(ctx))
+	size := qs.Size()
+	step := uint64(1)
+	if n := uint64(qs.statsSample); n > 0 && h > n {
+		step = h / n
+	}
+	st := &sampledStats{
+		Updated:    time.Now(),
+		Predicates: make(map[uint64]int64),
+		ValueTypes: make(map[string]int64),
+	}
+	var read, quads int64
+	ids := make([]uint64, 0, nextBatch)
+	for id := 1 + uint64(rand.Int63n(int64(step))); id <= h; {
+		ids = ids[:0]
+		for ; id <= h && len(ids) < cap(ids); id += step {
+			ids = append(ids, id)
+		}
+		prims, err := qs.getPrimitives(ctx, ids)
+		if err != nil {
+			return err
+		}
+		read += int64(len(ids))
+		for _, p := range prims {
+			if p == nil || p.Deleted {
+				continue
+			}
+			if !p.IsNode() {
+				quads++
+				st.Predicates[p.GetDirection(quad.Predicate)]++
+				continue
+			}
+			st.Nodes++
+			if v, err := qs.decodeValue(ctx, nil, p); err == nil {
+				st.ValueTypes[graph.ValueType(v)]++
+			}
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+	}
+	// scale the sample to the whole log; the number of quads is known
+	if read != 0 && uint64(read) < h {
+		scale := float64(h) / float64(read)
+		st.Nodes = int64(float64(st.Nodes)*scale + 0.5)
+		for k, v := range st.ValueTypes {
+			st.ValueTypes[k] = int64(float64(v)*scale + 0.5)
+		}
+	}
+	if quads != 0 && quads != size {
+		scale := float64(size) / float64(quads)
+		for k, v := range st.Predicates {
+			st.Predicates[k] = int64(float64(v)*scale + 0.5)
+		}
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	err = Update(ctx, qs.db, func(tx BucketTx) error {
+		return tx.Bucket(metaBucket).Put([]byte(metaStats), data)
+	})
+	if err != nil {
+		return err
+	}
+	qs.setSampledStats(st)
+	return nil
+}
+
+// maintainStats periodically refreshes sampled statistics until the store is closed.
+func (qs *QuadStore) maintainStats(done <-chan struct{}, interval time.Duration) {
+	defer qs.tasks.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	refresh := func() {
+		if err := qs.RefreshStats(ctx); err != nil && ctx.Err() == nil {
+			clog.Warningf("kv: cannot refresh statistics: %v", err)
+		}
+	}
+	if st := qs.sampledStats(); st == nil || time.Since(st.Updated) >= interval {
+		refresh()
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			refresh()
+		}
+	}
+}
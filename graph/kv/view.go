@@ -0,0 +1,92 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/lru"
+)
+
+var _ graph.HorizonReader = (*QuadStore)(nil)
+
+// AtHorizon implements graph.HorizonReader. The view reads all data from a single snapshot
+// of the database, thus only the current horizon can be requested.
+//
+// It returns graph.ErrNotSupported if the database does not implement SnapshotReader. Bolt does not
+// implement it, since a long-running read transaction blocks writers that need to grow the database file.
+// The view must not be used concurrently.
+func (qs *QuadStore) AtHorizon(ctx context.Context, h int64) (graph.QuadStore, error) {
+	sr, ok := qs.db.(SnapshotReader)
+	if !ok {
+		return nil, graph.ErrNotSupported
+	}
+	tx, err := sr.ReadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	cur, err := qs.getMetaIntTx(ctx, tx, metaCommits)
+	if err == ErrNotFound {
+		err = nil
+	}
+	if err == nil {
+		err = graph.CheckHorizon(h, cur)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	view := newQuadStore(&pinnedKV{BucketKV: qs.db, tx: tx})
+	view.hasher = qs.hasher
+	view.blobThreshold, view.blobs = qs.blobThreshold, qs.blobs
+	qs.indexes.RLock()
+	view.indexes.all = qs.indexes.all
+	view.indexes.exists = qs.indexes.exists
+	qs.indexes.RUnlock()
+	// values might be removed and added again with a different ID, thus the cache is not shared
+	view.valueLRU = lru.New(2000)
+	// IDs of nodes are never reused, thus decoded values can be shared
+	view.names = qs.names
+	view.deltaLog = qs.deltaLog
+	view.setSampledStats(qs.sampledStats())
+	return view, nil
+}
+
+// pinnedKV runs all read transactions in a single snapshot. Writes are not allowed.
+type pinnedKV struct {
+	BucketKV
+	tx BucketTx
+}
+
+func (kv *pinnedKV) Tx(update bool) (BucketTx, error) {
+	if update {
+		return nil, graph.ErrReadOnly
+	}
+	return pinnedTx{kv.tx}, nil
+}
+
+// Close releases the snapshot. It does not close the database.
+func (kv *pinnedKV) Close() error {
+	return kv.tx.Rollback()
+}
+
+// pinnedTx is a transaction that ignores Commit and Rollback. The underlying transaction is closed with pinnedKV.
+type pinnedTx struct {
+	BucketTx
+}
+
+func (pinnedTx) Commit(ctx context.Context) error { return graph.ErrReadOnly }
+func (pinnedTx) Rollback() error                  { return nil }
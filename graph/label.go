@@ -0,0 +1,115 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"time"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// NewLabelWriter wraps a QuadWriter to set a default label on all added and removed quads
+// that have no label. Quads with a label are passed as-is. If label is nil, qw is returned.
+func NewLabelWriter(qw QuadWriter, label quad.Value) QuadWriter {
+	if label == nil {
+		return qw
+	}
+	return &labelWriter{qw: qw, label: label}
+}
+
+type labelWriter struct {
+	qw    QuadWriter
+	label quad.Value
+}
+
+func (w *labelWriter) stamp(q quad.Quad) quad.Quad {
+	if q.Label == nil {
+		q.Label = w.label
+	}
+	return q
+}
+
+func (w *labelWriter) AddQuad(q quad.Quad) error {
+	return w.qw.AddQuad(w.stamp(q))
+}
+
+func (w *labelWriter) AddQuadSet(quads []quad.Quad) error {
+	out := make([]quad.Quad, len(quads))
+	for i, q := range quads {
+		out[i] = w.stamp(q)
+	}
+	return w.qw.AddQuadSet(out)
+}
+
+func (w *labelWriter) RemoveQuad(q quad.Quad) error {
+	return w.qw.RemoveQuad(w.stamp(q))
+}
+
+func (w *labelWriter) stampTx(tx *Transaction) (*Transaction, error) {
+	out := NewTransactionN(len(tx.Deltas))
+	for _, d := range tx.Deltas {
+		q := w.stamp(d.Quad)
+		switch d.Action {
+		case Add:
+			out.AddQuad(q)
+		case Delete:
+			out.RemoveQuad(q)
+		case AddUnique, ReplaceObject, DeleteMatching:
+			out.Append(Delta{Quad: q, Action: d.Action})
+		default:
+			return nil, ErrInvalidAction
+		}
+	}
+	return out, nil
+}
+
+func (w *labelWriter) ApplyTransaction(tx *Transaction) error {
+	out, err := w.stampTx(tx)
+	if err != nil {
+		return err
+	}
+	return w.qw.ApplyTransaction(out)
+}
+
+var _ HorizonWriter = (*labelWriter)(nil)
+
+// ApplyTransactionAt implements HorizonWriter.
+func (w *labelWriter) ApplyTransactionAt(tx *Transaction, h int64) error {
+	out, err := w.stampTx(tx)
+	if err != nil {
+		return err
+	}
+	return ApplyTransactionAt(w.qw, out, h)
+}
+
+var _ ExpiringWriter = (*labelWriter)(nil)
+
+// ApplyTransactionWithExpiration implements ExpiringWriter.
+func (w *labelWriter) ApplyTransactionWithExpiration(tx *Transaction, expires time.Time) error {
+	out, err := w.stampTx(tx)
+	if err != nil {
+		return err
+	}
+	return ApplyTransactionWithExpiration(w.qw, out, expires)
+}
+
+// RemoveNode removes all quads with a given node regardless of their label.
+func (w *labelWriter) RemoveNode(v quad.Value) error {
+	return w.qw.RemoveNode(v)
+}
+
+func (w *labelWriter) Close() error {
+	return w.qw.Close()
+}
@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestLabelWriter(t *testing.T) {
+	rw := &recordWriter{}
+	w := NewLabelWriter(rw, quad.IRI("g"))
+
+	tx := NewTransaction()
+	tx.AddQuad(quad.MakeIRI("a", "b", "c", ""))
+	tx.AddQuad(quad.MakeIRI("a", "b", "d", "h"))
+	tx.RemoveQuad(quad.MakeIRI("a", "b", "e", ""))
+	if err := w.ApplyTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	exp := []Delta{
+		{Quad: quad.MakeIRI("a", "b", "c", "g"), Action: Add},
+		{Quad: quad.MakeIRI("a", "b", "d", "h"), Action: Add},
+		{Quad: quad.MakeIRI("a", "b", "e", "g"), Action: Delete},
+	}
+	if !reflect.DeepEqual(rw.txs, [][]Delta{exp}) {
+		t.Fatalf("unexpected deltas applied: %v", rw.txs)
+	}
+	if NewLabelWriter(rw, nil) != QuadWriter(rw) {
+		t.Fatal("writer without a label should not be wrapped")
+	}
+}
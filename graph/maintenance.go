@@ -0,0 +1,224 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotSupported is returned when a maintenance operation is not supported by the backend.
+var ErrNotSupported = errors.New("operation is not supported by the backend")
+
+// Compactor is an optional interface for QuadStores that can reclaim space left by removed data.
+type Compactor interface {
+	Compact(ctx context.Context) error
+}
+
+// StatsRefresher is an optional interface for QuadStores that cache statistics used by the query optimizer.
+type StatsRefresher interface {
+	// RefreshStats drops cached statistics, so they will be recalculated on the next use.
+	RefreshStats(ctx context.Context) error
+}
+
+// Indexer is an optional interface for QuadStores that can create or update their indexes.
+type Indexer interface {
+	EnsureIndexes(ctx context.Context) error
+}
+
+// Compact reclaims space left by removed data. It returns ErrNotSupported if QuadStore does not implement Compactor.
+func Compact(ctx context.Context, qs QuadStore) error {
+	if c, ok := Unwrap(qs).(Compactor); ok {
+		return c.Compact(ctx)
+	}
+	return ErrNotSupported
+}
+
+// RefreshStats drops statistics cached by QuadStore. It returns ErrNotSupported if QuadStore does not implement StatsRefresher.
+func RefreshStats(ctx context.Context, qs QuadStore) error {
+	if r, ok := Unwrap(qs).(StatsRefresher); ok {
+		return r.RefreshStats(ctx)
+	}
+	return ErrNotSupported
+}
+
+// EnsureIndexes creates missing indexes. It returns ErrNotSupported if QuadStore does not implement Indexer.
+func EnsureIndexes(ctx context.Context, qs QuadStore) error {
+	if ix, ok := Unwrap(qs).(Indexer); ok {
+		return ix.EnsureIndexes(ctx)
+	}
+	return ErrNotSupported
+}
+
+// Kinds of problems reported by Checker.
+const (
+	ProblemDanglingRef  = "dangling_ref"  // quad references a node that does not exist
+	ProblemRefCount     = "refcount"      // reference counter of a node does not match the number of quads using it
+	ProblemOrphanIndex  = "orphan_index"  // index entry points to a missing or different record
+	ProblemMissingIndex = "missing_index" // record is not present in the index
+	ProblemBadValue     = "bad_value"     // record or value cannot be decoded
+	ProblemCounter      = "counter"       // stored counter, like a size of the database, is wrong
+)
+
+// Problem is an inconsistency in internal structures of the database.
+type Problem struct {
+	Kind     string // one of Problem* constants
+	Desc     string // human-readable description
+	Repaired bool   // problem was fixed by Check
+}
+
+// Checker is an optional interface for QuadStores that can verify consistency of their internal structures.
+type Checker interface {
+	// Check verifies consistency of the database and returns a list of found problems.
+	// If repair is set, problems that can be fixed without losing any readable data are repaired.
+	Check(ctx context.Context, repair bool) ([]Problem, error)
+}
+
+// Check verifies consistency of the database. It returns ErrNotSupported if QuadStore does not implement Checker.
+func Check(ctx context.Context, qs QuadStore, repair bool) ([]Problem, error) {
+	if c, ok := Unwrap(qs).(Checker); ok {
+		return c.Check(ctx, repair)
+	}
+	return nil, ErrNotSupported
+}
+
+// DedupStats is a summary of data removed by Dedup.
+type DedupStats struct {
+	Quads int64 // duplicate quads
+	Nodes int64 // node values not used by any quad
+	// Bytes is an approximate size of removed records. It is zero if the backend cannot estimate it.
+	Bytes int64
+}
+
+// Deduplicator is an optional interface for QuadStores that can remove duplicate quads and unused node values
+// left by partial writes or bugs in previous versions.
+type Deduplicator interface {
+	// Dedup removes duplicate quads, keeping the oldest one, and node values that are not used by any quad.
+	// If dryRun is set, data is only counted, but not removed.
+	Dedup(ctx context.Context, dryRun bool) (DedupStats, error)
+}
+
+// Dedup removes duplicate quads and unused node values. It returns ErrNotSupported if QuadStore does not implement Deduplicator.
+func Dedup(ctx context.Context, qs QuadStore, dryRun bool) (DedupStats, error) {
+	if d, ok := Unwrap(qs).(Deduplicator); ok {
+		return d.Dedup(ctx, dryRun)
+	}
+	return DedupStats{}, ErrNotSupported
+}
+
+// PurgeStats is a summary of data removed by Purge.
+type PurgeStats struct {
+	Quads      int64 // records of deleted quads
+	LogEntries int64 // entries of the delta log
+	// Bytes is an approximate size of removed records. It is zero if the backend cannot estimate it.
+	Bytes int64
+}
+
+// Purger is an optional interface for QuadStores that keep records of deleted quads (tombstones)
+// or a log of applied transactions.
+type Purger interface {
+	// Purge physically removes records of quads deleted before a given time and delta log entries
+	// of transactions applied before it. Backends may keep records newer than their retention window
+	// regardless of the time. If dryRun is set, records are only counted, but not removed.
+	Purge(ctx context.Context, before time.Time, dryRun bool) (PurgeStats, error)
+}
+
+// Purge removes tombstones and delta log entries older than a given time.
+// It returns ErrNotSupported if QuadStore does not implement Purger.
+func Purge(ctx context.Context, qs QuadStore, before time.Time, dryRun bool) (PurgeStats, error) {
+	if p, ok := Unwrap(qs).(Purger); ok {
+		return p.Purge(ctx, before, dryRun)
+	}
+	return PurgeStats{}, ErrNotSupported
+}
+
+// DefaultGCBatch is a number of nodes removed by GC in a single transaction, if not set explicitly.
+const DefaultGCBatch = 10000
+
+// GCStats is a summary of data removed by GC. GC only removes nodes, thus Quads are always zero.
+type GCStats struct {
+	DedupStats
+	Batches int64 // number of transactions used to remove nodes
+	// Compacted is set if the database was compacted after the removal.
+	Compacted bool
+}
+
+// GarbageCollector is an optional interface for QuadStores that can remove node values left after quads
+// using them were deleted.
+type GarbageCollector interface {
+	// GC removes nodes that are not referenced by any quad. Nodes are removed in transactions of up to
+	// a given number of nodes, and writes are not blocked between them. If dryRun is set, nodes are only counted.
+	GC(ctx context.Context, batch int, dryRun bool) (GCStats, error)
+}
+
+// GC removes nodes that are not referenced by any quad and compacts the database, if QuadStore implements Compactor.
+// It returns ErrNotSupported if QuadStore does not implement GarbageCollector.
+func GC(ctx context.Context, qs QuadStore, batch int, dryRun bool) (GCStats, error) {
+	g, ok := Unwrap(qs).(GarbageCollector)
+	if !ok {
+		return GCStats{}, ErrNotSupported
+	}
+	if batch <= 0 {
+		batch = DefaultGCBatch
+	}
+	st, err := g.GC(ctx, batch, dryRun)
+	if err != nil || dryRun {
+		return st, err
+	}
+	if err = Compact(ctx, qs); err == nil {
+		st.Compacted = true
+	} else if err == ErrNotSupported {
+		err = nil
+	}
+	return st, err
+}
+
+// Snapshotter is an optional interface for QuadStores that can copy all data without blocking writes.
+type Snapshotter interface {
+	// SnapshotFormat returns a name of the snapshot format. Snapshots can only be restored
+	// by backends with the same format.
+	SnapshotFormat() string
+	// Snapshot writes a consistent copy of the database to w.
+	Snapshot(ctx context.Context, w io.Writer) error
+	// RestoreSnapshot loads a snapshot into an empty database.
+	RestoreSnapshot(ctx context.Context, r io.Reader) error
+}
+
+// SnapshotFormat returns the snapshot format of QuadStore, or an empty string if it does not implement Snapshotter.
+func SnapshotFormat(qs QuadStore) string {
+	if s, ok := Unwrap(qs).(Snapshotter); ok {
+		return s.SnapshotFormat()
+	}
+	return ""
+}
+
+// Snapshot writes a consistent copy of the database to w. It returns ErrNotSupported if QuadStore does not implement Snapshotter.
+func Snapshot(ctx context.Context, qs QuadStore, w io.Writer) error {
+	if s, ok := Unwrap(qs).(Snapshotter); ok {
+		return s.Snapshot(ctx, w)
+	}
+	return ErrNotSupported
+}
+
+// RestoreSnapshot loads a snapshot of a given format into an empty database.
+// It returns ErrNotSupported if QuadStore does not implement Snapshotter or uses a different snapshot format.
+func RestoreSnapshot(ctx context.Context, qs QuadStore, format string, r io.Reader) error {
+	if s, ok := Unwrap(qs).(Snapshotter); ok && s.SnapshotFormat() == format {
+		return s.RestoreSnapshot(ctx, r)
+	}
+	return ErrNotSupported
+}
@@ -16,6 +16,7 @@ package memstore
 
 import (
 	"context"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
@@ -30,7 +31,9 @@ type AllIterator struct {
 	qs    *QuadStore
 	all   []*primitive
 	maxid int64 // id of last observed insert (prim id)
+	after int64 // iteration starts after this id
 	nodes bool
+	now   int64 // expired quads are skipped
 
 	i    int // index into qs.all
 	cur  *primitive
@@ -42,12 +45,15 @@ func newAllIterator(qs *QuadStore, nodes bool, maxid int64) *AllIterator {
 		uid: iterator.NextUID(),
 		qs:  qs, all: qs.cloneAll(), nodes: nodes,
 		i: -1, maxid: maxid,
+		now: time.Now().UnixNano(),
 	}
 }
 
 func (it *AllIterator) Clone() graph.Iterator {
 	it2 := newAllIterator(it.qs, it.nodes, it.maxid)
+	it2.after = it.after
 	it2.tags.CopyFrom(it)
+	it2.now = it.now
 	return it2
 }
 
@@ -58,12 +64,12 @@ func (it *AllIterator) Reset() {
 }
 
 func (it *AllIterator) ok(p *primitive) bool {
-	if p.ID > it.maxid {
+	if p.ID > it.maxid || p.ID <= it.after {
 		return false
 	} else if it.nodes && p.Value != nil {
 		return true
 	} else if !it.nodes && !p.Quad.Zero() {
-		return true
+		return !p.expired(it.now)
 	}
 	return false
 }
@@ -149,8 +155,13 @@ func (it *AllIterator) String() string {
 func (it *AllIterator) NextPath(ctx context.Context) bool { return false }
 
 func (it *AllIterator) Size() (int64, bool) {
-	// TODO: use maxid?
-	return int64(len(it.all)), true
+	// all contains both quads and nodes, thus sizes of the indexes are used instead;
+	// they are exact only if there were no writes since the iterator was created
+	exact := it.maxid == it.qs.last
+	if it.nodes {
+		return int64(len(it.qs.vals)), exact
+	}
+	return int64(len(it.qs.quads)), exact
 }
 func (it *AllIterator) Stats() graph.IteratorStats {
 	st := graph.IteratorStats{NextCost: 1, ContainsCost: 1}
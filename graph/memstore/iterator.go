@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
@@ -40,6 +41,8 @@ type Iterator struct {
 
 	d     quad.Direction
 	value int64
+	after int64 // iteration starts after this id
+	now   int64 // expired quads are skipped
 }
 
 func NewIterator(tree *Tree, qs *QuadStore, d quad.Direction, value int64) *Iterator {
@@ -50,6 +53,7 @@ func NewIterator(tree *Tree, qs *QuadStore, d quad.Direction, value int64) *Iter
 		tree:  tree,
 		d:     d,
 		value: value,
+		now:   time.Now().UnixNano(),
 	}
 }
 
@@ -74,6 +78,8 @@ func (it *Iterator) TagResults(dst map[string]graph.Value) {
 func (it *Iterator) Clone() graph.Iterator {
 	m := NewIterator(it.tree, it.qs, it.d, it.value)
 	m.tags.CopyFrom(it)
+	m.after = it.after
+	m.now = it.now
 	return m
 }
 
@@ -83,7 +89,9 @@ func (it *Iterator) Close() error {
 
 func (it *Iterator) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
-	if it.iter == nil {
+	if it.iter == nil && it.after != 0 {
+		it.iter, _ = it.tree.Seek(it.after + 1)
+	} else if it.iter == nil {
 		it.iter, it.err = it.tree.SeekFirst()
 		if it.err == io.EOF || it.iter == nil {
 			it.err = nil
@@ -100,6 +108,9 @@ func (it *Iterator) Next(ctx context.Context) bool {
 			}
 			return graph.NextLogOut(it, false)
 		}
+		if !it.nodes && p.expired(it.now) {
+			continue
+		}
 		it.cur = p
 		return graph.NextLogOut(it, true)
 	}
@@ -141,7 +152,7 @@ func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
 			return graph.ContainsLogOut(it, v, true)
 		}
 	case qprim:
-		if v.p.Quad.Dir(it.d) == it.value {
+		if v.p.Quad.Dir(it.d) == it.value && !v.p.expired(it.now) {
 			it.cur = v.p
 			return graph.ContainsLogOut(it, v, true)
 		}
@@ -0,0 +1,279 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad/nquads"
+)
+
+const (
+	snapshotFile = "snapshot.nq"
+	deltaLogFile = "deltas.log"
+)
+
+// PersistOptions configures on-disk persistence of the in-memory store.
+type PersistOptions struct {
+	// Dir is a directory for the snapshot and the delta log.
+	Dir string
+	// Interval between periodic snapshots. If zero, the snapshot is only written when the store is closed.
+	Interval time.Duration
+}
+
+// persister keeps a snapshot of the store and an append-only log of deltas applied after it.
+type persister struct {
+	dir string
+	// mu serializes writes with snapshots, so no delta is lost when the log is truncated.
+	mu   sync.Mutex
+	log  *os.File
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open creates an in-memory quad store that is persisted to a directory.
+// The store is loaded from the snapshot and the delta log found in the directory, if any.
+// Deltas applied to the store are appended to the delta log, and the snapshot is rewritten periodically and on Close.
+//
+// Provenance and expiration of quads are not persisted.
+func Open(opt PersistOptions) (*QuadStore, error) {
+	if err := os.MkdirAll(opt.Dir, 0755); err != nil {
+		return nil, err
+	}
+	qs := newQuadStore()
+	if err := qs.loadSnapshot(filepath.Join(opt.Dir, snapshotFile)); err != nil {
+		return nil, err
+	}
+	if err := qs.replayLog(filepath.Join(opt.Dir, deltaLogFile)); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(opt.Dir, deltaLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	p := &persister{dir: opt.Dir, log: f}
+	qs.persist = p
+	if opt.Interval > 0 {
+		p.done = make(chan struct{})
+		p.wg.Add(1)
+		go qs.snapshotEvery(p.done, opt.Interval)
+	}
+	return qs, nil
+}
+
+func newPersistentStore(opt graph.Options) (graph.QuadStore, error) {
+	dir, err := opt.StringKey("snapshot_path", "")
+	if err != nil {
+		return nil, err
+	}
+	interval, err := opt.DurationKey("snapshot_interval", 0)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		if interval != 0 {
+			return nil, fmt.Errorf("memstore: snapshot_interval requires snapshot_path to be set")
+		}
+		return newQuadStore(), nil
+	}
+	return Open(PersistOptions{Dir: dir, Interval: interval})
+}
+
+func (qs *QuadStore) loadSnapshot(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := nquads.NewReader(bufio.NewReader(f), false)
+	for {
+		q, err := r.ReadQuad()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("memstore: cannot read snapshot: %v", err)
+		}
+		qs.AddQuad(q)
+	}
+	return nil
+}
+
+// replayLog applies deltas recorded in the log on top of the snapshot.
+// An incomplete line at the end of the log is left by an interrupted write; it is discarded.
+func (qs *QuadStore) replayLog(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var (
+		deltas []graph.Delta
+		off    int
+	)
+	for off < len(data) {
+		i := bytes.IndexByte(data[off:], '\n')
+		if i < 0 {
+			clog.Warningf("memstore: ignoring incomplete record at the end of the delta log")
+			if err = os.Truncate(path, int64(off)); err != nil {
+				return err
+			}
+			break
+		}
+		line := data[off : off+i]
+		off += i + 1
+		if len(line) < 2 {
+			continue
+		}
+		var d graph.Delta
+		switch line[0] {
+		case '+':
+			d.Action = graph.Add
+		case '-':
+			d.Action = graph.Delete
+		default:
+			return fmt.Errorf("memstore: invalid delta log record: %q", line)
+		}
+		d.Quad, err = nquads.Parse(string(line[2:]))
+		if err != nil {
+			return fmt.Errorf("memstore: cannot read delta log: %v", err)
+		}
+		deltas = append(deltas, d)
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+	return qs.applyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true}, nil, 0)
+}
+
+// appendLog records applied deltas in the log. It must be called with p.mu held.
+func (p *persister) appendLog(deltas []graph.Delta) error {
+	var buf bytes.Buffer
+	w := nquads.NewWriter(&buf)
+	for _, d := range deltas {
+		switch d.Action {
+		case graph.Add:
+			buf.WriteString("+ ")
+		case graph.Delete:
+			buf.WriteString("- ")
+		default:
+			continue
+		}
+		if err := w.WriteQuad(d.Quad); err != nil {
+			return err
+		}
+	}
+	// single write per transaction, so an interrupted write only corrupts the last record
+	_, err := p.log.Write(buf.Bytes())
+	return err
+}
+
+// Snapshot writes all quads of the store to the snapshot file and truncates the delta log.
+// It is a no-op if the store was not opened with persistence enabled.
+func (qs *QuadStore) Snapshot() error {
+	p := qs.persist
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return qs.snapshot(p)
+}
+
+func (qs *QuadStore) snapshot(p *persister) error {
+	path := filepath.Join(p.dir, snapshotFile)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	w := nquads.NewWriter(bw)
+	for _, pr := range qs.all {
+		if pr.Quad.Zero() {
+			continue
+		}
+		if err = w.WriteQuad(qs.lookupQuadDirs(pr.Quad)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// all deltas are in the snapshot now
+	if err = p.log.Truncate(0); err != nil {
+		return err
+	}
+	return p.log.Sync()
+}
+
+func (qs *QuadStore) snapshotEvery(done <-chan struct{}, interval time.Duration) {
+	defer qs.persist.wg.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			if err := qs.Snapshot(); err != nil {
+				clog.Warningf("memstore: cannot write snapshot: %v", err)
+			}
+		}
+	}
+}
+
+// closePersist stops periodic snapshots and writes the final one.
+func (qs *QuadStore) closePersist() error {
+	p := qs.persist
+	if p.done != nil {
+		close(p.done)
+		p.wg.Wait()
+		p.done = nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := qs.snapshot(p)
+	if err2 := p.log.Close(); err == nil {
+		err = err2
+	}
+	qs.persist = nil
+	return err
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/stretchr/testify/require"
+)
+
+func storeQuads(t testing.TB, qs graph.QuadStore) []string {
+	ctx := context.Background()
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	var out []string
+	for it.Next(ctx) {
+		out = append(out, qs.Quad(it.Result()).String())
+	}
+	require.NoError(t, it.Err())
+	sort.Strings(out)
+	return out
+}
+
+func TestPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley_memstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	qs, err := Open(PersistOptions{Dir: dir})
+	require.NoError(t, err)
+
+	var deltas []graph.Delta
+	for _, q := range simpleGraph {
+		deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Add})
+	}
+	require.NoError(t, qs.ApplyDeltas(deltas, graph.IgnoreOpts{}))
+	require.NoError(t, qs.Snapshot())
+
+	// deltas after the snapshot are only recorded in the log
+	del := quad.MakeRaw("E", "follows", "F", "")
+	add := quad.Make("E", "age", 21, nil)
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{
+		{Quad: del, Action: graph.Delete},
+		{Quad: add, Action: graph.Add},
+	}, graph.IgnoreOpts{}))
+	expect := storeQuads(t, qs)
+
+	// simulate a crash: drop the store without the final snapshot
+	qs.persist.log.Close()
+	qs.persist = nil
+
+	// and an interrupted write to the log
+	f, err := os.OpenFile(filepath.Join(dir, deltaLogFile), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("+ <A> <follows>")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	qs, err = Open(PersistOptions{Dir: dir})
+	require.NoError(t, err)
+	require.Equal(t, expect, storeQuads(t, qs))
+	require.NoError(t, qs.Close())
+
+	info, err := os.Stat(filepath.Join(dir, deltaLogFile))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), info.Size(), "log should be truncated by the final snapshot")
+
+	qs, err = Open(PersistOptions{Dir: dir})
+	require.NoError(t, err)
+	defer qs.Close()
+	require.Equal(t, expect, storeQuads(t, qs))
+}
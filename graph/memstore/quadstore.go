@@ -15,9 +15,11 @@
 package memstore
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
@@ -28,8 +30,8 @@ const QuadStoreType = "memstore"
 
 func init() {
 	graph.RegisterQuadStore(QuadStoreType, graph.QuadStoreRegistration{
-		NewFunc: func(string, graph.Options) (graph.QuadStore, error) {
-			return newQuadStore(), nil
+		NewFunc: func(_ string, opt graph.Options) (graph.QuadStore, error) {
+			return newPersistentStore(opt)
 		},
 		UpgradeFunc:  nil,
 		InitFunc:     nil,
@@ -91,6 +93,13 @@ type primitive struct {
 	Quad  internalQuad
 	Value quad.Value
 	refs  int
+	prov  *graph.Provenance
+	// expires is an expiration time of a quad in Unix nanoseconds, or zero
+	expires int64
+}
+
+func (p *primitive) expired(now int64) bool {
+	return p.expires != 0 && p.expires <= now
 }
 
 type internalQuad struct {
@@ -140,6 +149,9 @@ type QuadStore struct {
 	reading bool         // someone else might be reading "all" slice - next insert/delete should clone it
 	index   QuadDirectionIndex
 	horizon int64 // used only to assign ids to tx
+	notify  graph.Notifier
+	ro      bool // read-only view
+	persist *persister
 	// vip_index map[string]map[int64]map[string]map[int64]*b.Tree
 }
 
@@ -203,6 +215,10 @@ func (qs *QuadStore) resolveVal(v quad.Value, add bool) (int64, bool) {
 				return id, ok
 			}
 			qs.appendPrimitive(&primitive{ID: id, refs: 1})
+			if id > qs.last {
+				// node was loaded from a dump of the store; do not reuse its id
+				qs.last = id
+			}
 			return id, true
 		}
 	}
@@ -368,13 +384,34 @@ func (qs *QuadStore) findQuad(q quad.Quad) (int64, internalQuad, bool) {
 }
 
 func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	return qs.applyDeltas(deltas, ignoreOpts, nil, 0)
+}
+
+// applyDeltas applies deltas to the store. If prov is set, it is recorded for all added quads.
+// If expires is not zero, added quads expire at this time (in Unix nanoseconds).
+func (qs *QuadStore) applyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts, prov *graph.Provenance, expires int64) error {
+	if qs.ro {
+		return graph.ErrReadOnly
+	}
+	if p := qs.persist; p != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+	}
+	if graph.HasExtendedDeltas(deltas) {
+		var err error
+		deltas, err = graph.ResolveDeltas(context.TODO(), qs, deltas)
+		if err != nil {
+			return err
+		}
+	}
+	now := time.Now().UnixNano()
 	// Precheck the whole transaction (if required)
 	if !ignoreOpts.IgnoreDup || !ignoreOpts.IgnoreMissing {
 		for _, d := range deltas {
 			switch d.Action {
 			case graph.Add:
 				if !ignoreOpts.IgnoreDup {
-					if _, _, ok := qs.findQuad(d.Quad); ok {
+					if id, _, ok := qs.findQuad(d.Quad); ok && !qs.prim[id].expired(now) {
 						return &graph.DeltaError{Delta: d, Err: graph.ErrQuadExists}
 					}
 				}
@@ -390,13 +427,31 @@ func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOp
 		}
 	}
 
+	var applied []graph.Delta
+	notify := qs.notify.Active()
 	for _, d := range deltas {
 		switch d.Action {
 		case graph.Add:
-			qs.AddQuad(d.Quad)
+			if id, _, ok := qs.findQuad(d.Quad); ok && qs.prim[id].expired(now) {
+				// replace expired quad
+				qs.Delete(id)
+			}
+			id, ok := qs.AddQuad(d.Quad)
+			if ok && prov != nil {
+				qs.prim[id].prov = prov
+			}
+			if ok && expires != 0 {
+				qs.prim[id].expires = expires
+			}
+			if ok && notify {
+				applied = append(applied, d)
+			}
 		case graph.Delete:
 			if id, _, ok := qs.findQuad(d.Quad); ok {
 				qs.Delete(id)
+				if notify {
+					applied = append(applied, d)
+				}
 			}
 		default:
 			// TODO: ideally we should rollback it
@@ -404,9 +459,133 @@ func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOp
 		}
 	}
 	qs.horizon++
+	if qs.persist != nil {
+		if err := qs.persist.appendLog(deltas); err != nil {
+			return fmt.Errorf("memstore: cannot write delta log: %v", err)
+		}
+	}
+	if len(applied) != 0 {
+		now := time.Now()
+		changes := make([]graph.Change, 0, len(applied))
+		for _, d := range applied {
+			changes = append(changes, graph.Change{Delta: d, Horizon: qs.horizon, Timestamp: now})
+		}
+		qs.notify.Notify(changes)
+	}
 	return nil
 }
 
+var _ graph.HorizonReader = (*QuadStore)(nil)
+
+// AtHorizon implements graph.HorizonReader. History is not retained, thus only the current horizon
+// can be requested. The view is a copy of all quads in the store.
+func (qs *QuadStore) AtHorizon(ctx context.Context, h int64) (graph.QuadStore, error) {
+	if err := graph.CheckHorizon(h, qs.horizon); err != nil {
+		return nil, err
+	}
+	view := newQuadStore()
+	for _, p := range qs.all {
+		if p.Quad.Zero() {
+			continue
+		}
+		id, _ := view.AddQuad(qs.lookupQuadDirs(p.Quad))
+		view.prim[id].prov = p.prov
+		view.prim[id].expires = p.expires
+	}
+	view.horizon = qs.horizon
+	view.ro = true
+	return view, nil
+}
+
+// Freeze makes the store read-only and sets its horizon to h.
+// It is used to build views of other stores in memory.
+func (qs *QuadStore) Freeze(h int64) {
+	qs.horizon, qs.ro = h, true
+}
+
+var _ graph.Subscriber = (*QuadStore)(nil)
+
+// Subscribe implements graph.Subscriber.
+func (qs *QuadStore) Subscribe(ctx context.Context) (*graph.Subscription, error) {
+	return qs.notify.Subscribe(ctx), nil
+}
+
+var _ graph.HorizonStore = (*QuadStore)(nil)
+
+// Horizon implements graph.HorizonStore. It returns the number of applied transactions.
+func (qs *QuadStore) Horizon(ctx context.Context) (int64, error) {
+	return qs.horizon, nil
+}
+
+// ApplyDeltasAt implements graph.HorizonStore.
+func (qs *QuadStore) ApplyDeltasAt(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts, h int64) error {
+	if qs.horizon != h {
+		return &graph.HorizonConflictError{Expected: h, Actual: qs.horizon}
+	}
+	return qs.ApplyDeltas(deltas, ignoreOpts)
+}
+
+var _ graph.CapabilityReporter = (*QuadStore)(nil)
+
+// Capabilities implements graph.CapabilityReporter.
+func (qs *QuadStore) Capabilities() graph.Capabilities {
+	return graph.Capabilities{
+		Transactions:      true,
+		ChangeFeed:        true,
+		HorizonReads:      true,
+		ConditionalWrites: true,
+		Provenance:        true,
+	}
+}
+
+var _ graph.ExpiringStore = (*QuadStore)(nil)
+
+// ApplyDeltasWithExpiration implements graph.ExpiringStore.
+func (qs *QuadStore) ApplyDeltasWithExpiration(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts, expires time.Time) error {
+	return qs.applyDeltas(deltas, ignoreOpts, nil, expires.UnixNano())
+}
+
+// ExpireQuads implements graph.ExpiringStore.
+func (qs *QuadStore) ExpireQuads(ctx context.Context, now time.Time) (int, error) {
+	ts := now.UnixNano()
+	var deltas []graph.Delta
+	for _, p := range qs.all {
+		if !p.Quad.Zero() && p.expired(ts) {
+			deltas = append(deltas, graph.Delta{Quad: qs.lookupQuadDirs(p.Quad), Action: graph.Delete})
+		}
+	}
+	if len(deltas) == 0 {
+		return 0, nil
+	}
+	if err := qs.applyDeltas(deltas, graph.IgnoreOpts{IgnoreMissing: true}, nil, 0); err != nil {
+		return 0, err
+	}
+	return len(deltas), nil
+}
+
+var _ graph.ProvenanceStore = (*QuadStore)(nil)
+
+// ApplyDeltasWithProvenance implements graph.ProvenanceStore.
+func (qs *QuadStore) ApplyDeltasWithProvenance(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts, p graph.Provenance) error {
+	if p.Time.IsZero() {
+		p.Time = time.Now().UTC()
+	}
+	return qs.applyDeltas(deltas, ignoreOpts, &p, 0)
+}
+
+// ProvenanceOf implements graph.ProvenanceStore.
+func (qs *QuadStore) ProvenanceOf(ctx context.Context, q quad.Quad) (*graph.Provenance, error) {
+	id, _, ok := qs.findQuad(q)
+	if !ok {
+		return nil, graph.ErrQuadNotExist
+	}
+	if p := qs.prim[id].prov; p != nil {
+		pc := *p
+		return &pc, nil
+	}
+	return nil, nil
+}
+
 func asID(v graph.Value) (int64, bool) {
 	switch v := v.(type) {
 	case bnode:
@@ -454,6 +633,29 @@ func (qs *QuadStore) QuadIterator(d quad.Direction, value graph.Value) graph.Ite
 	return iterator.NewNull()
 }
 
+var _ graph.Estimator = (*QuadStore)(nil)
+
+// EstimateNodes implements graph.Estimator. The total number of nodes is exact, nodes of a given type are not counted.
+func (qs *QuadStore) EstimateNodes(typ string) (int64, bool) {
+	if typ != "" {
+		return 0, false
+	}
+	return int64(len(qs.vals)), true
+}
+
+// EstimateQuads implements graph.Estimator. The number is taken from the size of quad index.
+func (qs *QuadStore) EstimateQuads(d quad.Direction, v graph.Value) (int64, bool) {
+	id, ok := asID(v)
+	if !ok {
+		return 0, true
+	}
+	index, ok := qs.index.Get(d, id)
+	if !ok {
+		return 0, true
+	}
+	return int64(index.Len()), true
+}
+
 func (qs *QuadStore) Size() int64 {
 	return int64(len(qs.prim))
 }
@@ -505,4 +707,10 @@ func (qs *QuadStore) NodesAllIterator() graph.Iterator {
 	return newAllIterator(qs, true, qs.last)
 }
 
-func (qs *QuadStore) Close() error { return nil }
+func (qs *QuadStore) Close() error {
+	qs.notify.CloseAll(graph.ErrStoreClosed)
+	if qs.persist != nil {
+		return qs.closePersist()
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"encoding/binary"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.QuadSeeker = (*QuadStore)(nil)
+
+// SeekQuads implements graph.QuadSeeker. Positions are ids of quads, which grow with each write.
+//
+// The index of the first pattern direction is used to list quads and other directions are checked for each quad.
+func (qs *QuadStore) SeekQuads(pattern quad.Quad, after []byte) graph.Iterator {
+	var id int64
+	if len(after) == 8 {
+		id = int64(binary.BigEndian.Uint64(after))
+	} else if len(after) != 0 {
+		return iterator.NewError(graph.ErrInvalidPosition)
+	}
+	var its []graph.Iterator
+	for _, d := range quad.Directions {
+		v := pattern.Get(d)
+		if v == nil {
+			continue
+		}
+		it, ok := qs.QuadIterator(d, qs.ValueOf(v)).(*Iterator)
+		if !ok {
+			// no quads with this value
+			return iterator.NewNull()
+		}
+		its = append(its, it)
+	}
+	if len(its) == 0 {
+		all := newAllIterator(qs, false, qs.last)
+		all.after = id
+		return all
+	}
+	its[0].(*Iterator).after = id
+	if len(its) == 1 {
+		return its[0]
+	}
+	return iterator.NewAnd(qs, its...)
+}
+
+// QuadPosition implements graph.QuadSeeker.
+func (qs *QuadStore) QuadPosition(v graph.Value) []byte {
+	p, ok := v.(qprim)
+	if !ok {
+		return nil
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(p.p.ID))
+	return buf[:]
+}
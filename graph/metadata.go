@@ -0,0 +1,53 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import "context"
+
+// MetadataStore is an optional interface for QuadStores that can persist small records alongside the data,
+// for example checkpoints of long-running operations.
+type MetadataStore interface {
+	// GetMetadata returns a record stored for the key, or nil if it is not set.
+	GetMetadata(ctx context.Context, key string) ([]byte, error)
+	// SetMetadata stores a record for the key. Nil value removes the record.
+	SetMetadata(ctx context.Context, key string, val []byte) error
+}
+
+// MetadataWriter is an optional interface for QuadStores that can persist metadata records
+// in the same transaction with deltas.
+type MetadataWriter interface {
+	MetadataStore
+	// ApplyDeltasWithMetadata applies deltas and stores metadata records atomically. Nil value removes the record.
+	// Records are not changed if deltas cannot be applied.
+	ApplyDeltasWithMetadata(deltas []Delta, opts IgnoreOpts, meta map[string][]byte) error
+}
+
+// GetMetadata returns a metadata record stored in QuadStore, or nil if it is not set.
+// It returns ErrNotSupported if QuadStore does not implement MetadataStore.
+func GetMetadata(ctx context.Context, qs QuadStore, key string) ([]byte, error) {
+	if m, ok := Unwrap(qs).(MetadataStore); ok {
+		return m.GetMetadata(ctx, key)
+	}
+	return nil, ErrNotSupported
+}
+
+// SetMetadata stores a metadata record in QuadStore. Nil value removes the record.
+// It returns ErrNotSupported if QuadStore does not implement MetadataStore.
+func SetMetadata(ctx context.Context, qs QuadStore, key string, val []byte) error {
+	if m, ok := Unwrap(qs).(MetadataStore); ok {
+		return m.SetMetadata(ctx, key, val)
+	}
+	return ErrNotSupported
+}
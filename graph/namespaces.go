@@ -0,0 +1,121 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cayleygraph/cayley/voc"
+)
+
+// namespacesKey is a metadata key for the list of namespaces persisted in the store.
+const namespacesKey = "namespaces"
+
+// ErrNamespaceNotExists is returned when removing a namespace that is not stored in the database.
+var ErrNamespaceNotExists = errors.New("namespace does not exist")
+
+// nsMu serializes updates of the namespace list, since metadata records have no compare-and-swap.
+var nsMu sync.Mutex
+
+// Namespaces returns namespaces persisted in the QuadStore metadata, sorted by prefix.
+// It returns ErrNotSupported if QuadStore does not implement MetadataStore.
+func Namespaces(ctx context.Context, qs QuadStore) ([]voc.Namespace, error) {
+	data, err := GetMetadata(ctx, qs, namespacesKey)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var list []voc.Namespace
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid namespaces record: %v", err)
+	}
+	return list, nil
+}
+
+func setNamespaces(ctx context.Context, qs QuadStore, list []voc.Namespace) error {
+	if len(list) == 0 {
+		return SetMetadata(ctx, qs, namespacesKey, nil)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Prefix < list[j].Prefix })
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return SetMetadata(ctx, qs, namespacesKey, data)
+}
+
+// AddNamespace persists a namespace in the QuadStore metadata, replacing a namespace with the same prefix.
+// It returns ErrNotSupported if QuadStore does not implement MetadataStore.
+func AddNamespace(ctx context.Context, qs QuadStore, ns voc.Namespace) error {
+	nsMu.Lock()
+	defer nsMu.Unlock()
+	list, err := Namespaces(ctx, qs)
+	if err != nil {
+		return err
+	}
+	for i, cur := range list {
+		if cur.Prefix == ns.Prefix {
+			if cur.Full == ns.Full {
+				return nil
+			}
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	return setNamespaces(ctx, qs, append(list, ns))
+}
+
+// DeleteNamespace removes a namespace with a given prefix from the QuadStore metadata.
+// It returns ErrNamespaceNotExists if the namespace is not stored in the database,
+// and ErrNotSupported if QuadStore does not implement MetadataStore.
+func DeleteNamespace(ctx context.Context, qs QuadStore, pref string) error {
+	nsMu.Lock()
+	defer nsMu.Unlock()
+	list, err := Namespaces(ctx, qs)
+	if err != nil {
+		return err
+	}
+	for i, cur := range list {
+		if cur.Prefix == pref {
+			return setNamespaces(ctx, qs, append(list[:i], list[i+1:]...))
+		}
+	}
+	return ErrNamespaceNotExists
+}
+
+// LoadNamespaces registers namespaces persisted in the QuadStore in a given list.
+// If the list is nil, namespaces are registered globally, thus they are used for
+// IRI expansion in queries and compaction in outputs.
+// Stores that do not implement MetadataStore have no namespaces and are ignored.
+func LoadNamespaces(ctx context.Context, qs QuadStore, dst *voc.Namespaces) error {
+	list, err := Namespaces(ctx, qs)
+	if err == ErrNotSupported {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, ns := range list {
+		if dst != nil {
+			dst.Register(ns)
+		} else {
+			voc.Register(ns)
+		}
+	}
+	return nil
+}
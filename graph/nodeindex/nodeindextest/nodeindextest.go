@@ -0,0 +1,62 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nodeindextest contains helpers for tests of indexes maintained by nodeindex.Store.
+package nodeindextest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/nodeindex"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Nodes returns nodes of search hits as strings, in the order of hits. Hits must be a slice of
+// graph.TextHit, graph.GeoHit or graph.VectorHit.
+func Nodes(t testing.TB, hits interface{}, err error) []string {
+	require.NoError(t, err)
+	var nodes []quad.Value
+	switch hits := hits.(type) {
+	case []graph.TextHit:
+		for _, h := range hits {
+			nodes = append(nodes, h.Node)
+		}
+	case []graph.GeoHit:
+		for _, h := range hits {
+			nodes = append(nodes, h.Node)
+		}
+	case []graph.VectorHit:
+		for _, h := range hits {
+			nodes = append(nodes, h.Node)
+		}
+	default:
+		t.Fatalf("unexpected hits: %T", hits)
+	}
+	var out []string
+	for _, n := range nodes {
+		out = append(out, quad.StringOf(n))
+	}
+	return out
+}
+
+// Documents returns the number of documents in the index maintained by the store.
+func Documents(t testing.TB, s *nodeindex.Store) int64 {
+	n, err := s.Documents(context.TODO())
+	require.NoError(t, err)
+	return n
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeindex
+
+// Postings is a set of documents with an inverted list of terms they contain.
+// It is shared by in-memory indexes, which only decide what terms of a document are.
+// Postings is not safe for concurrent use.
+type Postings struct {
+	docs  map[string]posting
+	terms map[interface{}]map[string]struct{} // term -> keys of documents
+}
+
+type posting struct {
+	doc   interface{}
+	terms []interface{}
+}
+
+// NewPostings creates an empty set of documents.
+func NewPostings() *Postings {
+	p := &Postings{}
+	p.Reset()
+	return p
+}
+
+// Put replaces a document with a given key. Documents without terms are removed.
+func (p *Postings) Put(key string, doc interface{}, terms []interface{}) {
+	p.Delete(key)
+	if len(terms) == 0 {
+		return
+	}
+	p.docs[key] = posting{doc: doc, terms: terms}
+	for _, t := range terms {
+		set := p.terms[t]
+		if set == nil {
+			set = make(map[string]struct{})
+			p.terms[t] = set
+		}
+		set[key] = struct{}{}
+	}
+}
+
+// Delete removes a document with a given key.
+func (p *Postings) Delete(key string) {
+	d, ok := p.docs[key]
+	if !ok {
+		return
+	}
+	for _, t := range d.terms {
+		set := p.terms[t]
+		delete(set, key)
+		if len(set) == 0 {
+			delete(p.terms, t)
+		}
+	}
+	delete(p.docs, key)
+}
+
+// Get returns a document with a given key, or nil if it does not exist.
+func (p *Postings) Get(key string) interface{} {
+	return p.docs[key].doc
+}
+
+// Term returns keys of documents that contain the term. The set must not be modified.
+func (p *Postings) Term(t interface{}) map[string]struct{} {
+	return p.terms[t]
+}
+
+// Terms calls fn for each term and keys of documents that contain it.
+func (p *Postings) Terms(fn func(t interface{}, keys map[string]struct{})) {
+	for t, set := range p.terms {
+		fn(t, set)
+	}
+}
+
+// Len returns the number of documents.
+func (p *Postings) Len() int {
+	return len(p.docs)
+}
+
+// NumTerms returns the number of distinct terms.
+func (p *Postings) NumTerms() int {
+	return len(p.terms)
+}
+
+// Reset removes all documents.
+func (p *Postings) Reset() {
+	p.docs = make(map[string]posting)
+	p.terms = make(map[interface{}]map[string]struct{})
+}
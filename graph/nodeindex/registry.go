@@ -0,0 +1,73 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeindex
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry is a set of index types of a single kind. It is safe for concurrent use.
+//
+// Constructors of index types are stored as is, thus each kind of index defines its own constructor signature.
+type Registry struct {
+	name  string
+	def   string
+	mu    sync.RWMutex
+	types map[string]interface{}
+}
+
+// NewRegistry creates a registry for an index kind with a given name. Default type is used if the type is not set.
+func NewRegistry(name, def string) *Registry {
+	return &Registry{name: name, def: def, types: make(map[string]interface{})}
+}
+
+// Register registers a constructor of an index type. It panics if the type is already registered.
+func (r *Registry) Register(typ string, fnc interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.types[typ]; ok {
+		panic(fmt.Errorf("%s index %q is already registered", r.name, typ))
+	}
+	r.types[typ] = fnc
+}
+
+// Types returns names of all registered index types.
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.types))
+	for typ := range r.types {
+		out = append(out, typ)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Lookup returns a constructor of an index type. The default type is used if the type is empty.
+// It returns the name of the type and an error if the type is not registered.
+func (r *Registry) Lookup(typ string) (string, interface{}, error) {
+	if typ == "" {
+		typ = r.def
+	}
+	r.mu.RLock()
+	fnc, ok := r.types[typ]
+	r.mu.RUnlock()
+	if !ok {
+		return typ, nil, fmt.Errorf("%s: unknown index type: %q", r.name, typ)
+	}
+	return typ, fnc, nil
+}
@@ -0,0 +1,280 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nodeindex implements a QuadStore wrapper that keeps external indexes of node documents up to date.
+//
+// Each node that is a subject of indexed literals is stored in the index as a separate document with a field
+// per predicate. Text, geospatial and vector indexes only define which literals are indexed and how.
+package nodeindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var (
+	_ graph.Wrapper   = (*Store)(nil)
+	_ shape.Optimizer = (*Store)(nil)
+)
+
+// rebuildBatch is a number of documents written to the index at once during a rebuild.
+const rebuildBatch = 1000
+
+// Document is a set of indexed values of a single node.
+type Document struct {
+	Node quad.Value
+	// Fields maps indexed predicates to values returned by Index.Field.
+	Fields map[quad.IRI][]interface{}
+}
+
+// Index is an index of node documents maintained by Store.
+type Index interface {
+	// Field converts a literal of an indexed predicate to a value stored in the document.
+	// It returns false if the literal cannot be indexed.
+	Field(v quad.Value) (interface{}, bool)
+	// Update indexes documents, replacing previous versions of them, and removes documents of given nodes.
+	Update(ctx context.Context, docs []Document, del []quad.Value) error
+	// Count returns the number of indexed documents.
+	Count(ctx context.Context) (int64, error)
+	// Clear removes all documents from the index.
+	Clear(ctx context.Context) error
+	// Close releases resources associated with the index.
+	Close() error
+}
+
+// Config is a configuration of the index maintained by Store.
+type Config struct {
+	// Name of the index kind, used as a prefix of logs and errors.
+	Name string
+	// Type of the index, used in logs.
+	Type string
+	// Predicates with literals to index.
+	Predicates []quad.IRI
+}
+
+// Store is a QuadStore wrapper that maintains an index on each write.
+type Store struct {
+	graph.QuadStore
+	name  string
+	idx   Index
+	preds []quad.IRI
+	pset  map[quad.IRI]struct{}
+
+	// mu serializes writes, so documents are updated in the same order as the data
+	mu sync.Mutex
+}
+
+// New opens the index and wraps the QuadStore to maintain it. If the index is empty, it is built from the data.
+// Writes must go through the returned QuadStore for the index to be updated.
+func New(qs graph.QuadStore, conf Config, open func() (Index, error)) (*Store, error) {
+	if len(conf.Predicates) == 0 {
+		return nil, errors.New(conf.Name + ": no predicates to index")
+	}
+	idx, err := open()
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		QuadStore: qs, name: conf.Name, idx: idx,
+		preds: append([]quad.IRI{}, conf.Predicates...),
+		pset:  make(map[quad.IRI]struct{}, len(conf.Predicates)),
+	}
+	for _, p := range conf.Predicates {
+		s.pset[p] = struct{}{}
+	}
+	ctx := context.TODO()
+	n, err := idx.Count(ctx)
+	if err == nil && n == 0 && qs.Size() != 0 {
+		clog.Infof("%s: building %s index", conf.Name, conf.Type)
+		err = s.Rebuild(ctx)
+	}
+	if err != nil {
+		idx.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Unwrap implements graph.Wrapper.
+func (s *Store) Unwrap() graph.QuadStore {
+	return s.QuadStore
+}
+
+// Documents returns the number of documents in the index.
+func (s *Store) Documents(ctx context.Context) (int64, error) {
+	return s.idx.Count(ctx)
+}
+
+// Predicates returns indexed predicates.
+func (s *Store) Predicates() []quad.IRI {
+	return append([]quad.IRI{}, s.preds...)
+}
+
+// ApplyDeltas applies deltas to the underlying QuadStore and updates documents of affected nodes.
+func (s *Store) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.QuadStore.ApplyDeltas(deltas, opts); err != nil {
+		return err
+	}
+	var (
+		nodes []quad.Value
+		seen  = make(map[string]struct{})
+	)
+	for _, d := range deltas {
+		if _, ok := s.field(d.Quad); !ok {
+			continue
+		}
+		key := quad.StringOf(d.Quad.Subject)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		nodes = append(nodes, d.Quad.Subject)
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	if err := s.reindex(context.TODO(), nodes); err != nil {
+		return fmt.Errorf("%s: data was written, but the index was not updated: %v", s.name, err)
+	}
+	return nil
+}
+
+// field returns an indexed value of the quad, if its predicate is indexed.
+func (s *Store) field(q quad.Quad) (interface{}, bool) {
+	p, ok := q.Predicate.(quad.IRI)
+	if !ok {
+		return nil, false
+	}
+	if _, ok = s.pset[p]; !ok {
+		return nil, false
+	}
+	return s.idx.Field(q.Object)
+}
+
+// document reads all indexed values of a node.
+func (s *Store) document(ctx context.Context, node quad.Value) (Document, error) {
+	doc := Document{Node: node}
+	gv := s.QuadStore.ValueOf(node)
+	if gv == nil {
+		return doc, nil
+	}
+	it := s.QuadStore.QuadIterator(quad.Subject, gv)
+	defer it.Close()
+	for it.Next(ctx) {
+		q := s.QuadStore.Quad(it.Result())
+		v, ok := s.field(q)
+		if !ok {
+			continue
+		}
+		if doc.Fields == nil {
+			doc.Fields = make(map[quad.IRI][]interface{})
+		}
+		p := q.Predicate.(quad.IRI)
+		doc.Fields[p] = append(doc.Fields[p], v)
+	}
+	return doc, it.Err()
+}
+
+// reindex updates documents of given nodes.
+func (s *Store) reindex(ctx context.Context, nodes []quad.Value) error {
+	var (
+		docs []Document
+		del  []quad.Value
+	)
+	for _, n := range nodes {
+		doc, err := s.document(ctx, n)
+		if err != nil {
+			return err
+		}
+		if len(doc.Fields) == 0 {
+			del = append(del, n)
+		} else {
+			docs = append(docs, doc)
+		}
+	}
+	return s.idx.Update(ctx, docs, del)
+}
+
+// Rebuild drops the index and indexes all the data again. Writes are blocked during the rebuild.
+func (s *Store) Rebuild(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.idx.Clear(ctx); err != nil {
+		return err
+	}
+	seen := make(map[string]struct{})
+	var nodes []quad.Value
+	for _, p := range s.preds {
+		pv := s.QuadStore.ValueOf(p)
+		if pv == nil {
+			continue
+		}
+		it := s.QuadStore.QuadIterator(quad.Predicate, pv)
+		for it.Next(ctx) {
+			q := s.QuadStore.Quad(it.Result())
+			if _, ok := s.idx.Field(q.Object); !ok {
+				continue
+			}
+			key := quad.StringOf(q.Subject)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			nodes = append(nodes, q.Subject)
+			if len(nodes) >= rebuildBatch {
+				if err := s.reindex(ctx, nodes); err != nil {
+					it.Close()
+					return err
+				}
+				nodes = nodes[:0]
+			}
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	return s.reindex(ctx, nodes)
+}
+
+// OptimizeShape passes shape optimization to the underlying QuadStore, if supported.
+// Index searches are resolved by the shape optimizer with search interfaces of the wrapping store.
+func (s *Store) OptimizeShape(sh shape.Shape) (shape.Shape, bool) {
+	if o, ok := s.QuadStore.(shape.Optimizer); ok {
+		return o.OptimizeShape(sh)
+	}
+	return sh, false
+}
+
+// Close closes the index and the underlying QuadStore.
+func (s *Store) Close() error {
+	err := s.idx.Close()
+	if err2 := s.QuadStore.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,197 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nosql
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Iterator = (*AggregateIterator)(nil)
+
+// AggregateIterator returns a single value with an aggregate of a value field of nodes matching filters.
+// Unlike iterator.Aggregate, the value is computed by the database without loading nodes.
+type AggregateIterator struct {
+	uid        uint64
+	tags       graph.Tagger
+	qs         *QuadStore
+	fnc        iterator.Aggregation
+	field      []string
+	constraint []FieldFilter
+
+	done   bool
+	result quad.Value
+	err    error
+}
+
+// NewAggregateIterator creates an iterator that computes an aggregate of a value field of nodes matching all constraints.
+// Database must implement Aggregator.
+func NewAggregateIterator(qs *QuadStore, fnc iterator.Aggregation, field []string, constraints ...FieldFilter) *AggregateIterator {
+	return &AggregateIterator{
+		uid:        iterator.NextUID(),
+		qs:         qs,
+		fnc:        fnc,
+		field:      field,
+		constraint: constraints,
+	}
+}
+
+func (it *AggregateIterator) UID() uint64 {
+	return it.uid
+}
+
+func (it *AggregateIterator) Reset() {
+	it.done = false
+	it.result = nil
+	it.err = nil
+}
+
+func (it *AggregateIterator) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *AggregateIterator) TagResults(dst map[string]graph.Value) {
+	it.tags.TagResult(dst, it.Result())
+}
+
+func (it *AggregateIterator) Clone() graph.Iterator {
+	m := NewAggregateIterator(it.qs, it.fnc, it.field, it.constraint...)
+	m.tags.CopyFrom(it)
+	return m
+}
+
+func (it *AggregateIterator) SubIterators() []graph.Iterator {
+	return nil
+}
+
+func (it *AggregateIterator) Next(ctx context.Context) bool {
+	if it.done {
+		return false
+	}
+	it.done = true
+	agg, ok := it.qs.db.(Aggregator)
+	if !ok {
+		it.err = fmt.Errorf("database does not support aggregation: %T", it.qs.db)
+		return false
+	}
+	var fnc AggregateFunc
+	switch it.fnc {
+	case iterator.AggregateSum:
+		fnc = AggregateSum
+	case iterator.AggregateMin:
+		fnc = AggregateMin
+	case iterator.AggregateMax:
+		fnc = AggregateMax
+	case iterator.AggregateAvg:
+		fnc = AggregateAvg
+	default:
+		it.err = fmt.Errorf("unsupported aggregation: %v", it.fnc)
+		return false
+	}
+	v, err := agg.Aggregate(ctx, colNodes, fnc, it.field, it.constraint...)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.result = it.toValue(v)
+	return it.result != nil
+}
+
+// toValue converts an aggregate returned by the database to a value of the same type as iterator.Aggregate returns.
+func (it *AggregateIterator) toValue(v Value) quad.Value {
+	isInt := it.field[len(it.field)-1] == fldValInt && it.fnc != iterator.AggregateAvg
+	if v == nil {
+		if it.fnc != iterator.AggregateSum {
+			return nil
+		} else if isInt {
+			return quad.Int(0)
+		}
+		return quad.Float(0)
+	}
+	var f float64
+	switch v := v.(type) {
+	case Int:
+		if isInt {
+			return quad.Int(v)
+		}
+		f = float64(v)
+	case Float:
+		f = float64(v)
+	default:
+		return nil
+	}
+	if isInt {
+		return quad.Int(math.Round(f))
+	}
+	return quad.Float(f)
+}
+
+func (it *AggregateIterator) Err() error {
+	return it.err
+}
+
+func (it *AggregateIterator) Result() graph.Value {
+	if it.result == nil {
+		return nil
+	}
+	return graph.PreFetched(it.result)
+}
+
+func (it *AggregateIterator) Contains(ctx context.Context, v graph.Value) bool {
+	if !it.done {
+		it.Next(ctx)
+	}
+	if it.result == nil {
+		return false
+	}
+	if pv, ok := v.(graph.PreFetchedValue); ok {
+		return pv.NameOf() == it.result
+	}
+	return it.qs.NameOf(v) == it.result
+}
+
+func (it *AggregateIterator) NextPath(ctx context.Context) bool {
+	return false
+}
+
+func (it *AggregateIterator) Close() error {
+	return nil
+}
+
+func (it *AggregateIterator) Type() graph.Type { return graph.Aggregate }
+
+func (it *AggregateIterator) Optimize() (graph.Iterator, bool) { return it, false }
+
+func (it *AggregateIterator) Size() (int64, bool) {
+	return 1, false
+}
+
+func (it *AggregateIterator) Stats() graph.IteratorStats {
+	return graph.IteratorStats{
+		ContainsCost: 5,
+		NextCost:     5,
+		Size:         1,
+		ExactSize:    false,
+	}
+}
+
+func (it *AggregateIterator) String() string {
+	return fmt.Sprintf("NoSQLAggregate(%v)", it.fnc)
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nosql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Iterator = (*CountIterator)(nil)
+
+// CountIterator returns a single value with a number of documents matching filters.
+// Unlike iterator.Count, documents are counted by the database without loading them.
+type CountIterator struct {
+	uid        uint64
+	tags       graph.Tagger
+	qs         *QuadStore
+	collection string
+	offset     int64
+	limit      int64
+	constraint []FieldFilter
+
+	done   bool
+	result quad.Value
+	err    error
+}
+
+// NewCountIterator creates an iterator that counts documents of the collection matching all constraints.
+// The offset is subtracted from the count, and if the limit is positive, the count is capped to it.
+func NewCountIterator(qs *QuadStore, collection string, offset, limit int64, constraints ...FieldFilter) *CountIterator {
+	return &CountIterator{
+		uid:        iterator.NextUID(),
+		qs:         qs,
+		collection: collection,
+		offset:     offset,
+		limit:      limit,
+		constraint: constraints,
+	}
+}
+
+func (it *CountIterator) UID() uint64 {
+	return it.uid
+}
+
+func (it *CountIterator) Reset() {
+	it.done = false
+	it.result = nil
+	it.err = nil
+}
+
+func (it *CountIterator) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *CountIterator) TagResults(dst map[string]graph.Value) {
+	it.tags.TagResult(dst, it.Result())
+}
+
+func (it *CountIterator) Clone() graph.Iterator {
+	m := NewCountIterator(it.qs, it.collection, it.offset, it.limit, it.constraint...)
+	m.tags.CopyFrom(it)
+	return m
+}
+
+func (it *CountIterator) SubIterators() []graph.Iterator {
+	return nil
+}
+
+func (it *CountIterator) Next(ctx context.Context) bool {
+	if it.done {
+		return false
+	}
+	it.done = true
+	n, err := it.qs.db.Count(ctx, it.collection, it.constraint...)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.result = quad.Int(pageSize(n, it.offset, it.limit))
+	return true
+}
+
+func (it *CountIterator) Err() error {
+	return it.err
+}
+
+func (it *CountIterator) Result() graph.Value {
+	if it.result == nil {
+		return nil
+	}
+	return graph.PreFetched(it.result)
+}
+
+func (it *CountIterator) Contains(ctx context.Context, v graph.Value) bool {
+	if !it.done {
+		it.Next(ctx)
+	}
+	if it.result == nil {
+		return false
+	}
+	if pv, ok := v.(graph.PreFetchedValue); ok {
+		return pv.NameOf() == it.result
+	}
+	return it.qs.NameOf(v) == it.result
+}
+
+func (it *CountIterator) NextPath(ctx context.Context) bool {
+	return false
+}
+
+func (it *CountIterator) Close() error {
+	return nil
+}
+
+func (it *CountIterator) Type() graph.Type { return graph.Count }
+
+func (it *CountIterator) Optimize() (graph.Iterator, bool) { return it, false }
+
+func (it *CountIterator) Size() (int64, bool) {
+	return 1, true
+}
+
+func (it *CountIterator) Stats() graph.IteratorStats {
+	return graph.IteratorStats{
+		ContainsCost: 5,
+		NextCost:     5,
+		Size:         1,
+		ExactSize:    true,
+	}
+}
+
+func (it *CountIterator) String() string {
+	return fmt.Sprintf("NoSQLCount(%v)", it.collection)
+}
@@ -0,0 +1,626 @@
+// Package dynamodb implements a nosql backend for Amazon DynamoDB.
+//
+// Each collection is stored in a separate table with a string partition key that holds a composite key
+// of the document. Tables are created on demand with on-demand billing, and single-field string indexes
+// are created as global secondary indexes. Filters are translated to key conditions and filter expressions;
+// the rest (regular expressions, text and geo filters, sorting, limits and offsets) are applied by the client.
+//
+// Reads from tables are strongly consistent by default, while queries using secondary indexes are
+// eventually consistent, as guaranteed by DynamoDB.
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/nosql"
+)
+
+const Type = "dynamodb"
+
+const (
+	// DefaultRegion is used if the address is an endpoint URL and the region is not set.
+	DefaultRegion = "us-east-1"
+
+	batchWrite = 25  // maximal number of items in BatchWriteItem request
+	batchGet   = 100 // maximal number of keys in BatchGetItem request
+)
+
+var (
+	_ nosql.BatchInserter = (*DB)(nil)
+)
+
+func init() {
+	nosql.Register(Type, nosql.Registration{
+		NewFunc:      Open,
+		InitFunc:     Create,
+		IsPersistent: true,
+	})
+}
+
+// dialDynamo creates a DynamoDB client. Address is either a region name, or an endpoint URL
+// (for example, of DynamoDB Local). Credentials are taken from the environment or shared AWS config,
+// unless they are set in options.
+func dialDynamo(addr string, opt graph.Options) (*dynamodb.DynamoDB, error) {
+	region, err := opt.StringKey("region", "")
+	if err != nil {
+		return nil, err
+	}
+	var conf aws.Config
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		conf.Endpoint = aws.String(addr)
+		if region == "" {
+			region = DefaultRegion
+		}
+	} else if addr != "" {
+		region = addr
+	}
+	if region != "" {
+		conf.Region = aws.String(region)
+	}
+	id, err := opt.StringKey("access_key_id", "")
+	if err != nil {
+		return nil, err
+	}
+	if id != "" {
+		secret, err := opt.StringKey("secret_access_key", "")
+		if err != nil {
+			return nil, err
+		}
+		conf.Credentials = credentials.NewStaticCredentials(id, secret, "")
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            conf,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dynamodb.New(sess), nil
+}
+
+func dialDB(addr string, opt graph.Options) (*DB, error) {
+	cli, err := dialDynamo(addr, opt)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := opt.StringKey("table_prefix", nosql.DefaultDBName)
+	if err != nil {
+		return nil, err
+	}
+	consistent, err := opt.BoolKey("consistent_read", true)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{
+		cli: cli, prefix: prefix, consistent: consistent,
+		colls: make(map[string]*collection),
+	}, nil
+}
+
+func Create(addr string, opt graph.Options) (nosql.Database, error) {
+	return dialDB(addr, opt)
+}
+
+func Open(addr string, opt graph.Options) (nosql.Database, error) {
+	return dialDB(addr, opt)
+}
+
+type collection struct {
+	table   string
+	primary nosql.Index
+	indexes map[string]string // names of global secondary indexes by their partition key
+}
+
+// toItem converts a document to a table item. Fields of the primary key are set from the key.
+func (c *collection) toItem(key nosql.Key, d nosql.Document) item {
+	m := toItem(d)
+	m[keyField] = &dynamodb.AttributeValue{S: aws.String(compKey(key))}
+	for i, f := range c.primary.Fields {
+		// empty strings cannot be stored in attributes used by indexes
+		if i < len(key) && key[i] != "" {
+			m[f] = &dynamodb.AttributeValue{S: aws.String(key[i])}
+		}
+	}
+	return m
+}
+
+func (c *collection) toDoc(m item) nosql.Document {
+	return fromItem(m)
+}
+
+func (c *collection) keyOf(m item) nosql.Key {
+	if len(c.primary.Fields) == 1 {
+		return nosql.Key{aws.StringValue(m[keyField].S)}
+	}
+	key := make(nosql.Key, 0, len(c.primary.Fields))
+	for _, f := range c.primary.Fields {
+		var s string
+		if v := m[f]; v != nil {
+			s = aws.StringValue(v.S)
+		}
+		key = append(key, s)
+	}
+	return key
+}
+
+type DB struct {
+	cli        *dynamodb.DynamoDB
+	prefix     string
+	consistent bool
+	colls      map[string]*collection
+}
+
+func (db *DB) Close() error {
+	return nil
+}
+
+func (db *DB) tableName(col string) string {
+	return db.prefix + "_" + col
+}
+
+func isErrCode(err error, code string) bool {
+	e, ok := err.(awserr.Error)
+	return ok && e.Code() == code
+}
+
+func (db *DB) collection(col string) (*collection, error) {
+	c, ok := db.colls[col]
+	if !ok {
+		return nil, fmt.Errorf("collection %q not found", col)
+	}
+	return c, nil
+}
+
+// EnsureIndex implements nosql.Database. It creates a table if it does not exist.
+//
+// Secondary indexes are only created with the table; existing tables use indexes that are already active.
+func (db *DB) EnsureIndex(ctx context.Context, col string, primary nosql.Index, secondary []nosql.Index) error {
+	if primary.Type != nosql.StringExact {
+		return fmt.Errorf("unsupported type of primary index: %v", primary.Type)
+	}
+	c := &collection{
+		table:   db.tableName(col),
+		primary: primary,
+		indexes: make(map[string]string),
+	}
+	desc := &dynamodb.DescribeTableInput{TableName: aws.String(c.table)}
+	out, err := db.cli.DescribeTableWithContext(ctx, desc)
+	if isErrCode(err, dynamodb.ErrCodeResourceNotFoundException) {
+		if err = db.createTable(ctx, c.table, secondary); err != nil {
+			return err
+		}
+		out, err = db.cli.DescribeTableWithContext(ctx, desc)
+	}
+	if err != nil {
+		return err
+	}
+	for _, ind := range out.Table.GlobalSecondaryIndexes {
+		if aws.StringValue(ind.IndexStatus) != dynamodb.IndexStatusActive || len(ind.KeySchema) != 1 {
+			continue
+		}
+		c.indexes[aws.StringValue(ind.KeySchema[0].AttributeName)] = aws.StringValue(ind.IndexName)
+	}
+	db.colls[col] = c
+	return nil
+}
+
+// createTable creates a table and waits for it to become active.
+// Secondary indexes are only created for single top-level string fields.
+func (db *DB) createTable(ctx context.Context, table string, secondary []nosql.Index) error {
+	in := &dynamodb.CreateTableInput{
+		TableName:   aws.String(table),
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{{
+			AttributeName: aws.String(keyField),
+			AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+		}},
+		KeySchema: []*dynamodb.KeySchemaElement{{
+			AttributeName: aws.String(keyField),
+			KeyType:       aws.String(dynamodb.KeyTypeHash),
+		}},
+	}
+	for _, ind := range secondary {
+		if ind.Type != nosql.StringExact || len(ind.Fields) != 1 || strings.Contains(ind.Fields[0], ".") {
+			continue
+		}
+		f := ind.Fields[0]
+		in.AttributeDefinitions = append(in.AttributeDefinitions, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(f),
+			AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+		})
+		in.GlobalSecondaryIndexes = append(in.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndex{
+			IndexName: aws.String("by_" + f),
+			KeySchema: []*dynamodb.KeySchemaElement{{
+				AttributeName: aws.String(f),
+				KeyType:       aws.String(dynamodb.KeyTypeHash),
+			}},
+			Projection: &dynamodb.Projection{
+				ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
+			},
+		})
+	}
+	if _, err := db.cli.CreateTableWithContext(ctx, in); err != nil {
+		return err
+	}
+	return db.cli.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+}
+
+func (db *DB) Insert(ctx context.Context, col string, key nosql.Key, d nosql.Document) (nosql.Key, error) {
+	c, err := db.collection(col)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		key = nosql.GenKey()
+	}
+	_, err = db.cli.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(c.table),
+		Item:                     c.toItem(key, d),
+		ConditionExpression:      aws.String("attribute_not_exists(#k)"),
+		ExpressionAttributeNames: map[string]*string{"#k": aws.String(keyField)},
+	})
+	if isErrCode(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return nil, fmt.Errorf("document %v already exists", key)
+	} else if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (db *DB) FindByKey(ctx context.Context, col string, key nosql.Key) (nosql.Document, error) {
+	c, err := db.collection(col)
+	if err != nil {
+		return nil, err
+	}
+	out, err := db.cli.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(c.table),
+		Key:            keyItem(key),
+		ConsistentRead: aws.Bool(db.consistent),
+	})
+	if err != nil {
+		return nil, err
+	} else if out.Item == nil {
+		return nil, nosql.ErrNotFound
+	}
+	return c.toDoc(out.Item), nil
+}
+
+// FindByKeys implements nosql.Database. Documents are fetched with BatchGetItem requests.
+func (db *DB) FindByKeys(ctx context.Context, col string, keys []nosql.Key) ([]nosql.Document, error) {
+	c, err := db.collection(col)
+	if err != nil {
+		return nil, err
+	}
+	// keys in a single request must be unique
+	ids := make([]string, 0, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		id := compKey(k)
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+	byID := make(map[string]item, len(ids))
+	for len(ids) > 0 {
+		n := batchGet
+		if n > len(ids) {
+			n = len(ids)
+		}
+		req := &dynamodb.KeysAndAttributes{ConsistentRead: aws.Bool(db.consistent)}
+		for _, id := range ids[:n] {
+			req.Keys = append(req.Keys, item{keyField: {S: aws.String(id)}})
+		}
+		ids = ids[n:]
+		reqs := map[string]*dynamodb.KeysAndAttributes{c.table: req}
+		for wait := time.Duration(0); len(reqs) != 0; wait = backoff(wait) {
+			if err = sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			out, err := db.cli.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{RequestItems: reqs})
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range out.Responses[c.table] {
+				byID[aws.StringValue(m[keyField].S)] = m
+			}
+			reqs = out.UnprocessedKeys
+		}
+	}
+	out := make([]nosql.Document, len(keys))
+	for i, k := range keys {
+		if m, ok := byID[compKey(k)]; ok {
+			out[i] = c.toDoc(m)
+		}
+	}
+	return out, nil
+}
+
+// backoff returns the next delay for retrying unprocessed items of batch requests.
+func backoff(d time.Duration) time.Duration {
+	const max = time.Second
+	if d == 0 {
+		return 50 * time.Millisecond
+	} else if d *= 2; d > max {
+		d = max
+	}
+	return d
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// writeBatch executes write requests in batches, retrying unprocessed items.
+func (db *DB) writeBatch(ctx context.Context, table string, reqs []*dynamodb.WriteRequest) error {
+	for len(reqs) > 0 {
+		n := batchWrite
+		if n > len(reqs) {
+			n = len(reqs)
+		}
+		items := map[string][]*dynamodb.WriteRequest{table: reqs[:n]}
+		reqs = reqs[n:]
+		for wait := time.Duration(0); len(items) != 0; wait = backoff(wait) {
+			if err := sleep(ctx, wait); err != nil {
+				return err
+			}
+			out, err := db.cli.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{RequestItems: items})
+			if err != nil {
+				return err
+			}
+			items = out.UnprocessedItems
+		}
+	}
+	return nil
+}
+
+func (db *DB) Query(col string) nosql.Query {
+	c, ok := db.colls[col]
+	if !ok {
+		c = &collection{table: db.tableName(col)}
+	}
+	return &Query{db: db, c: c}
+}
+
+// Count implements nosql.Database. Documents are counted by the database, unless some filters
+// can only be checked by the client.
+func (db *DB) Count(ctx context.Context, col string, filters ...nosql.FieldFilter) (int64, error) {
+	return db.Query(col).WithFields(filters...).Count(ctx)
+}
+
+func (db *DB) Update(col string, key nosql.Key) nosql.Update {
+	return &Update{db: db, col: col, key: key}
+}
+
+func (db *DB) Delete(col string) nosql.Delete {
+	return &Delete{db: db, col: col}
+}
+
+type Update struct {
+	db  *DB
+	col string
+	key nosql.Key
+
+	upsert nosql.Document
+	inc    map[string]int
+}
+
+func (u *Update) Inc(field string, dn int) nosql.Update {
+	if u.inc == nil {
+		u.inc = make(map[string]int)
+	}
+	u.inc[field] += dn
+	return u
+}
+func (u *Update) Upsert(d nosql.Document) nosql.Update {
+	u.upsert = d
+	if u.upsert == nil {
+		u.upsert = make(nosql.Document)
+	}
+	return u
+}
+
+// Do implements nosql.Update. Fields of the upserted document are only set if they are missing,
+// and counters are incremented with the ADD action, which creates them as needed.
+func (u *Update) Do(ctx context.Context) error {
+	c, err := u.db.collection(u.col)
+	if err != nil {
+		return err
+	}
+	var (
+		e        expr
+		set, add []string
+	)
+	if u.upsert != nil {
+		m := c.toItem(u.key, u.upsert)
+		delete(m, keyField)
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p := e.name(name)
+			set = append(set, p+" = if_not_exists("+p+", "+e.value(m[name])+")")
+		}
+	}
+	fields := make([]string, 0, len(u.inc))
+	for f := range u.inc {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		dn := strconv.Itoa(u.inc[f])
+		add = append(add, e.name(f)+" "+e.value(&dynamodb.AttributeValue{N: aws.String(dn)}))
+	}
+	var parts []string
+	if len(set) != 0 {
+		parts = append(parts, "SET "+strings.Join(set, ", "))
+	}
+	if len(add) != 0 {
+		parts = append(parts, "ADD "+strings.Join(add, ", "))
+	}
+	in := &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.table),
+		Key:       keyItem(u.key),
+	}
+	if len(parts) != 0 {
+		in.UpdateExpression = aws.String(strings.Join(parts, " "))
+	}
+	if u.upsert == nil {
+		in.ConditionExpression = aws.String("attribute_exists(" + e.name(keyField) + ")")
+	}
+	in.ExpressionAttributeNames, in.ExpressionAttributeValues = e.names, e.values
+	_, err = u.db.cli.UpdateItemWithContext(ctx, in)
+	if isErrCode(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return nosql.ErrNotFound
+	}
+	return err
+}
+
+type Delete struct {
+	db      *DB
+	col     string
+	filters []nosql.FieldFilter
+	keys    []nosql.Key
+}
+
+func (d *Delete) WithFields(filters ...nosql.FieldFilter) nosql.Delete {
+	d.filters = append(d.filters, filters...)
+	return d
+}
+func (d *Delete) Keys(keys ...nosql.Key) nosql.Delete {
+	d.keys = append(d.keys, keys...)
+	return d
+}
+
+// Do implements nosql.Delete. Matching documents are found first and then deleted in batches.
+func (d *Delete) Do(ctx context.Context) error {
+	c, err := d.db.collection(d.col)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	if len(d.keys) != 0 {
+		docs, err := d.db.FindByKeys(ctx, d.col, d.keys)
+		if err != nil {
+			return err
+		}
+		seen := make(map[string]struct{}, len(docs))
+		for i, doc := range docs {
+			id := compKey(d.keys[i])
+			if _, ok := seen[id]; ok || doc == nil || !matchesAll(d.filters, doc) {
+				continue
+			}
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	} else {
+		it := (&Query{db: d.db, c: c, filters: d.filters}).iterate()
+		for it.Next(ctx) {
+			ids = append(ids, compKey(it.Key()))
+		}
+		if err = it.Err(); err != nil {
+			return err
+		}
+		it.Close()
+	}
+	reqs := make([]*dynamodb.WriteRequest, 0, len(ids))
+	for _, id := range ids {
+		reqs = append(reqs, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{Key: item{keyField: {S: aws.String(id)}}},
+		})
+	}
+	return d.db.writeBatch(ctx, c.table, reqs)
+}
+
+func matchesAll(filters []nosql.FieldFilter, d nosql.Document) bool {
+	for _, f := range filters {
+		if !f.Matches(d) {
+			return false
+		}
+	}
+	return true
+}
+
+func (db *DB) BatchInsert(col string) nosql.DocWriter {
+	c, ok := db.colls[col]
+	if !ok {
+		c = &collection{table: db.tableName(col)}
+	}
+	return &inserter{db: db, c: c}
+}
+
+type inserter struct {
+	db    *DB
+	c     *collection
+	buf   []*dynamodb.WriteRequest
+	ikeys []nosql.Key
+	keys  []nosql.Key
+	err   error
+}
+
+func (w *inserter) WriteDoc(ctx context.Context, key nosql.Key, d nosql.Document) error {
+	if len(w.buf) >= batchWrite {
+		if err := w.Flush(ctx); err != nil {
+			return err
+		}
+	}
+	if key == nil {
+		key = nosql.GenKey()
+	}
+	w.buf = append(w.buf, &dynamodb.WriteRequest{
+		PutRequest: &dynamodb.PutRequest{Item: w.c.toItem(key, d)},
+	})
+	w.ikeys = append(w.ikeys, key)
+	return nil
+}
+
+func (w *inserter) Flush(ctx context.Context) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if err := w.db.writeBatch(ctx, w.c.table, w.buf); err != nil {
+		w.err = err
+		return err
+	}
+	w.keys = append(w.keys, w.ikeys...)
+	w.ikeys = w.ikeys[:0]
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *inserter) Keys() []nosql.Key {
+	return w.keys
+}
+
+func (w *inserter) Close() error {
+	w.buf = nil
+	w.ikeys = nil
+	return w.err
+}
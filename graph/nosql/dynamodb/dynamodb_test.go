@@ -0,0 +1,50 @@
+//go:build docker
+// +build docker
+
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/nosql"
+	"github.com/cayleygraph/cayley/graph/nosql/nosqltest"
+	"github.com/cayleygraph/cayley/internal/dock"
+)
+
+func makeDynamo(t testing.TB) (nosql.Database, *nosql.Options, graph.Options, func()) {
+	var conf dock.Config
+
+	conf.Image = "amazon/dynamodb-local"
+	conf.OpenStdin = true
+	conf.Tty = true
+
+	addr, closer := dock.RunAndWait(t, conf, "8000", nil)
+
+	// local instance accepts any credentials
+	opt := graph.Options{
+		"access_key_id":     "test",
+		"secret_access_key": "test",
+	}
+	qs, err := dialDB("http://"+addr, opt)
+	if err != nil {
+		closer()
+		t.Fatal(err)
+	}
+	return qs, nil, opt, func() {
+		qs.Close()
+		closer()
+	}
+}
+
+var conf = &nosqltest.Config{
+	FloatToInt: true,
+}
+
+func TestDynamoDB(t *testing.T) {
+	nosqltest.TestAll(t, makeDynamo, conf)
+}
+
+func BenchmarkDynamoDB(t *testing.B) {
+	nosqltest.BenchmarkAll(t, makeDynamo, conf)
+}
@@ -0,0 +1,454 @@
+package dynamodb
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/cayleygraph/cayley/graph/nosql"
+)
+
+// maxInValues is the maximal number of operands of the IN comparator.
+const maxInValues = 100
+
+// expr collects placeholders for attribute names and values used in expressions.
+type expr struct {
+	names  map[string]*string
+	byName map[string]string
+	values item
+}
+
+func (e *expr) name(name string) string {
+	if p, ok := e.byName[name]; ok {
+		return p
+	}
+	if e.names == nil {
+		e.names = make(map[string]*string)
+		e.byName = make(map[string]string)
+	}
+	p := "#n" + strconv.Itoa(len(e.names))
+	e.names[p] = aws.String(name)
+	e.byName[name] = p
+	return p
+}
+
+func (e *expr) path(path []string) string {
+	parts := make([]string, 0, len(path))
+	for _, name := range path {
+		parts = append(parts, e.name(name))
+	}
+	return strings.Join(parts, ".")
+}
+
+func (e *expr) value(v *dynamodb.AttributeValue) string {
+	if e.values == nil {
+		e.values = make(item)
+	}
+	p := ":v" + strconv.Itoa(len(e.values))
+	e.values[p] = v
+	return p
+}
+
+// cond converts a filter to a condition expression. It returns false if the filter
+// cannot be evaluated by the database.
+func (e *expr) cond(f nosql.FieldFilter) (string, bool) {
+	switch f.Filter {
+	case nosql.Exists:
+		if b, _ := f.Value.(nosql.Bool); b {
+			return "attribute_exists(" + e.path(f.Path) + ")", true
+		}
+		return "attribute_not_exists(" + e.path(f.Path) + ")", true
+	case nosql.In:
+		set, ok := f.Value.(nosql.Strings)
+		if !ok || len(set) == 0 || len(set) > maxInValues {
+			return "", false
+		}
+		vals := make([]string, 0, len(set))
+		for _, s := range set {
+			vals = append(vals, e.value(&dynamodb.AttributeValue{S: aws.String(s)}))
+		}
+		return e.path(f.Path) + " IN (" + strings.Join(vals, ", ") + ")", true
+	case nosql.Equal, nosql.NotEqual, nosql.GT, nosql.GTE, nosql.LT, nosql.LTE:
+	default:
+		// regexp, text and geo filters are checked by the client
+		return "", false
+	}
+	var (
+		path = f.Path
+		val  *dynamodb.AttributeValue
+	)
+	switch v := f.Value.(type) {
+	case nosql.String, nosql.Int, nosql.Float, nosql.Bool, nosql.Bytes:
+		val = toAttrValue(v)
+	case nosql.Time:
+		// compare a string representation of time stored in a map
+		path = append(path[:len(path):len(path)], timeField)
+		val = &dynamodb.AttributeValue{S: aws.String(formatTime(v))}
+	default:
+		return "", false
+	}
+	p, v := e.path(path), e.value(val)
+	switch f.Filter {
+	case nosql.Equal:
+		return p + " = " + v, true
+	case nosql.NotEqual:
+		// not equal allows the field to not exist
+		return "(attribute_not_exists(" + p + ") OR " + p + " <> " + v + ")", true
+	case nosql.GT:
+		return p + " > " + v, true
+	case nosql.GTE:
+		return p + " >= " + v, true
+	case nosql.LT:
+		return p + " < " + v, true
+	case nosql.LTE:
+		return p + " <= " + v, true
+	}
+	return "", false
+}
+
+// plan is a Query or Scan request for documents matching a set of filters.
+type plan struct {
+	c      *collection
+	index  string // name of a secondary index to query; empty for the table itself
+	key    string // condition on a partition key; the table is scanned if it is empty
+	filter string
+	e      expr
+	post   []nosql.FieldFilter // filters checked by the client
+}
+
+// newPlan selects an index for a set of filters. Equality filters on the primary key are preferred,
+// followed by ones on fields with a secondary index. Other filters are evaluated by the database,
+// if possible, or by the client.
+func (c *collection) newPlan(filters []nosql.FieldFilter) *plan {
+	p := &plan{c: c}
+	keyInd := -1
+	for i, f := range filters {
+		s, ok := f.Value.(nosql.String)
+		if f.Filter != nosql.Equal || !ok || s == "" || len(f.Path) != 1 {
+			continue
+		}
+		if len(c.primary.Fields) == 1 && f.Path[0] == c.primary.Fields[0] {
+			p.index, keyInd = "", i
+			p.key = p.e.name(keyField) + " = " + p.e.value(toAttrValue(s))
+			break
+		} else if ind, ok := c.indexes[f.Path[0]]; ok && keyInd < 0 {
+			p.index, keyInd = ind, i
+		}
+	}
+	if keyInd >= 0 && p.key == "" {
+		f := filters[keyInd]
+		p.key = p.e.name(f.Path[0]) + " = " + p.e.value(toAttrValue(f.Value))
+	}
+	var conds []string
+	for i, f := range filters {
+		if i == keyInd {
+			continue
+		}
+		if s, ok := p.e.cond(f); ok {
+			conds = append(conds, s)
+		} else {
+			p.post = append(p.post, f)
+		}
+	}
+	p.filter = strings.Join(conds, " AND ")
+	return p
+}
+
+func (p *plan) matches(d nosql.Document) bool {
+	for _, f := range p.post {
+		if !f.Matches(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// page is a single page of results of Query or Scan requests.
+type page struct {
+	items []item
+	count int64
+	last  item
+}
+
+// fetch requests a single page of results, starting after a given key. Only the number of
+// documents is returned if count is set.
+func (db *DB) fetch(ctx context.Context, p *plan, start item, count bool) (*page, error) {
+	var (
+		sel    *string
+		filter *string
+	)
+	if count {
+		sel = aws.String(dynamodb.SelectCount)
+	}
+	if p.filter != "" {
+		filter = aws.String(p.filter)
+	}
+	if p.key != "" {
+		in := &dynamodb.QueryInput{
+			TableName:                 aws.String(p.c.table),
+			KeyConditionExpression:    aws.String(p.key),
+			FilterExpression:          filter,
+			ExpressionAttributeNames:  p.e.names,
+			ExpressionAttributeValues: p.e.values,
+			ExclusiveStartKey:         start,
+			Select:                    sel,
+		}
+		if p.index != "" {
+			// secondary indexes only support eventually consistent reads
+			in.IndexName = aws.String(p.index)
+		} else {
+			in.ConsistentRead = aws.Bool(db.consistent)
+		}
+		out, err := db.cli.QueryWithContext(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		return &page{items: out.Items, count: aws.Int64Value(out.Count), last: out.LastEvaluatedKey}, nil
+	}
+	out, err := db.cli.ScanWithContext(ctx, &dynamodb.ScanInput{
+		TableName:                 aws.String(p.c.table),
+		FilterExpression:          filter,
+		ExpressionAttributeNames:  p.e.names,
+		ExpressionAttributeValues: p.e.values,
+		ExclusiveStartKey:         start,
+		Select:                    sel,
+		ConsistentRead:            aws.Bool(db.consistent),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page{items: out.Items, count: aws.Int64Value(out.Count), last: out.LastEvaluatedKey}, nil
+}
+
+// count returns a number of documents matching the plan.
+func (db *DB) count(ctx context.Context, p *plan) (int64, error) {
+	if len(p.post) != 0 {
+		it := &Iterator{db: db, p: p, left: -1}
+		defer it.Close()
+		var n int64
+		for it.Next(ctx) {
+			n++
+		}
+		return n, it.Err()
+	}
+	var (
+		n     int64
+		start item
+	)
+	for {
+		pg, err := db.fetch(ctx, p, start, true)
+		if err != nil {
+			return 0, err
+		}
+		n += pg.count
+		if len(pg.last) == 0 {
+			return n, nil
+		}
+		start = pg.last
+	}
+}
+
+type Query struct {
+	db      *DB
+	c       *collection
+	filters []nosql.FieldFilter
+	sort    []string
+	skip    int
+	limit   int
+}
+
+func (q *Query) WithFields(filters ...nosql.FieldFilter) nosql.Query {
+	q.filters = append(q.filters, filters...)
+	return q
+}
+func (q *Query) Limit(n int) nosql.Query {
+	q.limit = n
+	return q
+}
+func (q *Query) Skip(n int) nosql.Query {
+	q.skip = n
+	return q
+}
+func (q *Query) Sort(field []string) nosql.Query {
+	q.sort = field
+	return q
+}
+func (q *Query) Count(ctx context.Context) (int64, error) {
+	n, err := q.db.count(ctx, q.c.newPlan(q.filters))
+	if err != nil {
+		return 0, err
+	}
+	if n -= int64(q.skip); n < 0 {
+		n = 0
+	}
+	if q.limit > 0 && n > int64(q.limit) {
+		n = int64(q.limit)
+	}
+	return n, nil
+}
+func (q *Query) One(ctx context.Context) (nosql.Document, error) {
+	it := q.iterate()
+	it.left = 1
+	defer it.Close()
+	if !it.Next(ctx) {
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+		return nil, nosql.ErrNotFound
+	}
+	return it.Doc(), nil
+}
+func (q *Query) Iterate() nosql.DocIterator {
+	return q.iterate()
+}
+func (q *Query) iterate() *Iterator {
+	it := &Iterator{
+		db: q.db, p: q.c.newPlan(q.filters),
+		sort: q.sort, skip: q.skip, left: -1,
+	}
+	if q.limit > 0 {
+		it.left = q.limit
+	}
+	return it
+}
+
+type result struct {
+	key nosql.Key
+	doc nosql.Document
+}
+
+// Iterator iterates over pages of Query or Scan results. Limits and offsets are applied by the client.
+// If sorting is requested, all documents are loaded and sorted before returning the first one.
+type Iterator struct {
+	db   *DB
+	p    *plan
+	sort []string
+	skip int // documents to skip
+	left int // documents left to return; -1 if unlimited
+
+	buf     []result
+	i       int
+	started bool
+	last    item
+	done    bool
+	cur     *result
+	err     error
+}
+
+func (it *Iterator) Next(ctx context.Context) bool {
+	if it.sort != nil && !it.started {
+		if !it.loadSorted(ctx) {
+			return false
+		}
+	}
+	for it.left != 0 && it.next(ctx) {
+		if it.skip > 0 {
+			it.skip--
+			continue
+		}
+		if it.left > 0 {
+			it.left--
+		}
+		return true
+	}
+	it.cur = nil
+	return false
+}
+
+// next advances to the next document matching the plan.
+func (it *Iterator) next(ctx context.Context) bool {
+	for it.i >= len(it.buf) {
+		if it.err != nil || it.done {
+			return false
+		}
+		it.fetch(ctx)
+	}
+	it.cur = &it.buf[it.i]
+	it.i++
+	return true
+}
+
+// fetch loads the next page of results.
+func (it *Iterator) fetch(ctx context.Context) {
+	it.started = true
+	pg, err := it.db.fetch(ctx, it.p, it.last, false)
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.buf, it.i = it.buf[:0], 0
+	for _, m := range pg.items {
+		d := it.p.c.toDoc(m)
+		if it.p.matches(d) {
+			it.buf = append(it.buf, result{key: it.p.c.keyOf(m), doc: d})
+		}
+	}
+	it.last = pg.last
+	it.done = len(pg.last) == 0
+}
+
+// loadSorted loads all documents and sorts them.
+func (it *Iterator) loadSorted(ctx context.Context) bool {
+	var all []result
+	for !it.done {
+		it.fetch(ctx)
+		if it.err != nil {
+			return false
+		}
+		all = append(all, it.buf...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return compareValues(valueAt(all[i].doc, it.sort), valueAt(all[j].doc, it.sort)) < 0
+	})
+	it.buf, it.i = all, 0
+	return true
+}
+
+// compareValues is similar to nosql.CompareValues, but it compares integers and floats as numbers,
+// since the database does not distinguish them.
+func compareValues(v1, v2 nosql.Value) int {
+	f1, ok1 := asFloat(v1)
+	f2, ok2 := asFloat(v2)
+	if !ok1 || !ok2 {
+		return nosql.CompareValues(v1, v2)
+	} else if f1 < f2 {
+		return -1
+	} else if f1 > f2 {
+		return +1
+	}
+	return 0
+}
+
+func asFloat(v nosql.Value) (float64, bool) {
+	switch v := v.(type) {
+	case nosql.Int:
+		return float64(v), true
+	case nosql.Float:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func (it *Iterator) Err() error {
+	return it.err
+}
+func (it *Iterator) Close() error {
+	it.buf = nil
+	return nil
+}
+func (it *Iterator) Key() nosql.Key {
+	if it.cur == nil {
+		return nil
+	}
+	return it.cur.key
+}
+func (it *Iterator) Doc() nosql.Document {
+	if it.cur == nil {
+		return nil
+	}
+	return it.cur.doc
+}
@@ -0,0 +1,152 @@
+package dynamodb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/cayleygraph/cayley/graph/nosql"
+)
+
+const (
+	// keyField is a partition key of all tables. It stores a composite key of the document.
+	keyField = "_id"
+	// timeField is the only field of a map that stores a time value.
+	// Times are stored as strings of a fixed width, thus they can be ordered by the database.
+	timeField = "$t"
+	// timeFormat is similar to time.RFC3339Nano, but it does not trim trailing zeros.
+	timeFormat   = "2006-01-02T15:04:05.000000000Z"
+	keySeparator = "|"
+)
+
+type item = map[string]*dynamodb.AttributeValue
+
+func compKey(key nosql.Key) string {
+	if len(key) == 1 {
+		return key[0]
+	}
+	return strings.Join(key, keySeparator)
+}
+
+func keyItem(key nosql.Key) item {
+	return item{keyField: {S: aws.String(compKey(key))}}
+}
+
+func formatTime(t nosql.Time) string {
+	return time.Time(t).UTC().Format(timeFormat)
+}
+
+// toAttrValue converts nosql.Value to a DynamoDB attribute value.
+func toAttrValue(v nosql.Value) *dynamodb.AttributeValue {
+	switch v := v.(type) {
+	case nil:
+		return &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+	case nosql.Document:
+		return &dynamodb.AttributeValue{M: toItem(v)}
+	case nosql.Strings:
+		// string sets are not ordered and cannot be empty, thus a list is used
+		arr := make([]*dynamodb.AttributeValue, 0, len(v))
+		for _, s := range v {
+			arr = append(arr, &dynamodb.AttributeValue{S: aws.String(s)})
+		}
+		return &dynamodb.AttributeValue{L: arr}
+	case nosql.String:
+		return &dynamodb.AttributeValue{S: aws.String(string(v))}
+	case nosql.Int:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(int64(v), 10))}
+	case nosql.Float:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(float64(v), 'g', -1, 64))}
+	case nosql.Bool:
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(bool(v))}
+	case nosql.Time:
+		return &dynamodb.AttributeValue{M: item{
+			timeField: {S: aws.String(formatTime(v))},
+		}}
+	case nosql.Bytes:
+		return &dynamodb.AttributeValue{B: []byte(v)}
+	default:
+		panic(fmt.Errorf("unsupported type: %T", v))
+	}
+}
+
+// fromAttrValue converts a DynamoDB attribute value to nosql.Value.
+func fromAttrValue(v *dynamodb.AttributeValue) nosql.Value {
+	switch {
+	case v == nil || aws.BoolValue(v.NULL):
+		return nil
+	case v.S != nil:
+		return nosql.String(*v.S)
+	case v.N != nil:
+		return parseNumber(*v.N)
+	case v.BOOL != nil:
+		return nosql.Bool(*v.BOOL)
+	case v.B != nil:
+		return nosql.Bytes(v.B)
+	case v.L != nil:
+		arr := make(nosql.Strings, 0, len(v.L))
+		for _, s := range v.L {
+			arr = append(arr, aws.StringValue(s.S))
+		}
+		return arr
+	case v.M != nil:
+		if t, ok := v.M[timeField]; ok && len(v.M) == 1 && t.S != nil {
+			tv, err := time.Parse(time.RFC3339Nano, *t.S)
+			if err != nil {
+				panic(fmt.Errorf("cannot parse time value: %v", err))
+			}
+			return nosql.Time(tv)
+		}
+		return fromItem(v.M)
+	default:
+		panic(fmt.Errorf("unsupported attribute value: %v", v))
+	}
+}
+
+// parseNumber parses a number attribute. Integers are returned as nosql.Int, since the database
+// does not distinguish them from floats.
+func parseNumber(s string) nosql.Value {
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return nosql.Int(v)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		panic(fmt.Errorf("cannot parse number: %v", err))
+	}
+	return nosql.Float(v)
+}
+
+func toItem(d nosql.Document) item {
+	m := make(item, len(d))
+	for k, v := range d {
+		m[k] = toAttrValue(v)
+	}
+	return m
+}
+
+func fromItem(m item) nosql.Document {
+	d := make(nosql.Document, len(m))
+	for k, v := range m {
+		if k == keyField {
+			continue
+		}
+		d[k] = fromAttrValue(v)
+	}
+	return d
+}
+
+// valueAt returns a value of a field with a given path, or nil if it does not exist.
+func valueAt(d nosql.Document, path []string) nosql.Value {
+	var v nosql.Value = d
+	for _, name := range path {
+		d, ok := v.(nosql.Document)
+		if !ok {
+			return nil
+		}
+		v = d[name]
+	}
+	return v
+}
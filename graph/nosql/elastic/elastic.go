@@ -27,6 +27,10 @@ func init() {
 		NewFunc:      Open,
 		InitFunc:     Create,
 		IsPersistent: true,
+		Options: nosql.Options{
+			TextMatch: true,
+			Geo:       true,
+		},
 	})
 }
 
@@ -113,7 +117,8 @@ func (db *DB) Close() error {
 type indType string
 
 const (
-	indKeyword = indType("keyword")
+	indKeyword  = indType("keyword")
+	indGeoPoint = indType("geo_point")
 )
 
 type property struct {
@@ -163,6 +168,8 @@ func (db *DB) EnsureIndex(ctx context.Context, typ string, primary nosql.Index,
 			switch ind.Type {
 			case nosql.StringExact:
 				typ = indKeyword
+			case nosql.IndexGeo:
+				typ = indGeoPoint
 			}
 			if typ != "" {
 				props[f] = property{Type: typ}
@@ -216,10 +223,15 @@ func toElasticValue(v nosql.Value) interface{} {
 		return time.Time(v)
 	case nosql.Bytes:
 		return []byte(v)
+	case nosql.GeoPoint:
+		return geoPoint(v)
 	default:
 		panic(fmt.Errorf("unsupported type: %T", v))
 	}
 }
+func geoPoint(p nosql.GeoPoint) map[string]interface{} {
+	return map[string]interface{}{"lat": p.Lat, "lon": p.Lng}
+}
 func fromElasticValue(v interface{}) nosql.Value {
 	switch v := v.(type) {
 	case nil:
@@ -367,6 +379,32 @@ func (db *DB) FindByKey(ctx context.Context, col string, key nosql.Key) (nosql.D
 	h := resp.Hits.Hits[0]
 	return c.convDoc(h), nil
 }
+
+// FindByKeys implements nosql.Database. Documents are fetched with a single ids query.
+func (db *DB) FindByKeys(ctx context.Context, col string, keys []nosql.Key) ([]nosql.Document, error) {
+	c := db.colls[col]
+	ids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ids = append(ids, compKey(k))
+	}
+	resp, err := db.cli.Search(db.indexName(col)).Type(col).Query(
+		elastic.NewIdsQuery(col).Ids(ids...),
+	).Size(len(ids)).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*elastic.SearchHit, len(resp.Hits.Hits))
+	for _, h := range resp.Hits.Hits {
+		byID[h.Id] = h
+	}
+	out := make([]nosql.Document, len(keys))
+	for i, id := range ids {
+		if h, ok := byID[id]; ok {
+			out[i] = c.convDoc(h)
+		}
+	}
+	return out, nil
+}
 func (db *DB) indexRef(col string) indexRef {
 	c := db.colls[col]
 	return indexRef{cli: db.cli, ind: db.indexName(col), c: &c}
@@ -374,6 +412,62 @@ func (db *DB) indexRef(col string) indexRef {
 func (db *DB) Query(col string) nosql.Query {
 	return &Query{indexRef: db.indexRef(col)}
 }
+
+// Count implements nosql.Database. Documents are counted with the count API.
+func (db *DB) Count(ctx context.Context, col string, filters ...nosql.FieldFilter) (int64, error) {
+	q := db.Query(col)
+	if len(filters) != 0 {
+		q = q.WithFields(filters...)
+	}
+	return q.Count(ctx)
+}
+
+// Aggregate implements nosql.Aggregator. Aggregates are computed with metric aggregations.
+func (db *DB) Aggregate(ctx context.Context, col string, fnc nosql.AggregateFunc, field []string, filters ...nosql.FieldFilter) (nosql.Value, error) {
+	name := strings.Join(field, ".")
+	var agg elastic.Aggregation
+	switch fnc {
+	case nosql.AggregateSum:
+		agg = elastic.NewSumAggregation().Field(name)
+	case nosql.AggregateMin:
+		agg = elastic.NewMinAggregation().Field(name)
+	case nosql.AggregateMax:
+		agg = elastic.NewMaxAggregation().Field(name)
+	case nosql.AggregateAvg:
+		agg = elastic.NewAvgAggregation().Field(name)
+	default:
+		return nil, fmt.Errorf("unsupported aggregation: %v", fnc)
+	}
+	ref := db.indexRef(col)
+	qu := db.cli.Search(ref.ind).Type(ref.c.typ).Size(0).Aggregation("v", agg)
+	if eq := (elasticQuery{Filters: filters}); !eq.IsAll() {
+		qu = qu.Query(eq)
+	}
+	resp, err := qu.Do(ctx)
+	if err != nil {
+		return nil, err
+	} else if resp.Hits == nil || resp.Hits.TotalHits == 0 {
+		return nil, nil
+	}
+	var (
+		m  *elastic.AggregationValueMetric
+		ok bool
+	)
+	switch fnc {
+	case nosql.AggregateSum:
+		m, ok = resp.Aggregations.Sum("v")
+	case nosql.AggregateMin:
+		m, ok = resp.Aggregations.Min("v")
+	case nosql.AggregateMax:
+		m, ok = resp.Aggregations.Max("v")
+	case nosql.AggregateAvg:
+		m, ok = resp.Aggregations.Avg("v")
+	}
+	if !ok || m.Value == nil {
+		return nil, nil
+	}
+	return nosql.Float(*m.Value), nil
+}
 func (db *DB) Update(col string, key nosql.Key) nosql.Update {
 	return &Update{indexRef: db.indexRef(col), key: key}
 }
@@ -437,6 +531,51 @@ func (q elasticQuery) Source() (interface{}, error) {
 	ranges := make(map[string]rng)
 	for _, f := range q.Filters {
 		name := strings.Join(f.Path, ".")
+		if f.Filter == nosql.TextMatch {
+			tq, ok := f.Value.(nosql.TextQuery)
+			if !ok {
+				return nil, fmt.Errorf("unexpected value for text match: %T", f.Value)
+			}
+			match := map[string]interface{}{
+				"query":    tq.Query,
+				"operator": "and",
+			}
+			if tq.Fuzziness > 0 {
+				match["fuzziness"] = tq.Fuzziness
+			}
+			if tq.Boost > 0 {
+				match["boost"] = tq.Boost
+			}
+			must = append(must, map[string]interface{}{
+				"match": map[string]interface{}{
+					name: match,
+				},
+			})
+			continue
+		} else if f.Filter == nosql.GeoWithin {
+			switch r := f.Value.(type) {
+			case nosql.GeoCircle:
+				filters = append(filters, map[string]interface{}{
+					"geo_distance": map[string]interface{}{
+						"distance": strconv.FormatFloat(r.Radius, 'f', -1, 64) + "km",
+						name:       geoPoint(r.Center),
+					},
+				})
+			case nosql.GeoPolygon:
+				points := make([]map[string]interface{}, 0, len(r))
+				for _, p := range r {
+					points = append(points, geoPoint(p))
+				}
+				filters = append(filters, map[string]interface{}{
+					"geo_polygon": map[string]interface{}{
+						name: map[string]interface{}{"points": points},
+					},
+				})
+			default:
+				return nil, fmt.Errorf("unexpected value for geo filter: %T", f.Value)
+			}
+			continue
+		}
 		val := toElasticValue(f.Value)
 		switch f.Filter {
 		case nosql.Equal:
@@ -449,6 +588,21 @@ func (q elasticQuery) Source() (interface{}, error) {
 			})
 		case nosql.NotEqual:
 			not = append(not, term(name, val))
+		case nosql.In:
+			filters = append(filters, map[string]interface{}{
+				"terms": map[string]interface{}{
+					name: val,
+				},
+			})
+		case nosql.Exists:
+			exists := map[string]interface{}{
+				"exists": map[string]interface{}{"field": name},
+			}
+			if b, _ := f.Value.(nosql.Bool); b {
+				filters = append(filters, exists)
+			} else {
+				not = append(not, exists)
+			}
 		case nosql.GT, nosql.GTE, nosql.LT, nosql.LTE:
 			r := ranges[name]
 			switch f.Filter {
@@ -498,8 +652,13 @@ type indexRef struct {
 	c   *collection
 }
 
+// maxResultWindow is the default limit of from+size for search requests in Elasticsearch.
+const maxResultWindow = 10000
+
 type Query struct {
 	indexRef
+	sort  string
+	skip  int64
 	limit int64
 	qu    elasticQuery
 }
@@ -512,6 +671,14 @@ func (q *Query) Limit(n int) nosql.Query {
 	q.limit = int64(n)
 	return q
 }
+func (q *Query) Skip(n int) nosql.Query {
+	q.skip = int64(n)
+	return q
+}
+func (q *Query) Sort(field []string) nosql.Query {
+	q.sort = strings.Join(field, ".")
+	return q
+}
 func (q *Query) Count(ctx context.Context) (int64, error) {
 	cnt := q.cli.Count(q.ind).Type(q.c.typ)
 	if !q.qu.IsAll() {
@@ -521,6 +688,9 @@ func (q *Query) Count(ctx context.Context) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if n -= q.skip; n < 0 {
+		n = 0
+	}
 	if q.limit > 0 && n > q.limit {
 		n = q.limit
 	}
@@ -540,19 +710,44 @@ func (q *Query) One(ctx context.Context) (nosql.Document, error) {
 	return q.c.convDoc(resp.Hits.Hits[0]), nil
 }
 func (q *Query) Iterate() nosql.DocIterator {
+	it := &Iterator{indexRef: q.indexRef, left: -1}
+	if q.limit > 0 {
+		it.left = q.limit
+	}
+	if q.skip > 0 && q.limit > 0 && q.skip+q.limit <= maxResultWindow {
+		// the whole page fits into a single search request
+		qu := q.cli.Search(q.ind).Type(q.c.typ).From(int(q.skip)).Size(int(q.limit))
+		if q.sort != "" {
+			qu = qu.Sort(q.sort, true)
+		}
+		if !q.qu.IsAll() {
+			qu = qu.Query(q.qu)
+		}
+		it.search = qu
+		return it
+	}
+	// scroll API does not support offsets, thus documents are skipped by the iterator
+	it.skip = q.skip
 	qu := q.cli.Scroll(q.ind).Type(q.c.typ)
 	if q.limit > 0 {
 		qu = qu.Size(int(q.limit))
 	}
+	if q.sort != "" {
+		qu = qu.Sort(q.sort, true)
+	}
 	if !q.qu.IsAll() {
 		qu = qu.Query(q.qu)
 	}
-	return &Iterator{indexRef: q.indexRef, qu: qu}
+	it.qu = qu
+	return it
 }
 
 type Iterator struct {
 	indexRef
-	qu *elastic.ScrollService
+	qu     *elastic.ScrollService
+	search *elastic.SearchService
+	skip   int64 // documents to skip
+	left   int64 // documents left to return; -1 if unlimited
 
 	buf  *elastic.SearchResult
 	done bool
@@ -561,12 +756,34 @@ type Iterator struct {
 }
 
 func (it *Iterator) Next(ctx context.Context) bool {
+	for it.left != 0 && it.next(ctx) {
+		if it.skip > 0 {
+			it.skip--
+			continue
+		}
+		if it.left > 0 {
+			it.left--
+		}
+		return true
+	}
+	it.done = true
+	return false
+}
+func (it *Iterator) next(ctx context.Context) bool {
 	if it.done {
 		return false
 	}
 	if it.buf == nil {
-		it.buf, it.err = it.qu.Do(ctx)
+		if it.search != nil {
+			it.buf, it.err = it.search.Do(ctx)
+		} else {
+			it.buf, it.err = it.qu.Do(ctx)
+		}
 	} else if it.i+1 >= len(it.buf.Hits.Hits) {
+		if it.search != nil {
+			it.done = true
+			return false
+		}
 		it.i = 0
 		it.buf, it.err = it.cli.Scroll(it.ind).ScrollId(it.buf.ScrollId).Do(ctx)
 	} else {
@@ -51,6 +51,7 @@ func makeElasticVersion(vers string, legacy bool) nosqltest.DatabaseFunc {
 
 var conf = &nosqltest.Config{
 	FloatToInt: true,
+	Geo:        true,
 }
 
 func TestElastic(t *testing.T) {
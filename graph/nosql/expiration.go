@@ -0,0 +1,97 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nosql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var _ graph.ExpiringStore = (*QuadStore)(nil)
+
+// ApplyDeltasWithExpiration implements graph.ExpiringStore.
+// The expiration time is stored in the quad document.
+func (qs *QuadStore) ApplyDeltasWithExpiration(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts, expires time.Time) error {
+	return qs.applyDeltas(deltas, ignoreOpts, expires)
+}
+
+// ExpireQuads implements graph.ExpiringStore.
+//
+// Documents of expired quads are removed with a filtered delete and removals are written to the log.
+func (qs *QuadStore) ExpireQuads(ctx context.Context, now time.Time) (int, error) {
+	filter := FieldFilter{
+		Path:   []string{fldQuadExpires},
+		Filter: LTE,
+		Value:  Time(now.UTC()),
+	}
+	var (
+		keys   []Key
+		hashes []QuadHash
+	)
+	it := qs.db.Query(colQuads).WithFields(filter).Iterate()
+	for it.Next(ctx) {
+		doc := it.Doc()
+		keys = append(keys, it.Key())
+		if !checkQuadAdded(doc) {
+			continue
+		}
+		var h QuadHash
+		for i, f := range quadFields {
+			if s, ok := doc[f].(String); ok {
+				h[i] = string(s)
+			}
+		}
+		hashes = append(hashes, h)
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		return 0, fmt.Errorf("error reading quads: %v", err)
+	} else if len(keys) == 0 {
+		return 0, nil
+	}
+	refs := make(map[string]int)
+	deltas := make([]graph.Delta, 0, len(hashes))
+	for _, h := range hashes {
+		for _, s := range h {
+			if s != "" {
+				refs[s]++
+			}
+		}
+		deltas = append(deltas, graph.Delta{Quad: qs.Quad(h), Action: graph.Delete})
+	}
+	if _, err = qs.appendLog(ctx, deltas); err != nil {
+		return 0, err
+	}
+	if err = qs.db.Delete(colQuads).Keys(keys...).WithFields(filter).Do(ctx); err != nil {
+		return 0, fmt.Errorf("error removing quads: %v", err)
+	}
+	gc := make([]Key, 0, len(refs))
+	for h, dn := range refs {
+		key := NodeHash(h).key()
+		if err = qs.db.Update(colNodes, key).Inc(fldSize, -dn).Do(ctx); err != nil {
+			return 0, fmt.Errorf("error updating node: %v", err)
+		}
+		gc = append(gc, key)
+	}
+	if err = qs.cleanupNodes(ctx, gc); err != nil {
+		return 0, err
+	}
+	qs.sizes.Purge()
+	return len(deltas), nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nosql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var _ graph.GarbageCollector = (*QuadStore)(nil)
+
+// GC implements graph.GarbageCollector. Node documents are removed if their reference counter is not positive,
+// which happens if removal of quads was interrupted before unused nodes were cleaned up.
+//
+// Each batch is removed with a filtered delete, thus nodes referenced by quads written concurrently are kept.
+// Such nodes are still counted as removed. Reclaimed space is not reported.
+func (qs *QuadStore) GC(ctx context.Context, batch int, dryRun bool) (graph.GCStats, error) {
+	if batch <= 0 {
+		batch = graph.DefaultGCBatch
+	}
+	orphan := FieldFilter{Path: []string{fldSize}, Filter: LTE, Value: Int(0)}
+	var st graph.GCStats
+	if dryRun {
+		n, err := qs.db.Count(ctx, colNodes, orphan)
+		st.Nodes = n
+		return st, err
+	}
+	// some databases may return removed documents for a short time, thus keys are deduplicated
+	seen := make(map[string]struct{})
+	for {
+		var keys []Key
+		it := qs.db.Query(colNodes).WithFields(orphan).Limit(batch).Iterate()
+		for it.Next(ctx) {
+			k := it.Key()
+			sk := strings.Join(k, "\x00")
+			if _, ok := seen[sk]; ok {
+				continue
+			}
+			seen[sk] = struct{}{}
+			keys = append(keys, k)
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return st, fmt.Errorf("error reading nodes: %v", err)
+		} else if len(keys) == 0 {
+			break
+		}
+		if err = qs.db.Delete(colNodes).Keys(keys...).WithFields(orphan).Do(ctx); err != nil {
+			return st, fmt.Errorf("error removing nodes: %v", err)
+		}
+		st.Nodes += int64(len(keys))
+		st.Batches++
+	}
+	qs.sizes.Purge()
+	return st, nil
+}
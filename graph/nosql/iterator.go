@@ -26,19 +26,38 @@ import (
 
 var _ graph.Iterator = (*Iterator)(nil)
 
+// Linkage is a constraint on a node of quads in a given direction.
+//
+// Empty Val can only be used with quad.Label direction and selects quads without a label (the default graph).
 type Linkage struct {
 	Dir quad.Direction
 	Val NodeHash
 }
 
+// NodeLink is a constraint on quads that selects ones with a node matching filters in a given direction.
+//
+// Nodes are found by a separate query before querying quads.
+type NodeLink struct {
+	Dir     quad.Direction
+	Filters []FieldFilter // filters on documents of nodes collection
+}
+
+// maxInValues is a maximal number of nodes passed to a single In filter.
+const maxInValues = 1000
+
 type Iterator struct {
 	uid        uint64
 	tags       graph.Tagger
 	qs         *QuadStore
 	collection string
+	sort       []string
+	hint       []string // fields of a secondary index to use for the query
+	offset     int64
 	limit      int64
 	constraint []FieldFilter
 	links      []Linkage // used in Contains
+	nodes      []NodeLink
+	chunks     [][]FieldFilter // In filters for node links, one set per query; nil if not resolved yet
 
 	iter   DocIterator
 	result graph.Value
@@ -46,24 +65,89 @@ type Iterator struct {
 	err    error
 }
 
-func NewLinksToIterator(qs *QuadStore, collection string, links []Linkage) *Iterator {
+// linksFilters converts links to filters on quad documents.
+func linksFilters(links []Linkage) []FieldFilter {
 	filters := make([]FieldFilter, 0, len(links))
 	for _, l := range links {
+		if l.Val == "" {
+			filters = append(filters, FieldFilter{
+				Path:   []string{l.Dir.String()},
+				Filter: Exists,
+				Value:  Bool(false),
+			})
+			continue
+		}
 		filters = append(filters, FieldFilter{
 			Path:   []string{l.Dir.String()},
 			Filter: Equal,
 			Value:  String(l.Val),
 		})
 	}
-	it := NewIterator(qs, collection, filters...)
+	return filters
+}
+
+func NewLinksToIterator(qs *QuadStore, collection string, links []Linkage) *Iterator {
+	it := NewIterator(qs, collection, linksFilters(links)...)
 	it.links = links
 	return it
 }
 
+// resolveNodes finds nodes for node links and converts them to In filters.
+// Nodes of the first link with more than maxInValues nodes are split between multiple queries.
+func (it *Iterator) resolveNodes(ctx context.Context) error {
+	var filters, split []FieldFilter
+	for _, l := range it.nodes {
+		hashes, err := it.qs.nodeHashes(ctx, l.Filters)
+		if err != nil {
+			return err
+		} else if len(hashes) == 0 {
+			it.chunks = [][]FieldFilter{}
+			return nil
+		}
+		path := []string{l.Dir.String()}
+		if split != nil || len(hashes) <= maxInValues {
+			filters = append(filters, FieldFilter{Path: path, Filter: In, Value: Strings(hashes)})
+			continue
+		}
+		for len(hashes) > 0 {
+			n := maxInValues
+			if n > len(hashes) {
+				n = len(hashes)
+			}
+			split = append(split, FieldFilter{Path: path, Filter: In, Value: Strings(hashes[:n])})
+			hashes = hashes[n:]
+		}
+	}
+	if split == nil {
+		it.chunks = [][]FieldFilter{filters}
+		return nil
+	}
+	it.chunks = make([][]FieldFilter, 0, len(split))
+	for _, f := range split {
+		chunk := make([]FieldFilter, 0, len(filters)+1)
+		chunk = append(chunk, filters...)
+		it.chunks = append(it.chunks, append(chunk, f))
+	}
+	return nil
+}
+
 func (it *Iterator) makeIterator() DocIterator {
 	q := it.qs.db.Query(it.collection)
-	if len(it.constraint) != 0 {
-		q = q.WithFields(it.constraint...)
+	constraint := it.constraint
+	if len(it.chunks) != 0 {
+		constraint = append(append([]FieldFilter{}, constraint...), it.chunks[0]...)
+	}
+	if len(constraint) != 0 {
+		q = q.WithFields(constraint...)
+	}
+	if h, ok := q.(QueryHinter); ok && len(it.hint) != 0 {
+		q = h.Hint(it.hint)
+	}
+	if len(it.sort) != 0 {
+		q = q.Sort(it.sort)
+	}
+	if it.offset > 0 {
+		q = q.Skip(int(it.offset))
 	}
 	if it.limit > 0 {
 		q = q.Limit(int(it.limit))
@@ -91,7 +175,7 @@ func (it *Iterator) UID() uint64 {
 
 func (it *Iterator) Reset() {
 	it.Close()
-	it.iter = it.makeIterator()
+	it.iter, it.chunks = nil, nil
 }
 
 func (it *Iterator) Close() error {
@@ -116,20 +200,42 @@ func (it *Iterator) Clone() graph.Iterator {
 	} else {
 		m = NewLinksToIterator(it.qs, it.collection, it.links)
 	}
+	m.nodes = it.nodes
+	m.sort, m.hint, m.offset, m.limit = it.sort, it.hint, it.offset, it.limit
 	m.tags.CopyFrom(it)
 	return m
 }
 
 func (it *Iterator) Next(ctx context.Context) bool {
 	if it.iter == nil {
+		if len(it.nodes) != 0 && it.chunks == nil {
+			if err := it.resolveNodes(ctx); err != nil {
+				it.err = err
+				return false
+			}
+		}
+		if it.chunks != nil && len(it.chunks) == 0 {
+			return false
+		}
 		it.iter = it.makeIterator()
 	}
 	var doc Document
 	for {
+		// not all backends can cancel an open cursor, thus the context is checked between documents
+		if err := ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
 		if !it.iter.Next(ctx) {
 			if err := it.iter.Err(); err != nil {
 				it.err = err
 				clog.Errorf("error nexting iterator: %v", err)
+			} else if len(it.chunks) > 1 {
+				// query quads for the next chunk of nodes
+				it.iter.Close()
+				it.chunks = it.chunks[1:]
+				it.iter = it.makeIterator()
+				continue
 			}
 			return false
 		}
@@ -140,20 +246,29 @@ func (it *Iterator) Next(ctx context.Context) bool {
 		break
 	}
 	if it.collection == colQuads {
-		sh, _ := doc[fldSubject].(String)
-		ph, _ := doc[fldPredicate].(String)
-		oh, _ := doc[fldObject].(String)
-		lh, _ := doc[fldLabel].(String)
-		it.result = QuadHash{
-			string(sh), string(ph), string(oh), string(lh),
-		}
+		it.result = quadHashOf(doc)
 	} else {
 		id, _ := doc[fldHash].(String)
 		it.result = NodeHash(id)
+		// node documents already contain values, so cache them to avoid fetching nodes again in NameOf
+		if _, ok := it.qs.ids.Get(string(id)); !ok && id != "" {
+			it.qs.nodeValue(NodeHash(id), doc)
+		}
 	}
 	return true
 }
 
+// quadHashOf returns hashes of nodes of a quad document.
+func quadHashOf(doc Document) QuadHash {
+	sh, _ := doc[fldSubject].(String)
+	ph, _ := doc[fldPredicate].(String)
+	oh, _ := doc[fldObject].(String)
+	lh, _ := doc[fldLabel].(String)
+	return QuadHash{
+		string(sh), string(ph), string(oh), string(lh),
+	}
+}
+
 func (it *Iterator) Err() error {
 	return it.err
 }
@@ -171,13 +286,18 @@ func (it *Iterator) SubIterators() []graph.Iterator {
 }
 
 func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
-	if len(it.links) != 0 {
+	if len(it.links) != 0 || len(it.nodes) != 0 {
 		qh := v.(QuadHash)
 		for _, l := range it.links {
 			if l.Val != NodeHash(qh.Get(l.Dir)) {
 				return false
 			}
 		}
+		for _, l := range it.nodes {
+			if !it.qs.nodeMatches(NodeHash(qh.Get(l.Dir)), l.Filters) {
+				return false
+			}
+		}
 		it.result = v
 		return true
 	}
@@ -185,20 +305,41 @@ func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
 		it.result = v
 		return true
 	}
-	qv := it.qs.NameOf(v)
+	if !it.qs.nodeMatches(v, it.constraint) {
+		return false
+	}
+	it.result = v
+	return true
+}
+
+// nodeMatches checks if a document of the node matches all filters.
+func (qs *QuadStore) nodeMatches(v graph.Value, filters []FieldFilter) bool {
+	qv := qs.NameOf(v)
 	if qv == nil {
 		return false
 	}
-	d := it.qs.opt.toDocumentValue(qv)
-	for _, f := range it.constraint {
+	d := qs.opt.toDocumentValue(qv)
+	for _, f := range filters {
 		if !f.Matches(d) {
 			return false
 		}
 	}
-	it.result = v
 	return true
 }
 
+// nodeHashes returns hashes of all nodes matching filters.
+func (qs *QuadStore) nodeHashes(ctx context.Context, filters []FieldFilter) ([]string, error) {
+	it := qs.db.Query(colNodes).WithFields(filters...).Iterate()
+	defer it.Close()
+	var out []string
+	for it.Next(ctx) {
+		if h, ok := it.Doc()[fldHash].(String); ok {
+			out = append(out, string(h))
+		}
+	}
+	return out, it.Err()
+}
+
 func (it *Iterator) Size() (int64, bool) {
 	if it.size == -1 {
 		var err error
@@ -207,17 +348,26 @@ func (it *Iterator) Size() (int64, bool) {
 			it.err = err
 		}
 	}
-	if it.limit > 0 && it.size > it.limit {
-		it.size = it.limit
-	}
 	if it.size < 0 {
 		return it.qs.Size(), false
 	}
-	return it.size, true
+	// node links are not counted
+	return pageSize(it.size, it.offset, it.limit), len(it.nodes) == 0
+}
+
+// pageSize returns a number of documents left from n documents after applying the offset and the limit.
+func pageSize(n, offset, limit int64) int64 {
+	if n -= offset; n < 0 {
+		n = 0
+	}
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return n
 }
 
 func (it *Iterator) Type() graph.Type {
-	if len(it.constraint) == 0 {
+	if len(it.constraint) == 0 && len(it.nodes) == 0 {
 		return graph.All
 	}
 	return "nosql"
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,12 +13,15 @@ import (
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/nosql"
+	"github.com/cayleygraph/cayley/quad/geo"
 )
 
 const Type = "mongo"
 
 var (
 	_ nosql.BatchInserter = (*DB)(nil)
+	_ nosql.Joiner        = (*DB)(nil)
+	_ nosql.QueryHinter   = (*Query)(nil)
 )
 
 func init() {
@@ -25,6 +29,9 @@ func init() {
 		NewFunc:      Open,
 		InitFunc:     Create,
 		IsPersistent: true,
+		Options: nosql.Options{
+			Geo: true,
+		},
 	})
 }
 
@@ -115,8 +122,15 @@ func (db *DB) EnsureIndex(ctx context.Context, col string, primary nosql.Index,
 		}
 	}
 	for _, ind := range secondary {
+		key := []string(ind.Fields)
+		if ind.Type == nosql.IndexGeo {
+			key = make([]string, 0, len(ind.Fields))
+			for _, f := range ind.Fields {
+				key = append(key, "$2dsphere:"+f)
+			}
+		}
 		err := c.EnsureIndex(mgo.Index{
-			Key:        []string(ind.Fields),
+			Key:        key,
 			Unique:     false,
 			Background: true,
 			Sparse:     true,
@@ -153,6 +167,9 @@ func toBsonValue(v nosql.Value) interface{} {
 		return time.Time(v)
 	case nosql.Bytes:
 		return []byte(v)
+	case nosql.GeoPoint:
+		// GeoJSON point, as required by 2dsphere index
+		return bson.M{"type": "Point", "coordinates": []float64{v.Lng, v.Lat}}
 	default:
 		panic(fmt.Errorf("unsupported type: %T", v))
 	}
@@ -162,6 +179,9 @@ func fromBsonValue(v interface{}) nosql.Value {
 	case nil:
 		return nil
 	case bson.M:
+		if p, ok := fromBsonPoint(v); ok {
+			return p
+		}
 		return fromBsonDoc(v)
 	case []interface{}:
 		arr := make(nosql.Strings, 0, len(v))
@@ -194,6 +214,44 @@ func fromBsonValue(v interface{}) nosql.Value {
 		panic(fmt.Errorf("unsupported type: %T", v))
 	}
 }
+func fromBsonPoint(m bson.M) (nosql.GeoPoint, bool) {
+	if typ, _ := m["type"].(string); typ != "Point" || len(m) != 2 {
+		return nosql.GeoPoint{}, false
+	}
+	c, _ := m["coordinates"].([]interface{})
+	if len(c) != 2 {
+		return nosql.GeoPoint{}, false
+	}
+	lng, ok1 := c[0].(float64)
+	lat, ok2 := c[1].(float64)
+	if !ok1 || !ok2 {
+		return nosql.GeoPoint{}, false
+	}
+	return nosql.GeoPoint{Lat: lat, Lng: lng}, true
+}
+
+// toBsonRegion converts a value of GeoWithin filter to an argument of $geoWithin operator.
+func toBsonRegion(v nosql.Value) bson.M {
+	switch v := v.(type) {
+	case nosql.GeoCircle:
+		return bson.M{"$centerSphere": []interface{}{
+			[]float64{v.Center.Lng, v.Center.Lat}, v.Radius / geo.EarthRadius,
+		}}
+	case nosql.GeoPolygon:
+		// GeoJSON polygon must be closed; note that MongoDB uses geodesic edges
+		ring := make([][]float64, 0, len(v)+1)
+		for _, p := range v {
+			ring = append(ring, []float64{p.Lng, p.Lat})
+		}
+		if n := len(v); n != 0 && v[0] != v[n-1] {
+			ring = append(ring, ring[0])
+		}
+		return bson.M{"$geometry": bson.M{"type": "Polygon", "coordinates": [][][]float64{ring}}}
+	default:
+		panic(fmt.Errorf("unsupported geo region: %T", v))
+	}
+}
+
 func toBsonDoc(d nosql.Document) bson.M {
 	if d == nil {
 		return nil
@@ -317,10 +375,178 @@ func (db *DB) FindByKey(ctx context.Context, col string, key nosql.Key) (nosql.D
 	}
 	return c.convDoc(m), nil
 }
+
+// FindByKeys implements nosql.Database. Documents are fetched with a single $in query.
+func (db *DB) FindByKeys(ctx context.Context, col string, keys []nosql.Key) ([]nosql.Document, error) {
+	c := db.colls[col]
+	ids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ids = append(ids, compKey(k))
+	}
+	var res []bson.M
+	err := c.c.Find(bson.M{idField: bson.M{"$in": ids}}).All(&res)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]bson.M, len(res))
+	for _, m := range res {
+		if id, ok := m[idField].(string); ok {
+			byID[id] = m
+		}
+	}
+	out := make([]nosql.Document, len(keys))
+	for i, id := range ids {
+		if m, ok := byID[id]; ok {
+			out[i] = c.convDoc(m)
+		}
+	}
+	return out, nil
+}
 func (db *DB) Query(col string) nosql.Query {
 	c := db.colls[col]
 	return &Query{c: &c}
 }
+
+// Count implements nosql.Database. Documents are counted with the count command.
+func (db *DB) Count(ctx context.Context, col string, filters ...nosql.FieldFilter) (int64, error) {
+	q := db.Query(col)
+	if len(filters) != 0 {
+		q = q.WithFields(filters...)
+	}
+	return q.Count(ctx)
+}
+
+// Aggregate implements nosql.Aggregator. Aggregates are computed with an aggregation pipeline.
+func (db *DB) Aggregate(ctx context.Context, col string, fnc nosql.AggregateFunc, field []string, filters ...nosql.FieldFilter) (nosql.Value, error) {
+	var op string
+	switch fnc {
+	case nosql.AggregateSum:
+		op = "$sum"
+	case nosql.AggregateMin:
+		op = "$min"
+	case nosql.AggregateMax:
+		op = "$max"
+	case nosql.AggregateAvg:
+		op = "$avg"
+	default:
+		return nil, fmt.Errorf("unsupported aggregation: %v", fnc)
+	}
+	var pipe []bson.M
+	if len(filters) != 0 {
+		pipe = append(pipe, bson.M{"$match": buildFilters(filters)})
+	}
+	pipe = append(pipe, bson.M{"$group": bson.M{
+		"_id": nil,
+		"v":   bson.M{op: "$" + strings.Join(field, ".")},
+	}})
+	var res bson.M
+	err := db.colls[col].c.Pipe(pipe).One(&res)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return fromBsonValue(res["v"]), nil
+}
+
+// field returns a name of the document field with a given path.
+func (c *collection) field(path []string) string {
+	if !c.compPK && len(path) == 1 && path[0] == c.primary.Fields[0] {
+		// key field is renamed to _id
+		return idField
+	}
+	return strings.Join(path, ".")
+}
+
+// joinField returns a name of the field that holds a document of a given join step.
+func joinField(i int) string {
+	return "_j" + strconv.Itoa(i)
+}
+
+// Join implements nosql.Joiner with an aggregation pipeline. Documents of each step are added to the document
+// of the first step with $lookup and $unwind stages. Collections of all steps except the first one must
+// not be sharded.
+func (db *DB) Join(ctx context.Context, steps []nosql.JoinStep) nosql.JoinIterator {
+	if len(steps) == 0 {
+		return &joinIterator{err: fmt.Errorf("empty join")}
+	}
+	cols := make([]*collection, 0, len(steps))
+	for _, s := range steps {
+		c, ok := db.colls[s.Collection]
+		if !ok {
+			return &joinIterator{err: fmt.Errorf("collection %q not found", s.Collection)}
+		}
+		cols = append(cols, &c)
+	}
+	var pipe []bson.M
+	if len(steps[0].Filters) != 0 {
+		pipe = append(pipe, bson.M{"$match": buildFilters(steps[0].Filters)})
+	}
+	prev := ""
+	for i := 1; i < len(steps); i++ {
+		s, as := steps[i], joinField(i)
+		pipe = append(pipe,
+			bson.M{"$lookup": bson.M{
+				"from":         cols[i].c.Name,
+				"localField":   prev + cols[i-1].field(steps[i-1].Next),
+				"foreignField": cols[i].field(s.Field),
+				"as":           as,
+			}},
+			// one document for each joined document; documents without matches are dropped
+			bson.M{"$unwind": "$" + as},
+		)
+		if len(s.Filters) != 0 {
+			filters := make([]nosql.FieldFilter, 0, len(s.Filters))
+			for _, f := range s.Filters {
+				f.Path = append([]string{as}, f.Path...)
+				filters = append(filters, f)
+			}
+			pipe = append(pipe, bson.M{"$match": buildFilters(filters)})
+		}
+		prev = as + "."
+	}
+	it := cols[0].c.Pipe(pipe).AllowDiskUse().Iter()
+	return &joinIterator{it: it, cols: cols}
+}
+
+type joinIterator struct {
+	cols []*collection
+	it   *mgo.Iter
+	res  bson.M
+	err  error
+}
+
+func (it *joinIterator) Next(ctx context.Context) bool {
+	if it.it == nil {
+		return false
+	}
+	it.res = make(bson.M)
+	return it.it.Next(&it.res)
+}
+func (it *joinIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.it.Err()
+}
+func (it *joinIterator) Close() error {
+	if it.it == nil {
+		return it.err
+	}
+	return it.it.Close()
+}
+func (it *joinIterator) Docs() []nosql.Document {
+	docs := make([]nosql.Document, len(it.cols))
+	for i := len(it.cols) - 1; i > 0; i-- {
+		as := joinField(i)
+		m, _ := it.res[as].(bson.M)
+		delete(it.res, as)
+		docs[i] = it.cols[i].convDoc(m)
+	}
+	docs[0] = it.cols[0].convDoc(it.res)
+	return docs
+}
+
 func (db *DB) Update(col string, key nosql.Key) nosql.Update {
 	c := db.colls[col]
 	return &Update{col: &c, key: key, update: make(bson.M)}
@@ -334,6 +560,10 @@ func buildFilters(filters []nosql.FieldFilter) bson.M {
 	m := make(bson.M, len(filters))
 	for _, f := range filters {
 		name := strings.Join(f.Path, ".")
+		if f.Filter == nosql.GeoWithin {
+			m[name] = bson.M{"$geoWithin": toBsonRegion(f.Value)}
+			continue
+		}
 		v := toBsonValue(f.Value)
 		if f.Filter == nosql.Equal {
 			m[name] = v
@@ -361,6 +591,10 @@ func buildFilters(filters []nosql.FieldFilter) bson.M {
 			mf["$lt"] = v
 		case nosql.LTE:
 			mf["$lte"] = v
+		case nosql.Exists:
+			mf["$exists"] = v
+		case nosql.In:
+			mf["$in"] = v
 		case nosql.Regexp:
 			pattern, ok := f.Value.(nosql.String)
 			if !ok {
@@ -383,6 +617,9 @@ func mergeFilters(dst, src bson.M) {
 
 type Query struct {
 	c     *collection
+	sort  string
+	hint  []string
+	skip  int
 	limit int
 	query bson.M
 }
@@ -400,12 +637,35 @@ func (q *Query) Limit(n int) nosql.Query {
 	q.limit = n
 	return q
 }
+func (q *Query) Skip(n int) nosql.Query {
+	q.skip = n
+	return q
+}
+func (q *Query) Sort(field []string) nosql.Query {
+	q.sort = strings.Join(field, ".")
+	return q
+}
+
+// Hint implements nosql.QueryHinter.
+func (q *Query) Hint(fields []string) nosql.Query {
+	q.hint = fields
+	return q
+}
 func (q *Query) build() *mgo.Query {
 	var m interface{}
 	if q.query != nil {
 		m = q.query
 	}
 	qu := q.c.c.Find(m)
+	if len(q.hint) != 0 {
+		qu = qu.Hint(q.hint...)
+	}
+	if q.sort != "" {
+		qu = qu.Sort(q.sort)
+	}
+	if q.skip > 0 {
+		qu = qu.Skip(q.skip)
+	}
 	if q.limit > 0 {
 		qu = qu.Limit(q.limit)
 	}
@@ -594,3 +854,42 @@ func (w *inserter) Close() error {
 	w.buf = nil
 	return w.err
 }
+
+var _ nosql.ChangeWatcher = (*DB)(nil)
+
+// WatchInserts implements nosql.ChangeWatcher with MongoDB change streams. It requires a replica set
+// or a sharded cluster. Cluster time of each change is used as a sequence number.
+func (db *DB) WatchInserts(ctx context.Context, col string, fnc func(seq int64, d nosql.Document) error) error {
+	// separate session, so it can be closed to interrupt the stream
+	sess := db.sess.Copy()
+	defer sess.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			sess.Close()
+		case <-done:
+		}
+	}()
+	it := db.db.With(sess).C(col).Pipe([]bson.M{
+		{"$changeStream": bson.M{}},
+		{"$match": bson.M{"operationType": "insert"}},
+	}).Iter()
+	defer it.Close()
+	var ev struct {
+		ClusterTime  bson.MongoTimestamp `bson:"clusterTime"`
+		FullDocument bson.M              `bson:"fullDocument"`
+	}
+	for it.Next(&ev) {
+		delete(ev.FullDocument, idField)
+		if err := fnc(int64(ev.ClusterTime), fromBsonDoc(ev.FullDocument)); err != nil {
+			return err
+		}
+		ev.FullDocument = nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return it.Err()
+}
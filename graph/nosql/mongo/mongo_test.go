@@ -33,6 +33,7 @@ func makeMongo(t testing.TB) (nosql.Database, *nosql.Options, graph.Options, fun
 
 var conf = &nosqltest.Config{
 	TimeInMs: true,
+	Geo:      true,
 }
 
 func TestMongo(t *testing.T) {
@@ -7,6 +7,9 @@ import (
 	"regexp"
 
 	"github.com/pborman/uuid"
+
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad/geo"
 )
 
 var (
@@ -45,8 +48,14 @@ type Database interface {
 	Insert(ctx context.Context, col string, key Key, d Document) (Key, error)
 	// FindByKey finds a document by it's Key. It returns ErrNotFound if document not exists.
 	FindByKey(ctx context.Context, col string, key Key) (Document, error)
+	// FindByKeys finds multiple documents by their Keys in a single request. The returned slice has the same
+	// length and order as keys; it contains nil for documents that do not exist.
+	FindByKeys(ctx context.Context, col string, keys []Key) ([]Document, error)
 	// Query starts construction of a new query for a specified collection.
 	Query(col string) Query
+	// Count returns a number of documents in a collection that match all filters.
+	// It should use a native count operation instead of loading documents.
+	Count(ctx context.Context, col string, filters ...FieldFilter) (int64, error)
 	// Update starts construction of document update request for a specified document and collection.
 	Update(col string, key Key) Update
 	// Delete starts construction of document delete request.
@@ -58,6 +67,40 @@ type Database interface {
 	Close() error
 }
 
+// ChangeWatcher is an optional interface for databases that can stream documents inserted into a collection.
+type ChangeWatcher interface {
+	// WatchInserts calls fnc for each document inserted into a collection after the call, until the context
+	// is canceled or fnc returns an error. Seq increases with each insert.
+	WatchInserts(ctx context.Context, col string, fnc func(seq int64, d Document) error) error
+}
+
+// JoinStep is a single step of a join query.
+type JoinStep struct {
+	Collection string        // name of the collection
+	Filters    []FieldFilter // filters to select documents
+	Field      []string      // field that must be equal to the Next field of the previous document; ignored for the first step
+	Next       []string      // field compared with the Field of the next step; ignored for the last step
+}
+
+// Joiner is an optional interface for databases that can join documents from multiple queries in a single request.
+type Joiner interface {
+	// Join returns all chains of documents, where each document matches filters of the corresponding step, and
+	// its Field is equal to the Next field of the previous document in the chain.
+	Join(ctx context.Context, steps []JoinStep) JoinIterator
+}
+
+// JoinIterator iterates over chains of documents returned by a join query.
+type JoinIterator interface {
+	// Next advances an iterator to the next chain of documents.
+	Next(ctx context.Context) bool
+	// Err returns a last encountered error.
+	Err() error
+	// Close frees all resources associated with iterator.
+	Close() error
+	// Docs returns documents of the current chain, one for each step of the query.
+	Docs() []Document
+}
+
 // FilterOp is a comparison operation type used for value filters.
 type FilterOp int
 
@@ -76,6 +119,16 @@ func (op FilterOp) String() string {
 		name = "LT"
 	case LTE:
 		name = "LTE"
+	case Regexp:
+		name = "Regexp"
+	case TextMatch:
+		name = "TextMatch"
+	case GeoWithin:
+		name = "GeoWithin"
+	case Exists:
+		name = "Exists"
+	case In:
+		name = "In"
 	default:
 		return fmt.Sprintf("FilterOp(%d)", int(op))
 	}
@@ -93,6 +146,16 @@ const (
 	LT
 	LTE
 	Regexp
+	// TextMatch matches analyzed text of a string field. Value must be a TextQuery.
+	// It is only supported by databases that set Options.TextMatch.
+	TextMatch
+	// GeoWithin matches GeoPoint fields located inside a region. Value must be a GeoCircle or a GeoPolygon.
+	// It is only supported by databases that set Options.Geo.
+	GeoWithin
+	// Exists matches documents that have a given field if Value is Bool(true), and ones that don't have it otherwise.
+	Exists
+	// In matches string fields equal to one of the values. Value must be Strings.
+	In
 )
 
 // FieldFilter represents a single field comparison operation.
@@ -103,6 +166,22 @@ type FieldFilter struct {
 }
 
 func (f FieldFilter) Matches(d Document) bool {
+	if f.Filter == Exists {
+		want, _ := f.Value.(Bool)
+		var val Value = d
+		for _, name := range f.Path {
+			d, ok := val.(Document)
+			if !ok {
+				return !bool(want)
+			}
+			v, ok := d[name]
+			if !ok {
+				return !bool(want)
+			}
+			val = v
+		}
+		return bool(want)
+	}
 	if f.Filter == NotEqual {
 		// not equal is special - it allows parent fields to not exist
 		path := f.Path
@@ -159,16 +238,70 @@ func (f FieldFilter) Matches(d Document) bool {
 		}
 		ok, _ = regexp.MatchString(string(pattern), string(s))
 		return ok
+	case TextMatch:
+		q, ok := f.Value.(TextQuery)
+		if !ok {
+			return false
+		}
+		s, ok := val.(String)
+		if !ok {
+			return false
+		}
+		return shape.FullText{Query: q.Query, Fuzziness: q.Fuzziness}.MatchString(string(s))
+	case In:
+		set, ok := f.Value.(Strings)
+		if !ok {
+			return false
+		}
+		s, ok := val.(String)
+		if !ok {
+			return false
+		}
+		for _, v := range set {
+			if v == string(s) {
+				return true
+			}
+		}
+		return false
+	case GeoWithin:
+		r := geoRegion(f.Value)
+		if r == nil {
+			return false
+		}
+		p, ok := val.(GeoPoint)
+		if !ok {
+			return false
+		}
+		return r.Contains(geo.Point{Lat: p.Lat, Lng: p.Lng})
 	}
 	panic(fmt.Errorf("unsupported operation: %v", f.Filter))
 }
 
+// geoRegion converts a value of GeoWithin filter to a region. It returns nil for other values.
+func geoRegion(v Value) geo.Region {
+	switch v := v.(type) {
+	case GeoCircle:
+		return geo.Circle{Point: geo.Point{Lat: v.Center.Lat, Lng: v.Center.Lng}, Radius: v.Radius}
+	case GeoPolygon:
+		poly := make(geo.Polygon, 0, len(v))
+		for _, p := range v {
+			poly = append(poly, geo.Point{Lat: p.Lat, Lng: p.Lng})
+		}
+		return poly
+	}
+	return nil
+}
+
 // Query is a query builder object.
 type Query interface {
 	// WithFields adds specified filters to the query.
 	WithFields(filters ...FieldFilter) Query
 	// Limit limits a maximal number of results returned.
 	Limit(n int) Query
+	// Skip skips a given number of results. Results are skipped before the limit is applied.
+	Skip(n int) Query
+	// Sort orders results by a given field in ascending order. Results are sorted before skip and limit are applied.
+	Sort(field []string) Query
 
 	// Count executes query and returns a number of items that matches it.
 	Count(ctx context.Context) (int64, error)
@@ -178,6 +311,12 @@ type Query interface {
 	Iterate() DocIterator
 }
 
+// QueryHinter is an optional interface for queries that can be forced to use a specific secondary index.
+type QueryHinter interface {
+	// Hint instructs the database to use an index with given fields for the query.
+	Hint(fields []string) Query
+}
+
 // Update is an update request builder.
 type Update interface {
 	// Inc increments document field with a given amount. Will also increment upserted document.
@@ -269,12 +408,44 @@ type BatchInserter interface {
 	BatchInsert(col string) DocWriter
 }
 
+// AggregateFunc is an aggregation function computed by the database.
+type AggregateFunc int
+
+const (
+	AggregateSum = AggregateFunc(iota)
+	AggregateMin
+	AggregateMax
+	AggregateAvg
+)
+
+func (f AggregateFunc) String() string {
+	switch f {
+	case AggregateSum:
+		return "sum"
+	case AggregateMin:
+		return "min"
+	case AggregateMax:
+		return "max"
+	case AggregateAvg:
+		return "avg"
+	}
+	return fmt.Sprintf("AggregateFunc(%d)", int(f))
+}
+
+// Aggregator is an optional interface for databases that can compute aggregates of numeric fields natively.
+type Aggregator interface {
+	// Aggregate computes an aggregate of a numeric field for all documents matching filters.
+	// It returns nil if no documents match.
+	Aggregate(ctx context.Context, col string, fnc AggregateFunc, field []string, filters ...FieldFilter) (Value, error)
+}
+
 // IndexType is a type of index for collection.
 type IndexType int
 
 const (
 	IndexAny    = IndexType(iota)
 	StringExact // exact match for string values (usually a hash index)
+	IndexGeo    // geospatial index for GeoPoint values
 
 	//StringFulltext
 	//IntIndex
@@ -284,6 +455,6 @@ const (
 
 // Index is an index for a collection of documents.
 type Index struct {
-	Fields []string // an ordered set of fields used in index
+	Fields []string // an ordered set of fields used in index; names of nested fields are joined with a dot
 	Type   IndexType
 }
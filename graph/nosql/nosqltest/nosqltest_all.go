@@ -24,6 +24,7 @@ type Config struct {
 	TimeInMs   bool
 	Recreate   bool // tests should re-create database instance from scratch on each run
 	PageSize   int  // result page size for pagination (large iterator) tests
+	Geo        bool // database supports GeoPoint values and GeoWithin filters
 }
 
 func (c Config) quadStore() *graphtest.Config {
@@ -33,6 +34,8 @@ func (c Config) quadStore() *graphtest.Config {
 		OptimizesComparison:      true,
 		SkipDeletedFromIterator:  true,
 		SkipSizeCheckAfterDelete: true,
+		// change streams require a replica set
+		SkipSubscribe: true,
 	}
 }
 
@@ -73,6 +76,9 @@ func TestAll(t *testing.T, gen DatabaseFunc, conf *Config) {
 			return NewQuadStore(t, gen)
 		}, conf.quadStore())
 	})
+	t.Run("gc", func(t *testing.T) {
+		testGC(t, gen)
+	})
 	t.Run("concurrent", func(t *testing.T) {
 		if testing.Short() {
 			t.SkipNow()
@@ -93,6 +99,43 @@ func BenchmarkAll(t *testing.B, gen DatabaseFunc, conf *Config) {
 	})
 }
 
+func testGC(t *testing.T, gen DatabaseFunc) {
+	ctx := context.TODO()
+	db, nopt, opt, closer := gen(t)
+	defer closer()
+	require.NoError(t, nosql.Init(db, opt))
+	qs, err := nosql.NewQuadStore(db, nopt, opt)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	qw := testutil.MakeWriter(t, qs, opt)
+	require.NoError(t, qw.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("a", "p", "b", ""),
+		quad.MakeIRI("b", "p", "c", ""),
+	}))
+	require.NoError(t, qw.RemoveQuad(quad.MakeIRI("b", "p", "c", "")))
+
+	st, err := graph.GC(ctx, qs, 0, true)
+	require.NoError(t, err)
+	require.Equal(t, graph.GCStats{}, st)
+
+	// node left by an interrupted removal
+	_, err = db.Insert(ctx, "nodes", nosql.Key{"orphan"}, nosql.Document{"refs": nosql.Int(0)})
+	require.NoError(t, err)
+
+	st, err = graph.GC(ctx, qs, 0, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), st.Nodes)
+
+	st, err = graph.GC(ctx, qs, 0, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), st.Nodes)
+
+	st, err = graph.GC(ctx, qs, 0, true)
+	require.NoError(t, err)
+	require.Equal(t, graph.GCStats{}, st)
+}
+
 func randString() string {
 	const n = 60
 	b := bytes.NewBuffer(nil)
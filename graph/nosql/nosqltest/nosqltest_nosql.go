@@ -82,9 +82,16 @@ var testsNoSQLKey = []struct {
 }{
 	{name: "ensure", t: testEnsure},
 	{name: "insert", t: testInsert},
+	{name: "find by keys", t: testFindByKeys},
 	{name: "delete by key", t: testDeleteByKey},
 	{name: "update", t: testUpdate},
 	{name: "delete query", t: testDeleteQuery},
+	{name: "page", t: testPage},
+	{name: "sort", t: testSort},
+	{name: "aggregate", t: testAggregate},
+	{name: "exists", t: testExists},
+	{name: "in", t: testIn},
+	{name: "geo", t: testGeo},
 }
 
 type tableConf struct {
@@ -114,6 +121,9 @@ func (c tableConf) fixDoc(k nosql.Key, d nosql.Document) {
 }
 func (c tableConf) expectAll(t testing.TB, docs []nosql.Document) {
 	iterateExpect(t, c.kt, c.db.Query(c.col), docs)
+	n, err := c.db.Count(c.ctx, c.col)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(docs)), n)
 }
 func (c tableConf) insertDocs(t testing.TB, n int, fnc func(i int) nosql.Document) ([]nosql.Key, []nosql.Document) {
 	var (
@@ -366,6 +376,24 @@ func testInsert(t *testing.T, c tableConf) {
 	c.expectAll(t, docs)
 }
 
+func testFindByKeys(t *testing.T, c tableConf) {
+	c.ensurePK(t)
+
+	keys, docs := c.insertDocs(t, 10, func(i int) nosql.Document {
+		return nosql.Document{
+			"data": nosql.Int(i),
+		}
+	})
+
+	// request keys in a different order, with a missing key in the middle
+	req := []nosql.Key{keys[7], keys[2], c.kt.Gen(), keys[0]}
+	exp := []nosql.Document{docs[7], docs[2], nil, docs[0]}
+
+	out, err := c.db.FindByKeys(c.ctx, c.col, req)
+	require.NoError(t, err)
+	require.Equal(t, exp, out)
+}
+
 func testDeleteByKey(t *testing.T, c tableConf) {
 	c.ensurePK(t)
 
@@ -497,6 +525,14 @@ func testDeleteQuery(t *testing.T, c tableConf) {
 		fixDoc(c.conf, d)
 	}
 
+	n, err := c.db.Count(ctx, c.col, nosql.FieldFilter{
+		Path:   []string{"sub", "n"},
+		Filter: nosql.GTE,
+		Value:  nosql.Int(5),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(len(docs)-5), n)
+
 	lt := 1
 	delLt := func(keys []nosql.Key, field ...string) {
 		del := c.Delete()
@@ -545,8 +581,208 @@ func testDeleteQuery(t *testing.T, c tableConf) {
 	delLt(nil, "sub", "n")
 
 	// delete remaining docs
-	err := c.Delete().Do(ctx)
+	err = c.Delete().Do(ctx)
 	require.NoError(t, err)
 
 	c.expectAll(t, nil)
 }
+
+func testPage(t *testing.T, c tableConf) {
+	ctx := context.TODO()
+	c.ensurePK(t)
+
+	const total = 10
+	c.insertDocs(t, total, func(i int) nosql.Document {
+		return nosql.Document{
+			"data": nosql.Int(i),
+		}
+	})
+
+	for _, p := range []struct {
+		skip, limit int
+		exp         int
+	}{
+		{skip: 3, exp: total - 3},
+		{limit: 4, exp: 4},
+		{skip: 3, limit: 4, exp: 4},
+		{skip: 8, limit: 4, exp: 2},
+		{skip: total + 1, exp: 0},
+	} {
+		newQuery := func() nosql.Query {
+			qu := c.Query()
+			if p.skip > 0 {
+				qu = qu.Skip(p.skip)
+			}
+			if p.limit > 0 {
+				qu = qu.Limit(p.limit)
+			}
+			return qu
+		}
+		n, err := newQuery().Count(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(p.exp), n, "skip: %d, limit: %d", p.skip, p.limit)
+
+		it := newQuery().Iterate()
+		seen := make(map[string]struct{})
+		for it.Next(ctx) {
+			seen[fmt.Sprint(it.Key())] = struct{}{}
+		}
+		require.NoError(t, it.Err())
+		it.Close()
+		require.Equal(t, p.exp, len(seen), "skip: %d, limit: %d", p.skip, p.limit)
+	}
+}
+
+func testSort(t *testing.T, c tableConf) {
+	ctx := context.TODO()
+	c.ensurePK(t, nosql.Index{Fields: []string{"sub.n"}, Type: nosql.IndexAny})
+
+	const total = 10
+	c.insertDocs(t, total, func(i int) nosql.Document {
+		return nosql.Document{
+			"sub": nosql.Document{
+				"n": nosql.Float((i * 7) % total),
+			},
+		}
+	})
+
+	filter := nosql.FieldFilter{
+		Path:   []string{"sub", "n"},
+		Filter: nosql.GTE,
+		Value:  nosql.Float(0),
+	}
+	it := c.Query().WithFields(filter).Sort([]string{"sub", "n"}).Skip(2).Limit(5).Iterate()
+	defer it.Close()
+	var got []nosql.Value
+	for it.Next(ctx) {
+		sub, _ := it.Doc()["sub"].(nosql.Document)
+		got = append(got, sub["n"])
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []nosql.Value{
+		nosql.Float(2), nosql.Float(3), nosql.Float(4), nosql.Float(5), nosql.Float(6),
+	}, got)
+}
+
+func testAggregate(t *testing.T, c tableConf) {
+	agg, ok := c.db.(nosql.Aggregator)
+	if !ok {
+		t.SkipNow()
+	}
+	ctx := context.TODO()
+	c.ensurePK(t)
+
+	c.insertDocs(t, 10, func(i int) nosql.Document {
+		return nosql.Document{
+			"sub": nosql.Document{
+				"n": nosql.Float(i),
+			},
+		}
+	})
+
+	field := []string{"sub", "n"}
+	gte := func(v float64) nosql.FieldFilter {
+		return nosql.FieldFilter{Path: field, Filter: nosql.GTE, Value: nosql.Float(v)}
+	}
+	for _, a := range []struct {
+		fnc    nosql.AggregateFunc
+		filter nosql.FieldFilter
+		expect float64
+	}{
+		{fnc: nosql.AggregateSum, filter: gte(5), expect: 35},
+		{fnc: nosql.AggregateMin, filter: gte(5), expect: 5},
+		{fnc: nosql.AggregateMax, filter: gte(5), expect: 9},
+		{fnc: nosql.AggregateAvg, filter: gte(5), expect: 7},
+	} {
+		v, err := agg.Aggregate(ctx, c.col, a.fnc, field, a.filter)
+		require.NoError(t, err)
+		var got float64
+		switch v := v.(type) {
+		case nosql.Float:
+			got = float64(v)
+		case nosql.Int:
+			got = float64(v)
+		default:
+			t.Fatalf("unexpected value for %v: %#v", a.fnc, v)
+		}
+		require.Equal(t, a.expect, got, "%v", a.fnc)
+	}
+	v, err := agg.Aggregate(ctx, c.col, nosql.AggregateMax, field, gte(100))
+	require.NoError(t, err)
+	require.Nil(t, v)
+}
+
+func testGeo(t *testing.T, c tableConf) {
+	if !c.conf.Geo {
+		t.SkipNow()
+	}
+	ctx := context.TODO()
+	c.ensurePK(t, nosql.Index{Fields: []string{"sub.p"}, Type: nosql.IndexGeo})
+
+	// points along the meridian, ~11 km apart
+	const total = 10
+	c.insertDocs(t, total, func(i int) nosql.Document {
+		return nosql.Document{
+			"sub": nosql.Document{
+				"p": nosql.GeoPoint{Lat: 48 + float64(i)/10, Lng: 2},
+			},
+		}
+	})
+
+	count := func(region nosql.Value) int64 {
+		n, err := c.Query().WithFields(nosql.FieldFilter{
+			Path:   []string{"sub", "p"},
+			Filter: nosql.GeoWithin,
+			Value:  region,
+		}).Count(ctx)
+		require.NoError(t, err)
+		return n
+	}
+	require.Equal(t, int64(3), count(nosql.GeoCircle{Center: nosql.GeoPoint{Lat: 48.5, Lng: 2}, Radius: 15}))
+	require.Equal(t, int64(4), count(nosql.GeoPolygon{
+		{Lat: 48.15, Lng: 1.9}, {Lat: 48.15, Lng: 2.1}, {Lat: 48.55, Lng: 2.1}, {Lat: 48.55, Lng: 1.9},
+	}))
+}
+
+func testExists(t *testing.T, c tableConf) {
+	ctx := context.TODO()
+	// secondary index must not be used for documents without the field
+	c.ensurePK(t, nosql.Index{Fields: []string{"label"}, Type: nosql.StringExact})
+
+	c.insertDocs(t, 10, func(i int) nosql.Document {
+		d := nosql.Document{"n": nosql.Int(i)}
+		if i%3 == 0 {
+			d["label"] = nosql.String(fmt.Sprint("g", i))
+		}
+		return d
+	})
+
+	count := func(exists bool) int64 {
+		n, err := c.db.Count(ctx, c.col, nosql.FieldFilter{
+			Path:   []string{"label"},
+			Filter: nosql.Exists,
+			Value:  nosql.Bool(exists),
+		})
+		require.NoError(t, err)
+		return n
+	}
+	require.Equal(t, int64(4), count(true))
+	require.Equal(t, int64(6), count(false))
+}
+
+func testIn(t *testing.T, c tableConf) {
+	ctx := context.TODO()
+	c.ensurePK(t)
+
+	c.insertDocs(t, 10, func(i int) nosql.Document {
+		return nosql.Document{"s": nosql.String(fmt.Sprint("v", i))}
+	})
+
+	n, err := c.db.Count(ctx, c.col, nosql.FieldFilter{
+		Path:   []string{"s"},
+		Filter: nosql.In,
+		Value:  nosql.Strings{"v1", "v3", "v5", "x"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+}
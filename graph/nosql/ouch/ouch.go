@@ -17,7 +17,8 @@ import (
 const Type = driverName
 
 var nosqlOptions = nosql.Options{
-	Number32: true,
+	Number32:     true,
+	TimeAsString: true,
 }
 
 func init() {
@@ -116,9 +117,16 @@ func (db *DB) EnsureIndex(ctx context.Context, col string, primary nosql.Index,
 	// NOTE the field of the primary index is always "_id", so need not create an index
 
 	for k, v := range db.colls[col].secondary {
+		if v.Type == nosql.IndexGeo {
+			continue // Mango has no geospatial indexes
+		}
 		snam := fmt.Sprintf(secondaryIndexFmt, col, k)
+		fields := make([]string, 0, len(v.Fields))
+		for _, f := range v.Fields {
+			fields = append(fields, strings.Replace(f, ".", keySeparator, -1))
+		}
 		sindex := map[string]interface{}{
-			"fields": v.Fields,
+			"fields": fields,
 		}
 		if err := db.db.CreateIndex(ctx, snam, snam, sindex); err != nil {
 			return err
@@ -187,6 +195,35 @@ func (db *DB) FindByKey(ctx context.Context, col string, key nosql.Key) (nosql.D
 	return decoded, err
 }
 
+// FindByKeys implements nosql.Database. Documents are fetched with a single Mango query on document ids.
+func (db *DB) FindByKeys(ctx context.Context, col string, keys []nosql.Key) ([]nosql.Document, error) {
+	ids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ids = append(ids, compKey(k))
+	}
+	q := db.Query(col).(*Query)
+	q.qu.putSelector(idField, map[string]interface{}{"$in": ids})
+
+	byID := make(map[string]nosql.Document, len(ids))
+	it := q.Iterate().(*Iterator)
+	for it.Next(ctx) {
+		id, _ := it.doc[idField].(string)
+		byID[id] = it.Doc()
+	}
+	if err := it.Err(); err != nil {
+		it.Close()
+		return nil, err
+	}
+	if err := it.Close(); err != nil {
+		return nil, err
+	}
+	out := make([]nosql.Document, len(keys))
+	for i, id := range ids {
+		out[i] = byID[id]
+	}
+	return out, nil
+}
+
 func (db *DB) findByKey(ctx context.Context, col string, key nosql.Key) (nosql.Document, string, string, error) {
 	cK := compKey(key)
 	return db.findByOuchKey(ctx, cK)
@@ -225,6 +262,16 @@ func (db *DB) Query(col string) nosql.Query {
 	}
 	return qry
 }
+
+// Count implements nosql.Database. CouchDB has no count for Mango queries, thus matching
+// documents are iterated without fetching their fields.
+func (db *DB) Count(ctx context.Context, col string, filters ...nosql.FieldFilter) (int64, error) {
+	q := db.Query(col)
+	if len(filters) != 0 {
+		q = q.WithFields(filters...)
+	}
+	return q.Count(ctx)
+}
 func (db *DB) Update(col string, key nosql.Key) nosql.Update {
 	return &Update{db: db, col: col, key: key, update: nosql.Document{}}
 }
@@ -288,6 +335,19 @@ func (q *Query) WithFields(filters ...nosql.FieldFilter) nosql.Query {
 	return q
 }
 
+// absentField checks if filters select documents without the field.
+func absentField(filters []nosql.FieldFilter) bool {
+	for _, f := range filters {
+		if f.Filter != nosql.Exists {
+			continue
+		}
+		if b, _ := f.Value.(nosql.Bool); !b {
+			return true
+		}
+	}
+	return false
+}
+
 func (q *Query) buildFilters() {
 	for jp, filterList := range q.pathFilters {
 		term := map[string]interface{}{}
@@ -319,6 +379,10 @@ func (q *Query) buildFilters() {
 				test = "$lte"
 			case nosql.Regexp:
 				test = "$regex"
+			case nosql.Exists:
+				test = "$exists"
+			case nosql.In:
+				test = "$in"
 			default:
 				panic(fmt.Errorf("unknown nosqlFilter %v", filter.Filter))
 			}
@@ -336,7 +400,8 @@ func (q *Query) buildFilters() {
 			for si, sv := range c.secondary {
 				useSecondary := true
 				for _, fieldName := range sv.Fields {
-					if _, found := q.pathFilters[fieldName]; !found {
+					if filters, found := q.pathFilters[fieldName]; !found || absentField(filters) {
+						// indexes only contain documents with the field
 						useSecondary = false
 					}
 				}
@@ -354,6 +419,18 @@ func (q *Query) Limit(n int) nosql.Query {
 	return q
 }
 
+func (q *Query) Skip(n int) nosql.Query {
+	q.qu["skip"] = n
+	return q
+}
+
+func (q *Query) Sort(field []string) nosql.Query {
+	q.qu["sort"] = []interface{}{
+		map[string]string{strings.Join(field, keySeparator): "asc"},
+	}
+	return q
+}
+
 func (q *Query) Count(ctx context.Context) (int64, error) {
 	// TODO it should be possible to use map/reduce logic, rather than a mango query, to speed this up, at least for some cases
 
@@ -0,0 +1,233 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nosql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Iterator = (*PathIterator)(nil)
+
+// PathIterator returns quads or nodes selected by the last step of a Path. Steps are joined by the database,
+// thus it must implement Joiner.
+//
+// Validity of quads is checked for all steps of each chain returned by the database, so a chain that
+// passes through a deleted or expired quad is skipped.
+type PathIterator struct {
+	uid  uint64
+	tags graph.Tagger
+	qs   *QuadStore
+	path Path
+
+	iter   JoinIterator
+	result graph.Value
+	size   int64
+	err    error
+}
+
+// NewPathIterator creates an iterator that follows a given path.
+func NewPathIterator(qs *QuadStore, p Path) *PathIterator {
+	return &PathIterator{
+		uid:  iterator.NextUID(),
+		qs:   qs,
+		path: p,
+		size: -1,
+	}
+}
+
+func (it *PathIterator) UID() uint64 {
+	return it.uid
+}
+
+func (it *PathIterator) Reset() {
+	it.Close()
+	it.iter = nil
+	it.result = nil
+	it.err = nil
+}
+
+func (it *PathIterator) Close() error {
+	if it.iter != nil {
+		return it.iter.Close()
+	}
+	return nil
+}
+
+func (it *PathIterator) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *PathIterator) TagResults(dst map[string]graph.Value) {
+	it.tags.TagResult(dst, it.Result())
+}
+
+func (it *PathIterator) Clone() graph.Iterator {
+	m := NewPathIterator(it.qs, it.path)
+	m.size = it.size
+	m.tags.CopyFrom(it)
+	return m
+}
+
+func (it *PathIterator) SubIterators() []graph.Iterator {
+	return nil
+}
+
+// join starts a join query for a given path.
+func (it *PathIterator) join(ctx context.Context, p Path) (JoinIterator, error) {
+	j, ok := it.qs.db.(Joiner)
+	if !ok {
+		return nil, fmt.Errorf("database does not support joins: %T", it.qs.db)
+	}
+	return j.Join(ctx, p.joinSteps()), nil
+}
+
+// next advances the join iterator to the next chain with valid quads and returns its result.
+func (it *PathIterator) next(ctx context.Context, iter JoinIterator) (graph.Value, bool) {
+	for iter.Next(ctx) {
+		// not all backends can cancel an open cursor, thus the context is checked between documents
+		if err := ctx.Err(); err != nil {
+			it.err = err
+			return nil, false
+		}
+		docs := iter.Docs()
+		valid := len(docs) == len(it.path.Steps)
+		for _, d := range docs {
+			if d == nil || !checkQuadValid(d) {
+				valid = false
+				break
+			}
+		}
+		if !valid {
+			continue
+		}
+		last := docs[len(docs)-1]
+		if it.path.Result == quad.Any {
+			return quadHashOf(last), true
+		}
+		if h, _ := last[it.path.Result.String()].(String); h != "" {
+			return NodeHash(h), true
+		}
+	}
+	if err := iter.Err(); err != nil {
+		it.err = err
+	}
+	return nil, false
+}
+
+func (it *PathIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.iter == nil {
+		it.iter, it.err = it.join(ctx, it.path)
+		if it.err != nil {
+			return false
+		}
+	}
+	v, ok := it.next(ctx, it.iter)
+	if !ok {
+		return false
+	}
+	it.result = v
+	return true
+}
+
+func (it *PathIterator) Err() error {
+	return it.err
+}
+
+func (it *PathIterator) Result() graph.Value {
+	return it.result
+}
+
+func (it *PathIterator) NextPath(ctx context.Context) bool {
+	return false
+}
+
+// Contains checks if a value is selected by the path. It runs a separate join query with the value added
+// to constraints of the last step.
+func (it *PathIterator) Contains(ctx context.Context, v graph.Value) bool {
+	var links []Linkage
+	if it.path.Result == quad.Any {
+		qh, ok := v.(QuadHash)
+		if !ok {
+			return false
+		}
+		for _, d := range quad.Directions {
+			links = append(links, Linkage{Dir: d, Val: NodeHash(qh.Get(d))})
+		}
+	} else {
+		h, ok := v.(NodeHash)
+		if !ok || h == "" {
+			return false
+		}
+		links = []Linkage{{Dir: it.path.Result, Val: h}}
+	}
+	p := it.path
+	p.Steps = append([]PathStep{}, p.Steps...)
+	last := &p.Steps[len(p.Steps)-1]
+	last.Links = append(append([]Linkage{}, last.Links...), links...)
+
+	iter, err := it.join(ctx, p)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	defer iter.Close()
+	if _, ok := it.next(ctx, iter); !ok {
+		return false
+	}
+	it.result = v
+	return true
+}
+
+// Size returns a number of quads matching the last step of the path as an estimate.
+func (it *PathIterator) Size() (int64, bool) {
+	if it.size == -1 {
+		last := it.path.Steps[len(it.path.Steps)-1]
+		var err error
+		it.size, err = it.qs.getSize(colQuads, linksFilters(last.Links))
+		if err != nil {
+			it.err = err
+		}
+	}
+	if it.size < 0 {
+		return it.qs.Size(), false
+	}
+	return it.size, false
+}
+
+func (it *PathIterator) Type() graph.Type { return "nosql-path" }
+
+func (it *PathIterator) Optimize() (graph.Iterator, bool) { return it, false }
+
+func (it *PathIterator) String() string {
+	return fmt.Sprintf("NoSQLPath(%d, %v)", len(it.path.Steps), it.path.Result)
+}
+
+func (it *PathIterator) Stats() graph.IteratorStats {
+	size, exact := it.Size()
+	return graph.IteratorStats{
+		ContainsCost: 5 * int64(len(it.path.Steps)),
+		NextCost:     5,
+		Size:         size,
+		ExactSize:    exact,
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cayleygraph/cayley/clog"
@@ -25,6 +26,7 @@ import (
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/internal/lru"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
 	"github.com/cayleygraph/cayley/quad/pquads"
 )
 
@@ -38,7 +40,10 @@ type Registration struct {
 }
 
 type Options struct {
-	Number32 bool // store is limited to 32 bit precision
+	Number32     bool // store is limited to 32 bit precision
+	TimeAsString bool // store keeps time values as strings, thus they cannot be ordered by the database
+	TextMatch    bool // store supports TextMatch filters on analyzed text
+	Geo          bool // store can index GeoPoint values and supports GeoWithin filters
 }
 
 type InitFunc func(string, graph.Options) (Database, error)
@@ -83,17 +88,36 @@ func Register(name string, r Registration) {
 }
 
 func Init(db Database, opt graph.Options) error {
-	return ensureIndexes(context.TODO(), db)
+	indexes, err := indexesFromOptions(opt)
+	if err != nil {
+		return err
+	}
+	return ensureIndexes(context.TODO(), db, indexes)
 }
 
+// DefaultValueBatchSize is the default number of nodes fetched in a single request when resolving values.
+const DefaultValueBatchSize = 100
+
 func NewQuadStore(db Database, nopt *Options, opt graph.Options) (*QuadStore, error) {
-	if err := ensureIndexes(context.TODO(), db); err != nil {
+	batch, err := opt.IntKey("value_batch_size", DefaultValueBatchSize)
+	if err != nil {
+		return nil, err
+	} else if batch <= 0 {
+		batch = 1
+	}
+	indexes, err := indexesFromOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureIndexes(context.TODO(), db, indexes); err != nil {
 		return nil, err
 	}
 	qs := &QuadStore{
-		db:    db,
-		ids:   lru.New(1 << 16),
-		sizes: lru.New(1 << 16),
+		db:      db,
+		ids:     lru.New(1 << 16),
+		sizes:   lru.New(1 << 16),
+		batch:   batch,
+		indexes: indexes,
 	}
 	if nopt != nil {
 		qs.opt = *nopt
@@ -140,6 +164,7 @@ const (
 	fldLabel       = "label"
 	fldQuadAdded   = "added"
 	fldQuadDeleted = "deleted"
+	fldQuadExpires = "expires"
 
 	fldHash  = "hash"
 	fldValue = "value"
@@ -156,16 +181,71 @@ const (
 	fldValBool   = "bool"
 	fldValTime   = "ts"
 	fldValPb     = "pb"
+	fldValGeo    = "geo"
 )
 
 type QuadStore struct {
-	db    Database
-	ids   *lru.Cache
-	sizes *lru.Cache
-	opt   Options
+	db      Database
+	ids     *lru.Cache
+	sizes   *lru.Cache
+	opt     Options
+	batch   int
+	indexes map[string][]Index // user-defined secondary indexes by collection
 }
 
-func ensureIndexes(ctx context.Context, db Database) error {
+// indexesFromOptions parses user-defined secondary indexes from the "indexes" option.
+//
+// Each index is described by a string in the "<collection>:<field>,<field>..." format, where collection is
+// either "nodes" or "quads", and names of nested fields are joined with a dot, e.g. "quads:subject,predicate"
+// or "nodes:value.str". The option accepts a list of such strings, or a single string with indexes separated by ";".
+func indexesFromOptions(opt graph.Options) (map[string][]Index, error) {
+	var specs []string
+	switch v := opt["indexes"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		for _, s := range strings.Split(v, ";") {
+			if s = strings.TrimSpace(s); s != "" {
+				specs = append(specs, s)
+			}
+		}
+	case []string:
+		specs = v
+	case []interface{}:
+		for _, s := range v {
+			str, ok := s.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid index definition: %v", s)
+			}
+			specs = append(specs, str)
+		}
+	default:
+		return nil, fmt.Errorf("invalid type for indexes option: %T", v)
+	}
+	indexes := make(map[string][]Index)
+	for _, spec := range specs {
+		i := strings.Index(spec, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid index definition %q: collection is not set", spec)
+		}
+		col := strings.TrimSpace(spec[:i])
+		if col != colNodes && col != colQuads {
+			return nil, fmt.Errorf("invalid index definition %q: unsupported collection %q", spec, col)
+		}
+		var fields []string
+		for _, f := range strings.Split(spec[i+1:], ",") {
+			if f = strings.TrimSpace(f); f == "" {
+				return nil, fmt.Errorf("invalid index definition %q: empty field name", spec)
+			}
+			fields = append(fields, f)
+		}
+		indexes[col] = append(indexes[col], Index{Fields: fields, Type: IndexAny})
+	}
+	return indexes, nil
+}
+
+// ensureIndexes creates indexes of all collections. User-defined indexes are added to built-in secondary indexes.
+func ensureIndexes(ctx context.Context, db Database, user map[string][]Index) error {
 	err := db.EnsureIndex(ctx, colLog, Index{
 		Fields: []string{fldLogID},
 		Type:   StringExact,
@@ -176,7 +256,16 @@ func ensureIndexes(ctx context.Context, db Database) error {
 	err = db.EnsureIndex(ctx, colNodes, Index{
 		Fields: []string{fldHash},
 		Type:   StringExact,
-	}, nil)
+	}, append([]Index{
+		// used to order nodes by value
+		{Fields: []string{fldValue + "." + fldValInt}, Type: IndexAny},
+		{Fields: []string{fldValue + "." + fldValFloat}, Type: IndexAny},
+		{Fields: []string{fldValue + "." + fldValTime}, Type: IndexAny},
+		// used to find literals by datatype
+		{Fields: []string{fldValue + "." + fldType}, Type: IndexAny},
+		// used to find geometry literals by location
+		{Fields: []string{fldValue + "." + fldValGeo}, Type: IndexGeo},
+	}, user[colNodes]...))
 	if err != nil {
 		return err
 	}
@@ -188,12 +277,14 @@ func ensureIndexes(ctx context.Context, db Database) error {
 			fldLabel,
 		},
 		Type: StringExact,
-	}, []Index{
+	}, append([]Index{
 		{Fields: []string{fldSubject}, Type: StringExact},
 		{Fields: []string{fldPredicate}, Type: StringExact},
 		{Fields: []string{fldObject}, Type: StringExact},
 		{Fields: []string{fldLabel}, Type: StringExact},
-	})
+		// used to find quads of a predicate in a given graph, including the default one
+		{Fields: []string{fldPredicate, fldLabel}, Type: StringExact},
+	}, user[colQuads]...))
 	if err != nil {
 		return err
 	}
@@ -246,7 +337,7 @@ func (qs *QuadStore) cleanupNodes(ctx context.Context, keys []Key) error {
 	return err
 }
 
-func (qs *QuadStore) updateQuad(ctx context.Context, q quad.Quad, proc graph.Procedure) error {
+func (qs *QuadStore) updateQuad(ctx context.Context, q quad.Quad, proc graph.Procedure, expires time.Time) error {
 	var setname string
 	if proc == graph.Add {
 		setname = fldQuadAdded
@@ -261,6 +352,9 @@ func (qs *QuadStore) updateQuad(ctx context.Context, q quad.Quad, proc graph.Pro
 	if l := hashOf(q.Label); l != "" {
 		doc[fldLabel] = String(l)
 	}
+	if proc == graph.Add && !expires.IsZero() {
+		doc[fldQuadExpires] = Time(expires.UTC())
+	}
 	err := qs.db.Update(colQuads, getKeyForQuad(q)).Upsert(doc).
 		Inc(setname, 1).Do(ctx)
 	if err != nil {
@@ -269,22 +363,35 @@ func (qs *QuadStore) updateQuad(ctx context.Context, q quad.Quad, proc graph.Pro
 	return err
 }
 
-func checkQuadValid(q Document) bool {
+// checkQuadAdded checks if the quad was added more times than deleted.
+// Such quad holds references to its nodes, even if it's expired.
+func checkQuadAdded(q Document) bool {
 	added, _ := asInt(q[fldQuadAdded])
 	deleted, _ := asInt(q[fldQuadDeleted])
 	return added > deleted
 }
 
-func (qs *QuadStore) checkValidQuad(ctx context.Context, key Key) (bool, error) {
+// checkQuadExpired checks if the quad has an expiration time that is not after now.
+func checkQuadExpired(q Document, now time.Time) bool {
+	t, ok := q[fldQuadExpires].(Time)
+	return ok && !time.Time(t).After(now)
+}
+
+func checkQuadValid(q Document) bool {
+	return checkQuadAdded(q) && !checkQuadExpired(q, time.Now())
+}
+
+// findQuadDoc returns a quad document with a given key, or nil if it does not exist.
+func (qs *QuadStore) findQuadDoc(ctx context.Context, key Key) (Document, error) {
 	q, err := qs.db.FindByKey(ctx, colQuads, key)
 	if err == ErrNotFound {
-		return false, nil
+		return nil, nil
 	}
 	if err != nil {
 		err = fmt.Errorf("error checking quad validity: %v", err)
-		return false, err
+		return nil, err
 	}
-	return checkQuadValid(q), nil
+	return q, nil
 }
 
 func (qs *QuadStore) batchInsert(col string) DocWriter {
@@ -319,22 +426,35 @@ func (qs *QuadStore) appendLog(ctx context.Context, deltas []graph.Delta) ([]Key
 }
 
 func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	return qs.applyDeltas(deltas, ignoreOpts, time.Time{})
+}
+
+// applyDeltas applies deltas to the database. If expires is set, added quads expire at this time.
+func (qs *QuadStore) applyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts, expires time.Time) error {
 	ctx := context.TODO()
+	deltas, err := graph.ResolveDeltas(ctx, qs, deltas)
+	if err != nil {
+		return err
+	}
 	ids := make(map[quad.Value]int)
 
 	var validDeltas []graph.Delta
 	if ignoreOpts.IgnoreDup || ignoreOpts.IgnoreMissing {
 		validDeltas = make([]graph.Delta, 0, len(deltas))
 	}
+	// documents of deleted or expired quads that will be replaced by new ones
+	var stale []Key
 	// Pre-check the existence condition.
 	for _, d := range deltas {
 		if d.Action != graph.Add && d.Action != graph.Delete {
 			return &graph.DeltaError{Delta: d, Err: graph.ErrInvalidAction}
 		}
-		valid, err := qs.checkValidQuad(ctx, getKeyForQuad(d.Quad))
+		key := getKeyForQuad(d.Quad)
+		doc, err := qs.findQuadDoc(ctx, key)
 		if err != nil {
 			return &graph.DeltaError{Delta: d, Err: err}
 		}
+		valid := doc != nil && checkQuadValid(doc)
 		switch d.Action {
 		case graph.Add:
 			if valid {
@@ -344,6 +464,16 @@ func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOp
 					return &graph.DeltaError{Delta: d, Err: graph.ErrQuadExists}
 				}
 			}
+			if doc != nil {
+				stale = append(stale, key)
+				if checkQuadAdded(doc) {
+					// expired quad still holds references to nodes, the new one will reuse them
+					if validDeltas != nil {
+						validDeltas = append(validDeltas, d)
+					}
+					continue
+				}
+			}
 		case graph.Delete:
 			if !valid {
 				if ignoreOpts.IgnoreMissing {
@@ -395,8 +525,13 @@ func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOp
 	if err := qs.cleanupNodes(ctx, gc); err != nil {
 		return err
 	}
+	if len(stale) != 0 {
+		if err := qs.db.Delete(colQuads).Keys(stale...).Do(ctx); err != nil {
+			return fmt.Errorf("error removing stale quads: %v", err)
+		}
+	}
 	for _, d := range deltas {
-		err := qs.updateQuad(ctx, d.Quad, d.Action)
+		err := qs.updateQuad(ctx, d.Quad, d.Action, expires)
 		if err != nil {
 			return &graph.DeltaError{Delta: d, Err: err}
 		}
@@ -426,6 +561,13 @@ func (opt Options) toDocumentValue(v quad.Value) Document {
 		doc = Document{fldValData: String(d), fldBNode: Bool(true)}
 	case quad.TypedString:
 		doc = Document{fldValData: String(d.Value), fldType: String(d.Type)}
+		if opt.Geo {
+			// store a center of geometry literals, the same point that shape.GeoWithin checks
+			if g, err := geo.FromValue(d); err == nil {
+				c := g.Center()
+				doc[fldValGeo] = GeoPoint{Lat: c.Lat, Lng: c.Lng}
+			}
+		}
 	case quad.LangString:
 		doc = Document{fldValData: String(d.Value), fldLang: String(d.Lang)}
 	case quad.Int:
@@ -610,10 +752,15 @@ func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
 		clog.Errorf("couldn't retrieve node %v: %v", v, err)
 		return nil
 	}
+	return qs.nodeValue(hash, nd)
+}
+
+// nodeValue decodes a value from the node document and puts it to the cache.
+func (qs *QuadStore) nodeValue(hash NodeHash, nd Document) quad.Value {
 	dv, _ := nd[fldValue].(Document)
 	qv, err := qs.opt.toQuadValue(dv)
 	if err != nil {
-		clog.Errorf("couldn't convert node %v: %v", v, err)
+		clog.Errorf("couldn't convert node %v: %v", hash, err)
 		return nil
 	}
 	if id, _ := nd[fldHash].(String); id == String(hash) && qv != nil {
@@ -622,6 +769,66 @@ func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
 	return qv
 }
 
+var _ graph.BatchQuadStore = (*QuadStore)(nil)
+
+// ValuesOf implements graph.BatchQuadStore. Values that are not cached are fetched from the database
+// in batches, instead of issuing a separate request for each node.
+func (qs *QuadStore) ValuesOf(ctx context.Context, vals []graph.Value) ([]quad.Value, error) {
+	out := make([]quad.Value, len(vals))
+	var (
+		hashes  []NodeHash
+		pending = make(map[NodeHash][]int)
+	)
+	for i, v := range vals {
+		switch v := v.(type) {
+		case nil:
+		case graph.PreFetchedValue:
+			out[i] = v.NameOf()
+		case NodeHash:
+			if v == "" {
+				continue
+			} else if val, ok := qs.ids.Get(string(v)); ok {
+				out[i] = val.(quad.Value)
+				continue
+			}
+			if _, ok := pending[v]; !ok {
+				hashes = append(hashes, v)
+			}
+			pending[v] = append(pending[v], i)
+		default:
+			return out, fmt.Errorf("unexpected value type: %T", v)
+		}
+	}
+	keys := make([]Key, 0, qs.batch)
+	for len(hashes) > 0 {
+		batch := hashes
+		if len(batch) > qs.batch {
+			batch = batch[:qs.batch]
+		}
+		hashes = hashes[len(batch):]
+		keys = keys[:0]
+		for _, h := range batch {
+			keys = append(keys, h.key())
+		}
+		sctx, end := graph.StartSpan(ctx, "nosql.find")
+		docs, err := qs.db.FindByKeys(sctx, colNodes, keys)
+		end(err)
+		if err != nil {
+			return out, err
+		}
+		for j, nd := range docs {
+			if nd == nil {
+				continue
+			}
+			qv := qs.nodeValue(batch[j], nd)
+			for _, i := range pending[batch[j]] {
+				out[i] = qv
+			}
+		}
+	}
+	return out, nil
+}
+
 func (qs *QuadStore) Size() int64 {
 	// TODO(barakmich): Make size real; store it in the log, and retrieve it.
 	count, err := qs.db.Query(colQuads).Count(context.TODO())
@@ -636,6 +843,38 @@ func (qs *QuadStore) Close() error {
 	return qs.db.Close()
 }
 
+var _ graph.CapabilityReporter = (*QuadStore)(nil)
+
+// Capabilities implements graph.CapabilityReporter. Deltas are written to the database one by one,
+// thus transactions are not atomic.
+func (qs *QuadStore) Capabilities() graph.Capabilities {
+	_, watch := qs.db.(ChangeWatcher)
+	return graph.Capabilities{
+		RegexPushdown: true,
+		ChangeFeed:    watch,
+	}
+}
+
+// RefreshStats drops cached size estimates, so they will be recalculated on the next use.
+func (qs *QuadStore) RefreshStats(ctx context.Context) error {
+	qs.sizes.Purge()
+	return nil
+}
+
+// EnsureIndexes creates indexes required by the quad store, if they are missing.
+func (qs *QuadStore) EnsureIndexes(ctx context.Context) error {
+	return ensureIndexes(ctx, qs.db, qs.indexes)
+}
+
+// Ping checks the connection to the database by reading a single node document.
+func (qs *QuadStore) Ping(ctx context.Context) error {
+	_, err := qs.db.Query(colNodes).Limit(1).One(ctx)
+	if err == ErrNotFound {
+		err = nil
+	}
+	return err
+}
+
 func (qs *QuadStore) QuadDirection(in graph.Value, d quad.Direction) graph.Value {
 	return NodeHash(in.(QuadHash).Get(d))
 }
@@ -0,0 +1,106 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nosql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+var _ graph.PatternRemover = (*QuadStore)(nil)
+
+var quadFields = []string{fldSubject, fldPredicate, fldObject, fldLabel}
+
+// patternFilters returns filters that select quad documents matching the pattern.
+func patternFilters(pattern quad.Quad) []FieldFilter {
+	var filters []FieldFilter
+	for i, d := range quad.Directions {
+		if v := pattern.Get(d); v != nil {
+			filters = append(filters, FieldFilter{
+				Path:   []string{quadFields[i]},
+				Filter: Equal,
+				Value:  String(hashOf(v)),
+			})
+		}
+	}
+	return filters
+}
+
+// RemoveQuadsMatching removes all quads matching the pattern with a filtered delete.
+//
+// Only hashes of matching quads are read to update reference counters of nodes.
+// The operation is logged as a single entry with the pattern.
+func (qs *QuadStore) RemoveQuadsMatching(ctx context.Context, pattern quad.Quad) (int64, error) {
+	filters := patternFilters(pattern)
+	// count references to nodes from valid quads that will be removed
+	refs := make(map[string]int)
+	var n int64
+	it := qs.db.Query(colQuads).WithFields(filters...).Iterate()
+	for it.Next(ctx) {
+		doc := it.Doc()
+		if !checkQuadAdded(doc) {
+			continue
+		}
+		n++
+		for _, f := range quadFields {
+			if h, ok := doc[f].(String); ok && h != "" {
+				refs[string(h)]++
+			}
+		}
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		return 0, fmt.Errorf("error reading quads: %v", err)
+	}
+	data, err := pquads.MakeQuad(pattern).Marshal()
+	if err != nil {
+		return 0, err
+	}
+	w := qs.batchInsert(colLog)
+	err = w.WriteDoc(ctx, nil, Document{
+		"op":   String("DeleteMatchingPQ"),
+		"data": Bytes(data),
+		"ts":   Time(time.Now().UTC()),
+	})
+	if err == nil {
+		err = w.Flush(ctx)
+	}
+	w.Close()
+	if err != nil {
+		return 0, err
+	}
+	if err = qs.db.Delete(colQuads).WithFields(filters...).Do(ctx); err != nil {
+		return 0, fmt.Errorf("error removing quads: %v", err)
+	}
+	gc := make([]Key, 0, len(refs))
+	for h, dn := range refs {
+		key := NodeHash(h).key()
+		if err = qs.db.Update(colNodes, key).Inc(fldSize, -dn).Do(ctx); err != nil {
+			return n, fmt.Errorf("error updating node: %v", err)
+		}
+		gc = append(gc, key)
+	}
+	if err = qs.cleanupNodes(ctx, gc); err != nil {
+		return n, err
+	}
+	qs.sizes.Purge()
+	return n, nil
+}
@@ -3,12 +3,15 @@ package nosql
 import (
 	"fmt"
 	"math"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/shape"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
 )
 
 func (qs *QuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool) {
@@ -18,13 +21,31 @@ func (qs *QuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool)
 var _ shape.Optimizer = (*QuadStore)(nil)
 
 func (qs *QuadStore) OptimizeShape(s shape.Shape) (shape.Shape, bool) {
+	ns, ok := qs.optimizeShape(s)
+	if ok && len(qs.indexes) != 0 {
+		ns = qs.withHints(ns)
+	}
+	return ns, ok
+}
+
+func (qs *QuadStore) optimizeShape(s shape.Shape) (shape.Shape, bool) {
 	switch s := s.(type) {
 	case shape.Quads:
 		return qs.optimizeQuads(s)
+	case shape.NodesFrom:
+		return qs.optimizeNodesFrom(s)
 	case shape.Filter:
 		return qs.optimizeFilter(s)
+	case shape.Intersect:
+		return qs.optimizeIntersect(s)
 	case shape.Page:
 		return qs.optimizePage(s)
+	case shape.Count:
+		return qs.optimizeCount(s)
+	case shape.Sort:
+		return qs.optimizeSort(s)
+	case shape.Aggregate:
+		return qs.optimizeAggregate(s)
 	case shape.Composite:
 		if s2, opt := s.Simplify().Optimize(qs); opt {
 			return s2, true
@@ -37,39 +58,122 @@ func (qs *QuadStore) OptimizeShape(s shape.Shape) (shape.Shape, bool) {
 type Shape struct {
 	Collection string        // name of the collection
 	Filters    []FieldFilter // filters to select documents
+	Sort       []string      // orders documents by a given field
+	Offset     int64         // skips a number of documents
 	Limit      int64         // limits a number of documents
+	Hint       []string      // fields of a user-defined index that the database should use
 }
 
 func (s Shape) BuildIterator(qs graph.QuadStore) graph.Iterator {
-	db, ok := qs.(*QuadStore)
+	db, ok := graph.Unwrap(qs).(*QuadStore)
 	if !ok {
 		return iterator.NewError(fmt.Errorf("not a nosql database: %T", qs))
 	}
-	return NewIterator(db, s.Collection, s.Filters...)
+	it := NewIterator(db, s.Collection, s.Filters...)
+	it.sort, it.hint, it.offset, it.limit = s.Sort, s.Hint, s.Offset, s.Limit
+	return it
 }
 
 func (s Shape) Optimize(r shape.Optimizer) (shape.Shape, bool) {
 	return s, false
 }
 
+// filterable checks if more filters on nodes can be added to the query without changing its meaning.
+func (s Shape) filterable() bool {
+	return s.Collection == colNodes && s.Offset == 0 && s.Limit == 0
+}
+
 // Quads is a shape representing a quads query
 type Quads struct {
-	Links []Linkage // filters to select quads
-	Limit int64     // limits a number of documents
+	Links  []Linkage  // filters to select quads
+	Nodes  []NodeLink // filters on nodes of quads; they are resolved by a separate query
+	Offset int64      // skips a number of documents
+	Limit  int64      // limits a number of documents
+	Hint   []string   // fields of a user-defined index that the database should use
 }
 
 func (s Quads) BuildIterator(qs graph.QuadStore) graph.Iterator {
-	db, ok := qs.(*QuadStore)
+	db, ok := graph.Unwrap(qs).(*QuadStore)
 	if !ok {
 		return iterator.NewError(fmt.Errorf("not a nosql database: %T", qs))
 	}
-	return NewLinksToIterator(db, colQuads, s.Links)
+	it := NewLinksToIterator(db, colQuads, s.Links)
+	it.nodes = s.Nodes
+	it.hint, it.offset, it.limit = s.Hint, s.Offset, s.Limit
+	return it
 }
 
 func (s Quads) Optimize(r shape.Optimizer) (shape.Shape, bool) {
 	return s, false
 }
 
+// indexHint returns fields of the largest user-defined index of the collection that can be used to find
+// documents matching filters. Fields of the index except the last one must be compared for equality, and
+// the last one may also be compared with a range or a regexp. It returns nil if there is no such index.
+func (qs *QuadStore) indexHint(col string, filters []FieldFilter) []string {
+	ops := make(map[string][]FilterOp, len(filters))
+	for _, f := range filters {
+		name := strings.Join(f.Path, ".")
+		ops[name] = append(ops[name], f.Filter)
+	}
+	has := func(name string, eq bool) bool {
+		for _, op := range ops[name] {
+			switch op {
+			case Equal, In:
+				return true
+			case GT, GTE, LT, LTE, Regexp:
+				// these operations never match missing fields, thus sparse indexes can be used as well
+				if !eq {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	var best []string
+	for _, ind := range qs.indexes[col] {
+		if len(ind.Fields) <= len(best) {
+			continue
+		}
+		ok := true
+		for i, f := range ind.Fields {
+			if !has(f, i < len(ind.Fields)-1) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			best = ind.Fields
+		}
+	}
+	return best
+}
+
+// withHints sets index hints for queries in the shape according to their filters.
+func (qs *QuadStore) withHints(s shape.Shape) shape.Shape {
+	switch s := s.(type) {
+	case Shape:
+		s.Hint = qs.indexHint(s.Collection, s.Filters)
+		return s
+	case Quads:
+		s.Hint = qs.indexHint(colQuads, linksFilters(s.Links))
+		return s
+	case shape.Filter:
+		s.From = qs.withHints(s.From)
+		return s
+	case shape.Page:
+		s.From = qs.withHints(s.From)
+		return s
+	case shape.Intersect:
+		out := make(shape.Intersect, 0, len(s))
+		for _, sub := range s {
+			out = append(out, qs.withHints(sub))
+		}
+		return out
+	}
+	return s
+}
+
 const int64Adjust = 1 << 63
 
 // itos serializes int64 into a sortable string 13 chars long.
@@ -150,8 +254,98 @@ func (opt Options) toFieldFilter(c shape.Comparison) ([]FieldFilter, bool) {
 	return filters, true
 }
 
+// fullTextFilters converts a full-text filter to a native text match, if the database supports it,
+// or to case-insensitive regular expressions otherwise.
+func (opt Options) fullTextFilters(f shape.FullText) ([]FieldFilter, bool) {
+	fieldPath := func(s string) []string {
+		return []string{fldValue, s}
+	}
+	var filters []FieldFilter
+	if opt.TextMatch {
+		filters = append(filters, FieldFilter{
+			Path: fieldPath(fldValData), Filter: TextMatch,
+			Value: TextQuery{Query: f.Query, Fuzziness: f.Fuzziness, Boost: f.Boost},
+		})
+	} else if f.Fuzziness > 0 {
+		// regexps cannot match fuzzy words
+		return nil, false
+	} else {
+		for _, re := range f.Regexps() {
+			filters = append(filters, FieldFilter{
+				Path: fieldPath(fldValData), Filter: Regexp, Value: String(re.String()),
+			})
+		}
+	}
+	return append(filters,
+		FieldFilter{Path: fieldPath(fldIRI), Filter: NotEqual, Value: Bool(true)},
+		FieldFilter{Path: fieldPath(fldBNode), Filter: NotEqual, Value: Bool(true)},
+	), true
+}
+
+// geoFilter converts a geospatial filter to a native one, if the database supports it.
+// Only geometry literals have a location field, thus other values are excluded by the filter.
+func (opt Options) geoFilter(f shape.GeoWithin) (FieldFilter, bool) {
+	if !opt.Geo {
+		return FieldFilter{}, false
+	}
+	var region Value
+	switch r := f.Region.(type) {
+	case geo.Circle:
+		region = GeoCircle{Center: GeoPoint{Lat: r.Point.Lat, Lng: r.Point.Lng}, Radius: r.Radius}
+	case geo.Polygon:
+		poly := make(GeoPolygon, 0, len(r))
+		for _, p := range r {
+			poly = append(poly, GeoPoint{Lat: p.Lat, Lng: p.Lng})
+		}
+		region = poly
+	default:
+		return FieldFilter{}, false
+	}
+	return FieldFilter{Path: []string{fldValue, fldValGeo}, Filter: GeoWithin, Value: region}, true
+}
+
+// literalFilter converts a language or a datatype filter to a filter on corresponding field of string literals.
+// Datatypes that are converted to native values are not stored as such, thus filters for them are not converted.
+func (opt Options) literalFilter(f shape.ValueFilter) (FieldFilter, bool) {
+	switch f := f.(type) {
+	case shape.LangFilter:
+		return FieldFilter{
+			Path: []string{fldValue, fldLang}, Filter: Regexp,
+			Value: String(`(?i)^` + regexp.QuoteMeta(f.Lang) + `$`),
+		}, true
+	case shape.DatatypeFilter:
+		if quad.HasStringConversion(f.Type) {
+			return FieldFilter{}, false
+		}
+		full, short := f.Type.Full(), f.Type.Short()
+		if full == short {
+			return FieldFilter{Path: []string{fldValue, fldType}, Filter: Equal, Value: String(full)}, true
+		}
+		return FieldFilter{Path: []string{fldValue, fldType}, Filter: In, Value: Strings{string(full), string(short)}}, true
+	}
+	return FieldFilter{}, false
+}
+
 func (qs *QuadStore) optimizeFilter(s shape.Filter) (shape.Shape, bool) {
-	if _, ok := s.From.(shape.AllNodes); !ok {
+	var (
+		base Shape               // query to add filters to
+		prev []shape.ValueFilter // filters that were not converted by previous optimizations
+	)
+	switch f := s.From.(type) {
+	case shape.AllNodes:
+		base = Shape{Collection: colNodes}
+	case Shape:
+		if !f.filterable() {
+			return s, false
+		}
+		base = f
+	case shape.Filter:
+		b, ok := f.From.(Shape)
+		if !ok || !b.filterable() {
+			return s, false
+		}
+		base, prev = b, f.Filters
+	default:
 		return s, false
 	}
 	var (
@@ -184,58 +378,361 @@ func (qs *QuadStore) optimizeFilter(s shape.Filter) (shape.Shape, bool) {
 				}...)
 			}
 			continue
+		case shape.FullText:
+			if fld, ok := qs.opt.fullTextFilters(f); ok {
+				filters = append(filters, fld...)
+				continue
+			}
+		case shape.GeoWithin:
+			if fld, ok := qs.opt.geoFilter(f); ok {
+				filters = append(filters, fld)
+				continue
+			}
+		case shape.LangFilter, shape.DatatypeFilter:
+			if fld, ok := qs.opt.literalFilter(f); ok {
+				filters = append(filters, fld)
+				continue
+			}
 		}
 		left = append(left, f)
 	}
-	if len(filters) == 0 {
+	if len(filters) == 0 && prev == nil {
 		return s, false
 	}
-	var ns shape.Shape = Shape{Collection: colNodes, Filters: filters}
+	if len(filters) != 0 {
+		base.Filters = append(append([]FieldFilter{}, base.Filters...), filters...)
+	}
+	left = append(append([]shape.ValueFilter{}, prev...), left...)
+	var ns shape.Shape = base
 	if len(left) != 0 {
 		ns = shape.Filter{From: ns, Filters: left}
 	}
 	return ns, true
 }
 
+// optimizeIntersect merges queries on nodes in the intersection into a single query.
+func (qs *QuadStore) optimizeIntersect(s shape.Intersect) (shape.Shape, bool) {
+	var (
+		filters []FieldFilter
+		left    []shape.ValueFilter
+		rest    shape.Intersect
+		n       int
+	)
+	for _, sub := range s {
+		var (
+			b  Shape
+			lf []shape.ValueFilter
+			ok bool
+		)
+		switch f := sub.(type) {
+		case Shape:
+			b, ok = f, true
+		case shape.Filter:
+			b, ok = f.From.(Shape)
+			lf = f.Filters
+		}
+		if !ok || !b.filterable() || len(b.Sort) != 0 {
+			rest = append(rest, sub)
+			continue
+		}
+		filters = append(filters, b.Filters...)
+		left = append(left, lf...)
+		n++
+	}
+	if n < 2 {
+		return s, false
+	}
+	var ns shape.Shape = Shape{Collection: colNodes, Filters: filters}
+	if len(left) != 0 {
+		ns = shape.Filter{From: ns, Filters: left}
+	}
+	if len(rest) == 0 {
+		return ns, true
+	}
+	return append(shape.Intersect{ns}, rest...), true
+}
+
 func (qs *QuadStore) optimizeQuads(s shape.Quads) (shape.Shape, bool) {
 	var (
 		links []Linkage
+		nodes []NodeLink
 		left  []shape.QuadFilter
 	)
 	for _, f := range s {
+		if _, ok := f.Values.(shape.DefaultGraph); ok && f.Dir == quad.Label {
+			links = append(links, Linkage{Dir: f.Dir})
+			continue
+		}
 		if v, ok := shape.One(f.Values); ok {
 			if h, ok := v.(NodeHash); ok {
 				links = append(links, Linkage{Dir: f.Dir, Val: h})
 				continue
 			}
 		}
+		if n, ok := f.Values.(Shape); ok && n.Collection == colNodes && len(n.Filters) != 0 &&
+			len(n.Sort) == 0 && n.Offset == 0 && n.Limit == 0 {
+			// nodes matching value filters, e.g. quads with int objects > 10
+			nodes = append(nodes, NodeLink{Dir: f.Dir, Filters: n.Filters})
+			continue
+		}
 		left = append(left, f)
 	}
-	if len(links) == 0 {
+	if len(nodes) == 0 && len(left) == 1 {
+		// quads linked to nodes of other quads, e.g. the second hop of Out().Out()
+		if p, ok := qs.pathFrom(left[0].Values); ok {
+			return p.join(left[0].Dir, links), true
+		}
+	}
+	if len(links) == 0 && len(nodes) == 0 {
 		return s, false
 	}
-	var ns shape.Shape = Quads{Links: links}
+	var ns shape.Shape = Quads{Links: links, Nodes: nodes}
 	if len(left) != 0 {
 		ns = shape.Intersect{ns, shape.Quads(left)}
 	}
-	return s, true
+	return ns, true
 }
 
-func (qs *QuadStore) optimizePage(s shape.Page) (shape.Shape, bool) {
-	if s.Skip != 0 {
+// PathStep is a single step of a Path.
+type PathStep struct {
+	Links []Linkage      // filters to select quads
+	In    quad.Direction // direction linked to a node selected by the previous step; ignored for the first step
+	Out   quad.Direction // direction of a node passed to the next step; ignored for the last step
+}
+
+// Path is a shape representing a chain of quad queries, where each step selects quads linked to nodes of quads
+// selected by the previous step. The chain is followed by the database in a single request.
+//
+// Only databases that implement Joiner support this shape.
+type Path struct {
+	Steps  []PathStep
+	Result quad.Direction // nodes of quads of the last step in a given direction; quad.Any returns quads
+}
+
+func (s Path) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	db, ok := graph.Unwrap(qs).(*QuadStore)
+	if !ok {
+		return iterator.NewError(fmt.Errorf("not a nosql database: %T", qs))
+	}
+	return NewPathIterator(db, s)
+}
+
+func (s Path) Optimize(r shape.Optimizer) (shape.Shape, bool) {
+	return s, false
+}
+
+// join returns a path that selects quads matching links and linked in a given direction to nodes selected by s.
+func (s Path) join(dir quad.Direction, links []Linkage) Path {
+	steps := make([]PathStep, len(s.Steps), len(s.Steps)+1)
+	copy(steps, s.Steps)
+	steps[len(steps)-1].Out = s.Result
+	steps = append(steps, PathStep{Links: links, In: dir})
+	return Path{Steps: steps, Result: quad.Any}
+}
+
+// joinSteps converts the path to steps of a join query on quads collection.
+func (s Path) joinSteps() []JoinStep {
+	steps := make([]JoinStep, 0, len(s.Steps))
+	for i, p := range s.Steps {
+		js := JoinStep{Collection: colQuads, Filters: linksFilters(p.Links)}
+		if i > 0 {
+			js.Field = []string{p.In.String()}
+		}
+		if i < len(s.Steps)-1 {
+			js.Next = []string{p.Out.String()}
+			if p.Out == quad.Label {
+				// quads of the default graph have no label, and must not be joined with other quads
+				js.Filters = append(js.Filters, FieldFilter{Path: js.Next, Filter: Exists, Value: Bool(true)})
+			}
+		}
+		steps = append(steps, js)
+	}
+	return steps
+}
+
+// pathFrom converts a shape that selects nodes of quads to a Path. It returns false if the shape
+// can't be converted or if the database can't follow paths.
+func (qs *QuadStore) pathFrom(s shape.Shape) (Path, bool) {
+	if _, ok := qs.db.(Joiner); !ok {
+		return Path{}, false
+	}
+	switch s := s.(type) {
+	case Path:
+		return s, s.Result != quad.Any
+	case shape.NodesFrom:
+		q, ok := s.Quads.(Quads)
+		if !ok || len(q.Nodes) != 0 || q.Offset != 0 || q.Limit != 0 {
+			return Path{}, false
+		}
+		return Path{Steps: []PathStep{{Links: q.Links}}, Result: s.Dir}, true
+	}
+	return Path{}, false
+}
+
+func (qs *QuadStore) optimizeNodesFrom(s shape.NodesFrom) (shape.Shape, bool) {
+	p, ok := s.Quads.(Path)
+	if !ok || p.Result != quad.Any {
 		return s, false
 	}
+	p.Result = s.Dir
+	return p, true
+}
+
+// sortField returns a value field that all documents matching filters have and that the database can order
+// in the same way as iterator.CompareValues does. It returns nil if there is no such field.
+func (opt Options) sortField(filters []FieldFilter) []string {
+	var field string
+	for _, f := range filters {
+		if len(f.Path) != 2 || f.Path[0] != fldValue {
+			return nil
+		}
+		switch name := f.Path[1]; name {
+		case fldIRI, fldBNode:
+			// flags of string values
+			continue
+		case fldValInt:
+			if opt.Number32 {
+				return nil
+			}
+		case fldValFloat:
+		case fldValTime:
+			if opt.TimeAsString {
+				return nil
+			}
+		default:
+			// strings are collated differently by each database
+			return nil
+		}
+		if field != "" && field != f.Path[1] {
+			return nil
+		}
+		field = f.Path[1]
+	}
+	if field == "" {
+		return nil
+	}
+	return []string{fldValue, field}
+}
+
+func (qs *QuadStore) optimizeSort(s shape.Sort) (shape.Shape, bool) {
+	switch f := s.From.(type) {
+	case Shape:
+		if len(f.Sort) != 0 {
+			// already sorted
+			return f, true
+		}
+		if f.Collection != colNodes || f.Offset != 0 || f.Limit != 0 {
+			return s, false
+		}
+		fld := qs.opt.sortField(f.Filters)
+		if fld == nil {
+			return s, false
+		}
+		f.Sort = fld
+		return f, true
+	case shape.Filter:
+		// filters keep the order of documents
+		if sf, ok := qs.optimizeSort(shape.Sort{From: f.From}); ok {
+			f.From = sf
+			return f, true
+		}
+	}
+	return s, false
+}
+
+func (qs *QuadStore) optimizePage(s shape.Page) (shape.Shape, bool) {
 	switch f := s.From.(type) {
 	case shape.AllNodes:
-		return Shape{Collection: colNodes, Limit: s.Limit}, false
+		return Shape{Collection: colNodes, Offset: s.Skip, Limit: s.Limit}, true
 	case Shape:
-		s.ApplyPage(shape.Page{Limit: f.Limit})
-		f.Limit = s.Limit
+		p := shape.Page{Skip: f.Offset, Limit: f.Limit}.ApplyPage(s)
+		if p == nil {
+			return shape.Null{}, true
+		}
+		f.Offset, f.Limit = p.Skip, p.Limit
 		return f, true
 	case Quads:
-		s.ApplyPage(shape.Page{Limit: f.Limit})
-		f.Limit = s.Limit
+		if len(f.Nodes) != 0 {
+			// quads might be queried in multiple chunks
+			break
+		}
+		p := shape.Page{Skip: f.Offset, Limit: f.Limit}.ApplyPage(s)
+		if p == nil {
+			return shape.Null{}, true
+		}
+		f.Offset, f.Limit = p.Skip, p.Limit
 		return f, true
 	}
 	return s, false
 }
+
+// Count is a shape representing a number of documents matching a query. Documents are counted by the database.
+type Count struct {
+	Collection string        // name of the collection
+	Filters    []FieldFilter // filters to select documents
+	Offset     int64         // skips a number of documents
+	Limit      int64         // limits a number of documents
+}
+
+func (s Count) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	db, ok := graph.Unwrap(qs).(*QuadStore)
+	if !ok {
+		return iterator.NewError(fmt.Errorf("not a nosql database: %T", qs))
+	}
+	return NewCountIterator(db, s.Collection, s.Offset, s.Limit, s.Filters...)
+}
+
+func (s Count) Optimize(r shape.Optimizer) (shape.Shape, bool) {
+	return s, false
+}
+
+func (qs *QuadStore) optimizeCount(s shape.Count) (shape.Shape, bool) {
+	switch f := s.Values.(type) {
+	case shape.AllNodes:
+		return Count{Collection: colNodes}, true
+	case Shape:
+		return Count{Collection: f.Collection, Filters: f.Filters, Offset: f.Offset, Limit: f.Limit}, true
+	case Quads:
+		if len(f.Nodes) != 0 {
+			break
+		}
+		return Count{Collection: colQuads, Filters: linksFilters(f.Links), Offset: f.Offset, Limit: f.Limit}, true
+	}
+	return s, false
+}
+
+// Aggregate is a shape representing an aggregate of a value field of nodes matching a query.
+// Aggregates are computed by the database.
+type Aggregate struct {
+	Func    iterator.Aggregation
+	Field   []string      // value field to aggregate
+	Filters []FieldFilter // filters to select nodes
+}
+
+func (s Aggregate) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	db, ok := graph.Unwrap(qs).(*QuadStore)
+	if !ok {
+		return iterator.NewError(fmt.Errorf("not a nosql database: %T", qs))
+	}
+	return NewAggregateIterator(db, s.Func, s.Field, s.Filters...)
+}
+
+func (s Aggregate) Optimize(r shape.Optimizer) (shape.Shape, bool) {
+	return s, false
+}
+
+func (qs *QuadStore) optimizeAggregate(s shape.Aggregate) (shape.Shape, bool) {
+	if _, ok := qs.db.(Aggregator); !ok {
+		return s, false
+	}
+	f, ok := s.Values.(Shape)
+	if !ok || f.Collection != colNodes || f.Offset != 0 || f.Limit != 0 {
+		return s, false
+	}
+	fld := qs.opt.sortField(f.Filters)
+	if fld == nil || fld[1] == fldValTime {
+		// only numeric fields are supported by all databases
+		return s, false
+	}
+	return Aggregate{Func: s.Func, Field: fld, Filters: f.Filters}, true
+}
@@ -0,0 +1,632 @@
+package nosql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
+)
+
+func TestOptimizeCount(t *testing.T) {
+	qs := &QuadStore{}
+	filter := FieldFilter{Path: []string{fldValue, fldValInt}, Filter: GT, Value: Int(1)}
+	for _, c := range []struct {
+		name   string
+		in     shape.Shape
+		expect shape.Shape
+	}{
+		{
+			name:   "all nodes",
+			in:     shape.Count{Values: shape.AllNodes{}},
+			expect: Count{Collection: colNodes},
+		},
+		{
+			name: "filter",
+			in: shape.Count{Values: shape.Filter{
+				From:    shape.AllNodes{},
+				Filters: []shape.ValueFilter{shape.Comparison{Op: iterator.CompareGT, Val: quad.Int(1)}},
+			}},
+			expect: Count{Collection: colNodes, Filters: []FieldFilter{filter}},
+		},
+		{
+			name: "quads",
+			in: shape.Count{Values: shape.Quads{
+				{Dir: quad.Subject, Values: shape.Fixed{NodeHash("a")}},
+			}},
+			expect: Count{Collection: colQuads, Filters: []FieldFilter{
+				{Path: []string{"subject"}, Filter: Equal, Value: String("a")},
+			}},
+		},
+		{
+			name: "skip",
+			in: shape.Count{Values: shape.Page{
+				From: shape.AllNodes{},
+				Skip: 5, Limit: 10,
+			}},
+			expect: Count{Collection: colNodes, Offset: 5, Limit: 10},
+		},
+		{
+			name: "limit",
+			in: shape.Count{Values: shape.Page{
+				From:  shape.Quads{{Dir: quad.Object, Values: shape.Fixed{NodeHash("b")}}},
+				Limit: 10,
+			}},
+			expect: Count{Collection: colQuads, Limit: 10, Filters: []FieldFilter{
+				{Path: []string{"object"}, Filter: Equal, Value: String("b")},
+			}},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s, _ := c.in.Optimize(qs)
+			require.Equal(t, c.expect, s)
+		})
+	}
+}
+
+func TestOptimizePage(t *testing.T) {
+	qs := &QuadStore{}
+	quads := shape.Quads{{Dir: quad.Object, Values: shape.Fixed{NodeHash("b")}}}
+	links := []Linkage{{Dir: quad.Object, Val: NodeHash("b")}}
+	for _, c := range []struct {
+		name   string
+		in     shape.Shape
+		expect shape.Shape
+	}{
+		{
+			name:   "all nodes",
+			in:     shape.Page{From: shape.AllNodes{}, Skip: 3, Limit: 5},
+			expect: Shape{Collection: colNodes, Offset: 3, Limit: 5},
+		},
+		{
+			name:   "quads",
+			in:     shape.Page{From: quads, Skip: 3},
+			expect: Quads{Links: links, Offset: 3},
+		},
+		{
+			name: "nested",
+			in: shape.Page{
+				From: shape.Page{From: quads, Skip: 2, Limit: 10},
+				Skip: 3, Limit: 5,
+			},
+			expect: Quads{Links: links, Offset: 5, Limit: 5},
+		},
+		{
+			name: "empty",
+			in: shape.Page{
+				From: shape.Page{From: quads, Limit: 3},
+				Skip: 3,
+			},
+			expect: shape.Null{},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s, _ := c.in.Optimize(qs)
+			require.Equal(t, c.expect, s)
+		})
+	}
+}
+
+func TestOptimizeSort(t *testing.T) {
+	gt := func(v quad.Value) shape.Filter {
+		return shape.Filter{
+			From:    shape.AllNodes{},
+			Filters: []shape.ValueFilter{shape.Comparison{Op: iterator.CompareGT, Val: v}},
+		}
+	}
+	intFilter := FieldFilter{Path: []string{fldValue, fldValInt}, Filter: GT, Value: Int(1)}
+	for _, c := range []struct {
+		name   string
+		opt    Options
+		in     shape.Shape
+		expect shape.Shape
+	}{
+		{
+			name: "int",
+			in:   shape.Sort{From: gt(quad.Int(1))},
+			expect: Shape{Collection: colNodes, Sort: []string{fldValue, fldValInt},
+				Filters: []FieldFilter{intFilter},
+			},
+		},
+		{
+			name: "page",
+			in:   shape.Page{From: shape.Sort{From: gt(quad.Int(1))}, Skip: 2, Limit: 3},
+			expect: Shape{Collection: colNodes, Sort: []string{fldValue, fldValInt}, Offset: 2, Limit: 3,
+				Filters: []FieldFilter{intFilter},
+			},
+		},
+		{
+			name:   "int32",
+			opt:    Options{Number32: true},
+			in:     shape.Sort{From: gt(quad.Int(1))},
+			expect: shape.Sort{From: Shape{Collection: colNodes, Filters: []FieldFilter{intFilter}}},
+		},
+		{
+			name: "string",
+			in:   shape.Sort{From: gt(quad.String("a"))},
+			expect: shape.Sort{From: Shape{Collection: colNodes, Filters: []FieldFilter{
+				{Path: []string{fldValue, fldValData}, Filter: GT, Value: String("a")},
+				{Path: []string{fldValue, fldIRI}, Filter: NotEqual, Value: Bool(true)},
+				{Path: []string{fldValue, fldBNode}, Filter: NotEqual, Value: Bool(true)},
+			}}},
+		},
+		{
+			name: "limited",
+			in:   shape.Sort{From: shape.Page{From: gt(quad.Int(1)), Limit: 3}},
+			expect: shape.Sort{From: Shape{Collection: colNodes, Limit: 3,
+				Filters: []FieldFilter{intFilter},
+			}},
+		},
+		{
+			name:   "all nodes",
+			in:     shape.Sort{From: shape.AllNodes{}},
+			expect: shape.Sort{From: shape.AllNodes{}},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			qs := &QuadStore{opt: c.opt}
+			s, _ := c.in.Optimize(qs)
+			require.Equal(t, c.expect, s)
+		})
+	}
+}
+
+type aggregatorDB struct {
+	Database
+}
+
+func (aggregatorDB) Aggregate(ctx context.Context, col string, fnc AggregateFunc, field []string, filters ...FieldFilter) (Value, error) {
+	return nil, nil
+}
+
+func TestOptimizeAggregate(t *testing.T) {
+	gt := func(v quad.Value) shape.Filter {
+		return shape.Filter{
+			From:    shape.AllNodes{},
+			Filters: []shape.ValueFilter{shape.Comparison{Op: iterator.CompareGT, Val: v}},
+		}
+	}
+	for _, c := range []struct {
+		name   string
+		db     Database
+		in     shape.Shape
+		expect shape.Shape
+	}{
+		{
+			name: "sum",
+			db:   aggregatorDB{},
+			in:   shape.Aggregate{Func: iterator.AggregateSum, Values: shape.Sort{From: gt(quad.Float(1))}},
+			expect: Aggregate{Func: iterator.AggregateSum, Field: []string{fldValue, fldValFloat}, Filters: []FieldFilter{
+				{Path: []string{fldValue, fldValFloat}, Filter: GT, Value: Float(1)},
+			}},
+		},
+		{
+			name: "time",
+			db:   aggregatorDB{},
+			in:   shape.Aggregate{Func: iterator.AggregateMax, Values: gt(quad.Time{})},
+			expect: shape.Aggregate{Func: iterator.AggregateMax, Values: Shape{Collection: colNodes, Filters: []FieldFilter{
+				{Path: []string{fldValue, fldValTime}, Filter: GT, Value: Time{}},
+			}}},
+		},
+		{
+			name: "not supported",
+			in:   shape.Aggregate{Func: iterator.AggregateMin, Values: gt(quad.Int(1))},
+			expect: shape.Aggregate{Func: iterator.AggregateMin, Values: Shape{Collection: colNodes, Filters: []FieldFilter{
+				{Path: []string{fldValue, fldValInt}, Filter: GT, Value: Int(1)},
+			}}},
+		},
+		{
+			name:   "empty sum",
+			db:     aggregatorDB{},
+			in:     shape.Aggregate{Func: iterator.AggregateSum, Values: shape.Null{}},
+			expect: shape.Fixed{graph.PreFetched(quad.Int(0))},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			qs := &QuadStore{db: c.db}
+			s, _ := c.in.Optimize(qs)
+			require.Equal(t, c.expect, s)
+		})
+	}
+}
+
+func TestOptimizeFullText(t *testing.T) {
+	flags := []FieldFilter{
+		{Path: []string{fldValue, fldIRI}, Filter: NotEqual, Value: Bool(true)},
+		{Path: []string{fldValue, fldBNode}, Filter: NotEqual, Value: Bool(true)},
+	}
+	filter := func(f shape.FullText) shape.Shape {
+		return shape.Filter{From: shape.AllNodes{}, Filters: []shape.ValueFilter{f}}
+	}
+	for _, c := range []struct {
+		name   string
+		opt    Options
+		in     shape.Shape
+		expect shape.Shape
+	}{
+		{
+			name: "text match",
+			opt:  Options{TextMatch: true},
+			in:   filter(shape.FullText{Query: "quick fox", Fuzziness: 1, Boost: 2}),
+			expect: Shape{Collection: colNodes, Filters: append([]FieldFilter{
+				{Path: []string{fldValue, fldValData}, Filter: TextMatch, Value: TextQuery{Query: "quick fox", Fuzziness: 1, Boost: 2}},
+			}, flags...)},
+		},
+		{
+			name: "regexp",
+			in:   filter(shape.FullText{Query: "Quick fox"}),
+			expect: Shape{Collection: colNodes, Filters: append([]FieldFilter{
+				{Path: []string{fldValue, fldValData}, Filter: Regexp, Value: String("(?i)quick")},
+				{Path: []string{fldValue, fldValData}, Filter: Regexp, Value: String("(?i)fox")},
+			}, flags...)},
+		},
+		{
+			name:   "fuzzy",
+			in:     filter(shape.FullText{Query: "quick", Fuzziness: 1}),
+			expect: filter(shape.FullText{Query: "quick", Fuzziness: 1}),
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			qs := &QuadStore{opt: c.opt}
+			s, _ := c.in.Optimize(qs)
+			require.Equal(t, c.expect, s)
+		})
+	}
+}
+
+func TestOptimizeLiteralFilters(t *testing.T) {
+	filter := func(f shape.ValueFilter) shape.Shape {
+		return shape.Filter{From: shape.AllNodes{}, Filters: []shape.ValueFilter{f}}
+	}
+	for _, c := range []struct {
+		name   string
+		in     shape.Shape
+		expect shape.Shape
+	}{
+		{
+			name: "lang",
+			in:   filter(shape.LangFilter{Lang: "en-US"}),
+			expect: Shape{Collection: colNodes, Filters: []FieldFilter{
+				{Path: []string{fldValue, fldLang}, Filter: Regexp, Value: String(`(?i)^en-US$`)},
+			}},
+		},
+		{
+			name: "datatype",
+			in:   filter(shape.DatatypeFilter{Type: "ex:age"}),
+			expect: Shape{Collection: colNodes, Filters: []FieldFilter{
+				{Path: []string{fldValue, fldType}, Filter: Equal, Value: String("ex:age")},
+			}},
+		},
+		{
+			name:   "native datatype",
+			in:     filter(shape.DatatypeFilter{Type: "http://www.w3.org/2001/XMLSchema#integer"}),
+			expect: filter(shape.DatatypeFilter{Type: "http://www.w3.org/2001/XMLSchema#integer"}),
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			qs := &QuadStore{}
+			s, _ := c.in.Optimize(qs)
+			require.Equal(t, c.expect, s)
+		})
+	}
+}
+
+func TestOptimizeGeo(t *testing.T) {
+	filter := func(r geo.Region) shape.Shape {
+		return shape.Filter{From: shape.AllNodes{}, Filters: []shape.ValueFilter{shape.GeoWithin{Region: r}}}
+	}
+	circle := geo.Circle{Point: geo.Point{Lat: 48.8566, Lng: 2.3522}, Radius: 5}
+	for _, c := range []struct {
+		name   string
+		opt    Options
+		in     shape.Shape
+		expect shape.Shape
+	}{
+		{
+			name: "circle",
+			opt:  Options{Geo: true},
+			in:   filter(circle),
+			expect: Shape{Collection: colNodes, Filters: []FieldFilter{
+				{Path: []string{fldValue, fldValGeo}, Filter: GeoWithin, Value: GeoCircle{
+					Center: GeoPoint{Lat: 48.8566, Lng: 2.3522}, Radius: 5,
+				}},
+			}},
+		},
+		{
+			name: "polygon",
+			opt:  Options{Geo: true},
+			in:   filter(geo.Polygon{{Lat: 48, Lng: 2}, {Lat: 48, Lng: 3}, {Lat: 49, Lng: 3}}),
+			expect: Shape{Collection: colNodes, Filters: []FieldFilter{
+				{Path: []string{fldValue, fldValGeo}, Filter: GeoWithin, Value: GeoPolygon{
+					{Lat: 48, Lng: 2}, {Lat: 48, Lng: 3}, {Lat: 49, Lng: 3},
+				}},
+			}},
+		},
+		{
+			name:   "not supported",
+			in:     filter(circle),
+			expect: filter(circle),
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			qs := &QuadStore{opt: c.opt}
+			s, _ := c.in.Optimize(qs)
+			require.Equal(t, c.expect, s)
+		})
+	}
+}
+
+func TestOptimizeQuads(t *testing.T) {
+	qs := &QuadStore{}
+	for _, c := range []struct {
+		name   string
+		in     shape.Shape
+		expect shape.Shape
+	}{
+		{
+			name: "label",
+			in: shape.Quads{
+				{Dir: quad.Predicate, Values: shape.Fixed{NodeHash("p")}},
+				{Dir: quad.Label, Values: shape.Fixed{NodeHash("g")}},
+			},
+			expect: Quads{Links: []Linkage{
+				{Dir: quad.Predicate, Val: "p"},
+				{Dir: quad.Label, Val: "g"},
+			}},
+		},
+		{
+			name: "default graph",
+			in: shape.Quads{
+				{Dir: quad.Predicate, Values: shape.Fixed{NodeHash("p")}},
+				{Dir: quad.Label, Values: shape.DefaultGraph{}},
+			},
+			expect: Quads{Links: []Linkage{
+				{Dir: quad.Predicate, Val: "p"},
+				{Dir: quad.Label},
+			}},
+		},
+		{
+			name: "node filter",
+			in: shape.Quads{
+				{Dir: quad.Predicate, Values: shape.Fixed{NodeHash("p")}},
+				{Dir: quad.Object, Values: shape.Filter{
+					From:    shape.AllNodes{},
+					Filters: []shape.ValueFilter{shape.Comparison{Op: iterator.CompareGT, Val: quad.Int(10)}},
+				}},
+			},
+			expect: Quads{
+				Links: []Linkage{{Dir: quad.Predicate, Val: "p"}},
+				Nodes: []NodeLink{{Dir: quad.Object, Filters: []FieldFilter{
+					{Path: []string{fldValue, fldValInt}, Filter: GT, Value: Int(10)},
+				}}},
+			},
+		},
+		{
+			name: "page of node filter",
+			in: shape.Page{
+				From: shape.Quads{
+					{Dir: quad.Object, Values: shape.Filter{
+						From:    shape.AllNodes{},
+						Filters: []shape.ValueFilter{shape.Comparison{Op: iterator.CompareGT, Val: quad.Int(10)}},
+					}},
+				},
+				Limit: 5,
+			},
+			expect: shape.Page{
+				From: Quads{Nodes: []NodeLink{{Dir: quad.Object, Filters: []FieldFilter{
+					{Path: []string{fldValue, fldValInt}, Filter: GT, Value: Int(10)},
+				}}}},
+				Limit: 5,
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s, _ := c.in.Optimize(qs)
+			require.Equal(t, c.expect, s)
+		})
+	}
+	filters := linksFilters([]Linkage{{Dir: quad.Predicate, Val: "p"}, {Dir: quad.Label}})
+	require.Equal(t, []FieldFilter{
+		{Path: []string{fldPredicate}, Filter: Equal, Value: String("p")},
+		{Path: []string{fldLabel}, Filter: Exists, Value: Bool(false)},
+	}, filters)
+}
+
+func TestOptimizeMergeFilters(t *testing.T) {
+	qs := &QuadStore{}
+	gt := shape.Comparison{Op: iterator.CompareGT, Val: quad.Int(1)}
+	lt := shape.Comparison{Op: iterator.CompareLT, Val: quad.Int(10)}
+	fuzzy := shape.FullText{Query: "quick", Fuzziness: 1}
+	filters := []FieldFilter{
+		{Path: []string{fldValue, fldValInt}, Filter: GT, Value: Int(1)},
+		{Path: []string{fldValue, fldValInt}, Filter: LT, Value: Int(10)},
+	}
+	for _, c := range []struct {
+		name   string
+		in     shape.Shape
+		expect shape.Shape
+	}{
+		{
+			name: "nested filters",
+			in: shape.Filter{
+				From:    shape.Filter{From: shape.AllNodes{}, Filters: []shape.ValueFilter{fuzzy, gt}},
+				Filters: []shape.ValueFilter{lt},
+			},
+			expect: shape.Filter{
+				From:    Shape{Collection: colNodes, Filters: filters},
+				Filters: []shape.ValueFilter{fuzzy},
+			},
+		},
+		{
+			name: "intersect",
+			in: shape.Intersect{
+				shape.Filter{From: shape.AllNodes{}, Filters: []shape.ValueFilter{gt}},
+				shape.Filter{From: shape.AllNodes{}, Filters: []shape.ValueFilter{lt, fuzzy}},
+			},
+			expect: shape.Filter{
+				From:    Shape{Collection: colNodes, Filters: filters},
+				Filters: []shape.ValueFilter{fuzzy},
+			},
+		},
+		{
+			name: "page",
+			in: shape.Filter{
+				From:    shape.Page{From: shape.Filter{From: shape.AllNodes{}, Filters: []shape.ValueFilter{gt}}, Limit: 5},
+				Filters: []shape.ValueFilter{lt},
+			},
+			expect: shape.Filter{
+				From:    Shape{Collection: colNodes, Filters: filters[:1], Limit: 5},
+				Filters: []shape.ValueFilter{lt},
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s, _ := c.in.Optimize(qs)
+			require.Equal(t, c.expect, s)
+		})
+	}
+}
+
+type joinerDB struct {
+	Database
+}
+
+func (joinerDB) Join(ctx context.Context, steps []JoinStep) JoinIterator {
+	return nil
+}
+
+func TestOptimizePath(t *testing.T) {
+	out := func(from shape.Shape, pred string) shape.Shape {
+		return shape.NodesFrom{Dir: quad.Object, Quads: shape.Quads{
+			{Dir: quad.Subject, Values: from},
+			{Dir: quad.Predicate, Values: shape.Fixed{NodeHash(pred)}},
+		}}
+	}
+	in := out(out(out(shape.Fixed{NodeHash("a")}, "p1"), "p2"), "p3")
+	links := func(pred string) []Linkage {
+		return []Linkage{{Dir: quad.Predicate, Val: NodeHash(pred)}}
+	}
+	path := Path{
+		Steps: []PathStep{
+			{Links: []Linkage{{Dir: quad.Subject, Val: "a"}, {Dir: quad.Predicate, Val: "p1"}}, Out: quad.Object},
+			{Links: links("p2"), In: quad.Subject, Out: quad.Object},
+			{Links: links("p3"), In: quad.Subject},
+		},
+		Result: quad.Object,
+	}
+
+	qs := &QuadStore{db: joinerDB{}}
+	s, _ := in.Optimize(qs)
+	require.Equal(t, path, s)
+
+	require.Equal(t, []JoinStep{
+		{Collection: colQuads, Filters: linksFilters(path.Steps[0].Links), Next: []string{fldObject}},
+		{Collection: colQuads, Filters: linksFilters(links("p2")), Field: []string{fldSubject}, Next: []string{fldObject}},
+		{Collection: colQuads, Filters: linksFilters(links("p3")), Field: []string{fldSubject}},
+	}, path.joinSteps())
+
+	// databases without joins use a separate query for each step
+	qs = &QuadStore{}
+	s, _ = in.Optimize(qs)
+	_, ok := s.(shape.NodesFrom)
+	require.True(t, ok, "%#v", s)
+
+	// quads of the default graph are not joined by label
+	path = Path{Steps: []PathStep{{Out: quad.Label}, {In: quad.Subject}}, Result: quad.Any}
+	require.Equal(t, []JoinStep{
+		{Collection: colQuads, Filters: []FieldFilter{{Path: []string{fldLabel}, Filter: Exists, Value: Bool(true)}}, Next: []string{fldLabel}},
+		{Collection: colQuads, Filters: []FieldFilter{}, Field: []string{fldSubject}},
+	}, path.joinSteps())
+}
+
+func TestIndexesFromOptions(t *testing.T) {
+	exp := map[string][]Index{
+		colQuads: {{Fields: []string{fldSubject, fldPredicate}}},
+		colNodes: {{Fields: []string{"value.str"}}},
+	}
+	for _, v := range []interface{}{
+		"quads:subject,predicate; nodes:value.str",
+		[]string{"quads:subject,predicate", "nodes:value.str"},
+		[]interface{}{"quads:subject, predicate", "nodes:value.str"},
+	} {
+		indexes, err := indexesFromOptions(graph.Options{"indexes": v})
+		require.NoError(t, err)
+		require.Equal(t, exp, indexes)
+	}
+	for _, v := range []interface{}{"log:id", "quads", "quads:subject,", 1} {
+		_, err := indexesFromOptions(graph.Options{"indexes": v})
+		require.Error(t, err, "%v", v)
+	}
+}
+
+func TestOptimizeIndexHint(t *testing.T) {
+	qs := &QuadStore{indexes: map[string][]Index{
+		colQuads: {
+			{Fields: []string{fldSubject, fldPredicate}},
+			{Fields: []string{fldSubject, fldPredicate, fldLabel}},
+		},
+		colNodes: {{Fields: []string{fldValue + "." + fldValData}}},
+	}}
+	for _, c := range []struct {
+		name   string
+		in     shape.Shape
+		expect shape.Shape
+	}{
+		{
+			name: "compound",
+			in: shape.Quads{
+				{Dir: quad.Predicate, Values: shape.Fixed{NodeHash("p")}},
+				{Dir: quad.Subject, Values: shape.Fixed{NodeHash("s")}},
+			},
+			expect: Quads{
+				Links: []Linkage{{Dir: quad.Predicate, Val: "p"}, {Dir: quad.Subject, Val: "s"}},
+				Hint:  []string{fldSubject, fldPredicate},
+			},
+		},
+		{
+			name: "default graph",
+			in: shape.Quads{
+				{Dir: quad.Predicate, Values: shape.Fixed{NodeHash("p")}},
+				{Dir: quad.Subject, Values: shape.Fixed{NodeHash("s")}},
+				{Dir: quad.Label, Values: shape.DefaultGraph{}},
+			},
+			expect: Quads{
+				Links: []Linkage{{Dir: quad.Predicate, Val: "p"}, {Dir: quad.Subject, Val: "s"}, {Dir: quad.Label}},
+				Hint:  []string{fldSubject, fldPredicate},
+			},
+		},
+		{
+			name: "no index",
+			in: shape.Quads{
+				{Dir: quad.Predicate, Values: shape.Fixed{NodeHash("p")}},
+			},
+			expect: Quads{
+				Links: []Linkage{{Dir: quad.Predicate, Val: "p"}},
+			},
+		},
+		{
+			name: "prefix search",
+			in: shape.Filter{
+				From:    shape.AllNodes{},
+				Filters: []shape.ValueFilter{shape.Wildcard{Pattern: "abc%"}},
+			},
+			expect: Shape{
+				Collection: colNodes,
+				Filters: []FieldFilter{
+					{Path: []string{fldValue, fldValData}, Filter: Regexp, Value: String("^abc")},
+				},
+				Hint: []string{fldValue + "." + fldValData},
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s, _ := c.in.Optimize(qs)
+			require.Equal(t, c.expect, s)
+		})
+	}
+}
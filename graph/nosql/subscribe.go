@@ -0,0 +1,84 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nosql
+
+import (
+	"context"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+var _ graph.Subscriber = (*QuadStore)(nil)
+
+// logChange decodes a change from a log document. It returns false for entries that are not single deltas.
+func logChange(seq int64, d Document) (graph.Change, bool) {
+	var action graph.Procedure
+	switch op, _ := d["op"].(String); op {
+	case "AddQuadPQ":
+		action = graph.Add
+	case "DeleteQuadPQ":
+		action = graph.Delete
+	default:
+		return graph.Change{}, false
+	}
+	data, _ := d["data"].(Bytes)
+	var q pquads.Quad
+	if err := q.Unmarshal(data); err != nil {
+		return graph.Change{}, false
+	}
+	c := graph.Change{
+		Delta:   graph.Delta{Quad: q.ToNative(), Action: action},
+		Horizon: seq,
+	}
+	if ts, ok := d["ts"].(Time); ok {
+		c.Timestamp = time.Time(ts)
+	}
+	return c, true
+}
+
+// Subscribe implements graph.Subscriber by watching the log collection. Each subscriber opens
+// a separate stream of changes. It returns graph.ErrNotSupported if the database cannot watch for changes.
+//
+// Horizons of changes are assigned by the database and are only guaranteed to increase monotonically.
+// The stream is opened asynchronously, thus changes applied right after the call may be missed.
+func (qs *QuadStore) Subscribe(ctx context.Context) (*graph.Subscription, error) {
+	w, ok := qs.db.(ChangeWatcher)
+	if !ok {
+		return nil, graph.ErrNotSupported
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	n := &graph.Notifier{}
+	sub := n.Subscribe(ctx)
+	go func() {
+		defer cancel()
+		err := w.WatchInserts(ctx, colLog, func(seq int64, d Document) error {
+			if !n.Active() {
+				// subscriber is gone
+				return context.Canceled
+			}
+			if c, ok := logChange(seq, d); ok {
+				n.Notify([]graph.Change{c})
+			}
+			return nil
+		})
+		if err == nil {
+			err = ctx.Err()
+		}
+		n.CloseAll(err)
+	}()
+	return sub, nil
+}
@@ -63,6 +63,38 @@ type Strings []string
 
 func (Strings) isValue() {}
 
+// TextQuery is a full-text query used as a value of TextMatch filter. It cannot be stored in the database.
+type TextQuery struct {
+	Query     string  // all words of the query must be present in the text
+	Fuzziness int     // maximal number of edits for each word
+	Boost     float64 // relevance multiplier; ignored if not positive
+}
+
+func (TextQuery) isValue() {}
+
+// GeoPoint is a geographic location in WGS84 degrees.
+//
+// Databases store it in a native format that can be indexed for GeoWithin filters.
+type GeoPoint struct {
+	Lat, Lng float64
+}
+
+func (GeoPoint) isValue() {}
+
+// GeoCircle is a set of points within a given distance from the center. It is used as a value of GeoWithin filter
+// and cannot be stored in the database.
+type GeoCircle struct {
+	Center GeoPoint
+	Radius float64 // in kilometers
+}
+
+func (GeoCircle) isValue() {}
+
+// GeoPolygon is a closed ring of points. It is used as a value of GeoWithin filter and cannot be stored in the database.
+type GeoPolygon []GeoPoint
+
+func (GeoPolygon) isValue() {}
+
 // ValuesEqual returns true if values are strictly equal.
 func ValuesEqual(v1, v2 Value) bool {
 	switch v1 := v2.(type) {
@@ -33,6 +33,56 @@ var filterMatch = []struct {
 		d:   Document{"value1": Document{"str": String("bob")}},
 		exp: true,
 	},
+	{
+		f:   FieldFilter{Path: []string{"value", "str"}, Filter: TextMatch, Value: TextQuery{Query: "Bob smith"}},
+		d:   Document{"value": Document{"str": String("bob the smith")}},
+		exp: true,
+	},
+	{
+		f:   FieldFilter{Path: []string{"value", "str"}, Filter: TextMatch, Value: TextQuery{Query: "bob smyth"}},
+		d:   Document{"value": Document{"str": String("bob the smith")}},
+		exp: false,
+	},
+	{
+		f:   FieldFilter{Path: []string{"value", "geo"}, Filter: GeoWithin, Value: GeoCircle{Center: GeoPoint{Lat: 48.8566, Lng: 2.3522}, Radius: 5}},
+		d:   Document{"value": Document{"geo": GeoPoint{Lat: 48.86, Lng: 2.34}}},
+		exp: true,
+	},
+	{
+		f:   FieldFilter{Path: []string{"value", "geo"}, Filter: GeoWithin, Value: GeoPolygon{{Lat: 48, Lng: 2}, {Lat: 48, Lng: 3}, {Lat: 49, Lng: 3}}},
+		d:   Document{"value": Document{"geo": GeoPoint{Lat: 48.2, Lng: 2.9}}},
+		exp: true,
+	},
+	{
+		f:   FieldFilter{Path: []string{"value", "geo"}, Filter: GeoWithin, Value: GeoPolygon{{Lat: 48, Lng: 2}, {Lat: 48, Lng: 3}, {Lat: 49, Lng: 3}}},
+		d:   Document{"value": Document{"str": String("POINT(2.9 48.2)")}},
+		exp: false,
+	},
+	{
+		f:   FieldFilter{Path: []string{"label"}, Filter: Exists, Value: Bool(false)},
+		d:   Document{"subject": String("a")},
+		exp: true,
+	},
+	{
+		f:   FieldFilter{Path: []string{"label"}, Filter: Exists, Value: Bool(false)},
+		d:   Document{"subject": String("a"), "label": String("g")},
+		exp: false,
+	},
+	{
+		f:   FieldFilter{Path: []string{"value", "int"}, Filter: Exists, Value: Bool(true)},
+		d:   Document{"value": Document{"int": Int(1)}},
+		exp: true,
+	},
+	{
+		f:   FieldFilter{Path: []string{"object"}, Filter: In, Value: Strings{"a", "b"}},
+		d:   Document{"object": String("b")},
+		exp: true,
+	},
+	{
+		f:   FieldFilter{Path: []string{"object"}, Filter: In, Value: Strings{"a", "b"}},
+		d:   Document{"object": String("c")},
+		exp: false,
+	},
 }
 
 func TestFilterMatch(t *testing.T) {
@@ -29,6 +29,8 @@ import (
 func join(its ...shape.Shape) shape.Shape {
 	if len(its) == 0 {
 		return shape.Null{}
+	} else if len(its) == 1 {
+		return its[0]
 	} else if _, ok := its[0].(shape.AllNodes); ok {
 		return join(its[1:]...)
 	}
@@ -77,6 +79,20 @@ func isNodeMorphism(nodes ...graph.Value) morphism {
 	}
 }
 
+// searchMorphism is the set of nodes found by an index search, such as shape.TextSearch or shape.GeoSearch.
+func searchMorphism(s shape.Shape) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return searchMorphism(s), ctx },
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			if _, ok := in.(shape.AllNodes); ok {
+				return s, ctx
+			}
+			// search results are usually small, so join them first
+			return join(s, in), ctx
+		},
+	}
+}
+
 // filterMorphism is the set of nodes that passes filters.
 func filterMorphism(filt []shape.ValueFilter) morphism {
 	return morphism{
@@ -188,6 +204,22 @@ func labelContextMorphism(tags []string, via ...interface{}) morphism {
 	}
 }
 
+// labelShapeMorphism restricts the following operations to quads with labels from a given set.
+func labelShapeMorphism(labels shape.Shape) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) {
+			out := ctx.copy()
+			ctx.labelSet = labels
+			return labelShapeMorphism(labels), &out
+		},
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			out := ctx.copy()
+			out.labelSet = labels
+			return in, &out
+		},
+	}
+}
+
 // labelsMorphism iterates to the uniqified set of labels from
 // the given set of nodes in the path.
 func labelsMorphism() morphism {
@@ -286,10 +318,10 @@ func (s iteratorBuilder) Optimize(r shape.Optimizer) (shape.Shape, bool) {
 	return s, false
 }
 
-func followRecursiveMorphism(p *Path, maxDepth int, depthTags []string) morphism {
+func followRecursiveMorphism(p *Path, maxDepth int, depthTags, pathTags []string) morphism {
 	return morphism{
 		Reversal: func(ctx *pathContext) (morphism, *pathContext) {
-			return followRecursiveMorphism(p.Reverse(), maxDepth, depthTags), ctx
+			return followRecursiveMorphism(p.Reverse(), maxDepth, depthTags, pathTags), ctx
 		},
 		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
 			return iteratorBuilder(func(qs graph.QuadStore) graph.Iterator {
@@ -298,6 +330,9 @@ func followRecursiveMorphism(p *Path, maxDepth int, depthTags []string) morphism
 				for _, s := range depthTags {
 					it.AddDepthTag(s)
 				}
+				for _, s := range pathTags {
+					it.AddPathTag(s)
+				}
 				return it
 			}), ctx
 		},
@@ -324,6 +359,16 @@ func uniqueMorphism() morphism {
 	}
 }
 
+// orderMorphism sorts values of current path.
+func orderMorphism() morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return orderMorphism(), ctx },
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			return shape.Sort{From: in}, ctx
+		},
+	}
+}
+
 func saveMorphism(via interface{}, tag string) morphism {
 	return morphism{
 		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return saveMorphism(via, tag), ctx },
@@ -421,6 +466,16 @@ func limitMorphism(v int64) morphism {
 	}
 }
 
+// aggregateMorphism will return an aggregate of values.
+func aggregateMorphism(fnc iterator.Aggregation) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return aggregateMorphism(fnc), ctx },
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			return shape.Aggregate{Values: in, Func: fnc}, ctx
+		},
+	}
+}
+
 // countMorphism will return count of values.
 func countMorphism() morphism {
 	return morphism{
@@ -17,11 +17,15 @@ package path
 import (
 	"context"
 	"regexp"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/graph/temporal"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
+	"github.com/cayleygraph/cayley/quad/vector"
 )
 
 type applyMorphism func(shape.Shape, *pathContext) (shape.Shape, *pathContext)
@@ -83,7 +87,7 @@ func StartMorphism(nodes ...quad.Value) *Path {
 }
 
 func newPath(qs graph.QuadStore, m ...morphism) *Path {
-	qs = graph.Unwrap(qs)
+	qs = graph.UnwrapHandle(qs)
 	return &Path{
 		stack: m,
 		qs:    qs,
@@ -175,6 +179,46 @@ func (p *Path) RegexWithRefs(pattern *regexp.Regexp) *Path {
 	return p.Filters(shape.Regexp{Re: pattern, Refs: true})
 }
 
+// TextSearch represents the nodes that are subjects of string literals matching a full-text query.
+// If predicates are set, only their values are searched. Text index of the QuadStore is used if available.
+func (p *Path) TextSearch(query string, preds ...quad.IRI) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, searchMorphism(shape.TextSearch{Query: query, Predicates: preds}))
+	return np
+}
+
+// NearPoint represents the nodes that are subjects of geometry literals within a given distance
+// from a point, in kilometers. If predicates are set, only their values are checked.
+// Geospatial index of the QuadStore is used if available.
+func (p *Path) NearPoint(lat, lng, radius float64, preds ...quad.IRI) *Path {
+	return p.GeoWithin(geo.Circle{Point: geo.Point{Lat: lat, Lng: lng}, Radius: radius}, preds...)
+}
+
+// WithinPolygon represents the nodes that are subjects of geometry literals inside a polygon.
+// If predicates are set, only their values are checked. Geospatial index of the QuadStore is used if available.
+func (p *Path) WithinPolygon(poly geo.Polygon, preds ...quad.IRI) *Path {
+	return p.GeoWithin(poly, preds...)
+}
+
+// GeoWithin represents the nodes that are subjects of geometry literals inside a region.
+// If predicates are set, only their values are checked. Geospatial index of the QuadStore is used if available.
+func (p *Path) GeoWithin(r geo.Region, preds ...quad.IRI) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, searchMorphism(shape.GeoSearch{Region: r, Predicates: preds}))
+	return np
+}
+
+// NearestTo represents the nodes that are subjects of k vector literals nearest to a given vector,
+// according to the metric of the vector index. If predicates are set, only their values are compared.
+// Vector index of the QuadStore is used if available, otherwise literals are scanned using cosine distance.
+//
+// Nearest nodes are found first and then intersected with the path, thus the result may have less than k nodes.
+func (p *Path) NearestTo(vec vector.Vector, k int, preds ...quad.IRI) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, searchMorphism(shape.VectorSearch{Vector: vec, Predicates: preds, Limit: k}))
+	return np
+}
+
 // Filter represents the nodes that are passing comparison with provided value.
 func (p *Path) Filter(op iterator.Operator, node quad.Value) *Path {
 	return p.Filters(shape.Comparison{Op: op, Val: node})
@@ -343,6 +387,16 @@ func (p *Path) Unique() *Path {
 	return np
 }
 
+// Order updates the current Path to return nodes in ascending order.
+//
+// Numbers are ordered numerically, times chronologically and strings lexicographically.
+// See iterator.CompareValues for the order of values of different types.
+func (p *Path) Order() *Path {
+	np := p.clone()
+	np.stack = append(np.stack, orderMorphism())
+	return np
+}
+
 // Follow allows you to stitch two paths together. The resulting path will start
 // from where the first path left off and continue iterating down the path given.
 func (p *Path) Follow(path *Path) *Path {
@@ -377,6 +431,15 @@ func (p *Path) FollowReverse(path *Path) *Path {
 //
 // This is a very expensive operation in practice. Be sure to use it wisely.
 func (p *Path) FollowRecursive(via interface{}, maxDepth int, depthTags []string) *Path {
+	return p.FollowRecursiveWithPath(via, maxDepth, depthTags, nil)
+}
+
+// FollowRecursiveWithPath is the same as FollowRecursive, but also saves nodes on the path to each result.
+//
+// Each of pathTags is used as a prefix of tags for nodes on the path: a start node is saved to
+// prefix+"0", a node reached after the first step to prefix+"1", and so on up to the result node,
+// which is saved to prefix+depth. Only the path through which the node was reached the first time is saved.
+func (p *Path) FollowRecursiveWithPath(via interface{}, maxDepth int, depthTags, pathTags []string) *Path {
 	var path *Path
 	switch v := via.(type) {
 	case string:
@@ -389,7 +452,7 @@ func (p *Path) FollowRecursive(via interface{}, maxDepth int, depthTags []string
 		panic("did not pass a string predicate or a Path to FollowRecursive")
 	}
 	np := p.clone()
-	np.stack = append(p.stack, followRecursiveMorphism(path, maxDepth, depthTags))
+	np.stack = append(p.stack, followRecursiveMorphism(path, maxDepth, depthTags, pathTags))
 	return np
 }
 
@@ -468,6 +531,21 @@ func (p *Path) LabelContextWithTags(tags []string, via ...interface{}) *Path {
 	return np
 }
 
+// ValidAt restricts the following operations (such as In, Out) to versions of temporal facts
+// that are valid at a given time, according to the current knowledge. See temporal package for details.
+//
+// Facts written without temporal.Writer have no versions, thus they are not traversed.
+func (p *Path) ValidAt(t time.Time) *Path {
+	return p.ValidAtAsOf(t, time.Time{})
+}
+
+// ValidAtAsOf is exactly like ValidAt, except it uses the knowledge recorded in the database at a given time.
+func (p *Path) ValidAtAsOf(t, recorded time.Time) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, labelShapeMorphism(temporal.VersionsAt(t, recorded)))
+	return np
+}
+
 // Back returns to a previously tagged place in the path. Any constraints applied after the Tag will remain in effect, but traversal continues from the tagged point instead, not from the end of the chain.
 //
 // For example:
@@ -541,6 +619,14 @@ func (p *Path) Count() *Path {
 	return p
 }
 
+// Aggregate will compute an aggregate of results as it's own result set.
+// See iterator.Aggregation for a list of functions.
+func (p *Path) Aggregate(fnc iterator.Aggregation) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, aggregateMorphism(fnc))
+	return np
+}
+
 // Iterate is an shortcut for graph.Iterate.
 func (p *Path) Iterate(ctx context.Context) *graph.IterateChain {
 	return shape.Iterate(ctx, p.qs, p.Shape())
@@ -15,12 +15,101 @@
 package path_test
 
 import (
+	"context"
+	"fmt"
+	"reflect"
 	"testing"
 
-	_ "github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
 	"github.com/cayleygraph/cayley/graph/path/pathtest"
+	"github.com/cayleygraph/cayley/quad"
 )
 
 func TestMorphisms(t *testing.T) {
 	pathtest.RunTestMorphisms(t, nil)
 }
+
+func road(from, to, id string, length int) []quad.Quad {
+	return []quad.Quad{
+		quad.MakeIRI(from, "road", id, ""),
+		quad.MakeIRI(id, "to", to, ""),
+		quad.Make(quad.IRI(id), quad.IRI("length"), quad.Int(length), nil),
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	var quads []quad.Quad
+	quads = append(quads, road("a", "b", "r1", 1)...)
+	quads = append(quads, road("b", "c", "r2", 1)...)
+	quads = append(quads, road("a", "c", "r3", 5)...)
+	quads = append(quads, road("c", "d", "r4", 1)...)
+	quads = append(quads, road("b", "d", "r5", 4)...)
+	quads = append(quads, quad.MakeIRI("e", "to", "a", ""))
+	qs := memstore.New(quads...)
+
+	routes := func(from, to string, opt *path.ShortestPathOptions) [][]string {
+		out, err := path.ShortestPath(context.Background(), qs, []quad.Value{quad.IRI(from)}, []quad.Value{quad.IRI(to)}, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got [][]string
+		for _, r := range out {
+			var nodes []string
+			for _, n := range r.Nodes {
+				nodes = append(nodes, string(n.(quad.IRI)))
+			}
+			nodes = append(nodes, fmt.Sprint(r.Weight))
+			got = append(got, nodes)
+		}
+		return got
+	}
+	weighted := &path.ShortestPathOptions{
+		Via:    path.StartMorphism().Out(quad.IRI("road")).Save(quad.IRI("length"), "w").Out(quad.IRI("to")),
+		Weight: "w",
+		K:      5,
+	}
+	for _, c := range []struct {
+		name     string
+		from, to string
+		opt      *path.ShortestPathOptions
+		expect   [][]string
+	}{
+		{
+			name: "unweighted",
+			from: "e", to: "c",
+			expect: [][]string{{"e", "a", "r3", "c", "3"}},
+		},
+		{
+			name: "weighted",
+			from: "a", to: "d",
+			opt: weighted,
+			expect: [][]string{
+				{"a", "b", "c", "d", "3"},
+				{"a", "b", "d", "5"},
+				{"a", "c", "d", "6"},
+			},
+		},
+		{
+			name: "same node",
+			from: "a", to: "a",
+			opt:    weighted,
+			expect: [][]string{{"a", "0"}},
+		},
+		{
+			name: "not connected",
+			from: "d", to: "a",
+			opt: weighted,
+		},
+		{
+			name: "missing node",
+			from: "x", to: "a",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if got := routes(c.from, c.to, c.opt); !reflect.DeepEqual(got, c.expect) {
+				t.Fatalf("unexpected routes:\n%v\nvs\n%v", got, c.expect)
+			}
+		})
+	}
+}
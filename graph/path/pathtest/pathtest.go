@@ -91,6 +91,7 @@ type test struct {
 	path      *Path
 	expect    []quad.Value
 	expectAlt [][]quad.Value
+	ordered   bool // results are expected in exactly the same order
 	tag       string
 }
 
@@ -299,6 +300,18 @@ func testSet(qs graph.QuadStore) []test {
 				{vGreg},
 			},
 		},
+		{
+			message: "Order",
+			path:    StartPath(qs).Has(vStatus, vCool).Order(),
+			expect:  []quad.Value{vBob, vDani, vGreg},
+			ordered: true,
+		},
+		{
+			message: "Order and Limit",
+			path:    StartPath(qs).Has(vStatus, vCool).Order().Skip(1).Limit(1),
+			expect:  []quad.Value{vDani},
+			ordered: true,
+		},
 		{
 			message: "Count",
 			path:    StartPath(qs).Has(vStatus).Count(),
@@ -325,6 +338,11 @@ func testSet(qs graph.QuadStore) []test {
 			tag:     "acd",
 			expect:  []quad.Value{vDani},
 		},
+		{
+			message: "repeated .Back() to the same tag",
+			path:    StartPath(qs, vAlice).Out(vFollows).Tag("f").Back("f").Back("f").Out(vFollows),
+			expect:  []quad.Value{vFred},
+		},
 		{
 			message: "Labels()",
 			path:    StartPath(qs, vGreg).Labels(),
@@ -458,10 +476,11 @@ func RunTestMorphisms(t *testing.T, fnc testutil.DatabaseFunc) {
 					t.Error(err)
 					return
 				}
-				sort.Sort(quad.ByValueString(got))
 				var eq bool
 				exp := test.expect
-				if test.expectAlt != nil {
+				if test.ordered {
+					eq = reflect.DeepEqual(got, exp)
+				} else if sort.Sort(quad.ByValueString(got)); test.expectAlt != nil {
 					for _, alt := range test.expectAlt {
 						exp = alt
 						sort.Sort(quad.ByValueString(exp))
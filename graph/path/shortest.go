@@ -0,0 +1,376 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package path
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// ShortestPathOptions are parameters of ShortestPath.
+type ShortestPathOptions struct {
+	// Via is a morphism that is applied to a node to get its neighbours. If nil, all outgoing links are followed.
+	Via *Path
+	// Weight is a tag with a weight of each step, which must be saved by Via. For example, Via can save
+	// a weight predicate of an intermediate node that represents a link: Out("<road>").Save("<length>", "w").Out("<to>").
+	//
+	// Weights must be non-negative numbers. If Weight is not set, each step has a weight of 1.
+	Weight string
+	// K is the number of alternative paths to find, in order of their weights. Defaults to 1.
+	K int
+}
+
+// Route is a path between two nodes found by ShortestPath.
+type Route struct {
+	// Nodes is a sequence of nodes on the path, including the start and the end nodes.
+	Nodes []quad.Value
+	// Weight is a total weight of all steps of the path.
+	Weight float64
+}
+
+// ShortestPath finds K shortest paths that start at one of the nodes in from and end at one of the nodes in to.
+// Each path visits a node at most once. Paths are returned in order of their weights; fewer than K paths
+// are returned if there are no more paths, and no paths are returned if nodes are not connected.
+//
+// Neighbours of each node are loaded lazily with the Via morphism, thus only the part of the graph
+// that is closer than the target is loaded. Paths are found with Dijkstra's algorithm, and alternative
+// paths with Yen's algorithm.
+func ShortestPath(ctx context.Context, qs graph.QuadStore, from, to []quad.Value, opt *ShortestPathOptions) ([]Route, error) {
+	var o ShortestPathOptions
+	if opt != nil {
+		o = *opt
+	}
+	if o.K <= 0 {
+		o.K = 1
+	}
+	if o.Via == nil {
+		o.Via = StartMorphism().Out()
+	}
+	g := newSPGraph(ctx, qs, &o)
+	for _, v := range from {
+		if gv := qs.ValueOf(v); gv != nil {
+			g.sources = append(g.sources, spEdge{to: g.node(gv)})
+		}
+	}
+	for _, v := range to {
+		if gv := qs.ValueOf(v); gv != nil {
+			g.targets[g.node(gv)] = struct{}{}
+		}
+	}
+	if len(g.sources) == 0 || len(g.targets) == 0 {
+		return nil, nil
+	}
+	paths, err := g.yen(o.K)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Route, 0, len(paths))
+	for _, p := range paths {
+		// strip virtual source and target nodes
+		nodes := p.nodes[1 : len(p.nodes)-1]
+		r := Route{Nodes: make([]quad.Value, 0, len(nodes)), Weight: p.weight}
+		for _, n := range nodes {
+			r.Nodes = append(r.Nodes, qs.NameOf(g.nodes[n]))
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+const (
+	spSource = 0 // virtual node linked to all start nodes
+	spTarget = 1 // virtual node linked from all end nodes
+)
+
+// spTag is a tag that saves neighbours returned by the Via morphism.
+const spTag = "\x00to"
+
+type spEdge struct {
+	to     int
+	weight float64
+}
+
+// spGraph loads neighbours of nodes on demand and caches them.
+type spGraph struct {
+	ctx context.Context
+	qs  graph.QuadStore
+	opt *ShortestPathOptions
+
+	nodes   []graph.Value
+	index   map[interface{}]int
+	adj     map[int][]spEdge
+	sources []spEdge
+	targets map[int]struct{}
+}
+
+func newSPGraph(ctx context.Context, qs graph.QuadStore, opt *ShortestPathOptions) *spGraph {
+	return &spGraph{
+		ctx: ctx, qs: qs, opt: opt,
+		nodes:   []graph.Value{nil, nil},
+		index:   make(map[interface{}]int),
+		adj:     make(map[int][]spEdge),
+		targets: make(map[int]struct{}),
+	}
+}
+
+func (g *spGraph) node(v graph.Value) int {
+	k := graph.ToKey(v)
+	if i, ok := g.index[k]; ok {
+		return i
+	}
+	i := len(g.nodes)
+	g.nodes = append(g.nodes, v)
+	g.index[k] = i
+	return i
+}
+
+func (g *spGraph) neighbours(n int) ([]spEdge, error) {
+	switch n {
+	case spSource:
+		return g.sources, nil
+	case spTarget:
+		return nil, nil
+	}
+	if out, ok := g.adj[n]; ok {
+		return out, nil
+	}
+	p := StartPathNodes(g.qs, g.nodes[n]).Follow(g.opt.Via).Tag(spTag)
+	var (
+		out  []spEdge
+		best = make(map[int]int)
+		werr error
+	)
+	err := p.Iterate(g.ctx).Paths(true).TagEach(func(tags map[string]graph.Value) {
+		to := tags[spTag]
+		if to == nil || werr != nil {
+			return
+		}
+		w := 1.0
+		if g.opt.Weight != "" {
+			var err error
+			if w, err = g.weight(tags[g.opt.Weight]); err != nil {
+				werr = fmt.Errorf("invalid weight of a step from %v to %v: %v",
+					g.qs.NameOf(g.nodes[n]), g.qs.NameOf(to), err)
+				return
+			}
+		}
+		// keep the lightest of multiple links between the same nodes
+		i := g.node(to)
+		if j, ok := best[i]; !ok {
+			best[i] = len(out)
+			out = append(out, spEdge{to: i, weight: w})
+		} else if w < out[j].weight {
+			out[j].weight = w
+		}
+	})
+	if err == nil {
+		err = werr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := g.targets[n]; ok {
+		out = append(out, spEdge{to: spTarget})
+	}
+	g.adj[n] = out
+	return out, nil
+}
+
+var errNoWeight = errors.New("no weight")
+
+func (g *spGraph) weight(v graph.Value) (float64, error) {
+	if v == nil {
+		return 0, errNoWeight
+	}
+	var w float64
+	switch qv := g.qs.NameOf(v).(type) {
+	case quad.Int:
+		w = float64(qv)
+	case quad.Float:
+		w = float64(qv)
+	default:
+		return 0, fmt.Errorf("weight is not a number: %v", qv)
+	}
+	if w < 0 {
+		return 0, fmt.Errorf("negative weight: %v", w)
+	}
+	return w, nil
+}
+
+// edgeWeight returns a weight of a loaded edge.
+func (g *spGraph) edgeWeight(from, to int) float64 {
+	if from == spSource {
+		return 0
+	}
+	for _, e := range g.adj[from] {
+		if e.to == to {
+			return e.weight
+		}
+	}
+	return 0
+}
+
+type spPath struct {
+	nodes  []int
+	weight float64
+}
+
+type spItem struct {
+	node int
+	dist float64
+	seq  int
+}
+
+type spQueue []spItem
+
+func (q spQueue) Len() int { return len(q) }
+func (q spQueue) Less(i, j int) bool {
+	if q[i].dist != q[j].dist {
+		return q[i].dist < q[j].dist
+	}
+	return q[i].seq < q[j].seq
+}
+func (q spQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *spQueue) Push(x interface{}) { *q = append(*q, x.(spItem)) }
+func (q *spQueue) Pop() interface{} {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+// dijkstra finds the shortest path from src to the virtual target, ignoring removed nodes and edges.
+func (g *spGraph) dijkstra(src int, rmNodes map[int]bool, rmEdges map[[2]int]bool) (*spPath, error) {
+	dist := map[int]float64{src: 0}
+	prev := make(map[int]int)
+	done := make(map[int]bool)
+	q := &spQueue{{node: src}}
+	seq := 0
+	for q.Len() > 0 {
+		if err := g.ctx.Err(); err != nil {
+			return nil, err
+		}
+		cur := heap.Pop(q).(spItem)
+		if done[cur.node] {
+			continue
+		}
+		done[cur.node] = true
+		if cur.node == spTarget {
+			var nodes []int
+			for n := spTarget; n != src; n = prev[n] {
+				nodes = append(nodes, n)
+			}
+			nodes = append(nodes, src)
+			for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+				nodes[i], nodes[j] = nodes[j], nodes[i]
+			}
+			return &spPath{nodes: nodes, weight: cur.dist}, nil
+		}
+		edges, err := g.neighbours(cur.node)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range edges {
+			if done[e.to] || rmNodes[e.to] || rmEdges[[2]int{cur.node, e.to}] {
+				continue
+			}
+			d := cur.dist + e.weight
+			if od, ok := dist[e.to]; ok && od <= d {
+				continue
+			}
+			dist[e.to] = d
+			prev[e.to] = cur.node
+			seq++
+			heap.Push(q, spItem{node: e.to, dist: d, seq: seq})
+		}
+	}
+	return nil, nil
+}
+
+func samePrefix(a, b []int, n int) bool {
+	if len(a) < n || len(b) < n {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func pathKey(nodes []int) string {
+	return fmt.Sprint(nodes)
+}
+
+// yen finds k shortest loopless paths from the virtual source to the virtual target.
+func (g *spGraph) yen(k int) ([]spPath, error) {
+	first, err := g.dijkstra(spSource, nil, nil)
+	if err != nil || first == nil {
+		return nil, err
+	}
+	found := []spPath{*first}
+	seen := map[string]bool{pathKey(first.nodes): true}
+	var cands []spPath
+	for len(found) < k {
+		last := found[len(found)-1].nodes
+		// the last node is the virtual target, and a step before it has no alternatives
+		for i := 0; i < len(last)-2; i++ {
+			spur := last[i]
+			rmEdges := make(map[[2]int]bool)
+			for _, p := range found {
+				if samePrefix(p.nodes, last, i+1) && len(p.nodes) > i+1 {
+					rmEdges[[2]int{p.nodes[i], p.nodes[i+1]}] = true
+				}
+			}
+			rmNodes := make(map[int]bool, i)
+			rootWeight := 0.0
+			for j := 0; j < i; j++ {
+				rmNodes[last[j]] = true
+				rootWeight += g.edgeWeight(last[j], last[j+1])
+			}
+			sp, err := g.dijkstra(spur, rmNodes, rmEdges)
+			if err != nil {
+				return nil, err
+			} else if sp == nil {
+				continue
+			}
+			nodes := make([]int, 0, i+len(sp.nodes))
+			nodes = append(nodes, last[:i]...)
+			nodes = append(nodes, sp.nodes...)
+			if key := pathKey(nodes); !seen[key] {
+				seen[key] = true
+				cands = append(cands, spPath{nodes: nodes, weight: rootWeight + sp.weight})
+			}
+		}
+		if len(cands) == 0 {
+			break
+		}
+		best := 0
+		for i, c := range cands {
+			if c.weight < cands[best].weight {
+				best = i
+			}
+		}
+		found = append(found, cands[best])
+		cands = append(cands[:best], cands[best+1:]...)
+	}
+	return found, nil
+}
@@ -42,3 +42,9 @@ func (p Primitive) Key() interface{} {
 func (p *Primitive) IsSameLink(q *Primitive) bool {
 	return p.Subject == q.Subject && p.Predicate == q.Predicate && p.Object == q.Object && p.Label == q.Label
 }
+
+// IsExpired reports whether the primitive has an expiration time (in Unix nanoseconds)
+// which is not after now.
+func (p Primitive) IsExpired(now int64) bool {
+	return p.Expires != 0 && p.Expires <= now
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"time"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Provenance describes who asserted a quad, when and where it came from.
+type Provenance struct {
+	Author string    `json:"author,omitempty"` // identity of the writer
+	Source string    `json:"source,omitempty"` // source tag, for example a file name or a service name
+	Time   time.Time `json:"time"`             // wall-clock time of the write
+}
+
+// ProvenanceStore is an optional interface for QuadStores that can record provenance of quads.
+type ProvenanceStore interface {
+	// ApplyDeltasWithProvenance is the same as ApplyDeltas, but records provenance for each added quad.
+	ApplyDeltasWithProvenance(in []Delta, opts IgnoreOpts, p Provenance) error
+	// ProvenanceOf returns provenance of an existing quad, or ErrQuadNotExist if there is no such quad.
+	// Nil is returned if the quad was added without provenance and the store has no other information about it.
+	ProvenanceOf(ctx context.Context, q quad.Quad) (*Provenance, error)
+}
+
+// WithProvenance records provenance of all quads added by ApplyDeltas. If the time is not set,
+// the current time is used.
+//
+// If QuadStore does not implement ProvenanceStore, ApplyDeltas returns ErrNotSupported.
+// The option cannot be combined with IfHorizon.
+func WithProvenance(p Provenance) ApplyOption {
+	return func(o *applyOptions) {
+		if p.Time.IsZero() {
+			p.Time = time.Now().UTC()
+		}
+		o.prov = &p
+	}
+}
+
+// ProvenanceOf returns provenance of an existing quad.
+// It returns ErrNotSupported if QuadStore does not implement ProvenanceStore.
+func ProvenanceOf(ctx context.Context, qs QuadStore, q quad.Quad) (*Provenance, error) {
+	if ps, ok := Unwrap(qs).(ProvenanceStore); ok {
+		return ps.ProvenanceOf(ctx, q)
+	}
+	return nil, ErrNotSupported
+}
@@ -26,6 +26,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/cayleygraph/cayley/quad"
 )
@@ -45,6 +46,39 @@ func ValuesOf(ctx context.Context, qs QuadStore, vals []Value) ([]quad.Value, er
 	return out, nil
 }
 
+// Pinger is an optional interface for QuadStores that can check a connection to the underlying database.
+type Pinger interface {
+	// Ping checks if the database is reachable and can serve requests.
+	Ping(ctx context.Context) error
+}
+
+// Ping checks if QuadStore is able to serve requests.
+//
+// If QuadStore does not implement Pinger, estimated statistics are requested from StatsCollector instead.
+// As a last resort, the size of the store is read. This read cannot be canceled, thus on timeout
+// it keeps running in the background until the backend responds.
+func Ping(ctx context.Context, qs QuadStore) error {
+	qs = Unwrap(qs)
+	if p, ok := qs.(Pinger); ok {
+		return p.Ping(ctx)
+	} else if c, ok := qs.(StatsCollector); ok {
+		_, err := c.Stats(ctx, false)
+		return err
+	}
+	// buffered, so the goroutine can exit even if nobody waits for it
+	done := make(chan struct{}, 1)
+	go func() {
+		_ = qs.Size()
+		done <- struct{}{}
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
 type QuadStore interface {
 	// The only way in is through building a transaction, which
 	// is done by a replication strategy.
@@ -137,6 +171,26 @@ func (d Options) BoolKey(key string, def bool) (bool, error) {
 	return def, nil
 }
 
+// DurationKey returns a duration option. The value can be set as a string in time.ParseDuration format.
+func (d Options) DurationKey(key string, def time.Duration) (time.Duration, error) {
+	if val, ok := d[key]; ok {
+		switch v := val.(type) {
+		case time.Duration:
+			return v, nil
+		case string:
+			dt, err := time.ParseDuration(v)
+			if err != nil {
+				return def, fmt.Errorf("Invalid %s parameter value from config: %v", key, err)
+			}
+			return dt, nil
+		}
+
+		return def, fmt.Errorf("Invalid %s parameter type from config: %T", key, val)
+	}
+
+	return def, nil
+}
+
 var (
 	ErrDatabaseExists = errors.New("quadstore: cannot init; database already exists")
 	ErrNotInitialized = errors.New("quadstore: not initialized")
@@ -148,3 +202,19 @@ type BulkLoader interface {
 	// you cannot load in bulk to a non-empty database, and the db is non-empty.
 	BulkLoad(quad.Reader) error
 }
+
+// BulkIndexer is an optional interface for quad stores that can defer index maintenance
+// while a large number of quads is loaded by regular writes.
+type BulkIndexer interface {
+	// BeginBulkLoad prepares the store for a bulk load, for example by dropping secondary indexes.
+	// The returned function must be called after all quads are written to restore them.
+	BeginBulkLoad(ctx context.Context) (end func(ctx context.Context) error, err error)
+}
+
+// BulkIndexerOf returns a BulkIndexer of the QuadStore, or nil if the store cannot defer indexing.
+func BulkIndexerOf(qs QuadStore) BulkIndexer {
+	if b, ok := Unwrap(qs).(BulkIndexer); ok {
+		return b
+	}
+	return nil
+}
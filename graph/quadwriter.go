@@ -37,6 +37,12 @@ func (p Procedure) String() string {
 		return "add"
 	case -1:
 		return "delete"
+	case +2:
+		return "add_unique"
+	case +3:
+		return "replace_object"
+	case -2:
+		return "delete_matching"
 	default:
 		return "invalid"
 	}
@@ -48,18 +54,68 @@ const (
 	Delete Procedure = -1
 )
 
+// Extended actions are resolved against the current state of the store into Add and Delete deltas
+// before they are applied. See ResolveDeltas.
+const (
+	// AddUnique adds a quad, or fails with ErrQuadExists if it exists, regardless of IgnoreOpts.
+	AddUnique Procedure = +2
+	// ReplaceObject replaces objects of all quads with the same subject, predicate and label
+	// with the object of the quad.
+	ReplaceObject Procedure = +3
+	// DeleteMatching removes all quads matching the quad. Directions set to nil match any value.
+	DeleteMatching Procedure = -2
+)
+
 type Delta struct {
 	Quad   quad.Quad
 	Action Procedure
 }
 
-// Unwrap returns an original QuadStore value if it was wrapped by Handle.
+// Wrapper is an optional interface for QuadStores that wrap another QuadStore to extend it,
+// for example to maintain an external index. Optional interfaces are checked on the wrapped
+// QuadStore, thus wrappers only need to implement their own features.
+type Wrapper interface {
+	QuadStore
+	// Unwrap returns the wrapped QuadStore.
+	Unwrap() QuadStore
+}
+
+// Unwrap returns an original QuadStore value if it was wrapped by Handle or by a Wrapper.
 // This prevents shadowing of optional interface implementations.
 func Unwrap(qs QuadStore) QuadStore {
-	if h, ok := qs.(*Handle); ok {
-		return h.QuadStore
+	for {
+		switch s := qs.(type) {
+		case *Handle:
+			qs = s.QuadStore
+		case Wrapper:
+			qs = s.Unwrap()
+		default:
+			return qs
+		}
 	}
-	return qs
+}
+
+// UnwrapHandle returns a QuadStore of a Handle. Unlike Unwrap, it keeps Wrappers,
+// thus it should be used when the QuadStore is passed further, for example to build iterators.
+func UnwrapHandle(qs QuadStore) QuadStore {
+	for {
+		h, ok := qs.(*Handle)
+		if !ok {
+			return qs
+		}
+		qs = h.QuadStore
+	}
+}
+
+// unwrapOnce removes a single layer of Handle or Wrapper. It returns nil if the QuadStore is not wrapped.
+func unwrapOnce(qs QuadStore) QuadStore {
+	switch s := qs.(type) {
+	case *Handle:
+		return s.QuadStore
+	case Wrapper:
+		return s.Unwrap()
+	}
+	return nil
 }
 
 type Handle struct {
@@ -255,6 +311,8 @@ func (w *txWriter) WriteQuad(q quad.Quad) error {
 		w.tx.AddQuad(q)
 	case Delete:
 		w.tx.RemoveQuad(q)
+	case AddUnique, ReplaceObject, DeleteMatching:
+		w.tx.Append(Delta{Quad: q, Action: w.p})
 	default:
 		return ErrInvalidAction
 	}
@@ -309,25 +367,40 @@ func NewResultReader(qs QuadStore, it Iterator) quad.ReadSkipCloser {
 type quadReader struct {
 	qs QuadStore
 	it Iterator
+
+	buf  []Value
+	vals []Value // results of the last batch that were not read yet
+}
+
+// next returns the next result of the iterator. Results are fetched from the iterator in batches.
+func (r *quadReader) next() (Value, error) {
+	if len(r.vals) == 0 {
+		if r.buf == nil {
+			r.buf = make([]Value, iterateBatch)
+		}
+		n, err := NextBatch(context.TODO(), r.it, r.buf)
+		if n == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		r.vals = r.buf[:n]
+	}
+	v := r.vals[0]
+	r.vals = r.vals[1:]
+	return v, nil
 }
 
 func (r *quadReader) ReadQuad() (quad.Quad, error) {
-	if r.it.Next(context.TODO()) {
-		return r.qs.Quad(r.it.Result()), nil
-	}
-	err := r.it.Err()
-	if err == nil {
-		err = io.EOF
+	v, err := r.next()
+	if err != nil {
+		return quad.Quad{}, err
 	}
-	return quad.Quad{}, err
+	return r.qs.Quad(v), nil
 }
 func (r *quadReader) SkipQuad() error {
-	if r.it.Next(context.TODO()) {
-		return nil
-	}
-	if err := r.it.Err(); err != nil {
-		return err
-	}
-	return io.EOF
+	_, err := r.next()
+	return err
 }
 func (r *quadReader) Close() error { return r.it.Close() }
@@ -0,0 +1,70 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// PatternRemover is an optional interface for QuadStores that can remove all quads
+// matching a pattern without reading them first.
+type PatternRemover interface {
+	// RemoveQuadsMatching removes all quads that match the pattern and returns the number of quads removed.
+	// Directions of the pattern set to nil match any value.
+	RemoveQuadsMatching(ctx context.Context, pattern quad.Quad) (int64, error)
+}
+
+// wildcards returns directions of the pattern that match any value.
+func wildcards(pattern quad.Quad) []quad.Direction {
+	var dirs []quad.Direction
+	for _, d := range quad.Directions {
+		if pattern.Get(d) == nil {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// RemoveQuadsMatching removes all quads that match the pattern and returns the number of quads removed.
+// Directions of the pattern set to nil match any value, including the label; thus a pattern with only
+// the predicate set removes the predicate from all graphs.
+//
+// If QuadStore implements PatternRemover, quads are removed by the backend natively.
+// Otherwise, matching quads are read into memory and removed in batches, which is not atomic.
+func RemoveQuadsMatching(ctx context.Context, qs QuadStore, pattern quad.Quad) (int64, error) {
+	if r, ok := Unwrap(qs).(PatternRemover); ok {
+		return r.RemoveQuadsMatching(ctx, pattern)
+	}
+	quads, err := quadsMatching(ctx, qs, pattern, wildcards(pattern)...)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	deltas := make([]Delta, 0, quad.DefaultBatch)
+	for len(quads) != 0 {
+		deltas = deltas[:0]
+		for len(quads) != 0 && len(deltas) < quad.DefaultBatch {
+			deltas = append(deltas, Delta{Quad: quads[0], Action: Delete})
+			quads = quads[1:]
+		}
+		if err = qs.ApplyDeltas(deltas, IgnoreOpts{IgnoreMissing: true}); err != nil {
+			return n, err
+		}
+		n += int64(len(deltas))
+	}
+	return n, nil
+}
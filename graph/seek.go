@@ -0,0 +1,37 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"errors"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// ErrInvalidPosition is returned by iterators of QuadSeeker for positions that were not issued by the store.
+var ErrInvalidPosition = errors.New("invalid position")
+
+// QuadSeeker is an optional interface for QuadStores that list quads in a stable order and can resume
+// the listing from a position, for example to read quads page by page.
+//
+// Positions of quads do not change when other quads are added or removed, thus a position remains valid
+// even if the quad it was taken from was removed.
+type QuadSeeker interface {
+	// SeekQuads returns an iterator over quads that have given values in all non-nil directions of the pattern.
+	// Quads are ordered by position, starting after a given one. Nil position starts from the first quad.
+	SeekQuads(pattern quad.Quad, after []byte) Iterator
+	// QuadPosition returns a position of a quad returned by SeekQuads.
+	QuadPosition(v Value) []byte
+}
@@ -0,0 +1,213 @@
+package shape
+
+import (
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+// planner is an optimizer that reorders intersections and quad filters by their estimated cardinality,
+// so the most selective constraint is evaluated first. Estimates are provided by graph.Estimator.
+type planner struct {
+	qs  graph.QuadStore
+	est graph.Estimator
+}
+
+// newPlanner returns a cost-based planner for QuadStore, or nil if the store cannot estimate cardinalities.
+func newPlanner(qs graph.QuadStore) *planner {
+	est := graph.EstimatorOf(qs)
+	if est == nil {
+		return nil
+	}
+	return &planner{qs: qs, est: est}
+}
+
+func (p *planner) OptimizeShape(s Shape) (Shape, bool) {
+	switch s := s.(type) {
+	case Intersect:
+		costs := make([]int64, len(s))
+		for i, c := range s {
+			costs[i] = p.nodes(c)
+		}
+		perm := byCost(costs)
+		if perm == nil {
+			return s, false
+		}
+		ns := make(Intersect, len(s))
+		for i, j := range perm {
+			ns[i] = s[j]
+		}
+		return ns, true
+	case Quads:
+		costs := make([]int64, len(s))
+		for i, f := range s {
+			costs[i] = p.filterQuads(f)
+		}
+		perm := byCost(costs)
+		if perm == nil {
+			return s, false
+		}
+		nq := make(Quads, len(s))
+		for i, j := range perm {
+			nq[i] = s[j]
+		}
+		return nq, true
+	}
+	return s, false
+}
+
+// byCost returns a stable permutation that sorts elements by their costs,
+// or nil if elements are already sorted.
+func byCost(costs []int64) []int {
+	if sort.SliceIsSorted(costs, func(i, j int) bool { return costs[i] < costs[j] }) {
+		return nil
+	}
+	perm := make([]int, len(costs))
+	for i := range perm {
+		perm[i] = i
+	}
+	sort.SliceStable(perm, func(i, j int) bool { return costs[perm[i]] < costs[perm[j]] })
+	return perm
+}
+
+// totalNodes returns an estimated number of nodes in the store.
+func (p *planner) totalNodes() int64 {
+	if n, ok := p.est.EstimateNodes(""); ok {
+		return n
+	}
+	return p.qs.Size()
+}
+
+// fanout returns an average number of quads per node.
+func (p *planner) fanout() int64 {
+	nodes := p.totalNodes()
+	if nodes <= 0 {
+		return 1
+	}
+	if f := p.qs.Size() / nodes; f > 1 {
+		return f
+	}
+	return 1
+}
+
+// nodes returns an estimated number of nodes produced by a shape.
+func (p *planner) nodes(s Shape) int64 {
+	switch s := s.(type) {
+	case nil, Null:
+		return 0
+	case Fixed:
+		return int64(len(s))
+	case Lookup:
+		return int64(len(s))
+	case FixedTags:
+		return p.nodes(s.On)
+	case AllNodes:
+		return p.totalNodes()
+	case Filter:
+		n := p.nodes(s.From)
+		for _, f := range s.Filters {
+			if c, ok := f.(Comparison); ok && c.Val != nil {
+				// values of a different type never match the comparison
+				if t, ok := p.est.EstimateNodes(graph.ValueType(c.Val)); ok && t < n {
+					n = t
+				}
+			}
+			// assume that each filter drops a half of values
+			n /= 2
+		}
+		return n
+	case NodesFrom:
+		return p.quads(s.Quads)
+	case Intersect:
+		var n int64 = -1
+		for _, c := range s {
+			if v := p.nodes(c); n < 0 || v < n {
+				n = v
+			}
+		}
+		if n < 0 {
+			return 0
+		}
+		return n
+	case Union:
+		var n int64
+		for _, c := range s {
+			n += p.nodes(c)
+		}
+		return n
+	case Page:
+		n := p.nodes(s.From) - s.Skip
+		if n < 0 {
+			n = 0
+		}
+		if s.Limit > 0 && s.Limit < n {
+			n = s.Limit
+		}
+		return n
+	case Unique:
+		return p.nodes(s.From)
+	case Save:
+		return p.nodes(s.From)
+	case Optional:
+		// optional shape does not restrict the intersection
+		return p.totalNodes()
+	case QuadsAction:
+		if s.Size > 0 {
+			return s.Size
+		}
+		var n int64 = -1
+		for d, v := range s.Filter {
+			if v := p.filterQuads(QuadFilter{Dir: d, Values: Fixed{v}}); n < 0 || v < n {
+				n = v
+			}
+		}
+		if n >= 0 {
+			return n
+		}
+	}
+	return p.totalNodes()
+}
+
+// quads returns an estimated number of quads produced by a shape.
+func (p *planner) quads(s Shape) int64 {
+	switch s := s.(type) {
+	case nil, Null:
+		return 0
+	case Quads:
+		if len(s) == 0 {
+			return p.qs.Size()
+		}
+		var n int64 = -1
+		for _, f := range s {
+			if v := p.filterQuads(f); n < 0 || v < n {
+				n = v
+			}
+		}
+		return n
+	}
+	return p.qs.Size()
+}
+
+// filterQuads returns an estimated number of quads matching a single quad filter.
+func (p *planner) filterQuads(f QuadFilter) int64 {
+	total := p.qs.Size()
+	switch v := f.Values.(type) {
+	case DefaultGraph:
+		return total
+	case Fixed:
+		var n int64
+		for _, id := range v {
+			if c, ok := p.est.EstimateQuads(f.Dir, id); ok {
+				n += c
+			} else {
+				n += p.fanout()
+			}
+		}
+		return n
+	}
+	n := p.nodes(f.Values) * p.fanout()
+	if n > total || n < 0 {
+		n = total
+	}
+	return n
+}
@@ -0,0 +1,113 @@
+package shape
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var (
+	rtValue   = reflect.TypeOf((*graph.Value)(nil)).Elem()
+	rtQuadVal = reflect.TypeOf((*quad.Value)(nil)).Elem()
+	rtString  = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	shapePkg  = reflect.TypeOf(Null{}).PkgPath()
+)
+
+// Describe returns a description of the shape tree that consists of maps, slices and basic values,
+// thus it can be encoded to JSON. Each shape is described by a map with a "type" key and its non-empty fields.
+// Node values are replaced with their names, if QuadStore is set.
+func Describe(qs graph.QuadStore, s Shape) interface{} {
+	if s == nil {
+		return nil
+	}
+	return describeValue(qs, reflect.ValueOf(s))
+}
+
+// Pushdown returns all backend-specific shapes of the tree, which are executed natively by the backend.
+func Pushdown(s Shape) []Shape {
+	var out []Shape
+	Walk(s, func(s Shape) bool {
+		if reflect.TypeOf(s).PkgPath() != shapePkg {
+			out = append(out, s)
+		}
+		return true
+	})
+	return out
+}
+
+func typeName(rt reflect.Type) string {
+	if rt.PkgPath() == shapePkg {
+		return rt.Name()
+	}
+	return rt.String()
+}
+
+func describeValue(qs graph.QuadStore, rv reflect.Value) interface{} {
+	if !rv.IsValid() {
+		return nil
+	}
+	rt := rv.Type()
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+	if rt.Implements(rtQuadVal) {
+		return rv.Interface().(quad.Value).String()
+	} else if qs != nil && rt.Implements(rtValue) && !rt.Implements(rtShape) && rv.Kind() != reflect.Interface {
+		if name := qs.NameOf(rv.Interface().(graph.Value)); name != nil {
+			return name.String()
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		return describeValue(qs, rv.Elem())
+	case reflect.Struct:
+		m := map[string]interface{}{"type": typeName(rt)}
+		for i := 0; i < rt.NumField(); i++ {
+			f := rt.Field(i)
+			if f.PkgPath != "" {
+				continue // unexported
+			}
+			fv := rv.Field(i)
+			if isZero(fv) {
+				continue
+			}
+			m[f.Name] = describeValue(qs, fv)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.Uint8 {
+			return fmt.Sprintf("%x", rv.Bytes())
+		}
+		arr := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			arr = append(arr, describeValue(qs, rv.Index(i)))
+		}
+		if rt.Implements(rtShape) {
+			return map[string]interface{}{"type": typeName(rt), "values": arr}
+		}
+		return arr
+	case reflect.Map:
+		m := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			m[fmt.Sprint(k.Interface())] = describeValue(qs, rv.MapIndex(k))
+		}
+		return m
+	}
+	if rt.Implements(rtString) {
+		return rv.Interface().(fmt.Stringer).String()
+	}
+	return rv.Interface()
+}
+
+func isZero(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return reflect.DeepEqual(rv.Interface(), reflect.Zero(rv.Type()).Interface())
+}
@@ -0,0 +1,128 @@
+package shape
+
+import (
+	"regexp"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// FullText filters string literals matching a full-text query. All words of the query must be present in the literal.
+//
+// Words are compared case-insensitively. If Fuzziness is set, words may differ by up to a given number of edits.
+// Boost increases the relevance of matches for QuadStores that rank them; it does not change the set of matched values.
+//
+// QuadStore optimizer may replace the filter with a native text query. Otherwise, literals are scanned
+// with regular expressions, or compared word by word if fuzziness is set.
+type FullText struct {
+	Query     string
+	Fuzziness int
+	Boost     float64
+}
+
+// Terms returns lowercase words of the query.
+func (f FullText) Terms() []string {
+	return textTerms(f.Query)
+}
+
+// Regexps returns case-insensitive regular expressions that a literal must match.
+// They are only equivalent to the filter if fuzziness is not set.
+func (f FullText) Regexps() []*regexp.Regexp {
+	terms := f.Terms()
+	out := make([]*regexp.Regexp, 0, len(terms))
+	for _, t := range terms {
+		out = append(out, regexp.MustCompile(`(?i)`+regexp.QuoteMeta(t)))
+	}
+	return out
+}
+
+// MatchString checks if a string contains all words of the query.
+func (f FullText) MatchString(s string) bool {
+	terms := f.Terms()
+	if len(terms) == 0 {
+		return false
+	}
+	if f.Fuzziness <= 0 {
+		for _, re := range f.Regexps() {
+			if !re.MatchString(s) {
+				return false
+			}
+		}
+		return true
+	}
+	words := textTerms(s)
+	for _, t := range terms {
+		found := false
+		for _, w := range words {
+			if editDistance(t, w) <= f.Fuzziness {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (f FullText) match(v quad.Value) (bool, error) {
+	switch v := v.(type) {
+	case quad.String:
+		return f.MatchString(string(v)), nil
+	case quad.TypedString:
+		return f.MatchString(string(v.Value)), nil
+	case quad.LangString:
+		return f.MatchString(string(v.Value)), nil
+	}
+	return false, nil
+}
+
+func (f FullText) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	if len(f.Terms()) == 0 {
+		return iterator.NewNull()
+	}
+	if f.Fuzziness > 0 {
+		return iterator.NewValueFilter(qs, it, "fulltext", f.match)
+	}
+	for _, re := range f.Regexps() {
+		it = iterator.NewRegex(it, re, qs)
+	}
+	return it
+}
+
+// editDistance returns the Levenshtein distance between two words.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 {
+		return len(rb)
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,106 @@
+package shape
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
+)
+
+// GeoWithin filters geometry literals (see geo.FromValue) located inside a region.
+// Geometries are matched by their center.
+type GeoWithin struct {
+	Region geo.Region
+}
+
+func (f GeoWithin) match(v quad.Value) (bool, error) {
+	g, err := geo.FromValue(v)
+	if err != nil {
+		// not a geometry, or an invalid one
+		return false, nil
+	}
+	return f.Region.Contains(g.Center()), nil
+}
+
+func (f GeoWithin) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	return iterator.NewValueFilter(qs, it, "geo", f.match)
+}
+
+// GeoSearch is a set of nodes that are subjects of geometry literals located inside a region.
+//
+// The optimizer replaces it with nodes found in the geospatial index of QuadStore (see graph.GeoSearcher),
+// ordered by distance from the center of the region. If QuadStore has no geospatial index,
+// all literals are scanned, which is slow for large databases.
+type GeoSearch struct {
+	Region geo.Region
+	// Predicates restricts the search to geometries of given predicates.
+	Predicates []quad.IRI
+	// Limit is a maximal number of nodes; graph.DefaultGeoLimit is used if not set.
+	Limit int
+}
+
+func (s GeoSearch) resolve(qs graph.QuadStore) (Shape, error) {
+	// TODO: pass the context of the query
+	hits, err := graph.SearchGeo(context.TODO(), qs, graph.GeoQuery{
+		Region:     s.Region,
+		Predicates: s.Predicates,
+		Limit:      s.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	vals := make(Fixed, 0, len(hits))
+	for _, h := range hits {
+		if gv := qs.ValueOf(h.Node); gv != nil {
+			vals = append(vals, gv)
+		}
+	}
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	return vals, nil
+}
+
+// scan returns a shape that checks all geometry literals.
+func (s GeoSearch) scan() Shape {
+	quads := Quads{{Dir: quad.Object, Values: Filter{From: AllNodes{}, Filters: []ValueFilter{GeoWithin{Region: s.Region}}}}}
+	if len(s.Predicates) != 0 {
+		preds := make(Lookup, 0, len(s.Predicates))
+		for _, p := range s.Predicates {
+			preds = append(preds, p)
+		}
+		quads = append(quads, QuadFilter{Dir: quad.Predicate, Values: preds})
+	}
+	limit := s.Limit
+	if limit <= 0 {
+		limit = graph.DefaultGeoLimit
+	}
+	return Page{
+		From:  Unique{From: NodesFrom{Dir: quad.Subject, Quads: quads}},
+		Limit: int64(limit),
+	}
+}
+
+func (s GeoSearch) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	ns, err := s.resolve(qs)
+	if err == graph.ErrNotSupported {
+		ns = s.scan()
+	} else if err != nil {
+		return iterator.NewError(err)
+	}
+	if IsNull(ns) {
+		return iterator.NewNull()
+	}
+	return ns.BuildIterator(qs)
+}
+func (s GeoSearch) Optimize(r Optimizer) (Shape, bool) {
+	if s.Region == nil {
+		return nil, true
+	}
+	if r != nil {
+		return r.OptimizeShape(s)
+	}
+	return s, false
+}
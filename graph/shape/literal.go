@@ -0,0 +1,62 @@
+package shape
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ ValueFilter = LangFilter{}
+
+// LangFilter filters string literals with a given language tag. Tags are compared case-insensitively.
+type LangFilter struct {
+	Lang string
+}
+
+func (f LangFilter) match(v quad.Value) (bool, error) {
+	s, ok := v.(quad.LangString)
+	return ok && strings.EqualFold(s.Lang, f.Lang), nil
+}
+
+func (f LangFilter) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	return iterator.NewValueFilter(qs, it, "lang", f.match)
+}
+
+var _ ValueFilter = DatatypeFilter{}
+
+// DatatypeFilter filters typed literals with a given datatype.
+//
+// Native values (see quad.TypedStringer) match their default datatype, as well as any other datatype
+// that is converted to the same native type (for example, both xsd:integer and xsd:int match quad.Int).
+type DatatypeFilter struct {
+	Type quad.IRI
+}
+
+func (f DatatypeFilter) match(v quad.Value) (bool, error) {
+	if s, ok := v.(quad.TypedString); ok {
+		if pv, err := s.ParseValue(); err == nil {
+			v = pv
+		}
+	}
+	typ := f.Type.Full()
+	switch v := v.(type) {
+	case quad.TypedString:
+		return v.Type.Full() == typ, nil
+	case quad.TypedStringer:
+		s := v.TypedString()
+		if s.Type.Full() == typ {
+			return true, nil
+		}
+		// datatype may be an alias of the default one
+		pv, err := quad.TypedString{Value: s.Value, Type: typ}.ParseValue()
+		return err == nil && reflect.TypeOf(pv) == reflect.TypeOf(v), nil
+	}
+	return false, nil
+}
+
+func (f DatatypeFilter) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	return iterator.NewValueFilter(qs, it, "datatype", f.match)
+}
@@ -1,9 +1,12 @@
 package shape
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
@@ -28,6 +31,30 @@ type Optimizer interface {
 	OptimizeShape(s Shape) (Shape, bool)
 }
 
+// Rewriter is an optional interface for QuadStores and their wrappers that rewrite query shapes
+// before any optimizations are applied. Lookups are already resolved to Fixed values at this point.
+//
+// It allows to change the meaning of the query, for example to expand it with inferred values,
+// while the rewritten shape can still be optimized by the backend.
+type Rewriter interface {
+	RewriteShape(s Shape) (Shape, bool)
+}
+
+// asRewriter finds a Rewriter in a chain of QuadStore wrappers.
+func asRewriter(qs graph.QuadStore) Rewriter {
+	for qs != nil {
+		if r, ok := qs.(Rewriter); ok {
+			return r
+		}
+		w, ok := qs.(graph.Wrapper)
+		if !ok {
+			return nil
+		}
+		qs = w.Unwrap()
+	}
+	return nil
+}
+
 // Composite shape can be simplified to a tree of more basic shapes.
 type Composite interface {
 	Simplify() Shape
@@ -39,11 +66,41 @@ type WalkFunc func(Shape) bool
 
 type resolveValues struct {
 	qs graph.QuadStore
+	rw Rewriter
 }
 
 func (r resolveValues) OptimizeShape(s Shape) (Shape, bool) {
-	if l, ok := s.(Lookup); ok {
-		return l.resolve(r.qs), true
+	switch s := s.(type) {
+	case Lookup:
+		return s.resolve(r.qs), true
+	case TextSearch:
+		ns, err := s.resolve(r.qs)
+		if err == graph.ErrNotSupported {
+			// let the quadstore optimize the scan
+			return s.scan(), true
+		} else if err != nil {
+			// error will be returned when building iterators
+			return s, false
+		}
+		return ns, true
+	case GeoSearch:
+		ns, err := s.resolve(r.qs)
+		if err == graph.ErrNotSupported {
+			return s.scan(), true
+		} else if err != nil {
+			return s, false
+		}
+		return ns, true
+	case VectorSearch:
+		// vectors are scanned by resolve if there is no index
+		ns, err := s.resolve(r.qs)
+		if err != nil {
+			return s, false
+		}
+		return ns, true
+	}
+	if r.rw != nil {
+		return r.rw.RewriteShape(s)
 	}
 	return s, false
 }
@@ -55,11 +112,11 @@ func Optimize(s Shape, qs graph.QuadStore) (Shape, bool) {
 	if s == nil {
 		return nil, false
 	}
-	qs = graph.Unwrap(qs)
+	qs = graph.UnwrapHandle(qs)
 	var opt bool
 	if qs != nil {
-		// resolve all lookups earlier
-		s, opt = s.Optimize(resolveValues{qs: qs})
+		// resolve all lookups earlier and apply rewrites
+		s, opt = s.Optimize(resolveValues{qs: qs, rw: asRewriter(qs)})
 	}
 	if s == nil {
 		return Null{}, true
@@ -71,6 +128,12 @@ func Optimize(s Shape, qs graph.QuadStore) (Shape, bool) {
 		return Null{}, true
 	}
 	opt = opt || opt1
+	// pick the join order from cardinality estimates
+	if p := newPlanner(qs); p != nil {
+		var opt2 bool
+		s, opt2 = s.Optimize(p)
+		opt = opt || opt2
+	}
 	// apply quadstore-specific optimizations
 	if so, ok := qs.(Optimizer); ok && s != nil {
 		var opt2 bool
@@ -199,7 +262,7 @@ func IsNull(s Shape) bool {
 
 // BuildIterator optimizes the shape and builds a corresponding iterator tree.
 func BuildIterator(qs graph.QuadStore, s Shape) graph.Iterator {
-	qs = graph.Unwrap(qs)
+	qs = graph.UnwrapHandle(qs)
 	if s != nil {
 		if clog.V(2) {
 			clog.Infof("shape: %#v", s)
@@ -272,6 +335,9 @@ func (s Except) Optimize(r Optimizer) (Shape, bool) {
 		return ns, opt || nopt
 	}
 	if IsNull(s.Exclude) {
+		if s.From != nil {
+			return s.From, true
+		}
 		return AllNodes{}, true
 	} else if _, ok := s.Exclude.(AllNodes); ok {
 		return nil, true
@@ -395,6 +461,98 @@ func (f Wildcard) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Ite
 	return rit
 }
 
+// TextSearch is a set of nodes that are subjects of string literals matching a full-text query.
+//
+// The optimizer replaces it with nodes found in the text index of QuadStore (see graph.TextSearcher),
+// ordered by relevance. If QuadStore has no text index, string literals are scanned for all words
+// of the query, which is slow for large databases.
+type TextSearch struct {
+	Query string
+	// Predicates restricts the search to string literals of given predicates.
+	Predicates []quad.IRI
+	// Limit is a maximal number of nodes; graph.DefaultTextLimit is used if not set.
+	Limit int
+}
+
+func (s TextSearch) resolve(qs graph.QuadStore) (Shape, error) {
+	// TODO: pass the context of the query
+	hits, err := graph.SearchText(context.TODO(), qs, graph.TextQuery{
+		Query:      s.Query,
+		Predicates: s.Predicates,
+		Limit:      s.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	vals := make(Fixed, 0, len(hits))
+	for _, h := range hits {
+		if gv := qs.ValueOf(h.Node); gv != nil {
+			vals = append(vals, gv)
+		}
+	}
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	return vals, nil
+}
+
+// textTerms splits a text query to lowercase words.
+func textTerms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// scan returns a shape that scans all string literals for words of the query.
+func (s TextSearch) scan() Shape {
+	terms := textTerms(s.Query)
+	if len(terms) == 0 {
+		return nil
+	}
+	filters := make([]ValueFilter, 0, len(terms))
+	for _, t := range terms {
+		filters = append(filters, Regexp{Re: regexp.MustCompile(`(?i)` + regexp.QuoteMeta(t))})
+	}
+	quads := Quads{{Dir: quad.Object, Values: Filter{From: AllNodes{}, Filters: filters}}}
+	if len(s.Predicates) != 0 {
+		preds := make(Lookup, 0, len(s.Predicates))
+		for _, p := range s.Predicates {
+			preds = append(preds, p)
+		}
+		quads = append(quads, QuadFilter{Dir: quad.Predicate, Values: preds})
+	}
+	limit := s.Limit
+	if limit <= 0 {
+		limit = graph.DefaultTextLimit
+	}
+	return Page{
+		From:  Unique{From: NodesFrom{Dir: quad.Subject, Quads: quads}},
+		Limit: int64(limit),
+	}
+}
+
+func (s TextSearch) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	ns, err := s.resolve(qs)
+	if err == graph.ErrNotSupported {
+		ns = s.scan()
+	} else if err != nil {
+		return iterator.NewError(err)
+	}
+	if IsNull(ns) {
+		return iterator.NewNull()
+	}
+	return ns.BuildIterator(qs)
+}
+func (s TextSearch) Optimize(r Optimizer) (Shape, bool) {
+	if len(textTerms(s.Query)) == 0 {
+		return nil, true
+	}
+	if r != nil {
+		return r.OptimizeShape(s)
+	}
+	return s, false
+}
+
 // Count returns a count of objects in source as a single value. It always returns exactly one value.
 type Count struct {
 	Values Shape
@@ -426,6 +584,49 @@ func (s Count) Optimize(r Optimizer) (Shape, bool) {
 	return s, opt
 }
 
+// Aggregate returns an aggregate of objects in source as a single value. See iterator.Aggregation for details.
+//
+// QuadStore optimizer may replace it with a shape that is computed by the database.
+type Aggregate struct {
+	Values Shape
+	Func   iterator.Aggregation
+}
+
+func (s Aggregate) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	var it graph.Iterator
+	if IsNull(s.Values) {
+		it = iterator.NewNull()
+	} else {
+		it = s.Values.BuildIterator(qs)
+	}
+	return iterator.NewAggregate(qs, it, s.Func)
+}
+func (s Aggregate) empty() Shape {
+	if s.Func == iterator.AggregateSum {
+		return Fixed{graph.PreFetched(quad.Int(0))}
+	}
+	return nil
+}
+func (s Aggregate) Optimize(r Optimizer) (Shape, bool) {
+	if IsNull(s.Values) {
+		return s.empty(), true
+	}
+	var opt bool
+	s.Values, opt = s.Values.Optimize(r)
+	if IsNull(s.Values) {
+		return s.empty(), true
+	}
+	if sv, ok := s.Values.(Sort); ok {
+		// order does not affect the result
+		s.Values, opt = sv.From, true
+	}
+	if r != nil {
+		ns, nopt := r.OptimizeShape(s)
+		return ns, opt || nopt
+	}
+	return s, opt
+}
+
 // QuadFilter is a constraint used to filter quads that have a certain set of values on a given direction.
 // Analog of LinksTo iterator.
 type QuadFilter struct {
@@ -433,10 +634,25 @@ type QuadFilter struct {
 	Values Shape
 }
 
+// DefaultGraph can be used as Values of QuadFilter on the label direction to select quads without a label.
+//
+// It is not a set of nodes and cannot be used in any other context.
+type DefaultGraph struct{}
+
+func (DefaultGraph) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	return iterator.NewError(errors.New("default graph is not a set of nodes"))
+}
+func (s DefaultGraph) Optimize(r Optimizer) (Shape, bool) {
+	return s, false
+}
+
 // buildIterator is not exposed to force to use Quads and group filters together.
 func (s QuadFilter) buildIterator(qs graph.QuadStore) graph.Iterator {
 	if s.Values == nil {
 		return iterator.NewNull()
+	} else if _, ok := s.Values.(DefaultGraph); ok {
+		// all quads except ones with some node on this direction
+		return iterator.NewNot(iterator.NewLinksTo(qs, qs.NodesAllIterator(), s.Dir), qs.QuadsAllIterator())
 	} else if v, ok := One(s.Values); ok {
 		return qs.QuadIterator(s.Dir, v)
 	}
@@ -528,12 +744,20 @@ func (s NodesFrom) Optimize(r Optimizer) (Shape, bool) {
 	}
 	var opt bool
 	s.Quads, opt = s.Quads.Optimize(r)
+	q, ok := s.Quads.(Quads)
+	if ok {
+		for _, f := range q {
+			if _, def := f.Values.(DefaultGraph); def && f.Dir == s.Dir {
+				// quads of the default graph have no nodes on this direction
+				return nil, true
+			}
+		}
+	}
 	if r != nil {
 		// ignore default optimizations
 		ns, nopt := r.OptimizeShape(s)
 		return ns, opt || nopt
 	}
-	q, ok := s.Quads.(Quads)
 	if !ok {
 		return s, opt
 	}
@@ -940,6 +1164,7 @@ func (s Intersect) Optimize(r Optimizer) (sout Shape, opt bool) {
 	}
 	var (
 		onlyAll = true   // contains only AllNodes shapes
+		hadAll  = false  // AllNodes was removed
 		fixed   []Fixed  // we will collect all Fixed, and will place it as a first iterator
 		tags    []string // if we find a Save inside, we will push it outside of Intersect
 		quads   Quads    // also, collect all quad filters into a single set
@@ -960,6 +1185,7 @@ func (s Intersect) Optimize(r Optimizer) (sout Shape, opt bool) {
 		switch c := c.(type) {
 		case AllNodes: // remove AllNodes - it's useless
 			remove(&i, true)
+			hadAll = true
 			// prevent resetting of onlyAll
 			continue
 		case Optional:
@@ -994,6 +1220,10 @@ func (s Intersect) Optimize(r Optimizer) (sout Shape, opt bool) {
 	if onlyAll {
 		return AllNodes{}, true
 	}
+	if hadAll && quads == nil && len(fixed) == 0 && onlyOptional(s) {
+		// optional shapes cannot produce results by themselves, thus AllNodes is still required
+		s = append(Intersect{AllNodes{}}, s...)
+	}
 	if len(tags) != 0 {
 		// don't forget to move Save outside of Intersect at the end
 		defer func() {
@@ -1241,6 +1471,41 @@ func (s Unique) Optimize(r Optimizer) (Shape, bool) {
 	return s, opt
 }
 
+// Sort orders nodes of the query in ascending order, as defined by iterator.CompareValues.
+//
+// QuadStore optimizer may replace it with a shape that is ordered by the database.
+// Otherwise, all nodes are sorted in memory.
+type Sort struct {
+	From Shape
+}
+
+func (s Sort) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	if IsNull(s.From) {
+		return iterator.NewNull()
+	}
+	it := s.From.BuildIterator(qs)
+	return iterator.NewSort(qs, it)
+}
+func (s Sort) Optimize(r Optimizer) (Shape, bool) {
+	if IsNull(s.From) {
+		return nil, true
+	}
+	var opt bool
+	s.From, opt = s.From.Optimize(r)
+	if IsNull(s.From) {
+		return nil, true
+	}
+	if sf, ok := s.From.(Sort); ok {
+		// already sorted
+		return sf, true
+	}
+	if r != nil {
+		ns, nopt := r.OptimizeShape(s)
+		return ns, opt || nopt
+	}
+	return s, opt
+}
+
 // Save tags a results of query with provided tags.
 type Save struct {
 	Tags []string
@@ -1274,6 +1539,16 @@ func (s Save) Optimize(r Optimizer) (Shape, bool) {
 	return s, opt
 }
 
+// onlyOptional checks if all shapes are Optional.
+func onlyOptional(arr []Shape) bool {
+	for _, s := range arr {
+		if _, ok := s.(Optional); !ok {
+			return false
+		}
+	}
+	return len(arr) != 0
+}
+
 // Optional makes a query execution optional. The query can only produce tagged results,
 // since it's value is not used to compute intersection.
 type Optional struct {
@@ -15,11 +15,14 @@
 package shape_test
 
 import (
+	"context"
 	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/graphmock"
+	"github.com/cayleygraph/cayley/graph/memstore"
 	. "github.com/cayleygraph/cayley/graph/shape"
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/stretchr/testify/assert"
@@ -99,6 +102,15 @@ var optimizeCases = []struct {
 		opt:    false,
 		expect: AllNodes{},
 	},
+	{
+		name: "except null",
+		from: Except{
+			From:    Fixed{intVal(1)},
+			Exclude: Null{},
+		},
+		opt:    true,
+		expect: Fixed{intVal(1)},
+	},
 	{
 		name: "page min limit",
 		from: Page{
@@ -197,6 +209,12 @@ var optimizeCases = []struct {
 		opt:    true,
 		expect: Null{},
 	},
+	{
+		name:   "collapse nested sort",
+		from:   Sort{Sort{AllNodes{}}},
+		opt:    true,
+		expect: Sort{AllNodes{}},
+	},
 	{ // remove "all nodes" in intersect, merge Fixed and order them first
 		name: "remove all in intersect and reorder",
 		from: Intersect{
@@ -322,6 +340,75 @@ func TestOptimize(t *testing.T) {
 	}
 }
 
+func TestDefaultGraph(t *testing.T) {
+	qs := memstore.New(
+		quad.MakeIRI("a", "follows", "b", ""),
+		quad.MakeIRI("b", "follows", "c", "g1"),
+		quad.MakeIRI("c", "follows", "a", ""),
+	)
+	s := NodesFrom{
+		Dir: quad.Subject,
+		Quads: Quads{
+			{Dir: quad.Predicate, Values: Lookup{quad.IRI("follows")}},
+			{Dir: quad.Label, Values: DefaultGraph{}},
+		},
+	}
+	it := BuildIterator(qs, s)
+	defer it.Close()
+	var got []string
+	for it.Next(context.TODO()) {
+		got = append(got, qs.NameOf(it.Result()).String())
+	}
+	require.NoError(t, it.Err())
+	sort.Strings(got)
+	require.Equal(t, []string{"<a>", "<c>"}, got)
+
+	// labels of the default graph
+	ns, _ := Optimize(NodesFrom{
+		Dir:   quad.Label,
+		Quads: Quads{{Dir: quad.Label, Values: DefaultGraph{}}},
+	}, qs)
+	require.True(t, IsNull(ns))
+}
+
+func TestPlanner(t *testing.T) {
+	qs := memstore.New(
+		quad.MakeIRI("a", "follows", "x", ""),
+		quad.MakeIRI("b", "follows", "x", ""),
+		quad.MakeIRI("c", "follows", "x", ""),
+		quad.MakeIRI("d", "follows", "x", ""),
+		quad.MakeIRI("a", "status", "x", ""),
+	)
+	val := func(s string) Fixed {
+		return Fixed{qs.ValueOf(quad.IRI(s))}
+	}
+	// the most selective quad filter goes first
+	ns, _ := Optimize(Quads{
+		{Dir: quad.Object, Values: Lookup{quad.IRI("x")}},
+		{Dir: quad.Predicate, Values: Lookup{quad.IRI("status")}},
+	}, qs)
+	require.Equal(t, Quads{
+		{Dir: quad.Predicate, Values: val("status")},
+		{Dir: quad.Object, Values: val("x")},
+	}, ns)
+
+	// the same for intersections
+	follows := NodesFrom{
+		Dir:   quad.Subject,
+		Quads: Quads{{Dir: quad.Predicate, Values: val("follows")}},
+	}
+	status := NodesFrom{
+		Dir:   quad.Subject,
+		Quads: Quads{{Dir: quad.Predicate, Values: val("status")}},
+	}
+	optimize := func(s Shape) Shape {
+		s, _ = Optimize(s, qs)
+		return s
+	}
+	ns = optimize(Intersect{follows, status})
+	require.Equal(t, Intersect{optimize(status), optimize(follows)}, ns)
+}
+
 func TestWalk(t *testing.T) {
 	var s Shape = NodesFrom{
 		Dir: quad.Subject,
@@ -352,3 +439,20 @@ func TestWalk(t *testing.T) {
 		"shape.QuadsAction",
 	}, types)
 }
+
+func TestFullTextMatch(t *testing.T) {
+	for _, c := range []struct {
+		f      FullText
+		s      string
+		expect bool
+	}{
+		{f: FullText{Query: "Quick fox"}, s: "The quick brown fox", expect: true},
+		{f: FullText{Query: "quick cat"}, s: "The quick brown fox", expect: false},
+		{f: FullText{Query: "quikc"}, s: "The quick brown fox", expect: false},
+		{f: FullText{Query: "quikc", Fuzziness: 2}, s: "The quick brown fox", expect: true},
+		{f: FullText{Query: "quack fix", Fuzziness: 1}, s: "The quick brown fox", expect: true},
+		{f: FullText{Query: "..."}, s: "...", expect: false},
+	} {
+		require.Equal(t, c.expect, c.f.MatchString(c.s), "%q in %q", c.f.Query, c.s)
+	}
+}
@@ -0,0 +1,126 @@
+package shape
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/vector"
+)
+
+// VectorSearch is a set of nodes that are subjects of vector literals nearest to a given vector,
+// ordered by distance.
+//
+// The optimizer replaces it with nodes found in the nearest neighbours index of QuadStore (see graph.VectorSearcher).
+// If QuadStore has no such index, all literals are scanned and compared using vector.DefaultMetric,
+// which is slow for large databases.
+type VectorSearch struct {
+	Vector vector.Vector
+	// Predicates restricts the search to vectors of given predicates.
+	Predicates []quad.IRI
+	// Limit is a number of nearest nodes; graph.DefaultVectorLimit is used if not set.
+	Limit int
+}
+
+func (s VectorSearch) limit() int {
+	if s.Limit <= 0 {
+		return graph.DefaultVectorLimit
+	}
+	return s.Limit
+}
+
+func (s VectorSearch) resolve(qs graph.QuadStore) (Shape, error) {
+	// TODO: pass the context of the query
+	hits, err := graph.SearchVectors(context.TODO(), qs, graph.VectorQuery{
+		Vector:     s.Vector,
+		Predicates: s.Predicates,
+		Limit:      s.limit(),
+	})
+	if err == graph.ErrNotSupported {
+		hits, err = s.scan(context.TODO(), qs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	vals := make(Fixed, 0, len(hits))
+	for _, h := range hits {
+		if gv := qs.ValueOf(h.Node); gv != nil {
+			vals = append(vals, gv)
+		}
+	}
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	return vals, nil
+}
+
+// scan compares all vector literals with the query vector.
+func (s VectorSearch) scan(ctx context.Context, qs graph.QuadStore) ([]graph.VectorHit, error) {
+	var its []graph.Iterator
+	if len(s.Predicates) == 0 {
+		its = append(its, qs.QuadsAllIterator())
+	} else {
+		for _, p := range s.Predicates {
+			if pv := qs.ValueOf(p); pv != nil {
+				its = append(its, qs.QuadIterator(quad.Predicate, pv))
+			}
+		}
+	}
+	best := make(map[string]graph.VectorHit)
+	for _, it := range its {
+		for it.Next(ctx) {
+			q := it.Result()
+			vec, err := vector.FromValue(qs.NameOf(qs.QuadDirection(q, quad.Object)))
+			if err != nil || len(vec) != len(s.Vector) {
+				continue
+			}
+			d := vector.DefaultMetric.Distance(s.Vector, vec)
+			node := qs.NameOf(qs.QuadDirection(q, quad.Subject))
+			key := quad.StringOf(node)
+			if h, ok := best[key]; !ok || d < h.Distance {
+				best[key] = graph.VectorHit{Node: node, Distance: d}
+			}
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	hits := make([]graph.VectorHit, 0, len(best))
+	for _, h := range best {
+		hits = append(hits, h)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Distance != hits[j].Distance {
+			return hits[i].Distance < hits[j].Distance
+		}
+		return quad.StringOf(hits[i].Node) < quad.StringOf(hits[j].Node)
+	})
+	if n := s.limit(); len(hits) > n {
+		hits = hits[:n]
+	}
+	return hits, nil
+}
+
+func (s VectorSearch) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	ns, err := s.resolve(qs)
+	if err != nil {
+		return iterator.NewError(err)
+	}
+	if IsNull(ns) {
+		return iterator.NewNull()
+	}
+	return ns.BuildIterator(qs)
+}
+func (s VectorSearch) Optimize(r Optimizer) (Shape, bool) {
+	if len(s.Vector) == 0 {
+		return nil, true
+	}
+	if r != nil {
+		return r.OptimizeShape(s)
+	}
+	return s, false
+}
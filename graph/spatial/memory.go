@@ -0,0 +1,187 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spatial
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/nodeindex"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
+)
+
+// DefaultCellsPerDegree is a default resolution of the memory index grid.
+const DefaultCellsPerDegree = 10
+
+func init() {
+	RegisterIndex(DefaultType, func(path string, opts graph.Options) (Index, error) {
+		n, err := opts.IntKey("cells_per_degree", DefaultCellsPerDegree)
+		if err != nil {
+			return nil, err
+		} else if n <= 0 {
+			return nil, errors.New("spatial: cells_per_degree must be positive")
+		}
+		return NewMemory(n), nil
+	})
+}
+
+var _ Index = (*memIndex)(nil)
+
+// NewMemory creates an in-memory index that groups points into a grid with a given number of cells per degree.
+func NewMemory(cellsPerDegree int) Index {
+	return &memIndex{res: float64(cellsPerDegree), docs: nodeindex.NewPostings()}
+}
+
+type cell struct {
+	lat, lng int32
+}
+
+type memIndex struct {
+	mu   sync.RWMutex
+	res  float64
+	docs *nodeindex.Postings // terms of *memDoc are cells
+}
+
+type memDoc struct {
+	node   quad.Value
+	fields map[quad.IRI][]geo.Point
+}
+
+func (m *memIndex) cellOf(p geo.Point) cell {
+	return cell{lat: int32(math.Floor(p.Lat * m.res)), lng: int32(math.Floor(p.Lng * m.res))}
+}
+
+func (m *memIndex) Update(ctx context.Context, docs []Document, del []quad.Value) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range del {
+		m.docs.Delete(quad.StringOf(v))
+	}
+	for _, doc := range docs {
+		var cells []interface{}
+		seen := make(map[cell]struct{})
+		for _, pts := range doc.Fields {
+			for _, p := range pts {
+				c := m.cellOf(p)
+				if _, ok := seen[c]; ok {
+					continue
+				}
+				seen[c] = struct{}{}
+				cells = append(cells, c)
+			}
+		}
+		m.docs.Put(quad.StringOf(doc.Node), &memDoc{node: doc.Node, fields: doc.Fields}, cells)
+	}
+	return nil
+}
+
+// candidates returns keys of documents that may have points inside the bounding box.
+func (m *memIndex) candidates(r geo.Rect) map[string]struct{} {
+	min, max := m.cellOf(r.Min), m.cellOf(r.Max)
+	n := (int64(max.lat) - int64(min.lat) + 1) * (int64(max.lng) - int64(min.lng) + 1)
+	out := make(map[string]struct{})
+	if n > int64(m.docs.NumTerms()) {
+		// the region covers more cells than there are in the index
+		m.docs.Terms(func(t interface{}, set map[string]struct{}) {
+			c := t.(cell)
+			if c.lat < min.lat || c.lat > max.lat || c.lng < min.lng || c.lng > max.lng {
+				return
+			}
+			for key := range set {
+				out[key] = struct{}{}
+			}
+		})
+		return out
+	}
+	for lat := min.lat; lat <= max.lat; lat++ {
+		for lng := min.lng; lng <= max.lng; lng++ {
+			for key := range m.docs.Term(cell{lat: lat, lng: lng}) {
+				out[key] = struct{}{}
+			}
+		}
+	}
+	return out
+}
+
+func (m *memIndex) Search(ctx context.Context, q graph.GeoQuery) ([]graph.GeoHit, error) {
+	if q.Region == nil {
+		return nil, nil
+	}
+	var preds map[quad.IRI]struct{}
+	if len(q.Predicates) != 0 {
+		preds = make(map[quad.IRI]struct{}, len(q.Predicates))
+		for _, p := range q.Predicates {
+			preds[p] = struct{}{}
+		}
+	}
+	center := q.Region.Center()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var hits []graph.GeoHit
+	for key := range m.candidates(q.Region.Bounds()) {
+		d := m.docs.Get(key).(*memDoc)
+		dist, found := 0.0, false
+		for p, pts := range d.fields {
+			if preds != nil {
+				if _, ok := preds[p]; !ok {
+					continue
+				}
+			}
+			for _, pt := range pts {
+				if !q.Region.Contains(pt) {
+					continue
+				}
+				if dt := geo.Distance(center, pt); !found || dt < dist {
+					dist, found = dt, true
+				}
+			}
+		}
+		if found {
+			hits = append(hits, graph.GeoHit{Node: d.node, Distance: dist})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Distance != hits[j].Distance {
+			return hits[i].Distance < hits[j].Distance
+		}
+		return quad.StringOf(hits[i].Node) < quad.StringOf(hits[j].Node)
+	})
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+func (m *memIndex) Count(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(m.docs.Len()), nil
+}
+
+func (m *memIndex) Clear(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docs.Reset()
+	return nil
+}
+
+func (m *memIndex) Close() error {
+	return nil
+}
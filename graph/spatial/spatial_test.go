@@ -0,0 +1,120 @@
+package spatial_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/nodeindex/nodeindextest"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/spatial"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+const (
+	location = quad.IRI("location")
+	area     = quad.IRI("area")
+)
+
+var (
+	paris   = geo.Point{Lat: 48.8566, Lng: 2.3522}
+	louvre  = geo.Point{Lat: 48.8606, Lng: 2.3376}
+	eiffel  = geo.Point{Lat: 48.8584, Lng: 2.2945}
+	london  = geo.Point{Lat: 51.5074, Lng: -0.1278}
+	invalid = quad.TypedString{Value: "POINT(x y)", Type: geo.WKTLiteral}
+)
+
+var testQuads = []quad.Quad{
+	{Subject: quad.IRI("louvre"), Predicate: location, Object: louvre.TypedString()},
+	{Subject: quad.IRI("eiffel"), Predicate: location, Object: quad.TypedString{
+		Value: `{"type":"Point","coordinates":[2.2945,48.8584]}`, Type: geo.GeoJSONLiteral,
+	}},
+	{Subject: quad.IRI("london"), Predicate: location, Object: london.TypedString()},
+	{Subject: quad.IRI("london"), Predicate: quad.IRI("note"), Object: paris.TypedString()},
+	{Subject: quad.IRI("park"), Predicate: area, Object: geo.Polygon{
+		{Lat: 48.85, Lng: 2.33}, {Lat: 48.85, Lng: 2.34}, {Lat: 48.86, Lng: 2.34}, {Lat: 48.86, Lng: 2.33},
+	}.TypedString()},
+	{Subject: quad.IRI("broken"), Predicate: location, Object: invalid},
+}
+
+func search(t testing.TB, qs graph.QuadStore, r geo.Region, preds ...quad.IRI) []string {
+	hits, err := graph.SearchGeo(context.TODO(), qs, graph.GeoQuery{Region: r, Predicates: preds})
+	return nodeindextest.Nodes(t, hits, err)
+}
+
+func TestStore(t *testing.T) {
+	qs, err := spatial.New(memstore.New(), spatial.Config{Predicates: []quad.IRI{location, area}})
+	require.NoError(t, err)
+	defer qs.Close()
+	qw, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, qw.AddQuadSet(testQuads))
+	require.Equal(t, int64(4), nodeindextest.Documents(t, qs.Store))
+	require.True(t, graph.CapabilitiesOf(qs).GeoSearch)
+
+	near := geo.Circle{Point: paris, Radius: 5}
+	// ordered by distance
+	require.Equal(t, []string{"<louvre>", "<park>", "<eiffel>"}, search(t, qs, near))
+	require.Equal(t, []string{"<louvre>", "<eiffel>"}, search(t, qs, near, location))
+	require.Equal(t, []string{"<louvre>", "<park>", "<eiffel>", "<london>"}, search(t, qs, geo.Circle{Point: paris, Radius: 500}))
+	require.Equal(t, []string{"<london>"}, search(t, qs, geo.Polygon{{Lat: 50, Lng: -1}, {Lat: 50, Lng: 1}, {Lat: 52, Lng: 1}, {Lat: 52, Lng: -1}}))
+
+	require.NoError(t, qw.RemoveQuad(testQuads[0]))
+	require.Equal(t, []string{"<park>", "<eiffel>"}, search(t, qs, near))
+	require.Equal(t, int64(3), nodeindextest.Documents(t, qs.Store))
+
+	require.NoError(t, qs.Rebuild(context.TODO()))
+	require.Equal(t, int64(3), nodeindextest.Documents(t, qs.Store))
+}
+
+func TestStoreBuild(t *testing.T) {
+	mem := memstore.New(testQuads...)
+	_, err := graph.SearchGeo(context.TODO(), mem, graph.GeoQuery{Region: geo.Circle{Point: paris, Radius: 5}})
+	require.Equal(t, graph.ErrNotSupported, err)
+
+	qs, err := spatial.New(mem, spatial.Config{Predicates: []quad.IRI{location}})
+	require.NoError(t, err)
+	defer qs.Close()
+	require.Equal(t, int64(3), nodeindextest.Documents(t, qs.Store))
+
+	_, err = spatial.New(mem, spatial.Config{Type: "unknown", Predicates: []quad.IRI{location}})
+	require.Error(t, err)
+}
+
+func TestPathGeo(t *testing.T) {
+	mem := memstore.New(testQuads...)
+	idx, err := spatial.New(mem, spatial.Config{Predicates: []quad.IRI{location, area}})
+	require.NoError(t, err)
+	defer idx.Close()
+
+	for _, c := range []struct {
+		name string
+		qs   graph.QuadStore
+		near []quad.Value
+	}{
+		{"index", idx, []quad.Value{quad.IRI("park"), quad.IRI("louvre"), quad.IRI("eiffel")}},
+		// scan checks all predicates
+		{"scan", mem, []quad.Value{quad.IRI("park"), quad.IRI("louvre"), quad.IRI("eiffel"), quad.IRI("london")}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			run := func(p *path.Path) []quad.Value {
+				vals, err := p.Iterate(context.TODO()).AllValues(c.qs)
+				require.NoError(t, err)
+				return vals
+			}
+			require.ElementsMatch(t, []quad.Value{quad.IRI("louvre"), quad.IRI("eiffel")},
+				run(path.StartPath(c.qs).NearPoint(paris.Lat, paris.Lng, 5, location)))
+			require.ElementsMatch(t, c.near, run(path.StartPath(c.qs).NearPoint(paris.Lat, paris.Lng, 5)))
+			require.ElementsMatch(t, []quad.Value{quad.IRI("louvre")},
+				run(path.StartPath(c.qs, quad.IRI("louvre"), quad.IRI("london")).NearPoint(paris.Lat, paris.Lng, 5, location)))
+			require.ElementsMatch(t, []quad.Value{quad.IRI("park"), quad.IRI("louvre")},
+				run(path.StartPath(c.qs).WithinPolygon(geo.Polygon{{Lat: 48.84, Lng: 2.32}, {Lat: 48.84, Lng: 2.34}, {Lat: 48.87, Lng: 2.34}, {Lat: 48.87, Lng: 2.32}})))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Checker = (*QuadStore)(nil)
+
+// refsExpr calculates the number of references to a node from the quads table.
+var refsExpr = func() string {
+	var parts []string
+	for _, d := range quad.Directions {
+		parts = append(parts, `(SELECT COUNT(*) FROM quads WHERE `+d.String()+`_hash = nodes.hash)`)
+	}
+	return "(" + strings.Join(parts, " + ") + ")"
+}()
+
+// sqlCheck is a single consistency check with an optional repair statement.
+type sqlCheck struct {
+	kind   string
+	desc   string // format for the number of found problems
+	count  string
+	repair []string
+}
+
+func sqlChecks() []sqlCheck {
+	var checks []sqlCheck
+	for _, d := range quad.Directions {
+		cond := ` WHERE ` + d.String() + `_hash IS NOT NULL AND ` + d.String() + `_hash NOT IN (SELECT hash FROM nodes);`
+		checks = append(checks, sqlCheck{
+			kind:   graph.ProblemDanglingRef,
+			desc:   "%d quads reference missing " + d.String() + " nodes",
+			count:  `SELECT COUNT(*) FROM quads` + cond,
+			repair: []string{`DELETE FROM quads` + cond},
+		})
+	}
+	checks = append(checks, sqlCheck{
+		kind:  graph.ProblemRefCount,
+		desc:  "%d nodes have wrong reference counters",
+		count: `SELECT COUNT(*) FROM nodes WHERE refs <> ` + refsExpr + `;`,
+		repair: []string{
+			`UPDATE nodes SET refs = ` + refsExpr + ` WHERE refs <> ` + refsExpr + `;`,
+			`DELETE FROM nodes WHERE refs <= 0;`,
+		},
+	})
+	return checks
+}
+
+// Check verifies that all quads reference existing nodes and that reference counters of nodes are correct.
+//
+// With repair set, quads that reference missing nodes are removed, and reference counters are recalculated.
+func (qs *QuadStore) Check(ctx context.Context, repair bool) ([]graph.Problem, error) {
+	var (
+		problems []graph.Problem
+		fixes    []string
+	)
+	for _, c := range sqlChecks() {
+		var n int64
+		if err := qs.db.QueryRowContext(ctx, c.count).Scan(&n); err != nil {
+			return nil, err
+		} else if n == 0 {
+			continue
+		}
+		problems = append(problems, graph.Problem{Kind: c.kind, Desc: fmt.Sprintf(c.desc, n)})
+		fixes = append(fixes, c.repair...)
+	}
+	if !repair || len(problems) == 0 {
+		return problems, nil
+	}
+	tx, err := qs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return problems, err
+	}
+	defer tx.Rollback()
+	for _, q := range fixes {
+		if _, err = tx.ExecContext(ctx, q); err != nil {
+			return problems, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return problems, err
+	}
+	for i := range problems {
+		problems[i].Repaired = true
+	}
+	qs.RefreshStats(ctx)
+	return problems, nil
+}
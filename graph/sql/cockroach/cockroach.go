@@ -46,6 +46,9 @@ func init() {
 		RunTx:               runTxCockroach,
 		TxRetry:             retryTxCockroach,
 		NoSchemaChangesInTx: true,
+		DropIndex: func(name string) string {
+			return `DROP INDEX IF EXISTS quads@` + name + `;`
+		},
 	})
 }
 
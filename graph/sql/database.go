@@ -29,6 +29,7 @@ type Registration struct {
 	ConditionalIndexes bool   // database supports conditional indexes
 	FillFactor         bool   // database supports fill percent on indexes
 	NoForeignKeys      bool   // database has no support for FKs
+	ValueIndexes       bool   // create indexes on typed value columns of nodes table
 
 	QueryDialect
 	NoOffsetWithoutLimit bool // SELECT ... OFFSET can be used only with LIMIT
@@ -38,6 +39,7 @@ type Registration struct {
 	RunTx               func(tx *sql.Tx, nodes []graphlog.NodeUpdate, quads []graphlog.QuadUpdate, opts graph.IgnoreOpts) error
 	TxRetry             func(tx *sql.Tx, stmts func() error) error
 	NoSchemaChangesInTx bool
+	DropIndex           func(name string) string // statement that drops an index of quads table; DROP INDEX IF EXISTS is used if not set
 }
 
 func (r Registration) nodesTable() string {
@@ -113,19 +115,51 @@ func (r Registration) quadIndexes(options graph.Options) []string {
 			`ALTER TABLE quads ADD CONSTRAINT label_hash_fk FOREIGN KEY (label_hash) REFERENCES nodes (hash);`,
 		)
 	}
+	indexes = append(indexes, r.valueIndexes()...)
+	return append(indexes, r.lookupIndexes(options)...)
+}
+
+// valueIndexes returns statements that create indexes on typed value columns of nodes table.
+// They allow to run range comparisons on numbers and time values without a full scan.
+func (r Registration) valueIndexes() []string {
+	if !r.ValueIndexes {
+		return nil
+	}
+	cols := []string{"value_int", "value_float", "value_time"}
+	indexes := make([]string, 0, len(cols))
+	for _, c := range cols {
+		stmt := `CREATE INDEX nodes_` + c + `_index ON nodes (` + c + `)`
+		if r.ConditionalIndexes {
+			stmt += ` WHERE ` + c + ` IS NOT NULL`
+		}
+		indexes = append(indexes, stmt+`;`)
+	}
+	return indexes
+}
+
+// lookupIndexNames are names of non-unique indexes of quads table. They can be dropped during a bulk load.
+var lookupIndexNames = []string{"spo_index", "pos_index", "osp_index"}
+
+// lookupIndexes returns statements that create indexes listed in lookupIndexNames.
+func (r Registration) lookupIndexes(options graph.Options) []string {
 	if r.FillFactor {
 		factor, _ := options.IntKey("db_fill_factor", 50)
-		indexes = append(indexes,
+		return []string{
 			fmt.Sprintf(`CREATE INDEX spo_index ON quads (subject_hash) WITH (FILLFACTOR = %d);`, factor),
 			fmt.Sprintf(`CREATE INDEX pos_index ON quads (predicate_hash) WITH (FILLFACTOR = %d);`, factor),
 			fmt.Sprintf(`CREATE INDEX osp_index ON quads (object_hash) WITH (FILLFACTOR = %d);`, factor),
-		)
-	} else {
-		indexes = append(indexes,
-			`CREATE INDEX spo_index ON quads (subject_hash);`,
-			`CREATE INDEX pos_index ON quads (predicate_hash);`,
-			`CREATE INDEX osp_index ON quads (object_hash);`,
-		)
+		}
 	}
-	return indexes
+	return []string{
+		`CREATE INDEX spo_index ON quads (subject_hash);`,
+		`CREATE INDEX pos_index ON quads (predicate_hash);`,
+		`CREATE INDEX osp_index ON quads (object_hash);`,
+	}
+}
+
+func (r Registration) dropIndex(name string) string {
+	if r.DropIndex != nil {
+		return r.DropIndex(name)
+	}
+	return `DROP INDEX IF EXISTS ` + name + `;`
 }
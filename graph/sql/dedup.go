@@ -0,0 +1,66 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var _ graph.Deduplicator = (*QuadStore)(nil)
+
+// dupQuadsCond matches quads that have the same values as a quad with a lower horizon.
+// Derived table is required by MySQL, since it cannot select from a table that is being modified.
+const dupQuadsCond = ` WHERE horizon NOT IN (SELECT h FROM (
+	SELECT MIN(horizon) AS h FROM quads GROUP BY subject_hash, predicate_hash, object_hash, label_hash
+) AS keep)`
+
+// Dedup removes quads that have the same values as a quad with a lower horizon,
+// and nodes that are not referenced by any quad.
+//
+// Reclaimed space is not reported.
+func (qs *QuadStore) Dedup(ctx context.Context, dryRun bool) (graph.DedupStats, error) {
+	var st graph.DedupStats
+	if err := qs.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM quads`+dupQuadsCond+`;`).Scan(&st.Quads); err != nil {
+		return st, err
+	}
+	// duplicates reference the same nodes as the quads that are kept
+	if err := qs.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM nodes WHERE `+refsExpr+` = 0;`).Scan(&st.Nodes); err != nil {
+		return st, err
+	}
+	if dryRun || (st.Quads == 0 && st.Nodes == 0) {
+		return st, nil
+	}
+	tx, err := qs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return graph.DedupStats{}, err
+	}
+	defer tx.Rollback()
+	for _, q := range []string{
+		`DELETE FROM quads` + dupQuadsCond + `;`,
+		`UPDATE nodes SET refs = ` + refsExpr + ` WHERE refs <> ` + refsExpr + `;`,
+		`DELETE FROM nodes WHERE refs <= 0;`,
+	} {
+		if _, err = tx.ExecContext(ctx, q); err != nil {
+			return graph.DedupStats{}, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return graph.DedupStats{}, err
+	}
+	qs.RefreshStats(ctx)
+	return st, nil
+}
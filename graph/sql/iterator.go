@@ -45,7 +45,9 @@ func (qs *QuadStore) Query(ctx context.Context, s Shape) (*sql.Rows, error) {
 	}
 	b := NewBuilder(qs.flavor.QueryDialect)
 	qu := s.SQL(b)
+	ctx, end := graph.StartSpan(ctx, "sql.query")
 	rows, err := qs.db.QueryContext(ctx, qu, vals...)
+	end(err)
 	if err != nil {
 		return nil, fmt.Errorf("sql query failed: %v\nquery: %v", err, qu)
 	}
@@ -37,6 +37,9 @@ func init() {
 		},
 		Estimated: nil,
 		RunTx:     runTxMysql,
+		DropIndex: func(name string) string {
+			return `DROP INDEX ` + name + ` ON quads;`
+		},
 	})
 }
 
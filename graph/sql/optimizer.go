@@ -32,6 +32,7 @@ type Optimizer struct {
 	tableInd int
 
 	regexpOp             CmpOp
+	regexpConv           func(string) (string, bool)
 	noOffsetWithoutLimit bool // blame mysql
 }
 
@@ -39,6 +40,11 @@ func (opt *Optimizer) SetRegexpOp(op CmpOp) {
 	opt.regexpOp = op
 }
 
+// SetRegexpConv sets a function that converts regular expressions to the database syntax.
+func (opt *Optimizer) SetRegexpConv(conv func(string) (string, bool)) {
+	opt.regexpConv = conv
+}
+
 func (opt *Optimizer) NoOffsetWithoutLimit() {
 	opt.noOffsetWithoutLimit = true
 }
@@ -215,8 +221,11 @@ func (opt *Optimizer) optimizeLookup(s shape.Lookup) (shape.Shape, bool) {
 	return *sel, true
 }
 
-func convRegexp(re string) string {
-	return re // TODO: convert regular expression
+func (opt *Optimizer) convRegexp(re string) (string, bool) {
+	if opt.regexpConv == nil {
+		return re, true
+	}
+	return opt.regexpConv(re)
 }
 
 func (opt *Optimizer) optimizeFilter(from shape.Shape, f shape.ValueFilter) ([]Where, []Value, bool) {
@@ -240,15 +249,23 @@ func (opt *Optimizer) optimizeFilter(from shape.Shape, f shape.ValueFilter) ([]W
 		if opt.regexpOp == "" {
 			return nil, nil, false
 		}
+		re, ok := opt.convRegexp(f.Regexp())
+		if !ok {
+			return nil, nil, false
+		}
 		return []Where{
 				{Field: "value_string", Op: opt.regexpOp, Value: Placeholder{}},
 			}, []Value{
-				StringVal(convRegexp(f.Regexp())),
+				StringVal(re),
 			}, true
 	case shape.Regexp:
 		if opt.regexpOp == "" {
 			return nil, nil, false
 		}
+		re, ok := opt.convRegexp(f.Re.String())
+		if !ok {
+			return nil, nil, false
+		}
 		where := []Where{
 			{Field: "value_string", Op: opt.regexpOp, Value: Placeholder{}},
 		}
@@ -259,7 +276,7 @@ func (opt *Optimizer) optimizeFilter(from shape.Shape, f shape.ValueFilter) ([]W
 			}...)
 		}
 		return where, []Value{
-			StringVal(convRegexp(f.Re.String())),
+			StringVal(re),
 		}, true
 	default:
 		return nil, nil, false
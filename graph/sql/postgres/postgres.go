@@ -18,6 +18,7 @@ const Type = "postgres"
 
 var QueryDialect = csql.QueryDialect{
 	RegexpOp:   "~",
+	ConvRegexp: ConvRegexp,
 	FieldQuote: pq.QuoteIdentifier,
 	Placeholder: func(n int) string {
 		return fmt.Sprintf("$%d", n)
@@ -33,6 +34,7 @@ func init() {
 		TimeType:           `timestamp with time zone`,
 		QueryDialect:       QueryDialect,
 		ConditionalIndexes: true,
+		ValueIndexes:       true,
 		FillFactor:         true,
 		Error:              ConvError,
 		Estimated: func(table string) string {
@@ -0,0 +1,222 @@
+package postgres
+
+import (
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxRepeat is the maximal bound of counted repetition accepted by PostgreSQL.
+const maxRepeat = 255
+
+// ConvRegexp converts a regular expression in RE2 syntax to the PostgreSQL advanced regular expression (ARE).
+//
+// It returns false if the expression uses features that can't be expressed in ARE in the default (non-newline-sensitive)
+// mode, like multi-line anchors. The converted expression is only guaranteed to match the same strings, submatches
+// and match positions are not preserved.
+func ConvRegexp(re string) (string, bool) {
+	r, err := syntax.Parse(re, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	var buf strings.Builder
+	if !writeRegexp(&buf, r) {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// isAtom checks if the expression can be followed by a quantifier without a grouping.
+func isAtom(r *syntax.Regexp) bool {
+	switch r.Op {
+	case syntax.OpLiteral:
+		return len(r.Rune) == 1
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL, syntax.OpCapture:
+		return true
+	}
+	return false
+}
+
+func writeGroup(buf *strings.Builder, r *syntax.Regexp, group bool) bool {
+	if !group {
+		return writeRegexp(buf, r)
+	}
+	buf.WriteString("(?:")
+	if !writeRegexp(buf, r) {
+		return false
+	}
+	buf.WriteString(")")
+	return true
+}
+
+func writeRegexp(buf *strings.Builder, r *syntax.Regexp) bool {
+	switch r.Op {
+	case syntax.OpEmptyMatch:
+		buf.WriteString("(?:)")
+	case syntax.OpLiteral:
+		for _, c := range r.Rune {
+			if r.Flags&syntax.FoldCase != 0 && unicode.SimpleFold(c) != c {
+				writeFold(buf, c)
+			} else {
+				writeRune(buf, c)
+			}
+		}
+	case syntax.OpCharClass:
+		writeClass(buf, r.Rune)
+	case syntax.OpAnyChar:
+		// matches newlines in the default mode
+		buf.WriteString(".")
+	case syntax.OpAnyCharNotNL:
+		buf.WriteString(`[^\n]`)
+	case syntax.OpBeginText:
+		buf.WriteString("^")
+	case syntax.OpEndText:
+		buf.WriteString("$")
+	case syntax.OpWordBoundary:
+		buf.WriteString(`\y`)
+	case syntax.OpNoWordBoundary:
+		buf.WriteString(`\Y`)
+	case syntax.OpCapture:
+		// submatches are not used, thus all groups are non-capturing
+		return writeGroup(buf, r.Sub[0], true)
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		sub := r.Sub[0]
+		if !writeGroup(buf, sub, !isAtom(sub)) {
+			return false
+		}
+		switch r.Op {
+		case syntax.OpStar:
+			buf.WriteString("*")
+		case syntax.OpPlus:
+			buf.WriteString("+")
+		case syntax.OpQuest:
+			buf.WriteString("?")
+		case syntax.OpRepeat:
+			if r.Min > maxRepeat || r.Max > maxRepeat {
+				return false
+			}
+			buf.WriteString("{" + strconv.Itoa(r.Min))
+			if r.Max < 0 {
+				buf.WriteString(",")
+			} else if r.Max != r.Min {
+				buf.WriteString("," + strconv.Itoa(r.Max))
+			}
+			buf.WriteString("}")
+		}
+		if r.Flags&syntax.NonGreedy != 0 {
+			buf.WriteString("?")
+		}
+	case syntax.OpConcat:
+		for _, sub := range r.Sub {
+			if !writeGroup(buf, sub, sub.Op == syntax.OpAlternate) {
+				return false
+			}
+		}
+	case syntax.OpAlternate:
+		for i, sub := range r.Sub {
+			if i != 0 {
+				buf.WriteString("|")
+			}
+			if !writeRegexp(buf, sub) {
+				return false
+			}
+		}
+	default:
+		// OpNoMatch, OpBeginLine, OpEndLine
+		return false
+	}
+	return true
+}
+
+// writeFold writes a class that matches all case variants of the rune.
+func writeFold(buf *strings.Builder, c rune) {
+	buf.WriteString("[")
+	for f := c; ; {
+		writeClassRune(buf, f)
+		if f = unicode.SimpleFold(f); f == c {
+			break
+		}
+	}
+	buf.WriteString("]")
+}
+
+// writeClass writes a character class defined by sorted pairs of rune ranges.
+// Classes that include the last code point are written in a negated form.
+func writeClass(buf *strings.Builder, ranges []rune) {
+	negate := len(ranges) != 0 && ranges[len(ranges)-1] == unicode.MaxRune
+	if negate {
+		// find a complement of ranges
+		var inv []rune
+		next := rune(0)
+		for i := 0; i < len(ranges); i += 2 {
+			if ranges[i] > next {
+				inv = append(inv, next, ranges[i]-1)
+			}
+			next = ranges[i+1] + 1
+		}
+		if len(inv) == 0 {
+			buf.WriteString(".")
+			return
+		}
+		ranges = inv
+		buf.WriteString("[^")
+	} else {
+		buf.WriteString("[")
+	}
+	for i := 0; i < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if lo == 0 {
+			// PostgreSQL strings can't contain zero bytes
+			if lo = 1; hi < lo {
+				continue
+			}
+		}
+		writeClassRune(buf, lo)
+		if hi != lo {
+			buf.WriteString("-")
+			writeClassRune(buf, hi)
+		}
+	}
+	buf.WriteString("]")
+}
+
+func writeRune(buf *strings.Builder, c rune) {
+	if strings.ContainsRune(`\.+*?()|[]{}^$`, c) {
+		buf.WriteString(`\`)
+		buf.WriteRune(c)
+		return
+	}
+	writePrintable(buf, c)
+}
+
+func writeClassRune(buf *strings.Builder, c rune) {
+	if strings.ContainsRune(`\[]^-`, c) {
+		buf.WriteString(`\`)
+		buf.WriteRune(c)
+		return
+	}
+	writePrintable(buf, c)
+}
+
+func writePrintable(buf *strings.Builder, c rune) {
+	switch {
+	case c == '\n':
+		buf.WriteString(`\n`)
+	case c == '\t':
+		buf.WriteString(`\t`)
+	case unicode.IsPrint(c):
+		buf.WriteRune(c)
+	case c <= 0xffff:
+		buf.WriteString(`\u` + pad(strconv.FormatInt(int64(c), 16), 4))
+	default:
+		buf.WriteString(`\U` + pad(strconv.FormatInt(int64(c), 16), 8))
+	}
+}
+
+func pad(s string, n int) string {
+	if len(s) < n {
+		s = strings.Repeat("0", n-len(s)) + s
+	}
+	return s
+}
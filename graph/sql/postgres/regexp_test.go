@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"testing"
+)
+
+var convRegexpCases = []struct {
+	re  string
+	exp string
+	bad bool
+}{
+	{re: `abc`, exp: `abc`},
+	{re: `^a.c$`, exp: `^a[^\n]c$`},
+	{re: `(?s)a.c`, exp: `a.c`},
+	{re: `a\.b\+`, exp: `a\.b\+`},
+	{re: `(ab)+c?`, exp: `(?:ab)+c?`},
+	{re: `ab+?`, exp: `ab+?`},
+	{re: `x(a|bc)y`, exp: `x(?:a|bc)y`},
+	{re: `a{2,5}b{3}c{1,}`, exp: `a{2,5}b{3}c{1,}`},
+	{re: `\bfoo\B`, exp: `\yfoo\Y`},
+	{re: `[a-c\]-]`, exp: `[\-\]a-c]`},
+	{re: `[^a-z]`, exp: `[^a-z]`},
+	{re: `\d+`, exp: `[0-9]+`},
+	{re: `(?i)ab`, exp: `[Aa][Bb]`},
+	{re: `(?P<name>a)`, exp: `(?:a)`},
+	{re: `\x01`, exp: `\u0001`},
+	{re: `(?m)^a$`, bad: true},
+	{re: `a{300}`, bad: true},
+	{re: `a(`, bad: true},
+}
+
+func TestConvRegexp(t *testing.T) {
+	for _, c := range convRegexpCases {
+		got, ok := ConvRegexp(c.re)
+		if c.bad {
+			if ok {
+				t.Errorf("expected %q to fail, got %q", c.re, got)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("failed to convert %q", c.re)
+		} else if got != c.exp {
+			t.Errorf("unexpected result for %q: got %q, expected %q", c.re, got, c.exp)
+		}
+	}
+}
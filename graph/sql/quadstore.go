@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
@@ -117,9 +118,12 @@ type QuadStore struct {
 	sizes        *lru.Cache
 	noSizes      bool
 	useEstimates bool
+	lookup       []string // statements that create lookup indexes
+	notify       graph.Notifier
 
 	mu   sync.RWMutex
 	size int64
+	seq  int64 // number of transactions applied by this instance; used as a horizon of changes
 }
 
 func connect(addr string, flavor string, opts graph.Options) (*sql.DB, error) {
@@ -261,8 +265,10 @@ func New(typ string, addr string, options graph.Options) (graph.QuadStore, error
 		sizes:   lru.New(1024),
 		ids:     lru.New(1024),
 		noSizes: true, // Skip size checking by default.
+		lookup:  fl.lookupIndexes(options),
 	}
 	qs.opt.SetRegexpOp(qs.flavor.RegexpOp)
+	qs.opt.SetRegexpConv(qs.flavor.ConvRegexp)
 	if qs.flavor.NoOffsetWithoutLimit {
 		qs.opt.NoOffsetWithoutLimit()
 	}
@@ -278,6 +284,35 @@ func New(typ string, addr string, options graph.Options) (graph.QuadStore, error
 	return qs, nil
 }
 
+var _ graph.BulkIndexer = (*QuadStore)(nil)
+
+// BeginBulkLoad implements graph.BulkIndexer. Lookup indexes of quads table are dropped and created again
+// when the load ends. Unique indexes and foreign keys are kept, since they are required for deduplication.
+func (qs *QuadStore) BeginBulkLoad(ctx context.Context) (func(ctx context.Context) error, error) {
+	create := func(ctx context.Context, stmts []string) error {
+		for _, stmt := range stmts {
+			if _, err := qs.db.ExecContext(ctx, stmt); err != nil {
+				return qs.flavor.Error(err)
+			}
+		}
+		return nil
+	}
+	for i, name := range lookupIndexNames {
+		if _, err := qs.db.ExecContext(ctx, qs.flavor.dropIndex(name)); err != nil {
+			err = qs.flavor.Error(err)
+			// restore indexes that were already dropped
+			if err2 := create(ctx, qs.lookup[:i]); err2 != nil {
+				clog.Errorf("cannot restore indexes: %v", err2)
+			}
+			return nil, err
+		}
+	}
+	return func(ctx context.Context) error {
+		clog.Infof("creating indexes")
+		return create(ctx, qs.lookup)
+	}, nil
+}
+
 func escapeNullByte(s string) string {
 	return strings.Replace(s, "\u0000", `\x00`, -1)
 }
@@ -337,6 +372,10 @@ func NodeValues(h NodeHash, v quad.Value) (ValueType, []interface{}, error) {
 }
 
 func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error {
+	in, err := graph.ResolveDeltas(context.TODO(), qs, in)
+	if err != nil {
+		return err
+	}
 	// first calculate values ref deltas
 	deltas := graphlog.SplitDeltas(in)
 
@@ -357,7 +396,12 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error
 		p[i] = qs.flavor.Placeholder(i + 1)
 	}
 
+	notify := qs.notify.Active()
+	var missing map[int]struct{} // deltas that deleted nothing
 	err = retry(tx, func() error {
+		if notify {
+			missing = make(map[int]struct{})
+		}
 		err = qs.flavor.RunTx(tx, deltas.IncNode, deltas.QuadAdd, opts)
 		if err != nil {
 			return err
@@ -403,6 +447,9 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error
 					// TODO: reference to delta
 					return &graph.DeltaError{Err: graph.ErrQuadNotExist}
 				}
+				if notify {
+					missing[d.Ind] = struct{}{}
+				}
 				// revert counters for all directions of this quad
 				for _, dir := range quad.Directions {
 					if h := d.Quad.Get(dir); h.Valid() {
@@ -443,10 +490,34 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error
 		return err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return err
+	}
 	qs.mu.Lock()
 	qs.size = -1 // TODO(barakmich): Sync size with writes.
+	qs.seq++
+	seq := qs.seq
 	qs.mu.Unlock()
-	return tx.Commit()
+	if notify {
+		now := time.Now()
+		changes := make([]graph.Change, 0, len(in))
+		for i, d := range in {
+			if _, ok := missing[i]; !ok {
+				changes = append(changes, graph.Change{Delta: d, Horizon: seq, Timestamp: now})
+			}
+		}
+		qs.notify.Notify(changes)
+	}
+	return nil
+}
+
+var _ graph.Subscriber = (*QuadStore)(nil)
+
+// Subscribe implements graph.Subscriber. Only changes applied through this instance are delivered,
+// and quads ignored as duplicates on insert are still reported as added.
+// Horizon of a change is the number of transactions applied by this instance.
+func (qs *QuadStore) Subscribe(ctx context.Context) (*graph.Subscription, error) {
+	return qs.notify.Subscribe(ctx), nil
 }
 
 func (qs *QuadStore) Quad(val graph.Value) quad.Quad {
@@ -655,9 +726,35 @@ func (qs *QuadStore) Size() int64 {
 }
 
 func (qs *QuadStore) Close() error {
+	qs.notify.CloseAll(graph.ErrStoreClosed)
 	return qs.db.Close()
 }
 
+var _ graph.CapabilityReporter = (*QuadStore)(nil)
+
+// Capabilities implements graph.CapabilityReporter. Regular expressions are pushed down
+// only if the SQL flavor supports them.
+func (qs *QuadStore) Capabilities() graph.Capabilities {
+	return graph.Capabilities{
+		Transactions:  true,
+		RegexPushdown: qs.flavor.RegexpOp != "",
+	}
+}
+
+// RefreshStats drops cached size estimates, so they will be recalculated on the next use.
+func (qs *QuadStore) RefreshStats(ctx context.Context) error {
+	qs.mu.Lock()
+	qs.size = -1
+	qs.mu.Unlock()
+	qs.sizes.Purge()
+	return nil
+}
+
+// Ping checks the connection to the database.
+func (qs *QuadStore) Ping(ctx context.Context) error {
+	return qs.db.PingContext(ctx)
+}
+
 func (qs *QuadStore) QuadDirection(in graph.Value, d quad.Direction) graph.Value {
 	return NodeHash{in.(QuadHashes).Get(d)}
 }
@@ -0,0 +1,108 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.PatternRemover = (*QuadStore)(nil)
+
+// patternCond builds a WHERE condition for quads matching the pattern.
+// Placeholders are numbered starting from start.
+func (qs *QuadStore) patternCond(pattern quad.Quad, start int) (string, []interface{}) {
+	var (
+		parts []string
+		args  []interface{}
+	)
+	for _, d := range quad.Directions {
+		v := pattern.Get(d)
+		if v == nil {
+			continue
+		}
+		args = append(args, HashOf(v).SQLValue())
+		parts = append(parts, d.String()+`_hash = `+qs.flavor.Placeholder(start+len(args)-1))
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+	return ` WHERE ` + strings.Join(parts, ` AND `), args
+}
+
+// RemoveQuadsMatching removes all quads matching the pattern in a single transaction.
+// Reference counters of nodes are updated by the database, without reading removed quads.
+func (qs *QuadStore) RemoveQuadsMatching(ctx context.Context, pattern quad.Quad) (int64, error) {
+	tx, err := qs.db.BeginTx(ctx, nil)
+	if err != nil {
+		clog.Errorf("couldn't begin write transaction: %v", err)
+		return 0, err
+	}
+	retry := qs.flavor.TxRetry
+	if retry == nil {
+		retry = func(tx *sql.Tx, stmts func() error) error {
+			return stmts()
+		}
+	}
+	var n int64
+	err = retry(tx, func() error {
+		for _, d := range quad.Directions {
+			// condition is used twice: to count references and to select nodes to update
+			cond, args := qs.patternCond(pattern, 1)
+			cond2, args2 := qs.patternCond(pattern, len(args)+1)
+			col := d.String() + `_hash`
+			and := ` WHERE `
+			if cond != "" {
+				and = cond + ` AND `
+			}
+			_, err := tx.ExecContext(ctx, `UPDATE nodes SET refs = refs - (SELECT COUNT(*) FROM quads`+and+col+` = nodes.hash)`+
+				` WHERE hash IN (SELECT `+col+` FROM quads`+cond2+`);`, append(args, args2...)...)
+			if err != nil {
+				clog.Errorf("couldn't exec UPDATE nodes statement: %v", err)
+				return err
+			}
+		}
+		cond, args := qs.patternCond(pattern, 1)
+		res, err := tx.ExecContext(ctx, `DELETE FROM quads`+cond+`;`, args...)
+		if err != nil {
+			clog.Errorf("couldn't exec DELETE statement: %v", err)
+			return err
+		}
+		if n, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM nodes WHERE refs <= 0;`)
+		if err != nil {
+			clog.Errorf("couldn't exec DELETE nodes statement: %v", err)
+		}
+		return err
+	})
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	qs.mu.Lock()
+	qs.size = -1
+	qs.mu.Unlock()
+	return n, nil
+}
@@ -38,6 +38,9 @@ type QueryDialect struct {
 	RegexpOp    CmpOp
 	FieldQuote  func(string) string
 	Placeholder func(int) string
+	// ConvRegexp converts a regular expression in RE2 syntax to the one accepted by RegexpOp.
+	// It returns false if the expression can't be converted. Expressions are passed as-is if it's not set.
+	ConvRegexp func(string) (string, bool)
 }
 
 func NewBuilder(d QueryDialect) *Builder {
@@ -299,7 +302,7 @@ func (s Select) Columns() []string {
 }
 
 func (s Select) BuildIterator(qs graph.QuadStore) graph.Iterator {
-	sq, ok := qs.(*QuadStore)
+	sq, ok := graph.Unwrap(qs).(*QuadStore)
 	if !ok {
 		return iterator.NewError(fmt.Errorf("not a SQL quadstore: %T", qs))
 	}
@@ -377,3 +377,35 @@ func TestSQLShapes(t *testing.T) {
 		})
 	}
 }
+
+func TestSQLRegexpFilter(t *testing.T) {
+	dialect := DefaultDialect
+	dialect.Placeholder = func(i int) string {
+		return fmt.Sprintf("$%d", i)
+	}
+	s := shape.Filter{
+		From: shape.AllNodes{},
+		Filters: []shape.ValueFilter{
+			shape.Wildcard{Pattern: "a%"},
+		},
+	}
+	opt := NewOptimizer()
+	opt.SetRegexpOp("~")
+	opt.SetRegexpConv(func(re string) (string, bool) {
+		return "conv:" + re, true
+	})
+	out, ok := s.Optimize(opt)
+	require.True(t, ok)
+	sq, ok := out.(Shape)
+	require.True(t, ok, "%#v", out)
+	require.Equal(t, `SELECT hash AS `+tagNode+` FROM nodes WHERE value_string ~ $1`, sq.SQL(NewBuilder(dialect)))
+	require.Equal(t, []Value{StringVal("conv:^a")}, sq.Args())
+
+	// filters that can't be converted are left to the iterator
+	opt.SetRegexpConv(func(re string) (string, bool) {
+		return "", false
+	})
+	out, _ = s.Optimize(opt)
+	_, ok = out.(shape.Filter)
+	require.True(t, ok, "%#v", out)
+}
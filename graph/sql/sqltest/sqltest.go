@@ -1,6 +1,7 @@
 package sqltest
 
 import (
+	"context"
 	"testing"
 	"unicode/utf8"
 
@@ -38,6 +39,14 @@ func TestAll(t *testing.T, typ string, fnc DatabaseFunc, c *Config) {
 		t.Parallel()
 		testZeroRune(t, create)
 	})
+	t.Run("check", func(t *testing.T) {
+		t.Parallel()
+		testCheck(t, create)
+	})
+	t.Run("dedup", func(t *testing.T) {
+		t.Parallel()
+		testDedup(t, create)
+	})
 }
 
 func BenchmarkAll(t *testing.B, typ string, fnc DatabaseFunc, c *Config) {
@@ -90,3 +99,29 @@ func testZeroRune(t testing.TB, create testutil.DatabaseFunc) {
 	require.NoError(t, err)
 	require.Equal(t, obj, qs.NameOf(qs.ValueOf(quad.Raw(obj.String()))))
 }
+
+func testCheck(t testing.TB, create testutil.DatabaseFunc) {
+	qs, opts, closer := create(t)
+	defer closer()
+
+	w := testutil.MakeWriter(t, qs, opts, graphtest.MakeQuadSet()...)
+	err := w.RemoveQuad(quad.Make("A", "follows", "B", nil))
+	require.NoError(t, err)
+
+	problems, err := graph.Check(context.TODO(), qs, false)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+}
+
+func testDedup(t testing.TB, create testutil.DatabaseFunc) {
+	qs, opts, closer := create(t)
+	defer closer()
+
+	w := testutil.MakeWriter(t, qs, opts, graphtest.MakeQuadSet()...)
+	err := w.RemoveQuad(quad.Make("A", "follows", "B", nil))
+	require.NoError(t, err)
+
+	st, err := graph.Dedup(context.TODO(), qs, false)
+	require.NoError(t, err)
+	require.Equal(t, graph.DedupStats{}, st)
+}
@@ -0,0 +1,171 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Stats is a summary of the data stored in QuadStore.
+type Stats struct {
+	Quads int64 `json:"quads"`
+	Nodes int64 `json:"nodes"`
+	// Exact is set if Quads and Nodes are exact numbers, and not estimates.
+	Exact bool `json:"exact"`
+	// Predicates and ValueTypes are always calculated for exact statistics. For estimated statistics,
+	// they are only set if the backend keeps sampled statistics.
+	//
+	// Predicates is a number of quads for each predicate, indexed by predicate value in N-Quads notation.
+	Predicates map[string]int64 `json:"predicates"`
+	// ValueTypes is a number of nodes of each type, indexed by the names returned by ValueType.
+	ValueTypes map[string]int64 `json:"value_types"`
+	// Storage is a size in bytes of internal structures of the backend. It is empty if the backend does not report it.
+	Storage map[string]int64 `json:"storage,omitempty"`
+}
+
+// StatsCollector is an optional interface for QuadStores that can calculate statistics faster than
+// by iterating over all quads, or that can report the size of internal structures.
+type StatsCollector interface {
+	// Stats returns statistics of the store. If exact is false, the store may return estimated
+	// numbers of quads and nodes without calculating other fields. If exact is true, statistics must be
+	// recalculated, and statistics cached for the query optimizer must be refreshed.
+	Stats(ctx context.Context, exact bool) (Stats, error)
+}
+
+// StatsOf returns statistics of QuadStore. If exact is false, the number of quads and nodes may be estimated,
+// which is cheap for most backends. Exact statistics are always recalculated, thus it may take a while.
+//
+// If QuadStore does not implement StatsCollector, estimates are taken from the sizes of QuadsAllIterator
+// and NodesAllIterator, and exact statistics are calculated with IterateStats after refreshing
+// statistics cached by StatsRefresher.
+func StatsOf(ctx context.Context, qs QuadStore, exact bool) (Stats, error) {
+	if c, ok := Unwrap(qs).(StatsCollector); ok {
+		return c.Stats(ctx, exact)
+	}
+	if !exact {
+		return EstimateStats(qs), nil
+	}
+	if err := RefreshStats(ctx, qs); err != nil && err != ErrNotSupported {
+		return Stats{}, err
+	}
+	return IterateStats(ctx, qs)
+}
+
+// CollectStats calculates exact statistics of QuadStore. It is the same as StatsOf with exact set to true.
+func CollectStats(ctx context.Context, qs QuadStore) (Stats, error) {
+	return StatsOf(ctx, qs, true)
+}
+
+// EstimateStats returns the number of quads and nodes reported by QuadsAllIterator and NodesAllIterator.
+// It can be used by backends to implement StatsCollector.
+func EstimateStats(qs QuadStore) Stats {
+	it := qs.QuadsAllIterator()
+	quads, qexact := it.Size()
+	it.Close()
+	it = qs.NodesAllIterator()
+	nodes, nexact := it.Size()
+	it.Close()
+	return Stats{Quads: quads, Nodes: nodes, Exact: qexact && nexact}
+}
+
+// ValueType returns a name of the value type used in Stats.
+func ValueType(v quad.Value) string {
+	switch v.(type) {
+	case quad.IRI:
+		return "iri"
+	case quad.BNode:
+		return "bnode"
+	case quad.String:
+		return "string"
+	case quad.LangString:
+		return "lang_string"
+	case quad.TypedString:
+		return "typed_string"
+	case quad.Int:
+		return "int"
+	case quad.Float:
+		return "float"
+	case quad.Bool:
+		return "bool"
+	case quad.Time:
+		return "time"
+	}
+	return "other"
+}
+
+// IterateStats calculates statistics by iterating over all quads and nodes of QuadStore.
+// It can be used by backends to implement StatsCollector.
+func IterateStats(ctx context.Context, qs QuadStore) (Stats, error) {
+	st := Stats{
+		Exact:      true,
+		Predicates: make(map[string]int64),
+		ValueTypes: make(map[string]int64),
+	}
+	preds := make(map[interface{}]int64)
+	var pvals []Value
+	it := qs.QuadsAllIterator()
+	for it.Next(ctx) {
+		st.Quads++
+		p := qs.QuadDirection(it.Result(), quad.Predicate)
+		k := ToKey(p)
+		if _, ok := preds[k]; !ok {
+			pvals = append(pvals, p)
+		}
+		preds[k]++
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		return st, err
+	}
+	names, err := ValuesOf(ctx, qs, pvals)
+	if err != nil {
+		return st, err
+	}
+	for i, p := range pvals {
+		st.Predicates[quad.StringOf(names[i])] += preds[ToKey(p)]
+	}
+
+	it = qs.NodesAllIterator()
+	defer it.Close()
+	for it.Next(ctx) {
+		st.Nodes++
+		st.ValueTypes[ValueType(qs.NameOf(it.Result()))]++
+	}
+	return st, it.Err()
+}
+
+// Estimator is an optional interface for QuadStores that can cheaply estimate cardinalities, for example
+// from index statistics or from sampled statistics. Estimates are used by the query planner to pick the join order.
+//
+// Methods must not read the whole store, since they are called for each query.
+type Estimator interface {
+	// EstimateNodes returns an estimated number of nodes of a given value type (see ValueType),
+	// or the total number of nodes if the type is empty. It returns false if the number is unknown.
+	EstimateNodes(typ string) (int64, bool)
+	// EstimateQuads returns an estimated number of quads that have a node v on direction d.
+	// It returns false if the number is unknown.
+	EstimateQuads(d quad.Direction, v Value) (int64, bool)
+}
+
+// EstimatorOf returns an Estimator implemented by QuadStore, or nil if it cannot estimate cardinalities.
+func EstimatorOf(qs QuadStore) Estimator {
+	if e, ok := Unwrap(qs).(Estimator); ok {
+		return e
+	}
+	return nil
+}
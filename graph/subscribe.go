@@ -0,0 +1,211 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// ErrSlowSubscriber is returned by Subscription.Err if the subscriber did not keep up with changes.
+var ErrSlowSubscriber = errors.New("subscriber is too slow, changes were dropped")
+
+// ErrStoreClosed is returned by Subscription.Err if the store was closed.
+var ErrStoreClosed = errors.New("quad store is closed")
+
+// subscriptionBuffer is the number of changes buffered for each subscriber.
+const subscriptionBuffer = 1024
+
+// Subscriber is an optional interface for QuadStores that can deliver applied deltas to in-process consumers.
+type Subscriber interface {
+	// Subscribe starts delivering changes applied to the store after the call.
+	// Subscription is closed when the context is canceled.
+	Subscribe(ctx context.Context) (*Subscription, error)
+}
+
+// Subscription is a stream of changes applied to the store.
+//
+// Horizon of each change is set to the horizon of the store after the change was applied,
+// thus changes applied in the same batch have the same horizon.
+type Subscription struct {
+	// C receives changes in the order they were applied. It is closed when the subscription ends.
+	C <-chan Change
+
+	n    *Notifier
+	c    chan Change
+	done chan struct{}
+	err  error
+}
+
+// Err returns the reason why the subscription has ended, or nil if it is still active.
+func (s *Subscription) Err() error {
+	s.n.mu.Lock()
+	defer s.n.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.n.mu.Lock()
+	s.n.closeSub(s, context.Canceled)
+	s.n.mu.Unlock()
+}
+
+// Notifier delivers changes to subscribers. It can be used by QuadStore implementations to implement Subscriber.
+//
+// Notifier never blocks the writer: if a subscriber does not keep up, its subscription is closed
+// with ErrSlowSubscriber. Zero value is ready to use.
+type Notifier struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscribe adds a new subscriber. Subscription is closed when the context is canceled.
+func (n *Notifier) Subscribe(ctx context.Context) *Subscription {
+	c := make(chan Change, subscriptionBuffer)
+	s := &Subscription{C: c, n: n, c: c, done: make(chan struct{})}
+	n.mu.Lock()
+	if n.subs == nil {
+		n.subs = make(map[*Subscription]struct{})
+	}
+	n.subs[s] = struct{}{}
+	n.mu.Unlock()
+	if done := ctx.Done(); done != nil {
+		go func() {
+			select {
+			case <-done:
+			case <-s.done:
+				return
+			}
+			n.mu.Lock()
+			n.closeSub(s, ctx.Err())
+			n.mu.Unlock()
+		}()
+	}
+	return s
+}
+
+// closeSub removes a subscriber. It must be called with the lock held.
+func (n *Notifier) closeSub(s *Subscription, err error) {
+	if _, ok := n.subs[s]; !ok {
+		return
+	}
+	delete(n.subs, s)
+	s.err = err
+	close(s.c)
+	close(s.done)
+}
+
+// Active checks if there are any subscribers. It can be used to avoid preparing changes if nobody listens.
+func (n *Notifier) Active() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.subs) != 0
+}
+
+// Notify sends changes to all subscribers.
+func (n *Notifier) Notify(changes []Change) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for s := range n.subs {
+		for _, c := range changes {
+			select {
+			case s.c <- c:
+				continue
+			default:
+			}
+			n.closeSub(s, ErrSlowSubscriber)
+			break
+		}
+	}
+}
+
+// CloseAll closes all subscriptions with a given error.
+func (n *Notifier) CloseAll(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for s := range n.subs {
+		n.closeSub(s, err)
+	}
+}
+
+// Subscribe starts delivering changes applied to the QuadStore.
+// It returns ErrNotSupported if QuadStore does not implement Subscriber.
+//
+// If patterns are given, only changes of quads matching at least one of them are delivered.
+// Directions of a pattern set to nil match any value.
+func Subscribe(ctx context.Context, qs QuadStore, patterns ...quad.Quad) (*Subscription, error) {
+	s, ok := Unwrap(qs).(Subscriber)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	if len(patterns) == 0 {
+		return s.Subscribe(ctx)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	sub, err := s.Subscribe(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	n := &Notifier{}
+	fsub := n.Subscribe(ctx)
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case c, ok := <-sub.C:
+				if !ok {
+					n.CloseAll(sub.Err())
+					return
+				}
+				if MatchesAny(c.Quad, patterns) {
+					n.Notify([]Change{c})
+				}
+			case <-fsub.done:
+				return
+			}
+		}
+	}()
+	return fsub, nil
+}
+
+// matches checks if a quad matches a pattern.
+func matches(q, pattern quad.Quad) bool {
+	for _, d := range quad.Directions {
+		pv := pattern.Get(d)
+		if pv == nil {
+			continue
+		}
+		if v := q.Get(d); v == nil || v.String() != pv.String() {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesAny checks if a quad matches at least one of the patterns.
+// Directions of a pattern set to nil match any value.
+func MatchesAny(q quad.Quad, patterns []quad.Quad) bool {
+	for _, p := range patterns {
+		if matches(q, p) {
+			return true
+		}
+	}
+	return false
+}
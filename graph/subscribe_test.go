@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestNotifierSlowSubscriber(t *testing.T) {
+	var n Notifier
+	sub := n.Subscribe(context.Background())
+	changes := make([]Change, subscriptionBuffer+1)
+	for i := range changes {
+		changes[i] = Change{Delta: Delta{Quad: quad.MakeIRI("a", "b", "c", ""), Action: Add}, Horizon: 1}
+	}
+	n.Notify(changes)
+	if n.Active() {
+		t.Fatal("slow subscriber must be removed")
+	}
+	cnt := 0
+	for range sub.C {
+		cnt++
+	}
+	if cnt != subscriptionBuffer {
+		t.Fatalf("expected %d buffered changes, got %d", subscriptionBuffer, cnt)
+	} else if err := sub.Err(); err != ErrSlowSubscriber {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSubscribePatterns(t *testing.T) {
+	qs := &notifyStore{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub, err := Subscribe(ctx, qs, quad.Quad{Predicate: quad.IRI("follows")}, quad.Quad{Subject: quad.IRI("bob"), Label: quad.IRI("g")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := []quad.Quad{
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("alice", "likes", "bob", ""),
+		quad.MakeIRI("bob", "likes", "alice", ""),
+		quad.MakeIRI("bob", "likes", "alice", "g"),
+	}
+	for i, q := range in {
+		qs.n.Notify([]Change{{Delta: Delta{Quad: q, Action: Add}, Horizon: int64(i + 1)}})
+	}
+	for _, exp := range []int64{1, 4} {
+		c := <-sub.C
+		if c.Horizon != exp {
+			t.Fatalf("unexpected change: %v (%d)", c.Quad, c.Horizon)
+		}
+	}
+	qs.n.CloseAll(ErrStoreClosed)
+	if _, ok := <-sub.C; ok {
+		t.Fatal("expected subscription to be closed")
+	} else if err := sub.Err(); err != ErrStoreClosed {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type notifyStore struct {
+	QuadStore
+	n Notifier
+}
+
+func (qs *notifyStore) Subscribe(ctx context.Context) (*Subscription, error) {
+	return qs.n.Subscribe(ctx), nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package temporal implements bitemporal facts on top of quads.
+//
+// A fact is a quad without a label. Each version of a fact is stored as the same quad with a unique label,
+// called a version node. The version node has the valid time of the fact (when the fact is true in the modeled
+// world) and the recorded time of the version (when the version was the current knowledge in the database).
+//
+// Versions are never removed. Asserting or retracting facts closes the recorded time of affected versions
+// and writes new versions, thus the database can be queried as of any point in the past.
+package temporal
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Predicates of version nodes. Bounds are stored as time literals; unbounded intervals have no bound.
+const (
+	ValidFrom    = quad.IRI("cayley:validFrom")
+	ValidTo      = quad.IRI("cayley:validTo")
+	RecordedFrom = quad.IRI("cayley:recordedFrom")
+	RecordedTo   = quad.IRI("cayley:recordedTo")
+)
+
+// ErrLabeled is returned when writing a fact with a label. Labels of temporal quads are used for version nodes.
+var ErrLabeled = errors.New("temporal: facts must have no label")
+
+// Interval is a half-open time interval [From, To). Zero bounds are unbounded.
+type Interval struct {
+	From, To time.Time
+}
+
+// Always is an unbounded interval.
+var Always = Interval{}
+
+// Contains checks if the interval contains a point in time.
+func (iv Interval) Contains(t time.Time) bool {
+	return (iv.From.IsZero() || !t.Before(iv.From)) && (iv.To.IsZero() || t.Before(iv.To))
+}
+
+// Overlaps checks if intervals have common points in time.
+func (iv Interval) Overlaps(o Interval) bool {
+	return (iv.To.IsZero() || o.From.IsZero() || o.From.Before(iv.To)) &&
+		(o.To.IsZero() || iv.From.IsZero() || iv.From.Before(o.To))
+}
+
+// Covers checks if the interval contains all points of another one.
+func (iv Interval) Covers(o Interval) bool {
+	return (iv.From.IsZero() || (!o.From.IsZero() && !o.From.Before(iv.From))) &&
+		(iv.To.IsZero() || (!o.To.IsZero() && !o.To.After(iv.To)))
+}
+
+// Empty checks if the interval contains no points in time.
+func (iv Interval) Empty() bool {
+	return !iv.From.IsZero() && !iv.To.IsZero() && !iv.From.Before(iv.To)
+}
+
+// union returns the smallest interval that covers both intervals.
+func (iv Interval) union(o Interval) Interval {
+	if !iv.From.IsZero() && (o.From.IsZero() || o.From.Before(iv.From)) {
+		iv.From = o.From
+	}
+	if !iv.To.IsZero() && (o.To.IsZero() || o.To.After(iv.To)) {
+		iv.To = o.To
+	}
+	return iv
+}
+
+// subtract returns parts of the interval that are not covered by another one.
+func (iv Interval) subtract(o Interval) []Interval {
+	var out []Interval
+	if !o.From.IsZero() && (iv.From.IsZero() || iv.From.Before(o.From)) {
+		left := Interval{From: iv.From, To: o.From}
+		if !iv.To.IsZero() && iv.To.Before(o.From) {
+			left.To = iv.To
+		}
+		out = append(out, left)
+	}
+	if !o.To.IsZero() && (iv.To.IsZero() || o.To.Before(iv.To)) {
+		right := Interval{From: o.To, To: iv.To}
+		if !iv.From.IsZero() && iv.From.After(o.To) {
+			right.From = iv.From
+		}
+		out = append(out, right)
+	}
+	return out
+}
+
+// Version is a single version of a fact.
+type Version struct {
+	// Node is a version node, used as a label of the quad.
+	Node quad.Value
+	// Quad is the fact itself, without the label.
+	Quad     quad.Quad
+	Valid    Interval
+	Recorded Interval
+}
+
+// Current checks if the version is a part of the current knowledge.
+func (v Version) Current() bool {
+	return v.Recorded.To.IsZero()
+}
+
+// Versions returns all versions of a fact, ordered by the recorded time.
+func Versions(ctx context.Context, qs graph.QuadStore, q quad.Quad) ([]Version, error) {
+	if q.Label != nil {
+		return nil, ErrLabeled
+	}
+	sv := qs.ValueOf(q.Subject)
+	if sv == nil {
+		return nil, nil
+	}
+	var labels []quad.Value
+	it := qs.QuadIterator(quad.Subject, sv)
+	for it.Next(ctx) {
+		q2 := qs.Quad(it.Result())
+		if q2.Label != nil && q2.Predicate == q.Predicate && q2.Object == q.Object {
+			labels = append(labels, q2.Label)
+		}
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		return nil, err
+	}
+	var out []Version
+	for _, l := range labels {
+		v, ok, err := readVersion(ctx, qs, l)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		v.Quad = q
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Recorded.From.Before(out[j].Recorded.From)
+	})
+	return out, nil
+}
+
+// readVersion reads time intervals of a version node. It returns false if the node is not a version.
+func readVersion(ctx context.Context, qs graph.QuadStore, node quad.Value) (Version, bool, error) {
+	v := Version{Node: node}
+	nv := qs.ValueOf(node)
+	if nv == nil {
+		return v, false, nil
+	}
+	ok := false
+	it := qs.QuadIterator(quad.Subject, nv)
+	defer it.Close()
+	for it.Next(ctx) {
+		q := qs.Quad(it.Result())
+		t, isTime := q.Object.(quad.Time)
+		if !isTime {
+			continue
+		}
+		switch q.Predicate {
+		case ValidFrom:
+			v.Valid.From = time.Time(t)
+		case ValidTo:
+			v.Valid.To = time.Time(t)
+		case RecordedFrom:
+			v.Recorded.From = time.Time(t)
+			ok = true
+		case RecordedTo:
+			v.Recorded.To = time.Time(t)
+		}
+	}
+	return v, ok, it.Err()
+}
+
+// hasPredicate returns nodes that have a given predicate.
+func hasPredicate(p quad.IRI) shape.Shape {
+	return shape.NodesFrom{Dir: quad.Subject, Quads: shape.Quads{
+		{Dir: quad.Predicate, Values: shape.Lookup{p}},
+	}}
+}
+
+// hasTime returns nodes that have a time literal with a given predicate that satisfies the comparison.
+func hasTime(p quad.IRI, op iterator.Operator, t time.Time) shape.Shape {
+	return shape.NodesFrom{Dir: quad.Subject, Quads: shape.Quads{
+		{Dir: quad.Predicate, Values: shape.Lookup{p}},
+		{Dir: quad.Object, Values: shape.Filter{
+			From:    shape.AllNodes{},
+			Filters: []shape.ValueFilter{shape.Comparison{Op: op, Val: quad.Time(t)}},
+		}},
+	}}
+}
+
+// VersionsAt returns a shape with version nodes of facts that are valid at a given time,
+// according to the knowledge recorded at a given time. If recorded time is zero, the current knowledge is used.
+//
+// The shape is meant to be used as a label context for traversals (see path.Path.ValidAt).
+func VersionsAt(valid, recorded time.Time) shape.Shape {
+	var s shape.Shape = hasPredicate(RecordedFrom)
+	except := func(ex shape.Shape) {
+		s = shape.Except{From: s, Exclude: ex}
+	}
+	except(hasTime(ValidFrom, iterator.CompareGT, valid))
+	except(hasTime(ValidTo, iterator.CompareLTE, valid))
+	if recorded.IsZero() {
+		except(hasPredicate(RecordedTo))
+	} else {
+		except(hasTime(RecordedFrom, iterator.CompareGT, recorded))
+		except(hasTime(RecordedTo, iterator.CompareLTE, recorded))
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+package temporal_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/temporal"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func date(y int) time.Time {
+	return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestInterval(t *testing.T) {
+	iv := temporal.Interval{From: date(2000), To: date(2010)}
+	require.True(t, iv.Contains(date(2000)))
+	require.False(t, iv.Contains(date(2010)))
+	require.True(t, temporal.Always.Contains(date(1900)))
+	require.True(t, iv.Overlaps(temporal.Interval{From: date(2009)}))
+	require.False(t, iv.Overlaps(temporal.Interval{To: date(2000)}))
+	require.True(t, temporal.Always.Covers(iv))
+	require.False(t, iv.Covers(temporal.Interval{From: date(2005)}))
+	require.True(t, temporal.Interval{From: date(2010), To: date(2000)}.Empty())
+}
+
+var (
+	alice   = quad.IRI("alice")
+	worksAt = quad.IRI("worksAt")
+	acme    = quad.MakeIRI("alice", "worksAt", "acme", "")
+	globex  = quad.MakeIRI("alice", "worksAt", "globex", "")
+)
+
+func TestWriter(t *testing.T) {
+	ctx := context.TODO()
+	qs := memstore.New()
+	qw, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+
+	clock := date(2020)
+	w := temporal.NewWriter(qs, qw)
+	w.Now = func() time.Time { return clock }
+	tick := func() time.Time {
+		clock = clock.Add(time.Hour)
+		return clock
+	}
+
+	require.Equal(t, temporal.ErrLabeled, w.Assert(ctx, quad.MakeIRI("a", "b", "c", "d"), temporal.Always))
+
+	require.NoError(t, w.Assert(ctx, acme, temporal.Interval{From: date(2000), To: date(2010)}))
+	t1 := tick()
+	require.NoError(t, w.Assert(ctx, globex, temporal.Interval{From: date(2010)}))
+	// already known
+	require.NoError(t, w.Assert(ctx, acme, temporal.Interval{From: date(2001), To: date(2002)}))
+
+	list, err := temporal.Versions(ctx, qs, acme)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.True(t, list[0].Current())
+	require.Equal(t, temporal.Interval{From: date(2000), To: date(2010)}, list[0].Valid)
+
+	// correction: alice did not work at acme in 2005
+	t2 := tick()
+	require.NoError(t, w.Retract(ctx, acme, temporal.Interval{From: date(2005), To: date(2006)}))
+	list, err = temporal.Versions(ctx, qs, acme)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	require.False(t, list[0].Current())
+	require.Equal(t, temporal.Interval{From: date(2020), To: t2}, list[0].Recorded)
+	var valid []temporal.Interval
+	for _, v := range list[1:] {
+		require.True(t, v.Current())
+		valid = append(valid, v.Valid)
+	}
+	require.ElementsMatch(t, []temporal.Interval{
+		{From: date(2000), To: date(2005)},
+		{From: date(2006), To: date(2010)},
+	}, valid)
+
+	// merge overlapping versions
+	tick()
+	require.NoError(t, w.Assert(ctx, acme, temporal.Interval{From: date(2004), To: date(2007)}))
+	list, err = temporal.Versions(ctx, qs, acme)
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	require.Equal(t, temporal.Interval{From: date(2000), To: date(2010)}, list[3].Valid)
+
+	employer := func(p *path.Path) []quad.Value {
+		vals, err := p.Out(worksAt).Iterate(ctx).AllValues(qs)
+		require.NoError(t, err)
+		return vals
+	}
+	start := func() *path.Path { return path.StartPath(qs, alice) }
+	require.ElementsMatch(t, []quad.Value{quad.IRI("acme")}, employer(start().ValidAt(date(2005))))
+	require.ElementsMatch(t, []quad.Value{quad.IRI("globex")}, employer(start().ValidAt(date(2015))))
+	require.Empty(t, employer(start().ValidAt(date(1990))))
+	// as it was known before the correction
+	require.ElementsMatch(t, []quad.Value{quad.IRI("acme")}, employer(start().ValidAtAsOf(date(2005), t1)))
+	// and after the correction
+	require.Empty(t, employer(start().ValidAtAsOf(date(2005), t2)))
+	// before anything was recorded
+	require.Empty(t, employer(start().ValidAtAsOf(date(2005), date(2019))))
+	// all versions are visible without the temporal context
+	require.Len(t, employer(start()), 5)
+}
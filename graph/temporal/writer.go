@@ -0,0 +1,135 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package temporal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Writer asserts and retracts facts over intervals of valid time.
+type Writer struct {
+	qs graph.QuadStore
+	qw graph.QuadWriter
+	// Now returns the current recorded time. If not set, time.Now is used.
+	Now func() time.Time
+
+	// mu serializes writes, since versions are read before the transaction is applied
+	mu sync.Mutex
+}
+
+// NewWriter creates a new writer for bitemporal facts. Versions are read from qs and written with qw.
+func NewWriter(qs graph.QuadStore, qw graph.QuadWriter) *Writer {
+	return &Writer{qs: qs, qw: qw}
+}
+
+func (w *Writer) now() time.Time {
+	if w.Now != nil {
+		return w.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// current returns current versions of a fact that overlap with a given interval.
+func (w *Writer) current(ctx context.Context, q quad.Quad, valid Interval) ([]Version, error) {
+	list, err := Versions(ctx, w.qs, q)
+	if err != nil {
+		return nil, err
+	}
+	out := list[:0]
+	for _, v := range list {
+		if v.Current() && v.Valid.Overlaps(valid) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// addVersion adds a new version of a fact to the transaction.
+func addVersion(tx *graph.Transaction, q quad.Quad, valid Interval, now time.Time) {
+	node := quad.RandomBlankNode()
+	q.Label = node
+	tx.AddQuad(q)
+	if !valid.From.IsZero() {
+		tx.AddQuad(quad.Quad{Subject: node, Predicate: ValidFrom, Object: quad.Time(valid.From.UTC())})
+	}
+	if !valid.To.IsZero() {
+		tx.AddQuad(quad.Quad{Subject: node, Predicate: ValidTo, Object: quad.Time(valid.To.UTC())})
+	}
+	tx.AddQuad(quad.Quad{Subject: node, Predicate: RecordedFrom, Object: quad.Time(now)})
+}
+
+// closeVersion adds the end of the recorded time of a version to the transaction.
+func closeVersion(tx *graph.Transaction, v Version, now time.Time) {
+	tx.AddQuad(quad.Quad{Subject: v.Node, Predicate: RecordedTo, Object: quad.Time(now)})
+}
+
+// Assert records that a fact is valid during a given interval. Current versions of the fact that overlap
+// with the interval are replaced by a single version that covers all of them.
+func (w *Writer) Assert(ctx context.Context, q quad.Quad, valid Interval) error {
+	if q.Label != nil {
+		return ErrLabeled
+	} else if valid.Empty() {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	list, err := w.current(ctx, q, valid)
+	if err != nil {
+		return err
+	}
+	for _, v := range list {
+		if v.Valid.Covers(valid) {
+			return nil
+		}
+	}
+	now := w.now()
+	tx := graph.NewTransaction()
+	for _, v := range list {
+		closeVersion(tx, v, now)
+		valid = valid.union(v.Valid)
+	}
+	addVersion(tx, q, valid, now)
+	return w.qw.ApplyTransaction(tx)
+}
+
+// Retract records that a fact is not valid during a given interval. Current versions of the fact that overlap
+// with the interval are replaced by versions that cover the rest of their valid time.
+func (w *Writer) Retract(ctx context.Context, q quad.Quad, valid Interval) error {
+	if q.Label != nil {
+		return ErrLabeled
+	} else if valid.Empty() {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	list, err := w.current(ctx, q, valid)
+	if err != nil || len(list) == 0 {
+		return err
+	}
+	now := w.now()
+	tx := graph.NewTransaction()
+	for _, v := range list {
+		closeVersion(tx, v, now)
+		for _, rest := range v.Valid.subtract(valid) {
+			addVersion(tx, q, rest, now)
+		}
+	}
+	return w.qw.ApplyTransaction(tx)
+}
@@ -0,0 +1,171 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bleve implements a persistent text index with Bleve.
+package bleve
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+// Type is a name of the index type.
+const Type = "bleve"
+
+func init() {
+	text.RegisterIndex(Type, Open)
+}
+
+var _ text.Index = (*Index)(nil)
+
+// Index is a text index stored in Bleve.
+type Index struct {
+	path string
+	idx  bleve.Index
+}
+
+// Open opens or creates an index at a given path. If the path is empty, the index is kept in memory.
+func Open(path string, opts graph.Options) (text.Index, error) {
+	idx, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Index{path: path, idx: idx}, nil
+}
+
+func open(path string) (bleve.Index, error) {
+	m := bleve.NewIndexMapping()
+	if path == "" {
+		return bleve.NewMemOnly(m)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return bleve.Open(path)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return bleve.New(path, m)
+}
+
+// docID returns an ID of the document of a node.
+func docID(v quad.Value) (string, error) {
+	data, err := pquads.MarshalValue(v)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// field returns a name of the document field for a predicate.
+// Predicates are hex-encoded, since Bleve interprets dots in field names as paths.
+func field(p quad.IRI) string {
+	return "p_" + hex.EncodeToString([]byte(p))
+}
+
+func (b *Index) Update(ctx context.Context, docs []text.Document, del []quad.Value) error {
+	batch := b.idx.NewBatch()
+	for _, v := range del {
+		id, err := docID(v)
+		if err != nil {
+			return err
+		}
+		batch.Delete(id)
+	}
+	for _, d := range docs {
+		id, err := docID(d.Node)
+		if err != nil {
+			return err
+		}
+		doc := make(map[string]interface{}, len(d.Fields))
+		for p, texts := range d.Fields {
+			doc[field(p)] = texts
+		}
+		if err = batch.Index(id, doc); err != nil {
+			return err
+		}
+	}
+	return b.idx.Batch(batch)
+}
+
+func (b *Index) Search(ctx context.Context, q graph.TextQuery) ([]graph.TextHit, error) {
+	var bq query.Query
+	if len(q.Predicates) == 0 {
+		m := bleve.NewMatchQuery(q.Query)
+		m.SetOperator(query.MatchQueryOperatorAnd)
+		bq = m
+	} else {
+		dis := bleve.NewDisjunctionQuery()
+		for _, p := range q.Predicates {
+			m := bleve.NewMatchQuery(q.Query)
+			m.SetField(field(p))
+			m.SetOperator(query.MatchQueryOperatorAnd)
+			dis.AddQuery(m)
+		}
+		bq = dis
+	}
+	req := bleve.NewSearchRequestOptions(bq, q.Limit, 0, false)
+	res, err := b.idx.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]graph.TextHit, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		data, err := hex.DecodeString(h.ID)
+		if err != nil {
+			return nil, fmt.Errorf("bleve: invalid document id: %q", h.ID)
+		}
+		v, err := pquads.UnmarshalValue(data)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, graph.TextHit{Node: v, Score: h.Score})
+	}
+	return hits, nil
+}
+
+func (b *Index) Count(ctx context.Context) (int64, error) {
+	n, err := b.idx.DocCount()
+	return int64(n), err
+}
+
+// Clear removes the index and creates a new one at the same location.
+func (b *Index) Clear(ctx context.Context) error {
+	if err := b.idx.Close(); err != nil {
+		return err
+	}
+	if b.path != "" {
+		if err := os.RemoveAll(b.path); err != nil {
+			return err
+		}
+	}
+	idx, err := open(b.path)
+	if err != nil {
+		return err
+	}
+	b.idx = idx
+	return nil
+}
+
+func (b *Index) Close() error {
+	return b.idx.Close()
+}
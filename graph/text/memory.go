@@ -0,0 +1,150 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/nodeindex"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func init() {
+	RegisterIndex(DefaultType, func(path string, opts graph.Options) (Index, error) {
+		return NewMemory(), nil
+	})
+}
+
+var _ Index = (*memIndex)(nil)
+
+// NewMemory creates an in-memory inverted index. All terms of a query must match a document.
+func NewMemory() Index {
+	return &memIndex{docs: nodeindex.NewPostings()}
+}
+
+type memIndex struct {
+	mu   sync.RWMutex
+	docs *nodeindex.Postings // terms of *memDoc are words
+}
+
+type memDoc struct {
+	node quad.Value
+	// freq is a number of occurrences of each term in fields
+	freq map[string]map[quad.IRI]int
+}
+
+func (m *memIndex) Update(ctx context.Context, docs []Document, del []quad.Value) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range del {
+		m.docs.Delete(quad.StringOf(v))
+	}
+	for _, doc := range docs {
+		d := &memDoc{node: doc.Node, freq: make(map[string]map[quad.IRI]int)}
+		for p, texts := range doc.Fields {
+			for _, s := range texts {
+				for _, t := range tokenize(s) {
+					f := d.freq[t]
+					if f == nil {
+						f = make(map[quad.IRI]int)
+						d.freq[t] = f
+					}
+					f[p]++
+				}
+			}
+		}
+		terms := make([]interface{}, 0, len(d.freq))
+		for t := range d.freq {
+			terms = append(terms, t)
+		}
+		m.docs.Put(quad.StringOf(doc.Node), d, terms)
+	}
+	return nil
+}
+
+func (m *memIndex) Search(ctx context.Context, q graph.TextQuery) ([]graph.TextHit, error) {
+	terms := tokenize(q.Query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	var preds map[quad.IRI]struct{}
+	if len(q.Predicates) != 0 {
+		preds = make(map[quad.IRI]struct{}, len(q.Predicates))
+		for _, p := range q.Predicates {
+			preds[p] = struct{}{}
+		}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// start from the rarest term to check fewer documents
+	sort.Slice(terms, func(i, j int) bool {
+		return len(m.docs.Term(terms[i])) < len(m.docs.Term(terms[j]))
+	})
+	n := float64(m.docs.Len())
+	var hits []graph.TextHit
+next:
+	for key := range m.docs.Term(terms[0]) {
+		d := m.docs.Get(key).(*memDoc)
+		score := 0.0
+		for _, t := range terms {
+			tf := 0
+			for p, c := range d.freq[t] {
+				if preds != nil {
+					if _, ok := preds[p]; !ok {
+						continue
+					}
+				}
+				tf += c
+			}
+			if tf == 0 {
+				continue next
+			}
+			idf := math.Log(1 + n/float64(len(m.docs.Term(t))))
+			score += math.Sqrt(float64(tf)) * idf
+		}
+		hits = append(hits, graph.TextHit{Node: d.node, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return quad.StringOf(hits[i].Node) < quad.StringOf(hits[j].Node)
+	})
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+func (m *memIndex) Count(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(m.docs.Len()), nil
+}
+
+func (m *memIndex) Clear(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docs.Reset()
+	return nil
+}
+
+func (m *memIndex) Close() error {
+	return nil
+}
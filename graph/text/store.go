@@ -0,0 +1,118 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/nodeindex"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var (
+	_ graph.Wrapper     = (*Store)(nil)
+	_ graph.TextIndexer = (*Store)(nil)
+	_ shape.Optimizer   = (*Store)(nil)
+)
+
+// Store is a QuadStore wrapper that maintains a text index on each write.
+type Store struct {
+	*nodeindex.Store
+	typ string
+	idx Index
+}
+
+// New opens a text index and wraps the QuadStore to maintain it. If the index is empty,
+// it is built from the data. Writes must go through the returned QuadStore for the index to be updated.
+func New(qs graph.QuadStore, conf Config) (*Store, error) {
+	typ, fnc, err := indexes.Lookup(conf.Type)
+	if err != nil {
+		return nil, err
+	}
+	conf.Type = typ
+	newIndex := fnc.(NewIndexFunc)
+	s := &Store{typ: conf.Type}
+	ns, err := nodeindex.New(qs, nodeindex.Config{
+		Name: "text", Type: conf.Type, Predicates: conf.Predicates,
+	}, func() (nodeindex.Index, error) {
+		idx, err := newIndex(conf.Path, conf.Options)
+		if err != nil {
+			return nil, err
+		}
+		s.idx = idx
+		return indexer{idx}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Store = ns
+	return s, nil
+}
+
+// indexer adapts Index to documents of string literals.
+type indexer struct {
+	Index
+}
+
+func (indexer) Field(v quad.Value) (interface{}, bool) {
+	return literalText(v)
+}
+
+func (ix indexer) Update(ctx context.Context, docs []nodeindex.Document, del []quad.Value) error {
+	out := make([]Document, 0, len(docs))
+	for _, d := range docs {
+		doc := Document{Node: d.Node, Fields: make(map[quad.IRI][]string, len(d.Fields))}
+		for p, vals := range d.Fields {
+			for _, v := range vals {
+				doc.Fields[p] = append(doc.Fields[p], v.(string))
+			}
+		}
+		out = append(out, doc)
+	}
+	return ix.Index.Update(ctx, out, del)
+}
+
+// Index returns the text index maintained by the store.
+func (s *Store) Index() Index {
+	return s.idx
+}
+
+// SearchText implements graph.TextSearcher.
+func (s *Store) SearchText(ctx context.Context, q graph.TextQuery) ([]graph.TextHit, error) {
+	if q.Limit <= 0 {
+		q.Limit = graph.DefaultTextLimit
+	}
+	return s.idx.Search(ctx, q)
+}
+
+// TextIndexStatus implements graph.TextIndexer.
+func (s *Store) TextIndexStatus(ctx context.Context) (graph.TextIndexStatus, error) {
+	n, err := s.idx.Count(ctx)
+	if err != nil {
+		return graph.TextIndexStatus{}, err
+	}
+	return graph.TextIndexStatus{
+		Type:       s.typ,
+		Predicates: s.Predicates(),
+		Documents:  n,
+	}, nil
+}
+
+// RebuildTextIndex implements graph.TextIndexer. Writes are blocked during the rebuild.
+func (s *Store) RebuildTextIndex(ctx context.Context) error {
+	return s.Rebuild(ctx)
+}
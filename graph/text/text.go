@@ -0,0 +1,102 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package text maintains full-text indexes over string literals of configured predicates.
+//
+// The index is maintained by a QuadStore wrapper (see New) on each write and is used by the query
+// optimizer to resolve shape.TextSearch. Each node that is a subject of indexed literals is stored as
+// a separate document with a field per predicate.
+package text
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/nodeindex"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DefaultType is a type of index used if it is not set in the config.
+const DefaultType = "memory"
+
+// Document is a set of string literals of a single node.
+type Document struct {
+	Node quad.Value
+	// Fields maps indexed predicates to string literals of the node.
+	Fields map[quad.IRI][]string
+}
+
+// Index is a full-text index of documents.
+type Index interface {
+	// Update indexes documents, replacing previous versions of them, and removes documents of given nodes.
+	Update(ctx context.Context, docs []Document, del []quad.Value) error
+	// Search returns nodes with documents matching the query, ordered by relevance.
+	// The limit of the query is always set.
+	Search(ctx context.Context, q graph.TextQuery) ([]graph.TextHit, error)
+	// Count returns the number of indexed documents.
+	Count(ctx context.Context) (int64, error)
+	// Clear removes all documents from the index.
+	Clear(ctx context.Context) error
+	// Close releases resources associated with the index.
+	Close() error
+}
+
+// NewIndexFunc opens an index at a given path, creating it if necessary.
+// If the path is empty, the index is kept in memory.
+type NewIndexFunc func(path string, opts graph.Options) (Index, error)
+
+// indexes is a registry of index types.
+var indexes = nodeindex.NewRegistry("text", DefaultType)
+
+// RegisterIndex registers an index type.
+func RegisterIndex(name string, fnc NewIndexFunc) {
+	indexes.Register(name, fnc)
+}
+
+// Indexes returns names of all registered index types.
+func Indexes() []string {
+	return indexes.Types()
+}
+
+// Config is a configuration of a text index.
+type Config struct {
+	// Type of the index; DefaultType is used if not set.
+	Type string
+	// Path to the index, if it is persistent.
+	Path string
+	// Predicates with string literals to index.
+	Predicates []quad.IRI
+	// Options of the index.
+	Options graph.Options
+}
+
+// literalText returns the text of string literals.
+func literalText(v quad.Value) (string, bool) {
+	switch v := v.(type) {
+	case quad.String:
+		return string(v), true
+	case quad.LangString:
+		return string(v.Value), true
+	}
+	return "", false
+}
+
+// tokenize splits a text to lowercase words.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
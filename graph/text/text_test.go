@@ -0,0 +1,116 @@
+package text_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/nodeindex/nodeindextest"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+const (
+	name = quad.IRI("name")
+	desc = quad.IRI("desc")
+)
+
+var testQuads = []quad.Quad{
+	quad.MakeIRI("alice", "name", "", ""),
+	{Subject: quad.IRI("alice"), Predicate: name, Object: quad.String("Alice Smith")},
+	{Subject: quad.IRI("bob"), Predicate: name, Object: quad.LangString{Value: "Bob Smith", Lang: "en"}},
+	{Subject: quad.IRI("bob"), Predicate: desc, Object: quad.String("Likes graph databases")},
+	{Subject: quad.IRI("carol"), Predicate: name, Object: quad.Int(42)},
+	{Subject: quad.IRI("carol"), Predicate: quad.IRI("note"), Object: quad.String("smith")},
+}
+
+func init() {
+	// a quad with an IRI object of an indexed predicate is not a literal
+	testQuads[0].Object = quad.IRI("smith")
+}
+
+func search(t testing.TB, qs graph.QuadStore, q string, preds ...quad.IRI) []string {
+	hits, err := graph.SearchText(context.TODO(), qs, graph.TextQuery{Query: q, Predicates: preds})
+	out := nodeindextest.Nodes(t, hits, err)
+	sort.Strings(out)
+	return out
+}
+
+func TestStore(t *testing.T) {
+	qs, err := text.New(memstore.New(), text.Config{Predicates: []quad.IRI{name, desc}})
+	require.NoError(t, err)
+	defer qs.Close()
+	qw, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, qw.AddQuadSet(testQuads))
+	require.Equal(t, int64(2), nodeindextest.Documents(t, qs.Store))
+	require.True(t, graph.CapabilitiesOf(qs).TextSearch)
+
+	require.Equal(t, []string{"<alice>", "<bob>"}, search(t, qs, "SMITH"))
+	require.Equal(t, []string{"<bob>"}, search(t, qs, "smith graph"))
+	require.Equal(t, []string{"<bob>"}, search(t, qs, "graph", desc))
+	require.Nil(t, search(t, qs, "smith", desc))
+	require.Nil(t, search(t, qs, "42"))
+
+	require.NoError(t, qw.RemoveQuad(testQuads[2]))
+	require.Equal(t, []string{"<alice>"}, search(t, qs, "smith"))
+	require.Equal(t, int64(2), nodeindextest.Documents(t, qs.Store))
+
+	require.NoError(t, qw.RemoveQuad(testQuads[3]))
+	require.Equal(t, int64(1), nodeindextest.Documents(t, qs.Store))
+
+	require.NoError(t, graph.RebuildTextIndex(context.TODO(), qs))
+	require.Equal(t, int64(1), nodeindextest.Documents(t, qs.Store))
+	require.Equal(t, []string{"<alice>"}, search(t, qs, "alice"))
+}
+
+func TestStoreBuild(t *testing.T) {
+	mem := memstore.New(testQuads...)
+	_, err := graph.TextIndexStatusOf(context.TODO(), mem)
+	require.Equal(t, graph.ErrNotSupported, err)
+
+	qs, err := text.New(mem, text.Config{Predicates: []quad.IRI{name, desc}})
+	require.NoError(t, err)
+	defer qs.Close()
+	require.Equal(t, int64(2), nodeindextest.Documents(t, qs.Store))
+
+	_, err = text.New(mem, text.Config{Type: "unknown", Predicates: []quad.IRI{name}})
+	require.Error(t, err)
+}
+
+func TestPathTextSearch(t *testing.T) {
+	mem := memstore.New(testQuads...)
+	idx, err := text.New(mem, text.Config{Predicates: []quad.IRI{name, desc}})
+	require.NoError(t, err)
+	defer idx.Close()
+
+	for _, c := range []struct {
+		name string
+		qs   graph.QuadStore
+	}{
+		{"index", idx},
+		{"scan", mem},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			vals, err := path.StartPath(c.qs).TextSearch("smith", name).Iterate(context.TODO()).AllValues(c.qs)
+			require.NoError(t, err)
+			var out []string
+			for _, v := range vals {
+				out = append(out, quad.StringOf(v))
+			}
+			sort.Strings(out)
+			require.Equal(t, []string{"<alice>", "<bob>"}, out)
+
+			vals, err = path.StartPath(c.qs).TextSearch("databases").Out(name).Iterate(context.TODO()).AllValues(c.qs)
+			require.NoError(t, err)
+			require.Equal(t, []quad.Value{quad.LangString{Value: "Bob Smith", Lang: "en"}}, vals)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DefaultTextLimit is a maximal number of nodes returned by a text search if the limit is not set.
+const DefaultTextLimit = 1000
+
+// TextQuery is a full-text search query.
+type TextQuery struct {
+	// Query is a text to search for. All words of the query must be present in the node text.
+	Query string
+	// Predicates restricts the search to string literals of given predicates.
+	// All indexed predicates are searched if empty.
+	Predicates []quad.IRI
+	// Limit is a maximal number of nodes to return. DefaultTextLimit is used if not set.
+	Limit int
+}
+
+// TextHit is a node found by a text search.
+type TextHit struct {
+	// Node is a subject of quads with matching string literals.
+	Node quad.Value
+	// Score is a relevance of the node; higher is better.
+	Score float64
+}
+
+// TextSearcher is an optional interface for QuadStores that maintain a full-text index over
+// string literals of some predicates. The index may be backend-native or maintained by a Wrapper.
+type TextSearcher interface {
+	// SearchText returns nodes with string literals matching the query, ordered by relevance.
+	SearchText(ctx context.Context, q TextQuery) ([]TextHit, error)
+}
+
+// TextIndexStatus describes a full-text index.
+type TextIndexStatus struct {
+	Type       string     `json:"type"`
+	Predicates []quad.IRI `json:"predicates"`
+	Documents  int64      `json:"documents"` // number of indexed nodes
+}
+
+// TextIndexer is an optional interface for text-searchable QuadStores that allow to manage their index.
+type TextIndexer interface {
+	TextSearcher
+	// TextIndexStatus returns the state of the text index.
+	TextIndexStatus(ctx context.Context) (TextIndexStatus, error)
+	// RebuildTextIndex drops the text index and indexes all the data again.
+	RebuildTextIndex(ctx context.Context) error
+}
+
+// asTextSearcher finds a TextSearcher in a chain of Handles and Wrappers.
+func asTextSearcher(qs QuadStore) TextSearcher {
+	for qs != nil {
+		if s, ok := qs.(TextSearcher); ok {
+			return s
+		}
+		qs = unwrapOnce(qs)
+	}
+	return nil
+}
+
+// SearchText returns nodes with string literals matching the query, ordered by relevance.
+// It returns ErrNotSupported if QuadStore does not implement TextSearcher.
+func SearchText(ctx context.Context, qs QuadStore, q TextQuery) ([]TextHit, error) {
+	if s := asTextSearcher(qs); s != nil {
+		return s.SearchText(ctx, q)
+	}
+	return nil, ErrNotSupported
+}
+
+// TextIndexStatusOf returns the state of the text index of QuadStore.
+// It returns ErrNotSupported if QuadStore does not implement TextIndexer.
+func TextIndexStatusOf(ctx context.Context, qs QuadStore) (TextIndexStatus, error) {
+	if ix, ok := asTextSearcher(qs).(TextIndexer); ok {
+		return ix.TextIndexStatus(ctx)
+	}
+	return TextIndexStatus{}, ErrNotSupported
+}
+
+// RebuildTextIndex indexes all the data of QuadStore again.
+// It returns ErrNotSupported if QuadStore does not implement TextIndexer.
+func RebuildTextIndex(ctx context.Context, qs QuadStore) error {
+	if ix, ok := asTextSearcher(qs).(TextIndexer); ok {
+		return ix.RebuildTextIndex(ctx)
+	}
+	return ErrNotSupported
+}
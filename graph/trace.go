@@ -0,0 +1,52 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import "context"
+
+// Tracer records spans of query execution, such as parsing, optimization, iteration and backend calls.
+// It allows to plug a tracing system without adding a dependency on it to each backend.
+type Tracer interface {
+	// StartSpan starts a span as a child of a span in the context, if any. It returns a context with
+	// the new span and a function that ends the span. An error passed to the function is recorded in the span.
+	StartSpan(ctx context.Context, name string) (context.Context, func(err error))
+}
+
+type tracerKey struct{}
+
+// WithTracer sets a tracer for operations executed with a given context.
+func WithTracer(ctx context.Context, t Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// TracerFrom returns a tracer set by WithTracer, or nil.
+func TracerFrom(ctx context.Context) Tracer {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(tracerKey{}).(Tracer)
+	return t
+}
+
+func endNoSpan(error) {}
+
+// StartSpan starts a span with a tracer from the context. It does nothing if no tracer was set with WithTracer.
+func StartSpan(ctx context.Context, name string) (context.Context, func(err error)) {
+	t := TracerFrom(ctx)
+	if t == nil {
+		return ctx, endNoSpan
+	}
+	return t.StartSpan(ctx, name)
+}
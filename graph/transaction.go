@@ -14,7 +14,11 @@
 
 package graph
 
-import "github.com/cayleygraph/cayley/quad"
+import (
+	"errors"
+
+	"github.com/cayleygraph/cayley/quad"
+)
 
 // Transaction stores a bunch of Deltas to apply together in an atomic step on the database.
 type Transaction struct {
@@ -64,6 +68,21 @@ func (t *Transaction) RemoveQuad(q quad.Quad) {
 	}
 }
 
+// Append adds a delta to the transaction. Add and Delete deltas are handled as in AddQuad and RemoveQuad,
+// while deltas with extended actions are recorded as is and are resolved when the transaction is applied.
+func (t *Transaction) Append(d Delta) {
+	switch d.Action {
+	case Add:
+		t.AddQuad(d.Quad)
+	case Delete:
+		t.RemoveQuad(d.Quad)
+	default:
+		if _, ok := t.deltas[d]; !ok {
+			t.addDelta(d)
+		}
+	}
+}
+
 func createDeltas(q quad.Quad) (ad, rd Delta) {
 	ad = Delta{
 		Quad:   q,
@@ -91,3 +110,94 @@ func (t *Transaction) deleteDelta(d Delta) {
 		}
 	}
 }
+
+// ErrTxDone is returned when using a transaction that was already committed or rolled back.
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// Tx is a multi-operation transaction. Changes are buffered in memory and are not visible
+// to readers until Commit applies all of them in a single step. Rollback discards all changes.
+//
+// Isolation of the commit depends on the backend:
+//
+//	KV backends (bolt, leveldb, btree): changes are applied in a single write transaction;
+//	readers observe either none or all of them. Commits are serialized.
+//	SQL backends: changes are applied in a single SQL transaction with the default isolation level of the database.
+//	memstore: changes are applied without locking; concurrent readers may observe part of the changes.
+//	NoSQL backends: all changes are validated first and then written one by one;
+//	readers may observe part of the changes, and a failed write may leave the commit partially applied.
+//
+// In all cases, the commit fails without applying any changes if some of them conflict with
+// the database (for example, a quad to add already exists), unless conflicts are ignored by IgnoreOpts.
+//
+// Tx is not safe for concurrent use.
+type Tx struct {
+	tx     *Transaction
+	commit func(tx *Transaction) error
+	done   bool
+}
+
+// Begin starts a transaction on the QuadStore. Commit applies changes with QuadStore.ApplyDeltas and given options.
+func Begin(qs QuadStore, opts IgnoreOpts) *Tx {
+	return &Tx{
+		tx: NewTransaction(),
+		commit: func(tx *Transaction) error {
+			return qs.ApplyDeltas(tx.Deltas, opts)
+		},
+	}
+}
+
+// BeginWriter starts a transaction that is committed with QuadWriter.ApplyTransaction,
+// thus the options of the writer are used.
+func BeginWriter(qw QuadWriter) *Tx {
+	return &Tx{tx: NewTransaction(), commit: qw.ApplyTransaction}
+}
+
+// Begin starts a transaction on the database. See BeginWriter.
+func (h *Handle) Begin() *Tx {
+	return BeginWriter(h.QuadWriter)
+}
+
+// AddQuad adds a quad to the transaction. See Transaction.AddQuad.
+func (tx *Tx) AddQuad(q quad.Quad) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.tx.AddQuad(q)
+	return nil
+}
+
+// RemoveQuad adds a quad to remove to the transaction. See Transaction.RemoveQuad.
+func (tx *Tx) RemoveQuad(q quad.Quad) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.tx.RemoveQuad(q)
+	return nil
+}
+
+// Deltas returns changes that will be applied on commit. The slice must not be modified.
+func (tx *Tx) Deltas() []Delta {
+	return tx.tx.Deltas
+}
+
+// Commit applies all changes of the transaction. Transaction cannot be used after the commit, even if it fails.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	if len(tx.tx.Deltas) == 0 {
+		return nil
+	}
+	return tx.commit(tx.tx)
+}
+
+// Rollback discards all changes of the transaction. It is a no-op if the transaction was already committed
+// or rolled back, so it is safe to defer it right after Begin.
+func (tx *Tx) Rollback() error {
+	if !tx.done {
+		tx.done = true
+		tx.tx = NewTransactionN(0)
+	}
+	return nil
+}
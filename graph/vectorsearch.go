@@ -0,0 +1,73 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/vector"
+)
+
+// DefaultVectorLimit is a number of nearest nodes returned by a vector search if the limit is not set.
+const DefaultVectorLimit = 10
+
+// VectorQuery is a nearest neighbours search query.
+type VectorQuery struct {
+	// Vector to find the nearest vectors to.
+	Vector vector.Vector
+	// Predicates restricts the search to vector literals of given predicates.
+	// All indexed predicates are searched if empty.
+	Predicates []quad.IRI
+	// Limit is a number of nearest nodes to return. DefaultVectorLimit is used if not set.
+	Limit int
+}
+
+// VectorHit is a node found by a vector search.
+type VectorHit struct {
+	// Node is a subject of quads with matching vector literals.
+	Node quad.Value
+	// Distance from the query vector to the closest vector of the node, according to the metric of the index.
+	Distance float64
+}
+
+// VectorSearcher is an optional interface for QuadStores that maintain a nearest neighbours index over
+// vector literals (see vector.FromValue) of some predicates. The index may be backend-native,
+// maintained by a Wrapper or by an external vector database.
+type VectorSearcher interface {
+	// SearchVectors returns nodes with vectors nearest to the query vector, ordered by distance.
+	// Results of approximate indexes may miss some of the nearest nodes.
+	SearchVectors(ctx context.Context, q VectorQuery) ([]VectorHit, error)
+}
+
+// asVectorSearcher finds a VectorSearcher in a chain of Handles and Wrappers.
+func asVectorSearcher(qs QuadStore) VectorSearcher {
+	for qs != nil {
+		if s, ok := qs.(VectorSearcher); ok {
+			return s
+		}
+		qs = unwrapOnce(qs)
+	}
+	return nil
+}
+
+// SearchVectors returns nodes with vectors nearest to the query vector, ordered by distance.
+// It returns ErrNotSupported if QuadStore does not implement VectorSearcher.
+func SearchVectors(ctx context.Context, qs QuadStore, q VectorQuery) ([]VectorHit, error) {
+	if s := asVectorSearcher(qs); s != nil {
+		return s.SearchVectors(ctx, q)
+	}
+	return nil, ErrNotSupported
+}
@@ -18,6 +18,11 @@ type Handle struct {
 	graph.QuadWriter
 }
 
+// Begin starts a transaction on the database.
+func (h *Handle) Begin() *graph.Tx {
+	return graph.BeginWriter(h.QuadWriter)
+}
+
 func (h *Handle) Close() error {
 	err := h.QuadWriter.Close()
 	h.QuadStore.Close()
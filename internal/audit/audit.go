@@ -0,0 +1,260 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit implements an audit log that records who ran which queries and writes against the database.
+//
+// Entries are written to a pluggable sink (see RegisterSink). Sinks that keep entries locally can also be
+// searched and support a retention policy, while others forward entries to an external system.
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// ErrNotSearchable is returned when searching a log with a sink that cannot be searched.
+var ErrNotSearchable = errors.New("audit: sink does not support search")
+
+// Sink stores or forwards audit entries.
+type Sink interface {
+	// Write records a single entry.
+	Write(e model.AuditEntry) error
+	// Close flushes pending entries and releases resources.
+	Close() error
+}
+
+// Searcher is an optional interface for sinks that can search recorded entries.
+type Searcher interface {
+	// Search returns entries matching the filter, newest first.
+	Search(ctx context.Context, f Filter) ([]model.AuditEntry, error)
+}
+
+// Purger is an optional interface for sinks that can remove old entries.
+type Purger interface {
+	// Purge removes entries recorded before a given time and returns the number of removed entries.
+	Purge(before time.Time) (int, error)
+}
+
+// NewSinkFunc creates a sink with a given address. The meaning of the address depends on the sink type.
+type NewSinkFunc func(addr string, opts graph.Options) (Sink, error)
+
+var (
+	sinksMu sync.RWMutex
+	sinks   = make(map[string]NewSinkFunc)
+)
+
+// RegisterSink registers a sink type.
+func RegisterSink(name string, fnc NewSinkFunc) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	if _, ok := sinks[name]; ok {
+		panic(fmt.Errorf("audit sink %q is already registered", name))
+	}
+	sinks[name] = fnc
+}
+
+// Sinks returns names of all registered sink types.
+func Sinks() []string {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	out := make([]string, 0, len(sinks))
+	for name := range sinks {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// NewSink creates a sink of a given type.
+func NewSink(typ, addr string, opts graph.Options) (Sink, error) {
+	sinksMu.RLock()
+	fnc := sinks[typ]
+	sinksMu.RUnlock()
+	if fnc == nil {
+		return nil, fmt.Errorf("audit: unknown sink type %q (supported: %s)", typ, strings.Join(Sinks(), ", "))
+	}
+	return fnc(addr, opts)
+}
+
+// Filter selects entries of the audit log. Zero fields match all entries.
+type Filter struct {
+	Since  time.Time // entries recorded at or after this time
+	Until  time.Time // entries recorded before this time
+	User   string
+	Role   string
+	Path   string // path prefix
+	Lang   string
+	Text   string // substring of the query
+	Failed bool   // only entries of failed requests
+	Limit  int    // maximal number of entries to return; zero means no limit
+}
+
+// Match checks if the entry satisfies the filter. The limit is not checked.
+func (f *Filter) Match(e *model.AuditEntry) bool {
+	switch {
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	case f.User != "" && e.User != f.User:
+		return false
+	case f.Path != "" && !strings.HasPrefix(e.Path, f.Path):
+		return false
+	case f.Lang != "" && e.Lang != f.Lang:
+		return false
+	case f.Text != "" && !strings.Contains(e.Query, f.Text):
+		return false
+	case f.Failed && e.Error == "":
+		return false
+	}
+	if f.Role != "" {
+		for _, r := range e.Roles {
+			if r == f.Role {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Config sets options of the audit log.
+type Config struct {
+	// Retention is the time after which entries are removed from the sink.
+	// Zero means that entries are kept forever. It only applies to sinks that implement Purger.
+	Retention time.Duration
+}
+
+// Log records entries to a sink and applies the retention policy.
+type Log struct {
+	sink      Sink
+	retention time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates an audit log that writes entries to a given sink. The sink is closed with the log.
+func New(sink Sink, conf Config) *Log {
+	l := &Log{sink: sink, retention: conf.Retention}
+	if _, ok := sink.(Purger); ok && l.retention > 0 {
+		l.stop = make(chan struct{})
+		l.done = make(chan struct{})
+		go l.runRetention()
+	}
+	return l
+}
+
+// purgeInterval returns an interval between applying the retention policy.
+func (l *Log) purgeInterval() time.Duration {
+	d := l.retention / 10
+	if d > time.Hour {
+		d = time.Hour
+	} else if d < time.Second {
+		d = time.Second
+	}
+	return d
+}
+
+func (l *Log) runRetention() {
+	defer close(l.done)
+	t := time.NewTicker(l.purgeInterval())
+	defer t.Stop()
+	for {
+		if n, err := l.Purge(); err != nil {
+			clog.Errorf("audit: cannot remove old entries: %v", err)
+		} else if n > 0 {
+			clog.Infof("audit: removed %d old entries", n)
+		}
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Record writes an entry to the audit log. Errors are reported to the server log.
+func (l *Log) Record(e model.AuditEntry) {
+	if err := l.sink.Write(e); err != nil {
+		clog.Errorf("audit: cannot record entry: %v", err)
+	}
+}
+
+// Search returns entries matching the filter, newest first.
+// It returns ErrNotSearchable if the sink cannot be searched.
+func (l *Log) Search(ctx context.Context, f Filter) ([]model.AuditEntry, error) {
+	s, ok := l.sink.(Searcher)
+	if !ok {
+		return nil, ErrNotSearchable
+	}
+	return s.Search(ctx, f)
+}
+
+// Purge removes entries that are older than the retention period.
+func (l *Log) Purge() (int, error) {
+	p, ok := l.sink.(Purger)
+	if !ok || l.retention <= 0 {
+		return 0, nil
+	}
+	return p.Purge(time.Now().Add(-l.retention))
+}
+
+// Close stops the retention policy and closes the sink.
+func (l *Log) Close() error {
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+	}
+	return l.sink.Close()
+}
+
+// collector keeps the last entries matching the filter, up to the limit.
+type collector struct {
+	f   *Filter
+	out []model.AuditEntry
+	i   int // next position to overwrite, if the buffer is full
+}
+
+func (c *collector) add(e *model.AuditEntry) {
+	if !c.f.Match(e) {
+		return
+	}
+	if c.f.Limit <= 0 || len(c.out) < c.f.Limit {
+		c.out = append(c.out, *e)
+		return
+	}
+	c.out[c.i] = *e
+	c.i = (c.i + 1) % len(c.out)
+}
+
+// result returns collected entries, newest first.
+func (c *collector) result() []model.AuditEntry {
+	out := make([]model.AuditEntry, 0, len(c.out))
+	out = append(out, c.out[c.i:]...)
+	out = append(out, c.out[:c.i]...)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+var t0 = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func testEntries() []model.AuditEntry {
+	return []model.AuditEntry{
+		{Time: t0, User: "alice", Path: "/api/v2/query", Lang: "gizmo", Query: `g.V("<alice>").All()`},
+		{Time: t0.Add(time.Minute), User: "bob", Roles: []string{"writer"}, Path: "/api/v2/write"},
+		{Time: t0.Add(2 * time.Minute), User: "alice", Path: "/api/v2/query", Lang: "graphql", Error: "query"},
+		{Time: t0.Add(3 * time.Minute), User: "bob", Path: "/api/v1/query/gizmo", Lang: "gizmo", Query: `g.V("<bob>").All()`},
+	}
+}
+
+func users(entries []model.AuditEntry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.User+e.Time.Format(":04"))
+	}
+	return out
+}
+
+func testSearcher(t *testing.T, s interface {
+	Sink
+	Searcher
+	Purger
+}) {
+	for _, e := range testEntries() {
+		require.NoError(t, s.Write(e))
+	}
+	ctx := context.TODO()
+	for _, c := range []struct {
+		name   string
+		filter Filter
+		expect []string
+	}{
+		{name: "all", expect: []string{"bob:03", "alice:02", "bob:01", "alice:00"}},
+		{name: "limit", filter: Filter{Limit: 2}, expect: []string{"bob:03", "alice:02"}},
+		{name: "user", filter: Filter{User: "alice"}, expect: []string{"alice:02", "alice:00"}},
+		{name: "role", filter: Filter{Role: "writer"}, expect: []string{"bob:01"}},
+		{name: "path", filter: Filter{Path: "/api/v2/"}, expect: []string{"alice:02", "bob:01", "alice:00"}},
+		{name: "lang", filter: Filter{Lang: "gizmo", Limit: 1}, expect: []string{"bob:03"}},
+		{name: "text", filter: Filter{Text: "<alice>"}, expect: []string{"alice:00"}},
+		{name: "failed", filter: Filter{Failed: true}, expect: []string{"alice:02"}},
+		{name: "range", filter: Filter{Since: t0.Add(time.Minute), Until: t0.Add(3 * time.Minute)}, expect: []string{"alice:02", "bob:01"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := s.Search(ctx, c.filter)
+			require.NoError(t, err)
+			require.Equal(t, c.expect, users(out))
+		})
+	}
+	n, err := s.Purge(t0.Add(2 * time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.NoError(t, s.Write(model.AuditEntry{Time: t0.Add(4 * time.Minute), User: "fred"}))
+	out, err := s.Search(ctx, Filter{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"fred:04", "bob:03", "alice:02"}, users(out))
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory(10)
+	defer m.Close()
+	testSearcher(t, m)
+
+	// only last entries are kept
+	m = NewMemory(2)
+	for _, e := range testEntries() {
+		require.NoError(t, m.Write(e))
+	}
+	out, err := m.Search(context.TODO(), Filter{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"bob:03", "alice:02"}, users(out))
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley_audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	f, err := OpenFile(path, true)
+	require.NoError(t, err)
+	testSearcher(t, f)
+	require.NoError(t, f.Close())
+
+	// entries are appended when the file is reopened
+	f, err = OpenFile(path, false)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, f.Write(model.AuditEntry{Time: t0.Add(5 * time.Minute), User: "greg"}))
+	out, err := f.Search(context.TODO(), Filter{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"greg:05", "fred:04"}, users(out))
+}
+
+func TestWebhook(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []model.AuditEntry
+		requests int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var list model.AuditEntryList
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&list))
+		mu.Lock()
+		received = append(received, list.Entries...)
+		requests++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("webhook", srv.URL, map[string]interface{}{
+		"batch": 3, "interval": "1h", "token": "secret",
+	})
+	require.NoError(t, err)
+	l := New(s, Config{Retention: time.Hour})
+	for _, e := range testEntries() {
+		l.Record(e)
+	}
+	_, err = l.Search(context.TODO(), Filter{})
+	require.Equal(t, ErrNotSearchable, err)
+	// pending entries are sent on close
+	require.NoError(t, l.Close())
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 2, requests)
+	require.Equal(t, testEntries(), received)
+}
+
+func TestRetention(t *testing.T) {
+	m := NewMemory(10)
+	for _, e := range testEntries() {
+		require.NoError(t, m.Write(e))
+	}
+	now := model.AuditEntry{Time: time.Now(), User: "fred"}
+	require.NoError(t, m.Write(now))
+	l := New(m, Config{Retention: time.Hour})
+	defer l.Close()
+	// old entries are removed on start
+	for i := 0; i < 100; i++ {
+		out, err := l.Search(context.TODO(), Filter{})
+		require.NoError(t, err)
+		if len(out) == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("old entries were not removed")
+}
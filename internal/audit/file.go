@@ -0,0 +1,199 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+func init() {
+	RegisterSink("file", func(addr string, opts graph.Options) (Sink, error) {
+		doSync, err := opts.BoolKey("sync", false)
+		if err != nil {
+			return nil, err
+		}
+		return OpenFile(addr, doSync)
+	})
+}
+
+// File is a sink that appends entries to a file as JSON lines.
+//
+// The file is searched by scanning it, and the retention policy is applied by rewriting it.
+type File struct {
+	path string
+	sync bool
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// OpenFile opens or creates a file sink. If sync is set, the file is synced to the disk after each entry.
+func OpenFile(path string, sync bool) (*File, error) {
+	if path == "" {
+		return nil, errors.New("audit: file path is not set")
+	}
+	f := &File{path: path, sync: sync}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	fh, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	f.f = fh
+	f.enc = json.NewEncoder(fh)
+	f.enc.SetEscapeHTML(false)
+	return nil
+}
+
+// Write implements Sink.
+func (f *File) Write(e model.AuditEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return os.ErrClosed
+	}
+	if err := f.enc.Encode(e); err != nil {
+		return err
+	}
+	if f.sync {
+		return f.f.Sync()
+	}
+	return nil
+}
+
+// scan reads all entries from the file and calls fnc for each of them.
+func (f *File) scan(ctx context.Context, fnc func(e *model.AuditEntry) error) error {
+	fh, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	// lines are read without a limit, since queries can be large
+	r := bufio.NewReader(fh)
+	for i := 0; ; i++ {
+		if i%1000 == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+		}
+		line, err := r.ReadBytes('\n')
+		if len(line) != 0 && line[len(line)-1] == '\n' {
+			var e model.AuditEntry
+			if err := json.Unmarshal(line, &e); err != nil {
+				return err
+			}
+			if err := fnc(&e); err != nil {
+				return err
+			}
+		}
+		// the last line without a newline is either empty or not completely written yet
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Search implements Searcher.
+func (f *File) Search(ctx context.Context, filter Filter) ([]model.AuditEntry, error) {
+	c := collector{f: &filter}
+	err := f.scan(ctx, func(e *model.AuditEntry) error {
+		c.add(e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.result(), nil
+}
+
+// Purge implements Purger. It blocks writes while the file is rewritten.
+func (f *File) Purge(before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return 0, os.ErrClosed
+	}
+	tmp := f.path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp)
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	cnt := 0
+	err = f.scan(context.Background(), func(e *model.AuditEntry) error {
+		if e.Time.Before(before) {
+			cnt++
+			return nil
+		}
+		return enc.Encode(e)
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if err2 := out.Close(); err == nil {
+		err = err2
+	}
+	if err != nil || cnt == 0 {
+		return 0, err
+	}
+	if err = os.Rename(tmp, f.path); err != nil {
+		return 0, err
+	}
+	// reopen the file, since the old descriptor points to the removed one
+	f.f.Close()
+	f.f = nil
+	if err = f.open(); err != nil {
+		return cnt, err
+	}
+	return cnt, nil
+}
+
+// Close implements Sink.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
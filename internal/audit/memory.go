@@ -0,0 +1,102 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// DefaultMemorySize is the default number of entries kept by the memory sink.
+const DefaultMemorySize = 10000
+
+func init() {
+	RegisterSink("memory", func(_ string, opts graph.Options) (Sink, error) {
+		size, err := opts.IntKey("size", DefaultMemorySize)
+		if err != nil {
+			return nil, err
+		}
+		return NewMemory(size), nil
+	})
+}
+
+// Memory is a sink that keeps a given number of last entries in memory.
+type Memory struct {
+	mu    sync.RWMutex
+	buf   []model.AuditEntry
+	start int // index of the oldest entry
+	n     int // number of entries in the buffer
+}
+
+// NewMemory creates a memory sink that keeps up to size last entries.
+func NewMemory(size int) *Memory {
+	if size <= 0 {
+		size = DefaultMemorySize
+	}
+	return &Memory{buf: make([]model.AuditEntry, size)}
+}
+
+// Write implements Sink.
+func (m *Memory) Write(e model.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := (m.start + m.n) % len(m.buf)
+	m.buf[i] = e
+	if m.n < len(m.buf) {
+		m.n++
+	} else {
+		m.start = (m.start + 1) % len(m.buf)
+	}
+	return nil
+}
+
+// at returns i-th entry, starting from the oldest one.
+func (m *Memory) at(i int) *model.AuditEntry {
+	return &m.buf[(m.start+i)%len(m.buf)]
+}
+
+// Search implements Searcher.
+func (m *Memory) Search(ctx context.Context, f Filter) ([]model.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c := collector{f: &f}
+	for i := 0; i < m.n; i++ {
+		c.add(m.at(i))
+	}
+	return c.result(), nil
+}
+
+// Purge implements Purger.
+func (m *Memory) Purge(before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cnt := 0
+	for m.n > 0 && m.at(0).Time.Before(before) {
+		*m.at(0) = model.AuditEntry{}
+		m.start = (m.start + 1) % len(m.buf)
+		m.n--
+		cnt++
+	}
+	return cnt, nil
+}
+
+// Close implements Sink.
+func (m *Memory) Close() error {
+	return nil
+}
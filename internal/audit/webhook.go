@@ -0,0 +1,188 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// Defaults for the webhook sink.
+const (
+	DefaultWebhookBatch    = 100
+	DefaultWebhookInterval = time.Second
+	DefaultWebhookTimeout  = 10 * time.Second
+
+	webhookRetries = 3
+)
+
+func init() {
+	RegisterSink("webhook", func(addr string, opts graph.Options) (Sink, error) {
+		var (
+			conf WebhookConfig
+			err  error
+		)
+		if conf.Batch, err = opts.IntKey("batch", DefaultWebhookBatch); err != nil {
+			return nil, err
+		}
+		if conf.Interval, err = opts.DurationKey("interval", DefaultWebhookInterval); err != nil {
+			return nil, err
+		}
+		if conf.Timeout, err = opts.DurationKey("timeout", DefaultWebhookTimeout); err != nil {
+			return nil, err
+		}
+		if conf.Token, err = opts.StringKey("token", ""); err != nil {
+			return nil, err
+		}
+		return NewWebhook(addr, conf)
+	})
+}
+
+// WebhookConfig sets options of the webhook sink.
+type WebhookConfig struct {
+	Batch    int           // maximal number of entries in a single request
+	Interval time.Duration // maximal delay before pending entries are sent
+	Timeout  time.Duration // timeout of a single request
+	Token    string        // bearer token sent with requests, if set
+}
+
+// Webhook is a sink that sends batches of entries to an HTTP endpoint.
+//
+// Entries are sent as a JSON object with the "entries" array in POST requests. Failed requests are retried
+// a few times, after which the batch is dropped and the error is reported to the server log.
+// Writes block if the endpoint cannot keep up with the rate of requests.
+type Webhook struct {
+	url  string
+	conf WebhookConfig
+	cli  *http.Client
+
+	entries chan model.AuditEntry
+	done    chan struct{}
+}
+
+// NewWebhook creates a webhook sink that sends entries to a given URL.
+func NewWebhook(addr string, conf WebhookConfig) (*Webhook, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("audit: unsupported webhook url: %q", addr)
+	}
+	if conf.Batch <= 0 {
+		conf.Batch = DefaultWebhookBatch
+	}
+	if conf.Interval <= 0 {
+		conf.Interval = DefaultWebhookInterval
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = DefaultWebhookTimeout
+	}
+	wh := &Webhook{
+		url: addr, conf: conf,
+		cli:     &http.Client{Timeout: conf.Timeout},
+		entries: make(chan model.AuditEntry, conf.Batch),
+		done:    make(chan struct{}),
+	}
+	go wh.run()
+	return wh, nil
+}
+
+// Write implements Sink.
+func (wh *Webhook) Write(e model.AuditEntry) error {
+	wh.entries <- e
+	return nil
+}
+
+func (wh *Webhook) run() {
+	defer close(wh.done)
+	t := time.NewTicker(wh.conf.Interval)
+	defer t.Stop()
+	batch := make([]model.AuditEntry, 0, wh.conf.Batch)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := wh.send(batch); err != nil {
+			clog.Errorf("audit: dropped %d entries: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case e, ok := <-wh.entries:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= wh.conf.Batch {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		}
+	}
+}
+
+// send posts a batch of entries, retrying failed requests.
+func (wh *Webhook) send(batch []model.AuditEntry) error {
+	data, err := json.Marshal(model.AuditEntryList{Entries: batch})
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		err = wh.post(data)
+		if err == nil || i+1 >= webhookRetries {
+			return err
+		}
+		time.Sleep(time.Duration(i+1) * 100 * time.Millisecond)
+	}
+}
+
+func (wh *Webhook) post(data []byte) error {
+	req, err := http.NewRequest("POST", wh.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.conf.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+wh.conf.Token)
+	}
+	resp, err := wh.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New("webhook returned " + resp.Status)
+	}
+	return nil
+}
+
+// Close implements Sink. It sends all pending entries.
+func (wh *Webhook) Close() error {
+	close(wh.entries)
+	<-wh.done
+	return nil
+}
@@ -0,0 +1,315 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth implements authentication and authorization of requests to the HTTP API.
+//
+// Requests are authenticated with API keys or JSON Web Tokens (see Authenticator). Roles of the authenticated
+// identity are checked against a Policy that grants each role read, write or admin access to databases served
+// by the process. Roles are also passed to the request context with acl.WithRoles, so access to named graphs
+// within a database can be restricted further with acl.Policy.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/server/http"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// ErrInvalidCredentials is returned when credentials of the request are not valid.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Level is an access level to a database. Each level includes all lower ones.
+type Level uint8
+
+const (
+	// None denies all requests.
+	None Level = iota
+	// Read allows queries.
+	Read
+	// Write allows to add and remove quads.
+	Write
+	// Admin allows maintenance endpoints, management of graphs and namespaces.
+	Admin
+)
+
+func (l Level) String() string {
+	switch l {
+	case None:
+		return "none"
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case Admin:
+		return "admin"
+	}
+	return fmt.Sprintf("Level(%d)", uint8(l))
+}
+
+// ParseLevel parses the name of access level: "read", "write" or "admin".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "read":
+		return Read, nil
+	case "write":
+		return Write, nil
+	case "admin":
+		return Admin, nil
+	}
+	return None, fmt.Errorf("auth: unknown access level: %q", s)
+}
+
+// Identity is an authenticated client.
+type Identity struct {
+	Name  string
+	Roles []string
+}
+
+// Authenticator checks credentials of the request.
+type Authenticator interface {
+	// Authenticate returns an identity of the request. It returns nil if the request has no credentials
+	// recognized by this authenticator, and ErrInvalidCredentials if the credentials are recognized, but are not valid.
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Permission grants a role access to a database.
+type Permission struct {
+	Role string
+	// Database is a name of the database. An empty name is the main database, and "*" is any database.
+	Database string
+	Level    Level
+}
+
+// Policy is a set of permissions. It is safe for concurrent use.
+type Policy struct {
+	roles map[string]map[string]Level // role -> database -> level
+}
+
+// NewPolicy creates a policy from a list of permissions. The highest level is used if a role
+// is granted multiple permissions for the same database.
+func NewPolicy(perms ...Permission) *Policy {
+	p := &Policy{roles: make(map[string]map[string]Level)}
+	for _, pm := range perms {
+		dbs := p.roles[pm.Role]
+		if dbs == nil {
+			dbs = make(map[string]Level)
+			p.roles[pm.Role] = dbs
+		}
+		if pm.Level > dbs[pm.Database] {
+			dbs[pm.Database] = pm.Level
+		}
+	}
+	return p
+}
+
+// Level returns the access level of given roles to a database. Permissions of acl.Anyone role are granted to everyone.
+func (p *Policy) Level(roles []string, db string) Level {
+	lvl := None
+	check := func(role string) {
+		dbs := p.roles[role]
+		if l := dbs[db]; l > lvl {
+			lvl = l
+		}
+		if l := dbs["*"]; l > lvl {
+			lvl = l
+		}
+	}
+	check(acl.Anyone)
+	for _, r := range roles {
+		check(r)
+	}
+	return lvl
+}
+
+// Allowed checks if given roles have at least a given access level to a database.
+func (p *Policy) Allowed(roles []string, db string, lvl Level) bool {
+	return p.Level(roles, db) >= lvl
+}
+
+const (
+	dbPrefix     = "/db/"
+	graphsPrefix = "/api/v2/graphs/"
+)
+
+// readPosts lists endpoints that accept POST requests, but do not modify the database.
+var readPosts = []string{
+	"/api/v2/query",
+	"/api/v2/read",
+	"/api/v1/query/",
+	"/api/v1/shape/",
+}
+
+// Classify returns the name of the database a request is sent to (empty for the main database)
+// and the access level required to serve it.
+func Classify(method, path string) (string, Level) {
+	db := ""
+	if strings.HasPrefix(path, dbPrefix) {
+		db, path = splitName(strings.TrimPrefix(path, dbPrefix))
+	} else if strings.HasPrefix(path, graphsPrefix) {
+		name, rest := splitName(strings.TrimPrefix(path, graphsPrefix))
+		if name == "" {
+			// list of graphs
+			return "", Read
+		} else if rest == "" {
+			// creating or dropping a graph
+			if method == http.MethodGet || method == http.MethodHead {
+				return name, Read
+			}
+			return name, Admin
+		}
+		db, path = name, "/api/v2"+rest
+	}
+	return db, classifyPath(method, path)
+}
+
+func splitName(path string) (name, rest string) {
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[:i], path[i:]
+	}
+	return path, ""
+}
+
+func classifyPath(method, path string) Level {
+	if strings.HasPrefix(path, "/api/v2/admin/") {
+		return Admin
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return Read
+	}
+	for _, op := range model.Operations {
+		if op.Method == method && matchPath(op.Path, path) {
+			if op.Admin {
+				return Admin
+			} else if op.Write {
+				return Write
+			}
+			return Read
+		}
+	}
+	for _, p := range readPosts {
+		if method == http.MethodPost && (path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p))) {
+			return Read
+		}
+	}
+	// unknown methods are assumed to modify the database
+	return Write
+}
+
+// matchPath checks if a path matches a pattern in the OpenAPI notation, for example "/api/v2/jobs/{id}".
+func matchPath(pattern, path string) bool {
+	ps, ss := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(ps) != len(ss) {
+		return false
+	}
+	for i, p := range ps {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if ss[i] == "" {
+				return false
+			}
+		} else if p != ss[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isPublic checks if a path can be accessed without authentication. It includes health probes
+// and the web UI pages, while all data is still fetched through the API.
+func isPublic(path string) bool {
+	switch path {
+	case "/", "/healthz", "/readyz":
+		return true
+	}
+	for _, p := range []string{"/ui/", "/static/", "/docs/"} {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware authenticates requests with a list of authenticators and checks their access level with the policy.
+//
+// Requests without credentials are only allowed if the policy grants access to acl.Anyone. Roles of the request
+// are set in the context with acl.WithRoles. Requests with admin access to the database are also allowed to access
+// admin endpoints without the admin token (see cayleyhttp.WithAdminAccess).
+func Middleware(h http.Handler, auths []Authenticator, p *Policy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions || isPublic(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		var id *Identity
+		for _, a := range auths {
+			var err error
+			id, err = a.Authenticate(r)
+			if err != nil {
+				clog.Infof("auth: rejected request from %s: %v", r.RemoteAddr, err)
+				unauthorized(w, err)
+				return
+			} else if id != nil {
+				break
+			}
+		}
+		if id == nil && hasCredentials(r) {
+			unauthorized(w, ErrInvalidCredentials)
+			return
+		}
+		var roles []string
+		if id != nil {
+			roles = id.Roles
+		}
+		db, lvl := Classify(r.Method, r.URL.Path)
+		if !p.Allowed(roles, db, lvl) {
+			if id == nil {
+				unauthorized(w, errors.New("authentication required"))
+				return
+			}
+			jsonError(w, http.StatusForbidden, fmt.Errorf("%s access is not granted", lvl))
+			return
+		}
+		ctx := acl.WithRoles(r.Context(), roles...)
+		if lvl == Admin || p.Level(roles, db) == Admin {
+			ctx = cayleyhttp.WithAdminAccess(ctx)
+		}
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// hasCredentials checks if the request carries credentials of any kind.
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || r.Header.Get(apiKeyHeader) != "" ||
+		r.Header.Get(cayleyhttp.AdminTokenHeader) != ""
+}
+
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="cayley"`)
+	jsonError(w, http.StatusUnauthorized, err)
+}
+
+func jsonError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/server/http"
+)
+
+var classifyCases = []struct {
+	method, path string
+	db           string
+	level        Level
+}{
+	{"GET", "/api/v2/read", "", Read},
+	{"POST", "/api/v2/query", "", Read},
+	{"POST", "/api/v1/query/gizmo", "", Read},
+	{"POST", "/api/v2/write", "", Write},
+	{"POST", "/api/v1/delete", "", Write},
+	{"DELETE", "/api/v2/namespaces/ex", "", Admin},
+	{"POST", "/api/v2/namespaces", "", Admin},
+	{"GET", "/api/v2/jobs/1", "", Read},
+	{"POST", "/api/v2/jobs", "", Write},
+	{"DELETE", "/api/v2/jobs/1", "", Write},
+	{"GET", "/api/v2/admin/stats", "", Admin},
+	{"POST", "/db/people/api/v2/write", "people", Write},
+	{"GET", "/db/people/api/v2/admin/backup", "people", Admin},
+	{"POST", "/api/v2/graphs/people/query", "people", Read},
+	{"POST", "/api/v2/graphs/people/delete", "people", Write},
+	{"PUT", "/api/v2/graphs/people", "people", Admin},
+	{"DELETE", "/api/v2/graphs/people", "people", Admin},
+	{"GET", "/api/v2/graphs/", "", Read},
+	{"POST", "/api/v2/unknown", "", Write},
+}
+
+func TestClassify(t *testing.T) {
+	for _, c := range classifyCases {
+		db, lvl := Classify(c.method, c.path)
+		require.Equal(t, c.db, db, "%s %s", c.method, c.path)
+		require.Equal(t, c.level, lvl, "%s %s", c.method, c.path)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := NewPolicy(
+		Permission{Role: acl.Anyone, Database: "", Level: Read},
+		Permission{Role: "writer", Database: "people", Level: Write},
+		Permission{Role: "ops", Database: "*", Level: Admin},
+	)
+	require.True(t, p.Allowed(nil, "", Read))
+	require.False(t, p.Allowed(nil, "", Write))
+	require.False(t, p.Allowed(nil, "people", Read))
+	require.True(t, p.Allowed([]string{"writer"}, "people", Write))
+	require.False(t, p.Allowed([]string{"writer"}, "people", Admin))
+	require.False(t, p.Allowed([]string{"writer"}, "movies", Read))
+	require.True(t, p.Allowed([]string{"writer", "ops"}, "movies", Admin))
+}
+
+func signHS256(t testing.TB, secret string, claims map[string]interface{}) string {
+	enc := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	s := enc(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + enc(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(s))
+	return s + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestMiddleware(t *testing.T) {
+	var roles []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		roles = acl.RolesFromContext(r.Context())
+	})
+	auths := []Authenticator{
+		NewAPIKeys(map[string]Identity{"key1": {Name: "ci", Roles: []string{"writer"}}}),
+		&JWT{Secret: []byte("secret"), Issuer: "idp"},
+	}
+	p := NewPolicy(
+		Permission{Role: acl.Anyone, Database: "", Level: Read},
+		Permission{Role: "writer", Database: "*", Level: Write},
+	)
+	srv := Middleware(h, auths, p)
+
+	do := func(method, path, token string) int {
+		roles = nil
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Code
+	}
+	exp := float64(time.Now().Add(time.Hour).Unix())
+
+	require.Equal(t, http.StatusOK, do("GET", "/healthz", ""))
+	require.Equal(t, http.StatusOK, do("GET", "/api/v2/read", ""))
+	require.Equal(t, http.StatusUnauthorized, do("POST", "/api/v2/write", ""))
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/api/v2/read", "wrong"))
+
+	require.Equal(t, http.StatusOK, do("POST", "/api/v2/write", "key1"))
+	require.Equal(t, []string{"writer"}, roles)
+	require.Equal(t, http.StatusForbidden, do("GET", "/api/v2/admin/stats", "key1"))
+
+	tok := signHS256(t, "secret", map[string]interface{}{"sub": "bob", "iss": "idp", "roles": []string{"writer"}, "exp": exp})
+	require.Equal(t, http.StatusOK, do("POST", "/db/people/api/v2/write", tok))
+	require.Equal(t, []string{"writer"}, roles)
+
+	tok = signHS256(t, "secret", map[string]interface{}{"sub": "bob", "iss": "idp", "roles": "reader", "exp": exp})
+	require.Equal(t, http.StatusForbidden, do("POST", "/api/v2/write", tok))
+
+	tok = signHS256(t, "other", map[string]interface{}{"sub": "bob", "iss": "idp", "roles": []string{"writer"}, "exp": exp})
+	require.Equal(t, http.StatusUnauthorized, do("POST", "/api/v2/write", tok))
+
+	tok = signHS256(t, "secret", map[string]interface{}{"sub": "bob", "iss": "idp", "roles": []string{"writer"}, "exp": exp - 3*3600})
+	require.Equal(t, http.StatusUnauthorized, do("POST", "/api/v2/write", tok))
+
+	tok = signHS256(t, "secret", map[string]interface{}{"sub": "bob", "iss": "other", "roles": []string{"writer"}, "exp": exp})
+	require.Equal(t, http.StatusUnauthorized, do("POST", "/api/v2/write", tok))
+}
+
+func TestAdminToken(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cayleyhttp.CheckAdminToken(w, r, "admin") {
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	auths := []Authenticator{
+		NewAdminToken("admin"),
+		&JWT{Secret: []byte("secret"), Issuer: "idp"},
+	}
+	p := NewPolicy(
+		Permission{Role: AdminTokenRole, Database: "*", Level: Admin},
+		Permission{Role: "ops", Database: "*", Level: Admin},
+		Permission{Role: "writer", Database: "*", Level: Write},
+	)
+	srv := Middleware(h, auths, p)
+
+	do := func(method, path string, hdr http.Header) int {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range hdr {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Code
+	}
+	bearer := func(tok string) http.Header {
+		return http.Header{"Authorization": {"Bearer " + tok}}
+	}
+	exp := float64(time.Now().Add(time.Hour).Unix())
+
+	require.Equal(t, http.StatusOK, do("GET", "/api/v2/admin/stats", http.Header{cayleyhttp.AdminTokenHeader: {"admin"}}))
+	require.Equal(t, http.StatusOK, do("GET", "/api/v2/admin/stats", bearer("admin")))
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/api/v2/admin/stats", http.Header{cayleyhttp.AdminTokenHeader: {"wrong"}}))
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/api/v2/admin/stats", bearer("wrong")))
+
+	// admin endpoints accept users with admin access, even if the endpoint only requires write access
+	tok := signHS256(t, "secret", map[string]interface{}{"sub": "bob", "iss": "idp", "roles": []string{"ops"}, "exp": exp})
+	require.Equal(t, http.StatusOK, do("POST", "/api/v2/write/stream", bearer(tok)))
+
+	tok = signHS256(t, "secret", map[string]interface{}{"sub": "bob", "iss": "idp", "roles": []string{"writer"}, "exp": exp})
+	require.Equal(t, http.StatusOK, do("POST", "/api/v2/write/stream", http.Header{
+		"Authorization":             {"Bearer " + tok},
+		cayleyhttp.AdminTokenHeader: {"admin"},
+	}))
+	require.Equal(t, http.StatusUnauthorized, do("POST", "/api/v2/write/stream", bearer(tok)))
+}
@@ -0,0 +1,231 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultRolesClaim is a name of the JWT claim with roles of the client.
+const DefaultRolesClaim = "roles"
+
+// jwtLeeway is an allowed clock skew when checking token expiration.
+const jwtLeeway = time.Minute
+
+// JWT authenticates requests with JSON Web Tokens passed in "Authorization: Bearer" header.
+//
+// Tokens signed with HMAC (HS256, HS384, HS512) and RSA (RS256, RS384, RS512) are supported.
+// The subject claim is used as a name of the identity.
+type JWT struct {
+	// Secret is a shared key for HMAC signatures. HMAC tokens are rejected if it is empty.
+	Secret []byte
+	// PublicKey verifies RSA signatures. RSA tokens are rejected if it is nil.
+	PublicKey *rsa.PublicKey
+	// Issuer is an expected value of the issuer claim. It is not checked if empty.
+	Issuer string
+	// Audience is an expected value of the audience claim. It is not checked if empty.
+	Audience string
+	// RolesClaim is a name of the claim with roles: either a list of strings or a space-separated string.
+	// DefaultRolesClaim is used if it is empty.
+	RolesClaim string
+
+	now func() time.Time
+}
+
+// ParseRSAPublicKey parses an RSA public key in PEM format, either PKIX or PKCS#1.
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("auth: no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("auth: cannot parse public key: %v", err)
+	}
+	rk, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("auth: unsupported public key type: %T", key)
+	}
+	return rk, nil
+}
+
+// Authenticate implements Authenticator. Only bearer tokens in JWT format are recognized.
+func (a *JWT) Authenticate(r *http.Request) (*Identity, error) {
+	tok := bearerToken(r)
+	if strings.Count(tok, ".") != 2 {
+		return nil, nil
+	}
+	claims, err := a.verify(tok)
+	if err != nil {
+		return nil, err
+	}
+	return a.identity(claims), nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims map[string]interface{}
+
+func decodeSegment(s string, dst interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// verify checks the signature of the token and returns its claims.
+func (a *JWT) verify(tok string) (jwtClaims, error) {
+	parts := strings.Split(tok, ".")
+	var hdr jwtHeader
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch hdr.Alg {
+	case "HS256", "HS384", "HS512":
+		if len(a.Secret) == 0 {
+			return nil, fmt.Errorf("unsupported token algorithm: %q", hdr.Alg)
+		}
+		mac := hmac.New(hmacHash(hdr.Alg), a.Secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return nil, ErrInvalidCredentials
+		}
+	case "RS256", "RS384", "RS512":
+		if a.PublicKey == nil {
+			return nil, fmt.Errorf("unsupported token algorithm: %q", hdr.Alg)
+		}
+		h := rsaHash(hdr.Alg)
+		hw := h.New()
+		hw.Write(signed)
+		if err := rsa.VerifyPKCS1v15(a.PublicKey, h, hw.Sum(nil), sig); err != nil {
+			return nil, ErrInvalidCredentials
+		}
+	default:
+		// including "none"
+		return nil, fmt.Errorf("unsupported token algorithm: %q", hdr.Alg)
+	}
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if err := a.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func hmacHash(alg string) func() hash.Hash {
+	switch alg {
+	case "HS384":
+		return sha512.New384
+	case "HS512":
+		return sha512.New
+	}
+	return sha256.New
+}
+
+func rsaHash(alg string) crypto.Hash {
+	switch alg {
+	case "RS384":
+		return crypto.SHA384
+	case "RS512":
+		return crypto.SHA512
+	}
+	return crypto.SHA256
+}
+
+// checkClaims validates registered claims of the token.
+func (a *JWT) checkClaims(c jwtClaims) error {
+	now := time.Now()
+	if a.now != nil {
+		now = a.now()
+	}
+	if exp, ok := c["exp"].(float64); ok && now.Add(-jwtLeeway).After(time.Unix(int64(exp), 0)) {
+		return errors.New("token is expired")
+	}
+	if nbf, ok := c["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token is not valid yet")
+	}
+	if a.Issuer != "" {
+		if iss, _ := c["iss"].(string); iss != a.Issuer {
+			return errors.New("unexpected token issuer")
+		}
+	}
+	if a.Audience != "" && !containsString(stringList(c["aud"]), a.Audience) {
+		return errors.New("unexpected token audience")
+	}
+	return nil
+}
+
+func (a *JWT) identity(c jwtClaims) *Identity {
+	claim := a.RolesClaim
+	if claim == "" {
+		claim = DefaultRolesClaim
+	}
+	id := &Identity{Roles: stringList(c[claim])}
+	id.Name, _ = c["sub"].(string)
+	return id
+}
+
+// stringList converts a claim that is either a list of strings or a space-separated string to a list.
+func stringList(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cayleygraph/cayley/server/http"
+)
+
+// apiKeyHeader is an alternative header for API keys, for clients that cannot set the Authorization header.
+const apiKeyHeader = "X-API-Key"
+
+// bearerToken returns a token from "Authorization: Bearer" header, or an empty string.
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(auth[len(prefix):])
+}
+
+// APIKeys authenticates requests with static API keys passed in "Authorization: Bearer" or "X-API-Key" header.
+type APIKeys struct {
+	keys map[[sha256.Size]byte]Identity
+}
+
+// NewAPIKeys creates an authenticator for a set of API keys.
+func NewAPIKeys(keys map[string]Identity) *APIKeys {
+	a := &APIKeys{keys: make(map[[sha256.Size]byte]Identity, len(keys))}
+	for k, id := range keys {
+		// keys are looked up by digest, so the lookup time does not depend on the key prefix
+		a.keys[sha256.Sum256([]byte(k))] = id
+	}
+	return a
+}
+
+// Authenticate implements Authenticator. Unknown keys are not recognized, since the same header is used by tokens.
+func (a *APIKeys) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		key = bearerToken(r)
+	}
+	if key == "" {
+		return nil, nil
+	}
+	id, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, nil
+	}
+	return &id, nil
+}
+
+// AdminTokenRole is a role of requests authenticated by AdminToken.
+const AdminTokenRole = "cayley-admin-token"
+
+// AdminToken authenticates requests with the admin token of the HTTP API, passed in "X-Admin-Token" or
+// "Authorization: Bearer" header (see cayleyhttp.CheckAdminToken). Authenticated requests get AdminTokenRole.
+type AdminToken struct {
+	mu    sync.RWMutex
+	token string
+}
+
+// NewAdminToken creates an authenticator for the admin token. Empty token is never accepted.
+func NewAdminToken(token string) *AdminToken {
+	return &AdminToken{token: token}
+}
+
+// SetToken replaces the admin token.
+func (a *AdminToken) SetToken(token string) {
+	a.mu.Lock()
+	a.token = token
+	a.mu.Unlock()
+}
+
+// Authenticate implements Authenticator. A token in "Authorization" header is not recognized
+// if it does not match, since the same header is used by other credentials.
+func (a *AdminToken) Authenticate(r *http.Request) (*Identity, error) {
+	a.mu.RLock()
+	token := a.token
+	a.mu.RUnlock()
+	tok := cayleyhttp.AdminTokenOf(r)
+	if tok == "" {
+		return nil, nil
+	}
+	if token != "" && subtle.ConstantTimeCompare([]byte(tok), []byte(token)) == 1 {
+		return &Identity{Name: "admin", Roles: []string{AdminTokenRole}}, nil
+	} else if r.Header.Get(cayleyhttp.AdminTokenHeader) != "" {
+		return nil, ErrInvalidCredentials
+	}
+	return nil, nil
+}
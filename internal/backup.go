@@ -0,0 +1,342 @@
+package internal
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+	"github.com/cayleygraph/cayley/schema"
+	"github.com/cayleygraph/cayley/voc"
+)
+
+const (
+	backupMagic   = "CAYLEYBK"
+	backupVersion = 1
+
+	// BackupFormatQuads is a format of backups made by dumping all quads.
+	BackupFormatQuads = "pquads"
+	// BackupFormatDeltas is a format of incremental backups made from the delta log.
+	BackupFormatDeltas = "deltas"
+
+	backupCompressed = 1 << 0
+	backupEncrypted  = 1 << 1
+
+	maxBackupInfo = 16 << 20
+)
+
+// ErrBackupPassword is returned by Restore if the backup is encrypted, but the password is not set.
+var ErrBackupPassword = errors.New("backup is encrypted; password is required")
+
+// BackupOptions controls encoding of backup archives.
+type BackupOptions struct {
+	// Compress enables gzip compression of the archive.
+	Compress bool
+	// Password enables encryption of the archive with AES-256-GCM using a key derived from the password.
+	Password string
+	// Verify checks that the number of quads in the restored database matches the number recorded in the backup.
+	// Only full backups are verified. Since the number is recorded when the backup is started, backups of databases
+	// modified during the backup may fail verification.
+	Verify bool
+}
+
+// BackupInfo is a metadata stored at the beginning of a backup archive.
+type BackupInfo struct {
+	Backend string    `json:"backend"`
+	Format  string    `json:"format"` // snapshot format of the backend, or BackupFormatQuads
+	Created time.Time `json:"created"`
+	// Quads is a number of quads in the database when the backup was started. It is used to verify restored data.
+	Quads      int64           `json:"quads"`
+	Namespaces []voc.Namespace `json:"namespaces,omitempty"`
+	// Horizon of the database at the time of the backup. It is zero if the database does not track horizons.
+	Horizon int64 `json:"horizon,omitempty"`
+	// Since is set for incremental backups, which contain transactions with horizons in range (Since, Horizon].
+	Since int64 `json:"since,omitempty"`
+}
+
+// Incremental reports if the archive is an incremental backup.
+func (info *BackupInfo) Incremental() bool {
+	return info.Format == BackupFormatDeltas
+}
+
+// Backup writes a single archive with the metadata, namespaces and all data of the database.
+//
+// Backends that implement graph.Snapshotter write a consistent snapshot of their data without blocking writes.
+// For other backends all quads are dumped, thus the database should not be modified during the backup.
+func Backup(ctx context.Context, w io.Writer, qs graph.QuadStore, backend string, opt BackupOptions) (*BackupInfo, error) {
+	info := &BackupInfo{
+		Backend: backend,
+		Format:  graph.SnapshotFormat(qs),
+		Created: time.Now().UTC(),
+	}
+	if info.Format == "" {
+		info.Format = BackupFormatQuads
+	}
+	n, err := countQuads(ctx, qs)
+	if err != nil {
+		return nil, err
+	}
+	info.Quads = n
+	// the horizon is read before the data, thus it might be lower than the horizon of the snapshot;
+	// it is safe, since incremental backups are replayed idempotently
+	h, err := graph.Horizon(ctx, qs)
+	if err == nil {
+		info.Horizon = h
+	} else if err != graph.ErrNotSupported {
+		return nil, err
+	}
+	var ns voc.Namespaces
+	if err = schema.LoadNamespaces(ctx, qs, &ns); err != nil {
+		return nil, err
+	}
+	info.Namespaces = ns.List()
+	err = writeBackup(w, info, opt, func(out io.Writer) error {
+		if info.Format == BackupFormatQuads {
+			return dumpQuads(out, qs)
+		}
+		return graph.Snapshot(ctx, qs, out)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// writeBackup writes an archive header with the metadata and calls fnc to write the data.
+func writeBackup(w io.Writer, info *BackupInfo, opt BackupOptions, fnc func(w io.Writer) error) error {
+	var flags byte
+	if opt.Compress {
+		flags |= backupCompressed
+	}
+	if opt.Password != "" {
+		flags |= backupEncrypted
+	}
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(backupMagic); err != nil {
+		return err
+	}
+	bw.WriteByte(backupVersion)
+	bw.WriteByte(flags)
+
+	var (
+		out     io.Writer = bw
+		closers []io.Closer
+	)
+	if opt.Password != "" {
+		salt := make([]byte, encSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		bw.Write(salt)
+		ew, err := newEncryptWriter(out, opt.Password, salt)
+		if err != nil {
+			return err
+		}
+		out = ew
+		closers = append(closers, ew)
+	}
+	if opt.Compress {
+		gw := gzip.NewWriter(out)
+		out = gw
+		closers = append(closers, gw)
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(data)))
+	if _, err = out.Write(buf[:n]); err != nil {
+		return err
+	} else if _, err = out.Write(data); err != nil {
+		return err
+	}
+	if err = fnc(out); err != nil {
+		return err
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err = closers[i].Close(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func dumpQuads(w io.Writer, qs graph.QuadStore) error {
+	qr := graph.NewQuadStoreReader(qs)
+	defer qr.Close()
+	pw := pquads.NewWriter(w, &pquads.Options{Full: false, Strict: false})
+	if _, err := quad.Copy(pw, qr); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+// ReadBackupInfo reads the header of a backup archive and returns its metadata and a reader for the data.
+func ReadBackupInfo(r io.Reader, opt BackupOptions) (*BackupInfo, io.Reader, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, len(backupMagic)+2)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, nil, fmt.Errorf("cannot read backup header: %v", err)
+	} else if string(head[:len(backupMagic)]) != backupMagic {
+		return nil, nil, errors.New("not a backup archive")
+	} else if vers := head[len(backupMagic)]; vers != backupVersion {
+		return nil, nil, fmt.Errorf("unsupported backup version: %d", vers)
+	}
+	flags := head[len(backupMagic)+1]
+
+	var in io.Reader = br
+	if flags&backupEncrypted != 0 {
+		if opt.Password == "" {
+			return nil, nil, ErrBackupPassword
+		}
+		salt := make([]byte, encSaltSize)
+		if _, err := io.ReadFull(br, salt); err != nil {
+			return nil, nil, err
+		}
+		dr, err := newDecryptReader(br, opt.Password, salt)
+		if err != nil {
+			return nil, nil, err
+		}
+		in = dr
+	}
+	if flags&backupCompressed != 0 {
+		gr, err := gzip.NewReader(in)
+		if err != nil {
+			return nil, nil, err
+		}
+		in = gr
+	}
+	ir := bufio.NewReader(in)
+	n, err := binary.ReadUvarint(ir)
+	if err != nil {
+		return nil, nil, err
+	} else if n > maxBackupInfo {
+		return nil, nil, fmt.Errorf("backup metadata is too large: %d", n)
+	}
+	data := make([]byte, n)
+	if _, err = io.ReadFull(ir, data); err != nil {
+		return nil, nil, err
+	}
+	info := new(BackupInfo)
+	if err = json.Unmarshal(data, info); err != nil {
+		return nil, nil, fmt.Errorf("cannot decode backup metadata: %v", err)
+	}
+	return info, ir, nil
+}
+
+// Restore loads a backup archive into an empty database. Namespaces from the archive are written to the
+// database if they are missing in the restored data.
+//
+// Snapshots can only be restored to backends with the same snapshot format, while quad dumps can be restored to any backend.
+// Incremental backups are applied to a database restored from a previous backup, see RestoreUntil.
+func Restore(ctx context.Context, r io.Reader, qs graph.QuadStore, qw graph.QuadWriter, opt BackupOptions) (*BackupInfo, error) {
+	return RestoreUntil(ctx, r, qs, qw, opt, 0)
+}
+
+// RestoreUntil is similar to Restore, but only applies transactions from incremental backups with horizons
+// less or equal to a given one, thus allowing point-in-time recovery. Zero horizon means no limit.
+//
+// A full backup must be restored first, followed by incremental backups in the order they were made.
+func RestoreUntil(ctx context.Context, r io.Reader, qs graph.QuadStore, qw graph.QuadWriter, opt BackupOptions, until int64) (*BackupInfo, error) {
+	info, data, err := ReadBackupInfo(r, opt)
+	if err != nil {
+		return nil, err
+	}
+	if info.Incremental() {
+		return info, applyIncremental(ctx, data, qs, info, until)
+	}
+	if until > 0 && info.Horizon > until {
+		return info, fmt.Errorf("backup was made at horizon %d, after the requested horizon %d", info.Horizon, until)
+	}
+	if qs.Size() != 0 {
+		return info, errors.New("cannot restore a backup into a non-empty database")
+	}
+	if info.Format == BackupFormatQuads {
+		qr := pquads.NewReader(data, 0)
+		_, err = quad.CopyBatch(graph.NewWriter(qw), qr, quad.DefaultBatch)
+		qr.Close()
+	} else {
+		err = graph.RestoreSnapshot(ctx, qs, info.Format, data)
+		if err == graph.ErrNotSupported {
+			err = fmt.Errorf("snapshot of %q backend in %q format cannot be restored to this backend", info.Backend, info.Format)
+		}
+	}
+	if err != nil {
+		return info, err
+	}
+	if opt.Verify {
+		if err = verifyQuads(ctx, qs, info.Quads); err != nil {
+			return info, err
+		}
+	}
+	if info.Horizon != 0 {
+		if err = setRestoredHorizon(ctx, qs, info.Horizon); err != nil {
+			return info, err
+		}
+	}
+	if len(info.Namespaces) == 0 {
+		return info, nil
+	}
+	var cur voc.Namespaces
+	if err = schema.LoadNamespaces(ctx, qs, &cur); err != nil {
+		return info, err
+	}
+	var missing voc.Namespaces
+	for _, ns := range info.Namespaces {
+		if cur.FullIRI(ns.Prefix) == ns.Prefix {
+			missing.Register(ns)
+		}
+	}
+	if len(missing.List()) == 0 {
+		return info, nil
+	}
+	w := graph.NewWriter(qw)
+	if err = schema.WriteNamespaces(w, &missing); err != nil {
+		return info, err
+	}
+	return info, w.Close()
+}
+
+// countQuads returns the number of quads in the database. Backends that only estimate it are scanned.
+func countQuads(ctx context.Context, qs graph.QuadStore) (int64, error) {
+	st, err := graph.StatsOf(ctx, qs, false)
+	if err != nil {
+		return 0, err
+	} else if st.Exact {
+		return st.Quads, nil
+	}
+	return scanQuads(ctx, qs)
+}
+
+// scanQuads counts quads by iterating over all of them.
+func scanQuads(ctx context.Context, qs graph.QuadStore) (int64, error) {
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	var n int64
+	for it.Next(ctx) {
+		n++
+	}
+	return n, it.Err()
+}
+
+// verifyQuads counts quads in the restored database and checks that the number matches the backup.
+func verifyQuads(ctx context.Context, qs graph.QuadStore, exp int64) error {
+	n, err := scanQuads(ctx, qs)
+	if err != nil {
+		return err
+	} else if n != exp {
+		return fmt.Errorf("restored database has %d quads, while the backup has %d", n, exp)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+	"github.com/cayleygraph/cayley/schema"
+	"github.com/cayleygraph/cayley/voc"
+)
+
+func sortedQuads(t testing.TB, qs graph.QuadStore) []string {
+	var out []string
+	for _, q := range readAll(t, graph.NewQuadStoreReader(qs)) {
+		out = append(out, q.NQuad())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.TODO()
+	var ns voc.Namespaces
+	ns.Register(voc.Namespace{Prefix: "ex:", Full: "http://example.com/"})
+
+	from, qw := newKVStore(t)
+	defer from.Close()
+	require.NoError(t, qw.AddQuadSet(testQuads(2000)))
+	w := graph.NewWriter(qw)
+	require.NoError(t, schema.WriteNamespaces(w, &ns))
+	require.NoError(t, w.Close())
+	exp := sortedQuads(t, from)
+
+	for _, c := range []struct {
+		name string
+		opt  BackupOptions
+	}{
+		{name: "plain"},
+		{name: "compressed", opt: BackupOptions{Compress: true}},
+		{name: "encrypted", opt: BackupOptions{Password: "secret"}},
+		{name: "both", opt: BackupOptions{Compress: true, Password: "secret"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			info, err := Backup(ctx, buf, from, "btree", c.opt)
+			require.NoError(t, err)
+			require.Equal(t, "kv", info.Format)
+			require.Equal(t, from.Size(), info.Quads)
+			require.Equal(t, ns.List(), info.Namespaces)
+			data := buf.Bytes()
+
+			to, qw := newKVStore(t)
+			defer to.Close()
+			opt := c.opt
+			opt.Verify = true
+			_, err = Restore(ctx, bytes.NewReader(data), to, qw, opt)
+			require.NoError(t, err)
+			require.Equal(t, exp, sortedQuads(t, to))
+			require.Equal(t, from.Size(), to.Size())
+
+			_, err = Restore(ctx, bytes.NewReader(data), to, qw, c.opt)
+			require.NotNil(t, err, "restored into a non-empty database")
+
+			mem := memstore.New()
+			mw, err := graph.NewQuadWriter("single", mem, nil)
+			require.NoError(t, err)
+			_, err = Restore(ctx, bytes.NewReader(data), mem, mw, c.opt)
+			require.NotNil(t, err, "restored snapshot into a different backend")
+
+			if c.opt.Password == "" {
+				return
+			}
+			_, err = Restore(ctx, bytes.NewReader(data), mem, mw, BackupOptions{})
+			require.Equal(t, ErrBackupPassword, err)
+
+			to2, qw2 := newKVStore(t)
+			defer to2.Close()
+			_, err = Restore(ctx, bytes.NewReader(data), to2, qw2, BackupOptions{Password: "wrong"})
+			require.NotNil(t, err)
+
+			_, err = Restore(ctx, bytes.NewReader(data[:len(data)-10]), to2, qw2, c.opt)
+			require.NotNil(t, err, "restored truncated backup")
+		})
+	}
+}
+
+func TestBackupQuads(t *testing.T) {
+	ctx := context.TODO()
+	quads := testQuads(10)
+	from := memstore.New(quads...)
+
+	buf := bytes.NewBuffer(nil)
+	info, err := Backup(ctx, buf, from, "memstore", BackupOptions{Compress: true})
+	require.NoError(t, err)
+	require.Equal(t, BackupFormatQuads, info.Format)
+
+	to, qw := newKVStore(t)
+	defer to.Close()
+	_, err = Restore(ctx, buf, to, qw, BackupOptions{Verify: true})
+	require.NoError(t, err)
+	require.Equal(t, sortedQuads(t, from), sortedQuads(t, to))
+
+	// restored quads are verified against the number recorded in the backup, not the number of quads in the dump
+	dump := func(quads []quad.Quad, n int64) *bytes.Buffer {
+		buf := bytes.NewBuffer(nil)
+		info := &BackupInfo{Backend: "memstore", Format: BackupFormatQuads, Quads: n}
+		err := writeBackup(buf, info, BackupOptions{}, func(w io.Writer) error {
+			pw := pquads.NewWriter(w, &pquads.Options{})
+			if _, err := quad.Copy(pw, quad.NewReader(quads)); err != nil {
+				return err
+			}
+			return pw.Close()
+		})
+		require.NoError(t, err)
+		return buf
+	}
+	dup := append(quads[:len(quads):len(quads)], quads[0])
+
+	to2, qw2 := newKVStore(t)
+	defer to2.Close()
+	_, err = Restore(ctx, dump(dup, int64(len(dup))), to2, qw2, BackupOptions{Verify: true})
+	require.NotNil(t, err, "duplicate quads in the dump are only stored once")
+
+	to3, qw3 := newKVStore(t)
+	defer to3.Close()
+	_, err = Restore(ctx, dump(dup, int64(len(quads))), to3, qw3, BackupOptions{Verify: true})
+	require.NoError(t, err)
+}
+
+func TestBackupVerifySnapshot(t *testing.T) {
+	ctx := context.TODO()
+	from, qw := newKVStore(t)
+	defer from.Close()
+	require.NoError(t, qw.AddQuadSet(testQuads(10)))
+
+	buf := bytes.NewBuffer(nil)
+	info := &BackupInfo{Backend: "btree", Format: "kv", Quads: from.Size() + 1}
+	err := writeBackup(buf, info, BackupOptions{}, func(w io.Writer) error {
+		return graph.Snapshot(ctx, from, w)
+	})
+	require.NoError(t, err)
+
+	to, qw2 := newKVStore(t)
+	defer to.Close()
+	_, err = Restore(ctx, buf, to, qw2, BackupOptions{Verify: true})
+	require.NotNil(t, err, "restored snapshot with a wrong number of quads")
+}
+
+func TestBackupIncremental(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	from, err := kv.New(db, graph.Options{"delta_log": true})
+	require.NoError(t, err)
+	defer from.Close()
+	qw, err := graph.NewQuadWriter("single", from, nil)
+	require.NoError(t, err)
+	require.NoError(t, qw.AddQuadSet(testQuads(10)))
+
+	full := bytes.NewBuffer(nil)
+	info, err := Backup(ctx, full, from, "btree", BackupOptions{})
+	require.NoError(t, err)
+	require.NotZero(t, info.Horizon)
+
+	// a bad bulk write happens after two good transactions
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("b", "p", "c", "")))
+	require.NoError(t, qw.RemoveQuad(testQuads(1)[0]))
+	good, err := graph.Horizon(ctx, from)
+	require.NoError(t, err)
+	exp := sortedQuads(t, from)
+	require.NoError(t, qw.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("bad", "p", "1", ""),
+		quad.MakeIRI("bad", "p", "2", ""),
+	}))
+
+	inc := bytes.NewBuffer(nil)
+	iinfo, err := BackupIncremental(ctx, inc, from, "btree", info.Horizon, BackupOptions{Compress: true})
+	require.NoError(t, err)
+	require.True(t, iinfo.Incremental())
+	require.Equal(t, info.Horizon, iinfo.Since)
+	data := inc.Bytes()
+
+	// point-in-time recovery
+	to, tw := newKVStore(t)
+	defer to.Close()
+	_, err = RestoreUntil(ctx, bytes.NewReader(full.Bytes()), to, tw, BackupOptions{}, good)
+	require.NoError(t, err)
+	_, err = RestoreUntil(ctx, bytes.NewReader(data), to, tw, BackupOptions{Compress: true}, good)
+	require.NoError(t, err)
+	require.Equal(t, exp, sortedQuads(t, to))
+
+	// the rest of the changes can be applied later
+	_, err = Restore(ctx, bytes.NewReader(data), to, tw, BackupOptions{})
+	require.NoError(t, err)
+	require.Equal(t, sortedQuads(t, from), sortedQuads(t, to))
+
+	// incremental backups must be applied in order
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("c", "p", "d", "")))
+	cur, err := graph.Horizon(ctx, from)
+	require.NoError(t, err)
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("c", "p", "e", "")))
+	inc2 := bytes.NewBuffer(nil)
+	_, err = BackupIncremental(ctx, inc2, from, "btree", cur, BackupOptions{})
+	require.NoError(t, err)
+
+	to2, tw2 := newKVStore(t)
+	defer to2.Close()
+	_, err = Restore(ctx, bytes.NewReader(full.Bytes()), to2, tw2, BackupOptions{})
+	require.NoError(t, err)
+	_, err = Restore(ctx, bytes.NewReader(inc2.Bytes()), to2, tw2, BackupOptions{})
+	require.NotNil(t, err, "applied backups out of order")
+
+	_, err = Restore(ctx, bytes.NewReader(data[:len(data)-5]), to2, tw2, BackupOptions{})
+	require.NotNil(t, err, "applied truncated backup")
+
+	// the delta log is required
+	mem, _ := newKVStore(t)
+	defer mem.Close()
+	_, err = BackupIncremental(ctx, bytes.NewBuffer(nil), mem, "btree", 0, BackupOptions{})
+	require.Equal(t, ErrNoDeltaLog, err)
+}
@@ -0,0 +1,265 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bench implements a workload for benchmarking backends.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+)
+
+// Number of distinct predicates in generated data.
+const numPredicates = 10
+
+// GenerateQuads returns a reader for n random quads.
+//
+// Quads connect nodes <n0>..<nK> with predicates <p0>..<p9>, where K is n/10,
+// so each node has ~10 outgoing and incoming links on average.
+func GenerateQuads(n int, seed int64) quad.Reader {
+	nodes := n / numPredicates
+	if nodes < 1 {
+		nodes = 1
+	}
+	return &generator{rnd: rand.New(rand.NewSource(seed)), n: n, nodes: nodes}
+}
+
+type generator struct {
+	rnd   *rand.Rand
+	i, n  int
+	nodes int
+}
+
+func (g *generator) ReadQuad() (quad.Quad, error) {
+	if g.i >= g.n {
+		return quad.Quad{}, io.EOF
+	}
+	// subjects are written sequentially to make sure that all nodes exist
+	q := quad.Quad{
+		Subject:   quad.IRI(fmt.Sprintf("n%d", g.i%g.nodes)),
+		Predicate: quad.IRI(fmt.Sprintf("p%d", g.rnd.Intn(numPredicates))),
+		Object:    quad.IRI(fmt.Sprintf("n%d", g.rnd.Intn(g.nodes))),
+	}
+	g.i++
+	return q, nil
+}
+
+func (g *generator) Close() error { return nil }
+
+// DefaultQueries is a query mix used for generated data.
+var DefaultQueries = []Query{
+	{Lang: "gizmo", Text: `g.V("<n1>").Out().All()`},
+	{Lang: "gizmo", Text: `g.V("<n1>").Out("<p0>").Out("<p1>").All()`},
+	{Lang: "gizmo", Text: `g.V("<n2>").In().In().Limit(100).All()`},
+	{Lang: "gizmo", Text: `g.V().Has("<p3>", "<n3>").All()`},
+	{Lang: "gizmo", Text: `g.V().Limit(100).OutPredicates().Unique().All()`},
+}
+
+// Query is a single query in a workload.
+type Query struct {
+	Lang string
+	Text string
+}
+
+// ParseQuery parses a query in the form "lang:query". If lang is not specified, def is used.
+func ParseQuery(s, def string) Query {
+	if i := strings.Index(s, ":"); i > 0 && query.GetLanguage(s[:i]) != nil {
+		return Query{Lang: s[:i], Text: s[i+1:]}
+	}
+	return Query{Lang: def, Text: s}
+}
+
+// Config describes a query workload.
+type Config struct {
+	Concurrency int           // number of concurrent workers
+	Requests    int           // total number of queries to run; zero means no limit
+	Duration    time.Duration // maximal time to run queries; zero means no limit
+	Limit       int           // maximal number of results for a single query
+	Timeout     time.Duration // timeout for a single query
+}
+
+// Stats is a summary of a series of operations.
+type Stats struct {
+	Count     int
+	Errors    int
+	Results   int
+	Elapsed   time.Duration
+	Latencies []time.Duration // sorted
+}
+
+// Throughput returns a number of operations per second.
+func (s *Stats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Count) / s.Elapsed.Seconds()
+}
+
+// Percentile returns a latency percentile; p must be in [0, 100] range.
+func (s *Stats) Percentile(p float64) time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(s.Latencies))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(s.Latencies) {
+		i = len(s.Latencies) - 1
+	}
+	return s.Latencies[i]
+}
+
+// Load writes all quads from the reader and reports the load time.
+// Count in the returned stats is the number of written quads.
+func Load(qw graph.QuadWriter, r quad.Reader, batch int) (*Stats, error) {
+	start := time.Now()
+	w := graph.NewWriter(qw)
+	n, err := quad.CopyBatch(w, r, batch)
+	if err == nil {
+		err = w.Close()
+	}
+	return &Stats{Count: n, Elapsed: time.Since(start)}, err
+}
+
+// Run executes queries from the mix in a round-robin fashion with given concurrency.
+// It stops when the number of requests or the duration is reached, or when the context is canceled.
+func Run(ctx context.Context, qs graph.QuadStore, queries []Query, c Config) (*Stats, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no queries to run")
+	} else if c.Requests <= 0 && c.Duration <= 0 {
+		return nil, fmt.Errorf("either number of requests or duration should be set")
+	}
+	for _, q := range queries {
+		if l := query.GetLanguage(q.Lang); l == nil || l.Session == nil {
+			return nil, fmt.Errorf("unsupported query language: %q", q.Lang)
+		}
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if c.Duration > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, c.Duration)
+		defer cancel()
+	}
+	var (
+		mu    sync.Mutex
+		next  int
+		stats Stats
+	)
+	// take returns an index of the next query to run, or -1 if the workload is done
+	take := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() != nil || (c.Requests > 0 && next >= c.Requests) {
+			return -1
+		}
+		next++
+		return next - 1
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < c.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sessions := make(map[string]query.Session)
+			for {
+				i := take()
+				if i < 0 {
+					return
+				}
+				q := queries[i%len(queries)]
+				ses := sessions[q.Lang]
+				if ses == nil {
+					ses = query.GetLanguage(q.Lang).Session(qs)
+					sessions[q.Lang] = ses
+				}
+				t := time.Now()
+				n, err := execute(ctx, ses, q.Text, c)
+				dt := time.Since(t)
+				if err != nil && ctx.Err() != nil {
+					// interrupted by the end of the benchmark
+					return
+				}
+				mu.Lock()
+				stats.Count++
+				stats.Results += n
+				stats.Latencies = append(stats.Latencies, dt)
+				if err != nil {
+					stats.Errors++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	stats.Elapsed = time.Since(start)
+	sort.Slice(stats.Latencies, func(i, j int) bool {
+		return stats.Latencies[i] < stats.Latencies[j]
+	})
+	return &stats, nil
+}
+
+func execute(ctx context.Context, ses query.Session, qu string, c Config) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+	defer cancel()
+	out := make(chan query.Result, 10)
+	go ses.Execute(ctx, qu, out, c.Limit)
+	var (
+		n   int
+		err error
+	)
+	for r := range out {
+		if e := r.Err(); e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		n++
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return n, err
+}
+
+// WriteReport prints a human-readable summary of the stats.
+func WriteReport(w io.Writer, name string, s *Stats) {
+	fmt.Fprintf(w, "%s: %d ops in %v (%.1f ops/s)", name, s.Count, s.Elapsed, s.Throughput())
+	if s.Errors != 0 {
+		fmt.Fprintf(w, ", %d errors", s.Errors)
+	}
+	fmt.Fprintln(w)
+	if len(s.Latencies) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  results: %d\n", s.Results)
+	fmt.Fprintf(w, "  latency: p50=%v p90=%v p99=%v max=%v\n",
+		s.Percentile(50), s.Percentile(90), s.Percentile(99), s.Latencies[len(s.Latencies)-1])
+}
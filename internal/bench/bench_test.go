@@ -0,0 +1,47 @@
+package bench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func TestBench(t *testing.T) {
+	qs := memstore.New()
+	qw, err := graph.NewQuadWriter("single", qs, graph.Options{"ignore_duplicate": true})
+	require.NoError(t, err)
+
+	st, err := Load(qw, GenerateQuads(1000, 1), 100)
+	require.NoError(t, err)
+	require.Equal(t, 1000, st.Count)
+	require.True(t, qs.Size() > 0)
+
+	st, err = Run(context.Background(), qs, DefaultQueries, Config{
+		Concurrency: 4, Requests: 50, Limit: 10, Timeout: time.Minute,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 50, st.Count)
+	require.Equal(t, 0, st.Errors)
+	require.True(t, st.Results > 0)
+	require.Len(t, st.Latencies, 50)
+	require.True(t, st.Percentile(50) <= st.Percentile(99))
+
+	st, err = Run(context.Background(), qs, []Query{ParseQuery(`gizmo:g.V(`, "")}, Config{Requests: 3})
+	require.NoError(t, err)
+	require.Equal(t, 3, st.Errors)
+
+	_, err = Run(context.Background(), qs, []Query{{Lang: "unknown", Text: "x"}}, Config{Requests: 1})
+	require.Error(t, err)
+}
+
+func TestParseQuery(t *testing.T) {
+	require.Equal(t, Query{Lang: "gizmo", Text: "g.V()"}, ParseQuery("gizmo:g.V()", "mql"))
+	require.Equal(t, Query{Lang: "gizmo", Text: `g.V("<a:b>")`}, ParseQuery(`g.V("<a:b>")`, "gizmo"))
+}
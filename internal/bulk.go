@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// loadBulk loads a quad file with multiple concurrent writers. Indexes are rebuilt after the load
+// if the database supports it. Checkpoints are not recorded, since batches are written out of order.
+func loadBulk(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, path string, opt LoadOptions) error {
+	if opt.Resume {
+		return errors.New("cannot resume a bulk load")
+	}
+	workers := opt.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	batch := opt.Batch
+	if batch <= 0 {
+		batch = quad.DefaultBatch
+	}
+	r, c, size, err := openQuadSource(ctx, path)
+	if err != nil {
+		return err
+	}
+	cr := &countingReader{r: r}
+	qr, err := newQuadReader(cr, c, path, opt.Format, workers)
+	if err != nil {
+		return err
+	}
+	defer qr.Close()
+
+	var end func(ctx context.Context) error
+	if bl := graph.BulkIndexerOf(qs); bl != nil {
+		if end, err = bl.BeginBulkLoad(ctx); err != nil {
+			return err
+		}
+	}
+	var prog *progress
+	if opt.Progress != nil {
+		prog = newProgress(opt.Progress, size, 0, 0, true)
+	}
+	var (
+		mu    sync.Mutex
+		total int64
+	)
+	start := time.Now()
+	err = copyParallel(ctx, qw, qr, workers, batch, func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		total += int64(n)
+		if prog != nil {
+			prog.update(total, cr.pos())
+		}
+	})
+	if prog != nil {
+		prog.done(total, cr.pos())
+	}
+	if end != nil {
+		// indexes must be restored even if the load was canceled
+		if err2 := end(context.Background()); err2 != nil && err == nil {
+			err = fmt.Errorf("cannot restore indexes: %v", err2)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("db: failed to load data: %v", err)
+	}
+	dt := time.Since(start)
+	clog.Infof("loaded %d quads in %v (%.0f quads/s)", total, dt.Round(time.Millisecond), float64(total)/dt.Seconds())
+	return nil
+}
+
+// copyParallel reads quads and writes them in batches with a given number of concurrent writers.
+// Quads are partitioned by a hash of the subject, thus duplicates of a quad are always written by the same worker.
+// Function written is called after each written batch.
+func copyParallel(ctx context.Context, qw graph.QuadWriter, qr quad.Reader, workers, batch int, written func(n int)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		werr error
+	)
+	parts := make([]chan []quad.Quad, workers)
+	for i := range parts {
+		ch := make(chan []quad.Quad, 1)
+		parts[i] = ch
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for quads := range ch {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := qw.AddQuadSet(quads); err != nil {
+					once.Do(func() {
+						werr = err
+						cancel()
+					})
+					continue
+				}
+				written(len(quads))
+			}
+		}()
+	}
+	bufs := make([][]quad.Quad, workers)
+	send := func(i int) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case parts[i] <- bufs[i]:
+			bufs[i] = make([]quad.Quad, 0, batch)
+			return true
+		}
+	}
+	var err error
+	for {
+		q, e := qr.ReadQuad()
+		if e == io.EOF {
+			break
+		} else if e != nil {
+			err = e
+			break
+		}
+		i := partition(q, workers)
+		bufs[i] = append(bufs[i], q)
+		if len(bufs[i]) >= batch && !send(i) {
+			break
+		}
+	}
+	if err == nil && ctx.Err() == nil {
+		for i := range bufs {
+			if len(bufs[i]) != 0 && !send(i) {
+				break
+			}
+		}
+	}
+	for _, ch := range parts {
+		close(ch)
+	}
+	wg.Wait()
+	if err != nil {
+		return err
+	} else if werr != nil {
+		return werr
+	}
+	return ctx.Err()
+}
+
+// partition returns a worker index for a quad.
+func partition(q quad.Quad, n int) int {
+	if n <= 1 || q.Subject == nil {
+		return 0
+	}
+	h := fnv.New32a()
+	io.WriteString(h, q.Subject.String())
+	return int(h.Sum32() % uint32(n))
+}
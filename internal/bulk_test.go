@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestLoadFileBulk(t *testing.T) {
+	ctx := context.TODO()
+	var quads []quad.Quad
+	for i := 0; i < 100; i++ {
+		quads = append(quads, quad.MakeIRI(fmt.Sprintf("s%d", i%7), "p", fmt.Sprintf("o%d", i), ""))
+	}
+	// duplicates are written by the same worker and are skipped
+	quads = append(quads, quads[:10]...)
+	path, closer := writeQuadFile(t, quads)
+	defer closer()
+
+	qs, qw := newKVStore(t)
+	defer qs.Close()
+
+	buf := bytes.NewBuffer(nil)
+	err := LoadFile(ctx, qs, qw, path, LoadOptions{Batch: 3, Bulk: true, Workers: 4, Progress: buf})
+	require.NoError(t, err)
+	require.Equal(t, int64(100), qs.Size())
+	require.Contains(t, buf.String(), "100.0%")
+
+	err = LoadFile(ctx, qs, qw, path, LoadOptions{Bulk: true, Resume: true})
+	require.NotNil(t, err)
+}
+
+func TestLoadFileBulkError(t *testing.T) {
+	ctx := context.TODO()
+	path, closer := writeQuadFile(t, testQuads(50))
+	defer closer()
+
+	qs, qw := newKVStore(t)
+	defer qs.Close()
+
+	err := LoadFile(ctx, qs, &crashingWriter{QuadWriter: qw}, path, LoadOptions{Batch: 2, Bulk: true, Workers: 3})
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), errCrash.Error())
+	require.Equal(t, int64(0), qs.Size())
+	require.Nil(t, graph.BulkIndexerOf(qs))
+}
@@ -0,0 +1,374 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Metadata keys for checkpoints of interrupted operations.
+const (
+	loadCheckpointKey    = "load.checkpoint"
+	migrateCheckpointKey = "migrate.checkpoint"
+)
+
+// Checkpoint is a position of the load process in a quad file or another quad source. It is stored
+// in the database metadata after each written batch and removed when the source is copied completely.
+type Checkpoint struct {
+	Source string `json:"source"` // absolute path or URL of the file, or an address of the source database
+	Quads  int64  `json:"quads"`  // number of quads written to the database
+	// Bytes is a number of bytes read from the file. It may run ahead of Quads because of buffering,
+	// thus it is used for progress reporting only.
+	Bytes int64     `json:"bytes"`
+	Size  int64     `json:"size"` // size of the file or the number of quads in the source database; -1 if unknown
+	Time  time.Time `json:"time"`
+}
+
+// GetCheckpoint returns the checkpoint of an interrupted load, or nil if there is none.
+// It returns graph.ErrNotSupported if the database cannot store metadata.
+func GetCheckpoint(ctx context.Context, qs graph.QuadStore) (*Checkpoint, error) {
+	return getCheckpoint(ctx, qs, loadCheckpointKey)
+}
+
+func getCheckpoint(ctx context.Context, qs graph.QuadStore, key string) (*Checkpoint, error) {
+	data, err := graph.GetMetadata(ctx, qs, key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var c Checkpoint
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint: %v", err)
+	}
+	return &c, nil
+}
+
+func setCheckpoint(ctx context.Context, qs graph.QuadStore, key string, c *Checkpoint) error {
+	if c == nil {
+		return graph.SetMetadata(ctx, qs, key, nil)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return graph.SetMetadata(ctx, qs, key, data)
+}
+
+// sourceName returns a name of the quad source that does not depend on the working directory.
+func sourceName(path string) string {
+	if path == "-" {
+		return path
+	}
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" && u.Scheme != "file" {
+		return path
+	}
+	path = strings.TrimPrefix(path, "file://")
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// LoadOptions controls checkpointing and progress reporting of LoadFile.
+type LoadOptions struct {
+	Batch  int    // number of quads in a batch; quad.DefaultBatch is used if not set
+	Format string // quad file format; detected by file extension if not set
+	// Resume continues loading from the checkpoint left by an interrupted load of the same file.
+	Resume bool
+	// Progress receives a progress bar. Nothing is printed if not set.
+	Progress io.Writer
+	// Bulk loads the file with multiple concurrent writers and defers index creation if the database supports it.
+	// Checkpoints are not recorded in this mode.
+	Bulk bool
+	// Workers is a number of concurrent writers for a bulk load; the number of CPUs is used if not set.
+	Workers int
+}
+
+// LoadFile loads a quad file into the database, recording a checkpoint after each batch.
+//
+// If the database cannot store metadata, the file is loaded without checkpoints and resuming is not possible.
+// When resuming, the last batch before the interruption may be written twice; existing quads are skipped in this case.
+func LoadFile(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, path string, opt LoadOptions) error {
+	if path == "" {
+		return nil
+	} else if opt.Resume && path == "-" {
+		return errors.New("cannot resume loading from stdin")
+	}
+	if opt.Bulk {
+		return loadBulk(ctx, qs, qw, path, opt)
+	}
+	job := &copyJob{key: loadCheckpointKey, src: sourceName(path), batch: opt.Batch, progress: opt.Progress}
+	if err := job.prepare(ctx, qs, opt.Resume); err != nil {
+		return err
+	}
+	r, c, size, err := openQuadSource(ctx, path)
+	if err != nil {
+		return err
+	}
+	cr := &countingReader{r: r}
+	qr, err := newQuadReader(cr, c, path, opt.Format, 1)
+	if err != nil {
+		return err
+	}
+	defer qr.Close()
+	job.size = size
+	job.pos = cr.pos
+	return job.run(ctx, qs, qw, qr)
+}
+
+// copyJob copies quads from a source to the database, recording checkpoints under a given metadata key.
+type copyJob struct {
+	key      string
+	src      string
+	batch    int
+	progress io.Writer
+
+	size int64        // size of the source in units of pos
+	pos  func() int64 // position in the source; nil means that the position is the number of quads
+
+	skip int64 // quads written by the previous run
+	save bool  // database can store checkpoints
+}
+
+// prepare checks the checkpoint left by a previous run and decides where to start.
+func (j *copyJob) prepare(ctx context.Context, qs graph.QuadStore, resume bool) error {
+	j.save = true
+	prev, err := getCheckpoint(ctx, qs, j.key)
+	if err == graph.ErrNotSupported {
+		if resume {
+			return errors.New("resume is not supported: backend cannot store checkpoints")
+		}
+		j.save = false
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !resume {
+		if prev != nil {
+			clog.Warningf("discarding checkpoint of an interrupted copy from %q at %d quads", prev.Source, prev.Quads)
+		}
+		return nil
+	}
+	if prev == nil {
+		clog.Infof("no checkpoint found, starting from the beginning")
+	} else if prev.Source != j.src {
+		return fmt.Errorf("checkpoint was recorded for a different source: %q", prev.Source)
+	} else {
+		j.skip = prev.Quads
+		clog.Infof("resuming copy from %q after %d quads", j.src, j.skip)
+	}
+	return nil
+}
+
+func (j *copyJob) run(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, qr quad.Reader) error {
+	skipper, _ := qr.(quad.Skipper)
+	for i := int64(0); i < j.skip; i++ {
+		var err error
+		if skipper != nil {
+			err = skipper.SkipQuad()
+		} else {
+			_, err = qr.ReadQuad()
+		}
+		if err == io.EOF {
+			return fmt.Errorf("source has only %d quads, but checkpoint is at %d", i, j.skip)
+		} else if err != nil {
+			return fmt.Errorf("db: failed to skip loaded data: %v", err)
+		}
+	}
+
+	w := &checkpointWriter{
+		ctx: ctx, qs: qs, qw: qw, key: j.key,
+		cp:     Checkpoint{Source: j.src, Quads: j.skip, Size: j.size},
+		pos:    j.pos,
+		resume: j.skip > 0,
+		save:   j.save,
+	}
+	if j.progress != nil {
+		w.progress = newProgress(j.progress, j.size, j.skip, w.position(), j.pos != nil)
+	}
+	batch := j.batch
+	if batch <= 0 {
+		batch = quad.DefaultBatch
+	}
+	_, err := quad.CopyBatch(w, qr, batch)
+	if w.progress != nil {
+		w.progress.done(w.cp.Quads, w.position())
+	}
+	if err != nil {
+		return fmt.Errorf("db: failed to load data: %v", err)
+	}
+	if j.save {
+		return setCheckpoint(ctx, qs, j.key, nil)
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	atomic.AddInt64(&r.n, int64(n))
+	return n, err
+}
+
+// pos returns the number of bytes read. It is safe to call concurrently with Read.
+func (r *countingReader) pos() int64 {
+	return atomic.LoadInt64(&r.n)
+}
+
+// checkpointWriter writes batches of quads and records a checkpoint after each of them.
+type checkpointWriter struct {
+	ctx      context.Context
+	qs       graph.QuadStore
+	qw       graph.QuadWriter
+	key      string
+	cp       Checkpoint
+	pos      func() int64
+	resume   bool // the first batch may contain quads written before the interruption
+	save     bool
+	progress *progress
+}
+
+// position returns a position in the source used for progress reporting.
+func (w *checkpointWriter) position() int64 {
+	if w.pos == nil {
+		return w.cp.Quads
+	}
+	return w.pos()
+}
+
+func (w *checkpointWriter) WriteQuads(quads []quad.Quad) (int, error) {
+	if len(quads) == 0 {
+		return 0, nil
+	} else if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	err := w.qw.AddQuadSet(quads)
+	if w.resume && graph.IsQuadExist(err) {
+		err = w.writeMissing(quads)
+	}
+	if err != nil {
+		return 0, err
+	}
+	w.resume = false
+	w.cp.Quads += int64(len(quads))
+	if w.pos != nil {
+		w.cp.Bytes = w.pos()
+	}
+	w.cp.Time = time.Now()
+	if w.save {
+		if err = setCheckpoint(w.ctx, w.qs, w.key, &w.cp); err != nil {
+			return len(quads), fmt.Errorf("cannot save checkpoint: %v", err)
+		}
+	}
+	if w.progress != nil {
+		w.progress.update(w.cp.Quads, w.position())
+	}
+	return len(quads), nil
+}
+
+// writeMissing writes quads one by one, skipping ones that already exist.
+func (w *checkpointWriter) writeMissing(quads []quad.Quad) error {
+	for _, q := range quads {
+		if err := w.qw.AddQuad(q); err != nil && !graph.IsQuadExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// progressInterval is a minimal interval between progress bar updates.
+const progressInterval = 500 * time.Millisecond
+
+// progress prints a progress bar of the copy process.
+//
+// Position in the source is measured either in bytes, or in quads if the source size is not known in bytes.
+type progress struct {
+	w     io.Writer
+	size  int64 // -1 if unknown
+	bytes bool  // position is measured in bytes
+	start time.Time
+	last  time.Time
+	quads int64 // quads skipped when resuming
+	pos   int64 // position when the copy was started
+}
+
+func newProgress(w io.Writer, size, quads, pos int64, bytes bool) *progress {
+	return &progress{w: w, size: size, bytes: bytes, start: time.Now(), quads: quads, pos: pos}
+}
+
+func (p *progress) update(quads, pos int64) {
+	now := time.Now()
+	if now.Sub(p.last) < progressInterval {
+		return
+	}
+	p.last = now
+	fmt.Fprint(p.w, "\r"+p.line(now, quads, pos))
+}
+
+func (p *progress) done(quads, pos int64) {
+	fmt.Fprintln(p.w, "\r"+p.line(time.Now(), quads, pos))
+}
+
+const progressWidth = 30
+
+func (p *progress) line(now time.Time, quads, pos int64) string {
+	dt := now.Sub(p.start)
+	rate := 0.0
+	if sec := dt.Seconds(); sec > 0 {
+		rate = float64(quads-p.quads) / sec
+	}
+	if p.size <= 0 {
+		if !p.bytes {
+			return fmt.Sprintf("%d quads  %.0f quads/s", quads, rate)
+		}
+		return fmt.Sprintf("%d quads  %s  %.0f quads/s", quads, FormatBytes(pos), rate)
+	}
+	if pos > p.size {
+		pos = p.size
+	}
+	frac := float64(pos) / float64(p.size)
+	n := int(frac * progressWidth)
+	bar := strings.Repeat("=", n)
+	if n < progressWidth {
+		bar += ">" + strings.Repeat(" ", progressWidth-n-1)
+	}
+	eta := "?"
+	if read := pos - p.pos; read > 0 {
+		left := time.Duration(float64(dt) * float64(p.size-pos) / float64(read))
+		eta = left.Round(time.Second).String()
+	}
+	if !p.bytes {
+		return fmt.Sprintf("[%s] %5.1f%%  %d/%d quads  %.0f quads/s  ETA %s",
+			bar, frac*100, quads, p.size, rate, eta)
+	}
+	return fmt.Sprintf("[%s] %5.1f%%  %d quads  %s/%s  %.0f quads/s  ETA %s",
+		bar, frac*100, quads, FormatBytes(pos), FormatBytes(p.size), rate, eta)
+}
+
+// FormatBytes formats a size in bytes using binary units.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
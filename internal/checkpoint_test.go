@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func writeQuadFile(t testing.TB, quads []quad.Quad) (string, func()) {
+	dir, err := ioutil.TempDir("", "cayley_load")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "data.nq")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	w := nquads.NewWriter(f)
+	_, err = quad.Copy(w, quad.NewReader(quads))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func newKVStore(t testing.TB) (graph.QuadStore, graph.QuadWriter) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	qw, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+	return qs, qw
+}
+
+var errCrash = errors.New("crash")
+
+// crashingWriter fails after writing a given number of batches.
+type crashingWriter struct {
+	graph.QuadWriter
+	batches int
+}
+
+func (w *crashingWriter) AddQuadSet(quads []quad.Quad) error {
+	if w.batches == 0 {
+		return errCrash
+	}
+	w.batches--
+	return w.QuadWriter.AddQuadSet(quads)
+}
+
+func testQuads(n int) []quad.Quad {
+	var out []quad.Quad
+	for i := 0; i < n; i++ {
+		out = append(out, quad.MakeIRI("a", "p", "n"+string('a'+rune(i)), ""))
+	}
+	return out
+}
+
+func TestLoadFileResume(t *testing.T) {
+	ctx := context.TODO()
+	quads := testQuads(10)
+	path, closer := writeQuadFile(t, quads)
+	defer closer()
+
+	qs, qw := newKVStore(t)
+	defer qs.Close()
+
+	opt := LoadOptions{Batch: 3}
+	err := LoadFile(ctx, qs, &crashingWriter{QuadWriter: qw, batches: 2}, path, opt)
+	require.NotNil(t, err)
+	require.Equal(t, int64(6), qs.Size())
+
+	cp, err := GetCheckpoint(ctx, qs)
+	require.NoError(t, err)
+	require.NotNil(t, cp)
+	require.Equal(t, int64(6), cp.Quads)
+	require.Equal(t, sourceName(path), cp.Source)
+
+	// pretend that the last batch was written, but the checkpoint was not updated
+	cp.Quads = 3
+	require.NoError(t, setCheckpoint(ctx, qs, loadCheckpointKey, cp))
+
+	opt.Resume = true
+	err = LoadFile(ctx, qs, qw, path, opt)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(quads)), qs.Size())
+
+	cp, err = GetCheckpoint(ctx, qs)
+	require.NoError(t, err)
+	require.Nil(t, cp)
+
+	got := readAll(t, graph.NewQuadStoreReader(qs))
+	require.Equal(t, len(quads), len(got))
+}
+
+func TestLoadFileResumeOther(t *testing.T) {
+	ctx := context.TODO()
+	path, closer := writeQuadFile(t, testQuads(3))
+	defer closer()
+
+	qs, qw := newKVStore(t)
+	defer qs.Close()
+	require.NoError(t, setCheckpoint(ctx, qs, loadCheckpointKey, &Checkpoint{Source: "/other.nq", Quads: 1}))
+
+	err := LoadFile(ctx, qs, qw, path, LoadOptions{Resume: true})
+	require.NotNil(t, err)
+	require.Equal(t, int64(0), qs.Size())
+}
+
+func TestLoadFileNoMetadata(t *testing.T) {
+	ctx := context.TODO()
+	quads := testQuads(5)
+	path, closer := writeQuadFile(t, quads)
+	defer closer()
+
+	qs := memstore.New()
+	qw, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+
+	err = LoadFile(ctx, qs, qw, path, LoadOptions{Resume: true})
+	require.NotNil(t, err)
+
+	buf := bytes.NewBuffer(nil)
+	err = LoadFile(ctx, qs, qw, path, LoadOptions{Batch: 2, Progress: buf})
+	require.NoError(t, err)
+	require.Equal(t, len(quads), len(readAll(t, graph.NewQuadStoreReader(qs))))
+	require.Contains(t, buf.String(), "100.0%")
+}
+
+func TestProgressLine(t *testing.T) {
+	p := newProgress(nil, 1000, 0, 0, true)
+	now := p.start.Add(10 * time.Second)
+	line := p.line(now, 50, 250)
+	require.True(t, strings.HasPrefix(line, "[=======>"), line)
+	require.Contains(t, line, " 25.0%")
+	require.Contains(t, line, "ETA 30s")
+
+	p = newProgress(nil, -1, 0, 0, true)
+	require.Equal(t, "50 quads  2.0 KiB  5 quads/s", p.line(now, 50, 2048))
+
+	p = newProgress(nil, 100, 0, 0, false)
+	line = p.line(now, 50, 50)
+	require.Contains(t, line, " 50/100 quads  5 quads/s  ETA 10s")
+}
@@ -25,17 +25,22 @@ import (
 const (
 	gzipMagic  = "\x1f\x8b"
 	b2zipMagic = "BZh"
+	zstdMagic  = "\x28\xb5\x2f\xfd"
 )
 
 // New detects the file type of an io.Reader between
-// bzip, gzip, or raw quad file.
+// bzip, gzip, zstd, or raw quad file.
 func New(r io.Reader) (io.Reader, error) {
 	br := bufio.NewReader(r)
-	buf, err := br.Peek(3)
-	if err != nil {
+	buf, err := br.Peek(len(zstdMagic))
+	if err == io.EOF && len(buf) >= 3 {
+		err = nil
+	} else if err != nil {
 		return nil, err
 	}
 	switch {
+	case bytes.HasPrefix(buf, []byte(zstdMagic)):
+		return newZstdReader(br)
 	case bytes.Compare(buf[:2], []byte(gzipMagic)) == 0:
 		return gzip.NewReader(br)
 	case bytes.Compare(buf[:3], []byte(b2zipMagic)) == 0:
@@ -19,6 +19,8 @@ import (
 	"compress/bzip2"
 	"compress/gzip"
 	"io"
+	"io/ioutil"
+	"os/exec"
 	"strings"
 	"testing"
 )
@@ -94,3 +96,29 @@ func TestDecompressor(t *testing.T) {
 		}
 	}
 }
+
+func TestZstd(t *testing.T) {
+	if _, err := exec.LookPath(zstdTool); err != nil {
+		t.Skip("zstd is not installed")
+	}
+	var buf bytes.Buffer
+	w, err := NewZstdWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.WriteString(w, "cayley data\n"); err != nil {
+		t.Fatal(err)
+	} else if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(data) != "cayley data\n" {
+		t.Errorf("Unexpected read result for zstd, got:%q", data)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompressor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// zstdTool is the name of the command used for zstd (de)compression.
+// There is no zstd implementation in the standard library, thus an external tool is used.
+const zstdTool = "zstd"
+
+// ErrNoZstd is returned if the zstd tool is not installed.
+var ErrNoZstd = fmt.Errorf("decompressor: %q tool is required for zstd compression", zstdTool)
+
+func zstdCommand(args ...string) (*exec.Cmd, *bytes.Buffer, error) {
+	path, err := exec.LookPath(zstdTool)
+	if err != nil {
+		return nil, nil, ErrNoZstd
+	}
+	cmd := exec.Command(path, append([]string{"-q", "-c"}, args...)...)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	return cmd, stderr, nil
+}
+
+func zstdError(err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("zstd: %v: %s", err, msg)
+	}
+	return fmt.Errorf("zstd: %v", err)
+}
+
+// zstdReader reads the output of the zstd tool. The process is waited for when all data is read.
+type zstdReader struct {
+	cmd    *exec.Cmd
+	out    io.ReadCloser
+	stderr *bytes.Buffer
+	err    error
+}
+
+func newZstdReader(r io.Reader) (io.Reader, error) {
+	cmd, stderr, err := zstdCommand("-d")
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = r
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &zstdReader{cmd: cmd, out: out, stderr: stderr}, nil
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.out.Read(p)
+	if err == io.EOF {
+		if werr := r.cmd.Wait(); werr != nil {
+			err = zstdError(werr, r.stderr)
+		}
+		r.err = err
+	} else if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// zstdWriter compresses data written to it with the zstd tool.
+type zstdWriter struct {
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	stderr *bytes.Buffer
+}
+
+// NewZstdWriter returns a writer that compresses data with zstd and writes it to w.
+// Close must be called to flush the data; it does not close w.
+func NewZstdWriter(w io.Writer) (io.WriteCloser, error) {
+	cmd, stderr, err := zstdCommand()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = w
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &zstdWriter{cmd: cmd, in: in, stderr: stderr}, nil
+}
+
+func (w *zstdWriter) Write(p []byte) (int, error) {
+	return w.in.Write(p)
+}
+
+func (w *zstdWriter) Close() error {
+	w.in.Close()
+	if err := w.cmd.Wait(); err != nil {
+		return zstdError(err, w.stderr)
+	}
+	return nil
+}
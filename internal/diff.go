@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DiffStats is a number of quads reported by Diff.
+type DiffStats struct {
+	Added   int
+	Removed int
+}
+
+// Diff compares two quad streams and calls fnc for each quad that must be deleted from
+// the first stream or added to it to get the second one.
+//
+// Streams are sorted in memory by the N-Quads notation of quads and then compared in a single pass,
+// reporting deltas in this order. Duplicate quads are ignored.
+func Diff(ctx context.Context, from, to quad.Reader, fnc func(graph.Delta) error) (DiffStats, error) {
+	var st DiffStats
+	a, err := readSorted(ctx, from)
+	if err != nil {
+		return st, err
+	}
+	b, err := readSorted(ctx, to)
+	if err != nil {
+		return st, err
+	}
+	emit := func(q quad.Quad, act graph.Procedure) error {
+		if act == graph.Add {
+			st.Added++
+		} else {
+			st.Removed++
+		}
+		return fnc(graph.Delta{Quad: q, Action: act})
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if err = ctx.Err(); err != nil {
+			return st, err
+		}
+		switch {
+		case j == len(b) || (i < len(a) && a[i].key < b[j].key):
+			err = emit(a[i].q, graph.Delete)
+			i++
+		case i == len(a) || b[j].key < a[i].key:
+			err = emit(b[j].q, graph.Add)
+			j++
+		default:
+			i++
+			j++
+		}
+		if err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+type sortedQuad struct {
+	key string
+	q   quad.Quad
+}
+
+// readSorted reads all quads from the reader, sorts them and removes duplicates.
+func readSorted(ctx context.Context, r quad.Reader) ([]sortedQuad, error) {
+	var out []sortedQuad
+	for {
+		q, err := r.ReadQuad()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(out)%quad.DefaultBatch == 0 {
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, sortedQuad{key: q.NQuad(), q: q})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].key < out[j].key
+	})
+	n := 0
+	for i := range out {
+		if n > 0 && out[n-1].key == out[i].key {
+			continue
+		}
+		out[n] = out[i]
+		n++
+	}
+	return out[:n], nil
+}
@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestDiff(t *testing.T) {
+	from := []quad.Quad{
+		quad.MakeIRI("bob", "follows", "fred", ""),
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "status", "cool", "g"),
+		quad.MakeIRI("alice", "follows", "bob", ""),
+	}
+	to := []quad.Quad{
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "status", "cool", ""),
+		quad.MakeIRI("fred", "follows", "greg", ""),
+	}
+	var got []graph.Delta
+	st, err := Diff(context.TODO(), quad.NewReader(from), quad.NewReader(to), func(d graph.Delta) error {
+		got = append(got, d)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, DiffStats{Added: 2, Removed: 2}, st)
+	require.Equal(t, []graph.Delta{
+		{Quad: quad.MakeIRI("bob", "follows", "fred", ""), Action: graph.Delete},
+		{Quad: quad.MakeIRI("bob", "status", "cool", ""), Action: graph.Add},
+		{Quad: quad.MakeIRI("bob", "status", "cool", "g"), Action: graph.Delete},
+		{Quad: quad.MakeIRI("fred", "follows", "greg", ""), Action: graph.Add},
+	}, got)
+}
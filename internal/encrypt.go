@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+const (
+	// encChunkSize is a size of plaintext sealed at once.
+	encChunkSize = 64 * 1024
+	// encKeyIters is a number of PBKDF2 iterations used to derive a key from a password.
+	encKeyIters = 100000
+	encSaltSize = 16
+)
+
+// errDecrypt is returned if the password is wrong or encrypted data was modified.
+var errDecrypt = errors.New("cannot decrypt data: wrong password or corrupted data")
+
+// passwordKey derives a 256 bit key from the password with PBKDF2-HMAC-SHA256.
+func passwordKey(password string, salt []byte) []byte {
+	prf := hmac.New(sha256.New, []byte(password))
+	// a single block of PBKDF2 is enough for a 256 bit key
+	var u, t []byte
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u = prf.Sum(nil)
+	t = append(t, u...)
+	for i := 1; i < encKeyIters; i++ {
+		u = hmacSum(prf, u)
+		for j := range t {
+			t[j] ^= u[j]
+		}
+	}
+	return t
+}
+
+func hmacSum(h hash.Hash, p []byte) []byte {
+	h.Reset()
+	h.Write(p)
+	return h.Sum(nil)
+}
+
+func newAEAD(password string, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(passwordKey(password, salt))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns a nonce for a chunk with a given index.
+// The last chunk is marked to detect truncated streams.
+func chunkNonce(aead cipher.AEAD, i uint64, last bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce, i)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// encryptWriter seals data with AES-256-GCM in chunks of encChunkSize. The key is unique for each salt,
+// thus chunk indexes are used as nonces.
+type encryptWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+	buf  []byte
+	out  []byte
+	n    uint64
+}
+
+// newEncryptWriter returns a writer that encrypts data with a key derived from the password and a random salt.
+// Close must be called to write the last chunk.
+func newEncryptWriter(w io.Writer, password string, salt []byte) (*encryptWriter, error) {
+	aead, err := newAEAD(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, buf: make([]byte, 0, encChunkSize)}, nil
+}
+
+func (w *encryptWriter) seal(last bool) error {
+	w.out = w.aead.Seal(w.out[:0], chunkNonce(w.aead, w.n, last), w.buf, nil)
+	w.n++
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(w.out)
+	return err
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if len(w.buf) == encChunkSize {
+			// only seal a full chunk when more data is written, since the last one must be marked
+			if err := w.seal(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(w.buf[len(w.buf):encChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+// Close writes the last chunk. It does not close the underlying writer.
+func (w *encryptWriter) Close() error {
+	return w.seal(true)
+}
+
+// decryptReader opens data sealed by encryptWriter.
+type decryptReader struct {
+	r    *bufio.Reader
+	aead cipher.AEAD
+	in   []byte
+	buf  []byte
+	n    uint64
+	last bool
+	err  error
+}
+
+func newDecryptReader(r io.Reader, password string, salt []byte) (*decryptReader, error) {
+	aead, err := newAEAD(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r: bufio.NewReader(r), aead: aead,
+		in: make([]byte, encChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (r *decryptReader) next() error {
+	if r.last {
+		return io.EOF
+	}
+	n, err := io.ReadFull(r.r, r.in)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		r.last = true
+	} else if err != nil {
+		return err
+	} else if _, err = r.r.Peek(1); err == io.EOF {
+		r.last = true
+	} else if err != nil {
+		return err
+	}
+	r.buf, err = r.aead.Open(r.in[:0], chunkNonce(r.aead, r.n, r.last), r.in[:n], nil)
+	if err != nil {
+		return errDecrypt
+	}
+	r.n++
+	return nil
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+)
+
+// QuadPattern matches quads by subject, predicate, object and label. Nil value matches any value.
+type QuadPattern [4]quad.Value
+
+var patternTerm = regexp.MustCompile(`^\s*(\*|<[^>]*>|_:[^\s]+|"(?:[^"\\]|\\.)*"(?:\^\^<[^>]*>|@[a-zA-Z0-9-]+)?)`)
+
+// ParsePattern parses a quad pattern in N-Quads notation, e.g. `* <follows> * <graph>`.
+// Wildcard "*" matches any value; missing trailing terms match any value as well.
+func ParsePattern(s string) (QuadPattern, error) {
+	var p QuadPattern
+	s = strings.TrimSuffix(strings.TrimSpace(s), ".")
+	nq := quad.FormatByName("nquads")
+	for i := 0; strings.TrimSpace(s) != ""; i++ {
+		if i >= len(p) {
+			return p, fmt.Errorf("too many terms in pattern")
+		}
+		m := patternTerm.FindStringSubmatch(s)
+		if m == nil {
+			return p, fmt.Errorf("invalid term in pattern: %q", strings.TrimSpace(s))
+		}
+		s = s[len(m[0]):]
+		if m[1] == "*" {
+			continue
+		}
+		v, err := nq.UnmarshalValue([]byte(m[1]))
+		if err != nil {
+			return p, fmt.Errorf("invalid term in pattern: %v", err)
+		}
+		p[i] = v
+	}
+	return p, nil
+}
+
+// Shape returns a shape that finds all quads matching the pattern.
+func (p QuadPattern) Shape() shape.Quads {
+	var s shape.Quads
+	for i, v := range p {
+		if v != nil {
+			s = append(s, shape.QuadFilter{Dir: quad.Direction(i + 1), Values: shape.Lookup{v}})
+		}
+	}
+	return s
+}
+
+// Match checks if a quad matches the pattern.
+func (p QuadPattern) Match(q quad.Quad) bool {
+	for i, v := range p {
+		if v == nil {
+			continue
+		}
+		if qv := q.Get(quad.Direction(i + 1)); qv == nil || qv.String() != v.String() {
+			return false
+		}
+	}
+	return true
+}
+
+// QueryNodes runs a query and collects all nodes returned in its results, including tagged ones.
+func QueryNodes(ctx context.Context, qs graph.QuadStore, lang, qu string) ([]graph.Value, error) {
+	l := query.GetLanguage(lang)
+	if l == nil || l.Session == nil {
+		return nil, fmt.Errorf("unsupported query language: %q", lang)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	out := make(chan query.Result, 10)
+	go l.Session(qs).Execute(ctx, qu, out, -1)
+
+	var (
+		nodes []graph.Value
+		err   error
+	)
+	seen := make(map[interface{}]struct{})
+	add := func(v graph.Value) {
+		if v == nil {
+			return
+		}
+		k := graph.ToKey(v)
+		if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			nodes = append(nodes, v)
+		}
+	}
+	for r := range out {
+		if err != nil {
+			continue
+		} else if err = r.Err(); err != nil {
+			cancel()
+			continue
+		}
+		switch v := r.Result().(type) {
+		case map[string]graph.Value:
+			for _, gv := range v {
+				add(gv)
+			}
+		case graph.Value:
+			add(v)
+		default:
+			err = fmt.Errorf("unsupported result type: %T", v)
+			cancel()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// NewNodesReader returns a reader for all quads with given subjects that match the pattern.
+func NewNodesReader(qs graph.QuadStore, nodes []graph.Value, p QuadPattern) quad.ReadCloser {
+	return &nodesReader{qs: qs, nodes: nodes, p: p}
+}
+
+type nodesReader struct {
+	qs    graph.QuadStore
+	nodes []graph.Value
+	p     QuadPattern
+	it    graph.Iterator
+}
+
+func (r *nodesReader) ReadQuad() (quad.Quad, error) {
+	ctx := context.TODO()
+	for {
+		if r.it == nil {
+			if len(r.nodes) == 0 {
+				return quad.Quad{}, io.EOF
+			}
+			r.it = r.qs.QuadIterator(quad.Subject, r.nodes[0])
+			r.nodes = r.nodes[1:]
+		}
+		for r.it.Next(ctx) {
+			q := r.qs.Quad(r.it.Result())
+			if r.p.Match(q) {
+				return q, nil
+			}
+		}
+		err := r.it.Err()
+		r.it.Close()
+		r.it = nil
+		if err != nil {
+			return quad.Quad{}, err
+		}
+	}
+}
+
+func (r *nodesReader) Close() error {
+	if r.it != nil {
+		return r.it.Close()
+	}
+	return nil
+}
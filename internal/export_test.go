@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+)
+
+var exportQuads = []quad.Quad{
+	quad.MakeIRI("alice", "follows", "bob", ""),
+	quad.MakeIRI("bob", "follows", "fred", ""),
+	quad.MakeIRI("bob", "status", "cool", "g"),
+	quad.MakeIRI("fred", "follows", "greg", ""),
+	quad.Make(quad.IRI("fred"), quad.IRI("name"), "Fred Smith", nil),
+}
+
+func readAll(t testing.TB, r quad.Reader) []quad.Quad {
+	var out []quad.Quad
+	for {
+		q, err := r.ReadQuad()
+		if err == io.EOF {
+			return out
+		}
+		require.NoError(t, err)
+		out = append(out, q)
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	for _, c := range []struct {
+		s   string
+		exp QuadPattern
+		err bool
+	}{
+		{s: "", exp: QuadPattern{}},
+		{s: "* <follows>", exp: QuadPattern{nil, quad.IRI("follows")}},
+		{s: `* * "Fred Smith" .`, exp: QuadPattern{nil, nil, quad.String("Fred Smith")}},
+		{s: `* * * <g>`, exp: QuadPattern{nil, nil, nil, quad.IRI("g")}},
+		{s: `_:a * "x"@en`, exp: QuadPattern{quad.BNode("a"), nil, quad.LangString{Value: "x", Lang: "en"}}},
+		{s: `* * * * *`, err: true},
+		{s: `follows`, err: true},
+	} {
+		p, err := ParsePattern(c.s)
+		if c.err {
+			require.Error(t, err, c.s)
+			continue
+		}
+		require.NoError(t, err, c.s)
+		require.Equal(t, c.exp, p, c.s)
+	}
+}
+
+func TestExport(t *testing.T) {
+	qs := memstore.New(exportQuads...)
+
+	p, err := ParsePattern("* <follows> *")
+	require.NoError(t, err)
+	got := readAll(t, graph.NewResultReader(qs, p.Shape().BuildIterator(qs)))
+	require.Len(t, got, 3)
+
+	nodes, err := QueryNodes(context.Background(), qs, "gizmo", `g.V("<alice>").Out("<follows>").All()`)
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+
+	got = readAll(t, NewNodesReader(qs, nodes, QuadPattern{}))
+	require.Len(t, got, 2)
+
+	p, err = ParsePattern("* * * <g>")
+	require.NoError(t, err)
+	got = readAll(t, NewNodesReader(qs, nodes, p))
+	require.Equal(t, []quad.Quad{exportQuads[2]}, got)
+}
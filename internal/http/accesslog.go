@@ -0,0 +1,118 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/server/http"
+)
+
+// AccessLog writes a JSON line for each served request.
+type AccessLog struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	sample float64
+}
+
+// NewAccessLog creates an access log that writes entries to w.
+//
+// Only a given fraction of successful requests is logged, while failed requests are always logged.
+// Sample rate outside of (0, 1) range means that all requests are logged.
+func NewAccessLog(w io.Writer, sample float64) *AccessLog {
+	if sample <= 0 || sample > 1 {
+		sample = 1
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &AccessLog{enc: enc, sample: sample}
+}
+
+// AccessEntry is a single line of the access log.
+type AccessEntry struct {
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Remote   string    `json:"remote"`
+	Status   int       `json:"status"`
+	Duration float64   `json:"duration_ms"`
+	Lang     string    `json:"lang,omitempty"`
+	Results  *int      `json:"results,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Log writes an entry to the access log, unless it was sampled out.
+func (l *AccessLog) Log(e AccessEntry) {
+	if e.Error == "" && l.sample < 1 && rand.Float64() >= l.sample {
+		return
+	}
+	l.mu.Lock()
+	err := l.enc.Encode(e)
+	l.mu.Unlock()
+	if err != nil {
+		clog.Errorf("cannot write access log: %v", err)
+	}
+}
+
+// Wrap is a middleware that logs requests served by the handler.
+func (l *AccessLog) Wrap(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		start := time.Now()
+		req, ri := cayleyhttp.WithRequestInfo(req)
+		if lang := params.ByName("query_lang"); lang != "" {
+			ri.SetLang(lang)
+		}
+		code := http.StatusOK
+		handler(&statusWriter{ResponseWriter: w, code: &code}, req, params)
+
+		e := AccessEntry{
+			Time:     start.UTC(),
+			Method:   req.Method,
+			Path:     req.URL.Path,
+			Remote:   remoteAddr(req),
+			Status:   code,
+			Duration: float64(time.Since(start)) / float64(time.Millisecond),
+			Lang:     ri.Lang,
+		}
+		if ri.Results >= 0 {
+			n := ri.Results
+			e.Results = &n
+		}
+		e.Error = errorClass(ri, code)
+		l.Log(e)
+	}
+}
+
+// errorClass returns an error class of the request, taking the status code into account.
+func errorClass(ri *cayleyhttp.RequestInfo, code int) string {
+	if ri.Error != "" {
+		return ri.Error
+	}
+	switch {
+	case code >= 500:
+		return cayleyhttp.ErrClassServer
+	case code >= 400:
+		return cayleyhttp.ErrClassClient
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+)
+
+func TestAccessLog(t *testing.T) {
+	h := newTestHandle(t, quad.MakeIRI("alice", "follows", "bob", ""))
+	buf := bytes.NewBuffer(nil)
+	r, _ := newRouter(h, &Config{AccessLog: NewAccessLog(buf, 1)}, "")
+
+	for _, q := range []string{
+		`g.V("<alice>").Out("<follows>").All()`,
+		`g.V(`,
+	} {
+		req := httptest.NewRequest("POST", "/api/v2/query?lang=gizmo", strings.NewReader(q))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest("POST", "/api/v1/query/gizmo", strings.NewReader(`g.V().All()`))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entries []AccessEntry
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var e AccessEntry
+		require.NoError(t, dec.Decode(&e))
+		entries = append(entries, e)
+	}
+	require.Len(t, entries, 3)
+
+	e := entries[0]
+	require.Equal(t, "POST", e.Method)
+	require.Equal(t, "/api/v2/query", e.Path)
+	require.Equal(t, http.StatusOK, e.Status)
+	require.Equal(t, "gizmo", e.Lang)
+	require.NotNil(t, e.Results)
+	require.Equal(t, 1, *e.Results)
+	require.Empty(t, e.Error)
+
+	e = entries[1]
+	require.Equal(t, http.StatusBadRequest, e.Status)
+	require.Equal(t, "query", e.Error)
+	require.Nil(t, e.Results)
+
+	e = entries[2]
+	require.Equal(t, "/api/v1/query/gizmo", e.Path)
+	require.Equal(t, "gizmo", e.Lang)
+	require.NotNil(t, e.Results)
+	require.Equal(t, 3, *e.Results)
+}
+
+func TestAccessLogSample(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := NewAccessLog(buf, 0.000001)
+	ok := l.Wrap(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
+	fail := l.Wrap(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	for i := 0; i < 10; i++ {
+		ok(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), nil)
+	}
+	fail(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), nil)
+	// failed requests are never sampled out
+	var e AccessEntry
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		require.NoError(t, dec.Decode(&e))
+	}
+	require.Equal(t, http.StatusInternalServerError, e.Status)
+	require.Equal(t, "server", e.Error)
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/server/http"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// auditWrap is a middleware that records requests served by the handler to the audit log.
+func (cfg *Config) auditWrap(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		start := time.Now()
+		req, ri := cayleyhttp.WithRequestInfo(req)
+		if lang := params.ByName("query_lang"); lang != "" {
+			ri.SetLang(lang)
+		}
+		code := http.StatusOK
+		handler(&statusWriter{ResponseWriter: w, code: &code}, req, params)
+
+		e := model.AuditEntry{
+			Time:     start.UTC(),
+			Roles:    cayleyhttp.RequestRoles(req, cfg.RolesHeader),
+			Remote:   remoteAddr(req),
+			Method:   req.Method,
+			Path:     req.URL.Path,
+			Lang:     ri.Lang,
+			Query:    ri.Query,
+			Status:   code,
+			Duration: float64(time.Since(start)) / float64(time.Millisecond),
+			Error:    errorClass(ri, code),
+		}
+		if cfg.AuditUserHeader != "" {
+			e.User = req.Header.Get(cfg.AuditUserHeader)
+		}
+		if vals := req.URL.Query(); len(vals) != 0 {
+			e.Params = vals
+		}
+		if ri.Results >= 0 {
+			n := ri.Results
+			e.Results = &n
+		}
+		if ri.Quads >= 0 {
+			n := ri.Quads
+			e.Quads = &n
+		}
+		cfg.Audit.Record(e)
+	}
+}
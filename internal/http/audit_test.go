@@ -0,0 +1,88 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/internal/audit"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+func TestAuditLog(t *testing.T) {
+	h := newTestHandle(t, quad.MakeIRI("alice", "follows", "bob", ""))
+	buf := bytes.NewBuffer(nil)
+	l := audit.New(audit.NewMemory(10), audit.Config{})
+	defer l.Close()
+	r, _ := newRouter(h, &Config{
+		AccessLog:       NewAccessLog(buf, 1),
+		AdminToken:      "secret",
+		RolesHeader:     "X-Roles",
+		Audit:           l,
+		AuditUserHeader: "X-User",
+	}, "")
+
+	const qu = `g.V("<alice>").Out("<follows>").All()`
+	req := httptest.NewRequest("POST", "/api/v2/query?lang=gizmo", strings.NewReader(qu))
+	req.Header.Set("X-User", "bob")
+	req.Header.Set("X-Roles", "analyst")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/api/v2/write", strings.NewReader("<bob> <follows> <fred> .\n<fred> <follows> <bob> .\n"))
+	req.Header.Set("Content-Type", "application/n-quads")
+	req.Header.Set("X-User", "alice")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	// access log shares request details with the audit log
+	var ae AccessEntry
+	require.NoError(t, json.NewDecoder(buf).Decode(&ae))
+	require.Equal(t, "gizmo", ae.Lang)
+	require.NotNil(t, ae.Results)
+
+	search := func(params string) []model.AuditEntry {
+		req := httptest.NewRequest("GET", "/api/v2/admin/audit"+params, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var out model.AuditEntryList
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&out))
+		return out.Entries
+	}
+
+	entries := search("")
+	require.Len(t, entries, 2)
+
+	e := entries[1]
+	require.Equal(t, "bob", e.User)
+	require.Equal(t, []string{"analyst"}, e.Roles)
+	require.Equal(t, "/api/v2/query", e.Path)
+	require.Equal(t, map[string][]string{"lang": {"gizmo"}}, e.Params)
+	require.Equal(t, "gizmo", e.Lang)
+	require.Equal(t, qu, e.Query)
+	require.NotNil(t, e.Results)
+	require.Equal(t, 1, *e.Results)
+	require.Nil(t, e.Quads)
+
+	e = entries[0]
+	require.Equal(t, "alice", e.User)
+	require.Equal(t, "/api/v2/write", e.Path)
+	require.Equal(t, http.StatusOK, e.Status)
+	require.NotNil(t, e.Quads)
+	require.Equal(t, 2, *e.Quads)
+
+	entries = search("?user=bob")
+	require.Len(t, entries, 1)
+	require.Equal(t, "/api/v2/query", entries[0].Path)
+
+	// searches are audited as well, after they complete
+	entries = search("?path=/api/v2/admin")
+	require.Len(t, entries, 2)
+	require.Equal(t, map[string][]string{"user": {"bob"}}, entries[0].Params)
+}
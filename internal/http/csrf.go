@@ -0,0 +1,105 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/cayleygraph/cayley/server/http"
+)
+
+const (
+	sessionCookie = "cayley_session"
+	hdrCSRFToken  = "X-CSRF-Token"
+	sessionIDSize = 16
+)
+
+// sessions issues session cookies to the web UI and validates CSRF tokens bound to them.
+//
+// Requests that carry a session cookie are considered to be originated by the UI and must pass
+// a valid CSRF token in the X-CSRF-Token header for all unsafe methods. Requests without
+// the cookie or with an Authorization header are regular API calls and are not affected.
+type sessions struct {
+	key []byte // HMAC key for CSRF tokens; sessions expire on restart
+}
+
+func newSessions() *sessions {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return &sessions{key: key}
+}
+
+// uiSessions is shared by all databases, since the UI is served only for the main one.
+var uiSessions = newSessions()
+
+func (s *sessions) token(id string) string {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// Start returns a CSRF token for the current session, creating a new session if necessary.
+func (s *sessions) Start(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		return s.token(c.Value)
+	}
+	buf := make([]byte, sessionIDSize)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	id := base64.RawURLEncoding.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     cayleyhttp.BasePath(r) + "/",
+		HttpOnly: true,
+		Secure:   cayleyhttp.BaseURL(r).Scheme == "https",
+	})
+	return s.token(id)
+}
+
+// Valid checks if the request is either not bound to a UI session, or has a valid CSRF token.
+func (s *sessions) Valid(r *http.Request) bool {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	if r.Header.Get("Authorization") != "" {
+		return true
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return true
+	}
+	tok := r.Header.Get(hdrCSRFToken)
+	return tok != "" && hmac.Equal([]byte(tok), []byte(s.token(c.Value)))
+}
+
+// Protect rejects UI-originated requests without a valid CSRF token.
+func (s *sessions) Protect(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Valid(r) {
+			jsonResponse(w, http.StatusForbidden, "invalid or missing CSRF token")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSRF(t *testing.T) {
+	s := newSessions()
+	h := s.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	tok := s.Start(rec, httptest.NewRequest("GET", "/", nil))
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	sc := cookies[0]
+	require.Equal(t, sessionCookie, sc.Name)
+	require.True(t, sc.HttpOnly)
+
+	// token is stable for the same session
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(sc)
+	rec = httptest.NewRecorder()
+	require.Equal(t, tok, s.Start(rec, req))
+	require.Empty(t, rec.Result().Cookies())
+
+	serve := func(method string, cookie bool, hdr map[string]string) int {
+		req := httptest.NewRequest(method, "/api/v1/write", nil)
+		if cookie {
+			req.AddCookie(sc)
+		}
+		for k, v := range hdr {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for _, c := range []struct {
+		name   string
+		method string
+		cookie bool
+		hdr    map[string]string
+		exp    int
+	}{
+		{name: "api call", method: "POST", exp: http.StatusOK},
+		{name: "ui read", method: "GET", cookie: true, exp: http.StatusOK},
+		{name: "ui write", method: "POST", cookie: true, hdr: map[string]string{hdrCSRFToken: tok}, exp: http.StatusOK},
+		{name: "no token", method: "POST", cookie: true, exp: http.StatusForbidden},
+		{name: "bad token", method: "DELETE", cookie: true, hdr: map[string]string{hdrCSRFToken: "x" + tok}, exp: http.StatusForbidden},
+		{name: "token auth", method: "POST", cookie: true, hdr: map[string]string{"Authorization": "Bearer secret"}, exp: http.StatusOK},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.exp, serve(c.method, c.cookie, c.hdr))
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/http"
+)
+
+// dbPrefix is a path prefix for named databases.
+const dbPrefix = "/db/"
+
+var validDBName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// database is a named database served by the API.
+type database struct {
+	handler http.Handler // serves all methods of the API for this database
+	rt      *routes
+	graph   *managedGraph // set for graphs created through the graph management API
+}
+
+var databases = struct {
+	sync.RWMutex
+	once   sync.Once
+	main   *routes              // database served by SetupRoutes
+	names  map[string]*database // named databases; nil while being set up
+	graphs *graphManager        // graph management API, if enabled
+}{names: make(map[string]*database)}
+
+// addDatabase serves a named database with a given handle.
+func addDatabase(name string, handle *graph.Handle, cfg *Config, g *managedGraph) error {
+	if !validDBName.MatchString(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid database name: %q", name)
+	}
+	databases.Lock()
+	_, ok := databases.names[name]
+	if !ok {
+		databases.names[name] = nil
+	}
+	databases.Unlock()
+	if ok {
+		return fmt.Errorf("database %q is already registered", name)
+	}
+	assets, err := findAssetsPath()
+	if err != nil {
+		databases.Lock()
+		delete(databases.names, name)
+		databases.Unlock()
+		return err
+	}
+	r, rt := newRouter(handle, cfg, assets)
+	databases.Lock()
+	databases.names[name] = &database{handler: uiSessions.Protect(r), rt: rt, graph: g}
+	databases.Unlock()
+	databases.once.Do(func() {
+		http.HandleFunc(dbPrefix, serveDatabases)
+	})
+	return nil
+}
+
+// lookupDatabase returns a named database, or nil if it is not served.
+func lookupDatabase(name string) *database {
+	databases.RLock()
+	defer databases.RUnlock()
+	return databases.names[name]
+}
+
+// databaseNames returns sorted names of all named databases.
+func databaseNames() []string {
+	databases.RLock()
+	names := make([]string, 0, len(databases.names))
+	for name, db := range databases.names {
+		if db != nil {
+			names = append(names, name)
+		}
+	}
+	databases.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// SetupDatabase serves an additional named database under /db/{name}/.
+//
+// Each database is served by a separate instance of the API with its own configuration,
+// change feed and handle, thus requests to one database never touch another one.
+func SetupDatabase(name string, handle *graph.Handle, cfg *Config) error {
+	return addDatabase(name, handle, cfg, nil)
+}
+
+// Reload applies settings that can be changed without restarting the server to a database served by
+// SetupDatabase, or to the one served by SetupRoutes if the name is empty. Settings of the main
+// database are also applied to graphs created through the graph management API.
+//
+// Only ReadOnly, Timeout, Limits and AdminToken fields of the config are applied, other fields are ignored.
+func Reload(name string, cfg *Config) error {
+	databases.RLock()
+	rt := databases.main
+	if name != "" {
+		rt = nil
+		if db := databases.names[name]; db != nil && db.graph == nil {
+			rt = db.rt
+		}
+	}
+	gm := databases.graphs
+	databases.RUnlock()
+	if rt == nil && name == "" {
+		return fmt.Errorf("main database is not served")
+	} else if rt == nil {
+		return fmt.Errorf("database %q is not served", name)
+	}
+	rt.reload(cfg)
+	if name == "" && gm != nil {
+		gm.reload(cfg)
+	}
+	return nil
+}
+
+// serveDatabases lists names of all databases served under /db/, or passes the request to one of them.
+func serveDatabases(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != dbPrefix {
+		name := strings.TrimPrefix(r.URL.Path, dbPrefix)
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			name = name[:i]
+		}
+		db := lookupDatabase(name)
+		if db == nil {
+			jsonResponse(w, http.StatusNotFound, "database not found")
+			return
+		}
+		cayleyhttp.StripPrefix(dbPrefix+name, db.handler).ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Databases []string `json:"databases"`
+	}{databaseNames()})
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHandle(t testing.TB, quads ...quad.Quad) *graph.Handle {
+	qs := memstore.New(quads...)
+	qw, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	return &graph.Handle{QuadStore: qs, QuadWriter: qw}
+}
+
+func TestSetupDatabase(t *testing.T) {
+	a := newTestHandle(t, quad.MakeIRI("alice", "follows", "bob", ""))
+	b := newTestHandle(t)
+	require.NoError(t, SetupDatabase("a", a, &Config{}))
+	require.NoError(t, SetupDatabase("b", b, &Config{ReadOnly: true}))
+	require.Error(t, SetupDatabase("a", b, &Config{}))
+	require.Error(t, SetupDatabase("a/b", b, &Config{}))
+
+	srv := httptest.NewServer(http.DefaultServeMux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/db/")
+	require.NoError(t, err)
+	var list struct {
+		Databases []string `json:"databases"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, list.Databases)
+
+	write := func(db string) int {
+		resp, err := http.Post(srv.URL+"/db/"+db+"/api/v2/write", "application/n-quads",
+			bytes.NewBufferString("<bob> <follows> <fred> .\n"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusOK, write("a"))
+	// read-only database does not have write methods
+	require.NotEqual(t, http.StatusOK, write("b"))
+	require.NotNil(t, a.QuadStore.ValueOf(quad.IRI("fred")))
+	require.Nil(t, b.QuadStore.ValueOf(quad.IRI("fred")))
+}
+
+func TestReload(t *testing.T) {
+	h := newTestHandle(t)
+	require.NoError(t, SetupDatabase("reload", h, &Config{ReadOnly: true}))
+	require.Error(t, Reload("missing", &Config{}))
+
+	srv := httptest.NewServer(http.DefaultServeMux)
+	defer srv.Close()
+
+	write := func() int {
+		resp, err := http.Post(srv.URL+"/db/reload/api/v2/write", "application/n-quads",
+			bytes.NewBufferString("<bob> <follows> <fred> .\n"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusForbidden, write())
+	require.NoError(t, Reload("reload", &Config{}))
+	require.Equal(t, http.StatusOK, write())
+	require.NotNil(t, h.QuadStore.ValueOf(quad.IRI("fred")))
+}
+
+func TestGraphs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley_graphs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "graphs.json")
+
+	var opened []string
+	open := func(name string, spec GraphSpec) (*graph.Handle, error) {
+		opened = append(opened, name)
+		return newTestHandle(t), nil
+	}
+	require.NoError(t, SetupGraphs(open, &Config{AdminToken: "secret"}, path))
+	defer CloseGraphs()
+
+	srv := httptest.NewServer(http.DefaultServeMux)
+	defer srv.Close()
+
+	do := func(method, path, token, body string) int {
+		req, err := http.NewRequest(method, srv.URL+path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	const spec = `{"backend": "memstore"}`
+	require.Equal(t, http.StatusUnauthorized, do("PUT", "/api/v2/graphs/people", "wrong", spec))
+	require.Equal(t, http.StatusCreated, do("PUT", "/api/v2/graphs/people", "secret", spec))
+	require.Equal(t, http.StatusConflict, do("PUT", "/api/v2/graphs/people", "secret", spec))
+	require.Equal(t, http.StatusBadRequest, do("PUT", "/api/v2/graphs/movies", "secret", `{"backend": "unknown"}`))
+	require.Equal(t, []string{"people"}, opened)
+
+	write := `<bob> <follows> <fred> .` + "\n"
+	require.Equal(t, http.StatusOK, do("POST", "/api/v2/graphs/people/write", "", write))
+	require.Equal(t, http.StatusNotFound, do("POST", "/api/v2/graphs/movies/write", "", write))
+	h := lookupDatabase("people").graph.handle
+	require.NotNil(t, h.QuadStore.ValueOf(quad.IRI("fred")))
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var specs map[string]GraphSpec
+	require.NoError(t, json.Unmarshal(data, &specs))
+	require.Equal(t, map[string]GraphSpec{"people": {Backend: "memstore"}}, specs)
+
+	require.Equal(t, http.StatusOK, do("DELETE", "/api/v2/graphs/people", "secret", ""))
+	require.Equal(t, http.StatusNotFound, do("DELETE", "/api/v2/graphs/people", "secret", ""))
+	require.Equal(t, http.StatusNotFound, do("POST", "/api/v2/graphs/people/write", "", write))
+	require.Nil(t, lookupDatabase("people"))
+}
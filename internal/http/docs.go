@@ -23,6 +23,8 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/russross/blackfriday"
+
+	"github.com/cayleygraph/cayley/server/http"
 )
 
 type DocRequestHandler struct {
@@ -30,6 +32,10 @@ type DocRequestHandler struct {
 }
 
 func MarkdownWithCSS(input []byte, title string) []byte {
+	return markdownWithCSS(input, title, markdownCSS)
+}
+
+func markdownWithCSS(input []byte, title, css string) []byte {
 	// set up the HTML renderer
 	htmlFlags := 0
 	htmlFlags |= blackfriday.HTML_USE_XHTML
@@ -37,7 +43,7 @@ func MarkdownWithCSS(input []byte, title string) []byte {
 	htmlFlags |= blackfriday.HTML_SMARTYPANTS_FRACTIONS
 	htmlFlags |= blackfriday.HTML_SMARTYPANTS_LATEX_DASHES
 	htmlFlags |= blackfriday.HTML_COMPLETE_PAGE
-	renderer := blackfriday.HtmlRenderer(htmlFlags, title, markdownCSS)
+	renderer := blackfriday.HtmlRenderer(htmlFlags, title, css)
 
 	// set up the parser
 	extensions := 0
@@ -71,7 +77,7 @@ func (h *DocRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, pa
 		http.Error(w, err.Error(), http.StatusNoContent)
 		return
 	}
-	output := MarkdownWithCSS(data, fmt.Sprintf("Cayley Docs - %s", docpage))
+	output := markdownWithCSS(data, fmt.Sprintf("Cayley Docs - %s", docpage), cayleyhttp.BasePath(r)+markdownCSS)
 	fmt.Fprint(w, string(output))
 }
 
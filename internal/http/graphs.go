@@ -0,0 +1,327 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/http"
+)
+
+// graphsPrefix is a path prefix of the graph management API.
+const graphsPrefix = "/api/v2/graphs/"
+
+// GraphSpec describes a database created through the graph management API.
+type GraphSpec struct {
+	Backend  string                 `json:"backend"`
+	Address  string                 `json:"address,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+	ReadOnly bool                   `json:"read_only,omitempty"`
+}
+
+// OpenGraphFunc opens a database for a graph created through the graph management API.
+type OpenGraphFunc func(name string, spec GraphSpec) (*graph.Handle, error)
+
+// managedGraph is a graph created through the graph management API.
+type managedGraph struct {
+	spec   GraphSpec
+	handle *graph.Handle
+}
+
+// graphManager creates and drops named databases at runtime.
+type graphManager struct {
+	mu   sync.Mutex // serializes changes of the graph list
+	open OpenGraphFunc
+	path string // file with specs of created graphs; graphs are not persisted if empty
+
+	cmu sync.RWMutex
+	cfg *Config
+}
+
+func (gm *graphManager) conf() *Config {
+	gm.cmu.RLock()
+	defer gm.cmu.RUnlock()
+	return gm.cfg
+}
+
+// graphConfig returns a config for a graph with a given spec.
+func (gm *graphManager) graphConfig(spec GraphSpec) *Config {
+	c := *gm.conf()
+	c.ReadOnly = c.ReadOnly || spec.ReadOnly
+	return &c
+}
+
+// reload applies settings of the main database to all created graphs.
+func (gm *graphManager) reload(cfg *Config) {
+	gm.cmu.Lock()
+	c := *gm.cfg
+	c.ReadOnly = cfg.ReadOnly
+	c.Timeout = cfg.Timeout
+	c.Limits = cfg.Limits
+	c.Parallel = cfg.Parallel
+	c.StreamFlush = cfg.StreamFlush
+	c.AdminToken = cfg.AdminToken
+	c.ACL = cfg.ACL
+	c.RolesHeader = cfg.RolesHeader
+	gm.cfg = &c
+	gm.cmu.Unlock()
+
+	databases.RLock()
+	defer databases.RUnlock()
+	for _, db := range databases.names {
+		if db != nil && db.graph != nil {
+			db.rt.reload(gm.graphConfig(db.graph.spec))
+		}
+	}
+}
+
+// SetupGraphs enables the graph management API under /api/v2/graphs/.
+//
+// Graphs can be created and dropped at runtime by requests authorized with the admin token.
+// Each graph is a separate named database opened with the open function, and is served both under
+// /api/v2/graphs/{name}/ and /db/{name}/. Graphs follow the config of the main database.
+//
+// If path is not empty, specs of created graphs are saved to this file, and graphs listed in it are opened
+// by this function.
+func SetupGraphs(open OpenGraphFunc, cfg *Config, path string) error {
+	gm := &graphManager{open: open, path: path, cfg: cfg}
+	databases.Lock()
+	if databases.graphs != nil {
+		databases.Unlock()
+		return fmt.Errorf("graph management API is already enabled")
+	}
+	databases.graphs = gm
+	databases.Unlock()
+	if path != "" {
+		specs, err := gm.load()
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(specs))
+		for name := range specs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err = gm.create(name, specs[name]); err != nil {
+				return fmt.Errorf("graph %q: %v", name, err)
+			}
+			clog.Infof("serving graph %q (%s)", name, specs[name].Backend)
+		}
+	}
+	http.HandleFunc(strings.TrimSuffix(graphsPrefix, "/"), gm.serveList)
+	http.HandleFunc(graphsPrefix, gm.serveHTTP)
+	return nil
+}
+
+// CloseGraphs closes databases of all graphs created through the graph management API.
+func CloseGraphs() error {
+	databases.Lock()
+	var list []*managedGraph
+	for name, db := range databases.names {
+		if db != nil && db.graph != nil {
+			list = append(list, db.graph)
+			delete(databases.names, name)
+		}
+	}
+	databases.Unlock()
+	var last error
+	for _, g := range list {
+		if err := g.handle.Close(); err != nil {
+			last = err
+		}
+	}
+	return last
+}
+
+// load reads specs of graphs from the registry file.
+func (gm *graphManager) load() (map[string]GraphSpec, error) {
+	data, err := ioutil.ReadFile(gm.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var specs map[string]GraphSpec
+	if err = json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("cannot parse graph list %q: %v", gm.path, err)
+	}
+	return specs, nil
+}
+
+// save writes specs of all created graphs to the registry file. It must be called with gm.mu held.
+func (gm *graphManager) save() error {
+	if gm.path == "" {
+		return nil
+	}
+	specs := make(map[string]GraphSpec)
+	databases.RLock()
+	for name, db := range databases.names {
+		if db != nil && db.graph != nil {
+			specs[name] = db.graph.spec
+		}
+	}
+	databases.RUnlock()
+	data, err := json.MarshalIndent(specs, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := gm.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, gm.path)
+}
+
+// create opens a database for a graph and starts serving it.
+func (gm *graphManager) create(name string, spec GraphSpec) error {
+	if spec.Backend == "" {
+		return fmt.Errorf("backend is not set")
+	} else if !graph.IsRegistered(spec.Backend) {
+		return fmt.Errorf("unknown backend: %q", spec.Backend)
+	}
+	h, err := gm.open(name, spec)
+	if err != nil {
+		return err
+	}
+	g := &managedGraph{spec: spec, handle: h}
+	if err = addDatabase(name, h, gm.graphConfig(spec), g); err != nil {
+		h.Close()
+		return err
+	}
+	return nil
+}
+
+// drop stops serving a graph and closes its database. Data of persistent backends is not removed.
+func (gm *graphManager) drop(name string) (int, error) {
+	databases.Lock()
+	db := databases.names[name]
+	if db == nil {
+		databases.Unlock()
+		return http.StatusNotFound, fmt.Errorf("graph %q does not exist", name)
+	} else if db.graph == nil {
+		databases.Unlock()
+		return http.StatusConflict, fmt.Errorf("database %q is defined in the config and cannot be dropped", name)
+	}
+	delete(databases.names, name)
+	databases.Unlock()
+	if err := db.graph.handle.Close(); err != nil {
+		clog.Warningf("cannot close graph %q: %v", name, err)
+	}
+	return http.StatusOK, nil
+}
+
+// serveList lists names of all databases available under /api/v2/graphs/.
+func (gm *graphManager) serveList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Graphs []string `json:"graphs"`
+	}{databaseNames()})
+}
+
+func (gm *graphManager) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, graphsPrefix)
+	if path == "" {
+		gm.serveList(w, r)
+		return
+	}
+	name, rest := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		name, rest = path[:i], path[i:]
+	}
+	if rest == "" {
+		gm.serveManage(w, r, name)
+		return
+	}
+	db := lookupDatabase(name)
+	if db == nil {
+		jsonResponse(w, http.StatusNotFound, "graph not found")
+		return
+	}
+	// /api/v2/graphs/{name}/query is served as /api/v2/query of the graph
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = "/api/v2" + rest
+	r2.URL.RawPath = ""
+	db.handler.ServeHTTP(w, r2)
+}
+
+// serveManage creates or drops a graph.
+func (gm *graphManager) serveManage(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		jsonResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !cayleyhttp.CheckAdminToken(w, r, gm.conf().AdminToken) {
+		return
+	}
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	if r.Method == http.MethodDelete {
+		if code, err := gm.drop(name); err != nil {
+			jsonResponse(w, code, err)
+			return
+		}
+		if err := gm.save(); err != nil {
+			jsonResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+		clog.Infof("dropped graph %q", name)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Result string `json:"result"`
+		}{fmt.Sprintf("graph %q dropped", name)})
+		return
+	}
+	var spec GraphSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if lookupDatabase(name) != nil {
+		jsonResponse(w, http.StatusConflict, fmt.Errorf("graph %q already exists", name))
+		return
+	}
+	if err := gm.create(name, spec); err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := gm.save(); err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	clog.Infof("created graph %q (%s)", name, spec.Backend)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		Result string `json:"result"`
+	}{fmt.Sprintf("graph %q created", name)})
+}
@@ -20,14 +20,22 @@ import (
 	"html/template"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/internal/audit"
 	"github.com/cayleygraph/cayley/internal/gephi"
+	"github.com/cayleygraph/cayley/internal/metrics"
+	"github.com/cayleygraph/cayley/quad/redact"
+	"github.com/cayleygraph/cayley/query"
 	"github.com/cayleygraph/cayley/server/http"
+	"github.com/cayleygraph/cayley/server/http/model"
 )
 
 var AssetsPath string
@@ -72,18 +80,32 @@ type statusWriter struct {
 
 func (w *statusWriter) WriteHeader(code int) {
 	*(w.code) = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher to support streaming responses.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// remoteAddr returns an address of the client, taking proxy headers into account.
+func remoteAddr(req *http.Request) string {
+	addr := req.Header.Get("X-Real-IP")
+	if addr == "" {
+		addr = req.Header.Get("X-Forwarded-For")
+		if addr == "" {
+			addr = req.RemoteAddr
+		}
+	}
+	return addr
 }
 
 func LogRequest(handler httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
 		start := time.Now()
-		addr := req.Header.Get("X-Real-IP")
-		if addr == "" {
-			addr = req.Header.Get("X-Forwarded-For")
-			if addr == "" {
-				addr = req.RemoteAddr
-			}
-		}
+		addr := remoteAddr(req)
 		code := 200
 		rw := &statusWriter{ResponseWriter: w, code: &code}
 		clog.Infof("started %s %s for %s", req.Method, req.URL.Path, addr)
@@ -110,28 +132,79 @@ func (h *TemplateRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 	if r.URL.Path == "/" {
 		uiType = "query"
 	}
-	err := h.templates.ExecuteTemplate(w, uiType+".html", h)
+	// links in templates are relative to the base path, so UI works behind a proxy
+	data := struct {
+		Base      string
+		CSRFToken string
+	}{
+		Base:      cayleyhttp.BasePath(r),
+		CSRFToken: uiSessions.Start(w, r),
+	}
+	err := h.templates.ExecuteTemplate(w, uiType+".html", data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
 type API struct {
+	mu     sync.RWMutex
 	config *Config
 	handle *graph.Handle
 }
 
+// conf returns the current config of the API.
+func (api *API) conf() *Config {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.config
+}
+
+// reload replaces settings that can be changed without restarting the server.
+func (api *API) reload(cfg *Config) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	c := *api.config
+	c.ReadOnly = cfg.ReadOnly
+	c.Timeout = cfg.Timeout
+	c.Limits = cfg.Limits
+	c.Parallel = cfg.Parallel
+	c.StreamFlush = cfg.StreamFlush
+	c.AdminToken = cfg.AdminToken
+	c.ACL = cfg.ACL
+	c.RolesHeader = cfg.RolesHeader
+	api.config = &c
+}
+
 func (api *API) GetHandleForRequest(r *http.Request) (*graph.Handle, error) {
-	return cayleyhttp.HandleForRequest(api.handle, "single", nil, r)
+	h, err := cayleyhttp.HandleForRequest(api.handle, "single", nil, r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg := api.conf(); cfg.ACL != nil {
+		return cayleyhttp.RestrictHandle(h, cfg.ACL, cayleyhttp.RequestRoles(r, cfg.RolesHeader)), nil
+	}
+	return h, nil
+}
+
+// WithoutACL disables a handler that reads the database directly while access control is enabled.
+func (api *API) WithoutACL(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		if api.conf().ACL != nil {
+			jsonResponse(w, http.StatusForbidden, "not available while access control is enabled")
+			return
+		}
+		handler(w, req, params)
+	}
 }
 
 func (api *API) RWOnly(handler httprouter.Handle) httprouter.Handle {
-	if api.config.ReadOnly {
-		return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		if api.conf().ReadOnly {
 			jsonResponse(w, http.StatusForbidden, "Database is read-only.")
+			return
 		}
+		handler(w, req, params)
 	}
-	return handler
 }
 
 func CORSFunc(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
@@ -151,20 +224,128 @@ func CORS(h httprouter.Handle) httprouter.Handle {
 }
 
 func (api *API) APIv1(r *httprouter.Router) {
-	r.POST("/api/v1/query/:query_lang", CORS(LogRequest(api.ServeV1Query)))
-	r.POST("/api/v1/shape/:query_lang", CORS(LogRequest(api.ServeV1Shape)))
-	r.POST("/api/v1/write", CORS(api.RWOnly(LogRequest(api.ServeV1Write))))
-	r.POST("/api/v1/write/file/nquad", CORS(api.RWOnly(LogRequest(api.ServeV1WriteNQuad))))
-	r.POST("/api/v1/delete", CORS(api.RWOnly(LogRequest(api.ServeV1Delete))))
+	logRequest := api.conf().requestLogger()
+	r.POST("/api/v1/query/:query_lang", CORS(logRequest(api.ServeV1Query)))
+	r.POST("/api/v1/shape/:query_lang", CORS(logRequest(api.ServeV1Shape)))
+	r.POST("/api/v1/write", CORS(api.RWOnly(logRequest(api.ServeV1Write))))
+	r.POST("/api/v1/write/file/nquad", CORS(api.RWOnly(logRequest(api.ServeV1WriteNQuad))))
+	r.POST("/api/v1/delete", CORS(api.RWOnly(logRequest(api.ServeV1Delete))))
 }
 
 type Config struct {
 	ReadOnly bool
 	Timeout  time.Duration
 	Batch    int
+	Limits   query.Limits
+	// Parallel enables parallel execution of queries, see query.WithParallel.
+	Parallel int
+	// StreamFlush is a maximal interval between flushes of streamed query results. Each row is flushed if not set.
+	StreamFlush time.Duration
+	// AdminToken enables maintenance endpoints protected by this bearer token.
+	AdminToken string
+	// AccessLog replaces default request logging with structured access log, if set.
+	AccessLog *AccessLog
+	// Replica reports the state of replication if the server is a read replica.
+	Replica cayleyhttp.ReplicaStatusFunc
+	// ACL restricts access of requests to named graphs, if set.
+	ACL *acl.Policy
+	// RolesHeader is a name of a header with roles of the request, set by an authenticating proxy.
+	RolesHeader string
+	// Audit records queries and writes to the audit log, if set.
+	Audit *audit.Log
+	// AuditUserHeader is a name of a header with the user name, set by an authenticating proxy.
+	AuditUserHeader string
+	// Redact removes or anonymizes quads returned by the read endpoint, if set.
+	Redact *redact.Redactor
+	// CacheSize is a number of query results cached by each database. Cache is disabled if not set.
+	CacheSize int
+	// Metrics collects metrics of queries and writes and serves them on /metrics, if set.
+	Metrics *metrics.Metrics
+	// Tracer records spans of requests and queries, if set.
+	Tracer *metrics.Tracer
 }
 
-func SetupRoutes(handle *graph.Handle, cfg *Config) error {
+// requestLogger returns a middleware for logging requests according to the config.
+func (cfg *Config) requestLogger() cayleyhttp.HandlerWrapper {
+	log := LogRequest
+	if cfg.AccessLog != nil {
+		log = cfg.AccessLog.Wrap
+	}
+	if cfg.Audit == nil && cfg.Metrics == nil && cfg.Tracer == nil {
+		return log
+	}
+	return func(h httprouter.Handle) httprouter.Handle {
+		h = log(h)
+		if cfg.Audit != nil {
+			h = cfg.auditWrap(h)
+		}
+		if cfg.Metrics != nil || cfg.Tracer != nil {
+			h = cfg.metricsWrap(h)
+		}
+		return h
+	}
+}
+
+// SetupHealth registers liveness and readiness probes.
+// It can be called before SetupRoutes to serve probes while the database is being opened.
+func SetupHealth(hs *cayleyhttp.Health) {
+	http.HandleFunc("/healthz", hs.ServeLive)
+	http.HandleFunc("/readyz", hs.ServeReady)
+}
+
+// setupSpec loads an OpenAPI specification and checks that it describes all API methods.
+func setupSpec(api *cayleyhttp.APIv2, path string) {
+	spec, err := cayleyhttp.LoadOpenAPI(path)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		clog.Warningf("cannot load api spec: %v", err)
+		return
+	}
+	if err = spec.Check(model.Operations); err != nil {
+		clog.Warningf("%v", err)
+	}
+	api.SetSpec(spec)
+}
+
+// changeFeedSize is the number of last changes retained for change feed subscribers.
+const changeFeedSize = 10000
+
+// routes is a set of APIs serving a single database.
+type routes struct {
+	v1 *API
+	v2 *cayleyhttp.APIv2
+}
+
+// reload applies settings that can be changed without restarting the server.
+func (rt *routes) reload(cfg *Config) {
+	rt.v1.reload(cfg)
+	rt.v2.SetReadOnly(cfg.ReadOnly)
+	rt.v2.SetQueryTimeout(cfg.Timeout)
+	rt.v2.SetQueryLimits(cfg.Limits)
+	rt.v2.SetQueryParallel(cfg.Parallel)
+	rt.v2.SetAdminToken(cfg.AdminToken)
+	rt.v2.SetACL(cfg.ACL)
+	rt.v2.SetRolesHeader(cfg.RolesHeader)
+	rt.v2.SetRedaction(cfg.Redact)
+}
+
+// newRouter creates a router serving all API methods for a given database.
+func newRouter(handle *graph.Handle, cfg *Config, assets string) (*httprouter.Router, *routes) {
+	feed, err := graph.NewChangeFeed(handle.QuadStore, changeFeedSize)
+	if err == graph.ErrNotSupported {
+		clog.Infof("backend does not report applied changes, change feed is disabled")
+	} else if err != nil {
+		clog.Errorf("cannot start change feed: %v", err)
+	}
+	if cfg.Metrics != nil {
+		hooks := new(graph.Hooks)
+		hooks.AfterCommit(cfg.Metrics.ObserveWrite)
+		handle = &graph.Handle{
+			QuadStore:  handle.QuadStore,
+			QuadWriter: graph.NewHookWriter(handle.QuadStore, handle.QuadWriter, hooks),
+		}
+	}
 	r := httprouter.New()
 	api := &API{config: cfg, handle: handle}
 	r.OPTIONS("/*path", CORSFunc)
@@ -174,15 +355,42 @@ func SetupRoutes(handle *graph.Handle, cfg *Config) error {
 	api2.SetReadOnly(cfg.ReadOnly)
 	api2.SetBatchSize(cfg.Batch)
 	api2.SetQueryTimeout(cfg.Timeout)
-	api2.RegisterOn(r, CORS, LogRequest)
+	api2.SetQueryLimits(cfg.Limits)
+	api2.SetQueryParallel(cfg.Parallel)
+	api2.SetAdminToken(cfg.AdminToken)
+	api2.SetACL(cfg.ACL)
+	api2.SetRolesHeader(cfg.RolesHeader)
+	api2.SetRedaction(cfg.Redact)
+	if feed != nil {
+		api2.SetChangeFeed(feed)
+	}
+	if cfg.CacheSize > 0 {
+		api2.SetResultCache(cayleyhttp.NewResultCache(cfg.CacheSize))
+	}
+	api2.SetReplicaStatus(cfg.Replica)
+	api2.SetAuditLog(cfg.Audit)
+	if assets != "" {
+		setupSpec(api2, filepath.Join(assets, "docs", "api", "swagger.yml"))
+	}
+	api2.RegisterOn(r, CORS, cfg.requestLogger())
 
 	gs := &gephi.GraphStreamHandler{QS: handle.QuadStore}
 	const gephiPath = "/gephi/gs"
-	r.GET(gephiPath, CORS(gs.ServeHTTP))
+	r.GET(gephiPath, CORS(api.WithoutACL(gs.ServeHTTP)))
+	return r, &routes{v1: api, v2: api2}
+}
 
-	if assets, err := findAssetsPath(); err != nil {
+func SetupRoutes(handle *graph.Handle, cfg *Config) error {
+	assets, err := findAssetsPath()
+	if err != nil {
 		return err
-	} else if assets != "" {
+	}
+	r, rt := newRouter(handle, cfg, assets)
+	databases.Lock()
+	databases.main = rt
+	databases.Unlock()
+
+	if assets != "" {
 		clog.Infof("using assets from %q", assets)
 		docs := &DocRequestHandler{assets: assets}
 		r.GET("/docs/:docpage", docs.ServeHTTP)
@@ -195,6 +403,9 @@ func SetupRoutes(handle *graph.Handle, cfg *Config) error {
 		http.Handle("/static/", http.StripPrefix("/static", http.FileServer(http.Dir(fmt.Sprint(assets, "/static/")))))
 	}
 
-	http.Handle("/", r)
+	if cfg.Metrics != nil {
+		http.Handle("/metrics", cfg.Metrics.Handler())
+	}
+	http.Handle("/", uiSessions.Protect(r))
 	return nil
 }
@@ -0,0 +1,58 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/http"
+)
+
+// metricsWrap is a middleware that records query metrics and traces requests served by the handler.
+func (cfg *Config) metricsWrap(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		start := time.Now()
+		ctx := req.Context()
+		if cfg.Tracer != nil {
+			ctx = cfg.Tracer.Context(req)
+		}
+		if cfg.Metrics != nil {
+			ctx = cfg.Metrics.Context(ctx)
+		}
+		ctx, end := graph.StartSpan(ctx, req.Method+" "+req.URL.Path)
+		req, ri := cayleyhttp.WithRequestInfo(req.WithContext(ctx))
+		if lang := params.ByName("query_lang"); lang != "" {
+			ri.SetLang(lang)
+		}
+		code := http.StatusOK
+		handler(&statusWriter{ResponseWriter: w, code: &code}, req, params)
+
+		status := errorClass(ri, code)
+		if status != "" {
+			end(fmt.Errorf("request failed: %s error", status))
+		} else {
+			end(nil)
+			status = "ok"
+		}
+		if cfg.Metrics != nil && ri.Lang != "" {
+			cfg.Metrics.ObserveQuery(ri.Lang, status, time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/internal/metrics"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+)
+
+func TestMetrics(t *testing.T) {
+	h := newTestHandle(t, quad.MakeIRI("alice", "follows", "bob", ""))
+	m := metrics.New(metrics.Options{Iterators: true})
+	r, _ := newRouter(h, &Config{Metrics: m}, "")
+
+	for _, q := range []string{
+		`g.V("<alice>").Out("<follows>").All()`,
+		`g.V(`,
+	} {
+		req := httptest.NewRequest("POST", "/api/v2/query?lang=gizmo", strings.NewReader(q))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest("POST", "/api/v2/write", strings.NewReader(`<bob> <follows> <fred> .`))
+	req.Header.Set("Content-Type", "application/n-quads")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	data, err := ioutil.ReadAll(w.Body)
+	require.NoError(t, err)
+	out := string(data)
+	require.Contains(t, out, `cayley_query_duration_seconds_count{lang="gizmo",status="ok"} 1`)
+	require.Contains(t, out, `cayley_query_duration_seconds_count{lang="gizmo",status="query"} 1`)
+	require.Contains(t, out, `cayley_iterator_calls_total{op="next"}`)
+	require.Contains(t, out, `cayley_write_batch_size_count 1`)
+	require.Contains(t, out, `cayley_write_deltas_total{action="add"} 1`)
+}
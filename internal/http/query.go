@@ -23,10 +23,12 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/server/http"
 )
 
 type SuccessQueryWrapper struct {
@@ -58,10 +60,10 @@ func GetQueryShape(q string, ses query.HTTP) ([]byte, error) {
 }
 
 func (api *API) contextForRequest(r *http.Request) (context.Context, func()) {
-	ctx := context.TODO() // TODO(dennwc): get from request
+	ctx := query.WithParallel(r.Context(), api.conf().Parallel)
 	cancel := func() {}
-	if api.config.Timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, api.config.Timeout)
+	if timeout := api.conf().Timeout; timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 	}
 	return ctx, cancel
 }
@@ -98,9 +100,16 @@ func (api *API) ServeV1Query(w http.ResponseWriter, r *http.Request, params http
 		errFunc(w, err)
 		return
 	}
+	qs, release, ok := cayleyhttp.StoreAt(w, r, h.QuadStore)
+	if !ok {
+		return
+	}
+	defer release()
+	ctx, qs, budget := query.WithLimits(ctx, qs, api.conf().Limits)
+	qs = query.Instrument(ctx, qs)
 	if l.HTTPQuery != nil {
 		defer r.Body.Close()
-		l.HTTPQuery(ctx, h.QuadStore, w, r.Body)
+		l.HTTPQuery(ctx, qs, w, r.Body)
 		return
 	}
 	if l.HTTP == nil {
@@ -114,7 +123,7 @@ func (api *API) ServeV1Query(w http.ResponseWriter, r *http.Request, params http
 		limit = 100
 	}
 
-	ses := l.HTTP(h.QuadStore)
+	ses := l.HTTP(qs)
 	bodyBytes, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		errFunc(w, err)
@@ -122,24 +131,57 @@ func (api *API) ServeV1Query(w http.ResponseWriter, r *http.Request, params http
 	}
 	code := string(bodyBytes)
 
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	ri := cayleyhttp.GetRequestInfo(r)
+	ri.SetQuery(code)
 	c := make(chan query.Result, 5)
-	go ses.Execute(ctx, code, c, limit)
+	go ses.Execute(ctx, code, c, budget.ResultLimit(limit))
+
+	if isStreaming(r) {
+		s := &resultStream{w: w, flush: api.conf().StreamFlush, errFunc: errFunc}
+		s.run(cancel, ses, c, budget)
+		if err := s.err; err != nil {
+			ri.SetError(err)
+		}
+		ri.SetResults(s.rows)
+		return
+	}
 
 	for res := range c {
-		if err := res.Err(); err != nil {
-			if err == nil {
-				continue // wait for results channel to close
+		err := res.Err()
+		if err == nil {
+			err = budget.AddResult()
+		}
+		if err != nil {
+			cancel()
+			for range c {
+			}
+			if lerr := budget.Err(); lerr != nil {
+				err = lerr
+			}
+			ri.SetError(err)
+			if !cayleyhttp.WriteLimitError(w, err) {
+				errFunc(w, err)
 			}
-			errFunc(w, err)
 			return
 		}
 		ses.Collate(res)
 	}
+	if err := budget.Err(); err != nil {
+		ri.SetError(err)
+		cayleyhttp.WriteLimitError(w, err)
+		return
+	}
 	output, err := ses.Results()
 	if err != nil {
+		ri.SetError(err)
 		errFunc(w, err)
 		return
 	}
+	if rows, ok := output.([]interface{}); ok {
+		ri.SetResults(len(rows))
+	}
 	_ = WriteResult(w, output)
 }
 
@@ -184,3 +226,162 @@ func (api *API) ServeV1Shape(w http.ResponseWriter, r *http.Request, params http
 	}
 	w.Write(output)
 }
+
+// mimeNDJSON is a content type of results streamed as JSON lines.
+const mimeNDJSON = "application/x-ndjson"
+
+// isStreaming checks if the client asked to stream results, either with the stream parameter,
+// or by accepting newline-delimited JSON.
+func isStreaming(r *http.Request) bool {
+	if s := r.URL.Query().Get("stream"); s != "" {
+		ok, _ := strconv.ParseBool(s)
+		return ok
+	}
+	for _, a := range cayleyhttp.ParseAccept(r.Header, "Accept") {
+		if a.Value == mimeNDJSON && a.Q > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resultStream writes query results as newline-delimited JSON, one row per line, while they are produced.
+//
+// Rows are written synchronously, so a slow client stops the query from producing more results until
+// the previous ones are sent. Errors that happen after the first row are sent as a last line with an "error" field.
+type resultStream struct {
+	w       http.ResponseWriter
+	flush   time.Duration // interval between flushes; each row is flushed if zero
+	errFunc func(query.ResponseWriter, error)
+
+	enc   *json.Encoder
+	rows  int
+	dirty bool
+	err   error
+}
+
+func (s *resultStream) run(cancel func(), ses query.HTTP, c <-chan query.Result, budget *query.Budget) {
+	defer func() {
+		// let the session exit
+		cancel()
+		for range c {
+		}
+	}()
+	sses, _ := ses.(query.StreamingHTTP)
+	var tick <-chan time.Time
+	if s.flush > 0 {
+		t := time.NewTicker(s.flush)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		var (
+			res query.Result
+			ok  bool
+		)
+		select {
+		case <-tick:
+			s.flushRows()
+			continue
+		case res, ok = <-c:
+		}
+		if !ok {
+			break
+		}
+		err := res.Err()
+		if err == nil {
+			err = budget.AddResult()
+		}
+		if err != nil {
+			if lerr := budget.Err(); lerr != nil {
+				err = lerr
+			}
+			s.fail(err)
+			return
+		}
+		if sses == nil {
+			// session cannot convert results separately; they are written at the end
+			ses.Collate(res)
+			continue
+		}
+		if row, ok := sses.Row(res); ok {
+			if err = s.writeRow(row); err != nil {
+				// client is gone
+				s.err = err
+				return
+			}
+		}
+	}
+	if err := budget.Err(); err != nil {
+		s.fail(err)
+		return
+	}
+	if sses == nil {
+		out, err := ses.Results()
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		rows, ok := out.([]interface{})
+		if !ok {
+			rows = []interface{}{out}
+		}
+		for _, row := range rows {
+			if err = s.writeRow(row); err != nil {
+				s.err = err
+				return
+			}
+		}
+	}
+	if s.enc == nil {
+		// no results, but the client still expects a stream
+		s.start()
+	}
+	s.flushRows()
+}
+
+func (s *resultStream) start() {
+	s.w.Header().Set("Content-Type", mimeNDJSON)
+	s.w.WriteHeader(http.StatusOK)
+	s.enc = json.NewEncoder(s.w)
+	s.enc.SetEscapeHTML(false)
+}
+
+func (s *resultStream) writeRow(row interface{}) error {
+	if s.enc == nil {
+		s.start()
+	}
+	if err := s.enc.Encode(row); err != nil {
+		return err
+	}
+	s.rows++
+	s.dirty = true
+	if s.flush <= 0 {
+		s.flushRows()
+	}
+	return nil
+}
+
+func (s *resultStream) flushRows() {
+	if !s.dirty {
+		return
+	}
+	s.dirty = false
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// fail reports an error to the client. It uses a regular error response if nothing was written yet.
+func (s *resultStream) fail(err error) {
+	s.err = err
+	if s.enc == nil {
+		if !cayleyhttp.WriteLimitError(s.w, err) {
+			s.errFunc(s.w, err)
+		}
+		return
+	}
+	s.enc.Encode(ErrorQueryWrapper{err.Error()})
+	s.dirty = true
+	s.flushRows()
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+)
+
+func TestV1QueryStream(t *testing.T) {
+	h := newTestHandle(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", ""),
+		quad.MakeIRI("fred", "follows", "alice", ""),
+	)
+	api := &API{config: &Config{}, handle: h}
+	params := httprouter.Params{{Key: "query_lang", Value: "gizmo"}}
+
+	run := func(path, accept, qu string) (*httptest.ResponseRecorder, []map[string]interface{}) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(qu))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		api.ServeV1Query(w, req, params)
+		var rows []map[string]interface{}
+		sc := bufio.NewScanner(w.Body)
+		for sc.Scan() {
+			var m map[string]interface{}
+			require.NoError(t, json.Unmarshal(sc.Bytes(), &m), "%q", sc.Text())
+			rows = append(rows, m)
+		}
+		return w, rows
+	}
+
+	const qu = `g.V().Has("<follows>").All()`
+	for _, c := range []struct{ path, accept string }{
+		{path: "/api/v1/query/gizmo?stream=true"},
+		{path: "/api/v1/query/gizmo", accept: mimeNDJSON},
+	} {
+		w, rows := run(c.path, c.accept, qu)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, mimeNDJSON, w.Header().Get("Content-Type"))
+		require.Len(t, rows, 3)
+		require.True(t, w.Flushed)
+		for _, r := range rows {
+			require.NotEmpty(t, r["id"])
+		}
+	}
+
+	// regular response is a single JSON object
+	w, rows := run("/api/v1/query/gizmo?stream=false", mimeNDJSON, qu)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, rows, 1)
+	require.Len(t, rows[0]["result"], 3)
+
+	// errors before the first row use a regular response
+	w, rows = run("/api/v1/query/gizmo?stream=true", "", `g.V(`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Len(t, rows, 1)
+	require.NotEmpty(t, rows[0]["error"])
+
+	// limit errors after the first row are sent as a last line
+	api.config.Limits = query.Limits{MaxResults: 2}
+	w, rows = run("/api/v1/query/gizmo?stream=true", "", qu)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, rows, 3)
+	require.NotEmpty(t, rows[2]["error"])
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/cayleygraph/cayley/internal/decompressor"
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/cayleygraph/cayley/quad/nquads"
+	"github.com/cayleygraph/cayley/server/http"
 )
 
 func ParseJSONToQuadList(jsonBody []byte) (out []quad.Quad, _ error) {
@@ -70,7 +71,7 @@ func readLimit(r io.Reader) ([]byte, error) {
 }
 
 func (api *API) ServeV1Write(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	if api.config.ReadOnly {
+	if api.conf().ReadOnly {
 		jsonResponse(w, 400, "Database is read-only.")
 		return
 	}
@@ -94,11 +95,12 @@ func (api *API) ServeV1Write(w http.ResponseWriter, r *http.Request, _ httproute
 		jsonResponse(w, 400, err)
 		return
 	}
+	cayleyhttp.GetRequestInfo(r).SetQuads(len(quads))
 	fmt.Fprintf(w, "{\"result\": \"Successfully wrote %d quads.\"}", len(quads))
 }
 
 func (api *API) ServeV1WriteNQuad(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	if api.config.ReadOnly {
+	if api.conf().ReadOnly {
 		jsonResponse(w, 400, "Database is read-only.")
 		return
 	}
@@ -136,11 +138,12 @@ func (api *API) ServeV1WriteNQuad(w http.ResponseWriter, r *http.Request, params
 		jsonResponse(w, 400, err)
 		return
 	}
+	cayleyhttp.GetRequestInfo(r).SetQuads(n)
 	fmt.Fprintf(w, "{\"result\": \"Successfully wrote %d quads.\"}", n)
 }
 
 func (api *API) ServeV1Delete(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	if api.config.ReadOnly {
+	if api.conf().ReadOnly {
 		jsonResponse(w, 400, "Database is read-only.")
 		return
 	}
@@ -166,5 +169,6 @@ func (api *API) ServeV1Delete(w http.ResponseWriter, r *http.Request, params htt
 			return
 		}
 	}
+	cayleyhttp.GetRequestInfo(r).SetQuads(len(quads))
 	fmt.Fprintf(w, "{\"result\": \"Successfully deleted %d quads.\"}", len(quads))
 }
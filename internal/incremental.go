@@ -0,0 +1,260 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad/pquads"
+	"github.com/cayleygraph/cayley/schema"
+	"github.com/cayleygraph/cayley/voc"
+)
+
+const (
+	// restoredHorizonKey is a metadata key for the horizon of the source database the backup was restored to.
+	restoredHorizonKey = "backup:horizon"
+
+	// incrementalBatch is a number of transactions read from the delta log at once.
+	incrementalBatch = 256
+
+	maxBackupDelta = 64 << 20
+)
+
+// ErrNoDeltaLog is returned by BackupIncremental if the database does not keep the delta log.
+var ErrNoDeltaLog = errors.New("incremental backups require a database with the delta log enabled")
+
+// BackupIncremental writes an archive with all transactions recorded in the delta log after a given horizon.
+// Usually, the horizon is taken from the metadata of the previous backup (see BackupInfo.Horizon).
+//
+// The database must implement graph.DeltaLog and the log must be enabled for the whole range of horizons.
+func BackupIncremental(ctx context.Context, w io.Writer, qs graph.QuadStore, backend string, since int64, opt BackupOptions) (*BackupInfo, error) {
+	if !graph.CapabilitiesOf(qs).DeltaLog {
+		return nil, ErrNoDeltaLog
+	}
+	cur, err := graph.Horizon(ctx, qs)
+	if err != nil {
+		return nil, err
+	} else if since > cur {
+		return nil, fmt.Errorf("horizon %d is ahead of the database horizon %d", since, cur)
+	}
+	info := &BackupInfo{
+		Backend: backend,
+		Format:  BackupFormatDeltas,
+		Created: time.Now().UTC(),
+		Quads:   qs.Size(),
+		Horizon: cur,
+		Since:   since,
+	}
+	var ns voc.Namespaces
+	if err = schema.LoadNamespaces(ctx, qs, &ns); err != nil {
+		return nil, err
+	}
+	info.Namespaces = ns.List()
+	err = writeBackup(w, info, opt, func(out io.Writer) error {
+		return dumpDeltas(ctx, out, qs, since, cur)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// dumpDeltas writes deltas of transactions in range (from, to] as a sequence of length-prefixed LogDelta messages.
+// The sequence is terminated by a zero length, so truncated archives can be detected.
+func dumpDeltas(ctx context.Context, w io.Writer, qs graph.QuadStore, from, to int64) error {
+	var buf [binary.MaxVarintLen64]byte
+	for from < to {
+		end := from + incrementalBatch
+		if end > to {
+			end = to
+		}
+		list, err := graph.LogEntries(ctx, qs, from, end)
+		if err == graph.ErrNotSupported {
+			return ErrNoDeltaLog
+		} else if err != nil {
+			return err
+		}
+		for _, e := range list {
+			for _, d := range e.Deltas {
+				ld := proto.LogDelta{
+					ID:        uint64(e.Horizon),
+					Quad:      pquads.MakeQuad(d.Quad),
+					Action:    int32(d.Action),
+					Timestamp: e.Timestamp.UnixNano(),
+				}
+				data, err := ld.Marshal()
+				if err != nil {
+					return err
+				}
+				n := binary.PutUvarint(buf[:], uint64(len(data)))
+				if _, err = w.Write(buf[:n]); err != nil {
+					return err
+				} else if _, err = w.Write(data); err != nil {
+					return err
+				}
+			}
+		}
+		from = end
+	}
+	n := binary.PutUvarint(buf[:], 0)
+	_, err := w.Write(buf[:n])
+	return err
+}
+
+// deltaReader reads transactions written by dumpDeltas.
+type deltaReader struct {
+	r    *bufio.Reader
+	next *proto.LogDelta
+	done bool
+}
+
+func newDeltaReader(r io.Reader) *deltaReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &deltaReader{r: br}
+}
+
+func (r *deltaReader) readDelta() (*proto.LogDelta, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	sz, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	} else if sz == 0 {
+		r.done = true
+		// read to the end, so compressed and encrypted streams are verified
+		if _, err = r.r.ReadByte(); err == nil {
+			return nil, errors.New("unexpected data after the end of the backup")
+		} else if err != io.EOF {
+			return nil, err
+		}
+		return nil, io.EOF
+	} else if sz > maxBackupDelta {
+		return nil, fmt.Errorf("delta is too large: %d", sz)
+	}
+	data := make([]byte, sz)
+	if _, err = io.ReadFull(r.r, data); err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	ld := new(proto.LogDelta)
+	if err = ld.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return ld, nil
+}
+
+// ReadEntry reads all deltas of the next transaction. It returns io.EOF if there are no more transactions.
+func (r *deltaReader) ReadEntry() (*graph.LogEntry, error) {
+	if r.next == nil {
+		ld, err := r.readDelta()
+		if err != nil {
+			return nil, err
+		}
+		r.next = ld
+	}
+	e := &graph.LogEntry{
+		Horizon:   int64(r.next.ID),
+		Timestamp: time.Unix(0, r.next.Timestamp),
+	}
+	for r.next != nil && int64(r.next.ID) == e.Horizon {
+		e.Deltas = append(e.Deltas, graph.Delta{
+			Quad:   r.next.Quad.ToNative(),
+			Action: graph.Procedure(r.next.Action),
+		})
+		ld, err := r.readDelta()
+		if err == io.EOF {
+			ld = nil
+		} else if err != nil {
+			return nil, err
+		}
+		r.next = ld
+	}
+	return e, nil
+}
+
+// restoredHorizon returns the horizon of the source database that was restored from backups.
+func restoredHorizon(ctx context.Context, qs graph.QuadStore) (int64, bool, error) {
+	data, err := graph.GetMetadata(ctx, qs, restoredHorizonKey)
+	if err != nil || data == nil {
+		return 0, false, err
+	}
+	if len(data) != 8 {
+		return 0, false, errors.New("invalid record of the restored horizon")
+	}
+	return int64(binary.BigEndian.Uint64(data)), true, nil
+}
+
+// setRestoredHorizon records the horizon of the source database that was restored from backups.
+// It is a no-op if the database does not support metadata records.
+func setRestoredHorizon(ctx context.Context, qs graph.QuadStore, h int64) error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(h))
+	err := graph.SetMetadata(ctx, qs, restoredHorizonKey, buf[:])
+	if err == graph.ErrNotSupported {
+		return nil
+	}
+	return err
+}
+
+// applyIncremental replays transactions from an incremental backup with horizons up to a given one.
+// Transactions that were already restored are skipped.
+func applyIncremental(ctx context.Context, r io.Reader, qs graph.QuadStore, info *BackupInfo, until int64) error {
+	base, ok, err := restoredHorizon(ctx, qs)
+	if err == graph.ErrNotSupported {
+		// cannot check the order of backups; replay all transactions
+		base, ok, err = info.Since, true, nil
+	}
+	if err != nil {
+		return err
+	} else if !ok {
+		return errors.New("incremental backup can only be applied to a database restored from a backup")
+	} else if info.Since > base {
+		return fmt.Errorf("incremental backup starts at horizon %d, but the database is restored to %d; previous backup is missing", info.Since, base)
+	}
+	dr := newDeltaReader(r)
+	for {
+		e, err := dr.ReadEntry()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if e.Horizon <= base {
+			continue
+		} else if until > 0 && e.Horizon > until {
+			break
+		}
+		// transactions are applied idempotently, since the full backup might already include some of them
+		err = qs.ApplyDeltas(e.Deltas, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true})
+		if err != nil {
+			return err
+		}
+		if err = setRestoredHorizon(ctx, qs, e.Horizon); err != nil {
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+	}
+	h := info.Horizon
+	if until > 0 && until < h {
+		h = until
+	}
+	if h <= base {
+		return nil
+	}
+	return setRestoredHorizon(ctx, qs, h)
+}
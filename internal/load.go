@@ -1,9 +1,9 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -42,12 +42,24 @@ type nopCloser struct {
 func (r nopCloser) Close() error { return nil }
 
 func QuadReaderFor(path, typ string) (quad.ReadCloser, error) {
-	var (
-		r io.Reader
-		c io.Closer
-	)
+	return ParallelQuadReaderFor(path, typ, 1)
+}
+
+// ParallelQuadReaderFor is the same as QuadReaderFor, but parses line-based formats with a given number of workers.
+// Order of quads is preserved.
+func ParallelQuadReaderFor(path, typ string, workers int) (quad.ReadCloser, error) {
+	r, c, _, err := openQuadSource(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	return newQuadReader(r, c, path, typ, workers)
+}
+
+// openQuadSource opens a file, stdin or a remote resource for reading.
+// It also returns the size of the source, or -1 if it is unknown.
+func openQuadSource(ctx context.Context, path string) (io.Reader, io.Closer, int64, error) {
 	if path == "-" {
-		r = os.Stdin
+		return os.Stdin, nil, -1, nil
 	} else if u, err := url.Parse(path); err != nil || u.Scheme == "file" || u.Scheme == "" {
 		// Don't alter relative URL path or non-URL path parameter.
 		if u.Scheme != "" && err == nil {
@@ -56,20 +68,26 @@ func QuadReaderFor(path, typ string) (quad.ReadCloser, error) {
 		}
 		f, err := os.Open(path)
 		if os.IsNotExist(err) {
-			return nil, err
+			return nil, nil, 0, err
 		} else if err != nil {
-			return nil, fmt.Errorf("could not open file %q: %v", path, err)
+			return nil, nil, 0, fmt.Errorf("could not open file %q: %v", path, err)
+		}
+		size := int64(-1)
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			size = fi.Size()
 		}
-		r, c = f, f
+		return f, f, size, nil
 	} else {
-		res, err := http.Get(path)
+		rc, size, err := OpenRemote(ctx, path)
 		if err != nil {
-			return nil, fmt.Errorf("could not get resource <%s>: %v", u, err)
+			return nil, nil, 0, err
 		}
 		// TODO(dennwc): save content type for format auto-detection
-		r, c = res.Body, res.Body
+		return rc, rc, size, nil
 	}
+}
 
+func newQuadReader(r io.Reader, c io.Closer, path, typ string, workers int) (quad.ReadCloser, error) {
 	r, err := decompressor.New(r)
 	if err != nil {
 		if c != nil {
@@ -84,13 +102,25 @@ func QuadReaderFor(path, typ string) (quad.ReadCloser, error) {
 	var qr quad.ReadCloser
 	switch typ {
 	case "cquad", "nquad": // legacy
-		qr = nquads.NewReader(r, false)
+		if workers > 1 {
+			qr = nquads.NewParallelReader(r, false, workers)
+		} else {
+			qr = nquads.NewReader(r, false)
+		}
 	default:
 		var format *quad.Format
 		if typ == "" {
-			name := filepath.Base(path)
+			name := path
+			if IsRemoteSource(path) {
+				// ignore query parameters of URLs
+				if u, err := url.Parse(path); err == nil {
+					name = u.Path
+				}
+			}
+			name = filepath.Base(name)
 			name = strings.TrimSuffix(name, ".gz")
 			name = strings.TrimSuffix(name, ".bz2")
+			name = strings.TrimSuffix(name, ".zst")
 			format = quad.FormatByExt(filepath.Ext(name))
 			if format == nil {
 				typ = "nquads"
@@ -110,7 +140,11 @@ func QuadReaderFor(path, typ string) (quad.ReadCloser, error) {
 			}
 			return nil, err
 		}
-		qr = format.Reader(r)
+		if workers > 1 && format.Name == "nquads" {
+			qr = nquads.NewParallelReader(r, nquads.DecodeRaw, workers)
+		} else {
+			qr = format.Reader(r)
+		}
 	}
 	if c != nil {
 		return readCloser{ReadCloser: qr, close: c.Close}, nil
@@ -68,6 +68,14 @@ func (lru *Cache) Del(key string) {
 	lru.priority.Remove(e)
 }
 
+// Purge removes all entries from the cache.
+func (lru *Cache) Purge() {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	lru.cache = make(map[string]*list.Element)
+	lru.priority.Init()
+}
+
 func (lru *Cache) Get(key string) (interface{}, bool) {
 	lru.mu.Lock()
 	defer lru.mu.Unlock()
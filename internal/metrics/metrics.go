@@ -0,0 +1,137 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics exports Prometheus metrics of the query and write paths and traces queries with OpenTelemetry.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/query"
+)
+
+const namespace = "cayley"
+
+// Options configures collected metrics.
+type Options struct {
+	// Iterators enables counting of Next and Contains calls to iterators.
+	// It requires profiling of each iterator tree, which adds an overhead to each call.
+	Iterators bool
+}
+
+// Metrics is a set of Prometheus collectors for query and write paths. It is safe for concurrent use.
+type Metrics struct {
+	reg *prometheus.Registry
+	qm  *query.Metrics
+
+	queryDuration *prometheus.HistogramVec
+	iteratorCalls *prometheus.CounterVec
+	backendCalls  *prometheus.CounterVec
+	writeBatch    prometheus.Histogram
+	writeDeltas   *prometheus.CounterVec
+}
+
+// New creates a set of metrics and registers them in a new registry, together with process and Go runtime metrics.
+func New(opt Options) *Metrics {
+	m := &Metrics{
+		reg: prometheus.NewRegistry(),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "query_duration_seconds",
+			Help:      "Duration of queries by language and error class.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"lang", "status"}),
+		iteratorCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "iterator_calls_total",
+			Help:      "Number of Next and Contains calls to iterators.",
+		}, []string{"op"}),
+		backendCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "backend_calls_total",
+			Help:      "Number of calls to the quad store made by queries, by method.",
+		}, []string{"op"}),
+		writeBatch: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "write_batch_size",
+			Help:      "Number of deltas in applied write batches.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		}),
+		writeDeltas: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "write_deltas_total",
+			Help:      "Number of applied deltas by action.",
+		}, []string{"action"}),
+	}
+	m.reg.MustRegister(
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		prometheus.NewGoCollector(),
+		m.queryDuration, m.iteratorCalls, m.backendCalls,
+		m.writeBatch, m.writeDeltas,
+	)
+	m.qm = &query.Metrics{
+		Backend: func(op string) {
+			m.backendCalls.WithLabelValues(op).Inc()
+		},
+	}
+	if opt.Iterators {
+		next, contains := m.iteratorCalls.WithLabelValues("next"), m.iteratorCalls.WithLabelValues("contains")
+		m.qm.Iterators = func(n, c int64) {
+			next.Add(float64(n))
+			contains.Add(float64(c))
+		}
+	}
+	return m
+}
+
+// Handler returns an HTTP handler that serves metrics in Prometheus format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{})
+}
+
+// Context enables collection of query metrics for a given context (see query.WithMetrics).
+func (m *Metrics) Context(ctx context.Context) context.Context {
+	return query.WithMetrics(ctx, m.qm)
+}
+
+// ObserveQuery records a duration of the query. Status is an error class of the query, or "ok".
+func (m *Metrics) ObserveQuery(lang, status string, dt time.Duration) {
+	m.queryDuration.WithLabelValues(lang, status).Observe(dt.Seconds())
+}
+
+// ObserveWrite records a batch of applied deltas. It can be used as graph.AfterCommitHook.
+func (m *Metrics) ObserveWrite(deltas []graph.Delta) {
+	m.writeBatch.Observe(float64(len(deltas)))
+	var add, del int
+	for _, d := range deltas {
+		switch d.Action {
+		case graph.Add:
+			add++
+		case graph.Delete:
+			del++
+		}
+	}
+	if add != 0 {
+		m.writeDeltas.WithLabelValues("add").Add(float64(add))
+	}
+	if del != 0 {
+		m.writeDeltas.WithLabelValues("delete").Add(float64(del))
+	}
+}
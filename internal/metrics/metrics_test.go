@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New(Options{Iterators: true})
+	m.ObserveQuery("gizmo", "ok", 5*time.Millisecond)
+	m.ObserveWrite([]graph.Delta{
+		{Quad: quad.MakeIRI("a", "b", "c", ""), Action: graph.Add},
+		{Quad: quad.MakeIRI("a", "b", "d", ""), Action: graph.Delete},
+	})
+	qm := query.MetricsFrom(m.Context(context.Background()))
+	require.NotNil(t, qm)
+	qm.Backend("name_of")
+	qm.Iterators(10, 3)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	data, err := ioutil.ReadAll(w.Body)
+	require.NoError(t, err)
+	out := string(data)
+	require.Contains(t, out, `cayley_query_duration_seconds_count{lang="gizmo",status="ok"} 1`)
+	require.Contains(t, out, `cayley_write_batch_size_sum 2`)
+	require.Contains(t, out, `cayley_write_deltas_total{action="delete"} 1`)
+	require.Contains(t, out, `cayley_backend_calls_total{op="name_of"} 1`)
+	require.Contains(t, out, `cayley_iterator_calls_total{op="next"} 10`)
+}
@@ -0,0 +1,25 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+// TraceOptions configures export of query traces.
+type TraceOptions struct {
+	// Endpoint is an address of the OpenTelemetry collector accepting OTLP over gRPC, for example "localhost:4317".
+	Endpoint string
+	// Insecure disables TLS for connections to the collector.
+	Insecure bool
+	// Sample is a fraction of traced requests. Sample rate outside of (0, 1) range means that all requests are traced.
+	Sample float64
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !otel
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+// Tracer exports spans of queries to OpenTelemetry collector. It implements graph.Tracer.
+//
+// OpenTelemetry exporter is only included in binaries built with "otel" build tag.
+type Tracer struct{}
+
+var _ graph.Tracer = (*Tracer)(nil)
+
+// NewTracer creates a tracer that exports spans with a given options.
+// It always fails, since the binary was built without "otel" build tag.
+func NewTracer(opt TraceOptions) (*Tracer, error) {
+	return nil, errors.New("tracing is not supported by this build, rebuild with \"otel\" build tag")
+}
+
+// StartSpan implements graph.Tracer.
+func (t *Tracer) StartSpan(ctx context.Context, name string) (context.Context, func(err error)) {
+	return ctx, func(err error) {}
+}
+
+// Context returns a context of the request with the tracer set.
+func (t *Tracer) Context(r *http.Request) context.Context {
+	return graph.WithTracer(r.Context(), t)
+}
+
+// Close flushes pending spans and stops the exporter.
+func (t *Tracer) Close() error {
+	return nil
+}
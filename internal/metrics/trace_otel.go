@@ -0,0 +1,96 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build otel
+
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+// Tracer exports spans of queries to OpenTelemetry collector. It implements graph.Tracer.
+type Tracer struct {
+	tp   *sdktrace.TracerProvider
+	tr   trace.Tracer
+	prop propagation.TextMapPropagator
+}
+
+var _ graph.Tracer = (*Tracer)(nil)
+
+// NewTracer creates a tracer that exports spans with a given options.
+// Spans are exported in batches in the background; Close must be called to flush them.
+func NewTracer(opt TraceOptions) (*Tracer, error) {
+	eopts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opt.Endpoint)}
+	if opt.Insecure {
+		eopts = append(eopts, otlptracegrpc.WithInsecure())
+	}
+	exp, err := otlptracegrpc.New(context.Background(), eopts...)
+	if err != nil {
+		return nil, err
+	}
+	sample := opt.Sample
+	if sample <= 0 || sample > 1 {
+		sample = 1
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		// follow the decision of the caller, if the request is a part of a trace
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sample))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "cayley"))),
+	)
+	return &Tracer{
+		tp:   tp,
+		tr:   tp.Tracer("github.com/cayleygraph/cayley"),
+		prop: propagation.TraceContext{},
+	}, nil
+}
+
+// StartSpan implements graph.Tracer.
+func (t *Tracer) StartSpan(ctx context.Context, name string) (context.Context, func(err error)) {
+	ctx, span := t.tr.Start(ctx, name)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Context returns a context of the request with the tracer set. If the request carries a trace context
+// of the caller (W3C Trace Context headers), spans of the request are recorded as a part of the caller's trace.
+func (t *Tracer) Context(r *http.Request) context.Context {
+	ctx := t.prop.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return graph.WithTracer(ctx, t)
+}
+
+// Close flushes pending spans and stops the exporter.
+func (t *Tracer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return t.tp.Shutdown(ctx)
+}
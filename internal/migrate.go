@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"context"
+	"io"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+// MigrateOptions controls checkpointing and progress reporting of Migrate.
+type MigrateOptions struct {
+	Batch int // number of quads in a batch; quad.DefaultBatch is used if not set
+	// Resume continues copying from the checkpoint left by an interrupted migration from the same source.
+	Resume bool
+	// Progress receives a progress bar. Nothing is printed if not set.
+	Progress io.Writer
+}
+
+// Migrate copies all quads from one database to another, recording a checkpoint in the destination after each batch.
+//
+// Source name identifies the source database in the checkpoint, thus it should include both the backend name and the address.
+// The source should not be modified during the migration, otherwise resuming may skip or duplicate some quads.
+// Identifiers of nodes and quads, including the horizon, are assigned by the destination backend.
+func Migrate(ctx context.Context, from graph.QuadStore, source string, to graph.QuadStore, qw graph.QuadWriter, opt MigrateOptions) error {
+	job := &copyJob{
+		key: migrateCheckpointKey, src: source,
+		batch: opt.Batch, progress: opt.Progress,
+		size: from.Size(),
+	}
+	if err := job.prepare(ctx, to, opt.Resume); err != nil {
+		return err
+	}
+	qr := graph.NewQuadStoreReader(from)
+	defer qr.Close()
+	return job.run(ctx, to, qw, qr)
+}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+)
+
+func TestMigrateResume(t *testing.T) {
+	ctx := context.TODO()
+	quads := testQuads(10)
+	from := memstore.New(quads...)
+
+	to, qw := newKVStore(t)
+	defer to.Close()
+
+	opt := MigrateOptions{Batch: 4}
+	err := Migrate(ctx, from, "memstore:", to, &crashingWriter{QuadWriter: qw, batches: 1}, opt)
+	require.NotNil(t, err)
+	require.Equal(t, int64(4), to.Size())
+
+	cp, err := getCheckpoint(ctx, to, migrateCheckpointKey)
+	require.NoError(t, err)
+	require.NotNil(t, cp)
+	require.Equal(t, int64(4), cp.Quads)
+
+	opt.Resume = true
+	err = Migrate(ctx, from, "memstore:other", to, qw, opt)
+	require.NotNil(t, err, "resumed from a different source")
+
+	err = Migrate(ctx, from, "memstore:", to, qw, opt)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(quads)), to.Size())
+
+	cp, err = getCheckpoint(ctx, to, migrateCheckpointKey)
+	require.NoError(t, err)
+	require.Nil(t, cp)
+
+	got := readAll(t, graph.NewQuadStoreReader(to))
+	require.Len(t, got, len(quads))
+}
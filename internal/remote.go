@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// maxRemoteRetries is a number of attempts to continue a download after a connection error.
+const maxRemoteRetries = 5
+
+// IsRemoteSource checks if the path is an URL of a remote source supported by OpenRemote.
+func IsRemoteSource(path string) bool {
+	u, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "s3", "gs":
+		return true
+	}
+	return false
+}
+
+// RemoteQuadReader opens a quad reader for a remote source. See OpenRemote for supported URLs.
+func RemoteQuadReader(ctx context.Context, path, typ string) (quad.ReadCloser, error) {
+	if !IsRemoteSource(path) {
+		return nil, fmt.Errorf("not a remote source: %q", path)
+	}
+	rc, _, err := OpenRemote(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return newQuadReader(rc, rc, path, typ, 1)
+}
+
+// OpenRemote starts a download of a remote file. It also returns the size of the file, or -1 if it is unknown.
+//
+// Supported URLs are:
+//
+//	http:// and https:// URLs
+//	s3://bucket/key for Amazon S3 or compatible storage
+//	gs://bucket/object for Google Cloud Storage
+//
+// Requests to S3 are signed if AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables are set.
+// The region is set by AWS_REGION (us-east-1 by default), and S3-compatible storage can be used by setting
+// AWS_ENDPOINT_URL. Requests to GCS are authorized with a token from GOOGLE_OAUTH_ACCESS_TOKEN, if it is set.
+//
+// If the connection breaks, the download continues from the last received byte by requesting a range of the file.
+func OpenRemote(ctx context.Context, path string) (io.ReadCloser, int64, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, -1, err
+	}
+	var newReq func() (*http.Request, error)
+	switch u.Scheme {
+	case "http", "https":
+		newReq = func() (*http.Request, error) {
+			return http.NewRequest("GET", u.String(), nil)
+		}
+	case "s3":
+		newReq = s3Request(u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "gs":
+		token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+		obj := &url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + u.Host + u.Path}
+		newReq = func() (*http.Request, error) {
+			req, err := http.NewRequest("GET", obj.String(), nil)
+			if err == nil && token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			return req, err
+		}
+	default:
+		return nil, -1, fmt.Errorf("unsupported URL scheme: %q", u.Scheme)
+	}
+	r := &remoteReader{ctx: ctx, url: path, newReq: newReq}
+	if err = r.open(); err != nil {
+		return nil, -1, err
+	}
+	return r, r.size, nil
+}
+
+// remoteReader reads a remote file and requests the rest of it if the connection breaks.
+type remoteReader struct {
+	ctx    context.Context
+	url    string
+	newReq func() (*http.Request, error)
+
+	body    io.ReadCloser
+	size    int64
+	off     int64
+	etag    string
+	retries int
+}
+
+// open sends a request for the file, or for the rest of it, if some data was already received.
+func (r *remoteReader) open() error {
+	req, err := r.newReq()
+	if err != nil {
+		return err
+	}
+	if r.off > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.off))
+		if r.etag != "" {
+			// do not mix parts of different versions of the file
+			req.Header.Set("If-Range", r.etag)
+		}
+	}
+	if s, ok := req.Header["X-Amz-Date"]; ok && len(s) != 0 {
+		// range must be included in the signature
+		if err = signS3(req); err != nil {
+			return err
+		}
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(r.ctx))
+	if err != nil {
+		return fmt.Errorf("could not get resource <%s>: %v", r.url, err)
+	}
+	switch {
+	case r.off == 0 && resp.StatusCode == http.StatusOK:
+		r.size = resp.ContentLength
+		r.etag = resp.Header.Get("ETag")
+	case r.off > 0 && resp.StatusCode == http.StatusPartialContent:
+	case r.off > 0 && resp.StatusCode == http.StatusOK:
+		resp.Body.Close()
+		return fmt.Errorf("could not resume download of <%s>: range requests are not supported or the file was changed", r.url)
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("could not get resource <%s>: %s", r.url, resp.Status)
+	}
+	r.body = resp.Body
+	return nil
+}
+
+func (r *remoteReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.body.Read(p)
+		r.off += int64(n)
+		if err == nil || err == io.EOF {
+			return n, err
+		} else if r.retries >= maxRemoteRetries || r.ctx.Err() != nil {
+			return n, err
+		}
+		r.retries++
+		clog.Warningf("download of <%s> failed at %d bytes, resuming: %v", r.url, r.off, err)
+		r.body.Close()
+		if oerr := r.open(); oerr != nil {
+			return n, oerr
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *remoteReader) Close() error {
+	return r.body.Close()
+}
+
+const (
+	s3Algorithm = "AWS4-HMAC-SHA256"
+	emptyHash   = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// s3Request returns a function that creates requests for an object in S3.
+// Requests are signed in open, after setting all headers.
+func s3Request(bucket, key string) func() (*http.Request, error) {
+	var u *url.URL
+	if ep := os.Getenv("AWS_ENDPOINT_URL"); ep != "" {
+		u, _ = url.Parse(strings.TrimSuffix(ep, "/") + "/" + bucket + "/" + key)
+	} else {
+		u = &url.URL{Scheme: "https", Host: bucket + ".s3." + awsRegion() + ".amazonaws.com", Path: "/" + key}
+	}
+	return func() (*http.Request, error) {
+		if u == nil {
+			return nil, errors.New("invalid AWS_ENDPOINT_URL")
+		}
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+			req.Header.Set("X-Amz-Date", time.Now().UTC().Format("20060102T150405Z"))
+		}
+		return req, nil
+	}
+}
+
+func awsRegion() string {
+	if r := os.Getenv("AWS_REGION"); r != "" {
+		return r
+	}
+	return "us-east-1"
+}
+
+// signS3 signs a GET request using AWS Signature Version 4 with credentials from environment variables.
+func signS3(req *http.Request) error {
+	return signS3With(req, os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		os.Getenv("AWS_SESSION_TOKEN"), awsRegion())
+}
+
+func signS3With(req *http.Request, keyID, secret, token, region string) error {
+	date := req.Header.Get("X-Amz-Date")
+	if len(date) < 8 {
+		return errors.New("invalid request date")
+	}
+	req.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	if token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if k == "range" || strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canon strings.Builder
+	canon.WriteString(req.Method + "\n")
+	canon.WriteString(awsURIEncode(req.URL.Path) + "\n")
+	canon.WriteString(req.URL.Query().Encode() + "\n")
+	for _, k := range names {
+		canon.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canon.WriteString("\n" + signed + "\n" + emptyHash)
+
+	scope := date[:8] + "/" + region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canon.String()))
+	toSign := s3Algorithm + "\n" + date + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + secret)
+	for _, s := range []string{date[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", s3Algorithm+" Credential="+keyID+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+sig)
+	return nil
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// awsURIEncode escapes the path as required by AWS: all characters except unreserved ones and '/' are escaped.
+func awsURIEncode(path string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
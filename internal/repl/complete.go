@@ -0,0 +1,153 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repl
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/schema"
+	"github.com/cayleygraph/cayley/voc"
+)
+
+const (
+	// completionScanLimit is a maximal number of quads scanned to collect predicates and IRIs for completion.
+	completionScanLimit = 100000
+	// maxCompletions is a maximal number of completions suggested at once.
+	maxCompletions = 100
+)
+
+// completionDelims separate words that can be completed.
+const completionDelims = " \t\n(),[]{}\"'`"
+
+// completer suggests REPL commands, predicates and IRIs stored in the graph.
+//
+// IRIs are suggested in both full and short forms, using namespaces registered globally and stored in the graph.
+// Candidates are collected on the first completion by scanning first completionScanLimit quads.
+type completer struct {
+	ctx      context.Context
+	qs       graph.QuadStore
+	commands []string
+
+	once  sync.Once
+	words []string
+}
+
+func newCompleter(ctx context.Context, qs graph.QuadStore, commands []string) *completer {
+	return &completer{ctx: ctx, qs: qs, commands: commands}
+}
+
+// Complete implements liner.WordCompleter.
+func (c *completer) Complete(line string, pos int) (head string, completions []string, tail string) {
+	if pos > len(line) {
+		pos = len(line)
+	}
+	start := strings.LastIndexAny(line[:pos], completionDelims) + 1
+	head, word, tail := line[:start], line[start:pos], line[pos:]
+	if word == "" {
+		return head, nil, tail
+	}
+	words := c.commands
+	if strings.TrimSpace(head) != "" || (word[0] != ':' && word[0] != '\\') {
+		c.once.Do(c.load)
+		words = c.words
+	}
+	i := sort.SearchStrings(words, word)
+	for ; i < len(words) && len(completions) < maxCompletions; i++ {
+		if !strings.HasPrefix(words[i], word) {
+			break
+		} else if words[i] == word {
+			continue
+		}
+		completions = append(completions, words[i])
+	}
+	return head, completions, tail
+}
+
+// load collects predicates and IRIs for completion.
+func (c *completer) load() {
+	ns := voc.Clone()
+	_ = schema.LoadNamespaces(c.ctx, c.qs, ns)
+
+	seen := make(map[string]struct{})
+	add := func(v quad.Value) {
+		iri, ok := v.(quad.IRI)
+		if !ok {
+			if v != nil {
+				seen[v.String()] = struct{}{}
+			}
+			return
+		}
+		seen[iri.String()] = struct{}{}
+		if short := iri.ShortWith(ns); short != iri {
+			seen[short.String()] = struct{}{}
+		}
+	}
+	for _, n := range ns.List() {
+		seen["<"+n.Prefix] = struct{}{}
+	}
+	it := c.qs.QuadsAllIterator()
+	defer it.Close()
+	for i := 0; i < completionScanLimit && it.Next(c.ctx); i++ {
+		q := c.qs.Quad(it.Result())
+		add(q.Predicate)
+		for _, v := range []quad.Value{q.Subject, q.Object} {
+			if _, ok := v.(quad.IRI); ok {
+				add(v)
+			}
+		}
+	}
+	words := make([]string, 0, len(seen))
+	for w := range seen {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	c.words = words
+}
+
+// unbalanced checks if the code has unclosed brackets or string literals, thus more input is required.
+func unbalanced(code string) bool {
+	var (
+		depth   int
+		quote   rune
+		escaped bool
+	)
+	for _, r := range code {
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch r {
+		case '"', '\'', '`':
+			quote = r
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+		}
+	}
+	return depth > 0 || quote != 0
+}
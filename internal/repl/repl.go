@@ -18,10 +18,12 @@ package repl
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -45,10 +47,14 @@ func un(s string, startTime time.Time) {
 }
 
 func Run(ctx context.Context, qu string, ses query.REPLSession) error {
+	return run(ctx, qu, ses, true)
+}
+
+func run(ctx context.Context, qu string, ses query.REPLSession, timing bool) error {
 	nResults := 0
 	startTrace, startTime := trace("Elapsed time: %g ms\n\n")
 	defer func() {
-		if nResults > 0 {
+		if nResults > 0 && timing {
 			un(startTrace, startTime)
 		}
 	}()
@@ -81,21 +87,116 @@ const (
 	history = ".cayley_history"
 )
 
+const help = `Help
+	exit // Exit
+	help // this help
+	:d <quad> // delete quad
+	:a <quad> // add quad
+	:debug [t|f]
+	\lang [name] // switch query language or print the current one
+	\explain // print the shape of the last query
+	\timing [on|off] // toggle printing of query execution time
+Press Ctrl-C to discard multiline input.
+`
+
+// replCommands are suggested by tab completion at the start of the line.
+var replCommands = []string{":a", ":d", ":debug", "\\explain", "\\lang", "\\timing"}
+
+// session is a state of the REPL.
+type session struct {
+	qs     graph.QuadStore
+	lang   string
+	ses    query.REPLSession
+	timing bool
+	last   string // last executed query
+}
+
+func (s *session) setLanguage(name string) error {
+	l := query.GetLanguage(name)
+	if l == nil || l.REPL == nil {
+		return fmt.Errorf("unsupported query language: %q", name)
+	}
+	s.lang, s.ses = name, l.REPL(s.qs)
+	return nil
+}
+
+// explain prints the shape of the last query, if the language supports it.
+func (s *session) explain() error {
+	if s.last == "" {
+		return fmt.Errorf("no query was executed yet")
+	}
+	l := query.GetLanguage(s.lang)
+	if l.HTTP == nil {
+		return fmt.Errorf("explain is not supported for %s", s.lang)
+	}
+	shape, err := l.HTTP(s.qs).ShapeOf(s.last)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(shape, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// backslashCommand runs one of \-commands.
+func (s *session) backslashCommand(cmd, args string) error {
+	args = strings.TrimSpace(args)
+	switch cmd {
+	case "\\lang":
+		if args == "" {
+			fmt.Printf("Query language: %s (available: %s)\n", s.lang, strings.Join(query.Languages(), ", "))
+			return nil
+		}
+		if err := s.setLanguage(args); err != nil {
+			return err
+		}
+		s.last = ""
+		fmt.Printf("Query language set to %s\n", args)
+	case "\\explain":
+		return s.explain()
+	case "\\timing":
+		switch args {
+		case "":
+			s.timing = !s.timing
+		case "on":
+			s.timing = true
+		case "off":
+			s.timing = false
+		default:
+			return fmt.Errorf("expected 'on' or 'off', got %q", args)
+		}
+		if s.timing {
+			fmt.Println("Timing is on")
+		} else {
+			fmt.Println("Timing is off")
+		}
+	default:
+		return fmt.Errorf("unknown command: %q", cmd)
+	}
+	return nil
+}
+
 func Repl(ctx context.Context, h *graph.Handle, queryLanguage string, timeout time.Duration) error {
 	if queryLanguage == "" {
 		queryLanguage = defaultLanguage
 	}
-	l := query.GetLanguage(queryLanguage)
-	if l == nil || l.REPL == nil {
-		return fmt.Errorf("unsupported query language: %q", queryLanguage)
+	s := &session{qs: h.QuadStore, timing: true}
+	if err := s.setLanguage(queryLanguage); err != nil {
+		return err
 	}
-	ses := l.REPL(h.QuadStore)
 
-	term, err := terminal(history)
+	hist := historyPath()
+	term, err := terminal(hist)
 	if os.IsNotExist(err) {
-		fmt.Printf("creating new history file: %q\n", history)
+		fmt.Printf("creating new history file: %q\n", hist)
 	}
-	defer persist(term, history)
+	defer persist(term, hist)
+	term.SetCtrlCAborts(true)
+	term.SetMultiLineMode(true)
+	term.SetWordCompleter(newCompleter(ctx, h.QuadStore, replCommands).Complete)
 
 	var (
 		prompt = ps1
@@ -120,7 +221,10 @@ func Repl(ctx context.Context, h *graph.Handle, queryLanguage string, timeout ti
 			prompt = ps2
 		}
 		line, err := term.Prompt(prompt)
-		if err != nil {
+		if err == liner.ErrPromptAborted {
+			code = ""
+			continue
+		} else if err != nil {
 			if err == io.EOF {
 				fmt.Println()
 				return nil
@@ -185,11 +289,11 @@ func Repl(ctx context.Context, h *graph.Handle, queryLanguage string, timeout ti
 				}
 				continue
 
-			case "help":
-				fmt.Printf("Help\n\texit // Exit\n\thelp // this help\n\td: <quad> // delete quad\n\ta: <quad> // add quad\n\t:debug [t|f]\n")
+			case "help", "\\help", "\\?":
+				fmt.Print(help)
 				continue
 
-			case "exit":
+			case "exit", "\\q":
 				term.Close()
 				os.Exit(0)
 
@@ -197,21 +301,35 @@ func Repl(ctx context.Context, h *graph.Handle, queryLanguage string, timeout ti
 				if cmd[0] == ':' {
 					fmt.Printf("Unknown command: %q\n", cmd)
 					continue
+				} else if cmd[0] == '\\' {
+					if err := s.backslashCommand(cmd, args); err != nil {
+						fmt.Println("Error: ", err)
+					}
+					continue
 				}
 			}
 		}
 
+		if code != "" {
+			code += "\n"
+		}
 		code += line
+		if unbalanced(code) {
+			// collect more input
+			continue
+		}
 
 		nctx, cancel := newCtx()
-		err = Run(nctx, code, ses)
+		err = run(nctx, code, s.ses, s.timing)
 		cancel()
 		if err == query.ErrParseMore {
 			// collect more input
 		} else if err != nil {
 			fmt.Println("Error: ", err)
+			s.last = code
 			code = ""
 		} else {
+			s.last = code
 			code = ""
 		}
 	}
@@ -245,7 +363,7 @@ func terminal(path string) (*liner.State, error) {
 		signal.Notify(c, os.Interrupt, os.Kill)
 		<-c
 
-		err := persist(term, history)
+		err := persist(term, path)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to properly clean up terminal: %v\n", err)
 			os.Exit(1)
@@ -263,8 +381,21 @@ func terminal(path string) (*liner.State, error) {
 	return term, err
 }
 
+// historyPath returns a path of the history file in the home directory of the user,
+// or in the working directory if the home directory is not known.
+func historyPath() string {
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, history)
+	} else if home = os.Getenv("USERPROFILE"); home != "" {
+		return filepath.Join(home, history)
+	}
+	return history
+}
+
+// persist writes the history to the file and closes the terminal. The file is rewritten, since
+// the history read on start is written back together with new entries.
 func persist(term *liner.State, path string) error {
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0666)
 	if err != nil {
 		return fmt.Errorf("could not open %q to append history: %v", path, err)
 	}
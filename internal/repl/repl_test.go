@@ -15,7 +15,13 @@
 package repl
 
 import (
+	"context"
 	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
 )
 
 var testSplitLines = []struct {
@@ -64,3 +70,40 @@ func TestSplitLines(t *testing.T) {
 		}
 	}
 }
+
+func TestUnbalanced(t *testing.T) {
+	for _, c := range []struct {
+		code string
+		more bool
+	}{
+		{code: `g.V("<alice>").All()`},
+		{code: `g.V("<alice>").Out(`, more: true},
+		{code: "g.V().ForEach(function(d) {\n  g.Emit(d)", more: true},
+		{code: "g.V().ForEach(function(d) {\n  g.Emit(d)\n})"},
+		{code: `g.V("(").All()`},
+		{code: `g.V("\"(`, more: true},
+		{code: `g.V().All())`},
+	} {
+		require.Equal(t, c.more, unbalanced(c.code), "%q", c.code)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	qs := memstore.New(
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", ""),
+		quad.Make(quad.IRI("bob"), quad.IRI("http://schema.org/name"), "Bob", nil),
+	)
+	c := newCompleter(context.TODO(), qs, replCommands)
+
+	head, out, tail := c.Complete(`g.V("<alice>").Out("<fol").All()`, 24)
+	require.Equal(t, `g.V("<alice>").Out("`, head)
+	require.Equal(t, []string{"<follows>"}, out)
+	require.Equal(t, `").All()`, tail)
+
+	_, out, _ = c.Complete(`g.V().Out("<schema:`, 19)
+	require.Equal(t, []string{"<schema:name>"}, out)
+
+	_, out, _ = c.Complete(`\ti`, 3)
+	require.Equal(t, []string{"\\timing"}, out)
+}
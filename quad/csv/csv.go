@@ -0,0 +1,324 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package csv implements reading of quads from CSV and TSV files using a column-to-quad mapping.
+//
+// Each row of a file is converted to a set of quads with the same subject. The first row must contain
+// column names, which are referenced by the mapping.
+package csv
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/rdf"
+)
+
+// DefaultMapping is used by readers created for registered "csv" and "tsv" formats.
+// If not set, each column is mapped to a string value with a predicate named after the column.
+var DefaultMapping *Mapping
+
+func init() {
+	quad.RegisterFormat(quad.Format{
+		Name: "csv",
+		Ext:  []string{".csv"},
+		Mime: []string{"text/csv"},
+		Reader: func(r io.Reader) quad.ReadCloser {
+			return NewReader(r, ',', DefaultMapping)
+		},
+	})
+	quad.RegisterFormat(quad.Format{
+		Name: "tsv",
+		Ext:  []string{".tsv"},
+		Mime: []string{"text/tab-separated-values"},
+		Reader: func(r io.Reader) quad.ReadCloser {
+			return NewReader(r, '\t', DefaultMapping)
+		},
+	})
+}
+
+// Mapping describes how rows of a table are converted to quads.
+//
+// Templates may contain {column} placeholders, which are replaced with values of the column in the current row.
+// Values are escaped as path segments of IRIs.
+type Mapping struct {
+	// Subject is an IRI template for subjects. A new blank node is used for each row, if not set.
+	Subject string `json:"subject"`
+	// Type is an optional IRI of a class that is added to each subject as rdf:type.
+	Type string `json:"type,omitempty"`
+	// Label is an optional label for all quads.
+	Label string `json:"label,omitempty"`
+	// Columns maps column names to predicates and value types. If not set, all columns are mapped to strings.
+	// Otherwise, only listed columns are converted to quads.
+	Columns map[string]Column `json:"columns,omitempty"`
+}
+
+// Column describes how values of a column are converted to quads.
+type Column struct {
+	// Predicate is an IRI of the predicate. The name of the column is used, if not set.
+	Predicate string `json:"predicate,omitempty"`
+	// Type is a type of values: "string" (default), "int", "float", "bool", "time" or "iri".
+	// Any other type is interpreted as a datatype IRI of a typed string.
+	Type string `json:"type,omitempty"`
+	// Template is an IRI template for values of the "iri" type. The value itself is used, if not set.
+	// A {value} placeholder refers to the current value, unless there is a column with this name.
+	Template string `json:"template,omitempty"`
+	// Format is a layout of values of the "time" type, as defined by the time package. Defaults to RFC 3339.
+	Format string `json:"format,omitempty"`
+	// Lang is a language tag of string values.
+	Lang string `json:"lang,omitempty"`
+	// Separator splits a cell into multiple values, if set.
+	Separator string `json:"separator,omitempty"`
+}
+
+// LoadMapping reads a mapping from a JSON file.
+func LoadMapping(path string) (*Mapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var m Mapping
+	if err = json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("cannot decode mapping: %v", err)
+	}
+	return &m, nil
+}
+
+var reTemplate = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// column is a column of the mapping, bound to an index in the table.
+type column struct {
+	Column
+	index int
+	pred  quad.IRI
+}
+
+// NewReader returns a reader of quads from a table with a given delimiter. If mapping is nil, a default one is used.
+func NewReader(r io.Reader, comma rune, m *Mapping) *Reader {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	if comma == '\t' {
+		cr.LazyQuotes = true
+	}
+	if m == nil {
+		m = &Mapping{}
+	}
+	return &Reader{r: cr, m: m}
+}
+
+// Reader implements reading of quads from CSV and TSV files.
+type Reader struct {
+	r *csv.Reader
+	m *Mapping
+
+	index  map[string]int // column indexes by name
+	cols   []column
+	label  quad.Value
+	bnodes quad.Sequence
+	bpref  string
+	rows   int // number of rows read, including the header
+
+	queue []quad.Quad
+	err   error
+}
+
+// header reads column names and binds them to the mapping.
+func (r *Reader) header() error {
+	names, err := r.r.Read()
+	if err == io.EOF {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("cannot read header: %v", err)
+	}
+	r.index = make(map[string]int, len(names))
+	for i, name := range names {
+		r.index[strings.TrimSpace(name)] = i
+	}
+	check := func(tmpl string, value bool) error {
+		for _, sub := range reTemplate.FindAllStringSubmatch(tmpl, -1) {
+			if _, ok := r.index[sub[1]]; !ok && !(value && sub[1] == "value") {
+				return fmt.Errorf("unknown column in template %q: %q", tmpl, sub[1])
+			}
+		}
+		return nil
+	}
+	if err = check(r.m.Subject, false); err != nil {
+		return err
+	}
+	if len(r.m.Columns) == 0 {
+		for i, name := range names {
+			name = strings.TrimSpace(name)
+			r.cols = append(r.cols, column{index: i, pred: quad.IRI(name)})
+		}
+	} else {
+		for i, name := range names {
+			c, ok := r.m.Columns[strings.TrimSpace(name)]
+			if !ok {
+				continue
+			}
+			if err = check(c.Template, true); err != nil {
+				return err
+			}
+			switch c.Type {
+			case "", "string", "int", "float", "bool", "time", "iri":
+			default:
+				if c.Lang != "" {
+					return fmt.Errorf("language tag cannot be set for typed values in column %q", name)
+				}
+			}
+			pred := c.Predicate
+			if pred == "" {
+				pred = strings.TrimSpace(name)
+			}
+			r.cols = append(r.cols, column{Column: c, index: i, pred: quad.IRI(pred)})
+		}
+		for name := range r.m.Columns {
+			if _, ok := r.index[name]; !ok {
+				return fmt.Errorf("unknown column: %q", name)
+			}
+		}
+	}
+	if r.m.Label != "" {
+		r.label = quad.IRI(r.m.Label)
+	}
+	// blank nodes must not collide with nodes from other files
+	r.bpref = string(quad.RandomBlankNode())
+	r.rows = 1
+	return nil
+}
+
+// expand substitutes column values to a template.
+func (r *Reader) expand(tmpl string, row []string) string {
+	return reTemplate.ReplaceAllStringFunc(tmpl, func(s string) string {
+		return url.PathEscape(row[r.index[s[1:len(s)-1]]])
+	})
+}
+
+func (c *column) value(r *Reader, s string, row []string) (quad.Value, error) {
+	switch c.Type {
+	case "", "string":
+		if c.Lang != "" {
+			return quad.LangString{Value: quad.String(s), Lang: c.Lang}, nil
+		}
+		return quad.String(s), nil
+	case "int":
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return quad.Int(v), nil
+	case "float":
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return quad.Float(v), nil
+	case "bool":
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, err
+		}
+		return quad.Bool(v), nil
+	case "time":
+		layout := c.Format
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		v, err := time.Parse(layout, s)
+		if err != nil {
+			return nil, err
+		}
+		return quad.Time(v), nil
+	case "iri":
+		if c.Template == "" {
+			return quad.IRI(s), nil
+		}
+		// the current value is substituted for {value}, if there is no such column
+		tmpl := c.Template
+		if _, ok := r.index["value"]; !ok {
+			tmpl = strings.Replace(tmpl, "{value}", url.PathEscape(s), -1)
+		}
+		return quad.IRI(r.expand(tmpl, row)), nil
+	}
+	return quad.TypedString{Value: quad.String(s), Type: quad.IRI(c.Type)}, nil
+}
+
+// row converts a single row of the table to quads.
+func (r *Reader) row(row []string) error {
+	var subj quad.Value
+	if r.m.Subject != "" {
+		subj = quad.IRI(r.expand(r.m.Subject, row))
+	} else {
+		subj = quad.BNode(r.bpref + "_" + string(r.bnodes.Next()))
+	}
+	if r.m.Type != "" {
+		r.queue = append(r.queue, quad.Quad{Subject: subj, Predicate: quad.IRI(rdf.NS + "type"), Object: quad.IRI(r.m.Type), Label: r.label})
+	}
+	for i := range r.cols {
+		c := &r.cols[i]
+		vals := []string{row[c.index]}
+		if c.Separator != "" {
+			vals = strings.Split(row[c.index], c.Separator)
+		}
+		for _, s := range vals {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			v, err := c.value(r, s, row)
+			if err != nil {
+				return fmt.Errorf("column %d: %v", c.index+1, err)
+			}
+			r.queue = append(r.queue, quad.Quad{Subject: subj, Predicate: c.pred, Object: v, Label: r.label})
+		}
+	}
+	return nil
+}
+
+// ReadQuad returns the next quad from the table, or io.EOF if there are no more rows.
+func (r *Reader) ReadQuad() (quad.Quad, error) {
+	for len(r.queue) == 0 {
+		if r.err != nil {
+			return quad.Quad{}, r.err
+		}
+		if r.index == nil {
+			if r.err = r.header(); r.err != nil {
+				continue
+			}
+		}
+		row, err := r.r.Read()
+		if err == io.EOF {
+			r.err = err
+		} else if err != nil {
+			r.err = err
+		} else if err = r.row(row); err != nil {
+			r.err = fmt.Errorf("row %d: %v", r.rows+1, err)
+		}
+		r.rows++
+	}
+	q := r.queue[0]
+	r.queue = r.queue[1:]
+	return q, nil
+}
+
+func (r *Reader) Close() error { return nil }
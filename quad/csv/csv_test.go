@@ -0,0 +1,91 @@
+package csv_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/csv"
+)
+
+func readAll(t testing.TB, r quad.Reader) ([]quad.Quad, error) {
+	var out []quad.Quad
+	for {
+		q, err := r.ReadQuad()
+		if err == io.EOF {
+			return out, nil
+		} else if err != nil {
+			return out, err
+		}
+		out = append(out, q)
+	}
+}
+
+const people = `id,name,age,born,friends,score
+1,Alice,30,1987-01-02,2;3,1.5
+2,Bob,,1990-03-04,,2
+`
+
+func TestReaderMapping(t *testing.T) {
+	m := &csv.Mapping{
+		Subject: "http://example.org/person/{id}",
+		Type:    "http://example.org/Person",
+		Label:   "http://example.org/people",
+		Columns: map[string]csv.Column{
+			"name":    {Predicate: "http://example.org/name", Lang: "en"},
+			"age":     {Type: "int"},
+			"born":    {Type: "time", Format: "2006-01-02"},
+			"friends": {Predicate: "http://example.org/knows", Type: "iri", Template: "http://example.org/person/{value}", Separator: ";"},
+			"score":   {Type: "http://www.w3.org/2001/XMLSchema#decimal"},
+		},
+	}
+	got, err := readAll(t, csv.NewReader(strings.NewReader(people), ',', m))
+	require.NoError(t, err)
+
+	alice, bob := quad.IRI("http://example.org/person/1"), quad.IRI("http://example.org/person/2")
+	label := quad.IRI("http://example.org/people")
+	typ := quad.IRI("http://www.w3.org/1999/02/22-rdf-syntax-ns#type")
+	date := func(s string) quad.Value {
+		v, err := time.Parse("2006-01-02", s)
+		require.NoError(t, err)
+		return quad.Time(v)
+	}
+	require.Equal(t, []quad.Quad{
+		{Subject: alice, Predicate: typ, Object: quad.IRI("http://example.org/Person"), Label: label},
+		{Subject: alice, Predicate: quad.IRI("http://example.org/name"), Object: quad.LangString{Value: "Alice", Lang: "en"}, Label: label},
+		{Subject: alice, Predicate: quad.IRI("age"), Object: quad.Int(30), Label: label},
+		{Subject: alice, Predicate: quad.IRI("born"), Object: date("1987-01-02"), Label: label},
+		{Subject: alice, Predicate: quad.IRI("http://example.org/knows"), Object: quad.IRI("http://example.org/person/2"), Label: label},
+		{Subject: alice, Predicate: quad.IRI("http://example.org/knows"), Object: quad.IRI("http://example.org/person/3"), Label: label},
+		{Subject: alice, Predicate: quad.IRI("score"), Object: quad.TypedString{Value: "1.5", Type: "http://www.w3.org/2001/XMLSchema#decimal"}, Label: label},
+		{Subject: bob, Predicate: typ, Object: quad.IRI("http://example.org/Person"), Label: label},
+		{Subject: bob, Predicate: quad.IRI("http://example.org/name"), Object: quad.LangString{Value: "Bob", Lang: "en"}, Label: label},
+		{Subject: bob, Predicate: quad.IRI("born"), Object: date("1990-03-04"), Label: label},
+		{Subject: bob, Predicate: quad.IRI("score"), Object: quad.TypedString{Value: "2", Type: "http://www.w3.org/2001/XMLSchema#decimal"}, Label: label},
+	}, got)
+}
+
+func TestReaderDefault(t *testing.T) {
+	got, err := readAll(t, csv.NewReader(strings.NewReader("name\tage\nAlice\t30\n"), '\t', nil))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	_, ok := got[0].Subject.(quad.BNode)
+	require.True(t, ok)
+	require.Equal(t, got[0].Subject, got[1].Subject)
+	require.Equal(t, quad.Quad{Subject: got[0].Subject, Predicate: quad.IRI("age"), Object: quad.String("30")}, got[1])
+}
+
+func TestReaderErrors(t *testing.T) {
+	_, err := readAll(t, csv.NewReader(strings.NewReader(people), ',', &csv.Mapping{Subject: "{missing}"}))
+	require.Error(t, err)
+
+	_, err = readAll(t, csv.NewReader(strings.NewReader(people), ',', &csv.Mapping{
+		Columns: map[string]csv.Column{"name": {Type: "int"}},
+	}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "row 2")
+}
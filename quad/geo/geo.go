@@ -0,0 +1,363 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package geo implements geospatial literals in WKT and GeoJSON formats.
+//
+// Geometries are stored as typed string literals with GeoSPARQL data types. Coordinates are
+// WGS84 degrees; WKT and GeoJSON list longitude first, while Go types use latitude first.
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc"
+)
+
+func init() {
+	voc.RegisterPrefix(Prefix, NS)
+}
+
+const (
+	// NS is a namespace of the GeoSPARQL vocabulary.
+	NS = "http://www.opengis.net/ont/geosparql#"
+	// Prefix is a default prefix of the GeoSPARQL vocabulary.
+	Prefix = "geo:"
+)
+
+const (
+	// WKTLiteral is a data type of geometries in the Well-Known Text format.
+	WKTLiteral = quad.IRI(NS + "wktLiteral")
+	// GeoJSONLiteral is a data type of geometries in the GeoJSON format.
+	GeoJSONLiteral = quad.IRI(NS + "geoJSONLiteral")
+)
+
+// EarthRadius is a mean radius of the Earth in kilometers.
+const EarthRadius = 6371.0088
+
+// Geometry is a shape on the Earth surface.
+type Geometry interface {
+	// Center returns a point used to match the geometry against regions.
+	Center() Point
+	// WKT returns the geometry in the Well-Known Text format.
+	WKT() string
+	// TypedString returns the geometry as a WKT literal.
+	TypedString() quad.TypedString
+}
+
+// Region is an area used in geospatial queries.
+type Region interface {
+	// Contains checks if the region contains a point.
+	Contains(p Point) bool
+	// Bounds returns a bounding box of the region.
+	Bounds() Rect
+	// Center returns a point that is used to order query results by distance.
+	Center() Point
+}
+
+var (
+	_ Geometry = Point{}
+	_ Geometry = Polygon{}
+	_ Region   = Polygon{}
+	_ Region   = Circle{}
+)
+
+// Point is a geographic location.
+type Point struct {
+	Lat, Lng float64
+}
+
+// Valid checks if coordinates of the point are in the valid range.
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+func (p Point) Center() Point { return p }
+
+func (p Point) WKT() string {
+	return "POINT(" + formatCoords(p) + ")"
+}
+
+func (p Point) TypedString() quad.TypedString {
+	return quad.TypedString{Value: quad.String(p.WKT()), Type: WKTLiteral}
+}
+
+func formatCoords(p Point) string {
+	return strconv.FormatFloat(p.Lng, 'f', -1, 64) + " " + strconv.FormatFloat(p.Lat, 'f', -1, 64)
+}
+
+// Distance returns a great-circle distance between two points in kilometers.
+func Distance(a, b Point) float64 {
+	const rad = math.Pi / 180
+	dlat := (b.Lat - a.Lat) * rad
+	dlng := (b.Lng - a.Lng) * rad
+	h := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dlng/2)*math.Sin(dlng/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Rect is a bounding box.
+type Rect struct {
+	Min, Max Point
+}
+
+// Contains checks if the box contains a point.
+func (r Rect) Contains(p Point) bool {
+	return p.Lat >= r.Min.Lat && p.Lat <= r.Max.Lat && p.Lng >= r.Min.Lng && p.Lng <= r.Max.Lng
+}
+
+// Circle is a set of points within a given distance from the center.
+type Circle struct {
+	Point Point
+	// Radius in kilometers.
+	Radius float64
+}
+
+func (c Circle) Center() Point { return c.Point }
+
+func (c Circle) Contains(p Point) bool {
+	return Distance(c.Point, p) <= c.Radius
+}
+
+func (c Circle) Bounds() Rect {
+	dlat := c.Radius / EarthRadius * 180 / math.Pi
+	r := Rect{
+		Min: Point{Lat: math.Max(-90, c.Point.Lat-dlat), Lng: -180},
+		Max: Point{Lat: math.Min(90, c.Point.Lat+dlat), Lng: 180},
+	}
+	if r.Min.Lat == -90 || r.Max.Lat == 90 {
+		// includes a pole
+		return r
+	}
+	dlng := math.Asin(math.Min(1, math.Sin(c.Radius/EarthRadius)/math.Cos(c.Point.Lat*math.Pi/180))) * 180 / math.Pi
+	if c.Point.Lng-dlng >= -180 && c.Point.Lng+dlng <= 180 {
+		r.Min.Lng, r.Max.Lng = c.Point.Lng-dlng, c.Point.Lng+dlng
+	}
+	return r
+}
+
+// Polygon is a closed ring of points. The last point may repeat the first one.
+// Edges are straight lines in latitude and longitude, and polygons must not cross the antimeridian.
+type Polygon []Point
+
+// Center returns a centroid of polygon vertices.
+func (p Polygon) Center() Point {
+	ring := p.ring()
+	var c Point
+	if len(ring) == 0 {
+		return c
+	}
+	for _, v := range ring {
+		c.Lat += v.Lat
+		c.Lng += v.Lng
+	}
+	c.Lat /= float64(len(ring))
+	c.Lng /= float64(len(ring))
+	return c
+}
+
+// ring returns vertices of the polygon without the closing point.
+func (p Polygon) ring() []Point {
+	if n := len(p); n > 1 && p[0] == p[n-1] {
+		return p[:n-1]
+	}
+	return p
+}
+
+func (p Polygon) Bounds() Rect {
+	if len(p) == 0 {
+		return Rect{}
+	}
+	r := Rect{Min: p[0], Max: p[0]}
+	for _, v := range p[1:] {
+		r.Min.Lat, r.Max.Lat = math.Min(r.Min.Lat, v.Lat), math.Max(r.Max.Lat, v.Lat)
+		r.Min.Lng, r.Max.Lng = math.Min(r.Min.Lng, v.Lng), math.Max(r.Max.Lng, v.Lng)
+	}
+	return r
+}
+
+// Contains checks if the point is inside the polygon using the even-odd rule.
+func (p Polygon) Contains(pt Point) bool {
+	ring := p.ring()
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > pt.Lat) != (b.Lat > pt.Lat) &&
+			pt.Lng < (b.Lng-a.Lng)*(pt.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			in = !in
+		}
+	}
+	return in
+}
+
+func (p Polygon) WKT() string {
+	ring := p.ring()
+	parts := make([]string, 0, len(ring)+1)
+	for _, v := range ring {
+		parts = append(parts, formatCoords(v))
+	}
+	if len(ring) != 0 {
+		parts = append(parts, formatCoords(ring[0]))
+	}
+	return "POLYGON((" + strings.Join(parts, ", ") + "))"
+}
+
+func (p Polygon) TypedString() quad.TypedString {
+	return quad.TypedString{Value: quad.String(p.WKT()), Type: WKTLiteral}
+}
+
+// ErrNotGeometry is returned by FromValue if the value is not a geospatial literal.
+var ErrNotGeometry = errors.New("geo: not a geometry literal")
+
+// FromValue parses a WKT or GeoJSON literal.
+func FromValue(v quad.Value) (Geometry, error) {
+	ts, ok := v.(quad.TypedString)
+	if !ok {
+		return nil, ErrNotGeometry
+	}
+	switch ts.Type.Full() {
+	case WKTLiteral:
+		return ParseWKT(string(ts.Value))
+	case GeoJSONLiteral:
+		return ParseGeoJSON([]byte(ts.Value))
+	}
+	return nil, ErrNotGeometry
+}
+
+// ParseWKT parses a POINT or POLYGON in the Well-Known Text format. Only the outer ring of polygons is used.
+// An optional CRS IRI prefix is allowed, but only WGS84 coordinates are supported.
+func ParseWKT(s string) (Geometry, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "<") {
+		// CRS IRI, as defined in GeoSPARQL
+		i := strings.IndexByte(s, '>')
+		if i < 0 {
+			return nil, fmt.Errorf("geo: invalid WKT: %q", s)
+		}
+		s = strings.TrimSpace(s[i+1:])
+	}
+	i := strings.IndexByte(s, '(')
+	if i < 0 || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("geo: invalid WKT: %q", s)
+	}
+	typ, body := strings.ToUpper(strings.TrimSpace(s[:i])), s[i+1:len(s)-1]
+	switch typ {
+	case "POINT":
+		return parseWKTPoint(body)
+	case "POLYGON":
+		body = strings.TrimSpace(body)
+		if !strings.HasPrefix(body, "(") {
+			return nil, fmt.Errorf("geo: invalid WKT polygon: %q", s)
+		}
+		if j := strings.IndexByte(body, ')'); j > 0 {
+			body = body[1:j]
+		} else {
+			return nil, fmt.Errorf("geo: invalid WKT polygon: %q", s)
+		}
+		var poly Polygon
+		for _, c := range strings.Split(body, ",") {
+			p, err := parseWKTPoint(c)
+			if err != nil {
+				return nil, err
+			}
+			poly = append(poly, p)
+		}
+		if err := poly.validate(); err != nil {
+			return nil, err
+		}
+		return poly, nil
+	}
+	return nil, fmt.Errorf("geo: unsupported WKT geometry: %q", typ)
+}
+
+func parseWKTPoint(s string) (Point, error) {
+	f := strings.Fields(s)
+	if len(f) != 2 {
+		return Point{}, fmt.Errorf("geo: invalid WKT coordinates: %q", s)
+	}
+	lng, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return Point{}, err
+	}
+	lat, err := strconv.ParseFloat(f[1], 64)
+	if err != nil {
+		return Point{}, err
+	}
+	p := Point{Lat: lat, Lng: lng}
+	if !p.Valid() {
+		return p, fmt.Errorf("geo: coordinates out of range: %q", s)
+	}
+	return p, nil
+}
+
+func (p Polygon) validate() error {
+	if len(p.ring()) < 3 {
+		return errors.New("geo: polygon must have at least 3 points")
+	}
+	for _, v := range p {
+		if !v.Valid() {
+			return fmt.Errorf("geo: coordinates out of range: %v", v)
+		}
+	}
+	return nil
+}
+
+// ParseGeoJSON parses a GeoJSON Point or Polygon geometry. Only the outer ring of polygons is used.
+func ParseGeoJSON(data []byte) (Geometry, error) {
+	var obj struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("geo: invalid GeoJSON: %v", err)
+	}
+	switch obj.Type {
+	case "Point":
+		var c []float64
+		if err := json.Unmarshal(obj.Coordinates, &c); err != nil {
+			return nil, fmt.Errorf("geo: invalid GeoJSON point: %v", err)
+		} else if len(c) < 2 {
+			return nil, errors.New("geo: invalid GeoJSON point")
+		}
+		p := Point{Lat: c[1], Lng: c[0]}
+		if !p.Valid() {
+			return nil, fmt.Errorf("geo: coordinates out of range: %v", c)
+		}
+		return p, nil
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &rings); err != nil {
+			return nil, fmt.Errorf("geo: invalid GeoJSON polygon: %v", err)
+		} else if len(rings) == 0 {
+			return nil, errors.New("geo: invalid GeoJSON polygon")
+		}
+		poly := make(Polygon, 0, len(rings[0]))
+		for _, c := range rings[0] {
+			if len(c) < 2 {
+				return nil, errors.New("geo: invalid GeoJSON polygon")
+			}
+			poly = append(poly, Point{Lat: c[1], Lng: c[0]})
+		}
+		if err := poly.validate(); err != nil {
+			return nil, err
+		}
+		return poly, nil
+	}
+	return nil, fmt.Errorf("geo: unsupported GeoJSON geometry: %q", obj.Type)
+}
@@ -0,0 +1,117 @@
+package geo_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
+)
+
+var (
+	paris  = geo.Point{Lat: 48.8566, Lng: 2.3522}
+	london = geo.Point{Lat: 51.5074, Lng: -0.1278}
+)
+
+var parseCases = []struct {
+	name string
+	val  quad.Value
+	exp  geo.Geometry
+	err  bool
+}{
+	{
+		name: "wkt point",
+		val:  quad.TypedString{Value: "POINT(2.3522 48.8566)", Type: geo.WKTLiteral},
+		exp:  paris,
+	},
+	{
+		name: "wkt point with crs",
+		val:  quad.TypedString{Value: "<http://www.opengis.net/def/crs/OGC/1.3/CRS84> Point( 2.3522  48.8566 )", Type: geo.WKTLiteral},
+		exp:  paris,
+	},
+	{
+		name: "wkt polygon",
+		val:  quad.TypedString{Value: "POLYGON((0 0, 10 0, 10 10, 0 10, 0 0))", Type: geo.WKTLiteral},
+		exp:  geo.Polygon{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}},
+	},
+	{
+		name: "prefixed type",
+		val:  quad.TypedString{Value: "POINT(2.3522 48.8566)", Type: "geo:wktLiteral"},
+		exp:  paris,
+	},
+	{
+		name: "geojson point",
+		val:  quad.TypedString{Value: `{"type":"Point","coordinates":[2.3522,48.8566]}`, Type: geo.GeoJSONLiteral},
+		exp:  paris,
+	},
+	{
+		name: "geojson polygon",
+		val:  quad.TypedString{Value: `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,0]]]}`, Type: geo.GeoJSONLiteral},
+		exp:  geo.Polygon{{0, 0}, {0, 10}, {10, 10}, {0, 0}},
+	},
+	{
+		name: "out of range",
+		val:  quad.TypedString{Value: "POINT(200 10)", Type: geo.WKTLiteral},
+		err:  true,
+	},
+	{
+		name: "degenerate polygon",
+		val:  quad.TypedString{Value: "POLYGON((0 0, 1 1, 0 0))", Type: geo.WKTLiteral},
+		err:  true,
+	},
+	{
+		name: "unsupported",
+		val:  quad.TypedString{Value: "LINESTRING(0 0, 1 1)", Type: geo.WKTLiteral},
+		err:  true,
+	},
+	{
+		name: "string",
+		val:  quad.String("POINT(0 0)"),
+		err:  true,
+	},
+}
+
+func TestFromValue(t *testing.T) {
+	for _, c := range parseCases {
+		t.Run(c.name, func(t *testing.T) {
+			g, err := geo.FromValue(c.val)
+			if c.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, g)
+
+			// round trip
+			g2, err := geo.FromValue(g.TypedString())
+			require.NoError(t, err)
+			require.Equal(t, geo.WKTLiteral, g.TypedString().Type)
+			if p, ok := g.(geo.Polygon); ok && p[0] == p[len(p)-1] {
+				require.Equal(t, g, g2)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	d := geo.Distance(paris, london)
+	require.True(t, math.Abs(d-343.5) < 1, "distance: %v", d)
+	require.Equal(t, 0.0, geo.Distance(paris, paris))
+
+	c := geo.Circle{Point: paris, Radius: 350}
+	require.True(t, c.Contains(london))
+	require.True(t, c.Bounds().Contains(london))
+	require.False(t, geo.Circle{Point: paris, Radius: 300}.Contains(london))
+}
+
+func TestPolygonContains(t *testing.T) {
+	// concave polygon
+	poly := geo.Polygon{{0, 0}, {0, 10}, {10, 10}, {10, 5}, {5, 5}, {5, 0}}
+	require.True(t, poly.Contains(geo.Point{Lat: 2, Lng: 2}))
+	require.True(t, poly.Contains(geo.Point{Lat: 8, Lng: 8}))
+	require.False(t, poly.Contains(geo.Point{Lat: 8, Lng: 2}))
+	require.False(t, poly.Contains(geo.Point{Lat: 20, Lng: 2}))
+	require.Equal(t, geo.Rect{Min: geo.Point{0, 0}, Max: geo.Point{10, 10}}, poly.Bounds())
+}
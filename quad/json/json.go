@@ -31,7 +31,7 @@ func init() {
 	})
 	quad.RegisterFormat(quad.Format{
 		Name:   "json-stream",
-		Mime:   []string{"application/x-json-stream"},
+		Mime:   []string{"application/x-json-stream", "application/x-ndjson"},
 		Writer: func(w io.Writer) quad.WriteCloser { return NewStreamWriter(w) },
 		Reader: func(r io.Reader) quad.ReadCloser { return NewStreamReader(r) },
 	})
@@ -0,0 +1,192 @@
+package jsonld
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/linkeddata/gojsonld"
+)
+
+// frameNodes shapes expanded JSON-LD data into a list of root nodes selected by the frame.
+//
+// Only a subset of JSON-LD framing is supported: a node matches the frame if it has one of the frame's
+// @id and @type values and has all properties listed in the frame. Nodes referenced by the matched ones
+// are embedded into them, unless it would create a cycle. Framing flags like @embed and @explicit are ignored.
+//
+// Named graphs are merged into a single one. The result is compacted with the frame's @context, if any.
+func frameNodes(data interface{}, frame interface{}, opts *gojsonld.Options) (interface{}, error) {
+	exp, err := gojsonld.Expand(frame, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot expand JSON-LD frame: %v", err)
+	}
+	var match map[string]interface{}
+	if len(exp) != 0 {
+		if match, _ = exp[0].(map[string]interface{}); match == nil {
+			return nil, fmt.Errorf("unexpected JSON-LD frame: %T", exp[0])
+		}
+	}
+	nodes := make(map[string]map[string]interface{})
+	collectNodes(nodes, data)
+
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	graph := make([]interface{}, 0)
+	for _, id := range ids {
+		if n := nodes[id]; matchesFrame(n, match) {
+			graph = append(graph, embedNode(nodes, n, map[string]bool{id: true}))
+		}
+	}
+	var ctx interface{}
+	if m, ok := frame.(map[string]interface{}); ok {
+		ctx = m["@context"]
+	}
+	if ctx == nil {
+		return map[string]interface{}{"@graph": graph}, nil
+	}
+	m, err := gojsonld.Compact(map[string]interface{}{"@graph": graph}, ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	// compaction removes @graph with a single node, but framed output always has it
+	if _, ok := m["@graph"]; ok {
+		return m, nil
+	}
+	root := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != "@context" {
+			root[k] = v
+		}
+	}
+	res := map[string]interface{}{"@graph": []interface{}{}}
+	if c, ok := m["@context"]; ok {
+		res["@context"] = c
+	}
+	if len(root) != 0 {
+		res["@graph"] = []interface{}{root}
+	}
+	return res, nil
+}
+
+// collectNodes adds node objects of expanded JSON-LD data to a map by their @id, merging nodes from all graphs.
+func collectNodes(nodes map[string]map[string]interface{}, data interface{}) {
+	list, ok := data.([]interface{})
+	if !ok {
+		list = []interface{}{data}
+	}
+	for _, v := range list {
+		n, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if g, ok := n["@graph"]; ok {
+			collectNodes(nodes, g)
+		}
+		id, _ := n["@id"].(string)
+		if id == "" {
+			continue
+		}
+		cur := nodes[id]
+		if cur == nil {
+			cur = make(map[string]interface{}, len(n))
+			nodes[id] = cur
+		}
+		for k, pv := range n {
+			if k == "@graph" {
+				continue
+			} else if k == "@id" {
+				cur[k] = pv
+				continue
+			}
+			prev, _ := cur[k].([]interface{})
+			vals, _ := pv.([]interface{})
+			cur[k] = append(prev, vals...)
+		}
+	}
+}
+
+// matchesFrame checks if a node has one of @id and @type values of the frame and all its properties.
+func matchesFrame(n, frame map[string]interface{}) bool {
+	for k, fv := range frame {
+		switch k {
+		case "@id":
+			if !containsString(fv, n["@id"]) {
+				return false
+			}
+		case "@type":
+			types, _ := n["@type"].([]interface{})
+			found := len(values(fv)) == 0
+			for _, t := range types {
+				if containsString(fv, t) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		default:
+			if len(k) != 0 && k[0] == '@' {
+				continue
+			}
+			if _, ok := n[k]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// embedNode returns a copy of the node with referenced nodes embedded into it.
+// Nodes in the path are not embedded to prevent cycles.
+func embedNode(nodes map[string]map[string]interface{}, n map[string]interface{}, path map[string]bool) map[string]interface{} {
+	out := make(map[string]interface{}, len(n))
+	for k, pv := range n {
+		vals, ok := pv.([]interface{})
+		if !ok || k[0] == '@' {
+			out[k] = pv
+			continue
+		}
+		nv := make([]interface{}, 0, len(vals))
+		for _, v := range vals {
+			ref, ok := v.(map[string]interface{})
+			id, _ := ref["@id"].(string)
+			if sub := nodes[id]; ok && len(ref) == 1 && sub != nil && !path[id] {
+				path[id] = true
+				v = embedNode(nodes, sub, path)
+				delete(path, id)
+			}
+			nv = append(nv, v)
+		}
+		out[k] = nv
+	}
+	return out
+}
+
+// values returns elements of an expanded JSON-LD value which may be a single value or an array.
+func values(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
+
+// containsString checks if a frame value lists a given string. Empty frame values match anything.
+func containsString(fv interface{}, s interface{}) bool {
+	list := values(fv)
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -16,6 +16,13 @@ import (
 // If conversion error occurs, it will preserve original TypedString value.
 var AutoConvertTypedString = true
 
+// DefaultContext and DefaultFrame are used by new writers as an initial @context and frame document.
+// See Writer.SetLdContext and Writer.SetLdFrame.
+var (
+	DefaultContext interface{}
+	DefaultFrame   interface{}
+)
+
 func init() {
 	quad.RegisterFormat(quad.Format{
 		Name:   "jsonld",
@@ -95,19 +102,37 @@ func (r *Reader) Close() error {
 }
 
 func NewWriter(w io.Writer) *Writer {
-	return &Writer{w: w, ds: gojsonld.NewDataset()}
+	return &Writer{w: w, ds: gojsonld.NewDataset(), ctx: DefaultContext, frame: DefaultFrame}
 }
 
 type Writer struct {
-	w   io.Writer
-	ds  *gojsonld.Dataset
-	ctx interface{}
+	w     io.Writer
+	ds    *gojsonld.Dataset
+	ctx   interface{}
+	frame interface{}
 }
 
+// SetLdContext sets a @context used to compact the output.
 func (w *Writer) SetLdContext(ctx interface{}) {
 	w.ctx = ctx
 }
 
+// SetLdFrame sets a frame document used to shape the output into nested objects.
+// If the frame has no @context, the one set by SetLdContext is used.
+// Only a subset of JSON-LD framing is supported, see frameNodes.
+func (w *Writer) SetLdFrame(frame interface{}) {
+	w.frame = frame
+}
+
+// ReadDocument reads a JSON document that can be used as a @context or a frame.
+func ReadDocument(r io.Reader) (interface{}, error) {
+	var o interface{}
+	if err := json.NewDecoder(r).Decode(&o); err != nil {
+		return nil, fmt.Errorf("cannot decode JSON-LD document: %v", err)
+	}
+	return o, nil
+}
+
 func (w *Writer) WriteQuad(q quad.Quad) error {
 	var graph string
 	if q.Label == nil {
@@ -131,7 +156,13 @@ func (w *Writer) Close() error {
 	opts := gojsonld.NewOptions("")
 	var data interface{}
 	data = gojsonld.FromRDF(w.ds, opts)
-	if w.ctx != nil {
+	if w.frame != nil {
+		out, err := frameNodes(data, w.frameWithContext(), opts)
+		if err != nil {
+			return err
+		}
+		data = out
+	} else if w.ctx != nil {
 		out, err := gojsonld.Compact(data, w.ctx, opts)
 		if err != nil {
 			return err
@@ -141,6 +172,23 @@ func (w *Writer) Close() error {
 	return json.NewEncoder(w.w).Encode(data)
 }
 
+// frameWithContext returns a frame document with a @context set by SetLdContext,
+// unless the frame defines its own.
+func (w *Writer) frameWithContext() interface{} {
+	frame, ok := w.frame.(map[string]interface{})
+	if !ok || w.ctx == nil {
+		return w.frame
+	} else if _, ok = frame["@context"]; ok {
+		return w.frame
+	}
+	out := make(map[string]interface{}, len(frame)+1)
+	for k, v := range frame {
+		out[k] = v
+	}
+	out["@context"] = w.ctx
+	return out
+}
+
 func toTerm(v quad.Value) gojsonld.Term {
 	switch v := v.(type) {
 	case quad.IRI:
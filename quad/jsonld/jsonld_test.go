@@ -246,3 +246,43 @@ func TestRoundtrip(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteFrame(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w := NewWriter(buf)
+	w.SetLdContext(map[string]interface{}{
+		"ex": "http://example.org/",
+	})
+	frame, err := ReadDocument(strings.NewReader(`{"@type": "ex:Person"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetLdFrame(frame)
+	_, err = quad.Copy(w, quad.NewReader([]quad.Quad{
+		quad.MakeIRI("http://example.org/alice", "http://www.w3.org/1999/02/22-rdf-syntax-ns#type", "http://example.org/Person", ""),
+		quad.MakeIRI("http://example.org/alice", "http://example.org/knows", "http://example.org/bob", ""),
+		quad.Make(quad.IRI("http://example.org/bob"), quad.IRI("http://example.org/name"), quad.String("Bob"), nil),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	} else if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Context map[string]interface{}   `json:"@context"`
+		Graph   []map[string]interface{} `json:"@graph"`
+	}
+	if err = json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Context["ex"] != "http://example.org/" {
+		t.Fatalf("unexpected context: %v", out.Context)
+	} else if len(out.Graph) != 1 || out.Graph[0]["@id"] != "ex:alice" {
+		t.Fatalf("unexpected framed graph: %s", buf.String())
+	}
+	// bob should be embedded into alice's node
+	bob, ok := out.Graph[0]["ex:knows"].(map[string]interface{})
+	if !ok || bob["@id"] != "ex:bob" || bob["ex:name"] == nil {
+		t.Fatalf("expected an embedded node: %s", buf.String())
+	}
+}
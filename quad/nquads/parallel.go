@@ -0,0 +1,180 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nquads
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// parallelChunk is a number of lines parsed by a worker at once.
+const parallelChunk = 1024
+
+type lineChunk struct {
+	lines []string
+	quads []quad.Quad
+	err   error
+	done  chan struct{}
+}
+
+// ParallelReader parses N-Quads with multiple goroutines.
+// Quads are returned in the same order as they appear in the input.
+type ParallelReader struct {
+	raw   bool
+	order chan *lineChunk
+	stop  chan struct{}
+	err   error // read error; set before order is closed
+
+	cur    *lineChunk
+	i      int
+	closed bool
+}
+
+// NewParallelReader returns an N-Quad decoder that reads lines from r and parses them
+// using a given number of workers. If workers is zero, the number of CPUs is used.
+func NewParallelReader(r io.Reader, raw bool, workers int) *ParallelReader {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	pr := &ParallelReader{
+		raw:   raw,
+		order: make(chan *lineChunk, 2*workers),
+		stop:  make(chan struct{}),
+	}
+	jobs := make(chan *lineChunk, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for c := range jobs {
+				pr.parse(c)
+			}
+		}()
+	}
+	go pr.read(bufio.NewReader(r), jobs)
+	return pr
+}
+
+// read splits the input into chunks of lines and sends them to workers.
+func (pr *ParallelReader) read(br *bufio.Reader, jobs chan<- *lineChunk) {
+	defer close(pr.order)
+	defer close(jobs)
+	c := &lineChunk{done: make(chan struct{})}
+	send := func() bool {
+		select {
+		case jobs <- c:
+		case <-pr.stop:
+			return false
+		}
+		select {
+		case pr.order <- c:
+		case <-pr.stop:
+			return false
+		}
+		c = &lineChunk{done: make(chan struct{})}
+		return true
+	}
+	var buf []byte
+	for {
+		buf = buf[:0]
+		var err error
+		for {
+			l, pre, e := br.ReadLine()
+			if e != nil {
+				err = e
+				break
+			}
+			buf = append(buf, l...)
+			if !pre {
+				break
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				pr.err = err
+			}
+			if len(c.lines) != 0 {
+				send()
+			}
+			return
+		}
+		if line := bytes.TrimSpace(buf); len(line) != 0 && line[0] != '#' {
+			c.lines = append(c.lines, string(line))
+			if len(c.lines) == parallelChunk && !send() {
+				return
+			}
+		}
+	}
+}
+
+func (pr *ParallelReader) parse(c *lineChunk) {
+	defer close(c.done)
+	c.quads = make([]quad.Quad, 0, len(c.lines))
+	for _, line := range c.lines {
+		var (
+			q   quad.Quad
+			err error
+		)
+		if pr.raw {
+			q, err = ParseRaw(line)
+		} else {
+			q, err = Parse(line)
+		}
+		if err != nil {
+			c.err = fmt.Errorf("failed to parse %q: %v", line, err)
+			break
+		}
+		if q.IsValid() {
+			c.quads = append(c.quads, q)
+		}
+	}
+	c.lines = nil
+}
+
+// ReadQuad returns the next valid N-Quad as a quad.Quad, or an error.
+func (pr *ParallelReader) ReadQuad() (quad.Quad, error) {
+	for {
+		if pr.cur != nil {
+			if pr.i < len(pr.cur.quads) {
+				q := pr.cur.quads[pr.i]
+				pr.i++
+				return q, nil
+			} else if pr.cur.err != nil {
+				return quad.Quad{}, pr.cur.err
+			}
+		}
+		c, ok := <-pr.order
+		if !ok {
+			if pr.err != nil {
+				return quad.Quad{}, pr.err
+			}
+			return quad.Quad{}, io.EOF
+		}
+		<-c.done
+		pr.cur, pr.i = c, 0
+	}
+}
+
+// Close stops reading of the input. It does not close the underlying reader.
+func (pr *ParallelReader) Close() error {
+	if !pr.closed {
+		pr.closed = true
+		close(pr.stop)
+	}
+	return nil
+}
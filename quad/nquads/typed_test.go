@@ -696,6 +696,35 @@ func TestDecoder(t *testing.T) {
 	}
 }
 
+func TestParallelDecoder(t *testing.T) {
+	var buf strings.Builder
+	for i := 0; i < 3*parallelChunk+10; i++ {
+		fmt.Fprintf(&buf, "<s%d> <p> \"%d\" .\n", i, i)
+		if i%100 == 0 {
+			buf.WriteString("# comment\n\n")
+		}
+	}
+	exp, err := quad.ReadAll(NewReader(strings.NewReader(buf.String()), false))
+	require.NoError(t, err)
+
+	dec := NewParallelReader(strings.NewReader(buf.String()), false, 4)
+	defer dec.Close()
+	got, err := quad.ReadAll(dec)
+	require.NoError(t, err)
+	require.Equal(t, exp, got)
+
+	dec = NewParallelReader(strings.NewReader(document+"<a> <b> broken\n"), false, 2)
+	defer dec.Close()
+	n := 0
+	for ; ; n++ {
+		if _, err = dec.ReadQuad(); err != nil {
+			break
+		}
+	}
+	require.True(t, err != nil && err != io.EOF, "expected parse error, got: %v", err)
+	require.Equal(t, 20, n)
+}
+
 func TestRDFWorkingGroupSuit(t *testing.T) {
 	// Tests that are not passable by cquads parsing from the RDF
 	// Working Group Suite:
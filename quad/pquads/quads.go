@@ -156,6 +156,11 @@ func makeRef(v quad.Value) (*StrictQuad_Ref, error) {
 	return &StrictQuad_Ref{Value: sv}, nil
 }
 
+// MakeWireQuad converts quad.Quad to its protobuf representation that allows any value in each direction.
+func MakeWireQuad(q quad.Quad) *WireQuad {
+	return makeWireQuad(q)
+}
+
 func makeWireQuad(q quad.Quad) *WireQuad {
 	return &WireQuad{
 		Subject:   MakeValue(q.Subject),
@@ -0,0 +1,203 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact removes or anonymizes quads, so a graph can be shared without leaking personal data.
+//
+// Rules can drop quads by predicate or label, and replace literal values of listed predicates
+// with a keyed hash or a fixed mask. Hashing keeps equal values equal, so anonymized values can
+// still be joined and counted.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DefaultMask replaces masked values, if the mask is not set in Rules.
+const DefaultMask = "***"
+
+// HashPrefix is prepended to hashed values.
+const HashPrefix = "sha256:"
+
+// Rules configures redaction.
+type Rules struct {
+	// Allow lists predicates that are kept. If it is not empty, quads with other predicates are removed.
+	Allow []quad.Value
+	// Deny lists predicates that are removed.
+	Deny []quad.Value
+	// Hash lists predicates with literal objects replaced by a keyed hash of the value.
+	Hash []quad.Value
+	// Mask lists predicates with literal objects replaced by the mask.
+	Mask []quad.Value
+	// ExcludeLabels lists labels of quads that are removed. Nil value is the default graph.
+	ExcludeLabels []quad.Value
+	// Salt is a secret key of the hash. Without it, hashes of guessable values can be reversed.
+	Salt string
+	// MaskValue replaces masked values. DefaultMask is used if it is not set.
+	MaskValue string
+}
+
+// IsEmpty checks if rules leave all quads unchanged.
+func (r *Rules) IsEmpty() bool {
+	return len(r.Allow) == 0 && len(r.Deny) == 0 && len(r.Hash) == 0 &&
+		len(r.Mask) == 0 && len(r.ExcludeLabels) == 0
+}
+
+type action int
+
+const (
+	keep = action(iota)
+	hash
+	mask
+)
+
+// Redactor applies redaction rules to quads. It is safe for concurrent use.
+//
+// All methods are safe to call on a nil Redactor, which leaves quads unchanged.
+type Redactor struct {
+	allow  map[string]struct{}
+	deny   map[string]struct{}
+	labels map[string]struct{}
+	preds  map[string]action
+	salt   []byte
+	mask   quad.String
+}
+
+// key returns a string that uniquely identifies a value.
+func key(v quad.Value) string {
+	if v == nil {
+		return ""
+	}
+	if iri, ok := v.(quad.IRI); ok {
+		v = iri.Full()
+	}
+	return v.String()
+}
+
+func newSet(vals []quad.Value) map[string]struct{} {
+	if len(vals) == 0 {
+		return nil
+	}
+	m := make(map[string]struct{}, len(vals))
+	for _, v := range vals {
+		m[key(v)] = struct{}{}
+	}
+	return m
+}
+
+// New creates a redactor for given rules. It returns nil if the rules are empty.
+func New(r Rules) *Redactor {
+	if r.IsEmpty() {
+		return nil
+	}
+	rd := &Redactor{
+		allow:  newSet(r.Allow),
+		deny:   newSet(r.Deny),
+		labels: newSet(r.ExcludeLabels),
+		preds:  make(map[string]action),
+		salt:   []byte(r.Salt),
+		mask:   quad.String(r.MaskValue),
+	}
+	if rd.mask == "" {
+		rd.mask = DefaultMask
+	}
+	for _, p := range r.Hash {
+		rd.preds[key(p)] = hash
+	}
+	// masking takes precedence, since it leaks less
+	for _, p := range r.Mask {
+		rd.preds[key(p)] = mask
+	}
+	return rd
+}
+
+// isLiteral checks if the value is a literal, as opposed to a node reference.
+func isLiteral(v quad.Value) bool {
+	switch v.(type) {
+	case nil, quad.IRI, quad.BNode:
+		return false
+	}
+	return true
+}
+
+// Hash returns a keyed hash of the value, as it is written for hashed predicates.
+func (r *Redactor) Hash(v quad.Value) quad.String {
+	var salt []byte
+	if r != nil {
+		salt = r.salt
+	}
+	h := hmac.New(sha256.New, salt)
+	h.Write([]byte(v.String()))
+	return quad.String(HashPrefix + hex.EncodeToString(h.Sum(nil)[:16]))
+}
+
+// Quad applies the rules to a quad. It returns false if the quad must be removed.
+func (r *Redactor) Quad(q quad.Quad) (quad.Quad, bool) {
+	if r == nil {
+		return q, true
+	}
+	if r.labels != nil {
+		if _, ok := r.labels[key(q.Label)]; ok {
+			return q, false
+		}
+	}
+	p := key(q.Predicate)
+	if r.allow != nil {
+		if _, ok := r.allow[p]; !ok {
+			return q, false
+		}
+	}
+	if _, ok := r.deny[p]; ok {
+		return q, false
+	}
+	if !isLiteral(q.Object) {
+		return q, true
+	}
+	switch r.preds[p] {
+	case hash:
+		q.Object = r.Hash(q.Object)
+	case mask:
+		q.Object = r.mask
+	}
+	return q, true
+}
+
+// NewReader returns a reader that applies the rules to quads read from qr.
+// If the redactor is nil, qr is returned as is.
+func (r *Redactor) NewReader(qr quad.Reader) quad.Reader {
+	if r == nil {
+		return qr
+	}
+	return &reader{r: r, qr: qr}
+}
+
+type reader struct {
+	r  *Redactor
+	qr quad.Reader
+}
+
+func (r *reader) ReadQuad() (quad.Quad, error) {
+	for {
+		q, err := r.qr.ReadQuad()
+		if err != nil {
+			return q, err
+		}
+		if q, ok := r.r.Quad(q); ok {
+			return q, nil
+		}
+	}
+}
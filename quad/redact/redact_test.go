@@ -0,0 +1,100 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestRedactor(t *testing.T) {
+	r := New(Rules{
+		Deny:          []quad.Value{quad.IRI("password")},
+		Hash:          []quad.Value{quad.IRI("email")},
+		Mask:          []quad.Value{quad.IRI("phone")},
+		ExcludeLabels: []quad.Value{quad.IRI("private")},
+		Salt:          "secret",
+	})
+	email := quad.Make(quad.IRI("alice"), quad.IRI("email"), "alice@example.com", nil)
+	for _, c := range []struct {
+		name   string
+		in     quad.Quad
+		expect quad.Quad
+		keep   bool
+	}{
+		{
+			name:   "unlisted",
+			in:     quad.MakeIRI("alice", "follows", "bob", ""),
+			expect: quad.MakeIRI("alice", "follows", "bob", ""),
+			keep:   true,
+		},
+		{
+			name: "denied",
+			in:   quad.Make(quad.IRI("alice"), quad.IRI("password"), "123", nil),
+		},
+		{
+			name: "excluded label",
+			in:   quad.MakeIRI("alice", "follows", "bob", "private"),
+		},
+		{
+			name:   "masked",
+			in:     quad.Make(quad.IRI("alice"), quad.IRI("phone"), "+1 555 1234", nil),
+			expect: quad.Make(quad.IRI("alice"), quad.IRI("phone"), DefaultMask, nil),
+			keep:   true,
+		},
+		{
+			name:   "hashed",
+			in:     email,
+			expect: quad.Make(quad.IRI("alice"), quad.IRI("email"), r.Hash(email.Object), nil),
+			keep:   true,
+		},
+		{
+			name:   "node object",
+			in:     quad.MakeIRI("alice", "email", "mailbox", ""),
+			expect: quad.MakeIRI("alice", "email", "mailbox", ""),
+			keep:   true,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			q, ok := r.Quad(c.in)
+			require.Equal(t, c.keep, ok)
+			if ok {
+				require.Equal(t, c.expect, q)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	r1 := New(Rules{Hash: []quad.Value{quad.IRI("email")}, Salt: "a"})
+	r2 := New(Rules{Hash: []quad.Value{quad.IRI("email")}, Salt: "b"})
+	v := quad.String("alice@example.com")
+	h := r1.Hash(v)
+	require.Contains(t, string(h), HashPrefix)
+	require.Equal(t, h, r1.Hash(quad.String("alice@example.com")))
+	require.NotEqual(t, h, r1.Hash(quad.String("bob@example.com")))
+	require.NotEqual(t, h, r2.Hash(v))
+	require.NotEqual(t, h, r1.Hash(quad.LangString{Value: "alice@example.com", Lang: "en"}))
+}
+
+func TestAllow(t *testing.T) {
+	r := New(Rules{Allow: []quad.Value{quad.IRI("follows")}})
+	_, ok := r.Quad(quad.MakeIRI("alice", "follows", "bob", ""))
+	require.True(t, ok)
+	_, ok = r.Quad(quad.MakeIRI("alice", "likes", "bob", ""))
+	require.False(t, ok)
+
+	// nil redactor keeps all quads
+	require.Nil(t, New(Rules{}))
+	q := quad.MakeIRI("alice", "likes", "bob", "")
+	qr := (*Redactor)(nil).NewReader(quad.NewReader([]quad.Quad{q}))
+	quads, err := quad.ReadAll(qr)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Quad{q}, quads)
+
+	qr = r.NewReader(quad.NewReader([]quad.Quad{q, quad.MakeIRI("alice", "follows", "bob", "")}))
+	quads, err = quad.ReadAll(qr)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Quad{quad.MakeIRI("alice", "follows", "bob", "")}, quads)
+}
@@ -0,0 +1,426 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package turtle
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const eof = -1
+
+func (r *Reader) readRune() (rune, error) {
+	if n := len(r.buf); n != 0 {
+		c := r.buf[n-1]
+		r.buf = r.buf[:n-1]
+		return c, nil
+	}
+	c, _, err := r.r.ReadRune()
+	if err != nil {
+		return eof, err
+	}
+	if c == '\n' {
+		r.line++
+	}
+	return c, nil
+}
+
+// readRuneEOF is the same as readRune, but returns eof instead of io.EOF error.
+func (r *Reader) readRuneEOF() (rune, error) {
+	c, err := r.readRune()
+	if err == io.EOF {
+		return eof, nil
+	}
+	return c, err
+}
+
+// unreadRune returns a rune to the input. Multiple runes can be returned in reverse order.
+func (r *Reader) unreadRune(c rune) {
+	if c != eof {
+		r.buf = append(r.buf, c)
+	}
+}
+
+func (r *Reader) next() (token, error) {
+	if r.hasPeek {
+		r.hasPeek = false
+		return r.peek, nil
+	}
+	return r.lex()
+}
+
+func (r *Reader) peekToken() (token, error) {
+	if !r.hasPeek {
+		t, err := r.lex()
+		if err != nil {
+			return t, err
+		}
+		r.peek, r.hasPeek = t, true
+	}
+	return r.peek, nil
+}
+
+// skipSpace skips whitespaces and comments and returns the next rune.
+func (r *Reader) skipSpace() (rune, error) {
+	for {
+		c, err := r.readRuneEOF()
+		if err != nil || c == eof {
+			return c, err
+		}
+		if c == '#' {
+			for c != '\n' && c != eof {
+				if c, err = r.readRuneEOF(); err != nil {
+					return c, err
+				}
+			}
+			continue
+		}
+		if !unicode.IsSpace(c) {
+			return c, nil
+		}
+	}
+}
+
+func (r *Reader) lex() (token, error) {
+	c, err := r.skipSpace()
+	if err != nil {
+		return token{}, err
+	}
+	switch {
+	case c == eof:
+		return token{kind: tokEOF}, nil
+	case c == '<':
+		return r.lexIRI()
+	case c == '"' || c == '\'':
+		return r.lexString(c)
+	case c == '@':
+		var b strings.Builder
+		for {
+			c, err = r.readRuneEOF()
+			if err != nil {
+				return token{}, err
+			}
+			if !isLangChar(c) {
+				r.unreadRune(c)
+				break
+			}
+			b.WriteRune(c)
+		}
+		if b.Len() == 0 {
+			return token{}, fmt.Errorf("expected language tag after '@'")
+		}
+		return token{kind: tokLang, val: b.String()}, nil
+	case c == '^':
+		if c, err = r.readRuneEOF(); err != nil {
+			return token{}, err
+		} else if c != '^' {
+			return token{}, fmt.Errorf("expected '^^'")
+		}
+		return token{kind: tokDatatype}, nil
+	case c == '_':
+		if c, err = r.readRuneEOF(); err != nil {
+			return token{}, err
+		} else if c != ':' {
+			return token{}, fmt.Errorf("expected ':' after '_'")
+		}
+		name, err := r.readName()
+		if err != nil {
+			return token{}, err
+		} else if name == "" {
+			return token{}, fmt.Errorf("empty blank node label")
+		}
+		return token{kind: tokBNode, val: name}, nil
+	case c == '.':
+		// a decimal can start with a dot
+		c2, err := r.readRuneEOF()
+		if err != nil {
+			return token{}, err
+		}
+		r.unreadRune(c2)
+		if isDigit(c2) {
+			r.unreadRune(c)
+			return r.lexNumber()
+		}
+		return token{kind: tokPunct, val: "."}, nil
+	case strings.ContainsRune(";,[](){}", c):
+		return token{kind: tokPunct, val: string(c)}, nil
+	case c == '+' || c == '-' || isDigit(c):
+		r.unreadRune(c)
+		return r.lexNumber()
+	case c == ':' || isNameStart(c):
+		r.unreadRune(c)
+		name, err := r.readName()
+		if err != nil {
+			return token{}, err
+		}
+		if strings.ContainsRune(name, ':') {
+			return token{kind: tokPName, val: name}, nil
+		}
+		return token{kind: tokWord, val: name}, nil
+	}
+	return token{}, fmt.Errorf("unexpected character: %q", c)
+}
+
+func (r *Reader) lexIRI() (token, error) {
+	var b strings.Builder
+	for {
+		c, err := r.readRuneEOF()
+		if err != nil {
+			return token{}, err
+		}
+		switch {
+		case c == '>':
+			return token{kind: tokIRI, val: b.String()}, nil
+		case c == eof || unicode.IsSpace(c):
+			return token{}, fmt.Errorf("unterminated IRI")
+		case c == '\\':
+			e, err := r.readEscape(true)
+			if err != nil {
+				return token{}, err
+			}
+			b.WriteRune(e)
+		default:
+			b.WriteRune(c)
+		}
+	}
+}
+
+func (r *Reader) lexString(q rune) (token, error) {
+	long := false
+	c, err := r.readRuneEOF()
+	if err != nil {
+		return token{}, err
+	}
+	if c == q {
+		if c, err = r.readRuneEOF(); err != nil {
+			return token{}, err
+		} else if c != q {
+			r.unreadRune(c)
+			return token{kind: tokString}, nil // empty string
+		}
+		long = true
+	} else {
+		r.unreadRune(c)
+	}
+	var b strings.Builder
+	for {
+		c, err := r.readRuneEOF()
+		if err != nil {
+			return token{}, err
+		}
+		switch {
+		case c == eof:
+			return token{}, fmt.Errorf("unterminated string")
+		case c == '\\':
+			e, err := r.readEscape(false)
+			if err != nil {
+				return token{}, err
+			}
+			b.WriteRune(e)
+		case c == q:
+			if !long {
+				return token{kind: tokString, val: b.String()}, nil
+			}
+			n := 1
+			for n < 3 {
+				if c, err = r.readRuneEOF(); err != nil {
+					return token{}, err
+				} else if c != q {
+					r.unreadRune(c)
+					break
+				}
+				n++
+			}
+			if n == 3 {
+				return token{kind: tokString, val: b.String()}, nil
+			}
+			b.WriteString(strings.Repeat(string(q), n))
+		case !long && (c == '\n' || c == '\r'):
+			return token{}, fmt.Errorf("new line in a string")
+		default:
+			b.WriteRune(c)
+		}
+	}
+}
+
+// readEscape reads an escape sequence after a backslash. Only unicode escapes are allowed in IRIs.
+func (r *Reader) readEscape(iri bool) (rune, error) {
+	c, err := r.readRuneEOF()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	switch c {
+	case 'u':
+		n = 4
+	case 'U':
+		n = 8
+	}
+	if n == 0 {
+		if !iri {
+			switch c {
+			case 't':
+				return '\t', nil
+			case 'b':
+				return '\b', nil
+			case 'n':
+				return '\n', nil
+			case 'r':
+				return '\r', nil
+			case 'f':
+				return '\f', nil
+			case '"', '\'', '\\':
+				return c, nil
+			}
+		}
+		return 0, fmt.Errorf("invalid escape sequence: '\\%c'", c)
+	}
+	hex := make([]rune, 0, n)
+	for i := 0; i < n; i++ {
+		if c, err = r.readRuneEOF(); err != nil {
+			return 0, err
+		}
+		hex = append(hex, c)
+	}
+	v, err := strconv.ParseUint(string(hex), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid unicode escape: %q", string(hex))
+	}
+	return rune(v), nil
+}
+
+func (r *Reader) lexNumber() (token, error) {
+	var b strings.Builder
+	kind := tokInteger
+	c, err := r.readRuneEOF()
+	if err != nil {
+		return token{}, err
+	}
+	if c == '+' || c == '-' {
+		b.WriteRune(c)
+		if c, err = r.readRuneEOF(); err != nil {
+			return token{}, err
+		}
+	}
+	digits := func() (int, error) {
+		n := 0
+		for isDigit(c) {
+			b.WriteRune(c)
+			n++
+			if c, err = r.readRuneEOF(); err != nil {
+				return n, err
+			}
+		}
+		return n, nil
+	}
+	n, err := digits()
+	if err != nil {
+		return token{}, err
+	}
+	if c == '.' {
+		// a dot may also end a statement
+		c2, err := r.readRuneEOF()
+		if err != nil {
+			return token{}, err
+		}
+		if isDigit(c2) {
+			kind = tokDecimal
+			b.WriteRune(c)
+			c = c2
+			m, err := digits()
+			if err != nil {
+				return token{}, err
+			}
+			n += m
+		} else {
+			r.unreadRune(c2)
+		}
+	}
+	if n == 0 {
+		return token{}, fmt.Errorf("invalid number: %q", b.String())
+	}
+	if c == 'e' || c == 'E' {
+		kind = tokDouble
+		b.WriteRune(c)
+		if c, err = r.readRuneEOF(); err != nil {
+			return token{}, err
+		}
+		if c == '+' || c == '-' {
+			b.WriteRune(c)
+			if c, err = r.readRuneEOF(); err != nil {
+				return token{}, err
+			}
+		}
+		if m, err := digits(); err != nil {
+			return token{}, err
+		} else if m == 0 {
+			return token{}, fmt.Errorf("invalid exponent: %q", b.String())
+		}
+	}
+	r.unreadRune(c)
+	return token{kind: kind, val: b.String()}, nil
+}
+
+// readName reads a prefixed name, a blank node label or a keyword.
+func (r *Reader) readName() (string, error) {
+	var b []rune
+	for {
+		c, err := r.readRuneEOF()
+		if err != nil {
+			return "", err
+		}
+		if c == '\\' {
+			// escaped characters in local names are kept with a backslash, see unescapeLocal
+			e, err := r.readRuneEOF()
+			if err != nil {
+				return "", err
+			} else if e == eof {
+				return "", fmt.Errorf("unexpected end of document")
+			}
+			b = append(b, c, e)
+			continue
+		}
+		if c == eof || !(isNameChar(c) || c == ':' || c == '%') {
+			r.unreadRune(c)
+			break
+		}
+		b = append(b, c)
+	}
+	// names cannot end with a dot
+	for len(b) != 0 && b[len(b)-1] == '.' && (len(b) < 2 || b[len(b)-2] != '\\') {
+		r.unreadRune('.')
+		b = b[:len(b)-1]
+	}
+	return string(b), nil
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLangChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c) || c == '-'
+}
+
+func isNameStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+func isNameChar(c rune) bool {
+	return isNameStart(c) || unicode.IsDigit(c) || c == '-' || c == '.' || c == 0xB7 || unicode.Is(unicode.Mn, c)
+}
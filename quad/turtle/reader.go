@@ -0,0 +1,505 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package turtle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/rdf"
+)
+
+const nsXSD = `http://www.w3.org/2001/XMLSchema#`
+
+const (
+	iriType  = quad.IRI(rdf.NS + `type`)
+	iriFirst = quad.IRI(rdf.NS + `first`)
+	iriRest  = quad.IRI(rdf.NS + `rest`)
+	iriNil   = quad.IRI(rdf.NS + `nil`)
+)
+
+type tokenKind int
+
+const (
+	tokEOF      tokenKind = iota
+	tokIRI                // <iri>
+	tokPName              // prefix:local
+	tokBNode              // _:label
+	tokString             // "string"
+	tokLang               // @lang, @prefix or @base
+	tokDatatype           // ^^
+	tokInteger            // 1
+	tokDecimal            // 1.0
+	tokDouble             // 1e0
+	tokPunct              // . ; , [ ] ( ) { }
+	tokWord               // a, true, false, PREFIX, BASE or GRAPH
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of document"
+	case tokIRI:
+		return "<" + t.val + ">"
+	case tokBNode:
+		return "_:" + t.val
+	case tokString:
+		return strconv.Quote(t.val)
+	case tokLang:
+		return "@" + t.val
+	case tokDatatype:
+		return "^^"
+	}
+	return strconv.Quote(t.val)
+}
+
+func (t token) is(punct string) bool {
+	return t.kind == tokPunct && t.val == punct
+}
+
+// Reader implements parsing of Turtle and TriG documents.
+//
+// Quads are returned as soon as a statement is parsed, thus the document is never loaded into memory as a whole.
+// Typed literals are converted to native values, if possible. Anonymous blank nodes get unique labels.
+type Reader struct {
+	r    *bufio.Reader
+	buf  []rune // runes returned to the input
+	line int
+	trig bool
+
+	base   *url.URL
+	prefix map[string]string
+
+	anonPref string
+	anon     int
+
+	inGraph bool
+	graph   quad.Value
+
+	peek    token
+	hasPeek bool
+
+	queue []quad.Quad
+	err   error
+}
+
+// NewReader returns a Turtle decoder that takes its input from the provided io.Reader.
+func NewReader(r io.Reader) *Reader {
+	return newReader(r, false)
+}
+
+// NewTriGReader returns a TriG decoder that takes its input from the provided io.Reader.
+// Quads in named graphs are returned with a graph name as a label.
+func NewTriGReader(r io.Reader) *Reader {
+	return newReader(r, true)
+}
+
+func newReader(r io.Reader, trig bool) *Reader {
+	return &Reader{
+		r: bufio.NewReader(r), line: 1, trig: trig,
+		prefix: make(map[string]string),
+	}
+}
+
+// ReadQuad returns the next quad from the document, or io.EOF if there are no more quads.
+func (r *Reader) ReadQuad() (quad.Quad, error) {
+	for len(r.queue) == 0 {
+		if r.err != nil {
+			return quad.Quad{}, r.err
+		}
+		if err := r.statement(); err == io.EOF {
+			r.err = err
+		} else if err != nil {
+			r.err = fmt.Errorf("turtle: line %d: %v", r.line, err)
+		}
+	}
+	q := r.queue[0]
+	r.queue = r.queue[1:]
+	return q, nil
+}
+
+func (r *Reader) Close() error { return nil }
+
+func (r *Reader) emit(s, p, o quad.Value) {
+	r.queue = append(r.queue, quad.Quad{Subject: s, Predicate: p, Object: o, Label: r.graph})
+}
+
+func (r *Reader) newBNode() quad.BNode {
+	if r.anonPref == "" {
+		// anonymous nodes must not collide with nodes from other documents
+		r.anonPref = string(quad.RandomBlankNode())
+	}
+	r.anon++
+	return quad.BNode(r.anonPref + "_" + strconv.Itoa(r.anon))
+}
+
+func (r *Reader) statement() error {
+	t, err := r.next()
+	if err != nil {
+		return err
+	}
+	switch {
+	case t.kind == tokEOF:
+		if r.inGraph {
+			return fmt.Errorf("unexpected end of document in a graph")
+		}
+		return io.EOF
+	case t.kind == tokLang && (t.val == "prefix" || t.val == "base"):
+		if err = r.directive(t.val); err != nil {
+			return err
+		}
+		return r.expect(".")
+	case t.kind == tokWord && (strings.EqualFold(t.val, "prefix") || strings.EqualFold(t.val, "base")):
+		return r.directive(strings.ToLower(t.val))
+	case r.trig && t.kind == tokWord && strings.EqualFold(t.val, "graph"):
+		if t, err = r.next(); err != nil {
+			return err
+		}
+		label, err := r.subject(t)
+		if err != nil {
+			return err
+		}
+		if err = r.expect("{"); err != nil {
+			return err
+		}
+		return r.openGraph(label)
+	case r.trig && t.is("{"):
+		return r.openGraph(nil)
+	case r.inGraph && t.is("}"):
+		r.inGraph, r.graph = false, nil
+		return nil
+	}
+	return r.triples(t)
+}
+
+func (r *Reader) openGraph(label quad.Value) error {
+	if r.inGraph {
+		return fmt.Errorf("nested graphs are not allowed")
+	}
+	r.inGraph, r.graph = true, label
+	return nil
+}
+
+func (r *Reader) directive(name string) error {
+	t, err := r.next()
+	if err != nil {
+		return err
+	}
+	var pref string
+	if name == "prefix" {
+		if t.kind != tokPName || !strings.HasSuffix(t.val, ":") {
+			return fmt.Errorf("expected prefix name, got %v", t)
+		}
+		pref = strings.TrimSuffix(t.val, ":")
+		if t, err = r.next(); err != nil {
+			return err
+		}
+	}
+	if t.kind != tokIRI {
+		return fmt.Errorf("expected IRI, got %v", t)
+	}
+	iri := r.resolve(t.val)
+	if name == "prefix" {
+		r.prefix[pref] = iri
+		return nil
+	}
+	r.base, err = url.Parse(iri)
+	return err
+}
+
+func (r *Reader) triples(t token) error {
+	var (
+		subj quad.Value
+		err  error
+		list = true // predicate list is required
+	)
+	switch {
+	case t.is("["):
+		var empty bool
+		subj, empty, err = r.propertyList()
+		if err != nil {
+			return err
+		}
+		if !empty {
+			if t, err = r.peekToken(); err != nil {
+				return err
+			}
+			list = !t.is(".") && !t.is("}")
+		}
+	case t.is("("):
+		subj, err = r.collection()
+	default:
+		subj, err = r.subject(t)
+		if err == nil && r.trig && !r.inGraph {
+			if t, err = r.peekToken(); err == nil && t.is("{") {
+				r.next()
+				return r.openGraph(subj)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if list {
+		if err = r.predicateObjectList(subj); err != nil {
+			return err
+		}
+	}
+	t, err = r.next()
+	if err != nil {
+		return err
+	} else if t.is(".") {
+		return nil
+	} else if r.inGraph && t.is("}") {
+		// the last dot in a graph is optional
+		r.inGraph, r.graph = false, nil
+		return nil
+	}
+	return fmt.Errorf("expected '.', got %v", t)
+}
+
+func (r *Reader) subject(t token) (quad.Value, error) {
+	switch t.kind {
+	case tokIRI, tokPName:
+		return r.iri(t)
+	case tokBNode:
+		return quad.BNode(t.val), nil
+	}
+	return nil, fmt.Errorf("expected IRI or blank node, got %v", t)
+}
+
+func (r *Reader) iri(t token) (quad.IRI, error) {
+	switch t.kind {
+	case tokIRI:
+		return quad.IRI(r.resolve(t.val)), nil
+	case tokPName:
+		i := strings.IndexByte(t.val, ':')
+		pref, local := t.val[:i], t.val[i+1:]
+		ns, ok := r.prefix[pref]
+		if !ok {
+			return "", fmt.Errorf("undefined prefix: %q", pref)
+		}
+		return quad.IRI(ns + unescapeLocal(local)), nil
+	}
+	return "", fmt.Errorf("expected IRI, got %v", t)
+}
+
+func (r *Reader) resolve(iri string) string {
+	if r.base == nil {
+		return iri
+	}
+	u, err := url.Parse(iri)
+	if err != nil || u.IsAbs() {
+		return iri
+	}
+	return r.base.ResolveReference(u).String()
+}
+
+func (r *Reader) predicateObjectList(subj quad.Value) error {
+	for {
+		t, err := r.next()
+		if err != nil {
+			return err
+		}
+		var pred quad.Value
+		if t.kind == tokWord && t.val == "a" {
+			pred = iriType
+		} else if pred, err = r.iri(t); err != nil {
+			return fmt.Errorf("expected predicate, got %v", t)
+		}
+		if err = r.objectList(subj, pred); err != nil {
+			return err
+		}
+		if t, err = r.peekToken(); err != nil || !t.is(";") {
+			return err
+		}
+		for t.is(";") {
+			r.next()
+			if t, err = r.peekToken(); err != nil {
+				return err
+			}
+		}
+		if t.kind == tokEOF || t.is(".") || t.is("]") || t.is("}") {
+			return nil
+		}
+	}
+}
+
+func (r *Reader) objectList(subj, pred quad.Value) error {
+	for {
+		obj, err := r.object()
+		if err != nil {
+			return err
+		}
+		r.emit(subj, pred, obj)
+		t, err := r.peekToken()
+		if err != nil || !t.is(",") {
+			return err
+		}
+		r.next()
+	}
+}
+
+func (r *Reader) object() (quad.Value, error) {
+	t, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	switch t.kind {
+	case tokIRI, tokPName, tokBNode:
+		return r.subject(t)
+	case tokString:
+		return r.literal(t.val)
+	case tokInteger:
+		return typedValue(t.val, nsXSD+"integer"), nil
+	case tokDecimal:
+		return typedValue(t.val, nsXSD+"decimal"), nil
+	case tokDouble:
+		return typedValue(t.val, nsXSD+"double"), nil
+	case tokWord:
+		switch t.val {
+		case "true":
+			return quad.Bool(true), nil
+		case "false":
+			return quad.Bool(false), nil
+		}
+	case tokPunct:
+		switch t.val {
+		case "[":
+			b, _, err := r.propertyList()
+			return b, err
+		case "(":
+			return r.collection()
+		}
+	}
+	return nil, fmt.Errorf("expected object, got %v", t)
+}
+
+func (r *Reader) literal(s string) (quad.Value, error) {
+	t, err := r.peekToken()
+	if err != nil {
+		return nil, err
+	}
+	switch t.kind {
+	case tokLang:
+		r.next()
+		return quad.LangString{Value: quad.String(s), Lang: t.val}, nil
+	case tokDatatype:
+		r.next()
+		if t, err = r.next(); err != nil {
+			return nil, err
+		}
+		dt, err := r.iri(t)
+		if err != nil {
+			return nil, err
+		}
+		return typedValue(s, dt), nil
+	}
+	return quad.String(s), nil
+}
+
+// typedValue converts a typed literal to a native value, if possible.
+func typedValue(s string, dt quad.IRI) quad.Value {
+	v := quad.TypedString{Value: quad.String(s), Type: dt}
+	if nv, err := v.ParseValue(); err == nil {
+		return nv
+	}
+	return v
+}
+
+// propertyList parses a blank node property list. An opening bracket must be already consumed.
+func (r *Reader) propertyList() (quad.Value, bool, error) {
+	b := r.newBNode()
+	t, err := r.peekToken()
+	if err != nil {
+		return nil, false, err
+	} else if t.is("]") {
+		r.next()
+		return b, true, nil
+	}
+	if err = r.predicateObjectList(b); err != nil {
+		return nil, false, err
+	}
+	return b, false, r.expect("]")
+}
+
+// collection parses an RDF list. An opening parenthesis must be already consumed.
+func (r *Reader) collection() (quad.Value, error) {
+	var items []quad.Value
+	for {
+		t, err := r.peekToken()
+		if err != nil {
+			return nil, err
+		} else if t.is(")") {
+			r.next()
+			break
+		}
+		v, err := r.object()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	if len(items) == 0 {
+		return iriNil, nil
+	}
+	head := r.newBNode()
+	cur := head
+	for i, v := range items {
+		r.emit(cur, iriFirst, v)
+		if i == len(items)-1 {
+			r.emit(cur, iriRest, iriNil)
+			break
+		}
+		next := r.newBNode()
+		r.emit(cur, iriRest, next)
+		cur = next
+	}
+	return head, nil
+}
+
+func (r *Reader) expect(punct string) error {
+	t, err := r.next()
+	if err != nil {
+		return err
+	} else if !t.is(punct) {
+		return fmt.Errorf("expected '%s', got %v", punct, t)
+	}
+	return nil
+}
+
+func unescapeLocal(s string) string {
+	if !strings.ContainsRune(s, '\\') {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
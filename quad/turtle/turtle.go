@@ -0,0 +1,44 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package turtle implements reading and writing of RDF 1.1 Turtle and TriG documents.
+//
+// See https://www.w3.org/TR/turtle/ and https://www.w3.org/TR/trig/ for the grammar.
+//
+// Prefixed names and relative IRIs are expanded while reading. Turtle documents have no
+// named graphs, thus all quads are read without a label, and labels are not written by the Turtle writer.
+package turtle
+
+import (
+	"io"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func init() {
+	quad.RegisterFormat(quad.Format{
+		Name:   "turtle",
+		Ext:    []string{".ttl"},
+		Mime:   []string{"text/turtle"},
+		Reader: func(r io.Reader) quad.ReadCloser { return NewReader(r) },
+		Writer: func(w io.Writer) quad.WriteCloser { return NewWriter(w) },
+	})
+	quad.RegisterFormat(quad.Format{
+		Name:   "trig",
+		Ext:    []string{".trig"},
+		Mime:   []string{"application/trig"},
+		Reader: func(r io.Reader) quad.ReadCloser { return NewTriGReader(r) },
+		Writer: func(w io.Writer) quad.WriteCloser { return NewTriGWriter(w) },
+	})
+}
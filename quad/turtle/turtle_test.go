@@ -0,0 +1,204 @@
+package turtle_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/turtle"
+	"github.com/cayleygraph/cayley/voc"
+)
+
+const (
+	rdfNS = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xsdNS = "http://www.w3.org/2001/XMLSchema#"
+)
+
+func readAll(t testing.TB, r quad.Reader) []quad.Quad {
+	var out []quad.Quad
+	for {
+		q, err := r.ReadQuad()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		out = append(out, q)
+	}
+	// anonymous blank nodes have random labels, thus replace them with a sequence
+	names := make(map[quad.BNode]quad.BNode)
+	rename := func(v quad.Value) quad.Value {
+		b, ok := v.(quad.BNode)
+		if !ok || !strings.Contains(string(b), "_") {
+			return v
+		}
+		if nb, ok := names[b]; ok {
+			return nb
+		}
+		nb := quad.BNode("anon" + string(rune('0'+len(names))))
+		names[b] = nb
+		return nb
+	}
+	for i, q := range out {
+		out[i] = quad.Quad{Subject: rename(q.Subject), Predicate: q.Predicate, Object: rename(q.Object), Label: q.Label}
+	}
+	return out
+}
+
+var testReader = []struct {
+	name   string
+	trig   bool
+	data   string
+	expect []quad.Quad
+}{
+	{
+		name: "prefixes and lists",
+		data: `
+@prefix ex: <http://example.org/> .
+PREFIX : <http://example.org/ns#>
+# comment
+ex:bob a :Person ; :name "Bob", "Robert"@en ;
+	:knows ex:alice, _:c . # another comment
+`,
+		expect: []quad.Quad{
+			{Subject: quad.IRI("http://example.org/bob"), Predicate: quad.IRI(rdfNS + "type"), Object: quad.IRI("http://example.org/ns#Person")},
+			{Subject: quad.IRI("http://example.org/bob"), Predicate: quad.IRI("http://example.org/ns#name"), Object: quad.String("Bob")},
+			{Subject: quad.IRI("http://example.org/bob"), Predicate: quad.IRI("http://example.org/ns#name"), Object: quad.LangString{Value: "Robert", Lang: "en"}},
+			{Subject: quad.IRI("http://example.org/bob"), Predicate: quad.IRI("http://example.org/ns#knows"), Object: quad.IRI("http://example.org/alice")},
+			{Subject: quad.IRI("http://example.org/bob"), Predicate: quad.IRI("http://example.org/ns#knows"), Object: quad.BNode("c")},
+		},
+	},
+	{
+		name: "literals",
+		data: `
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+<s> <p> 42, -1.5, 1e3, true, "2017-01-02"^^xsd:date, """multi
+"line" string""", 'single\tquote', "" .
+`,
+		expect: []quad.Quad{
+			{Subject: quad.IRI("s"), Predicate: quad.IRI("p"), Object: quad.Int(42)},
+			{Subject: quad.IRI("s"), Predicate: quad.IRI("p"), Object: quad.TypedString{Value: "-1.5", Type: xsdNS + "decimal"}},
+			{Subject: quad.IRI("s"), Predicate: quad.IRI("p"), Object: quad.Float(1000)},
+			{Subject: quad.IRI("s"), Predicate: quad.IRI("p"), Object: quad.Bool(true)},
+			{Subject: quad.IRI("s"), Predicate: quad.IRI("p"), Object: quad.TypedString{Value: "2017-01-02", Type: xsdNS + "date"}},
+			{Subject: quad.IRI("s"), Predicate: quad.IRI("p"), Object: quad.String("multi\n\"line\" string")},
+			{Subject: quad.IRI("s"), Predicate: quad.IRI("p"), Object: quad.String("single\tquote")},
+			{Subject: quad.IRI("s"), Predicate: quad.IRI("p"), Object: quad.String("")},
+		},
+	},
+	{
+		name: "blank nodes and collections",
+		data: `
+@base <http://example.org/> .
+[ <name> "x" ] <items> ( 1 <b> ) .
+<a> <empty> () ; <anon> [] .
+`,
+		expect: []quad.Quad{
+			{Subject: quad.BNode("anon0"), Predicate: quad.IRI("http://example.org/name"), Object: quad.String("x")},
+			{Subject: quad.BNode("anon1"), Predicate: quad.IRI(rdfNS + "first"), Object: quad.Int(1)},
+			{Subject: quad.BNode("anon1"), Predicate: quad.IRI(rdfNS + "rest"), Object: quad.BNode("anon2")},
+			{Subject: quad.BNode("anon2"), Predicate: quad.IRI(rdfNS + "first"), Object: quad.IRI("http://example.org/b")},
+			{Subject: quad.BNode("anon2"), Predicate: quad.IRI(rdfNS + "rest"), Object: quad.IRI(rdfNS + "nil")},
+			{Subject: quad.BNode("anon0"), Predicate: quad.IRI("http://example.org/items"), Object: quad.BNode("anon1")},
+			{Subject: quad.IRI("http://example.org/a"), Predicate: quad.IRI("http://example.org/empty"), Object: quad.IRI(rdfNS + "nil")},
+			{Subject: quad.IRI("http://example.org/a"), Predicate: quad.IRI("http://example.org/anon"), Object: quad.BNode("anon3")},
+		},
+	},
+	{
+		name: "named graphs",
+		trig: true,
+		data: `
+@prefix ex: <http://example.org/> .
+ex:a ex:p ex:b .
+GRAPH ex:g1 { ex:a ex:p ex:c }
+ex:g2 {
+	ex:a ex:p ex:d .
+	ex:d ex:p ex:e .
+}
+{ ex:a ex:p ex:f }
+`,
+		expect: []quad.Quad{
+			{Subject: quad.IRI("http://example.org/a"), Predicate: quad.IRI("http://example.org/p"), Object: quad.IRI("http://example.org/b")},
+			{Subject: quad.IRI("http://example.org/a"), Predicate: quad.IRI("http://example.org/p"), Object: quad.IRI("http://example.org/c"), Label: quad.IRI("http://example.org/g1")},
+			{Subject: quad.IRI("http://example.org/a"), Predicate: quad.IRI("http://example.org/p"), Object: quad.IRI("http://example.org/d"), Label: quad.IRI("http://example.org/g2")},
+			{Subject: quad.IRI("http://example.org/d"), Predicate: quad.IRI("http://example.org/p"), Object: quad.IRI("http://example.org/e"), Label: quad.IRI("http://example.org/g2")},
+			{Subject: quad.IRI("http://example.org/a"), Predicate: quad.IRI("http://example.org/p"), Object: quad.IRI("http://example.org/f")},
+		},
+	},
+}
+
+func TestReader(t *testing.T) {
+	for _, c := range testReader {
+		t.Run(c.name, func(t *testing.T) {
+			var r quad.Reader
+			if c.trig {
+				r = turtle.NewTriGReader(strings.NewReader(c.data))
+			} else {
+				r = turtle.NewReader(strings.NewReader(c.data))
+			}
+			require.Equal(t, c.expect, readAll(t, r))
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	for _, data := range []string{
+		`ex:a <p> <o> .`,
+		`<s> <p> "unterminated .`,
+		`<s> <p> <o>`,
+		`<s> <p> <o> { <a> <b> <c> } .`,
+	} {
+		r := turtle.NewReader(strings.NewReader(data))
+		var err error
+		for err == nil {
+			_, err = r.ReadQuad()
+		}
+		require.NotEqual(t, io.EOF, err, "%q", data)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var ns voc.Namespaces
+	ns.Register(voc.Namespace{Full: "http://example.org/", Prefix: "ex:"})
+	quads := []quad.Quad{
+		{Subject: quad.IRI("http://example.org/bob"), Predicate: quad.IRI(rdfNS + "type"), Object: quad.IRI("http://example.org/Person")},
+		{Subject: quad.IRI("http://example.org/bob"), Predicate: quad.IRI("http://example.org/name"), Object: quad.String("Bob")},
+		{Subject: quad.IRI("http://example.org/bob"), Predicate: quad.IRI("http://example.org/name"), Object: quad.LangString{Value: "Robert", Lang: "en"}},
+		{Subject: quad.BNode("x"), Predicate: quad.IRI("http://example.org/age"), Object: quad.Int(42)},
+		{Subject: quad.BNode("x"), Predicate: quad.IRI("http://example.org/p"), Object: quad.IRI("http://example.org/a.b."), Label: quad.IRI("http://example.org/g")},
+	}
+	var buf bytes.Buffer
+	w := turtle.NewTriGWriter(&buf)
+	w.SetNamespaces(&ns)
+	for _, q := range quads {
+		require.NoError(t, w.WriteQuad(q))
+	}
+	require.NoError(t, w.Close())
+	require.Equal(t, `@prefix ex: <http://example.org/> .
+
+ex:bob a ex:Person ;
+	ex:name "Bob" ,
+		"Robert"@en .
+_:x ex:age 42 .
+ex:g {
+	_:x ex:p <http://example.org/a.b.> .
+}
+`, buf.String())
+
+	got := readAll(t, turtle.NewTriGReader(&buf))
+	require.Equal(t, quads, got)
+
+	// labels are dropped in Turtle
+	buf.Reset()
+	w = turtle.NewWriter(&buf)
+	for _, q := range quads {
+		require.NoError(t, w.WriteQuad(q))
+	}
+	require.NoError(t, w.Close())
+	got = readAll(t, turtle.NewReader(&buf))
+	require.Len(t, got, len(quads))
+	require.Nil(t, got[len(got)-1].Label)
+}
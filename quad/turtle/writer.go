@@ -0,0 +1,202 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package turtle
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc"
+)
+
+// NewWriter returns a Turtle encoder that writes its output to the provided io.Writer.
+// Labels of quads are not written, since Turtle documents have no named graphs.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// NewTriGWriter returns a TriG encoder that writes its output to the provided io.Writer.
+// Quads with a label are written to named graphs.
+func NewTriGWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), trig: true}
+}
+
+// Writer implements Turtle and TriG document generator.
+//
+// Consecutive quads with the same subject (and predicate) are grouped into a single statement,
+// thus sorted input produces more compact output.
+type Writer struct {
+	w    *bufio.Writer
+	trig bool
+	ns   *voc.Namespaces
+
+	started bool
+	inGraph bool
+	graph   quad.Value
+	subj    quad.Value
+	pred    quad.Value
+	err     error
+}
+
+// SetNamespaces sets prefixes that are used to shorten IRIs. It must be called before writing any quads.
+func (w *Writer) SetNamespaces(ns *voc.Namespaces) {
+	w.ns = ns
+}
+
+func (w *Writer) writeString(s string) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.WriteString(s)
+}
+
+func (w *Writer) writeValue(v quad.Value) {
+	switch v := v.(type) {
+	case quad.IRI:
+		if w.ns != nil {
+			if s := w.ns.ShortIRI(string(v)); s != string(v) && isPName(s) {
+				w.writeString(s)
+				return
+			}
+		}
+		w.writeString(v.Full().String())
+		return
+	case quad.Int:
+		w.writeString(strconv.FormatInt(int64(v), 10))
+		return
+	case quad.Bool:
+		w.writeString(strconv.FormatBool(bool(v)))
+		return
+	case quad.Float:
+		if f := float64(v); !math.IsInf(f, 0) && !math.IsNaN(f) {
+			w.writeString(strconv.FormatFloat(f, 'E', -1, 64))
+			return
+		}
+	}
+	if ts, ok := v.(quad.TypedStringer); ok {
+		v = ts.TypedString()
+	}
+	if ts, ok := v.(quad.TypedString); ok {
+		// types of native values may be stored in a short form
+		w.writeString(ts.Value.String() + "^^")
+		w.writeValue(ts.Type)
+		return
+	}
+	w.writeString(v.String())
+}
+
+// isPName checks if a shortened IRI can be written as a prefixed name without escaping.
+func isPName(s string) bool {
+	i := strings.IndexByte(s, ':')
+	if i < 0 || strings.HasSuffix(s, ".") {
+		return false
+	}
+	for j, c := range s {
+		if j == i {
+			continue
+		}
+		if !isNameChar(c) || (j == i+1 && c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *Writer) writePrefixes() {
+	if w.ns == nil {
+		return
+	}
+	list := w.ns.List()
+	for _, n := range list {
+		w.writeString("@prefix " + n.Prefix + " " + quad.IRI(n.Full).String() + " .\n")
+	}
+	if len(list) != 0 {
+		w.writeString("\n")
+	}
+}
+
+// endStatement finishes the current statement, if any.
+func (w *Writer) endStatement() {
+	if w.subj != nil {
+		w.writeString(" .\n")
+	}
+	w.subj, w.pred = nil, nil
+}
+
+func (w *Writer) endGraph() {
+	w.endStatement()
+	if w.inGraph {
+		w.writeString("}\n")
+	}
+	w.inGraph, w.graph = false, nil
+}
+
+func (w *Writer) WriteQuad(q quad.Quad) error {
+	if !w.started {
+		w.started = true
+		w.writePrefixes()
+	}
+	if w.trig && quad.ToString(q.Label) != quad.ToString(w.graph) {
+		w.endGraph()
+		if q.Label != nil {
+			w.writeValue(q.Label)
+			w.writeString(" {\n")
+			w.inGraph, w.graph = true, q.Label
+		}
+	}
+	indent := ""
+	if w.inGraph {
+		indent = "\t"
+	}
+	switch {
+	case w.subj != nil && quad.ToString(q.Subject) == quad.ToString(w.subj) &&
+		quad.ToString(q.Predicate) == quad.ToString(w.pred):
+		w.writeString(" ,\n" + indent + "\t\t")
+	case w.subj != nil && quad.ToString(q.Subject) == quad.ToString(w.subj):
+		w.writeString(" ;\n" + indent + "\t")
+		w.writePredicate(q.Predicate)
+		w.writeString(" ")
+	default:
+		w.endStatement()
+		w.writeString(indent)
+		w.writeValue(q.Subject)
+		w.writeString(" ")
+		w.writePredicate(q.Predicate)
+		w.writeString(" ")
+	}
+	w.writeValue(q.Object)
+	w.subj, w.pred = q.Subject, q.Predicate
+	return w.err
+}
+
+func (w *Writer) writePredicate(p quad.Value) {
+	if iri, ok := p.(quad.IRI); ok && iri.Full() == iriType {
+		w.writeString("a")
+		return
+	}
+	w.writeValue(p)
+}
+
+func (w *Writer) Close() error {
+	w.endGraph()
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
@@ -310,6 +310,13 @@ func RegisterStringConversion(dataType IRI, fnc StringConversion) {
 	}
 }
 
+// HasStringConversion checks if TypedString values with a given type are converted to a native equivalent.
+// See RegisterStringConversion.
+func HasStringConversion(dataType IRI) bool {
+	_, ok := knownConversions[dataType.Full()]
+	return ok
+}
+
 func stringToInt(s string) (Value, error) {
 	v, err := strconv.ParseInt(s, 10, 64)
 	if err != nil {
@@ -0,0 +1,151 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vector implements float vector literals, such as embeddings of nodes.
+//
+// Vectors are stored as typed string literals of cayley:vector type, with components written
+// as a JSON array, for example "[0.1, 0.2, 0.3]"^^<cayley:vector>.
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Type is a data type of vector literals.
+const Type = quad.IRI("cayley:vector")
+
+// ErrNotVector is returned when a value is not a vector literal.
+var ErrNotVector = errors.New("vector: not a vector literal")
+
+// Vector is a list of float components.
+type Vector []float32
+
+// String returns vector components as a JSON array.
+func (v Vector) String() string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, f := range v {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(strconv.FormatFloat(float64(f), 'g', -1, 32))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+// TypedString returns the vector as a typed literal.
+func (v Vector) TypedString() quad.TypedString {
+	return quad.TypedString{Value: quad.String(v.String()), Type: Type}
+}
+
+// Norm returns the Euclidean length of the vector.
+func (v Vector) Norm() float64 {
+	return math.Sqrt(Dot(v, v))
+}
+
+// Parse parses vector components written as a JSON array.
+func Parse(s string) (Vector, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("vector: invalid vector: %q", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if s == "" {
+		return nil, errors.New("vector: empty vector")
+	}
+	parts := strings.Split(s, ",")
+	out := make(Vector, 0, len(parts))
+	for _, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 32)
+		if err != nil {
+			return nil, fmt.Errorf("vector: invalid component: %q", p)
+		} else if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("vector: component is not finite: %q", p)
+		}
+		out = append(out, float32(f))
+	}
+	return out, nil
+}
+
+// FromValue parses a vector literal.
+func FromValue(v quad.Value) (Vector, error) {
+	ts, ok := v.(quad.TypedString)
+	if !ok || ts.Type.Full() != Type {
+		return nil, ErrNotVector
+	}
+	return Parse(string(ts.Value))
+}
+
+// Dot returns the dot product of two vectors. Vectors must have the same length.
+func Dot(a, b Vector) float64 {
+	var s float64
+	for i := range a {
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}
+
+// Metric is a distance function between vectors. Smaller values mean more similar vectors.
+type Metric string
+
+const (
+	// Cosine distance is 1 minus the cosine of the angle between vectors.
+	Cosine = Metric("cosine")
+	// Euclidean is the straight-line distance between vectors.
+	Euclidean = Metric("euclidean")
+	// DotProduct distance is the negated dot product of vectors.
+	DotProduct = Metric("dot")
+)
+
+// DefaultMetric is used if the metric is not set.
+const DefaultMetric = Cosine
+
+// ParseMetric checks the name of the metric. Empty name is parsed as DefaultMetric.
+func ParseMetric(s string) (Metric, error) {
+	switch m := Metric(s); m {
+	case "":
+		return DefaultMetric, nil
+	case Cosine, Euclidean, DotProduct:
+		return m, nil
+	}
+	return "", fmt.Errorf("vector: unknown metric: %q", s)
+}
+
+// Distance returns the distance between vectors. Vectors must have the same length.
+func (m Metric) Distance(a, b Vector) float64 {
+	switch m {
+	case Euclidean:
+		var s float64
+		for i := range a {
+			d := float64(a[i]) - float64(b[i])
+			s += d * d
+		}
+		return math.Sqrt(s)
+	case DotProduct:
+		return -Dot(a, b)
+	default:
+		na, nb := a.Norm(), b.Norm()
+		if na == 0 || nb == 0 {
+			return 1
+		}
+		return 1 - Dot(a, b)/(na*nb)
+	}
+}
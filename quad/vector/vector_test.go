@@ -0,0 +1,49 @@
+package vector
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse(" [1, -0.5,2e3 ] ")
+	require.NoError(t, err)
+	require.Equal(t, Vector{1, -0.5, 2000}, v)
+	require.Equal(t, "[1, -0.5, 2000]", v.String())
+
+	for _, s := range []string{"", "[]", "1, 2", "[1, x]", "[1,,2]", "[NaN]"} {
+		_, err = Parse(s)
+		require.Error(t, err, "%q", s)
+	}
+}
+
+func TestFromValue(t *testing.T) {
+	v := Vector{0.25, 1}
+	got, err := FromValue(v.TypedString())
+	require.NoError(t, err)
+	require.Equal(t, v, got)
+
+	_, err = FromValue(quad.String("[1, 2]"))
+	require.Equal(t, ErrNotVector, err)
+	_, err = FromValue(quad.TypedString{Value: "[1, 2]", Type: "xsd:string"})
+	require.Equal(t, ErrNotVector, err)
+}
+
+func TestMetric(t *testing.T) {
+	a, b := Vector{1, 0}, Vector{0, 2}
+	require.InDelta(t, 1, Cosine.Distance(a, b), 1e-9)
+	require.InDelta(t, 0, Cosine.Distance(a, Vector{3, 0}), 1e-9)
+	require.InDelta(t, 1, Cosine.Distance(a, Vector{0, 0}), 1e-9)
+	require.InDelta(t, math.Sqrt(5), Euclidean.Distance(a, b), 1e-9)
+	require.InDelta(t, -3, DotProduct.Distance(Vector{1, 1}, Vector{1, 2}), 1e-9)
+
+	m, err := ParseMetric("")
+	require.NoError(t, err)
+	require.Equal(t, DefaultMetric, m)
+	_, err = ParseMetric("manhattan")
+	require.Error(t, err)
+}
@@ -0,0 +1,358 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cypher
+
+import (
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/rdf"
+)
+
+// iri converts a label, a relationship type or a property name to an IRI.
+// Registered namespace prefixes are expanded, thus `rdf:type` can be used as well.
+func iri(name string) quad.IRI {
+	return quad.IRI(name).Full()
+}
+
+// notEqual is a value filter for the <> operator.
+type notEqual struct {
+	val quad.Value
+}
+
+func (f notEqual) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	s := quad.StringOf(f.val)
+	return iterator.NewValueFilter(qs, it, "not equal", func(v quad.Value) (bool, error) {
+		return v != nil && quad.StringOf(v) != s, nil
+	})
+}
+
+var comparisons = map[string]iterator.Operator{
+	"<": iterator.CompareLT, "<=": iterator.CompareLTE, ">": iterator.CompareGT, ">=": iterator.CompareGTE,
+}
+
+// column is a column of query results.
+type column struct {
+	name   string
+	tag    string // tag with the value, empty for count(*)
+	item   returnItem
+	hidden bool // only used for ordering
+}
+
+// plan is a compiled query: a path that finds all matches, and the way to convert them to result rows.
+type plan struct {
+	q    *statement
+	path *path.Path
+	// eq lists pairs of tags that must have the same value;
+	// it is used when a variable appears more than once in patterns
+	eq   [][2]string
+	cols []column
+	agg  bool // some columns are aggregates
+}
+
+// compiler keeps the state of variables while patterns are converted to a path.
+type compiler struct {
+	q     *statement
+	p     *path.Path
+	nodes map[string]bool // names of node variables
+	rels  map[string]bool // names of relationship variables
+	bound map[string]bool // variables that were already visited
+	conds map[string][]condition
+	saves map[string][]string // properties of nodes that are returned
+	eq    [][2]string
+	at    string // variable of the current node of the path
+}
+
+// compile converts a parsed query to a plan for a given QuadStore.
+func compile(qs graph.QuadStore, q *statement) (*plan, error) {
+	c := &compiler{
+		q:     q,
+		p:     path.StartPath(qs),
+		nodes: make(map[string]bool),
+		rels:  make(map[string]bool),
+		bound: make(map[string]bool),
+		conds: make(map[string][]condition),
+		saves: make(map[string][]string),
+	}
+	var names []string
+	for _, pt := range q.patterns {
+		for _, n := range pt.nodes {
+			if n.name != "" && !c.nodes[n.name] {
+				c.nodes[n.name] = true
+				names = append(names, n.name)
+			}
+		}
+		for _, r := range pt.rels {
+			if r.name == "" {
+				continue
+			} else if c.rels[r.name] || c.nodes[r.name] {
+				return nil, fmt.Errorf("cypher: relationship variable %s is used more than once", r.name)
+			}
+			c.rels[r.name] = true
+			names = append(names, r.name)
+		}
+	}
+	for _, cond := range q.where {
+		if err := c.checkOperand(cond.left); err != nil {
+			return nil, err
+		} else if c.rels[cond.left.name] {
+			return nil, fmt.Errorf("cypher: conditions on relationships are not supported, use relationship types instead")
+		}
+		c.conds[cond.left.name] = append(c.conds[cond.left.name], cond)
+	}
+	pl := &plan{q: q}
+	if q.star {
+		if len(names) == 0 {
+			return nil, fmt.Errorf("cypher: RETURN * requires named variables")
+		}
+		for _, name := range names {
+			pl.cols = append(pl.cols, column{name: name, tag: name, item: returnItem{op: operand{name: name}}})
+		}
+	}
+	for _, r := range q.ret {
+		col, err := c.column(r)
+		if err != nil {
+			return nil, err
+		}
+		pl.cols = append(pl.cols, col)
+		pl.agg = pl.agg || r.count
+	}
+	for _, o := range q.order {
+		if pl.findColumn(o.item) >= 0 {
+			continue
+		} else if pl.agg || q.distinct {
+			return nil, fmt.Errorf("cypher: ORDER BY %s must refer to a returned column", o.item.text())
+		}
+		col, err := c.column(o.item)
+		if err != nil {
+			return nil, err
+		}
+		col.hidden = true
+		pl.cols = append(pl.cols, col)
+	}
+	for i, pt := range q.patterns {
+		if err := c.pattern(i, pt); err != nil {
+			return nil, err
+		}
+	}
+	pl.path, pl.eq = c.p, c.eq
+	return pl, nil
+}
+
+func (c *compiler) checkOperand(o operand) error {
+	if !c.nodes[o.name] && !c.rels[o.name] {
+		return fmt.Errorf("cypher: variable %s is not defined", o.name)
+	} else if o.prop != "" && c.rels[o.name] {
+		return fmt.Errorf("cypher: relationships have no properties: %s", o)
+	}
+	return nil
+}
+
+// column creates a result column for an expression and records properties that must be saved.
+func (c *compiler) column(r returnItem) (column, error) {
+	col := column{name: r.column(), item: r}
+	if r.countAll {
+		return col, nil
+	}
+	if err := c.checkOperand(r.op); err != nil {
+		return col, err
+	}
+	col.tag = r.op.String()
+	if r.op.prop != "" {
+		props := c.saves[r.op.name]
+		for _, p := range props {
+			if p == r.op.prop {
+				return col, nil
+			}
+		}
+		c.saves[r.op.name] = append(props, r.op.prop)
+	}
+	return col, nil
+}
+
+// findColumn returns an index of a column with the same expression or alias, or -1.
+func (pl *plan) findColumn(r returnItem) int {
+	for i, col := range pl.cols {
+		if col.item.text() == r.text() || (!r.count && r.op.prop == "" && col.name == r.op.name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// pattern adds a pattern to the path. Patterns after the first one must share a variable with previous patterns.
+func (c *compiler) pattern(i int, pt pattern) error {
+	k := -1
+	for j, n := range pt.nodes {
+		if n.name != "" && c.bound[n.name] {
+			k = j
+			break
+		}
+	}
+	if k < 0 && i > 0 {
+		return fmt.Errorf("cypher: disconnected patterns are not supported")
+	} else if k < 0 {
+		k = 0
+	} else {
+		// continue from a node that was already matched
+		c.back(pt.nodes[k].name)
+	}
+	c.node(pt.nodes[k], true)
+	for j := k; j < len(pt.rels); j++ {
+		if err := c.rel(pt.rels[j], false); err != nil {
+			return err
+		}
+		c.node(pt.nodes[j+1], false)
+	}
+	if k > 0 {
+		// walk the rest of the pattern backward
+		c.back(pt.nodes[k].name)
+		for j := k - 1; j >= 0; j-- {
+			if err := c.rel(pt.rels[j], true); err != nil {
+				return err
+			}
+			c.node(pt.nodes[j], false)
+		}
+	}
+	return nil
+}
+
+// back returns the path to a node bound to a given variable.
+func (c *compiler) back(name string) {
+	if c.at != name {
+		c.p = c.p.Back(name)
+		c.at = name
+	}
+}
+
+// node adds constraints of a node pattern at the current position of the path.
+// If the variable was already bound, at is set when the path is known to be at the same node.
+func (c *compiler) node(n nodePattern, at bool) {
+	for _, l := range n.labels {
+		c.p = c.p.Has(quad.IRI(rdf.Type), iri(l))
+	}
+	for _, pr := range n.props {
+		c.p = c.p.Has(iri(pr.key), pr.val)
+	}
+	c.at = n.name
+	if n.name == "" {
+		return
+	} else if c.bound[n.name] {
+		if !at {
+			// the same node must be matched again; it is checked when results are produced
+			tag := fmt.Sprintf("%s#%d", n.name, len(c.eq))
+			c.p = c.p.Tag(tag)
+			c.eq = append(c.eq, [2]string{n.name, tag})
+		}
+		return
+	}
+	c.bound[n.name] = true
+	for _, cond := range c.conds[n.name] {
+		c.p = c.condition(c.p, cond)
+	}
+	c.p = c.p.Tag(n.name)
+	for _, prop := range c.saves[n.name] {
+		c.p = c.p.SaveOptional(iri(prop), n.name+"."+prop)
+	}
+}
+
+// condition adds a WHERE condition on the current node of the path.
+func (c *compiler) condition(p *path.Path, cond condition) *path.Path {
+	var filt shape.ValueFilter
+	switch cond.op {
+	case "=", "IN":
+		if cond.left.prop == "" {
+			return p.Is(cond.vals...)
+		}
+		return p.Has(iri(cond.left.prop), cond.vals...)
+	case "<>":
+		filt = notEqual{val: cond.vals[0]}
+	case "=~", "STARTS", "ENDS", "CONTAINS":
+		// node identifiers are IRIs
+		filt = shape.Regexp{Re: cond.re, Refs: cond.left.prop == ""}
+	default:
+		filt = shape.Comparison{Op: comparisons[cond.op], Val: cond.vals[0]}
+	}
+	if cond.left.prop == "" {
+		return p.Filters(filt)
+	}
+	return p.HasFilter(iri(cond.left.prop), false, filt)
+}
+
+// rel follows a relationship from the current node of the path. If rev is set, the pattern is walked backward.
+func (c *compiler) rel(r relPattern, rev bool) error {
+	c.at = ""
+	dir := r.dir
+	if rev && dir == dirOut {
+		dir = dirIn
+	} else if rev && dir == dirIn {
+		dir = dirOut
+	}
+	via := make([]interface{}, 0, len(r.types))
+	for _, t := range r.types {
+		via = append(via, iri(t))
+	}
+	if !r.varLen {
+		var tags []string
+		if r.name != "" {
+			tags = []string{r.name}
+			c.bound[r.name] = true
+		}
+		switch dir {
+		case dirOut:
+			c.p = c.p.OutWithTags(tags, via...)
+		case dirIn:
+			c.p = c.p.InWithTags(tags, via...)
+		default:
+			c.p = c.p.BothWithTags(tags, via...)
+		}
+		return nil
+	}
+	if r.name != "" {
+		return fmt.Errorf("cypher: variables of variable-length relationships are not supported")
+	} else if r.min == 0 {
+		return fmt.Errorf("cypher: zero-length relationships are not supported")
+	}
+	m := path.StartMorphism()
+	switch dir {
+	case dirOut:
+		m = m.Out(via...)
+	case dirIn:
+		m = m.In(via...)
+	default:
+		m = m.Both(via...)
+	}
+	for i := 1; i < r.min; i++ {
+		c.p = c.p.Follow(m)
+	}
+	if r.max < 0 {
+		// recursion reaches each node only once, even if it can be reached from multiple start nodes
+		c.p = c.p.FollowRecursive(m, -1, nil)
+		return nil
+	}
+	// a union of paths of all lengths in the range, thus all start nodes are matched
+	next := c.p.Follow(m)
+	out := next
+	for i := r.min; i < r.max; i++ {
+		next = next.Follow(m)
+		out = out.Or(next)
+	}
+	c.p = out
+	return nil
+}
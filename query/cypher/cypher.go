@@ -0,0 +1,183 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cypher implements a subset of openCypher query language.
+//
+// Supported clauses are MATCH with node and relationship patterns (including variable-length relationships),
+// WHERE with comparisons joined by AND, and RETURN with DISTINCT, count aggregation, ORDER BY, SKIP and LIMIT.
+//
+// Graph data is mapped to Cypher concepts as follows: nodes are graph nodes, node labels are rdf:type values,
+// node properties are values of outgoing predicates, and relationship types are predicates.
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+)
+
+const Name = "cypher"
+
+func init() {
+	query.RegisterLanguage(query.Language{
+		Name: Name,
+		Session: func(qs graph.QuadStore) query.Session {
+			return NewSession(qs)
+		},
+		HTTP: func(qs graph.QuadStore) query.HTTP {
+			return NewSession(qs)
+		},
+		REPL: func(qs graph.QuadStore) query.REPLSession {
+			return NewSession(qs)
+		},
+	})
+}
+
+// Session runs Cypher queries. Each result is a map of column names to graph.PreFetched values.
+type Session struct {
+	qs graph.QuadStore
+
+	// used only to collate web results
+	rows []interface{}
+	err  error
+}
+
+func NewSession(qs graph.QuadStore) *Session {
+	return &Session{qs: qs}
+}
+
+func (s *Session) compile(qu string) (*plan, error) {
+	q, err := parse(qu)
+	if err != nil {
+		return nil, err
+	}
+	return compile(s.qs, q)
+}
+
+func (s *Session) Execute(ctx context.Context, qu string, out chan query.Result, limit int) {
+	defer close(out)
+	_, end := graph.StartSpan(ctx, "parse")
+	pl, err := s.compile(qu)
+	end(err)
+	if err == nil {
+		cols := pl.cols[:pl.visible()]
+		err = pl.run(ctx, s.qs, limit, func(r row) bool {
+			tags := make(map[string]graph.Value, len(r))
+			for i, v := range r {
+				if v != nil {
+					tags[cols[i].name] = graph.PreFetched(v)
+				}
+			}
+			select {
+			case out <- query.TagMapResult(tags):
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}
+	if err != nil {
+		select {
+		case out <- query.ErrorResult(err):
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (s *Session) FormatREPL(result query.Result) string {
+	if err := result.Err(); err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	tags, ok := result.Result().(map[string]graph.Value)
+	if !ok {
+		return fmt.Sprintf("Error: unexpected result type: %T\n", result)
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := fmt.Sprintln("****")
+	for _, k := range keys {
+		out += fmt.Sprintf("%s : %s\n", k, quad.StringOf(s.qs.NameOf(tags[k])))
+	}
+	return out
+}
+
+// Web stuff
+
+func (s *Session) ShapeOf(qu string) (interface{}, error) {
+	pl, err := s.compile(qu)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{})
+	iterator.OutputQueryShapeForIterator(pl.path.BuildIteratorOn(s.qs), s.qs, out)
+	return out, nil
+}
+
+func (s *Session) Collate(result query.Result) {
+	if err := result.Err(); err != nil {
+		s.err = err
+		return
+	}
+	if row, ok := s.Row(result); ok {
+		s.rows = append(s.rows, row)
+	}
+}
+
+var _ query.StreamingHTTP = (*Session)(nil)
+
+// Row implements query.StreamingHTTP.
+func (s *Session) Row(result query.Result) (interface{}, bool) {
+	tags, ok := result.Result().(map[string]graph.Value)
+	if !ok {
+		return nil, false
+	}
+	row := make(map[string]interface{}, len(tags))
+	for k, v := range tags {
+		row[k] = toNative(s.qs.NameOf(v))
+	}
+	return row, true
+}
+
+func (s *Session) Results() (interface{}, error) {
+	defer s.Clear()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.rows, nil
+}
+
+func (s *Session) Clear() {
+	s.rows, s.err = nil, nil
+}
+
+// toNative converts values to JSON-friendly types. IRIs and other values without a native
+// representation are returned in the same notation as in the query text.
+func toNative(v quad.Value) interface{} {
+	if v == nil {
+		return nil
+	}
+	out := v.Native()
+	if nv, ok := out.(quad.Value); ok && v == nv {
+		return quad.StringOf(v)
+	}
+	return out
+}
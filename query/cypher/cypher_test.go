@@ -0,0 +1,261 @@
+package cypher
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest/testutil"
+	_ "github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/voc/rdf"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+var peopleGraph = []quad.Quad{
+	quad.MakeIRI("alice", rdf.Type, "Person", ""),
+	quad.MakeIRI("bob", rdf.Type, "Person", ""),
+	quad.MakeIRI("charlie", rdf.Type, "Person", ""),
+	quad.MakeIRI("acme", rdf.Type, "Company", ""),
+	quad.Make(quad.IRI("alice"), quad.IRI("name"), "Alice", nil),
+	quad.Make(quad.IRI("bob"), quad.IRI("name"), "Bob", nil),
+	quad.Make(quad.IRI("charlie"), quad.IRI("name"), "Charlie", nil),
+	quad.Make(quad.IRI("alice"), quad.IRI("age"), 31, nil),
+	quad.Make(quad.IRI("bob"), quad.IRI("age"), 25, nil),
+	quad.Make(quad.IRI("charlie"), quad.IRI("age"), 42, nil),
+	quad.MakeIRI("alice", "knows", "bob", ""),
+	quad.MakeIRI("bob", "knows", "charlie", ""),
+	quad.MakeIRI("charlie", "knows", "alice", ""),
+	quad.MakeIRI("alice", "worksAt", "acme", ""),
+	quad.MakeIRI("bob", "worksAt", "acme", ""),
+}
+
+var testQueries = []struct {
+	message string
+	data    []quad.Quad
+	query   string
+	expect  []string
+	ordered bool
+	err     bool
+}{
+	{
+		message: "all followers",
+		query:   `MATCH (a)-[:follows]->(b {status: "cool_person"}) RETURN a`,
+		expect:  []string{"a=<alice>", "a=<charlie>", "a=<dani>", "a=<charlie>", "a=<dani>", "a=<fred>"},
+	},
+	{
+		message: "incoming relationship",
+		query:   `MATCH (b)<-[:follows]-(a) WHERE id(b) = "<bob>" RETURN a`,
+		expect:  []string{"a=<alice>", "a=<charlie>", "a=<dani>"},
+	},
+	{
+		message: "relationship variable",
+		query:   `MATCH (a)-[r]->(b) WHERE id(a) = "<bob>" RETURN r, b`,
+		expect:  []string{"b=<fred> r=<follows>", "b=cool_person r=<status>"},
+	},
+	{
+		message: "undirected relationship",
+		query:   `MATCH (a)-[:follows]-(b) WHERE id(a) = "<fred>" RETURN b`,
+		expect:  []string{"b=<bob>", "b=<emily>", "b=<greg>"},
+	},
+	{
+		message: "variable length",
+		query:   `MATCH (a)-[:follows*2]->(b) WHERE id(a) = "<alice>" RETURN b`,
+		expect:  []string{"b=<fred>"},
+	},
+	{
+		message: "variable length range",
+		query:   `MATCH (a)-[:follows*1..3]->(b) WHERE id(a) = "<alice>" RETURN b`,
+		expect:  []string{"b=<bob>", "b=<fred>", "b=<greg>"},
+	},
+	{
+		message: "variable length unbounded",
+		query:   `MATCH (a)-[:follows*]->(b) WHERE id(a) = "<charlie>" RETURN DISTINCT b`,
+		expect:  []string{"b=<bob>", "b=<dani>", "b=<fred>", "b=<greg>"},
+	},
+	{
+		message: "variable length backward",
+		query:   `MATCH (a)-[:follows*2..4]->(b) WHERE id(b) = "<greg>" RETURN DISTINCT a`,
+		expect:  []string{"a=<alice>", "a=<bob>", "a=<charlie>", "a=<dani>", "a=<emily>"},
+	},
+	{
+		message: "multiple patterns",
+		query:   `MATCH (a)-[:follows]->(b), (c)-[:follows]->(b) WHERE id(a) = "<alice>" RETURN DISTINCT c`,
+		expect:  []string{"c=<alice>", "c=<charlie>", "c=<dani>"},
+	},
+	{
+		message: "pattern from the middle",
+		query:   `MATCH (a {name: "Alice"}), (b)<-[:knows]-(a)-[:worksAt]->(c) RETURN b.name, c`,
+		data:    peopleGraph,
+		expect:  []string{"b.name=Bob c=<acme>"},
+	},
+	{
+		message: "cycle",
+		query:   `MATCH (a)-[:knows]->(b)-[:knows]->(c)-[:knows]->(a) RETURN a.name`,
+		data:    peopleGraph,
+		expect:  []string{"a.name=Alice", "a.name=Bob", "a.name=Charlie"},
+	},
+	{
+		message: "labels and properties",
+		query:   `MATCH (p:Person)-[:worksAt]->(:Company) WHERE p.age > 30 RETURN p.name AS name`,
+		data:    peopleGraph,
+		expect:  []string{"name=Alice"},
+	},
+	{
+		message: "string conditions",
+		query:   `MATCH (p:Person) WHERE p.name STARTS WITH "A" OR p.name = "Bob" RETURN p`,
+		data:    peopleGraph,
+		err:     true,
+	},
+	{
+		message: "string operators",
+		query:   `MATCH (p) WHERE p.name CONTAINS "li" AND NOT p.age < 40 RETURN p`,
+		data:    peopleGraph,
+		err:     true,
+	},
+	{
+		message: "contains and in",
+		query:   `MATCH (p) WHERE p.name CONTAINS "li" AND p.age IN [31, 25] RETURN p`,
+		data:    peopleGraph,
+		expect:  []string{"p=<alice>"},
+	},
+	{
+		message: "regexp and not equal",
+		query:   `MATCH (p) WHERE p.name =~ "[AB].*" AND p.name <> "Bob" RETURN p`,
+		data:    peopleGraph,
+		expect:  []string{"p=<alice>"},
+	},
+	{
+		message: "literal on the left",
+		query:   `MATCH (p:Person) WHERE 30 <= p.age RETURN p.name`,
+		data:    peopleGraph,
+		expect:  []string{"p.name=Alice", "p.name=Charlie"},
+	},
+	{
+		message: "order skip limit",
+		query:   `MATCH (p:Person) RETURN p.name ORDER BY p.age DESC SKIP 1 LIMIT 1`,
+		data:    peopleGraph,
+		expect:  []string{"p.name=Alice"},
+		ordered: true,
+	},
+	{
+		message: "order by alias",
+		query:   `MATCH (p:Person) RETURN p.name AS n ORDER BY n`,
+		data:    peopleGraph,
+		expect:  []string{"n=Alice", "n=Bob", "n=Charlie"},
+		ordered: true,
+	},
+	{
+		message: "count",
+		query:   `MATCH (a)-[:follows]->(b) RETURN b, count(*) AS followers ORDER BY followers DESC, b LIMIT 2`,
+		expect:  []string{"b=<bob> followers=3", "b=<fred> followers=2"},
+		ordered: true,
+	},
+	{
+		message: "count distinct",
+		query:   `MATCH (a)-[:follows]->(b)-[:follows]->(c) RETURN count(DISTINCT c) AS n, count(c) AS total`,
+		expect:  []string{"n=3 total=7"},
+	},
+	{
+		message: "return star",
+		query:   `MATCH (a)-[r:worksAt]->(c) RETURN *`,
+		data:    peopleGraph,
+		expect:  []string{"a=<alice> c=<acme> r=<worksAt>", "a=<bob> c=<acme> r=<worksAt>"},
+	},
+	{
+		message: "missing property",
+		query:   `MATCH (c:Company) RETURN c, c.name`,
+		data:    peopleGraph,
+		expect:  []string{"c=<acme>"},
+	},
+	{
+		message: "keywords are case-insensitive",
+		query:   "match (p:Person {name: 'Bob'})\nreturn p.age",
+		data:    peopleGraph,
+		expect:  []string{"p.age=25"},
+	},
+	{
+		message: "undefined variable",
+		query:   `MATCH (a) RETURN b`,
+		err:     true,
+	},
+	{
+		message: "disconnected patterns",
+		query:   `MATCH (a), (b) RETURN a, b`,
+		err:     true,
+	},
+	{
+		message: "write clause",
+		query:   `CREATE (a:Person) RETURN a`,
+		err:     true,
+	},
+	{
+		message: "syntax error",
+		query:   `MATCH (a)-[:follows->(b) RETURN a`,
+		err:     true,
+	},
+}
+
+func formatResult(qs graph.QuadStore, tags map[string]graph.Value) string {
+	var parts []string
+	for k, v := range tags {
+		parts = append(parts, fmt.Sprintf("%s=%v", k, toNative(qs.NameOf(v))))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func TestCypher(t *testing.T) {
+	simpleGraph := testutil.LoadGraph(t, "../../data/testdata.nq")
+	for _, test := range testQueries {
+		test := test
+		t.Run(test.message, func(t *testing.T) {
+			data := simpleGraph
+			if test.data != nil {
+				data = test.data
+			}
+			qs, _ := graph.NewQuadStore("memstore", "", nil)
+			w, _ := graph.NewQuadWriter("single", qs, nil)
+			w.AddQuadSet(data)
+
+			ses := NewSession(qs)
+			c := make(chan query.Result, 1)
+			go ses.Execute(context.TODO(), test.query, c, -1)
+			var (
+				got []string
+				err error
+			)
+			for res := range c {
+				if err = res.Err(); err != nil {
+					continue
+				}
+				got = append(got, formatResult(qs, res.Result().(map[string]graph.Value)))
+			}
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if !test.ordered {
+				sort.Strings(got)
+				sort.Strings(test.expect)
+			}
+			if !reflect.DeepEqual(got, test.expect) {
+				t.Errorf("got: %v expected: %v", got, test.expect)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := parse(`MATCH (a) WHERE a.name = "x" RETURN a LIMIT`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "at 43")
+}
@@ -0,0 +1,293 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cypher
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+)
+
+// row is a result row with values for all columns of a plan. Values of aggregated columns are counts.
+type row []quad.Value
+
+// key returns a string that is equal for rows with the same values in the first n columns.
+func (r row) key(n int) string {
+	var buf strings.Builder
+	for _, v := range r[:n] {
+		if v == nil {
+			buf.WriteString("\x01")
+		} else {
+			buf.WriteString(quad.StringOf(v))
+		}
+		buf.WriteByte(0)
+	}
+	return buf.String()
+}
+
+// visible returns a number of columns that are returned to the user. Hidden columns always go last.
+func (pl *plan) visible() int {
+	n := len(pl.cols)
+	for n > 0 && pl.cols[n-1].hidden {
+		n--
+	}
+	return n
+}
+
+// iterate runs the path and calls fnc for each match. Matches that violate equality constraints are skipped.
+// Iteration stops if fnc returns false.
+func (pl *plan) iterate(ctx context.Context, qs graph.QuadStore, fnc func(r row) bool) error {
+	var it graph.Iterator
+	if e := query.ExplainFrom(ctx); e != nil {
+		// record shapes in the query plan
+		it = e.BuildIterator(qs, pl.path.Shape())
+	} else {
+		it = pl.path.BuildIteratorOn(qs)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := false
+	err := query.Iterate(ctx, qs, it).Paths(true).TagEach(func(tags map[string]graph.Value) {
+		if stop {
+			return
+		}
+		for _, eq := range pl.eq {
+			if graph.ToKey(tags[eq[0]]) != graph.ToKey(tags[eq[1]]) {
+				return
+			}
+		}
+		r := make(row, len(pl.cols))
+		for i, col := range pl.cols {
+			if col.tag == "" {
+				continue
+			}
+			if v, ok := tags[col.tag]; ok && v != nil {
+				r[i] = qs.NameOf(v)
+			}
+		}
+		if !fnc(r) {
+			stop = true
+			cancel()
+		}
+	})
+	if stop {
+		err = nil
+	}
+	return err
+}
+
+// run executes the plan and calls fnc for each result row, applying aggregation, DISTINCT, ORDER BY, SKIP and LIMIT.
+// Rows have only visible columns. If limit is positive, it restricts the number of rows further.
+func (pl *plan) run(ctx context.Context, qs graph.QuadStore, limit int, fnc func(r row) bool) error {
+	q := pl.q
+	n := pl.visible()
+	max := q.limit
+	if limit > 0 && (max < 0 || int64(limit) < max) {
+		max = int64(limit)
+	}
+	if max == 0 {
+		return nil
+	}
+	skip := q.skip
+	var seen map[string]struct{}
+	if q.distinct {
+		seen = make(map[string]struct{})
+	}
+	// emit applies DISTINCT, SKIP and LIMIT
+	emit := func(r row) bool {
+		if seen != nil {
+			k := r.key(n)
+			if _, ok := seen[k]; ok {
+				return true
+			}
+			seen[k] = struct{}{}
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		if !fnc(r[:n]) {
+			return false
+		}
+		max--
+		return max != 0
+	}
+	if !pl.agg && len(q.order) == 0 {
+		return pl.iterate(ctx, qs, emit)
+	}
+	var rows []row
+	var err error
+	if pl.agg {
+		rows, err = pl.aggregate(ctx, qs)
+	} else {
+		err = pl.iterate(ctx, qs, func(r row) bool {
+			rows = append(rows, r)
+			return true
+		})
+	}
+	if err != nil {
+		return err
+	}
+	pl.sort(rows)
+	for _, r := range rows {
+		if !emit(r) {
+			break
+		}
+	}
+	return nil
+}
+
+// aggregate groups matches by non-aggregated columns and counts values in aggregated columns.
+func (pl *plan) aggregate(ctx context.Context, qs graph.QuadStore) ([]row, error) {
+	type group struct {
+		r        row
+		counts   []int64
+		distinct []map[string]struct{}
+	}
+	var (
+		groups []*group
+		byKey  = make(map[string]*group)
+	)
+	keys := make([]quad.Value, 0, len(pl.cols))
+	err := pl.iterate(ctx, qs, func(r row) bool {
+		keys = keys[:0]
+		for i, col := range pl.cols {
+			if !col.item.count {
+				keys = append(keys, r[i])
+			}
+		}
+		k := row(keys).key(len(keys))
+		g := byKey[k]
+		if g == nil {
+			g = &group{r: r, counts: make([]int64, len(pl.cols)), distinct: make([]map[string]struct{}, len(pl.cols))}
+			byKey[k] = g
+			groups = append(groups, g)
+		}
+		for i, col := range pl.cols {
+			it := col.item
+			if !it.count || (!it.countAll && r[i] == nil) {
+				continue
+			} else if it.distinct {
+				if g.distinct[i] == nil {
+					g.distinct[i] = make(map[string]struct{})
+				}
+				vk := r[i : i+1].key(1)
+				if _, ok := g.distinct[i][vk]; ok {
+					continue
+				}
+				g.distinct[i][vk] = struct{}{}
+			}
+			g.counts[i]++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]row, 0, len(groups))
+	for _, g := range groups {
+		for i, col := range pl.cols {
+			if col.item.count {
+				g.r[i] = quad.Int(g.counts[i])
+			}
+		}
+		rows = append(rows, g.r)
+	}
+	return rows, nil
+}
+
+// sort orders rows according to the ORDER BY clause.
+func (pl *plan) sort(rows []row) {
+	if len(pl.q.order) == 0 {
+		return
+	}
+	type key struct {
+		col  int
+		desc bool
+	}
+	keys := make([]key, 0, len(pl.q.order))
+	for _, o := range pl.q.order {
+		keys = append(keys, key{col: pl.findColumn(o.item), desc: o.desc})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, k := range keys {
+			c := compareValues(rows[i][k.col], rows[j][k.col])
+			if c == 0 {
+				continue
+			} else if k.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// compareValues orders values for ORDER BY. Numbers are compared by value, strings and times are compared
+// naturally, and other values are compared by their string representation. Missing values go last.
+func compareValues(a, b quad.Value) int {
+	if a == nil || b == nil {
+		switch {
+		case a == b:
+			return 0
+		case a == nil:
+			return 1
+		}
+		return -1
+	}
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := a.(quad.Time); ok {
+		if y, ok := b.(quad.Time); ok {
+			switch {
+			case time.Time(x).Before(time.Time(y)):
+				return -1
+			case time.Time(x).After(time.Time(y)):
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := a.(quad.String); ok {
+		if y, ok := b.(quad.String); ok {
+			return strings.Compare(string(x), string(y))
+		}
+	}
+	return strings.Compare(quad.StringOf(a), quad.StringOf(b))
+}
+
+func toFloat(v quad.Value) (float64, bool) {
+	switch v := v.(type) {
+	case quad.Int:
+		return float64(v), true
+	case quad.Float:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cypher
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokInt
+	tokFloat
+	tokPunct
+)
+
+type token struct {
+	kind   tokenKind
+	text   string // identifier, literal text or punctuation
+	pos    int    // offset in the query text
+	quoted bool   // identifier was written in backticks, thus it is never a keyword
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// keyword checks if a token is a given keyword. Keywords are case-insensitive.
+func (t token) keyword(kw string) bool {
+	return t.kind == tokIdent && !t.quoted && strings.EqualFold(t.text, kw)
+}
+
+func (t token) punct(p string) bool {
+	return t.kind == tokPunct && t.text == p
+}
+
+// punctuation lists multi-character operators first, so the longest one is matched.
+var punctuation = []string{
+	"..", "<>", "<=", ">=", "=~",
+	"(", ")", "[", "]", "{", "}", ":", ",", ".", "|", "*", "-", "<", ">", "=", "+", "/", "%",
+}
+
+// lex splits a query into tokens.
+func lex(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += n
+		case r == '/' && strings.HasPrefix(s[i:], "//"):
+			// line comment
+			if j := strings.IndexByte(s[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(s)
+			}
+		case r == '_' || unicode.IsLetter(r):
+			j := i + n
+			for j < len(s) {
+				r, n := utf8.DecodeRuneInString(s[j:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += n
+			}
+			toks = append(toks, token{kind: tokIdent, text: s[i:j], pos: i})
+			i = j
+		case r == '`':
+			j := strings.IndexByte(s[i+1:], '`')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated identifier at %d", i)
+			}
+			toks = append(toks, token{kind: tokIdent, text: s[i+1 : i+1+j], pos: i, quoted: true})
+			i += j + 2
+		case r == '\'' || r == '"':
+			str, j, err := lexString(s, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{kind: tokString, text: str, pos: i})
+			i = j
+		case r >= '0' && r <= '9':
+			kind := tokInt
+			j := i
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			// do not consume a range operator: *1..3
+			if j+1 < len(s) && s[j] == '.' && s[j+1] >= '0' && s[j+1] <= '9' {
+				kind = tokFloat
+				j++
+				for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+					j++
+				}
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				k := j + 1
+				if k < len(s) && (s[k] == '-' || s[k] == '+') {
+					k++
+				}
+				if k < len(s) && s[k] >= '0' && s[k] <= '9' {
+					kind = tokFloat
+					for j = k; j < len(s) && s[j] >= '0' && s[j] <= '9'; j++ {
+					}
+				}
+			}
+			toks = append(toks, token{kind: kind, text: s[i:j], pos: i})
+			i = j
+		default:
+			found := false
+			for _, p := range punctuation {
+				if strings.HasPrefix(s[i:], p) {
+					toks = append(toks, token{kind: tokPunct, text: p, pos: i})
+					i += len(p)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character %q at %d", r, i)
+			}
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, pos: len(s)})
+	return toks, nil
+}
+
+// lexString reads a quoted string starting at i. It returns the unescaped string and the offset after it.
+func lexString(s string, i int) (string, int, error) {
+	q := s[i]
+	var buf strings.Builder
+	for j := i + 1; j < len(s); j++ {
+		c := s[j]
+		switch c {
+		case q:
+			return buf.String(), j + 1, nil
+		case '\\':
+			j++
+			if j >= len(s) {
+				break
+			}
+			switch s[j] {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'r':
+				buf.WriteByte('\r')
+			case '\\', '\'', '"':
+				buf.WriteByte(s[j])
+			default:
+				return "", 0, fmt.Errorf("invalid escape sequence at %d", j-1)
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at %d", i)
+}
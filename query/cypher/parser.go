@@ -0,0 +1,662 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cypher
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// direction of a relationship in a pattern.
+type direction int
+
+const (
+	dirOut  direction = iota // (a)-->(b)
+	dirIn                    // (a)<--(b)
+	dirBoth                  // (a)--(b)
+)
+
+// statement is a parsed Cypher query.
+type statement struct {
+	patterns []pattern
+	where    []condition
+	distinct bool
+	star     bool // RETURN *
+	ret      []returnItem
+	order    []orderItem
+	skip     int64
+	limit    int64 // -1 if not set
+}
+
+// pattern is a chain of nodes connected by relationships; len(nodes) == len(rels)+1.
+type pattern struct {
+	nodes []nodePattern
+	rels  []relPattern
+}
+
+type nodePattern struct {
+	name   string
+	labels []string
+	props  []property
+}
+
+type property struct {
+	key string
+	val quad.Value
+}
+
+type relPattern struct {
+	name  string
+	types []string
+	dir   direction
+	// variable-length relationships: *min..max; max is -1 if not bounded
+	varLen   bool
+	min, max int
+}
+
+// operand refers to a variable, or to a property of a node bound to it.
+type operand struct {
+	name string
+	prop string
+}
+
+func (o operand) String() string {
+	if o.prop != "" {
+		return o.name + "." + o.prop
+	}
+	return o.name
+}
+
+// condition is a single comparison in the WHERE clause. Conditions are always joined with AND.
+type condition struct {
+	left operand
+	op   string
+	vals []quad.Value
+	re   *regexp.Regexp
+}
+
+type returnItem struct {
+	op       operand
+	count    bool // count(...) aggregation
+	countAll bool // count(*)
+	distinct bool // count(DISTINCT ...)
+	alias    string
+}
+
+// column returns a name of the result column for this item.
+func (r returnItem) column() string {
+	if r.alias != "" {
+		return r.alias
+	}
+	return r.text()
+}
+
+// text returns a canonical text of the expression.
+func (r returnItem) text() string {
+	switch {
+	case r.countAll:
+		return "count(*)"
+	case r.count && r.distinct:
+		return "count(DISTINCT " + r.op.String() + ")"
+	case r.count:
+		return "count(" + r.op.String() + ")"
+	}
+	return r.op.String()
+}
+
+type orderItem struct {
+	item returnItem
+	desc bool
+}
+
+// parser is a recursive descent parser for the supported subset of openCypher.
+type parser struct {
+	toks []token
+	i    int
+}
+
+// parse parses a query text.
+func parse(s string) (*statement, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	return p.statement()
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("cypher: %s at %d: got %v", fmt.Sprintf(format, args...), t.pos, t)
+}
+
+// acceptPunct consumes a punctuation token, if it matches.
+func (p *parser) acceptPunct(s string) bool {
+	if p.peek().punct(s) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// acceptKeyword consumes a keyword, if it matches.
+func (p *parser) acceptKeyword(kw string) bool {
+	if p.peek().keyword(kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.acceptPunct(s) {
+		return p.errorf(p.peek(), "expected %q", s)
+	}
+	return nil
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		return p.errorf(p.peek(), "expected %s", kw)
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", p.errorf(t, "expected identifier")
+	}
+	return t.text, nil
+}
+
+// unsupported are keywords of clauses that are not implemented.
+var unsupported = []string{
+	"OPTIONAL", "WITH", "UNWIND", "CREATE", "MERGE", "SET", "DELETE", "DETACH", "REMOVE", "CALL", "UNION",
+}
+
+func (p *parser) statement() (*statement, error) {
+	q := &statement{limit: -1}
+	for p.peek().keyword("MATCH") {
+		p.next()
+		for {
+			pt, err := p.pattern()
+			if err != nil {
+				return nil, err
+			}
+			q.patterns = append(q.patterns, pt)
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+		if p.acceptKeyword("WHERE") {
+			for {
+				c, err := p.condition()
+				if err != nil {
+					return nil, err
+				}
+				q.where = append(q.where, c)
+				if !p.acceptKeyword("AND") {
+					break
+				}
+			}
+		}
+	}
+	t := p.peek()
+	for _, kw := range unsupported {
+		if t.keyword(kw) {
+			return nil, p.errorf(t, "%s clause is not supported", kw)
+		}
+	}
+	if t.keyword("OR") || t.keyword("XOR") || t.keyword("NOT") {
+		return nil, p.errorf(t, "only conditions joined with AND are supported")
+	}
+	if len(q.patterns) == 0 {
+		return nil, p.errorf(t, "expected MATCH")
+	}
+	if err := p.expectKeyword("RETURN"); err != nil {
+		return nil, err
+	}
+	q.distinct = p.acceptKeyword("DISTINCT")
+	if p.acceptPunct("*") {
+		q.star = true
+	} else {
+		for {
+			r, err := p.returnItem(true)
+			if err != nil {
+				return nil, err
+			}
+			q.ret = append(q.ret, r)
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			r, err := p.returnItem(false)
+			if err != nil {
+				return nil, err
+			}
+			o := orderItem{item: r}
+			if p.acceptKeyword("DESC") || p.acceptKeyword("DESCENDING") {
+				o.desc = true
+			} else if !p.acceptKeyword("ASC") {
+				p.acceptKeyword("ASCENDING")
+			}
+			q.order = append(q.order, o)
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("SKIP") {
+		n, err := p.count()
+		if err != nil {
+			return nil, err
+		}
+		q.skip = n
+	}
+	if p.acceptKeyword("LIMIT") {
+		n, err := p.count()
+		if err != nil {
+			return nil, err
+		}
+		q.limit = n
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected token")
+	}
+	return q, nil
+}
+
+// count parses a non-negative integer for SKIP and LIMIT.
+func (p *parser) count() (int64, error) {
+	t := p.next()
+	if t.kind != tokInt {
+		return 0, p.errorf(t, "expected a number")
+	}
+	return strconv.ParseInt(t.text, 10, 64)
+}
+
+func (p *parser) pattern() (pattern, error) {
+	var pt pattern
+	if t := p.peek(); t.kind == tokIdent && p.toks[p.i+1].punct("=") {
+		return pt, p.errorf(t, "path variables are not supported")
+	}
+	n, err := p.node()
+	if err != nil {
+		return pt, err
+	}
+	pt.nodes = append(pt.nodes, n)
+	for p.peek().punct("-") || p.peek().punct("<") {
+		r, err := p.rel()
+		if err != nil {
+			return pt, err
+		}
+		n, err := p.node()
+		if err != nil {
+			return pt, err
+		}
+		pt.rels = append(pt.rels, r)
+		pt.nodes = append(pt.nodes, n)
+	}
+	return pt, nil
+}
+
+func (p *parser) node() (nodePattern, error) {
+	var n nodePattern
+	if err := p.expectPunct("("); err != nil {
+		return n, err
+	}
+	if p.peek().kind == tokIdent {
+		n.name = p.next().text
+	}
+	for p.acceptPunct(":") {
+		l, err := p.ident()
+		if err != nil {
+			return n, err
+		}
+		n.labels = append(n.labels, l)
+	}
+	if p.peek().punct("{") {
+		props, err := p.properties()
+		if err != nil {
+			return n, err
+		}
+		n.props = props
+	}
+	return n, p.expectPunct(")")
+}
+
+func (p *parser) properties() ([]property, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	var props []property
+	for !p.acceptPunct("}") {
+		if len(props) != 0 {
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+		}
+		k, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, property{key: k, val: v})
+	}
+	return props, nil
+}
+
+func (p *parser) rel() (relPattern, error) {
+	r := relPattern{dir: dirBoth}
+	in := p.acceptPunct("<")
+	if err := p.expectPunct("-"); err != nil {
+		return r, err
+	}
+	if p.acceptPunct("[") {
+		if p.peek().kind == tokIdent {
+			r.name = p.next().text
+		}
+		if p.acceptPunct(":") {
+			for {
+				typ, err := p.ident()
+				if err != nil {
+					return r, err
+				}
+				r.types = append(r.types, typ)
+				if !p.acceptPunct("|") {
+					break
+				}
+				p.acceptPunct(":")
+			}
+		}
+		if p.acceptPunct("*") {
+			if err := p.varLength(&r); err != nil {
+				return r, err
+			}
+		}
+		if t := p.peek(); t.punct("{") {
+			return r, p.errorf(t, "relationship properties are not supported")
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return r, err
+		}
+	}
+	if err := p.expectPunct("-"); err != nil {
+		return r, err
+	}
+	out := p.acceptPunct(">")
+	if in && !out {
+		r.dir = dirIn
+	} else if out && !in {
+		r.dir = dirOut
+	}
+	return r, nil
+}
+
+// varLength parses the length of a variable-length relationship after '*': [min][..[max]].
+func (p *parser) varLength(r *relPattern) error {
+	r.varLen = true
+	r.min, r.max = 1, -1
+	if t := p.peek(); t.kind == tokInt {
+		p.next()
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return p.errorf(t, "invalid length")
+		}
+		r.min, r.max = n, n
+	}
+	if p.acceptPunct("..") {
+		r.max = -1
+		if t := p.peek(); t.kind == tokInt {
+			p.next()
+			n, err := strconv.Atoi(t.text)
+			if err != nil {
+				return p.errorf(t, "invalid length")
+			}
+			r.max = n
+		}
+	}
+	if r.max >= 0 && r.max < r.min {
+		return fmt.Errorf("cypher: invalid relationship length: %d..%d", r.min, r.max)
+	}
+	return nil
+}
+
+// operand parses a variable, a property access (a.name), or id(a) and type(r) functions.
+func (p *parser) operand() (operand, error) {
+	name, err := p.ident()
+	if err != nil {
+		return operand{}, err
+	}
+	if p.acceptPunct("(") {
+		fn := strings.ToLower(name)
+		if fn != "id" && fn != "type" {
+			return operand{}, fmt.Errorf("cypher: function %s is not supported", name)
+		}
+		// both functions return the value of the variable itself: a node or a predicate
+		if name, err = p.ident(); err != nil {
+			return operand{}, err
+		}
+		return operand{name: name}, p.expectPunct(")")
+	}
+	o := operand{name: name}
+	if p.acceptPunct(".") {
+		if o.prop, err = p.ident(); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+// flipped maps comparison operators to operators with swapped operands.
+var flipped = map[string]string{
+	"=": "=", "<>": "<>", "<": ">", "<=": ">=", ">": "<", ">=": "<=",
+}
+
+func (p *parser) condition() (condition, error) {
+	var c condition
+	if t := p.peek(); t.kind != tokIdent || t.keyword("TRUE") || t.keyword("FALSE") {
+		// literal on the left side
+		v, err := p.literal()
+		if err != nil {
+			return c, err
+		}
+		t := p.next()
+		op, ok := flipped[t.text]
+		if t.kind != tokPunct || !ok {
+			return c, p.errorf(t, "expected comparison")
+		}
+		if c.left, err = p.operand(); err != nil {
+			return c, err
+		}
+		c.op, c.vals = op, []quad.Value{v}
+		return c, nil
+	}
+	var err error
+	if c.left, err = p.operand(); err != nil {
+		return c, err
+	}
+	t := p.next()
+	switch {
+	case t.kind == tokPunct && flipped[t.text] != "":
+		c.op = t.text
+		v, err := p.literal()
+		if err != nil {
+			return c, err
+		}
+		c.vals = []quad.Value{v}
+	case t.punct("=~"):
+		c.op = t.text
+		s, err := p.stringLiteral()
+		if err != nil {
+			return c, err
+		}
+		// the whole string must match
+		if c.re, err = regexp.Compile("^(?:" + s + ")$"); err != nil {
+			return c, err
+		}
+	case t.keyword("IN"):
+		c.op = "IN"
+		if err := p.expectPunct("["); err != nil {
+			return c, err
+		}
+		for !p.acceptPunct("]") {
+			if len(c.vals) != 0 {
+				if err := p.expectPunct(","); err != nil {
+					return c, err
+				}
+			}
+			v, err := p.literal()
+			if err != nil {
+				return c, err
+			}
+			c.vals = append(c.vals, v)
+		}
+	case t.keyword("STARTS"), t.keyword("ENDS"):
+		if err := p.expectKeyword("WITH"); err != nil {
+			return c, err
+		}
+		fallthrough
+	case t.keyword("CONTAINS"):
+		c.op = strings.ToUpper(t.text)
+		s, err := p.stringLiteral()
+		if err != nil {
+			return c, err
+		}
+		pat := regexp.QuoteMeta(s)
+		switch c.op {
+		case "STARTS":
+			pat = "^" + pat
+		case "ENDS":
+			pat = pat + "$"
+		}
+		c.re = regexp.MustCompile(pat)
+	default:
+		return c, p.errorf(t, "expected comparison")
+	}
+	return c, nil
+}
+
+func (p *parser) stringLiteral() (string, error) {
+	t := p.next()
+	if t.kind != tokString {
+		return "", p.errorf(t, "expected string")
+	}
+	return t.text, nil
+}
+
+// literal parses a string, a number or a boolean. Strings use the same notation as other query languages:
+// "<iri>" is an IRI, "_:id" is a blank node, and other strings are string literals.
+func (p *parser) literal() (quad.Value, error) {
+	t := p.next()
+	neg := false
+	if t.punct("-") {
+		neg = true
+		t = p.next()
+	}
+	switch {
+	case t.kind == tokString && !neg:
+		return quad.StringToValue(t.text), nil
+	case t.kind == tokInt:
+		v, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number")
+		}
+		if neg {
+			v = -v
+		}
+		return quad.Int(v), nil
+	case t.kind == tokFloat:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number")
+		}
+		if neg {
+			v = -v
+		}
+		return quad.Float(v), nil
+	case t.keyword("TRUE") && !neg:
+		return quad.Bool(true), nil
+	case t.keyword("FALSE") && !neg:
+		return quad.Bool(false), nil
+	case t.keyword("NULL"):
+		return nil, p.errorf(t, "null values are not supported")
+	}
+	return nil, p.errorf(t, "expected a literal")
+}
+
+// returnItem parses an expression in RETURN or ORDER BY clauses. Aliases are only allowed in RETURN.
+func (p *parser) returnItem(alias bool) (returnItem, error) {
+	var r returnItem
+	if t := p.peek(); t.keyword("count") && p.toks[p.i+1].punct("(") {
+		p.i += 2
+		r.count = true
+		if p.acceptPunct("*") {
+			r.countAll = true
+		} else {
+			r.distinct = p.acceptKeyword("DISTINCT")
+			op, err := p.operand()
+			if err != nil {
+				return r, err
+			}
+			r.op = op
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return r, err
+		}
+	} else {
+		op, err := p.operand()
+		if err != nil {
+			return r, err
+		}
+		r.op = op
+	}
+	if alias && p.acceptKeyword("AS") {
+		name, err := p.ident()
+		if err != nil {
+			return r, err
+		}
+		r.alias = name
+	}
+	return r, nil
+}
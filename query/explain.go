@@ -0,0 +1,116 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/shape"
+)
+
+// Plan describes how a single iterator tree of the query was built and executed.
+type Plan struct {
+	// Shape is the shape tree built by the query language, before optimizations.
+	Shape interface{} `json:"shape,omitempty"`
+	// Optimized is the shape tree after generic and backend-specific optimizations.
+	Optimized interface{} `json:"optimized,omitempty"`
+	// Pushdown lists backend-specific shapes that are executed natively by the backend,
+	// for example document filters of NoSQL backends.
+	Pushdown []interface{} `json:"pushdown,omitempty"`
+	// Iterator is the final iterator tree with estimated sizes and execution statistics.
+	Iterator *iterator.ProfileNode `json:"iterator,omitempty"`
+
+	it graph.Iterator
+}
+
+// Explain collects plans of iterators executed with a context returned by WithExplain.
+type Explain struct {
+	mu      sync.Mutex
+	plans   []*Plan
+	pending map[uint64]*Plan
+}
+
+type explainKey struct{}
+
+// WithExplain returns a context that collects query plans of all iterators executed with it.
+// Query sessions run iterators with Iterate, which records the plan and profiles the execution.
+// Sessions that build iterators from shapes may also use Explain.BuildIterator to include shapes in the plan.
+func WithExplain(ctx context.Context) (context.Context, *Explain) {
+	e := &Explain{pending: make(map[uint64]*Plan)}
+	return context.WithValue(ctx, explainKey{}, e), e
+}
+
+// ExplainFrom returns a plan collector associated with the context, or nil if plans are not collected.
+func ExplainFrom(ctx context.Context) *Explain {
+	if ctx == nil {
+		return nil
+	}
+	e, _ := ctx.Value(explainKey{}).(*Explain)
+	return e
+}
+
+// BuildIterator optimizes the shape and builds an iterator for it, recording shapes in the plan
+// of the iterator. The plan is completed when the iterator is executed with Iterate.
+func (e *Explain) BuildIterator(qs graph.QuadStore, s shape.Shape) graph.Iterator {
+	p := &Plan{Shape: shape.Describe(qs, s)}
+	s, _ = shape.Optimize(s, qs)
+	p.Optimized = shape.Describe(qs, s)
+	for _, ps := range shape.Pushdown(s) {
+		p.Pushdown = append(p.Pushdown, shape.Describe(qs, ps))
+	}
+	var it graph.Iterator
+	if shape.IsNull(s) {
+		it = iterator.NewNull()
+	} else {
+		it = s.BuildIterator(qs)
+	}
+	e.mu.Lock()
+	e.pending[it.UID()] = p
+	e.mu.Unlock()
+	return it
+}
+
+// profile wraps an optimized iterator tree to collect execution statistics and records its plan.
+// The original iterator is used to find shapes recorded by BuildIterator.
+func (e *Explain) profile(orig, it graph.Iterator) graph.Iterator {
+	it = iterator.Profile(it)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	p := e.pending[orig.UID()]
+	if p == nil {
+		p = new(Plan)
+	}
+	delete(e.pending, orig.UID())
+	p.it = it
+	e.plans = append(e.plans, p)
+	return it
+}
+
+// Plans returns plans of all iterators executed so far. It should be called after the query finishes.
+func (e *Explain) Plans() []Plan {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]Plan, 0, len(e.plans))
+	for _, p := range e.plans {
+		pl := *p
+		d := iterator.DescribeProfile(p.it)
+		pl.Iterator = &d
+		out = append(out, pl)
+	}
+	return out
+}
@@ -17,16 +17,21 @@ package gizmo
 // Builds a new Gizmo environment pointing at a session.
 
 import (
+	"errors"
 	"fmt"
+	"math/rand"
 	"regexp"
 	"time"
 
 	"github.com/dop251/goja"
 
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/algo"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/path"
 	"github.com/cayleygraph/cayley/graph/shape"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
 	"github.com/cayleygraph/cayley/voc"
 )
 
@@ -119,6 +124,138 @@ func (g *graphObject) Emit(call goja.FunctionCall) goja.Value {
 	return goja.Null()
 }
 
+// SampleNodes returns a list of nodes chosen uniformly at random from the whole graph.
+// Signature: (n, [seed])
+//
+// Arguments:
+//
+// * `n`: Number of nodes to return.
+// * `seed` (Optional): A seed for the random number generator, to get the same sample each time.
+//
+// Example:
+//
+//	// javascript
+//	// Emit 10 random nodes
+//	g.Emit(g.SampleNodes(10))
+func (g *graphObject) SampleNodes(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) < 1 || len(args) > 2 {
+		return throwErr(g.s.vm, errArgCount{Got: len(args)})
+	}
+	n, ok := toInt(args[0])
+	if !ok {
+		return throwErr(g.s.vm, fmt.Errorf("expected a number, got: %v", args[0]))
+	}
+	var rnd *rand.Rand
+	if len(args) == 2 {
+		seed, ok := toInt(args[1])
+		if !ok {
+			return throwErr(g.s.vm, fmt.Errorf("expected a seed, got: %v", args[1]))
+		}
+		rnd = rand.New(rand.NewSource(int64(seed)))
+	}
+	nodes, err := algo.SampleNodes(g.s.context(), g.s.qs, n, rnd)
+	if err != nil {
+		return throwErr(g.s.vm, err)
+	}
+	return g.s.vm.ToValue(nativeValues(nodes))
+}
+
+// RandomWalk performs a random walk from a given node and returns a list of visited nodes.
+// The walk stops early if there are no links to follow. Literal values are never visited.
+// Signature: (node, [options])
+//
+// Arguments:
+//
+// * `node`: A node to start from.
+// * `options` (Optional): An object with parameters of the walk:
+//   - `length`: Maximal number of nodes in the walk, including the start node. Defaults to 10.
+//   - `predicates`: A predicate or a list of predicates to follow. Defaults to all predicates.
+//   - `p`, `q`: Return and in-out parameters that bias the walk in the same way as in node2vec. Default to 1.
+//   - `undirected`: Follow links in both directions.
+//   - `seed`: A seed for the random number generator.
+//
+// Example:
+//
+//	// javascript
+//	// Emit 10 walks that follow people Charlie follows
+//	for (var i = 0; i < 10; i++) {
+//		g.Emit(g.RandomWalk("<charlie>", {length: 5, predicates: "<follows>", q: 0.5}))
+//	}
+func (g *graphObject) RandomWalk(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) < 1 || len(args) > 2 {
+		return throwErr(g.s.vm, errArgCount{Got: len(args)})
+	}
+	start, err := toQuadValue(args[0])
+	if err != nil {
+		return throwErr(g.s.vm, err)
+	}
+	var opt algo.WalkOptions
+	if len(args) == 2 && args[1] != nil {
+		m, ok := args[1].(map[string]interface{})
+		if !ok {
+			return throwErr(g.s.vm, fmt.Errorf("expected an options object, got: %v", args[1]))
+		}
+		if err = toWalkOptions(&opt, m); err != nil {
+			return throwErr(g.s.vm, err)
+		}
+	}
+	walk, err := algo.RandomWalk(g.s.context(), g.s.qs, start, &opt)
+	if err == graph.ErrNodeNotExists {
+		return g.s.vm.ToValue([]interface{}{})
+	} else if err != nil {
+		return throwErr(g.s.vm, err)
+	}
+	return g.s.vm.ToValue(nativeValues(walk))
+}
+
+func toWalkOptions(opt *algo.WalkOptions, m map[string]interface{}) error {
+	for k, v := range m {
+		var ok bool
+		switch k {
+		case "length":
+			opt.Length, ok = toInt(v)
+		case "p":
+			opt.Return, ok = toFloat(v)
+		case "q":
+			opt.InOut, ok = toFloat(v)
+		case "undirected":
+			opt.Undirected, ok = v.(bool)
+		case "seed":
+			var seed int
+			if seed, ok = toInt(v); ok {
+				opt.Rand = rand.New(rand.NewSource(int64(seed)))
+			}
+		case "predicates":
+			preds, err := toPredicates([]interface{}{v})
+			if err != nil {
+				return err
+			}
+			for _, p := range preds {
+				opt.Predicates = append(opt.Predicates, p)
+			}
+			ok = true
+		default:
+			return fmt.Errorf("unknown walk option: %q", k)
+		}
+		if !ok {
+			return fmt.Errorf("invalid value for walk option %q: %v", k, v)
+		}
+	}
+	return nil
+}
+
+func nativeValues(vals []quad.Value) []interface{} {
+	out := make([]interface{}, 0, len(vals))
+	for _, v := range vals {
+		if o := quadValueToNative(v); o != nil {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
 func oneStringType(fnc func(s string) quad.Value) func(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
 	return func(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
 		args := toStrings(exportArgs(call.Arguments))
@@ -139,6 +276,39 @@ func twoStringType(fnc func(s1, s2 string) quad.Value) func(vm *goja.Runtime, ca
 	}
 }
 
+// langType creates a language-tagged string if called with two arguments, or a filter for a language tag otherwise.
+func langType(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
+	args := toStrings(exportArgs(call.Arguments))
+	switch len(args) {
+	case 1:
+		return vm.ToValue(valFilter{f: shape.LangFilter{Lang: args[0]}})
+	case 2:
+		return vm.ToValue(quad.LangString{Value: quad.String(args[0]), Lang: args[1]})
+	}
+	return throwErr(vm, errArgCount2{Expected: 2, Got: len(args)})
+}
+
+func cmpDatatype(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 1 {
+		return throwErr(vm, errArgCount2{Expected: 1, Got: len(args)})
+	}
+	v, err := toQuadValue(args[0])
+	if err != nil {
+		return throwErr(vm, err)
+	}
+	var typ quad.IRI
+	switch v := v.(type) {
+	case quad.IRI:
+		typ = v
+	case quad.String:
+		typ = quad.IRI(v)
+	default:
+		return throwErr(vm, fmt.Errorf("datatype: unsupported type: %T", v))
+	}
+	return vm.ToValue(valFilter{f: shape.DatatypeFilter{Type: typ}})
+}
+
 func cmpOpType(op iterator.Operator) func(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
 	return func(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
 		args := exportArgs(call.Arguments)
@@ -229,19 +399,18 @@ var defaultEnv = map[string]func(vm *goja.Runtime, call goja.FunctionCall) goja.
 	"raw":   oneStringType(func(s string) quad.Value { return quad.Raw(s) }),
 	"str":   oneStringType(func(s string) quad.Value { return quad.String(s) }),
 
-	"lang": twoStringType(func(s, lang string) quad.Value {
-		return quad.LangString{Value: quad.String(s), Lang: lang}
-	}),
+	"lang": langType,
 	"typed": twoStringType(func(s, typ string) quad.Value {
 		return quad.TypedString{Value: quad.String(s), Type: quad.IRI(typ)}
 	}),
 
-	"lt":    cmpOpType(iterator.CompareLT),
-	"lte":   cmpOpType(iterator.CompareLTE),
-	"gt":    cmpOpType(iterator.CompareGT),
-	"gte":   cmpOpType(iterator.CompareGTE),
-	"regex": cmpRegexp,
-	"like":  cmpWildcard,
+	"lt":       cmpOpType(iterator.CompareLT),
+	"lte":      cmpOpType(iterator.CompareLTE),
+	"gt":       cmpOpType(iterator.CompareGT),
+	"gte":      cmpOpType(iterator.CompareGTE),
+	"regex":    cmpRegexp,
+	"like":     cmpWildcard,
+	"datatype": cmpDatatype,
 }
 
 func unwrap(o interface{}) interface{} {
@@ -285,6 +454,73 @@ func toInt(o interface{}) (int, bool) {
 	}
 }
 
+func toFloat(o interface{}) (float64, bool) {
+	switch v := o.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// toPolygon converts a list of [lat, lng] pairs to a polygon.
+func toPolygon(o interface{}) (geo.Polygon, error) {
+	list, ok := o.([]interface{})
+	if !ok || len(list) < 3 {
+		return nil, errors.New("expected a list of at least 3 points")
+	}
+	poly := make(geo.Polygon, 0, len(list))
+	for _, o := range list {
+		pt, ok := o.([]interface{})
+		if !ok || len(pt) != 2 {
+			return nil, fmt.Errorf("expected a [lat, lng] pair, got: %v", o)
+		}
+		lat, ok1 := toFloat(pt[0])
+		lng, ok2 := toFloat(pt[1])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("expected a [lat, lng] pair, got: %v", o)
+		}
+		poly = append(poly, geo.Point{Lat: lat, Lng: lng})
+	}
+	return poly, nil
+}
+
+// toTime converts a Date, an RFC3339 string or a time value to time.Time.
+func toTime(o interface{}) (time.Time, bool) {
+	switch v := o.(type) {
+	case time.Time:
+		return v, true
+	case quad.Time:
+		return time.Time(v), true
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+// toPredicates converts a predicate or a list of predicates to IRIs.
+func toPredicates(objs []interface{}) ([]quad.IRI, error) {
+	var out []quad.IRI
+	for _, o := range toVia(objs) {
+		qv, err := toQuadValue(o)
+		if err != nil {
+			return nil, err
+		}
+		iri, ok := qv.(quad.IRI)
+		if !ok {
+			return nil, fmt.Errorf("expected predicate IRI, got: %v", qv)
+		}
+		out = append(out, iri)
+	}
+	return out, nil
+}
+
 func toQuadValue(o interface{}) (quad.Value, error) {
 	var qv quad.Value
 	switch v := o.(type) {
@@ -408,6 +644,32 @@ func toViaDepthData(objs []interface{}) (predicates []interface{}, maxDepth int,
 	return
 }
 
+type recursiveOptions struct {
+	maxDepth  int
+	depthTags []string
+	pathTags  []string
+}
+
+func toRecursiveOptions(m map[string]interface{}) (opt recursiveOptions, _ error) {
+	for k, v := range m {
+		switch k {
+		case "maxDepth":
+			n, ok := toInt(v)
+			if !ok {
+				return opt, fmt.Errorf("expected an integer for %q, got: %T", k, v)
+			}
+			opt.maxDepth = n
+		case "depthTag":
+			opt.depthTags = toStrings([]interface{}{v})
+		case "pathTag":
+			opt.pathTags = toStrings([]interface{}{v})
+		default:
+			return opt, fmt.Errorf("unknown option for recursive follow: %q", k)
+		}
+	}
+	return opt, nil
+}
+
 func throwErr(vm *goja.Runtime, err error) goja.Value {
 	panic(vm.ToValue(err))
 }
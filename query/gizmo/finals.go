@@ -15,9 +15,16 @@
 package gizmo
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/dop251/goja"
 
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/path"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
 )
 
 const TopResultTag = "id"
@@ -26,14 +33,16 @@ const TopResultTag = "id"
 func (p *pathObject) GetLimit(limit int) error {
 	it := p.buildIteratorTree()
 	it.Tagger().Add(TopResultTag)
-	p.s.limit = limit
-	p.s.count = 0
+	// results of views are sent through the root session
+	root := p.s.root()
+	root.limit = limit
+	root.count = 0
 	return p.s.runIterator(it)
 }
 
 // All executes the query and adds the results, with all tags, as a string-to-string (tag to node) map in the output set, one for each path that a traversal could take.
 func (p *pathObject) All() error {
-	return p.GetLimit(p.s.limit)
+	return p.GetLimit(p.s.root().limit)
 }
 
 func (p *pathObject) toArray(call goja.FunctionCall, withTags bool) goja.Value {
@@ -167,6 +176,298 @@ func (p *pathObject) Count() (int64, error) {
 	return p.s.countResults(it)
 }
 
+// property returns a path to values of an optional property passed in arguments.
+// If the property is not set, values at the end of the path are used.
+func (p *pathObject) property(call goja.FunctionCall) (*pathObject, error) {
+	args := exportArgs(call.Arguments)
+	if len(args) > 1 {
+		return nil, errArgCount2{Expected: 1, Got: len(args)}
+	} else if len(args) == 0 {
+		return p, nil
+	}
+	if vp, ok := args[0].(*path.Path); ok {
+		return p.new(p.clonePath().Follow(vp)), nil
+	}
+	via, err := toQuadValue(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return p.new(p.clonePath().Out(via)), nil
+}
+
+func (p *pathObject) aggregate(call goja.FunctionCall, fnc iterator.Aggregation) goja.Value {
+	vp, err := p.property(call)
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	v, err := vp.new(vp.clonePath().Aggregate(fnc)).toValue(false)
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	return p.s.vm.ToValue(v)
+}
+
+// Sum returns a sum of numeric values at the end of the path. Other values are ignored.
+// Signature: ([predicate])
+//
+// Arguments:
+//
+// * `predicate` (Optional): A string for a predicate, or a path. If set, values of this property are summed instead.
+//
+// Example:
+//	// javascript
+//	var total = g.V().Out("<age>").Sum()
+//	// the same as above
+//	var total2 = g.V().Sum("<age>")
+//	g.Emit(total)
+func (p *pathObject) Sum(call goja.FunctionCall) goja.Value {
+	return p.aggregate(call, iterator.AggregateSum)
+}
+
+// Min returns a minimal value at the end of the path, or null if there are no values.
+// Signature: ([predicate])
+//
+// Numbers are compared numerically, times chronologically and strings lexicographically.
+// If a predicate is set, a minimal value of this property is returned, as in Sum.
+func (p *pathObject) Min(call goja.FunctionCall) goja.Value {
+	return p.aggregate(call, iterator.AggregateMin)
+}
+
+// Max returns a maximal value at the end of the path, or null if there are no values.
+// Signature: ([predicate])
+//
+// Numbers are compared numerically, times chronologically and strings lexicographically.
+// If a predicate is set, a maximal value of this property is returned, as in Sum.
+func (p *pathObject) Max(call goja.FunctionCall) goja.Value {
+	return p.aggregate(call, iterator.AggregateMax)
+}
+
+// Avg returns an average of numeric values at the end of the path, or null if there are no numeric values.
+// Signature: ([predicate])
+//
+// If a predicate is set, an average value of this property is returned, as in Sum.
+func (p *pathObject) Avg(call goja.FunctionCall) goja.Value {
+	return p.aggregate(call, iterator.AggregateAvg)
+}
+
+// Distinct returns an array of unique values at the end of the path.
+// Signature: ([predicate])
+//
+// Arguments:
+//
+// * `predicate` (Optional): A string for a predicate, or a path. If set, unique values of this property are returned instead.
+//
+// Example:
+//	// javascript
+//	// Returns statuses of all nodes: "cool_person" and "smart_person".
+//	var statuses = g.V().Distinct("<status>")
+func (p *pathObject) Distinct(call goja.FunctionCall) goja.Value {
+	vp, err := p.property(call)
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	it := vp.new(vp.clonePath().Unique()).buildIteratorTree()
+	arr, err := p.s.runIteratorToArrayNoTags(it, -1)
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	return p.s.vm.ToValue(arr)
+}
+
+// groupTag is a tag used to save group keys in GroupBy.
+const groupTag = "\x00group"
+
+// GroupBy groups nodes at the end of the path by values of a property.
+// It returns an object that maps each value of the property to an array of nodes with this value.
+// Signature: (predicate)
+//
+// Arguments:
+//
+// * `predicate`: A string for a predicate, or a path.
+//
+// Nodes without the property are omitted. Nodes with multiple values are added to multiple groups.
+//
+// Example:
+//	// javascript
+//	// Returns {"cool_person": ["<bob>", "<dani>", "<greg>"], "smart_person": ["<emily>", "<greg>"]}.
+//	var byStatus = g.V().GroupBy("<status>")
+//	g.Emit(byStatus["cool_person"].length)
+func (p *pathObject) GroupBy(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 1 {
+		return throwErr(p.s.vm, errArgCount2{Expected: 1, Got: len(args)})
+	}
+	via := args[0]
+	if _, ok := via.(*path.Path); !ok {
+		var err error
+		via, err = toQuadValue(via)
+		if err != nil {
+			return throwErr(p.s.vm, err)
+		}
+	}
+	it := p.new(p.clonePath().Save(via, groupTag)).buildIteratorTree()
+	it.Tagger().Add(TopResultTag)
+
+	qs := p.s.qs
+	groups := make(map[string]interface{})
+	seen := make(map[[2]interface{}]struct{})
+	err := query.Iterate(p.s.context(), qs, it).Paths(true).TagEach(func(tags map[string]graph.Value) {
+		g, id := tags[groupTag], tags[TopResultTag]
+		if g == nil || id == nil {
+			return
+		}
+		k := [2]interface{}{graph.ToKey(g), graph.ToKey(id)}
+		if _, ok := seen[k]; ok {
+			return
+		}
+		seen[k] = struct{}{}
+		node := quadValueToNative(qs.NameOf(id))
+		if node == nil {
+			return
+		}
+		key := quadValueToString(qs.NameOf(g))
+		arr, _ := groups[key].([]interface{})
+		groups[key] = append(arr, node)
+	})
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	return p.s.vm.ToValue(groups)
+}
+
+// ShortestPathTo finds shortest paths from nodes at the end of the path to given nodes.
+// It returns an array of paths ordered by their weights, where each path is an object with a list
+// of nodes ("nodes"), including the start and the end nodes, and a total weight ("weight").
+// Signature: (node, [options])
+//
+// Arguments:
+//
+// * `node`: A node or a list of nodes to find paths to.
+// * `options` (Optional): An object with parameters of the search:
+//   * `via`: A predicate, a list of predicates or a morphism to follow on each step. Defaults to all outgoing predicates.
+//   * `weight`: A tag with a weight of each step, which must be saved by the `via` morphism. Each step has a weight of 1 by default.
+//   * `k`: A number of alternative paths to return. Defaults to 1.
+//
+// Example:
+//	// javascript
+//	// Find how charlie is connected to greg.
+//	var p = g.V("<charlie>").ShortestPathTo("<greg>", {via: "<follows>"})[0]
+//	g.Emit(p.nodes)
+//	// Find 3 shortest routes, where each road is a node with a length.
+//	var road = g.M().Out("<road>").Save("<length>", "w").Out("<to>")
+//	g.Emit(g.V("<a>").ShortestPathTo("<d>", {via: road, weight: "w", k: 3}))
+func (p *pathObject) ShortestPathTo(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) < 1 || len(args) > 2 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	arr, ok := args[0].([]interface{})
+	if !ok {
+		arr = []interface{}{args[0]}
+	}
+	to, err := toQuadValues(arr)
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	var opt path.ShortestPathOptions
+	if len(args) == 2 && args[1] != nil {
+		m, ok := args[1].(map[string]interface{})
+		if !ok {
+			return throwErr(p.s.vm, fmt.Errorf("expected an options object, got: %v", args[1]))
+		}
+		if err = toShortestPathOptions(&opt, m); err != nil {
+			return throwErr(p.s.vm, err)
+		}
+	}
+	ctx := p.s.context()
+	var from []quad.Value
+	seen := make(map[string]struct{})
+	it := p.buildIteratorTree()
+	err = query.Iterate(ctx, p.s.qs, it).Paths(false).EachValue(p.s.qs, func(v quad.Value) {
+		k := quad.ToString(v)
+		if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			from = append(from, v)
+		}
+	})
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	routes, err := path.ShortestPath(ctx, p.s.qs, from, to, &opt)
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	out := make([]interface{}, 0, len(routes))
+	for _, r := range routes {
+		out = append(out, map[string]interface{}{
+			"nodes":  nativeValues(r.Nodes),
+			"weight": r.Weight,
+		})
+	}
+	return p.s.vm.ToValue(out)
+}
+
+func toShortestPathOptions(opt *path.ShortestPathOptions, m map[string]interface{}) error {
+	for k, v := range m {
+		var ok bool
+		switch k {
+		case "via":
+			if vp, isPath := v.(*path.Path); isPath {
+				opt.Via, ok = vp, true
+				break
+			}
+			preds, err := toPredicates([]interface{}{v})
+			if err != nil {
+				return err
+			}
+			via := make([]interface{}, 0, len(preds))
+			for _, p := range preds {
+				via = append(via, p)
+			}
+			opt.Via, ok = path.StartMorphism().Out(via...), true
+		case "weight":
+			opt.Weight, ok = v.(string)
+		case "k":
+			opt.K, ok = toInt(v)
+		default:
+			return fmt.Errorf("unknown shortest path option: %q", k)
+		}
+		if !ok {
+			return fmt.Errorf("invalid value for shortest path option %q: %v", k, v)
+		}
+	}
+	return nil
+}
+
+// Explain executes the path and returns its query plan instead of results. The plan contains the shape tree
+// built for the path ("shape"), the tree after optimizations ("optimized"), shapes that are executed
+// natively by the backend ("pushdown") and the final iterator tree with estimated sizes, numbers of calls
+// and timings of each iterator ("iterator").
+//
+// Example:
+//	// javascript
+//	var plan = g.V("<alice>").Out("<follows>").Explain()
+//	g.Emit(plan.iterator)
+func (p *pathObject) Explain() (interface{}, error) {
+	ctx, e := query.WithExplain(p.s.context())
+	var it graph.Iterator = iterator.NewNull()
+	if p.path != nil {
+		it = e.BuildIterator(p.s.qs, p.path.Shape())
+	}
+	err := query.Iterate(ctx, p.s.qs, it).Paths(true).Limit(p.s.limit).TagEach(func(map[string]graph.Value) {})
+	if err != nil {
+		return nil, err
+	}
+	// convert the plan to a JS object with the same field names as in JSON
+	data, err := json.Marshal(e.Plans()[0])
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
 func quadValueToString(v quad.Value) string {
 	if s, ok := v.(quad.String); ok {
 		return string(s)
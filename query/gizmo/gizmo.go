@@ -23,6 +23,7 @@ import (
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/history"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/cayleygraph/cayley/query"
@@ -77,12 +78,56 @@ type Session struct {
 	dataOutput []interface{}
 	err        error
 	shape      map[string]interface{}
+
+	// parent is set for sessions that run traversals on a view of a past state (see At);
+	// results are sent through the parent session
+	parent *Session
+	views  map[int64]graph.QuadStore
 }
 
 func (s *Session) context() context.Context {
 	return s.ctx
 }
 
+func (s *Session) root() *Session {
+	for s.parent != nil {
+		s = s.parent
+	}
+	return s
+}
+
+// at returns a session that runs traversals on a view of the store at horizon h.
+// Views are cached until the end of the query.
+func (s *Session) at(h int64) (*Session, error) {
+	root := s.root()
+	view, ok := root.views[h]
+	if !ok {
+		var err error
+		view, err = history.AsOf(s.context(), root.qs, h)
+		if err != nil {
+			return nil, err
+		}
+		if root.views == nil {
+			root.views = make(map[int64]graph.QuadStore)
+		}
+		root.views[h] = view
+	}
+	return &Session{
+		qs: view, vm: root.vm, sch: root.sch,
+		ctx: root.ctx, limit: root.limit, shape: root.shape,
+		parent: root,
+	}, nil
+}
+
+func (s *Session) closeViews() {
+	for h, view := range s.views {
+		if err := view.Close(); err != nil {
+			clog.Warningf("error closing a view at horizon %d: %v", h, err)
+		}
+	}
+	s.views = nil
+}
+
 func (s *Session) buildEnv() error {
 	if s.vm != nil {
 		return nil
@@ -115,7 +160,7 @@ func (s *Session) runIteratorToArray(it graph.Iterator, limit int) ([]map[string
 	ctx := s.context()
 
 	output := make([]map[string]interface{}, 0)
-	err := graph.Iterate(ctx, it).Limit(limit).TagEach(func(tags map[string]graph.Value) {
+	err := query.Iterate(ctx, s.qs, it).Limit(limit).TagEach(func(tags map[string]graph.Value) {
 		tm := s.tagsToValueMap(tags)
 		if tm == nil {
 			return
@@ -132,7 +177,7 @@ func (s *Session) runIteratorToArrayNoTags(it graph.Iterator, limit int) ([]inte
 	ctx := s.context()
 
 	output := make([]interface{}, 0)
-	err := graph.Iterate(ctx, it).Paths(false).Limit(limit).EachValue(s.qs, func(v quad.Value) {
+	err := query.Iterate(ctx, s.qs, it).Paths(false).Limit(limit).EachValue(s.qs, func(v quad.Value) {
 		if o := quadValueToNative(v); o != nil {
 			output = append(output, o)
 		}
@@ -151,7 +196,7 @@ func (s *Session) runIteratorWithCallback(it graph.Iterator, callback goja.Value
 	ctx, cancel := context.WithCancel(s.context())
 	defer cancel()
 	var gerr error
-	err := graph.Iterate(ctx, it).Paths(true).Limit(limit).TagEach(func(tags map[string]graph.Value) {
+	err := query.Iterate(ctx, s.qs, it).Paths(true).Limit(limit).TagEach(func(tags map[string]graph.Value) {
 		tm := s.tagsToValueMap(tags)
 		if tm == nil {
 			return
@@ -168,6 +213,17 @@ func (s *Session) runIteratorWithCallback(it graph.Iterator, callback goja.Value
 }
 
 func (s *Session) send(ctx context.Context, r *Result) bool {
+	if s.parent != nil {
+		// values of the view are resolved, since it is closed at the end of the query
+		if r.Tags != nil {
+			tags := make(map[string]graph.Value, len(r.Tags))
+			for k, v := range r.Tags {
+				tags[k] = graph.PreFetched(s.qs.NameOf(v))
+			}
+			r = &Result{Meta: r.Meta, Val: r.Val, Tags: tags}
+		}
+		return s.parent.send(ctx, r)
+	}
 	if s.limit >= 0 && s.count >= s.limit {
 		return false
 	}
@@ -195,7 +251,7 @@ func (s *Session) runIterator(it graph.Iterator) error {
 	ctx, cancel := context.WithCancel(s.context())
 	defer cancel()
 	stop := false
-	err := graph.Iterate(ctx, it).Paths(true).TagEach(func(tags map[string]graph.Value) {
+	err := query.Iterate(ctx, s.qs, it).Paths(true).TagEach(func(tags map[string]graph.Value) {
 		if !s.send(ctx, &Result{Tags: tags}) {
 			cancel()
 			stop = true
@@ -212,7 +268,7 @@ func (s *Session) countResults(it graph.Iterator) (int64, error) {
 		iterator.OutputQueryShapeForIterator(it, s.qs, s.shape)
 		return 0, nil
 	}
-	return graph.Iterate(s.context(), it).Paths(true).Count()
+	return query.Iterate(s.context(), s.qs, it).Paths(true).Count()
 }
 
 type Result struct {
@@ -234,7 +290,13 @@ func (s *Session) run(qu string) (v goja.Value, err error) {
 	if s.last == qu && s.last != "" {
 		p = s.p
 	} else {
+		ctx := s.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		_, end := graph.StartSpan(ctx, "parse")
 		p, err = goja.Compile("", qu, false)
+		end(err)
 		if err != nil {
 			return
 		}
@@ -250,13 +312,20 @@ func (s *Session) run(qu string) (v goja.Value, err error) {
 }
 func (s *Session) Execute(ctx context.Context, qu string, out chan query.Result, limit int) {
 	defer close(out)
+	defer s.closeViews()
 	s.out = out
 	s.limit = limit
 	s.count = 0
 	s.ctx = ctx
 	done := make(chan struct{})
-	defer close(done)
+	stopped := make(chan struct{})
+	defer func() {
+		close(done)
+		// the VM is reused by the next query, so the interrupt must not happen after we return
+		<-stopped
+	}()
 	go func() {
+		defer close(stopped)
 		select {
 		case <-ctx.Done():
 			s.vm.Interrupt(ctx.Err())
@@ -333,6 +402,7 @@ func (s *Session) FormatREPL(result query.Result) string {
 
 func (s *Session) ShapeOf(qu string) (interface{}, error) {
 	s.shape = make(map[string]interface{})
+	defer s.closeViews()
 	_, err := s.run(qu)
 	out := s.shape
 	s.shape = nil
@@ -344,16 +414,24 @@ func (s *Session) Collate(result query.Result) {
 		s.err = err
 		return
 	}
+	if row, ok := s.Row(result); ok {
+		s.dataOutput = append(s.dataOutput, row)
+	}
+}
+
+var _ query.StreamingHTTP = (*Session)(nil)
+
+// Row implements query.StreamingHTTP.
+func (s *Session) Row(result query.Result) (interface{}, bool) {
 	data, ok := result.(*Result)
 	if !ok {
 		clog.Errorf("unexpected result type: %T", result)
-		return
+		return nil, false
 	} else if data.Meta {
-		return
+		return nil, false
 	}
 	if data.Val != nil {
-		s.dataOutput = append(s.dataOutput, data.Val)
-		return
+		return data.Val, true
 	}
 	obj := make(map[string]interface{})
 	tags := data.Tags
@@ -369,9 +447,7 @@ func (s *Session) Collate(result query.Result) {
 			delete(obj, k)
 		}
 	}
-	if len(obj) != 0 {
-		s.dataOutput = append(s.dataOutput, obj)
-	}
+	return obj, len(obj) != 0
 }
 
 func (s *Session) Results() (interface{}, error) {
@@ -20,13 +20,18 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/graphtest/testutil"
+	_ "github.com/cayleygraph/cayley/graph/kv/btree"
 	_ "github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/temporal"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geo"
+	"github.com/cayleygraph/cayley/quad/vector"
 	"github.com/cayleygraph/cayley/query"
 	_ "github.com/cayleygraph/cayley/writer"
 
@@ -236,6 +241,20 @@ var testQueries = []struct {
 		`,
 		expect: []string{"<bob>", "<dani>", "<fred>"},
 	},
+	{
+		message: "use Order",
+		query: `
+			g.V("<alice>", "<bob>", "<charlie>").Out("<follows>").Unique().Order().Limit(2).All()
+		`,
+		expect: []string{"<bob>", "<dani>"},
+	},
+	{
+		message: "use Search",
+		query: `
+			g.V().Search("SMRT", {fuzziness: 1}).In("<status>").All()
+		`,
+		expect: []string{"<emily>", "<greg>"},
+	},
 
 	// Morphism tests.
 	{
@@ -373,6 +392,81 @@ var testQueries = []struct {
 		`,
 		expect: []string{"6"},
 	},
+	{
+		message: "use Explain",
+		query: `
+				var plan = g.V("<alice>").Out("<follows>").Explain()
+				g.Emit(plan.shape.type)
+				g.Emit(plan.iterator.next > 0)
+		`,
+		expect: []string{"NodesFrom", "true"},
+	},
+	{
+		message: "use aggregates",
+		data: []quad.Quad{
+			quad.Make(quad.IRI("alice"), quad.IRI("age"), quad.Int(30), nil),
+			quad.Make(quad.IRI("bob"), quad.IRI("age"), quad.Int(25), nil),
+			quad.Make(quad.IRI("bob"), quad.IRI("name"), quad.String("Bob"), nil),
+		},
+		query: `
+			g.Emit(g.V().Out("<age>").Sum())
+			g.Emit(g.V().Out("<age>").Min())
+			g.Emit(g.V().Out("<age>").Avg())
+			g.Emit(g.V().Out("<height>").Max())
+		`,
+		expect: []string{"25", "27.5", "55"},
+	},
+	{
+		message: "use aggregates on a property",
+		data: []quad.Quad{
+			quad.Make(quad.IRI("alice"), quad.IRI("age"), quad.Int(30), nil),
+			quad.Make(quad.IRI("bob"), quad.IRI("age"), quad.Int(25), nil),
+			quad.Make(quad.IRI("bob"), quad.IRI("name"), quad.String("Bob"), nil),
+		},
+		query: `
+			g.Emit(g.V().Sum("<age>"))
+			g.Emit(g.V("<alice>").Max("<age>"))
+			g.Emit(g.V().Min(g.M().Out("<age>")))
+		`,
+		expect: []string{"25", "30", "55"},
+	},
+	{
+		message: "use distinct",
+		query: `
+			g.Emit(g.V().Distinct("<status>").sort().join(","))
+			g.Emit(g.V("<alice>", "<charlie>").Out("<follows>").Distinct().length)
+		`,
+		expect: []string{"2", "cool_person,smart_person"},
+	},
+	{
+		message: "find shortest paths",
+		query: `
+			var p = g.V("<charlie>").ShortestPathTo("<greg>", {via: "<follows>"})
+			g.Emit(p.length + ": " + p[0].nodes.join(",") + " " + p[0].weight)
+			p = g.V("<alice>", "<charlie>").ShortestPathTo(["<fred>", "<greg>"], {via: g.M().Out("<follows>"), k: 3})
+			for (var i = 0; i < p.length; i++) {
+				g.Emit(p[i].nodes.join(",") + " " + p[i].weight)
+			}
+			g.Emit(g.V("<greg>").ShortestPathTo("<alice>").length)
+		`,
+		expect: []string{
+			"1: <charlie>,<dani>,<greg> 2",
+			"<charlie>,<bob>,<fred> 2",
+			"<charlie>,<dani>,<greg> 2",
+			"<alice>,<bob>,<fred> 2",
+			"0",
+		},
+	},
+	{
+		message: "use group by",
+		query: `
+			var groups = g.V().GroupBy("<status>")
+			g.Emit(groups["cool_person"].sort().join(","))
+			g.Emit(groups["smart_person"].sort().join(","))
+			g.Emit(Object.keys(g.V("<bob>").GroupBy("<follows>")).join(","))
+		`,
+		expect: []string{"<bob>,<dani>,<greg>", "<emily>,<greg>", "<fred>"},
+	},
 
 	// Tag tests.
 	{
@@ -574,6 +668,14 @@ var testQueries = []struct {
 		tag:    "depth",
 		expect: []string{intVal(1), intVal(1), intVal(2), intVal(2)},
 	},
+	{
+		message: "recursive follow path tags",
+		query: `
+			g.V("<charlie>").FollowRecursive("<follows>", {depthTag: "depth", pathTag: "hop"}).Is("<greg>").All();
+		`,
+		tag:    "hop1",
+		expect: []string{"<dani>"},
+	},
 	{
 		message: "recursive follow path",
 		query: `
@@ -588,6 +690,146 @@ var testQueries = []struct {
 		`,
 		expect: nil,
 	},
+	{
+		message: "near point",
+		data:    geoTestGraph,
+		query: `
+			g.V().NearPoint(48.8566, 2.3522, 5, "<location>").All()
+		`,
+		expect: []string{"<eiffel>", "<louvre>"},
+	},
+	{
+		message: "within polygon",
+		data:    geoTestGraph,
+		query: `
+			g.V().WithinPolygon([[50, -1], [50, 1], [52, 1], [52, -1]]).All()
+		`,
+		expect: []string{"<london>"},
+	},
+	{
+		message: "near filter",
+		data:    geoTestGraph,
+		query: `
+			g.V().Out("<location>").Near(48.8566, 2.3522, 2).In("<location>").All()
+		`,
+		expect: []string{"<louvre>"},
+	},
+	{
+		message: "filter by language",
+		data:    literalTestGraph,
+		query: `
+			g.V().Out("<label>").Filter(lang("en")).In("<label>").All()
+		`,
+		expect: []string{"<cat>", "<dog>"},
+	},
+	{
+		message: "filter by native datatype",
+		data:    literalTestGraph,
+		query: `
+			g.V().Out("<age>").Filter(datatype("<http://www.w3.org/2001/XMLSchema#integer>")).In("<age>").All()
+		`,
+		expect: []string{"<cat>", "<dog>"},
+	},
+	{
+		message: "filter by custom datatype",
+		data:    literalTestGraph,
+		query: `
+			g.V().Out("<age>").Filter(datatype("<ex:age>")).In("<age>").All()
+		`,
+		expect: []string{"<bird>"},
+	},
+	{
+		message: "within filter",
+		data:    geoTestGraph,
+		query: `
+			g.V("<london>", "<eiffel>").Out("<location>").Within([[48, 2], [48, 3], [49, 3], [49, 2]]).In("<location>").All()
+		`,
+		expect: []string{"<eiffel>"},
+	},
+	{
+		message: "near point without radius",
+		data:    geoTestGraph,
+		query: `
+			g.V().NearPoint(48.8566, 2.3522).All()
+		`,
+		err: true,
+	},
+	{
+		message: "nearest to",
+		data:    vectorTestGraph,
+		query: `
+			g.V().NearestTo([1, 0.1], 2, "<embedding>").Out("<name>").All()
+		`,
+		expect: []string{"cat", "kitten"},
+	},
+	{
+		message: "nearest to in path",
+		data:    vectorTestGraph,
+		query: `
+			g.V("<dog>").NearestTo([1, 0], 2).All()
+		`,
+		expect: nil,
+	},
+	{
+		message: "nearest to without k",
+		data:    vectorTestGraph,
+		query: `
+			g.V().NearestTo([1, 0]).All()
+		`,
+		err: true,
+	},
+	{
+		message: "valid at",
+		data:    temporalTestGraph,
+		query: `
+			g.V("<alice>").ValidAt("2005-01-01T00:00:00Z").Out("<worksAt>").All()
+		`,
+		expect: []string{"<acme>"},
+	},
+	{
+		message: "valid at as of",
+		data:    temporalTestGraph,
+		query: `
+			g.V("<alice>").ValidAt(new Date(Date.UTC(2015, 0, 1)), "2020-06-01T00:00:00Z").Out("<worksAt>").All()
+		`,
+		expect: nil,
+	},
+	{
+		message: "valid at invalid date",
+		data:    temporalTestGraph,
+		query: `
+			g.V("<alice>").ValidAt("yesterday").Out("<worksAt>").All()
+		`,
+		err: true,
+	},
+	{
+		message: "random walk",
+		query: `
+			g.Emit(g.RandomWalk("<alice>", {predicates: ["<follows>"], seed: 1}))
+		`,
+		expect: []string{"[<alice> <bob> <fred> <greg>]"},
+	},
+	{
+		message: "random walk length",
+		query: `
+			g.Emit(g.RandomWalk("<alice>", {predicates: "<follows>", length: 2}))
+		`,
+		expect: []string{"[<alice> <bob>]"},
+	},
+	{
+		message: "random walk unknown option",
+		query: `
+			g.Emit(g.RandomWalk("<alice>", {steps: 2}))
+		`,
+		err: true,
+	},
+	{
+		message: "sample nodes",
+		query: `
+			g.Emit(g.SampleNodes(3, 42).length)
+		`,
+		expect: []string{"3"},
+	},
 	{
 		message: "default limit All",
 		query: `
@@ -599,12 +841,52 @@ var testQueries = []struct {
 	},
 }
 
-func runQueryGetTag(rec func(), g []quad.Quad, qu string, tag string, limit int) ([]string, error) {
+var geoTestGraph = []quad.Quad{
+	quad.Make(quad.IRI("louvre"), quad.IRI("location"), geo.Point{Lat: 48.8606, Lng: 2.3376}.TypedString(), nil),
+	quad.Make(quad.IRI("eiffel"), quad.IRI("location"), geo.Point{Lat: 48.8584, Lng: 2.2945}.TypedString(), nil),
+	quad.Make(quad.IRI("london"), quad.IRI("location"), geo.Point{Lat: 51.5074, Lng: -0.1278}.TypedString(), nil),
+}
+
+var literalTestGraph = []quad.Quad{
+	quad.Make(quad.IRI("cat"), quad.IRI("label"), quad.LangString{Value: "cat", Lang: "en"}, nil),
+	quad.Make(quad.IRI("cat"), quad.IRI("label"), quad.LangString{Value: "chat", Lang: "fr"}, nil),
+	quad.Make(quad.IRI("cat"), quad.IRI("age"), quad.Int(3), nil),
+	quad.Make(quad.IRI("dog"), quad.IRI("label"), quad.LangString{Value: "dog", Lang: "EN"}, nil),
+	quad.Make(quad.IRI("dog"), quad.IRI("label"), quad.LangString{Value: "chien", Lang: "fr"}, nil),
+	quad.Make(quad.IRI("dog"), quad.IRI("age"), quad.TypedString{Value: "5", Type: "http://www.w3.org/2001/XMLSchema#long"}, nil),
+	quad.Make(quad.IRI("bird"), quad.IRI("label"), quad.String("bird"), nil),
+	quad.Make(quad.IRI("bird"), quad.IRI("age"), quad.TypedString{Value: "young", Type: "ex:age"}, nil),
+}
+
+var vectorTestGraph = []quad.Quad{
+	quad.Make(quad.IRI("cat"), quad.IRI("embedding"), vector.Vector{1, 0}.TypedString(), nil),
+	quad.Make(quad.IRI("cat"), quad.IRI("name"), quad.String("cat"), nil),
+	quad.Make(quad.IRI("kitten"), quad.IRI("embedding"), vector.Vector{0.9, 0.2}.TypedString(), nil),
+	quad.Make(quad.IRI("kitten"), quad.IRI("name"), quad.String("kitten"), nil),
+	quad.Make(quad.IRI("dog"), quad.IRI("embedding"), vector.Vector{0, 1}.TypedString(), nil),
+	quad.Make(quad.IRI("dog"), quad.IRI("name"), quad.String("dog"), nil),
+}
+
+func temporalDate(y int) quad.Value {
+	return quad.Time(time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+var temporalTestGraph = []quad.Quad{
+	quad.Make(quad.IRI("alice"), quad.IRI("worksAt"), quad.IRI("acme"), quad.BNode("v1")),
+	quad.Make(quad.BNode("v1"), temporal.ValidFrom, temporalDate(2000), nil),
+	quad.Make(quad.BNode("v1"), temporal.ValidTo, temporalDate(2010), nil),
+	quad.Make(quad.BNode("v1"), temporal.RecordedFrom, temporalDate(2020), nil),
+	quad.Make(quad.IRI("alice"), quad.IRI("worksAt"), quad.IRI("globex"), quad.BNode("v2")),
+	quad.Make(quad.BNode("v2"), temporal.ValidFrom, temporalDate(2010), nil),
+	quad.Make(quad.BNode("v2"), temporal.RecordedFrom, temporalDate(2021), nil),
+}
+
+func runQueryGetTag(ctx context.Context, rec func(), g []quad.Quad, qu string, tag string, limit int) ([]string, error) {
 	js := makeTestSession(g)
 	c := make(chan query.Result, 1)
 	go func() {
 		defer rec()
-		js.Execute(context.TODO(), qu, c, limit)
+		js.Execute(ctx, qu, c, limit)
 	}()
 
 	var results []string
@@ -630,6 +912,14 @@ func runQueryGetTag(rec func(), g []quad.Quad, qu string, tag string, limit int)
 }
 
 func TestGizmo(t *testing.T) {
+	testGizmo(t, context.TODO())
+}
+
+func TestGizmoParallel(t *testing.T) {
+	testGizmo(t, query.WithParallel(context.TODO(), 2))
+}
+
+func testGizmo(t *testing.T, ctx context.Context) {
 	simpleGraph := testutil.LoadGraph(t, "../../data/testdata.nq")
 	for _, test := range testQueries {
 		test := test
@@ -651,7 +941,7 @@ func TestGizmo(t *testing.T) {
 			if limit == 0 {
 				limit = -1
 			}
-			got, err := runQueryGetTag(rec, quads, test.query, test.tag, limit)
+			got, err := runQueryGetTag(ctx, rec, quads, test.query, test.tag, limit)
 			if err != nil {
 				if test.err {
 					return //expected
@@ -716,6 +1006,44 @@ g.Emit({id: x.id})
 	require.NoError(t, err)
 }
 
+func TestAt(t *testing.T) {
+	ctx := context.TODO()
+	qs, err := graph.NewQuadStore("btree", "", graph.Options{"delta_log": true})
+	require.NoError(t, err)
+	defer qs.Close()
+	w, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.AddQuad(quad.MakeIRI("alice", "follows", "bob", "")))
+	h, err := graph.Horizon(ctx, qs)
+	require.NoError(t, err)
+	require.NoError(t, w.AddQuad(quad.MakeIRI("alice", "follows", "charlie", "")))
+	require.NoError(t, w.RemoveQuad(quad.MakeIRI("alice", "follows", "bob", "")))
+
+	run := func(qu string) []string {
+		ses := NewSession(qs)
+		c := make(chan query.Result, 1)
+		go ses.Execute(ctx, qu, c, -1)
+		var got []string
+		for res := range c {
+			require.NoError(t, res.Err())
+			data := res.(*Result)
+			if data.Val != nil {
+				got = append(got, fmt.Sprint(data.Val))
+			} else {
+				got = append(got, quadValueToString(ses.qs.NameOf(data.Tags[TopResultTag])))
+			}
+		}
+		sort.Strings(got)
+		return got
+	}
+	require.Equal(t, []string{"<charlie>"}, run(`g.V("<alice>").Out("<follows>").All()`))
+	require.Equal(t, []string{"<bob>"}, run(fmt.Sprintf(`g.V("<alice>").At(%d).Out("<follows>").All()`, h)))
+	require.Equal(t, []string{"<bob>", "<charlie>"}, run(fmt.Sprintf(`g.V("<alice>").At(%d).Out("<follows>").All()`, h+1)))
+	require.Equal(t, []string{"<bob>"}, run(fmt.Sprintf(`g.Emit(g.V("<alice>").At(%d).Out("<follows>").ToArray()[0])`, h)))
+	require.Equal(t, []string{"0"}, run(`g.Emit(g.V().At(0).Count())`))
+	require.Equal(t, []string{"<alice>"}, run(`g.V("<alice>").At(new Date()).Out("<follows>").In("<follows>").All()`))
+}
+
 const issue718Limit = 5
 
 func issue718Graph() []quad.Quad {
@@ -17,14 +17,20 @@ package gizmo
 // Adds special traversal functions to JS Gizmo objects. Most of these just build the chain of objects, and won't often need the session.
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/dop251/goja"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/history"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/path"
 	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad/geo"
+	"github.com/cayleygraph/cayley/quad/vector"
+	"github.com/cayleygraph/cayley/query"
 )
 
 // pathObject is a Path object in Gizmo.
@@ -81,6 +87,10 @@ func (p *pathObject) buildIteratorTree() graph.Iterator {
 	if p.path == nil {
 		return iterator.NewNull()
 	}
+	if e := query.ExplainFrom(p.s.context()); e != nil {
+		// record shapes in the query plan
+		return e.BuildIterator(p.s.qs, p.path.Shape())
+	}
 	return p.path.BuildIteratorOn(p.s.qs)
 }
 
@@ -247,15 +257,39 @@ func (p *pathObject) FollowR(path *pathObject) *pathObject {
 //	// Returns all people in Charlie's network.
 //	// Returns bob and dani (from charlie), fred (from bob) and greg (from dani).
 //	g.V("<charlie>").FollowRecursive(friend).All()
+//
+// Instead of max depth and depth tags, an options object can be passed as the second argument.
+// It accepts "maxDepth", "depthTag" and "pathTag" fields. If "pathTag" is set, nodes on the path to each result
+// are saved to tags with this prefix and an index of the hop, starting from 0 for the start node.
+//
+// Example:
+// 	// javascript:
+//	// Returns greg with tags "hop0" = charlie, "hop1" = dani, "hop2" = greg, "depth" = 2 (and other nodes).
+//	g.V("<charlie>").FollowRecursive(friend, {depthTag: "depth", pathTag: "hop"}).All()
 func (p *pathObject) FollowRecursive(call goja.FunctionCall) goja.Value {
-	preds, maxDepth, tags, ok := toViaDepthData(exportArgs(call.Arguments))
+	args := exportArgs(call.Arguments)
+	var pathTags []string
+	if len(args) == 2 {
+		if m, ok := args[1].(map[string]interface{}); ok {
+			opt, err := toRecursiveOptions(m)
+			if err != nil {
+				return throwErr(p.s.vm, err)
+			}
+			args = append(args[:1], opt.maxDepth)
+			if opt.depthTags != nil {
+				args = append(args, opt.depthTags)
+			}
+			pathTags = opt.pathTags
+		}
+	}
+	preds, maxDepth, tags, ok := toViaDepthData(args)
 	if !ok || len(preds) == 0 {
 		return throwErr(p.s.vm, errNoVia)
 	} else if len(preds) != 1 {
 		return throwErr(p.s.vm, fmt.Errorf("expected one predicate or path for recursive follow"))
 	}
 	np := p.clonePath()
-	np = np.FollowRecursive(preds[0], maxDepth, tags)
+	np = np.FollowRecursiveWithPath(preds[0], maxDepth, tags, pathTags)
 	return p.newVal(np)
 }
 
@@ -533,6 +567,19 @@ func (p *pathObject) Unique() *pathObject {
 	return p.new(np)
 }
 
+// Order returns values from the path in ascending order.
+//
+// Numbers are ordered numerically, times chronologically and strings lexicographically.
+//
+// Example:
+// 	// javascript
+//	// Returns nodes followed by alice, bob and charlie, ordered by their IDs.
+//	g.V("<alice>", "<bob>", "<charlie>").Out("<follows>").Unique().Order().All()
+func (p *pathObject) Order() *pathObject {
+	np := p.clonePath().Order()
+	return p.new(np)
+}
+
 // Difference is an alias for Except.
 func (p *pathObject) Difference(path *pathObject) *pathObject {
 	return p.Except(path)
@@ -625,7 +672,95 @@ func (p *pathObject) LabelContext(call goja.FunctionCall) goja.Value {
 	return p.newVal(np)
 }
 
+// ValidAt restricts the following traversals to versions of facts valid at a given time.
+// Facts must be written as versions with valid-time and recorded-time attributes (see graph/temporal),
+// facts without versions are not traversed. It affects all In(), Out(), and Both() calls that follow it,
+// similar to LabelContext.
+// Signature: (time, [recorded])
+//
+// Arguments:
+//
+// * `time`: A Date or an RFC3339 string. Only facts valid at this time are considered.
+// * `recorded` (Optional): A Date or an RFC3339 string. Only versions known to the database at this time are considered.
+// Defaults to the current knowledge.
+//
+// Example:
+//	// javascript
+//	// Find where Alice worked in 2005
+//	g.V("<alice>").ValidAt("2005-01-01T00:00:00Z").Out("<worksAt>").All()
+//	// Same, but as it was recorded at the beginning of 2020
+//	g.V("<alice>").ValidAt(new Date(2005, 0, 1), new Date(2020, 0, 1)).Out("<worksAt>").All()
+func (p *pathObject) ValidAt(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) < 1 || len(args) > 2 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	var ts [2]time.Time
+	for i, a := range args {
+		t, ok := toTime(a)
+		if !ok {
+			return throwErr(p.s.vm, fmt.Errorf("expected a date, got: %v", a))
+		}
+		ts[i] = t
+	}
+	// zero recorded time means the current knowledge
+	np := p.clonePath().ValidAtAsOf(ts[0], ts[1])
+	return p.newVal(np)
+}
+
+// At evaluates the path against the state of the graph at a given horizon or time, instead of the current state.
+// The backend must record a delta log (see `delta_log` option), and the state is rebuilt by reverting transactions
+// that were made after that point, thus it may be expensive for large graphs.
+// Signature: (horizon)
+//
+// Arguments:
+//
+// * `horizon`: A number of the transaction (horizon) to use, or a Date or an RFC3339 string.
+// For a time, the state after the last transaction made before or at that time is used.
+//
+// Example:
+//	// javascript
+//	// Find who Alice followed after the 10th transaction
+//	g.V("<alice>").At(10).Out("<follows>").All()
+//	// Find who Alice followed at the beginning of 2020
+//	g.V("<alice>").At("2020-01-01T00:00:00Z").Out("<follows>").All()
+func (p *pathObject) At(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 1 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	var h int64
+	switch a := args[0].(type) {
+	case int64:
+		h = a
+	case float64:
+		h = int64(a)
+	default:
+		t, ok := toTime(a)
+		if !ok {
+			return throwErr(p.s.vm, fmt.Errorf("expected a horizon or a date, got: %v", a))
+		}
+		var err error
+		h, err = history.HorizonAt(p.s.context(), p.s.root().qs, t)
+		if err != nil {
+			return throwErr(p.s.vm, err)
+		}
+	}
+	s, err := p.s.at(h)
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	return p.s.vm.ToValue(&pathObject{s: s, finals: p.finals, path: p.clonePath()})
+}
+
 // Filter applies constraints to a set of nodes. Can be used to filter values by range or match strings.
+//
+// Literals can be filtered by language tag with `lang("en")` and by datatype with `datatype("<xsd:integer>")`.
+//
+// Example:
+//	// javascript
+//	// Return English labels only.
+//	g.V().Out("<rdfs:label>").Filter(lang("en")).All()
 func (p *pathObject) Filter(args ...valFilter) (*pathObject, error) {
 	if len(args) == 0 {
 		return nil, errArgCount{Got: len(args)}
@@ -638,6 +773,220 @@ func (p *pathObject) Filter(args ...valFilter) (*pathObject, error) {
 	return p.new(np), nil
 }
 
+// Search filters string literals that contain all words of a full-text query. Words are compared case-insensitively.
+// Signature: (query, [options])
+//
+// Arguments:
+//
+// * `query`: A text to search for.
+// * `options` (Optional): An object with search options:
+//   * `fuzziness`: A number of edits allowed for each word to still match.
+//   * `boost`: Relevance boost for backends that rank matches; it does not affect the results.
+//
+// Example:
+//	// javascript
+//	// Find all nodes with a status that mentions "cool"
+//	g.V().Search("cool").In("<status>").All()
+func (p *pathObject) Search(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) < 1 || len(args) > 2 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	text, ok := args[0].(string)
+	if !ok {
+		return throwErr(p.s.vm, fmt.Errorf("expected a string, got: %v", args[0]))
+	}
+	f := shape.FullText{Query: text}
+	if len(args) == 2 && args[1] != nil {
+		m, ok := args[1].(map[string]interface{})
+		if !ok {
+			return throwErr(p.s.vm, fmt.Errorf("expected an options object, got: %v", args[1]))
+		}
+		for k, v := range m {
+			switch k {
+			case "fuzziness":
+				f.Fuzziness, ok = toInt(v)
+			case "boost":
+				f.Boost, ok = toFloat(v)
+			default:
+				return throwErr(p.s.vm, fmt.Errorf("unknown search option: %q", k))
+			}
+			if !ok {
+				return throwErr(p.s.vm, fmt.Errorf("invalid value for search option %q: %v", k, v))
+			}
+		}
+	}
+	np := p.clonePath().Filters(f)
+	return p.newVal(np)
+}
+
+// NearPoint filters nodes that are subjects of geometry literals located within a given distance from a point.
+// Literals must be of `geo:wktLiteral` or `geo:geoJSONLiteral` types.
+// Signature: (lat, lng, radiusKm, [predicate])
+//
+// Arguments:
+//
+// * `lat`, `lng`: Coordinates of the point in degrees.
+// * `radiusKm`: A distance from the point in kilometers.
+// * `predicate` (Optional): A predicate or a list of predicates with geometries to check. Defaults to all predicates.
+//
+// Example:
+//	// javascript
+//	// Find places within 5 km from the center of Paris
+//	g.V().NearPoint(48.8566, 2.3522, 5, "<location>").Out("<name>").All()
+func (p *pathObject) NearPoint(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) < 3 || len(args) > 4 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	var c [3]float64
+	for i := range c {
+		f, ok := toFloat(args[i])
+		if !ok {
+			return throwErr(p.s.vm, fmt.Errorf("expected a number, got: %v", args[i]))
+		}
+		c[i] = f
+	}
+	preds, err := toPredicates(args[3:])
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	np := p.clonePath().NearPoint(c[0], c[1], c[2], preds...)
+	return p.newVal(np)
+}
+
+// WithinPolygon filters nodes that are subjects of geometry literals located inside a polygon.
+// Literals must be of `geo:wktLiteral` or `geo:geoJSONLiteral` types.
+// Signature: (points, [predicate])
+//
+// Arguments:
+//
+// * `points`: A list of polygon vertices as `[lat, lng]` pairs.
+// * `predicate` (Optional): A predicate or a list of predicates with geometries to check. Defaults to all predicates.
+//
+// Example:
+//	// javascript
+//	// Find places inside a bounding box
+//	g.V().WithinPolygon([[48, 2], [48, 3], [49, 3], [49, 2]]).All()
+func (p *pathObject) WithinPolygon(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) < 1 || len(args) > 2 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	poly, err := toPolygon(args[0])
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	preds, err := toPredicates(args[1:])
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	np := p.clonePath().WithinPolygon(poly, preds...)
+	return p.newVal(np)
+}
+
+// Near filters geometry literals located within a given distance from a point.
+// Literals must be of `geo:wktLiteral` or `geo:geoJSONLiteral` types; polygons are matched by their center.
+// Signature: (lat, lng, radiusKm)
+//
+// Arguments:
+//
+// * `lat`, `lng`: Coordinates of the point in degrees.
+// * `radiusKm`: A distance from the point in kilometers.
+//
+// Unlike NearPoint, it filters geometries in the current path instead of finding their subjects.
+//
+// Example:
+//	// javascript
+//	// Find locations of "<cafe>" within 5 km from the center of Paris
+//	g.V("<cafe>").Out("<location>").Near(48.8566, 2.3522, 5).All()
+func (p *pathObject) Near(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 3 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	var c [3]float64
+	for i := range c {
+		f, ok := toFloat(args[i])
+		if !ok {
+			return throwErr(p.s.vm, fmt.Errorf("expected a number, got: %v", args[i]))
+		}
+		c[i] = f
+	}
+	np := p.clonePath().Filters(shape.GeoWithin{Region: geo.Circle{Point: geo.Point{Lat: c[0], Lng: c[1]}, Radius: c[2]}})
+	return p.newVal(np)
+}
+
+// Within filters geometry literals located inside a polygon.
+// Literals must be of `geo:wktLiteral` or `geo:geoJSONLiteral` types; polygons are matched by their center.
+// Signature: (points)
+//
+// Arguments:
+//
+// * `points`: A list of polygon vertices as `[lat, lng]` pairs.
+//
+// Example:
+//	// javascript
+//	// Find locations inside a bounding box
+//	g.V().Out("<location>").Within([[48, 2], [48, 3], [49, 3], [49, 2]]).All()
+func (p *pathObject) Within(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 1 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	poly, err := toPolygon(args[0])
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	np := p.clonePath().Filters(shape.GeoWithin{Region: poly})
+	return p.newVal(np)
+}
+
+// NearestTo filters nodes that are subjects of `k` vector literals nearest to a given vector.
+// Literals must be of `cayley:vector` type, for example `"[0.1, 0.2]"^^<cayley:vector>`.
+// Signature: (vector, k, [predicate])
+//
+// Arguments:
+//
+// * `vector`: A list of numbers to compare vectors with.
+// * `k`: A number of nearest nodes to find.
+// * `predicate` (Optional): A predicate or a list of predicates with vectors to compare. Defaults to all predicates.
+//
+// Nearest nodes are found first and then intersected with the current path, thus the result may have less than `k` nodes.
+//
+// Example:
+//	// javascript
+//	// Find names of 5 documents most similar to a given embedding
+//	g.V().NearestTo([0.12, -0.5, 0.33], 5, "<embedding>").Out("<name>").All()
+func (p *pathObject) NearestTo(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) < 2 || len(args) > 3 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	list, ok := args[0].([]interface{})
+	if !ok || len(list) == 0 {
+		return throwErr(p.s.vm, errors.New("expected a non-empty list of numbers"))
+	}
+	vec := make(vector.Vector, 0, len(list))
+	for _, o := range list {
+		f, ok := toFloat(o)
+		if !ok {
+			return throwErr(p.s.vm, fmt.Errorf("expected a number, got: %v", o))
+		}
+		vec = append(vec, float32(f))
+	}
+	k, ok := toInt(args[1])
+	if !ok || k <= 0 {
+		return throwErr(p.s.vm, fmt.Errorf("expected a positive number, got: %v", args[1]))
+	}
+	preds, err := toPredicates(args[2:])
+	if err != nil {
+		return throwErr(p.s.vm, err)
+	}
+	np := p.clonePath().NearestTo(vec, k, preds...)
+	return p.newVal(np)
+}
+
 // Limit limits a number of nodes for current path.
 //
 // Arguments:
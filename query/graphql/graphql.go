@@ -16,6 +16,7 @@ import (
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/shape"
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/cayleygraph/cayley/query"
 )
@@ -64,7 +65,9 @@ type Session struct {
 
 func (s *Session) Execute(ctx context.Context, qu string, out chan query.Result, limit int) {
 	defer close(out)
+	_, end := graph.StartSpan(ctx, "parse")
 	q, err := Parse(strings.NewReader(qu))
+	end(err)
 	if err != nil {
 		select {
 		case out <- query.ErrorResult(err):
@@ -91,14 +94,17 @@ func (s *Session) FormatREPL(result query.Result) string {
 
 // Configurable keywords and special field names.
 var (
-	ValueKey = "id"
-	LimitKey = "first"
-	SkipKey  = "offset"
-	AnyKey   = "*"
+	ValueKey    = "id"
+	LimitKey    = "first"
+	SkipKey     = "offset"
+	LangKey     = "lang"
+	DatatypeKey = "datatype"
+	AnyKey      = "*"
 )
 
 type Query struct {
 	fields []field
+	muts   []mutation
 }
 
 type has struct {
@@ -164,6 +170,24 @@ func iterateObject(ctx context.Context, qs graph.QuadStore, f *field, p *path.Pa
 					skip = 0
 				}
 			}
+		case quad.IRI(LangKey), quad.IRI(DatatypeKey): // literal filters
+			if len(h.Values) != 1 {
+				return nil, fmt.Errorf("unexpected arguments: %v (%d)", h.Values, len(h.Values))
+			}
+			var s string
+			switch v := h.Values[0].(type) {
+			case quad.String:
+				s = string(v)
+			case quad.IRI:
+				s = string(v)
+			default:
+				return nil, fmt.Errorf("unexpected value type for %v: %T", string(h.Via), h.Values[0])
+			}
+			if h.Via == quad.IRI(LangKey) {
+				p = p.Filters(shape.LangFilter{Lang: s})
+			} else {
+				p = p.Filters(shape.DatatypeFilter{Type: quad.IRI(s)})
+			}
 		default: // everything else - Has constraint
 			if len(h.Labels) != 0 {
 				p = p.LabelContext(h.Labels)
@@ -367,20 +391,28 @@ func iterateObject(ctx context.Context, qs graph.QuadStore, f *field, p *path.Pa
 	return out, nil
 }
 
+// oneOrMany returns a single object if the list has only one element, or the list itself otherwise.
+func oneOrMany(arr []map[string]interface{}) interface{} {
+	if len(arr) == 1 {
+		return arr[0]
+	} else if len(arr) > 1 {
+		return arr
+	}
+	return nil
+}
+
+// Execute runs the query. Mutations require a quad writer to be set on the context with query.WithWriter.
 func (q *Query) Execute(ctx context.Context, qs graph.QuadStore) (map[string]interface{}, error) {
+	if len(q.muts) != 0 {
+		return q.mutate(ctx, qs)
+	}
 	out := make(map[string]interface{})
 	for _, f := range q.fields {
 		arr, err := iterateObject(ctx, qs, &f, path.StartPath(qs))
 		if err != nil {
 			return out, err
 		}
-		var v interface{}
-		if len(arr) == 1 {
-			v = arr[0]
-		} else if len(arr) > 1 {
-			v = arr
-		}
-		out[f.Alias] = v
+		out[f.Alias] = oneOrMany(arr)
 	}
 	return out, nil
 }
@@ -400,6 +432,12 @@ func Parse(r io.Reader) (*Query, error) {
 	def, ok := doc.Definitions[0].(*ast.OperationDefinition)
 	if !ok {
 		return nil, fmt.Errorf("unsupported query type: %T", doc.Definitions[0])
+	} else if def.Operation == "mutation" {
+		muts, err := setToMutations(def.SelectionSet)
+		if err != nil {
+			return nil, err
+		}
+		return &Query{muts: muts}, nil
 	} else if def.Operation != "query" {
 		return nil, fmt.Errorf("unsupported operation: %s", def.Operation)
 	}
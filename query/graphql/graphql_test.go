@@ -13,6 +13,7 @@ import (
 	"github.com/cayleygraph/cayley/graph/graphtest/testutil"
 	"github.com/cayleygraph/cayley/graph/memstore"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
 	"github.com/cayleygraph/cayley/voc/rdf"
 )
 
@@ -275,3 +276,90 @@ func TestExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestLiteralFilters(t *testing.T) {
+	qs := memstore.New()
+	qw := testutil.MakeWriter(t, qs, nil)
+	err := qw.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("cat", "type", "Animal", ""),
+		quad.Make(quad.IRI("cat"), quad.IRI("label"), quad.LangString{Value: "cat", Lang: "en"}, nil),
+		quad.Make(quad.IRI("cat"), quad.IRI("label"), quad.LangString{Value: "chat", Lang: "fr"}, nil),
+		quad.Make(quad.IRI("cat"), quad.IRI("age"), quad.TypedString{Value: "kitten", Type: "ex:age"}, nil),
+		quad.Make(quad.IRI("cat"), quad.IRI("age"), quad.Int(1), nil),
+	})
+	require.NoError(t, err)
+
+	q, err := Parse(strings.NewReader(`{
+  animals(type: <Animal>) {
+    label(` + LangKey + `: "fr") { ` + ValueKey + ` }
+    age(` + DatatypeKey + `: <ex:age>) { ` + ValueKey + ` }
+  }
+}`))
+	require.NoError(t, err)
+	out, err := q.Execute(context.Background(), qs)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"animals": map[string]interface{}{
+			"label": map[string]interface{}{ValueKey: quad.LangString{Value: "chat", Lang: "fr"}},
+			"age":   map[string]interface{}{ValueKey: quad.TypedString{Value: "kitten", Type: "ex:age"}},
+		},
+	}, out, "results:\n%v", toJson(out))
+}
+
+func TestMutation(t *testing.T) {
+	qs := memstore.New()
+	qw := testutil.MakeWriter(t, qs, nil)
+	quads := testutil.LoadGraph(t, "../../data/testdata.nq")
+	err := qw.AddQuadSet(quads)
+	require.NoError(t, err)
+
+	q, err := Parse(strings.NewReader(`mutation {
+  insert(input: {id: <carol>, name: "Carol", follows: {id: <bob>}}) {
+    id
+    name
+    follows { id }
+  }
+  update(input: [{id: <greg>, status: "busy"}]) {
+    id
+    status
+  }
+  removed: delete(id: <emily>) {
+    id
+    status
+  }
+}`))
+	require.NoError(t, err)
+
+	_, err = q.Execute(context.Background(), qs)
+	require.Equal(t, errReadOnly, err)
+
+	out, err := q.Execute(query.WithWriter(context.Background(), qw), qs)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"insert": map[string]interface{}{
+			ValueKey:  quad.IRI("carol"),
+			"name":    quad.String("Carol"),
+			"follows": map[string]interface{}{ValueKey: quad.IRI("bob")},
+		},
+		"update": map[string]interface{}{
+			ValueKey: quad.IRI("greg"),
+			"status": quad.String("busy"),
+		},
+		"removed": map[string]interface{}{
+			ValueKey: quad.IRI("emily"),
+			"status": quad.String("smart_person"),
+		},
+	}, out, "results:\n%v", toJson(out))
+
+	q, err = Parse(strings.NewReader(`{
+  people(status: "busy") { id }
+  removed(id: <emily>) { id }
+}`))
+	require.NoError(t, err)
+	out, err = q.Execute(context.Background(), qs)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"people":  map[string]interface{}{ValueKey: quad.IRI("greg")},
+		"removed": nil,
+	}, out, "results:\n%v", toJson(out))
+}
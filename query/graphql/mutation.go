@@ -0,0 +1,278 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dennwc/graphql/language/ast"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/schema"
+)
+
+// Configurable names of mutations and their arguments.
+var (
+	InsertKey = "insert"
+	UpdateKey = "update"
+	DeleteKey = "delete"
+	InputKey  = "input"
+)
+
+var errReadOnly = errors.New("mutations are not allowed: database is read-only")
+
+// idKey is a key of the object ID in input objects, as expected by the schema package.
+const idKey = "@id"
+
+type mutation struct {
+	Op     string
+	Alias  string
+	Input  []map[string]interface{} // objects to insert or update
+	IDs    []quad.Value             // nodes to delete
+	Fields []field                  // fields to return for affected nodes
+}
+
+func setToMutations(set *ast.SelectionSet) (out []mutation, _ error) {
+	if set == nil {
+		return
+	}
+	for _, s := range set.Selections {
+		sel, ok := s.(*ast.Field)
+		if !ok {
+			return nil, fmt.Errorf("unknown selection type: %T", s)
+		}
+		m, err := convMutation(sel)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return
+}
+
+func convMutation(fld *ast.Field) (out mutation, err error) {
+	out.Op = fld.Name.Value
+	if fld.Alias != nil && fld.Alias.Value != "" {
+		out.Alias = fld.Alias.Value
+	} else {
+		out.Alias = out.Op
+	}
+	switch out.Op {
+	case InsertKey, UpdateKey, DeleteKey:
+	default:
+		return out, fmt.Errorf("unknown mutation: %q", out.Op)
+	}
+	if len(fld.Directives) != 0 {
+		return out, fmt.Errorf("directives are not supported for mutations")
+	}
+	for _, arg := range fld.Arguments {
+		name := arg.Name.Value
+		switch {
+		case out.Op == DeleteKey && name == ValueKey:
+			vals, err := convValue(arg.Value)
+			if err != nil {
+				return out, err
+			}
+			out.IDs = append(out.IDs, vals...)
+		case out.Op != DeleteKey && name == InputKey:
+			objs, err := convInput(arg.Value)
+			if err != nil {
+				return out, err
+			}
+			out.Input = append(out.Input, objs...)
+		default:
+			return out, fmt.Errorf("unexpected argument for %s: %q", out.Op, name)
+		}
+	}
+	if out.Op == DeleteKey {
+		if len(out.IDs) == 0 {
+			return out, fmt.Errorf("%s requires %q argument", out.Op, ValueKey)
+		}
+	} else if len(out.Input) == 0 {
+		return out, fmt.Errorf("%s requires %q argument", out.Op, InputKey)
+	}
+	if out.Op == UpdateKey {
+		for _, obj := range out.Input {
+			if _, ok := obj[idKey]; !ok {
+				return out, fmt.Errorf("objects passed to %s must have %q field", out.Op, ValueKey)
+			}
+		}
+	}
+	var all bool
+	out.Fields, all, err = setToFields(fld.SelectionSet, nil)
+	if err != nil {
+		return
+	} else if all {
+		return out, fmt.Errorf("expand all is not supported for mutations")
+	}
+	if len(out.Fields) == 0 {
+		out.Fields = []field{{Via: quad.IRI(ValueKey), Alias: ValueKey}}
+	}
+	return
+}
+
+// convInput converts an input argument of a mutation to a list of objects.
+func convInput(v ast.Value) ([]map[string]interface{}, error) {
+	switch v := v.(type) {
+	case *ast.ObjectValue:
+		obj, err := convObject(v)
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]interface{}{obj}, nil
+	case *ast.ListValue:
+		out := make([]map[string]interface{}, 0, len(v.Values))
+		for _, sv := range v.Values {
+			ov, ok := sv.(*ast.ObjectValue)
+			if !ok {
+				return nil, fmt.Errorf("expected an object in %q list, got: %T", InputKey, sv)
+			}
+			obj, err := convObject(ov)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, obj)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("expected an object or a list of objects, got: %T", v)
+	}
+}
+
+// convObject converts an input object to a map in a format expected by schema.WriteAsQuads.
+func convObject(o *ast.ObjectValue) (map[string]interface{}, error) {
+	obj := make(map[string]interface{}, len(o.Fields))
+	for _, f := range o.Fields {
+		name := f.Name.Value
+		if name == ValueKey {
+			vals, err := convValue(f.Value)
+			if err != nil {
+				return nil, err
+			} else if len(vals) != 1 {
+				return nil, fmt.Errorf("expected a single value for %q, got: %v", ValueKey, vals)
+			}
+			switch vals[0].(type) {
+			case quad.IRI, quad.BNode:
+			default:
+				return nil, fmt.Errorf("%q must be an IRI or a blank node, got: %v", ValueKey, vals[0])
+			}
+			obj[idKey] = vals[0]
+			continue
+		}
+		via, rev := stringToVia(name)
+		if rev {
+			return nil, fmt.Errorf("reverse predicates are not supported in mutations: %q", name)
+		}
+		v, err := convInputValue(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		obj[string(via)] = v
+	}
+	return obj, nil
+}
+
+func convInputValue(v ast.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case *ast.ObjectValue:
+		return convObject(v)
+	case *ast.ListValue:
+		out := make([]interface{}, 0, len(v.Values))
+		for _, sv := range v.Values {
+			cv, err := convInputValue(sv)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, cv)
+		}
+		return out, nil
+	default:
+		vals, err := convValue(v)
+		if err != nil {
+			return nil, err
+		} else if len(vals) != 1 {
+			return nil, fmt.Errorf("unexpected value array: %v (%d)", vals, len(vals))
+		}
+		return vals[0], nil
+	}
+}
+
+// mutate applies all mutations in a single transaction and returns the requested fields of affected nodes.
+//
+// Inserted and updated nodes are loaded after the transaction is applied, while deleted nodes
+// are loaded before it, thus the result contains their last state.
+func (q *Query) mutate(ctx context.Context, qs graph.QuadStore) (map[string]interface{}, error) {
+	qw := query.WriterFrom(ctx)
+	if qw == nil {
+		return nil, errReadOnly
+	}
+	sch := schema.NewConfig()
+	tx := graph.NewTransaction()
+	w := graph.NewTxWriter(tx, graph.Add)
+
+	out := make(map[string]interface{})
+	ids := make([][]quad.Value, len(q.muts))
+	for i, m := range q.muts {
+		if m.Op == DeleteKey {
+			v, err := loadMutated(ctx, qs, m.Fields, m.IDs)
+			if err != nil {
+				return nil, err
+			}
+			out[m.Alias] = v
+			for _, id := range m.IDs {
+				tx.Append(graph.Delta{Quad: quad.Quad{Subject: id}, Action: graph.DeleteMatching})
+				tx.Append(graph.Delta{Quad: quad.Quad{Object: id}, Action: graph.DeleteMatching})
+			}
+			continue
+		}
+		for _, obj := range m.Input {
+			if m.Op == UpdateKey {
+				// replace all values of predicates that are set in the object
+				id := obj[idKey].(quad.Value)
+				preds := make([]string, 0, len(obj))
+				for k := range obj {
+					if k != idKey {
+						preds = append(preds, k)
+					}
+				}
+				sort.Strings(preds)
+				for _, p := range preds {
+					tx.Append(graph.Delta{Quad: quad.Quad{Subject: id, Predicate: quad.IRI(p)}, Action: graph.DeleteMatching})
+				}
+			}
+			id, err := sch.WriteAsQuads(w, obj)
+			if err != nil {
+				return nil, err
+			}
+			ids[i] = append(ids[i], id)
+		}
+	}
+	if err := qw.ApplyTransaction(tx); err != nil {
+		return nil, err
+	}
+	for i, m := range q.muts {
+		if m.Op == DeleteKey {
+			continue
+		}
+		v, err := loadMutated(ctx, qs, m.Fields, ids[i])
+		if err != nil {
+			return nil, err
+		}
+		out[m.Alias] = v
+	}
+	return out, nil
+}
+
+// loadMutated loads fields of nodes affected by a mutation.
+func loadMutated(ctx context.Context, qs graph.QuadStore, fields []field, ids []quad.Value) (interface{}, error) {
+	f := field{Fields: fields}
+	arr, err := iterateObject(ctx, qs, &f, path.StartPath(qs, ids...))
+	if err != nil {
+		return nil, err
+	}
+	return oneOrMany(arr), nil
+}
@@ -0,0 +1,317 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Names of execution limits, as reported by LimitError.
+const (
+	LimitResults = "results"
+	LimitMemory  = "memory"
+	LimitQuads   = "quads"
+)
+
+// Limits are per-request caps on query execution. Zero value means no limit.
+type Limits struct {
+	// MaxResults is the maximal number of results a query can return.
+	MaxResults int
+	// MaxMemory is the maximal size (in bytes) of values a query can load. This is an approximation.
+	MaxMemory int64
+	// MaxQuads is the maximal number of quads a query can touch while executing. Nodes read by full scans
+	// are counted as well. Backends that execute queries natively may touch more quads than reported.
+	MaxQuads int64
+}
+
+// IsZero checks if no limits are set.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// LimitError is returned when a query exceeds one of execution limits.
+type LimitError struct {
+	Limit string // one of Limit* constants
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("query exceeded %s limit (%d)", e.Limit, e.Max)
+}
+
+// Budget tracks resources used by a single query. Nil budget has no limits.
+type Budget struct {
+	lim    Limits
+	cancel func()
+
+	mu      sync.Mutex
+	results int
+	memory  int64
+	quads   int64
+	err     *LimitError
+}
+
+func (b *Budget) exceeded(name string, max int64) {
+	if b.err == nil {
+		b.err = &LimitError{Limit: name, Max: max}
+		b.cancel()
+	}
+}
+
+// Err returns LimitError if any limit was exceeded, or nil otherwise.
+func (b *Budget) Err() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err == nil {
+		return nil
+	}
+	return b.err
+}
+
+// AddResult accounts for a single result returned by the query.
+func (b *Budget) AddResult() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.results++
+	if b.lim.MaxResults > 0 && b.results > b.lim.MaxResults {
+		b.exceeded(LimitResults, int64(b.lim.MaxResults))
+	}
+	if b.err == nil {
+		return nil
+	}
+	return b.err
+}
+
+// ResultLimit returns a limit that should be passed to the session to detect if MaxResults is exceeded.
+func (b *Budget) ResultLimit(limit int) int {
+	if b == nil {
+		return limit
+	}
+	if max := b.lim.MaxResults; max > 0 && (limit <= 0 || limit > max) {
+		return max + 1
+	}
+	return limit
+}
+
+func (b *Budget) touch(quads int64, vals ...quad.Value) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return false
+	}
+	b.quads += quads
+	if b.lim.MaxQuads > 0 && b.quads > b.lim.MaxQuads {
+		b.exceeded(LimitQuads, b.lim.MaxQuads)
+		return false
+	}
+	if b.lim.MaxMemory > 0 {
+		for _, v := range vals {
+			b.memory += valueSize(v)
+		}
+		if b.memory > b.lim.MaxMemory {
+			b.exceeded(LimitMemory, b.lim.MaxMemory)
+			return false
+		}
+	}
+	return true
+}
+
+// valueSize returns an approximate size of the value in memory.
+func valueSize(v quad.Value) int64 {
+	const overhead = 16 // interface and string headers
+	if v == nil {
+		return 0
+	}
+	return overhead + int64(len(quad.StringOf(v)))
+}
+
+// WithLimits wraps a QuadStore to enforce execution limits.
+//
+// It returns a context that is canceled when one of the limits is exceeded. Query should be executed
+// with this context and the returned QuadStore. Budget can be used to check which limit was hit.
+// Each quad or node read by iterators of the QuadStore is counted, as well as the size of values it resolves.
+// When any limit is exceeded, these iterators stop and return LimitError from Err.
+//
+// If no limits are set, context and QuadStore are returned unchanged with a nil Budget.
+func WithLimits(ctx context.Context, qs graph.QuadStore, l Limits) (context.Context, graph.QuadStore, *Budget) {
+	if l.IsZero() {
+		return ctx, qs, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	b := &Budget{lim: l, cancel: cancel}
+	return ctx, &limitedQuadStore{QuadStore: graph.UnwrapHandle(qs), b: b}, b
+}
+
+type limitedQuadStore struct {
+	graph.QuadStore
+	b *Budget
+}
+
+func (qs *limitedQuadStore) Quad(v graph.Value) quad.Quad {
+	q := qs.QuadStore.Quad(v)
+	qs.b.touch(0, q.Subject, q.Predicate, q.Object, q.Label)
+	return q
+}
+
+func (qs *limitedQuadStore) NameOf(v graph.Value) quad.Value {
+	nv := qs.QuadStore.NameOf(v)
+	qs.b.touch(0, nv)
+	return nv
+}
+
+func (qs *limitedQuadStore) ValuesOf(ctx context.Context, vals []graph.Value) ([]quad.Value, error) {
+	out, err := graph.ValuesOf(ctx, qs.QuadStore, vals)
+	if err != nil {
+		return nil, err
+	}
+	if !qs.b.touch(0, out...) {
+		return nil, qs.b.Err()
+	}
+	return out, nil
+}
+
+func (qs *limitedQuadStore) QuadIterator(d quad.Direction, v graph.Value) graph.Iterator {
+	return newLimitedIterator(qs.QuadStore.QuadIterator(d, v), qs.b)
+}
+
+func (qs *limitedQuadStore) QuadsAllIterator() graph.Iterator {
+	return newLimitedIterator(qs.QuadStore.QuadsAllIterator(), qs.b)
+}
+
+func (qs *limitedQuadStore) NodesAllIterator() graph.Iterator {
+	return newLimitedIterator(qs.QuadStore.NodesAllIterator(), qs.b)
+}
+
+// OptimizeIterator passes optimization to the underlying QuadStore. Iterators it returns read
+// from the store directly, thus they are counted as well.
+func (qs *limitedQuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool) {
+	nit, ok := qs.QuadStore.OptimizeIterator(it)
+	if !ok {
+		return it, false
+	}
+	return newLimitedIterator(nit, qs.b), true
+}
+
+// OptimizeShape passes shape optimization to the underlying QuadStore, if supported.
+func (qs *limitedQuadStore) OptimizeShape(s shape.Shape) (shape.Shape, bool) {
+	if o, ok := qs.QuadStore.(shape.Optimizer); ok {
+		return o.OptimizeShape(s)
+	}
+	return s, false
+}
+
+// limitedType is a type of iterators that count reads of their sub-iterators.
+const limitedType = graph.Type("budget")
+
+var _ graph.Iterator = (*limitedIterator)(nil)
+
+// limitedIterator counts each result and each Contains check of the sub-iterator as a single touched quad.
+// Clones of the iterator share the budget.
+type limitedIterator struct {
+	uid uint64
+	sub graph.Iterator
+	b   *Budget
+}
+
+func newLimitedIterator(sub graph.Iterator, b *Budget) *limitedIterator {
+	return &limitedIterator{uid: iterator.NextUID(), sub: sub, b: b}
+}
+
+func (it *limitedIterator) UID() uint64 {
+	return it.uid
+}
+
+func (it *limitedIterator) Tagger() *graph.Tagger {
+	return it.sub.Tagger()
+}
+
+func (it *limitedIterator) TagResults(dst map[string]graph.Value) {
+	it.sub.TagResults(dst)
+}
+
+func (it *limitedIterator) Result() graph.Value {
+	return it.sub.Result()
+}
+
+func (it *limitedIterator) Next(ctx context.Context) bool {
+	return it.sub.Next(ctx) && it.b.touch(1)
+}
+
+func (it *limitedIterator) NextPath(ctx context.Context) bool {
+	return it.sub.NextPath(ctx) && it.b.touch(1)
+}
+
+func (it *limitedIterator) Contains(ctx context.Context, v graph.Value) bool {
+	return it.b.touch(1) && it.sub.Contains(ctx, v)
+}
+
+// Err returns an error of the sub-iterator, or LimitError if any limit was exceeded.
+func (it *limitedIterator) Err() error {
+	if err := it.sub.Err(); err != nil {
+		return err
+	}
+	return it.b.Err()
+}
+
+func (it *limitedIterator) Reset() {
+	it.sub.Reset()
+}
+
+func (it *limitedIterator) Clone() graph.Iterator {
+	return newLimitedIterator(it.sub.Clone(), it.b)
+}
+
+func (it *limitedIterator) Stats() graph.IteratorStats {
+	return it.sub.Stats()
+}
+
+func (it *limitedIterator) Size() (int64, bool) {
+	return it.sub.Size()
+}
+
+func (it *limitedIterator) Type() graph.Type { return limitedType }
+
+func (it *limitedIterator) Optimize() (graph.Iterator, bool) {
+	nit, ok := it.sub.Optimize()
+	if ok {
+		it.sub = nit
+	}
+	return it, false
+}
+
+func (it *limitedIterator) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.sub}
+}
+
+func (it *limitedIterator) Close() error {
+	return it.sub.Close()
+}
+
+func (it *limitedIterator) String() string {
+	return "Budget"
+}
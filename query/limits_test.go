@@ -0,0 +1,68 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits(t *testing.T) {
+	mem := memstore.New(
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", ""),
+		quad.MakeIRI("bob", "status", "cool", ""),
+	)
+	touchAll := func(lim query.Limits) (context.Context, *query.Budget) {
+		ctx, qs, b := query.WithLimits(context.Background(), mem, lim)
+		it := qs.QuadsAllIterator()
+		defer it.Close()
+		for it.Next(ctx) {
+			q := qs.Quad(it.Result())
+			require.NotEqual(t, quad.Quad{}, q)
+			qs.NameOf(qs.ValueOf(q.Subject))
+		}
+		require.Equal(t, b.Err(), it.Err())
+		return ctx, b
+	}
+
+	_, b := touchAll(query.Limits{})
+	require.Nil(t, b)
+	require.NoError(t, b.Err())
+
+	ctx, b := touchAll(query.Limits{MaxQuads: 10})
+	require.NoError(t, b.Err())
+	require.NoError(t, ctx.Err())
+
+	ctx, b = touchAll(query.Limits{MaxQuads: 2})
+	require.Equal(t, &query.LimitError{Limit: query.LimitQuads, Max: 2}, b.Err())
+	require.Equal(t, context.Canceled, ctx.Err())
+
+	_, b = touchAll(query.Limits{MaxMemory: 50})
+	require.Equal(t, &query.LimitError{Limit: query.LimitMemory, Max: 50}, b.Err())
+
+	// nodes are counted by full scans and checks of quad iterators
+	ctx, qs, b := query.WithLimits(context.Background(), mem, query.Limits{MaxQuads: 6})
+	it := qs.NodesAllIterator()
+	n := 0
+	for it.Next(ctx) {
+		n++
+	}
+	it.Close()
+	require.Equal(t, 6, n)
+	require.NoError(t, it.Err())
+	it = qs.QuadIterator(quad.Subject, qs.ValueOf(quad.IRI("bob")))
+	require.False(t, it.Contains(ctx, qs.ValueOf(quad.IRI("alice"))))
+	require.Equal(t, &query.LimitError{Limit: query.LimitQuads, Max: 6}, it.Err())
+	it.Close()
+
+	_, _, b = query.WithLimits(context.Background(), mem, query.Limits{MaxResults: 2})
+	require.Equal(t, 3, b.ResultLimit(100))
+	require.Equal(t, 1, b.ResultLimit(1))
+	require.NoError(t, b.AddResult())
+	require.NoError(t, b.AddResult())
+	require.Equal(t, &query.LimitError{Limit: query.LimitResults, Max: 2}, b.AddResult())
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Metrics receives execution statistics of queries. Functions must be safe for concurrent use.
+type Metrics struct {
+	// Backend is called for each QuadStore method called by the query, with a name of the method.
+	// It is only called if the QuadStore was wrapped with Instrument. Optional.
+	Backend func(op string)
+	// Iterators is called each time a query finishes iterating over results, with the number of Next and
+	// Contains calls made to all iterators of the tree. Optional.
+	//
+	// Iterators are only profiled if it is set, since profiling adds an overhead to each call.
+	Iterators func(next, contains int64)
+}
+
+type metricsKey struct{}
+
+// WithMetrics enables collection of execution statistics for queries executed with a given context.
+func WithMetrics(ctx context.Context, m *Metrics) context.Context {
+	return context.WithValue(ctx, metricsKey{}, m)
+}
+
+// MetricsFrom returns metrics set by WithMetrics, or nil.
+func MetricsFrom(ctx context.Context) *Metrics {
+	m, _ := ctx.Value(metricsKey{}).(*Metrics)
+	return m
+}
+
+// Instrument wraps a QuadStore to count calls to it, if the context collects backend metrics (see WithMetrics).
+// Otherwise, the QuadStore is returned unchanged.
+//
+// Calls made by iterators of the backend to itself are not visible to the wrapper.
+func Instrument(ctx context.Context, qs graph.QuadStore) graph.QuadStore {
+	m := MetricsFrom(ctx)
+	if m == nil || m.Backend == nil {
+		return qs
+	}
+	return &countedQuadStore{QuadStore: graph.UnwrapHandle(qs), call: m.Backend}
+}
+
+type countedQuadStore struct {
+	graph.QuadStore
+	call func(op string)
+}
+
+var _ graph.Wrapper = (*countedQuadStore)(nil)
+
+// Unwrap implements graph.Wrapper.
+func (qs *countedQuadStore) Unwrap() graph.QuadStore {
+	return qs.QuadStore
+}
+
+func (qs *countedQuadStore) Quad(v graph.Value) quad.Quad {
+	qs.call("quad")
+	return qs.QuadStore.Quad(v)
+}
+
+func (qs *countedQuadStore) QuadDirection(v graph.Value, d quad.Direction) graph.Value {
+	qs.call("quad_direction")
+	return qs.QuadStore.QuadDirection(v, d)
+}
+
+func (qs *countedQuadStore) QuadIterator(d quad.Direction, v graph.Value) graph.Iterator {
+	qs.call("quad_iterator")
+	return qs.QuadStore.QuadIterator(d, v)
+}
+
+func (qs *countedQuadStore) NodesAllIterator() graph.Iterator {
+	qs.call("nodes_all_iterator")
+	return qs.QuadStore.NodesAllIterator()
+}
+
+func (qs *countedQuadStore) QuadsAllIterator() graph.Iterator {
+	qs.call("quads_all_iterator")
+	return qs.QuadStore.QuadsAllIterator()
+}
+
+func (qs *countedQuadStore) ValueOf(v quad.Value) graph.Value {
+	qs.call("value_of")
+	return qs.QuadStore.ValueOf(v)
+}
+
+func (qs *countedQuadStore) NameOf(v graph.Value) quad.Value {
+	qs.call("name_of")
+	return qs.QuadStore.NameOf(v)
+}
+
+func (qs *countedQuadStore) Size() int64 {
+	qs.call("size")
+	return qs.QuadStore.Size()
+}
+
+// ValuesOf resolves values in batches, if supported by the underlying QuadStore. Each batch is counted as a single call.
+func (qs *countedQuadStore) ValuesOf(ctx context.Context, vals []graph.Value) ([]quad.Value, error) {
+	qs.call("values_of")
+	return graph.ValuesOf(ctx, qs.QuadStore, vals)
+}
+
+// OptimizeShape passes shape optimization to the underlying QuadStore, if supported.
+func (qs *countedQuadStore) OptimizeShape(s shape.Shape) (shape.Shape, bool) {
+	if o, ok := qs.QuadStore.(shape.Optimizer); ok {
+		return o.OptimizeShape(s)
+	}
+	return s, false
+}
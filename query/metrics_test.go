@@ -0,0 +1,64 @@
+package query_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	"github.com/stretchr/testify/require"
+)
+
+type traceRecorder struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (r *traceRecorder) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	return ctx, func(error) {
+		r.mu.Lock()
+		r.spans = append(r.spans, name)
+		r.mu.Unlock()
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	mem := memstore.New(
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", ""),
+		quad.MakeIRI("bob", "status", "cool", ""),
+	)
+	var (
+		mu    sync.Mutex
+		calls = make(map[string]int)
+		next  int64
+	)
+	m := &query.Metrics{
+		Backend: func(op string) {
+			mu.Lock()
+			calls[op]++
+			mu.Unlock()
+		},
+		Iterators: func(n, _ int64) {
+			next += n
+		},
+	}
+	tr := &traceRecorder{}
+	ctx := graph.WithTracer(query.WithMetrics(context.Background(), m), tr)
+
+	qs := query.Instrument(ctx, mem)
+	require.Equal(t, mem, graph.Unwrap(qs))
+
+	it := qs.QuadIterator(quad.Subject, qs.ValueOf(quad.IRI("bob")))
+	vals, err := query.Iterate(ctx, qs, it).AllValues(qs)
+	require.NoError(t, err)
+	require.Len(t, vals, 2)
+
+	require.Equal(t, 1, calls["quad_iterator"])
+	require.Equal(t, 1, calls["value_of"])
+	require.True(t, next >= 2, "next: %d", next)
+	require.Equal(t, []string{"optimize", "iterate"}, tr.spans)
+}
@@ -73,25 +73,24 @@ func (s *Session) ShapeOf(query string) (interface{}, error) {
 func (s *Session) Execute(ctx context.Context, input string, c chan query.Result, limit int) {
 	defer close(c)
 	var mqlQuery interface{}
-	if err := json.Unmarshal([]byte(input), &mqlQuery); err != nil {
-		select {
-		case c <- query.ErrorResult(err):
-		case <-ctx.Done():
-		}
-		return
+	_, end := graph.StartSpan(ctx, "parse")
+	err := json.Unmarshal([]byte(input), &mqlQuery)
+	if err == nil {
+		s.query = NewQuery(s)
+		s.query.BuildIteratorTree(mqlQuery)
+		err = s.query.err
 	}
-	s.query = NewQuery(s)
-	s.query.BuildIteratorTree(mqlQuery)
-	if s.query.isError() {
+	end(err)
+	if err != nil {
 		select {
-		case c <- query.ErrorResult(s.query.err):
+		case c <- query.ErrorResult(err):
 		case <-ctx.Done():
 		}
 		return
 	}
 
 	it := s.query.it
-	err := graph.Iterate(ctx, it).Limit(limit).TagEach(func(tags map[string]graph.Value) {
+	err = query.Iterate(ctx, s.qs, it).Limit(limit).TagEach(func(tags map[string]graph.Value) {
 		select {
 		case c <- query.TagMapResult(tags):
 		case <-ctx.Done():
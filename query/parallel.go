@@ -0,0 +1,78 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+)
+
+type parallelKey struct{}
+
+// WithParallel enables parallel execution of independent branches of queries executed with a given context.
+// Each branch runs in a separate goroutine and buffers up to n results. Zero value disables parallel execution.
+//
+// Parallel execution helps backends with high latency of reads by overlapping their round trips.
+func WithParallel(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, parallelKey{}, n)
+}
+
+// Parallel returns the buffer size for parallel execution set by WithParallel, or zero if it is disabled.
+func Parallel(ctx context.Context) int {
+	n, _ := ctx.Value(parallelKey{}).(int)
+	return n
+}
+
+// Iterate is the same as graph.Iterate, but executes independent branches of the iterator tree in parallel,
+// if it was enabled for the context with WithParallel. Query sessions should use it to run iterators.
+//
+// If the context collects query plans (see WithExplain), the iterator is also profiled and its plan is recorded.
+// If the context collects iterator metrics (see WithMetrics), the number of iterator calls is reported when the iteration ends.
+func Iterate(ctx context.Context, qs graph.QuadStore, it graph.Iterator) *graph.IterateChain {
+	n := Parallel(ctx)
+	e := ExplainFrom(ctx)
+	m := MetricsFrom(ctx)
+	if m != nil && m.Iterators == nil {
+		m = nil
+	}
+	if n <= 0 && e == nil && m == nil {
+		return graph.Iterate(ctx, it)
+	}
+	orig := it
+	_, end := graph.StartSpan(ctx, "optimize")
+	it, _ = it.Optimize()
+	it, _ = qs.OptimizeIterator(it)
+	end(nil)
+	if n > 0 {
+		it = iterator.Parallelize(it, n)
+	}
+	if e != nil {
+		it = e.profile(orig, it)
+	}
+	if m != nil {
+		// profiled iterators are reused if the plan is also recorded
+		it = iterator.Profile(it)
+	}
+	c := graph.Iterate(ctx, it).On(qs).UnOptimized()
+	if m != nil {
+		c.OnClose(func() {
+			st := iterator.TotalProfileStats(it)
+			m.Iterators(st.Next, st.Contains)
+		})
+	}
+	return c
+}
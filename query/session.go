@@ -68,6 +68,14 @@ type HTTP interface {
 	Results() (interface{}, error)
 }
 
+// StreamingHTTP is an optional interface for HTTP sessions that can convert each result to a response row
+// separately. It allows sending results to the client as they are produced, without collecting them in memory.
+type StreamingHTTP interface {
+	HTTP
+	// Row converts a single result to a response row. It returns false if the result produces no rows.
+	Row(Result) (interface{}, bool)
+}
+
 type REPLSession interface {
 	Session
 	FormatREPL(Result) string
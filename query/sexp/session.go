@@ -76,8 +76,10 @@ func (s *Session) Parse(input string) error {
 
 func (s *Session) Execute(ctx context.Context, input string, out chan query.Result, limit int) {
 	defer close(out)
+	_, end := graph.StartSpan(ctx, "parse")
 	it := BuildIteratorTreeForQuery(s.qs, input)
-	err := graph.Iterate(ctx, it).Paths(true).Limit(limit).TagEach(func(tags map[string]graph.Value) {
+	end(nil)
+	err := query.Iterate(ctx, s.qs, it).Paths(true).Limit(limit).TagEach(func(tags map[string]graph.Value) {
 		select {
 		case out <- query.TagMapResult(tags):
 		case <-ctx.Done():
@@ -0,0 +1,40 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+type writerKey struct{}
+
+// WithWriter associates a quad writer with the context. Query languages that support writes
+// (for example, GraphQL mutations) use it to apply changes to the graph.
+//
+// Writes are disabled if the writer is not set.
+func WithWriter(ctx context.Context, qw graph.QuadWriter) context.Context {
+	return context.WithValue(ctx, writerKey{}, qw)
+}
+
+// WriterFrom returns a quad writer associated with the context, or nil if writes are disabled.
+func WriterFrom(ctx context.Context) graph.QuadWriter {
+	if ctx == nil {
+		return nil
+	}
+	qw, _ := ctx.Value(writerKey{}).(graph.QuadWriter)
+	return qw
+}
@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// isPolymorphic checks if values of a given type should be loaded as one of registered types.
+// Empty interfaces are not considered, since they are used for native quad values.
+func isPolymorphic(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Interface && rt.NumMethod() != 0
+}
+
+// implementations returns all registered types that implement a given interface, indexed by type IRIs.
+// If only a pointer to a registered type implements the interface, the pointer type is returned.
+func implementations(it reflect.Type) map[quad.IRI]reflect.Type {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	out := make(map[quad.IRI]reflect.Type)
+	for rt, iri := range typeToIRI {
+		if rt.Implements(it) {
+			out[iri] = rt
+		} else if pt := reflect.PtrTo(rt); pt.Implements(it) {
+			out[iri] = pt
+		}
+	}
+	return out
+}
+
+// typeOf finds a Go type of a node by looking at its rdf:type values. Types must be indexed by full IRIs.
+// If the node has multiple matching types, the first one returned by the quad store is used.
+func (c *Config) typeOf(ctx context.Context, qs graph.QuadStore, v graph.Value, byType map[quad.IRI]reflect.Type) (reflect.Type, error) {
+	pred := qs.ValueOf(c.iri(iriType))
+	if pred == nil {
+		return nil, nil
+	}
+	it := qs.QuadIterator(quad.Subject, v)
+	defer it.Close()
+	for it.Next(ctx) {
+		q := it.Result()
+		if !keysEqual(qs.QuadDirection(q, quad.Predicate), pred) {
+			continue
+		}
+		if iri, ok := qs.NameOf(qs.QuadDirection(q, quad.Object)).(quad.IRI); ok {
+			if rt, ok := byType[iri.Full()]; ok {
+				return rt, nil
+			}
+		}
+	}
+	return nil, it.Err()
+}
+
+// loadInterfaceTo loads objects to a destination of an interface type (or a slice or a channel of them).
+// A concrete type of each object is selected by its rdf:type from types registered with RegisterType.
+func (c *Config) loadInterfaceTo(ctx context.Context, qs graph.QuadStore, dst reflect.Value, et reflect.Type, slice, chanl bool, depth int, list graph.Iterator, pg *pager) error {
+	impl := implementations(et)
+	if len(impl) == 0 {
+		return fmt.Errorf("no registered types implement %v", et)
+	}
+	byType := make(map[quad.IRI]reflect.Type, len(impl))
+	iris := make([]string, 0, len(impl))
+	for iri, rt := range impl {
+		byType[iri.Full()] = rt
+		iris = append(iris, string(iri))
+	}
+	sort.Strings(iris)
+	types := make([]quad.Value, 0, len(iris))
+	for _, iri := range iris {
+		types = append(types, c.iri(quad.IRI(iri)))
+	}
+	it, err := iteratorFromPath(qs, list, path.StartMorphism().Has(c.iri(iriType), types...))
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for it.Next(ctx) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		id := it.Result()
+		if pg != nil && pg.skip(id) {
+			continue
+		}
+		rt, err := c.typeOf(ctx, qs, id, byType)
+		if err != nil {
+			return err
+		} else if rt == nil {
+			continue
+		}
+		st := rt
+		if st.Kind() == reflect.Ptr {
+			st = st.Elem()
+		}
+		obj := reflect.New(st)
+		sit := iterator.NewFixed()
+		sit.Add(id)
+		err = c.loadIteratorToDepth(ctx, qs, obj, depth, sit, nil)
+		if err == errRequiredFieldIsMissing {
+			if !slice && !chanl {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		cur := obj
+		if rt.Kind() != reflect.Ptr {
+			cur = obj.Elem()
+		}
+		if slice {
+			dst.Set(reflect.Append(dst, cur))
+		} else if chanl {
+			dst.Send(cur)
+		} else {
+			dst.Set(cur)
+			return nil
+		}
+		if pg != nil && pg.full(ctx, qs, it, id) {
+			return it.Err()
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if pg != nil && pg.after != nil {
+		return fmt.Errorf("cursor object not found: %v", pg.After)
+	}
+	if slice || chanl {
+		return nil
+	}
+	return errNotFound
+}
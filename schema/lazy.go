@@ -0,0 +1,49 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var lazyType = reflect.TypeOf(Lazy{})
+
+var errLazyNotBound = errors.New("reference is not bound to a quad store")
+
+// Lazy is a reference to an object that is loaded on demand.
+//
+// It can be used as a field type instead of a nested struct to avoid loading large object graphs at once.
+// Only an ID of the referenced object is loaded together with the parent object, and the object itself
+// can be loaded later with LoadTo. When writing, Lazy fields are written as links to the ID.
+//
+//	type Person struct{
+//		ID quad.IRI `json:"@id"`
+//		Name string `json:"name"`
+//		Friends []schema.Lazy `json:"friend"`
+//	}
+type Lazy struct {
+	ID quad.Value
+
+	src *lazySource
+}
+
+type lazySource struct {
+	c  *Config
+	qs graph.QuadStore
+}
+
+// LoadTo loads the referenced object to a destination Go object. See Config.LoadTo for details.
+func (l Lazy) LoadTo(ctx context.Context, dst interface{}) error {
+	return l.LoadToDepth(ctx, dst, -1)
+}
+
+// LoadToDepth is the same as LoadTo, but stops at a specified depth.
+func (l Lazy) LoadToDepth(ctx context.Context, dst interface{}, depth int) error {
+	if l.src == nil {
+		return errLazyNotBound
+	}
+	return l.src.c.LoadToDepth(ctx, l.src.qs, dst, depth, l.ID)
+}
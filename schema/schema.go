@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 
@@ -247,6 +248,10 @@ var (
 // RegisterType associates an IRI with a given Go type.
 //
 // All queries and writes will require or add a type triple.
+//
+// Registered types are also used to load objects into values of interface types, for example []Animal.
+// A type of each object is selected by its rdf:type from registered types (or pointers to them) that
+// implement the interface. Objects with no matching type are skipped.
 func RegisterType(iri quad.IRI, obj interface{}) {
 	var rt reflect.Type
 	if obj != nil {
@@ -546,6 +551,7 @@ func (c *Config) loadToValue(ctx context.Context, qs graph.QuadStore, dst reflec
 			}
 		}
 	}
+	var src *lazySource // shared by all lazy references of the object
 	for i := 0; i < rt.NumField(); i++ {
 		select {
 		case <-ctx.Done():
@@ -578,14 +584,15 @@ func (c *Config) loadToValue(ctx context.Context, qs graph.QuadStore, dst reflec
 			native = native || isNative(ft)
 			ft = ft.Elem()
 		}
-		recursive := !native && ft.Kind() == reflect.Struct
+		lazy := ft == lazyType
+		recursive := !native && !lazy && (ft.Kind() == reflect.Struct || isPolymorphic(ft))
 		for _, fv := range arr {
 			var sv reflect.Value
 			if recursive {
 				sv = reflect.New(ft).Elem()
 				sit := iterator.NewFixed()
 				sit.Add(fv)
-				err := c.loadIteratorToDepth(ctx, qs, sv, depth-1, sit)
+				err := c.loadIteratorToDepth(ctx, qs, sv, depth-1, sit, nil)
 				if err == errRequiredFieldIsMissing {
 					continue
 				} else if err != nil {
@@ -596,7 +603,14 @@ func (c *Config) loadToValue(ctx context.Context, qs graph.QuadStore, dst reflec
 				if fv == nil {
 					continue
 				}
-				sv = reflect.ValueOf(fv)
+				if lazy {
+					if src == nil {
+						src = &lazySource{c: c, qs: qs}
+					}
+					sv = reflect.ValueOf(Lazy{ID: fv, src: src})
+				} else {
+					sv = reflect.ValueOf(fv)
+				}
 			}
 			if err := DefaultConverter.SetValue(df, sv); err != nil {
 				return fmt.Errorf("field %s: %v", f.Name, err)
@@ -738,10 +752,78 @@ func (c *Config) LoadIteratorToDepth(ctx context.Context, qs graph.QuadStore, ds
 		// 0 depth means "current level only" for user, but it's easier to make depth=0 a stop condition
 		depth++
 	}
-	return c.loadIteratorToDepth(ctx, qs, dst, depth, list)
+	return c.loadIteratorToDepth(ctx, qs, dst, depth, list, nil)
+}
+
+// Page selects a subset of objects to load.
+type Page struct {
+	// Offset is the number of objects to skip.
+	Offset int
+	// Limit is the maximal number of objects to load. Zero means no limit.
+	Limit int
+	// After is a cursor returned by a previous call to LoadIteratorPageTo.
+	// If set, objects are loaded starting after the object with this ID.
+	After quad.Value
+}
+
+// pager tracks the state of a single page while loading objects.
+type pager struct {
+	Page
+	after graph.Value // cursor object; nil when it was already reached
+	n     int         // number of objects loaded
+	next  quad.Value  // cursor for the next page
+}
+
+// skip checks if an object should be skipped before loading it.
+func (p *pager) skip(v graph.Value) bool {
+	if p.after != nil {
+		if keysEqual(p.after, v) {
+			p.after = nil
+		}
+		return true
+	}
+	if p.Offset > 0 {
+		p.Offset--
+		return true
+	}
+	return false
+}
+
+// full records a loaded object and checks if the page is full.
+// If so, it advances the iterator to check if there are more objects and sets a cursor for the next page.
+func (p *pager) full(ctx context.Context, qs graph.QuadStore, it graph.Iterator, id graph.Value) bool {
+	p.n++
+	if p.Limit <= 0 || p.n < p.Limit {
+		return false
+	}
+	if it.Next(ctx) {
+		p.next = qs.NameOf(id)
+	}
+	return true
+}
+
+// LoadIteratorPageTo is the same as LoadIteratorTo, but loads only a single page of objects into a slice or a channel.
+//
+// It returns a cursor that can be passed as Page.After to load the next page, or nil if there are no more objects.
+// Objects are returned in the order of the iterator, thus the same iterator tree should be used for all pages.
+// Offset and the cursor are applied to matching nodes, before nested objects are loaded.
+func (c *Config) LoadIteratorPageTo(ctx context.Context, qs graph.QuadStore, dst reflect.Value, list graph.Iterator, pg Page) (quad.Value, error) {
+	p := &pager{Page: pg}
+	if pg.After != nil {
+		if p.after = qs.ValueOf(pg.After); p.after == nil {
+			return nil, fmt.Errorf("cursor object not found: %v", pg.After)
+		}
+	}
+	err := c.loadIteratorToDepth(ctx, qs, dst, -1, list, p)
+	return p.next, err
+}
+
+// LoadPathPageTo is the same as LoadIteratorPageTo, but starts loading objects from a given path.
+func (c *Config) LoadPathPageTo(ctx context.Context, qs graph.QuadStore, dst interface{}, p *path.Path, pg Page) (quad.Value, error) {
+	return c.LoadIteratorPageTo(ctx, qs, reflect.ValueOf(dst), p.BuildIterator(), pg)
 }
 
-func (c *Config) loadIteratorToDepth(ctx context.Context, qs graph.QuadStore, dst reflect.Value, depth int, list graph.Iterator) error {
+func (c *Config) loadIteratorToDepth(ctx context.Context, qs graph.QuadStore, dst reflect.Value, depth int, list graph.Iterator, pg *pager) error {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -758,6 +840,9 @@ func (c *Config) loadIteratorToDepth(ctx context.Context, qs graph.QuadStore, ds
 		chanl = true
 		defer dst.Close()
 	}
+	if isPolymorphic(et) {
+		return c.loadInterfaceTo(ctx, qs, dst, et, slice, chanl, depth, list, pg)
+	}
 	fields, err := c.rulesFor(et)
 	if err != nil {
 		return err
@@ -781,6 +866,10 @@ func (c *Config) loadIteratorToDepth(ctx context.Context, qs graph.QuadStore, ds
 			return ctx.Err()
 		default:
 		}
+		id := it.Result()
+		if pg != nil && pg.skip(id) {
+			continue
+		}
 		mp := make(map[string]graph.Value)
 		it.TagResults(mp)
 		if len(mp) == 0 {
@@ -843,10 +932,16 @@ func (c *Config) loadIteratorToDepth(ctx context.Context, qs graph.QuadStore, ds
 		} else {
 			return nil
 		}
+		if pg != nil && pg.full(ctx, qs, it, id) {
+			return it.Err()
+		}
 	}
 	if err := it.Err(); err != nil {
 		return err
 	}
+	if pg != nil && pg.after != nil {
+		return fmt.Errorf("cursor object not found: %v", pg.After)
+	}
 	if slice || chanl {
 		return nil
 	}
@@ -870,6 +965,15 @@ func (c *Config) writeOneValReflect(w quad.Writer, id quad.Value, pred quad.Valu
 	if isZero(rv) {
 		return nil
 	}
+	if rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	if l, ok := reflect.Indirect(rv).Interface().(Lazy); ok {
+		if l.ID == nil {
+			return nil
+		}
+		rv = reflect.ValueOf(l.ID)
+	}
 	targ, ok := quad.AsValue(rv.Interface())
 	if !ok {
 		if rv.Kind() == reflect.Ptr {
@@ -990,9 +1094,16 @@ func (c *Config) idFor(rules fieldRules, rt reflect.Type, rv reflect.Value, pref
 // Otherwise, a new BNode will be generated using GenerateID function.
 //
 // See LoadTo for a list of quads mapping rules.
+//
+// Objects can also be passed as map[string]interface{}, in which case keys are used as predicates.
+// Special keys "@id" and "@type" are mapped to object ID and rdf:type respectively. Values can be
+// quad values, native Go values, nested maps or slices of them.
 func (c *Config) WriteAsQuads(w quad.Writer, o interface{}) (quad.Value, error) {
-	if v, ok := o.(quad.Value); ok {
-		return v, nil
+	switch o := o.(type) {
+	case quad.Value:
+		return o, nil
+	case map[string]interface{}:
+		return c.writeMap(w, o)
 	}
 	rv := reflect.ValueOf(o)
 	if rv.Kind() == reflect.Ptr {
@@ -1019,6 +1130,72 @@ func (c *Config) WriteAsQuads(w quad.Writer, o interface{}) (quad.Value, error)
 	return id, nil
 }
 
+// writeMap writes an object represented as a map in form of quads.
+func (c *Config) writeMap(w quad.Writer, m map[string]interface{}) (quad.Value, error) {
+	var id quad.Value
+	if v, ok := m["@id"]; ok {
+		switch v := v.(type) {
+		case quad.IRI:
+			id = c.iri(v)
+		case quad.BNode:
+			id = v
+		case string:
+			id = c.toIRI(v)
+		default:
+			return nil, fmt.Errorf("unsupported type for id field: %T", v)
+		}
+	} else {
+		id = c.genID(m)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if k != "@id" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := c.writeMapValue(w, id, c.toIRI(k), m[k]); err != nil {
+			return nil, err
+		}
+	}
+	return id, nil
+}
+
+func (c *Config) writeMapValue(w quad.Writer, id quad.Value, pred quad.IRI, v interface{}) error {
+	var targ quad.Value
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for _, sv := range v {
+			if err := c.writeMapValue(w, id, pred, sv); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		sid, err := c.writeMap(w, v)
+		if err != nil {
+			return err
+		}
+		targ = sid
+	case string:
+		if pred == c.iri(iriType) {
+			targ = c.toIRI(v)
+		} else {
+			targ = quad.String(v)
+		}
+	default:
+		var ok bool
+		targ, ok = quad.AsValue(v)
+		if !ok {
+			return fmt.Errorf("unsupported type: %T", v)
+		}
+	}
+	return w.WriteQuad(quad.Quad{Subject: id, Predicate: pred, Object: targ, Label: c.Label})
+}
+
 type namespace struct {
 	_      struct{} `quad:"@type > cayley:namespace"`
 	Full   quad.IRI `quad:"@id"`
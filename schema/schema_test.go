@@ -102,6 +102,32 @@ type subSubObject struct {
 func init() {
 	voc.RegisterPrefix("ex:", "http://example.org/")
 	schema.RegisterType(quad.IRI("ex:Coords"), Coords{})
+	schema.RegisterType(quad.IRI("ex:Cat"), Cat{})
+	schema.RegisterType(quad.IRI("ex:Dog"), Dog{})
+}
+
+type Animal interface {
+	Sound() string
+}
+
+type Cat struct {
+	ID   quad.IRI `quad:"@id"`
+	Name string   `quad:"ex:name"`
+}
+
+func (Cat) Sound() string { return "meow" }
+
+type Dog struct {
+	ID    quad.IRI `quad:"@id"`
+	Name  string   `quad:"ex:name"`
+	Breed string   `quad:"ex:breed,optional"`
+}
+
+func (*Dog) Sound() string { return "woof" }
+
+type Zoo struct {
+	ID      quad.IRI `quad:"@id"`
+	Animals []Animal `quad:"ex:animal"`
 }
 
 type Coords struct {
@@ -291,6 +317,31 @@ var testWriteValueCases = []struct {
 		},
 		nil,
 	},
+	{
+		"map",
+		map[string]interface{}{
+			"@id":   "1234",
+			"@type": "some:Type",
+			"name":  "some item",
+			"num":   3,
+			"items": []interface{}{iri("sub1"), quad.String("val")},
+			"sub": map[string]interface{}{
+				"@id":  iri("sub2"),
+				"name": "Sub 2",
+			},
+		},
+		iri("1234"),
+		[]quad.Quad{
+			{iri("1234"), typeIRI, iri("some:Type"), nil},
+			{iri("1234"), iri("items"), iri("sub1"), nil},
+			{iri("1234"), iri("items"), quad.String("val"), nil},
+			{iri("1234"), iri("name"), quad.String("some item"), nil},
+			{iri("1234"), iri("num"), quad.Int(3), nil},
+			{iri("sub2"), iri("name"), quad.String("Sub 2"), nil},
+			{iri("1234"), iri("sub"), iri("sub2"), nil},
+		},
+		nil,
+	},
 }
 
 type quadSlice []quad.Quad
@@ -617,6 +668,172 @@ func TestLoadIteratorTo(t *testing.T) {
 	}
 }
 
+type namedNode struct {
+	ID   quad.IRI `quad:"@id"`
+	Name string   `quad:"name"`
+}
+
+func TestLoadIteratorPageTo(t *testing.T) {
+	sch := schema.NewConfig()
+	qs := memstore.New(treeQuads...)
+
+	var all []namedNode
+	if err := sch.LoadTo(nil, qs, &all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("unexpected number of objects: %d", len(all))
+	}
+
+	var (
+		got   []namedNode
+		after quad.Value
+		pages int
+	)
+	for {
+		var page []namedNode
+		next, err := sch.LoadIteratorPageTo(nil, qs, reflect.ValueOf(&page), nil, schema.Page{Limit: 2, After: after})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		if len(page) > 2 {
+			t.Fatalf("page is too large: %v", page)
+		}
+		got = append(got, page...)
+		if next == nil {
+			break
+		} else if pages > 5 {
+			t.Fatal("too many pages")
+		}
+		after = next
+	}
+	if pages != 3 {
+		t.Errorf("unexpected number of pages: %d", pages)
+	}
+	if !reflect.DeepEqual(all, got) {
+		t.Errorf("objects are different\n%v\n%v", got, all)
+	}
+
+	var page []namedNode
+	next, err := sch.LoadIteratorPageTo(nil, qs, reflect.ValueOf(&page), nil, schema.Page{Offset: 3, Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	} else if next != nil {
+		t.Errorf("unexpected cursor: %v", next)
+	} else if !reflect.DeepEqual(all[3:], page) {
+		t.Errorf("objects are different\n%v\n%v", page, all[3:])
+	}
+}
+
+type lazyTreeItem struct {
+	ID       quad.IRI      `quad:"@id"`
+	Name     string        `quad:"name"`
+	Children []schema.Lazy `quad:"child,optional"`
+}
+
+func TestLazy(t *testing.T) {
+	sch := schema.NewConfig()
+	qs := memstore.New(treeQuads...)
+
+	var out lazyTreeItem
+	if err := sch.LoadTo(nil, qs, &out, iri("n1")); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range out.Children {
+		ids = append(ids, quad.ToString(c.ID))
+	}
+	sort.Strings(ids)
+	if !reflect.DeepEqual(ids, []string{"<n2>", "<n3>"}) {
+		t.Fatalf("unexpected children: %v", ids)
+	}
+	for _, c := range out.Children {
+		if c.ID != iri("n3") {
+			continue
+		}
+		var sub treeItem
+		if err := c.LoadTo(nil, &sub); err != nil {
+			t.Fatal(err)
+		}
+		expect := treeItem{ID: "n3", Name: "Node 3", Children: []treeItem{{ID: "n4", Name: "Node 4"}}}
+		if !reflect.DeepEqual(expect, sub) {
+			t.Errorf("objects are different\n%#v\n%#v", sub, expect)
+		}
+	}
+
+	qw := memstore.New()
+	if _, err := sch.WriteAsQuads(qw, out); err != nil {
+		t.Fatal(err)
+	}
+	var back treeItem
+	if err := sch.LoadToDepth(nil, qw, &back, 1, iri("n1")); err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Children) != 0 {
+		t.Errorf("children should not be loaded without names: %v", back.Children)
+	}
+	var backLazy lazyTreeItem
+	if err := sch.LoadTo(nil, qw, &backLazy, iri("n1")); err != nil {
+		t.Fatal(err)
+	} else if len(backLazy.Children) != 2 {
+		t.Errorf("links to children were not written: %v", backLazy.Children)
+	}
+}
+
+func TestLoadInterface(t *testing.T) {
+	sch := schema.NewConfig()
+	qs := memstore.New(
+		quad.Quad{iri("bird"), iri("ex:name"), quad.String("Tweety"), nil},
+	)
+	zoo := Zoo{ID: "zoo", Animals: []Animal{
+		Cat{ID: "tom", Name: "Tom"},
+		&Dog{ID: "spike", Name: "Spike", Breed: "bulldog"},
+	}}
+	if _, err := sch.WriteAsQuads(qs, zoo); err != nil {
+		t.Fatal(err)
+	}
+	byID := func(arr []Animal) map[quad.IRI]Animal {
+		m := make(map[quad.IRI]Animal)
+		for _, a := range arr {
+			switch a := a.(type) {
+			case Cat:
+				m[a.ID] = a
+			case *Dog:
+				m[a.ID] = a
+			default:
+				t.Fatalf("unexpected type: %T", a)
+			}
+		}
+		return m
+	}
+	expect := byID(zoo.Animals)
+
+	var animals []Animal
+	if err := sch.LoadTo(nil, qs, &animals); err != nil {
+		t.Fatal(err)
+	} else if got := byID(animals); !reflect.DeepEqual(expect, got) {
+		t.Errorf("objects are different\n%#v\n%#v", got, expect)
+	}
+
+	var one Animal
+	if err := sch.LoadTo(nil, qs, &one, iri("spike")); err != nil {
+		t.Fatal(err)
+	} else if one.Sound() != "woof" {
+		t.Errorf("unexpected object: %#v", one)
+	}
+	if err := sch.LoadTo(nil, qs, &one, iri("bird")); !schema.IsNotFound(err) {
+		t.Errorf("expected not found error, got: %v", err)
+	}
+
+	var zoo2 Zoo
+	if err := sch.LoadTo(nil, qs, &zoo2, iri("zoo")); err != nil {
+		t.Fatal(err)
+	} else if got := byID(zoo2.Animals); !reflect.DeepEqual(expect, got) {
+		t.Errorf("objects are different\n%#v\n%#v", got, expect)
+	}
+}
+
 func TestSaveNamespaces(t *testing.T) {
 	sch := schema.NewConfig()
 	save := []voc.Namespace{
@@ -0,0 +1,320 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cdc streams changes applied to a quad store to external message brokers.
+//
+// Each applied delta is published as a separate message. Messages are delivered at least once:
+// the horizon of the last published transaction is stored in the database metadata and publishing
+// resumes from it after a restart, using the delta log to replay missed transactions.
+package cdc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad/pquads"
+	"github.com/cayleygraph/cayley/quad/redact"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// Message is a single message sent to a broker.
+type Message struct {
+	Key     []byte // subject of the quad in N-Quads notation; can be used for partitioning
+	Value   []byte // encoded change
+	Horizon int64  // horizon of the transaction that applied the change
+}
+
+// Sink publishes messages to a message broker.
+type Sink interface {
+	// Publish sends messages in order and returns when all of them are acknowledged by the broker.
+	Publish(ctx context.Context, msgs []Message) error
+	// Close releases resources associated with the sink.
+	Close() error
+}
+
+// NewSinkFunc creates a sink for a broker address and a topic.
+type NewSinkFunc func(addr, topic string, opts graph.Options) (Sink, error)
+
+var (
+	sinksMu sync.RWMutex
+	sinks   = make(map[string]NewSinkFunc)
+)
+
+// RegisterSink registers a sink type.
+func RegisterSink(name string, fnc NewSinkFunc) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	if _, ok := sinks[name]; ok {
+		panic("cdc: sink " + name + " is already registered")
+	}
+	sinks[name] = fnc
+}
+
+// Sinks returns names of all registered sink types.
+func Sinks() []string {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	out := make([]string, 0, len(sinks))
+	for name := range sinks {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// NewSink creates a sink of a given type.
+func NewSink(name, addr, topic string, opts graph.Options) (Sink, error) {
+	sinksMu.RLock()
+	fnc := sinks[name]
+	sinksMu.RUnlock()
+	if fnc == nil {
+		return nil, fmt.Errorf("cdc: unsupported sink: %q", name)
+	}
+	return fnc(addr, topic, opts)
+}
+
+// Format encodes changes as message values.
+type Format func(c graph.Change) ([]byte, error)
+
+// Formats lists supported message formats.
+var Formats = map[string]Format{
+	// "json" encodes changes the same way as the HTTP change feed.
+	"json": func(c graph.Change) ([]byte, error) {
+		return json.Marshal(model.Change{
+			Action:    c.Action.String(),
+			Quad:      model.NewQuad(c.Quad),
+			Horizon:   c.Horizon,
+			Timestamp: c.Timestamp,
+		})
+	},
+	// "proto" encodes changes as LogDelta protobuf messages.
+	"proto": func(c graph.Change) ([]byte, error) {
+		ld := proto.LogDelta{
+			ID:        uint64(c.Horizon),
+			Quad:      pquads.MakeQuad(c.Quad),
+			Action:    int32(c.Action),
+			Timestamp: c.Timestamp.UnixNano(),
+		}
+		return ld.Marshal()
+	},
+}
+
+// Config is a configuration of the Publisher.
+type Config struct {
+	// Name identifies the publisher. It is used as a key for the stored offset,
+	// thus publishers with different names can stream the same store independently.
+	Name string
+	// Format of messages, see Formats. JSON is used if not set.
+	Format string
+	// Redact removes or anonymizes published quads, if set.
+	Redact *redact.Redactor
+}
+
+// offsetKey returns a metadata key for the offset of the publisher.
+func offsetKey(name string) string {
+	return "cdc:" + name
+}
+
+// Publisher streams changes applied to a QuadStore to a Sink.
+type Publisher struct {
+	qs   graph.QuadStore
+	sink Sink
+	name string
+	enc  Format
+	red  *redact.Redactor
+}
+
+// NewPublisher creates a publisher. The sink is not closed by the publisher.
+func NewPublisher(qs graph.QuadStore, sink Sink, conf Config) (*Publisher, error) {
+	if conf.Name == "" {
+		conf.Name = "default"
+	}
+	if conf.Format == "" {
+		conf.Format = "json"
+	}
+	enc := Formats[conf.Format]
+	if enc == nil {
+		return nil, fmt.Errorf("cdc: unsupported format: %q", conf.Format)
+	}
+	return &Publisher{qs: qs, sink: sink, name: conf.Name, enc: enc, red: conf.Redact}, nil
+}
+
+// Offset returns the horizon of the last published transaction. It returns false if no offset is stored.
+func (p *Publisher) Offset(ctx context.Context) (int64, bool, error) {
+	data, err := graph.GetMetadata(ctx, p.qs, offsetKey(p.name))
+	if err != nil || data == nil {
+		return 0, false, err
+	}
+	if len(data) != 8 {
+		return 0, false, fmt.Errorf("cdc: invalid offset record for %q", p.name)
+	}
+	return int64(binary.BigEndian.Uint64(data)), true, nil
+}
+
+func (p *Publisher) setOffset(ctx context.Context, h int64) error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(h))
+	return graph.SetMetadata(ctx, p.qs, offsetKey(p.name), buf[:])
+}
+
+// publish sends changes of a single transaction and stores its horizon as the offset.
+func (p *Publisher) publish(ctx context.Context, changes []graph.Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	msgs := make([]Message, 0, len(changes))
+	for _, c := range changes {
+		var ok bool
+		if c.Quad, ok = p.red.Quad(c.Quad); !ok {
+			continue
+		}
+		val, err := p.enc(c)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, Message{
+			Key:     []byte(model.Value(c.Quad.Subject)),
+			Value:   val,
+			Horizon: c.Horizon,
+		})
+	}
+	// all changes of the transaction may be redacted, but the offset is still moved
+	if len(msgs) != 0 {
+		if err := p.sink.Publish(ctx, msgs); err != nil {
+			return err
+		}
+	}
+	return p.setOffset(ctx, changes[len(changes)-1].Horizon)
+}
+
+// catchUp publishes transactions recorded in the delta log after the stored offset, up to the horizon cur.
+// If no offset is stored, publishing starts from the current horizon.
+func (p *Publisher) catchUp(ctx context.Context, cur int64) (int64, error) {
+	off, ok, err := p.Offset(ctx)
+	if err != nil {
+		return 0, err
+	} else if !ok {
+		return cur, p.setOffset(ctx, cur)
+	}
+	for off < cur {
+		to := off + logPage
+		if to > cur {
+			to = cur
+		}
+		list, err := graph.LogEntries(ctx, p.qs, off, to)
+		if err == graph.ErrNotSupported {
+			return 0, fmt.Errorf("cdc: changes after horizon %d were not published and the delta log is not enabled", off)
+		} else if err != nil {
+			return 0, err
+		}
+		for _, e := range list {
+			changes := make([]graph.Change, 0, len(e.Deltas))
+			for _, d := range e.Deltas {
+				changes = append(changes, graph.Change{Delta: d, Horizon: e.Horizon, Timestamp: e.Timestamp})
+			}
+			if err = p.publish(ctx, changes); err != nil {
+				return 0, err
+			}
+		}
+		off = to
+	}
+	return off, nil
+}
+
+// logPage is the number of transactions read from the delta log at once.
+const logPage = 100
+
+// Run publishes changes until the context is canceled or an error occurs.
+//
+// The store must implement graph.Subscriber, graph.HorizonStore and graph.MetadataStore.
+// Transactions applied while the publisher was not running are replayed from the delta log (see graph.DeltaLog).
+func (p *Publisher) Run(ctx context.Context) error {
+	for {
+		err := p.run(ctx)
+		if err != graph.ErrSlowSubscriber {
+			return err
+		}
+		clog.Warningf("cdc: %s: publisher fell behind, resuming from the delta log", p.name)
+	}
+}
+
+func (p *Publisher) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// subscribe before reading the horizon, so no changes are missed
+	sub, err := graph.Subscribe(ctx, p.qs)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+	cur, err := graph.Horizon(ctx, p.qs)
+	if err != nil {
+		return err
+	}
+	// changes up to this horizon were already published from the delta log
+	start, err := p.catchUp(ctx, cur)
+	if err != nil {
+		return err
+	}
+	var batch []graph.Change
+	for {
+		var (
+			c  graph.Change
+			ok bool
+		)
+		if len(batch) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case c, ok = <-sub.C:
+			}
+		} else {
+			// flush the transaction if no other changes are pending
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case c, ok = <-sub.C:
+			default:
+				if err = p.publish(ctx, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+				continue
+			}
+		}
+		if !ok {
+			if err = sub.Err(); err == nil {
+				err = ctx.Err()
+			}
+			return err
+		}
+		if c.Horizon <= start {
+			continue
+		}
+		if len(batch) != 0 && batch[0].Horizon != c.Horizon {
+			if err = p.publish(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		batch = append(batch, c)
+	}
+}
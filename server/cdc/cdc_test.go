@@ -0,0 +1,151 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	_ "github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/redact"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+type memSink struct {
+	mu   sync.Mutex
+	msgs []Message
+}
+
+func (s *memSink) Publish(ctx context.Context, msgs []Message) error {
+	s.mu.Lock()
+	s.msgs = append(s.msgs, msgs...)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memSink) Close() error { return nil }
+
+func (s *memSink) wait(t *testing.T, n int) []Message {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		msgs := append([]Message{}, s.msgs...)
+		s.mu.Unlock()
+		if len(msgs) >= n || time.Now().After(deadline) {
+			return msgs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func decodeChange(t *testing.T, m Message) model.Change {
+	var c model.Change
+	require.NoError(t, json.Unmarshal(m.Value, &c))
+	return c
+}
+
+func TestPublisher(t *testing.T) {
+	qs, err := graph.NewQuadStore("btree", "", graph.Options{"delta_log": true})
+	require.NoError(t, err)
+	defer qs.Close()
+
+	add := func(q quad.Quad) {
+		require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: q, Action: graph.Add}}, graph.IgnoreOpts{}))
+	}
+	// changes applied before the first start are not published
+	add(quad.MakeIRI("a", "b", "c", ""))
+
+	sink := &memSink{}
+	p, err := NewPublisher(qs, sink, Config{})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, ok, err := p.Offset(ctx)
+		require.NoError(t, err)
+		if ok {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "publisher did not start")
+		time.Sleep(10 * time.Millisecond)
+	}
+	add(quad.MakeIRI("a", "b", "d", ""))
+	msgs := sink.wait(t, 1)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "<a>", string(msgs[0].Key))
+	c := decodeChange(t, msgs[0])
+	require.Equal(t, "add", c.Action)
+	require.Equal(t, "<d>", c.Quad.Object)
+
+	cancel()
+	require.Equal(t, context.Canceled, <-done)
+
+	// changes applied while the publisher is stopped are replayed from the delta log
+	add(quad.MakeIRI("a", "b", "e", ""))
+	p, err = NewPublisher(qs, sink, Config{Format: "proto"})
+	require.NoError(t, err)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	msgs = sink.wait(t, 2)
+	require.Len(t, msgs, 2)
+	var ld proto.LogDelta
+	require.NoError(t, ld.Unmarshal(msgs[1].Value))
+	require.Equal(t, quad.MakeIRI("a", "b", "e", ""), ld.Quad.ToNative())
+	require.Equal(t, uint64(msgs[1].Horizon), ld.ID)
+}
+
+func TestPublisherNoLog(t *testing.T) {
+	qs, err := graph.NewQuadStore("btree", "", nil)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	sink := &memSink{}
+	p, err := NewPublisher(qs, sink, Config{Name: "test"})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, p.setOffset(ctx, 0))
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: quad.MakeIRI("a", "b", "c", ""), Action: graph.Add}}, graph.IgnoreOpts{}))
+
+	err = p.Run(ctx)
+	require.Error(t, err)
+	require.Empty(t, sink.msgs)
+}
+
+func TestPublisherRedact(t *testing.T) {
+	qs, err := graph.NewQuadStore("btree", "", graph.Options{"delta_log": true})
+	require.NoError(t, err)
+	defer qs.Close()
+
+	sink := &memSink{}
+	p, err := NewPublisher(qs, sink, Config{
+		Redact: redact.New(redact.Rules{Deny: []quad.Value{quad.IRI("password")}}),
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, p.setOffset(ctx, 0))
+	for _, q := range []quad.Quad{
+		quad.Make(quad.IRI("a"), quad.IRI("password"), "123", nil),
+		quad.MakeIRI("a", "b", "c", ""),
+	} {
+		require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: q, Action: graph.Add}}, graph.IgnoreOpts{}))
+	}
+	cur, err := p.catchUp(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), cur)
+	require.Len(t, sink.msgs, 1)
+	require.Equal(t, "<c>", decodeChange(t, sink.msgs[0]).Quad.Object)
+	off, _, err := p.Offset(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), off)
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafka implements a CDC sink that publishes changes to a Kafka topic.
+package kafka
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/cdc"
+)
+
+// Type is the name of the sink.
+const Type = "kafka"
+
+func init() {
+	cdc.RegisterSink(Type, New)
+}
+
+// Sink publishes messages to a Kafka topic. Messages are keyed by the quad subject,
+// thus changes of the same node are delivered in order.
+type Sink struct {
+	p     sarama.SyncProducer
+	topic string
+}
+
+// New connects to Kafka brokers listed in addr separated by commas.
+//
+// The only supported option is "client_id".
+func New(addr, topic string, opts graph.Options) (cdc.Sink, error) {
+	conf := sarama.NewConfig()
+	id, err := opts.StringKey("client_id", "cayley")
+	if err != nil {
+		return nil, err
+	}
+	conf.ClientID = id
+	// wait for all in-sync replicas to acknowledge a message
+	conf.Producer.RequiredAcks = sarama.WaitForAll
+	conf.Producer.Return.Successes = true
+	p, err := sarama.NewSyncProducer(strings.Split(addr, ","), conf)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{p: p, topic: topic}, nil
+}
+
+// Publish implements cdc.Sink.
+func (s *Sink) Publish(ctx context.Context, msgs []cdc.Message) error {
+	out := make([]*sarama.ProducerMessage, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, &sarama.ProducerMessage{
+			Topic: s.topic,
+			Key:   sarama.ByteEncoder(m.Key),
+			Value: sarama.ByteEncoder(m.Value),
+		})
+	}
+	return s.p.SendMessages(out)
+}
+
+// Close implements cdc.Sink.
+func (s *Sink) Close() error {
+	return s.p.Close()
+}
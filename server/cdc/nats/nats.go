@@ -0,0 +1,81 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nats implements a CDC sink that publishes changes to a NATS subject.
+package nats
+
+import (
+	"context"
+	"time"
+
+	gonats "github.com/nats-io/go-nats"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/cdc"
+)
+
+// Type is the name of the sink.
+const Type = "nats"
+
+// DefaultFlushTimeout is the default time to wait for the server to confirm published messages.
+const DefaultFlushTimeout = 5 * time.Second
+
+func init() {
+	cdc.RegisterSink(Type, New)
+}
+
+// Sink publishes messages to a NATS subject.
+//
+// Publish returns after the server has received all messages. Core NATS does not persist messages,
+// thus they are only delivered to consumers that are connected at the time of publishing.
+type Sink struct {
+	nc      *gonats.Conn
+	subject string
+	timeout time.Duration
+}
+
+// New connects to NATS servers listed in addr separated by commas.
+//
+// The only supported option is "flush_timeout".
+func New(addr, subject string, opts graph.Options) (cdc.Sink, error) {
+	timeout, err := opts.DurationKey("flush_timeout", DefaultFlushTimeout)
+	if err != nil {
+		return nil, err
+	}
+	nc, err := gonats.Connect(addr, gonats.Name("cayley"))
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{nc: nc, subject: subject, timeout: timeout}, nil
+}
+
+// Publish implements cdc.Sink.
+func (s *Sink) Publish(ctx context.Context, msgs []cdc.Message) error {
+	for _, m := range msgs {
+		if err := s.nc.Publish(s.subject, m.Value); err != nil {
+			return err
+		}
+	}
+	timeout := s.timeout
+	if dl, ok := ctx.Deadline(); ok && time.Until(dl) < timeout {
+		timeout = time.Until(dl)
+	}
+	return s.nc.FlushTimeout(timeout)
+}
+
+// Close implements cdc.Sink.
+func (s *Sink) Close() error {
+	s.nc.Close()
+	return nil
+}
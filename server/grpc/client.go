@@ -0,0 +1,175 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleygrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/cayleygraph/cayley/server/grpc/pb"
+)
+
+// Client is a client of the Cayley gRPC service.
+type Client struct {
+	cc *grpc.ClientConn
+}
+
+// NewClient creates a client for the service using an existing connection.
+func NewClient(cc *grpc.ClientConn) *Client {
+	return &Client{cc: cc}
+}
+
+func (c *Client) newStream(ctx context.Context, i int, opts []grpc.CallOption) (grpc.ClientStream, error) {
+	desc := &serviceDesc.Streams[i]
+	opts = append([]grpc.CallOption{grpc.CallCustomCodec(codec{})}, opts...)
+	return c.cc.NewStream(ctx, desc, "/"+pb.ServiceName+"/"+desc.StreamName, opts...)
+}
+
+// sendRequest sends a single request message and closes the sending side of the stream.
+func sendRequest(s grpc.ClientStream, req interface{}) error {
+	if err := s.SendMsg(req); err != nil {
+		return err
+	}
+	return s.CloseSend()
+}
+
+// QueryResults receives query results from the server.
+type QueryResults interface {
+	// Recv returns the next result, or io.EOF if there are no more results.
+	Recv() (*pb.QueryResult, error)
+	grpc.ClientStream
+}
+
+type queryResults struct{ grpc.ClientStream }
+
+func (s queryResults) Recv() (*pb.QueryResult, error) {
+	m := new(pb.QueryResult)
+	if err := s.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Query runs a query and returns a stream of its results.
+func (c *Client) Query(ctx context.Context, req *pb.QueryRequest, opts ...grpc.CallOption) (QueryResults, error) {
+	s, err := c.newStream(ctx, 0, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = sendRequest(s, req); err != nil {
+		return nil, err
+	}
+	return queryResults{s}, nil
+}
+
+// QuadsSender sends batches of quads to the server.
+type QuadsSender interface {
+	Send(*pb.QuadBatch) error
+	// CloseAndRecv finishes sending quads and waits for the server to apply them.
+	CloseAndRecv() (*pb.WriteReply, error)
+	grpc.ClientStream
+}
+
+type quadsSender struct{ grpc.ClientStream }
+
+func (s quadsSender) Send(m *pb.QuadBatch) error { return s.SendMsg(m) }
+
+func (s quadsSender) CloseAndRecv() (*pb.WriteReply, error) {
+	if err := s.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(pb.WriteReply)
+	if err := s.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Write opens a stream that adds quads to the database.
+func (c *Client) Write(ctx context.Context, opts ...grpc.CallOption) (QuadsSender, error) {
+	s, err := c.newStream(ctx, 1, opts)
+	if err != nil {
+		return nil, err
+	}
+	return quadsSender{s}, nil
+}
+
+// Delete opens a stream that removes quads from the database.
+func (c *Client) Delete(ctx context.Context, opts ...grpc.CallOption) (QuadsSender, error) {
+	s, err := c.newStream(ctx, 2, opts)
+	if err != nil {
+		return nil, err
+	}
+	return quadsSender{s}, nil
+}
+
+// QuadBatches receives batches of quads from the server.
+type QuadBatches interface {
+	// Recv returns the next batch, or io.EOF if there are no more quads.
+	Recv() (*pb.QuadBatch, error)
+	grpc.ClientStream
+}
+
+type quadBatches struct{ grpc.ClientStream }
+
+func (s quadBatches) Recv() (*pb.QuadBatch, error) {
+	m := new(pb.QuadBatch)
+	if err := s.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// QuadsOf returns a stream of quads matching a pattern.
+func (c *Client) QuadsOf(ctx context.Context, req *pb.QuadsRequest, opts ...grpc.CallOption) (QuadBatches, error) {
+	s, err := c.newStream(ctx, 3, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = sendRequest(s, req); err != nil {
+		return nil, err
+	}
+	return quadBatches{s}, nil
+}
+
+// NodeBatches receives batches of nodes from the server.
+type NodeBatches interface {
+	// Recv returns the next batch, or io.EOF if there are no more nodes.
+	Recv() (*pb.NodeBatch, error)
+	grpc.ClientStream
+}
+
+type nodeBatches struct{ grpc.ClientStream }
+
+func (s nodeBatches) Recv() (*pb.NodeBatch, error) {
+	m := new(pb.NodeBatch)
+	if err := s.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NodesOf returns a stream of nodes in a given direction of quads matching a pattern.
+func (c *Client) NodesOf(ctx context.Context, req *pb.NodesRequest, opts ...grpc.CallOption) (NodeBatches, error) {
+	s, err := c.newStream(ctx, 4, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = sendRequest(s, req); err != nil {
+		return nil, err
+	}
+	return nodeBatches{s}, nil
+}
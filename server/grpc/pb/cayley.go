@@ -0,0 +1,125 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pb defines messages of the gRPC API described in cayley.proto.
+//
+// Messages are encoded by gogo/protobuf using struct tags, thus no code generation is required.
+// Values and quads reuse types from the pquads package.
+package pb
+
+import (
+	proto "github.com/gogo/protobuf/proto"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+// ServiceName is a full name of the gRPC service.
+const ServiceName = "cayley.Cayley"
+
+type QueryRequest struct {
+	Lang  string `protobuf:"bytes,1,opt,name=lang,proto3" json:"lang,omitempty"`
+	Query string `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
+	Limit int64  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (m *QueryRequest) Reset()         { *m = QueryRequest{} }
+func (m *QueryRequest) String() string { return proto.CompactTextString(m) }
+func (*QueryRequest) ProtoMessage()    {}
+
+// QueryResult is a single result of a query. Only one of the fields is set.
+type QueryResult struct {
+	Value *pquads.Value            `protobuf:"bytes,1,opt,name=value" json:"value,omitempty"`
+	Tags  map[string]*pquads.Value `protobuf:"bytes,2,rep,name=tags" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value"`
+	JSON  string                   `protobuf:"bytes,3,opt,name=json,proto3" json:"json,omitempty"`
+}
+
+func (m *QueryResult) Reset()         { *m = QueryResult{} }
+func (m *QueryResult) String() string { return proto.CompactTextString(m) }
+func (*QueryResult) ProtoMessage()    {}
+
+type QuadBatch struct {
+	Quads []*pquads.WireQuad `protobuf:"bytes,1,rep,name=quads" json:"quads,omitempty"`
+}
+
+func (m *QuadBatch) Reset()         { *m = QuadBatch{} }
+func (m *QuadBatch) String() string { return proto.CompactTextString(m) }
+func (*QuadBatch) ProtoMessage()    {}
+
+// MakeQuadBatch converts quads to their protobuf representation.
+func MakeQuadBatch(quads []quad.Quad) *QuadBatch {
+	b := &QuadBatch{Quads: make([]*pquads.WireQuad, 0, len(quads))}
+	for _, q := range quads {
+		b.Quads = append(b.Quads, pquads.MakeWireQuad(q))
+	}
+	return b
+}
+
+// ToNative converts quads of the batch to quad.Quad.
+func (m *QuadBatch) ToNative() []quad.Quad {
+	quads := make([]quad.Quad, 0, len(m.Quads))
+	for _, q := range m.Quads {
+		quads = append(quads, q.ToNative())
+	}
+	return quads
+}
+
+type WriteReply struct {
+	Count int64 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+}
+
+func (m *WriteReply) Reset()         { *m = WriteReply{} }
+func (m *WriteReply) String() string { return proto.CompactTextString(m) }
+func (*WriteReply) ProtoMessage()    {}
+
+// Direction is a direction of a quad. Values match quad.Direction.
+type Direction int32
+
+const (
+	Direction_ANY       = Direction(quad.Any)
+	Direction_SUBJECT   = Direction(quad.Subject)
+	Direction_PREDICATE = Direction(quad.Predicate)
+	Direction_OBJECT    = Direction(quad.Object)
+	Direction_LABEL     = Direction(quad.Label)
+)
+
+// QuadsRequest selects quads by their values. Directions that are not set in the pattern match any value.
+type QuadsRequest struct {
+	Pattern *pquads.WireQuad `protobuf:"bytes,1,opt,name=pattern" json:"pattern,omitempty"`
+	Limit   int64            `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (m *QuadsRequest) Reset()         { *m = QuadsRequest{} }
+func (m *QuadsRequest) String() string { return proto.CompactTextString(m) }
+func (*QuadsRequest) ProtoMessage()    {}
+
+// NodesRequest selects nodes in a given direction of quads matching a pattern.
+// If the pattern and the direction are not set, all nodes are returned.
+type NodesRequest struct {
+	Pattern   *pquads.WireQuad `protobuf:"bytes,1,opt,name=pattern" json:"pattern,omitempty"`
+	Direction Direction        `protobuf:"varint,2,opt,name=direction,proto3,casttype=Direction" json:"direction,omitempty"`
+	Limit     int64            `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (m *NodesRequest) Reset()         { *m = NodesRequest{} }
+func (m *NodesRequest) String() string { return proto.CompactTextString(m) }
+func (*NodesRequest) ProtoMessage()    {}
+
+type NodeBatch struct {
+	Nodes []*pquads.Value `protobuf:"bytes,1,rep,name=nodes" json:"nodes,omitempty"`
+}
+
+func (m *NodeBatch) Reset()         { *m = NodeBatch{} }
+func (m *NodeBatch) String() string { return proto.CompactTextString(m) }
+func (*NodeBatch) ProtoMessage()    {}
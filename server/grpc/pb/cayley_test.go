@@ -0,0 +1,44 @@
+package pb
+
+import (
+	"testing"
+
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+func TestMarshal(t *testing.T) {
+	quads := []quad.Quad{
+		quad.MakeIRI("a", "b", "c", ""),
+		{Subject: quad.BNode("x"), Predicate: quad.IRI("age"), Object: quad.Int(42), Label: quad.IRI("g")},
+	}
+	for _, c := range []struct {
+		in, out proto.Message
+	}{
+		{in: &QueryRequest{Lang: "gizmo", Query: "g.V().All()", Limit: 10}, out: &QueryRequest{}},
+		{in: &QueryResult{Value: pquads.MakeValue(quad.String("s"))}, out: &QueryResult{}},
+		{in: &QueryResult{Tags: map[string]*pquads.Value{
+			"id": pquads.MakeValue(quad.IRI("a")),
+			"n":  pquads.MakeValue(quad.Float(1.5)),
+		}}, out: &QueryResult{}},
+		{in: &QueryResult{JSON: `{"a":1}`}, out: &QueryResult{}},
+		{in: MakeQuadBatch(quads), out: &QuadBatch{}},
+		{in: &WriteReply{Count: 3}, out: &WriteReply{}},
+		{in: &QuadsRequest{Pattern: pquads.MakeWireQuad(quad.Quad{Predicate: quad.IRI("b")}), Limit: 5}, out: &QuadsRequest{}},
+		{in: &NodesRequest{Direction: Direction_OBJECT, Limit: 1}, out: &NodesRequest{}},
+		{in: &NodeBatch{Nodes: []*pquads.Value{pquads.MakeValue(quad.Bool(true))}}, out: &NodeBatch{}},
+	} {
+		data, err := proto.Marshal(c.in)
+		require.NoError(t, err)
+		require.NoError(t, proto.Unmarshal(data, c.out))
+		require.Equal(t, c.in, c.out)
+	}
+	var b QuadBatch
+	data, err := proto.Marshal(MakeQuadBatch(quads))
+	require.NoError(t, err)
+	require.NoError(t, proto.Unmarshal(data, &b))
+	require.Equal(t, quads, b.ToNative())
+}
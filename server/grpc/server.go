@@ -0,0 +1,368 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cayleygrpc implements a gRPC API for Cayley.
+//
+// The service is described in pb/cayley.proto. Quads and values are sent as protobuf messages
+// of the pquads package, thus clients receive typed values and avoid the overhead of JSON.
+package cayleygrpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/server/grpc/pb"
+)
+
+// DefaultBatch is the default number of quads or nodes sent in a single message.
+const DefaultBatch = 100
+
+// Config is a configuration of the gRPC service.
+type Config struct {
+	ReadOnly bool          // reject writes and deletes
+	Timeout  time.Duration // maximal duration of a single call; zero means no timeout
+	Limits   query.Limits  // resource limits of a single query
+	Limit    int           // number of query results returned if the client does not set the limit; zero means no limit
+	Batch    int           // maximal number of quads or nodes in a single message; DefaultBatch is used if not set
+}
+
+// Service implements the Cayley gRPC service on top of a quad store.
+type Service struct {
+	h    *graph.Handle
+	conf Config
+}
+
+var _ CayleyServer = (*Service)(nil)
+
+// NewService creates a service for a given database handle.
+func NewService(h *graph.Handle, conf Config) *Service {
+	if conf.Batch <= 0 {
+		conf.Batch = DefaultBatch
+	}
+	return &Service{h: h, conf: conf}
+}
+
+// NewServer creates a gRPC server with the Cayley service registered.
+func NewServer(h *graph.Handle, conf Config, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(append(ServerOptions(), opts...)...)
+	RegisterCayleyServer(s, NewService(h, conf))
+	return s
+}
+
+func (s *Service) context(ctx context.Context) (context.Context, func()) {
+	if s.conf.Timeout > 0 {
+		return context.WithTimeout(ctx, s.conf.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// errorStatus converts an error to a gRPC status error.
+func errorStatus(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case graph.IsQuadExist(err):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case graph.IsQuadNotExist(err):
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if _, ok := err.(*query.LimitError); ok {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+// Query implements CayleyServer.
+func (s *Service) Query(req *pb.QueryRequest, stream QueryStream) error {
+	l := query.GetLanguage(req.Lang)
+	if l == nil {
+		return status.Errorf(codes.InvalidArgument, "unknown query language: %q", req.Lang)
+	} else if l.HTTP == nil {
+		return status.Errorf(codes.Unimplemented, "query language %q cannot be used over gRPC", req.Lang)
+	}
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = s.conf.Limit
+	}
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	ctx, cancel := s.context(stream.Context())
+	defer cancel()
+	ctx, qs, budget := query.WithLimits(ctx, s.h.QuadStore, s.conf.Limits)
+
+	ses := l.HTTP(qs)
+	c := make(chan query.Result, 5)
+	go ses.Execute(ctx, req.Query, c, budget.ResultLimit(limit))
+	defer func() {
+		// let the session exit
+		cancel()
+		for range c {
+		}
+	}()
+	sses, _ := ses.(query.StreamingHTTP)
+	for res := range c {
+		err := res.Err()
+		if err == nil {
+			err = budget.AddResult()
+		}
+		if err != nil {
+			if lerr := budget.Err(); lerr != nil {
+				err = lerr
+			}
+			return errorStatus(err)
+		}
+		if sses == nil {
+			// session cannot convert results separately; they are sent at the end
+			ses.Collate(res)
+			continue
+		}
+		row, ok := sses.Row(res)
+		if !ok {
+			continue
+		}
+		m, err := makeResult(qs, res.Result(), row)
+		if err != nil {
+			return errorStatus(err)
+		}
+		if err = stream.Send(m); err != nil {
+			return err
+		}
+	}
+	if err := budget.Err(); err != nil {
+		return errorStatus(err)
+	} else if err = ctx.Err(); err != nil {
+		return errorStatus(err)
+	}
+	if sses != nil {
+		return nil
+	}
+	out, err := ses.Results()
+	if err != nil {
+		return errorStatus(err)
+	}
+	rows, ok := out.([]interface{})
+	if !ok {
+		rows = []interface{}{out}
+	}
+	for _, row := range rows {
+		m, err := makeResult(qs, nil, row)
+		if err != nil {
+			return errorStatus(err)
+		}
+		if err = stream.Send(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// makeResult converts a query result to a message. Tags and values are sent as typed values,
+// while other results are sent as a JSON of the row.
+func makeResult(qs graph.QuadStore, v interface{}, row interface{}) (*pb.QueryResult, error) {
+	switch v := v.(type) {
+	case map[string]graph.Value:
+		tags := make(map[string]*pquads.Value, len(v))
+		for k, gv := range v {
+			if name := qs.NameOf(gv); name != nil {
+				tags[k] = pquads.MakeValue(name)
+			}
+		}
+		return &pb.QueryResult{Tags: tags}, nil
+	case quad.Value:
+		return &pb.QueryResult{Value: pquads.MakeValue(v)}, nil
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.QueryResult{JSON: string(data)}, nil
+}
+
+// Write implements CayleyServer.
+func (s *Service) Write(stream WriteStream) error {
+	return s.write(stream, s.h.QuadWriter.AddQuadSet)
+}
+
+// Delete implements CayleyServer.
+func (s *Service) Delete(stream WriteStream) error {
+	rw := graph.NewRemover(s.h.QuadWriter)
+	return s.write(stream, func(quads []quad.Quad) error {
+		_, err := rw.WriteQuads(quads)
+		return err
+	})
+}
+
+// write applies each received batch of quads with a given function. Batches are not rolled back on errors.
+func (s *Service) write(stream WriteStream, apply func([]quad.Quad) error) error {
+	if s.conf.ReadOnly {
+		return status.Error(codes.PermissionDenied, "database is read-only")
+	}
+	var cnt int64
+	for {
+		b, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		quads := b.ToNative()
+		for _, q := range quads {
+			if !q.IsValid() {
+				return status.Errorf(codes.InvalidArgument, "invalid quad: %v", q)
+			}
+		}
+		if len(quads) == 0 {
+			continue
+		}
+		if err = apply(quads); err != nil {
+			return errorStatus(err)
+		}
+		cnt += int64(len(quads))
+	}
+	return stream.SendAndClose(&pb.WriteReply{Count: cnt})
+}
+
+// iterateLimit converts a limit of a request to a limit of graph.Iterate.
+func iterateLimit(n int64) int {
+	if n <= 0 {
+		return -1
+	}
+	return int(n)
+}
+
+// patternShape returns a shape that selects quads matching a pattern, or nil if the pattern is not set.
+func patternShape(p *pquads.WireQuad) shape.Shape {
+	if p == nil {
+		return nil
+	}
+	q := p.ToNative()
+	var s shape.Quads
+	for _, d := range quad.Directions {
+		if v := q.Get(d); v != nil {
+			s = append(s, shape.QuadFilter{Dir: d, Values: shape.Lookup{v}})
+		}
+	}
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// QuadsOf implements CayleyServer.
+func (s *Service) QuadsOf(req *pb.QuadsRequest, stream QuadsStream) error {
+	ctx, cancel := s.context(stream.Context())
+	defer cancel()
+	qs := s.h.QuadStore
+	var it graph.Iterator
+	if p := patternShape(req.Pattern); p != nil {
+		it = shape.BuildIterator(qs, p)
+	} else {
+		it = qs.QuadsAllIterator()
+	}
+	defer it.Close()
+
+	b := &pb.QuadBatch{}
+	var serr error
+	err := graph.Iterate(ctx, it).On(qs).Paths(false).Limit(iterateLimit(req.Limit)).Each(func(v graph.Value) {
+		if serr != nil {
+			return
+		}
+		b.Quads = append(b.Quads, pquads.MakeWireQuad(qs.Quad(v)))
+		if len(b.Quads) >= s.conf.Batch {
+			if serr = stream.Send(b); serr != nil {
+				cancel()
+			}
+			b = &pb.QuadBatch{}
+		}
+	})
+	if serr != nil {
+		return serr
+	} else if err != nil {
+		return errorStatus(err)
+	}
+	if len(b.Quads) != 0 {
+		return stream.Send(b)
+	}
+	return nil
+}
+
+// NodesOf implements CayleyServer.
+func (s *Service) NodesOf(req *pb.NodesRequest, stream NodesStream) error {
+	ctx, cancel := s.context(stream.Context())
+	defer cancel()
+	qs := s.h.QuadStore
+	d := quad.Direction(req.Direction)
+	p := patternShape(req.Pattern)
+	var sh shape.Shape
+	switch {
+	case p == nil && d == quad.Any:
+		sh = shape.AllNodes{}
+	case d == quad.Any:
+		return status.Error(codes.InvalidArgument, "direction must be set for a pattern")
+	case d < quad.Subject || d > quad.Label:
+		return status.Errorf(codes.InvalidArgument, "invalid direction: %d", req.Direction)
+	default:
+		if p == nil {
+			p = shape.Quads{}
+		}
+		sh = shape.Unique{From: shape.NodesFrom{Dir: d, Quads: p}}
+	}
+	it := shape.BuildIterator(qs, sh)
+	defer it.Close()
+
+	b := &pb.NodeBatch{}
+	var serr error
+	err := graph.Iterate(ctx, it).On(qs).Paths(false).Limit(iterateLimit(req.Limit)).EachValue(nil, func(v quad.Value) {
+		if serr != nil {
+			return
+		}
+		b.Nodes = append(b.Nodes, pquads.MakeValue(v))
+		if len(b.Nodes) >= s.conf.Batch {
+			if serr = stream.Send(b); serr != nil {
+				cancel()
+			}
+			b = &pb.NodeBatch{}
+		}
+	})
+	if serr != nil {
+		return serr
+	} else if err != nil {
+		return errorStatus(err)
+	}
+	if len(b.Nodes) != 0 {
+		return stream.Send(b)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package cayleygrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+	"github.com/cayleygraph/cayley/server/grpc/pb"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func newTestClient(t *testing.T, conf Config, quads ...quad.Quad) (*Client, func()) {
+	qs := memstore.New(quads...)
+	qw, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+	h := &graph.Handle{QuadStore: qs, QuadWriter: qw}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	srv := NewServer(h, conf)
+	go srv.Serve(lis)
+
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	return NewClient(cc), func() {
+		cc.Close()
+		srv.Stop()
+	}
+}
+
+var testQuads = []quad.Quad{
+	quad.MakeIRI("alice", "follows", "bob", ""),
+	quad.MakeIRI("bob", "follows", "fred", ""),
+	quad.MakeIRI("fred", "follows", "alice", ""),
+	quad.Make(quad.IRI("alice"), quad.IRI("age"), quad.Int(30), nil),
+}
+
+func TestQuery(t *testing.T) {
+	c, closer := newTestClient(t, Config{}, testQuads...)
+	defer closer()
+	ctx := context.Background()
+
+	run := func(qu string, limit int64) []*pb.QueryResult {
+		s, err := c.Query(ctx, &pb.QueryRequest{Lang: "gizmo", Query: qu, Limit: limit})
+		require.NoError(t, err)
+		var out []*pb.QueryResult
+		for {
+			r, err := s.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			out = append(out, r)
+		}
+		return out
+	}
+
+	res := run(`g.V("<alice>").Out("<age>").All()`, 0)
+	require.Len(t, res, 1)
+	require.Equal(t, quad.Int(30), res[0].Tags["id"].ToNative())
+
+	res = run(`g.V().Has("<follows>").All()`, 2)
+	require.Len(t, res, 2)
+
+	res = run(`g.Emit({"a": 1})`, 0)
+	require.Len(t, res, 1)
+	require.JSONEq(t, `{"a": 1}`, res[0].JSON)
+
+	s, err := c.Query(ctx, &pb.QueryRequest{Lang: "unknown", Query: "x"})
+	require.NoError(t, err)
+	_, err = s.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWriteAndRead(t *testing.T) {
+	c, closer := newTestClient(t, Config{Batch: 2})
+	defer closer()
+	ctx := context.Background()
+
+	w, err := c.Write(ctx)
+	require.NoError(t, err)
+	require.NoError(t, w.Send(pb.MakeQuadBatch(testQuads[:2])))
+	require.NoError(t, w.Send(pb.MakeQuadBatch(testQuads[2:])))
+	rep, err := w.CloseAndRecv()
+	require.NoError(t, err)
+	require.Equal(t, int64(len(testQuads)), rep.Count)
+
+	quadsOf := func(p quad.Quad) []quad.Quad {
+		s, err := c.QuadsOf(ctx, &pb.QuadsRequest{Pattern: pquads.MakeWireQuad(p)})
+		require.NoError(t, err)
+		var out []quad.Quad
+		for {
+			b, err := s.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.True(t, len(b.Quads) <= 2)
+			out = append(out, b.ToNative()...)
+		}
+		return out
+	}
+	require.Len(t, quadsOf(quad.Quad{}), len(testQuads))
+	require.Equal(t, []quad.Quad{testQuads[3]}, quadsOf(quad.Quad{Predicate: quad.IRI("age")}))
+
+	nodesOf := func(req *pb.NodesRequest) []string {
+		s, err := c.NodesOf(ctx, req)
+		require.NoError(t, err)
+		var out []string
+		for {
+			b, err := s.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			for _, v := range b.Nodes {
+				out = append(out, v.ToNative().String())
+			}
+		}
+		sort.Strings(out)
+		return out
+	}
+	require.Equal(t, []string{"<alice>", "<bob>", "<fred>"}, nodesOf(&pb.NodesRequest{
+		Pattern:   pquads.MakeWireQuad(quad.Quad{Predicate: quad.IRI("follows")}),
+		Direction: pb.Direction_SUBJECT,
+	}))
+	require.Len(t, nodesOf(&pb.NodesRequest{}), 6)
+
+	d, err := c.Delete(ctx)
+	require.NoError(t, err)
+	require.NoError(t, d.Send(pb.MakeQuadBatch(testQuads[3:])))
+	rep, err = d.CloseAndRecv()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rep.Count)
+	require.Empty(t, quadsOf(quad.Quad{Predicate: quad.IRI("age")}))
+}
+
+func TestReadOnly(t *testing.T) {
+	c, closer := newTestClient(t, Config{ReadOnly: true})
+	defer closer()
+
+	w, err := c.Write(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, w.Send(pb.MakeQuadBatch(testQuads)))
+	_, err = w.CloseAndRecv()
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,177 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleygrpc
+
+import (
+	"github.com/gogo/protobuf/proto"
+	"google.golang.org/grpc"
+
+	"github.com/cayleygraph/cayley/server/grpc/pb"
+)
+
+// codec encodes messages with gogo/protobuf. Messages of the API are not generated by protoc,
+// thus the default codec of gRPC cannot encode them.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	return proto.Marshal(v.(proto.Message))
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (codec) Name() string   { return "proto" }
+func (codec) String() string { return "proto" }
+
+// CayleyServer is the server API of the Cayley service.
+type CayleyServer interface {
+	// Query runs a query and streams its results.
+	Query(*pb.QueryRequest, QueryStream) error
+	// Write adds streamed quads to the database.
+	Write(WriteStream) error
+	// Delete removes streamed quads from the database.
+	Delete(WriteStream) error
+	// QuadsOf streams quads matching a pattern.
+	QuadsOf(*pb.QuadsRequest, QuadsStream) error
+	// NodesOf streams nodes in a given direction of quads matching a pattern.
+	NodesOf(*pb.NodesRequest, NodesStream) error
+}
+
+// QueryStream sends query results to the client.
+type QueryStream interface {
+	Send(*pb.QueryResult) error
+	grpc.ServerStream
+}
+
+// WriteStream receives quads from the client.
+type WriteStream interface {
+	SendAndClose(*pb.WriteReply) error
+	Recv() (*pb.QuadBatch, error)
+	grpc.ServerStream
+}
+
+// QuadsStream sends batches of quads to the client.
+type QuadsStream interface {
+	Send(*pb.QuadBatch) error
+	grpc.ServerStream
+}
+
+// NodesStream sends batches of nodes to the client.
+type NodesStream interface {
+	Send(*pb.NodeBatch) error
+	grpc.ServerStream
+}
+
+// RegisterCayleyServer registers the service implementation on the gRPC server.
+// The server must be created with ServerOptions.
+func RegisterCayleyServer(s *grpc.Server, srv CayleyServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+// ServerOptions returns options required by gRPC servers that serve the Cayley service.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{grpc.CustomCodec(codec{})}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: pb.ServiceName,
+	HandlerType: (*CayleyServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Query",
+			Handler:       queryHandler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Write",
+			Handler:       writeHandler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Delete",
+			Handler:       deleteHandler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "QuadsOf",
+			Handler:       quadsOfHandler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "NodesOf",
+			Handler:       nodesOfHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "cayley.proto",
+}
+
+type queryServer struct{ grpc.ServerStream }
+
+func (s queryServer) Send(m *pb.QueryResult) error { return s.SendMsg(m) }
+
+type writeServer struct{ grpc.ServerStream }
+
+func (s writeServer) SendAndClose(m *pb.WriteReply) error { return s.SendMsg(m) }
+
+func (s writeServer) Recv() (*pb.QuadBatch, error) {
+	m := new(pb.QuadBatch)
+	if err := s.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type quadsOfServer struct{ grpc.ServerStream }
+
+func (s quadsOfServer) Send(m *pb.QuadBatch) error { return s.SendMsg(m) }
+
+type nodesOfServer struct{ grpc.ServerStream }
+
+func (s nodesOfServer) Send(m *pb.NodeBatch) error { return s.SendMsg(m) }
+
+func queryHandler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(pb.QueryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CayleyServer).Query(m, queryServer{stream})
+}
+
+func writeHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CayleyServer).Write(writeServer{stream})
+}
+
+func deleteHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CayleyServer).Delete(writeServer{stream})
+}
+
+func quadsOfHandler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(pb.QuadsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CayleyServer).QuadsOf(m, quadsOfServer{stream})
+}
+
+func nodesOfHandler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(pb.NodesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CayleyServer).NodesOf(m, nodesOfServer{stream})
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+)
+
+// SetACL enables access control of named graphs: each request can only read and write quads with labels
+// granted to its roles by the policy. Roles are read from the request context (see acl.WithRoles) and
+// from the roles header, if it is set. A nil policy disables access control.
+// It can be called while the API is serving requests.
+func (api *APIv2) SetACL(p *acl.Policy) {
+	api.mu.Lock()
+	api.settings.acl = p
+	api.mu.Unlock()
+}
+
+// SetRolesHeader sets a name of the request header with a comma-separated list of roles.
+// The header must only be trusted if it is set by an authenticating proxy in front of the server.
+func (api *APIv2) SetRolesHeader(name string) {
+	api.mu.Lock()
+	api.settings.rolesHeader = name
+	api.mu.Unlock()
+}
+
+// RequestRoles returns roles of the request set in the context by an authentication middleware,
+// and roles listed in a given header, if the header name is not empty.
+func RequestRoles(r *http.Request, header string) []string {
+	roles := acl.RolesFromContext(r.Context())
+	if header == "" {
+		return roles
+	}
+	roles = roles[:len(roles):len(roles)]
+	for _, v := range r.Header[http.CanonicalHeaderKey(header)] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				roles = append(roles, s)
+			}
+		}
+	}
+	return roles
+}
+
+// RestrictHandle returns a handle that can only access graphs granted by the policy to given roles.
+// Writes are checked and passed to the writer of the handle. Closing the returned handle does not close h.
+func RestrictHandle(h *graph.Handle, p *acl.Policy, roles []string) *graph.Handle {
+	qs := acl.NewStore(h.QuadStore, p, roles...)
+	return &graph.Handle{QuadStore: qs, QuadWriter: acl.NewWriter(qs, h.QuadWriter)}
+}
+
+// temporaryError is implemented by errors of writes that can be retried, for example on another cluster member.
+type temporaryError interface {
+	Temporary() bool
+}
+
+// writeErrorCode returns a response code for a failed write.
+func writeErrorCode(err error) int {
+	if _, ok := err.(*acl.ForbiddenError); ok {
+		return http.StatusForbidden
+	} else if te, ok := err.(temporaryError); ok && te.Temporary() {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
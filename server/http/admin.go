@@ -0,0 +1,351 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+type activeQuery struct {
+	model.ActiveQuery
+	cancel func()
+}
+
+// activeQueries is a registry of queries that are currently executed.
+type activeQueries struct {
+	mu   sync.Mutex
+	byID map[string]*activeQuery
+}
+
+func (s *activeQueries) add(q *activeQuery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byID == nil {
+		s.byID = make(map[string]*activeQuery)
+	}
+	s.byID[q.ID] = q
+}
+
+func (s *activeQueries) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byID, id)
+}
+
+func (s *activeQueries) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.byID)
+}
+
+func (s *activeQueries) list() []model.ActiveQuery {
+	s.mu.Lock()
+	out := make([]model.ActiveQuery, 0, len(s.byID))
+	for _, q := range s.byID {
+		out = append(out, q.ActiveQuery)
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Started.Before(out[j].Started)
+	})
+	return out
+}
+
+func (s *activeQueries) kill(id string) (model.ActiveQuery, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.byID[id]
+	if !ok {
+		return model.ActiveQuery{}, false
+	}
+	q.cancel()
+	delete(s.byID, id)
+	return q.ActiveQuery, true
+}
+
+// trackQuery registers a query as active until the returned function is called.
+// Returned context is canceled if the query is killed.
+func (api *APIv2) trackQuery(ctx context.Context, q model.ActiveQuery) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	q.ID = newJobID()
+	q.Started = time.Now()
+	api.active.add(&activeQuery{ActiveQuery: q, cancel: cancel})
+	return ctx, func() {
+		api.active.remove(q.ID)
+		cancel()
+	}
+}
+
+// SetAdminToken enables maintenance endpoints under /api/v2/admin.
+// Requests to these endpoints must provide the token in AdminTokenHeader or "Authorization: Bearer" header.
+// Admin endpoints are disabled if the token is empty.
+func (api *APIv2) SetAdminToken(token string) {
+	api.mu.Lock()
+	api.settings.adminToken = token
+	api.mu.Unlock()
+}
+
+// ReplicaStatusFunc reports the state of a read replica.
+type ReplicaStatusFunc func() model.ReplicaStatus
+
+// SetReplicaStatus sets a function that reports the state of replication, if the server is a read replica.
+// The status is included in database stats.
+func (api *APIv2) SetReplicaStatus(fnc ReplicaStatusFunc) {
+	api.replica = fnc
+}
+
+func (api *APIv2) RegisterAdminOn(r *httprouter.Router, wrappers ...HandlerWrapper) {
+	r.GET("/api/v2/admin/stats", wrap(api.adminOnly(api.ServeStats), wrappers))
+	r.POST("/api/v2/admin/stats/refresh", wrap(api.adminOnly(api.ServeRefreshStats), wrappers))
+	r.POST("/api/v2/admin/compact", wrap(api.adminOnly(api.ServeCompact), wrappers))
+	r.POST("/api/v2/admin/purge", wrap(api.adminOnly(api.ServePurge), wrappers))
+	r.POST("/api/v2/admin/indexes", wrap(api.adminOnly(api.ServeEnsureIndexes), wrappers))
+	r.GET("/api/v2/admin/text", wrap(api.adminOnly(api.ServeTextIndex), wrappers))
+	r.POST("/api/v2/admin/text/rebuild", wrap(api.adminOnly(api.ServeRebuildTextIndex), wrappers))
+	r.GET("/api/v2/admin/backup", wrap(api.adminOnly(api.ServeBackup), wrappers))
+	r.GET("/api/v2/admin/queries", wrap(api.adminOnly(api.ServeActiveQueries), wrappers))
+	r.DELETE("/api/v2/admin/queries/:id", wrap(api.adminOnly(api.ServeKillQuery), wrappers))
+	r.GET("/api/v2/admin/audit", wrap(api.adminOnly(api.ServeAuditLog), wrappers))
+}
+
+// adminOnly checks that a request contains a valid admin token.
+func (api *APIv2) adminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.checkAdmin(w, r) {
+			h(w, r)
+		}
+	}
+}
+
+// checkAdmin checks that a request contains a valid admin token. If not, it writes an error response.
+func (api *APIv2) checkAdmin(w http.ResponseWriter, r *http.Request) bool {
+	return CheckAdminToken(w, r, api.conf().adminToken)
+}
+
+type adminAccessKey struct{}
+
+// WithAdminAccess marks a request as authorized to access admin endpoints, for example by an authentication
+// middleware. Such requests are accepted without the admin token.
+func WithAdminAccess(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminAccessKey{}, true)
+}
+
+// AdminTokenHeader is a header for the admin token. Unlike "Authorization" header, it does not conflict
+// with credentials checked by an authentication middleware.
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminTokenOf returns the admin token passed in AdminTokenHeader or "Authorization: Bearer" header of the request.
+func AdminTokenOf(r *http.Request) string {
+	if tok := r.Header.Get(AdminTokenHeader); tok != "" {
+		return tok
+	}
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return ""
+	}
+	return auth[len(prefix):]
+}
+
+// CheckAdminToken checks that a request contains a given admin token (see AdminTokenOf),
+// or is marked with WithAdminAccess. If not, it writes an error response.
+// All requests without admin access are rejected if the token is empty.
+func CheckAdminToken(w http.ResponseWriter, r *http.Request, token string) bool {
+	if ok, _ := r.Context().Value(adminAccessKey{}).(bool); ok {
+		return true
+	}
+	if token == "" {
+		jsonResponse(w, http.StatusForbidden, "admin API is disabled")
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(AdminTokenOf(r)), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="cayley"`)
+		jsonResponse(w, http.StatusUnauthorized, "invalid admin token")
+		return false
+	}
+	return true
+}
+
+// maintenanceResponse writes a result of a maintenance operation.
+func maintenanceResponse(w http.ResponseWriter, op string, err error) {
+	if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, err)
+		return
+	} else if err != nil {
+		clog.Errorf("admin: %s failed: %v", op, err)
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	clog.Infof("admin: %s completed", op)
+	writeJSON(w, http.StatusOK, model.Result{Result: op + " completed"})
+}
+
+func (api *APIv2) ServeStats(w http.ResponseWriter, r *http.Request) {
+	exact := false
+	if s := r.FormValue("exact"); s != "" {
+		var err error
+		if exact, err = strconv.ParseBool(s); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	gs, err := graph.StatsOf(r.Context(), api.h.QuadStore, exact)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	st := model.Stats{
+		Size:          api.h.QuadStore.Size(),
+		Quads:         gs.Quads,
+		Nodes:         gs.Nodes,
+		Exact:         gs.Exact,
+		ActiveQueries: api.active.len(),
+	}
+	if api.feed != nil {
+		st.Horizon = api.feed.Horizon()
+	}
+	if api.replica != nil {
+		rs := api.replica()
+		st.Replica = &rs
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (api *APIv2) ServeRefreshStats(w http.ResponseWriter, r *http.Request) {
+	maintenanceResponse(w, "stats refresh", graph.RefreshStats(r.Context(), api.h.QuadStore))
+}
+
+func (api *APIv2) ServeCompact(w http.ResponseWriter, r *http.Request) {
+	maintenanceResponse(w, "compaction", graph.Compact(r.Context(), api.h.QuadStore))
+}
+
+// ServePurge removes tombstones and delta log entries older than a given duration.
+func (api *APIv2) ServePurge(w http.ResponseWriter, r *http.Request) {
+	var (
+		age    time.Duration
+		dryRun bool
+		err    error
+	)
+	if s := r.FormValue("older_than"); s != "" {
+		if age, err = time.ParseDuration(s); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if s := r.FormValue("dry_run"); s != "" {
+		if dryRun, err = strconv.ParseBool(s); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	st, err := graph.Purge(r.Context(), api.h.QuadStore, time.Now().Add(-age), dryRun)
+	if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, err)
+		return
+	} else if err != nil {
+		clog.Errorf("admin: purge failed: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !dryRun {
+		clog.Infof("admin: purge completed")
+	}
+	writeJSON(w, http.StatusOK, model.PurgeResult{
+		Quads:      st.Quads,
+		LogEntries: st.LogEntries,
+		Bytes:      st.Bytes,
+		DryRun:     dryRun,
+	})
+}
+
+func (api *APIv2) ServeEnsureIndexes(w http.ResponseWriter, r *http.Request) {
+	maintenanceResponse(w, "index creation", graph.EnsureIndexes(r.Context(), api.h.QuadStore))
+}
+
+// ServeTextIndex returns the state of the full-text index.
+func (api *APIv2) ServeTextIndex(w http.ResponseWriter, r *http.Request) {
+	st, err := graph.TextIndexStatusOf(r.Context(), api.h.QuadStore)
+	if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, "full-text index is not enabled")
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := model.TextIndex{
+		Type:       st.Type,
+		Predicates: make([]string, 0, len(st.Predicates)),
+		Documents:  st.Documents,
+	}
+	for _, p := range st.Predicates {
+		out.Predicates = append(out.Predicates, model.Value(p))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (api *APIv2) ServeRebuildTextIndex(w http.ResponseWriter, r *http.Request) {
+	maintenanceResponse(w, "text index rebuild", graph.RebuildTextIndex(r.Context(), api.h.QuadStore))
+}
+
+// ServeBackup streams all quads from the database as a file attachment.
+// Only the format parameter of the read method is respected.
+func (api *APIv2) ServeBackup(w http.ResponseWriter, r *http.Request) {
+	q := make(url.Values)
+	if f := r.URL.Query().Get("format"); f != "" {
+		q.Set("format", f)
+	} else {
+		q.Set("format", defaultFormat)
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.RawQuery = q.Encode()
+	r2.URL = &u
+	r2.Form, r2.PostForm = nil, nil
+
+	name := fmt.Sprintf("cayley-%s.%s", time.Now().UTC().Format("20060102-150405"), q.Get("format"))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	api.ServeRead(w, r2)
+}
+
+func (api *APIv2) ServeActiveQueries(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, model.ActiveQueryList{Queries: api.active.list()})
+}
+
+func (api *APIv2) ServeKillQuery(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v2/admin/queries/")
+	q, ok := api.active.kill(id)
+	if !ok {
+		jsonResponse(w, http.StatusNotFound, "query not found")
+		return
+	}
+	clog.Infof("admin: killed query %s", id)
+	writeJSON(w, http.StatusOK, q)
+}
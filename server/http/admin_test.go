@@ -0,0 +1,139 @@
+package cayleyhttp
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http/model"
+	"github.com/cayleygraph/cayley/writer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", ""),
+	)
+	defer h.Close()
+	api := NewAPIv2(h)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	do := func(method, path, token string, code int, out interface{}) *http.Response {
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode)
+		if out != nil {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+		} else {
+			ioutil.ReadAll(resp.Body)
+		}
+		return resp
+	}
+
+	// disabled by default
+	do("GET", "/api/v2/admin/stats", "secret", http.StatusForbidden, nil)
+
+	api.SetAdminToken("secret")
+	do("GET", "/api/v2/admin/stats", "", http.StatusUnauthorized, nil)
+	do("GET", "/api/v2/admin/stats", "wrong", http.StatusUnauthorized, nil)
+
+	var st model.Stats
+	do("GET", "/api/v2/admin/stats", "secret", http.StatusOK, &st)
+	require.Equal(t, h.QuadStore.Size(), st.Size)
+
+	// memstore has no maintenance operations
+	do("POST", "/api/v2/admin/compact", "secret", http.StatusNotImplemented, nil)
+	do("POST", "/api/v2/admin/purge?older_than=1h", "secret", http.StatusNotImplemented, nil)
+	do("POST", "/api/v2/admin/purge?older_than=x", "secret", http.StatusBadRequest, nil)
+	do("GET", "/api/v2/admin/text", "secret", http.StatusNotImplemented, nil)
+	do("POST", "/api/v2/admin/text/rebuild", "secret", http.StatusNotImplemented, nil)
+
+	req, err := http.NewRequest("GET", srv.URL+"/api/v2/admin/backup", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+	require.Equal(t, 2, strings.Count(string(data), "\n"))
+
+	// active queries can be listed and killed
+	ctx, done := api.trackQuery(context.Background(), model.ActiveQuery{Lang: "gizmo", Query: "g.V().All()"})
+	defer done()
+	var list model.ActiveQueryList
+	do("GET", "/api/v2/admin/queries", "secret", http.StatusOK, &list)
+	require.Len(t, list.Queries, 1)
+	require.Equal(t, "g.V().All()", list.Queries[0].Query)
+
+	do("DELETE", "/api/v2/admin/queries/"+list.Queries[0].ID, "secret", http.StatusOK, nil)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("query was not canceled")
+	}
+	do("DELETE", "/api/v2/admin/queries/"+list.Queries[0].ID, "secret", http.StatusNotFound, nil)
+	do("GET", "/api/v2/admin/queries", "secret", http.StatusOK, &list)
+	require.Empty(t, list.Queries)
+}
+
+func TestAdminTextIndex(t *testing.T) {
+	qs, err := text.New(memstore.New(
+		quad.Make(quad.IRI("alice"), quad.IRI("name"), "Alice Smith", nil),
+		quad.Make(quad.IRI("bob"), quad.IRI("name"), "Bob", nil),
+	), text.Config{Predicates: []quad.IRI{"name"}})
+	require.NoError(t, err)
+	wr, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	h := &graph.Handle{QuadStore: qs, QuadWriter: wr}
+	defer h.Close()
+	api := NewAPIv2(h)
+	api.SetAdminToken("secret")
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	get := func() model.TextIndex {
+		req, err := http.NewRequest("GET", srv.URL+"/api/v2/admin/text", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var out model.TextIndex
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out
+	}
+	require.Equal(t, model.TextIndex{Type: "memory", Predicates: []string{"<name>"}, Documents: 2}, get())
+
+	require.NoError(t, qs.Index().Clear(context.Background()))
+	require.Equal(t, int64(0), get().Documents)
+
+	req, err := http.NewRequest("POST", srv.URL+"/api/v2/admin/text/rebuild", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int64(2), get().Documents)
+}
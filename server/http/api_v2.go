@@ -15,6 +15,7 @@
 package cayleyhttp
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/json"
@@ -23,14 +24,22 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/internal"
+	"github.com/cayleygraph/cayley/internal/audit"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/redact"
 	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/server/http/model"
 	_ "github.com/cayleygraph/cayley/writer"
 )
 
@@ -39,7 +48,7 @@ func NewAPIv2(h *graph.Handle) *APIv2 {
 }
 
 func NewAPIv2Writer(h *graph.Handle, wtype string, wopts graph.Options) *APIv2 {
-	api := &APIv2{h: h, wtyp: wtype, wopt: wopts, limit: 100}
+	api := &APIv2{h: h, wtyp: wtype, wopt: wopts, settings: settings{limit: 100}}
 	api.r = httprouter.New()
 	api.RegisterOn(api.r)
 	return api
@@ -48,29 +57,81 @@ func NewAPIv2Writer(h *graph.Handle, wtype string, wopts graph.Options) *APIv2 {
 type APIv2 struct {
 	h     *graph.Handle
 	r     *httprouter.Router
-	ro    bool
 	batch int
 
 	// replication
 	wtyp string
 	wopt graph.Options
 
+	jobs   jobs
+	active activeQueries
+	spec   *OpenAPISpec
+	feed   *graph.ChangeFeed
+	// cache stores results of repeated queries, if set
+	cache *ResultCache
+	// replica reports the state of replication if the server is a read replica
+	replica ReplicaStatusFunc
+	// audit is searched by the admin API, if set
+	audit *audit.Log
+
+	mu       sync.RWMutex
+	settings settings
+}
+
+// settings are parameters of the API that can be changed while it serves requests.
+type settings struct {
+	ro bool
+
 	// query
 	timeout time.Duration
 	limit   int
+	limits  query.Limits
+	// parallel is a buffer size for parallel execution of queries; zero disables it
+	parallel int
+
+	adminToken string
+
+	// acl restricts access to named graphs, if set
+	acl         *acl.Policy
+	rolesHeader string
+
+	// redact removes or anonymizes quads returned by the read endpoint, if set
+	redact *redact.Redactor
+}
+
+// conf returns current settings of the API.
+func (api *APIv2) conf() settings {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.settings
 }
 
+// SetReadOnly disables write methods. It can be called while the API is serving requests.
 func (api *APIv2) SetReadOnly(ro bool) {
-	api.ro = ro
+	api.mu.Lock()
+	api.settings.ro = ro
+	api.mu.Unlock()
+}
+
+// SetRedaction sets rules applied to quads returned by the read endpoint.
+// It can be called while the API is serving requests.
+func (api *APIv2) SetRedaction(r *redact.Redactor) {
+	api.mu.Lock()
+	api.settings.redact = r
+	api.mu.Unlock()
 }
 func (api *APIv2) SetBatchSize(n int) {
 	api.batch = n
 }
 func (api *APIv2) SetQueryTimeout(dt time.Duration) {
-	api.timeout = dt
+	api.mu.Lock()
+	api.settings.timeout = dt
+	api.mu.Unlock()
 }
 func (api *APIv2) SetQueryLimit(n int) {
-	api.limit = n
+	api.mu.Lock()
+	api.settings.limit = n
+	api.mu.Unlock()
 }
 func (api *APIv2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	api.r.ServeHTTP(w, r)
@@ -88,22 +149,34 @@ func wrap(h http.HandlerFunc, arr []HandlerWrapper) httprouter.Handle {
 	return wh
 }
 func (api *APIv2) RegisterDataOn(r *httprouter.Router, wrappers ...HandlerWrapper) {
-	if !api.ro {
-		r.POST("/api/v2/write", wrap(api.ServeWrite, wrappers))
-		r.POST("/api/v2/delete", wrap(api.ServeDelete, wrappers))
-		r.POST("/api/v2/node/delete", wrap(api.ServeNodeDelete, wrappers))
-	}
+	// write methods are registered even if the API is read-only, since it can be toggled at runtime
+	r.POST("/api/v2/write", wrap(api.ServeWrite, wrappers))
+	r.POST("/api/v2/write/stream", wrap(api.ServeWriteStream, wrappers))
+	r.POST("/api/v2/delete", wrap(api.ServeDelete, wrappers))
+	r.POST("/api/v2/node/delete", wrap(api.ServeNodeDelete, wrappers))
+	r.POST("/api/v2/tx", wrap(api.ServeTransaction, wrappers))
 	r.POST("/api/v2/read", wrap(api.ServeRead, wrappers))
 	r.GET("/api/v2/read", wrap(api.ServeRead, wrappers))
 	r.GET("/api/v2/formats", wrap(api.ServeFormats, wrappers))
+	r.GET("/api/v2/capabilities", wrap(api.ServeCapabilities, wrappers))
+	r.GET("/api/v2/changes", wrap(api.ServeChanges, wrappers))
+	r.GET("/api/v2/horizon", wrap(api.ServeHorizon, wrappers))
+	r.GET("/api/v2/log", wrap(api.ServeLogEntries, wrappers))
+	r.GET("/api/v2/log/:horizon", wrap(api.ServeLogEntry, wrappers))
 }
 func (api *APIv2) RegisterQueryOn(r *httprouter.Router, wrappers ...HandlerWrapper) {
 	r.POST("/api/v2/query", wrap(api.ServeQuery, wrappers))
 	r.GET("/api/v2/query", wrap(api.ServeQuery, wrappers))
+	if l := query.GetLanguage(SPARQLLang); l != nil && l.Session != nil {
+		api.RegisterSPARQLOn(r, l, wrappers...)
+	}
 }
 func (api *APIv2) RegisterOn(r *httprouter.Router, wrappers ...HandlerWrapper) {
 	api.RegisterDataOn(r, wrappers...)
 	api.RegisterQueryOn(r, wrappers...)
+	api.RegisterGraphStoreOn(r, wrappers...)
+	api.RegisterResourcesOn(r, wrappers...)
+	api.RegisterAdminOn(r, wrappers...)
 }
 
 const (
@@ -154,24 +227,34 @@ type nopWriteCloser struct {
 
 func (nopWriteCloser) Close() error { return nil }
 
-func writerFrom(w http.ResponseWriter, r *http.Request, acceptName string) io.WriteCloser {
-	if specs := ParseAccept(r.Header, acceptName); len(specs) != 0 {
-		if s := specs[0]; s.Value == "gzip" {
-			w.Header().Set(hdrContentEncoding, s.Value)
-			zw := gzip.NewWriter(w)
-			return zw
-		}
+func (api *APIv2) handleForRequest(r *http.Request) (*graph.Handle, error) {
+	h, err := HandleForRequest(api.h, api.wtyp, api.wopt, r)
+	if err != nil {
+		return nil, err
+	}
+	if conf := api.conf(); conf.acl != nil {
+		return RestrictHandle(h, conf.acl, RequestRoles(r, conf.rolesHeader)), nil
 	}
-	return nopWriteCloser{Writer: w}
+	return h, nil
 }
 
-func (api *APIv2) handleForRequest(r *http.Request) (*graph.Handle, error) {
-	return HandleForRequest(api.h, api.wtyp, api.wopt, r)
+// writerForRequest returns a writer for the handle that sets a label from the "label" parameter
+// on all quads without a label.
+func writerForRequest(h *graph.Handle, r *http.Request) (graph.QuadWriter, error) {
+	s := r.FormValue("label")
+	if s == "" {
+		return h.QuadWriter, nil
+	}
+	v, err := model.ParseValue(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label value: %v", err)
+	}
+	return graph.NewLabelWriter(h.QuadWriter, v), nil
 }
 
 func (api *APIv2) ServeWrite(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	if api.ro {
+	if api.conf().ro {
 		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
 		return
 	}
@@ -193,25 +276,233 @@ func (api *APIv2) ServeWrite(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, err)
 		return
 	}
-	qw := graph.NewWriter(h.QuadWriter)
+	hw, err := writerForRequest(h, r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	expires, err := expirationForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if mode := r.FormValue("mode"); (mode != "" && mode != "add") || !expires.IsZero() {
+		api.serveWriteMode(w, r, hw, qr, mode, expires)
+		return
+	}
+	qw := graph.NewWriter(hw)
 	defer qw.Close()
 	n, err := quad.CopyBatch(qw, qr, api.batch)
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+		jsonResponse(w, writeErrorCode(err), err)
 		return
 	}
 	err = qw.Close()
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+		jsonResponse(w, writeErrorCode(err), err)
+		return
+	}
+	GetRequestInfo(r).SetQuads(n)
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	fmt.Fprintf(w, `{"result": "Successfully wrote %d quads.", "count": %d}`+"\n", n, n)
+}
+
+// writeModes maps values of the mode parameter of the write method to delta actions.
+var writeModes = map[string]graph.Procedure{
+	"":        graph.Add,
+	"add":     graph.Add,
+	"unique":  graph.AddUnique,
+	"replace": graph.ReplaceObject,
+}
+
+// expirationForRequest returns an expiration time for written quads, set either by the "ttl" parameter
+// as a duration from now, or by the "expires" parameter as an RFC 3339 timestamp.
+// It returns zero time if neither is set.
+func expirationForRequest(r *http.Request) (time.Time, error) {
+	ttl, exp := r.FormValue("ttl"), r.FormValue("expires")
+	switch {
+	case ttl != "" && exp != "":
+		return time.Time{}, errors.New("ttl and expires cannot be used together")
+	case ttl != "":
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return time.Time{}, fmt.Errorf("invalid ttl: %q", ttl)
+		}
+		return time.Now().Add(d), nil
+	case exp != "":
+		t, err := time.Parse(time.RFC3339, exp)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid expiration time: %q", exp)
+		}
+		return t, nil
+	}
+	return time.Time{}, nil
+}
+
+// serveWriteMode writes all quads with a given action in a single transaction.
+// If expires is set, written quads expire at this time.
+func (api *APIv2) serveWriteMode(w http.ResponseWriter, r *http.Request, qw graph.QuadWriter, qr quad.Reader, mode string, expires time.Time) {
+	p, ok := writeModes[mode]
+	if !ok {
+		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("unknown write mode: %q", mode))
+		return
+	}
+	tx := graph.NewTransaction()
+	n, err := quad.Copy(graph.NewTxWriter(tx, p), qr)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if expires.IsZero() {
+		err = qw.ApplyTransaction(tx)
+	} else {
+		err = graph.ApplyTransactionWithExpiration(qw, tx, expires)
+	}
+	if err != nil {
+		jsonResponse(w, txErrorCode(err), err)
 		return
 	}
+	GetRequestInfo(r).SetQuads(n)
 	w.Header().Set(hdrContentType, contentTypeJSON)
 	fmt.Fprintf(w, `{"result": "Successfully wrote %d quads.", "count": %d}`+"\n", n, n)
 }
 
+// writeProgress is a single line of a streaming write response.
+type writeProgress struct {
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Count  int    `json:"count"`
+	Done   bool   `json:"done,omitempty"`
+}
+
+// ServeWriteStream reads quads from a request body of an arbitrary size and writes them in batches.
+//
+// Batch size can be set with the "batch" parameter. After each batch is applied, a progress line
+// is written to the response as a JSON object, followed by a final line with the total count or an error.
+// Batches that were already applied are not rolled back if the request fails.
+//
+// Instead of the request body, quads can be downloaded from a remote URL set by the "source" parameter
+// (http, https, s3 or gs). Such requests require the admin token. The format is set by the "format"
+// parameter or detected by the file extension; compressed files are decompressed automatically.
+func (api *APIv2) ServeWriteStream(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if api.conf().ro {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return
+	}
+	source := r.FormValue("source")
+	if source != "" && !api.checkAdmin(w, r) {
+		return
+	} else if source != "" && !internal.IsRemoteSource(source) {
+		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("unsupported source: %q", source))
+		return
+	}
+	format := getFormat(r, "", hdrContentType)
+	if source == "" && (format == nil || format.Reader == nil) {
+		jsonResponse(w, http.StatusBadRequest, errors.New("format is not supported for reading data"))
+		return
+	}
+	batch := api.batch
+	if s := r.FormValue("batch"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid batch size: %q", s))
+			return
+		}
+		batch = n
+	}
+	if batch <= 0 {
+		batch = quad.DefaultBatch
+	}
+	var qr quad.ReadCloser
+	if source != "" {
+		var err error
+		qr, err = internal.RemoteQuadReader(r.Context(), source, r.FormValue("format"))
+		if err != nil {
+			jsonResponse(w, http.StatusBadGateway, err)
+			return
+		}
+	} else {
+		rd, err := readerFrom(r, hdrContentEncoding)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		defer rd.Close()
+		qr = format.Reader(rd)
+	}
+	defer qr.Close()
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	hw, err := writerForRequest(h, r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	send := func(p writeProgress) {
+		enc.Encode(p)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	bqr, ok := qr.(quad.BatchReader)
+	if !ok {
+		bqr = batchReader{qr}
+	}
+	buf := make([]quad.Quad, batch)
+	cnt := 0
+	// batches are not rolled back on errors, so report everything that was written
+	defer func() { GetRequestInfo(r).SetQuads(cnt) }()
+	for {
+		n, rerr := bqr.ReadQuads(buf)
+		if rerr != nil && rerr != io.EOF {
+			send(writeProgress{Error: rerr.Error(), Count: cnt})
+			return
+		}
+		if n > 0 {
+			if err := hw.AddQuadSet(buf[:n]); err != nil {
+				send(writeProgress{Error: err.Error(), Count: cnt})
+				return
+			}
+			cnt += n
+		}
+		if rerr == io.EOF {
+			break
+		}
+		send(writeProgress{Count: cnt})
+	}
+	send(writeProgress{
+		Result: fmt.Sprintf("Successfully wrote %d quads.", cnt),
+		Count:  cnt, Done: true,
+	})
+}
+
+// batchReader implements quad.BatchReader for readers that can only read one quad at a time.
+type batchReader struct {
+	quad.Reader
+}
+
+func (r batchReader) ReadQuads(buf []quad.Quad) (n int, err error) {
+	for ; n < len(buf); n++ {
+		buf[n], err = r.ReadQuad()
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
 func (api *APIv2) ServeDelete(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	if api.ro {
+	if api.conf().ro {
 		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
 		return
 	}
@@ -233,20 +524,26 @@ func (api *APIv2) ServeDelete(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, err)
 		return
 	}
-	qw := graph.NewRemover(h.QuadWriter)
+	hw, err := writerForRequest(h, r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	qw := graph.NewRemover(hw)
 	defer qw.Close()
 	n, err := quad.CopyBatch(qw, qr, api.batch)
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+		jsonResponse(w, writeErrorCode(err), err)
 		return
 	}
+	GetRequestInfo(r).SetQuads(n)
 	w.Header().Set(hdrContentType, contentTypeJSON)
 	fmt.Fprintf(w, `{"result": "Successfully deleted %d quads.", "count": %d}`+"\n", n, n)
 }
 
 func (api *APIv2) ServeNodeDelete(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	if api.ro {
+	if api.conf().ro {
 		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
 		return
 	}
@@ -280,7 +577,7 @@ func (api *APIv2) ServeNodeDelete(w http.ResponseWriter, r *http.Request) {
 	}
 	err = h.RemoveNode(v)
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+		jsonResponse(w, writeErrorCode(err), err)
 		return
 	}
 	w.Header().Set(hdrContentType, contentTypeJSON)
@@ -298,19 +595,98 @@ func (w *checkWriter) Write(p []byte) (int, error) {
 	return w.w.Write(p)
 }
 
+// ldWriter is implemented by quad writers that can compact and frame JSON-LD output.
+type ldWriter interface {
+	SetLdContext(ctx interface{})
+	SetLdFrame(frame interface{})
+}
+
+type ldDocs struct {
+	ctx, frame interface{}
+}
+
+// ldDocuments parses JSON-LD @context and frame documents passed in "context" and "frame" parameters.
+func ldDocuments(r *http.Request) (ldDocs, error) {
+	var docs ldDocs
+	for _, p := range []struct {
+		name string
+		dst  *interface{}
+	}{
+		{"context", &docs.ctx},
+		{"frame", &docs.frame},
+	} {
+		s := r.FormValue(p.name)
+		if s == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(s), p.dst); err != nil {
+			return docs, fmt.Errorf("invalid %s document: %v", p.name, err)
+		}
+	}
+	return docs, nil
+}
+
 func (api *APIv2) ServeRead(w http.ResponseWriter, r *http.Request) {
 	format := getFormat(r, "format", hdrAccept)
 	if format == nil || format.Writer == nil {
 		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("format is not supported for reading data"))
 		return
 	}
+	ld, err := ldDocuments(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	filter, fkey, err := quadFilter(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
 	h, err := api.handleForRequest(r)
 	if err != nil {
 		jsonResponse(w, http.StatusBadRequest, err)
 		return
 	}
-	qr := graph.NewQuadStoreReader(h.QuadStore)
-	defer qr.Close()
+	if api.notModified(w, r) {
+		return
+	}
+	var qr quad.Reader
+	if r.FormValue("limit") != "" || r.FormValue("cursor") != "" {
+		// paged mode
+		limit := 0
+		if s := r.FormValue("limit"); s != "" {
+			if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+				jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %q", s))
+				return
+			}
+		}
+		var after *cursor
+		if s := r.FormValue("cursor"); s != "" {
+			if after, err = parseCursor(s); err != nil {
+				jsonResponse(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		ctx, cancel := api.queryContext(r)
+		defer cancel()
+		quads, next, err := readPage(ctx, h.QuadStore, filter, fkey, after, limit)
+		if err == errInvalidCursor {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		} else if err != nil {
+			jsonResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+		if next != nil {
+			w.Header().Set(hdrNextCursor, next.String())
+		}
+		qr = quad.NewReader(quads)
+	} else {
+		rc := graph.NewResultReader(h.QuadStore, shape.BuildIterator(h.QuadStore, filterShape(filter)))
+		defer rc.Close()
+		qr = rc
+	}
+	qr = api.conf().redact.NewReader(qr)
 
 	wr := writerFrom(w, r, hdrAcceptEncoding)
 	defer wr.Close()
@@ -318,6 +694,19 @@ func (api *APIv2) ServeRead(w http.ResponseWriter, r *http.Request) {
 	cw := &checkWriter{w: wr}
 	qw := format.Writer(cw)
 	defer qw.Close()
+	if ld.ctx != nil || ld.frame != nil {
+		lw, ok := qw.(ldWriter)
+		if !ok {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("%s format does not support JSON-LD context and frame", format.Name))
+			return
+		}
+		if ld.ctx != nil {
+			lw.SetLdContext(ld.ctx)
+		}
+		if ld.frame != nil {
+			lw.SetLdFrame(ld.frame)
+		}
+	}
 	if len(format.Mime) != 0 {
 		w.Header().Set(hdrContentType, format.Mime[0])
 	}
@@ -361,10 +750,38 @@ func (api *APIv2) ServeFormats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(out)
 }
 
+// ServeCapabilities lists optional features supported by the database, so clients can avoid
+// requests that will fail with "not implemented".
+func (api *APIv2) ServeCapabilities(w http.ResponseWriter, r *http.Request) {
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	c := graph.CapabilitiesOf(h.QuadStore)
+	writeJSON(w, http.StatusOK, model.Capabilities{
+		ReadOnly:          api.conf().ro,
+		Transactions:      c.Transactions,
+		RegexPushdown:     c.RegexPushdown,
+		SortPushdown:      c.SortPushdown,
+		ChangeFeed:        c.ChangeFeed,
+		HorizonReads:      c.HorizonReads,
+		ConditionalWrites: c.ConditionalWrites,
+		DeltaLog:          c.DeltaLog,
+		Provenance:        c.Provenance,
+		Metadata:          c.Metadata,
+		TextSearch:        c.TextSearch,
+		GeoSearch:         c.GeoSearch,
+		VectorSearch:      c.VectorSearch,
+	})
+}
+
 func (api *APIv2) queryContext(r *http.Request) (ctx context.Context, cancel func()) {
-	ctx = context.TODO() // TODO(dennwc): get from request
-	if api.timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, api.timeout)
+	// queries are canceled when the client disconnects or the server is forcibly closed
+	conf := api.conf()
+	ctx = query.WithParallel(r.Context(), conf.parallel)
+	if timeout := conf.timeout; timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 	} else {
 		ctx, cancel = context.WithCancel(ctx)
 	}
@@ -385,6 +802,21 @@ func writeResults(w io.Writer, r interface{}) {
 	w.Write([]byte("}\n"))
 }
 
+// parseTimeout parses a query timeout, either as a number of seconds or as a Go duration string.
+func parseTimeout(s string) (time.Duration, error) {
+	if sec, err := strconv.ParseFloat(s, 64); err == nil {
+		if sec <= 0 {
+			return 0, fmt.Errorf("invalid timeout: %q", s)
+		}
+		return time.Duration(sec * float64(time.Second)), nil
+	}
+	dt, err := time.ParseDuration(s)
+	if err != nil || dt <= 0 {
+		return 0, fmt.Errorf("invalid timeout: %q", s)
+	}
+	return dt, nil
+}
+
 const maxQuerySize = 1024 * 1024 // 1 MB
 func readLimit(r io.Reader) ([]byte, error) {
 	lr := io.LimitReader(r, maxQuerySize).(*io.LimitedReader)
@@ -404,6 +836,8 @@ func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, "query language not specified")
 		return
 	}
+	ri := GetRequestInfo(r)
+	ri.SetLang(lang)
 	l := query.GetLanguage(lang)
 	if l == nil {
 		jsonResponse(w, http.StatusBadRequest, "unknown query language")
@@ -419,21 +853,78 @@ func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	default:
 	}
+	explain := false
+	if s := vals.Get("explain"); s != "" {
+		var err error
+		if explain, err = strconv.ParseBool(s); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if s := vals.Get("timeout"); s != "" {
+		dt, err := parseTimeout(s)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		// the server-side timeout is still applied, thus clients can only make it shorter
+		var cancelTimeout func()
+		ctx, cancelTimeout = context.WithTimeout(ctx, dt)
+		defer cancelTimeout()
+	}
 	h, err := api.handleForRequest(r)
 	if err != nil {
 		errFunc(w, err)
 		return
 	}
+	qs, release, ok := StoreAt(w, r, h.QuadStore)
+	if !ok {
+		return
+	}
+	defer release()
+	if !api.conf().ro && vals.Get("at") == "" {
+		// allow query languages to write, unless the query runs against a past state of the graph
+		ctx = query.WithWriter(ctx, h.QuadWriter)
+	}
 	if l.HTTPQuery != nil {
+		if explain {
+			jsonResponse(w, http.StatusBadRequest, "explain is not supported for this query language")
+			return
+		}
 		defer r.Body.Close()
-		l.HTTPQuery(ctx, h.QuadStore, w, r.Body)
+		ver := api.cacheVersion()
+		if ver == "" {
+			ctx, done := api.trackQuery(ctx, model.ActiveQuery{Lang: lang, Remote: r.RemoteAddr})
+			defer done()
+			ctx, qs, _ := query.WithLimits(ctx, qs, api.conf().limits)
+			l.HTTPQuery(ctx, query.Instrument(ctx, qs), w, r.Body)
+			return
+		}
+		data, err := readLimit(r.Body)
+		if err != nil {
+			errFunc(w, err)
+			return
+		}
+		key := api.cacheKey(r, string(data))
+		if v, ok := api.cache.get(ver, key); ok {
+			v.(*cachedResponse).writeTo(w)
+			return
+		}
+		ctx, done := api.trackQuery(ctx, model.ActiveQuery{Lang: lang, Query: string(data), Remote: r.RemoteAddr})
+		defer done()
+		ctx, qs, _ := query.WithLimits(ctx, qs, api.conf().limits)
+		rec := &responseRecorder{ResponseWriter: w}
+		l.HTTPQuery(ctx, query.Instrument(ctx, qs), rec, bytes.NewReader(data))
+		// the query might have changed the graph
+		if resp := rec.response(); resp != nil && ctx.Err() == nil && api.cacheVersion() == ver {
+			api.cache.put(ver, key, resp)
+		}
 		return
 	}
 	if l.HTTP == nil {
 		errFunc(w, errors.New("HTTP interface is not supported for this query language"))
 		return
 	}
-	ses := l.HTTP(h.QuadStore)
 	var qu string
 	if r.Method == "GET" {
 		qu = vals.Get("qu")
@@ -449,27 +940,78 @@ func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, "query is empty")
 		return
 	}
+	ri.SetQuery(qu)
+	rf, err := resultFormatFor(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	// queries sent with GET are assumed to be deterministic, but plans include timings
+	if !explain && api.notModified(w, r) {
+		return
+	}
 	if clog.V(1) {
 		clog.Infof("query: %s: %q", lang, qu)
 	}
-
-	c := make(chan query.Result, 5)
-	go ses.Execute(ctx, qu, c, api.limit)
-
-	for res := range c {
-		if err := res.Err(); err != nil {
-			if err == nil {
-				continue // wait for results channel to close
-			}
-			errFunc(w, err)
-			return
-		}
-		ses.Collate(res)
+	ctx, done := api.trackQuery(ctx, model.ActiveQuery{Lang: lang, Query: qu, Remote: r.RemoteAddr})
+	defer done()
+	var plans *query.Explain
+	if explain {
+		ctx, plans = query.WithExplain(ctx)
+	}
+	conf := api.conf()
+	var (
+		output interface{}
+		cached bool
+		ver    string
+		key    string
+	)
+	if !explain {
+		ver = api.cacheVersion()
+	}
+	if ver != "" {
+		key = api.cacheKey(r, qu)
+		output, cached = api.cache.get(ver, key)
+	}
+	if !cached {
+		output, err = execQuery(ctx, qs, l, qu, conf.limit, conf.limits)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		// results are incomplete, even if the session stopped without an error
+		ri.SetError(context.DeadlineExceeded)
+		jsonResponse(w, http.StatusRequestTimeout, "query timed out")
+		return
 	}
-	output, err := ses.Results()
-	if err != nil {
+	ri.SetError(err)
+	if WriteLimitError(w, err) {
+		return
+	} else if err != nil {
 		errFunc(w, err)
 		return
 	}
-	writeResults(w, output)
+	// the query might have changed the graph
+	if ver != "" && !cached && api.cacheVersion() == ver {
+		api.cache.put(ver, key, output)
+	}
+	rows, isList := resultRows(output)
+	if isList {
+		ri.SetResults(len(rows))
+	}
+	if plans != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"result":  output,
+			"explain": plans.Plans(),
+		})
+		return
+	}
+	if rf.List && !isList {
+		jsonResponse(w, http.StatusNotAcceptable, fmt.Errorf("results cannot be encoded as %s", rf.Name))
+		return
+	}
+	w.Header().Set(hdrContentType, rf.Mime)
+	wr := writerFrom(w, r, hdrAcceptEncoding)
+	defer wr.Close()
+	if err = rf.Write(wr, output, rows); err != nil {
+		clog.Errorf("query: cannot write results: %v", err)
+	}
 }
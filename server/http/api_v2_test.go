@@ -1,15 +1,26 @@
 package cayleyhttp
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/cayleygraph/cayley/client"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
 	"github.com/cayleygraph/cayley/graph/graphtest"
+	_ "github.com/cayleygraph/cayley/graph/kv/btree"
 	"github.com/cayleygraph/cayley/graph/memstore"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+	"github.com/cayleygraph/cayley/quad/redact"
+	"github.com/cayleygraph/cayley/server/http/model"
 	"github.com/cayleygraph/cayley/writer"
 	"github.com/stretchr/testify/require"
 )
@@ -49,6 +60,89 @@ func TestV2Write(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestV2WriteLabel(t *testing.T) {
+	h := makeHandle(t)
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	const data = "<a> <b> <c> .\n<a> <b> <d> <g2> .\n"
+	resp, err := http.Post(srv.URL+"/api/v2/write?label=%3Cg1%3E", "application/n-quads", bytes.NewBufferString(data))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	quads, err := quad.ReadAll(graph.NewQuadStoreReader(h.QuadStore))
+	require.NoError(t, err)
+	sort.Sort(quad.ByQuadString(quads))
+	require.Equal(t, []quad.Quad{
+		quad.MakeIRI("a", "b", "c", "g1"),
+		quad.MakeIRI("a", "b", "d", "g2"),
+	}, quads)
+
+	resp, err = http.Post(srv.URL+"/api/v2/write?label=%22g", "application/n-quads", bytes.NewBufferString(data))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestV2ACL(t *testing.T) {
+	h := makeHandle(t, quad.MakeIRI("a", "b", "c", "g2"))
+	defer h.Close()
+	// writes must go through the writer of the handle
+	commits := 0
+	hooks := new(graph.Hooks)
+	hooks.AfterCommit(func([]graph.Delta) { commits++ })
+	h = &graph.Handle{QuadStore: h.QuadStore, QuadWriter: graph.NewHookWriter(h.QuadStore, h.QuadWriter, hooks)}
+	api := NewAPIv2(h)
+	api.SetACL(acl.NewPolicy(
+		acl.Grant{Role: "r1", Labels: []quad.Value{quad.IRI("g1")}, Access: acl.ReadWrite},
+		acl.Grant{Role: "r2", Labels: []quad.Value{quad.IRI("g2")}, Access: acl.Read},
+	))
+	api.SetRolesHeader("X-Roles")
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	do := func(method, path, roles, data string) (int, string) {
+		req, err := http.NewRequest(method, srv.URL+path, bytes.NewBufferString(data))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/n-quads")
+		if roles != "" {
+			req.Header.Set("X-Roles", roles)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, buf.String()
+	}
+
+	code, _ := do("POST", "/api/v2/write", "r1", "<a> <b> <d> <g1> .\n")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 1, commits)
+	code, _ = do("POST", "/api/v2/write", "r1, r2", "<a> <b> <e> <g2> .\n")
+	require.Equal(t, http.StatusForbidden, code)
+	require.Equal(t, 1, commits)
+	code, _ = do("POST", "/api/v2/write", "", "<a> <b> <e> .\n")
+	require.Equal(t, http.StatusForbidden, code)
+
+	code, body := do("GET", "/api/v2/read", "r1", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "<a> <b> <d> <g1> .\n", body)
+	code, body = do("GET", "/api/v2/read", "r1,r2", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 2, strings.Count(body, "\n"))
+	code, body = do("GET", "/api/v2/read", "", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "", body)
+
+	quads, err := quad.ReadAll(graph.NewQuadStoreReader(h.QuadStore))
+	require.NoError(t, err)
+	require.Len(t, quads, 2)
+}
+
 func TestV2Read(t *testing.T) {
 	expect := graphtest.MakeQuadSet()
 	addr, closer := makeServerV2(t, expect...)
@@ -64,3 +158,233 @@ func TestV2Read(t *testing.T) {
 	sort.Sort(quad.ByQuadString(expect))
 	require.Equal(t, expect, quads)
 }
+
+func TestV2ReadFrame(t *testing.T) {
+	addr, closer := makeServerV2(t, quad.MakeIRI("alice", "follows", "bob", ""))
+	defer closer()
+
+	for _, c := range []struct {
+		name  string
+		query string
+	}{
+		{name: "invalid frame", query: "format=jsonld&frame=" + url.QueryEscape("{")},
+		{name: "unsupported format", query: "format=nquads&frame=" + url.QueryEscape(`{"@type": "Person"}`)},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			resp, err := http.Get(addr + "/api/v2/read?" + c.query)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
+
+func TestV2ReadRedact(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.Make(quad.IRI("alice"), quad.IRI("phone"), "+1 555 1234", nil),
+		quad.MakeIRI("alice", "follows", "fred", "private"),
+	)
+	defer h.Close()
+	api := NewAPIv2(h)
+	api.SetRedaction(redact.New(redact.Rules{
+		Mask:          []quad.Value{quad.IRI("phone")},
+		ExcludeLabels: []quad.Value{quad.IRI("private")},
+	}))
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	qr, err := client.New(srv.URL).QuadReader()
+	require.NoError(t, err)
+	defer qr.Close()
+	quads, err := quad.ReadAll(qr)
+	require.NoError(t, err)
+	sort.Sort(quad.ByQuadString(quads))
+	require.Equal(t, []quad.Quad{
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.Make(quad.IRI("alice"), quad.IRI("phone"), redact.DefaultMask, nil),
+	}, quads)
+}
+
+func TestV2WriteStream(t *testing.T) {
+	h := makeHandle(t)
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	quads := graphtest.MakeQuadSet()
+	buf := bytes.NewBuffer(nil)
+	qw := nquads.NewWriter(buf)
+	_, err := quad.Copy(qw, quad.NewReader(quads))
+	require.NoError(t, err)
+	require.NoError(t, qw.Close())
+
+	resp, err := http.Post(srv.URL+"/api/v2/write/stream?batch=5", "application/n-quads", buf)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var last writeProgress
+	lines := 0
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		require.NoError(t, dec.Decode(&last))
+		require.Empty(t, last.Error)
+		lines++
+	}
+	require.True(t, last.Done)
+	require.Equal(t, len(quads), last.Count)
+	require.True(t, lines > 1, "expected progress lines")
+}
+
+func TestV2WriteStreamSource(t *testing.T) {
+	quads := graphtest.MakeQuadSet()
+	buf := bytes.NewBuffer(nil)
+	qw := nquads.NewWriter(buf)
+	_, err := quad.Copy(qw, quad.NewReader(quads))
+	require.NoError(t, err)
+	require.NoError(t, qw.Close())
+	data := buf.Bytes()
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer src.Close()
+
+	h := makeHandle(t)
+	defer h.Close()
+	api := NewAPIv2(h)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	post := func() *http.Response {
+		req, err := http.NewRequest("POST", srv.URL+"/api/v2/write/stream?source="+src.URL+"/data.nq", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	resp := post()
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode, "admin API is disabled")
+
+	api.SetAdminToken("secret")
+	resp = post()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var last writeProgress
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		require.NoError(t, dec.Decode(&last))
+		require.Empty(t, last.Error)
+	}
+	require.True(t, last.Done)
+	require.Equal(t, len(quads), last.Count)
+}
+
+func TestV2Capabilities(t *testing.T) {
+	h := makeHandle(t)
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v2/capabilities")
+	require.NoError(t, err)
+	var c model.Capabilities
+	err = json.NewDecoder(resp.Body).Decode(&c)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, c.Transactions)
+	require.True(t, c.ConditionalWrites)
+	require.False(t, c.DeltaLog)
+
+	resp, err = http.Get(srv.URL + "/api/v2/log")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+}
+
+func TestV2QueryExplain(t *testing.T) {
+	addr, closer := makeServerV2(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", ""),
+	)
+	defer closer()
+
+	resp, err := http.Post(addr+"/api/v2/query?lang=gizmo&explain=true", "application/javascript",
+		strings.NewReader(`g.V("<alice>").Out("<follows>").All()`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var out struct {
+		Result  []map[string]string `json:"result"`
+		Explain []struct {
+			Shape    map[string]interface{} `json:"shape"`
+			Iterator struct {
+				Type string `json:"type"`
+				Next int64  `json:"next"`
+			} `json:"iterator"`
+		} `json:"explain"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	require.Equal(t, []map[string]string{{"id": "<bob>"}}, out.Result)
+	require.Len(t, out.Explain, 1)
+	require.Equal(t, "NodesFrom", out.Explain[0].Shape["type"])
+	require.NotEmpty(t, out.Explain[0].Iterator.Type)
+	require.True(t, out.Explain[0].Iterator.Next > 0)
+}
+
+func TestV2QueryAt(t *testing.T) {
+	qs, err := graph.NewQuadStore("btree", "", graph.Options{"delta_log": true})
+	require.NoError(t, err)
+	wr, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	h := &graph.Handle{qs, wr}
+	defer h.Close()
+	require.NoError(t, wr.AddQuad(quad.MakeIRI("alice", "follows", "bob", "")))
+	require.NoError(t, wr.AddQuad(quad.MakeIRI("alice", "follows", "fred", "")))
+	require.NoError(t, wr.RemoveQuad(quad.MakeIRI("alice", "follows", "bob", "")))
+
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	query := func(at string) (int, []map[string]string) {
+		resp, err := http.Post(srv.URL+"/api/v2/query?lang=gizmo&at="+at, "application/javascript",
+			strings.NewReader(`g.V("<alice>").Out("<follows>").All()`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out struct {
+			Result []map[string]string `json:"result"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		}
+		return resp.StatusCode, out.Result
+	}
+	code, res := query("1")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []map[string]string{{"id": "<bob>"}}, res)
+	code, res = query("3")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []map[string]string{{"id": "<fred>"}}, res)
+	code, res = query("0")
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, res)
+
+	code, _ = query("4")
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = query("yesterday")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+type tempError struct{}
+
+func (tempError) Error() string   { return "not a leader" }
+func (tempError) Temporary() bool { return true }
+
+func TestWriteErrorCode(t *testing.T) {
+	require.Equal(t, http.StatusServiceUnavailable, writeErrorCode(tempError{}))
+	require.Equal(t, http.StatusInternalServerError, writeErrorCode(errors.New("failed")))
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cayleygraph/cayley/internal/audit"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// Limits of the audit log search.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 10000
+)
+
+// SetAuditLog sets an audit log that can be searched with the admin API.
+func (api *APIv2) SetAuditLog(l *audit.Log) {
+	api.audit = l
+}
+
+// parseAuditTime parses a time in RFC 3339 format, or a duration relative to the current time.
+func parseAuditTime(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %q", s)
+	}
+	return t, nil
+}
+
+// auditFilter reads search parameters of the audit log from the request.
+func auditFilter(r *http.Request) (audit.Filter, error) {
+	f := audit.Filter{
+		User:  r.FormValue("user"),
+		Role:  r.FormValue("role"),
+		Path:  r.FormValue("path"),
+		Lang:  r.FormValue("lang"),
+		Text:  r.FormValue("text"),
+		Limit: defaultAuditLimit,
+	}
+	var err error
+	if s := r.FormValue("since"); s != "" {
+		if f.Since, err = parseAuditTime(s); err != nil {
+			return f, err
+		}
+	}
+	if s := r.FormValue("until"); s != "" {
+		if f.Until, err = parseAuditTime(s); err != nil {
+			return f, err
+		}
+	}
+	if s := r.FormValue("failed"); s != "" {
+		if f.Failed, err = strconv.ParseBool(s); err != nil {
+			return f, err
+		}
+	}
+	if s := r.FormValue("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return f, fmt.Errorf("invalid limit: %q", s)
+		}
+		f.Limit = n
+	}
+	if f.Limit > maxAuditLimit {
+		f.Limit = maxAuditLimit
+	}
+	return f, nil
+}
+
+// ServeAuditLog searches the audit log. Entries are returned newest first.
+func (api *APIv2) ServeAuditLog(w http.ResponseWriter, r *http.Request) {
+	if api.audit == nil {
+		jsonResponse(w, http.StatusNotFound, "audit log is disabled")
+		return
+	}
+	f, err := auditFilter(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	entries, err := api.audit.Search(r.Context(), f)
+	if err == audit.ErrNotSearchable {
+		jsonResponse(w, http.StatusNotImplemented, err)
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	if entries == nil {
+		entries = []model.AuditEntry{}
+	}
+	writeJSON(w, http.StatusOK, model.AuditEntryList{Entries: entries})
+}
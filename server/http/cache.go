@@ -0,0 +1,177 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cayleygraph/cayley/internal/lru"
+)
+
+// DefaultCacheSize is the number of entries in the result cache if the size is not specified.
+const DefaultCacheSize = 1000
+
+// ParseCacheSpec parses a description of the result cache in the form "lru,size=N".
+// It returns zero for an empty string, which disables the cache.
+func ParseCacheSpec(spec string) (int, error) {
+	if spec == "" {
+		return 0, nil
+	}
+	parts := strings.Split(spec, ",")
+	if typ := strings.TrimSpace(parts[0]); typ != "lru" {
+		return 0, fmt.Errorf("unsupported cache type: %q", typ)
+	}
+	size := DefaultCacheSize
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) != 2 || kv[0] != "size" {
+			return 0, fmt.Errorf("unsupported cache option: %q", p)
+		}
+		n, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid cache size: %v", err)
+		} else if n <= 0 {
+			return 0, fmt.Errorf("cache size must be positive: %d", n)
+		}
+		size = n
+	}
+	return size, nil
+}
+
+// ResultCache stores results of queries for a single version of the graph.
+// All entries are dropped as soon as the version changes.
+type ResultCache struct {
+	mu  sync.Mutex
+	lru *lru.Cache
+	ver string
+}
+
+// NewResultCache creates a cache that holds results of up to a given number of queries.
+func NewResultCache(size int) *ResultCache {
+	return &ResultCache{lru: lru.New(size)}
+}
+
+// get returns a cached value for the version of the graph, if any.
+func (c *ResultCache) get(ver, key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ver != c.ver {
+		c.lru.Purge()
+		c.ver = ver
+		return nil, false
+	}
+	return c.lru.Get(key)
+}
+
+// put stores a value computed for the version of the graph. Values for older versions are discarded.
+func (c *ResultCache) put(ver, key string, v interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ver != c.ver {
+		return
+	}
+	c.lru.Put(key, v)
+}
+
+// cachedResponse is a response of a query language that writes results directly to the client.
+type cachedResponse struct {
+	header http.Header
+	body   []byte
+}
+
+func (c *cachedResponse) writeTo(w http.ResponseWriter) {
+	for k, v := range c.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(c.body)
+}
+
+// responseRecorder copies a response written to the client, so it can be cached.
+type responseRecorder struct {
+	http.ResponseWriter
+	code int
+	buf  bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	w.buf.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *responseRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// response returns a copy of the recorded response, or nil if the request failed.
+func (w *responseRecorder) response() *cachedResponse {
+	if w.code != http.StatusOK {
+		return nil
+	}
+	h := make(http.Header, len(w.Header()))
+	for k, v := range w.Header() {
+		h[k] = append([]string{}, v...)
+	}
+	return &cachedResponse{header: h, body: w.buf.Bytes()}
+}
+
+// SetResultCache enables caching of query results. Results are cached for a single version of
+// the graph, thus the cache requires the change feed to detect writes made through the API.
+// Writes made to the backend by other processes are only detected if they change the number of quads.
+func (api *APIv2) SetResultCache(c *ResultCache) {
+	api.cache = c
+}
+
+// cacheVersion returns a version of the graph for cache entries, or an empty string if results
+// cannot be cached.
+func (api *APIv2) cacheVersion() string {
+	if api.cache == nil || api.feed == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", api.feed.Horizon(), api.h.QuadStore.Size())
+}
+
+// cacheKey returns a key for results of a query. Query text is normalized by removing leading
+// and trailing whitespace; parameters, roles and negotiated format are included as is.
+func (api *APIv2) cacheKey(r *http.Request, qu string) string {
+	vals := r.URL.Query()
+	vals.Del("qu")
+	conf := api.conf()
+	roles := ""
+	if conf.rolesHeader != "" {
+		roles = r.Header.Get(conf.rolesHeader)
+	}
+	return strings.Join([]string{
+		r.URL.Path, vals.Encode(), r.Header.Get(hdrAccept), roles,
+		strconv.Itoa(conf.limit), strings.TrimSpace(qu),
+	}, "\x00")
+}
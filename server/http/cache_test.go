@@ -0,0 +1,122 @@
+package cayleyhttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCacheSpec(t *testing.T) {
+	for _, c := range []struct {
+		spec string
+		size int
+		err  bool
+	}{
+		{spec: "", size: 0},
+		{spec: "lru", size: DefaultCacheSize},
+		{spec: "lru,size=10", size: 10},
+		{spec: "lru, size=5", size: 5},
+		{spec: "lfu,size=10", err: true},
+		{spec: "lru,size=0", err: true},
+		{spec: "lru,size=x", err: true},
+		{spec: "lru,ttl=1s", err: true},
+	} {
+		size, err := ParseCacheSpec(c.spec)
+		if c.err {
+			require.Error(t, err, "%q", c.spec)
+			continue
+		}
+		require.NoError(t, err, "%q", c.spec)
+		require.Equal(t, c.size, size, "%q", c.spec)
+	}
+}
+
+func TestResultCache(t *testing.T) {
+	h := makeHandle(t)
+	defer h.Close()
+	feed, err := graph.NewChangeFeed(h.QuadStore, 10)
+	require.NoError(t, err)
+	defer feed.Close()
+	require.NoError(t, h.AddQuad(quad.MakeIRI("a", "b", "c", "")))
+
+	// counts executions and returns the number of quads in the store
+	calls := 0
+	query.RegisterLanguage(query.Language{
+		Name: "cache-test",
+		HTTPQuery: func(ctx context.Context, qs graph.QuadStore, w query.ResponseWriter, r io.Reader) {
+			calls++
+			data, _ := ioutil.ReadAll(r)
+			if string(data) == "fail" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, "%d", qs.Size())
+		},
+	})
+
+	api := NewAPIv2(h)
+	api.SetChangeFeed(feed)
+	api.SetResultCache(NewResultCache(10))
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, "%s", rec.Body.String())
+		return rec
+	}
+
+	t.Run("gizmo", func(t *testing.T) {
+		const path = "/api/v2/query?lang=gizmo"
+		rec := do(path, `g.V().All()`)
+		require.Contains(t, rec.Body.String(), `u003cc`)
+		ver := api.cacheVersion()
+		req := httptest.NewRequest("POST", path, nil)
+		_, ok := api.cache.get(ver, api.cacheKey(req, ` g.V().All() `))
+		require.True(t, ok, "result is not cached")
+
+		rec2 := do(path, "\n"+`g.V().All()`)
+		require.Equal(t, rec.Body.String(), rec2.Body.String())
+
+		// writes invalidate the cache
+		require.NoError(t, h.AddQuad(quad.MakeIRI("a", "b", "d", "")))
+		rec = do(path, `g.V().All()`)
+		require.Contains(t, rec.Body.String(), `u003cd`)
+	})
+	t.Run("http query", func(t *testing.T) {
+		const path = "/api/v2/query?lang=cache-test"
+		calls = 0
+		rec := do(path, "q")
+		rec2 := do(path, "q")
+		require.Equal(t, 1, calls)
+		require.Equal(t, rec.Body.String(), rec2.Body.String())
+
+		// other parameters are cached separately
+		do(path+"&x=1", "q")
+		require.Equal(t, 2, calls)
+
+		require.NoError(t, h.AddQuad(quad.MakeIRI("a", "b", "e", "")))
+		rec = do(path, "q")
+		require.Equal(t, 3, calls)
+		require.NotEqual(t, rec.Body.String(), rec2.Body.String())
+
+		// failed requests are not cached
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest("POST", path, strings.NewReader("fail"))
+			w := httptest.NewRecorder()
+			api.ServeHTTP(w, req)
+			require.Equal(t, http.StatusBadRequest, w.Code)
+		}
+		require.Equal(t, 5, calls)
+	})
+}
@@ -0,0 +1,197 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+const (
+	hdrLastEventID = "Last-Event-ID"
+	// changesKeepAlive is an interval for sending comments to keep idle connections open.
+	changesKeepAlive = 15 * time.Second
+)
+
+// SetChangeFeed sets a feed used by the changes endpoint. The feed must be created for the QuadStore of the API.
+func (api *APIv2) SetChangeFeed(f *graph.ChangeFeed) {
+	api.feed = f
+}
+
+// changePattern builds a quad pattern from "sub", "pred", "obj" and "label" request parameters.
+// It returns nil if none of them are set.
+func changePattern(r *http.Request) ([]quad.Quad, error) {
+	var (
+		p   quad.Quad
+		set bool
+	)
+	for _, f := range []struct {
+		name string
+		dir  quad.Direction
+	}{
+		{"sub", quad.Subject},
+		{"pred", quad.Predicate},
+		{"obj", quad.Object},
+		{"label", quad.Label},
+	} {
+		s := r.FormValue(f.name)
+		if s == "" {
+			continue
+		}
+		v, err := model.ParseValue(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value: %v", f.name, err)
+		}
+		p.Set(f.dir, v)
+		set = true
+	}
+	if !set {
+		return nil, nil
+	}
+	return []quad.Quad{p}, nil
+}
+
+// ServeChanges streams applied deltas as Server-Sent Events.
+//
+// Clients may resume the stream by passing a horizon of the last seen change in "since"
+// parameter or in Last-Event-ID header. If neither is set, only new changes are sent.
+// Event IDs are only sent once all changes of a transaction were sent, thus resuming from
+// the last event ID never skips a part of a transaction.
+// Changes can be restricted to quads matching a pattern set by "sub", "pred", "obj" and "label" parameters.
+func (api *APIv2) ServeChanges(w http.ResponseWriter, r *http.Request) {
+	if api.feed == nil {
+		jsonResponse(w, http.StatusNotImplemented, "change feed is not enabled")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		jsonResponse(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	since := api.feed.Horizon()
+	s := r.Header.Get(hdrLastEventID)
+	if v := r.FormValue("since"); v != "" {
+		s = v
+	}
+	if s != "" {
+		h, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || h < 0 {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid horizon: %q", s))
+			return
+		}
+		since = h
+	}
+	patterns, err := changePattern(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if !api.feed.Available(since) {
+		jsonResponse(w, http.StatusGone, graph.ErrHorizonExpired)
+		return
+	}
+	// changes are not read through the QuadStore, thus they are checked against the policy here
+	conf := api.conf()
+	roles := RequestRoles(r, conf.rolesHeader)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	changes := api.feed.Changes(since)
+
+	type event struct {
+		graph.Change
+		visible bool // change matches the pattern and is allowed by the policy
+		last    bool // last change of the transaction
+	}
+	events := make(chan event)
+	go func() {
+		defer close(events)
+		for changes.Next(ctx) {
+			e := event{Change: changes.Result(), last: changes.Last()}
+			e.visible = (patterns == nil || graph.MatchesAny(e.Quad, patterns)) &&
+				(conf.acl == nil || conf.acl.Allowed(roles, e.Quad.Label, acl.Read))
+			if !e.visible && !e.last {
+				continue
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	w.Header().Set(hdrContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	tick := time.NewTicker(changesKeepAlive)
+	defer tick.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				if err := changes.Err(); err != nil && err != context.Canceled {
+					data, _ := json.Marshal(model.Error{Error: err.Error()})
+					fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+					flusher.Flush()
+				}
+				return
+			}
+			var id string
+			if e.last {
+				id = fmt.Sprintf("id: %d\n", e.Horizon)
+			}
+			if !e.visible {
+				// only advance the event ID of the client
+				if _, err := fmt.Fprintf(w, "%s\n", id); err != nil {
+					return
+				}
+				flusher.Flush()
+				continue
+			}
+			data, err := json.Marshal(model.Change{
+				Action:    e.Action.String(),
+				Quad:      model.NewQuad(e.Quad),
+				Horizon:   e.Horizon,
+				Timestamp: e.Timestamp,
+			})
+			if err != nil {
+				return
+			}
+			if _, err = fmt.Fprintf(w, "%sevent: %s\ndata: %s\n\n", id, e.Action, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-tick.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
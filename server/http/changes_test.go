@@ -0,0 +1,105 @@
+package cayleyhttp
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http/model"
+	"github.com/cayleygraph/cayley/writer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangesFeed(t *testing.T) {
+	h := makeHandle(t)
+	defer h.Close()
+	feed, err := graph.NewChangeFeed(h.QuadStore, 10)
+	require.NoError(t, err)
+	defer feed.Close()
+
+	api := NewAPIv2(h)
+	api.SetChangeFeed(feed)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	qw, err := writer.NewSingle(h.QuadStore, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true})
+	require.NoError(t, err)
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("a", "b", "c", "")))
+	// ignored deltas are not published
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("a", "b", "c", "")))
+	require.NoError(t, qw.RemoveQuad(quad.MakeIRI("a", "b", "c", "")))
+
+	resp, err := http.Get(srv.URL + "/api/v2/changes?since=0")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get(hdrContentType))
+
+	var (
+		got []model.Change
+		ids []string
+	)
+	sc := bufio.NewScanner(resp.Body)
+	for len(got) < 2 && sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimPrefix(line, "id: "))
+		}
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var c model.Change
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &c))
+		got = append(got, c)
+	}
+	require.NoError(t, sc.Err())
+	require.Len(t, got, 2)
+	require.Equal(t, "add", got[0].Action)
+	require.Equal(t, "delete", got[1].Action)
+	require.Equal(t, int64(3), got[1].Horizon)
+	require.Equal(t, "<a>", got[1].Quad.Subject)
+	require.Equal(t, []string{"1", "3"}, ids)
+}
+
+func TestChangesFeedPattern(t *testing.T) {
+	h := makeHandle(t)
+	defer h.Close()
+	feed, err := graph.NewChangeFeed(h.QuadStore, 10)
+	require.NoError(t, err)
+	defer feed.Close()
+
+	api := NewAPIv2(h)
+	api.SetChangeFeed(feed)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	require.NoError(t, h.AddQuad(quad.MakeIRI("a", "b", "c", "")))
+	require.NoError(t, h.AddQuad(quad.MakeIRI("x", "y", "z", "")))
+
+	resp, err := http.Get(srv.URL + "/api/v2/changes?since=0&pred=%3Cy%3E")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	sc := bufio.NewScanner(resp.Body)
+	var c model.Change
+	for sc.Scan() {
+		if line := sc.Text(); strings.HasPrefix(line, "data: ") {
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &c))
+			break
+		}
+	}
+	require.NoError(t, sc.Err())
+	require.Equal(t, "<x>", c.Quad.Subject)
+	require.Equal(t, int64(2), c.Horizon)
+
+	resp, err = http.Get(srv.URL + "/api/v2/changes?pred=%22unterminated")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// hdrNextCursor is set on paged responses of the read endpoint if more results are available.
+const hdrNextCursor = "X-Next-Cursor"
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursor is a position in the stream of quads matching a specific filter.
+//
+// For stores implementing graph.QuadSeeker it keeps a position of the last returned quad, which remains valid
+// across server restarts and while other quads are added or removed. Other stores are scanned from the start
+// again, thus the cursor keeps the last returned quad and the number of quads returned so far.
+type cursor struct {
+	Filter string `json:"f,omitempty"` // filter the cursor was created for
+	Pos    []byte `json:"p,omitempty"` // position returned by graph.QuadSeeker
+	Last   string `json:"q,omitempty"` // last returned quad in N-Quads notation
+	Offset int    `json:"n,omitempty"` // number of quads returned before the page
+}
+
+func (c cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c cursor
+	if err = json.Unmarshal(data, &c); err != nil || (c.Pos == nil && c.Last == "") {
+		return nil, errInvalidCursor
+	}
+	return &c, nil
+}
+
+// quadFilter builds a quad pattern from request parameters.
+// It also returns a string representation of the filter to bind cursors to it.
+func quadFilter(r *http.Request) (quad.Quad, string, error) {
+	var (
+		filter quad.Quad
+		key    []string
+	)
+	for _, p := range []struct {
+		name string
+		dir  quad.Direction
+	}{
+		{"sub", quad.Subject},
+		{"pred", quad.Predicate},
+		{"obj", quad.Object},
+		{"label", quad.Label},
+	} {
+		s := r.FormValue(p.name)
+		if s == "" {
+			continue
+		}
+		v, err := model.ParseValue(s)
+		if err != nil {
+			return quad.Quad{}, "", fmt.Errorf("invalid %s value: %v", p.name, err)
+		}
+		filter.Set(p.dir, v)
+		key = append(key, p.name+"="+v.String())
+	}
+	return filter, strings.Join(key, "&"), nil
+}
+
+// filterShape converts a quad pattern to a shape. Nil directions match any value.
+func filterShape(filter quad.Quad) shape.Quads {
+	var s shape.Quads
+	for _, d := range quad.Directions {
+		if v := filter.Get(d); v != nil {
+			s = append(s, shape.QuadFilter{Dir: d, Values: shape.Lookup{v}})
+		}
+	}
+	return s
+}
+
+// readPage reads up to limit quads matching the filter, starting after the cursor position.
+// It returns a cursor for the next page, or nil if there are no more results.
+//
+// Stores implementing graph.QuadSeeker continue from the position of the last returned quad. Other stores are
+// scanned up to the last returned quad; if it was removed, the scan continues after the number of quads returned
+// before it, which may skip or repeat quads if other quads of previous pages were changed as well.
+func readPage(ctx context.Context, qs graph.QuadStore, filter quad.Quad, fkey string, after *cursor, limit int) ([]quad.Quad, *cursor, error) {
+	if after != nil && after.Filter != fkey {
+		return nil, nil, errors.New("cursor was issued for a different filter")
+	}
+	var (
+		it     graph.Iterator
+		seeker graph.QuadSeeker
+		offset int
+	)
+	if s, ok := qs.(graph.QuadSeeker); ok && (after == nil || after.Pos != nil) {
+		seeker = s
+		var pos []byte
+		if after != nil {
+			pos = after.Pos
+		}
+		it = s.SeekQuads(filter, pos)
+	} else {
+		it = shape.BuildIterator(qs, filterShape(filter))
+	}
+	defer it.Close()
+	if seeker == nil && after != nil {
+		offset = after.Offset
+		if err := skipTo(ctx, qs, it, after); err != nil {
+			return nil, nil, err
+		}
+	}
+	var (
+		out  []quad.Quad
+		last graph.Value
+	)
+	for (limit <= 0 || len(out) < limit) && it.Next(ctx) {
+		last = it.Result()
+		out = append(out, qs.Quad(last))
+	}
+	if err := it.Err(); err == graph.ErrInvalidPosition {
+		return nil, nil, errInvalidCursor
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if len(out) == 0 || limit <= 0 || len(out) < limit || !it.Next(ctx) {
+		return out, nil, it.Err()
+	}
+	next := &cursor{Filter: fkey}
+	if seeker != nil {
+		next.Pos = seeker.QuadPosition(last)
+	} else {
+		next.Last, next.Offset = out[len(out)-1].NQuad(), offset+len(out)
+	}
+	return out, next, nil
+}
+
+// skipTo advances the iterator past the last quad returned by the cursor. If the quad no longer exists,
+// the iterator is reset and advanced past the quads returned before it, assuming they were not changed.
+func skipTo(ctx context.Context, qs graph.QuadStore, it graph.Iterator, after *cursor) error {
+	for it.Next(ctx) {
+		if qs.Quad(it.Result()).NQuad() == after.Last {
+			return nil
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	it.Reset()
+	for i := 0; i < after.Offset-1 && it.Next(ctx); i++ {
+	}
+	return it.Err()
+}
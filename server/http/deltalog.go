@@ -0,0 +1,147 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// maxLogRange is the maximal number of transactions returned by a single request to the delta log.
+const maxLogRange = 1000
+
+func newLogEntry(e graph.LogEntry) model.LogEntry {
+	out := model.LogEntry{
+		Horizon:   e.Horizon,
+		Timestamp: e.Timestamp,
+		Deltas:    make([]model.Delta, 0, len(e.Deltas)),
+	}
+	for _, d := range e.Deltas {
+		out.Deltas = append(out.Deltas, model.Delta{Action: d.Action.String(), Quad: model.NewQuad(d.Quad)})
+	}
+	return out
+}
+
+func parseHorizon(s string) (int64, error) {
+	h, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || h < 0 {
+		return 0, fmt.Errorf("invalid horizon: %q", s)
+	}
+	return h, nil
+}
+
+// ServeHorizon returns the current horizon of the database.
+func (api *APIv2) ServeHorizon(w http.ResponseWriter, r *http.Request) {
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	cur, err := graph.Horizon(r.Context(), h.QuadStore)
+	if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, err)
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, model.Horizon{Horizon: cur})
+}
+
+// ServeLogEntries lists transactions recorded in the delta log with horizons in range (from, to].
+// If "to" is not set, the current horizon is used. Ranges longer than maxLogRange are truncated.
+func (api *APIv2) ServeLogEntries(w http.ResponseWriter, r *http.Request) {
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if !graph.CapabilitiesOf(h.QuadStore).DeltaLog {
+		jsonResponse(w, http.StatusNotImplemented, "delta log is not enabled")
+		return
+	}
+	var from, to int64
+	if s := r.FormValue("from"); s != "" {
+		if from, err = parseHorizon(s); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if s := r.FormValue("to"); s != "" {
+		to, err = parseHorizon(s)
+	} else {
+		to, err = graph.Horizon(r.Context(), h.QuadStore)
+	}
+	if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, err)
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	var out model.LogEntryList
+	if to-from > maxLogRange {
+		to = from + maxLogRange
+		out.Next = to
+	}
+	list, err := graph.LogEntries(r.Context(), h.QuadStore, from, to)
+	if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, "delta log is not enabled")
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	out.Entries = make([]model.LogEntry, 0, len(list))
+	for _, e := range list {
+		out.Entries = append(out.Entries, newLogEntry(e))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// ServeLogEntry returns quads added and removed by a transaction with a given horizon.
+func (api *APIv2) ServeLogEntry(w http.ResponseWriter, r *http.Request) {
+	hz, err := parseHorizon(strings.TrimPrefix(r.URL.Path, "/api/v2/log/"))
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if !graph.CapabilitiesOf(h.QuadStore).DeltaLog {
+		jsonResponse(w, http.StatusNotImplemented, "delta log is not enabled")
+		return
+	}
+	e, err := graph.LogEntryAt(r.Context(), h.QuadStore, hz)
+	if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, "delta log is not enabled")
+		return
+	} else if err == graph.ErrNoLogEntry {
+		jsonResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newLogEntry(*e))
+}
@@ -0,0 +1,82 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Encoder wraps a response writer to compress the data. Close must flush all buffered data.
+type Encoder func(w io.Writer) io.WriteCloser
+
+var encoders = struct {
+	sync.RWMutex
+	byName map[string]Encoder
+}{byName: make(map[string]Encoder)}
+
+// RegisterEncoding registers a response compression method for a given Accept-Encoding token.
+//
+// Only gzip and deflate are available by default; other methods (like zstd) can be
+// registered by the binary that links a corresponding compression library.
+func RegisterEncoding(name string, enc Encoder) {
+	encoders.Lock()
+	encoders.byName[name] = enc
+	encoders.Unlock()
+}
+
+func getEncoder(name string) Encoder {
+	encoders.RLock()
+	defer encoders.RUnlock()
+	return encoders.byName[name]
+}
+
+func init() {
+	RegisterEncoding("gzip", func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	})
+	RegisterEncoding("deflate", func(w io.Writer) io.WriteCloser {
+		zw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return zw
+	})
+}
+
+// sortAccept sorts accept specs by quality, preserving the order of specs with the same quality.
+func sortAccept(specs []AcceptSpec) []AcceptSpec {
+	sort.SliceStable(specs, func(i, j int) bool {
+		return specs[i].Q > specs[j].Q
+	})
+	return specs
+}
+
+// writerFrom selects a response compression according to the accept header with a given name.
+// Returned writer must be closed to flush compressed data.
+func writerFrom(w http.ResponseWriter, r *http.Request, acceptName string) io.WriteCloser {
+	w.Header().Add("Vary", acceptName)
+	for _, s := range sortAccept(ParseAccept(r.Header, acceptName)) {
+		if s.Q <= 0 {
+			break
+		}
+		if enc := getEncoder(s.Value); enc != nil {
+			w.Header().Set(hdrContentEncoding, s.Value)
+			return enc(w)
+		}
+	}
+	return nopWriteCloser{Writer: w}
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	hdrETag        = "ETag"
+	hdrIfNoneMatch = "If-None-Match"
+)
+
+// instanceID distinguishes generations of the database between server restarts,
+// since the change feed horizon starts from zero on each start.
+var instanceID = func() string {
+	var b [6]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}()
+
+// etagFor computes an entity tag for a response to a read-only request.
+//
+// Tag is based on the change feed horizon and the number of quads in the store, thus it changes
+// on every write made through the API. Writes made to the backend by other processes are only
+// detected if they change the number of quads. ETags are not supported if the change feed is not set.
+func (api *APIv2) etagFor(r *http.Request) string {
+	if api.feed == nil {
+		return ""
+	}
+	h := sha256.New()
+	// response depends on parameters and on the negotiated format
+	fmt.Fprintf(h, "%s\n%s\n%s", r.URL.Path, r.URL.RawQuery, r.Header.Get(hdrAccept))
+	sum := hex.EncodeToString(h.Sum(nil)[:8])
+	return fmt.Sprintf(`W/"%s-%d-%d-%s"`, instanceID, api.feed.Horizon(), api.h.QuadStore.Size(), sum)
+}
+
+// notModified sets an ETag for GET requests and responds with 304 if the client already has
+// an up-to-date version of the response. It returns true if the response was written.
+func (api *APIv2) notModified(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "GET" {
+		return false
+	}
+	tag := api.etagFor(r)
+	if tag == "" {
+		return false
+	}
+	w.Header().Set(hdrETag, tag)
+	// clients may cache responses, but should always revalidate them
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatch(r.Header.Get(hdrIfNoneMatch), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// etagMatch checks if the tag is listed in If-None-Match header using weak comparison.
+func etagMatch(header, tag string) bool {
+	if header == "" {
+		return false
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
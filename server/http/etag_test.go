@@ -0,0 +1,64 @@
+package cayleyhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETag(t *testing.T) {
+	h := makeHandle(t)
+	defer h.Close()
+	feed, err := graph.NewChangeFeed(h.QuadStore, 10)
+	require.NoError(t, err)
+	defer feed.Close()
+	require.NoError(t, h.AddQuad(quad.MakeIRI("a", "b", "c", "")))
+
+	api := NewAPIv2(h)
+	api.SetChangeFeed(feed)
+
+	do := func(method, path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`g.V().All()`))
+		if etag != "" {
+			req.Header.Set(hdrIfNoneMatch, etag)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, path := range []string{
+		"/api/v2/read?format=nquads",
+		"/api/v2/query?lang=gizmo&qu=" + url.QueryEscape(`g.V().All()`),
+	} {
+		t.Run(path, func(t *testing.T) {
+			rec := do("GET", path, "")
+			require.Equal(t, http.StatusOK, rec.Code)
+			etag := rec.Header().Get(hdrETag)
+			require.NotEmpty(t, etag)
+
+			rec = do("GET", path, etag)
+			require.Equal(t, http.StatusNotModified, rec.Code)
+			require.Empty(t, rec.Body.String())
+
+			// other parameters produce a different tag
+			rec = do("GET", path+"&x=1", etag)
+			require.NotEqual(t, http.StatusNotModified, rec.Code)
+
+			require.NoError(t, h.AddQuad(quad.MakeIRI("a", "b", path, "")))
+			rec = do("GET", path, etag)
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.NotEqual(t, etag, rec.Header().Get(hdrETag))
+		})
+	}
+
+	// queries sent with POST are not cached
+	rec := do("POST", "/api/v2/query?lang=gizmo", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get(hdrETag))
+}
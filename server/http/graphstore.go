@@ -0,0 +1,301 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+)
+
+// RegisterGraphStoreOn registers handlers for SPARQL 1.1 Graph Store HTTP Protocol.
+//
+// Graphs are identified indirectly, either by "graph" parameter with an IRI of the named graph,
+// or by "default" parameter for the default graph. Named graphs are mapped to quad labels.
+func (api *APIv2) RegisterGraphStoreOn(r *httprouter.Router, wrappers ...HandlerWrapper) {
+	const path = "/sparql/graph"
+	r.GET(path, wrap(api.ServeGraphStore, wrappers))
+	r.HEAD(path, wrap(api.ServeGraphStore, wrappers))
+	r.PUT(path, wrap(api.ServeGraphStore, wrappers))
+	r.POST(path, wrap(api.ServeGraphStore, wrappers))
+	r.DELETE(path, wrap(api.ServeGraphStore, wrappers))
+}
+
+// graphLabel returns a label of the graph requested by the client.
+// Default graph is represented by a nil label.
+func graphLabel(r *http.Request) (quad.Value, error) {
+	vals := r.URL.Query()
+	_, def := vals["default"]
+	name := vals.Get("graph")
+	if def && name != "" {
+		return nil, errors.New("either graph or default parameter should be set, not both")
+	} else if def {
+		return nil, nil
+	} else if name == "" {
+		return nil, errors.New("graph is not specified")
+	}
+	return quad.IRI(name), nil
+}
+
+// graphIterator returns an iterator over quads of the graph with a specific label.
+func graphIterator(qs graph.QuadStore, label quad.Value) graph.Iterator {
+	var s shape.Shape = shape.Quads{{Dir: quad.Label, Values: shape.DefaultGraph{}}}
+	if label != nil {
+		s = shape.Quads{{Dir: quad.Label, Values: shape.Lookup{label}}}
+	}
+	return shape.BuildIterator(qs, s)
+}
+
+// graphExists checks if the graph with a specific label has any quads.
+func graphExists(ctx context.Context, qs graph.QuadStore, label quad.Value) (bool, error) {
+	it := graphIterator(qs, label)
+	defer it.Close()
+	ok := it.Next(ctx)
+	return ok, it.Err()
+}
+
+// clearGraph removes all quads of the graph with a specific label in batches and returns the number of removed quads.
+// Each batch is read with a new iterator, since removed quads may invalidate iterators of some backends.
+func clearGraph(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, label quad.Value, batch int) (int, error) {
+	n := 0
+	for {
+		tx := graph.NewTransaction()
+		it := graphIterator(qs, label)
+		for len(tx.Deltas) < batch && it.Next(ctx) {
+			tx.RemoveQuad(qs.Quad(it.Result()))
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return n, err
+		} else if len(tx.Deltas) == 0 {
+			return n, nil
+		}
+		if err = qw.ApplyTransaction(tx); err != nil {
+			return n, err
+		}
+		n += len(tx.Deltas)
+	}
+}
+
+// writeGraph adds quads to a graph with a specific label in batches and returns the number of written quads.
+// Quads that already exist are skipped: duplicates within the request are removed before writing,
+// and quads existing in the store are filtered out only if the QuadWriter rejects duplicates.
+func writeGraph(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, label quad.Value, quads []quad.Quad, batch int) (int, error) {
+	seen := make(map[quad.Quad]struct{}, len(quads))
+	uniq := quads[:0]
+	for _, q := range quads {
+		q.Label = label
+		if _, ok := seen[q]; ok {
+			continue
+		}
+		seen[q] = struct{}{}
+		uniq = append(uniq, q)
+	}
+	n := 0
+	for len(uniq) != 0 {
+		cur := uniq
+		if len(cur) > batch {
+			cur = cur[:batch]
+		}
+		uniq = uniq[len(cur):]
+		tx := graph.NewTransactionN(len(cur))
+		for _, q := range cur {
+			tx.AddQuad(q)
+		}
+		err := qw.ApplyTransaction(tx)
+		if graph.IsQuadExist(err) {
+			tx, err = missingQuads(ctx, qs, cur)
+			if err == nil && len(tx.Deltas) != 0 {
+				err = qw.ApplyTransaction(tx)
+			}
+		}
+		if err != nil {
+			return n, err
+		}
+		n += len(tx.Deltas)
+	}
+	return n, nil
+}
+
+// missingQuads returns a transaction that adds quads that do not exist in the store.
+func missingQuads(ctx context.Context, qs graph.QuadStore, quads []quad.Quad) (*graph.Transaction, error) {
+	tx := graph.NewTransactionN(len(quads))
+	for _, q := range quads {
+		err := graph.CheckPreconditions(ctx, qs, []graph.Precondition{{Quad: q}})
+		if err == nil {
+			continue // already exists
+		} else if err != graph.ErrPreconditionFailed {
+			return nil, err
+		}
+		tx.AddQuad(q)
+	}
+	return tx, nil
+}
+
+// ServeGraphStore implements operations of SPARQL 1.1 Graph Store HTTP Protocol.
+//
+// The request body is parsed before the graph is modified, but quads are written in batches,
+// the same way bulk loading works, thus a failed write may leave the graph partially updated.
+// PUT removes the graph before writing new quads.
+func (api *APIv2) ServeGraphStore(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	label, err := graphLabel(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	switch r.Method {
+	case "PUT", "POST", "DELETE":
+		if api.conf().ro {
+			jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+			return
+		}
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	batch := api.batch
+	if batch <= 0 {
+		batch = quad.DefaultBatch
+	}
+	ctx, cancel := api.queryContext(r)
+	defer cancel()
+	// writes are neither limited by the query timeout, nor canceled when the client disconnects,
+	// thus the graph is not left half-replaced
+	wctx := query.WithParallel(context.Background(), api.conf().parallel)
+	switch r.Method {
+	case "GET", "HEAD":
+		exists, err := graphExists(ctx, h.QuadStore, label)
+		if err != nil {
+			jsonResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !exists && label != nil {
+			jsonResponse(w, http.StatusNotFound, "graph not found")
+			return
+		}
+		api.serveGraphRead(w, r, h.QuadStore, label, batch)
+	case "DELETE":
+		exists, err := graphExists(wctx, h.QuadStore, label)
+		if err != nil {
+			jsonResponse(w, http.StatusInternalServerError, err)
+			return
+		} else if !exists {
+			jsonResponse(w, http.StatusNotFound, "graph not found")
+			return
+		}
+		n, err := clearGraph(wctx, h.QuadStore, h.QuadWriter, label, batch)
+		GetRequestInfo(r).SetQuads(n)
+		if err != nil {
+			jsonResponse(w, writeErrorCode(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "PUT", "POST":
+		format := getFormat(r, "", hdrContentType)
+		if format == nil || format.Reader == nil {
+			jsonResponse(w, http.StatusUnsupportedMediaType, errors.New("format is not supported for reading data"))
+			return
+		}
+		rd, err := readerFrom(r, hdrContentEncoding)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		defer rd.Close()
+		qr := format.Reader(rd)
+		defer qr.Close()
+		quads, err := quad.ReadAll(qr)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		for _, q := range quads {
+			if !q.IsValid() {
+				jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid quad: %v", q))
+				return
+			}
+		}
+
+		exists, err := graphExists(wctx, h.QuadStore, label)
+		if err != nil {
+			jsonResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+		n := 0
+		if r.Method == "PUT" && exists {
+			n, err = clearGraph(wctx, h.QuadStore, h.QuadWriter, label, batch)
+			if err != nil {
+				GetRequestInfo(r).SetQuads(n)
+				jsonResponse(w, writeErrorCode(err), err)
+				return
+			}
+		}
+		added, err := writeGraph(wctx, h.QuadStore, h.QuadWriter, label, quads, batch)
+		GetRequestInfo(r).SetQuads(n + added)
+		if err != nil {
+			jsonResponse(w, writeErrorCode(err), err)
+			return
+		}
+		if !exists {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		jsonResponse(w, http.StatusMethodNotAllowed, fmt.Errorf("unsupported method: %s", r.Method))
+	}
+}
+
+func (api *APIv2) serveGraphRead(w http.ResponseWriter, r *http.Request, qs graph.QuadStore, label quad.Value, batch int) {
+	format := getFormat(r, "format", hdrAccept)
+	if format == nil || format.Writer == nil {
+		jsonResponse(w, http.StatusNotAcceptable, errors.New("format is not supported for writing data"))
+		return
+	}
+	if len(format.Mime) != 0 {
+		w.Header().Set(hdrContentType, format.Mime[0])
+	}
+	if r.Method == "HEAD" {
+		return
+	}
+	qr := graph.NewResultReader(qs, graphIterator(qs, label))
+	defer qr.Close()
+	wr := writerFrom(w, r, hdrAcceptEncoding)
+	defer wr.Close()
+	qw := format.Writer(wr)
+	defer qw.Close()
+	var err error
+	if bw, ok := qw.(quad.BatchWriter); ok {
+		_, err = quad.CopyBatch(bw, qr, batch)
+	} else {
+		_, err = quad.Copy(qw, qr)
+	}
+	if err != nil {
+		clog.Errorf("graph store: read quads error: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package cayleyhttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphStore(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", "g1"),
+	)
+	defer h.Close()
+	api := NewAPIv2(h)
+	api.SetBatchSize(1) // check that multiple batches are applied
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	do := func(method, graph, body string) (int, string) {
+		addr := srv.URL + "/sparql/graph?default"
+		if graph != "" {
+			addr = srv.URL + "/sparql/graph?graph=" + url.QueryEscape(graph)
+		}
+		req, err := http.NewRequest(method, addr, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set(hdrContentType, "application/n-triples")
+		req.Header.Set(hdrAccept, "application/n-quads")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+	lines := func(s string) []string {
+		arr := strings.Split(strings.TrimSpace(s), "\n")
+		sort.Strings(arr)
+		return arr
+	}
+
+	code, body := do("GET", "g1", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"<bob> <follows> <fred> <g1> ."}, lines(body))
+
+	code, body = do("GET", "", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"<alice> <follows> <bob> ."}, lines(body))
+
+	code, _ = do("GET", "g2", "")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, _ = do("PUT", "g2", "<a> <b> <c> .\n")
+	require.Equal(t, http.StatusCreated, code)
+
+	code, _ = do("POST", "g2", "<a> <b> <c> .\n<a> <b> <d> .\n")
+	require.Equal(t, http.StatusNoContent, code)
+
+	code, body = do("GET", "g2", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"<a> <b> <c> <g2> .", "<a> <b> <d> <g2> ."}, lines(body))
+
+	code, _ = do("PUT", "g2", "<a> <b> <d> .\n<x> <y> <z> .\n<x> <y> <z> .\n")
+	require.Equal(t, http.StatusNoContent, code)
+
+	code, body = do("GET", "g2", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"<a> <b> <d> <g2> .", "<x> <y> <z> <g2> ."}, lines(body))
+
+	// graph is not changed if the body is invalid
+	code, _ = do("PUT", "g2", "<a> <b> <e> .\n<x> <y>\n")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, body = do("GET", "g2", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"<a> <b> <d> <g2> .", "<x> <y> <z> <g2> ."}, lines(body))
+
+	code, _ = do("DELETE", "g2", "")
+	require.Equal(t, http.StatusNoContent, code)
+
+	code, _ = do("GET", "g2", "")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, _ = do("DELETE", "g2", "")
+	require.Equal(t, http.StatusNotFound, code)
+}
+
+func TestGraphStoreDuplicates(t *testing.T) {
+	qs := memstore.New(quad.MakeIRI("a", "b", "c", "g"))
+	// writer rejects duplicates
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	api := NewAPIv2(&graph.Handle{QuadStore: qs, QuadWriter: qw})
+	api.SetBatchSize(1)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	req, err := http.NewRequest("POST", srv.URL+"/sparql/graph?graph=g",
+		strings.NewReader("<a> <b> <d> .\n<a> <b> <c> .\n<a> <b> <d> .\n"))
+	require.NoError(t, err)
+	req.Header.Set(hdrContentType, "application/n-triples")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	got, err := quad.ReadAll(graph.NewQuadStoreReader(qs))
+	require.NoError(t, err)
+	sort.Sort(quad.ByQuadString(got))
+	require.Equal(t, []quad.Quad{
+		quad.MakeIRI("a", "b", "c", "g"),
+		quad.MakeIRI("a", "b", "d", "g"),
+	}, got)
+}
@@ -0,0 +1,170 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+const defaultHealthTimeout = 5 * time.Second
+
+// Health serves liveness and readiness probes for the server.
+//
+// The server is considered live as soon as it can answer HTTP requests,
+// and ready only when a database handle was set and the backend responds to a ping.
+type Health struct {
+	mu       sync.RWMutex
+	h        *graph.Handle
+	backend  string
+	wtyp     string
+	ro       bool
+	timeout  time.Duration
+	draining bool
+	replica  ReplicaStatusFunc
+}
+
+// NewHealth creates a health handler in an initializing state.
+// It will report as not ready until SetHandle is called.
+func NewHealth() *Health {
+	return &Health{wtyp: "single", timeout: defaultHealthTimeout}
+}
+
+// SetHandle sets a database handle and marks the server as initialized.
+func (s *Health) SetHandle(h *graph.Handle) {
+	s.mu.Lock()
+	s.h = h
+	s.mu.Unlock()
+}
+func (s *Health) SetBackend(name string) {
+	s.mu.Lock()
+	s.backend = name
+	s.mu.Unlock()
+}
+func (s *Health) SetReplication(wtyp string) {
+	s.mu.Lock()
+	s.wtyp = wtyp
+	s.mu.Unlock()
+}
+
+// SetReplicaStatus sets a function that reports the state of replication, if the server is a read replica.
+func (s *Health) SetReplicaStatus(fnc ReplicaStatusFunc) {
+	s.mu.Lock()
+	s.replica = fnc
+	s.mu.Unlock()
+}
+
+func (s *Health) SetReadOnly(ro bool) {
+	s.mu.Lock()
+	s.ro = ro
+	s.mu.Unlock()
+}
+func (s *Health) SetTimeout(dt time.Duration) {
+	s.mu.Lock()
+	s.timeout = dt
+	s.mu.Unlock()
+}
+
+// SetDraining marks the server as shutting down. It will report as not ready from now on,
+// so load balancers can stop sending new requests while in-flight requests are finishing.
+func (s *Health) SetDraining() {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+}
+
+func (s *Health) RegisterOn(r *httprouter.Router, wrappers ...HandlerWrapper) {
+	r.GET("/healthz", wrap(s.ServeLive, wrappers))
+	r.GET("/readyz", wrap(s.ServeReady, wrappers))
+}
+
+const (
+	statusOK           = "ok"
+	statusInitializing = "initializing"
+	statusUnavailable  = "unavailable"
+	statusDraining     = "draining"
+)
+
+type healthStatus struct {
+	Status      string `json:"status"`
+	Backend     string `json:"backend,omitempty"`
+	Replication string `json:"replication,omitempty"`
+	ReadOnly    bool   `json:"read_only"`
+	Error       string `json:"error,omitempty"`
+
+	Replica *model.ReplicaStatus `json:"replica,omitempty"`
+}
+
+func writeHealth(w http.ResponseWriter, code int, st healthStatus) {
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(st)
+}
+
+// ServeLive reports that the process is running and can serve HTTP requests.
+func (s *Health) ServeLive(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthStatus{Status: statusOK})
+}
+
+// ServeReady reports if the server is initialized and the backend is reachable.
+// It responds with 503 if the server is still initializing, shutting down or the backend ping failed.
+func (s *Health) ServeReady(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	h, timeout, draining := s.h, s.timeout, s.draining
+	st := healthStatus{
+		Backend:     s.backend,
+		Replication: s.wtyp,
+		ReadOnly:    s.ro,
+	}
+	replica := s.replica
+	s.mu.RUnlock()
+	if replica != nil {
+		rs := replica()
+		st.Replica = &rs
+	}
+	if draining {
+		st.Status = statusDraining
+		writeHealth(w, http.StatusServiceUnavailable, st)
+		return
+	}
+	if h == nil {
+		st.Status = statusInitializing
+		writeHealth(w, http.StatusServiceUnavailable, st)
+		return
+	}
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := graph.Ping(ctx, h.QuadStore); err != nil {
+		st.Status = statusUnavailable
+		st.Error = err.Error()
+		writeHealth(w, http.StatusServiceUnavailable, st)
+		return
+	}
+	st.Status = statusOK
+	writeHealth(w, http.StatusOK, st)
+}
@@ -0,0 +1,59 @@
+package cayleyhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+func TestHealth(t *testing.T) {
+	hs := NewHealth()
+	hs.SetBackend("memstore")
+
+	check := func(serve http.HandlerFunc, code int, status string) {
+		rec := httptest.NewRecorder()
+		serve(rec, httptest.NewRequest("GET", "/", nil))
+		require.Equal(t, code, rec.Code)
+		var st healthStatus
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&st))
+		require.Equal(t, status, st.Status)
+	}
+
+	check(hs.ServeLive, http.StatusOK, statusOK)
+	check(hs.ServeReady, http.StatusServiceUnavailable, statusInitializing)
+
+	h := makeHandle(t)
+	defer h.Close()
+	hs.SetHandle(h)
+
+	check(hs.ServeLive, http.StatusOK, statusOK)
+	check(hs.ServeReady, http.StatusOK, statusOK)
+
+	hs.SetDraining()
+	check(hs.ServeLive, http.StatusOK, statusOK)
+	check(hs.ServeReady, http.StatusServiceUnavailable, statusDraining)
+}
+
+func TestHealthReplica(t *testing.T) {
+	hs := NewHealth()
+	h := makeHandle(t)
+	defer h.Close()
+	hs.SetHandle(h)
+	hs.SetReplicaStatus(func() model.ReplicaStatus {
+		return model.ReplicaStatus{Primary: "http://primary", Horizon: 3, PrimaryHorizon: 5, Lag: 2}
+	})
+
+	rec := httptest.NewRecorder()
+	hs.ServeReady(rec, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var st healthStatus
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&st))
+	require.NotNil(t, st.Replica)
+	require.Equal(t, int64(2), st.Replica.Lag)
+	require.Equal(t, "http://primary", st.Replica.Primary)
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/history"
+)
+
+// StoreAt returns a read-only view of the QuadStore at a point set by the "at" request parameter,
+// which is either a horizon or an RFC3339 time. If the parameter is not set, the store is returned as-is.
+// The returned function releases the view. On error, the response is written and false is returned.
+func StoreAt(w http.ResponseWriter, r *http.Request, qs graph.QuadStore) (graph.QuadStore, func(), bool) {
+	s := r.URL.Query().Get("at")
+	if s == "" {
+		return qs, func() {}, true
+	}
+	ctx := r.Context()
+	cur, err := graph.Horizon(ctx, qs)
+	if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, "history is not supported by the backend")
+		return nil, nil, false
+	} else if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+	var h int64
+	if t, terr := time.Parse(time.RFC3339, s); terr == nil {
+		h, err = history.HorizonAt(ctx, qs, t)
+	} else if h, err = strconv.ParseInt(s, 10, 64); err != nil || h < 0 || h > cur {
+		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid value for at: %q", s))
+		return nil, nil, false
+	}
+	var view graph.QuadStore
+	if err == nil {
+		view, err = history.AsOf(ctx, qs, h)
+	}
+	if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, "delta log is not enabled")
+		return nil, nil, false
+	} else if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+	return view, func() { view.Close() }, true
+}
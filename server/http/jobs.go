@@ -0,0 +1,218 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// maxFinishedJobs is the number of finished jobs kept in memory.
+const maxFinishedJobs = 100
+
+type job struct {
+	model.Job
+	cancel func()
+}
+
+// jobs is a registry of asynchronous query jobs.
+type jobs struct {
+	mu    sync.Mutex
+	byID  map[string]*job
+	order []string
+}
+
+func newJobID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+func (s *jobs) add(j *job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byID == nil {
+		s.byID = make(map[string]*job)
+	}
+	s.byID[j.ID] = j
+	s.order = append(s.order, j.ID)
+	// forget oldest finished jobs
+	finished := 0
+	for _, id := range s.order {
+		if s.byID[id].Status != model.JobRunning {
+			finished++
+		}
+	}
+	for i := 0; i < len(s.order) && finished > maxFinishedJobs; {
+		id := s.order[i]
+		if s.byID[id].Status == model.JobRunning {
+			i++
+			continue
+		}
+		delete(s.byID, id)
+		s.order = append(s.order[:i], s.order[i+1:]...)
+		finished--
+	}
+}
+
+func (s *jobs) get(id string) (model.Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.byID[id]
+	if !ok {
+		return model.Job{}, false
+	}
+	return j.Job, true
+}
+
+func (s *jobs) list() []model.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]model.Job, 0, len(s.order))
+	for _, id := range s.order {
+		j := s.byID[id].Job
+		j.Result = nil
+		out = append(out, j)
+	}
+	return out
+}
+
+func (s *jobs) finish(id string, res interface{}, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.byID[id]
+	if !ok || j.Status != model.JobRunning {
+		return
+	}
+	now := time.Now()
+	j.Finished = &now
+	if err != nil {
+		j.Status = model.JobFailed
+		j.Error = err.Error()
+	} else {
+		j.Status = model.JobDone
+		j.Result = res
+	}
+}
+
+func (s *jobs) cancel(id string) (model.Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.byID[id]
+	if !ok {
+		return model.Job{}, false
+	}
+	if j.Status == model.JobRunning {
+		j.cancel()
+		now := time.Now()
+		j.Finished = &now
+		j.Status = model.JobCanceled
+	}
+	return j.Job, true
+}
+
+func jobID(r *http.Request) string {
+	return strings.TrimPrefix(r.URL.Path, "/api/v2/jobs/")
+}
+
+// ServeCreateJob starts a query in background and returns a job descriptor.
+func (api *APIv2) ServeCreateJob(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req model.QueryRequest
+	if err := readJSON(r, &req); err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	l, err := queryLanguage(req.Lang)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	} else if req.Query == "" {
+		jsonResponse(w, http.StatusBadRequest, "query is empty")
+		return
+	}
+	conf := api.conf()
+	limit := conf.limit
+	if req.Limit > 0 && (limit <= 0 || req.Limit < limit) {
+		limit = req.Limit
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	// job outlives the request, thus it cannot use request context
+	var (
+		ctx    context.Context
+		cancel func()
+	)
+	if conf.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), conf.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	ctx = query.WithParallel(ctx, conf.parallel)
+	j := &job{
+		Job: model.Job{
+			ID:      newJobID(),
+			Status:  model.JobRunning,
+			Request: req,
+			Created: time.Now(),
+		},
+		cancel: cancel,
+	}
+	resp := j.Job
+	api.jobs.add(j)
+	ctx, done := api.trackQuery(ctx, model.ActiveQuery{Lang: req.Lang, Query: req.Query, Remote: r.RemoteAddr, Job: j.ID})
+	go func() {
+		defer cancel()
+		defer done()
+		res, err := execQuery(ctx, h.QuadStore, l, req.Query, limit, conf.limits)
+		api.jobs.finish(j.ID, res, err)
+	}()
+	writeJSON(w, http.StatusAccepted, resp)
+}
+
+func (api *APIv2) ServeListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, model.JobList{Jobs: api.jobs.list()})
+}
+
+func (api *APIv2) ServeGetJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := api.jobs.get(jobID(r))
+	if !ok {
+		jsonResponse(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+func (api *APIv2) ServeCancelJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := api.jobs.cancel(jobID(r))
+	if !ok {
+		jsonResponse(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
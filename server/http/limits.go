@@ -0,0 +1,52 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"net/http"
+
+	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// SetQueryLimits sets per-request execution limits for queries.
+func (api *APIv2) SetQueryLimits(l query.Limits) {
+	api.mu.Lock()
+	api.settings.limits = l
+	api.mu.Unlock()
+}
+
+// SetQueryParallel enables parallel execution of queries, buffering up to n results for each branch.
+// Zero value disables parallel execution. See query.WithParallel.
+func (api *APIv2) SetQueryParallel(n int) {
+	api.mu.Lock()
+	api.settings.parallel = n
+	api.mu.Unlock()
+}
+
+// WriteLimitError writes a structured response if err is an execution limit error.
+// It returns false if the error is of a different kind and nothing was written.
+func WriteLimitError(w http.ResponseWriter, err error) bool {
+	e, ok := err.(*query.LimitError)
+	if !ok {
+		return false
+	}
+	writeJSON(w, http.StatusRequestEntityTooLarge, model.LimitError{
+		Error: e.Error(),
+		Limit: e.Limit,
+		Max:   e.Max,
+	})
+	return true
+}
@@ -0,0 +1,74 @@
+package cayleyhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+	"github.com/cayleygraph/cayley/server/http/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLimits(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", ""),
+		quad.MakeIRI("bob", "status", "cool", ""),
+	)
+	defer h.Close()
+	api := NewAPIv2(h)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	run := func(qu string, code int) *model.LimitError {
+		resp, err := http.Get(srv.URL + "/api/v2/query?lang=gizmo&qu=" + url.QueryEscape(qu))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode)
+		if code != http.StatusRequestEntityTooLarge {
+			return nil
+		}
+		var e model.LimitError
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&e))
+		return &e
+	}
+
+	run(`g.V().All()`, http.StatusOK)
+
+	api.SetQueryLimits(query.Limits{MaxResults: 2})
+	e := run(`g.V().All()`, http.StatusRequestEntityTooLarge)
+	require.Equal(t, query.LimitResults, e.Limit)
+	require.Equal(t, int64(2), e.Max)
+	run(`g.V("<alice>").Out("<follows>").All()`, http.StatusOK)
+
+	api.SetQueryLimits(query.Limits{MaxQuads: 1})
+	e = run(`g.V().Out("<follows>").All()`, http.StatusRequestEntityTooLarge)
+	require.Equal(t, query.LimitQuads, e.Limit)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+	)
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	run := func(timeout string, code int) {
+		resp, err := http.Get(srv.URL + "/api/v2/query?lang=gizmo&timeout=" + url.QueryEscape(timeout) +
+			"&qu=" + url.QueryEscape(`g.V().All()`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode, "timeout: %q", timeout)
+	}
+	run("10", http.StatusOK)
+	run("10s", http.StatusOK)
+	run("1ns", http.StatusRequestTimeout)
+	run("-1s", http.StatusBadRequest)
+	run("soon", http.StatusBadRequest)
+}
@@ -0,0 +1,320 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package model defines request and response objects of the HTTP API v2.
+//
+// All types in this package are described in the OpenAPI specification (docs/api/swagger.yml),
+// and the list of operations is checked against it when the server starts.
+package model
+
+import (
+	"time"
+
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/quad/nquads"
+)
+
+// Error is returned by all API methods in case of a failure.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// LimitError is returned when a query exceeds one of the execution limits set on the server.
+type LimitError struct {
+	Error string `json:"error"`
+	Limit string `json:"limit"` // results, memory or quads
+	Max   int64  `json:"max"`
+}
+
+// Quad is a JSON representation of a quad.
+//
+// Values are encoded in N-Quads notation, for example <iri>, _:bnode or "literal"^^<type>.
+type Quad struct {
+	Subject   string `json:"subject"`
+	Predicate string `json:"predicate"`
+	Object    string `json:"object"`
+	Label     string `json:"label,omitempty"`
+}
+
+// Value encodes a single value to N-Quads notation. Nil value is encoded as an empty string.
+func Value(v quad.Value) string {
+	if v == nil {
+		return ""
+	}
+	return v.String()
+}
+
+// ParseValue decodes a value from N-Quads notation. Empty string is decoded as nil.
+func ParseValue(s string) (quad.Value, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return nquadsFormat.UnmarshalValue([]byte(s))
+}
+
+var nquadsFormat = quad.FormatByName("nquads")
+
+// NewQuad converts a quad to a JSON representation.
+func NewQuad(q quad.Quad) Quad {
+	return Quad{
+		Subject:   Value(q.Subject),
+		Predicate: Value(q.Predicate),
+		Object:    Value(q.Object),
+		Label:     Value(q.Label),
+	}
+}
+
+// Quad converts a JSON representation back to a quad.
+func (q Quad) Quad() (quad.Quad, error) {
+	var out quad.Quad
+	for i, str := range [...]string{q.Subject, q.Predicate, q.Object, q.Label} {
+		v, err := ParseValue(str)
+		if err != nil {
+			return quad.Quad{}, err
+		}
+		out.Set(quad.Directions[i], v)
+	}
+	return out, nil
+}
+
+// QuadList is a list of quads.
+type QuadList struct {
+	Quads []Quad `json:"quads"`
+	// Next is an opaque cursor for the next page of results. It is empty on the last page.
+	Next string `json:"next,omitempty"`
+}
+
+// NodeList is a list of nodes.
+type NodeList struct {
+	Nodes []string `json:"nodes"`
+}
+
+// WalkList is a list of random walks. Each walk is a list of visited nodes.
+type WalkList struct {
+	Walks [][]string `json:"walks"`
+}
+
+// Node describes a single node with all quads that refer to it.
+type Node struct {
+	ID  string `json:"id"`
+	In  []Quad `json:"in"`
+	Out []Quad `json:"out"`
+}
+
+// Namespace is an RDF namespace registered on the server.
+type Namespace struct {
+	Prefix string `json:"prefix"`
+	IRI    string `json:"iri"`
+}
+
+// NamespaceList is a list of namespaces.
+type NamespaceList struct {
+	Namespaces []Namespace `json:"namespaces"`
+}
+
+// QueryRequest describes a query to run.
+type QueryRequest struct {
+	Lang  string `json:"lang"`
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// QueryResponse contains results of the query.
+type QueryResponse struct {
+	Result interface{} `json:"result"`
+}
+
+// JobStatus is a state of an asynchronous job.
+type JobStatus string
+
+const (
+	JobRunning  = JobStatus("running")
+	JobDone     = JobStatus("done")
+	JobFailed   = JobStatus("failed")
+	JobCanceled = JobStatus("canceled")
+)
+
+// Job is an asynchronous query execution.
+type Job struct {
+	ID       string       `json:"id"`
+	Status   JobStatus    `json:"status"`
+	Request  QueryRequest `json:"request"`
+	Created  time.Time    `json:"created"`
+	Finished *time.Time   `json:"finished,omitempty"`
+	Result   interface{}  `json:"result,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// JobList is a list of jobs.
+type JobList struct {
+	Jobs []Job `json:"jobs"`
+}
+
+// Change is a single delta applied to the database, sent by the change feed.
+type Change struct {
+	Action    string    `json:"action"` // "add" or "delete"
+	Quad      Quad      `json:"quad"`
+	Horizon   int64     `json:"horizon"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Delta is a single change of a quad.
+type Delta struct {
+	Action string `json:"action"` // "add" or "delete"
+	Quad   Quad   `json:"quad"`
+}
+
+// LogEntry is a set of deltas applied to the database by a single transaction.
+type LogEntry struct {
+	Horizon   int64     `json:"horizon"` // also serves as a transaction ID
+	Timestamp time.Time `json:"timestamp"`
+	Deltas    []Delta   `json:"deltas"`
+}
+
+// LogEntryList is a list of delta log entries.
+type LogEntryList struct {
+	Entries []LogEntry `json:"entries"`
+	// Next is a horizon to pass as "from" to get the next page, if the range was truncated.
+	Next int64 `json:"next,omitempty"`
+}
+
+// Horizon is the current horizon of the database.
+type Horizon struct {
+	Horizon int64 `json:"horizon"`
+}
+
+// Capabilities lists optional features supported by the database backend.
+type Capabilities struct {
+	ReadOnly          bool `json:"read_only"`          // write methods are disabled
+	Transactions      bool `json:"transactions"`       // writes are applied atomically
+	RegexPushdown     bool `json:"regex_pushdown"`     // regular expressions are evaluated by the backend
+	SortPushdown      bool `json:"sort_pushdown"`      // results are sorted by the backend
+	ChangeFeed        bool `json:"change_feed"`        // backend streams changes applied by all writers
+	HorizonReads      bool `json:"horizon_reads"`      // backend can read data pinned to a horizon
+	ConditionalWrites bool `json:"conditional_writes"` // backend tracks the horizon of writes
+	DeltaLog          bool `json:"delta_log"`          // backend keeps a log of transactions
+	Provenance        bool `json:"provenance"`         // backend records provenance of quads
+	Metadata          bool `json:"metadata"`           // backend persists metadata records, such as namespaces
+	TextSearch        bool `json:"text_search"`        // database maintains a full-text index
+	GeoSearch         bool `json:"geo_search"`         // database maintains a geospatial index
+	VectorSearch      bool `json:"vector_search"`      // database maintains a nearest neighbours index
+}
+
+// TextIndex describes the state of a full-text index.
+type TextIndex struct {
+	Type       string   `json:"type"`
+	Predicates []string `json:"predicates"` // predicates with indexed string literals
+	Documents  int64    `json:"documents"`  // number of indexed nodes
+}
+
+// WriteResult is returned by methods that modify the data.
+type WriteResult struct {
+	Result string `json:"result"`
+	Count  int    `json:"count"`
+}
+
+// Transaction is a set of operations applied atomically.
+type Transaction struct {
+	// Require lists conditions that must hold for the transaction to be applied.
+	Require []Precondition `json:"require,omitempty"`
+	// Horizon, if set, applies the transaction only if the database was not modified after this horizon.
+	Horizon *int64 `json:"horizon,omitempty"`
+	Ops     []TxOp `json:"ops"`
+}
+
+// TxOp is a single operation of a transaction.
+type TxOp struct {
+	Action string `json:"action"` // add, delete, add_unique, replace_object or delete_matching
+	Quad   Quad   `json:"quad"`
+}
+
+// Precondition requires a quad to exist in the database, or to be absent from it if Absent is set.
+type Precondition struct {
+	Quad   Quad `json:"quad"`
+	Absent bool `json:"absent,omitempty"`
+}
+
+// Stats describes the state of the database.
+type Stats struct {
+	Size          int64 `json:"size"` // backend-specific estimate
+	Quads         int64 `json:"quads"`
+	Nodes         int64 `json:"nodes"`
+	Exact         bool  `json:"exact"` // quads and nodes are exact numbers
+	ActiveQueries int   `json:"active_queries"`
+	Horizon       int64 `json:"horizon,omitempty"` // horizon of the last change in the change feed
+	// Replica is set if the server is a read replica of another instance.
+	Replica *ReplicaStatus `json:"replica,omitempty"`
+}
+
+// ReplicaStatus describes the state of a read replica.
+type ReplicaStatus struct {
+	Primary        string    `json:"primary"`             // address of the primary instance
+	Horizon        int64     `json:"horizon"`             // horizon of the primary applied by the replica
+	PrimaryHorizon int64     `json:"primary_horizon"`     // last known horizon of the primary
+	Lag            int64     `json:"lag"`                 // number of transactions not applied yet
+	LagSeconds     float64   `json:"lag_seconds"`         // time since the replica was last in sync
+	LastSync       time.Time `json:"last_sync,omitempty"` // time when the replica was last in sync
+	Error          string    `json:"error,omitempty"`     // last replication error, if any
+}
+
+// PurgeResult describes records removed by a purge.
+type PurgeResult struct {
+	Quads      int64 `json:"quads"`       // records of deleted quads
+	LogEntries int64 `json:"log_entries"` // delta log entries
+	Bytes      int64 `json:"bytes,omitempty"`
+	DryRun     bool  `json:"dry_run,omitempty"` // records were only counted
+}
+
+// ActiveQuery is a query that is currently executed by the server.
+type ActiveQuery struct {
+	ID      string    `json:"id"`
+	Lang    string    `json:"lang"`
+	Query   string    `json:"query"`
+	Started time.Time `json:"started"`
+	Remote  string    `json:"remote,omitempty"`
+	Job     string    `json:"job,omitempty"` // set for queries started as asynchronous jobs
+}
+
+// ActiveQueryList is a list of active queries.
+type ActiveQueryList struct {
+	Queries []ActiveQuery `json:"queries"`
+}
+
+// AuditEntry is a record of the audit log describing who ran a query or a write and what was its outcome.
+type AuditEntry struct {
+	Time     time.Time           `json:"time"`
+	User     string              `json:"user,omitempty"`  // user name set by an authenticating proxy
+	Roles    []string            `json:"roles,omitempty"` // access control roles of the request
+	Remote   string              `json:"remote"`
+	Method   string              `json:"method"`
+	Path     string              `json:"path"`
+	Params   map[string][]string `json:"params,omitempty"` // URL parameters of the request
+	Lang     string              `json:"lang,omitempty"`
+	Query    string              `json:"query,omitempty"`
+	Results  *int                `json:"results,omitempty"` // number of returned results
+	Quads    *int                `json:"quads,omitempty"`   // number of written or deleted quads
+	Status   int                 `json:"status"`
+	Duration float64             `json:"duration_ms"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// AuditEntryList is a list of audit log entries.
+type AuditEntryList struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+// Result is returned by maintenance methods that has no other output.
+type Result struct {
+	Result string `json:"result"`
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+// Operation describes a single method of HTTP API.
+type Operation struct {
+	ID     string // operationId in the OpenAPI specification
+	Method string
+	Path   string // path in the OpenAPI notation
+	Write  bool   // operation modifies the database
+	Admin  bool   // operation requires admin access
+}
+
+// Operations lists all methods of HTTP API v2 described in the OpenAPI specification.
+var Operations = []Operation{
+	{ID: "listFormats", Method: "GET", Path: "/api/v2/formats"},
+	{ID: "getCapabilities", Method: "GET", Path: "/api/v2/capabilities"},
+	{ID: "readQuads", Method: "GET", Path: "/api/v2/read"},
+	{ID: "writeQuads", Method: "POST", Path: "/api/v2/write", Write: true},
+	{ID: "writeQuadsStream", Method: "POST", Path: "/api/v2/write/stream", Write: true},
+	{ID: "deleteNode", Method: "POST", Path: "/api/v2/node/delete", Write: true},
+	{ID: "deleteQuads", Method: "POST", Path: "/api/v2/delete", Write: true},
+	{ID: "applyTransaction", Method: "POST", Path: "/api/v2/tx", Write: true},
+	{ID: "query", Method: "GET", Path: "/api/v2/query"},
+	{ID: "changes", Method: "GET", Path: "/api/v2/changes"},
+	{ID: "getHorizon", Method: "GET", Path: "/api/v2/horizon"},
+	{ID: "listLogEntries", Method: "GET", Path: "/api/v2/log"},
+	{ID: "getLogEntry", Method: "GET", Path: "/api/v2/log/{horizon}"},
+
+	{ID: "listQuads", Method: "GET", Path: "/api/v2/quads"},
+	{ID: "listNodes", Method: "GET", Path: "/api/v2/nodes"},
+	{ID: "getNode", Method: "GET", Path: "/api/v2/node"},
+	{ID: "sampleNodes", Method: "GET", Path: "/api/v2/sample"},
+	{ID: "randomWalks", Method: "GET", Path: "/api/v2/walks"},
+	{ID: "listNamespaces", Method: "GET", Path: "/api/v2/namespaces"},
+	{ID: "registerNamespace", Method: "POST", Path: "/api/v2/namespaces", Write: true, Admin: true},
+	{ID: "deleteNamespace", Method: "DELETE", Path: "/api/v2/namespaces/{prefix}", Write: true, Admin: true},
+	{ID: "runQuery", Method: "POST", Path: "/api/v2/queries"},
+	{ID: "listJobs", Method: "GET", Path: "/api/v2/jobs"},
+	{ID: "createJob", Method: "POST", Path: "/api/v2/jobs", Write: true},
+	{ID: "getJob", Method: "GET", Path: "/api/v2/jobs/{id}"},
+	{ID: "cancelJob", Method: "DELETE", Path: "/api/v2/jobs/{id}", Write: true},
+	{ID: "getSpec", Method: "GET", Path: "/api/v2/openapi.yml"},
+
+	{ID: "getStats", Method: "GET", Path: "/api/v2/admin/stats"},
+	{ID: "refreshStats", Method: "POST", Path: "/api/v2/admin/stats/refresh"},
+	{ID: "compact", Method: "POST", Path: "/api/v2/admin/compact"},
+	{ID: "purge", Method: "POST", Path: "/api/v2/admin/purge"},
+	{ID: "ensureIndexes", Method: "POST", Path: "/api/v2/admin/indexes"},
+	{ID: "getTextIndex", Method: "GET", Path: "/api/v2/admin/text"},
+	{ID: "rebuildTextIndex", Method: "POST", Path: "/api/v2/admin/text/rebuild"},
+	{ID: "backup", Method: "GET", Path: "/api/v2/admin/backup"},
+	{ID: "listActiveQueries", Method: "GET", Path: "/api/v2/admin/queries"},
+	{ID: "killQuery", Method: "DELETE", Path: "/api/v2/admin/queries/{id}"},
+	{ID: "searchAuditLog", Method: "GET", Path: "/api/v2/admin/audit"},
+
+	{ID: "sparqlQuery", Method: "GET", Path: "/sparql"},
+	{ID: "sparqlQueryPost", Method: "POST", Path: "/sparql"},
+	{ID: "graphStoreGet", Method: "GET", Path: "/sparql/graph"},
+	{ID: "graphStorePut", Method: "PUT", Path: "/sparql/graph", Write: true},
+	{ID: "graphStorePost", Method: "POST", Path: "/sparql/graph", Write: true},
+	{ID: "graphStoreDelete", Method: "DELETE", Path: "/sparql/graph", Write: true},
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// OpenAPISpec is a parsed OpenAPI specification of HTTP API.
+type OpenAPISpec struct {
+	data []byte
+	// path -> method -> operationId
+	paths map[string]map[string]string
+}
+
+// ParseOpenAPI parses an OpenAPI specification in YAML or JSON format.
+func ParseOpenAPI(data []byte) (*OpenAPISpec, error) {
+	var doc struct {
+		OpenAPI string                            `yaml:"openapi"`
+		Paths   map[string]map[string]interface{} `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	} else if doc.OpenAPI == "" {
+		return nil, fmt.Errorf("not an OpenAPI document")
+	}
+	s := &OpenAPISpec{data: data, paths: make(map[string]map[string]string, len(doc.Paths))}
+	for path, ops := range doc.Paths {
+		m := make(map[string]string, len(ops))
+		for method, op := range ops {
+			// path item may also contain common fields like parameters
+			if op, ok := op.(map[interface{}]interface{}); ok {
+				id, _ := op["operationId"].(string)
+				m[strings.ToUpper(method)] = id
+			}
+		}
+		s.paths[path] = m
+	}
+	return s, nil
+}
+
+// LoadOpenAPI reads and parses an OpenAPI specification from a file.
+func LoadOpenAPI(path string) (*OpenAPISpec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseOpenAPI(data)
+}
+
+// Check verifies that all operations are described in the specification.
+func (s *OpenAPISpec) Check(ops []model.Operation) error {
+	var errs []string
+	for _, op := range ops {
+		id, ok := s.paths[op.Path][op.Method]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s %s is not described", op.Method, op.Path))
+		} else if id != op.ID {
+			errs = append(errs, fmt.Sprintf("%s %s: expected operation %q, got %q", op.Method, op.Path, op.ID, id))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("api spec mismatch: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// SetSpec sets an OpenAPI specification served by the API.
+func (api *APIv2) SetSpec(spec *OpenAPISpec) {
+	api.spec = spec
+}
+
+// withServer returns the specification with a server list replaced by a single URL.
+func (s *OpenAPISpec) withServer(u string) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(s.data, &doc); err != nil {
+		return nil, err
+	}
+	servers := []yaml.MapSlice{{{Key: "url", Value: u}}}
+	found := false
+	for i := range doc {
+		if doc[i].Key == "servers" {
+			doc[i].Value = servers
+			found = true
+		}
+	}
+	if !found {
+		doc = append(doc, yaml.MapItem{Key: "servers", Value: servers})
+	}
+	return yaml.Marshal(doc)
+}
+
+// ServeSpec serves the specification with a server URL as seen by the client.
+func (api *APIv2) ServeSpec(w http.ResponseWriter, r *http.Request) {
+	if api.spec == nil {
+		jsonResponse(w, http.StatusNotFound, "API specification is not available")
+		return
+	}
+	data, err := api.spec.withServer(BaseURL(r).String())
+	if err != nil {
+		data = api.spec.data
+	}
+	w.Header().Set(hdrContentType, "application/x-yaml")
+	w.Write(data)
+}
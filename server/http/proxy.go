@@ -0,0 +1,100 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	hdrForwardedProto  = "X-Forwarded-Proto"
+	hdrForwardedHost   = "X-Forwarded-Host"
+	hdrForwardedPrefix = "X-Forwarded-Prefix"
+)
+
+// firstValue returns the first value of a comma-separated header, as set by a chain of proxies.
+func firstValue(s string) string {
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// BasePath returns a path prefix the server is available at, as seen by the client.
+// It is empty if the server is served from the root. Returned path never ends with a slash.
+//
+// The prefix is taken from X-Forwarded-Prefix header, that is either set by the reverse proxy
+// or by StripPrefix handler.
+func BasePath(r *http.Request) string {
+	p := strings.TrimRight(firstValue(r.Header.Get(hdrForwardedPrefix)), "/")
+	if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "\"'<> ") {
+		return ""
+	}
+	return p
+}
+
+// BaseURL returns an absolute URL of the server root, as seen by the client.
+// It respects X-Forwarded-Proto, X-Forwarded-Host and X-Forwarded-Prefix headers.
+func BaseURL(r *http.Request) *url.URL {
+	u := &url.URL{Scheme: "http", Host: r.Host, Path: BasePath(r)}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if s := strings.ToLower(firstValue(r.Header.Get(hdrForwardedProto))); s == "http" || s == "https" {
+		u.Scheme = s
+	}
+	if h := firstValue(r.Header.Get(hdrForwardedHost)); h != "" {
+		u.Host = h
+	}
+	return u
+}
+
+// StripPrefix serves all requests under a given path prefix with a handler, as if they were sent to the root.
+// The prefix is appended to X-Forwarded-Prefix header, so BasePath and BaseURL will return correct paths.
+func StripPrefix(prefix string, h http.Handler) http.Handler {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" {
+		return h
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, prefix)
+		if len(p) == len(r.URL.Path) || (p != "" && p[0] != '/') {
+			http.NotFound(w, r)
+			return
+		} else if p == "" {
+			u := *r.URL
+			u.Path = BasePath(r) + prefix + "/"
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+			return
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = ""
+		r2.Header = make(http.Header, len(r.Header))
+		for k, v := range r.Header {
+			r2.Header[k] = v
+		}
+		r2.Header.Set(hdrForwardedPrefix, BasePath(r)+prefix)
+		h.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,61 @@
+package cayleyhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripPrefix(t *testing.T) {
+	var (
+		path string
+		base string
+	)
+	h := StripPrefix("/cayley/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		base = BaseURL(r).String()
+	}))
+
+	serve := func(p string, hdr http.Header) int {
+		req := httptest.NewRequest("GET", "http://example.com"+p, nil)
+		for k, v := range hdr {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve("/cayley/api/v2/query", nil))
+	require.Equal(t, "/api/v2/query", path)
+	require.Equal(t, "http://example.com/cayley", base)
+
+	require.Equal(t, http.StatusOK, serve("/cayley/", http.Header{
+		hdrForwardedProto:  {"https"},
+		hdrForwardedHost:   {"graph.example.org, proxy.local"},
+		hdrForwardedPrefix: {"/outer/"},
+	}))
+	require.Equal(t, "/", path)
+	require.Equal(t, "https://graph.example.org/outer/cayley", base)
+
+	require.Equal(t, http.StatusMovedPermanently, serve("/cayley", nil))
+	require.Equal(t, http.StatusNotFound, serve("/cayleyx/", nil))
+	require.Equal(t, http.StatusNotFound, serve("/api/v2/query", nil))
+}
+
+func TestSpecServer(t *testing.T) {
+	spec, err := ParseOpenAPI([]byte("openapi: \"3.0.0\"\nservers:\n- url: http://localhost\npaths: {}\n"))
+	require.NoError(t, err)
+	api := NewAPIv2(makeHandle(t))
+	api.SetSpec(spec)
+
+	req := httptest.NewRequest("GET", "http://example.com/api/v2/openapi.yml", nil)
+	req.Header.Set(hdrForwardedPrefix, "/cayley")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "url: http://example.com/cayley")
+	require.NotContains(t, rec.Body.String(), "localhost")
+}
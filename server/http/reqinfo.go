@@ -0,0 +1,110 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cayleygraph/cayley/query"
+)
+
+// Error classes reported in RequestInfo.
+const (
+	ErrClassTimeout  = "timeout"
+	ErrClassCanceled = "canceled"
+	ErrClassLimit    = "limit"
+	ErrClassQuery    = "query"
+	ErrClassClient   = "client" // other 4xx responses
+	ErrClassServer   = "server" // other 5xx responses
+)
+
+// RequestInfo collects details about a request that are only known to API handlers, such as
+// a query language or a number of results. It is used for access and audit logging.
+//
+// All methods are safe to call on a nil RequestInfo.
+type RequestInfo struct {
+	Lang    string
+	Query   string // query text, if any
+	Results int    // number of returned results; negative if unknown
+	Quads   int    // number of written or deleted quads; negative if unknown
+	Error   string // error class; empty if request succeeded
+}
+
+// SetLang sets a query language used by the request.
+func (ri *RequestInfo) SetLang(lang string) {
+	if ri != nil {
+		ri.Lang = lang
+	}
+}
+
+// SetQuery sets a text of the query executed by the request.
+func (ri *RequestInfo) SetQuery(qu string) {
+	if ri != nil {
+		ri.Query = qu
+	}
+}
+
+// SetQuads sets a number of quads written or deleted by the request.
+func (ri *RequestInfo) SetQuads(n int) {
+	if ri != nil {
+		ri.Quads = n
+	}
+}
+
+// SetResults sets a number of results returned to the client.
+func (ri *RequestInfo) SetResults(n int) {
+	if ri != nil {
+		ri.Results = n
+	}
+}
+
+// SetError records a class of an error that interrupted the request.
+func (ri *RequestInfo) SetError(err error) {
+	if ri == nil || err == nil {
+		return
+	}
+	switch err.(type) {
+	case *query.LimitError:
+		ri.Error = ErrClassLimit
+		return
+	}
+	switch err {
+	case context.DeadlineExceeded:
+		ri.Error = ErrClassTimeout
+	case context.Canceled:
+		ri.Error = ErrClassCanceled
+	default:
+		ri.Error = ErrClassQuery
+	}
+}
+
+type reqInfoKey struct{}
+
+// WithRequestInfo attaches an empty RequestInfo to the request.
+// If the request already has one, it is returned as is, so a few middlewares can share it.
+func WithRequestInfo(r *http.Request) (*http.Request, *RequestInfo) {
+	if ri := GetRequestInfo(r); ri != nil {
+		return r, ri
+	}
+	ri := &RequestInfo{Results: -1, Quads: -1}
+	return r.WithContext(context.WithValue(r.Context(), reqInfoKey{}, ri)), ri
+}
+
+// GetRequestInfo returns a RequestInfo attached to the request, or nil.
+func GetRequestInfo(r *http.Request) *RequestInfo {
+	ri, _ := r.Context().Value(reqInfoKey{}).(*RequestInfo)
+	return ri
+}
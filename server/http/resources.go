@@ -0,0 +1,377 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/server/http/model"
+	"github.com/cayleygraph/cayley/voc"
+)
+
+// RegisterResourcesOn registers resource-style methods of API v2.
+func (api *APIv2) RegisterResourcesOn(r *httprouter.Router, wrappers ...HandlerWrapper) {
+	r.GET("/api/v2/quads", wrap(api.ServeListQuads, wrappers))
+	r.GET("/api/v2/nodes", wrap(api.ServeListNodes, wrappers))
+	r.GET("/api/v2/node", wrap(api.ServeGetNode, wrappers))
+	r.GET("/api/v2/sample", wrap(api.ServeSampleNodes, wrappers))
+	r.GET("/api/v2/walks", wrap(api.ServeRandomWalks, wrappers))
+	r.GET("/api/v2/namespaces", wrap(api.ServeListNamespaces, wrappers))
+	r.POST("/api/v2/namespaces", wrap(api.ServeRegisterNamespace, wrappers))
+	r.DELETE("/api/v2/namespaces/:prefix", wrap(api.ServeDeleteNamespace, wrappers))
+	r.POST("/api/v2/queries", wrap(api.ServeRunQuery, wrappers))
+	r.GET("/api/v2/jobs", wrap(api.ServeListJobs, wrappers))
+	r.POST("/api/v2/jobs", wrap(api.ServeCreateJob, wrappers))
+	r.GET("/api/v2/jobs/:id", wrap(api.ServeGetJob, wrappers))
+	r.DELETE("/api/v2/jobs/:id", wrap(api.ServeCancelJob, wrappers))
+	r.GET("/api/v2/openapi.yml", wrap(api.ServeSpec, wrappers))
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func readJSON(r *http.Request, v interface{}) error {
+	data, err := readLimit(r.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// requestLimit returns a limit set by the client, capped by the server limit.
+func (api *APIv2) requestLimit(r *http.Request) (int, error) {
+	limit := api.conf().limit
+	if s := r.FormValue("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid limit: %q", s)
+		}
+		if limit <= 0 || n < limit {
+			limit = n
+		}
+	}
+	return limit, nil
+}
+
+// iterateQuads collects up to limit quads from an iterator.
+func iterateQuads(ctx context.Context, qs graph.QuadStore, it graph.Iterator, limit int) ([]model.Quad, error) {
+	defer it.Close()
+	out := make([]model.Quad, 0)
+	for (limit <= 0 || len(out) < limit) && it.Next(ctx) {
+		out = append(out, model.NewQuad(qs.Quad(it.Result())))
+	}
+	return out, it.Err()
+}
+
+func (api *APIv2) ServeListQuads(w http.ResponseWriter, r *http.Request) {
+	limit, err := api.requestLimit(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	filter, fkey, err := quadFilter(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	var after *cursor
+	if s := r.FormValue("cursor"); s != "" {
+		if after, err = parseCursor(s); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := api.queryContext(r)
+	defer cancel()
+	quads, next, err := readPage(ctx, h.QuadStore, filter, fkey, after, limit)
+	if err == errInvalidCursor {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := model.QuadList{Quads: make([]model.Quad, 0, len(quads))}
+	for _, q := range quads {
+		out.Quads = append(out.Quads, model.NewQuad(q))
+	}
+	if next != nil {
+		out.Next = next.String()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (api *APIv2) ServeListNodes(w http.ResponseWriter, r *http.Request) {
+	limit, err := api.requestLimit(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := api.queryContext(r)
+	defer cancel()
+	it := h.QuadStore.NodesAllIterator()
+	defer it.Close()
+	nodes := make([]string, 0)
+	for (limit <= 0 || len(nodes) < limit) && it.Next(ctx) {
+		nodes = append(nodes, model.Value(h.QuadStore.NameOf(it.Result())))
+	}
+	if err := it.Err(); err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, model.NodeList{Nodes: nodes})
+}
+
+func (api *APIv2) ServeGetNode(w http.ResponseWriter, r *http.Request) {
+	limit, err := api.requestLimit(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		jsonResponse(w, http.StatusBadRequest, "node id is not specified")
+		return
+	}
+	v, err := model.ParseValue(id)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	qs := h.QuadStore
+	gv := qs.ValueOf(v)
+	if gv == nil {
+		jsonResponse(w, http.StatusNotFound, graph.ErrNodeNotExists)
+		return
+	}
+	ctx, cancel := api.queryContext(r)
+	defer cancel()
+	node := model.Node{ID: model.Value(v)}
+	if node.In, err = iterateQuads(ctx, qs, qs.QuadIterator(quad.Object, gv), limit); err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	if node.Out, err = iterateQuads(ctx, qs, qs.QuadIterator(quad.Subject, gv), limit); err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, node)
+}
+
+// ServeListNamespaces lists namespaces registered in this process, together with ones persisted in the database.
+// Namespaces of the database are not registered globally, thus each store keeps its own list.
+func (api *APIv2) ServeListNamespaces(w http.ResponseWriter, r *http.Request) {
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	nss := voc.Clone()
+	if err = graph.LoadNamespaces(r.Context(), h.QuadStore, nss); err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	list := nss.List()
+	sort.Sort(voc.ByFullName(list))
+	out := model.NamespaceList{Namespaces: make([]model.Namespace, 0, len(list))}
+	for _, ns := range list {
+		out.Namespaces = append(out.Namespaces, model.Namespace{Prefix: ns.Prefix, IRI: ns.Full})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// ServeRegisterNamespace persists a namespace in the database metadata. It requires admin access,
+// since namespaces are shared by all clients of the database.
+func (api *APIv2) ServeRegisterNamespace(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if api.conf().ro {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return
+	} else if !api.checkAdmin(w, r) {
+		return
+	}
+	var ns model.Namespace
+	if err := readJSON(r, &ns); err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	} else if ns.Prefix == "" || ns.IRI == "" {
+		jsonResponse(w, http.StatusBadRequest, "both prefix and iri should be set")
+		return
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	err = graph.AddNamespace(r.Context(), h.QuadStore, voc.Namespace{Prefix: ns.Prefix, Full: ns.IRI})
+	if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, errors.New("backend cannot persist namespaces"))
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ns)
+}
+
+// ServeDeleteNamespace removes a namespace from the database metadata. It requires admin access.
+func (api *APIv2) ServeDeleteNamespace(w http.ResponseWriter, r *http.Request) {
+	if api.conf().ro {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return
+	} else if !api.checkAdmin(w, r) {
+		return
+	}
+	pref := strings.TrimPrefix(r.URL.Path, "/api/v2/namespaces/")
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	err = graph.DeleteNamespace(r.Context(), h.QuadStore, pref)
+	if err == graph.ErrNamespaceNotExists {
+		jsonResponse(w, http.StatusNotFound, "namespace not found")
+		return
+	} else if err == graph.ErrNotSupported {
+		jsonResponse(w, http.StatusNotImplemented, errors.New("backend cannot persist namespaces"))
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// queryLanguage returns a query language that can be used to collect results, or an error.
+func queryLanguage(name string) (*query.Language, error) {
+	if name == "" {
+		return nil, errors.New("query language not specified")
+	}
+	l := query.GetLanguage(name)
+	if l == nil {
+		return nil, errors.New("unknown query language")
+	} else if l.HTTP == nil {
+		return nil, errors.New("HTTP interface is not supported for this query language")
+	}
+	return l, nil
+}
+
+// execQuery runs a query and collects results in a format of a given language.
+// If execution limits are exceeded, query.LimitError is returned.
+func execQuery(ctx context.Context, qs graph.QuadStore, l *query.Language, qu string, limit int, lim query.Limits) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx, qs, budget := query.WithLimits(ctx, qs, lim)
+	ses := l.HTTP(query.Instrument(ctx, qs))
+	c := make(chan query.Result, 5)
+	go ses.Execute(ctx, qu, c, budget.ResultLimit(limit))
+	for res := range c {
+		err := res.Err()
+		if err == nil {
+			err = budget.AddResult()
+		}
+		if err != nil {
+			// let the session exit
+			cancel()
+			for range c {
+			}
+			if lerr := budget.Err(); lerr != nil {
+				return nil, lerr
+			}
+			return nil, err
+		}
+		ses.Collate(res)
+	}
+	if err := budget.Err(); err != nil {
+		return nil, err
+	}
+	return ses.Results()
+}
+
+func (api *APIv2) ServeRunQuery(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req model.QueryRequest
+	if err := readJSON(r, &req); err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	l, err := queryLanguage(req.Lang)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	} else if req.Query == "" {
+		jsonResponse(w, http.StatusBadRequest, "query is empty")
+		return
+	}
+	conf := api.conf()
+	limit := conf.limit
+	if req.Limit > 0 && (limit <= 0 || req.Limit < limit) {
+		limit = req.Limit
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := api.queryContext(r)
+	defer cancel()
+	ctx, done := api.trackQuery(ctx, model.ActiveQuery{Lang: req.Lang, Query: req.Query, Remote: r.RemoteAddr})
+	defer done()
+	ri := GetRequestInfo(r)
+	ri.SetLang(req.Lang)
+	ri.SetQuery(req.Query)
+	out, err := execQuery(ctx, h.QuadStore, l, req.Query, limit, conf.limits)
+	ri.SetError(err)
+	if WriteLimitError(w, err) {
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if rows, ok := resultRows(out); ok {
+		ri.SetResults(len(rows))
+	}
+	writeJSON(w, http.StatusOK, model.QueryResponse{Result: out})
+}
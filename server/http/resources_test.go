@@ -0,0 +1,313 @@
+package cayleyhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+	"github.com/cayleygraph/cayley/server/http/model"
+	"github.com/cayleygraph/cayley/voc"
+	"github.com/cayleygraph/cayley/writer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecOperations(t *testing.T) {
+	spec, err := LoadOpenAPI("../../docs/api/swagger.yml")
+	require.NoError(t, err)
+	require.NoError(t, spec.Check(model.Operations))
+}
+
+func TestResources(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", ""),
+		quad.MakeIRI("bob", "status", "cool", ""),
+	)
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	get := func(path string, code int, out interface{}) {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode)
+		if out != nil {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+		}
+	}
+
+	var quads model.QuadList
+	get("/api/v2/quads?sub="+url.QueryEscape("<bob>")+"&pred="+url.QueryEscape("<follows>"), http.StatusOK, &quads)
+	require.Equal(t, []model.Quad{{Subject: "<bob>", Predicate: "<follows>", Object: "<fred>"}}, quads.Quads)
+
+	get("/api/v2/quads?limit=2", http.StatusOK, &quads)
+	require.Len(t, quads.Quads, 2)
+
+	var node model.Node
+	get("/api/v2/node?id="+url.QueryEscape("<bob>"), http.StatusOK, &node)
+	require.Equal(t, "<bob>", node.ID)
+	require.Len(t, node.In, 1)
+	require.Len(t, node.Out, 2)
+
+	get("/api/v2/node?id="+url.QueryEscape("<nobody>"), http.StatusNotFound, nil)
+
+	var nodes model.NodeList
+	get("/api/v2/nodes", http.StatusOK, &nodes)
+	require.Len(t, nodes.Nodes, 6)
+
+	// async jobs require a query language with a HTTP interface
+	req, err := json.Marshal(model.QueryRequest{Lang: "unknown", Query: "x"})
+	require.NoError(t, err)
+	resp, err := http.Post(srv.URL+"/api/v2/jobs", contentTypeJSON, bytes.NewReader(req))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	get("/api/v2/jobs/missing", http.StatusNotFound, nil)
+	var jobs model.JobList
+	get("/api/v2/jobs", http.StatusOK, &jobs)
+	require.Empty(t, jobs.Jobs)
+}
+
+func TestSampling(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", ""),
+		quad.MakeIRI("bob", "status", "cool", ""),
+	)
+	defer h.Close()
+	api := NewAPIv2(h)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	get := func(path string, code int, out interface{}) {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode)
+		if out != nil {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+		}
+	}
+
+	var nodes model.NodeList
+	get("/api/v2/sample?n=4&seed=1", http.StatusOK, &nodes)
+	require.Len(t, nodes.Nodes, 4)
+	get("/api/v2/sample?n=0", http.StatusBadRequest, nil)
+
+	var walks model.WalkList
+	get("/api/v2/walks?start="+url.QueryEscape("<alice>")+"&pred="+url.QueryEscape("<follows>")+"&walks=2", http.StatusOK, &walks)
+	require.Equal(t, [][]string{
+		{"<alice>", "<bob>", "<fred>"},
+		{"<alice>", "<bob>", "<fred>"},
+	}, walks.Walks)
+
+	get("/api/v2/walks?start="+url.QueryEscape("<fred>")+"&undirected=true&length=3&p=0.5", http.StatusOK, &walks)
+	require.Len(t, walks.Walks, 1)
+	require.Equal(t, []string{"<fred>", "<bob>"}, walks.Walks[0][:2])
+
+	// start nodes are sampled; the number of walks is capped by the limit
+	api.SetQueryLimit(3)
+	get("/api/v2/walks?n=5&walks=2", http.StatusOK, &walks)
+	require.Len(t, walks.Walks, 2)
+
+	get("/api/v2/walks?q=-1", http.StatusBadRequest, nil)
+}
+
+func TestNamespaces(t *testing.T) {
+	qs, err := graph.NewQuadStore("btree", "", nil)
+	require.NoError(t, err)
+	wr, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	h := &graph.Handle{QuadStore: qs, QuadWriter: wr}
+	defer h.Close()
+	api := NewAPIv2(h)
+	api.SetAdminToken("secret")
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	do := func(method, path, token string, body interface{}) *http.Response {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req, err := http.NewRequest(method, srv.URL+path, &buf)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	list := func() []model.Namespace {
+		var list model.NamespaceList
+		resp := do("GET", "/api/v2/namespaces", "", nil)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		resp.Body.Close()
+		return list.Namespaces
+	}
+	ns := model.Namespace{Prefix: "nstest:", IRI: "http://example.com/nstest/"}
+	resp := do("POST", "/api/v2/namespaces", "", ns)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = do("POST", "/api/v2/namespaces", "secret", ns)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, list(), ns)
+
+	// namespaces are stored per database, not registered globally
+	require.Equal(t, "nstest:a", voc.FullIRI("nstest:a"))
+	ns2, err := graph.Namespaces(context.TODO(), qs)
+	require.NoError(t, err)
+	require.Equal(t, []voc.Namespace{{Prefix: ns.Prefix, Full: ns.IRI}}, ns2)
+
+	resp = do("DELETE", "/api/v2/namespaces/nstest:", "", nil)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = do("DELETE", "/api/v2/namespaces/nstest:", "secret", nil)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NotContains(t, list(), ns)
+
+	resp = do("DELETE", "/api/v2/namespaces/nstest:", "secret", nil)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestReadCursor(t *testing.T) {
+	expect := graphtest.MakeQuadSet()
+	h := makeHandle(t, expect...)
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	// list endpoint
+	var (
+		got  []model.Quad
+		next string
+	)
+	for i := 0; ; i++ {
+		require.True(t, i <= len(expect), "too many pages")
+		addr := srv.URL + "/api/v2/quads?limit=4"
+		if next != "" {
+			addr += "&cursor=" + next
+		}
+		resp, err := http.Get(addr)
+		require.NoError(t, err)
+		var page model.QuadList
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		require.NoError(t, err)
+		require.True(t, len(page.Quads) <= 4)
+		got = append(got, page.Quads...)
+		if page.Next == "" {
+			break
+		}
+		next = page.Next
+	}
+	require.Len(t, got, len(expect))
+
+	// read endpoint with a filter
+	var quads []quad.Quad
+	next = ""
+	for i := 0; ; i++ {
+		require.True(t, i <= len(expect), "too many pages")
+		addr := srv.URL + "/api/v2/read?format=nquads&limit=1&pred=" + url.QueryEscape("<follows>")
+		if next != "" {
+			addr += "&cursor=" + next
+		}
+		resp, err := http.Get(addr)
+		require.NoError(t, err)
+		page, err := quad.ReadAll(nquads.NewReader(resp.Body, false))
+		resp.Body.Close()
+		require.NoError(t, err)
+		quads = append(quads, page...)
+		if next = resp.Header.Get(hdrNextCursor); next == "" {
+			break
+		}
+	}
+	n := 0
+	for _, q := range expect {
+		if q.Predicate == quad.IRI("follows") {
+			n++
+		}
+	}
+	require.Len(t, quads, n)
+
+	resp, err := http.Get(srv.URL + "/api/v2/quads?cursor=garbage")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// noSeek hides graph.QuadSeeker implementation of the store.
+type noSeek struct {
+	graph.QuadStore
+}
+
+func TestReadPageRemoved(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		open func(quads []quad.Quad) graph.QuadStore
+	}{
+		{"memstore", func(quads []quad.Quad) graph.QuadStore {
+			return memstore.New(quads...)
+		}},
+		{"btree", func(quads []quad.Quad) graph.QuadStore {
+			qs, err := graph.NewQuadStore("btree", "", nil)
+			require.NoError(t, err)
+			w, err := writer.NewSingleReplication(qs, nil)
+			require.NoError(t, err)
+			require.NoError(t, w.AddQuadSet(quads))
+			return qs
+		}},
+		{"scan", func(quads []quad.Quad) graph.QuadStore {
+			return noSeek{memstore.New(quads...)}
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var quads []quad.Quad
+			for i := 0; i < 10; i++ {
+				quads = append(quads, quad.MakeIRI("a", "p", fmt.Sprintf("o%d", i), ""))
+			}
+			qs := c.open(quads)
+			defer qs.Close()
+			filter := quad.Quad{Predicate: quad.IRI("p")}
+			ctx := context.TODO()
+
+			page, next, err := readPage(ctx, qs, filter, "p", nil, 4)
+			require.NoError(t, err)
+			require.Equal(t, quads[:4], page)
+			require.NotNil(t, next)
+
+			// remove the quad the cursor points to
+			w, err := writer.NewSingleReplication(qs, nil)
+			require.NoError(t, err)
+			require.NoError(t, w.RemoveQuad(quads[3]))
+
+			page, next, err = readPage(ctx, qs, filter, "p", next, 4)
+			require.NoError(t, err)
+			require.Equal(t, quads[4:8], page)
+			require.NotNil(t, next)
+
+			page, next, err = readPage(ctx, qs, filter, "p", next, 4)
+			require.NoError(t, err)
+			require.Equal(t, quads[8:], page)
+			require.Nil(t, next)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// resultsColumn is a column name used for query results that are not objects.
+const resultsColumn = "result"
+
+// resultFormat is an encoding of query results.
+type resultFormat struct {
+	Name string
+	Mime string
+	// Write encodes results. Rows are nil if results are not a list.
+	Write func(w io.Writer, out interface{}, rows []interface{}) error
+	// List is set if the format can only encode a list of results.
+	List bool
+}
+
+var resultFormats = []resultFormat{
+	{Name: "json", Mime: contentTypeJSON, Write: writeResultsJSON},
+	{Name: "ndjson", Mime: "application/x-ndjson", Write: writeResultsNDJSON, List: true},
+	{Name: "csv", Mime: mimeCSV, Write: writeResultsCSV, List: true},
+	{Name: "sparql-json", Mime: mimeSPARQLJSON, Write: writeResultsSPARQL, List: true},
+}
+
+// resultFormatFor selects a format for query results.
+//
+// The format parameter takes precedence over the Accept header. If none of accepted
+// types are supported, JSON is used. Error is returned only for unknown format names.
+func resultFormatFor(r *http.Request) (*resultFormat, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for i := range resultFormats {
+			if resultFormats[i].Name == name {
+				return &resultFormats[i], nil
+			}
+		}
+		return nil, fmt.Errorf("unsupported result format: %q", name)
+	}
+	for _, s := range sortAccept(ParseAccept(r.Header, hdrAccept)) {
+		if s.Q <= 0 {
+			break
+		}
+		for i := range resultFormats {
+			if resultFormats[i].Mime == s.Value {
+				return &resultFormats[i], nil
+			}
+		}
+	}
+	return &resultFormats[0], nil
+}
+
+// resultRows returns query results as a list, if possible.
+func resultRows(out interface{}) ([]interface{}, bool) {
+	if arr, ok := out.([]interface{}); ok {
+		return arr, true
+	}
+	rv := reflect.ValueOf(out)
+	if !rv.IsValid() {
+		return nil, true
+	} else if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	rows := make([]interface{}, rv.Len())
+	for i := range rows {
+		rows[i] = rv.Index(i).Interface()
+	}
+	return rows, true
+}
+
+func writeResultsJSON(w io.Writer, out interface{}, _ []interface{}) error {
+	writeResults(w, out)
+	return nil
+}
+
+func writeResultsNDJSON(w io.Writer, _ interface{}, rows []interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resultColumns returns a sorted list of all keys of object results.
+func resultColumns(rows []interface{}) []string {
+	seen := make(map[string]struct{})
+	var cols []string
+	for _, r := range rows {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			m = map[string]interface{}{resultsColumn: r}
+		}
+		for k := range m {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				cols = append(cols, k)
+			}
+		}
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+func resultCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	case int, int64, float64, bool:
+		return fmt.Sprint(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func writeResultsCSV(w io.Writer, _ interface{}, rows []interface{}) error {
+	cols := resultColumns(rows)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+	rec := make([]string, len(cols))
+	for _, r := range rows {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			m = map[string]interface{}{resultsColumn: r}
+		}
+		for i, c := range cols {
+			rec[i] = resultCell(m[c])
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// nativeToValue converts a value returned by query languages back to a quad value.
+// IRIs and blank nodes are usually returned in N-Quads notation.
+func nativeToValue(v interface{}) quad.Value {
+	if s, ok := v.(string); ok {
+		switch {
+		case strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">"):
+			return quad.IRI(s[1 : len(s)-1])
+		case strings.HasPrefix(s, "_:"):
+			return quad.BNode(s[2:])
+		}
+		return quad.String(s)
+	}
+	if qv, ok := quad.AsValue(v); ok {
+		return qv
+	}
+	return quad.String(resultCell(v))
+}
+
+func writeResultsSPARQL(w io.Writer, _ interface{}, rows []interface{}) error {
+	res := &sparqlResults{vars: resultColumns(rows)}
+	for _, r := range rows {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			m = map[string]interface{}{resultsColumn: r}
+		}
+		row := make(map[string]quad.Value, len(m))
+		for k, v := range m {
+			if v != nil {
+				row[k] = nativeToValue(v)
+			}
+		}
+		res.rows = append(res.rows, row)
+	}
+	return writeSPARQLJSON(w, res)
+}
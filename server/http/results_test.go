@@ -0,0 +1,82 @@
+package cayleyhttp
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryResultFormats(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.MakeIRI("bob", "follows", "fred", ""),
+	)
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	const qu = `g.V("<alice>", "<bob>").Tag("src").Out("<follows>").All()`
+	get := func(params string, hdr http.Header) (*http.Response, string) {
+		req, err := http.NewRequest("GET", srv.URL+"/api/v2/query?lang=gizmo&qu="+url.QueryEscape(qu)+params, nil)
+		require.NoError(t, err)
+		for k, v := range hdr {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(data)
+	}
+
+	resp, body := get("", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, contentTypeJSON, resp.Header.Get(hdrContentType))
+	var res struct {
+		Result []map[string]string `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &res))
+	require.Len(t, res.Result, 2)
+
+	resp, body = get("&format=csv", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "id,src\n<bob>,<alice>\n<fred>,<bob>\n", body)
+
+	resp, body = get("", http.Header{hdrAccept: {"text/html;q=0.9, application/x-ndjson"}})
+	require.Equal(t, "application/x-ndjson", resp.Header.Get(hdrContentType))
+	require.Equal(t, `{"id":"<bob>","src":"<alice>"}`+"\n"+`{"id":"<fred>","src":"<bob>"}`+"\n", body)
+
+	resp, body = get("&format=sparql-json", nil)
+	var sres struct {
+		Head struct {
+			Vars []string `json:"vars"`
+		} `json:"head"`
+		Results struct {
+			Bindings []map[string]sparqlTerm `json:"bindings"`
+		} `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &sres))
+	require.Equal(t, []string{"id", "src"}, sres.Head.Vars)
+	require.Equal(t, sparqlTerm{Type: "uri", Value: "alice"}, sres.Results.Bindings[0]["src"])
+
+	resp, _ = get("&format=xml", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, body = get("&format=csv", http.Header{hdrAcceptEncoding: {"br, gzip;q=0.5"}})
+	require.Equal(t, "gzip", resp.Header.Get(hdrContentEncoding))
+	zr, err := gzip.NewReader(strings.NewReader(body))
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, "id,src\n<bob>,<alice>\n<fred>,<bob>\n", string(data))
+}
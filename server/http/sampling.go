@@ -0,0 +1,203 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+
+	"github.com/cayleygraph/cayley/graph/algo"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// defaultSampleSize is the number of nodes sampled if the client did not set it.
+const defaultSampleSize = 10
+
+// formInt parses an optional positive integer parameter.
+func formInt(r *http.Request, name string, def int) (int, error) {
+	s := r.FormValue(name)
+	if s == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, s)
+	}
+	return v, nil
+}
+
+// formRand returns a random source seeded by an optional "seed" parameter.
+func formRand(r *http.Request) (*rand.Rand, error) {
+	s := r.FormValue("seed")
+	if s == "" {
+		return nil, nil
+	}
+	seed, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seed: %q", s)
+	}
+	return rand.New(rand.NewSource(seed)), nil
+}
+
+// sampleSize returns the number of results requested with a given parameter, capped by the server limit.
+func (api *APIv2) sampleSize(r *http.Request, name string) (int, error) {
+	n, err := formInt(r, name, defaultSampleSize)
+	if err != nil {
+		return 0, err
+	}
+	if limit := api.conf().limit; limit > 0 && n > limit {
+		n = limit
+	}
+	return n, nil
+}
+
+func nodeList(vals []quad.Value) []string {
+	out := make([]string, 0, len(vals))
+	for _, v := range vals {
+		out = append(out, model.Value(v))
+	}
+	return out
+}
+
+// ServeSampleNodes returns nodes chosen uniformly at random.
+func (api *APIv2) ServeSampleNodes(w http.ResponseWriter, r *http.Request) {
+	n, err := api.sampleSize(r, "n")
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	rnd, err := formRand(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := api.queryContext(r)
+	defer cancel()
+	nodes, err := algo.SampleNodes(ctx, h.QuadStore, n, rnd)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, model.NodeList{Nodes: nodeList(nodes)})
+}
+
+// walkOptions parses parameters of random walks.
+func walkOptions(r *http.Request) (*algo.WalkOptions, error) {
+	opt := new(algo.WalkOptions)
+	var err error
+	if opt.Length, err = formInt(r, "length", 0); err != nil {
+		return nil, err
+	}
+	for _, p := range []struct {
+		name string
+		val  *float64
+	}{
+		{"p", &opt.Return},
+		{"q", &opt.InOut},
+	} {
+		if s := r.FormValue(p.name); s != "" {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil || v <= 0 {
+				return nil, fmt.Errorf("invalid %s: %q", p.name, s)
+			}
+			*p.val = v
+		}
+	}
+	if s := r.FormValue("undirected"); s != "" {
+		if opt.Undirected, err = strconv.ParseBool(s); err != nil {
+			return nil, err
+		}
+	}
+	if opt.Rand, err = formRand(r); err != nil {
+		return nil, err
+	}
+	// form is already parsed by FormValue
+	for _, s := range r.Form["pred"] {
+		v, err := model.ParseValue(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pred value: %v", err)
+		}
+		opt.Predicates = append(opt.Predicates, v)
+	}
+	return opt, nil
+}
+
+// ServeRandomWalks performs random walks from given nodes, or from nodes sampled at random
+// if no start nodes are set. The total number of walks is capped by the server limit.
+func (api *APIv2) ServeRandomWalks(w http.ResponseWriter, r *http.Request) {
+	opt, err := walkOptions(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	walks, err := formInt(r, "walks", 1)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	var starts []quad.Value
+	for _, s := range r.Form["start"] {
+		v, err := model.ParseValue(s)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid start value: %v", err))
+			return
+		}
+		starts = append(starts, v)
+	}
+	n := 0
+	if len(starts) == 0 {
+		if n, err = api.sampleSize(r, "n"); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := api.queryContext(r)
+	defer cancel()
+	if len(starts) == 0 {
+		if starts, err = algo.SampleNodes(ctx, h.QuadStore, n, opt.Rand); err != nil {
+			jsonResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if limit := api.conf().limit; limit > 0 && len(starts)*walks > limit {
+		if walks > limit {
+			walks = limit
+		}
+		starts = starts[:limit/walks]
+	}
+	list, err := algo.RandomWalks(ctx, h.QuadStore, starts, walks, opt)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := model.WalkList{Walks: make([][]string, 0, len(list))}
+	for _, p := range list {
+		out.Walks = append(out.Walks, nodeList(p))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
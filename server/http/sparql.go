@@ -0,0 +1,442 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// SPARQLLang is the name of the query language used by SPARQL Protocol endpoint.
+const SPARQLLang = "sparql"
+
+const (
+	mimeSPARQLQuery = "application/sparql-query"
+	mimeForm        = "application/x-www-form-urlencoded"
+
+	mimeSPARQLJSON = "application/sparql-results+json"
+	mimeSPARQLXML  = "application/sparql-results+xml"
+	mimeCSV        = "text/csv"
+	mimeTSV        = "text/tab-separated-values"
+)
+
+// sparqlResults is a buffered result of a SPARQL query.
+type sparqlResults struct {
+	vars []string
+	rows []map[string]quad.Value
+	// ask is set for boolean results
+	ask *bool
+}
+
+type sparqlResultWriter func(w io.Writer, res *sparqlResults) error
+
+var sparqlFormats = []struct {
+	mime  string
+	write sparqlResultWriter
+}{
+	{mime: mimeSPARQLJSON, write: writeSPARQLJSON},
+	{mime: mimeSPARQLXML, write: writeSPARQLXML},
+	{mime: mimeCSV, write: writeSPARQLCSV},
+	{mime: mimeTSV, write: writeSPARQLTSV},
+}
+
+// sparqlFormat selects the result format according to the Accept header.
+// It returns an empty mime type if none of the requested formats are supported.
+func sparqlFormat(r *http.Request) (string, sparqlResultWriter) {
+	specs := ParseAccept(r.Header, hdrAccept)
+	if len(specs) == 0 {
+		return mimeSPARQLJSON, writeSPARQLJSON
+	}
+	var (
+		best  string
+		write sparqlResultWriter
+		q     float64
+	)
+	for _, s := range specs {
+		if s.Q <= q {
+			continue
+		}
+		switch s.Value {
+		case "*/*", "application/*", contentTypeJSON:
+			best, write, q = mimeSPARQLJSON, writeSPARQLJSON, s.Q
+			continue
+		case "text/*":
+			best, write, q = mimeCSV, writeSPARQLCSV, s.Q
+			continue
+		}
+		for _, f := range sparqlFormats {
+			if f.mime == s.Value {
+				best, write, q = f.mime, f.write, s.Q
+				break
+			}
+		}
+	}
+	return best, write
+}
+
+// sparqlQuery extracts the query string from the request according to SPARQL 1.1 Protocol.
+func sparqlQuery(r *http.Request) (string, error) {
+	var qu string
+	switch r.Method {
+	case "GET":
+		vals := r.URL.Query()
+		if vals.Get("update") != "" {
+			return "", errors.New("SPARQL Update is not supported")
+		}
+		qu = vals.Get("query")
+	case "POST":
+		ct, _, _ := mime.ParseMediaType(r.Header.Get(hdrContentType))
+		switch ct {
+		case mimeForm:
+			r.Body = http.MaxBytesReader(nil, r.Body, maxQuerySize)
+			if err := r.ParseForm(); err != nil {
+				return "", err
+			}
+			if r.PostForm.Get("update") != "" {
+				return "", errors.New("SPARQL Update is not supported")
+			}
+			qu = r.PostForm.Get("query")
+		case mimeSPARQLQuery:
+			data, err := readLimit(r.Body)
+			if err != nil {
+				return "", err
+			}
+			qu = string(data)
+		default:
+			return "", errors.New("unsupported content type: " + ct)
+		}
+	default:
+		return "", errors.New("unsupported method: " + r.Method)
+	}
+	if qu == "" {
+		return "", errors.New("query is empty")
+	}
+	q := r.URL.Query()
+	if q.Get("default-graph-uri") != "" || q.Get("named-graph-uri") != "" ||
+		r.PostForm.Get("default-graph-uri") != "" || r.PostForm.Get("named-graph-uri") != "" {
+		return "", errors.New("RDF dataset specification is not supported")
+	}
+	return qu, nil
+}
+
+// RegisterSPARQLOn registers SPARQL 1.1 Protocol query endpoint that runs queries with a given language.
+// It is registered by RegisterQueryOn only if a query language named "sparql" is available.
+func (api *APIv2) RegisterSPARQLOn(r *httprouter.Router, lang *query.Language, wrappers ...HandlerWrapper) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		api.serveSPARQL(w, r, lang)
+	}
+	r.GET("/sparql", wrap(h, wrappers))
+	r.POST("/sparql", wrap(h, wrappers))
+}
+
+// serveSPARQL implements SPARQL 1.1 Protocol query operation.
+func (api *APIv2) serveSPARQL(w http.ResponseWriter, r *http.Request, lang *query.Language) {
+	ctx, cancel := api.queryContext(r)
+	defer cancel()
+	mt, write := sparqlFormat(r)
+	if write == nil {
+		jsonResponse(w, http.StatusNotAcceptable, "requested result format is not supported")
+		return
+	}
+	qu, err := sparqlQuery(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	ri := GetRequestInfo(r)
+	ri.SetLang(SPARQLLang)
+	ri.SetQuery(qu)
+	ctx, done := api.trackQuery(ctx, model.ActiveQuery{Lang: SPARQLLang, Query: qu, Remote: r.RemoteAddr})
+	defer done()
+	ctx, qs, budget := query.WithLimits(ctx, h.QuadStore, api.conf().limits)
+	ses := lang.Session(query.Instrument(ctx, qs))
+	if clog.V(1) {
+		clog.Infof("query: %s: %q", SPARQLLang, qu)
+	}
+
+	c := make(chan query.Result, 5)
+	go ses.Execute(ctx, qu, c, budget.ResultLimit(api.conf().limit))
+
+	res := &sparqlResults{}
+	vars := make(map[string]struct{})
+	for r := range c {
+		if err = r.Err(); err != nil {
+			break
+		} else if err = budget.AddResult(); err != nil {
+			break
+		}
+		switch v := r.Result().(type) {
+		case bool:
+			res.ask = &v
+		case map[string]graph.Value:
+			row := make(map[string]quad.Value, len(v))
+			for k, gv := range v {
+				vars[k] = struct{}{}
+				row[k] = qs.NameOf(gv)
+			}
+			res.rows = append(res.rows, row)
+		case map[string]quad.Value:
+			for k := range v {
+				vars[k] = struct{}{}
+			}
+			res.rows = append(res.rows, v)
+		default:
+			err = errors.New("unsupported result type")
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err != nil {
+		// drain the channel to let the session exit
+		cancel()
+		for range c {
+		}
+	}
+	if lerr := budget.Err(); lerr != nil {
+		err = lerr
+	}
+	ri.SetError(err)
+	if WriteLimitError(w, err) {
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	ri.SetResults(len(res.rows))
+	for k := range vars {
+		res.vars = append(res.vars, k)
+	}
+	sort.Strings(res.vars)
+
+	w.Header().Set(hdrContentType, mt+"; charset=utf-8")
+	if err = write(w, res); err != nil {
+		clog.Errorf("sparql: cannot write results: %v", err)
+	}
+}
+
+// sparqlTerm is a value representation used in SPARQL results.
+type sparqlTerm struct {
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Datatype string `json:"datatype,omitempty"`
+	Lang     string `json:"xml:lang,omitempty"`
+}
+
+func toSPARQLTerm(v quad.Value) sparqlTerm {
+	if ts, ok := v.(quad.TypedStringer); ok {
+		v = ts.TypedString()
+	}
+	switch v := v.(type) {
+	case quad.IRI:
+		return sparqlTerm{Type: "uri", Value: string(v.Full())}
+	case quad.BNode:
+		return sparqlTerm{Type: "bnode", Value: string(v)}
+	case quad.String:
+		return sparqlTerm{Type: "literal", Value: string(v)}
+	case quad.LangString:
+		return sparqlTerm{Type: "literal", Value: string(v.Value), Lang: v.Lang}
+	case quad.TypedString:
+		return sparqlTerm{Type: "literal", Value: string(v.Value), Datatype: string(v.Type.Full())}
+	}
+	return sparqlTerm{Type: "literal", Value: quad.StringOf(v)}
+}
+
+func writeSPARQLJSON(w io.Writer, res *sparqlResults) error {
+	type head struct {
+		Vars []string `json:"vars"`
+	}
+	if res.ask != nil {
+		return json.NewEncoder(w).Encode(struct {
+			Head    struct{} `json:"head"`
+			Boolean bool     `json:"boolean"`
+		}{Boolean: *res.ask})
+	}
+	bindings := make([]map[string]sparqlTerm, 0, len(res.rows))
+	for _, row := range res.rows {
+		b := make(map[string]sparqlTerm, len(row))
+		for k, v := range row {
+			if v == nil {
+				continue
+			}
+			b[k] = toSPARQLTerm(v)
+		}
+		bindings = append(bindings, b)
+	}
+	var out struct {
+		Head    head `json:"head"`
+		Results struct {
+			Bindings []map[string]sparqlTerm `json:"bindings"`
+		} `json:"results"`
+	}
+	out.Head.Vars = res.vars
+	if out.Head.Vars == nil {
+		out.Head.Vars = []string{}
+	}
+	out.Results.Bindings = bindings
+	return json.NewEncoder(w).Encode(out)
+}
+
+func writeSPARQLXML(w io.Writer, res *sparqlResults) error {
+	if _, err := io.WriteString(w, xml.Header+`<sparql xmlns="http://www.w3.org/2005/sparql-results#">`+"\n"); err != nil {
+		return err
+	}
+	esc := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	var b strings.Builder
+	b.WriteString("<head>")
+	if res.ask == nil {
+		for _, v := range res.vars {
+			b.WriteString(`<variable name="` + esc(v) + `"/>`)
+		}
+	}
+	b.WriteString("</head>\n")
+	if res.ask != nil {
+		b.WriteString("<boolean>" + strconv.FormatBool(*res.ask) + "</boolean>\n")
+	} else {
+		b.WriteString("<results>\n")
+		for _, row := range res.rows {
+			b.WriteString("<result>")
+			for _, k := range res.vars {
+				v := row[k]
+				if v == nil {
+					continue
+				}
+				t := toSPARQLTerm(v)
+				b.WriteString(`<binding name="` + esc(k) + `">`)
+				switch t.Type {
+				case "uri":
+					b.WriteString("<uri>" + esc(t.Value) + "</uri>")
+				case "bnode":
+					b.WriteString("<bnode>" + esc(t.Value) + "</bnode>")
+				default:
+					b.WriteString("<literal")
+					if t.Datatype != "" {
+						b.WriteString(` datatype="` + esc(t.Datatype) + `"`)
+					} else if t.Lang != "" {
+						b.WriteString(` xml:lang="` + esc(t.Lang) + `"`)
+					}
+					b.WriteString(">" + esc(t.Value) + "</literal>")
+				}
+				b.WriteString("</binding>")
+			}
+			b.WriteString("</result>\n")
+		}
+		b.WriteString("</results>\n")
+	}
+	b.WriteString("</sparql>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeSPARQLCSV(w io.Writer, res *sparqlResults) error {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	if res.ask != nil {
+		cw.Write([]string{"boolean"})
+		cw.Write([]string{strconv.FormatBool(*res.ask)})
+		cw.Flush()
+		return cw.Error()
+	}
+	cw.Write(res.vars)
+	rec := make([]string, len(res.vars))
+	for _, row := range res.rows {
+		for i, k := range res.vars {
+			rec[i] = ""
+			v := row[k]
+			if v == nil {
+				continue
+			}
+			t := toSPARQLTerm(v)
+			if t.Type == "bnode" {
+				rec[i] = "_:" + t.Value
+			} else {
+				rec[i] = t.Value
+			}
+		}
+		cw.Write(rec)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeSPARQLTSV(w io.Writer, res *sparqlResults) error {
+	var b strings.Builder
+	if res.ask != nil {
+		b.WriteString("?boolean\n" + strconv.FormatBool(*res.ask) + "\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	for i, k := range res.vars {
+		if i != 0 {
+			b.WriteByte('\t')
+		}
+		b.WriteString("?" + k)
+	}
+	b.WriteByte('\n')
+	for _, row := range res.rows {
+		for i, k := range res.vars {
+			if i != 0 {
+				b.WriteByte('\t')
+			}
+			v := row[k]
+			if v == nil {
+				continue
+			}
+			t := toSPARQLTerm(v)
+			switch t.Type {
+			case "uri":
+				b.WriteString(quad.IRI(t.Value).String())
+			case "bnode":
+				b.WriteString(quad.BNode(t.Value).String())
+			default:
+				var lit quad.Value = quad.String(t.Value)
+				if t.Datatype != "" {
+					lit = quad.TypedString{Value: quad.String(t.Value), Type: quad.IRI(t.Datatype)}
+				} else if t.Lang != "" {
+					lit = quad.LangString{Value: quad.String(t.Value), Lang: t.Lang}
+				}
+				b.WriteString(lit.String())
+			}
+		}
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,142 @@
+package cayleyhttp
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	"github.com/stretchr/testify/require"
+)
+
+// testSPARQLSession returns all subject-object pairs for a predicate given as a query.
+type testSPARQLSession struct {
+	qs graph.QuadStore
+}
+
+func (s *testSPARQLSession) Execute(ctx context.Context, qu string, out chan query.Result, limit int) {
+	defer close(out)
+	if qu == "ASK" {
+		out <- testAskResult(true)
+		return
+	}
+	it := s.qs.QuadsAllIterator()
+	defer it.Close()
+	for it.Next(ctx) {
+		q := s.qs.Quad(it.Result())
+		if q.Predicate != quad.IRI(qu) {
+			continue
+		}
+		out <- query.TagMapResult(map[string]graph.Value{
+			"s": s.qs.ValueOf(q.Subject),
+			"o": s.qs.ValueOf(q.Object),
+		})
+	}
+}
+
+type testAskResult bool
+
+func (r testAskResult) Result() interface{} { return bool(r) }
+func (testAskResult) Err() error            { return nil }
+
+func TestSPARQLProtocol(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.Make(quad.IRI("bob"), quad.IRI("name"), quad.LangString{Value: "Bob", Lang: "en"}, nil),
+	)
+	defer h.Close()
+	api := NewAPIv2(h)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	// not registered without SPARQL query language
+	resp, err := http.Get(srv.URL + "/sparql?query=" + url.QueryEscape("follows"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	api.RegisterSPARQLOn(api.r, &query.Language{
+		Name: SPARQLLang,
+		Session: func(qs graph.QuadStore) query.Session {
+			return &testSPARQLSession{qs: qs}
+		},
+	})
+
+	// GET with default format
+	resp, err = http.Get(srv.URL + "/sparql?query=" + url.QueryEscape("follows"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, strings.HasPrefix(resp.Header.Get(hdrContentType), mimeSPARQLJSON))
+	var out struct {
+		Head struct {
+			Vars []string `json:"vars"`
+		} `json:"head"`
+		Results struct {
+			Bindings []map[string]sparqlTerm `json:"bindings"`
+		} `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	resp.Body.Close()
+	require.Equal(t, []string{"o", "s"}, out.Head.Vars)
+	require.Equal(t, []map[string]sparqlTerm{{
+		"s": {Type: "uri", Value: "alice"},
+		"o": {Type: "uri", Value: "bob"},
+	}}, out.Results.Bindings)
+
+	// POST form with CSV
+	req, err := http.NewRequest("POST", srv.URL+"/sparql", strings.NewReader(url.Values{"query": {"name"}}.Encode()))
+	require.NoError(t, err)
+	req.Header.Set(hdrContentType, mimeForm)
+	req.Header.Set(hdrAccept, mimeCSV)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "o,s\r\nBob,bob\r\n", string(data))
+
+	// POST query with TSV
+	req, err = http.NewRequest("POST", srv.URL+"/sparql", strings.NewReader("name"))
+	require.NoError(t, err)
+	req.Header.Set(hdrContentType, mimeSPARQLQuery)
+	req.Header.Set(hdrAccept, mimeTSV)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	data, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "?o\t?s\n\"Bob\"@en\t<bob>\n", string(data))
+
+	// ASK with XML
+	req, err = http.NewRequest("GET", srv.URL+"/sparql?query=ASK", nil)
+	require.NoError(t, err)
+	req.Header.Set(hdrAccept, mimeSPARQLXML)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	data, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Contains(t, string(data), "<boolean>true</boolean>")
+
+	// protocol errors
+	resp, err = http.Get(srv.URL + "/sparql")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	req, err = http.NewRequest("GET", srv.URL+"/sparql?query=follows", nil)
+	require.NoError(t, err)
+	req.Header.Set(hdrAccept, "image/png")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// parseTxQuad decodes a quad of a transaction and sets a default label on it, if the quad has no label.
+func parseTxQuad(q model.Quad, label quad.Value) (quad.Quad, error) {
+	out, err := parseTxPattern(q, label)
+	if err != nil {
+		return quad.Quad{}, err
+	} else if !out.IsValid() {
+		return quad.Quad{}, fmt.Errorf("invalid quad: %v", out)
+	}
+	return out, nil
+}
+
+// txActions maps actions of transaction operations to delta actions.
+var txActions = map[string]graph.Procedure{
+	"add":             graph.Add,
+	"delete":          graph.Delete,
+	"add_unique":      graph.AddUnique,
+	"replace_object":  graph.ReplaceObject,
+	"delete_matching": graph.DeleteMatching,
+}
+
+// parseTxPattern decodes a quad pattern of a transaction. Empty directions of the pattern match any value.
+func parseTxPattern(q model.Quad, label quad.Value) (quad.Quad, error) {
+	out, err := q.Quad()
+	if err != nil {
+		return quad.Quad{}, err
+	}
+	if out.Label == nil {
+		out.Label = label
+	}
+	return out, nil
+}
+
+// txErrorCode returns a response code for a failed transaction.
+func txErrorCode(err error) int {
+	switch {
+	case err == graph.ErrPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case err == graph.ErrNotSupported:
+		return http.StatusNotImplemented
+	case graph.IsConflict(err), graph.IsQuadExist(err), graph.IsQuadNotExist(err):
+		return http.StatusConflict
+	}
+	return writeErrorCode(err)
+}
+
+// ServeTransaction applies a list of operations in a single transaction.
+//
+// The transaction may carry preconditions that require some quads to exist or to be absent,
+// and a horizon the database must be at. Preconditions are checked against the current state of the database
+// and the transaction is applied only if the database was not modified after the check.
+func (api *APIv2) ServeTransaction(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if api.conf().ro {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return
+	}
+	var req model.Transaction
+	if err := readJSON(r, &req); err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	var label quad.Value
+	if s := r.FormValue("label"); s != "" {
+		v, err := model.ParseValue(s)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid label value: %v", err))
+			return
+		}
+		label = v
+	}
+	tx := graph.NewTransactionN(len(req.Ops))
+	for i, op := range req.Ops {
+		p, ok := txActions[op.Action]
+		if !ok {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("operation %d: unknown action: %q", i, op.Action))
+			return
+		}
+		var (
+			q   quad.Quad
+			err error
+		)
+		if p == graph.DeleteMatching {
+			q, err = parseTxPattern(op.Quad, label)
+		} else {
+			q, err = parseTxQuad(op.Quad, label)
+		}
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("operation %d: %v", i, err))
+			return
+		}
+		tx.Append(graph.Delta{Quad: q, Action: p})
+	}
+	conds := make([]graph.Precondition, 0, len(req.Require))
+	for i, c := range req.Require {
+		q, err := parseTxQuad(c.Quad, label)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, fmt.Errorf("precondition %d: %v", i, err))
+			return
+		}
+		conds = append(conds, graph.Precondition{Quad: q, Absent: c.Absent})
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx := r.Context()
+	if req.Horizon != nil {
+		// the transaction fails if the database was modified after the horizon,
+		// thus preconditions only need to be checked once
+		err = graph.CheckPreconditions(ctx, h.QuadStore, conds)
+		if err == nil {
+			err = graph.ApplyTransactionAt(h.QuadWriter, tx, *req.Horizon)
+		}
+	} else {
+		err = graph.ApplyIf(ctx, h.QuadStore, h.QuadWriter, tx, conds...)
+	}
+	if err != nil {
+		jsonResponse(w, txErrorCode(err), err)
+		return
+	}
+	n := len(tx.Deltas)
+	GetRequestInfo(r).SetQuads(n)
+	writeJSON(w, http.StatusOK, model.WriteResult{
+		Result: fmt.Sprintf("Successfully applied %d operations.", n),
+		Count:  n,
+	})
+}
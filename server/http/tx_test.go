@@ -0,0 +1,113 @@
+package cayleyhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+func TestTransaction(t *testing.T) {
+	addr, closer := makeServerV2(t, quad.MakeIRI("alice", "status", "offline", ""))
+	defer closer()
+
+	post := func(tx model.Transaction) (int, model.WriteResult) {
+		data, err := json.Marshal(tx)
+		require.NoError(t, err)
+		resp, err := http.Post(addr+"/api/v2/tx", contentTypeJSON, bytes.NewReader(data))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out model.WriteResult
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	offline := model.NewQuad(quad.MakeIRI("alice", "status", "offline", ""))
+	online := model.NewQuad(quad.MakeIRI("alice", "status", "online", ""))
+	swap := model.Transaction{
+		Require: []model.Precondition{{Quad: offline}},
+		Ops: []model.TxOp{
+			{Action: "delete", Quad: offline},
+			{Action: "add", Quad: online},
+		},
+	}
+	code, res := post(swap)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 2, res.Count)
+
+	// the quad was already changed
+	code, _ = post(swap)
+	require.Equal(t, http.StatusPreconditionFailed, code)
+
+	code, _ = post(model.Transaction{
+		Require: []model.Precondition{{Quad: online, Absent: true}},
+		Ops:     []model.TxOp{{Action: "add", Quad: online}},
+	})
+	require.Equal(t, http.StatusPreconditionFailed, code)
+
+	h := int64(0)
+	code, _ = post(model.Transaction{
+		Horizon: &h,
+		Ops:     []model.TxOp{{Action: "add", Quad: offline}},
+	})
+	require.Equal(t, http.StatusConflict, code)
+
+	code, _ = post(model.Transaction{
+		Ops: []model.TxOp{{Action: "replace", Quad: offline}},
+	})
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, res = post(model.Transaction{
+		Ops: []model.TxOp{
+			{Action: "replace_object", Quad: model.NewQuad(quad.MakeIRI("alice", "status", "away", ""))},
+			{Action: "delete_matching", Quad: model.Quad{Predicate: "<status>"}},
+			{Action: "add_unique", Quad: online},
+		},
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 3, res.Count)
+
+	code, _ = post(model.Transaction{
+		Ops: []model.TxOp{{Action: "add_unique", Quad: online}},
+	})
+	require.Equal(t, http.StatusConflict, code)
+}
+
+func TestWriteMode(t *testing.T) {
+	addr, closer := makeServerV2(t, quad.MakeIRI("alice", "status", "offline", ""))
+	defer closer()
+
+	write := func(mode, data string) int {
+		resp, err := http.Post(addr+"/api/v2/write?mode="+mode, "application/n-quads", bytes.NewBufferString(data))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusConflict, write("unique", "<alice> <status> <offline> .\n"))
+	require.Equal(t, http.StatusOK, write("replace", "<alice> <status> <online> .\n"))
+	require.Equal(t, http.StatusOK, write("unique", "<alice> <status> <offline> .\n"))
+	require.Equal(t, http.StatusBadRequest, write("merge", "<alice> <status> <offline> .\n"))
+}
+
+func TestWriteExpiration(t *testing.T) {
+	addr, closer := makeServerV2(t)
+	defer closer()
+
+	write := func(params, data string) int {
+		resp, err := http.Post(addr+"/api/v2/write?"+params, "application/n-quads", bytes.NewBufferString(data))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusOK, write("ttl=1h", "<alice> <session> <s1> .\n"))
+	require.Equal(t, http.StatusConflict, write("mode=unique", "<alice> <session> <s1> .\n"))
+	// expired quads are ignored by the unique mode
+	require.Equal(t, http.StatusOK, write("expires=2000-01-01T00:00:00Z", "<bob> <session> <s2> .\n"))
+	require.Equal(t, http.StatusOK, write("mode=unique&ttl=1m", "<bob> <session> <s2> .\n"))
+	require.Equal(t, http.StatusBadRequest, write("ttl=soon", "<bob> <session> <s3> .\n"))
+	require.Equal(t, http.StatusBadRequest, write("ttl=1h&expires=2000-01-01T00:00:00Z", "<bob> <session> <s3> .\n"))
+}
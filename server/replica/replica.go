@@ -0,0 +1,302 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replica implements asynchronous read replicas.
+//
+// A replica copies all quads from a primary instance on the first start and then tails the delta log
+// of the primary over HTTP, applying transactions locally in the same order. The horizon of the primary
+// applied by the replica is stored in the database metadata, thus replication resumes after a restart.
+// The primary must be opened with the "delta_log" option.
+package replica
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/client"
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http/model"
+)
+
+// horizonKey is a metadata key for the horizon of the primary applied by the replica.
+const horizonKey = "replica:horizon"
+
+// DefaultInterval is a default interval between polls of the primary.
+const DefaultInterval = time.Second
+
+// ErrNotEmpty is returned when the replica is started for the first time on a non-empty database.
+var ErrNotEmpty = errors.New("replica: local database must be empty on the first start")
+
+// Config is a configuration of a read replica.
+type Config struct {
+	// Interval between polls of the primary. DefaultInterval is used if not set.
+	Interval time.Duration
+}
+
+// Replica applies changes from the primary instance to a local QuadStore.
+type Replica struct {
+	qs   graph.QuadStore
+	addr string
+	cli  *client.Client
+	conf Config
+
+	mu     sync.RWMutex
+	status model.ReplicaStatus
+}
+
+// New creates a replica of an instance with a given address that writes to qs.
+// All other writes to qs must be disabled.
+func New(qs graph.QuadStore, addr string, conf Config) *Replica {
+	if conf.Interval <= 0 {
+		conf.Interval = DefaultInterval
+	}
+	return &Replica{
+		qs: qs, addr: addr, conf: conf,
+		cli:    client.New(addr),
+		status: model.ReplicaStatus{Primary: addr},
+	}
+}
+
+// Status returns the current state of replication.
+func (r *Replica) Status() model.ReplicaStatus {
+	r.mu.RLock()
+	st := r.status
+	r.mu.RUnlock()
+	if !st.LastSync.IsZero() && (st.Lag != 0 || st.Error != "") {
+		st.LagSeconds = time.Since(st.LastSync).Seconds()
+	}
+	return st
+}
+
+func (r *Replica) setError(err error) {
+	r.mu.Lock()
+	r.status.Error = err.Error()
+	r.mu.Unlock()
+}
+
+func (r *Replica) setHorizons(h, ph int64) {
+	r.mu.Lock()
+	r.status.Horizon, r.status.PrimaryHorizon = h, ph
+	r.status.Lag = ph - h
+	if r.status.Lag < 0 {
+		r.status.Lag = 0
+	}
+	if r.status.Lag == 0 {
+		r.status.LastSync = time.Now()
+	}
+	r.status.Error = ""
+	r.mu.Unlock()
+}
+
+// Horizon returns the horizon of the primary applied by the replica.
+// It returns false if the replica was not initialized yet.
+func (r *Replica) Horizon(ctx context.Context) (int64, bool, error) {
+	data, err := graph.GetMetadata(ctx, r.qs, horizonKey)
+	if err != nil || data == nil {
+		return 0, false, err
+	}
+	if len(data) != 8 {
+		return 0, false, fmt.Errorf("replica: invalid horizon record")
+	}
+	return int64(binary.BigEndian.Uint64(data)), true, nil
+}
+
+func (r *Replica) setHorizon(ctx context.Context, h int64) error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(h))
+	return graph.SetMetadata(ctx, r.qs, horizonKey, buf[:])
+}
+
+// Init copies all quads from the primary if the replica is started for the first time.
+// It returns the horizon of the primary applied by the replica.
+func (r *Replica) Init(ctx context.Context) (int64, error) {
+	h, ok, err := r.Horizon(ctx)
+	if err != nil || ok {
+		return h, err
+	}
+	it := r.qs.QuadsAllIterator()
+	empty := !it.Next(ctx)
+	err = it.Err()
+	it.Close()
+	if err != nil {
+		return 0, err
+	} else if !empty {
+		return 0, ErrNotEmpty
+	}
+	// read the horizon before copying the data: changes applied during the copy
+	// are applied once again from the delta log, which is idempotent
+	h, err = r.cli.Horizon(ctx)
+	if err != nil {
+		return 0, err
+	}
+	clog.Infof("replica: copying quads from %s", r.addr)
+	n, err := r.copyQuads()
+	if err != nil {
+		return 0, err
+	}
+	clog.Infof("replica: copied %d quads at horizon %d", n, h)
+	if err = r.setHorizon(ctx, h); err != nil {
+		return 0, err
+	}
+	return h, nil
+}
+
+func (r *Replica) copyQuads() (int, error) {
+	qr, err := r.cli.QuadReader()
+	if err != nil {
+		return 0, err
+	}
+	defer qr.Close()
+	var (
+		n      int
+		deltas = make([]graph.Delta, 0, quad.DefaultBatch)
+	)
+	flush := func() error {
+		if len(deltas) == 0 {
+			return nil
+		}
+		err := r.qs.ApplyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true})
+		n += len(deltas)
+		deltas = deltas[:0]
+		return err
+	}
+	for {
+		q, err := qr.ReadQuad()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return n, err
+		}
+		deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Add})
+		if len(deltas) == cap(deltas) {
+			if err = flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, flush()
+}
+
+func (r *Replica) apply(ctx context.Context, e model.LogEntry) error {
+	deltas := make([]graph.Delta, 0, len(e.Deltas))
+	for _, d := range e.Deltas {
+		q, err := d.Quad.Quad()
+		if err != nil {
+			return err
+		}
+		var act graph.Procedure
+		switch d.Action {
+		case graph.Add.String():
+			act = graph.Add
+		case graph.Delete.String():
+			act = graph.Delete
+		default:
+			return fmt.Errorf("replica: unknown action %q in transaction %d", d.Action, e.Horizon)
+		}
+		deltas = append(deltas, graph.Delta{Quad: q, Action: act})
+	}
+	if len(deltas) != 0 {
+		// transactions may be applied more than once if the replica was interrupted
+		err := r.qs.ApplyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true})
+		if err != nil {
+			return err
+		}
+	}
+	return r.setHorizon(ctx, e.Horizon)
+}
+
+// Sync applies all transactions recorded by the primary after the horizon h.
+// It returns the horizon of the primary applied by the replica.
+func (r *Replica) Sync(ctx context.Context, h int64) (int64, error) {
+	ph, err := r.cli.Horizon(ctx)
+	if err != nil {
+		return h, err
+	} else if ph < h {
+		return h, fmt.Errorf("replica: primary is behind the replica (%d < %d)", ph, h)
+	}
+	r.setHorizons(h, ph)
+	for h < ph {
+		list, err := r.cli.LogEntries(ctx, h, ph)
+		if err != nil {
+			return h, err
+		}
+		for _, e := range list.Entries {
+			if err = r.apply(ctx, e); err != nil {
+				return h, err
+			}
+			h = e.Horizon
+			r.setHorizons(h, ph)
+		}
+		next := ph
+		if list.Next != 0 {
+			next = list.Next
+		}
+		if next != h {
+			// transactions that are not recorded in the log were skipped
+			if err = r.setHorizon(ctx, next); err != nil {
+				return h, err
+			}
+			h = next
+			r.setHorizons(h, ph)
+		}
+	}
+	return h, nil
+}
+
+// Run initializes the replica and applies changes from the primary until the context is cancelled.
+// Replication errors are reported in the status and the sync is retried after an interval.
+func (r *Replica) Run(ctx context.Context) error {
+	var (
+		h   int64
+		err error
+	)
+	for {
+		h, err = r.Init(ctx)
+		if err == nil {
+			break
+		} else if err == ErrNotEmpty || ctx.Err() != nil {
+			return err
+		}
+		clog.Errorf("replica: cannot initialize: %v", err)
+		r.setError(err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.conf.Interval):
+		}
+	}
+	ticker := time.NewTicker(r.conf.Interval)
+	defer ticker.Stop()
+	for {
+		h, err = r.Sync(ctx, h)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			clog.Errorf("replica: sync failed: %v", err)
+			r.setError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package replica
+
+import (
+	"context"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	_ "github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/quad"
+	cayleyhttp "github.com/cayleygraph/cayley/server/http"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func readAll(t *testing.T, qs graph.QuadStore) []quad.Quad {
+	quads, err := quad.ReadAll(graph.NewQuadStoreReader(qs))
+	require.NoError(t, err)
+	sort.Sort(quad.ByQuadString(quads))
+	return quads
+}
+
+func TestReplica(t *testing.T) {
+	ctx := context.TODO()
+	pqs, err := graph.NewQuadStore("btree", "", graph.Options{"delta_log": true})
+	require.NoError(t, err)
+	defer pqs.Close()
+	qw, err := graph.NewQuadWriter("single", pqs, graph.Options{})
+	require.NoError(t, err)
+	primary := &graph.Handle{QuadStore: pqs, QuadWriter: qw}
+	srv := httptest.NewServer(cayleyhttp.NewAPIv2(primary))
+	defer srv.Close()
+
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("a", "b", "c", "")))
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("a", "b", "d", "")))
+
+	qs, err := graph.NewQuadStore("btree", "", nil)
+	require.NoError(t, err)
+	defer qs.Close()
+	r := New(qs, srv.URL, Config{})
+
+	// the first start copies the data
+	h, err := r.Init(ctx)
+	require.NoError(t, err)
+	ph, err := graph.Horizon(ctx, pqs)
+	require.NoError(t, err)
+	require.Equal(t, ph, h)
+	require.Equal(t, readAll(t, pqs), readAll(t, qs))
+
+	tx := graph.NewTransaction()
+	tx.RemoveQuad(quad.MakeIRI("a", "b", "c", ""))
+	tx.AddQuad(quad.MakeIRI("a", "b", "e", ""))
+	require.NoError(t, qw.ApplyTransaction(tx))
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("e", "f", "g", "")))
+
+	st := r.Status()
+	require.Equal(t, srv.URL, st.Primary)
+
+	h, err = r.Sync(ctx, h)
+	require.NoError(t, err)
+	ph, err = graph.Horizon(ctx, pqs)
+	require.NoError(t, err)
+	require.Equal(t, ph, h)
+	require.Equal(t, readAll(t, pqs), readAll(t, qs))
+
+	st = r.Status()
+	require.Equal(t, h, st.Horizon)
+	require.Equal(t, ph, st.PrimaryHorizon)
+	require.Equal(t, int64(0), st.Lag)
+	require.Empty(t, st.Error)
+	require.False(t, st.LastSync.IsZero())
+
+	// the horizon is persisted, so a restarted replica resumes from it
+	r = New(qs, srv.URL, Config{})
+	h2, err := r.Init(ctx)
+	require.NoError(t, err)
+	require.Equal(t, h, h2)
+}
+
+func TestReplicaNotEmpty(t *testing.T) {
+	qs, err := graph.NewQuadStore("btree", "", nil)
+	require.NoError(t, err)
+	defer qs.Close()
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{
+		{Quad: quad.MakeIRI("a", "b", "c", ""), Action: graph.Add},
+	}, graph.IgnoreOpts{}))
+
+	r := New(qs, "http://127.0.0.1:0", Config{})
+	_, err = r.Init(context.TODO())
+	require.Equal(t, ErrNotEmpty, err)
+}
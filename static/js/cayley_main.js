@@ -13,9 +13,14 @@
 // limitations under the License.
 
 $(function() {
+  // writes from the UI are bound to the session cookie with a CSRF token
+  $.ajaxSetup({
+    headers: {"X-CSRF-Token": $("meta[name=csrf-token]").attr("content")}
+  });
+
   s = null;
   group = null;
-  Snap.load("/static/cayley.svg", function(d, err) {
+  Snap.load("static/cayley.svg", function(d, err) {
     //Snap(105,65).append(d);
     s = Snap("#logo").append(d);
     svg = Snap("svg");
@@ -88,11 +93,53 @@ $(function() {
 		})}, 7000))
 	}
 
+  // Minimal GraphQL mode: CodeMirror does not ship one.
+  CodeMirror.defineMode("graphql", function() {
+    var keywords = /^(query|mutation|fragment|on|true|false|null)$/;
+    return {
+      token: function(stream) {
+        if (stream.eatSpace()) {
+          return null;
+        }
+        if (stream.match("#")) {
+          stream.skipToEnd();
+          return "comment";
+        }
+        if (stream.match(/^"(?:[^"\\]|\\.)*"?/)) {
+          return "string";
+        }
+        if (stream.match(/^-?\d+(\.\d+)?/)) {
+          return "number";
+        }
+        if (stream.match(/^[$@][_A-Za-z][_0-9A-Za-z]*/)) {
+          return "variable-2";
+        }
+        if (stream.match(/^[_A-Za-z][_0-9A-Za-z]*/)) {
+          var word = stream.current();
+          if (keywords.test(word)) {
+            return "keyword";
+          }
+          return stream.match(/^\s*:/, false) ? "attribute" : "variable";
+        }
+        stream.next();
+        return null;
+      }
+    };
+  });
+
+  // Editor modes for each query language.
+  var queryModes = {
+    "gizmo": "javascript",
+    "mql": {name: "javascript", json: true},
+    "graphql": "graphql"
+  }
+
   if ($("#code").length != 0) {
     editor = CodeMirror.fromTextArea(document.getElementById("code"), {
       lineNumbers: true,
       matchBrackets: true,
       continueComments: "Enter",
+      mode: queryModes["gizmo"],
       //        extraKeys: {"Ctrl-Q": "toggleComment"}
     });
   } else{
@@ -121,6 +168,7 @@ $(function() {
       localStorage.setItem("cayleyQueryLang", type);
     }
     if (editor) {
+      editor.setOption("mode", queryModes[type])
       editor.setValue(getLastQueryStringFor(type))
     }
   }
@@ -172,6 +220,67 @@ $(function() {
   $("#graphql-dropdown").click(function() {
     switchTo("graphql")
   })
+
+  // Saved queries are stored in the browser as a list of {name, lang, query} objects.
+  var savedQueriesKey = "cayleyNamedQueries"
+
+  var loadSavedQueries = function() {
+    if (typeof(Storage) === "undefined") {
+      return []
+    }
+    try {
+      return JSON.parse(localStorage.getItem(savedQueriesKey)) || []
+    } catch (e) {
+      return []
+    }
+  }
+
+  var storeSavedQueries = function(list) {
+    if (typeof(Storage) !== "undefined") {
+      localStorage.setItem(savedQueriesKey, JSON.stringify(list))
+    }
+  }
+
+  var renderSavedQueries = function() {
+    var list = loadSavedQueries()
+    var menu = $("#saved-queries-list")
+    menu.empty()
+    if (list.length == 0) {
+      menu.append($("<li class='dropdown-header'>").text("No saved queries"))
+      return
+    }
+    $.each(list, function(i, q) {
+      var load = $("<a href='#'>").text(q.name + " (" + q.lang + ")").click(function(e) {
+        e.preventDefault()
+        switchTo(q.lang)
+        editor.setValue(q.query)
+      })
+      var del = $("<a href='#' class='pull-right' title='Delete'>&times;</a>").click(function(e) {
+        e.preventDefault()
+        e.stopPropagation()
+        storeSavedQueries($.grep(loadSavedQueries(), function(o) { return o.name !== q.name }))
+        renderSavedQueries()
+      })
+      menu.append($("<li>").append(del).append(load))
+    })
+  }
+
+  $("#save-query").click(function() {
+    if (!editor) {
+      return
+    }
+    var name = prompt("Save query as:")
+    if (!name) {
+      return
+    }
+    var list = $.grep(loadSavedQueries(), function(o) { return o.name !== name })
+    list.push({name: name, lang: selectedQueryLanguage, query: editor.getValue()})
+    list.sort(function(a, b) { return a.name < b.name ? -1 : (a.name > b.name ? 1 : 0) })
+    storeSavedQueries(list)
+    renderSavedQueries()
+  })
+
+  renderSavedQueries()
 });
 
 
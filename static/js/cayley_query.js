@@ -19,23 +19,257 @@ $(function() {
   output_editor = CodeMirror.fromTextArea(document.getElementById("output"), {
     lineNumbers: true,
     matchBrackets: true,
+    mode: {name: "javascript", json: true},
   });
 
+  // maximal number of nodes drawn in the graph view
+  var maxGraphNodes = 500
+
+  // resultRows extracts a list of rows from a query response.
+  // GraphQL responses are searched for the first list in the data object.
+  var resultRows = function(resp) {
+    var find = function(v) {
+      if ($.isArray(v)) {
+        return v
+      }
+      if (v !== null && typeof(v) === "object") {
+        for (var k in v) {
+          var r = find(v[k])
+          if (r !== null) {
+            return r
+          }
+        }
+      }
+      return null
+    }
+    if (resp.result !== undefined) {
+      if ($.isArray(resp.result)) {
+        return resp.result
+      }
+      return resp.result === null ? [] : [resp.result]
+    }
+    return find(resp.data) || []
+  }
+
+  var cellText = function(v) {
+    if (v === null || v === undefined) {
+      return ""
+    }
+    if (typeof(v) === "object") {
+      return JSON.stringify(v)
+    }
+    return String(v)
+  }
+
+  var renderTable = function(rows) {
+    var table = $("#output-table")
+    table.empty()
+    var cols = []
+    var seen = {}
+    $.each(rows, function(i, r) {
+      if (r === null || typeof(r) !== "object" || $.isArray(r)) {
+        r = {result: r}
+        rows[i] = r
+      }
+      for (var k in r) {
+        if (!seen[k]) {
+          seen[k] = true
+          cols.push(k)
+        }
+      }
+    })
+    cols.sort(function(a, b) {
+      // keep node ids in the first column
+      if (a === "id") return -1
+      if (b === "id") return 1
+      return a < b ? -1 : (a > b ? 1 : 0)
+    })
+    var head = $("<tr>")
+    $.each(cols, function(_, c) { head.append($("<th>").text(c)) })
+    table.append($("<thead>").append(head))
+    var body = $("<tbody>")
+    $.each(rows, function(_, r) {
+      var tr = $("<tr>")
+      $.each(cols, function(_, c) { tr.append($("<td>").text(cellText(r[c]))) })
+      body.append(tr)
+    })
+    table.append(body)
+  }
+
+  // buildGraph converts rows to nodes and edges. Rows with "source" and "target" tags are drawn
+  // as a single edge (like on the visualize page), otherwise each tag becomes an edge from the "id" node.
+  var buildGraph = function(rows) {
+    var g = {nodes: [], edges: []}
+    var nodes = {}
+    var addNode = function(id, color) {
+      id = cellText(id)
+      if (nodes[id] === undefined) {
+        if (g.nodes.length >= maxGraphNodes) {
+          return null
+        }
+        nodes[id] = true
+        g.nodes.push({id: id, label: id, x: Math.random(), y: Math.random(), size: 10, color: color})
+      }
+      return id
+    }
+    var addEdge = function(from, to, label) {
+      if (from === null || to === null) {
+        return
+      }
+      g.edges.push({id: "e" + g.edges.length, source: from, target: to, label: label, size: 5, color: "#ccc"})
+    }
+    $.each(rows, function(_, r) {
+      if (r === null || typeof(r) !== "object") {
+        addNode(r, "#001B8A")
+        return
+      }
+      if (r.source !== undefined && r.target !== undefined) {
+        addEdge(addNode(r.source, r.source_color || "#001B8A"), addNode(r.target, r.target_color || "#F09300"), "")
+        return
+      }
+      if (r.id === undefined) {
+        return
+      }
+      var id = addNode(r.id, "#001B8A")
+      for (var k in r) {
+        if (k !== "id" && r[k] !== null && typeof(r[k]) !== "object") {
+          addEdge(id, addNode(r[k], "#F09300"), k)
+        }
+      }
+    })
+    return g
+  }
+
+  var drawGraph = function(g) {
+    if (window.sigmaGraph !== undefined) {
+      sigmaGraph.stopForceAtlas2()
+      sigmaGraph.kill()
+      $("#visualize").text("")
+    }
+    sigmaGraph = new sigma({
+      graph: g,
+      container: "visualize",
+      settings: {
+        defaultNodeColor: "#ec5148"
+      }
+    });
+    sigmaGraph.startForceAtlas2();
+    sigmaGraph.forceatlas2.p.linLogMode = true;
+    // stop the layout after a while to save CPU
+    var drawn = sigmaGraph
+    setTimeout(function() { drawn.stopForceAtlas2() }, 5000)
+  }
+
+  // sigma cannot render into a hidden container, so the graph is drawn when its tab is shown
+  var pendingGraph = null
+  var renderGraph = function(rows) {
+    pendingGraph = buildGraph(rows)
+    if ($("#result-graph").hasClass("active")) {
+      drawGraph(pendingGraph)
+      pendingGraph = null
+    }
+  }
+  $("#result-tabs a[href='#result-graph']").on("shown.bs.tab", function() {
+    if (pendingGraph !== null) {
+      drawGraph(pendingGraph)
+      pendingGraph = null
+    }
+  })
+  $("#result-tabs a[href='#result-json']").on("shown.bs.tab", function() {
+    output_editor.refresh()
+  })
+
+  var showResults = function(resp) {
+    output_editor.setValue(JSON.stringify(resp, null, '\t'))
+    var rows = resultRows(resp)
+    $("#result-status").text(rows.length + " result(s)")
+    renderTable(rows)
+    renderGraph(rows)
+  }
+
   $("#run_button").click(function() {
     var data = editor.getValue()
-    $("#output").text(editor.getValue())
+    var lang = selectedQueryLanguage
     animate();
-    $.post("/api/v1/query/" + selectedQueryLanguage, data)
+    $.ajax({
+      type: "POST",
+      url: "api/v2/query?lang=" + encodeURIComponent(lang),
+      data: data,
+      contentType: "text/plain",
+      dataType: "text"
+    })
       .done(function(return_data) {
         if (typeof(Storage) !== "undefined") {
-          localStorage.setItem("cayleySavedQueries" + selectedQueryLanguage, data)
+          localStorage.setItem("cayleySavedQueries" + lang, data)
+        }
+        var resp
+        try {
+          resp = JSON.parse(return_data)
+        } catch (e) {
+          output_editor.setValue(return_data)
+          stopAndReset();
+          return
         }
-        output_editor.setValue(JSON.stringify(JSON.parse(return_data), null, '\t'))
+        showResults(resp)
         stopAndReset();
       })
       .fail(function(jqxhr, textStatus, errorThrown){
         output_editor.setValue(jqxhr.responseText)
+        $("#result-status").text("error")
         stopAndReset();
       })
   })
+
+  // Schema browser: lists registered namespaces and predicates used in the database.
+  var insertText = function(text) {
+    editor.replaceSelection(text)
+    editor.focus()
+  }
+
+  var loadNamespaces = function() {
+    $.getJSON("api/v2/namespaces").done(function(resp) {
+      var list = $("#schema-namespaces")
+      list.empty()
+      $.each(resp.namespaces || [], function(_, ns) {
+        list.append($("<li>").append(
+          $("<a>").attr("title", ns.iri).text(ns.prefix).click(function() { insertText(ns.prefix) })
+        ))
+      })
+    })
+  }
+
+  var loadPredicates = function() {
+    var list = $("#schema-predicates")
+    list.empty().append($("<li class='text-muted'>").text("loading..."))
+    $.ajax({
+      type: "POST",
+      url: "api/v2/query?lang=gizmo",
+      data: "g.V().OutPredicates().Unique().All()",
+      contentType: "text/plain",
+      dataType: "json"
+    })
+      .done(function(resp) {
+        list.empty()
+        $.each(resp.result || [], function(_, r) {
+          var p = r.id !== undefined ? r.id : r
+          list.append($("<li>").append(
+            $("<a>").text(cellText(p)).click(function() { insertText(JSON.stringify(cellText(p))) })
+          ))
+        })
+        if (list.children().length == 0) {
+          list.append($("<li class='text-muted'>").text("no predicates"))
+        }
+      })
+      .fail(function(jqxhr) {
+        list.empty().append($("<li class='text-danger'>").text(jqxhr.responseText))
+      })
+  }
+
+  $("#schema-refresh").click(function(e) {
+    e.preventDefault()
+    loadPredicates()
+  })
+
+  loadNamespaces()
+  loadPredicates()
 });
@@ -37,6 +37,15 @@ func (p *Namespaces) Register(ns Namespace) {
 	p.prefixes[ns.Prefix] = ns.Full
 }
 
+// Unregister removes a namespace with a given prefix from the list.
+func (p *Namespaces) Unregister(pref string) {
+	if !p.Safe {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+	}
+	delete(p.prefixes, pref)
+}
+
 // ShortIRI replaces a base IRI of a known vocabulary with it's prefix.
 //
 //	ShortIRI("http://www.w3.org/1999/02/22-rdf-syntax-ns#type") // returns "rdf:type"
@@ -130,6 +139,11 @@ func RegisterPrefix(pref string, ns string) {
 	Register(Namespace{Prefix: pref, Full: ns})
 }
 
+// Unregister removes a namespace with a given prefix from a global list.
+func Unregister(pref string) {
+	global.Unregister(pref)
+}
+
 // ShortIRI replaces a base IRI of a known vocabulary with it's prefix.
 //
 //	ShortIRI("http://www.w3.org/1999/02/22-rdf-syntax-ns#type") // returns "rdf:type"
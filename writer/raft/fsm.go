@@ -0,0 +1,301 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	hraft "github.com/hashicorp/raft"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+// appliedKey is a metadata key for the index of the last log entry applied to the store.
+const appliedKey = "raft_applied"
+
+const (
+	cmdVersion = 1
+
+	flagIgnoreDup     = 1 << 0
+	flagIgnoreMissing = 1 << 1
+)
+
+// encodeDeltas encodes deltas as a Raft log entry: a version byte, a byte with ignore flags
+// and a sequence of length-prefixed LogDelta messages.
+func encodeDeltas(in []graph.Delta, opts graph.IgnoreOpts) ([]byte, error) {
+	var flags byte
+	if opts.IgnoreDup {
+		flags |= flagIgnoreDup
+	}
+	if opts.IgnoreMissing {
+		flags |= flagIgnoreMissing
+	}
+	buf := []byte{cmdVersion, flags}
+	var tmp [binary.MaxVarintLen64]byte
+	for _, d := range in {
+		ld := proto.LogDelta{
+			Quad:   pquads.MakeQuad(d.Quad),
+			Action: int32(d.Action),
+		}
+		data, err := ld.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		n := binary.PutUvarint(tmp[:], uint64(len(data)))
+		buf = append(buf, tmp[:n]...)
+		buf = append(buf, data...)
+	}
+	return buf, nil
+}
+
+func decodeDeltas(data []byte) ([]graph.Delta, graph.IgnoreOpts, error) {
+	var opts graph.IgnoreOpts
+	if len(data) < 2 {
+		return nil, opts, errors.New("raft: log entry is too short")
+	} else if data[0] != cmdVersion {
+		return nil, opts, fmt.Errorf("raft: unsupported log entry version: %d", data[0])
+	}
+	opts.IgnoreDup = data[1]&flagIgnoreDup != 0
+	opts.IgnoreMissing = data[1]&flagIgnoreMissing != 0
+	data = data[2:]
+	var out []graph.Delta
+	for len(data) != 0 {
+		sz, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < sz {
+			return nil, opts, errors.New("raft: corrupted log entry")
+		}
+		data = data[n:]
+		var ld proto.LogDelta
+		if err := ld.Unmarshal(data[:sz]); err != nil {
+			return nil, opts, err
+		}
+		data = data[sz:]
+		out = append(out, graph.Delta{
+			Quad:   ld.Quad.ToNative(),
+			Action: graph.Procedure(ld.Action),
+		})
+	}
+	return out, opts, nil
+}
+
+var _ hraft.FSM = (*fsm)(nil)
+
+// fsm applies committed log entries to the store.
+//
+// The index of the last applied entry is persisted in the store metadata in the same transaction
+// with the deltas of the entry, thus entries are not applied twice after a restart.
+type fsm struct {
+	qs      graph.QuadStore
+	meta    graph.MetadataWriter // unwrapped store
+	applied uint64
+}
+
+func newFSM(qs graph.QuadStore) (*fsm, error) {
+	mw, ok := graph.Unwrap(qs).(graph.MetadataWriter)
+	if !ok {
+		return nil, errors.New("raft: backend cannot record applied index together with deltas")
+	}
+	f := &fsm{qs: qs, meta: mw}
+	data, err := mw.GetMetadata(context.TODO(), appliedKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 8 {
+		f.applied = binary.BigEndian.Uint64(data)
+	} else if data != nil {
+		return nil, errors.New("raft: invalid applied index record")
+	}
+	return f, nil
+}
+
+func encodeIndex(index uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, index)
+	return buf
+}
+
+// setApplied records the index of an entry that did not change the store.
+func (f *fsm) setApplied(index uint64) error {
+	if err := f.meta.SetMetadata(context.TODO(), appliedKey, encodeIndex(index)); err != nil {
+		return err
+	}
+	f.applied = index
+	return nil
+}
+
+// Apply implements raft.FSM. It returns an error of applying deltas, if any.
+//
+// Errors returned by the store are a part of the replicated state: all members reject the same writes.
+// Other errors are returned without recording the entry as applied, thus it is retried after a restart.
+func (f *fsm) Apply(l *hraft.Log) interface{} {
+	if l.Index <= f.applied {
+		return nil
+	}
+	deltas, opts, err := decodeDeltas(l.Data)
+	if err == nil {
+		err = f.meta.ApplyDeltasWithMetadata(deltas, opts, map[string][]byte{
+			appliedKey: encodeIndex(l.Index),
+		})
+		if err == nil {
+			f.applied = l.Index
+			return nil
+		} else if _, ok := err.(*graph.DeltaError); !ok {
+			return err
+		}
+	}
+	// the entry was rejected without changing the store
+	if err2 := f.setApplied(l.Index); err2 != nil {
+		return fmt.Errorf("raft: cannot record applied index: %v", err2)
+	}
+	return err
+}
+
+// Snapshot implements raft.FSM. It pins the current state of the store, if supported,
+// or copies all quads to memory otherwise.
+func (f *fsm) Snapshot() (hraft.FSMSnapshot, error) {
+	ctx := context.TODO()
+	s := &snapshot{index: f.applied}
+	if h, err := graph.Horizon(ctx, f.qs); err == nil {
+		view, err := graph.AtHorizon(ctx, f.qs, h)
+		if err == nil {
+			s.view = view
+			return s, nil
+		} else if err != graph.ErrNotSupported {
+			return nil, err
+		}
+	}
+	r := graph.NewQuadStoreReader(f.qs)
+	defer r.Close()
+	quads, err := quad.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s.quads = quads
+	return s, nil
+}
+
+// Restore implements raft.FSM. It replaces all quads in the store with the ones from the snapshot.
+// The snapshot is skipped if the store already has all the changes it contains.
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+	var hdr [8]byte
+	if _, err := io.ReadFull(rc, hdr[:]); err != nil {
+		return err
+	}
+	index := binary.BigEndian.Uint64(hdr[:])
+	if f.applied != 0 && index <= f.applied {
+		return nil
+	}
+	if err := clearStore(f.qs); err != nil {
+		return err
+	}
+	r := pquads.NewReader(rc, 0)
+	defer r.Close()
+	tx := graph.NewTransactionN(quad.DefaultBatch)
+	for {
+		q, err := r.ReadQuad()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		tx.AddQuad(q)
+		if len(tx.Deltas) >= quad.DefaultBatch {
+			if err = f.qs.ApplyDeltas(tx.Deltas, graph.IgnoreOpts{IgnoreDup: true}); err != nil {
+				return err
+			}
+			tx = graph.NewTransactionN(quad.DefaultBatch)
+		}
+	}
+	if len(tx.Deltas) != 0 {
+		if err := f.qs.ApplyDeltas(tx.Deltas, graph.IgnoreOpts{IgnoreDup: true}); err != nil {
+			return err
+		}
+	}
+	return f.setApplied(index)
+}
+
+// clearStore removes all quads from the store in batches.
+func clearStore(qs graph.QuadStore) error {
+	for {
+		r := graph.NewQuadStoreReader(qs)
+		tx := graph.NewTransactionN(quad.DefaultBatch)
+		for len(tx.Deltas) < quad.DefaultBatch {
+			q, err := r.ReadQuad()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				r.Close()
+				return err
+			}
+			tx.RemoveQuad(q)
+		}
+		r.Close()
+		if len(tx.Deltas) == 0 {
+			return nil
+		}
+		if err := qs.ApplyDeltas(tx.Deltas, graph.IgnoreOpts{IgnoreMissing: true}); err != nil {
+			return err
+		}
+	}
+}
+
+var _ hraft.FSMSnapshot = (*snapshot)(nil)
+
+// snapshot is a state of the store at a given log index. It is written as a big-endian index
+// followed by all quads in the pquads format.
+type snapshot struct {
+	index uint64
+	view  graph.QuadStore // pinned view of the store, if supported
+	quads []quad.Quad     // copy of all quads otherwise
+}
+
+func (s *snapshot) Persist(sink hraft.SnapshotSink) error {
+	if err := s.persist(sink); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) persist(w io.Writer) error {
+	var hdr [8]byte
+	binary.BigEndian.PutUint64(hdr[:], s.index)
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	pw := pquads.NewWriter(w, nil)
+	if s.view == nil {
+		_, err := quad.Copy(pw, quad.NewReader(s.quads))
+		return err
+	}
+	r := graph.NewQuadStoreReader(s.view)
+	defer r.Close()
+	_, err := quad.Copy(pw, r)
+	return err
+}
+
+func (s *snapshot) Release() {
+	if s.view != nil {
+		s.view.Close()
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package raft replicates writes to a quad store across a group of cayley processes using the Raft protocol.
+//
+// Each process keeps a full copy of the data in its own store. Writes are appended to a replicated log
+// by the leader and are applied to the stores of all members in the same order once committed, thus
+// writes are linearizable and the group keeps accepting them as long as the majority of members is alive.
+// Reads are served from the local store: they may be stale on followers, see Node.Sync for linearizable reads.
+//
+// The backend must apply deltas atomically (see graph.Capabilities), otherwise members may diverge.
+// It must also record the index of the applied log entry in the same transaction (see graph.MetadataWriter),
+// thus entries are not applied twice after a restart.
+package raft
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	hraft "github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+const (
+	// DefaultTimeout is the default time to wait for a write to be committed.
+	DefaultTimeout = 10 * time.Second
+	// DefaultSnapshots is the default number of snapshots retained on disk.
+	DefaultSnapshots = 2
+)
+
+// Peer is a member of the Raft group.
+type Peer struct {
+	ID      string // unique and stable name of the member
+	Address string // host:port of the Raft transport
+}
+
+// ParsePeers parses a comma-separated list of peers in the "id=host:port" form.
+func ParsePeers(s string) ([]Peer, error) {
+	var out []Peer
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		i := strings.Index(p, "=")
+		if i <= 0 || i == len(p)-1 {
+			return nil, fmt.Errorf("raft: invalid peer %q, expected id=host:port", p)
+		}
+		out = append(out, Peer{ID: p[:i], Address: p[i+1:]})
+	}
+	return out, nil
+}
+
+// Config is a configuration of the Raft node.
+type Config struct {
+	// ID is a unique and stable name of this node in the group.
+	ID string
+	// Bind is the address to listen on for the Raft traffic.
+	Bind string
+	// Advertise is the address other members use to reach this node. Bind address is used if not set.
+	Advertise string
+	// Dir is a directory for the Raft log and snapshots.
+	Dir string
+	// Peers is the initial list of members. It is only used to bootstrap a new group,
+	// thus all members should start with the same list. If it is empty, a single-node group is created.
+	Peers []Peer
+	// Timeout is the time to wait for a write to be committed. DefaultTimeout is used if not set.
+	Timeout time.Duration
+	// Snapshots is the number of snapshots retained on disk. DefaultSnapshots is used if not set.
+	Snapshots int
+}
+
+// ConfigFromOptions reads the node configuration from replication options.
+func ConfigFromOptions(opts graph.Options) (Config, error) {
+	var (
+		c   Config
+		err error
+	)
+	if c.ID, err = opts.StringKey("raft_id", ""); err != nil {
+		return c, err
+	}
+	if c.Bind, err = opts.StringKey("raft_bind", ""); err != nil {
+		return c, err
+	}
+	if c.Advertise, err = opts.StringKey("raft_advertise", ""); err != nil {
+		return c, err
+	}
+	if c.Dir, err = opts.StringKey("raft_dir", ""); err != nil {
+		return c, err
+	}
+	peers, err := opts.StringKey("raft_peers", "")
+	if err != nil {
+		return c, err
+	}
+	if c.Peers, err = ParsePeers(peers); err != nil {
+		return c, err
+	}
+	if c.Timeout, err = opts.DurationKey("raft_timeout", DefaultTimeout); err != nil {
+		return c, err
+	}
+	if c.Snapshots, err = opts.IntKey("raft_snapshots", DefaultSnapshots); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// NotLeaderError is returned when a write or a linearizable read is sent to a node that is not the leader.
+type NotLeaderError struct {
+	Leader string // address of the current leader; empty if it is not known
+}
+
+func (e *NotLeaderError) Error() string {
+	if e.Leader == "" {
+		return "raft: no leader is elected"
+	}
+	return "raft: not a leader; current leader is " + e.Leader
+}
+
+// Temporary reports that the request can be retried on the leader or after the election.
+func (e *NotLeaderError) Temporary() bool { return true }
+
+// IsNotLeader checks if an error is a NotLeaderError.
+func IsNotLeader(err error) bool {
+	_, ok := err.(*NotLeaderError)
+	return ok
+}
+
+// Node is a member of the Raft group that applies replicated writes to a local QuadStore.
+type Node struct {
+	qs      graph.QuadStore
+	raft    *hraft.Raft
+	trans   *hraft.NetworkTransport
+	logs    *raftboltdb.BoltStore
+	timeout time.Duration
+}
+
+// NewNode starts a Raft node for the QuadStore. A new group is bootstrapped if the directory has no Raft state.
+//
+// The QuadStore must not be written to directly while the node is running.
+func NewNode(qs graph.QuadStore, conf Config) (*Node, error) {
+	if !graph.CapabilitiesOf(qs).Transactions {
+		return nil, errors.New("raft: backend does not apply deltas atomically")
+	}
+	if conf.ID == "" {
+		return nil, errors.New("raft: node id is not set")
+	} else if conf.Bind == "" {
+		return nil, errors.New("raft: bind address is not set")
+	} else if conf.Dir == "" {
+		return nil, errors.New("raft: directory is not set")
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = DefaultTimeout
+	}
+	if conf.Snapshots <= 0 {
+		conf.Snapshots = DefaultSnapshots
+	}
+	if err := os.MkdirAll(conf.Dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := newFSM(qs)
+	if err != nil {
+		return nil, err
+	}
+	var adv net.Addr
+	if conf.Advertise != "" {
+		if adv, err = net.ResolveTCPAddr("tcp", conf.Advertise); err != nil {
+			return nil, err
+		}
+	}
+	snaps, err := hraft.NewFileSnapshotStore(conf.Dir, conf.Snapshots, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := raftboltdb.NewBoltStore(filepath.Join(conf.Dir, "raft.db"))
+	if err != nil {
+		return nil, err
+	}
+	exists, err := hraft.HasExistingState(logs, logs, snaps)
+	if err != nil {
+		logs.Close()
+		return nil, err
+	}
+	trans, err := hraft.NewTCPTransport(conf.Bind, adv, 3, conf.Timeout, os.Stderr)
+	if err != nil {
+		logs.Close()
+		return nil, err
+	}
+	rc := hraft.DefaultConfig()
+	rc.LocalID = hraft.ServerID(conf.ID)
+	r, err := hraft.NewRaft(rc, f, logs, logs, snaps, trans)
+	if err != nil {
+		trans.Close()
+		logs.Close()
+		return nil, err
+	}
+	n := &Node{qs: qs, raft: r, trans: trans, logs: logs, timeout: conf.Timeout}
+	if !exists {
+		var servers []hraft.Server
+		for _, p := range conf.Peers {
+			servers = append(servers, hraft.Server{
+				Suffrage: hraft.Voter,
+				ID:       hraft.ServerID(p.ID),
+				Address:  hraft.ServerAddress(p.Address),
+			})
+		}
+		if len(servers) == 0 {
+			servers = []hraft.Server{{
+				Suffrage: hraft.Voter,
+				ID:       rc.LocalID,
+				Address:  trans.LocalAddr(),
+			}}
+		}
+		err = r.BootstrapCluster(hraft.Configuration{Servers: servers}).Error()
+		if err != nil && err != hraft.ErrCantBootstrap {
+			n.Close()
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (n *Node) notLeader() error {
+	return &NotLeaderError{Leader: string(n.raft.Leader())}
+}
+
+// IsLeader checks if the node is the leader of the group.
+func (n *Node) IsLeader() bool {
+	return n.raft.State() == hraft.Leader
+}
+
+// Leader returns an address of the current leader, or an empty string if it is not known.
+func (n *Node) Leader() string {
+	return string(n.raft.Leader())
+}
+
+// ApplyDeltas replicates deltas through the group and waits until they are applied to the local store.
+// It returns *NotLeaderError if the node is not the leader.
+func (n *Node) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error {
+	if !n.IsLeader() {
+		return n.notLeader()
+	}
+	data, err := encodeDeltas(in, opts)
+	if err != nil {
+		return err
+	}
+	f := n.raft.Apply(data, n.timeout)
+	if err = f.Error(); err == hraft.ErrNotLeader || err == hraft.ErrLeadershipLost {
+		return n.notLeader()
+	} else if err != nil {
+		return err
+	}
+	if err, ok := f.Response().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// Sync confirms that the node is still the leader and waits until all committed writes are applied
+// to the local store. Reads from the local store that follow a successful call are linearizable.
+// It returns *NotLeaderError on followers.
+func (n *Node) Sync() error {
+	if !n.IsLeader() {
+		return n.notLeader()
+	}
+	if err := n.raft.VerifyLeader().Error(); err == hraft.ErrNotLeader || err == hraft.ErrLeadershipLost {
+		return n.notLeader()
+	} else if err != nil {
+		return err
+	}
+	return n.raft.Barrier(n.timeout).Error()
+}
+
+// Join adds a voting member to the group. It must be called on the leader.
+func (n *Node) Join(p Peer) error {
+	if !n.IsLeader() {
+		return n.notLeader()
+	}
+	return n.raft.AddVoter(hraft.ServerID(p.ID), hraft.ServerAddress(p.Address), 0, n.timeout).Error()
+}
+
+// Leave removes a member from the group. It must be called on the leader.
+func (n *Node) Leave(id string) error {
+	if !n.IsLeader() {
+		return n.notLeader()
+	}
+	return n.raft.RemoveServer(hraft.ServerID(id), 0, n.timeout).Error()
+}
+
+// Close stops the node. The QuadStore is not closed.
+func (n *Node) Close() error {
+	err := n.raft.Shutdown().Error()
+	if err2 := n.trans.Close(); err == nil {
+		err = err2
+	}
+	if err2 := n.logs.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+package raft
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	hraft "github.com/hashicorp/raft"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func newStore(t testing.TB, quads ...quad.Quad) graph.QuadStore {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	tx := graph.NewTransaction()
+	for _, q := range quads {
+		tx.AddQuad(q)
+	}
+	require.NoError(t, qs.ApplyDeltas(tx.Deltas, graph.IgnoreOpts{}))
+	return qs
+}
+
+func TestParsePeers(t *testing.T) {
+	peers, err := ParsePeers("a=10.0.0.1:7000, b=10.0.0.2:7000,")
+	require.NoError(t, err)
+	require.Equal(t, []Peer{
+		{ID: "a", Address: "10.0.0.1:7000"},
+		{ID: "b", Address: "10.0.0.2:7000"},
+	}, peers)
+
+	_, err = ParsePeers("a")
+	require.Error(t, err)
+}
+
+func TestEncodeDeltas(t *testing.T) {
+	in := []graph.Delta{
+		{Quad: quad.MakeIRI("a", "b", "c", ""), Action: graph.Add},
+		{Quad: quad.Make("a", "b", 1, "g"), Action: graph.Delete},
+	}
+	opts := graph.IgnoreOpts{IgnoreDup: true}
+	data, err := encodeDeltas(in, opts)
+	require.NoError(t, err)
+	out, opts2, err := decodeDeltas(data)
+	require.NoError(t, err)
+	require.Equal(t, opts, opts2)
+	require.Equal(t, in, out)
+}
+
+type bufSink struct {
+	bytes.Buffer
+}
+
+func (s *bufSink) ID() string    { return "test" }
+func (s *bufSink) Cancel() error { return nil }
+func (s *bufSink) Close() error  { return nil }
+
+func TestSnapshotRestore(t *testing.T) {
+	quads := []quad.Quad{
+		quad.MakeIRI("a", "b", "c", ""),
+		quad.MakeIRI("a", "b", "d", "g"),
+	}
+	f, err := newFSM(newStore(t, quads...))
+	require.NoError(t, err)
+	f.applied = 5
+	snap, err := f.Snapshot()
+	require.NoError(t, err)
+	var sink bufSink
+	require.NoError(t, snap.Persist(&sink))
+	snap.Release()
+
+	qs := newStore(t, quad.MakeIRI("x", "y", "z", ""))
+	f2, err := newFSM(qs)
+	require.NoError(t, err)
+	require.NoError(t, f2.Restore(ioutil.NopCloser(&sink.Buffer)))
+	require.Equal(t, uint64(5), f2.applied)
+
+	got, err := quad.ReadAll(graph.NewQuadStoreReader(qs))
+	require.NoError(t, err)
+	sort.Sort(quad.ByQuadString(got))
+	require.Equal(t, quads, got)
+
+	f2, err = newFSM(qs)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), f2.applied)
+}
+
+func TestApplyIndex(t *testing.T) {
+	_, err := newFSM(memstore.New())
+	require.Error(t, err)
+
+	qs := newStore(t)
+	f, err := newFSM(qs)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), f.applied)
+
+	q := quad.MakeIRI("a", "b", "c", "")
+	data, err := encodeDeltas([]graph.Delta{{Quad: q, Action: graph.Add}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.Nil(t, f.Apply(&hraft.Log{Index: 3, Data: data}))
+
+	f, err = newFSM(qs)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), f.applied)
+	// already applied
+	require.Nil(t, f.Apply(&hraft.Log{Index: 3, Data: data}))
+
+	// rejected writes are also recorded
+	err, _ = f.Apply(&hraft.Log{Index: 4, Data: data}).(error)
+	require.True(t, graph.IsQuadExist(err), "unexpected error: %v", err)
+	f, err = newFSM(qs)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), f.applied)
+}
+
+func TestSingleNode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley_raft")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	qs := newStore(t)
+	n, err := NewNode(qs, Config{ID: "a", Bind: "127.0.0.1:0", Dir: dir})
+	require.NoError(t, err)
+	w := NewWriter(n, graph.IgnoreOpts{})
+	defer w.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !n.IsLeader() {
+		require.True(t, time.Now().Before(deadline), "leader was not elected")
+		time.Sleep(50 * time.Millisecond)
+	}
+	q := quad.MakeIRI("a", "b", "c", "")
+	require.NoError(t, w.AddQuad(q))
+	require.NoError(t, n.Sync())
+	require.NotNil(t, qs.ValueOf(quad.IRI("c")))
+
+	err = w.AddQuad(q)
+	require.True(t, graph.IsQuadExist(err), "unexpected error: %v", err)
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raft
+
+import (
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func init() {
+	graph.RegisterWriter("raft", NewReplication)
+}
+
+// replicated routes all writes to the store through the Raft group.
+type replicated struct {
+	graph.QuadStore
+	n *Node
+}
+
+func (s replicated) ApplyDeltas(in []graph.Delta, opts graph.IgnoreOpts) error {
+	return s.n.ApplyDeltas(in, opts)
+}
+
+// Writer is a QuadWriter that replicates all writes through the Raft group.
+// Writes sent to a follower fail with *NotLeaderError.
+type Writer struct {
+	graph.QuadWriter
+	n *Node
+}
+
+// NewWriter creates a writer for a running node.
+func NewWriter(n *Node, opts graph.IgnoreOpts) *Writer {
+	qw, _ := writer.NewSingle(replicated{QuadStore: n.qs, n: n}, opts)
+	return &Writer{QuadWriter: qw, n: n}
+}
+
+// Node returns the Raft node used by the writer.
+func (w *Writer) Node() *Node {
+	return w.n
+}
+
+// Close stops the Raft node.
+func (w *Writer) Close() error {
+	err := w.QuadWriter.Close()
+	if err2 := w.n.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// NewReplication starts a Raft node for the QuadStore with a configuration from replication options
+// (see ConfigFromOptions) and returns a writer for it. Closing the writer stops the node.
+func NewReplication(qs graph.QuadStore, opts graph.Options) (graph.QuadWriter, error) {
+	ignoreMissing, err := opts.BoolKey("ignore_missing", graph.IgnoreMissing)
+	if err != nil {
+		return nil, err
+	}
+	ignoreDuplicate, err := opts.BoolKey("ignore_duplicate", graph.IgnoreDuplicates)
+	if err != nil {
+		return nil, err
+	}
+	label, err := opts.StringKey("default_label", "")
+	if err != nil {
+		return nil, err
+	}
+	conf, err := ConfigFromOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	n, err := NewNode(graph.UnwrapHandle(qs), conf)
+	if err != nil {
+		return nil, err
+	}
+	w := NewWriter(n, graph.IgnoreOpts{
+		IgnoreMissing: ignoreMissing,
+		IgnoreDup:     ignoreDuplicate,
+	})
+	if label == "" {
+		return w, nil
+	}
+	return graph.NewLabelWriter(w, quad.StringToValue(label)), nil
+}
@@ -15,6 +15,9 @@
 package writer
 
 import (
+	"context"
+	"time"
+
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/quad"
 )
@@ -46,10 +49,19 @@ func NewSingleReplication(qs graph.QuadStore, opts graph.Options) (graph.QuadWri
 		return nil, err
 	}
 
-	return NewSingle(qs, graph.IgnoreOpts{
+	label, err := opts.StringKey("default_label", "")
+	if err != nil {
+		return nil, err
+	}
+
+	qw, err := NewSingle(qs, graph.IgnoreOpts{
 		IgnoreMissing: ignoreMissing,
 		IgnoreDup:     ignoreDuplicate,
 	})
+	if err != nil || label == "" {
+		return qw, err
+	}
+	return graph.NewLabelWriter(qw, quad.StringToValue(label)), nil
 }
 
 func (s *Single) AddQuad(q quad.Quad) error {
@@ -114,6 +126,33 @@ func (s *Single) Close() error {
 	return nil
 }
 
+// ApplyTransaction applies the transaction. Extended actions are resolved before deltas are passed to the QuadStore,
+// thus QuadStore wrappers only observe Add and Delete deltas.
 func (s *Single) ApplyTransaction(t *graph.Transaction) error {
-	return s.qs.ApplyDeltas(t.Deltas, s.ignoreOpts)
+	return graph.ApplyResolved(context.TODO(), s.qs, t.Deltas, func(deltas []graph.Delta, h int64) error {
+		if h < 0 {
+			return s.qs.ApplyDeltas(deltas, s.ignoreOpts)
+		}
+		return graph.ApplyDeltas(s.qs, deltas, graph.IfHorizon(h), graph.WithIgnoreOpts(s.ignoreOpts))
+	})
+}
+
+var _ graph.HorizonWriter = (*Single)(nil)
+
+// ApplyTransactionAt implements graph.HorizonWriter.
+// It returns graph.ErrNotSupported if the QuadStore does not implement graph.HorizonStore.
+func (s *Single) ApplyTransactionAt(t *graph.Transaction, h int64) error {
+	return graph.ApplyDeltas(s.qs, t.Deltas, graph.IfHorizon(h), graph.WithIgnoreOpts(s.ignoreOpts))
+}
+
+var _ graph.ExpiringWriter = (*Single)(nil)
+
+// ApplyTransactionWithExpiration implements graph.ExpiringWriter.
+// It returns graph.ErrNotSupported if the QuadStore does not implement graph.ExpiringStore.
+func (s *Single) ApplyTransactionWithExpiration(t *graph.Transaction, expires time.Time) error {
+	deltas, err := graph.ResolveDeltas(context.TODO(), s.qs, t.Deltas)
+	if err != nil {
+		return err
+	}
+	return graph.ApplyDeltas(s.qs, deltas, graph.WithExpiration(expires), graph.WithIgnoreOpts(s.ignoreOpts))
 }